@@ -0,0 +1,283 @@
+package acks
+
+import (
+	"fmt"
+	"github.com/jitsucom/eventnative/safego"
+	"github.com/jitsucom/eventnative/uuid"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultTimeout = 15 * time.Minute
+	DefaultHistory = 1000
+
+	PendingStatus   = "pending"
+	DeliveredStatus = "delivered"
+	FailedStatus    = "failed"
+	TimeoutStatus   = "timeout"
+)
+
+//Instance is a singleton tracker
+var Instance = NewTracker(DefaultTimeout, DefaultHistory)
+
+//Config is a server.acks configuration
+type Config struct {
+	//batch is finished with timeout status if its deliveries haven't finished in time
+	TimeoutSec int `mapstructure:"timeout_sec"`
+	//count of the kept finished batches results
+	History int `mapstructure:"history"`
+	//allowed prefixes of webhook callback urls. Empty means webhook callbacks are disabled
+	CallbackUrls []string `mapstructure:"callback_urls"`
+}
+
+//Init set Instance
+func Init(config *Config) {
+	timeout := DefaultTimeout
+	if config.TimeoutSec > 0 {
+		timeout = time.Duration(config.TimeoutSec) * time.Second
+	}
+	Instance = NewTracker(timeout, config.History)
+	Instance.callbackUrls = config.CallbackUrls
+}
+
+//FailedDelivery is an event which hasn't been stored into the destination
+type FailedDelivery struct {
+	EventId       string `json:"event_id"`
+	DestinationId string `json:"destination_id"`
+	Error         string `json:"error"`
+}
+
+//Result is a delivery result of the batch. Status is delivered if all events have been stored (or skipped by filters)
+//in all their destinations, failed if at least one delivery has failed, timeout if deliveries haven't finished in time
+type Result struct {
+	BatchId  string           `json:"batch_id"`
+	Status   string           `json:"status"`
+	Events   int              `json:"events"`
+	Pending  int              `json:"pending"`
+	Failed   []FailedDelivery `json:"failed,omitempty"`
+	Created  time.Time        `json:"created"`
+	Finished *time.Time       `json:"finished,omitempty"`
+	//e.g. API token id: only the owner can read the result
+	Owner string `json:"-"`
+}
+
+//Batch is a chunk of events delivered by a source (HTTP batch, file, stream offsets range) which is acknowledged
+//by the callback only after all its events have been accepted by all destinations
+type Batch struct {
+	tracker  *Tracker
+	result   *Result
+	callback func(*Result)
+	sealed   bool
+	//pending deliveries count
+	pending int
+	timer   *time.Timer
+}
+
+//Tracker keeps pending deliveries (destination id + event id) of open batches. Destinations report deliveries
+//via Delivered, Skipped and Failed. Unknown deliveries (events without batches) are ignored
+type Tracker struct {
+	sync.Mutex
+
+	timeout time.Duration
+	//destination id + event id -> batches which wait for the delivery
+	pending map[string][]*Batch
+	//finished and open batches results by id. The oldest finished ones are evicted over history size
+	results  map[string]*Result
+	finished []string
+	history  int
+	//allowed webhook callback urls prefixes
+	callbackUrls []string
+}
+
+func NewTracker(timeout time.Duration, history int) *Tracker {
+	if history <= 0 {
+		history = DefaultHistory
+	}
+	return &Tracker{timeout: timeout, pending: map[string][]*Batch{}, results: map[string]*Result{}, history: history}
+}
+
+//NewBatch return open batch of the owner with the callback (might be nil) which is called once after Seal when the batch
+//is finished. Nil Tracker returns nil Batch: all its methods are no-op
+func (t *Tracker) NewBatch(owner string, callback func(*Result)) *Batch {
+	if t == nil {
+		return nil
+	}
+
+	result := &Result{BatchId: uuid.New(), Status: PendingStatus, Created: time.Now().UTC(), Owner: owner}
+	batch := &Batch{tracker: t, result: result, callback: callback}
+
+	t.Lock()
+	t.results[result.BatchId] = result
+	t.Unlock()
+
+	return batch
+}
+
+//Id return batch id or empty string if the batch is nil
+func (b *Batch) Id() string {
+	if b == nil {
+		return ""
+	}
+	return b.result.BatchId
+}
+
+//Add register the event which must be delivered into all destinations. Must be called before the event is consumed
+func (b *Batch) Add(eventId string, destinationIds []string) {
+	if b == nil || eventId == "" {
+		return
+	}
+
+	b.tracker.Lock()
+	defer b.tracker.Unlock()
+
+	b.result.Events++
+	for _, destinationId := range destinationIds {
+		key := deliveryKey(destinationId, eventId)
+		b.tracker.pending[key] = append(b.tracker.pending[key], b)
+		b.pending++
+	}
+	b.result.Pending = b.pending
+}
+
+//Seal mark that all batch events have been added: the callback is called when all deliveries are finished
+//or after the tracker timeout
+func (b *Batch) Seal() {
+	if b == nil {
+		return
+	}
+
+	b.tracker.Lock()
+	defer b.tracker.Unlock()
+
+	b.sealed = true
+	if b.pending == 0 {
+		b.tracker.finish(b, DeliveredStatus)
+		return
+	}
+	b.timer = time.AfterFunc(b.tracker.timeout, func() {
+		b.tracker.Lock()
+		defer b.tracker.Unlock()
+		if b.result.Finished == nil {
+			b.tracker.finish(b, TimeoutStatus)
+		}
+	})
+}
+
+//Delivered is called when the event has been stored into the destination
+func (t *Tracker) Delivered(destinationId, eventId string) {
+	t.resolve(destinationId, eventId, "")
+}
+
+//Skipped is called when the destination skipped the event (filter, sampling, etc.): it is considered delivered
+func (t *Tracker) Skipped(destinationId, eventId string) {
+	t.resolve(destinationId, eventId, "")
+}
+
+//Failed is called when the event hasn't been stored into the destination (e.g. it is written into fallback)
+func (t *Tracker) Failed(destinationId, eventId, errMsg string) {
+	if errMsg == "" {
+		errMsg = "unknown error"
+	}
+	t.resolve(destinationId, eventId, errMsg)
+}
+
+//Result return copy of the batch result
+func (t *Tracker) Result(batchId string) (Result, bool) {
+	if t == nil {
+		return Result{}, false
+	}
+
+	t.Lock()
+	defer t.Unlock()
+
+	result, ok := t.results[batchId]
+	if !ok {
+		return Result{}, false
+	}
+	return result.copy(), true
+}
+
+func (t *Tracker) resolve(destinationId, eventId, errMsg string) {
+	if t == nil || eventId == "" {
+		return
+	}
+
+	t.Lock()
+	defer t.Unlock()
+
+	key := deliveryKey(destinationId, eventId)
+	batches, ok := t.pending[key]
+	if !ok {
+		return
+	}
+	//the same event id might be added several times: the oldest delivery is resolved
+	batch := batches[0]
+	if len(batches) == 1 {
+		delete(t.pending, key)
+	} else {
+		t.pending[key] = batches[1:]
+	}
+
+	batch.pending--
+	batch.result.Pending = batch.pending
+	if errMsg != "" {
+		batch.result.Failed = append(batch.result.Failed, FailedDelivery{EventId: eventId, DestinationId: destinationId, Error: errMsg})
+	}
+	if batch.sealed && batch.pending == 0 && batch.result.Finished == nil {
+		status := DeliveredStatus
+		if len(batch.result.Failed) > 0 {
+			status = FailedStatus
+		}
+		t.finish(batch, status)
+	}
+}
+
+//finish set the batch status, remove its pending deliveries and call the callback asynchronously. Must be called under the lock
+func (t *Tracker) finish(batch *Batch, status string) {
+	if batch.timer != nil {
+		batch.timer.Stop()
+	}
+	if batch.pending > 0 {
+		for key, batches := range t.pending {
+			left := batches[:0]
+			for _, b := range batches {
+				if b != batch {
+					left = append(left, b)
+				}
+			}
+			if len(left) == 0 {
+				delete(t.pending, key)
+			} else {
+				t.pending[key] = left
+			}
+		}
+	}
+
+	now := time.Now().UTC()
+	batch.result.Status = status
+	batch.result.Finished = &now
+
+	t.finished = append(t.finished, batch.result.BatchId)
+	for len(t.finished) > t.history {
+		delete(t.results, t.finished[0])
+		t.finished = t.finished[1:]
+	}
+
+	if batch.callback != nil {
+		copied := batch.result.copy()
+		safego.Run(func() {
+			batch.callback(&copied)
+		})
+	}
+}
+
+func (r *Result) copy() Result {
+	copied := *r
+	copied.Failed = append([]FailedDelivery{}, r.Failed...)
+	return copied
+}
+
+func deliveryKey(destinationId, eventId string) string {
+	return fmt.Sprintf("%s#%s", destinationId, eventId)
+}
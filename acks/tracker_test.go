@@ -0,0 +1,86 @@
+package acks
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestTracker(t *testing.T) {
+	tracker := NewTracker(time.Minute, 2)
+	results := make(chan *Result, 10)
+	callback := func(result *Result) { results <- result }
+
+	delivered := tracker.NewBatch("token1", callback)
+	delivered.Add("1", []string{"pg", "bq"})
+	delivered.Add("2", []string{"pg"})
+	tracker.Delivered("pg", "1")
+	tracker.Delivered("pg", "unknown")
+	delivered.Seal()
+	tracker.Skipped("bq", "1")
+	//not finished yet
+	pending, ok := tracker.Result(delivered.Id())
+	require.True(t, ok)
+	require.Equal(t, PendingStatus, pending.Status)
+	require.Equal(t, 1, pending.Pending)
+	tracker.Delivered("pg", "2")
+
+	result := <-results
+	require.Equal(t, DeliveredStatus, result.Status)
+	require.Equal(t, 2, result.Events)
+	require.Equal(t, 0, result.Pending)
+	require.Equal(t, "token1", result.Owner)
+
+	failed := tracker.NewBatch("token1", callback)
+	failed.Add("3", []string{"pg"})
+	failed.Seal()
+	tracker.Failed("pg", "3", "connection refused")
+	result = <-results
+	require.Equal(t, FailedStatus, result.Status)
+	require.Equal(t, []FailedDelivery{{EventId: "3", DestinationId: "pg", Error: "connection refused"}}, result.Failed)
+
+	//empty batch is delivered on seal
+	empty := tracker.NewBatch("token1", callback)
+	empty.Seal()
+	require.Equal(t, DeliveredStatus, (<-results).Status)
+
+	//history size is 2
+	_, ok = tracker.Result(delivered.Id())
+	require.False(t, ok)
+
+	var disabled *Tracker
+	batch := disabled.NewBatch("token1", callback)
+	batch.Add("4", []string{"pg"})
+	batch.Seal()
+	require.Equal(t, "", batch.Id())
+}
+
+func TestTrackerTimeout(t *testing.T) {
+	tracker := NewTracker(10*time.Millisecond, 10)
+	results := make(chan *Result, 1)
+
+	batch := tracker.NewBatch("token1", func(result *Result) { results <- result })
+	batch.Add("1", []string{"pg"})
+	batch.Seal()
+
+	select {
+	case result := <-results:
+		require.Equal(t, TimeoutStatus, result.Status)
+		require.Equal(t, 1, result.Pending)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Batch hasn't been finished by timeout")
+	}
+	//late delivery is ignored
+	tracker.Delivered("pg", "1")
+	require.Empty(t, tracker.pending)
+}
+
+func TestWebhookCallback(t *testing.T) {
+	tracker := NewTracker(time.Minute, 10)
+	tracker.callbackUrls = []string{"https://source.com/acks"}
+
+	_, err := tracker.WebhookCallback("https://other.com/acks")
+	require.Error(t, err)
+	_, err = tracker.WebhookCallback("https://source.com/acks?id=1")
+	require.NoError(t, err)
+}
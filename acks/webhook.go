@@ -0,0 +1,61 @@
+package acks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/jitsucom/eventnative/logging"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const webhookAttempts = 3
+
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+//WebhookCallback return callback which POSTs the batch result JSON to the url (with retries) or error if the url
+//doesn't match any of configured server.acks.callback_urls prefixes
+func (t *Tracker) WebhookCallback(url string) (func(*Result), error) {
+	allowed := false
+	for _, prefix := range t.callbackUrls {
+		if strings.HasPrefix(url, prefix) {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return nil, fmt.Errorf("Callback url [%s] isn't allowed. Please configure its prefix in server.acks.callback_urls", url)
+	}
+
+	return func(result *Result) {
+		var err error
+		for attempt := 1; attempt <= webhookAttempts; attempt++ {
+			if err = sendWebhook(url, result); err == nil {
+				return
+			}
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+		logging.Errorf("[%s] Error sending batch acknowledgment to %s: %v", result.BatchId, url, err)
+	}, nil
+}
+
+func sendWebhook(url string, result *Result) error {
+	b, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	resp, err := webhookClient.Post(url, "application/json", bytes.NewBuffer(b))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("HTTP code = %d, body: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
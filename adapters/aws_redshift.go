@@ -7,6 +7,7 @@ import (
 	"github.com/jitsucom/eventnative/schema"
 	"github.com/jitsucom/eventnative/typing"
 	_ "github.com/lib/pq"
+	"strconv"
 	"strings"
 )
 
@@ -19,6 +20,7 @@ const (
     				json 'auto'
                     dateformat 'auto'
                     timeformat 'auto'`
+	deleteByPkTemplate = `DELETE FROM "%s"."%s" WHERE %s`
 )
 
 //AwsRedshift adapter for creating,patching (schema or table), copying data from s3 to redshift
@@ -36,6 +38,7 @@ func NewAwsRedshift(ctx context.Context, dsConfig *DataSourceConfig, s3Config *S
 		return nil, err
 	}
 
+	postgres.sqlTypes = SchemaToRedshift
 	return &AwsRedshift{dataSourceProxy: postgres, s3Config: s3Config}, nil
 }
 
@@ -73,13 +76,23 @@ func (ar *AwsRedshift) CreateDbSchema(dbSchemaName string) error {
 }
 
 //Insert provided object in AwsRedshift in stream mode
-func (ar *AwsRedshift) Insert(schema *schema.Table, valuesMap map[string]interface{}) error {
+//Redshift doesn't support INSERT ... ON CONFLICT: if table has primary key fields
+//rows with the same primary key are deleted and the object is inserted in one transaction
+func (ar *AwsRedshift) Insert(table *schema.Table, valuesMap map[string]interface{}) error {
 	wrappedTx, err := ar.OpenTx()
 	if err != nil {
 		return err
 	}
 
-	if err := ar.dataSourceProxy.InsertInTransaction(wrappedTx, schema, valuesMap); err != nil {
+	if len(table.PKFields) > 0 {
+		if err := ar.deleteByPk(wrappedTx, table, valuesMap); err != nil {
+			wrappedTx.Rollback()
+			return err
+		}
+		table = &schema.Table{Name: table.Name, Columns: table.Columns, Version: table.Version}
+	}
+
+	if err := ar.dataSourceProxy.InsertInTransaction(wrappedTx, table, valuesMap); err != nil {
 		wrappedTx.Rollback()
 		return err
 	}
@@ -87,14 +100,63 @@ func (ar *AwsRedshift) Insert(schema *schema.Table, valuesMap map[string]interfa
 	return wrappedTx.DirectCommit()
 }
 
+func (ar *AwsRedshift) deleteByPk(wrappedTx *Transaction, table *schema.Table, valuesMap map[string]interface{}) error {
+	var conditions []string
+	var values []interface{}
+	for i, pkField := range sortedPkFields(table.PKFields) {
+		value, ok := valuesMap[pkField]
+		if !ok {
+			return fmt.Errorf("Error inserting in %s table: primary key field [%s] is missing in the object", table.Name, pkField)
+		}
+		conditions = append(conditions, pkField+"=$"+strconv.Itoa(i+1))
+		values = append(values, value)
+	}
+
+	p := ar.dataSourceProxy
+	query := fmt.Sprintf(deleteByPkTemplate, p.config.Schema, table.Name, strings.Join(conditions, " AND "))
+	p.queryLogger.LogWithValues(query, values)
+	if _, err := wrappedTx.tx.ExecContext(p.ctx, query, values...); err != nil {
+		return fmt.Errorf("Error deleting from %s table by primary key %v: %v", table.Name, values, err)
+	}
+
+	return nil
+}
+
 //PatchTableSchema add new columns(from provided schema.Table) to existing table
 func (ar *AwsRedshift) PatchTableSchema(patchSchema *schema.Table) error {
+	plan, err := addColumnsPlan(patchSchema)
+	if err != nil {
+		return err
+	}
+
+	return ar.ApplyMigration(plan)
+}
+
+//MigrationStatements return ADD COLUMN statements of the plan
+//Redshift doesn't support column type changing (except varchar length)
+func (ar *AwsRedshift) MigrationStatements(plan *schema.MigrationPlan) ([]string, error) {
+	for _, step := range plan.Steps {
+		if step.Kind == schema.WidenColumnStep {
+			return nil, fmt.Errorf("Redshift doesn't support column [%s] type changing from: %s to: %s", step.Column, step.From.String(), step.To.String())
+		}
+	}
+
+	return ar.dataSourceProxy.MigrationStatements(plan)
+}
+
+//ApplyMigration execute migration plan statements in one transaction
+func (ar *AwsRedshift) ApplyMigration(plan *schema.MigrationPlan) error {
+	statements, err := ar.MigrationStatements(plan)
+	if err != nil {
+		return err
+	}
+
 	wrappedTx, err := ar.OpenTx()
 	if err != nil {
 		return err
 	}
 
-	return ar.dataSourceProxy.patchTableSchemaInTransaction(wrappedTx, patchSchema)
+	return applyStatementsInTransaction(ar.dataSourceProxy.ctx, wrappedTx, plan.Table, statements, ar.dataSourceProxy.queryLogger)
 }
 
 //GetTableSchema return table (name,columns with name and types) representation wrapped in schema.Table struct
@@ -118,6 +180,10 @@ func (ar *AwsRedshift) GetTableSchema(tableName string) (*schema.Table, error) {
 				//skip dropped postgres field
 				continue
 			}
+			if spec, isDecimal, _ := typing.ParseDecimalType(columnPostgresType); isDecimal {
+				table.Columns[columnName] = schema.NewDecimalColumn(spec)
+				continue
+			}
 			logging.Errorf("Unknown postgres [%s] column type: %s in schema: [%s] table: [%s]", columnName, columnPostgresType, p.config.Schema, tableName)
 
 			mappedType = typing.STRING
@@ -145,6 +211,52 @@ func (ar *AwsRedshift) UpdatePrimaryKey(patchTableSchema *schema.Table, patchCon
 	return nil
 }
 
+//TablesList return slice of redshift table names
+func (ar *AwsRedshift) TablesList() ([]string, error) {
+	return ar.dataSourceProxy.TablesList()
+}
+
+//CompactTable copy all rows of the source table into the target table and drop the source table if drop is true (see Postgres.CompactTable)
+func (ar *AwsRedshift) CompactTable(target, source *schema.Table, drop bool) (int64, error) {
+	return ar.dataSourceProxy.CompactTable(target, source, drop)
+}
+
+//DeleteExpired delete rows of the table which match the condition and pass them to archive func if it isn't nil (see Postgres.DeleteExpired)
+func (ar *AwsRedshift) DeleteExpired(table *schema.Table, condition *ExpirationCondition, archive func([]map[string]interface{}) error) (int64, error) {
+	return ar.dataSourceProxy.DeleteExpired(table, condition, archive)
+}
+
+//Annotate update fields of the table rows with the key column value and insert the audit row (see Postgres.Annotate)
+func (ar *AwsRedshift) Annotate(table *schema.Table, keyColumn, key string, fields map[string]interface{}, audit *schema.Table,
+	auditRow func(previous []map[string]interface{}, updated int64) map[string]interface{}) (int64, error) {
+	return ar.dataSourceProxy.Annotate(table, keyColumn, key, fields, audit, auditRow)
+}
+
+//CountRows return count of the table rows which match the condition (see Postgres.CountRows)
+func (ar *AwsRedshift) CountRows(table *schema.Table, condition *DeletionCondition) (int64, error) {
+	return ar.dataSourceProxy.CountRows(table, condition)
+}
+
+//DeleteBatch delete the next batch of the table rows which match the condition (see Postgres.DeleteBatch)
+func (ar *AwsRedshift) DeleteBatch(table *schema.Table, keyColumn string, condition *DeletionCondition, batchSize int) (int64, error) {
+	return ar.dataSourceProxy.DeleteBatch(table, keyColumn, condition, batchSize)
+}
+
+//Exec execute the statement (e.g. custom SQL hook) outside of transaction
+func (ar *AwsRedshift) Exec(statement string) error {
+	return ar.dataSourceProxy.Exec(statement)
+}
+
+//ExecInTransaction execute the statement (e.g. custom SQL hook) in provided wrapped transaction
+func (ar *AwsRedshift) ExecInTransaction(wrappedTx *Transaction, statement string) error {
+	return ar.dataSourceProxy.ExecInTransaction(wrappedTx, statement)
+}
+
+//Ping check the database connection
+func (ar *AwsRedshift) Ping() error {
+	return ar.dataSourceProxy.Ping()
+}
+
 //Close underlying sql.DB
 func (ar *AwsRedshift) Close() error {
 	return ar.dataSourceProxy.Close()
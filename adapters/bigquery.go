@@ -10,15 +10,22 @@ import (
 	"github.com/jitsucom/eventnative/typing"
 	"google.golang.org/api/googleapi"
 	"net/http"
+	"strconv"
 	"strings"
 )
 
+const mergeBigQueryTemplate = "MERGE `%s.%s.%s` T USING (SELECT %s) S ON %s WHEN MATCHED THEN UPDATE SET %s WHEN NOT MATCHED THEN INSERT (%s) VALUES (%s)"
+
 var (
 	SchemaToBigQueryString = map[typing.DataType]string{
 		typing.STRING:    string(bigquery.StringFieldType),
 		typing.INT64:     string(bigquery.IntegerFieldType),
 		typing.FLOAT64:   string(bigquery.FloatFieldType),
 		typing.TIMESTAMP: string(bigquery.TimestampFieldType),
+		typing.DECIMAL:   string(bigquery.NumericFieldType),
+		typing.BOOL:      string(bigquery.BooleanFieldType),
+		typing.DATE:      string(bigquery.DateFieldType),
+		typing.UUID:      string(bigquery.StringFieldType),
 	}
 
 	SchemaToBigQuery = map[typing.DataType]bigquery.FieldType{
@@ -26,6 +33,10 @@ var (
 		typing.INT64:     bigquery.IntegerFieldType,
 		typing.FLOAT64:   bigquery.FloatFieldType,
 		typing.TIMESTAMP: bigquery.TimestampFieldType,
+		typing.DECIMAL:   bigquery.NumericFieldType,
+		typing.BOOL:      bigquery.BooleanFieldType,
+		typing.DATE:      bigquery.DateFieldType,
+		typing.UUID:      bigquery.StringFieldType,
 	}
 
 	BigQueryToSchema = map[bigquery.FieldType]typing.DataType{
@@ -33,6 +44,9 @@ var (
 		bigquery.IntegerFieldType:   typing.INT64,
 		bigquery.FloatFieldType:     typing.FLOAT64,
 		bigquery.TimestampFieldType: typing.TIMESTAMP,
+		bigquery.NumericFieldType:   typing.DECIMAL,
+		bigquery.BooleanFieldType:   typing.BOOL,
+		bigquery.DateFieldType:      typing.DATE,
 	}
 )
 
@@ -83,12 +97,75 @@ func (bq *BigQuery) Test() error {
 }
 
 //Insert provided object in BigQuery in stream mode
+//if table has primary key fields: upsert object with MERGE query instead of streaming insert
 func (bq *BigQuery) Insert(schema *schema.Table, valuesMap map[string]interface{}) error {
+	if len(schema.PKFields) > 0 {
+		return bq.merge(schema, valuesMap)
+	}
+
 	inserter := bq.client.Dataset(bq.config.Dataset).Table(schema.Name).Inserter()
 	bq.logQuery(fmt.Sprintf("Inserting values to table %s: ", schema.Name), valuesMap)
 	return inserter.Put(bq.ctx, BQItem{values: valuesMap})
 }
 
+//merge run MERGE query with named parameters: row with the same primary key is updated otherwise inserted
+func (bq *BigQuery) merge(table *schema.Table, valuesMap map[string]interface{}) error {
+	var conditions []string
+	for _, pkField := range sortedPkFields(table.PKFields) {
+		if _, ok := valuesMap[pkField]; !ok {
+			return fmt.Errorf("Error inserting in %s table: primary key field [%s] is missing in the object", table.Name, pkField)
+		}
+		conditions = append(conditions, fmt.Sprintf("T.`%s` = S.`%s`", pkField, pkField))
+	}
+
+	var columns, selectSection, updateSection, sourceValues []string
+	var parameters []bigquery.QueryParameter
+	i := 0
+	for name, value := range valuesMap {
+		column := "`" + name + "`"
+		columns = append(columns, column)
+		updateSection = append(updateSection, column+" = S."+column)
+		sourceValues = append(sourceValues, "S."+column)
+
+		//query parameters can't be nil: cast NULL to the column type
+		if value == nil {
+			bqType := SchemaToBigQueryString[typing.STRING]
+			if c, ok := table.Columns[name]; ok {
+				if mappedType, ok := SchemaToBigQueryString[c.GetType()]; ok {
+					bqType = mappedType
+				}
+			}
+			selectSection = append(selectSection, fmt.Sprintf("CAST(NULL AS %s) AS %s", bqType, column))
+			continue
+		}
+
+		paramName := "p" + strconv.Itoa(i)
+		i++
+		selectSection = append(selectSection, "@"+paramName+" AS "+column)
+		parameters = append(parameters, bigquery.QueryParameter{Name: paramName, Value: typing.ReformatValue(value)})
+	}
+
+	query := fmt.Sprintf(mergeBigQueryTemplate, bq.config.Project, bq.config.Dataset, table.Name, strings.Join(selectSection, ","),
+		strings.Join(conditions, " AND "), strings.Join(updateSection, ","), strings.Join(columns, ","), strings.Join(sourceValues, ","))
+	bq.logQuery(query+" with values: ", valuesMap)
+
+	q := bq.client.Query(query)
+	q.Parameters = parameters
+	job, err := q.Run(bq.ctx)
+	if err != nil {
+		return fmt.Errorf("Error running merge query in BigQuery table %s: %v", table.Name, err)
+	}
+	jobStatus, err := job.Wait(bq.ctx)
+	if err != nil {
+		return fmt.Errorf("Error waiting merge query job in BigQuery table %s: %v", table.Name, err)
+	}
+	if jobStatus.Err() != nil {
+		return fmt.Errorf("Error merging into BigQuery table %s: %v", table.Name, jobStatus.Err())
+	}
+
+	return nil
+}
+
 //Return google BigQuery table representation(name, columns with types) as schema.Table
 func (bq *BigQuery) GetTableSchema(tableName string) (*schema.Table, error) {
 	table := &schema.Table{Name: tableName, Columns: schema.Columns{}}
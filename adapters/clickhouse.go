@@ -39,6 +39,10 @@ var (
 		typing.INT64:     "Int64",
 		typing.FLOAT64:   "Float64",
 		typing.TIMESTAMP: "DateTime",
+		typing.DECIMAL:   "Decimal(38,18)",
+		typing.BOOL:      "UInt8",
+		typing.DATE:      "Date",
+		typing.UUID:      "UUID",
 	}
 
 	ClickhouseToSchema = map[string]typing.DataType{
@@ -50,6 +54,12 @@ var (
 		"Nullable(Float64)":  typing.FLOAT64,
 		"DateTime":           typing.TIMESTAMP,
 		"Nullable(DateTime)": typing.TIMESTAMP,
+		"UInt8":              typing.BOOL,
+		"Nullable(UInt8)":    typing.BOOL,
+		"Date":               typing.DATE,
+		"Nullable(Date)":     typing.DATE,
+		"UUID":               typing.UUID,
+		"Nullable(UUID)":     typing.UUID,
 	}
 )
 
@@ -77,6 +87,44 @@ type FieldConfig struct {
 	Field    string `mapstructure:"field" json:"field,omitempty" yaml:"field,omitempty"`
 }
 
+//clickHousePartitionFunctions is a ClickHouse date function -> partition granularity
+var clickHousePartitionFunctions = map[string]schema.Granularity{
+	"tostartofhour":  schema.HOUR,
+	"toyyyymmdd":     schema.DAY,
+	"todate":         schema.DAY,
+	"tostartofday":   schema.DAY,
+	"tomonday":       schema.WEEK,
+	"tostartofweek":  schema.WEEK,
+	"toisoweek":      schema.WEEK,
+	"toyyyymm":       schema.MONTH,
+	"tostartofmonth": schema.MONTH,
+}
+
+//PartitionGranularity return time partitioning granularity of the table engine (default PARTITION BY toYYYYMM(_timestamp) is month)
+//return false if it can't be detected (raw statement, not a date function or several date functions)
+func (ec *EngineConfig) PartitionGranularity() (schema.Granularity, bool) {
+	if ec == nil || (ec.RawStatement == "" && len(ec.PartitionFields) == 0) {
+		return schema.MONTH, true
+	}
+	if ec.RawStatement != "" {
+		return "", false
+	}
+
+	var granularity schema.Granularity
+	for _, field := range ec.PartitionFields {
+		g, ok := clickHousePartitionFunctions[strings.ToLower(field.Function)]
+		if !ok {
+			continue
+		}
+		if granularity != "" && granularity != g {
+			return "", false
+		}
+		granularity = g
+	}
+
+	return granularity, granularity != ""
+}
+
 //Validate required fields in ClickHouseConfig
 func (chc *ClickHouseConfig) Validate() error {
 	if chc == nil {
@@ -280,7 +328,7 @@ func (ch *ClickHouse) CreateTable(tableSchema *schema.Table) error {
 
 	var columnsDDL []string
 	for columnName, column := range tableSchema.Columns {
-		mappedType, ok := SchemaToClickhouse[column.GetType()]
+		mappedType, ok := columnSQLType(column, SchemaToClickhouse, clickhouseDecimalTemplate)
 		if !ok {
 			logging.Error("Unknown clickhouse schema type:", column.GetType())
 			mappedType = SchemaToClickhouse[typing.STRING]
@@ -332,6 +380,15 @@ func (ch *ClickHouse) GetTableSchema(tableName string) (*schema.Table, error) {
 
 		mappedType, ok := ClickhouseToSchema[columnClickhouseType]
 		if !ok {
+			//Decimal(P, S) or Nullable(Decimal(P, S))
+			decimalType := columnClickhouseType
+			if strings.HasPrefix(decimalType, "Nullable(") {
+				decimalType = strings.TrimSuffix(strings.TrimPrefix(decimalType, "Nullable("), ")")
+			}
+			if spec, isDecimal, _ := typing.ParseDecimalType(decimalType); isDecimal {
+				table.Columns[columnName] = schema.NewDecimalColumn(spec)
+				continue
+			}
 			logging.Error("Unknown clickhouse column type:", columnClickhouseType)
 			mappedType = typing.STRING
 		}
@@ -353,7 +410,7 @@ func (ch *ClickHouse) PatchTableSchema(patchSchema *schema.Table) error {
 	}
 
 	for columnName, column := range patchSchema.Columns {
-		mappedType, ok := SchemaToClickhouse[column.GetType()]
+		mappedType, ok := columnSQLType(column, SchemaToClickhouse, clickhouseDecimalTemplate)
 		if !ok {
 			logging.Error("Unknown clickhouse schema type:", column.GetType().String())
 			mappedType = SchemaToClickhouse[typing.STRING]
@@ -437,6 +494,11 @@ func (ch *ClickHouse) UpdatePrimaryKey(patchTableSchema *schema.Table, patchCons
 	return nil
 }
 
+//Ping check the database connection
+func (ch *ClickHouse) Ping() error {
+	return ch.dataSource.PingContext(ch.ctx)
+}
+
 //Close underlying sql.DB
 func (ch *ClickHouse) Close() error {
 	if err := ch.dataSource.Close(); err != nil {
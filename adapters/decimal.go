@@ -0,0 +1,25 @@
+package adapters
+
+import (
+	"fmt"
+	"github.com/jitsucom/eventnative/schema"
+	"github.com/jitsucom/eventnative/typing"
+)
+
+const (
+	postgresDecimalTemplate   = "numeric(%d,%d)"
+	snowflakeDecimalTemplate  = "numeric(%d,%d)"
+	clickhouseDecimalTemplate = "Decimal(%d,%d)"
+)
+
+//columnSQLType return SQL type of the column from mapping
+//DECIMAL columns types are formatted with decimalTemplate and the column precision and scale
+func columnSQLType(column schema.Column, mapping map[typing.DataType]string, decimalTemplate string) (string, bool) {
+	if column.GetType() == typing.DECIMAL {
+		spec := column.DecimalSpec()
+		return fmt.Sprintf(decimalTemplate, spec.Precision, spec.Scale), true
+	}
+
+	sqlType, ok := mapping[column.GetType()]
+	return sqlType, ok
+}
@@ -0,0 +1,33 @@
+package adapters
+
+import (
+	"context"
+	"fmt"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/schema"
+)
+
+//Migrator is implemented by SQL adapters: DDL statements of table migration plans (new columns and column types widenings)
+//are built in one place per SQL dialect and are applied in one transaction
+type Migrator interface {
+	MigrationStatements(plan *schema.MigrationPlan) ([]string, error)
+	ApplyMigration(plan *schema.MigrationPlan) error
+}
+
+//addColumnsPlan return migration plan with new columns of the patch schema
+func addColumnsPlan(patchSchema *schema.Table) (*schema.MigrationPlan, error) {
+	return schema.NewMigrationPlan(&schema.Table{Name: patchSchema.Name}, patchSchema)
+}
+
+//applyStatementsInTransaction execute DDL statements one by one and commit the transaction
+func applyStatementsInTransaction(ctx context.Context, wrappedTx *Transaction, table string, statements []string, queryLogger *logging.QueryLogger) error {
+	for _, statement := range statements {
+		queryLogger.Log(statement)
+		if _, err := wrappedTx.tx.ExecContext(ctx, statement); err != nil {
+			wrappedTx.Rollback()
+			return fmt.Errorf("Error migrating %s table schema with [%s]: %v", table, statement, err)
+		}
+	}
+
+	return wrappedTx.tx.Commit()
+}
@@ -12,6 +12,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 const (
@@ -41,11 +42,23 @@ const (
 					  	AND indisprimary`
 	createDbSchemaIfNotExistsTemplate = `CREATE SCHEMA IF NOT EXISTS "%s"`
 	addColumnTemplate                 = `ALTER TABLE "%s"."%s" ADD COLUMN %s %s`
+	alterColumnTypeTemplate           = `ALTER TABLE "%s"."%s" ALTER COLUMN %s TYPE %s USING %s::%s`
 	dropPrimaryKeyTemplate            = "ALTER TABLE %s.%s DROP CONSTRAINT IF EXISTS %s"
 	alterPrimaryKeyTemplate           = `ALTER TABLE "%s"."%s" ADD CONSTRAINT %s PRIMARY KEY (%s)`
 	createTableTemplate               = `CREATE TABLE "%s"."%s" (%s)`
 	insertTemplate                    = `INSERT INTO "%s"."%s" (%s) VALUES (%s)`
 	mergeTemplate                     = `INSERT INTO %s.%s(%s) VALUES(%s) ON CONFLICT ON CONSTRAINT %s DO UPDATE set %s;`
+	upsertTemplate                    = `INSERT INTO "%s"."%s" AS t (%s) VALUES (%s) ON CONFLICT ON CONSTRAINT %s DO UPDATE SET %s`
+	insertIgnoreTemplate              = `INSERT INTO "%s"."%s" (%s) VALUES %s ON CONFLICT DO NOTHING`
+	copyTableTemplate                 = `INSERT INTO "%s"."%s" (%s) SELECT %s FROM "%s"."%s"`
+	dropTableTemplate                 = `DROP TABLE "%s"."%s"`
+	backfillColumnTemplate            = `UPDATE "%s"."%s" SET %s = %s WHERE ctid IN (SELECT ctid FROM "%s"."%s" WHERE %s IS NULL AND %s IS NOT NULL LIMIT %d)`
+	selectExpiredTemplate             = `SELECT * FROM "%s"."%s" WHERE %s`
+	deleteExpiredTemplate             = `DELETE FROM "%s"."%s" WHERE %s`
+	selectByKeyTemplate               = `SELECT %s FROM "%s"."%s" WHERE %s = $1`
+	updateByKeyTemplate               = `UPDATE "%s"."%s" SET %s WHERE %s = $%d`
+	countRowsTemplate                 = `SELECT COUNT(*) FROM "%s"."%s" WHERE %s`
+	deleteBatchTemplate               = `DELETE FROM "%s"."%s" WHERE %s AND %s IN (SELECT %s FROM "%s"."%s" WHERE %s AND %s IS NOT NULL LIMIT %d)`
 )
 
 var (
@@ -54,15 +67,35 @@ var (
 		typing.INT64:     "bigint",
 		typing.FLOAT64:   "numeric(38,18)",
 		typing.TIMESTAMP: "timestamp",
+		typing.DECIMAL:   "numeric(38,18)",
+		typing.BOOL:      "boolean",
+		typing.DATE:      "date",
+		typing.UUID:      "uuid",
+	}
+
+	//Redshift doesn't have uuid type
+	SchemaToRedshift = map[typing.DataType]string{
+		typing.STRING:    "character varying(8192)",
+		typing.INT64:     "bigint",
+		typing.FLOAT64:   "numeric(38,18)",
+		typing.TIMESTAMP: "timestamp",
+		typing.DECIMAL:   "numeric(38,18)",
+		typing.BOOL:      "boolean",
+		typing.DATE:      "date",
+		typing.UUID:      "character varying(36)",
 	}
 
 	PostgresToSchema = map[string]typing.DataType{
+		"character varying(36)":       typing.STRING,
 		"character varying(512)":      typing.STRING,
 		"character varying(8192)":     typing.STRING,
 		"bigint":                      typing.INT64,
 		"numeric(40,20)":              typing.FLOAT64,
 		"numeric(38,18)":              typing.FLOAT64,
 		"timestamp without time zone": typing.TIMESTAMP,
+		"boolean":                     typing.BOOL,
+		"date":                        typing.DATE,
+		"uuid":                        typing.UUID,
 	}
 )
 
@@ -98,23 +131,30 @@ func (dsc *DataSourceConfig) Validate() error {
 	return nil
 }
 
+//PostgresConnectionString return lib/pq connection string with provided connection parameters
+func PostgresConnectionString(config *DataSourceConfig) string {
+	connectionString := fmt.Sprintf("host=%s port=%d dbname=%s user=%s password=%s ",
+		config.Host, config.Port, config.Db, config.Username, config.Password)
+	//concat provided connection parameters
+	for k, v := range config.Parameters {
+		connectionString += k + "=" + v + " "
+	}
+	return connectionString
+}
+
 //Postgres is adapter for creating,patching (schema or table), inserting data to postgres
 type Postgres struct {
 	ctx         context.Context
 	config      *DataSourceConfig
 	dataSource  *sql.DB
 	queryLogger *logging.QueryLogger
+	//SchemaToPostgres or SchemaToRedshift
+	sqlTypes map[typing.DataType]string
 }
 
 //NewPostgres return configured Postgres adapter instance
 func NewPostgres(ctx context.Context, config *DataSourceConfig, queryLogger *logging.QueryLogger) (*Postgres, error) {
-	connectionString := fmt.Sprintf("host=%s port=%d dbname=%s user=%s password=%s ",
-		config.Host, config.Port, config.Db, config.Username, config.Password)
-	//concat provided connection parameters
-	for k, v := range config.Parameters {
-		connectionString += k + "=" + v + " "
-	}
-	dataSource, err := sql.Open("postgres", connectionString)
+	dataSource, err := sql.Open("postgres", PostgresConnectionString(config))
 
 	if err != nil {
 		return nil, err
@@ -123,7 +163,7 @@ func NewPostgres(ctx context.Context, config *DataSourceConfig, queryLogger *log
 		return nil, err
 	}
 
-	return &Postgres{ctx: ctx, config: config, dataSource: dataSource, queryLogger: queryLogger}, nil
+	return &Postgres{ctx: ctx, config: config, dataSource: dataSource, queryLogger: queryLogger, sqlTypes: SchemaToPostgres}, nil
 }
 
 func (Postgres) Name() string {
@@ -162,12 +202,50 @@ func (p *Postgres) CreateTable(tableSchema *schema.Table) error {
 
 //PatchTableSchema add new columns(from provided schema.Table) to existing table
 func (p *Postgres) PatchTableSchema(patchSchema *schema.Table) error {
+	plan, err := addColumnsPlan(patchSchema)
+	if err != nil {
+		return err
+	}
+
+	return p.ApplyMigration(plan)
+}
+
+//MigrationStatements return ADD COLUMN and ALTER COLUMN TYPE statements of the plan
+func (p *Postgres) MigrationStatements(plan *schema.MigrationPlan) ([]string, error) {
+	var statements []string
+	for _, step := range plan.Steps {
+		sqlType, ok := columnSQLType(step.ToColumn(), p.sqlTypes, postgresDecimalTemplate)
+		if !ok {
+			logging.Error("Unknown postgres schema type:", step.To.String())
+			sqlType = p.sqlTypes[typing.STRING]
+		}
+
+		switch step.Kind {
+		case schema.AddColumnStep:
+			statements = append(statements, fmt.Sprintf(addColumnTemplate, p.config.Schema, plan.Table, step.Column, sqlType))
+		case schema.WidenColumnStep:
+			statements = append(statements, fmt.Sprintf(alterColumnTypeTemplate, p.config.Schema, plan.Table, step.Column, sqlType, step.Column, sqlType))
+		default:
+			return nil, fmt.Errorf("Unknown migration step: %s", step.Kind)
+		}
+	}
+
+	return statements, nil
+}
+
+//ApplyMigration execute migration plan statements in one transaction
+func (p *Postgres) ApplyMigration(plan *schema.MigrationPlan) error {
+	statements, err := p.MigrationStatements(plan)
+	if err != nil {
+		return err
+	}
+
 	wrappedTx, err := p.OpenTx()
 	if err != nil {
 		return err
 	}
 
-	return p.patchTableSchemaInTransaction(wrappedTx, patchSchema)
+	return applyStatementsInTransaction(p.ctx, wrappedTx, plan.Table, statements, p.queryLogger)
 }
 
 //GetTableSchema return table (name,columns with name and types) representation wrapped in schema.Table struct
@@ -190,6 +268,10 @@ func (p *Postgres) GetTableSchema(tableName string) (*schema.Table, error) {
 				//skip dropped postgres field
 				continue
 			}
+			if spec, isDecimal, _ := typing.ParseDecimalType(columnPostgresType); isDecimal {
+				table.Columns[columnName] = schema.NewDecimalColumn(spec)
+				continue
+			}
 			logging.Errorf("Unknown postgres [%s] column type: %s in schema: [%s] table: [%s]", columnName, columnPostgresType, p.config.Schema, tableName)
 
 			mappedType = typing.STRING
@@ -229,10 +311,10 @@ func (p *Postgres) GetTableSchema(tableName string) (*schema.Table, error) {
 func (p *Postgres) createTableInTransaction(wrappedTx *Transaction, tableSchema *schema.Table) error {
 	var columnsDDL []string
 	for columnName, column := range tableSchema.Columns {
-		mappedType, ok := SchemaToPostgres[column.GetType()]
+		mappedType, ok := columnSQLType(column, p.sqlTypes, postgresDecimalTemplate)
 		if !ok {
 			logging.Error("Unknown postgres schema type:", column.GetType())
-			mappedType = SchemaToPostgres[typing.STRING]
+			mappedType = p.sqlTypes[typing.STRING]
 		}
 		columnsDDL = append(columnsDDL, fmt.Sprintf(`%s %s`, columnName, mappedType))
 	}
@@ -256,30 +338,6 @@ func (p *Postgres) createTableInTransaction(wrappedTx *Transaction, tableSchema
 	return wrappedTx.tx.Commit()
 }
 
-func (p *Postgres) patchTableSchemaInTransaction(wrappedTx *Transaction, patchSchema *schema.Table) error {
-	for columnName, column := range patchSchema.Columns {
-		mappedColumnType, ok := SchemaToPostgres[column.GetType()]
-		if !ok {
-			logging.Error("Unknown postgres schema type:", column.GetType().String())
-			mappedColumnType = SchemaToPostgres[typing.STRING]
-		}
-		query := fmt.Sprintf(addColumnTemplate, p.config.Schema, patchSchema.Name, columnName, mappedColumnType)
-		p.queryLogger.Log(query)
-		alterStmt, err := wrappedTx.tx.PrepareContext(p.ctx, query)
-		if err != nil {
-			wrappedTx.Rollback()
-			return fmt.Errorf("Error preparing patching table %s schema statement: %v", patchSchema.Name, err)
-		}
-
-		_, err = alterStmt.ExecContext(p.ctx)
-		if err != nil {
-			wrappedTx.Rollback()
-			return fmt.Errorf("Error patching %s table with '%s' - %s column schema: %v", patchSchema.Name, columnName, mappedColumnType, err)
-		}
-	}
-	return wrappedTx.tx.Commit()
-}
-
 func (p *Postgres) UpdatePrimaryKey(patchTableSchema *schema.Table, patchConstraint *schema.PKFieldsPatch) error {
 	wrappedTx, err := p.OpenTx()
 	if err != nil {
@@ -365,6 +423,109 @@ func (p *Postgres) InsertInTransaction(wrappedTx *Transaction, table *schema.Tab
 	return nil
 }
 
+//UpsertProfileInTransaction insert the profile row or merge it into the existing one: firstSeenColumn keeps the minimum,
+//lastSeenColumn keeps the maximum, other columns are overwritten only if the row isn't older than the existing one
+//(or the existing value is null). Primary key columns aren't updated
+func (p *Postgres) UpsertProfileInTransaction(wrappedTx *Transaction, table *schema.Table, valuesMap map[string]interface{}, firstSeenColumn, lastSeenColumn string) error {
+	columns := make([]string, 0, len(valuesMap))
+	for name := range valuesMap {
+		columns = append(columns, name)
+	}
+	sort.Strings(columns)
+
+	var placeholders, updates []string
+	var values []interface{}
+	for i, name := range columns {
+		placeholders = append(placeholders, "$"+strconv.Itoa(i+1))
+		values = append(values, valuesMap[name])
+
+		switch {
+		case table.PKFields[name]:
+		case name == firstSeenColumn:
+			updates = append(updates, fmt.Sprintf("%s=LEAST(t.%s, EXCLUDED.%s)", name, name, name))
+		case name == lastSeenColumn:
+			updates = append(updates, fmt.Sprintf("%s=GREATEST(t.%s, EXCLUDED.%s)", name, name, name))
+		default:
+			updates = append(updates, fmt.Sprintf("%s=CASE WHEN t.%s IS NULL OR t.%s IS NULL OR EXCLUDED.%s >= t.%s THEN EXCLUDED.%s ELSE t.%s END",
+				name, name, lastSeenColumn, lastSeenColumn, lastSeenColumn, name, name))
+		}
+	}
+
+	header := strings.Join(columns, ",")
+	query := fmt.Sprintf(upsertTemplate, p.config.Schema, table.Name, header, strings.Join(placeholders, ","),
+		buildConstraintName(p.config.Schema, table.Name), strings.Join(updates, ","))
+	p.queryLogger.LogWithValues(query, values)
+	if _, err := wrappedTx.tx.ExecContext(p.ctx, query, values...); err != nil {
+		return fmt.Errorf("Error upserting profile in %s table with statement: %s values: %v: %v", table.Name, header, values, err)
+	}
+
+	return nil
+}
+
+//IncrementInTransaction insert the row or add counters columns values to the existing row values (null is 0).
+//Other columns except primary key columns are overwritten
+func (p *Postgres) IncrementInTransaction(wrappedTx *Transaction, table *schema.Table, valuesMap map[string]interface{}, counters map[string]bool) error {
+	columns := make([]string, 0, len(valuesMap))
+	for name := range valuesMap {
+		columns = append(columns, name)
+	}
+	sort.Strings(columns)
+
+	var placeholders, updates []string
+	var values []interface{}
+	for i, name := range columns {
+		placeholders = append(placeholders, "$"+strconv.Itoa(i+1))
+		values = append(values, valuesMap[name])
+
+		switch {
+		case table.PKFields[name]:
+		case counters[name]:
+			updates = append(updates, fmt.Sprintf("%s=COALESCE(t.%s, 0) + EXCLUDED.%s", name, name, name))
+		default:
+			updates = append(updates, fmt.Sprintf("%s=EXCLUDED.%s", name, name))
+		}
+	}
+
+	header := strings.Join(columns, ",")
+	query := fmt.Sprintf(upsertTemplate, p.config.Schema, table.Name, header, strings.Join(placeholders, ","),
+		buildConstraintName(p.config.Schema, table.Name), strings.Join(updates, ","))
+	p.queryLogger.LogWithValues(query, values)
+	if _, err := wrappedTx.tx.ExecContext(p.ctx, query, values...); err != nil {
+		return fmt.Errorf("Error incrementing row in %s table with statement: %s values: %v: %v", table.Name, header, values, err)
+	}
+
+	return nil
+}
+
+//InsertIgnoreInTransaction insert rows with the same columns skipping rows which conflict with existing ones.
+//return count of inserted rows
+func (p *Postgres) InsertIgnoreInTransaction(wrappedTx *Transaction, table *schema.Table, columns []string, rows [][]interface{}) (int64, error) {
+	if len(rows) == 0 {
+		return 0, nil
+	}
+
+	var tuples []string
+	var values []interface{}
+	for _, row := range rows {
+		placeholders := make([]string, len(row))
+		for i, value := range row {
+			values = append(values, value)
+			placeholders[i] = "$" + strconv.Itoa(len(values))
+		}
+		tuples = append(tuples, "("+strings.Join(placeholders, ",")+")")
+	}
+
+	header := strings.Join(columns, ",")
+	query := fmt.Sprintf(insertIgnoreTemplate, p.config.Schema, table.Name, header, strings.Join(tuples, ","))
+	p.queryLogger.LogWithValues(query, values)
+	result, err := wrappedTx.tx.ExecContext(p.ctx, query, values...)
+	if err != nil {
+		return 0, fmt.Errorf("Error inserting %d rows in %s table with statement: %s: %v", len(rows), table.Name, header, err)
+	}
+
+	return result.RowsAffected()
+}
+
 func (p *Postgres) insertQuery(pkFields []string, tableName string, header string, placeholders string) string {
 	if len(pkFields) == 0 {
 		return fmt.Sprintf(insertTemplate, p.config.Schema, tableName, header, placeholders)
@@ -409,6 +570,208 @@ func (p *Postgres) TablesList() ([]string, error) {
 	return tableNames, nil
 }
 
+//CompactTable copy all rows of the source table into the target table and drop the source table if drop is true.
+//Target columns which don't exist in the source are NULL, source columns with other types are cast to target types.
+//It is done in one transaction. return copied rows count
+func (p *Postgres) CompactTable(target, source *schema.Table, drop bool) (int64, error) {
+	wrappedTx, err := p.OpenTx()
+	if err != nil {
+		return 0, err
+	}
+
+	return compactTableInTransaction(p.ctx, wrappedTx, p.config.Schema, target, source, drop, p.sqlTypes, p.queryLogger)
+}
+
+//BackfillColumn copy at most batchSize not NULL values of the from column into NULL values of the to column (cast to its type).
+//Both columns must exist in the table. return updated rows count: less than batchSize means that backfill is finished
+func (p *Postgres) BackfillColumn(table *schema.Table, from, to string, batchSize int) (int64, error) {
+	fromColumn, ok := table.Columns[from]
+	if !ok {
+		return 0, fmt.Errorf("Column [%s] doesn't exist in [%s] table", from, table.Name)
+	}
+	toColumn, ok := table.Columns[to]
+	if !ok {
+		return 0, fmt.Errorf("Column [%s] doesn't exist in [%s] table", to, table.Name)
+	}
+
+	expression := from
+	if fromColumn.GetType() != toColumn.GetType() {
+		sqlType, ok := columnSQLType(toColumn, p.sqlTypes, postgresDecimalTemplate)
+		if !ok {
+			sqlType = p.sqlTypes[typing.STRING]
+		}
+		expression = fmt.Sprintf("CAST(%s AS %s)", from, sqlType)
+	}
+
+	query := fmt.Sprintf(backfillColumnTemplate, p.config.Schema, table.Name, to, expression, p.config.Schema, table.Name, to, from, batchSize)
+	p.queryLogger.Log(query)
+	result, err := p.dataSource.ExecContext(p.ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("Error backfilling [%s] column from [%s] in [%s] table: %v", to, from, table.Name, err)
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("Error getting backfilled rows count of [%s] table: %v", table.Name, err)
+	}
+	return updated, nil
+}
+
+//DeleteExpired delete rows of the table which match the condition. If archive func isn't nil, rows are selected and passed to it
+//before deletion in the same transaction: rows aren't deleted if archive returns an error. return deleted rows count
+func (p *Postgres) DeleteExpired(table *schema.Table, condition *ExpirationCondition, archive func([]map[string]interface{}) error) (int64, error) {
+	where, values := condition.sql()
+	//rows inserted during archiving aren't deleted: select and delete see one snapshot
+	tx, err := p.dataSource.BeginTx(p.ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+	if err != nil {
+		return 0, err
+	}
+	wrappedTx := &Transaction{tx: tx, dbType: p.Name()}
+
+	if archive != nil {
+		query := fmt.Sprintf(selectExpiredTemplate, p.config.Schema, table.Name, where)
+		p.queryLogger.LogWithValues(query, values)
+		rows, err := selectRows(p.ctx, tx, query, values)
+		if err != nil {
+			wrappedTx.Rollback()
+			return 0, fmt.Errorf("Error selecting expired rows of [%s] table: %v", table.Name, err)
+		}
+		if len(rows) == 0 {
+			wrappedTx.Rollback()
+			return 0, nil
+		}
+		if err := archive(rows); err != nil {
+			wrappedTx.Rollback()
+			return 0, fmt.Errorf("Error archiving expired rows of [%s] table: %v", table.Name, err)
+		}
+	}
+
+	query := fmt.Sprintf(deleteExpiredTemplate, p.config.Schema, table.Name, where)
+	p.queryLogger.LogWithValues(query, values)
+	result, err := tx.ExecContext(p.ctx, query, values...)
+	if err != nil {
+		wrappedTx.Rollback()
+		return 0, fmt.Errorf("Error deleting expired rows of [%s] table: %v", table.Name, err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		wrappedTx.Rollback()
+		return 0, fmt.Errorf("Error getting deleted rows count of [%s] table: %v", table.Name, err)
+	}
+
+	return deleted, wrappedTx.DirectCommit()
+}
+
+//Annotate update fields of the table rows with the key column value and insert the audit row into the audit table
+//in one transaction. auditRow func is called with previous values of the fields and updated rows count. return updated rows count
+func (p *Postgres) Annotate(table *schema.Table, keyColumn, key string, fields map[string]interface{}, audit *schema.Table,
+	auditRow func(previous []map[string]interface{}, updated int64) map[string]interface{}) (int64, error) {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var assignments []string
+	var values []interface{}
+	for i, name := range names {
+		assignments = append(assignments, fmt.Sprintf("%s = $%d", name, i+1))
+		values = append(values, fields[name])
+	}
+	values = append(values, key)
+
+	wrappedTx, err := p.OpenTx()
+	if err != nil {
+		return 0, err
+	}
+
+	query := fmt.Sprintf(selectByKeyTemplate, strings.Join(names, ", "), p.config.Schema, table.Name, keyColumn)
+	p.queryLogger.LogWithValues(query, []interface{}{key})
+	previous, err := selectRows(p.ctx, wrappedTx.tx, query, []interface{}{key})
+	if err != nil {
+		wrappedTx.Rollback()
+		return 0, fmt.Errorf("Error selecting annotated rows of [%s] table: %v", table.Name, err)
+	}
+
+	query = fmt.Sprintf(updateByKeyTemplate, p.config.Schema, table.Name, strings.Join(assignments, ", "), keyColumn, len(values))
+	p.queryLogger.LogWithValues(query, values)
+	result, err := wrappedTx.tx.ExecContext(p.ctx, query, values...)
+	if err != nil {
+		wrappedTx.Rollback()
+		return 0, fmt.Errorf("Error updating annotated rows of [%s] table: %v", table.Name, err)
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		wrappedTx.Rollback()
+		return 0, fmt.Errorf("Error getting updated rows count of [%s] table: %v", table.Name, err)
+	}
+
+	if err := p.InsertInTransaction(wrappedTx, audit, auditRow(previous, updated)); err != nil {
+		wrappedTx.Rollback()
+		return 0, err
+	}
+
+	return updated, wrappedTx.DirectCommit()
+}
+
+//CountRows return count of the table rows which match the condition
+func (p *Postgres) CountRows(table *schema.Table, condition *DeletionCondition) (int64, error) {
+	where, values := condition.sql()
+	query := fmt.Sprintf(countRowsTemplate, p.config.Schema, table.Name, where)
+	p.queryLogger.LogWithValues(query, values)
+
+	var count int64
+	if err := p.dataSource.QueryRowContext(p.ctx, query, values...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("Error counting rows of [%s] table: %v", table.Name, err)
+	}
+	return count, nil
+}
+
+//DeleteBatch delete rows of the table which match the condition and have one of at most batchSize key column values.
+//Rows with NULL key column value aren't deleted.
+//return deleted rows count: 0 means that there are no more matched rows
+func (p *Postgres) DeleteBatch(table *schema.Table, keyColumn string, condition *DeletionCondition, batchSize int) (int64, error) {
+	where, values := condition.sql()
+	query := deleteBatchQuery(p.config.Schema, table.Name, keyColumn, where, batchSize)
+	p.queryLogger.LogWithValues(query, values)
+	result, err := p.dataSource.ExecContext(p.ctx, query, values...)
+	if err != nil {
+		return 0, fmt.Errorf("Error deleting rows of [%s] table: %v", table.Name, err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("Error getting deleted rows count of [%s] table: %v", table.Name, err)
+	}
+	return deleted, nil
+}
+
+//deleteBatchQuery return batch delete statement. Keys are selected without NULL values: otherwise a batch of rows
+//with NULL keys wouldn't delete anything and deletion would be stopped before all matched rows are deleted.
+//Placeholders are the same in both WHERE clauses
+func deleteBatchQuery(dbSchema, tableName, keyColumn, where string, batchSize int) string {
+	return fmt.Sprintf(deleteBatchTemplate, dbSchema, tableName, where, keyColumn, keyColumn, dbSchema, tableName, where, keyColumn, batchSize)
+}
+
+//Exec execute the statement (e.g. custom SQL hook) outside of transaction
+func (p *Postgres) Exec(statement string) error {
+	p.queryLogger.Log(statement)
+	_, err := p.dataSource.ExecContext(p.ctx, statement)
+	return err
+}
+
+//ExecInTransaction execute the statement (e.g. custom SQL hook) in provided wrapped transaction
+func (p *Postgres) ExecInTransaction(wrappedTx *Transaction, statement string) error {
+	p.queryLogger.Log(statement)
+	_, err := wrappedTx.tx.ExecContext(p.ctx, statement)
+	return err
+}
+
+//Ping check the database connection
+func (p *Postgres) Ping() error {
+	return p.dataSource.PingContext(p.ctx)
+}
+
 //Close underlying sql.DB
 func (p *Postgres) Close() error {
 	return p.dataSource.Close()
@@ -442,6 +805,149 @@ func (t *Transaction) Rollback() {
 	}
 }
 
+//ExpirationCondition is a condition of outdated rows: rows with timestamp before Before
+//and event types from EventTypes (if not empty) except ExcludedEventTypes
+type ExpirationCondition struct {
+	TimestampColumn    string
+	EventTypeColumn    string
+	Before             time.Time
+	EventTypes         []string
+	ExcludedEventTypes []string
+}
+
+//sql return WHERE clause with placeholders and values
+func (ec *ExpirationCondition) sql() (string, []interface{}) {
+	conditions := []string{ec.TimestampColumn + " < $1"}
+	values := []interface{}{ec.Before}
+
+	placeholders := func(eventTypes []string) string {
+		var result []string
+		for _, eventType := range eventTypes {
+			values = append(values, eventType)
+			result = append(result, "$"+strconv.Itoa(len(values)))
+		}
+		return strings.Join(result, ", ")
+	}
+	if len(ec.EventTypes) > 0 {
+		conditions = append(conditions, fmt.Sprintf("%s IN (%s)", ec.EventTypeColumn, placeholders(ec.EventTypes)))
+	}
+	if len(ec.ExcludedEventTypes) > 0 {
+		conditions = append(conditions, fmt.Sprintf("(%s IS NULL OR %s NOT IN (%s))", ec.EventTypeColumn, ec.EventTypeColumn, placeholders(ec.ExcludedEventTypes)))
+	}
+
+	return strings.Join(conditions, " AND "), values
+}
+
+//DeletionCondition is a condition of the bulk deleted rows: rows with timestamp in [From, To) (zero bounds are omitted)
+//which match all predicates
+type DeletionCondition struct {
+	TimestampColumn string
+	From            time.Time
+	To              time.Time
+	Predicates      []*Predicate
+}
+
+//Predicate operators
+const (
+	EqOperator      = "eq"
+	NeqOperator     = "neq"
+	InOperator      = "in"
+	NotInOperator   = "not_in"
+	IsNullOperator  = "is_null"
+	NotNullOperator = "not_null"
+)
+
+//Predicate is a comparison of the column value with Values. eq and neq have one value, is_null and not_null have no values.
+//Rows with NULL column values don't match neq and not_in
+type Predicate struct {
+	Column   string
+	Operator string
+	Values   []interface{}
+}
+
+//sql return WHERE clause with placeholders and values
+func (dc *DeletionCondition) sql() (string, []interface{}) {
+	var conditions []string
+	var values []interface{}
+	placeholders := func(predicateValues []interface{}) string {
+		var result []string
+		for _, value := range predicateValues {
+			values = append(values, value)
+			result = append(result, "$"+strconv.Itoa(len(values)))
+		}
+		return strings.Join(result, ", ")
+	}
+
+	if !dc.From.IsZero() {
+		conditions = append(conditions, fmt.Sprintf("%s >= %s", dc.TimestampColumn, placeholders([]interface{}{dc.From})))
+	}
+	if !dc.To.IsZero() {
+		conditions = append(conditions, fmt.Sprintf("%s < %s", dc.TimestampColumn, placeholders([]interface{}{dc.To})))
+	}
+	for _, predicate := range dc.Predicates {
+		switch predicate.Operator {
+		case EqOperator:
+			conditions = append(conditions, fmt.Sprintf("%s = %s", predicate.Column, placeholders(predicate.Values)))
+		case NeqOperator:
+			conditions = append(conditions, fmt.Sprintf("%s != %s", predicate.Column, placeholders(predicate.Values)))
+		case InOperator:
+			conditions = append(conditions, fmt.Sprintf("%s IN (%s)", predicate.Column, placeholders(predicate.Values)))
+		case NotInOperator:
+			conditions = append(conditions, fmt.Sprintf("%s NOT IN (%s)", predicate.Column, placeholders(predicate.Values)))
+		case IsNullOperator:
+			conditions = append(conditions, predicate.Column+" IS NULL")
+		case NotNullOperator:
+			conditions = append(conditions, predicate.Column+" IS NOT NULL")
+		}
+	}
+
+	return strings.Join(conditions, " AND "), values
+}
+
+//selectRows return query result rows as objects. Byte slices (e.g. numeric values) are converted into strings
+func selectRows(ctx context.Context, tx *sql.Tx, query string, values []interface{}) ([]map[string]interface{}, error) {
+	rows, err := tx.QueryContext(ctx, query, values...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []map[string]interface{}
+	for rows.Next() {
+		rowValues := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range rowValues {
+			pointers[i] = &rowValues[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, fmt.Errorf("Error scanning row: %v", err)
+		}
+
+		object := map[string]interface{}{}
+		for i, column := range columns {
+			value := rowValues[i]
+			if value == nil {
+				continue
+			}
+			if b, ok := value.([]byte); ok {
+				value = string(b)
+			}
+			object[column] = value
+		}
+		result = append(result, object)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("Last rows.Err: %v", err)
+	}
+
+	return result, nil
+}
+
 func createDbSchemaInTransaction(ctx context.Context, wrappedTx *Transaction, statementTemplate,
 	dbSchemaName string, queryLogger *logging.QueryLogger) error {
 	query := fmt.Sprintf(statementTemplate, dbSchemaName)
@@ -462,6 +968,64 @@ func createDbSchemaInTransaction(ctx context.Context, wrappedTx *Transaction, st
 	return wrappedTx.tx.Commit()
 }
 
+func compactTableInTransaction(ctx context.Context, wrappedTx *Transaction, dbSchema string, target, source *schema.Table, drop bool,
+	sqlTypes map[typing.DataType]string, queryLogger *logging.QueryLogger) (int64, error) {
+	columns, expressions := copyTableExpressions(target, source, sqlTypes)
+	query := fmt.Sprintf(copyTableTemplate, dbSchema, target.Name, strings.Join(columns, ","), strings.Join(expressions, ","), dbSchema, source.Name)
+	queryLogger.Log(query)
+	result, err := wrappedTx.tx.ExecContext(ctx, query)
+	if err != nil {
+		wrappedTx.Rollback()
+		return 0, fmt.Errorf("Error copying [%s] table into [%s]: %v", source.Name, target.Name, err)
+	}
+	copied, err := result.RowsAffected()
+	if err != nil {
+		wrappedTx.Rollback()
+		return 0, fmt.Errorf("Error getting copied rows count of [%s] table: %v", source.Name, err)
+	}
+
+	if drop {
+		query := fmt.Sprintf(dropTableTemplate, dbSchema, source.Name)
+		queryLogger.Log(query)
+		if _, err := wrappedTx.tx.ExecContext(ctx, query); err != nil {
+			wrappedTx.Rollback()
+			return 0, fmt.Errorf("Error dropping [%s] table: %v", source.Name, err)
+		}
+	}
+
+	return copied, wrappedTx.DirectCommit()
+}
+
+//copyTableExpressions return sorted target columns and source select expressions:
+//column as is, column cast to the target type or NULL if the source doesn't have the column
+func copyTableExpressions(target, source *schema.Table, sqlTypes map[typing.DataType]string) ([]string, []string) {
+	columns := target.Columns.Header()
+	sort.Strings(columns)
+
+	expressions := make([]string, 0, len(columns))
+	for _, name := range columns {
+		sourceColumn, ok := source.Columns[name]
+		if !ok {
+			expressions = append(expressions, "NULL")
+			continue
+		}
+
+		targetColumn := target.Columns[name]
+		if sourceColumn.GetType() == targetColumn.GetType() {
+			expressions = append(expressions, name)
+			continue
+		}
+
+		sqlType, ok := columnSQLType(targetColumn, sqlTypes, postgresDecimalTemplate)
+		if !ok {
+			sqlType = sqlTypes[typing.STRING]
+		}
+		expressions = append(expressions, fmt.Sprintf("CAST(%s AS %s)", name, sqlType))
+	}
+
+	return columns, expressions
+}
+
 func removeLastComma(str string) string {
 	if last := len(str) - 1; last >= 0 && str[last] == ',' {
 		str = str[:last]
@@ -469,3 +1033,10 @@ func removeLastComma(str string) string {
 
 	return str
 }
+
+//sortedPkFields return primary key fields sorted asc for stable upsert statements
+func sortedPkFields(pkFields map[string]bool) []string {
+	fields := schema.PkToFieldsArray(pkFields)
+	sort.Strings(fields)
+	return fields
+}
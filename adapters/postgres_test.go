@@ -0,0 +1,49 @@
+package adapters
+
+import (
+	"github.com/jitsucom/eventnative/schema"
+	"github.com/jitsucom/eventnative/typing"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestCopyTableExpressions(t *testing.T) {
+	target := &schema.Table{Name: "events", Columns: schema.Columns{
+		"_timestamp": schema.NewColumn(typing.TIMESTAMP),
+		"amount":     schema.NewColumn(typing.FLOAT64),
+		"user_id":    schema.NewColumn(typing.STRING),
+		"utm_source": schema.NewColumn(typing.STRING),
+	}}
+	source := &schema.Table{Name: "events_2020_08_01", Columns: schema.Columns{
+		"_timestamp": schema.NewColumn(typing.TIMESTAMP),
+		"amount":     schema.NewColumn(typing.INT64),
+		"user_id":    schema.NewColumn(typing.INT64),
+	}}
+
+	columns, expressions := copyTableExpressions(target, source, SchemaToPostgres)
+	require.Equal(t, []string{"_timestamp", "amount", "user_id", "utm_source"}, columns)
+	require.Equal(t, []string{"_timestamp", "CAST(amount AS numeric(38,18))", "CAST(user_id AS character varying(8192))", "NULL"}, expressions)
+}
+
+func TestDeletionConditionSql(t *testing.T) {
+	from := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	condition := &DeletionCondition{TimestampColumn: "_timestamp", From: from, Predicates: []*Predicate{
+		{Column: "event_type", Operator: InOperator, Values: []interface{}{"test", "debug"}},
+		{Column: "app", Operator: NeqOperator, Values: []interface{}{"prod"}},
+		{Column: "user_id", Operator: IsNullOperator},
+	}}
+
+	where, values := condition.sql()
+	require.Equal(t, "_timestamp >= $1 AND event_type IN ($2, $3) AND app != $4 AND user_id IS NULL", where)
+	require.Equal(t, []interface{}{from, "test", "debug", "prod"}, values)
+}
+
+func TestDeleteBatchQuery(t *testing.T) {
+	condition := &DeletionCondition{Predicates: []*Predicate{{Column: "event_type", Operator: EqOperator, Values: []interface{}{"test"}}}}
+	where, _ := condition.sql()
+
+	require.Equal(t, `DELETE FROM "public"."events" WHERE event_type = $1 AND eventn_ctx_event_id IN `+
+		`(SELECT eventn_ctx_event_id FROM "public"."events" WHERE event_type = $1 AND eventn_ctx_event_id IS NOT NULL LIMIT 100)`,
+		deleteBatchQuery("public", "events", "eventn_ctx_event_id", where, 100))
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/jitsucom/eventnative/schema"
 	"io"
 	"net/http"
 )
@@ -17,6 +18,11 @@ type S3 struct {
 	client *s3.S3
 }
 
+const (
+	S3JsonFormat = "json"
+	S3CsvFormat  = "csv"
+)
+
 type S3Config struct {
 	AccessKeyID string `mapstructure:"access_key_id" json:"access_key_id,omitempty" yaml:"access_key_id,omitempty"`
 	SecretKey   string `mapstructure:"secret_access_key" json:"secret_access_key,omitempty" yaml:"secret_access_key,omitempty"`
@@ -24,6 +30,11 @@ type S3Config struct {
 	Region      string `mapstructure:"region" json:"region,omitempty" yaml:"region,omitempty"`
 	Endpoint    string `mapstructure:"endpoint" json:"endpoint,omitempty" yaml:"endpoint,omitempty"`
 	Folder      string `mapstructure:"folder" json:"folder,omitempty" yaml:"folder,omitempty"`
+	//batch files format (S3 destination only): json (default) or csv
+	Format string             `mapstructure:"format" json:"format,omitempty" yaml:"format,omitempty"`
+	Csv    *schema.CsvOptions `mapstructure:"csv" json:"csv,omitempty" yaml:"csv,omitempty"`
+	//write <file name>.manifest.json sidecar after every batch file (S3 destination only)
+	Manifest bool `mapstructure:"manifest" json:"manifest,omitempty" yaml:"manifest,omitempty"`
 }
 
 func (s3c *S3Config) Validate() error {
@@ -42,10 +53,26 @@ func (s3c *S3Config) Validate() error {
 	if s3c.Region == "" {
 		return errors.New("S3 region is required parameter")
 	}
+	switch s3c.Format {
+	case "", S3JsonFormat, S3CsvFormat:
+	default:
+		return fmt.Errorf("Unknown S3 format: %s. Available: [%s, %s]", s3c.Format, S3JsonFormat, S3CsvFormat)
+	}
+	if err := s3c.Csv.Validate(); err != nil {
+		return err
+	}
 
 	return nil
 }
 
+//GetFormat return configured or default (json) batch files format
+func (s3c *S3Config) GetFormat() string {
+	if s3c.Format == "" {
+		return S3JsonFormat
+	}
+	return s3c.Format
+}
+
 func NewS3(s3Config *S3Config) (*S3, error) {
 	if err := s3Config.Validate(); err != nil {
 		return nil, err
@@ -10,6 +10,7 @@ import (
 	"github.com/jitsucom/eventnative/typing"
 	sf "github.com/snowflakedb/gosnowflake"
 	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -27,6 +28,7 @@ const (
 	addSFColumnTemplate                 = `ALTER TABLE %s.%s ADD COLUMN %s %s`
 	createSFTableTemplate               = `CREATE TABLE %s.%s (%s)`
 	insertSFTemplate                    = `INSERT INTO %s.%s (%s) VALUES (%s)`
+	mergeSFTemplate                     = `MERGE INTO %s.%s T USING (SELECT %s) S ON %s WHEN MATCHED THEN UPDATE SET %s WHEN NOT MATCHED THEN INSERT (%s) VALUES (%s)`
 )
 
 var (
@@ -35,6 +37,10 @@ var (
 		typing.INT64:     "bigint",
 		typing.FLOAT64:   "numeric(38,18)",
 		typing.TIMESTAMP: "timestamp(6)",
+		typing.DECIMAL:   "numeric(38,18)",
+		typing.BOOL:      "boolean",
+		typing.DATE:      "date",
+		typing.UUID:      "character varying(36)",
 	}
 
 	SnowflakeToSchema = map[string]typing.DataType{
@@ -42,6 +48,8 @@ var (
 		"number0":       typing.INT64,
 		"number18":      typing.FLOAT64,
 		"timestamp_ntz": typing.TIMESTAMP,
+		"boolean":       typing.BOOL,
+		"date":          typing.DATE,
 	}
 )
 
@@ -157,7 +165,7 @@ func (s *Snowflake) CreateTable(tableSchema *schema.Table) error {
 
 	var columnsDDL []string
 	for columnName, column := range tableSchema.Columns {
-		mappedType, ok := SchemaToSnowflake[column.GetType()]
+		mappedType, ok := columnSQLType(column, SchemaToSnowflake, snowflakeDecimalTemplate)
 		if !ok {
 			logging.Error("Unknown snowflake schema type:", column.GetType())
 			mappedType = SchemaToSnowflake[typing.STRING]
@@ -186,34 +194,47 @@ func (s *Snowflake) CreateTable(tableSchema *schema.Table) error {
 
 //PatchTableSchema add new columns(from provided schema.Table) to existing table
 func (s *Snowflake) PatchTableSchema(patchSchema *schema.Table) error {
-	wrappedTx, err := s.OpenTx()
+	plan, err := addColumnsPlan(patchSchema)
 	if err != nil {
 		return err
 	}
 
-	for columnName, column := range patchSchema.Columns {
-		mappedColumnType, ok := SchemaToPostgres[column.GetType()]
-		if !ok {
-			logging.Error("Unknown snowflake schema type:", column.GetType().String())
-			mappedColumnType = SchemaToSnowflake[typing.STRING]
-		}
-		query := fmt.Sprintf(addSFColumnTemplate, s.config.Schema,
-			reformatValue(patchSchema.Name), reformatValue(columnName), mappedColumnType)
-		s.queryLogger.Log(query)
-		alterStmt, err := wrappedTx.tx.PrepareContext(s.ctx, query)
-		if err != nil {
-			wrappedTx.Rollback()
-			return fmt.Errorf("Error preparing patching table %s schema statement: %v", patchSchema.Name, err)
+	return s.ApplyMigration(plan)
+}
+
+//MigrationStatements return ADD COLUMN statements of the plan
+//Snowflake doesn't support column type changing (except varchar length and number precision)
+func (s *Snowflake) MigrationStatements(plan *schema.MigrationPlan) ([]string, error) {
+	var statements []string
+	for _, step := range plan.Steps {
+		if step.Kind != schema.AddColumnStep {
+			return nil, fmt.Errorf("Snowflake doesn't support column [%s] type changing from: %s to: %s", step.Column, step.From.String(), step.To.String())
 		}
 
-		_, err = alterStmt.ExecContext(s.ctx)
-		if err != nil {
-			wrappedTx.Rollback()
-			return fmt.Errorf("Error patching %s table with '%s' - %s column schema: %v", patchSchema.Name, columnName, mappedColumnType, err)
+		sqlType, ok := columnSQLType(step.ToColumn(), SchemaToSnowflake, snowflakeDecimalTemplate)
+		if !ok {
+			logging.Error("Unknown snowflake schema type:", step.To.String())
+			sqlType = SchemaToSnowflake[typing.STRING]
 		}
+		statements = append(statements, fmt.Sprintf(addSFColumnTemplate, s.config.Schema, reformatValue(plan.Table), reformatValue(step.Column), sqlType))
 	}
 
-	return wrappedTx.tx.Commit()
+	return statements, nil
+}
+
+//ApplyMigration execute migration plan statements in one transaction
+func (s *Snowflake) ApplyMigration(plan *schema.MigrationPlan) error {
+	statements, err := s.MigrationStatements(plan)
+	if err != nil {
+		return err
+	}
+
+	wrappedTx, err := s.OpenTx()
+	if err != nil {
+		return err
+	}
+
+	return applyStatementsInTransaction(s.ctx, wrappedTx, plan.Table, statements, s.queryLogger)
 }
 
 //GetTableSchema return table (name,columns with name and types) representation wrapped in schema.Table struct
@@ -233,6 +254,12 @@ func (s *Snowflake) GetTableSchema(tableName string) (*schema.Table, error) {
 		}
 		mappedType, ok := SnowflakeToSchema[strings.ToLower(columnSnowflakeType)]
 		if !ok {
+			//number with scale (precision isn't selected): number2 -> numeric(38,2)
+			lowerType := strings.ToLower(columnSnowflakeType)
+			if scale, err := strconv.Atoi(strings.TrimPrefix(lowerType, "number")); err == nil && strings.HasPrefix(lowerType, "number") {
+				table.Columns[strings.ToLower(columnName)] = schema.NewDecimalColumn(typing.DecimalSpec{Precision: typing.DefaultDecimalPrecision, Scale: scale})
+				continue
+			}
 			logging.Error("Unknown snowflake column type:", columnSnowflakeType)
 			mappedType = typing.STRING
 		}
@@ -281,19 +308,29 @@ func (s *Snowflake) Insert(schema *schema.Table, valuesMap map[string]interface{
 	return wrappedTx.DirectCommit()
 }
 
+//InsertInTransaction insert provided object in provided wrapped transaction
+//if table has primary key fields: upsert object with MERGE statement (Snowflake doesn't enforce primary keys)
 func (s *Snowflake) InsertInTransaction(wrappedTx *Transaction, schema *schema.Table, valuesMap map[string]interface{}) error {
-	var header, placeholders string
+	var columns, placeholders []string
 	var values []interface{}
 	for name, value := range valuesMap {
-		header += reformatValue(name) + ","
-		placeholders += "?,"
+		columns = append(columns, reformatValue(name))
+		placeholders = append(placeholders, "?")
 		values = append(values, value)
 	}
 
-	header = removeLastComma(header)
-	placeholders = removeLastComma(placeholders)
+	header := strings.Join(columns, ",")
+	var query string
+	if len(schema.PKFields) > 0 {
+		var err error
+		query, err = s.mergeQuery(schema, columns)
+		if err != nil {
+			return err
+		}
+	} else {
+		query = fmt.Sprintf(insertSFTemplate, s.config.Schema, reformatValue(schema.Name), header, strings.Join(placeholders, ","))
+	}
 
-	query := fmt.Sprintf(insertSFTemplate, s.config.Schema, reformatValue(schema.Name), header, placeholders)
 	s.queryLogger.LogWithValues(query, values)
 	insertStmt, err := wrappedTx.tx.PrepareContext(s.ctx, query)
 	if err != nil {
@@ -308,11 +345,44 @@ func (s *Snowflake) InsertInTransaction(wrappedTx *Transaction, schema *schema.T
 	return nil
 }
 
+//mergeQuery return MERGE statement with placeholders in columns order
+//all primary key fields must be in columns
+func (s *Snowflake) mergeQuery(table *schema.Table, columns []string) (string, error) {
+	columnsSet := map[string]bool{}
+	for _, column := range columns {
+		columnsSet[column] = true
+	}
+
+	var conditions []string
+	for _, pkField := range sortedPkFields(table.PKFields) {
+		pkColumn := reformatValue(pkField)
+		if !columnsSet[pkColumn] {
+			return "", fmt.Errorf("Error inserting in %s table: primary key field [%s] is missing in the object", table.Name, pkField)
+		}
+		conditions = append(conditions, "T."+pkColumn+" = S."+pkColumn)
+	}
+
+	var selectSection, updateSection, sourceValues []string
+	for _, column := range columns {
+		selectSection = append(selectSection, "? AS "+column)
+		updateSection = append(updateSection, "T."+column+" = S."+column)
+		sourceValues = append(sourceValues, "S."+column)
+	}
+
+	return fmt.Sprintf(mergeSFTemplate, s.config.Schema, reformatValue(table.Name), strings.Join(selectSection, ","),
+		strings.Join(conditions, " AND "), strings.Join(updateSection, ","), strings.Join(columns, ","), strings.Join(sourceValues, ",")), nil
+}
+
 func (s *Snowflake) UpdatePrimaryKey(patchTableSchema *schema.Table, patchConstraint *schema.PKFieldsPatch) error {
 	logging.Warn("Constraints update is not supported for Snowflake yet")
 	return nil
 }
 
+//Ping check the database connection
+func (s *Snowflake) Ping() error {
+	return s.dataSource.PingContext(s.ctx)
+}
+
 //Close underlying sql.DB
 func (s *Snowflake) Close() (multiErr error) {
 	return s.dataSource.Close()
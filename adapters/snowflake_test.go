@@ -1,6 +1,7 @@
 package adapters
 
 import (
+	"github.com/jitsucom/eventnative/schema"
 	"github.com/stretchr/testify/require"
 	"testing"
 )
@@ -87,3 +88,47 @@ func TestReformatToParam(t *testing.T) {
 		})
 	}
 }
+
+func TestMergeQuery(t *testing.T) {
+	tests := []struct {
+		name          string
+		pkFields      map[string]bool
+		columns       []string
+		expected      string
+		expectedError string
+	}{
+		{
+			"one primary key field",
+			map[string]bool{"id": true},
+			[]string{"id", "name"},
+			`MERGE INTO public.events T USING (SELECT ? AS id,? AS name) S ON T.id = S.id WHEN MATCHED THEN UPDATE SET T.id = S.id,T.name = S.name WHEN NOT MATCHED THEN INSERT (id,name) VALUES (S.id,S.name)`,
+			"",
+		},
+		{
+			"composite primary key",
+			map[string]bool{"user_id": true, "event_id": true},
+			[]string{"event_id", "user_id"},
+			`MERGE INTO public.events T USING (SELECT ? AS event_id,? AS user_id) S ON T.event_id = S.event_id AND T.user_id = S.user_id WHEN MATCHED THEN UPDATE SET T.event_id = S.event_id,T.user_id = S.user_id WHEN NOT MATCHED THEN INSERT (event_id,user_id) VALUES (S.event_id,S.user_id)`,
+			"",
+		},
+		{
+			"missing primary key field",
+			map[string]bool{"id": true},
+			[]string{"name"},
+			"",
+			"Error inserting in events table: primary key field [id] is missing in the object",
+		},
+	}
+	s := &Snowflake{config: &SnowflakeConfig{Schema: "public"}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := s.mergeQuery(&schema.Table{Name: "events", PKFields: tt.pkFields}, tt.columns)
+			if tt.expectedError != "" {
+				require.EqualError(t, err, tt.expectedError)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tt.expected, actual, "Merge queries aren't equal")
+			}
+		})
+	}
+}
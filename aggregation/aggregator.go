@@ -0,0 +1,422 @@
+package aggregation
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/jsonutils"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/metrics"
+	"github.com/jitsucom/eventnative/resources"
+	"github.com/jitsucom/eventnative/safego"
+	"github.com/jitsucom/eventnative/timestamp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	//OtherValue is a dimension value of events which exceed max groups of the rule in the window
+	OtherValue = "other"
+
+	CountKey       = "count"
+	WindowStartKey = "window_start"
+	WindowEndKey   = "window_end"
+	SumPrefix      = "sum_"
+	UniquesPrefix  = "uniques_"
+
+	eventTypeKey = "event_type"
+	apiTokenKey  = "api_key"
+
+	defaultWindowSec      = 60
+	defaultMaxGroups      = 10000
+	defaultEventTypeField = "/event_type"
+	flushCheckPeriod      = time.Second
+)
+
+//RuleConfig is an aggregation of events types. Fields are JSON paths of processed events
+type RuleConfig struct {
+	//aggregates event_type (e.g. it is used in destinations table_name_template)
+	Name       string   `mapstructure:"name"`
+	EventTypes []string `mapstructure:"event_types"`
+	Dimensions []string `mapstructure:"dimensions"`
+	Sums       []string `mapstructure:"sums"`
+	Uniques    []string `mapstructure:"uniques"`
+}
+
+//Config is an anonymous aggregation configuration. Rules are a list (not a map) because config keys are lowercased
+type Config struct {
+	Enabled   bool `mapstructure:"enabled"`
+	WindowSec int  `mapstructure:"window_sec"`
+	//aggregates of fewer events are suppressed (k-anonymity threshold). 0 - disabled
+	MinCount int `mapstructure:"min_count"`
+	//max dimension sets of a rule per token in a window. Events of new dimension sets are aggregated with 'other' values
+	MaxGroups int           `mapstructure:"max_groups"`
+	Rules     []*RuleConfig `mapstructure:"rules"`
+}
+
+//ConsumersFunc return token consumers of aggregates
+type ConsumersFunc func(tokenId string) []events.Consumer
+
+type field struct {
+	path   *jsonutils.JsonPath
+	column string
+}
+
+type rule struct {
+	name       string
+	dimensions []*field
+	sums       []*field
+	uniques    []*field
+}
+
+type windowKey struct {
+	tokenId     string
+	rule        string
+	windowStart int64
+}
+
+//group is an aggregate of one dimension set
+type group struct {
+	token         string
+	tokenId       string
+	rule          *rule
+	windowStart   time.Time
+	dimensionsKey string
+	dimensions    []interface{}
+	count         int64
+	sums          []float64
+	uniques       []*hll
+}
+
+//Aggregator aggregates events of configured types in memory per token, rule, window (by arrival time) and dimension set:
+//events count, sums of numeric fields and HyperLogLog estimations of distinct values. Events aren't kept, only
+//aggregates of finished windows are consumed by token destinations. Every server aggregates its own events:
+//in multi-node deployments aggregates of the same window are partial (counts and sums are additive, uniques aren't)
+type Aggregator struct {
+	serverName     string
+	window         time.Duration
+	minCount       int64
+	maxGroups      int
+	eventTypeField *jsonutils.JsonPath
+	rules          map[string][]*rule
+	consumers      ConsumersFunc
+	now            func() time.Time
+
+	mutex   sync.Mutex
+	windows map[windowKey]map[string]*group
+
+	closed chan struct{}
+	done   chan struct{}
+}
+
+//NewAggregator return configured and started Aggregator or nil if it is disabled
+func NewAggregator(serverName string, config Config, consumers ConsumersFunc) (*Aggregator, error) {
+	a, err := newAggregator(serverName, config, consumers)
+	if a == nil || err != nil {
+		return a, err
+	}
+
+	safego.Run(a.start)
+	logging.Infof("[anonymous_aggregation] Initialized with %d rules, window %s", len(config.Rules), a.window)
+	return a, nil
+}
+
+func newAggregator(serverName string, config Config, consumers ConsumersFunc) (*Aggregator, error) {
+	if !config.Enabled {
+		return nil, nil
+	}
+
+	window := config.WindowSec
+	if window <= 0 {
+		window = defaultWindowSec
+	}
+	maxGroups := config.MaxGroups
+	if maxGroups <= 0 {
+		maxGroups = defaultMaxGroups
+	}
+
+	a := &Aggregator{
+		serverName:     serverName,
+		window:         time.Duration(window) * time.Second,
+		minCount:       int64(config.MinCount),
+		maxGroups:      maxGroups,
+		eventTypeField: jsonutils.NewJsonPath(defaultEventTypeField),
+		rules:          map[string][]*rule{},
+		consumers:      consumers,
+		now:            time.Now,
+		windows:        map[windowKey]map[string]*group{},
+		closed:         make(chan struct{}),
+		done:           make(chan struct{}),
+	}
+
+	names := map[string]bool{}
+	for _, ruleConfig := range config.Rules {
+		if ruleConfig.Name == "" {
+			return nil, fmt.Errorf("Anonymous aggregation rule name is required")
+		}
+		if names[ruleConfig.Name] {
+			return nil, fmt.Errorf("Anonymous aggregation rule [%s] is declared twice", ruleConfig.Name)
+		}
+		names[ruleConfig.Name] = true
+		if len(ruleConfig.EventTypes) == 0 {
+			return nil, fmt.Errorf("Anonymous aggregation rule [%s] event_types is required parameter", ruleConfig.Name)
+		}
+
+		r := &rule{
+			name:       ruleConfig.Name,
+			dimensions: parseFields(ruleConfig.Dimensions, ""),
+			sums:       parseFields(ruleConfig.Sums, SumPrefix),
+			uniques:    parseFields(ruleConfig.Uniques, UniquesPrefix),
+		}
+		for _, eventType := range ruleConfig.EventTypes {
+			a.rules[eventType] = append(a.rules[eventType], r)
+		}
+	}
+
+	return a, nil
+}
+
+//parseFields return fields with columns names: JSON path parts joined with '_' (e.g. /utm/source - utm_source)
+func parseFields(paths []string, prefix string) []*field {
+	var fields []*field
+	for _, path := range paths {
+		jsonPath := jsonutils.NewJsonPath(path)
+		fields = append(fields, &field{path: jsonPath, column: prefix + strings.Join(jsonPath.Parts(), "_")})
+	}
+	return fields
+}
+
+//Match return true if the event type is aggregated. Such events mustn't be cached or stored row-level
+func (a *Aggregator) Match(event events.Fact) bool {
+	return len(a.matchedRules(event)) > 0
+}
+
+//Aggregate add the processed event into the current window aggregates of all matched rules
+func (a *Aggregator) Aggregate(token, tokenId string, event events.Fact) {
+	rules := a.matchedRules(event)
+	if len(rules) == 0 {
+		return
+	}
+	windowStart := a.now().UTC().Truncate(a.window)
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	for _, r := range rules {
+		wk := windowKey{tokenId: tokenId, rule: r.name, windowStart: windowStart.Unix()}
+		groups, ok := a.windows[wk]
+		if !ok {
+			groups = map[string]*group{}
+			a.windows[wk] = groups
+		}
+
+		dimensions := make([]interface{}, len(r.dimensions))
+		for i, dimension := range r.dimensions {
+			dimensions[i] = dimensionValue(dimension.path, event)
+		}
+		key := dimensionsKey(dimensions)
+		g, ok := groups[key]
+		if !ok && len(groups) >= a.maxGroups {
+			for i := range dimensions {
+				dimensions[i] = OtherValue
+			}
+			key = dimensionsKey(dimensions)
+			g, ok = groups[key]
+		}
+		if !ok {
+			g = &group{
+				token:         token,
+				tokenId:       tokenId,
+				rule:          r,
+				windowStart:   windowStart,
+				dimensionsKey: key,
+				dimensions:    dimensions,
+				sums:          make([]float64, len(r.sums)),
+				uniques:       make([]*hll, len(r.uniques)),
+			}
+			for i := range g.uniques {
+				g.uniques[i] = newHll()
+			}
+			groups[key] = g
+		}
+
+		g.count++
+		for i, sum := range r.sums {
+			if value, ok := sum.path.Get(event); ok {
+				if number, ok := toFloat(value); ok {
+					g.sums[i] += number
+				}
+			}
+		}
+		for i, unique := range r.uniques {
+			if value, ok := unique.path.Get(event); ok && value != nil && value != "" {
+				g.uniques[i].add(fmt.Sprint(value))
+			}
+		}
+
+		metrics.AnonymousAggregationEvent(r.name, metrics.AggregatedResult)
+	}
+}
+
+//Skip count the matched event which isn't aggregated (e.g. it doesn't match JSON Schema or tracking plan)
+func (a *Aggregator) Skip(event events.Fact) {
+	for _, r := range a.matchedRules(event) {
+		metrics.AnonymousAggregationEvent(r.name, metrics.SkippedResult)
+	}
+}
+
+//Close stop flushing and consume aggregates of all windows including the current one
+func (a *Aggregator) Close() error {
+	close(a.closed)
+	<-a.done
+	a.flush(true)
+	return nil
+}
+
+func (a *Aggregator) start() {
+	defer close(a.done)
+
+	ticker := time.NewTicker(flushCheckPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.closed:
+			return
+		case <-ticker.C:
+			a.flush(false)
+		}
+	}
+}
+
+//flush consume aggregates of finished windows (all windows if all is true). Aggregates of fewer than min count
+//events are suppressed
+func (a *Aggregator) flush(all bool) {
+	now := a.now().UTC()
+	var groups []*group
+
+	a.mutex.Lock()
+	for wk, windowGroups := range a.windows {
+		if !all && time.Unix(wk.windowStart, 0).Add(a.window).After(now) {
+			continue
+		}
+		for _, g := range windowGroups {
+			groups = append(groups, g)
+		}
+		delete(a.windows, wk)
+	}
+	a.mutex.Unlock()
+
+	sort.Slice(groups, func(i, j int) bool {
+		if !groups[i].windowStart.Equal(groups[j].windowStart) {
+			return groups[i].windowStart.Before(groups[j].windowStart)
+		}
+		if groups[i].rule.name != groups[j].rule.name {
+			return groups[i].rule.name < groups[j].rule.name
+		}
+		return groups[i].dimensionsKey < groups[j].dimensionsKey
+	})
+
+	for _, g := range groups {
+		if g.count < a.minCount {
+			metrics.AnonymousAggregate(g.rule.name, metrics.SuppressedResult)
+			continue
+		}
+
+		consumers := a.consumers(g.tokenId)
+		if len(consumers) == 0 {
+			logging.Warnf("[anonymous_aggregation] Token [%s] doesn't have destinations: [%s] aggregate is skipped", g.tokenId, g.rule.name)
+			continue
+		}
+		fact := a.fact(g)
+		for _, consumer := range consumers {
+			consumer.Consume(fact, g.tokenId)
+		}
+		metrics.AnonymousAggregate(g.rule.name, metrics.WrittenResult)
+	}
+}
+
+//fact return the aggregate event. Event id is deterministic so the same aggregate isn't duplicated by replays
+func (a *Aggregator) fact(g *group) events.Fact {
+	windowStart := timestamp.ToISOFormat(g.windowStart)
+	fact := events.Fact{
+		eventTypeKey:   g.rule.name,
+		apiTokenKey:    g.token,
+		timestamp.Key:  windowStart,
+		WindowStartKey: windowStart,
+		WindowEndKey:   timestamp.ToISOFormat(g.windowStart.Add(a.window)),
+		CountKey:       g.count,
+	}
+	for i, dimension := range g.rule.dimensions {
+		if g.dimensions[i] != nil {
+			fact[dimension.column] = g.dimensions[i]
+		}
+	}
+	for i, sum := range g.rule.sums {
+		fact[sum.column] = g.sums[i]
+	}
+	for i, unique := range g.rule.uniques {
+		fact[unique.column] = g.uniques[i].estimate()
+	}
+
+	id := strings.Join([]string{a.serverName, g.tokenId, g.rule.name, windowStart, g.dimensionsKey}, "|")
+	events.EnrichWithEventId(fact, resources.GetHash([]byte(id)))
+	return fact
+}
+
+func (a *Aggregator) matchedRules(event events.Fact) []*rule {
+	value, ok := a.eventTypeField.Get(event)
+	if !ok {
+		return nil
+	}
+	eventType, ok := value.(string)
+	if !ok {
+		return nil
+	}
+	return a.rules[eventType]
+}
+
+//dimensionValue return scalar value as is, objects and arrays as JSON strings
+func dimensionValue(path *jsonutils.JsonPath, event events.Fact) interface{} {
+	value, ok := path.Get(event)
+	if !ok || value == nil {
+		return nil
+	}
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		b, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Sprint(value)
+		}
+		return string(b)
+	default:
+		return value
+	}
+}
+
+func dimensionsKey(dimensions []interface{}) string {
+	b, err := json.Marshal(dimensions)
+	if err != nil {
+		return fmt.Sprint(dimensions)
+	}
+	return string(b)
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,129 @@
+package aggregation
+
+import (
+	"fmt"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+type collectingConsumer struct {
+	facts []events.Fact
+}
+
+func (cc *collectingConsumer) Consume(fact events.Fact, tokenId string) {
+	cc.facts = append(cc.facts, fact)
+}
+
+func (cc *collectingConsumer) Close() error {
+	return nil
+}
+
+func TestAggregate(t *testing.T) {
+	consumer := &collectingConsumer{}
+	a, err := newAggregator("server1", Config{
+		Enabled:   true,
+		WindowSec: 60,
+		MinCount:  2,
+		MaxGroups: 2,
+		Rules: []*RuleConfig{{
+			Name:       "pageviews_aggregates",
+			EventTypes: []string{"pageview"},
+			Dimensions: []string{"/utm/source"},
+			Sums:       []string{"/revenue"},
+			Uniques:    []string{"/eventn_ctx/user/anonymous_id"},
+		}},
+	}, func(tokenId string) []events.Consumer { return []events.Consumer{consumer} })
+	require.NoError(t, err)
+
+	now := time.Date(2020, 10, 1, 10, 0, 30, 0, time.UTC)
+	a.now = func() time.Time { return now }
+
+	event := func(eventType, source string, revenue interface{}, anonymousId string) events.Fact {
+		return events.Fact{
+			"event_type": eventType,
+			"utm":        map[string]interface{}{"source": source},
+			"revenue":    revenue,
+			"eventn_ctx": map[string]interface{}{"user": map[string]interface{}{"anonymous_id": anonymousId}},
+		}
+	}
+
+	require.False(t, a.Match(event("signup", "google", 1.0, "a")))
+	require.True(t, a.Match(event("pageview", "google", 1.0, "a")))
+
+	for _, e := range []events.Fact{
+		event("pageview", "google", 1.5, "a"),
+		event("pageview", "google", 2.0, "b"),
+		event("pageview", "google", "not a number", "a"),
+		//suppressed: fewer than min count events
+		event("pageview", "bing", 1.0, "c"),
+		//max groups are exceeded
+		event("pageview", "yahoo", 1.0, "d"),
+		event("pageview", "duckduckgo", 1.0, "e"),
+	} {
+		a.Aggregate("token1", "token1_id", e)
+	}
+
+	//current window isn't flushed
+	a.flush(false)
+	require.Empty(t, consumer.facts)
+
+	now = now.Add(time.Minute)
+	a.flush(false)
+	require.Len(t, consumer.facts, 2)
+
+	google := consumer.facts[0]
+	require.Equal(t, "pageviews_aggregates", google["event_type"])
+	require.Equal(t, "2020-10-01T10:00:00.000000Z", google[WindowStartKey])
+	require.Equal(t, "2020-10-01T10:01:00.000000Z", google[WindowEndKey])
+	require.Equal(t, "token1", google["api_key"])
+	require.Equal(t, "google", google["utm_source"])
+	require.Equal(t, int64(3), google[CountKey])
+	require.Equal(t, 3.5, google["sum_revenue"])
+	require.Equal(t, int64(2), google["uniques_eventn_ctx_user_anonymous_id"])
+	require.NotEmpty(t, events.ExtractEventId(google))
+
+	other := consumer.facts[1]
+	require.Equal(t, OtherValue, other["utm_source"])
+	require.Equal(t, int64(2), other[CountKey])
+
+	//windows are removed after flush
+	a.flush(true)
+	require.Len(t, consumer.facts, 2)
+}
+
+func TestHllEstimate(t *testing.T) {
+	tests := []int{0, 1, 100, 10000, 200000}
+	for _, cardinality := range tests {
+		h := newHll()
+		for i := 0; i < cardinality; i++ {
+			h.add(fmt.Sprintf("user%d", i))
+			//duplicates don't change estimation
+			h.add(fmt.Sprintf("user%d", i))
+		}
+		estimate := float64(h.estimate())
+		require.InDelta(t, float64(cardinality), estimate, float64(cardinality)*0.05+1, "cardinality %d", cardinality)
+	}
+}
+
+func TestNewAggregatorErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []*RuleConfig
+	}{
+		{"Empty name", []*RuleConfig{{EventTypes: []string{"pageview"}}}},
+		{"Empty event types", []*RuleConfig{{Name: "agg"}}},
+		{"Duplicate name", []*RuleConfig{{Name: "agg", EventTypes: []string{"a"}}, {Name: "agg", EventTypes: []string{"b"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newAggregator("server1", Config{Enabled: true, Rules: tt.rules}, nil)
+			require.Error(t, err)
+		})
+	}
+
+	a, err := newAggregator("server1", Config{}, nil)
+	require.NoError(t, err)
+	require.Nil(t, a)
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/spf13/viper"
 	"io"
 	"os"
+	"time"
 )
 
 type AppConfig struct {
@@ -35,6 +36,19 @@ func setDefaultParams() {
 	viper.SetDefault("server.sync_tasks.pool.size", 500)
 	viper.SetDefault("server.disable_version_reminder", false)
 	viper.SetDefault("server.cache.events.size", 100)
+	viper.SetDefault("server.signed_tokens.default_ttl_sec", 3600)
+	viper.SetDefault("server.signed_tokens.max_ttl_sec", 86400)
+	viper.SetDefault("server.client_versions.header", "X-Client-Version")
+	viper.SetDefault("server.client_versions.fields", []string{"/eventn_ctx/client_version", "/client_version"})
+	viper.SetDefault("server.ledger.history_size", 1000)
+	viper.SetDefault("server.parallel_parsing.min_file_size_kb", 1024)
+	viper.SetDefault("server.bulk_delete.pause_ms", 1000)
+	viper.SetDefault("server.health.check_timeout_sec", 5)
+	viper.SetDefault("server.shutdown.drain_timeout_sec", 30)
+	viper.SetDefault("server.log.async", true)
+	viper.SetDefault("server.log.async_buffer_size", logging.DefaultAsyncBufferSize)
+	viper.SetDefault("server.log.format", logging.TextFormat)
+	viper.SetDefault("server.log.level", "debug")
 	viper.SetDefault("geo.maxmind_path", "/home/eventnative/app/res/")
 	viper.SetDefault("log.path", "/home/eventnative/logs/events")
 	viper.SetDefault("log.fallback", "/home/eventnative/logs/fallback")
@@ -57,6 +71,10 @@ func Init() error {
 	if err := globalLoggerConfig.Validate(); err != nil {
 		return fmt.Errorf("Error while creating global logger: %v", err)
 	}
+	//per component levels, e.g. schema: warn (components: schema, events, destinations)
+	if err := logging.Configure(viper.GetString("server.log.format"), viper.GetString("server.log.level"), viper.GetStringMapString("server.log.levels")); err != nil {
+		return fmt.Errorf("Error while configuring global logger: %v", err)
+	}
 
 	//Global logger writes logs and sends system error notifications
 	//
@@ -73,7 +91,13 @@ func Init() error {
 	} else {
 		globalLogsWriter = os.Stdout
 	}
-	err := logging.InitGlobalLogger(globalLogsWriter)
+	var err error
+	if viper.GetBool("server.log.async") {
+		//logging never blocks events processing: lines are written in batches by a separate goroutine
+		err = logging.InitAsyncGlobalLogger(globalLogsWriter, viper.GetInt("server.log.async_buffer_size"))
+	} else {
+		err = logging.InitGlobalLogger(globalLogsWriter)
+	}
 	if err != nil {
 		return err
 	}
@@ -140,14 +164,34 @@ func NewQueryWriter(globalLogsWriter io.Writer) (io.Writer, error) {
 	return queryLogsWriter, nil
 }
 
+//ScheduleClosing add the component to the closing list. Components are closed on shutdown in reverse scheduling order
+//(like defer): a component must be scheduled after the components it writes to (e.g. sources after destinations)
 func (a *AppConfig) ScheduleClosing(c io.Closer) {
 	a.closeMe = append(a.closeMe, c)
 }
 
+//Close close all scheduled components in reverse order: components are scheduled after their dependencies
+//(e.g. HTTP server and processing shards after destinations), so producers are stopped and drained before consumers are closed
 func (a *AppConfig) Close() {
-	for _, cl := range a.closeMe {
-		if err := cl.Close(); err != nil {
+	for i := len(a.closeMe) - 1; i >= 0; i-- {
+		if err := a.closeMe[i].Close(); err != nil {
 			logging.Error(err)
 		}
 	}
 }
+
+//Drain call Close and return true if it has finished in timeout
+func (a *AppConfig) Drain(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		a.Close()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
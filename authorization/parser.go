@@ -12,6 +12,23 @@ type Token struct {
 	ClientSecret string   `mapstructure:"client_secret" json:"client_secret,omitempty"`
 	ServerSecret string   `mapstructure:"server_secret" json:"server_secret,omitempty"`
 	Origins      []string `mapstructure:"origins" json:"origins,omitempty"`
+
+	//multi-tenant routing: if configured - the token events are stored only in these destinations (only_tokens are ignored)
+	Destinations []string `mapstructure:"destinations" json:"destinations,omitempty"`
+	//multi-tenant processing: if configured - every destination of the token gets a separate instance with overridden data_layout
+	DataLayout *TokenDataLayout `mapstructure:"data_layout" json:"data_layout,omitempty"`
+	//workspace id: the token events are stored only in the workspace destinations. Is set for workspaces tokens
+	Workspace string `mapstructure:"workspace" json:"workspace,omitempty"`
+	//test mode: all the token events are test traffic and are stored into <table>_test tables (see events.IsTest)
+	Test bool `mapstructure:"test" json:"test,omitempty"`
+}
+
+//TokenDataLayout overrides destinations data_layout fields (only not empty ones) for the token events
+//e.g. per tenant table names which prevent one tenant's schema leaking into another's tables
+type TokenDataLayout struct {
+	MappingType       string   `mapstructure:"mapping_type" json:"mapping_type,omitempty"`
+	Mapping           []string `mapstructure:"mapping" json:"mapping,omitempty"`
+	TableNameTemplate string   `mapstructure:"table_name_template" json:"table_name_template,omitempty"`
 }
 
 type TokensPayload struct {
@@ -34,6 +51,19 @@ func (th *TokensHolder) IsEmpty() bool {
 	return th == nil || len(th.ids) == 0
 }
 
+//tokens return all token objects
+func (th *TokensHolder) tokens() []Token {
+	if th == nil {
+		return []Token{}
+	}
+
+	tokens := make([]Token, 0, len(th.ids))
+	for _, id := range th.ids {
+		tokens = append(tokens, th.all[id])
+	}
+	return tokens
+}
+
 //parse tokens from json bytes
 func parseFromBytes(b []byte) (*TokensHolder, error) {
 	payload := &TokensPayload{}
@@ -56,6 +86,14 @@ func fromStrings(clientSecrets, serverSecrets []string) *TokensHolder {
 	return reformat(tokens)
 }
 
+//GetTokenId return token id or hash from client,server secret if id is empty
+func GetTokenId(token Token) string {
+	if token.Id != "" {
+		return token.Id
+	}
+	return resources.GetHash([]byte(token.ClientSecret + token.ServerSecret))
+}
+
 func reformat(tokens []Token) *TokensHolder {
 	clientTokensOrigins := map[string][]string{}
 	serverTokensOrigins := map[string][]string{}
@@ -63,10 +101,7 @@ func reformat(tokens []Token) *TokensHolder {
 	var ids []string
 
 	for _, tokenObj := range tokens {
-		if tokenObj.Id == "" {
-			//hash from client,server secret will be id
-			tokenObj.Id = resources.GetHash([]byte(tokenObj.ClientSecret + tokenObj.ServerSecret))
-		}
+		tokenObj.Id = GetTokenId(tokenObj)
 
 		all[tokenObj.Id] = tokenObj
 		ids = append(ids, tokenObj.Id)
@@ -1,7 +1,9 @@
 package authorization
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/jitsucom/eventnative/logging"
 	"github.com/jitsucom/eventnative/resources"
 	"github.com/jitsucom/eventnative/uuid"
@@ -25,16 +27,32 @@ type Service struct {
 	tokensHolder *TokensHolder
 	//will call after every reloading
 	DestinationsForceReload func()
+	//http(s):// or file:// source if tokens are reloaded from it
+	source string
+	//workspaces tokens are added to configured ones after every reloading
+	workspaceTokens []Token
+
+	//nil if server.signed_tokens.secret isn't configured
+	signer          *Signer
+	signedTokensTTL time.Duration
+	signedTokensMax time.Duration
 }
 
 func NewService() (*Service, error) {
-	service := &Service{}
+	service := &Service{
+		signedTokensTTL: time.Duration(viper.GetInt("server.signed_tokens.default_ttl_sec")) * time.Second,
+		signedTokensMax: time.Duration(viper.GetInt("server.signed_tokens.max_ttl_sec")) * time.Second,
+	}
 
 	reloadSec := viper.GetInt("server.auth_reload_sec")
 	if reloadSec == 0 {
 		return nil, errors.New("server.auth_reload_sec can't be empty")
 	}
 
+	if signingSecret := viper.GetString("server.signed_tokens.secret"); signingSecret != "" {
+		service.signer = NewSigner(signingSecret)
+	}
+
 	//deprecated viper key
 	deprecatedS2SAuth := viper.GetStringSlice(deprecatedViperAuthKey)
 
@@ -51,8 +69,10 @@ func NewService() (*Service, error) {
 		if len(auth) == 1 {
 			authSource := auth[0]
 			if strings.HasPrefix(authSource, "http://") || strings.HasPrefix(authSource, "https://") {
+				service.source = authSource
 				resources.Watch(serviceName, authSource, resources.LoadFromHttp, service.updateTokens, time.Duration(reloadSec)*time.Second)
 			} else if strings.HasPrefix(authSource, "file://") {
+				service.source = authSource
 				resources.Watch(serviceName, strings.Replace(authSource, "file://", "", 1), resources.LoadFromFile, service.updateTokens, time.Duration(reloadSec)*time.Second)
 			} else if strings.HasPrefix(authSource, "{") && strings.HasSuffix(authSource, "}") {
 				tokensHolder, err := parseFromBytes([]byte(authSource))
@@ -88,8 +108,10 @@ func NewService() (*Service, error) {
 	return service, nil
 }
 
-//GetClientOrigins return origins by client_secret
+//GetClientOrigins return origins by client_secret or by valid signed token with js scope
 func (s *Service) GetClientOrigins(clientSecret string) ([]string, bool) {
+	clientSecret = s.unwrapSigned(clientSecret, JsScope)
+
 	s.RLock()
 	defer s.RUnlock()
 
@@ -97,8 +119,10 @@ func (s *Service) GetClientOrigins(clientSecret string) ([]string, bool) {
 	return origins, ok
 }
 
-//GetServerOrigins return origins by server_secret
+//GetServerOrigins return origins by server_secret or by valid signed token with s2s scope
 func (s *Service) GetServerOrigins(serverSecret string) ([]string, bool) {
+	serverSecret = s.unwrapSigned(serverSecret, ApiScope)
+
 	s.RLock()
 	defer s.RUnlock()
 
@@ -106,6 +130,106 @@ func (s *Service) GetServerOrigins(serverSecret string) ([]string, bool) {
 	return origins, ok
 }
 
+//ResolveToken return original client_secret/server_secret (by signed token id and scope) if token is a valid signed token
+//otherwise return token as is
+func (s *Service) ResolveToken(token string) string {
+	if s.signer == nil || !IsSigned(token) {
+		return token
+	}
+
+	signedToken, err := s.signer.Verify(token, time.Now())
+	if err != nil {
+		return token
+	}
+
+	secret, ok := s.getSecret(signedToken.TokenId, signedToken.Scope)
+	if !ok {
+		return token
+	}
+	return secret
+}
+
+//SignToken return short-lived signed token of client_secret (js scope) or server_secret (s2s scope) token
+//if ttl is 0 - server.signed_tokens.default_ttl_sec is used
+func (s *Service) SignToken(token, scope string, ttl time.Duration) (string, time.Time, error) {
+	if s.signer == nil {
+		return "", time.Time{}, errors.New("server.signed_tokens.secret must be configured")
+	}
+
+	if ttl <= 0 {
+		ttl = s.signedTokensTTL
+	}
+	if s.signedTokensMax > 0 && ttl > s.signedTokensMax {
+		return "", time.Time{}, fmt.Errorf("ttl can't be greater than %s", s.signedTokensMax)
+	}
+
+	s.RLock()
+	var exist bool
+	switch scope {
+	case JsScope:
+		_, exist = s.tokensHolder.clientTokensOrigins[token]
+	case ApiScope:
+		_, exist = s.tokensHolder.serverTokensOrigins[token]
+	}
+	tokenId := s.tokensHolder.all[token].Id
+	s.RUnlock()
+
+	if !exist {
+		return "", time.Time{}, fmt.Errorf("Token with scope [%s] wasn't found", scope)
+	}
+
+	//signed token payload is readable: only token id is put into it
+	expiresAt := time.Now().UTC().Add(ttl)
+	signed, err := s.signer.Sign(tokenId, scope, expiresAt)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return signed, expiresAt, nil
+}
+
+//unwrapSigned return original token if token is a valid signed token with the scope
+//otherwise return token as is
+func (s *Service) unwrapSigned(token, scope string) string {
+	if s.signer == nil || !IsSigned(token) {
+		return token
+	}
+
+	tokenId, err := s.signer.VerifyScope(token, scope, time.Now())
+	if err != nil {
+		logging.Debugf("Signed token rejected: %v", err)
+		return token
+	}
+
+	secret, ok := s.getSecret(tokenId, scope)
+	if !ok {
+		logging.Debugf("Signed token rejected: token [%s] wasn't found", tokenId)
+		return token
+	}
+	return secret
+}
+
+//getSecret return client_secret (js scope) or server_secret (s2s scope) of the token by token id
+//return false if the token doesn't exist anymore or doesn't have the secret
+func (s *Service) getSecret(tokenId, scope string) (string, bool) {
+	s.RLock()
+	defer s.RUnlock()
+
+	tokenObj, ok := s.tokensHolder.all[tokenId]
+	if !ok || tokenObj.Id != tokenId {
+		return "", false
+	}
+
+	var secret string
+	switch scope {
+	case JsScope:
+		secret = strings.TrimSpace(tokenObj.ClientSecret)
+	case ApiScope:
+		secret = strings.TrimSpace(tokenObj.ServerSecret)
+	}
+	return secret, secret != ""
+}
+
 //GetAllTokenIds return all token ids
 func (s *Service) GetAllTokenIds() []string {
 	s.RLock()
@@ -114,6 +238,58 @@ func (s *Service) GetAllTokenIds() []string {
 	return s.tokensHolder.ids
 }
 
+//GetTokens return all token objects
+func (s *Service) GetTokens() []Token {
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.tokensHolder.tokens()
+}
+
+//SetWorkspaceTokens add workspaces tokens to configured ones (replace previous workspaces tokens)
+func (s *Service) SetWorkspaceTokens(workspaceTokens []Token) {
+	s.Lock()
+	var tokens []Token
+	for _, token := range s.tokensHolder.tokens() {
+		if token.Workspace == "" {
+			tokens = append(tokens, token)
+		}
+	}
+	s.workspaceTokens = workspaceTokens
+	s.tokensHolder = reformat(append(tokens, workspaceTokens...))
+	s.Unlock()
+
+	if s.DestinationsForceReload != nil {
+		s.DestinationsForceReload()
+	}
+}
+
+//SetTokens replace all tokens (declarative configuration):
+//file:// source is rewritten, configured in server.auth tokens are replaced in memory only (until restart)
+//http(s):// source isn't supported because tokens will be overwritten on the next reloading
+//return true if tokens have been persisted
+func (s *Service) SetTokens(tokens []Token) (bool, error) {
+	if strings.HasPrefix(s.source, "http://") || strings.HasPrefix(s.source, "https://") {
+		return false, fmt.Errorf("Tokens are reloaded from %s and can't be changed", s.source)
+	}
+
+	payload, err := json.MarshalIndent(TokensPayload{Tokens: tokens}, "", "  ")
+	if err != nil {
+		return false, fmt.Errorf("Error marshalling tokens: %v", err)
+	}
+
+	persisted := false
+	if strings.HasPrefix(s.source, "file://") {
+		if err := resources.SaveToFile(strings.Replace(s.source, "file://", "", 1), payload); err != nil {
+			return false, err
+		}
+		persisted = true
+	}
+
+	s.updateTokens(payload)
+	return persisted, nil
+}
+
 //GetAllIdsByToken return token ids by token identity(client_secret/server_secret/token id)
 func (s *Service) GetAllIdsByToken(tokenIdentity []string) (ids []string) {
 	s.RLock()
@@ -146,6 +322,28 @@ func (s *Service) GetTokenId(tokenFilter string) string {
 	return ""
 }
 
+//GetTokenWorkspace return workspace id of the token (token id)
+//return "" if token wasn't found or doesn't belong to any workspace
+func (s *Service) GetTokenWorkspace(tokenId string) string {
+	s.RLock()
+	defer s.RUnlock()
+
+	token, ok := s.tokensHolder.all[tokenId]
+	if ok {
+		return token.Workspace
+	}
+	return ""
+}
+
+//IsTestToken return true if the token (token id) is in test mode
+func (s *Service) IsTestToken(tokenId string) bool {
+	s.RLock()
+	defer s.RUnlock()
+
+	token, ok := s.tokensHolder.all[tokenId]
+	return ok && token.Test
+}
+
 //parse and set tokensHolder with lock
 func (s *Service) updateTokens(payload []byte) {
 	tokenHolder, err := parseFromBytes(payload)
@@ -153,6 +351,9 @@ func (s *Service) updateTokens(payload []byte) {
 		logging.Errorf("Error updating authorization tokens: %v", err)
 	} else {
 		s.Lock()
+		if len(s.workspaceTokens) > 0 {
+			tokenHolder = reformat(append(tokenHolder.tokens(), s.workspaceTokens...))
+		}
 		s.tokensHolder = tokenHolder
 		s.Unlock()
 
@@ -0,0 +1,120 @@
+package authorization
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	//JsScope allows signed token usage only on client (js) endpoints
+	JsScope = "js"
+	//ApiScope allows signed token usage only on server (s2s) endpoints
+	ApiScope = "s2s"
+
+	signedTokenPrefix = "sgn."
+)
+
+var (
+	ErrWrongSignedTokenFormat = errors.New("Wrong signed token format")
+	ErrWrongSignature         = errors.New("Signed token signature doesn't match")
+	ErrSignedTokenExpired     = errors.New("Signed token is expired")
+	ErrWrongScope             = errors.New("Signed token scope doesn't match")
+)
+
+//SignedToken is a payload of signed token. Payload isn't encrypted: it contains token id and never secrets
+type SignedToken struct {
+	TokenId   string `json:"i"`
+	Scope     string `json:"s"`
+	ExpiresAt int64  `json:"e"`
+}
+
+//Signer mints and verifies short-lived tokens: sgn.base64(payload).base64(hmac-sha256(payload))
+//signed token refers to the original token by id with scope and expiration
+type Signer struct {
+	secret []byte
+}
+
+func NewSigner(secret string) *Signer {
+	return &Signer{secret: []byte(secret)}
+}
+
+//IsSigned return true if token has signed token prefix
+func IsSigned(token string) bool {
+	return strings.HasPrefix(token, signedTokenPrefix)
+}
+
+//Sign return signed token which refers to the original token id with scope and expiration time
+func (s *Signer) Sign(tokenId, scope string, expiresAt time.Time) (string, error) {
+	if scope != JsScope && scope != ApiScope {
+		return "", fmt.Errorf("Unknown scope: %s. Supported: %s, %s", scope, JsScope, ApiScope)
+	}
+
+	b, err := json.Marshal(SignedToken{TokenId: tokenId, Scope: scope, ExpiresAt: expiresAt.Unix()})
+	if err != nil {
+		return "", fmt.Errorf("Error marshalling signed token payload: %v", err)
+	}
+
+	encodedPayload := base64.RawURLEncoding.EncodeToString(b)
+	return signedTokenPrefix + encodedPayload + "." + s.signature(encodedPayload), nil
+}
+
+//Verify check signature and expiration
+//return SignedToken with original token id and scope if signed token is valid
+func (s *Signer) Verify(signedToken string, now time.Time) (*SignedToken, error) {
+	if !IsSigned(signedToken) {
+		return nil, ErrWrongSignedTokenFormat
+	}
+
+	parts := strings.Split(strings.TrimPrefix(signedToken, signedTokenPrefix), ".")
+	if len(parts) != 2 {
+		return nil, ErrWrongSignedTokenFormat
+	}
+	encodedPayload, signature := parts[0], parts[1]
+
+	if !hmac.Equal([]byte(signature), []byte(s.signature(encodedPayload))) {
+		return nil, ErrWrongSignature
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return nil, ErrWrongSignedTokenFormat
+	}
+
+	payload := &SignedToken{}
+	if err := json.Unmarshal(b, payload); err != nil {
+		return nil, ErrWrongSignedTokenFormat
+	}
+
+	if now.Unix() >= payload.ExpiresAt {
+		return nil, ErrSignedTokenExpired
+	}
+
+	return payload, nil
+}
+
+//VerifyScope check signed token with Verify and compare scope
+//return original token id if signed token is valid
+func (s *Signer) VerifyScope(signedToken, scope string, now time.Time) (string, error) {
+	payload, err := s.Verify(signedToken, now)
+	if err != nil {
+		return "", err
+	}
+
+	if payload.Scope != scope {
+		return "", ErrWrongScope
+	}
+
+	return payload.TokenId, nil
+}
+
+func (s *Signer) signature(encodedPayload string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(encodedPayload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,134 @@
+package authorization
+
+import (
+	"encoding/base64"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSigner(t *testing.T) {
+	now := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	signer := NewSigner("secret")
+
+	jsToken, err := signer.Sign("token_id", JsScope, now.Add(time.Hour))
+	require.NoError(t, err)
+	require.True(t, IsSigned(jsToken))
+
+	tests := []struct {
+		name          string
+		signedToken   string
+		scope         string
+		now           time.Time
+		expectedToken string
+		expectedErr   error
+	}{
+		{
+			"Valid token",
+			jsToken,
+			JsScope,
+			now,
+			"token_id",
+			nil,
+		},
+		{
+			"Expired token",
+			jsToken,
+			JsScope,
+			now.Add(time.Hour),
+			"",
+			ErrSignedTokenExpired,
+		},
+		{
+			"Wrong scope",
+			jsToken,
+			ApiScope,
+			now,
+			"",
+			ErrWrongScope,
+		},
+		{
+			"Not signed token",
+			"token_id",
+			JsScope,
+			now,
+			"",
+			ErrWrongSignedTokenFormat,
+		},
+		{
+			"Wrong format",
+			signedTokenPrefix + "abc",
+			JsScope,
+			now,
+			"",
+			ErrWrongSignedTokenFormat,
+		},
+		{
+			"Tampered signature",
+			jsToken + "a",
+			JsScope,
+			now,
+			"",
+			ErrWrongSignature,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := signer.VerifyScope(tt.signedToken, tt.scope, tt.now)
+			if tt.expectedErr != nil {
+				require.Equal(t, tt.expectedErr, err)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tt.expectedToken, actual)
+			}
+		})
+	}
+
+	_, err = NewSigner("another secret").VerifyScope(jsToken, JsScope, now)
+	require.Equal(t, ErrWrongSignature, err)
+
+	_, err = signer.Sign("token_id", "unknown", now)
+	require.EqualError(t, err, "Unknown scope: unknown. Supported: js, s2s")
+}
+
+func TestServiceSignedTokens(t *testing.T) {
+	service := &Service{
+		tokensHolder: reformat([]Token{{Id: "id1", ClientSecret: "client_secret1", ServerSecret: "server_secret1", Origins: []string{"*.domain.com"}}}),
+		signer:       NewSigner("secret"),
+	}
+
+	jsToken, _, err := service.SignToken("client_secret1", JsScope, time.Hour)
+	require.NoError(t, err)
+	apiToken, _, err := service.SignToken("server_secret1", ApiScope, time.Hour)
+	require.NoError(t, err)
+
+	//payload is readable by anyone: it mustn't contain secrets
+	for _, signed := range []string{jsToken, apiToken} {
+		payload, err := base64.RawURLEncoding.DecodeString(strings.Split(strings.TrimPrefix(signed, signedTokenPrefix), ".")[0])
+		require.NoError(t, err)
+		require.NotContains(t, string(payload), "client_secret1")
+		require.NotContains(t, string(payload), "server_secret1")
+		require.Contains(t, string(payload), `"i":"id1"`)
+	}
+
+	require.Equal(t, "client_secret1", service.ResolveToken(jsToken))
+	require.Equal(t, "server_secret1", service.ResolveToken(apiToken))
+
+	origins, ok := service.GetClientOrigins(jsToken)
+	require.True(t, ok)
+	require.Equal(t, []string{"*.domain.com"}, origins)
+	_, ok = service.GetServerOrigins(jsToken)
+	require.False(t, ok, "js signed token mustn't be accepted on s2s endpoints")
+	_, ok = service.GetServerOrigins(apiToken)
+	require.True(t, ok)
+
+	//signed token of removed token isn't resolved
+	service.tokensHolder = reformat([]Token{{Id: "id2", ClientSecret: "client_secret2"}})
+	require.Equal(t, jsToken, service.ResolveToken(jsToken))
+	_, ok = service.GetClientOrigins(jsToken)
+	require.False(t, ok)
+
+	_, _, err = service.SignToken("unknown", JsScope, time.Hour)
+	require.EqualError(t, err, "Token with scope [js] wasn't found")
+}
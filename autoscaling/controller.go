@@ -0,0 +1,123 @@
+package autoscaling
+
+import (
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/metrics"
+	"github.com/jitsucom/eventnative/safego"
+	"time"
+)
+
+const (
+	defaultMaxWorkers     = 8
+	defaultScaleUpBacklog = 10
+	defaultIntervalSec    = 30
+)
+
+//Config is a workers autoscaling configuration
+type Config struct {
+	Enabled    bool `mapstructure:"enabled"`
+	MinWorkers int  `mapstructure:"min_workers"`
+	MaxWorkers int  `mapstructure:"max_workers"`
+	//backlog items per worker. Workers are added if the backlog is greater
+	ScaleUpBacklog int `mapstructure:"scale_up_backlog"`
+	//workers are added if the latency is greater and the backlog isn't empty. 0 - latency isn't checked
+	TargetLatencySec int `mapstructure:"target_latency_sec"`
+	IntervalSec      int `mapstructure:"interval_sec"`
+}
+
+//Target is a workers pool with queue depth and latency signals
+type Target interface {
+	Workers() int
+	SetWorkers(workers int)
+	//Backlog return number of pending items (queue depth)
+	Backlog() int
+	//Latency return current processing latency (e.g. age of the oldest pending item)
+	Latency() time.Duration
+}
+
+//Controller periodically scales target workers within configured bounds:
+//up (right to the desired count) if the backlog is big or latency is high and down (one worker per interval) if the backlog is small
+type Controller struct {
+	name   string
+	config *Config
+	target Target
+
+	closed bool
+}
+
+//NewController return Controller with default values of unset config fields
+func NewController(name string, config Config, target Target) *Controller {
+	if config.MinWorkers <= 0 {
+		config.MinWorkers = 1
+	}
+	if config.MaxWorkers <= 0 {
+		config.MaxWorkers = defaultMaxWorkers
+	}
+	if config.MaxWorkers < config.MinWorkers {
+		config.MaxWorkers = config.MinWorkers
+	}
+	if config.ScaleUpBacklog <= 0 {
+		config.ScaleUpBacklog = defaultScaleUpBacklog
+	}
+	if config.IntervalSec <= 0 {
+		config.IntervalSec = defaultIntervalSec
+	}
+
+	return &Controller{name: name, config: &config, target: target}
+}
+
+//Start set min workers and run scaling goroutine
+func (c *Controller) Start() {
+	c.scale(c.config.MinWorkers)
+
+	safego.RunWithRestart(func() {
+		for {
+			if c.closed {
+				break
+			}
+
+			time.Sleep(time.Duration(c.config.IntervalSec) * time.Second)
+			if c.closed {
+				break
+			}
+
+			c.scale(Decide(c.config, c.target.Workers(), c.target.Backlog(), c.target.Latency()))
+		}
+	})
+}
+
+func (c *Controller) Close() error {
+	c.closed = true
+	return nil
+}
+
+func (c *Controller) scale(workers int) {
+	current := c.target.Workers()
+	if workers != current {
+		logging.Infof("[%s] Scaling workers: %d -> %d (backlog: %d, latency: %s)", c.name, current, workers, c.target.Backlog(), c.target.Latency())
+		c.target.SetWorkers(workers)
+	}
+	metrics.AutoscalingWorkers(c.name, workers)
+}
+
+//Decide return desired workers count:
+//ceil(backlog / scale_up_backlog) or at least one more worker if latency is greater than the target and the backlog isn't empty.
+//Scaling down is done by one worker for preventing flapping. The result is in [min_workers, max_workers]
+func Decide(config *Config, workers, backlog int, latency time.Duration) int {
+	desired := (backlog + config.ScaleUpBacklog - 1) / config.ScaleUpBacklog
+	if config.TargetLatencySec > 0 && backlog > 0 && latency > time.Duration(config.TargetLatencySec)*time.Second && desired <= workers {
+		desired = workers + 1
+	}
+
+	if desired < workers {
+		desired = workers - 1
+	}
+
+	if desired < config.MinWorkers {
+		desired = config.MinWorkers
+	}
+	if desired > config.MaxWorkers {
+		desired = config.MaxWorkers
+	}
+	return desired
+}
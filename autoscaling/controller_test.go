@@ -0,0 +1,32 @@
+package autoscaling
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestDecide(t *testing.T) {
+	config := &Config{MinWorkers: 1, MaxWorkers: 5, ScaleUpBacklog: 10, TargetLatencySec: 60}
+	tests := []struct {
+		name     string
+		workers  int
+		backlog  int
+		latency  time.Duration
+		expected int
+	}{
+		{"empty backlog min workers", 1, 0, 0, 1},
+		{"scale up by backlog", 1, 31, 0, 4},
+		{"scale up no more than max", 2, 100, 0, 5},
+		{"scale up by latency", 2, 5, 2 * time.Minute, 3},
+		{"latency without backlog", 2, 0, 2 * time.Minute, 1},
+		{"latency with enough workers by backlog", 1, 25, 2 * time.Minute, 3},
+		{"scale down by one", 5, 0, 0, 4},
+		{"keep workers", 3, 30, 10 * time.Second, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, Decide(config, tt.workers, tt.backlog, tt.latency))
+		})
+	}
+}
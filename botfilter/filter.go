@@ -0,0 +1,349 @@
+package botfilter
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/jitsucom/eventnative/jsonutils"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/metrics"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	TagAction  = "tag"
+	DropAction = "drop"
+
+	UserAgentRule = "user_agent"
+	IpRule        = "ip"
+	HeadlessRule  = "headless"
+	RateRule      = "rate"
+
+	defaultTagField         = "/eventn_ctx/bot"
+	defaultRateWindowSec    = 60
+	defaultUserAgentField   = "/eventn_ctx/user_agent"
+	defaultAnonymousIdField = "/eventn_ctx/user/anonymous_id"
+	screenResolutionField   = "/eventn_ctx/screen_resolution"
+)
+
+var (
+	//knownBots is a list of common crawlers, monitoring tools and HTTP libraries user agents
+	knownBots = []string{
+		`(?i)bot\b`, `(?i)crawl`, `(?i)spider`, `(?i)slurp`, `(?i)mediapartners`, `(?i)facebookexternalhit`,
+		`(?i)pingdom`, `(?i)uptimerobot`, `(?i)lighthouse`, `(?i)^curl/`, `(?i)^wget/`, `(?i)python-requests`,
+		`(?i)go-http-client`, `(?i)^java/`, `(?i)okhttp`, `(?i)scrapy`,
+	}
+	//headlessMarkers are user agent substrings of headless browsers and automation tools
+	headlessMarkers = []string{"headlesschrome", "phantomjs", "puppeteer", "playwright", "selenium", "webdriver", "electron/"}
+)
+
+//RuleConfig is a common rule configuration. Action overrides the filter default action
+type RuleConfig struct {
+	Action string `mapstructure:"action"`
+}
+
+//Config is a bot filter configuration. Every rule is disabled if it doesn't have any parameters
+type Config struct {
+	Enabled bool `mapstructure:"enabled"`
+	//default rules action: tag (put the rule name into tag_field) or drop
+	Action   string `mapstructure:"action"`
+	TagField string `mapstructure:"tag_field"`
+
+	UserAgent struct {
+		RuleConfig `mapstructure:",squash"`
+		KnownBots  bool     `mapstructure:"known_bots"`
+		Regexes    []string `mapstructure:"regexes"`
+	} `mapstructure:"user_agent"`
+	Ip struct {
+		RuleConfig `mapstructure:",squash"`
+		//IPs or CIDRs
+		Cidrs []string `mapstructure:"cidrs"`
+		//files with one IP or CIDR per line (# - comments)
+		Files []string `mapstructure:"files"`
+	} `mapstructure:"ip"`
+	Headless struct {
+		RuleConfig `mapstructure:",squash"`
+		Enabled    bool `mapstructure:"enabled"`
+	} `mapstructure:"headless"`
+	Rate struct {
+		RuleConfig `mapstructure:",squash"`
+		//max events per client (anonymous id or ip) in the window. 0 - disabled
+		MaxEvents int `mapstructure:"max_events"`
+		WindowSec int `mapstructure:"window_sec"`
+	} `mapstructure:"rate"`
+}
+
+type rule struct {
+	name   string
+	action string
+	match  func(r *request) bool
+}
+
+//request is an event with extracted client fields
+type request struct {
+	event     map[string]interface{}
+	ip        string
+	userAgent string
+}
+
+//Filter checks client events with configured rules in order: ip, user agent, headless, rate
+//the first matched rule action is applied
+type Filter struct {
+	rules            []*rule
+	tagField         *jsonutils.JsonPath
+	userAgentField   *jsonutils.JsonPath
+	anonymousIdField *jsonutils.JsonPath
+	rate             *rateCounter
+}
+
+//NewFilter return configured Filter or nil if it is disabled
+func NewFilter(config Config) (*Filter, error) {
+	if !config.Enabled {
+		return nil, nil
+	}
+
+	defaultAction, err := parseAction(config.Action, TagAction)
+	if err != nil {
+		return nil, err
+	}
+	tagField := config.TagField
+	if tagField == "" {
+		tagField = defaultTagField
+	}
+
+	f := &Filter{
+		tagField:         jsonutils.NewJsonPath(tagField),
+		userAgentField:   jsonutils.NewJsonPath(defaultUserAgentField),
+		anonymousIdField: jsonutils.NewJsonPath(defaultAnonymousIdField),
+	}
+
+	networks, err := parseNetworks(config.Ip.Cidrs, config.Ip.Files)
+	if err != nil {
+		return nil, err
+	}
+	if len(networks) > 0 {
+		if err := f.addRule(IpRule, config.Ip.Action, defaultAction, func(r *request) bool {
+			return containsIp(networks, r.ip)
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	var expressions []string
+	if config.UserAgent.KnownBots {
+		expressions = append(expressions, knownBots...)
+	}
+	expressions = append(expressions, config.UserAgent.Regexes...)
+	if len(expressions) > 0 {
+		var regexes []*regexp.Regexp
+		for _, expression := range expressions {
+			regex, err := regexp.Compile(expression)
+			if err != nil {
+				return nil, fmt.Errorf("Malformed bot filter user agent regex [%s]: %v", expression, err)
+			}
+			regexes = append(regexes, regex)
+		}
+		if err := f.addRule(UserAgentRule, config.UserAgent.Action, defaultAction, func(r *request) bool {
+			for _, regex := range regexes {
+				if regex.MatchString(r.userAgent) {
+					return true
+				}
+			}
+			return false
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	if config.Headless.Enabled {
+		if err := f.addRule(HeadlessRule, config.Headless.Action, defaultAction, isHeadless); err != nil {
+			return nil, err
+		}
+	}
+
+	if config.Rate.MaxEvents > 0 {
+		window := config.Rate.WindowSec
+		if window <= 0 {
+			window = defaultRateWindowSec
+		}
+		f.rate = newRateCounter(time.Duration(window) * time.Second)
+		maxEvents := config.Rate.MaxEvents
+		if err := f.addRule(RateRule, config.Rate.Action, defaultAction, func(r *request) bool {
+			return f.rate.increment(f.clientKey(r), time.Now()) > maxEvents
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	logging.Infof("[bot_filter] Initialized with %d rules", len(f.rules))
+	return f, nil
+}
+
+//Check apply the first matched rule: tag the event (put the rule name into tag field) or return true if the event must be dropped
+//ip is the client ip, userAgent is the request user agent (it is used if the event doesn't have eventn_ctx.user_agent)
+func (f *Filter) Check(event map[string]interface{}, ip, userAgent string) bool {
+	r := &request{event: event, ip: ip, userAgent: userAgent}
+	if value, ok := f.userAgentField.Get(event); ok {
+		if eventUserAgent, ok := value.(string); ok && eventUserAgent != "" {
+			r.userAgent = eventUserAgent
+		}
+	}
+
+	for _, rule := range f.rules {
+		if !rule.match(r) {
+			continue
+		}
+
+		metrics.BotFilterMatched(rule.name, rule.action)
+		if rule.action == DropAction {
+			return true
+		}
+		if !f.tagField.Set(event, rule.name) {
+			logging.Warnf("[bot_filter] Tag can't be set into %s: node isn't an object", f.tagField.String())
+		}
+		return false
+	}
+
+	return false
+}
+
+func (f *Filter) addRule(name, action, defaultAction string, match func(r *request) bool) error {
+	ruleAction, err := parseAction(action, defaultAction)
+	if err != nil {
+		return fmt.Errorf("Error creating bot filter [%s] rule: %v", name, err)
+	}
+	f.rules = append(f.rules, &rule{name: name, action: ruleAction, match: match})
+	return nil
+}
+
+func (f *Filter) clientKey(r *request) string {
+	if value, ok := f.anonymousIdField.Get(r.event); ok && value != nil {
+		if anonymousId := fmt.Sprint(value); anonymousId != "" {
+			return "anonymous_id:" + anonymousId
+		}
+	}
+	return "ip:" + r.ip
+}
+
+//isHeadless return true if user agent is empty or belongs to a headless browser/automation tool or screen resolution is 0x0
+func isHeadless(r *request) bool {
+	userAgent := strings.ToLower(r.userAgent)
+	if strings.TrimSpace(userAgent) == "" {
+		return true
+	}
+	for _, marker := range headlessMarkers {
+		if strings.Contains(userAgent, marker) {
+			return true
+		}
+	}
+
+	if value, ok := jsonutils.NewJsonPath(screenResolutionField).Get(r.event); ok {
+		if resolution, ok := value.(string); ok && (resolution == "0x0" || resolution == "0") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseAction(action, defaultAction string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(action)) {
+	case "":
+		return defaultAction, nil
+	case TagAction:
+		return TagAction, nil
+	case DropAction:
+		return DropAction, nil
+	default:
+		return "", fmt.Errorf("Unknown bot filter action [%s]. Available: %s, %s", action, TagAction, DropAction)
+	}
+}
+
+func parseNetworks(cidrs []string, files []string) ([]*net.IPNet, error) {
+	values := append([]string{}, cidrs...)
+	for _, filePath := range files {
+		file, err := os.Open(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("Error opening bot filter ip list [%s]: %v", filePath, err)
+		}
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			values = append(values, line)
+		}
+		err = scanner.Err()
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("Error reading bot filter ip list [%s]: %v", filePath, err)
+		}
+	}
+
+	var networks []*net.IPNet
+	for _, value := range values {
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, fmt.Errorf("Malformed bot filter ip [%s]: it must be an IP or CIDR", value)
+			}
+			if ip.To4() != nil {
+				value += "/32"
+			} else {
+				value += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("Malformed bot filter CIDR [%s]: %v", value, err)
+		}
+		networks = append(networks, network)
+	}
+
+	return networks, nil
+}
+
+func containsIp(networks []*net.IPNet, ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range networks {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+//rateCounter counts events per client in fixed windows. Counters of the previous windows are dropped
+type rateCounter struct {
+	sync.Mutex
+	window        time.Duration
+	currentWindow int64
+	counters      map[string]int
+}
+
+func newRateCounter(window time.Duration) *rateCounter {
+	return &rateCounter{window: window, counters: map[string]int{}}
+}
+
+//increment return events count of the client in the current window including this one
+func (rc *rateCounter) increment(key string, now time.Time) int {
+	rc.Lock()
+	defer rc.Unlock()
+
+	window := now.UnixNano() / int64(rc.window)
+	if window != rc.currentWindow {
+		rc.currentWindow = window
+		rc.counters = map[string]int{}
+	}
+
+	rc.counters[key]++
+	return rc.counters[key]
+}
@@ -0,0 +1,159 @@
+package botfilter
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+const browserUserAgent = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_6) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/84.0.4147.105 Safari/537.36"
+
+func TestCheck(t *testing.T) {
+	config := Config{Enabled: true}
+	config.UserAgent.KnownBots = true
+	config.UserAgent.Regexes = []string{"(?i)mycompany-monitoring"}
+	config.Ip.Cidrs = []string{"66.249.64.0/19", "8.8.8.8"}
+	config.Ip.Action = DropAction
+	config.Headless.Enabled = true
+
+	tests := []struct {
+		name         string
+		event        map[string]interface{}
+		ip           string
+		userAgent    string
+		expectedDrop bool
+		expectedTag  interface{}
+	}{
+		{
+			"browser",
+			map[string]interface{}{"eventn_ctx": map[string]interface{}{"user_agent": browserUserAgent, "screen_resolution": "1440x900"}},
+			"1.1.1.1",
+			"",
+			false,
+			nil,
+		},
+		{
+			"known bot from the request",
+			map[string]interface{}{},
+			"1.1.1.1",
+			"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			false,
+			UserAgentRule,
+		},
+		{
+			"event user agent has priority",
+			map[string]interface{}{"eventn_ctx": map[string]interface{}{"user_agent": "MyCompany-Monitoring/1.0"}},
+			"1.1.1.1",
+			browserUserAgent,
+			false,
+			UserAgentRule,
+		},
+		{
+			"ip list drop",
+			map[string]interface{}{"eventn_ctx": map[string]interface{}{"user_agent": browserUserAgent}},
+			"66.249.66.1",
+			"",
+			true,
+			nil,
+		},
+		{
+			"single ip",
+			map[string]interface{}{},
+			"8.8.8.8",
+			browserUserAgent,
+			true,
+			nil,
+		},
+		{
+			"headless chrome",
+			map[string]interface{}{"eventn_ctx": map[string]interface{}{"user_agent": "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) HeadlessChrome/84.0.4147.105 Safari/537.36"}},
+			"1.1.1.1",
+			"",
+			false,
+			HeadlessRule,
+		},
+		{
+			"empty user agent",
+			map[string]interface{}{},
+			"1.1.1.1",
+			"",
+			false,
+			HeadlessRule,
+		},
+		{
+			"zero screen resolution",
+			map[string]interface{}{"eventn_ctx": map[string]interface{}{"user_agent": browserUserAgent, "screen_resolution": "0x0"}},
+			"1.1.1.1",
+			"",
+			false,
+			HeadlessRule,
+		},
+	}
+
+	filter, err := NewFilter(config)
+	require.NoError(t, err)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expectedDrop, filter.Check(tt.event, tt.ip, tt.userAgent))
+
+			tag, _ := filter.tagField.Get(tt.event)
+			require.Equal(t, tt.expectedTag, tag)
+		})
+	}
+}
+
+func TestRate(t *testing.T) {
+	config := Config{Enabled: true, Action: DropAction}
+	config.Rate.MaxEvents = 2
+
+	filter, err := NewFilter(config)
+	require.NoError(t, err)
+
+	anonymous := func(id string) map[string]interface{} {
+		return map[string]interface{}{"eventn_ctx": map[string]interface{}{"user": map[string]interface{}{"anonymous_id": id}}}
+	}
+
+	require.False(t, filter.Check(anonymous("a1"), "1.1.1.1", browserUserAgent))
+	require.False(t, filter.Check(anonymous("a1"), "1.1.1.1", browserUserAgent))
+	require.True(t, filter.Check(anonymous("a1"), "1.1.1.1", browserUserAgent))
+	//another anonymous id from the same ip
+	require.False(t, filter.Check(anonymous("a2"), "1.1.1.1", browserUserAgent))
+
+	//without anonymous id ip is used
+	require.False(t, filter.Check(map[string]interface{}{}, "2.2.2.2", browserUserAgent))
+	require.False(t, filter.Check(map[string]interface{}{}, "2.2.2.2", browserUserAgent))
+	require.True(t, filter.Check(map[string]interface{}{}, "2.2.2.2", browserUserAgent))
+}
+
+func TestRateCounterWindow(t *testing.T) {
+	counter := newRateCounter(time.Minute)
+	now := time.Date(2020, 8, 2, 18, 0, 10, 0, time.UTC)
+
+	require.Equal(t, 1, counter.increment("key", now))
+	require.Equal(t, 2, counter.increment("key", now.Add(30*time.Second)))
+	require.Equal(t, 1, counter.increment("key", now.Add(time.Minute)))
+}
+
+func TestNewFilter(t *testing.T) {
+	filter, err := NewFilter(Config{})
+	require.NoError(t, err)
+	require.Nil(t, filter)
+
+	_, err = NewFilter(Config{Enabled: true, Action: "block"})
+	require.Error(t, err)
+
+	config := Config{Enabled: true}
+	config.UserAgent.Regexes = []string{"(unclosed"}
+	_, err = NewFilter(config)
+	require.Error(t, err)
+
+	config = Config{Enabled: true}
+	config.Ip.Cidrs = []string{"66.249.64.0/33"}
+	_, err = NewFilter(config)
+	require.Error(t, err)
+
+	config = Config{Enabled: true}
+	config.Ip.Files = []string{"not_existing_file.txt"}
+	_, err = NewFilter(config)
+	require.Error(t, err)
+}
@@ -0,0 +1,228 @@
+package breaker
+
+import (
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/metrics"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	//writes are attempted
+	StateClosed = "closed"
+	//writes aren't attempted: stream events are spooled, batch files are kept until the probe
+	StateOpen = "open"
+	//one probe write is being attempted
+	StateHalfOpen = "half_open"
+
+	defaultFailureThreshold = 5
+	defaultProbeIntervalSec = 60
+)
+
+//Instance is a singleton registry of destinations circuit breakers. It is disabled (circuits are never opened) until Init is called
+var Instance = NewRegistry(nil)
+
+type Config struct {
+	Enabled bool `mapstructure:"enabled"`
+	//consecutive failed writes (with retryable errors) count which opens the circuit
+	FailureThreshold int `mapstructure:"failure_threshold"`
+	//while the circuit is open a probe write is attempted every interval
+	ProbeIntervalSec int `mapstructure:"probe_interval_sec"`
+}
+
+//Status is the destination circuit breaker state
+type Status struct {
+	State     string    `json:"state"`
+	Failures  int       `json:"failures"`
+	OpenedAt  time.Time `json:"opened_at"`
+	NextProbe time.Time `json:"next_probe"`
+}
+
+//Registry keeps circuit breakers of destinations. A breaker is kept when the destination is recreated
+type Registry struct {
+	enabled          bool
+	failureThreshold int
+	probeInterval    time.Duration
+	now              func() time.Time
+
+	mutex    sync.Mutex
+	breakers map[string]*Breaker
+}
+
+//Breaker is a destination circuit breaker: after failureThreshold consecutive failed writes the circuit is opened
+//and writes aren't attempted. Every probeInterval one probe write is allowed (half-open): success closes the circuit,
+//failure opens it again
+type Breaker struct {
+	registry      *Registry
+	destinationId string
+
+	mutex     sync.Mutex
+	state     string
+	failures  int
+	openedAt  time.Time
+	nextProbe time.Time
+}
+
+//Init replace Instance with configured registry
+func Init(config *Config) {
+	Instance = NewRegistry(config)
+	if Instance.enabled {
+		logging.Infof("Destinations circuit breakers are enabled: failure threshold %d, probe interval %s", Instance.failureThreshold, Instance.probeInterval)
+	}
+}
+
+//NewRegistry return Registry with default values of not configured parameters. Nil or disabled config means that circuits are never opened
+func NewRegistry(config *Config) *Registry {
+	r := &Registry{
+		failureThreshold: defaultFailureThreshold,
+		probeInterval:    defaultProbeIntervalSec * time.Second,
+		now:              time.Now,
+		breakers:         map[string]*Breaker{},
+	}
+	if config == nil {
+		return r
+	}
+
+	r.enabled = config.Enabled
+	if config.FailureThreshold > 0 {
+		r.failureThreshold = config.FailureThreshold
+	}
+	if config.ProbeIntervalSec > 0 {
+		r.probeInterval = time.Duration(config.ProbeIntervalSec) * time.Second
+	}
+	return r
+}
+
+//Enabled return true if circuits can be opened
+func (r *Registry) Enabled() bool {
+	return r.enabled
+}
+
+//Get return the destination breaker (create it if doesn't exist)
+func (r *Registry) Get(destinationId string) *Breaker {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	b, ok := r.breakers[destinationId]
+	if !ok {
+		b = &Breaker{registry: r, destinationId: destinationId, state: StateClosed}
+		r.breakers[destinationId] = b
+	}
+	return b
+}
+
+//Statuses return statuses of all breakers by destination ids
+func (r *Registry) Statuses() map[string]Status {
+	r.mutex.Lock()
+	ids := make([]string, 0, len(r.breakers))
+	for id := range r.breakers {
+		ids = append(ids, id)
+	}
+	r.mutex.Unlock()
+	sort.Strings(ids)
+
+	statuses := make(map[string]Status, len(ids))
+	for _, id := range ids {
+		statuses[id] = r.Get(id).Status()
+	}
+	return statuses
+}
+
+//Allow return true if a write can be attempted: the circuit is closed or it is open and the probe time has come.
+//In the last case the circuit becomes half-open and other writes aren't allowed until the probe result.
+//If the probe result hasn't been reported during probeInterval one more probe is allowed
+func (b *Breaker) Allow() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := b.registry.now()
+	switch b.state {
+	case StateClosed:
+		return true
+	case StateOpen:
+		if now.Before(b.nextProbe) {
+			return false
+		}
+		b.state = StateHalfOpen
+		b.nextProbe = now.Add(b.registry.probeInterval)
+		logging.Infof("[%s] Circuit breaker is half-open: probe write is being attempted", b.destinationId)
+		return true
+	default:
+		if now.Before(b.nextProbe) {
+			return false
+		}
+		b.nextProbe = now.Add(b.registry.probeInterval)
+		logging.Warnf("[%s] Circuit breaker probe result hasn't been reported: one more probe write is being attempted", b.destinationId)
+		return true
+	}
+}
+
+//Closed return true if writes are attempted as usual
+func (b *Breaker) Closed() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.state == StateClosed
+}
+
+//Succeeded reset failures and close the circuit
+func (b *Breaker) Succeeded() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.failures = 0
+	if b.state != StateClosed {
+		logging.Infof("[%s] Circuit breaker has been closed: the destination has recovered after %s", b.destinationId, b.registry.now().Sub(b.openedAt).Round(time.Second))
+		b.state = StateClosed
+		b.openedAt = time.Time{}
+		b.nextProbe = time.Time{}
+		metrics.DestinationCircuit(b.destinationId, false)
+	}
+}
+
+//Skipped return half-open circuit into open state with immediate next probe: the probe write hasn't been attempted
+//(e.g. the event couldn't be processed or is empty). Doesn't affect closed and open circuits
+func (b *Breaker) Skipped() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.state == StateHalfOpen {
+		b.state = StateOpen
+		b.nextProbe = b.registry.now()
+	}
+}
+
+//Failed count the failed write (with retryable error) and open the circuit if the threshold is reached or the probe has failed
+func (b *Breaker) Failed() {
+	if !b.registry.enabled {
+		return
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.failures++
+	now := b.registry.now()
+	switch b.state {
+	case StateClosed:
+		if b.failures < b.registry.failureThreshold {
+			return
+		}
+		b.openedAt = now
+		logging.Warnf("[%s] Circuit breaker has been opened after %d consecutive failures. Probe writes will be attempted every %s", b.destinationId, b.failures, b.registry.probeInterval)
+		metrics.DestinationCircuit(b.destinationId, true)
+	case StateHalfOpen:
+		logging.Warnf("[%s] Circuit breaker probe write has failed", b.destinationId)
+	}
+	b.state = StateOpen
+	b.nextProbe = now.Add(b.registry.probeInterval)
+}
+
+//Status return the current breaker state
+func (b *Breaker) Status() Status {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return Status{State: b.state, Failures: b.failures, OpenedAt: b.openedAt, NextProbe: b.nextProbe}
+}
@@ -0,0 +1,92 @@
+package breaker
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	now := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+	registry := NewRegistry(&Config{Enabled: true, FailureThreshold: 3, ProbeIntervalSec: 60})
+	registry.now = func() time.Time { return now }
+
+	b := registry.Get("pg")
+	require.True(t, b.Allow())
+
+	//success resets consecutive failures
+	b.Failed()
+	b.Failed()
+	b.Succeeded()
+	b.Failed()
+	b.Failed()
+	require.True(t, b.Closed())
+
+	b.Failed()
+	require.Equal(t, Status{State: StateOpen, Failures: 3, OpenedAt: now, NextProbe: now.Add(time.Minute)}, b.Status())
+	require.False(t, b.Allow())
+
+	//only one probe is allowed
+	now = now.Add(time.Minute)
+	require.True(t, b.Allow())
+	require.Equal(t, StateHalfOpen, b.Status().State)
+	require.False(t, b.Allow())
+
+	//failed probe opens the circuit again
+	b.Failed()
+	require.Equal(t, StateOpen, b.Status().State)
+	require.False(t, b.Allow())
+
+	now = now.Add(time.Minute)
+	require.True(t, b.Allow())
+	b.Succeeded()
+	require.Equal(t, Status{State: StateClosed}, b.Status())
+	require.True(t, b.Allow())
+
+	require.Equal(t, map[string]Status{"pg": {State: StateClosed}}, registry.Statuses())
+}
+
+func TestBreakerUnresolvedProbe(t *testing.T) {
+	now := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+	registry := NewRegistry(&Config{Enabled: true, FailureThreshold: 1, ProbeIntervalSec: 60})
+	registry.now = func() time.Time { return now }
+
+	b := registry.Get("pg")
+	b.Failed()
+	now = now.Add(time.Minute)
+	require.True(t, b.Allow())
+
+	//probe event hasn't been written (unprocessable): the next event is the probe
+	b.Skipped()
+	require.Equal(t, Status{State: StateOpen, Failures: 1, OpenedAt: now.Add(-time.Minute), NextProbe: now}, b.Status())
+	require.True(t, b.Allow())
+	require.Equal(t, StateHalfOpen, b.Status().State)
+	require.False(t, b.Allow())
+
+	//probe result hasn't been reported: one more probe is allowed after the probe interval
+	now = now.Add(59 * time.Second)
+	require.False(t, b.Allow())
+	now = now.Add(time.Second)
+	require.True(t, b.Allow())
+	require.Equal(t, StateHalfOpen, b.Status().State)
+	require.False(t, b.Allow())
+
+	//closed and open circuits aren't affected
+	b.Failed()
+	b.Skipped()
+	require.Equal(t, StateOpen, b.Status().State)
+	require.False(t, b.Allow())
+	now = now.Add(time.Minute)
+	require.True(t, b.Allow())
+	b.Succeeded()
+	b.Skipped()
+	require.True(t, b.Closed())
+}
+
+func TestDisabledBreaker(t *testing.T) {
+	b := NewRegistry(&Config{FailureThreshold: 1}).Get("pg")
+	b.Failed()
+	b.Failed()
+	require.True(t, b.Closed())
+	require.True(t, b.Allow())
+}
@@ -2,7 +2,10 @@ package caching
 
 import (
 	"encoding/json"
+	"github.com/jitsucom/eventnative/acks"
 	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/eventstore"
+	"github.com/jitsucom/eventnative/introspection"
 	"github.com/jitsucom/eventnative/logging"
 	"github.com/jitsucom/eventnative/meta"
 	"github.com/jitsucom/eventnative/safego"
@@ -11,8 +14,12 @@ import (
 	"time"
 )
 
+//TokenKeyPrefix is a prefix of API tokens (raw events) keys. Destinations keys are destination ids
+const TokenKeyPrefix = "token#"
+
 type EventsCache struct {
-	storage                meta.Storage
+	storage                EventsStorage
+	redactor               *Redactor
 	originalCh             chan *originalFact
 	succeedCh              chan *succeedFact
 	failedCh               chan *failedFact
@@ -22,7 +29,7 @@ type EventsCache struct {
 }
 
 //return EventsCache and start goroutine for async operations
-func NewEventsCache(storage meta.Storage, capacityPerDestination int) *EventsCache {
+func NewEventsCache(storage EventsStorage, capacityPerDestination int) *EventsCache {
 	c := &EventsCache{
 		storage:                storage,
 		originalCh:             make(chan *originalFact, 1000000),
@@ -70,24 +77,54 @@ func (ec *EventsCache) start() {
 	})
 }
 
-//Put put value into channel which will be read and written to storage
+//SetRedactor set redaction of original and processed events before caching. Must be called before events caching
+func (ec *EventsCache) SetRedactor(redactor *Redactor) {
+	ec.redactor = redactor
+}
+
+//TokenKey return cache key of the API token raw events
+func TokenKey(tokenId string) string {
+	return TokenKeyPrefix + tokenId
+}
+
+//Put put value (a copy which isn't changed after the call) into channel which will be read and written to storage
+//and into the introspection recent events. Configured fields are redacted
 func (ec *EventsCache) Put(destinationId, eventId string, value events.Fact) {
+	ec.redactor.RedactOriginal(value)
+	introspection.Instance.Received(destinationId, eventId, value)
+	ec.putAsync(destinationId, eventId, value)
+}
+
+//PutToken put raw event (a copy which isn't changed after the call) of the API token. Configured fields are redacted
+func (ec *EventsCache) PutToken(tokenId, eventId string, value events.Fact) {
+	ec.redactor.RedactOriginal(value)
+	ec.putAsync(TokenKey(tokenId), eventId, value)
+}
+
+func (ec *EventsCache) putAsync(destinationId, eventId string, value events.Fact) {
 	select {
 	case ec.originalCh <- &originalFact{destinationId: destinationId, eventId: eventId, eventFact: value}:
 	default:
 	}
 }
 
-//Succeed put value into channel which will be read and updated in storage
+//Succeed put value into channel which will be read and updated in storage (configured fields are redacted).
+//The table throughput is counted in introspection, processed value is kept in the event store (if enabled),
+//the delivery is acknowledged to the source batch (if any)
 func (ec *EventsCache) Succeed(destinationId, eventId string, processed events.Fact, table *schema.Table, types map[typing.DataType]string) {
+	introspection.Instance.Succeeded(destinationId, eventId, table.Name)
+	acks.Instance.Delivered(destinationId, eventId)
+	eventstore.Instance.Put(destinationId, table.Name, processed)
 	select {
-	case ec.succeedCh <- &succeedFact{destinationId: destinationId, eventId: eventId, processed: processed, table: table, types: types}:
+	case ec.succeedCh <- &succeedFact{destinationId: destinationId, eventId: eventId, processed: ec.redactor.RedactProcessed(processed), table: table, types: types}:
 	default:
 	}
 }
 
-//Error put value into channel which will be read and updated in storage
+//Error put value into channel which will be read and updated in storage and into the introspection failed events
 func (ec *EventsCache) Error(destinationId, eventId string, errMsg string) {
+	introspection.Instance.Failed(destinationId, eventId, errMsg)
+	acks.Instance.Failed(destinationId, eventId, errMsg)
 	select {
 	case ec.failedCh <- &failedFact{destinationId: destinationId, eventId: eventId, error: errMsg}:
 	default:
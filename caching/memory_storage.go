@@ -0,0 +1,150 @@
+package caching
+
+import (
+	"github.com/jitsucom/eventnative/meta"
+	"sync"
+	"time"
+)
+
+const (
+	RedisStorage  = "redis"
+	MemoryStorage = "memory"
+)
+
+//EventsStorage is a storage of the last events (meta.Storage or MemoryEventsStorage)
+type EventsStorage interface {
+	AddEvent(destinationId, eventId, payload string, now time.Time) (int, error)
+	UpdateSucceedEvent(destinationId, eventId, success string) error
+	UpdateErrorEvent(destinationId, eventId, error string) error
+	RemoveLastEvent(destinationId string) error
+
+	GetEvents(destinationId string, start, end time.Time, n int) ([]meta.Event, error)
+	GetTotalEvents(destinationId string) (int, error)
+}
+
+type memoryEvent struct {
+	id    string
+	unix  int64
+	event meta.Event
+}
+
+//memoryEvents is ordered by added time (the same as Redis sorted set index)
+type memoryEvents struct {
+	order []*memoryEvent
+	byId  map[string]*memoryEvent
+}
+
+//MemoryEventsStorage keeps the last events in memory (per server). It is used if Redis meta storage isn't configured
+//or server.cache.events.storage is memory
+type MemoryEventsStorage struct {
+	sync.RWMutex
+	keys map[string]*memoryEvents
+}
+
+func NewMemoryEventsStorage() *MemoryEventsStorage {
+	return &MemoryEventsStorage{keys: map[string]*memoryEvents{}}
+}
+
+//AddEvent put the original event and return count of the key events. Existing event is overwritten and becomes the newest
+func (mes *MemoryEventsStorage) AddEvent(key, eventId, payload string, now time.Time) (int, error) {
+	mes.Lock()
+	defer mes.Unlock()
+
+	events, ok := mes.keys[key]
+	if !ok {
+		events = &memoryEvents{byId: map[string]*memoryEvent{}}
+		mes.keys[key] = events
+	}
+	if existing, ok := events.byId[eventId]; ok {
+		events.remove(existing)
+	}
+
+	event := &memoryEvent{id: eventId, unix: now.Unix(), event: meta.Event{Original: payload}}
+	events.order = append(events.order, event)
+	events.byId[eventId] = event
+	return len(events.order), nil
+}
+
+//UpdateSucceedEvent set success and clear error of the existing event
+func (mes *MemoryEventsStorage) UpdateSucceedEvent(key, eventId, success string) error {
+	mes.Lock()
+	defer mes.Unlock()
+
+	if event, ok := mes.event(key, eventId); ok {
+		event.event.Success = success
+		event.event.Error = ""
+	}
+	return nil
+}
+
+//UpdateErrorEvent set error of the existing event
+func (mes *MemoryEventsStorage) UpdateErrorEvent(key, eventId, errMsg string) error {
+	mes.Lock()
+	defer mes.Unlock()
+
+	if event, ok := mes.event(key, eventId); ok {
+		event.event.Error = errMsg
+	}
+	return nil
+}
+
+//RemoveLastEvent remove the oldest event of the key
+func (mes *MemoryEventsStorage) RemoveLastEvent(key string) error {
+	mes.Lock()
+	defer mes.Unlock()
+
+	if events, ok := mes.keys[key]; ok && len(events.order) > 0 {
+		events.remove(events.order[0])
+	}
+	return nil
+}
+
+//GetEvents return at most n events which have been added in [start, end] seconds from the oldest
+func (mes *MemoryEventsStorage) GetEvents(key string, start, end time.Time, n int) ([]meta.Event, error) {
+	mes.RLock()
+	defer mes.RUnlock()
+
+	result := []meta.Event{}
+	events, ok := mes.keys[key]
+	if !ok {
+		return result, nil
+	}
+	for _, event := range events.order {
+		if len(result) >= n {
+			break
+		}
+		if event.unix >= start.Unix() && event.unix <= end.Unix() {
+			result = append(result, event.event)
+		}
+	}
+	return result, nil
+}
+
+func (mes *MemoryEventsStorage) GetTotalEvents(key string) (int, error) {
+	mes.RLock()
+	defer mes.RUnlock()
+
+	if events, ok := mes.keys[key]; ok {
+		return len(events.order), nil
+	}
+	return 0, nil
+}
+
+func (mes *MemoryEventsStorage) event(key, eventId string) (*memoryEvent, bool) {
+	events, ok := mes.keys[key]
+	if !ok {
+		return nil, false
+	}
+	event, ok := events.byId[eventId]
+	return event, ok
+}
+
+func (me *memoryEvents) remove(event *memoryEvent) {
+	delete(me.byId, event.id)
+	for i, e := range me.order {
+		if e == event {
+			me.order = append(me.order[:i], me.order[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package caching
+
+import (
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/jsonutils"
+	"strings"
+)
+
+//RedactedValue replaces values of redacted fields in cached events
+const RedactedValue = "[redacted]"
+
+//Redactor replaces configured fields values of cached events (e.g. emails or phones) so integrators can see the cache
+//without access to personal data. Nil Redactor doesn't change events
+type Redactor struct {
+	paths []*jsonutils.JsonPath
+	//flattened names of the paths (user/email -> user_email): processed events are flat
+	flattened []string
+}
+
+//NewRedactor return Redactor of dot separated paths (user.email) or json paths (/user/email). Nil if fields are empty
+func NewRedactor(fields []string) *Redactor {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	redactor := &Redactor{}
+	for _, field := range fields {
+		if !strings.HasPrefix(field, "/") {
+			field = strings.ReplaceAll(field, ".", "/")
+		}
+		path := jsonutils.NewJsonPath(field)
+		if path.IsEmpty() {
+			continue
+		}
+		redactor.paths = append(redactor.paths, path)
+		redactor.flattened = append(redactor.flattened, strings.ToLower(strings.Join(path.Parts(), "_")))
+	}
+	return redactor
+}
+
+//RedactOriginal replace existing fields values of the original (not flattened) event in place.
+//Nested objects are replaced as a whole
+func (r *Redactor) RedactOriginal(event events.Fact) {
+	if r == nil {
+		return
+	}
+
+	for _, path := range r.paths {
+		if _, ok := path.Get(event); ok {
+			path.Set(event, RedactedValue)
+		}
+	}
+}
+
+//RedactProcessed return copy of the processed (flattened) event with redacted columns of the fields and their nested fields
+//or the same event if it doesn't contain any of them
+func (r *Redactor) RedactProcessed(event events.Fact) events.Fact {
+	if r == nil {
+		return event
+	}
+
+	var redacted events.Fact
+	for column := range event {
+		if !r.matchesColumn(column) {
+			continue
+		}
+		if redacted == nil {
+			redacted = make(events.Fact, len(event))
+			for key, value := range event {
+				redacted[key] = value
+			}
+		}
+		redacted[column] = RedactedValue
+	}
+
+	if redacted == nil {
+		return event
+	}
+	return redacted
+}
+
+func (r *Redactor) matchesColumn(column string) bool {
+	for _, flattened := range r.flattened {
+		if column == flattened || strings.HasPrefix(column, flattened+"_") {
+			return true
+		}
+	}
+	return false
+}
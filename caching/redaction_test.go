@@ -0,0 +1,56 @@
+package caching
+
+import (
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/meta"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestRedactor(t *testing.T) {
+	redactor := NewRedactor([]string{"user.email", "/address"})
+
+	original := events.Fact{"user": map[string]interface{}{"email": "a@b.com", "id": "1"}, "address": map[string]interface{}{"city": "Berlin"}, "event_type": "pageview"}
+	redactor.RedactOriginal(original)
+	require.Equal(t, events.Fact{"user": map[string]interface{}{"email": RedactedValue, "id": "1"}, "address": RedactedValue, "event_type": "pageview"}, original)
+
+	processed := events.Fact{"user_email": "a@b.com", "user_id": "1", "address_city": "Berlin", "event_type": "pageview"}
+	redacted := redactor.RedactProcessed(processed)
+	require.Equal(t, events.Fact{"user_email": RedactedValue, "user_id": "1", "address_city": RedactedValue, "event_type": "pageview"}, redacted)
+	//processed event is stored as is
+	require.Equal(t, "a@b.com", processed["user_email"])
+
+	notMatched := events.Fact{"event_type": "pageview"}
+	require.Equal(t, notMatched, redactor.RedactProcessed(notMatched))
+
+	var disabled *Redactor
+	require.Nil(t, NewRedactor(nil))
+	require.Equal(t, processed, disabled.RedactProcessed(processed))
+}
+
+func TestMemoryEventsStorage(t *testing.T) {
+	storage := NewMemoryEventsStorage()
+	start := time.Date(2020, 11, 10, 12, 0, 0, 0, time.UTC)
+
+	for i, eventId := range []string{"1", "2", "3"} {
+		count, err := storage.AddEvent("pg", eventId, `{"id":"`+eventId+`"}`, start.Add(time.Duration(i)*time.Second))
+		require.NoError(t, err)
+		require.Equal(t, i+1, count)
+	}
+	require.NoError(t, storage.UpdateErrorEvent("pg", "2", "timeout"))
+	require.NoError(t, storage.UpdateSucceedEvent("pg", "3", `{"id":"3"}`))
+	require.NoError(t, storage.RemoveLastEvent("pg"))
+
+	total, err := storage.GetTotalEvents("pg")
+	require.NoError(t, err)
+	require.Equal(t, 2, total)
+
+	cached, err := storage.GetEvents("pg", start, start.Add(time.Minute), 10)
+	require.NoError(t, err)
+	require.Equal(t, []meta.Event{{Original: `{"id":"2"}`, Error: "timeout"}, {Original: `{"id":"3"}`, Success: `{"id":"3"}`}}, cached)
+
+	cached, err = storage.GetEvents("pg", start.Add(2*time.Second), start.Add(time.Minute), 10)
+	require.NoError(t, err)
+	require.Len(t, cached, 1)
+}
@@ -0,0 +1,188 @@
+package clientip
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+const (
+	realIpHeader       = "X-Real-IP"
+	forwardedForHeader = "X-Forwarded-For"
+	cloudflareHeader   = "CF-Connecting-IP"
+)
+
+var instance = &Resolver{}
+
+//Config is a client ip resolution policy configuration
+type Config struct {
+	//IPs or CIDRs of reverse proxies/load balancers. X-Forwarded-For entries of them are skipped.
+	//Forwarding headers of requests from not trusted addresses are ignored
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
+	//count of reverse proxies in front of EventNative. Client ip is the X-Forwarded-For entry before them
+	ForwardedForDepth int `mapstructure:"forwarded_for_depth"`
+	//use CF-Connecting-IP header (EventNative is behind Cloudflare)
+	Cloudflare bool `mapstructure:"cloudflare"`
+}
+
+//Resolver extracts client ip from request.
+//Without trusted proxies and X-Forwarded-For depth it uses the first of X-Real-IP, X-Forwarded-For (the leftmost entry)
+//and request remote address. Otherwise forwarding headers are used only if they are set by trusted proxies
+type Resolver struct {
+	trustedProxies []*net.IPNet
+	depth          int
+	cloudflare     bool
+}
+
+//Init create global Resolver which is used by Extract
+func Init(config Config) error {
+	resolver, err := NewResolver(config)
+	if err != nil {
+		return err
+	}
+
+	instance = resolver
+	return nil
+}
+
+//Extract return client ip of the request with global Resolver
+func Extract(r *http.Request) string {
+	return instance.Resolve(r)
+}
+
+//NewResolver return Resolver or error if trusted proxies are malformed
+func NewResolver(config Config) (*Resolver, error) {
+	if config.ForwardedForDepth < 0 {
+		return nil, fmt.Errorf("Malformed forwarded_for_depth [%d]: it can't be negative", config.ForwardedForDepth)
+	}
+
+	var trustedProxies []*net.IPNet
+	for _, proxy := range config.TrustedProxies {
+		proxy = strings.TrimSpace(proxy)
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, fmt.Errorf("Malformed trusted proxy [%s]: it must be an IP or CIDR", proxy)
+			}
+			bits := 32
+			if ip.To4() == nil {
+				bits = 128
+			}
+			proxy = fmt.Sprintf("%s/%d", proxy, bits)
+		}
+
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("Malformed trusted proxy [%s]: %v", proxy, err)
+		}
+		trustedProxies = append(trustedProxies, network)
+	}
+
+	return &Resolver{trustedProxies: trustedProxies, depth: config.ForwardedForDepth, cloudflare: config.Cloudflare}, nil
+}
+
+//Resolve return client ip or empty string
+func (r *Resolver) Resolve(req *http.Request) string {
+	remoteIp := normalize(req.RemoteAddr)
+
+	if len(r.trustedProxies) == 0 && r.depth == 0 {
+		return r.resolveLegacy(req, remoteIp)
+	}
+
+	//headers might be spoofed by the client
+	if len(r.trustedProxies) > 0 && !r.isTrusted(remoteIp) {
+		return remoteIp
+	}
+
+	if r.cloudflare {
+		if ip := normalize(req.Header.Get(cloudflareHeader)); ip != "" {
+			return ip
+		}
+	}
+
+	chain := forwardedFor(req)
+	if len(chain) == 0 {
+		if ip := normalize(req.Header.Get(realIpHeader)); ip != "" {
+			return ip
+		}
+		return remoteIp
+	}
+	if remoteIp != "" {
+		chain = append(chain, remoteIp)
+	}
+
+	if r.depth > 0 {
+		index := len(chain) - 1 - r.depth
+		if index < 0 {
+			index = 0
+		}
+		return chain[index]
+	}
+
+	//the rightmost not trusted address
+	for i := len(chain) - 1; i >= 0; i-- {
+		if !r.isTrusted(chain[i]) {
+			return chain[i]
+		}
+	}
+	return chain[0]
+}
+
+func (r *Resolver) resolveLegacy(req *http.Request, remoteIp string) string {
+	if r.cloudflare {
+		if ip := normalize(req.Header.Get(cloudflareHeader)); ip != "" {
+			return ip
+		}
+	}
+	if ip := normalize(req.Header.Get(realIpHeader)); ip != "" {
+		return ip
+	}
+	if chain := forwardedFor(req); len(chain) > 0 {
+		return chain[0]
+	}
+	return remoteIp
+}
+
+func (r *Resolver) isTrusted(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range r.trustedProxies {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+//forwardedFor return valid X-Forwarded-For entries of all header values (left to right)
+func forwardedFor(req *http.Request) []string {
+	var chain []string
+	for _, value := range req.Header.Values(forwardedForHeader) {
+		for _, entry := range strings.Split(value, ",") {
+			if ip := normalize(entry); ip != "" {
+				chain = append(chain, ip)
+			}
+		}
+	}
+	return chain
+}
+
+//normalize return ip without port and brackets or empty string if the value isn't an ip
+func normalize(value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return ""
+	}
+	if ip := net.ParseIP(value); ip != nil {
+		return ip.String()
+	}
+	if host, _, err := net.SplitHostPort(value); err == nil {
+		if ip := net.ParseIP(host); ip != nil {
+			return ip.String()
+		}
+	}
+	return ""
+}
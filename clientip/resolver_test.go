@@ -0,0 +1,125 @@
+package clientip
+
+import (
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     Config
+		remoteAddr string
+		headers    map[string][]string
+		expected   string
+	}{
+		{
+			"legacy remote address",
+			Config{},
+			"1.1.1.1:1234",
+			nil,
+			"1.1.1.1",
+		},
+		{
+			"legacy ipv6 remote address",
+			Config{},
+			"[2001:db8::1]:1234",
+			nil,
+			"2001:db8::1",
+		},
+		{
+			"legacy X-Real-IP",
+			Config{},
+			"10.0.0.1:1234",
+			map[string][]string{"X-Real-Ip": {"2.2.2.2"}, "X-Forwarded-For": {"3.3.3.3"}},
+			"2.2.2.2",
+		},
+		{
+			"legacy the leftmost X-Forwarded-For",
+			Config{},
+			"10.0.0.1:1234",
+			map[string][]string{"X-Forwarded-For": {"3.3.3.3, 10.0.0.2"}},
+			"3.3.3.3",
+		},
+		{
+			"legacy cloudflare",
+			Config{Cloudflare: true},
+			"10.0.0.1:1234",
+			map[string][]string{"Cf-Connecting-Ip": {"4.4.4.4"}, "X-Forwarded-For": {"3.3.3.3"}},
+			"4.4.4.4",
+		},
+		{
+			"not trusted remote address",
+			Config{TrustedProxies: []string{"10.0.0.0/8"}},
+			"5.5.5.5:1234",
+			map[string][]string{"X-Forwarded-For": {"3.3.3.3"}, "X-Real-Ip": {"2.2.2.2"}},
+			"5.5.5.5",
+		},
+		{
+			"the rightmost not trusted X-Forwarded-For",
+			Config{TrustedProxies: []string{"10.0.0.0/8", "172.16.0.1"}},
+			"10.0.0.1:1234",
+			map[string][]string{"X-Forwarded-For": {"6.6.6.6, 3.3.3.3", "172.16.0.1"}},
+			"3.3.3.3",
+		},
+		{
+			"all X-Forwarded-For are trusted",
+			Config{TrustedProxies: []string{"10.0.0.0/8"}},
+			"10.0.0.1:1234",
+			map[string][]string{"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"}},
+			"10.0.0.3",
+		},
+		{
+			"trusted X-Real-IP without X-Forwarded-For",
+			Config{TrustedProxies: []string{"10.0.0.0/8"}},
+			"10.0.0.1:1234",
+			map[string][]string{"X-Real-Ip": {"2.2.2.2"}},
+			"2.2.2.2",
+		},
+		{
+			"trusted cloudflare",
+			Config{TrustedProxies: []string{"10.0.0.0/8"}, Cloudflare: true},
+			"10.0.0.1:1234",
+			map[string][]string{"Cf-Connecting-Ip": {"4.4.4.4"}, "X-Forwarded-For": {"3.3.3.3"}},
+			"4.4.4.4",
+		},
+		{
+			"X-Forwarded-For depth",
+			Config{ForwardedForDepth: 2},
+			"10.0.0.1:1234",
+			map[string][]string{"X-Forwarded-For": {"6.6.6.6, 3.3.3.3, 10.0.0.2"}},
+			"3.3.3.3",
+		},
+		{
+			"X-Forwarded-For depth exceeds chain",
+			Config{ForwardedForDepth: 5},
+			"10.0.0.1:1234",
+			map[string][]string{"X-Forwarded-For": {"3.3.3.3:4567, malformed"}},
+			"3.3.3.3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver, err := NewResolver(tt.config)
+			require.NoError(t, err)
+
+			req := &http.Request{RemoteAddr: tt.remoteAddr, Header: http.Header(tt.headers)}
+			if req.Header == nil {
+				req.Header = http.Header{}
+			}
+			require.Equal(t, tt.expected, resolver.Resolve(req))
+		})
+	}
+}
+
+func TestNewResolverErrors(t *testing.T) {
+	_, err := NewResolver(Config{TrustedProxies: []string{"not_ip"}})
+	require.Error(t, err)
+
+	_, err = NewResolver(Config{TrustedProxies: []string{"10.0.0.0/33"}})
+	require.Error(t, err)
+
+	_, err = NewResolver(Config{ForwardedForDepth: -1})
+	require.Error(t, err)
+}
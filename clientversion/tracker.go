@@ -0,0 +1,177 @@
+package clientversion
+
+import (
+	"fmt"
+	"github.com/jitsucom/eventnative/jsonutils"
+	"github.com/spf13/viper"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	unknownVersion = "unknown"
+	otherVersions  = "other"
+
+	//max distinct versions per token
+	maxVersionsPerToken = 100
+)
+
+//Bucket is events statistics of one client version
+type Bucket struct {
+	Version    string    `json:"version"`
+	Events     int64     `json:"events"`
+	FirstSeen  time.Time `json:"first_seen"`
+	LastSeen   time.Time `json:"last_seen"`
+	Deprecated bool      `json:"deprecated"`
+}
+
+//Deprecation is returned to clients with version lower than configured min version
+type Deprecation struct {
+	Version    string
+	MinVersion string
+	Sunset     string
+}
+
+func (d *Deprecation) String() string {
+	return fmt.Sprintf("Client version %s is deprecated. Minimum supported version: %s", d.Version, d.MinVersion)
+}
+
+//Tracker buckets incoming events by client (SDK/app) version per token id
+//and detects deprecated client versions
+//statistics are kept in memory of the current instance
+type Tracker struct {
+	sync.RWMutex
+
+	header              string
+	fields              []*jsonutils.JsonPath
+	minVersion          string
+	minVersionByTokenId map[string]string
+	sunset              string
+
+	//token id -> version -> bucket
+	buckets map[string]map[string]*Bucket
+}
+
+//NewTracker return Tracker configured from server.client_versions viper section
+func NewTracker() *Tracker {
+	var fields []*jsonutils.JsonPath
+	for _, field := range viper.GetStringSlice("server.client_versions.fields") {
+		fields = append(fields, jsonutils.NewJsonPath(field))
+	}
+
+	return &Tracker{
+		header:              viper.GetString("server.client_versions.header"),
+		fields:              fields,
+		minVersion:          viper.GetString("server.client_versions.min_version"),
+		minVersionByTokenId: viper.GetStringMapString("server.client_versions.tokens"),
+		sunset:              viper.GetString("server.client_versions.sunset"),
+		buckets:             map[string]map[string]*Bucket{},
+	}
+}
+
+//Extract return client version from request header or from the first existing event field
+func (t *Tracker) Extract(r *http.Request, event map[string]interface{}) string {
+	if t.header != "" && r != nil {
+		if version := strings.TrimSpace(r.Header.Get(t.header)); version != "" {
+			return version
+		}
+	}
+
+	for _, field := range t.fields {
+		if value, ok := field.Get(event); ok && value != nil {
+			if version := strings.TrimSpace(fmt.Sprint(value)); version != "" {
+				return version
+			}
+		}
+	}
+
+	return ""
+}
+
+//Track increment version bucket of token id
+//return Deprecation if version is lower than min version of the token
+func (t *Tracker) Track(tokenId, version string) *Deprecation {
+	if version == "" {
+		version = unknownVersion
+	}
+
+	now := time.Now().UTC()
+	deprecation := t.checkDeprecation(tokenId, version)
+
+	t.Lock()
+	defer t.Unlock()
+
+	tokenBuckets, ok := t.buckets[tokenId]
+	if !ok {
+		tokenBuckets = map[string]*Bucket{}
+		t.buckets[tokenId] = tokenBuckets
+	}
+
+	bucket, ok := tokenBuckets[version]
+	if !ok {
+		//protect from unlimited growth with random versions
+		if len(tokenBuckets) >= maxVersionsPerToken {
+			version = otherVersions
+			bucket, ok = tokenBuckets[version]
+		}
+		if !ok {
+			bucket = &Bucket{Version: version, FirstSeen: now}
+			tokenBuckets[version] = bucket
+		}
+	}
+
+	bucket.Events++
+	bucket.LastSeen = now
+	bucket.Deprecated = deprecation != nil
+
+	return deprecation
+}
+
+//GetBuckets return version buckets sorted by version per token id
+//return all tokens if tokenIds is empty
+func (t *Tracker) GetBuckets(tokenIds map[string]bool) map[string][]Bucket {
+	t.RLock()
+	defer t.RUnlock()
+
+	result := map[string][]Bucket{}
+	for tokenId, tokenBuckets := range t.buckets {
+		if len(tokenIds) > 0 && !tokenIds[tokenId] {
+			continue
+		}
+
+		buckets := make([]Bucket, 0, len(tokenBuckets))
+		for _, bucket := range tokenBuckets {
+			buckets = append(buckets, *bucket)
+		}
+		sort.Slice(buckets, func(i, j int) bool {
+			return Compare(buckets[i].Version, buckets[j].Version) < 0
+		})
+		result[tokenId] = buckets
+	}
+
+	return result
+}
+
+//MinVersion return min supported version of token id or global one
+func (t *Tracker) MinVersion(tokenId string) string {
+	if minVersion, ok := t.minVersionByTokenId[strings.ToLower(tokenId)]; ok {
+		return minVersion
+	}
+	return t.minVersion
+}
+
+func (t *Tracker) checkDeprecation(tokenId, version string) *Deprecation {
+	minVersion := t.MinVersion(tokenId)
+	if minVersion == "" || version == unknownVersion {
+		return nil
+	}
+
+	if Compare(version, minVersion) < 0 {
+		return &Deprecation{Version: version, MinVersion: minVersion, Sunset: t.sunset}
+	}
+
+	return nil
+}
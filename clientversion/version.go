@@ -0,0 +1,66 @@
+package clientversion
+
+import (
+	"strconv"
+	"strings"
+)
+
+//Compare compare dot separated versions part by part: numeric parts as numbers, others as strings
+//pre-release and build suffixes (after '-' or '+') are ignored, 'v' prefix is trimmed
+//return -1 if a < b, 0 if a == b, 1 if a > b
+func Compare(a, b string) int {
+	aParts := splitVersion(a)
+	bParts := splitVersion(b)
+
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		var aPart, bPart string
+		if i < len(aParts) {
+			aPart = aParts[i]
+		}
+		if i < len(bParts) {
+			bPart = bParts[i]
+		}
+
+		if result := comparePart(aPart, bPart); result != 0 {
+			return result
+		}
+	}
+
+	return 0
+}
+
+func splitVersion(version string) []string {
+	version = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(version)), "v")
+	if i := strings.IndexAny(version, "-+"); i >= 0 {
+		version = version[:i]
+	}
+	if version == "" {
+		return nil
+	}
+	return strings.Split(version, ".")
+}
+
+//comparePart compare version parts, missing part equals 0
+func comparePart(a, b string) int {
+	if a == "" {
+		a = "0"
+	}
+	if b == "" {
+		b = "0"
+	}
+
+	aNumber, aErr := strconv.ParseInt(a, 10, 64)
+	bNumber, bErr := strconv.ParseInt(b, 10, 64)
+	if aErr == nil && bErr == nil {
+		switch {
+		case aNumber < bNumber:
+			return -1
+		case aNumber > bNumber:
+			return 1
+		default:
+			return 0
+		}
+	}
+
+	return strings.Compare(a, b)
+}
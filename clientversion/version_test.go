@@ -0,0 +1,30 @@
+package clientversion
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		name     string
+		a        string
+		b        string
+		expected int
+	}{
+		{"Equal", "1.2.3", "1.2.3", 0},
+		{"Missing parts equal zero", "1.2", "1.2.0", 0},
+		{"Numeric comparison", "1.10.0", "1.9.0", 1},
+		{"Lower major", "1.99", "2.0", -1},
+		{"V prefix", "v2.1.0", "2.1.0", 0},
+		{"Pre-release suffix ignored", "2.1.0-beta.1", "2.1.0", 0},
+		{"Build suffix ignored", "2.1.1+build5", "2.1.0", 1},
+		{"Not numeric parts", "1.0.b", "1.0.a", 1},
+		{"Empty version", "", "0.1", -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, Compare(tt.a, tt.b))
+		})
+	}
+}
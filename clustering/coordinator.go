@@ -0,0 +1,352 @@
+package clustering
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/counters"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/health"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/retry"
+	"github.com/jitsucom/eventnative/safego"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBatchSize      = 1000
+	defaultBatchPeriodSec = 10
+	defaultLeaseSec       = 30
+)
+
+var Instance *Coordinator
+
+type RedisConfig struct {
+	Host     string `mapstructure:"host"`
+	Port     int    `mapstructure:"port"`
+	Password string `mapstructure:"password"`
+}
+
+type Config struct {
+	Enabled bool `mapstructure:"enabled"`
+	//shared queue type. Available: redis (streams)
+	Type  string       `mapstructure:"type"`
+	Redis *RedisConfig `mapstructure:"redis"`
+	//max events count in one batch
+	BatchSize int `mapstructure:"batch_size"`
+	//batch is stored when it is full or after the period
+	BatchPeriodSec int `mapstructure:"batch_period_sec"`
+	//partition ownership lease. Partitions of failed nodes are taken over after the lease expiration
+	LeaseSec int `mapstructure:"lease_sec"`
+}
+
+//Coordinator is a clustering mode coordinator: batch mode destinations events are published into the shared queue
+//by every node (one partition per destination) and every partition is consumed by only one node (partition owner).
+//So batch files for a given table aren't written concurrently by two nodes and uploads don't conflict.
+//Events are acknowledged after they have been stored; not stored events are redelivered to the next owner
+type Coordinator struct {
+	serverName  string
+	queue       Queue
+	batchSize   int
+	batchPeriod time.Duration
+	lease       time.Duration
+
+	mutex      sync.RWMutex
+	partitions map[string]*partition
+	closed     bool
+}
+
+type partition struct {
+	destinationId string
+	storage       events.StorageProxy
+	owned         bool
+	done          chan struct{}
+	stopped       chan struct{}
+}
+
+//Publisher is a batch mode destination consumer which publishes events into the destination partition
+type Publisher struct {
+	destinationId string
+	queue         Queue
+}
+
+//Init initialize Instance if clustering is enabled
+func Init(serverName string, config *Config) error {
+	if config == nil || !config.Enabled {
+		return nil
+	}
+
+	if config.Type != "" && config.Type != RedisQueueType {
+		return fmt.Errorf("Unknown clustering type: %s. Available: [%s]", config.Type, RedisQueueType)
+	}
+	if config.Redis == nil || config.Redis.Host == "" {
+		return errors.New("Clustering redis.host is required parameter")
+	}
+	port := config.Redis.Port
+	if port == 0 {
+		port = 6379
+	}
+	queue, err := NewRedisQueue(config.Redis.Host, port, config.Redis.Password)
+	if err != nil {
+		return err
+	}
+
+	Instance = NewCoordinator(serverName, queue, config)
+	logging.Infof("Clustering mode is enabled: batch size %d, batch period %s, lease %s", Instance.batchSize, Instance.batchPeriod, Instance.lease)
+	return nil
+}
+
+//NewCoordinator return Coordinator with default values of not configured parameters
+func NewCoordinator(serverName string, queue Queue, config *Config) *Coordinator {
+	batchSize := config.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	batchPeriodSec := config.BatchPeriodSec
+	if batchPeriodSec <= 0 {
+		batchPeriodSec = defaultBatchPeriodSec
+	}
+	leaseSec := config.LeaseSec
+	if leaseSec <= 0 {
+		leaseSec = defaultLeaseSec
+	}
+
+	return &Coordinator{
+		serverName:  serverName,
+		queue:       queue,
+		batchSize:   batchSize,
+		batchPeriod: time.Duration(batchPeriodSec) * time.Second,
+		lease:       time.Duration(leaseSec) * time.Second,
+		partitions:  map[string]*partition{},
+	}
+}
+
+//Publisher return consumer of the destination events
+func (c *Coordinator) Publisher(destinationId string) *Publisher {
+	return &Publisher{destinationId: destinationId, queue: c.queue}
+}
+
+//Register start competing for the destination partition ownership. The owner stores partition events into the storage
+func (c *Coordinator) Register(destinationId string, storage events.StorageProxy) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed {
+		return
+	}
+	if _, ok := c.partitions[destinationId]; ok {
+		return
+	}
+
+	p := &partition{destinationId: destinationId, storage: storage, done: make(chan struct{}), stopped: make(chan struct{})}
+	c.partitions[destinationId] = p
+	safego.Run(func() {
+		defer close(p.stopped)
+		c.consume(p)
+	})
+}
+
+//Unregister stop consuming of the destination partition and release the ownership
+func (c *Coordinator) Unregister(destinationId string) {
+	c.mutex.Lock()
+	p, ok := c.partitions[destinationId]
+	delete(c.partitions, destinationId)
+	c.mutex.Unlock()
+
+	if ok {
+		c.stop(p)
+	}
+}
+
+//Owned return sorted destinations ids which partitions are owned by this node
+func (c *Coordinator) Owned() []string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	var owned []string
+	for destinationId, p := range c.partitions {
+		if p.owned {
+			owned = append(owned, destinationId)
+		}
+	}
+	sort.Strings(owned)
+	return owned
+}
+
+//Close stop consuming of all partitions (in-flight batches are stored) and release the ownership
+func (c *Coordinator) Close() error {
+	c.mutex.Lock()
+	c.closed = true
+	partitions := c.partitions
+	c.partitions = map[string]*partition{}
+	c.mutex.Unlock()
+
+	for _, p := range partitions {
+		c.stop(p)
+	}
+	return c.queue.Close()
+}
+
+func (c *Coordinator) stop(p *partition) {
+	close(p.done)
+	<-p.stopped
+	if err := c.queue.Release(p.destinationId, c.serverName); err != nil {
+		logging.Errorf("[%s] Error releasing clustering partition: %v", p.destinationId, err)
+	}
+}
+
+//consume acquire (renew) the partition lease and store batches while the lease is held
+func (c *Coordinator) consume(p *partition) {
+	for {
+		select {
+		case <-p.done:
+			return
+		default:
+		}
+
+		owned, err := c.queue.Acquire(p.destinationId, c.serverName, c.lease)
+		if err != nil {
+			logging.Errorf("[%s] Error acquiring clustering partition: %v", p.destinationId, err)
+		}
+		c.setOwned(p, owned)
+		if !owned {
+			c.wait(p, c.lease/3)
+			continue
+		}
+
+		if err := c.storeBatch(p); err != nil {
+			logging.Errorf("[%s] Error storing clustering batch: %v", p.destinationId, err)
+			c.wait(p, c.lease/3)
+		}
+	}
+}
+
+//storeBatch store pending messages or collect the batch of new ones and store it if the lease is still held.
+//Messages are acknowledged only if they have been stored
+func (c *Coordinator) storeBatch(p *partition) error {
+	messages, err := c.queue.ReadPending(p.destinationId, c.batchSize)
+	if err != nil {
+		return err
+	}
+	if len(messages) > 0 {
+		return c.store(p, messages)
+	}
+
+	deadline := time.Now().Add(c.batchPeriod)
+	renewAt := time.Now().Add(c.lease / 3)
+collect:
+	for len(messages) < c.batchSize {
+		left := time.Until(deadline)
+		if left <= 0 {
+			break
+		}
+		select {
+		case <-p.done:
+			break collect
+		default:
+		}
+
+		//reads mustn't outlive the lease
+		block := left
+		if block > c.lease/3 {
+			block = c.lease / 3
+		}
+		batch, err := c.queue.ReadNew(p.destinationId, c.batchSize-len(messages), block)
+		if err != nil {
+			return err
+		}
+		messages = append(messages, batch...)
+
+		if time.Now().After(renewAt) {
+			if owned, err := c.queue.Acquire(p.destinationId, c.serverName, c.lease); err != nil || !owned {
+				//not acknowledged messages will be redelivered to the new owner
+				c.setOwned(p, false)
+				return err
+			}
+			renewAt = time.Now().Add(c.lease / 3)
+		}
+	}
+	if len(messages) == 0 {
+		return nil
+	}
+
+	return c.store(p, messages)
+}
+
+func (c *Coordinator) store(p *partition, messages []Message) error {
+	owned, err := c.queue.Acquire(p.destinationId, c.serverName, c.lease)
+	if err != nil || !owned {
+		c.setOwned(p, false)
+		return err
+	}
+
+	storage, ok := p.storage.Get()
+	if !ok {
+		return fmt.Errorf("Destination storage isn't initialized")
+	}
+
+	var ids []string
+	payload := bytes.Buffer{}
+	for _, message := range messages {
+		ids = append(ids, message.Id)
+		//deleted messages
+		if len(message.Payload) == 0 {
+			continue
+		}
+		payload.Write(message.Payload)
+		payload.WriteByte('\n')
+	}
+
+	if payload.Len() > 0 {
+		fileName := fmt.Sprintf("%s-cluster-%s-%s.log", c.serverName, p.destinationId, time.Now().UTC().Format("2006-01-02T15-04-05.000"))
+		var rowsCount int
+		err := retry.Get(storage.Name()).Do("store", func() (err error) {
+			rowsCount, err = storage.Store(fileName, payload.Bytes())
+			return err
+		})
+		if err != nil {
+			counters.ErrorEvents(storage.Name(), rowsCount)
+			health.Instance.Failed(storage.Name())
+			return err
+		}
+		counters.SuccessEvents(storage.Name(), rowsCount)
+		health.Instance.Succeeded(storage.Name())
+	}
+
+	return c.queue.Ack(p.destinationId, ids...)
+}
+
+func (c *Coordinator) setOwned(p *partition, owned bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if p.owned != owned {
+		if owned {
+			logging.Infof("[%s] Clustering partition has been acquired by this node", p.destinationId)
+		} else {
+			logging.Infof("[%s] Clustering partition isn't owned by this node", p.destinationId)
+		}
+	}
+	p.owned = owned
+}
+
+func (c *Coordinator) wait(p *partition, period time.Duration) {
+	select {
+	case <-p.done:
+	case <-time.After(period):
+	}
+}
+
+//Consume publish the event into the destination partition
+func (p *Publisher) Consume(fact events.Fact, tokenId string) {
+	if err := p.queue.Publish(p.destinationId, []byte(fact.Serialize())); err != nil {
+		logging.Errorf("[%s] Error publishing event into clustering queue: %v", p.destinationId, err)
+		counters.ErrorEvents(p.destinationId, 1)
+	}
+}
+
+func (p *Publisher) Close() error {
+	return nil
+}
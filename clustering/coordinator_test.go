@@ -0,0 +1,178 @@
+package clustering
+
+import (
+	"bytes"
+	"errors"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/typing"
+	"github.com/stretchr/testify/require"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+//memoryQueue is a Queue with Redis streams semantics
+type memoryQueue struct {
+	sync.Mutex
+	nextId   int
+	messages map[string][]Message
+	pending  map[string][]Message
+	owners   map[string]string
+	expires  map[string]time.Time
+}
+
+func newMemoryQueue() *memoryQueue {
+	return &memoryQueue{messages: map[string][]Message{}, pending: map[string][]Message{}, owners: map[string]string{}, expires: map[string]time.Time{}}
+}
+
+func (mq *memoryQueue) Publish(partition string, payload []byte) error {
+	mq.Lock()
+	defer mq.Unlock()
+	mq.nextId++
+	mq.messages[partition] = append(mq.messages[partition], Message{Id: strconv.Itoa(mq.nextId), Payload: payload})
+	return nil
+}
+
+func (mq *memoryQueue) Acquire(partition, owner string, lease time.Duration) (bool, error) {
+	mq.Lock()
+	defer mq.Unlock()
+	if current, ok := mq.owners[partition]; ok && current != owner && time.Now().Before(mq.expires[partition]) {
+		return false, nil
+	}
+	mq.owners[partition] = owner
+	mq.expires[partition] = time.Now().Add(lease)
+	return true, nil
+}
+
+func (mq *memoryQueue) Release(partition, owner string) error {
+	mq.Lock()
+	defer mq.Unlock()
+	if mq.owners[partition] == owner {
+		delete(mq.owners, partition)
+	}
+	return nil
+}
+
+func (mq *memoryQueue) ReadPending(partition string, count int) ([]Message, error) {
+	mq.Lock()
+	defer mq.Unlock()
+	pending := mq.pending[partition]
+	if len(pending) > count {
+		pending = pending[:count]
+	}
+	return append([]Message{}, pending...), nil
+}
+
+func (mq *memoryQueue) ReadNew(partition string, count int, block time.Duration) ([]Message, error) {
+	mq.Lock()
+	messages := mq.messages[partition]
+	if len(messages) > count {
+		messages = messages[:count]
+	}
+	mq.messages[partition] = mq.messages[partition][len(messages):]
+	mq.pending[partition] = append(mq.pending[partition], messages...)
+	mq.Unlock()
+
+	if len(messages) == 0 {
+		time.Sleep(block)
+	}
+	return messages, nil
+}
+
+func (mq *memoryQueue) Ack(partition string, ids ...string) error {
+	mq.Lock()
+	defer mq.Unlock()
+	acked := map[string]bool{}
+	for _, id := range ids {
+		acked[id] = true
+	}
+	var pending []Message
+	for _, message := range mq.pending[partition] {
+		if !acked[message.Id] {
+			pending = append(pending, message)
+		}
+	}
+	mq.pending[partition] = pending
+	return nil
+}
+
+func (mq *memoryQueue) Close() error {
+	return nil
+}
+
+type storageMock struct {
+	sync.Mutex
+	name    string
+	fail    bool
+	payload bytes.Buffer
+}
+
+func (sm *storageMock) Store(fileName string, payload []byte) (int, error) {
+	sm.Lock()
+	defer sm.Unlock()
+	if sm.fail {
+		return 0, errors.New("storage error")
+	}
+	sm.payload.Write(payload)
+	return bytes.Count(payload, []byte("\n")), nil
+}
+
+func (sm *storageMock) stored() string {
+	sm.Lock()
+	defer sm.Unlock()
+	return sm.payload.String()
+}
+
+func (sm *storageMock) setFail(fail bool) {
+	sm.Lock()
+	defer sm.Unlock()
+	sm.fail = fail
+}
+
+func (sm *storageMock) StoreWithParseFunc(fileName string, payload []byte, parseFunc func([]byte) (map[string]interface{}, error)) (int, error) {
+	return sm.Store(fileName, payload)
+}
+func (sm *storageMock) SyncStore([]map[string]interface{}) (int, error) { return 0, nil }
+func (sm *storageMock) Fallback(fact ...*events.FailedFact)             {}
+func (sm *storageMock) ColumnTypesMapping() map[typing.DataType]string  { return nil }
+func (sm *storageMock) Name() string                                    { return sm.name }
+func (sm *storageMock) Type() string                                    { return "mock" }
+func (sm *storageMock) Close() error                                    { return nil }
+func (sm *storageMock) Get() (events.Storage, bool)                     { return sm, true }
+
+func TestPartitionOwnership(t *testing.T) {
+	queue := newMemoryQueue()
+	config := &Config{BatchSize: 2, BatchPeriodSec: 1, LeaseSec: 1}
+	nodeA := NewCoordinator("a", queue, config)
+	nodeB := NewCoordinator("b", queue, config)
+	defer nodeB.Close()
+
+	storageA := &storageMock{name: "dest"}
+	storageB := &storageMock{name: "dest"}
+	nodeA.Register("dest", storageA)
+	//the first node acquires the partition
+	require.Eventually(t, func() bool { return len(nodeA.Owned()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	nodeB.Register("dest", storageB)
+
+	publisher := nodeB.Publisher("dest")
+	publisher.Consume(events.Fact{"id": 1}, "token")
+	publisher.Consume(events.Fact{"id": 2}, "token")
+	publisher.Consume(events.Fact{"id": 3}, "token")
+
+	require.Eventually(t, func() bool { return storageA.stored() == "{\"id\":1}\n{\"id\":2}\n{\"id\":3}\n" }, 5*time.Second, 10*time.Millisecond)
+	require.Empty(t, storageB.stored())
+	require.Empty(t, nodeB.Owned())
+
+	//failed batch is redelivered to the next owner
+	storageA.setFail(true)
+	publisher.Consume(events.Fact{"id": 4}, "token")
+	require.Eventually(t, func() bool {
+		pending, _ := queue.ReadPending("dest", 10)
+		return len(pending) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, nodeA.Close())
+
+	require.Eventually(t, func() bool { return storageB.stored() == "{\"id\":4}\n" }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, []string{"dest"}, nodeB.Owned())
+}
@@ -0,0 +1,235 @@
+package clustering
+
+import (
+	"fmt"
+	"github.com/gomodule/redigo/redis"
+	"github.com/jitsucom/eventnative/logging"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	RedisQueueType = "redis"
+
+	consumerGroup = "eventnative"
+	//only the partition owner reads, so all nodes use one consumer name: pending (read but not acknowledged) messages
+	//of a failed owner are redelivered to the next one
+	consumerName = "owner"
+)
+
+var (
+	//renew the lease if it is held by the owner or acquire it if it is free
+	acquireLease = redis.NewScript(1, `if redis.call('get', KEYS[1]) == ARGV[1] then return redis.call('pexpire', KEYS[1], ARGV[2]) end
+if redis.call('set', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2]) then return 1 end
+return 0`)
+	releaseLease = redis.NewScript(1, `if redis.call('get', KEYS[1]) == ARGV[1] then return redis.call('del', KEYS[1]) end return 0`)
+)
+
+//Message is a queue message with its id (for acknowledgment)
+type Message struct {
+	Id      string
+	Payload []byte
+}
+
+//Queue is a shared durable queue of events partitions (one partition per destination) with partitions ownership
+type Queue interface {
+	io.Closer
+
+	Publish(partition string, payload []byte) error
+	//Acquire return true if the partition lease is held by the owner (has been acquired or renewed)
+	Acquire(partition, owner string, lease time.Duration) (bool, error)
+	Release(partition, owner string) error
+	//ReadPending return messages which have been read before but haven't been acknowledged (e.g. by failed owner)
+	ReadPending(partition string, count int) ([]Message, error)
+	//ReadNew return messages which haven't been read yet. They are waited for no longer than block
+	ReadNew(partition string, count int, block time.Duration) ([]Message, error)
+	Ack(partition string, ids ...string) error
+}
+
+//RedisQueue is a Queue on Redis streams. Keys:
+//cluster:destination#destinationId:events - stream with events JSON (consumer group eventnative)
+//cluster:destination#destinationId:owner  - partition lease with owner server name
+type RedisQueue struct {
+	pool *redis.Pool
+
+	groupsMutex sync.Mutex
+	groups      map[string]bool
+}
+
+func NewRedisQueue(host string, port int, password string) (*RedisQueue, error) {
+	logging.Infof("Initializing clustering redis [%s:%d]...", host, port)
+	rq := &RedisQueue{
+		pool: &redis.Pool{
+			MaxIdle:     100,
+			MaxActive:   600,
+			IdleTimeout: 240 * time.Second,
+
+			Wait: false,
+			Dial: func() (redis.Conn, error) {
+				return redis.Dial(
+					"tcp",
+					host+":"+strconv.Itoa(port),
+					redis.DialConnectTimeout(10*time.Second),
+					//blocking reads are longer than default timeout
+					redis.DialReadTimeout(time.Minute),
+					redis.DialPassword(password),
+				)
+			},
+			TestOnBorrow: func(c redis.Conn, t time.Time) error {
+				_, err := c.Do("PING")
+				return err
+			},
+		},
+		groups: map[string]bool{},
+	}
+
+	//test connection
+	connection := rq.pool.Get()
+	defer connection.Close()
+	if _, err := redis.String(connection.Do("PING")); err != nil {
+		return nil, fmt.Errorf("Error testing connection to Redis: %v", err)
+	}
+
+	return rq, nil
+}
+
+func (rq *RedisQueue) Publish(partition string, payload []byte) error {
+	connection := rq.pool.Get()
+	defer connection.Close()
+
+	_, err := connection.Do("XADD", streamKey(partition), "*", "event", payload)
+	return err
+}
+
+func (rq *RedisQueue) Acquire(partition, owner string, lease time.Duration) (bool, error) {
+	connection := rq.pool.Get()
+	defer connection.Close()
+
+	acquired, err := redis.Int(acquireLease.Do(connection, leaseKey(partition), owner, lease.Milliseconds()))
+	if err != nil {
+		return false, err
+	}
+	if acquired == 0 {
+		return false, nil
+	}
+
+	return true, rq.ensureGroup(connection, partition)
+}
+
+func (rq *RedisQueue) Release(partition, owner string) error {
+	connection := rq.pool.Get()
+	defer connection.Close()
+
+	_, err := releaseLease.Do(connection, leaseKey(partition), owner)
+	return err
+}
+
+func (rq *RedisQueue) ReadPending(partition string, count int) ([]Message, error) {
+	connection := rq.pool.Get()
+	defer connection.Close()
+
+	return rq.read(connection, partition, count, "0", 0)
+}
+
+func (rq *RedisQueue) ReadNew(partition string, count int, block time.Duration) ([]Message, error) {
+	connection := rq.pool.Get()
+	defer connection.Close()
+
+	return rq.read(connection, partition, count, ">", block)
+}
+
+func (rq *RedisQueue) read(connection redis.Conn, partition string, count int, id string, block time.Duration) ([]Message, error) {
+	args := redis.Args{}.Add("GROUP", consumerGroup, consumerName, "COUNT", count)
+	if id == ">" {
+		args = args.Add("BLOCK", block.Milliseconds())
+	}
+	args = args.Add("STREAMS", streamKey(partition), id)
+
+	streams, err := redis.Values(connection.Do("XREADGROUP", args...))
+	if err != nil {
+		if err == redis.ErrNil {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var messages []Message
+	for _, stream := range streams {
+		//[key, [[id, [field, value]], ...]]
+		streamValues, err := redis.Values(stream, nil)
+		if err != nil || len(streamValues) != 2 {
+			return nil, fmt.Errorf("Malformed XREADGROUP reply: %v", err)
+		}
+		entries, err := redis.Values(streamValues[1], nil)
+		if err != nil {
+			return nil, fmt.Errorf("Malformed XREADGROUP entries: %v", err)
+		}
+		for _, entry := range entries {
+			entryValues, err := redis.Values(entry, nil)
+			if err != nil || len(entryValues) != 2 {
+				return nil, fmt.Errorf("Malformed XREADGROUP entry: %v", err)
+			}
+			messageId, err := redis.String(entryValues[0], nil)
+			if err != nil {
+				return nil, err
+			}
+			//fields are nil if the message has been deleted
+			fields, _ := redis.ByteSlices(entryValues[1], nil)
+			message := Message{Id: messageId}
+			if len(fields) == 2 {
+				message.Payload = fields[1]
+			}
+			messages = append(messages, message)
+		}
+	}
+	return messages, nil
+}
+
+//Ack acknowledge and delete messages so as the stream keeps only not stored events
+func (rq *RedisQueue) Ack(partition string, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	connection := rq.pool.Get()
+	defer connection.Close()
+
+	key := streamKey(partition)
+	if _, err := connection.Do("XACK", redis.Args{}.Add(key, consumerGroup).AddFlat(ids)...); err != nil {
+		return err
+	}
+	_, err := connection.Do("XDEL", redis.Args{}.Add(key).AddFlat(ids)...)
+	return err
+}
+
+func (rq *RedisQueue) ensureGroup(connection redis.Conn, partition string) error {
+	rq.groupsMutex.Lock()
+	defer rq.groupsMutex.Unlock()
+
+	if rq.groups[partition] {
+		return nil
+	}
+
+	_, err := connection.Do("XGROUP", "CREATE", streamKey(partition), consumerGroup, "0", "MKSTREAM")
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("Error creating consumer group: %v", err)
+	}
+
+	rq.groups[partition] = true
+	return nil
+}
+
+func (rq *RedisQueue) Close() error {
+	return rq.pool.Close()
+}
+
+func streamKey(partition string) string {
+	return "cluster:destination#" + partition + ":events"
+}
+
+func leaseKey(partition string) string {
+	return "cluster:destination#" + partition + ":owner"
+}
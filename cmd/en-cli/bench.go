@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/test/bench"
+	"io"
+	"io/ioutil"
+)
+
+func benchmark(args []string, output io.Writer) error {
+	flags := flag.NewFlagSet("bench", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: en-cli bench [flags]")
+		fmt.Fprintln(flags.Output(), "Generates synthetic events and pushes them through the processor and a destination mock. Prints throughput and allocation stats")
+		flags.PrintDefaults()
+	}
+	config := bench.Config{}
+	flags.IntVar(&config.Events, "events", 100000, "generated events count")
+	flags.IntVar(&config.BatchSize, "batch_size", 1000, "events per processed file payload")
+	flags.IntVar(&config.Fields, "fields", 20, "leaf fields per event (without system fields)")
+	flags.IntVar(&config.Depth, "depth", 2, "max nesting level of fields. 0 - flat events")
+	flags.IntVar(&config.Cardinality, "cardinality", 100, "distinct values of string and integer fields")
+	flags.IntVar(&config.EventTypes, "event_types", 5, "distinct event_type values")
+	flags.StringVar(&config.TableNameTemplate, "table_template", "events", "destination table name template e.g. '{{.event_type}}'")
+	flags.Int64Var(&config.Seed, "seed", 1, "random seed: the same seed generates the same events")
+	runs := flags.Int("runs", 1, "workload runs count. The first run warms up and isn't printed if there are several runs")
+	jsonOutput := flags.Bool("json", false, "print stats as JSON lines")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	//processing warnings of every event would be measured
+	logging.InitGlobalLogger(ioutil.Discard)
+
+	workload, err := bench.NewWorkload(config)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < *runs; i++ {
+		stats, err := workload.Run()
+		if err != nil {
+			return err
+		}
+		if *runs > 1 && i == 0 {
+			continue
+		}
+
+		if *jsonOutput {
+			b, err := json.Marshal(stats)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(output, string(b))
+		} else {
+			fmt.Fprintln(output, stats.String())
+		}
+	}
+
+	return nil
+}
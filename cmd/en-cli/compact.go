@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/jitsucom/eventnative/adapters"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/schema"
+	"github.com/jitsucom/eventnative/storages"
+	"github.com/jitsucom/eventnative/typing"
+	"github.com/spf13/viper"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+//warehouse is a destination which supports tables compaction
+type warehouse interface {
+	TablesList() ([]string, error)
+	GetTableSchema(tableName string) (*schema.Table, error)
+	CreateTable(tableSchema *schema.Table) error
+	PatchTableSchema(patchSchema *schema.Table) error
+	CompactTable(target, source *schema.Table, drop bool) (int64, error)
+	Close() error
+}
+
+type compactOptions struct {
+	configPath  string
+	destination string
+	tables      *regexp.Regexp
+	target      string
+	drop        bool
+	dryRun      bool
+}
+
+//compactionPlan is a target table schema and source tables
+//target column types are types of the existing target table or common types of all source tables columns
+//(the same typecast tree as for incoming events)
+type compactionPlan struct {
+	target       *schema.Table
+	targetExists bool
+	//columns which will be added into the target table
+	newColumns schema.Columns
+	sources    []*schema.Table
+}
+
+func compact(args []string, output io.Writer) error {
+	flags := flag.NewFlagSet("compact", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: en-cli compact -config eventnative.yaml -destination <id> -tables <regexp> -target <table> [flags]")
+		fmt.Fprintln(flags.Output(), "Merges per-period tables (e.g. events_2020_08_01, events_2020_08_02) of postgres or redshift destination into one table")
+		flags.PrintDefaults()
+	}
+	options := &compactOptions{}
+	flags.StringVar(&options.configPath, "config", "eventnative.yaml", "EventNative configuration file with the destination")
+	flags.StringVar(&options.destination, "destination", "", "destination id from the configuration file")
+	tables := flags.String("tables", "", "regular expression of source table names e.g. '^events_\\d{4}_\\d{2}_\\d{2}$'")
+	flags.StringVar(&options.target, "target", "", "target table name. It is created or patched with missing columns")
+	flags.BoolVar(&options.drop, "drop", false, "drop every source table in the same transaction after copying its rows")
+	flags.BoolVar(&options.dryRun, "dry_run", false, "print the plan without any changes")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if options.destination == "" || *tables == "" || options.target == "" {
+		flags.Usage()
+		return errors.New("destination, tables and target flags are required")
+	}
+	var err error
+	if options.tables, err = regexp.Compile(*tables); err != nil {
+		return fmt.Errorf("Error parsing 'tables' flag: %v", err)
+	}
+
+	w, err := openWarehouse(options.configPath, options.destination)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	plan, err := planCompaction(w, options)
+	if err != nil {
+		return err
+	}
+	printPlan(output, plan)
+	if options.dryRun || len(plan.sources) == 0 {
+		return nil
+	}
+
+	if !plan.targetExists {
+		if err := w.CreateTable(plan.target); err != nil {
+			return err
+		}
+	} else if len(plan.newColumns) > 0 {
+		if err := w.PatchTableSchema(&schema.Table{Name: plan.target.Name, Columns: plan.newColumns}); err != nil {
+			return err
+		}
+	}
+
+	var total int64
+	for _, source := range plan.sources {
+		copied, err := w.CompactTable(plan.target, source, options.drop)
+		if err != nil {
+			return fmt.Errorf("%v. Already compacted rows: %d", err, total)
+		}
+		total += copied
+		fmt.Fprintf(output, "%s: %d rows copied\n", source.Name, copied)
+	}
+	fmt.Fprintf(output, "Total: %d rows from %d tables copied into %s\n", total, len(plan.sources), plan.target.Name)
+
+	return nil
+}
+
+//openWarehouse return postgres or redshift adapter of the destination from the configuration file
+func openWarehouse(configPath, destinationId string) (warehouse, error) {
+	config := viper.New()
+	config.SetConfigFile(configPath)
+	if err := config.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("Error reading configuration file [%s]: %v", configPath, err)
+	}
+
+	key := "destinations." + destinationId
+	if !config.IsSet(key) {
+		return nil, fmt.Errorf("Destination [%s] wasn't found in [%s]", destinationId, configPath)
+	}
+	destination := storages.DestinationConfig{}
+	if err := config.UnmarshalKey(key, &destination); err != nil {
+		return nil, fmt.Errorf("Error parsing destination [%s] config: %v", destinationId, err)
+	}
+	if destination.Type == "" {
+		destination.Type = destinationId
+	}
+
+	if err := destination.DataSource.Validate(); err != nil {
+		return nil, err
+	}
+	if destination.DataSource.Schema == "" {
+		destination.DataSource.Schema = "public"
+	}
+	queryLogger := logging.NewQueryLogger(destinationId, os.Stderr)
+
+	switch destination.Type {
+	case storages.PostgresType:
+		return adapters.NewPostgres(context.Background(), destination.DataSource, queryLogger)
+	case storages.RedshiftType:
+		return adapters.NewAwsRedshift(context.Background(), destination.DataSource, destination.S3, queryLogger)
+	default:
+		return nil, fmt.Errorf("Destination type [%s] doesn't support compaction. Supported: %s, %s", destination.Type, storages.PostgresType, storages.RedshiftType)
+	}
+}
+
+func planCompaction(w warehouse, options *compactOptions) (*compactionPlan, error) {
+	tableNames, err := w.TablesList()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(tableNames)
+
+	target, err := w.GetTableSchema(options.target)
+	if err != nil {
+		return nil, err
+	}
+	plan := &compactionPlan{target: target, targetExists: target.Exists(), newColumns: schema.Columns{}}
+	if target.Columns == nil {
+		target.Columns = schema.Columns{}
+	}
+
+	merged := schema.Columns{}
+	for _, name := range tableNames {
+		if name == options.target || !options.tables.MatchString(name) {
+			continue
+		}
+		source, err := w.GetTableSchema(name)
+		if err != nil {
+			return nil, err
+		}
+		if !source.Exists() {
+			continue
+		}
+		plan.sources = append(plan.sources, source)
+		merged.Merge(copyColumns(source.Columns))
+	}
+
+	var errs []string
+	for name, column := range merged {
+		targetColumn, ok := target.Columns[name]
+		if !ok {
+			mergedColumn := schema.NewColumn(column.GetType())
+			plan.newColumns[name] = mergedColumn
+			target.Columns[name] = mergedColumn
+			continue
+		}
+
+		//existing target types are kept: every source type must be convertible
+		for _, source := range plan.sources {
+			sourceColumn, ok := source.Columns[name]
+			if ok && !typing.IsConvertible(sourceColumn.GetType(), targetColumn.GetType()) {
+				errs = append(errs, fmt.Sprintf("%s.%s: %s can't be converted to target %s", source.Name, name, sourceColumn.GetType(), targetColumn.GetType()))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return nil, fmt.Errorf("Incompatible column types:\n%s", strings.Join(errs, "\n"))
+	}
+
+	return plan, nil
+}
+
+//copyColumns return columns copy: Columns.Merge changes type occurrences of current columns
+func copyColumns(columns schema.Columns) schema.Columns {
+	result := schema.Columns{}
+	for name, column := range columns {
+		result[name] = schema.NewColumn(column.GetType())
+	}
+	return result
+}
+
+func printPlan(output io.Writer, plan *compactionPlan) {
+	if plan.targetExists {
+		fmt.Fprintf(output, "Target table: %s (exists, new columns: %d)\n", plan.target.Name, len(plan.newColumns))
+	} else {
+		fmt.Fprintf(output, "Target table: %s (will be created)\n", plan.target.Name)
+	}
+
+	columns := plan.target.Columns.Header()
+	sort.Strings(columns)
+	for _, name := range columns {
+		marker := ""
+		if _, ok := plan.newColumns[name]; ok && plan.targetExists {
+			marker = " (new)"
+		}
+		fmt.Fprintf(output, "  %s %s%s\n", name, plan.target.Columns[name].GetType(), marker)
+	}
+
+	fmt.Fprintf(output, "Source tables: %d\n", len(plan.sources))
+	for _, source := range plan.sources {
+		var casts []string
+		for name, column := range source.Columns {
+			if targetType := plan.target.Columns[name].GetType(); column.GetType() != targetType {
+				casts = append(casts, fmt.Sprintf("%s %s -> %s", name, column.GetType(), targetType))
+			}
+		}
+		sort.Strings(casts)
+		if len(casts) == 0 {
+			fmt.Fprintf(output, "  %s\n", source.Name)
+		} else {
+			fmt.Fprintf(output, "  %s (casts: %s)\n", source.Name, strings.Join(casts, ", "))
+		}
+	}
+}
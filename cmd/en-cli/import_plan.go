@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/jitsucom/eventnative/trackingplan"
+	"github.com/jitsucom/eventnative/validation"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+//importedConfig is a part of EventNative configuration with the imported tracking plan and validation schemas
+type importedConfig struct {
+	Server importedServerConfig `json:"server"`
+}
+
+type importedServerConfig struct {
+	TrackingPlan *trackingplan.Config      `json:"tracking_plan"`
+	Validation   *importedValidationConfig `json:"validation,omitempty"`
+}
+
+type importedValidationConfig struct {
+	Schemas map[string]*validation.SchemaConfig `json:"schemas"`
+}
+
+func importPlan(args []string, output io.Writer) error {
+	flags := flag.NewFlagSet("import-plan", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: en-cli import-plan -format <segment|avo> [flags] <tracking plan export file>")
+		fmt.Fprintln(flags.Output(), "Converts Segment Protocols or Avo tracking plan JSON export into server.tracking_plan and server.validation.schemas configuration (JSON is valid YAML)")
+		flags.PrintDefaults()
+	}
+	format := flags.String("format", "", "tracking plan export format: segment or avo")
+	propertiesPath := flags.String("properties_path", trackingplan.DefaultPropertiesPath, "JSON path of the event properties in EventNative events. '/' - event root")
+	action := flags.String("action", "", "tracking plan action: tag (default), quarantine or block")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *format == "" || flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("format flag and one tracking plan file are required")
+	}
+
+	payload, err := ioutil.ReadFile(flags.Arg(0))
+	if err != nil {
+		return fmt.Errorf("Error reading tracking plan file: %v", err)
+	}
+
+	imported, err := trackingplan.Import(*format, payload, *propertiesPath)
+	if err != nil {
+		return err
+	}
+	imported.TrackingPlan.Action = *action
+	//check the result is a valid configuration
+	if _, err := trackingplan.NewPlan(*imported.TrackingPlan); err != nil {
+		return fmt.Errorf("Imported tracking plan is invalid: %v", err)
+	}
+	for _, warning := range imported.Warnings {
+		fmt.Fprintln(os.Stderr, warning)
+	}
+
+	result := importedConfig{Server: importedServerConfig{TrackingPlan: imported.TrackingPlan}}
+	if len(imported.Schemas) > 0 {
+		result.Server.Validation = &importedValidationConfig{Schemas: imported.Schemas}
+	}
+	encoder := json.NewEncoder(output)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(result)
+}
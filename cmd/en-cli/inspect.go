@@ -0,0 +1,405 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/jitsucom/eventnative/compression"
+	"github.com/jitsucom/eventnative/encryption"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/parsers"
+	"github.com/jitsucom/eventnative/schema"
+	"github.com/jitsucom/eventnative/timestamp"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	formatEvents   = "events"
+	formatFallback = "fallback"
+	formatQueue    = "queue"
+
+	tokenKey    = "api_key"
+	queueDirExt = ".queue"
+)
+
+type inspectOptions struct {
+	format        string
+	tableTemplate string
+	table         string
+	token         string
+	from          time.Time
+	to            time.Time
+	limit         int
+	ndjson        bool
+}
+
+//record is a decoded event from any file format
+type record struct {
+	tokenId string
+	event   events.Fact
+	//fallback event error or decoding error
+	err string
+}
+
+type fileSummary struct {
+	path    string
+	format  string
+	total   int
+	matched int
+	errors  int
+}
+
+type tableSummary struct {
+	rows    int
+	columns map[string]map[string]int
+	samples []events.Fact
+}
+
+//inspector decodes files, applies filters and accumulates per table schemas and sample rows
+type inspector struct {
+	options   *inspectOptions
+	processor *schema.Processor
+	output    io.Writer
+
+	files  []*fileSummary
+	tables map[string]*tableSummary
+	errors []string
+}
+
+func inspect(args []string, output io.Writer) error {
+	flags := flag.NewFlagSet("inspect", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: en-cli inspect [flags] <events log file | fallback file | stream queue dir>...")
+		flags.PrintDefaults()
+	}
+	options := &inspectOptions{}
+	flags.StringVar(&options.format, "format", "", "input format: events, fallback or queue. Detected by the file name if empty")
+	flags.StringVar(&options.tableTemplate, "table_template", "events", "destination table name template for splitting events by tables e.g. '{{.event_type}}'")
+	flags.StringVar(&options.table, "table", "", "show only events of the table")
+	flags.StringVar(&options.token, "token", "", "show only events of the token (token value or token id)")
+	from := flags.String("from", "", "show only events with _timestamp >= from (RFC3339)")
+	to := flags.String("to", "", "show only events with _timestamp < to (RFC3339)")
+	flags.IntVar(&options.limit, "limit", 5, "sample rows and errors per table count")
+	flags.BoolVar(&options.ndjson, "ndjson", false, "write all matched events as NDJSON into stdout instead of schemas and sample rows")
+	encryptionKey := flags.String("encryption_key", "", "server.encryption.key value (base64, file:///path, env://NAME or aws-kms://region/ciphertext) for reading encrypted files")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := encryption.Init(encryption.Config{Key: *encryptionKey}); err != nil {
+		return err
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return errors.New("At least one file or queue dir is required")
+	}
+
+	var err error
+	if options.from, err = parseTime(*from); err != nil {
+		return fmt.Errorf("Error parsing 'from' flag: %v", err)
+	}
+	if options.to, err = parseTime(*to); err != nil {
+		return fmt.Errorf("Error parsing 'to' flag: %v", err)
+	}
+	if options.format != "" && options.format != formatEvents && options.format != formatFallback && options.format != formatQueue {
+		return fmt.Errorf("Unknown format: %s", options.format)
+	}
+
+	processor, err := schema.NewProcessor(options.tableTemplate, nil, schema.Default, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	i := &inspector{options: options, processor: processor, output: output, tables: map[string]*tableSummary{}}
+	for _, filePath := range flags.Args() {
+		if err := i.inspectFile(filePath); err != nil {
+			return err
+		}
+	}
+
+	if !options.ndjson {
+		i.print()
+	} else {
+		i.printSummary(os.Stderr)
+	}
+
+	return nil
+}
+
+func (i *inspector) inspectFile(filePath string) error {
+	format := i.options.format
+	if format == "" {
+		format = detectFormat(filePath)
+	}
+
+	summary := &fileSummary{path: filePath, format: format}
+	i.files = append(i.files, summary)
+
+	handle := func(r *record) error {
+		summary.total++
+		if r.event == nil {
+			summary.errors++
+			i.addError(fmt.Sprintf("%s: %s", filePath, r.err))
+			return nil
+		}
+
+		matched, err := i.handle(r)
+		if err != nil {
+			summary.errors++
+			i.addError(fmt.Sprintf("%s: %v", filePath, err))
+		}
+		if matched {
+			summary.matched++
+		}
+		return nil
+	}
+
+	switch format {
+	case formatQueue:
+		return events.ReadQueue(filePath, func(queuedFact *events.QueuedFact, err error) error {
+			if err != nil {
+				return handle(&record{err: err.Error()})
+			}
+
+			fact, err := parsers.ParseJson(queuedFact.FactBytes)
+			if err != nil {
+				return handle(&record{err: fmt.Sprintf("Error parsing queued event: %v", err)})
+			}
+			return handle(&record{tokenId: queuedFact.TokenId, event: fact})
+		})
+	case formatFallback:
+		return readLines(filePath, func(line []byte) error {
+			failedFact := &events.FailedFact{}
+			if err := json.Unmarshal(line, failedFact); err != nil {
+				return handle(&record{err: fmt.Sprintf("Error parsing fallback line: %v", err)})
+			}
+
+			fact, err := parsers.ParseFallbackJson(line)
+			if err != nil {
+				return handle(&record{err: err.Error()})
+			}
+			return handle(&record{event: fact, err: failedFact.Error})
+		})
+	default:
+		tokenId := ""
+		if regexResult := logging.TokenIdExtractRegexp.FindStringSubmatch(filepath.Base(filePath)); len(regexResult) == 2 {
+			tokenId = regexResult[1]
+		}
+
+		return readLines(filePath, func(line []byte) error {
+			fact, err := parsers.ParseJson(line)
+			if err != nil {
+				return handle(&record{err: fmt.Sprintf("Error parsing event: %v", err)})
+			}
+			return handle(&record{tokenId: tokenId, event: fact})
+		})
+	}
+}
+
+//handle apply filters and accumulate table schema and sample rows or write the event as NDJSON
+//return true if the record matches all filters
+func (i *inspector) handle(r *record) (bool, error) {
+	if i.options.token != "" {
+		token, _ := r.event[tokenKey].(string)
+		if r.tokenId != i.options.token && token != i.options.token {
+			return false, nil
+		}
+	}
+
+	if !i.options.from.IsZero() || !i.options.to.IsZero() {
+		ts, _ := r.event[timestamp.Key].(string)
+		t, err := time.Parse(timestamp.Layout, ts)
+		if err != nil {
+			return false, nil
+		}
+		if !i.options.from.IsZero() && t.Before(i.options.from) {
+			return false, nil
+		}
+		if !i.options.to.IsZero() && !t.Before(i.options.to) {
+			return false, nil
+		}
+	}
+
+	table, flatEvent, err := i.processor.ProcessFact(r.event)
+	if err != nil {
+		return false, err
+	}
+	if i.options.table != "" && table.Name != i.options.table {
+		return false, nil
+	}
+
+	if r.err != "" {
+		i.addError(fmt.Sprintf("[%s] %s", table.Name, r.err))
+	}
+
+	if i.options.ndjson {
+		b, err := json.Marshal(r.event)
+		if err != nil {
+			return false, err
+		}
+		i.output.Write(append(b, '\n'))
+		return true, nil
+	}
+
+	summary, ok := i.tables[table.Name]
+	if !ok {
+		summary = &tableSummary{columns: map[string]map[string]int{}}
+		i.tables[table.Name] = summary
+	}
+	summary.rows++
+	for name, column := range table.Columns {
+		types, ok := summary.columns[name]
+		if !ok {
+			types = map[string]int{}
+			summary.columns[name] = types
+		}
+		types[column.GetType().String()]++
+	}
+	if len(summary.samples) < i.options.limit {
+		summary.samples = append(summary.samples, flatEvent)
+	}
+
+	return true, nil
+}
+
+func (i *inspector) addError(msg string) {
+	if len(i.errors) < i.options.limit {
+		i.errors = append(i.errors, msg)
+	}
+}
+
+func (i *inspector) print() {
+	i.printSummary(i.output)
+
+	tableNames := make([]string, 0, len(i.tables))
+	for name := range i.tables {
+		tableNames = append(tableNames, name)
+	}
+	sort.Strings(tableNames)
+
+	for _, name := range tableNames {
+		summary := i.tables[name]
+		fmt.Fprintf(i.output, "\nTable: %s (%d rows)\n", name, summary.rows)
+
+		fmt.Fprintln(i.output, "  Schema:")
+		columnNames := make([]string, 0, len(summary.columns))
+		width := 0
+		for column := range summary.columns {
+			columnNames = append(columnNames, column)
+			if len(column) > width {
+				width = len(column)
+			}
+		}
+		sort.Strings(columnNames)
+		for _, column := range columnNames {
+			fmt.Fprintf(i.output, "    %-*s  %s\n", width, column, formatTypes(summary.columns[column]))
+		}
+
+		fmt.Fprintln(i.output, "  Sample rows:")
+		for _, sample := range summary.samples {
+			b, err := json.MarshalIndent(sample, "    ", "  ")
+			if err != nil {
+				b = []byte(err.Error())
+			}
+			fmt.Fprintf(i.output, "    %s\n", b)
+		}
+	}
+
+	if len(i.errors) > 0 {
+		fmt.Fprintln(i.output, "\nErrors:")
+		for _, msg := range i.errors {
+			fmt.Fprintf(i.output, "  %s\n", msg)
+		}
+	}
+}
+
+func (i *inspector) printSummary(w io.Writer) {
+	for _, summary := range i.files {
+		fmt.Fprintf(w, "File: %s (format: %s) events: %d matched: %d errors: %d\n", summary.path, summary.format, summary.total, summary.matched, summary.errors)
+	}
+}
+
+//formatTypes return 'TYPE' or 'TYPE1 (count) | TYPE2 (count)' if a column has several types
+func formatTypes(types map[string]int) string {
+	names := make([]string, 0, len(types))
+	for name := range types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if len(names) == 1 {
+		return names[0]
+	}
+
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s (%d)", name, types[name]))
+	}
+	return strings.Join(parts, " | ")
+}
+
+//detectFormat return queue for dirs, fallback for $serverName-errors-$destinationId-$timestamp.log files and events otherwise
+func detectFormat(filePath string) string {
+	if info, err := os.Stat(filePath); err == nil && info.IsDir() || strings.HasSuffix(filePath, queueDirExt) {
+		return formatQueue
+	}
+
+	if strings.Contains(filepath.Base(filePath), "-errors-") {
+		return formatFallback
+	}
+
+	return formatEvents
+}
+
+func readLines(filePath string, fn func(line []byte) error) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("Error opening file [%s]: %v", filePath, err)
+	}
+	defer file.Close()
+
+	//compressed events log and fallback files are decompressed
+	decompressed, err := compression.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("Error reading file [%s]: %v", filePath, err)
+	}
+	defer decompressed.Close()
+
+	reader := bufio.NewReaderSize(decompressed, 64*1024)
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return fmt.Errorf("Error reading line in [%s] file: %v", filePath, readErr)
+		}
+
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			line, err = encryption.DecryptLine(line)
+			if err != nil {
+				return fmt.Errorf("Error decrypting line in [%s] file: %v", filePath, err)
+			}
+			if err := fn(line); err != nil {
+				return err
+			}
+		}
+
+		if readErr == io.EOF {
+			return nil
+		}
+	}
+}
+
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
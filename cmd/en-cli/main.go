@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"github.com/jitsucom/eventnative/logging"
+	"os"
+)
+
+const usage = `en-cli is an EventNative operator tool
+
+Usage:
+  en-cli <command> [flags] [arguments]
+
+Commands:
+  inspect        decode events log files, fallback files and stream queue dirs: print schemas and sample rows or convert to NDJSON
+  compact        merge per-period tables (e.g. per-day) of a postgres or redshift destination into one table
+  import-plan    convert Segment Protocols or Avo tracking plan export into tracking plan and validation schemas configuration
+  bench          generate synthetic events and measure processing throughput and allocations
+
+Run 'en-cli <command> -h' for the command flags
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	logging.InitGlobalLogger(os.Stderr)
+
+	var err error
+	switch os.Args[1] {
+	case "inspect":
+		err = inspect(os.Args[2:], os.Stdout)
+	case "compact":
+		err = compact(os.Args[2:], os.Stdout)
+	case "import-plan":
+		err = importPlan(os.Args[2:], os.Stdout)
+	case "bench":
+		err = benchmark(os.Args[2:], os.Stdout)
+	case "help", "-h", "--help":
+		fmt.Fprint(os.Stdout, usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,123 @@
+package compression
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"github.com/klauspost/compress/zstd"
+	"io"
+	"io/ioutil"
+)
+
+const (
+	Gzip = "gzip"
+	Zstd = "zstd"
+)
+
+var (
+	//ErrTruncated is returned with data which has been decompressed before the end of the truncated compressed data
+	ErrTruncated = errors.New("Compressed data is truncated")
+
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+//Validate return err if compression isn't supported. Empty value means no compression
+func Validate(compression string) error {
+	switch compression {
+	case "", Gzip, Zstd:
+		return nil
+	default:
+		return fmt.Errorf("Unknown compression: %s. Available: [gzip, zstd]", compression)
+	}
+}
+
+//Extension return file name extension of compressed files: .gz or .zst
+func Extension(compression string) string {
+	switch compression {
+	case Gzip:
+		return ".gz"
+	case Zstd:
+		return ".zst"
+	default:
+		return ""
+	}
+}
+
+//NewWriter return writer which compresses data into w. Close flushes compressed data but doesn't close w
+func NewWriter(compression string, w io.Writer) (io.WriteCloser, error) {
+	switch compression {
+	case Gzip:
+		return gzip.NewWriter(w), nil
+	case Zstd:
+		return zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+	default:
+		return nil, fmt.Errorf("Unknown compression: %s. Available: [gzip, zstd]", compression)
+	}
+}
+
+//IsCompressed return true if data starts with gzip or zstd magic number
+func IsCompressed(data []byte) bool {
+	return bytes.HasPrefix(data, gzipMagic) || bytes.HasPrefix(data, zstdMagic)
+}
+
+//NewReader return reader of decompressed data if r starts with gzip or zstd magic number or reader of r data as is
+func NewReader(r io.Reader) (io.ReadCloser, error) {
+	buffered := bufio.NewReader(r)
+	magic, err := buffered.Peek(len(zstdMagic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		gzipReader, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, fmt.Errorf("Malformed gzip data: %v", err)
+		}
+		return gzipReader, nil
+	case bytes.HasPrefix(magic, zstdMagic):
+		zstdReader, err := zstd.NewReader(buffered, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, fmt.Errorf("Malformed zstd data: %v", err)
+		}
+		return &zstdReadCloser{Decoder: zstdReader}, nil
+	default:
+		return ioutil.NopCloser(buffered), nil
+	}
+}
+
+//Decompress return decompressed gzip or zstd data (detected by magic number). Other data is returned as is.
+//Data of truncated compressed files (e.g. after a crash) is returned until the truncation point with ErrTruncated
+func Decompress(data []byte) ([]byte, error) {
+	if !IsCompressed(data) {
+		return data, nil
+	}
+
+	reader, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	decompressed, err := ioutil.ReadAll(reader)
+	if err == io.ErrUnexpectedEOF {
+		return decompressed, ErrTruncated
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error decompressing data: %v", err)
+	}
+	return decompressed, nil
+}
+
+//zstdReadCloser is a zstd.Decoder with io.Closer interface
+type zstdReadCloser struct {
+	*zstd.Decoder
+}
+
+func (zrc *zstdReadCloser) Close() error {
+	zrc.Decoder.Close()
+	return nil
+}
@@ -0,0 +1,41 @@
+package compression
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestDecompress(t *testing.T) {
+	payload := bytes.Repeat([]byte(`{"event_type": "pageview", "eventn_ctx": {"event_id": "e1"}}`+"\n"), 1000)
+
+	for _, compression := range []string{Gzip, Zstd} {
+		t.Run(compression, func(t *testing.T) {
+			compressed := &bytes.Buffer{}
+			writer, err := NewWriter(compression, compressed)
+			require.NoError(t, err)
+			_, err = writer.Write(payload)
+			require.NoError(t, err)
+			require.NoError(t, writer.Close())
+			require.True(t, IsCompressed(compressed.Bytes()))
+
+			decompressed, err := Decompress(compressed.Bytes())
+			require.NoError(t, err)
+			require.Equal(t, payload, decompressed)
+
+			//several rotated streams of one file
+			decompressed, err = Decompress(append(append([]byte{}, compressed.Bytes()...), compressed.Bytes()...))
+			require.NoError(t, err)
+			require.Equal(t, append(append([]byte{}, payload...), payload...), decompressed)
+
+			_, err = Decompress(compressed.Bytes()[:compressed.Len()-10])
+			require.Equal(t, ErrTruncated, err)
+		})
+	}
+
+	decompressed, err := Decompress(payload)
+	require.NoError(t, err)
+	require.Equal(t, payload, decompressed)
+
+	require.EqualError(t, Validate("lz4"), "Unknown compression: lz4. Available: [gzip, zstd]")
+}
@@ -0,0 +1,292 @@
+package configversions
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jitsucom/eventnative/enrichment"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/resources"
+	"github.com/jitsucom/eventnative/schema"
+	"github.com/jitsucom/eventnative/storages"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const fileExtension = ".versions"
+
+//Instance is a singleton config versions history. It is in-memory until Init is called
+var Instance = NewInMemory()
+
+//Processing is the part of destination configuration which defines how events are processed before they are stored:
+//mapping, table name template, enrichment rules, filter, sampling and events type. Connection settings aren't versioned
+type Processing struct {
+	DataLayout   *storages.DataLayout     `json:"data_layout,omitempty"`
+	Enrichment   []*enrichment.RuleConfig `json:"enrichment,omitempty"`
+	BreakOnError bool                     `json:"break_on_error,omitempty"`
+	Filter       string                   `json:"filter,omitempty"`
+	Sampling     *schema.Sampling         `json:"sampling,omitempty"`
+	Events       string                   `json:"events,omitempty"`
+}
+
+//Version is a destination processing configuration which has been active since ActiveFrom
+type Version struct {
+	Version    int        `json:"version"`
+	Hash       string     `json:"hash"`
+	ActiveFrom time.Time  `json:"active_from"`
+	Processing Processing `json:"processing"`
+}
+
+//Versions records destinations processing configuration changes: a new version is added when a destination is
+//(re)created with changed processing configuration. Versions are written to <dir>/<destination id>.versions files
+//(one json version per line)
+type Versions struct {
+	sync.RWMutex
+
+	dir          string
+	destinations map[string][]*Version
+}
+
+//Init open the persistent versions history and replace Instance
+func Init(dir string) error {
+	v, err := Open(dir)
+	if err != nil {
+		return err
+	}
+
+	Instance = v
+	return nil
+}
+
+//NewInMemory return versions history without persistence
+func NewInMemory() *Versions {
+	return &Versions{destinations: map[string][]*Version{}}
+}
+
+//Open create dir if doesn't exist and load all destinations versions files
+func Open(dir string) (*Versions, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("Error creating config versions dir [%s]: %v", dir, err)
+	}
+
+	v := NewInMemory()
+	v.dir = dir
+
+	files, err := filepath.Glob(path.Join(dir, "*"+fileExtension))
+	if err != nil {
+		return nil, fmt.Errorf("Error reading config versions dir [%s]: %v", dir, err)
+	}
+
+	for _, filePath := range files {
+		destinationId := strings.TrimSuffix(filepath.Base(filePath), fileExtension)
+		if err := v.load(destinationId, filePath); err != nil {
+			return nil, err
+		}
+	}
+
+	return v, nil
+}
+
+//ProcessingOf return processing part of the destination configuration
+func ProcessingOf(destination storages.DestinationConfig) Processing {
+	return Processing{
+		DataLayout:   destination.DataLayout,
+		Enrichment:   destination.Enrichment,
+		BreakOnError: destination.BreakOnError,
+		Filter:       destination.Filter,
+		Sampling:     destination.Sampling,
+		Events:       destination.Events,
+	}
+}
+
+//Apply return a copy of the destination configuration with the processing configuration
+func (p Processing) Apply(destination storages.DestinationConfig) storages.DestinationConfig {
+	destination.DataLayout = p.DataLayout
+	destination.Enrichment = p.Enrichment
+	destination.BreakOnError = p.BreakOnError
+	destination.Filter = p.Filter
+	destination.Sampling = p.Sampling
+	destination.Events = p.Events
+	return destination
+}
+
+func (p Processing) hash() (string, error) {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return "", fmt.Errorf("Error marshalling processing configuration: %v", err)
+	}
+	return resources.GetHash(b), nil
+}
+
+//Record add a new version if the destination processing configuration differs from the latest version
+//return the latest version and true if it has been added
+func (v *Versions) Record(destinationId string, destination storages.DestinationConfig) (*Version, bool) {
+	processing := ProcessingOf(destination)
+	hash, err := processing.hash()
+	if err != nil {
+		logging.SystemErrorf("[%s] %v", destinationId, err)
+		return nil, false
+	}
+
+	v.Lock()
+	defer v.Unlock()
+
+	versions := v.destinations[destinationId]
+	if len(versions) > 0 && versions[len(versions)-1].Hash == hash {
+		latest := *versions[len(versions)-1]
+		return &latest, false
+	}
+
+	version := &Version{
+		Version:    len(versions) + 1,
+		Hash:       hash,
+		ActiveFrom: time.Now().UTC(),
+		Processing: processing,
+	}
+	if len(versions) > 0 {
+		version.Version = versions[len(versions)-1].Version + 1
+	}
+	v.destinations[destinationId] = append(versions, version)
+	v.persist(destinationId, version)
+
+	versionCopy := *version
+	return &versionCopy, true
+}
+
+//Next return the version number which the destination processing configuration has or will have after Record
+//(the latest version number if the configuration isn't changed). Return 0 if the configuration can't be hashed
+func (v *Versions) Next(destinationId string, destination storages.DestinationConfig) int {
+	hash, err := ProcessingOf(destination).hash()
+	if err != nil {
+		logging.SystemErrorf("[%s] %v", destinationId, err)
+		return 0
+	}
+
+	v.RLock()
+	defer v.RUnlock()
+
+	versions := v.destinations[destinationId]
+	if len(versions) == 0 {
+		return 1
+	}
+	latest := versions[len(versions)-1]
+	if latest.Hash == hash {
+		return latest.Version
+	}
+	return latest.Version + 1
+}
+
+//AsOf return a copy of the version which was active at t. Return false if t is before the first recorded version
+func (v *Versions) AsOf(destinationId string, t time.Time) (*Version, bool) {
+	v.RLock()
+	defer v.RUnlock()
+
+	versions := v.destinations[destinationId]
+	i := sort.Search(len(versions), func(i int) bool { return versions[i].ActiveFrom.After(t) })
+	if i == 0 {
+		return nil, false
+	}
+
+	versionCopy := *versions[i-1]
+	return &versionCopy, true
+}
+
+//Latest return a copy of the currently active version
+func (v *Versions) Latest(destinationId string) (*Version, bool) {
+	v.RLock()
+	defer v.RUnlock()
+
+	versions := v.destinations[destinationId]
+	if len(versions) == 0 {
+		return nil, false
+	}
+
+	versionCopy := *versions[len(versions)-1]
+	return &versionCopy, true
+}
+
+//History return copies of destination versions (the newest first)
+func (v *Versions) History(destinationId string) []*Version {
+	v.RLock()
+	defer v.RUnlock()
+
+	versions := v.destinations[destinationId]
+	history := make([]*Version, 0, len(versions))
+	for i := len(versions) - 1; i >= 0; i-- {
+		versionCopy := *versions[i]
+		history = append(history, &versionCopy)
+	}
+	return history
+}
+
+//Destinations return sorted destination ids which have versions
+func (v *Versions) Destinations() []string {
+	v.RLock()
+	defer v.RUnlock()
+
+	ids := make([]string, 0, len(v.destinations))
+	for id := range v.destinations {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+//persist append version line to the destination versions file and fsync it
+func (v *Versions) persist(destinationId string, version *Version) {
+	if v.dir == "" {
+		return
+	}
+
+	b, err := json.Marshal(version)
+	if err != nil {
+		logging.SystemErrorf("[%s] Error marshalling config version: %v", destinationId, err)
+		return
+	}
+
+	file, err := os.OpenFile(path.Join(v.dir, destinationId+fileExtension), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		logging.SystemErrorf("[%s] Error opening config versions file: %v", destinationId, err)
+		return
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(b, '\n')); err != nil {
+		logging.SystemErrorf("[%s] Error writing config versions file: %v", destinationId, err)
+		return
+	}
+	if err := file.Sync(); err != nil {
+		logging.SystemErrorf("[%s] Error syncing config versions file: %v", destinationId, err)
+	}
+}
+
+func (v *Versions) load(destinationId, filePath string) error {
+	b, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("Error reading config versions file [%s]: %v", filePath, err)
+	}
+
+	var versions []*Version
+	for _, line := range strings.Split(string(b), "\n") {
+		if line == "" {
+			continue
+		}
+
+		version := &Version{}
+		if err := json.Unmarshal([]byte(line), version); err != nil {
+			//torn write of the last line
+			logging.Warnf("[%s] Skipping malformed config version line: %v", destinationId, err)
+			continue
+		}
+		versions = append(versions, version)
+	}
+
+	sort.SliceStable(versions, func(i, j int) bool { return versions[i].ActiveFrom.Before(versions[j].ActiveFrom) })
+	v.destinations[destinationId] = versions
+	return nil
+}
@@ -0,0 +1,66 @@
+package configversions
+
+import (
+	"github.com/jitsucom/eventnative/adapters"
+	"github.com/jitsucom/eventnative/storages"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestVersions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config_versions")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	v, err := Open(dir)
+	require.NoError(t, err)
+
+	destination := storages.DestinationConfig{Type: "postgres", DataLayout: &storages.DataLayout{TableNameTemplate: "events"}}
+	require.Equal(t, 1, v.Next("dst1", destination))
+	first, added := v.Record("dst1", destination)
+	require.True(t, added)
+	require.Equal(t, 1, first.Version)
+	require.Equal(t, 1, v.Next("dst1", destination))
+
+	//connection settings changes aren't versioned
+	destination.DataSource = &adapters.DataSourceConfig{Host: "new-host"}
+	_, added = v.Record("dst1", destination)
+	require.False(t, added)
+
+	time.Sleep(10 * time.Millisecond)
+	destination.DataLayout = &storages.DataLayout{TableNameTemplate: "events_v2"}
+	destination.Filter = `$.event_type == "pageview"`
+	require.Equal(t, 2, v.Next("dst1", destination))
+	second, added := v.Record("dst1", destination)
+	require.True(t, added)
+	require.Equal(t, 2, second.Version)
+
+	_, ok := v.AsOf("dst1", first.ActiveFrom.Add(-time.Second))
+	require.False(t, ok)
+	asOf, ok := v.AsOf("dst1", first.ActiveFrom)
+	require.True(t, ok)
+	require.Equal(t, "events", asOf.Processing.DataLayout.TableNameTemplate)
+	asOf, ok = v.AsOf("dst1", second.ActiveFrom.Add(time.Hour))
+	require.True(t, ok)
+	require.Equal(t, 2, asOf.Version)
+
+	applied := asOf.Processing.Apply(storages.DestinationConfig{Type: "postgres", Mode: storages.StreamMode})
+	require.Equal(t, "events_v2", applied.DataLayout.TableNameTemplate)
+	require.Equal(t, `$.event_type == "pageview"`, applied.Filter)
+	require.Equal(t, storages.StreamMode, applied.Mode)
+
+	//versions are restored from the file
+	reopened, err := Open(dir)
+	require.NoError(t, err)
+	require.Equal(t, []string{"dst1"}, reopened.Destinations())
+	history := reopened.History("dst1")
+	require.Len(t, history, 2)
+	require.Equal(t, 2, history[0].Version)
+	require.Equal(t, 1, history[1].Version)
+	latest, ok := reopened.Latest("dst1")
+	require.True(t, ok)
+	require.Equal(t, second.Hash, latest.Hash)
+}
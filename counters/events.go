@@ -3,39 +3,113 @@ package counters
 import (
 	"github.com/jitsucom/eventnative/logging"
 	"github.com/jitsucom/eventnative/meta"
+	"github.com/jitsucom/eventnative/reports"
+	"github.com/jitsucom/eventnative/safego"
+	"sync"
 	"time"
 )
 
+const flushInterval = time.Second
+
 var eventsInstance *Events
 
+//Events accumulate success and error events counters per destination in memory (lock-free in the hot path)
+//and flush them into meta storage every second
 type Events struct {
 	storage meta.Storage
+
+	//destination id -> *ShardedCounter
+	success sync.Map
+	errors  sync.Map
+
+	flushMutex sync.Mutex
+	closed     chan struct{}
+	closeOnce  sync.Once
 }
 
 func InitEvents(storage meta.Storage) {
-	eventsInstance = &Events{storage: storage}
+	eventsInstance = &Events{storage: storage, closed: make(chan struct{})}
+	eventsInstance.start()
 }
 
 func SuccessEvents(destinationId string, value int) {
+	reports.Instance.Delivered(destinationId, value)
+
 	if eventsInstance == nil {
 		logging.Warnf("Counters instance isn't configured!")
 		return
 	}
 
-	err := eventsInstance.storage.SuccessEvents(destinationId, time.Now().UTC(), value)
-	if err != nil {
-		logging.SystemErrorf("Error updating success events counter destination [%s] value [%d]: %v", destinationId, value, err)
-	}
+	counter(&eventsInstance.success, destinationId).Add(uint64(value))
 }
 
 func ErrorEvents(destinationId string, value int) {
+	reports.Instance.Failed(destinationId, value)
+
 	if eventsInstance == nil {
 		logging.Warnf("Counters instance isn't configured!")
 		return
 	}
 
-	err := eventsInstance.storage.ErrorEvents(destinationId, time.Now().UTC(), value)
-	if err != nil {
-		logging.SystemErrorf("Error updating error events counter destination [%s] value [%d]: %v", destinationId, value, err)
+	counter(&eventsInstance.errors, destinationId).Add(uint64(value))
+}
+
+//Flush write accumulated counters into meta storage and stop flushing goroutine.
+//It must be called on shutdown before meta storage closing
+func Flush() {
+	if eventsInstance == nil {
+		return
+	}
+
+	eventsInstance.closeOnce.Do(func() { close(eventsInstance.closed) })
+	eventsInstance.flush()
+}
+
+func counter(counters *sync.Map, destinationId string) *ShardedCounter {
+	if c, ok := counters.Load(destinationId); ok {
+		return c.(*ShardedCounter)
 	}
+	c, _ := counters.LoadOrStore(destinationId, NewShardedCounter())
+	return c.(*ShardedCounter)
+}
+
+func (e *Events) start() {
+	safego.RunWithRestart(func() {
+		ticker := time.NewTicker(flushInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-e.closed:
+				return
+			case <-ticker.C:
+				e.flush()
+			}
+		}
+	})
+}
+
+func (e *Events) flush() {
+	e.flushMutex.Lock()
+	defer e.flushMutex.Unlock()
+
+	now := time.Now().UTC()
+	e.success.Range(func(key, value interface{}) bool {
+		destinationId := key.(string)
+		if delta := value.(*ShardedCounter).Cut(); delta > 0 {
+			if err := e.storage.SuccessEvents(destinationId, now, int(delta)); err != nil {
+				logging.SystemErrorf("Error updating success events counter destination [%s] value [%d]: %v", destinationId, delta, err)
+			}
+		}
+		return true
+	})
+	e.errors.Range(func(key, value interface{}) bool {
+		destinationId := key.(string)
+		if delta := value.(*ShardedCounter).Cut(); delta > 0 {
+			if err := e.storage.ErrorEvents(destinationId, now, int(delta)); err != nil {
+				logging.SystemErrorf("Error updating error events counter destination [%s] value [%d]: %v", destinationId, delta, err)
+			}
+		}
+		return true
+	})
 }
@@ -0,0 +1,52 @@
+package counters
+
+import (
+	"github.com/jitsucom/eventnative/meta"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+//countingStorage sums flushed counters
+type countingStorage struct {
+	meta.Dummy
+
+	mutex   sync.Mutex
+	success map[string]int
+	errors  map[string]int
+}
+
+func (cs *countingStorage) SuccessEvents(destinationId string, now time.Time, value int) error {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+	cs.success[destinationId] += value
+	return nil
+}
+
+func (cs *countingStorage) ErrorEvents(destinationId string, now time.Time, value int) error {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+	cs.errors[destinationId] += value
+	return nil
+}
+
+func TestEventsFlush(t *testing.T) {
+	storage := &countingStorage{success: map[string]int{}, errors: map[string]int{}}
+	InitEvents(storage)
+	defer func() { eventsInstance = nil }()
+
+	SuccessEvents("pg", 3)
+	SuccessEvents("pg", 2)
+	ErrorEvents("pg", 1)
+	ErrorEvents("bq", 4)
+
+	//Flush writes all accumulated counters and stops the flushing goroutine (concurrently with it)
+	Flush()
+	Flush()
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+	require.Equal(t, map[string]int{"pg": 5}, storage.success)
+	require.Equal(t, map[string]int{"pg": 1, "bq": 4}, storage.errors)
+}
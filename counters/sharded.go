@@ -0,0 +1,62 @@
+package counters
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+//stripe is a counter value on its own cache line (no false sharing between stripes)
+type stripe struct {
+	value uint64
+	_     [56]byte
+}
+
+var (
+	nextHint uint32
+	//sync.Pool caches objects per P so goroutines running on the same P mostly get the same stripe hint
+	stripeHints = sync.Pool{New: func() interface{} {
+		hint := atomic.AddUint32(&nextHint, 1)
+		return &hint
+	}}
+)
+
+//ShardedCounter is a lock-free counter which is split into GOMAXPROCS (power of 2) stripes.
+//Concurrent Add calls from different CPUs update different cache lines. Value and Cut sum all stripes
+type ShardedCounter struct {
+	stripes []stripe
+	mask    uint32
+}
+
+func NewShardedCounter() *ShardedCounter {
+	size := 1
+	for size < runtime.GOMAXPROCS(0) {
+		size <<= 1
+	}
+	return &ShardedCounter{stripes: make([]stripe, size), mask: uint32(size - 1)}
+}
+
+//Add increment the counter
+func (sc *ShardedCounter) Add(delta uint64) {
+	hint := stripeHints.Get().(*uint32)
+	atomic.AddUint64(&sc.stripes[*hint&sc.mask].value, delta)
+	stripeHints.Put(hint)
+}
+
+//Value return current counter value
+func (sc *ShardedCounter) Value() uint64 {
+	var sum uint64
+	for i := range sc.stripes {
+		sum += atomic.LoadUint64(&sc.stripes[i].value)
+	}
+	return sum
+}
+
+//Cut return current value and set it to 0. Concurrent increments aren't lost: they are returned by the next Cut
+func (sc *ShardedCounter) Cut() uint64 {
+	var sum uint64
+	for i := range sc.stripes {
+		sum += atomic.SwapUint64(&sc.stripes[i].value, 0)
+	}
+	return sum
+}
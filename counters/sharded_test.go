@@ -0,0 +1,30 @@
+package counters
+
+import (
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+)
+
+func TestShardedCounter(t *testing.T) {
+	counter := NewShardedCounter()
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				counter.Add(2)
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, uint64(32000), counter.Value())
+	require.Equal(t, uint64(32000), counter.Cut())
+	require.Equal(t, uint64(0), counter.Value())
+
+	counter.Add(5)
+	require.Equal(t, uint64(5), counter.Cut())
+}
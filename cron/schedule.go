@@ -0,0 +1,236 @@
+package cron
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const everyPrefix = "@every "
+
+//descriptors are predefined schedules
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var monthNames = map[string]int{"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6, "jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12}
+var weekdayNames = map[string]int{"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6}
+
+//field is a cron expression field bounds
+type field struct {
+	name  string
+	min   int
+	max   int
+	names map[string]int
+}
+
+var (
+	minuteField  = field{name: "minute", min: 0, max: 59}
+	hourField    = field{name: "hour", min: 0, max: 23}
+	dayField     = field{name: "day of month", min: 1, max: 31}
+	monthField   = field{name: "month", min: 1, max: 12, names: monthNames}
+	weekdayField = field{name: "day of week", min: 0, max: 7, names: weekdayNames}
+)
+
+//Schedule is a parsed cron expression. Times are in UTC
+//Standard 5 fields format: minute hour day-of-month month day-of-week with *, lists (1,15), ranges (1-5), steps (*/15, 0-30/10)
+//and month/day names (jan, mon). Day of week 0 and 7 are Sunday. If both day fields are restricted, either matches
+//Predefined schedules: @yearly (@annually), @monthly, @weekly, @daily (@midnight), @hourly and @every <duration> (e.g. @every 90s)
+type Schedule struct {
+	expression string
+
+	minutes  uint64
+	hours    uint64
+	days     uint64
+	months   uint64
+	weekdays uint64
+	//true if day of month (day of week) field is *
+	anyDay     bool
+	anyWeekday bool
+
+	//not 0 if expression is @every <duration>
+	every time.Duration
+}
+
+//Parse return parsed cron expression or error if it is malformed
+func Parse(expression string) (*Schedule, error) {
+	expression = strings.TrimSpace(expression)
+	if expression == "" {
+		return nil, errors.New("Cron expression is empty")
+	}
+
+	if strings.HasPrefix(expression, everyPrefix) {
+		every, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(expression, everyPrefix)))
+		if err != nil {
+			return nil, fmt.Errorf("Malformed cron expression [%s]: %v", expression, err)
+		}
+		if every < time.Second {
+			return nil, fmt.Errorf("Malformed cron expression [%s]: duration must be at least 1s", expression)
+		}
+		return &Schedule{expression: expression, every: every}, nil
+	}
+
+	standard := expression
+	if strings.HasPrefix(expression, "@") {
+		descriptor, ok := descriptors[strings.ToLower(expression)]
+		if !ok {
+			return nil, fmt.Errorf("Unknown cron descriptor: %s", expression)
+		}
+		standard = descriptor
+	}
+
+	fields := strings.Fields(standard)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("Malformed cron expression [%s]: expected 5 fields (minute hour day-of-month month day-of-week), got %d", expression, len(fields))
+	}
+
+	s := &Schedule{expression: expression, anyDay: fields[2] == "*", anyWeekday: fields[4] == "*"}
+	var err error
+	for i, parse := range []struct {
+		field  field
+		target *uint64
+	}{
+		{minuteField, &s.minutes},
+		{hourField, &s.hours},
+		{dayField, &s.days},
+		{monthField, &s.months},
+		{weekdayField, &s.weekdays},
+	} {
+		*parse.target, err = parseField(fields[i], parse.field)
+		if err != nil {
+			return nil, fmt.Errorf("Malformed cron expression [%s]: %v", expression, err)
+		}
+	}
+
+	//7 is Sunday too
+	if s.weekdays&(1<<7) != 0 {
+		s.weekdays |= 1
+	}
+
+	return s, nil
+}
+
+//parseField return bitset of field values
+func parseField(value string, f field) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(value, ",") {
+		rangeValue := part
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			parsedStep, err := strconv.Atoi(part[i+1:])
+			if err != nil || parsedStep <= 0 {
+				return 0, fmt.Errorf("%s step must be a positive number: %s", f.name, part)
+			}
+			step = parsedStep
+			rangeValue = part[:i]
+		}
+
+		var start, end int
+		switch {
+		case rangeValue == "*":
+			start, end = f.min, f.max
+		case strings.Contains(rangeValue, "-"):
+			bounds := strings.SplitN(rangeValue, "-", 2)
+			var err error
+			if start, err = f.parseValue(bounds[0]); err != nil {
+				return 0, err
+			}
+			if end, err = f.parseValue(bounds[1]); err != nil {
+				return 0, err
+			}
+			if start > end {
+				return 0, fmt.Errorf("%s range start is greater than end: %s", f.name, part)
+			}
+		default:
+			var err error
+			if start, err = f.parseValue(rangeValue); err != nil {
+				return 0, err
+			}
+			end = start
+			//a/n means from a to max with step n
+			if step > 1 {
+				end = f.max
+			}
+		}
+
+		for v := start; v <= end; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, nil
+}
+
+func (f field) parseValue(value string) (int, error) {
+	if number, ok := f.names[strings.ToLower(value)]; ok {
+		return number, nil
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s value isn't a number: %s", f.name, value)
+	}
+	if number < f.min || number > f.max {
+		return 0, fmt.Errorf("%s value %d is out of [%d, %d] range", f.name, number, f.min, f.max)
+	}
+	return number, nil
+}
+
+//Next return the first schedule time after t (with minute precision, @every schedules return t + duration)
+//return zero time if there is no such time in 5 years (e.g. 0 0 30 2 *)
+func (s *Schedule) Next(t time.Time) time.Time {
+	if s.every > 0 {
+		return t.Add(s.every)
+	}
+
+	t = t.UTC().Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if s.months&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, 1, 0)
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, 1)
+			continue
+		}
+		if s.hours&(1<<uint(t.Hour())) == 0 {
+			t = t.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+		if s.minutes&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+
+	return time.Time{}
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	dayMatched := s.days&(1<<uint(t.Day())) != 0
+	weekdayMatched := s.weekdays&(1<<uint(t.Weekday())) != 0
+	switch {
+	case s.anyDay && s.anyWeekday:
+		return true
+	case s.anyDay:
+		return weekdayMatched
+	case s.anyWeekday:
+		return dayMatched
+	default:
+		return dayMatched || weekdayMatched
+	}
+}
+
+func (s *Schedule) String() string {
+	return s.expression
+}
@@ -0,0 +1,62 @@
+package cron
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestNext(t *testing.T) {
+	//Thursday
+	from := time.Date(2020, 10, 1, 10, 7, 30, 0, time.UTC)
+	tests := []struct {
+		expression string
+		expected   time.Time
+	}{
+		{"* * * * *", time.Date(2020, 10, 1, 10, 8, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2020, 10, 1, 10, 15, 0, 0, time.UTC)},
+		{"5,10 * * * *", time.Date(2020, 10, 1, 10, 10, 0, 0, time.UTC)},
+		{"0 9-17/4 * * *", time.Date(2020, 10, 1, 13, 0, 0, 0, time.UTC)},
+		{"30 2 * * *", time.Date(2020, 10, 2, 2, 30, 0, 0, time.UTC)},
+		{"0 0 * * mon", time.Date(2020, 10, 5, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2020, 10, 4, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 jan *", time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		//either restricted day field matches
+		{"0 0 15 * 5", time.Date(2020, 10, 2, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2020, 10, 1, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2020, 10, 2, 0, 0, 0, 0, time.UTC)},
+		{"@every 90s", time.Date(2020, 10, 1, 10, 9, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			schedule, err := Parse(tt.expression)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, schedule.Next(from))
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"*/0 * * * *",
+		"10-5 * * * *",
+		"* * * * abc",
+		"@sometimes",
+		"@every 10ms",
+		"@every abc",
+	}
+	for _, expression := range tests {
+		t.Run(expression, func(t *testing.T) {
+			_, err := Parse(expression)
+			require.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package declarative
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jitsucom/eventnative/authorization"
+	"github.com/jitsucom/eventnative/storages"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+//Config is a canonical declarative configuration: tokens sorted by id (with filled ids) and destinations by name
+//nil Tokens or Destinations in desired state mean 'not managed': they aren't changed
+type Config struct {
+	Tokens       []authorization.Token                 `json:"tokens"`
+	Destinations map[string]storages.DestinationConfig `json:"destinations"`
+}
+
+//Changes are ids (names) of created, updated and deleted objects
+type Changes struct {
+	Create []string `json:"create"`
+	Update []string `json:"update"`
+	Delete []string `json:"delete"`
+}
+
+func (c *Changes) Empty() bool {
+	return len(c.Create) == 0 && len(c.Update) == 0 && len(c.Delete) == 0
+}
+
+//Plan is a diff between the current and the desired configuration
+type Plan struct {
+	Tokens       *Changes `json:"tokens,omitempty"`
+	Destinations *Changes `json:"destinations,omitempty"`
+	Warnings     []string `json:"warnings,omitempty"`
+
+	//true if the plan has been applied
+	Applied bool `json:"applied"`
+	//true if applied changes have been written to file:// sources. Otherwise they are kept until restart
+	Persisted bool `json:"persisted"`
+}
+
+//Canonical return a copy of the config with filled token ids and tokens sorted by id
+func Canonical(config *Config) *Config {
+	result := &Config{Destinations: config.Destinations}
+	if config.Tokens != nil {
+		result.Tokens = make([]authorization.Token, 0, len(config.Tokens))
+		for _, token := range config.Tokens {
+			token.Id = authorization.GetTokenId(token)
+			result.Tokens = append(result.Tokens, token)
+		}
+		sort.Slice(result.Tokens, func(i, j int) bool { return result.Tokens[i].Id < result.Tokens[j].Id })
+	}
+	return result
+}
+
+//Validate return error if the canonical desired config is malformed and warnings about dangling references
+func Validate(desired *Config) ([]string, error) {
+	secrets := map[string]string{}
+	for i, token := range desired.Tokens {
+		if i > 0 && desired.Tokens[i-1].Id == token.Id {
+			return nil, fmt.Errorf("Token id [%s] is duplicated", token.Id)
+		}
+		if strings.TrimSpace(token.ClientSecret) == "" && strings.TrimSpace(token.ServerSecret) == "" {
+			return nil, fmt.Errorf("Token [%s] must have client_secret or server_secret", token.Id)
+		}
+		for _, secret := range []string{strings.TrimSpace(token.ClientSecret), strings.TrimSpace(token.ServerSecret)} {
+			if secret == "" {
+				continue
+			}
+			if owner, ok := secrets[secret]; ok && owner != token.Id {
+				return nil, fmt.Errorf("Tokens [%s] and [%s] have the same secret", owner, token.Id)
+			}
+			secrets[secret] = token.Id
+		}
+	}
+
+	for name, destination := range desired.Destinations {
+		if strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("Destination name can't be empty")
+		}
+		if destination.Mode != "" && destination.Mode != storages.BatchMode && destination.Mode != storages.StreamMode {
+			return nil, fmt.Errorf("Destination [%s] has unknown mode: %s. Available mode: [%s, %s]", name, destination.Mode, storages.BatchMode, storages.StreamMode)
+		}
+	}
+
+	var warnings []string
+	if desired.Tokens != nil && desired.Destinations != nil {
+		for _, token := range desired.Tokens {
+			for _, name := range token.Destinations {
+				if _, ok := desired.Destinations[name]; !ok {
+					warnings = append(warnings, fmt.Sprintf("Token [%s] is routed to unknown destination [%s]", token.Id, name))
+				}
+			}
+		}
+		for _, name := range sortedNames(desired.Destinations) {
+			for _, identity := range desired.Destinations[name].OnlyTokens {
+				if _, ok := secrets[identity]; !ok && !hasToken(desired.Tokens, identity) {
+					warnings = append(warnings, fmt.Sprintf("Destination [%s] has unknown token in only_tokens: %s", name, identity))
+				}
+			}
+		}
+	}
+
+	return warnings, nil
+}
+
+//Diff return plan of changes from the current to the desired canonical config
+func Diff(current, desired *Config) *Plan {
+	plan := &Plan{}
+
+	if desired.Tokens != nil {
+		currentTokens := map[string]interface{}{}
+		for _, token := range current.Tokens {
+			currentTokens[token.Id] = token
+		}
+		desiredTokens := map[string]interface{}{}
+		for _, token := range desired.Tokens {
+			desiredTokens[token.Id] = token
+		}
+		plan.Tokens = diff(currentTokens, desiredTokens)
+	}
+
+	if desired.Destinations != nil {
+		currentDestinations := map[string]interface{}{}
+		for name, destination := range current.Destinations {
+			currentDestinations[name] = destination
+		}
+		desiredDestinations := map[string]interface{}{}
+		for name, destination := range desired.Destinations {
+			desiredDestinations[name] = destination
+		}
+		plan.Destinations = diff(currentDestinations, desiredDestinations)
+	}
+
+	return plan
+}
+
+//diff compare objects by json representation
+func diff(current, desired map[string]interface{}) *Changes {
+	changes := &Changes{Create: []string{}, Update: []string{}, Delete: []string{}}
+	for id, desiredObj := range desired {
+		currentObj, ok := current[id]
+		if !ok {
+			changes.Create = append(changes.Create, id)
+			continue
+		}
+
+		if !jsonEqual(currentObj, desiredObj) {
+			changes.Update = append(changes.Update, id)
+		}
+	}
+	for id := range current {
+		if _, ok := desired[id]; !ok {
+			changes.Delete = append(changes.Delete, id)
+		}
+	}
+
+	sort.Strings(changes.Create)
+	sort.Strings(changes.Update)
+	sort.Strings(changes.Delete)
+	return changes
+}
+
+func jsonEqual(a, b interface{}) bool {
+	aBytes, aErr := json.Marshal(a)
+	bBytes, bErr := json.Marshal(b)
+	if aErr != nil || bErr != nil {
+		return reflect.DeepEqual(a, b)
+	}
+
+	var aObj, bObj interface{}
+	if json.Unmarshal(aBytes, &aObj) != nil || json.Unmarshal(bBytes, &bObj) != nil {
+		return string(aBytes) == string(bBytes)
+	}
+	return reflect.DeepEqual(aObj, bObj)
+}
+
+func hasToken(tokens []authorization.Token, id string) bool {
+	for _, token := range tokens {
+		if token.Id == id {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedNames(destinations map[string]storages.DestinationConfig) []string {
+	names := make([]string, 0, len(destinations))
+	for name := range destinations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
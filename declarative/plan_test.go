@@ -0,0 +1,156 @@
+package declarative
+
+import (
+	"github.com/jitsucom/eventnative/authorization"
+	"github.com/jitsucom/eventnative/storages"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	current := Canonical(&Config{
+		Tokens: []authorization.Token{
+			{Id: "id1", ClientSecret: "token1"},
+			{Id: "id2", ClientSecret: "token2", Origins: []string{"abc.com"}},
+			{Id: "id3", ServerSecret: "s2s3"},
+		},
+		Destinations: map[string]storages.DestinationConfig{
+			"pg":       {Type: "postgres", OnlyTokens: []string{"token1"}},
+			"bigquery": {Type: "bigquery"},
+		},
+	})
+
+	tests := []struct {
+		name     string
+		desired  *Config
+		expected *Plan
+	}{
+		{
+			"unmanaged",
+			&Config{},
+			&Plan{},
+		},
+		{
+			"no changes",
+			&Config{
+				Tokens: []authorization.Token{
+					{Id: "id3", ServerSecret: "s2s3"},
+					{Id: "id2", ClientSecret: "token2", Origins: []string{"abc.com"}},
+					{Id: "id1", ClientSecret: "token1"},
+				},
+				Destinations: map[string]storages.DestinationConfig{
+					"bigquery": {Type: "bigquery"},
+					"pg":       {Type: "postgres", OnlyTokens: []string{"token1"}},
+				},
+			},
+			&Plan{
+				Tokens:       &Changes{Create: []string{}, Update: []string{}, Delete: []string{}},
+				Destinations: &Changes{Create: []string{}, Update: []string{}, Delete: []string{}},
+			},
+		},
+		{
+			"only destinations",
+			&Config{
+				Destinations: map[string]storages.DestinationConfig{
+					"pg":         {Type: "postgres", OnlyTokens: []string{"token1", "token2"}},
+					"clickhouse": {Type: "clickhouse"},
+				},
+			},
+			&Plan{
+				Destinations: &Changes{Create: []string{"clickhouse"}, Update: []string{"pg"}, Delete: []string{"bigquery"}},
+			},
+		},
+		{
+			"tokens changes",
+			&Config{
+				Tokens: []authorization.Token{
+					{Id: "id2", ClientSecret: "token2"},
+					{Id: "id3", ServerSecret: "s2s3"},
+					{ClientSecret: "new"},
+				},
+			},
+			&Plan{
+				Tokens: &Changes{Create: []string{authorization.GetTokenId(authorization.Token{ClientSecret: "new"})}, Update: []string{"id2"}, Delete: []string{"id1"}},
+			},
+		},
+		{
+			"delete all",
+			&Config{
+				Tokens:       []authorization.Token{},
+				Destinations: map[string]storages.DestinationConfig{},
+			},
+			&Plan{
+				Tokens:       &Changes{Create: []string{}, Update: []string{}, Delete: []string{"id1", "id2", "id3"}},
+				Destinations: &Changes{Create: []string{}, Update: []string{}, Delete: []string{"bigquery", "pg"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, Diff(current, Canonical(tt.desired)))
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name             string
+		desired          *Config
+		expectedWarnings []string
+		expectedErr      string
+	}{
+		{
+			"valid",
+			&Config{
+				Tokens:       []authorization.Token{{Id: "id1", ClientSecret: "token1", Destinations: []string{"pg"}}},
+				Destinations: map[string]storages.DestinationConfig{"pg": {Type: "postgres", Mode: "stream", OnlyTokens: []string{"token1"}}},
+			},
+			nil,
+			"",
+		},
+		{
+			"dangling references",
+			&Config{
+				Tokens:       []authorization.Token{{Id: "id1", ClientSecret: "token1", Destinations: []string{"s3"}}},
+				Destinations: map[string]storages.DestinationConfig{"pg": {Type: "postgres", OnlyTokens: []string{"id1", "unknown"}}},
+			},
+			[]string{"Token [id1] is routed to unknown destination [s3]", "Destination [pg] has unknown token in only_tokens: unknown"},
+			"",
+		},
+		{
+			"duplicated ids",
+			&Config{Tokens: []authorization.Token{{Id: "id1", ClientSecret: "token1"}, {Id: "id1", ClientSecret: "token2"}}},
+			nil,
+			"Token id [id1] is duplicated",
+		},
+		{
+			"empty secrets",
+			&Config{Tokens: []authorization.Token{{Id: "id1"}}},
+			nil,
+			"Token [id1] must have client_secret or server_secret",
+		},
+		{
+			"shared secret",
+			&Config{Tokens: []authorization.Token{{Id: "id1", ClientSecret: "token1"}, {Id: "id2", ServerSecret: "token1"}}},
+			nil,
+			"Tokens [id1] and [id2] have the same secret",
+		},
+		{
+			"unknown mode",
+			&Config{Destinations: map[string]storages.DestinationConfig{"pg": {Type: "postgres", Mode: "realtime"}}},
+			nil,
+			"Destination [pg] has unknown mode: realtime. Available mode: [batch, stream]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnings, err := Validate(Canonical(tt.desired))
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedWarnings, warnings)
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package declarative
+
+import (
+	"fmt"
+	"github.com/jitsucom/eventnative/authorization"
+	"github.com/jitsucom/eventnative/destinations"
+	"github.com/jitsucom/eventnative/logging"
+	"sync"
+)
+
+//Service is a declarative configuration service: export the effective configuration and apply a desired state
+type Service struct {
+	sync.Mutex
+
+	authService        *authorization.Service
+	destinationService *destinations.Service
+}
+
+func NewService(authService *authorization.Service, destinationService *destinations.Service) *Service {
+	return &Service{authService: authService, destinationService: destinationService}
+}
+
+//Export return the current configuration in canonical form. Workspaces tokens and destinations aren't included
+func (s *Service) Export() *Config {
+	tokens := []authorization.Token{}
+	for _, token := range s.authService.GetTokens() {
+		if token.Workspace == "" {
+			tokens = append(tokens, token)
+		}
+	}
+	return Canonical(&Config{Tokens: tokens, Destinations: s.destinationService.GetConfig()})
+}
+
+//Plan return diff between the current configuration and the desired state without applying
+func (s *Service) Plan(desired *Config) (*Plan, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	_, plan, err := s.plan(desired)
+	return plan, err
+}
+
+//Apply replace tokens and (or) destinations with the desired state and return applied plan
+//Only changed parts are applied. Tokens are applied first because destinations might reference them
+func (s *Service) Apply(desired *Config) (*Plan, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	canonical, plan, err := s.plan(desired)
+	if err != nil {
+		return nil, err
+	}
+
+	persisted := true
+	if plan.Tokens != nil && !plan.Tokens.Empty() {
+		tokensPersisted, err := s.authService.SetTokens(canonical.Tokens)
+		if err != nil {
+			return nil, fmt.Errorf("Error applying tokens: %v", err)
+		}
+		persisted = persisted && tokensPersisted
+		logging.Infof("[declarative] Tokens have been applied: %+v", *plan.Tokens)
+	}
+
+	if plan.Destinations != nil && !plan.Destinations.Empty() {
+		destinationsPersisted, err := s.destinationService.SetConfig(canonical.Destinations)
+		if err != nil {
+			return nil, fmt.Errorf("Error applying destinations: %v", err)
+		}
+		persisted = persisted && destinationsPersisted
+		logging.Infof("[declarative] Destinations have been applied: %+v", *plan.Destinations)
+	}
+
+	plan.Applied = true
+	plan.Persisted = persisted
+	if !persisted {
+		plan.Warnings = append(plan.Warnings, "Changes are applied in memory only and will be lost after restart. Use file:// sources for persisting them")
+	}
+	return plan, nil
+}
+
+func (s *Service) plan(desired *Config) (*Config, *Plan, error) {
+	canonical := Canonical(desired)
+	current := s.Export()
+
+	//unmanaged parts are validated as current ones for checking references
+	validated := &Config{Tokens: canonical.Tokens, Destinations: canonical.Destinations}
+	if validated.Tokens == nil {
+		validated.Tokens = current.Tokens
+	}
+	if validated.Destinations == nil {
+		validated.Destinations = current.Destinations
+	}
+	warnings, err := Validate(validated)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	plan := Diff(current, canonical)
+	plan.Warnings = warnings
+	return canonical, plan, nil
+}
@@ -2,9 +2,12 @@ package destinations
 
 import (
 	"encoding/json"
+	"errors"
 	"github.com/google/martian/log"
 	"github.com/jitsucom/eventnative/resources"
 	"github.com/jitsucom/eventnative/storages"
+	"github.com/spf13/viper"
+	"strings"
 )
 
 type Payload struct {
@@ -21,6 +24,41 @@ func parseFromBytes(b []byte) (map[string]storages.DestinationConfig, error) {
 	return payload.Destinations, nil
 }
 
+//LoadConfig return destinations configuration from viper or from the source (http(s) url, file:// path or JSON string)
+//the same way as NewService does it once (without reloading)
+func LoadConfig(destinations *viper.Viper, destinationsSource string) (map[string]storages.DestinationConfig, error) {
+	if destinations != nil {
+		dc := map[string]storages.DestinationConfig{}
+		if err := destinations.Unmarshal(&dc); err != nil {
+			return nil, errors.New(marshallingErrorMsg + " " + err.Error())
+		}
+		return dc, nil
+	}
+
+	var payload []byte
+	var err error
+	if strings.HasPrefix(destinationsSource, "http://") || strings.HasPrefix(destinationsSource, "https://") {
+		payload, _, err = resources.LoadFromHttp(destinationsSource, "")
+	} else if strings.Contains(destinationsSource, "file://") {
+		payload, _, err = resources.LoadFromFile(strings.Replace(destinationsSource, "file://", "", 1), "")
+	} else if strings.HasPrefix(destinationsSource, "{") && strings.HasSuffix(destinationsSource, "}") {
+		payload = []byte(destinationsSource)
+	} else if destinationsSource != "" {
+		return nil, errors.New("Unknown destination source: " + destinationsSource)
+	} else {
+		return map[string]storages.DestinationConfig{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	dc, err := parseFromBytes(payload)
+	if err != nil {
+		return nil, errors.New(marshallingErrorMsg + " " + err.Error())
+	}
+	return dc, nil
+}
+
 func getHash(name string, destination storages.DestinationConfig) string {
 	b, err := json.Marshal(destination)
 	if err != nil {
@@ -0,0 +1,40 @@
+package destinations
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/jitsucom/eventnative/secrets"
+	"github.com/jitsucom/eventnative/storages"
+)
+
+//ResolveSecrets return the destination with resolved secrets references (e.g. datasource password: vault://secret/data/pg#password).
+//References are resolved in a copy: the applied config (GetConfig, declarative configuration API) keeps references
+//and resolved values are used only for storages creation and configs hashes (rotated secrets recreate destinations)
+func ResolveSecrets(destination storages.DestinationConfig) (storages.DestinationConfig, error) {
+	b, err := json.Marshal(destination)
+	if err != nil {
+		return destination, fmt.Errorf("Error marshalling destination: %v", err)
+	}
+	if !containsReferences(b) {
+		return destination, nil
+	}
+
+	resolved := storages.DestinationConfig{}
+	if err := json.Unmarshal(b, &resolved); err != nil {
+		return destination, fmt.Errorf("Error copying destination: %v", err)
+	}
+	if err := secrets.ResolveAll(&resolved); err != nil {
+		return destination, err
+	}
+	return resolved, nil
+}
+
+func containsReferences(b []byte) bool {
+	for _, scheme := range secrets.Schemes() {
+		if bytes.Contains(b, []byte(`"`+scheme+"://")) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+package destinations
+
+import (
+	"github.com/jitsucom/eventnative/adapters"
+	"github.com/jitsucom/eventnative/storages"
+	"github.com/stretchr/testify/require"
+	"os"
+	"testing"
+)
+
+func TestResolveSecrets(t *testing.T) {
+	require.NoError(t, os.Setenv("DESTINATIONS_TEST_PASSWORD", "first"))
+	defer os.Unsetenv("DESTINATIONS_TEST_PASSWORD")
+
+	destination := storages.DestinationConfig{
+		Type:       "postgres",
+		DataSource: &adapters.DataSourceConfig{Host: "pg.internal", Password: "env://DESTINATIONS_TEST_PASSWORD"},
+	}
+
+	resolved, err := ResolveSecrets(destination)
+	require.NoError(t, err)
+	require.Equal(t, "first", resolved.DataSource.Password)
+	require.Equal(t, "env://DESTINATIONS_TEST_PASSWORD", destination.DataSource.Password, "Original config must keep the reference")
+	firstHash := getHash("pg", resolved)
+
+	//rotation
+	require.NoError(t, os.Setenv("DESTINATIONS_TEST_PASSWORD", "second"))
+	rotated, err := ResolveSecrets(destination)
+	require.NoError(t, err)
+	require.Equal(t, "second", rotated.DataSource.Password)
+	require.NotEqual(t, firstHash, getHash("pg", rotated), "Rotated secret must change the destination hash")
+
+	require.NoError(t, os.Unsetenv("DESTINATIONS_TEST_PASSWORD"))
+	_, err = ResolveSecrets(destination)
+	require.Error(t, err)
+
+	//without references
+	plain := storages.DestinationConfig{Type: "postgres", DataSource: &adapters.DataSourceConfig{Password: "plain"}}
+	resolved, err = ResolveSecrets(plain)
+	require.NoError(t, err)
+	require.Equal(t, plain, resolved)
+}
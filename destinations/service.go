@@ -2,23 +2,37 @@ package destinations
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/hashicorp/go-multierror"
 	"github.com/jitsucom/eventnative/appconfig"
 	"github.com/jitsucom/eventnative/caching"
+	"github.com/jitsucom/eventnative/clustering"
+	"github.com/jitsucom/eventnative/configversions"
 	"github.com/jitsucom/eventnative/events"
 	"github.com/jitsucom/eventnative/logging"
 	"github.com/jitsucom/eventnative/resources"
+	"github.com/jitsucom/eventnative/retry"
+	"github.com/jitsucom/eventnative/safego"
 	"github.com/jitsucom/eventnative/storages"
 	"github.com/spf13/viper"
 	"io"
+	"path"
 	"strings"
 	"sync"
 	"time"
 )
 
-const serviceName = "destinations"
+const (
+	serviceName = "destinations"
+	//fallback events of config versions storages are written into the subdir: the same file isn't written by two loggers
+	versionsFallbackDir = "versions"
+)
+
+//destinationsLogger writes destinations component log lines (see logging.Configure levels)
+var destinationsLogger = logging.Component(logging.DestinationsComponent)
+
 const marshallingErrorMsg = `Error initializing destinations: wrong config format: each destination must contains one key and config as a value(see https://docs.eventnative.dev/configuration) e.g. 
 destinations:  
   custom_name:
@@ -49,6 +63,15 @@ type Service struct {
 	//map for holding all loggers for closing
 	loggersUsageByTokenId map[string]*LoggerUsage
 
+	//the last applied destinations config (before tokens resolving)
+	config map[string]storages.DestinationConfig
+	//workspaces destinations are added to configured ones on every init
+	workspaceConfig map[string]storages.DestinationConfig
+	//http(s):// or file:// source if destinations are reloaded from it
+	source string
+	//init can be called from reloading goroutines and declarative configuration API
+	initLock sync.Mutex
+
 	sync.RWMutex
 	consumersByTokenId      TokenizedConsumers
 	storagesByTokenId       TokenizedStorages
@@ -65,8 +88,8 @@ func NewTestService(consumersByTokenId TokenizedConsumers, storagesByTokenId Tok
 }
 
 //NewService return loaded Service instance and call resources.Watcher() if destinations source is http url or file path
-func NewService(ctx context.Context, destinations *viper.Viper, destinationsSource, logEventPath,
-	logFallbackPath string, logRotationMin int64, monitorKeeper storages.MonitorKeeper, queryWriter io.Writer, eventsCache *caching.EventsCache,
+func NewService(ctx context.Context, destinations *viper.Viper, destinationsSource string, workspaceDestinations map[string]storages.DestinationConfig,
+	logEventPath, logFallbackPath string, logRotationMin int64, monitorKeeper storages.MonitorKeeper, queryWriter io.Writer, eventsCache *caching.EventsCache,
 	storageFactoryMethod func(ctx context.Context, name, logEventPath, logFallbackPath string, logRotationMin int64,
 		destination storages.DestinationConfig, monitorKeeper storages.MonitorKeeper, queryWriter io.Writer, eventsCache *caching.EventsCache) (events.StorageProxy, *events.PersistentQueue, error)) (*Service, error) {
 	service := &Service{
@@ -78,6 +101,7 @@ func NewService(ctx context.Context, destinations *viper.Viper, destinationsSour
 		monitorKeeper:        monitorKeeper,
 		queryWriter:          queryWriter,
 		eventsCache:          eventsCache,
+		workspaceConfig:      workspaceDestinations,
 
 		unitsByName:           map[string]*Unit{},
 		loggersUsageByTokenId: map[string]*LoggerUsage{},
@@ -95,26 +119,40 @@ func NewService(ctx context.Context, destinations *viper.Viper, destinationsSour
 	if destinations != nil {
 		dc := map[string]storages.DestinationConfig{}
 		if err := destinations.Unmarshal(&dc); err != nil {
-			logging.Error(marshallingErrorMsg, err)
+			destinationsLogger.Errorf("%s %v", marshallingErrorMsg, err)
 			return service, nil
 		}
 
 		service.init(dc)
 
 		if len(service.unitsByName) == 0 {
-			logging.Errorf("Destinations are empty")
+			destinationsLogger.Errorf("Destinations are empty")
 		}
 
 	} else if destinationsSource != "" {
 		if strings.HasPrefix(destinationsSource, "http://") || strings.HasPrefix(destinationsSource, "https://") {
+			service.source = destinationsSource
 			appconfig.Instance.AuthorizationService.DestinationsForceReload = resources.Watch(serviceName, destinationsSource, resources.LoadFromHttp, service.updateDestinations, time.Duration(reloadSec)*time.Second)
 		} else if strings.Contains(destinationsSource, "file://") {
+			service.source = destinationsSource
 			appconfig.Instance.AuthorizationService.DestinationsForceReload = resources.Watch(serviceName, strings.Replace(destinationsSource, "file://", "", 1), resources.LoadFromFile, service.updateDestinations, time.Duration(reloadSec)*time.Second)
 		} else if strings.HasPrefix(destinationsSource, "{") && strings.HasSuffix(destinationsSource, "}") {
 			service.updateDestinations([]byte(destinationsSource))
 		} else {
 			return nil, errors.New("Unknown destination source: " + destinationsSource)
 		}
+	} else if len(workspaceDestinations) > 0 {
+		service.init(map[string]storages.DestinationConfig{})
+	}
+
+	//secrets references are resolved again periodically: rotated secrets recreate destinations
+	if refreshSec := viper.GetInt("server.secrets.refresh_sec"); refreshSec > 0 {
+		service.startSecretsRefreshing(time.Duration(refreshSec) * time.Second)
+	}
+
+	//not reloadable destinations: tokens reloading might change only_tokens and per token destinations instances
+	if service.source == "" {
+		appconfig.Instance.AuthorizationService.DestinationsForceReload = func() { service.init(service.GetConfig()) }
 	}
 
 	return service, nil
@@ -141,6 +179,54 @@ func (ds *Service) GetStorageById(id string) (events.StorageProxy, bool) {
 	return unit.storage, true
 }
 
+//GetEventQueue return events queue of the stream mode destination
+func (ds *Service) GetEventQueue(id string) (*events.PersistentQueue, bool) {
+	ds.RLock()
+	defer ds.RUnlock()
+
+	unit, ok := ds.unitsByName[id]
+	if !ok || unit.eventQueue == nil {
+		return nil, false
+	}
+
+	return unit.eventQueue, true
+}
+
+//CreateVersionStorage return a new batch mode storage of the destination with the current connection settings and
+//the processing configuration of the version. The storage isn't registered in the service and must be closed by the caller
+func (ds *Service) CreateVersionStorage(id string, version *configversions.Version) (events.StorageProxy, error) {
+	ds.RLock()
+	unit, ok := ds.unitsByName[id]
+	ds.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("Destination [%s] wasn't found", id)
+	}
+
+	destination := version.Processing.Apply(unit.destination)
+	//the retry policy of the destination is re-registered by the factory: stream mode events are retried until stored
+	if destination.Mode == storages.StreamMode && destination.Retry == nil {
+		destination.Retry = &retry.Config{MaxAttempts: -1}
+	}
+	destination.Mode = storages.BatchMode
+	destination.ConfigVersion = version.Version
+
+	storageProxy, _, err := ds.storageFactoryMethod(ds.ctx, id, ds.logEventPath, path.Join(ds.logFallbackPath, versionsFallbackDir), ds.logRotationMin,
+		destination, ds.monitorKeeper, ds.queryWriter, ds.eventsCache)
+	return storageProxy, err
+}
+
+//GetAllStorages return all storages by destination name
+func (ds *Service) GetAllStorages() map[string]events.StorageProxy {
+	ds.RLock()
+	defer ds.RUnlock()
+
+	result := make(map[string]events.StorageProxy, len(ds.unitsByName))
+	for name, unit := range ds.unitsByName {
+		result[name] = unit.storage
+	}
+	return result
+}
+
 func (ds *Service) GetStorages(tokenId string) (storages []events.StorageProxy) {
 	ds.RLock()
 	defer ds.RUnlock()
@@ -160,25 +246,93 @@ func (ds *Service) GetDestinationIds(tokenId string) map[string]bool {
 	return ids
 }
 
+//GetConfig return a copy of the last applied destinations config
+func (ds *Service) GetConfig() map[string]storages.DestinationConfig {
+	ds.RLock()
+	defer ds.RUnlock()
+
+	result := make(map[string]storages.DestinationConfig, len(ds.config))
+	for name, destination := range ds.config {
+		result[name] = destination
+	}
+	return result
+}
+
+//SetConfig replace all destinations (declarative configuration):
+//file:// source is rewritten, destinations from the application config are replaced in memory only (until restart)
+//http(s):// source isn't supported because destinations will be overwritten on the next reloading
+//return true if destinations have been persisted
+func (ds *Service) SetConfig(dc map[string]storages.DestinationConfig) (bool, error) {
+	if strings.HasPrefix(ds.source, "http://") || strings.HasPrefix(ds.source, "https://") {
+		return false, fmt.Errorf("Destinations are reloaded from %s and can't be changed", ds.source)
+	}
+
+	if strings.Contains(ds.source, "file://") {
+		payload, err := json.MarshalIndent(Payload{Destinations: dc}, "", "  ")
+		if err != nil {
+			return false, fmt.Errorf("Error marshalling destinations: %v", err)
+		}
+		if err := resources.SaveToFile(strings.Replace(ds.source, "file://", "", 1), payload); err != nil {
+			return false, err
+		}
+		ds.updateDestinations(payload)
+		return true, nil
+	}
+
+	ds.init(dc)
+	return false, nil
+}
+
+func (s *Service) startSecretsRefreshing(period time.Duration) {
+	safego.RunWithRestart(func() {
+		ticker := time.NewTicker(period)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C:
+				s.init(s.GetConfig())
+			}
+		}
+	})
+}
+
 func (s *Service) updateDestinations(payload []byte) {
 	dc, err := parseFromBytes(payload)
 	if err != nil {
-		logging.Error(marshallingErrorMsg, err)
+		destinationsLogger.Errorf("%s %v", marshallingErrorMsg, err)
 		return
 	}
 
 	s.init(dc)
 
 	if len(s.unitsByName) == 0 {
-		logging.Errorf("Destinations are empty")
+		destinationsLogger.Errorf("Destinations are empty")
 	}
 }
 
 //1. close and remove all destinations which don't exist in new config
 //2. recreate/create changed/new destinations
 func (s *Service) init(dc map[string]storages.DestinationConfig) {
+	s.initLock.Lock()
+	defer s.initLock.Unlock()
+
 	StatusInstance.Reloading = true
 
+	s.Lock()
+	s.config = dc
+	s.Unlock()
+
+	for name, destination := range dc {
+		if len(destination.OnlyTokens) == 0 {
+			destinationsLogger.WithDestination(name).Warnf("only_tokens aren't provided. All tokens will be stored.")
+		}
+	}
+	dc = mergeWorkspaces(dc, s.workspaceConfig)
+	//map tokens -> ids and create per token destinations instances (multi-tenant tokens configuration)
+	dc = resolveTenants(dc, appconfig.Instance.AuthorizationService.GetTokens())
+
 	//close and remove non-existent (in new config)
 	toDelete := map[string]*Unit{}
 	for name, unit := range s.unitsByName {
@@ -201,18 +355,15 @@ func (s *Service) init(dc map[string]storages.DestinationConfig) {
 	newIds := TokenizedIds{}
 	for name, d := range dc {
 		//common case
-		destination := d
-
-		//map token -> id
-		if len(destination.OnlyTokens) > 0 {
-			destination.OnlyTokens = appconfig.Instance.AuthorizationService.GetAllIdsByToken(destination.OnlyTokens)
-		} else {
-			logging.Warnf("[%s] only_tokens aren't provided. All tokens will be stored.", name)
-			destination.OnlyTokens = appconfig.Instance.AuthorizationService.GetAllTokenIds()
+		destination, err := ResolveSecrets(d)
+		unit, ok := s.unitsByName[name]
+		if err != nil {
+			//the running destination (if exists) is kept with previous secrets values
+			destinationsLogger.WithDestination(name).Errorf("Error resolving secrets: %v", err)
+			continue
 		}
 
 		hash := getHash(name, destination)
-		unit, ok := s.unitsByName[name]
 		if ok {
 			if unit.hash == hash {
 				//destination wasn't changed
@@ -225,24 +376,34 @@ func (s *Service) init(dc map[string]storages.DestinationConfig) {
 		}
 
 		if len(destination.OnlyTokens) == 0 {
-			logging.Warnf("[%s] destination's authorization isn't ready. Will be created in next reloading cycle.", name)
+			destinationsLogger.WithDestination(name).Warnf("destination's authorization isn't ready. Will be created in next reloading cycle.")
 			//authorization tokens weren't loaded => create this destination when authorization service will be reloaded
 			//and call force reload on this service
 			continue
 		}
 
 		//create new
+		destination.ConfigVersion = configversions.Instance.Next(name, destination)
 		newStorageProxy, eventQueue, err := s.storageFactoryMethod(s.ctx, name, s.logEventPath, s.logFallbackPath, s.logRotationMin, destination, s.monitorKeeper, s.queryWriter, s.eventsCache)
 		if err != nil {
-			logging.Errorf("[%s] Error initializing destination of type %s: %v", name, destination.Type, err)
+			destinationsLogger.WithDestination(name).Errorf("Error initializing destination of type %s: %v", destination.Type, err)
 			continue
 		}
 
+		clustered := clustering.Instance != nil && destination.Mode != storages.StreamMode
 		s.unitsByName[name] = &Unit{
-			eventQueue: eventQueue,
-			storage:    newStorageProxy,
-			tokenIds:   destination.OnlyTokens,
-			hash:       hash,
+			eventQueue:  eventQueue,
+			storage:     newStorageProxy,
+			clustered:   clustered,
+			destination: destination,
+			tokenIds:    destination.OnlyTokens,
+			hash:        hash,
+		}
+		if version, added := configversions.Instance.Record(name, destination); added {
+			destinationsLogger.WithDestination(name).Infof("Processing configuration version %d has been recorded", version.Version)
+		}
+		if clustered {
+			clustering.Instance.Register(name, newStorageProxy)
 		}
 
 		//create:
@@ -255,6 +416,10 @@ func (s *Service) init(dc map[string]storages.DestinationConfig) {
 			newIds.Add(tokenId, name)
 			if destination.Mode == storages.StreamMode {
 				newConsumers.Add(tokenId, name, eventQueue)
+			} else if clustered {
+				//the partition owner node stores batches
+				newConsumers.Add(tokenId, name, clustering.Instance.Publisher(name))
+				newStorages.Add(tokenId, name, newStorageProxy)
 			} else {
 				//get or create new logger
 				loggerUsage, ok := s.loggersUsageByTokenId[tokenId]
@@ -265,6 +430,8 @@ func (s *Service) init(dc map[string]storages.DestinationConfig) {
 						FileDir:       s.logEventPath,
 						RotationMin:   s.logRotationMin,
 						RotateOnClose: true,
+						MaxSizeMB:     viper.GetInt("log.max_file_size_mb"),
+						Compression:   viper.GetString("log.compression"),
 					})
 					logger := events.NewAsyncLogger(eventLogWriter, viper.GetBool("log.show_in_server"))
 					loggerUsage = &LoggerUsage{logger: logger, usage: 0}
@@ -298,7 +465,7 @@ func (s *Service) remove(name string, unit *Unit) {
 	//remove from other collections: queue or logger(if needed) + storage
 	for _, tokenId := range unit.tokenIds {
 		oldConsumers := s.consumersByTokenId[tokenId]
-		if unit.eventQueue != nil {
+		if unit.eventQueue != nil || unit.clustered {
 			delete(oldConsumers, name)
 		} else {
 			//logger
@@ -334,12 +501,15 @@ func (s *Service) remove(name string, unit *Unit) {
 		}
 	}
 
+	if unit.clustered {
+		clustering.Instance.Unregister(name)
+	}
 	if err := unit.Close(); err != nil {
-		logging.Errorf("[%s] Error closing destination unit: %v", name, err)
+		destinationsLogger.WithDestination(name).Errorf("Error closing destination unit: %v", err)
 	}
 
 	delete(s.unitsByName, name)
-	logging.Infof("[%s] has been removed!", name)
+	destinationsLogger.WithDestination(name).Infof("has been removed!")
 }
 
 func (s *Service) Close() (multiErr error) {
@@ -113,7 +113,7 @@ func TestServiceInit(t *testing.T) {
 	mockDestinationsServer := startTestServer(payload)
 
 	eventsCache := caching.NewEventsCache(&meta.Dummy{}, 100)
-	service, err := NewService(context.Background(), nil, mockDestinationsServer.URL, "/tmp",
+	service, err := NewService(context.Background(), nil, mockDestinationsServer.URL, nil, "/tmp",
 		"/tmp/fallback", 5, nil, os.Stdout, eventsCache, createTestStorage)
 	require.NoError(t, err)
 	require.NotNil(t, service)
@@ -302,7 +302,7 @@ func startTestServer(ph *payloadHolder) *httptest.Server {
 func createTestStorage(ctx context.Context, name, logEventPath, logFallbackPath string, logRotationMin int64, destination storages.DestinationConfig, monitorKeeper storages.MonitorKeeper, queryWriter io.Writer, eventsCache *caching.EventsCache) (events.StorageProxy, *events.PersistentQueue, error) {
 	var eventQueue *events.PersistentQueue
 	if destination.Mode == storages.StreamMode {
-		eventQueue, _ = events.NewPersistentQueue(name, "/tmp")
+		eventQueue, _ = events.NewPersistentQueue(name, "/tmp", 0)
 	}
 	return &testProxyMock{}, eventQueue, nil
 }
@@ -0,0 +1,141 @@
+package destinations
+
+import (
+	"github.com/jitsucom/eventnative/authorization"
+	"github.com/jitsucom/eventnative/schema"
+	"github.com/jitsucom/eventnative/storages"
+	"sort"
+	"strings"
+)
+
+//tenantDestinationDelimiter separates destination name and token id in per token destination instance name
+const tenantDestinationDelimiter = "."
+
+//resolveTenants return destinations configs with only_tokens resolved into token ids according to multi-tenant tokens configuration:
+//1. tokens with configured destinations are routed only to them (only_tokens of other destinations don't include them)
+//2. tokens with configured data_layout get separate destination instances: $destination.$tokenId with overridden data_layout
+//3. workspaces isolation: destinations store only tokens from the same workspace (global destinations store only global tokens)
+func resolveTenants(dc map[string]storages.DestinationConfig, tokens []authorization.Token) map[string]storages.DestinationConfig {
+	idsByIdentity := map[string]string{}
+	tokensById := map[string]authorization.Token{}
+	//tokens which are routed explicitly: destination name -> token ids
+	routed := map[string][]string{}
+	for _, token := range tokens {
+		tokensById[token.Id] = token
+		idsByIdentity[token.Id] = token.Id
+		if clientSecret := strings.TrimSpace(token.ClientSecret); clientSecret != "" {
+			idsByIdentity[clientSecret] = token.Id
+		}
+		if serverSecret := strings.TrimSpace(token.ServerSecret); serverSecret != "" {
+			idsByIdentity[serverSecret] = token.Id
+		}
+		for _, name := range token.Destinations {
+			routed[name] = append(routed[name], token.Id)
+		}
+	}
+
+	result := map[string]storages.DestinationConfig{}
+	for name, destination := range dc {
+		tokenIds := map[string]bool{}
+		if len(destination.OnlyTokens) > 0 {
+			for _, identity := range destination.OnlyTokens {
+				if id, ok := idsByIdentity[identity]; ok && tokensById[id].Workspace == destination.Workspace {
+					tokenIds[id] = true
+				}
+			}
+		} else {
+			for id, token := range tokensById {
+				if token.Workspace == destination.Workspace {
+					tokenIds[id] = true
+				}
+			}
+		}
+
+		//explicitly routed tokens are stored only in their destinations
+		for id := range tokenIds {
+			if len(tokensById[id].Destinations) > 0 {
+				delete(tokenIds, id)
+			}
+		}
+		for _, id := range routed[name] {
+			if tokensById[id].Workspace == destination.Workspace {
+				tokenIds[id] = true
+			}
+		}
+
+		var shared []string
+		for _, id := range sortedIds(tokenIds) {
+			token := tokensById[id]
+			if token.DataLayout == nil {
+				shared = append(shared, id)
+				continue
+			}
+
+			tenantDestination := destination
+			tenantDestination.OnlyTokens = []string{id}
+			tenantDestination.DataLayout = overrideDataLayout(destination.DataLayout, token.DataLayout)
+			result[name+tenantDestinationDelimiter+id] = tenantDestination
+		}
+
+		//all tokens have separate instances
+		if len(shared) == 0 && len(tokenIds) > 0 {
+			continue
+		}
+
+		destination.OnlyTokens = shared
+		result[name] = destination
+	}
+
+	return result
+}
+
+//mergeWorkspaces return destinations config with added workspaces destinations
+//configured destinations with names of workspaces destinations are skipped
+func mergeWorkspaces(dc, workspaceDc map[string]storages.DestinationConfig) map[string]storages.DestinationConfig {
+	if len(workspaceDc) == 0 {
+		return dc
+	}
+
+	result := make(map[string]storages.DestinationConfig, len(dc)+len(workspaceDc))
+	for name, destination := range dc {
+		if workspaceDestination, ok := workspaceDc[name]; ok {
+			destinationsLogger.WithDestination(name).Errorf("destination name is reserved by workspace [%s]. The destination will be skipped", workspaceDestination.Workspace)
+			continue
+		}
+		result[name] = destination
+	}
+	for name, destination := range workspaceDc {
+		result[name] = destination
+	}
+
+	return result
+}
+
+//overrideDataLayout return a copy of the destination data layout with not empty token data layout fields
+func overrideDataLayout(dataLayout *storages.DataLayout, tokenDataLayout *authorization.TokenDataLayout) *storages.DataLayout {
+	result := &storages.DataLayout{}
+	if dataLayout != nil {
+		*result = *dataLayout
+	}
+
+	if tokenDataLayout.MappingType != "" {
+		result.MappingType = schema.FieldMappingType(tokenDataLayout.MappingType)
+	}
+	if len(tokenDataLayout.Mapping) > 0 {
+		result.Mapping = tokenDataLayout.Mapping
+	}
+	if tokenDataLayout.TableNameTemplate != "" {
+		result.TableNameTemplate = tokenDataLayout.TableNameTemplate
+	}
+
+	return result
+}
+
+func sortedIds(ids map[string]bool) []string {
+	result := make([]string, 0, len(ids))
+	for id := range ids {
+		result = append(result, id)
+	}
+	sort.Strings(result)
+	return result
+}
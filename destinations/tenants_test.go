@@ -0,0 +1,108 @@
+package destinations
+
+import (
+	"github.com/jitsucom/eventnative/authorization"
+	"github.com/jitsucom/eventnative/schema"
+	"github.com/jitsucom/eventnative/storages"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestResolveTenants(t *testing.T) {
+	tokens := []authorization.Token{
+		{Id: "id1", ClientSecret: "token1"},
+		{Id: "id2", ClientSecret: "token2", ServerSecret: "s2s2"},
+		{Id: "tenant1", ClientSecret: "tenant1_token", Destinations: []string{"pg"}},
+		{Id: "tenant2", ClientSecret: "tenant2_token", Destinations: []string{"pg", "s3"},
+			DataLayout: &authorization.TokenDataLayout{TableNameTemplate: "tenant2_events", MappingType: "strict"}},
+	}
+
+	tests := []struct {
+		name     string
+		dc       map[string]storages.DestinationConfig
+		tokens   []authorization.Token
+		expected map[string]storages.DestinationConfig
+	}{
+		{
+			"empty",
+			map[string]storages.DestinationConfig{},
+			tokens,
+			map[string]storages.DestinationConfig{},
+		},
+		{
+			"without tenants",
+			map[string]storages.DestinationConfig{
+				"pg":       {Type: "postgres"},
+				"bigquery": {Type: "bigquery", OnlyTokens: []string{"token1", "s2s2", "unknown"}},
+			},
+			tokens[:2],
+			map[string]storages.DestinationConfig{
+				"pg":       {Type: "postgres", OnlyTokens: []string{"id1", "id2"}},
+				"bigquery": {Type: "bigquery", OnlyTokens: []string{"id1", "id2"}},
+			},
+		},
+		{
+			"tenants routing and data layout",
+			map[string]storages.DestinationConfig{
+				"pg":       {Type: "postgres", DataLayout: &storages.DataLayout{TableNameTemplate: "events", Mapping: []string{"/a -> /b"}}},
+				"bigquery": {Type: "bigquery", OnlyTokens: []string{"token1", "tenant1_token"}},
+				"s3":       {Type: "s3", OnlyTokens: []string{"token2"}},
+			},
+			tokens,
+			map[string]storages.DestinationConfig{
+				"pg": {Type: "postgres", OnlyTokens: []string{"id1", "id2", "tenant1"},
+					DataLayout: &storages.DataLayout{TableNameTemplate: "events", Mapping: []string{"/a -> /b"}}},
+				"pg.tenant2": {Type: "postgres", OnlyTokens: []string{"tenant2"},
+					DataLayout: &storages.DataLayout{TableNameTemplate: "tenant2_events", Mapping: []string{"/a -> /b"}, MappingType: schema.Strict}},
+				"bigquery": {Type: "bigquery", OnlyTokens: []string{"id1"}},
+				"s3":       {Type: "s3", OnlyTokens: []string{"id2"}},
+				"s3.tenant2": {Type: "s3", OnlyTokens: []string{"tenant2"},
+					DataLayout: &storages.DataLayout{TableNameTemplate: "tenant2_events", MappingType: schema.Strict}},
+			},
+		},
+		{
+			"only tenant instances",
+			map[string]storages.DestinationConfig{
+				"s3": {Type: "s3", OnlyTokens: []string{"tenant2_token"}},
+			},
+			tokens,
+			map[string]storages.DestinationConfig{
+				"s3.tenant2": {Type: "s3", OnlyTokens: []string{"tenant2"},
+					DataLayout: &storages.DataLayout{TableNameTemplate: "tenant2_events", MappingType: schema.Strict}},
+			},
+		},
+		{
+			"workspaces isolation",
+			map[string]storages.DestinationConfig{
+				"pg":     {Type: "postgres"},
+				"s3":     {Type: "s3", OnlyTokens: []string{"ws_token"}},
+				"ws.pg":  {Type: "postgres", Workspace: "ws"},
+				"ws.s3":  {Type: "s3", Workspace: "ws", OnlyTokens: []string{"ws_token", "token1"}},
+				"ws2.pg": {Type: "postgres", Workspace: "ws2"},
+			},
+			append([]authorization.Token{{Id: "ws.id1", ClientSecret: "ws_token", Workspace: "ws"}}, tokens[:2]...),
+			map[string]storages.DestinationConfig{
+				"pg":     {Type: "postgres", OnlyTokens: []string{"id1", "id2"}},
+				"s3":     {Type: "s3"},
+				"ws.pg":  {Type: "postgres", Workspace: "ws", OnlyTokens: []string{"ws.id1"}},
+				"ws.s3":  {Type: "s3", Workspace: "ws", OnlyTokens: []string{"ws.id1"}},
+				"ws2.pg": {Type: "postgres", Workspace: "ws2"},
+			},
+		},
+		{
+			"authorization isn't ready",
+			map[string]storages.DestinationConfig{
+				"pg": {Type: "postgres", OnlyTokens: []string{"token1"}},
+			},
+			nil,
+			map[string]storages.DestinationConfig{
+				"pg": {Type: "postgres"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, resolveTenants(tt.dc, tt.tokens))
+		})
+	}
+}
@@ -4,12 +4,17 @@ import (
 	"fmt"
 	"github.com/hashicorp/go-multierror"
 	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/storages"
 )
 
 //Unit holds storage bundle for closing at once
 type Unit struct {
 	eventQueue *events.PersistentQueue
 	storage    events.StorageProxy
+	//batch mode events are published into the clustering shared queue instead of the token logger
+	clustered bool
+	//config with resolved secrets
+	destination storages.DestinationConfig
 
 	tokenIds []string
 	hash     string
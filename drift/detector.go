@@ -0,0 +1,305 @@
+package drift
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/metrics"
+	"github.com/jitsucom/eventnative/notifications"
+	"github.com/jitsucom/eventnative/safego"
+	"github.com/jitsucom/eventnative/schema"
+	"github.com/jitsucom/eventnative/typing"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	NewField    = "new_field"
+	TypeChanged = "type_changed"
+	NullRate    = "null_rate"
+
+	defaultSampleRate        = 0.01
+	defaultWindowSize        = 1000
+	defaultNullRateThreshold = 0.3
+	defaultNotifyIntervalMin = 60
+)
+
+//Instance is a singleton detector. It is nil if schema drift detection isn't enabled
+var Instance *Detector
+
+//Config is a schema drift detector configuration
+type Config struct {
+	Enabled bool `mapstructure:"enabled"`
+	//share of processed events which are compared with the registered schema
+	SampleRate float64 `mapstructure:"sample_rate"`
+	//sampled events count per table for null-rate calculation
+	WindowSize int `mapstructure:"window_size"`
+	//null-rate absolute change between windows which is a drift
+	NullRateThreshold float64 `mapstructure:"null_rate_threshold"`
+	//the same drift of the column isn't notified more often
+	NotifyIntervalMin int    `mapstructure:"notify_interval_min"`
+	Webhook           string `mapstructure:"webhook"`
+	Slack             bool   `mapstructure:"slack"`
+}
+
+//Change is a detected schema drift of a destination table column
+type Change struct {
+	Destination string    `json:"destination"`
+	Table       string    `json:"table"`
+	Column      string    `json:"column"`
+	Kind        string    `json:"kind"`
+	Previous    string    `json:"previous,omitempty"`
+	Current     string    `json:"current"`
+	DetectedAt  time.Time `json:"detected_at"`
+}
+
+func (c *Change) String() string {
+	switch c.Kind {
+	case NewField:
+		return fmt.Sprintf("[%s] table [%s]: new field [%s] of type [%s]", c.Destination, c.Table, c.Column, c.Current)
+	case TypeChanged:
+		return fmt.Sprintf("[%s] table [%s]: field [%s] type has changed from [%s] to [%s]", c.Destination, c.Table, c.Column, c.Previous, c.Current)
+	default:
+		return fmt.Sprintf("[%s] table [%s]: field [%s] null-rate has changed from %s to %s", c.Destination, c.Table, c.Column, c.Previous, c.Current)
+	}
+}
+
+//tableState is a registered schema of the table: column types of sampled events
+//and null-rates of the previous window. The first window is a baseline: new fields aren't reported
+type tableState struct {
+	types     map[string]typing.DataType
+	baselined bool
+
+	sampled   int
+	present   map[string]int
+	nullRates map[string]float64
+}
+
+//Detector samples processed events, compares them with the registered per table schema and
+//notifies (webhook and/or Slack) about new fields, field type shifts and sharp null-rate changes
+type Detector struct {
+	sync.Mutex
+
+	config         Config
+	notifyInterval time.Duration
+	client         *http.Client
+	random         func() float64
+	now            func() time.Time
+
+	//destination/table -> state
+	tables       map[string]*tableState
+	lastNotified map[string]time.Time
+
+	changesCh chan *Change
+	closed    bool
+}
+
+//Init create Instance if detection is enabled
+func Init(config Config) error {
+	detector, err := NewDetector(config)
+	if err != nil {
+		return err
+	}
+	if detector == nil {
+		return nil
+	}
+
+	Instance = detector
+	Instance.start()
+	logging.Infof("Schema drift detector is enabled with sample rate: %v", Instance.config.SampleRate)
+	return nil
+}
+
+//NewDetector return Detector or nil if it isn't enabled
+func NewDetector(config Config) (*Detector, error) {
+	if !config.Enabled {
+		return nil, nil
+	}
+
+	if config.SampleRate == 0 {
+		config.SampleRate = defaultSampleRate
+	}
+	if config.SampleRate < 0 || config.SampleRate > 1 {
+		return nil, fmt.Errorf("Schema drift sample_rate must be in (0, 1] range")
+	}
+	if config.WindowSize <= 0 {
+		config.WindowSize = defaultWindowSize
+	}
+	if config.NullRateThreshold <= 0 {
+		config.NullRateThreshold = defaultNullRateThreshold
+	}
+	if config.NotifyIntervalMin <= 0 {
+		config.NotifyIntervalMin = defaultNotifyIntervalMin
+	}
+	if config.Webhook == "" && !config.Slack {
+		logging.Warnf("Schema drift detector doesn't have webhook or slack configuration: drifts are only logged")
+	}
+
+	return &Detector{
+		config:         config,
+		notifyInterval: time.Duration(config.NotifyIntervalMin) * time.Minute,
+		client:         &http.Client{Timeout: 10 * time.Second},
+		random:         rand.Float64,
+		now:            time.Now,
+		tables:         map[string]*tableState{},
+		lastNotified:   map[string]time.Time{},
+		changesCh:      make(chan *Change, 1000),
+	}, nil
+}
+
+//Observe compare sampled processed object with the registered table schema
+func (d *Detector) Observe(destinationName string, table *schema.Table, object map[string]interface{}) {
+	if d == nil || !table.Exists() || d.random() >= d.config.SampleRate {
+		return
+	}
+
+	for _, change := range d.observe(destinationName, table, object) {
+		select {
+		case d.changesCh <- change:
+		default:
+			logging.Warnf("[%s] Schema drift notifications queue is full: %s", destinationName, change)
+		}
+	}
+}
+
+func (d *Detector) observe(destinationName string, table *schema.Table, object map[string]interface{}) []*Change {
+	d.Lock()
+	defer d.Unlock()
+
+	now := d.now().UTC()
+	key := destinationName + "/" + table.Name
+	state, ok := d.tables[key]
+	if !ok {
+		state = &tableState{types: map[string]typing.DataType{}, present: map[string]int{}}
+		d.tables[key] = state
+	}
+
+	var changes []*Change
+	for name, column := range table.Columns {
+		current := column.GetType()
+		registered, ok := state.types[name]
+		if !ok {
+			if state.baselined {
+				changes = d.appendChange(changes, &Change{Destination: destinationName, Table: table.Name, Column: name,
+					Kind: NewField, Current: current.String(), DetectedAt: now})
+			}
+			state.types[name] = current
+			continue
+		}
+		if registered != current {
+			changes = d.appendChange(changes, &Change{Destination: destinationName, Table: table.Name, Column: name,
+				Kind: TypeChanged, Previous: registered.String(), Current: current.String(), DetectedAt: now})
+			state.types[name] = current
+		}
+	}
+
+	state.sampled++
+	for name, value := range object {
+		if value != nil {
+			state.present[name]++
+		}
+	}
+	if state.sampled < d.config.WindowSize {
+		return changes
+	}
+
+	nullRates := make(map[string]float64, len(state.types))
+	for name := range state.types {
+		nullRate := 1 - float64(state.present[name])/float64(state.sampled)
+		nullRates[name] = nullRate
+		if previous, ok := state.nullRates[name]; ok && math.Abs(nullRate-previous) >= d.config.NullRateThreshold {
+			changes = d.appendChange(changes, &Change{Destination: destinationName, Table: table.Name, Column: name,
+				Kind: NullRate, Previous: fmt.Sprintf("%.2f", previous), Current: fmt.Sprintf("%.2f", nullRate), DetectedAt: now})
+		}
+	}
+	state.nullRates = nullRates
+	state.sampled = 0
+	state.present = map[string]int{}
+	state.baselined = true
+
+	return changes
+}
+
+//appendChange append the change if the same drift hasn't been notified during notify interval
+func (d *Detector) appendChange(changes []*Change, change *Change) []*Change {
+	key := change.Destination + "/" + change.Table + "/" + change.Column + "/" + change.Kind
+	if last, ok := d.lastNotified[key]; ok && change.DetectedAt.Sub(last) < d.notifyInterval {
+		return changes
+	}
+	d.lastNotified[key] = change.DetectedAt
+
+	metrics.SchemaDrift(change.Destination, change.Kind)
+	return append(changes, change)
+}
+
+func (d *Detector) start() {
+	safego.RunWithRestart(func() {
+		for {
+			if d.closed {
+				break
+			}
+
+			changes := []*Change{<-d.changesCh}
+			//send all queued changes at once
+			for len(d.changesCh) > 0 {
+				changes = append(changes, <-d.changesCh)
+			}
+			d.notify(changes)
+		}
+	})
+}
+
+func (d *Detector) notify(changes []*Change) {
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].String() < changes[j].String()
+	})
+
+	var lines []string
+	for _, change := range changes {
+		lines = append(lines, change.String())
+	}
+	message := strings.Join(lines, "\n")
+	logging.Warnf("Schema drift has been detected:\n%s", message)
+
+	if d.config.Slack {
+		notifications.Warning("Schema drift has been detected:\n" + message)
+	}
+	if d.config.Webhook != "" {
+		if err := d.sendWebhook(changes); err != nil {
+			logging.Errorf("Error sending schema drift changes to webhook: %v", err)
+		}
+	}
+}
+
+func (d *Detector) sendWebhook(changes []*Change) error {
+	b, err := json.Marshal(map[string]interface{}{"changes": changes})
+	if err != nil {
+		return err
+	}
+
+	resp, err := d.client.Post(d.config.Webhook, "application/json", bytes.NewBuffer(b))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("HTTP code = %d, body: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+func (d *Detector) Close() error {
+	if d != nil {
+		d.closed = true
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package drift
+
+import (
+	"github.com/jitsucom/eventnative/schema"
+	"github.com/jitsucom/eventnative/typing"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestObserve(t *testing.T) {
+	d, err := NewDetector(Config{Enabled: true, SampleRate: 1, WindowSize: 2, NullRateThreshold: 0.5})
+	require.NoError(t, err)
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	d.now = func() time.Time { return now }
+
+	observe := func(object map[string]interface{}) []*Change {
+		table := &schema.Table{Name: "events", Columns: schema.Columns{}}
+		for name, value := range object {
+			if _, ok := value.(string); ok {
+				table.Columns[name] = schema.NewColumn(typing.STRING)
+			} else {
+				table.Columns[name] = schema.NewColumn(typing.INT64)
+			}
+		}
+		return d.observe("dest", table, object)
+	}
+
+	//baseline window
+	require.Empty(t, observe(map[string]interface{}{"a": 1, "b": "x"}))
+	require.Empty(t, observe(map[string]interface{}{"a": 1, "b": "x"}))
+
+	changes := observe(map[string]interface{}{"a": "1", "b": "x", "c": 1})
+	require.Len(t, changes, 2)
+	kinds := map[string]*Change{}
+	for _, change := range changes {
+		kinds[change.Kind] = change
+	}
+	require.Equal(t, "a", kinds[TypeChanged].Column)
+	require.Equal(t, "STRING", kinds[TypeChanged].Current)
+	require.Equal(t, "c", kinds[NewField].Column)
+
+	//b is null in the whole window
+	changes = observe(map[string]interface{}{"a": "1", "c": 1})
+	require.Len(t, changes, 1)
+	require.Equal(t, NullRate, changes[0].Kind)
+	require.Equal(t, "b", changes[0].Column)
+	require.Equal(t, "0.50", changes[0].Current)
+
+	//the same drift isn't notified during notify interval
+	require.Empty(t, observe(map[string]interface{}{"a": 1}))
+	now = now.Add(2 * time.Hour)
+	kinds = map[string]*Change{}
+	for _, change := range observe(map[string]interface{}{"a": "1"}) {
+		kinds[change.Column+"/"+change.Kind] = change
+	}
+	require.Contains(t, kinds, "a/"+TypeChanged)
+	require.Contains(t, kinds, "b/"+NullRate)
+}
@@ -0,0 +1,113 @@
+package drivers
+
+import (
+	"crypto/md5"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/events"
+	"strings"
+	"time"
+)
+
+const (
+	reportDayLayout                 = "2006-01-02"
+	defaultAttributionWindowDays    = 28
+	defaultReportInitialHistoryDays = 90
+)
+
+//ReportWindowConfig is a daily ad reports synchronization window (a part of ads sources configurations)
+type ReportWindowConfig struct {
+	//Optional. YYYY-MM-DD: the first day of the first sync (default: 90 days ago)
+	StartDate string `mapstructure:"start_date" json:"start_date,omitempty" yaml:"start_date,omitempty"`
+	//days which are re-read every sync because ad platforms restate metrics (conversions attribution). Default: 28
+	AttributionWindowDays int `mapstructure:"attribution_window_days" json:"attribution_window_days,omitempty" yaml:"attribution_window_days,omitempty"`
+}
+
+func (rwc *ReportWindowConfig) validate(sourceName string) error {
+	if rwc.StartDate != "" {
+		if _, err := time.Parse(reportDayLayout, rwc.StartDate); err != nil {
+			return fmt.Errorf("%s start_date must be YYYY-MM-DD: %v", sourceName, err)
+		}
+	}
+	if rwc.AttributionWindowDays < 0 {
+		return fmt.Errorf("%s attribution_window_days must be positive", sourceName)
+	}
+	if rwc.AttributionWindowDays == 0 {
+		rwc.AttributionWindowDays = defaultAttributionWindowDays
+	}
+	return nil
+}
+
+//reportState is a daily reports cursor: Next is the next day of the current sync, SyncedUntil is the last day
+//of the last finished sync
+type reportState struct {
+	Next        string `json:"next,omitempty"`
+	SyncedUntil string `json:"synced_until,omitempty"`
+}
+
+//reportDay return the day which is read with the state: either the next day of the current sync or the first day
+//of the new sync (the attribution window before the last synced day)
+func (rwc *ReportWindowConfig) reportDay(state string, today time.Time) (time.Time, *reportState, error) {
+	cursor := &reportState{}
+	if state != "" {
+		if err := json.Unmarshal([]byte(state), cursor); err != nil {
+			return time.Time{}, nil, fmt.Errorf("malformed state [%s]: %v", state, err)
+		}
+	}
+	if cursor.Next != "" {
+		day, err := time.Parse(reportDayLayout, cursor.Next)
+		return day, cursor, err
+	}
+
+	first := today.AddDate(0, 0, -defaultReportInitialHistoryDays)
+	if rwc.StartDate != "" {
+		first, _ = time.Parse(reportDayLayout, rwc.StartDate)
+	}
+	if cursor.SyncedUntil != "" {
+		syncedUntil, err := time.Parse(reportDayLayout, cursor.SyncedUntil)
+		if err != nil {
+			return time.Time{}, nil, err
+		}
+		if windowStart := syncedUntil.AddDate(0, 0, 1-rwc.AttributionWindowDays); windowStart.After(first) {
+			first = windowStart
+		}
+	}
+	if first.After(today) {
+		return time.Time{}, nil, errors.New("start_date is in the future")
+	}
+	return first, cursor, nil
+}
+
+//nextState return the state after the day has been read: the sync is finished with today
+func (rwc *ReportWindowConfig) nextState(day, today time.Time, cursor *reportState) (string, bool, error) {
+	next := &reportState{SyncedUntil: cursor.SyncedUntil}
+	hasMore := day.Before(today)
+	if hasMore {
+		next.Next = day.AddDate(0, 0, 1).Format(reportDayLayout)
+	} else {
+		next.SyncedUntil = today.Format(reportDayLayout)
+	}
+
+	b, err := json.Marshal(next)
+	if err != nil {
+		return "", false, err
+	}
+	return string(b), hasMore, nil
+}
+
+//reportToday return the current UTC day
+func reportToday() time.Time {
+	return time.Now().UTC().Truncate(24 * time.Hour)
+}
+
+//enrichWithReportKey set the event id as a hash of the report row key fields values: restated rows have the same
+//event id, so destinations with primary_key_fields: [eventn_ctx_event_id] overwrite previously loaded metrics
+func enrichWithReportKey(sourceType, collection string, row map[string]interface{}, keyFields []string) {
+	values := make([]string, 0, len(keyFields)+2)
+	values = append(values, sourceType, collection)
+	for _, field := range keyFields {
+		values = append(values, fmt.Sprint(row[field]))
+	}
+	events.EnrichWithEventId(row, fmt.Sprintf("%x", md5.Sum([]byte(strings.Join(values, "|")))))
+}
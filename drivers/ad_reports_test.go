@@ -0,0 +1,66 @@
+package drivers
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestReportWindow(t *testing.T) {
+	today := time.Date(2020, 9, 10, 0, 0, 0, 0, time.UTC)
+	config := &ReportWindowConfig{StartDate: "2020-09-08", AttributionWindowDays: 3}
+	require.NoError(t, config.validate("test"))
+
+	//the first sync reads days since start_date up to today
+	var days []string
+	state := ""
+	for hasMore := true; hasMore; {
+		day, cursor, err := config.reportDay(state, today)
+		require.NoError(t, err)
+		days = append(days, day.Format(reportDayLayout))
+
+		state, hasMore, err = config.nextState(day, today, cursor)
+		require.NoError(t, err)
+	}
+	require.Equal(t, []string{"2020-09-08", "2020-09-09", "2020-09-10"}, days)
+	require.Equal(t, `{"synced_until":"2020-09-10"}`, state)
+
+	//the next sync re-reads the attribution window
+	day, _, err := config.reportDay(state, today.AddDate(0, 0, 5))
+	require.NoError(t, err)
+	require.Equal(t, "2020-09-08", day.Format(reportDayLayout))
+
+	config.AttributionWindowDays = 1
+	day, _, err = config.reportDay(state, today)
+	require.NoError(t, err)
+	require.Equal(t, "2020-09-10", day.Format(reportDayLayout))
+
+	//interrupted sync is continued
+	day, _, err = config.reportDay(`{"next":"2020-09-09","synced_until":"2020-09-01"}`, today)
+	require.NoError(t, err)
+	require.Equal(t, "2020-09-09", day.Format(reportDayLayout))
+
+	_, _, err = config.reportDay("2020-09-09", today)
+	require.Error(t, err)
+
+	require.Error(t, (&ReportWindowConfig{StartDate: "09/08/2020"}).validate("test"))
+	defaultConfig := &ReportWindowConfig{}
+	require.NoError(t, defaultConfig.validate("test"))
+	require.Equal(t, defaultAttributionWindowDays, defaultConfig.AttributionWindowDays)
+}
+
+func TestEnrichWithReportKey(t *testing.T) {
+	row := map[string]interface{}{"date_start": "2020-09-01", "ad_id": "1", "clicks": "10"}
+	restated := map[string]interface{}{"date_start": "2020-09-01", "ad_id": "1", "clicks": "12"}
+	other := map[string]interface{}{"date_start": "2020-09-02", "ad_id": "1", "clicks": "10"}
+	for _, object := range []map[string]interface{}{row, restated, other} {
+		enrichWithReportKey(FacebookAdsType, "ad", object, []string{"date_start", "ad_id"})
+	}
+
+	eventId := func(object map[string]interface{}) interface{} {
+		return object["eventn_ctx"].(map[string]interface{})["event_id"]
+	}
+	require.NotEmpty(t, eventId(row))
+	require.Equal(t, eventId(row), eventId(restated))
+	require.NotEqual(t, eventId(row), eventId(other))
+}
@@ -0,0 +1,242 @@
+package drivers
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/logging"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	amplitudeAPIURL   = "https://amplitude.com"
+	amplitudeEUAPIURL = "https://analytics.eu.amplitude.com"
+	amplitudeHour     = "20060102T15"
+	//events are available in Export API about 2 hours after they are received by Amplitude
+	amplitudeExportLatency = 2 * time.Hour
+	//AmplitudeCollection is the only collection of Amplitude source
+	AmplitudeCollection = "events"
+	maxExportLineSize   = 10 * 1024 * 1024
+)
+
+func init() {
+	RegisterConnector(AmplitudeType, NewAmplitudeConnector)
+}
+
+//AmplitudeConfig is an Amplitude source configuration: project API key and secret key
+type AmplitudeConfig struct {
+	APIKey    string `mapstructure:"api_key" json:"api_key,omitempty" yaml:"api_key,omitempty"`
+	SecretKey string `mapstructure:"secret_key" json:"secret_key,omitempty" yaml:"secret_key,omitempty"`
+	//YYYY-MM-DD: the first exported day
+	StartDate string `mapstructure:"start_date" json:"start_date,omitempty" yaml:"start_date,omitempty"`
+	//if true - EU data center API is used
+	EURegion bool `mapstructure:"eu_region" json:"eu_region,omitempty" yaml:"eu_region,omitempty"`
+}
+
+func (ac *AmplitudeConfig) Validate() error {
+	if ac == nil {
+		return errors.New("Amplitude config is required")
+	}
+	if ac.APIKey == "" || ac.SecretKey == "" {
+		return errors.New("Amplitude api_key and secret_key are required")
+	}
+	if _, err := time.Parse(reportDayLayout, ac.StartDate); err != nil {
+		return fmt.Errorf("Amplitude start_date is required in YYYY-MM-DD format: %v", err)
+	}
+	return nil
+}
+
+//exportState is a cursor of export sources: the next hour (day) which hasn't been exported yet
+type exportState struct {
+	Next string `json:"next"`
+}
+
+//Amplitude is a connector which exports events hour by hour with Export API (GET /api/2/export) from start_date
+//and converts them into facts (see events.ConvertAmplitude). Hours are exported after Amplitude export latency
+type Amplitude struct {
+	ctx      context.Context
+	sourceId string
+	config   *AmplitudeConfig
+	client   *apiClient
+	apiURL   string
+	now      func() time.Time
+}
+
+//NewAmplitudeConnector is a ConnectorFactory of Amplitude connector
+func NewAmplitudeConnector(ctx context.Context, sourceId string, config map[string]interface{}) (Connector, error) {
+	amplitudeConfig := &AmplitudeConfig{}
+	if err := unmarshalConfig(config, amplitudeConfig); err != nil {
+		return nil, err
+	}
+	if err := amplitudeConfig.Validate(); err != nil {
+		return nil, err
+	}
+
+	apiURL := amplitudeAPIURL
+	if amplitudeConfig.EURegion {
+		apiURL = amplitudeEUAPIURL
+	}
+	return &Amplitude{ctx: ctx, sourceId: sourceId, config: amplitudeConfig, client: newAPIClient("Amplitude"), apiURL: apiURL, now: time.Now}, nil
+}
+
+func (a *Amplitude) Discover() ([]*Collection, error) {
+	return []*Collection{{Name: AmplitudeCollection, Incremental: true, CursorField: "utc_time", PrimaryKeyFields: []string{"eventn_ctx_event_id"}}}, nil
+}
+
+func (a *Amplitude) Read(collection, state string) (*ReadResult, error) {
+	if collection != AmplitudeCollection {
+		return nil, fmt.Errorf("Amplitude collection [%s] isn't supported. Available: [%s]", collection, AmplitudeCollection)
+	}
+
+	hour, err := a.nextHour(state)
+	if err != nil {
+		return nil, err
+	}
+	ready := a.now().UTC().Add(-amplitudeExportLatency).Truncate(time.Hour)
+	if !hour.Before(ready) {
+		return &ReadResult{State: state}, nil
+	}
+
+	objects, err := a.export(hour)
+	if err != nil {
+		return nil, fmt.Errorf("Amplitude error exporting %s: %v", hour.Format(amplitudeHour), err)
+	}
+
+	next := hour.Add(time.Hour)
+	b, err := json.Marshal(&exportState{Next: next.Format(amplitudeHour)})
+	if err != nil {
+		return nil, fmt.Errorf("Amplitude error marshalling state: %v", err)
+	}
+	return &ReadResult{Objects: objects, State: string(b), HasMore: next.Before(ready)}, nil
+}
+
+func (a *Amplitude) nextHour(state string) (time.Time, error) {
+	if state == "" {
+		return time.Parse(reportDayLayout, a.config.StartDate)
+	}
+
+	cursor := &exportState{}
+	if err := json.Unmarshal([]byte(state), cursor); err != nil {
+		return time.Time{}, fmt.Errorf("Amplitude malformed state [%s]: %v", state, err)
+	}
+	hour, err := time.Parse(amplitudeHour, cursor.Next)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Amplitude malformed state [%s]: %v", state, err)
+	}
+	return hour, nil
+}
+
+//export return converted events of the hour. The response is a zip archive of gzipped JSON lines files.
+//404 means that there is no data
+func (a *Amplitude) export(hour time.Time) ([]map[string]interface{}, error) {
+	query := url.Values{}
+	query.Set("start", hour.Format(amplitudeHour))
+	query.Set("end", hour.Format(amplitudeHour))
+	requestURL := strings.TrimRight(a.apiURL, "/") + "/api/2/export?" + query.Encode()
+
+	body, err := a.client.do(func() (*http.Request, error) {
+		request, err := http.NewRequestWithContext(a.ctx, http.MethodGet, requestURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		request.SetBasicAuth(a.config.APIKey, a.config.SecretKey)
+		return request, nil
+	})
+	if apiErr, ok := err.(*apiError); ok && apiErr.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return nil, fmt.Errorf("Error opening export archive: %v", err)
+	}
+
+	var objects []map[string]interface{}
+	for _, file := range archive.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		fileObjects, err := a.readFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading export file [%s]: %v", file.Name, err)
+		}
+		objects = append(objects, fileObjects...)
+	}
+	return objects, nil
+}
+
+func (a *Amplitude) readFile(file *zip.File) ([]map[string]interface{}, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	gzipReader, err := gzip.NewReader(reader)
+	if err != nil {
+		return nil, err
+	}
+	defer gzipReader.Close()
+
+	return readExportLines(a.sourceId, gzipReader, func(event map[string]interface{}) (events.Fact, error) {
+		return events.ConvertAmplitude(event)
+	})
+}
+
+func (a *Amplitude) Type() string {
+	return AmplitudeType
+}
+
+func (a *Amplitude) Close() error {
+	return nil
+}
+
+//readExportLines parse JSON lines and convert them into facts. Malformed lines and not converted events are skipped
+func readExportLines(sourceId string, reader io.Reader, convert func(map[string]interface{}) (events.Fact, error)) ([]map[string]interface{}, error) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), maxExportLineSize)
+
+	var objects []map[string]interface{}
+	skipped := 0
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(line))
+		decoder.UseNumber()
+		event := map[string]interface{}{}
+		if err := decoder.Decode(&event); err != nil {
+			skipped++
+			continue
+		}
+		fact, err := convert(event)
+		if err != nil {
+			skipped++
+			continue
+		}
+		objects = append(objects, fact)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if skipped > 0 {
+		logging.Warnf("[%s] %d malformed export events have been skipped", sourceId, skipped)
+	}
+	return objects, nil
+}
@@ -0,0 +1,86 @@
+package drivers
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAmplitudeRead(t *testing.T) {
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		require.True(t, ok)
+		require.Equal(t, "key", username)
+		require.Equal(t, "secret", password)
+		require.Equal(t, "/api/2/export", r.URL.Path)
+		queries = append(queries, r.URL.RawQuery)
+
+		if r.URL.Query().Get("start") != "20200901T00" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(amplitudeExportArchive(t,
+			`{"uuid":"e1","event_type":"click","event_time":"2020-09-01 00:10:00.000000","user_id":"u1","amplitude_id":123}`,
+			`malformed`,
+			`{"uuid":"e2","event_type":"","event_time":"2020-09-01 00:20:00.000000"}`,
+			`{"uuid":"e3","event_type":"view","event_time":"2020-09-01 00:30:00.000000"}`,
+		))
+	}))
+	defer server.Close()
+
+	amplitude := &Amplitude{ctx: context.Background(), config: &AmplitudeConfig{APIKey: "key", SecretKey: "secret", StartDate: "2020-09-01"},
+		client: newAPIClient("Amplitude"), apiURL: server.URL,
+		now: func() time.Time { return time.Date(2020, 9, 1, 4, 30, 0, 0, time.UTC) }}
+
+	result, err := amplitude.Read(AmplitudeCollection, "")
+	require.NoError(t, err)
+	require.Len(t, result.Objects, 2)
+	require.Equal(t, "amplitude", result.Objects[0]["src"])
+	require.Equal(t, "click", result.Objects[0]["event_type"])
+	require.Equal(t, "e1", result.Objects[0]["eventn_ctx"].(map[string]interface{})["event_id"])
+	require.Equal(t, "view", result.Objects[1]["event_type"])
+	require.Equal(t, `{"next":"20200901T01"}`, result.State)
+	require.True(t, result.HasMore)
+
+	//no data
+	result, err = amplitude.Read(AmplitudeCollection, result.State)
+	require.NoError(t, err)
+	require.Empty(t, result.Objects)
+	require.Equal(t, `{"next":"20200901T02"}`, result.State)
+	require.False(t, result.HasMore)
+
+	//02:00 - 03:00 isn't available yet
+	result, err = amplitude.Read(AmplitudeCollection, result.State)
+	require.NoError(t, err)
+	require.Empty(t, result.Objects)
+	require.Equal(t, `{"next":"20200901T02"}`, result.State)
+	require.False(t, result.HasMore)
+
+	require.Equal(t, []string{"end=20200901T00&start=20200901T00", "end=20200901T01&start=20200901T01"}, queries)
+
+	_, err = amplitude.Read("users", "")
+	require.EqualError(t, err, "Amplitude collection [users] isn't supported. Available: [events]")
+}
+
+func amplitudeExportArchive(t *testing.T, lines ...string) []byte {
+	buf := &bytes.Buffer{}
+	archive := zip.NewWriter(buf)
+	file, err := archive.Create("123/123_2020-09-01_0#0.json.gz")
+	require.NoError(t, err)
+
+	gzipWriter := gzip.NewWriter(file)
+	for _, line := range lines {
+		_, err := gzipWriter.Write([]byte(line + "\n"))
+		require.NoError(t, err)
+	}
+	require.NoError(t, gzipWriter.Close())
+	require.NoError(t, archive.Close())
+	return buf.Bytes()
+}
@@ -0,0 +1,153 @@
+package drivers
+
+import (
+	"fmt"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/retry"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultAPIRetries = 8
+	minAPIDelay       = 500 * time.Millisecond
+	maxAPIDelay       = 2 * time.Minute
+)
+
+//apiError is a not successful API response
+type apiError struct {
+	StatusCode int
+	Body       string
+}
+
+func (ae *apiError) Error() string {
+	return fmt.Sprintf("responded with %d: %s", ae.StatusCode, ae.Body)
+}
+
+//apiClient is HTTP client of source APIs with adaptive backoff: after rate limited (429, API specific errors) or
+//unavailable (503) responses requests are delayed (the delay is doubled up to 2 minutes, Retry-After header is respected) and every successful
+//response halves the delay. Network errors are retried as well
+type apiClient struct {
+	sync.Mutex
+
+	name       string
+	client     *http.Client
+	maxRetries int
+	delay      time.Duration
+	//Optional. Return true if the response means that the rate limit has been exceeded (default: 429 and 503 statuses)
+	rateLimited func(statusCode int, body []byte) bool
+
+	sleep func(time.Duration)
+}
+
+func newAPIClient(name string) *apiClient {
+	return &apiClient{name: name, client: &http.Client{Timeout: time.Minute}, maxRetries: defaultAPIRetries, sleep: time.Sleep}
+}
+
+//do send the request which is built by newRequest (it is called on every attempt: request bodies can't be reread)
+//and return 2xx response body. Other responses are returned as *apiError
+func (ac *apiClient) do(newRequest func() (*http.Request, error)) ([]byte, error) {
+	for attempt := 1; ; attempt++ {
+		if delay := ac.currentDelay(); delay > 0 {
+			ac.sleep(delay)
+		}
+
+		request, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+
+		response, err := ac.client.Do(request)
+		if err != nil {
+			if retry.IsNetworkError(err) && attempt <= ac.maxRetries {
+				logging.Warnf("[%s] API request attempt %d has failed: %v. Retry in %s", ac.name, attempt, err, ac.slowDown())
+				continue
+			}
+			return nil, fmt.Errorf("Error requesting %s API: %v", ac.name, err)
+		}
+
+		body, err := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("Error reading %s API response: %v", ac.name, err)
+		}
+
+		if ac.isRateLimited(response.StatusCode, body) {
+			//long limit windows (e.g. daily quotas) aren't waited: the sync is continued from the state next time
+			retryAfter := parseRetryAfter(response.Header.Get("Retry-After"))
+			if attempt > ac.maxRetries || retryAfter > maxAPIDelay {
+				return nil, &apiError{StatusCode: response.StatusCode, Body: string(body)}
+			}
+
+			delay := ac.slowDown()
+			if retryAfter > delay {
+				ac.sleep(retryAfter - delay)
+				delay = retryAfter
+			}
+			logging.Warnf("[%s] API rate limit has been exceeded (%d): retry in %s", ac.name, response.StatusCode, delay)
+			continue
+		}
+
+		ac.speedUp()
+		if response.StatusCode/100 != 2 {
+			return nil, &apiError{StatusCode: response.StatusCode, Body: string(body)}
+		}
+		return body, nil
+	}
+}
+
+func (ac *apiClient) isRateLimited(statusCode int, body []byte) bool {
+	if statusCode == http.StatusTooManyRequests || statusCode == http.StatusServiceUnavailable {
+		return true
+	}
+	return ac.rateLimited != nil && ac.rateLimited(statusCode, body)
+}
+
+func (ac *apiClient) currentDelay() time.Duration {
+	ac.Lock()
+	defer ac.Unlock()
+	return ac.delay
+}
+
+//slowDown double the delay and return it
+func (ac *apiClient) slowDown() time.Duration {
+	ac.Lock()
+	defer ac.Unlock()
+
+	ac.delay *= 2
+	if ac.delay < minAPIDelay {
+		ac.delay = minAPIDelay
+	}
+	if ac.delay > maxAPIDelay {
+		ac.delay = maxAPIDelay
+	}
+	return ac.delay
+}
+
+//speedUp halve the delay. Short delays are reset
+func (ac *apiClient) speedUp() {
+	ac.Lock()
+	defer ac.Unlock()
+
+	ac.delay /= 2
+	if ac.delay < minAPIDelay/4 {
+		ac.delay = 0
+	}
+}
+
+//parseRetryAfter return Retry-After header duration (seconds or HTTP date) or 0
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
@@ -0,0 +1,54 @@
+package drivers
+
+import (
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAPIClientBackoff(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch requests {
+		case 1:
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 2:
+			w.Header().Set("Retry-After", "3")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 3:
+			w.Write([]byte("ok"))
+		case 4:
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("bad request"))
+		}
+	}))
+	defer server.Close()
+
+	var sleeps []time.Duration
+	client := newAPIClient("test")
+	client.sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+	newRequest := func() (*http.Request, error) { return http.NewRequest(http.MethodGet, server.URL, nil) }
+
+	body, err := client.do(newRequest)
+	require.NoError(t, err)
+	require.Equal(t, "ok", string(body))
+	//delay after 429, Retry-After remainder after 503 and doubled delay before the third request
+	require.Equal(t, []time.Duration{minAPIDelay, 2 * time.Second, 2 * minAPIDelay}, sleeps)
+	//successful response halves the delay
+	require.Equal(t, minAPIDelay, client.currentDelay())
+
+	//too long Retry-After isn't waited
+	_, err = client.do(newRequest)
+	require.Error(t, err)
+	require.Equal(t, http.StatusTooManyRequests, err.(*apiError).StatusCode)
+
+	_, err = client.do(newRequest)
+	require.EqualError(t, err, "responded with 400: bad request")
+	require.Equal(t, minAPIDelay/2, client.currentDelay())
+}
@@ -0,0 +1,102 @@
+package drivers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+//Collection is a connector collection description (see Connector.Discover)
+type Collection struct {
+	Name string `json:"name"`
+	//true if objects are read incrementally from the cursor state, otherwise every sync reads all objects
+	Incremental bool `json:"incremental"`
+	//object field which is used as the incremental cursor (e.g. updated_at)
+	CursorField      string   `json:"cursor_field,omitempty"`
+	PrimaryKeyFields []string `json:"primary_key_fields,omitempty"`
+}
+
+//ReadResult is a page of collection objects with the cursor state after it
+type ReadResult struct {
+	Objects []map[string]interface{}
+	//opaque connector state: it is persisted after objects are stored and passed to the next Read
+	State string
+	//true if there are more objects: Read is called again with State in the same sync
+	HasMore bool
+}
+
+//Connector is an API-pulling source. Collections objects are read page by page from the persisted cursor state: the state
+//is saved after every stored page, so interrupted syncs are continued and next syncs are incremental.
+//Full refresh collections return empty state with the last page
+type Connector interface {
+	io.Closer
+	//Discover return collections which can be synchronized
+	Discover() ([]*Collection, error)
+	//Read return the next page of the collection objects after the state. Empty state means the first (full) sync
+	Read(collection, state string) (*ReadResult, error)
+
+	Type() string
+}
+
+//ConnectorFactory return a connector of the source config section (see SourceConfig.Config)
+type ConnectorFactory func(ctx context.Context, sourceId string, config map[string]interface{}) (Connector, error)
+
+var (
+	connectorsMutex    sync.RWMutex
+	connectorFactories = map[string]ConnectorFactory{}
+)
+
+//RegisterConnector register the connector type factory. It is called in init() of connector implementations
+func RegisterConnector(sourceType string, factory ConnectorFactory) {
+	connectorsMutex.Lock()
+	defer connectorsMutex.Unlock()
+
+	if _, ok := connectorFactories[sourceType]; ok {
+		panic(fmt.Sprintf("Connector [%s] has been already registered", sourceType))
+	}
+	connectorFactories[sourceType] = factory
+}
+
+//IsConnector return true if the source type is a registered connector
+func IsConnector(sourceType string) bool {
+	connectorsMutex.RLock()
+	defer connectorsMutex.RUnlock()
+
+	_, ok := connectorFactories[sourceType]
+	return ok
+}
+
+//ConnectorTypes return sorted registered connectors types
+func ConnectorTypes() []string {
+	connectorsMutex.RLock()
+	defer connectorsMutex.RUnlock()
+
+	types := make([]string, 0, len(connectorFactories))
+	for sourceType := range connectorFactories {
+		types = append(types, sourceType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+//CreateConnector return the source connector. Source type is the source name if it isn't set
+func CreateConnector(ctx context.Context, name string, sourceConfig *SourceConfig) (Connector, error) {
+	if err := prepare(name, sourceConfig); err != nil {
+		return nil, err
+	}
+
+	connectorsMutex.RLock()
+	factory, ok := connectorFactories[sourceConfig.Type]
+	connectorsMutex.RUnlock()
+	if !ok {
+		return nil, unknownSource
+	}
+
+	connector, err := factory(ctx, name, sourceConfig.Config)
+	if err != nil {
+		return nil, fmt.Errorf("error creating [%s] connector: %v", sourceConfig.Type, err)
+	}
+	return connector, nil
+}
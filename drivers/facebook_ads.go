@@ -0,0 +1,217 @@
+package drivers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	facebookGraphURL          = "https://graph.facebook.com"
+	defaultFacebookAPIVersion = "v8.0"
+	facebookPageSize          = "500"
+)
+
+//facebookAdsLevels are insights levels (collections) with their objects id fields
+var facebookAdsLevels = map[string]string{
+	"campaign": "campaign_id",
+	"adset":    "adset_id",
+	"ad":       "ad_id",
+}
+
+var defaultFacebookAdsFields = []string{"account_id", "campaign_id", "campaign_name", "adset_id", "adset_name", "ad_id", "ad_name",
+	"impressions", "reach", "clicks", "spend", "cpc", "cpm", "ctr", "actions"}
+
+//facebookRateLimitCodes are Graph API throttling error codes
+var facebookRateLimitCodes = map[int]bool{4: true, 17: true, 32: true, 613: true}
+
+func init() {
+	RegisterConnector(FacebookAdsType, NewFacebookAdsConnector)
+}
+
+//FacebookAdsConfig is a Facebook Ads source configuration. Collections are insights levels: campaign, adset, ad
+type FacebookAdsConfig struct {
+	ReportWindowConfig `mapstructure:",squash" yaml:",inline"`
+
+	//ad account id (without act_ prefix)
+	AccountId   string `mapstructure:"account_id" json:"account_id,omitempty" yaml:"account_id,omitempty"`
+	AccessToken string `mapstructure:"access_token" json:"access_token,omitempty" yaml:"access_token,omitempty"`
+	APIVersion  string `mapstructure:"api_version" json:"api_version,omitempty" yaml:"api_version,omitempty"`
+	//insights fields (default: ids, names and main metrics)
+	Fields []string `mapstructure:"fields" json:"fields,omitempty" yaml:"fields,omitempty"`
+	//Optional. insights breakdowns (e.g. age, gender, country, publisher_platform): they are a part of rows keys
+	Breakdowns []string `mapstructure:"breakdowns" json:"breakdowns,omitempty" yaml:"breakdowns,omitempty"`
+}
+
+func (fac *FacebookAdsConfig) Validate() error {
+	if fac == nil {
+		return errors.New("FacebookAds config is required")
+	}
+	if fac.AccountId == "" {
+		return errors.New("FacebookAds account_id is required")
+	}
+	fac.AccountId = strings.TrimPrefix(fac.AccountId, "act_")
+	if fac.AccessToken == "" {
+		return errors.New("FacebookAds access_token is required")
+	}
+	if fac.APIVersion == "" {
+		fac.APIVersion = defaultFacebookAPIVersion
+	}
+	if len(fac.Fields) == 0 {
+		fac.Fields = defaultFacebookAdsFields
+	}
+	return fac.ReportWindowConfig.validate("FacebookAds")
+}
+
+type facebookInsightsResponse struct {
+	Data   []map[string]interface{} `json:"data"`
+	Paging struct {
+		Next string `json:"next"`
+	} `json:"paging"`
+}
+
+//FacebookAds is a connector which reads daily ads insights day by day. Every sync re-reads the attribution window days
+//Rows event ids are hashes of date_start, account_id, the level id and breakdowns values (see enrichWithReportKey)
+type FacebookAds struct {
+	ctx    context.Context
+	config *FacebookAdsConfig
+	client *apiClient
+	apiURL string
+}
+
+//NewFacebookAdsConnector is a ConnectorFactory of Facebook Ads connector
+func NewFacebookAdsConnector(ctx context.Context, sourceId string, config map[string]interface{}) (Connector, error) {
+	faConfig := &FacebookAdsConfig{}
+	if err := unmarshalConfig(config, faConfig); err != nil {
+		return nil, err
+	}
+	if err := faConfig.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &FacebookAds{ctx: ctx, config: faConfig, client: newFacebookAPIClient(), apiURL: facebookGraphURL}, nil
+}
+
+//newFacebookAPIClient return apiClient which backs off Graph API throttling errors (they are returned with 400 and 403 statuses)
+func newFacebookAPIClient() *apiClient {
+	client := newAPIClient("FacebookAds")
+	client.rateLimited = func(statusCode int, body []byte) bool {
+		response := &struct {
+			Error struct {
+				Code int `json:"code"`
+			} `json:"error"`
+		}{}
+		if err := json.Unmarshal(body, response); err != nil {
+			return false
+		}
+		code := response.Error.Code
+		return facebookRateLimitCodes[code] || (code >= 80000 && code <= 80014)
+	}
+	return client
+}
+
+func (fa *FacebookAds) Discover() ([]*Collection, error) {
+	var collections []*Collection
+	for _, level := range []string{"campaign", "adset", "ad"} {
+		collections = append(collections, &Collection{Name: level, Incremental: true, CursorField: "date_start", PrimaryKeyFields: fa.keyFields(level)})
+	}
+	return collections, nil
+}
+
+func (fa *FacebookAds) Read(collection, state string) (*ReadResult, error) {
+	if _, ok := facebookAdsLevels[collection]; !ok {
+		return nil, fmt.Errorf("FacebookAds collection [%s] isn't supported. Available: [campaign, adset, ad]", collection)
+	}
+
+	today := reportToday()
+	day, cursor, err := fa.config.reportDay(state, today)
+	if err != nil {
+		return nil, fmt.Errorf("FacebookAds %v", err)
+	}
+
+	rows, err := fa.insights(collection, day.Format(reportDayLayout))
+	if err != nil {
+		return nil, fmt.Errorf("FacebookAds error reading [%s] insights: %v", collection, err)
+	}
+
+	keyFields := fa.keyFields(collection)
+	for _, row := range rows {
+		enrichWithReportKey(FacebookAdsType, collection, row, keyFields)
+	}
+
+	nextState, hasMore, err := fa.config.nextState(day, today, cursor)
+	if err != nil {
+		return nil, fmt.Errorf("FacebookAds error marshalling state: %v", err)
+	}
+	return &ReadResult{Objects: rows, State: nextState, HasMore: hasMore}, nil
+}
+
+//insights return all insights rows of the day: GET /act_<id>/insights (pages are followed with paging.next)
+func (fa *FacebookAds) insights(level, day string) ([]map[string]interface{}, error) {
+	fields := append([]string{}, fa.config.Fields...)
+	for _, field := range []string{"account_id", facebookAdsLevels[level]} {
+		if !contains(fields, field) {
+			fields = append(fields, field)
+		}
+	}
+
+	query := url.Values{}
+	query.Set("level", level)
+	query.Set("fields", strings.Join(fields, ","))
+	query.Set("time_range", fmt.Sprintf(`{"since":"%s","until":"%s"}`, day, day))
+	query.Set("time_increment", "1")
+	query.Set("limit", facebookPageSize)
+	if len(fa.config.Breakdowns) > 0 {
+		query.Set("breakdowns", strings.Join(fa.config.Breakdowns, ","))
+	}
+	requestURL := fmt.Sprintf("%s/%s/act_%s/insights?%s", strings.TrimRight(fa.apiURL, "/"), fa.config.APIVersion, fa.config.AccountId, query.Encode())
+
+	var rows []map[string]interface{}
+	for requestURL != "" {
+		body, err := fa.client.do(func() (*http.Request, error) {
+			request, err := http.NewRequestWithContext(fa.ctx, http.MethodGet, requestURL, nil)
+			if err != nil {
+				return nil, err
+			}
+			request.Header.Set("Authorization", "Bearer "+fa.config.AccessToken)
+			return request, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		response := &facebookInsightsResponse{}
+		if err := json.Unmarshal(body, response); err != nil {
+			return nil, fmt.Errorf("Error parsing response: %v", err)
+		}
+		rows = append(rows, response.Data...)
+		requestURL = response.Paging.Next
+	}
+	return rows, nil
+}
+
+//keyFields return the level rows key: day, account, the level object id and breakdowns
+func (fa *FacebookAds) keyFields(level string) []string {
+	return append([]string{"date_start", "account_id", facebookAdsLevels[level]}, fa.config.Breakdowns...)
+}
+
+func (fa *FacebookAds) Type() string {
+	return FacebookAdsType
+}
+
+func (fa *FacebookAds) Close() error {
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
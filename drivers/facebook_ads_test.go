@@ -0,0 +1,59 @@
+package drivers
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFacebookAdsRead(t *testing.T) {
+	var server *httptest.Server
+	var requests []*http.Request
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v8.0/act_123/insights", r.URL.Path)
+		require.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		requests = append(requests, r)
+		switch {
+		case len(requests) == 1:
+			//throttled
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":{"code":17,"message":"User request limit reached"}}`))
+		case r.URL.Query().Get("after") == "":
+			w.Write([]byte(`{"data":[{"date_start":"2020-09-09","account_id":"123","ad_id":"1","age":"18-24","clicks":"5"}],"paging":{"next":"` + server.URL + `/v8.0/act_123/insights?after=c1"}}`))
+		default:
+			w.Write([]byte(`{"data":[{"date_start":"2020-09-09","account_id":"123","ad_id":"1","age":"25-34","clicks":"7"}],"paging":{}}`))
+		}
+	}))
+	defer server.Close()
+
+	config := &FacebookAdsConfig{AccountId: "act_123", AccessToken: "token", Fields: []string{"clicks"}, Breakdowns: []string{"age"}}
+	config.StartDate = reportToday().AddDate(0, 0, -1).Format(reportDayLayout)
+	require.NoError(t, config.Validate())
+	client := newFacebookAPIClient()
+	client.sleep = func(time.Duration) {}
+	facebookAds := &FacebookAds{ctx: context.Background(), config: config, client: client, apiURL: server.URL}
+
+	result, err := facebookAds.Read("ad", "")
+	require.NoError(t, err)
+	require.Len(t, result.Objects, 2)
+	require.True(t, result.HasMore)
+	require.NotEqual(t, result.Objects[0]["eventn_ctx"], result.Objects[1]["eventn_ctx"])
+
+	query := requests[1].URL.Query()
+	require.Equal(t, "ad", query.Get("level"))
+	require.Equal(t, "clicks,account_id,ad_id", query.Get("fields"))
+	require.Equal(t, "age", query.Get("breakdowns"))
+	require.Equal(t, "1", query.Get("time_increment"))
+	require.Equal(t, `{"since":"`+config.StartDate+`","until":"`+config.StartDate+`"}`, query.Get("time_range"))
+	require.Len(t, requests, 3)
+
+	collections, err := facebookAds.Discover()
+	require.NoError(t, err)
+	require.Equal(t, []string{"date_start", "account_id", "adset_id", "age"}, collections[1].PrimaryKeyFields)
+
+	_, err = facebookAds.Read("account", "")
+	require.Error(t, err)
+}
@@ -14,6 +14,8 @@ type SourceConfig struct {
 	Type         string   `mapstructure:"type" json:"type,omitempty" yaml:"type,omitempty"`
 	Destinations []string `mapstructure:"destinations" json:"destinations,omitempty" yaml:"destinations,omitempty"`
 	Collections  []string `mapstructure:"collections" json:"collections,omitempty" yaml:"collections,omitempty"`
+	//cron expression of scheduled syncs (see cron.Parse). Empty - source is synchronized only via API
+	Schedule string `mapstructure:"schedule" json:"schedule,omitempty" yaml:"schedule,omitempty"`
 
 	Config map[string]interface{} `mapstructure:"config" json:"config,omitempty" yaml:"config,omitempty"`
 }
@@ -21,16 +23,8 @@ type SourceConfig struct {
 //Create source drivers per collection
 //Enrich incoming configs with default values if needed
 func Create(ctx context.Context, name string, sourceConfig *SourceConfig) (map[string]Driver, error) {
-	if sourceConfig.Type == "" {
-		sourceConfig.Type = name
-	}
-
-	logging.Infof("[%s] Initializing source of type: %s", name, sourceConfig.Type)
-	if len(sourceConfig.Collections) == 0 {
-		return nil, errors.New("collections are empty. Please specify at least one collection")
-	}
-	if len(sourceConfig.Destinations) == 0 {
-		return nil, errors.New("destinations are empty. Please specify at least one destination")
+	if err := prepare(name, sourceConfig); err != nil {
+		return nil, err
 	}
 
 	driverPerCollection := map[string]Driver{}
@@ -76,6 +70,22 @@ func Create(ctx context.Context, name string, sourceConfig *SourceConfig) (map[s
 	}
 }
 
+//prepare put the default source type (source name) and validate collections and destinations
+func prepare(name string, sourceConfig *SourceConfig) error {
+	if sourceConfig.Type == "" {
+		sourceConfig.Type = name
+	}
+
+	logging.Infof("[%s] Initializing source of type: %s", name, sourceConfig.Type)
+	if len(sourceConfig.Collections) == 0 {
+		return errors.New("collections are empty. Please specify at least one collection")
+	}
+	if len(sourceConfig.Destinations) == 0 {
+		return errors.New("destinations are empty. Please specify at least one destination")
+	}
+	return nil
+}
+
 func unmarshalConfig(config map[string]interface{}, object interface{}) error {
 	b, err := json.Marshal(config)
 	if err != nil {
@@ -0,0 +1,301 @@
+package drivers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const (
+	googleAdsAPIURL          = "https://googleads.googleapis.com"
+	googleOAuthTokenURL      = "https://oauth2.googleapis.com/token"
+	defaultGoogleAdsVersion  = "v6"
+	googleAdsDateSegment     = "segments.date"
+	googleAdsCustomerIdField = "customer.id"
+)
+
+//googleAdsLevel is a report level (collection): GAQL resource and its attribute fields. The last one is the level id
+type googleAdsLevel struct {
+	resource string
+	fields   []string
+}
+
+var googleAdsLevels = map[string]googleAdsLevel{
+	"campaign": {resource: "campaign", fields: []string{"campaign.name", "campaign.id"}},
+	"ad_group": {resource: "ad_group", fields: []string{"campaign.id", "campaign.name", "ad_group.name", "ad_group.id"}},
+	"ad":       {resource: "ad_group_ad", fields: []string{"campaign.id", "campaign.name", "ad_group.id", "ad_group.name", "ad_group_ad.ad.name", "ad_group_ad.ad.id"}},
+}
+
+var defaultGoogleAdsMetrics = []string{"impressions", "clicks", "cost_micros", "conversions", "conversions_value"}
+
+func init() {
+	RegisterConnector(GoogleAdsType, NewGoogleAdsConnector)
+}
+
+//GoogleAdsConfig is a Google Ads source configuration. Collections are report levels: campaign, ad_group, ad
+//OAuth refresh token of a user with access to the customer account is required
+type GoogleAdsConfig struct {
+	ReportWindowConfig `mapstructure:",squash" yaml:",inline"`
+
+	CustomerId string `mapstructure:"customer_id" json:"customer_id,omitempty" yaml:"customer_id,omitempty"`
+	//Optional. manager account id if the customer is accessed through it
+	LoginCustomerId string `mapstructure:"login_customer_id" json:"login_customer_id,omitempty" yaml:"login_customer_id,omitempty"`
+	DeveloperToken  string `mapstructure:"developer_token" json:"developer_token,omitempty" yaml:"developer_token,omitempty"`
+	ClientId        string `mapstructure:"client_id" json:"client_id,omitempty" yaml:"client_id,omitempty"`
+	ClientSecret    string `mapstructure:"client_secret" json:"client_secret,omitempty" yaml:"client_secret,omitempty"`
+	RefreshToken    string `mapstructure:"refresh_token" json:"refresh_token,omitempty" yaml:"refresh_token,omitempty"`
+	APIVersion      string `mapstructure:"api_version" json:"api_version,omitempty" yaml:"api_version,omitempty"`
+	//metrics names without metrics. prefix (default: impressions, clicks, cost_micros, conversions, conversions_value)
+	Metrics []string `mapstructure:"metrics" json:"metrics,omitempty" yaml:"metrics,omitempty"`
+	//Optional. segments names without segments. prefix (e.g. device, ad_network_type): they are a part of rows keys
+	Segments []string `mapstructure:"segments" json:"segments,omitempty" yaml:"segments,omitempty"`
+}
+
+func (gac *GoogleAdsConfig) Validate() error {
+	if gac == nil {
+		return errors.New("GoogleAds config is required")
+	}
+	gac.CustomerId = strings.ReplaceAll(gac.CustomerId, "-", "")
+	gac.LoginCustomerId = strings.ReplaceAll(gac.LoginCustomerId, "-", "")
+	if gac.CustomerId == "" {
+		return errors.New("GoogleAds customer_id is required")
+	}
+	if gac.DeveloperToken == "" {
+		return errors.New("GoogleAds developer_token is required")
+	}
+	if gac.ClientId == "" || gac.ClientSecret == "" || gac.RefreshToken == "" {
+		return errors.New("GoogleAds client_id, client_secret and refresh_token are required")
+	}
+	if gac.APIVersion == "" {
+		gac.APIVersion = defaultGoogleAdsVersion
+	}
+	if len(gac.Metrics) == 0 {
+		gac.Metrics = defaultGoogleAdsMetrics
+	}
+	return gac.ReportWindowConfig.validate("GoogleAds")
+}
+
+//GoogleAds is a connector which reads daily performance reports (GAQL searchStream) day by day. Every sync re-reads
+//the attribution window days. Rows fields are flattened in snake case (e.g. metrics_cost_micros, ad_group_id) and
+//event ids are hashes of customer, date, the level id and segments values (see enrichWithReportKey)
+type GoogleAds struct {
+	ctx      context.Context
+	config   *GoogleAdsConfig
+	client   *apiClient
+	apiURL   string
+	tokenURL string
+
+	tokenMutex  sync.Mutex
+	accessToken string
+	expiresAt   time.Time
+}
+
+//NewGoogleAdsConnector is a ConnectorFactory of Google Ads connector
+func NewGoogleAdsConnector(ctx context.Context, sourceId string, config map[string]interface{}) (Connector, error) {
+	gaConfig := &GoogleAdsConfig{}
+	if err := unmarshalConfig(config, gaConfig); err != nil {
+		return nil, err
+	}
+	if err := gaConfig.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &GoogleAds{ctx: ctx, config: gaConfig, client: newAPIClient("GoogleAds"), apiURL: googleAdsAPIURL, tokenURL: googleOAuthTokenURL}, nil
+}
+
+func (ga *GoogleAds) Discover() ([]*Collection, error) {
+	var collections []*Collection
+	for _, level := range []string{"campaign", "ad_group", "ad"} {
+		collections = append(collections, &Collection{Name: level, Incremental: true, CursorField: columnName(googleAdsDateSegment), PrimaryKeyFields: ga.keyFields(level)})
+	}
+	return collections, nil
+}
+
+func (ga *GoogleAds) Read(collection, state string) (*ReadResult, error) {
+	if _, ok := googleAdsLevels[collection]; !ok {
+		return nil, fmt.Errorf("GoogleAds collection [%s] isn't supported. Available: [campaign, ad_group, ad]", collection)
+	}
+
+	today := reportToday()
+	day, cursor, err := ga.config.reportDay(state, today)
+	if err != nil {
+		return nil, fmt.Errorf("GoogleAds %v", err)
+	}
+
+	rows, err := ga.searchStream(ga.query(collection, day.Format(reportDayLayout)))
+	if err != nil {
+		return nil, fmt.Errorf("GoogleAds error reading [%s] report: %v", collection, err)
+	}
+
+	keyFields := ga.keyFields(collection)
+	for _, row := range rows {
+		enrichWithReportKey(GoogleAdsType, collection, row, keyFields)
+	}
+
+	nextState, hasMore, err := ga.config.nextState(day, today, cursor)
+	if err != nil {
+		return nil, fmt.Errorf("GoogleAds error marshalling state: %v", err)
+	}
+	return &ReadResult{Objects: rows, State: nextState, HasMore: hasMore}, nil
+}
+
+//query return GAQL of the level report of the day
+func (ga *GoogleAds) query(level, day string) string {
+	fields := []string{googleAdsCustomerIdField, googleAdsDateSegment}
+	fields = append(fields, googleAdsLevels[level].fields...)
+	for _, segment := range ga.config.Segments {
+		fields = append(fields, "segments."+segment)
+	}
+	for _, metric := range ga.config.Metrics {
+		fields = append(fields, "metrics."+metric)
+	}
+	return fmt.Sprintf("SELECT %s FROM %s WHERE %s = '%s'", strings.Join(fields, ", "), googleAdsLevels[level].resource, googleAdsDateSegment, day)
+}
+
+//searchStream run the query and return flattened rows: POST /<version>/customers/<id>/googleAds:searchStream
+func (ga *GoogleAds) searchStream(query string) ([]map[string]interface{}, error) {
+	accessToken, err := ga.token()
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := json.Marshal(map[string]string{"query": query})
+	if err != nil {
+		return nil, err
+	}
+	requestURL := fmt.Sprintf("%s/%s/customers/%s/googleAds:searchStream", strings.TrimRight(ga.apiURL, "/"), ga.config.APIVersion, ga.config.CustomerId)
+	body, err := ga.client.do(func() (*http.Request, error) {
+		request, err := http.NewRequestWithContext(ga.ctx, http.MethodPost, requestURL, bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set("Authorization", "Bearer "+accessToken)
+		request.Header.Set("developer-token", ga.config.DeveloperToken)
+		if ga.config.LoginCustomerId != "" {
+			request.Header.Set("login-customer-id", ga.config.LoginCustomerId)
+		}
+		return request, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var batches []struct {
+		Results []map[string]interface{} `json:"results"`
+	}
+	if err := json.Unmarshal(body, &batches); err != nil {
+		return nil, fmt.Errorf("Error parsing response: %v", err)
+	}
+
+	var rows []map[string]interface{}
+	for _, batch := range batches {
+		for _, result := range batch.Results {
+			row := map[string]interface{}{}
+			flattenGoogleAdsRow("", result, row)
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+//token return cached or refreshed OAuth access token
+func (ga *GoogleAds) token() (string, error) {
+	ga.tokenMutex.Lock()
+	defer ga.tokenMutex.Unlock()
+
+	if ga.accessToken != "" && time.Now().Before(ga.expiresAt) {
+		return ga.accessToken, nil
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "refresh_token")
+	form.Set("client_id", ga.config.ClientId)
+	form.Set("client_secret", ga.config.ClientSecret)
+	form.Set("refresh_token", ga.config.RefreshToken)
+	body, err := ga.client.do(func() (*http.Request, error) {
+		request, err := http.NewRequestWithContext(ga.ctx, http.MethodPost, ga.tokenURL, strings.NewReader(form.Encode()))
+		if err != nil {
+			return nil, err
+		}
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return request, nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("GoogleAds error refreshing access token: %v", err)
+	}
+
+	token := &struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}{}
+	if err := json.Unmarshal(body, token); err != nil {
+		return "", fmt.Errorf("GoogleAds error parsing access token response: %v", err)
+	}
+	if token.AccessToken == "" {
+		return "", errors.New("GoogleAds access token response doesn't contain access_token")
+	}
+
+	//refresh a minute before the expiration
+	ga.accessToken = token.AccessToken
+	ga.expiresAt = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute)
+	return ga.accessToken, nil
+}
+
+//keyFields return the level rows key: customer, day, the level object id and segments
+func (ga *GoogleAds) keyFields(level string) []string {
+	levelFields := googleAdsLevels[level].fields
+	keyFields := []string{columnName(googleAdsCustomerIdField), columnName(googleAdsDateSegment), columnName(levelFields[len(levelFields)-1])}
+	for _, segment := range ga.config.Segments {
+		keyFields = append(keyFields, columnName("segments."+segment))
+	}
+	return keyFields
+}
+
+func (ga *GoogleAds) Type() string {
+	return GoogleAdsType
+}
+
+func (ga *GoogleAds) Close() error {
+	return nil
+}
+
+//columnName return flattened row field name of GAQL field: ad_group_ad.ad.id -> ad_group_ad_ad_id
+func columnName(field string) string {
+	return strings.ReplaceAll(field, ".", "_")
+}
+
+//flattenGoogleAdsRow put nested camel case JSON fields into row with snake case names joined by _
+//(adGroup.id -> ad_group_id) like GAQL fields names
+func flattenGoogleAdsRow(prefix string, object map[string]interface{}, row map[string]interface{}) {
+	for key, value := range object {
+		name := prefix + snakeCase(key)
+		if nested, ok := value.(map[string]interface{}); ok {
+			flattenGoogleAdsRow(name+"_", nested, row)
+			continue
+		}
+		row[name] = value
+	}
+}
+
+func snakeCase(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,65 @@
+package drivers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGoogleAdsRead(t *testing.T) {
+	var queries []string
+	tokens := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			require.NoError(t, r.ParseForm())
+			require.Equal(t, "refresh", r.Form.Get("refresh_token"))
+			tokens++
+			w.Write([]byte(`{"access_token":"token","expires_in":3600}`))
+		case "/v6/customers/1234567890/googleAds:searchStream":
+			require.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+			require.Equal(t, "dev", r.Header.Get("developer-token"))
+			require.Equal(t, "111", r.Header.Get("login-customer-id"))
+			body := map[string]string{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			queries = append(queries, body["query"])
+			w.Write([]byte(`[{"results":[{"customer":{"id":"1234567890"},"segments":{"date":"2020-09-09","device":"MOBILE"},"adGroup":{"id":"7","name":"g"},"campaign":{"id":"5"},"metrics":{"clicks":"3","costMicros":"1500000"}}]}]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	config := &GoogleAdsConfig{CustomerId: "123-456-7890", LoginCustomerId: "111", DeveloperToken: "dev", ClientId: "id", ClientSecret: "secret",
+		RefreshToken: "refresh", Metrics: []string{"clicks", "cost_micros"}, Segments: []string{"device"}}
+	config.StartDate = reportToday().Format(reportDayLayout)
+	require.NoError(t, config.Validate())
+	googleAds := &GoogleAds{ctx: context.Background(), config: config, client: newAPIClient("GoogleAds"), apiURL: server.URL, tokenURL: server.URL + "/token"}
+
+	result, err := googleAds.Read("ad_group", "")
+	require.NoError(t, err)
+	require.False(t, result.HasMore)
+	require.Equal(t, `{"synced_until":"`+config.StartDate+`"}`, result.State)
+	require.Len(t, result.Objects, 1)
+	row := result.Objects[0]
+	require.NotNil(t, row["eventn_ctx"])
+	delete(row, "eventn_ctx")
+	require.Equal(t, map[string]interface{}{"customer_id": "1234567890", "segments_date": "2020-09-09", "segments_device": "MOBILE", "ad_group_id": "7",
+		"ad_group_name": "g", "campaign_id": "5", "metrics_clicks": "3", "metrics_cost_micros": "1500000"}, row)
+
+	_, err = googleAds.Read("ad_group", result.State)
+	require.NoError(t, err)
+	require.Equal(t, 1, tokens)
+	require.Equal(t, "SELECT customer.id, segments.date, campaign.id, campaign.name, ad_group.name, ad_group.id, segments.device, metrics.clicks, metrics.cost_micros "+
+		"FROM ad_group WHERE segments.date = '"+config.StartDate+"'", queries[0])
+
+	collections, err := googleAds.Discover()
+	require.NoError(t, err)
+	require.Equal(t, []string{"customer_id", "segments_date", "ad_group_ad_ad_id", "segments_device"}, collections[2].PrimaryKeyFields)
+
+	_, err = googleAds.Read("keyword", "")
+	require.Error(t, err)
+}
@@ -61,32 +61,40 @@ func (gpc *GooglePlayConfig) Validate() error {
 		return errors.New("GooglePlay account_id is required")
 	}
 
-	switch gpc.KeyFile.(type) {
+	credentials, err := googleCredentials("GooglePlay", gpc.KeyFile)
+	if err != nil {
+		return err
+	}
+	gpc.credentials = credentials
+
+	return nil
+}
+
+//googleCredentials return client option of key_file: service account JSON object, JSON string or file path
+func googleCredentials(sourceName string, keyFile interface{}) (option.ClientOption, error) {
+	switch keyFile.(type) {
 	case map[string]interface{}:
-		keyFileObject := gpc.KeyFile.(map[string]interface{})
+		keyFileObject := keyFile.(map[string]interface{})
 		if len(keyFileObject) == 0 {
-			return errors.New("GooglePlay key_file is required parameter")
+			return nil, fmt.Errorf("%s key_file is required parameter", sourceName)
 		}
 		b, err := json.Marshal(keyFileObject)
 		if err != nil {
-			return fmt.Errorf("GooglePlay malformed key_file: %v", err)
+			return nil, fmt.Errorf("%s malformed key_file: %v", sourceName, err)
 		}
-		gpc.credentials = option.WithCredentialsJSON(b)
+		return option.WithCredentialsJSON(b), nil
 	case string:
-		keyFile := gpc.KeyFile.(string)
-		if keyFile == "" {
-			return errors.New("GooglePlay key file is required parameter")
+		keyFilePath := keyFile.(string)
+		if keyFilePath == "" {
+			return nil, fmt.Errorf("%s key file is required parameter", sourceName)
 		}
-		if strings.Contains(keyFile, "{") {
-			gpc.credentials = option.WithCredentialsJSON([]byte(keyFile))
-		} else {
-			gpc.credentials = option.WithCredentialsFile(keyFile)
+		if strings.Contains(keyFilePath, "{") {
+			return option.WithCredentialsJSON([]byte(keyFilePath)), nil
 		}
+		return option.WithCredentialsFile(keyFilePath), nil
 	default:
-		return errors.New("GooglePlay key_file must be string or json object")
+		return nil, fmt.Errorf("%s key_file must be string or json object", sourceName)
 	}
-
-	return nil
 }
 
 type GooglePlay struct {
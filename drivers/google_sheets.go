@@ -0,0 +1,223 @@
+package drivers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"google.golang.org/api/option"
+	"google.golang.org/api/sheets/v4"
+	"strconv"
+	"strings"
+)
+
+const (
+	//SheetRowKey is a sheet row number of the object
+	SheetRowKey = "_sheet_row"
+
+	defaultSheetsPageSize = 1000
+)
+
+func init() {
+	RegisterConnector(GoogleSheetsType, NewGoogleSheetsConnector)
+}
+
+//GoogleSheetsConfig is a Google Sheets source configuration. Collections are sheets (tabs) names
+//The spreadsheet must be shared with the service account of key_file
+type GoogleSheetsConfig struct {
+	SpreadsheetId string      `mapstructure:"spreadsheet_id" json:"spreadsheet_id,omitempty" yaml:"spreadsheet_id,omitempty"`
+	KeyFile       interface{} `mapstructure:"key_file" json:"key_file,omitempty" yaml:"key_file,omitempty"`
+	//sheet row number with field names (default 1). Rows after it are objects
+	HeaderRow int `mapstructure:"header_row" json:"header_row,omitempty" yaml:"header_row,omitempty"`
+	//rows per read request (default 1000)
+	PageSize int `mapstructure:"page_size" json:"page_size,omitempty" yaml:"page_size,omitempty"`
+	//if true - all rows are read every sync, otherwise only rows after the last synced one (appended rows)
+	FullRefresh bool `mapstructure:"full_refresh" json:"full_refresh,omitempty" yaml:"full_refresh,omitempty"`
+
+	//will be set on validation
+	credentials option.ClientOption
+}
+
+func (gsc *GoogleSheetsConfig) Validate() error {
+	if gsc == nil {
+		return errors.New("GoogleSheets config is required")
+	}
+	if gsc.SpreadsheetId == "" {
+		return errors.New("GoogleSheets spreadsheet_id is required")
+	}
+	if gsc.HeaderRow < 0 {
+		return errors.New("GoogleSheets header_row must be positive")
+	}
+	if gsc.HeaderRow == 0 {
+		gsc.HeaderRow = 1
+	}
+	if gsc.PageSize <= 0 {
+		gsc.PageSize = defaultSheetsPageSize
+	}
+
+	credentials, err := googleCredentials("GoogleSheets", gsc.KeyFile)
+	if err != nil {
+		return err
+	}
+	gsc.credentials = credentials
+	return nil
+}
+
+//sheetsClient is a part of Google Sheets API which is used by the connector
+type sheetsClient interface {
+	//sheetTitles return spreadsheet sheets names
+	sheetTitles() ([]string, error)
+	//values return rows of A1 notation range (trailing empty rows and cells are omitted)
+	values(readRange string) ([][]interface{}, error)
+}
+
+type sheetsApiClient struct {
+	ctx           context.Context
+	service       *sheets.Service
+	spreadsheetId string
+}
+
+func (sac *sheetsApiClient) sheetTitles() ([]string, error) {
+	spreadsheet, err := sac.service.Spreadsheets.Get(sac.spreadsheetId).Fields("sheets.properties.title").Context(sac.ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+
+	var titles []string
+	for _, sheet := range spreadsheet.Sheets {
+		if sheet.Properties != nil {
+			titles = append(titles, sheet.Properties.Title)
+		}
+	}
+	return titles, nil
+}
+
+func (sac *sheetsApiClient) values(readRange string) ([][]interface{}, error) {
+	valueRange, err := sac.service.Spreadsheets.Values.Get(sac.spreadsheetId, readRange).
+		ValueRenderOption("UNFORMATTED_VALUE").DateTimeRenderOption("FORMATTED_STRING").Context(sac.ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+	return valueRange.Values, nil
+}
+
+//GoogleSheets is a connector which reads sheets rows as objects: header row cells are field names
+//(lower case with underscores), rows are read incrementally: the state is the last synced row number
+type GoogleSheets struct {
+	config *GoogleSheetsConfig
+	client sheetsClient
+}
+
+//NewGoogleSheetsConnector is a ConnectorFactory of Google Sheets connector
+func NewGoogleSheetsConnector(ctx context.Context, sourceId string, config map[string]interface{}) (Connector, error) {
+	gsConfig := &GoogleSheetsConfig{}
+	if err := unmarshalConfig(config, gsConfig); err != nil {
+		return nil, err
+	}
+	if err := gsConfig.Validate(); err != nil {
+		return nil, err
+	}
+
+	service, err := sheets.NewService(ctx, gsConfig.credentials, option.WithScopes(sheets.SpreadsheetsReadonlyScope))
+	if err != nil {
+		return nil, fmt.Errorf("GoogleSheets error creating client: %v", err)
+	}
+
+	return &GoogleSheets{config: gsConfig, client: &sheetsApiClient{ctx: ctx, service: service, spreadsheetId: gsConfig.SpreadsheetId}}, nil
+}
+
+func (gs *GoogleSheets) Discover() ([]*Collection, error) {
+	titles, err := gs.client.sheetTitles()
+	if err != nil {
+		return nil, fmt.Errorf("GoogleSheets error getting spreadsheet [%s] sheets: %v", gs.config.SpreadsheetId, err)
+	}
+
+	collections := make([]*Collection, 0, len(titles))
+	for _, title := range titles {
+		collections = append(collections, &Collection{Name: title, Incremental: !gs.config.FullRefresh, CursorField: SheetRowKey, PrimaryKeyFields: []string{SheetRowKey}})
+	}
+	return collections, nil
+}
+
+//Read return the next page of rows after the last synced row (state). Empty rows are skipped
+func (gs *GoogleSheets) Read(collection, state string) (*ReadResult, error) {
+	lastRow := gs.config.HeaderRow
+	if state != "" {
+		parsed, err := strconv.Atoi(state)
+		if err != nil {
+			return nil, fmt.Errorf("GoogleSheets malformed state [%s]: row number is expected", state)
+		}
+		if parsed > lastRow {
+			lastRow = parsed
+		}
+	}
+
+	headerRows, err := gs.client.values(sheetRange(collection, gs.config.HeaderRow, gs.config.HeaderRow))
+	if err != nil {
+		return nil, fmt.Errorf("GoogleSheets error reading [%s] sheet header: %v", collection, err)
+	}
+	if len(headerRows) == 0 || len(headerRows[0]) == 0 {
+		return nil, fmt.Errorf("GoogleSheets [%s] sheet header row %d is empty", collection, gs.config.HeaderRow)
+	}
+	header := sheetHeader(headerRows[0])
+
+	firstRow := lastRow + 1
+	rows, err := gs.client.values(sheetRange(collection, firstRow, firstRow+gs.config.PageSize-1))
+	if err != nil {
+		return nil, fmt.Errorf("GoogleSheets error reading [%s] sheet rows: %v", collection, err)
+	}
+
+	result := &ReadResult{State: strconv.Itoa(lastRow + len(rows)), HasMore: len(rows) == gs.config.PageSize}
+	for i, row := range rows {
+		object := map[string]interface{}{}
+		for j, value := range row {
+			if j >= len(header) || value == nil || value == "" {
+				continue
+			}
+			object[header[j]] = value
+		}
+		if len(object) == 0 {
+			continue
+		}
+
+		object[SheetRowKey] = firstRow + i
+		result.Objects = append(result.Objects, object)
+	}
+
+	//all rows are read again in the next sync
+	if gs.config.FullRefresh && !result.HasMore {
+		result.State = ""
+	}
+	return result, nil
+}
+
+func (gs *GoogleSheets) Type() string {
+	return GoogleSheetsType
+}
+
+func (gs *GoogleSheets) Close() error {
+	return nil
+}
+
+//sheetRange return A1 notation rows range of the sheet
+func sheetRange(sheet string, from, to int) string {
+	return fmt.Sprintf("'%s'!%d:%d", strings.ReplaceAll(sheet, "'", "''"), from, to)
+}
+
+//sheetHeader return field names of header cells: lower case with underscores instead of spaces
+//Empty cells are named column_<n>, duplicates are suffixed with _<n> (n is 1-based column number)
+func sheetHeader(cells []interface{}) []string {
+	header := make([]string, len(cells))
+	used := map[string]bool{}
+	for i, cell := range cells {
+		name := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(fmt.Sprint(cell)), " ", "_"))
+		if name == "" || cell == nil {
+			name = "column_" + strconv.Itoa(i+1)
+		}
+		if used[name] {
+			name += "_" + strconv.Itoa(i+1)
+		}
+		used[name] = true
+		header[i] = name
+	}
+	return header
+}
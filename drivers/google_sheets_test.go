@@ -0,0 +1,99 @@
+package drivers
+
+import (
+	"github.com/stretchr/testify/require"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+var rangeRegex = regexp.MustCompile(`^'(.+)'!(\d+):(\d+)$`)
+
+//sheetsClientMock return rows of sheet (1-based) by A1 rows ranges
+type sheetsClientMock struct {
+	sheets map[string][][]interface{}
+	ranges []string
+}
+
+func (scm *sheetsClientMock) sheetTitles() ([]string, error) {
+	return []string{"Sheet1", "Sheet'2"}, nil
+}
+
+func (scm *sheetsClientMock) values(readRange string) ([][]interface{}, error) {
+	scm.ranges = append(scm.ranges, readRange)
+	parts := rangeRegex.FindStringSubmatch(readRange)
+	from, _ := strconv.Atoi(parts[2])
+	to, _ := strconv.Atoi(parts[3])
+
+	rows := scm.sheets[parts[1]]
+	var result [][]interface{}
+	for i := from; i <= to && i <= len(rows); i++ {
+		result = append(result, rows[i-1])
+	}
+	return result, nil
+}
+
+func TestGoogleSheetsRead(t *testing.T) {
+	client := &sheetsClientMock{sheets: map[string][][]interface{}{
+		"Sheet1": {
+			{"Name", "Order Amount", "", "name"},
+			{"a", 1.5},
+			{},
+			{"b", 2, "x", "B"},
+			{"c"},
+		},
+	}}
+	gs := &GoogleSheets{config: &GoogleSheetsConfig{HeaderRow: 1, PageSize: 2}, client: client}
+
+	result, err := gs.Read("Sheet1", "")
+	require.NoError(t, err)
+	require.Equal(t, []map[string]interface{}{{"name": "a", "order_amount": 1.5, SheetRowKey: 2}}, result.Objects)
+	require.Equal(t, "3", result.State)
+	require.True(t, result.HasMore)
+	require.Equal(t, []string{"'Sheet1'!1:1", "'Sheet1'!2:3"}, client.ranges)
+
+	result, err = gs.Read("Sheet1", result.State)
+	require.NoError(t, err)
+	require.Equal(t, []map[string]interface{}{
+		{"name": "b", "order_amount": 2, "column_3": "x", "name_4": "B", SheetRowKey: 4},
+		{"name": "c", SheetRowKey: 5},
+	}, result.Objects)
+	require.Equal(t, "5", result.State)
+	require.True(t, result.HasMore)
+
+	result, err = gs.Read("Sheet1", result.State)
+	require.NoError(t, err)
+	require.Empty(t, result.Objects)
+	require.Equal(t, "5", result.State)
+	require.False(t, result.HasMore)
+
+	//full refresh: the state is reset with the last page
+	gs.config.FullRefresh = true
+	result, err = gs.Read("Sheet1", "3")
+	require.NoError(t, err)
+	require.Len(t, result.Objects, 2)
+	require.True(t, result.HasMore)
+	result, err = gs.Read("Sheet1", result.State)
+	require.NoError(t, err)
+	require.Equal(t, "", result.State)
+
+	_, err = gs.Read("Sheet1", "abc")
+	require.Error(t, err)
+
+	_, err = gs.Read("Empty", "")
+	require.Error(t, err)
+}
+
+func TestGoogleSheetsDiscover(t *testing.T) {
+	client := &sheetsClientMock{}
+	gs := &GoogleSheets{config: &GoogleSheetsConfig{HeaderRow: 1, PageSize: 2}, client: client}
+
+	collections, err := gs.Discover()
+	require.NoError(t, err)
+	require.Len(t, collections, 2)
+	require.Equal(t, "Sheet1", collections[0].Name)
+	require.True(t, collections[0].Incremental)
+	require.Equal(t, SheetRowKey, collections[0].CursorField)
+
+	require.Equal(t, "'Sheet''2'!1:3", sheetRange(collections[1].Name, 1, 3))
+}
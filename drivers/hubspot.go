@@ -0,0 +1,259 @@
+package drivers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	hubSpotAPIURL          = "https://api.hubapi.com"
+	defaultHubSpotPageSize = 100
+	//search API doesn't return results after 10000th one: the search is restarted from the last modified value
+	hubSpotSearchLimit = 10000
+)
+
+//hubSpotModifiedProperties are last modification properties of supported CRM objects
+var hubSpotModifiedProperties = map[string]string{
+	"contacts":  "lastmodifieddate",
+	"deals":     "hs_lastmodifieddate",
+	"companies": "hs_lastmodifieddate",
+}
+
+func init() {
+	RegisterConnector(HubSpotType, NewHubSpotConnector)
+}
+
+//HubSpotConfig is a HubSpot source configuration. Collections are CRM objects: contacts, deals, companies
+type HubSpotConfig struct {
+	//private app access token
+	AccessToken string `mapstructure:"access_token" json:"access_token,omitempty" yaml:"access_token,omitempty"`
+	//objects per request (default and max 100)
+	PageSize int `mapstructure:"page_size" json:"page_size,omitempty" yaml:"page_size,omitempty"`
+}
+
+func (hsc *HubSpotConfig) Validate() error {
+	if hsc == nil {
+		return errors.New("HubSpot config is required")
+	}
+	if hsc.AccessToken == "" {
+		return errors.New("HubSpot access_token is required")
+	}
+	if hsc.PageSize <= 0 || hsc.PageSize > defaultHubSpotPageSize {
+		hsc.PageSize = defaultHubSpotPageSize
+	}
+	return nil
+}
+
+//hubSpotState is a search cursor: objects modified >= ModifiedGte (unix milliseconds) are read page by page (After)
+//MaxModified is the cursor of the next sync
+type hubSpotState struct {
+	ModifiedGte int64  `json:"modified_gte"`
+	After       string `json:"after,omitempty"`
+	MaxModified int64  `json:"max_modified,omitempty"`
+}
+
+type hubSpotObject struct {
+	Id         string                 `json:"id"`
+	Properties map[string]interface{} `json:"properties"`
+	Archived   bool                   `json:"archived"`
+}
+
+type hubSpotSearchResponse struct {
+	Results []hubSpotObject `json:"results"`
+	Paging  struct {
+		Next struct {
+			After string `json:"after"`
+		} `json:"next"`
+	} `json:"paging"`
+}
+
+//HubSpot is a connector which reads CRM objects incrementally with the search API sorted by the last modification date
+//Objects are normalized: properties are object fields. Objects modified in the same millisecond as the cursor are read
+//again: they are deduplicated by the event id hash
+type HubSpot struct {
+	ctx    context.Context
+	config *HubSpotConfig
+	client *apiClient
+	apiURL string
+
+	propertiesMutex sync.Mutex
+	properties      map[string][]string
+}
+
+//NewHubSpotConnector is a ConnectorFactory of HubSpot connector
+func NewHubSpotConnector(ctx context.Context, sourceId string, config map[string]interface{}) (Connector, error) {
+	hsConfig := &HubSpotConfig{}
+	if err := unmarshalConfig(config, hsConfig); err != nil {
+		return nil, err
+	}
+	if err := hsConfig.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &HubSpot{ctx: ctx, config: hsConfig, client: newAPIClient("HubSpot"), apiURL: hubSpotAPIURL, properties: map[string][]string{}}, nil
+}
+
+func (hs *HubSpot) Discover() ([]*Collection, error) {
+	var collections []*Collection
+	for _, name := range []string{"companies", "contacts", "deals"} {
+		collections = append(collections, &Collection{Name: name, Incremental: true, CursorField: hubSpotModifiedProperties[name], PrimaryKeyFields: []string{"id"}})
+	}
+	return collections, nil
+}
+
+func (hs *HubSpot) Read(collection, state string) (*ReadResult, error) {
+	modifiedProperty, ok := hubSpotModifiedProperties[collection]
+	if !ok {
+		return nil, fmt.Errorf("HubSpot collection [%s] isn't supported. Available: [companies, contacts, deals]", collection)
+	}
+
+	cursor := &hubSpotState{}
+	if state != "" {
+		if err := json.Unmarshal([]byte(state), cursor); err != nil {
+			return nil, fmt.Errorf("HubSpot malformed state [%s]: %v", state, err)
+		}
+	}
+
+	properties, err := hs.collectionProperties(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := hs.search(collection, modifiedProperty, properties, cursor)
+	if err != nil {
+		return nil, fmt.Errorf("HubSpot error searching [%s]: %v", collection, err)
+	}
+
+	result := &ReadResult{}
+	var lastModified int64
+	for _, object := range response.Results {
+		normalized := make(map[string]interface{}, len(object.Properties)+2)
+		for name, value := range object.Properties {
+			normalized[name] = value
+		}
+		normalized["id"] = object.Id
+		normalized["archived"] = object.Archived
+		result.Objects = append(result.Objects, normalized)
+
+		if modified, ok := object.Properties[modifiedProperty].(string); ok {
+			if t, err := time.Parse(time.RFC3339Nano, modified); err == nil {
+				lastModified = t.UnixNano() / int64(time.Millisecond)
+				if lastModified > cursor.MaxModified {
+					cursor.MaxModified = lastModified
+				}
+			}
+		}
+	}
+
+	next := response.Paging.Next.After
+	nextOffset, _ := strconv.Atoi(next)
+	switch {
+	case next == "":
+		//the sync is finished: the next one reads objects modified since the last modified object of this sync
+		finished := &hubSpotState{ModifiedGte: cursor.ModifiedGte}
+		if cursor.MaxModified > finished.ModifiedGte {
+			finished.ModifiedGte = cursor.MaxModified
+		}
+		cursor = finished
+	case nextOffset+hs.config.PageSize > hubSpotSearchLimit && lastModified > cursor.ModifiedGte:
+		cursor.ModifiedGte, cursor.After = lastModified, ""
+		result.HasMore = true
+	default:
+		cursor.After = next
+		result.HasMore = true
+	}
+
+	b, err := json.Marshal(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("HubSpot error marshalling state: %v", err)
+	}
+	result.State = string(b)
+	return result, nil
+}
+
+//search request POST /crm/v3/objects/<collection>/search sorted by modification date
+func (hs *HubSpot) search(collection, modifiedProperty string, properties []string, cursor *hubSpotState) (*hubSpotSearchResponse, error) {
+	search := map[string]interface{}{
+		"sorts":      []map[string]string{{"propertyName": modifiedProperty, "direction": "ASCENDING"}},
+		"properties": properties,
+		"limit":      hs.config.PageSize,
+	}
+	if cursor.ModifiedGte > 0 {
+		search["filterGroups"] = []map[string]interface{}{{
+			"filters": []map[string]string{{"propertyName": modifiedProperty, "operator": "GTE", "value": strconv.FormatInt(cursor.ModifiedGte, 10)}},
+		}}
+	}
+	if cursor.After != "" {
+		search["after"] = cursor.After
+	}
+	payload, err := json.Marshal(search)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &hubSpotSearchResponse{}
+	if err := hs.request(http.MethodPost, "/crm/v3/objects/"+collection+"/search", payload, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+//collectionProperties return cached object properties names
+func (hs *HubSpot) collectionProperties(collection string) ([]string, error) {
+	hs.propertiesMutex.Lock()
+	defer hs.propertiesMutex.Unlock()
+
+	if properties, ok := hs.properties[collection]; ok {
+		return properties, nil
+	}
+
+	response := &struct {
+		Results []struct {
+			Name string `json:"name"`
+		} `json:"results"`
+	}{}
+	if err := hs.request(http.MethodGet, "/crm/v3/properties/"+collection, nil, response); err != nil {
+		return nil, fmt.Errorf("HubSpot error getting [%s] properties: %v", collection, err)
+	}
+
+	properties := make([]string, 0, len(response.Results))
+	for _, property := range response.Results {
+		properties = append(properties, property.Name)
+	}
+	hs.properties[collection] = properties
+	return properties, nil
+}
+
+func (hs *HubSpot) request(method, path string, payload []byte, result interface{}) error {
+	body, err := hs.client.do(func() (*http.Request, error) {
+		request, err := http.NewRequestWithContext(hs.ctx, method, strings.TrimRight(hs.apiURL, "/")+path, bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		request.Header.Set("Authorization", "Bearer "+hs.config.AccessToken)
+		if payload != nil {
+			request.Header.Set("Content-Type", "application/json")
+		}
+		return request, nil
+	})
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, result)
+}
+
+func (hs *HubSpot) Type() string {
+	return HubSpotType
+}
+
+func (hs *HubSpot) Close() error {
+	return nil
+}
@@ -0,0 +1,61 @@
+package drivers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHubSpotRead(t *testing.T) {
+	var searches []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		switch r.URL.Path {
+		case "/crm/v3/properties/deals":
+			w.Write([]byte(`{"results":[{"name":"dealname"},{"name":"hs_lastmodifieddate"}]}`))
+		case "/crm/v3/objects/deals/search":
+			search := map[string]interface{}{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&search))
+			searches = append(searches, search)
+			if search["after"] == nil {
+				w.Write([]byte(`{"results":[{"id":"1","properties":{"dealname":"a","hs_lastmodifieddate":"2020-09-01T10:00:00.000Z"},"archived":false}],"paging":{"next":{"after":"1"}}}`))
+			} else {
+				w.Write([]byte(`{"results":[{"id":"2","properties":{"dealname":"b","hs_lastmodifieddate":"2020-09-01T10:00:01.500Z"},"archived":false}]}`))
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	hubSpot := &HubSpot{ctx: context.Background(), config: &HubSpotConfig{AccessToken: "token", PageSize: 1}, client: newAPIClient("HubSpot"), apiURL: server.URL, properties: map[string][]string{}}
+
+	result, err := hubSpot.Read("deals", "")
+	require.NoError(t, err)
+	require.Equal(t, []map[string]interface{}{{"id": "1", "archived": false, "dealname": "a", "hs_lastmodifieddate": "2020-09-01T10:00:00.000Z"}}, result.Objects)
+	require.True(t, result.HasMore)
+	require.Equal(t, `{"modified_gte":0,"after":"1","max_modified":1598954400000}`, result.State)
+
+	result, err = hubSpot.Read("deals", result.State)
+	require.NoError(t, err)
+	require.Len(t, result.Objects, 1)
+	require.False(t, result.HasMore)
+	require.Equal(t, `{"modified_gte":1598954401500}`, result.State)
+
+	//the next sync filters by the last modification date
+	_, err = hubSpot.Read("deals", result.State)
+	require.NoError(t, err)
+	require.Len(t, searches, 3)
+	require.Nil(t, searches[0]["filterGroups"])
+	require.Equal(t, "1", searches[1]["after"])
+	require.Equal(t, []interface{}{map[string]interface{}{"filters": []interface{}{
+		map[string]interface{}{"propertyName": "hs_lastmodifieddate", "operator": "GTE", "value": "1598954401500"},
+	}}}, searches[2]["filterGroups"])
+	require.Equal(t, []interface{}{"dealname", "hs_lastmodifieddate"}, searches[2]["properties"])
+
+	_, err = hubSpot.Read("tickets", "")
+	require.Error(t, err)
+}
@@ -0,0 +1,191 @@
+package drivers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/events"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	mixpanelAPIURL   = "https://data.mixpanel.com"
+	mixpanelEUAPIURL = "https://data-eu.mixpanel.com"
+	//MixpanelCollection is the only collection of Mixpanel source
+	MixpanelCollection = "events"
+)
+
+func init() {
+	RegisterConnector(MixpanelType, NewMixpanelConnector)
+}
+
+//MixpanelConfig is a Mixpanel source configuration: project API secret or service account credentials
+type MixpanelConfig struct {
+	//project API secret or service account secret (if username is set)
+	APISecret string `mapstructure:"api_secret" json:"api_secret,omitempty" yaml:"api_secret,omitempty"`
+	//Optional. Service account username. project_id is required with service account
+	Username  string `mapstructure:"username" json:"username,omitempty" yaml:"username,omitempty"`
+	ProjectId string `mapstructure:"project_id" json:"project_id,omitempty" yaml:"project_id,omitempty"`
+	//YYYY-MM-DD: the first exported day
+	StartDate string `mapstructure:"start_date" json:"start_date,omitempty" yaml:"start_date,omitempty"`
+	//Optional. IANA timezone of legacy projects which export time in the project timezone. Default: UTC
+	ProjectTimezone string `mapstructure:"project_timezone" json:"project_timezone,omitempty" yaml:"project_timezone,omitempty"`
+	//if true - EU data residency API is used
+	EURegion bool `mapstructure:"eu_region" json:"eu_region,omitempty" yaml:"eu_region,omitempty"`
+	//Optional. Exported event names. Default: all events
+	Events []string `mapstructure:"events" json:"events,omitempty" yaml:"events,omitempty"`
+
+	location *time.Location
+}
+
+func (mc *MixpanelConfig) Validate() error {
+	if mc == nil {
+		return errors.New("Mixpanel config is required")
+	}
+	if mc.APISecret == "" {
+		return errors.New("Mixpanel api_secret is required")
+	}
+	if mc.Username != "" && mc.ProjectId == "" {
+		return errors.New("Mixpanel project_id is required with service account username")
+	}
+	if _, err := time.Parse(reportDayLayout, mc.StartDate); err != nil {
+		return fmt.Errorf("Mixpanel start_date is required in YYYY-MM-DD format: %v", err)
+	}
+
+	mc.location = time.UTC
+	if mc.ProjectTimezone != "" {
+		location, err := time.LoadLocation(mc.ProjectTimezone)
+		if err != nil {
+			return fmt.Errorf("Mixpanel project_timezone must be IANA timezone: %v", err)
+		}
+		mc.location = location
+	}
+	return nil
+}
+
+//Mixpanel is a connector which exports raw events day by day with Export API (GET /api/2.0/export) from start_date
+//and converts them into facts (see events.ConvertMixpanel). Only finished days (in the project timezone) are exported
+type Mixpanel struct {
+	ctx      context.Context
+	sourceId string
+	config   *MixpanelConfig
+	client   *apiClient
+	apiURL   string
+	now      func() time.Time
+}
+
+//NewMixpanelConnector is a ConnectorFactory of Mixpanel connector
+func NewMixpanelConnector(ctx context.Context, sourceId string, config map[string]interface{}) (Connector, error) {
+	mixpanelConfig := &MixpanelConfig{}
+	if err := unmarshalConfig(config, mixpanelConfig); err != nil {
+		return nil, err
+	}
+	if err := mixpanelConfig.Validate(); err != nil {
+		return nil, err
+	}
+
+	apiURL := mixpanelAPIURL
+	if mixpanelConfig.EURegion {
+		apiURL = mixpanelEUAPIURL
+	}
+	return &Mixpanel{ctx: ctx, sourceId: sourceId, config: mixpanelConfig, client: newAPIClient("Mixpanel"), apiURL: apiURL, now: time.Now}, nil
+}
+
+func (m *Mixpanel) Discover() ([]*Collection, error) {
+	return []*Collection{{Name: MixpanelCollection, Incremental: true, CursorField: "utc_time", PrimaryKeyFields: []string{"eventn_ctx_event_id"}}}, nil
+}
+
+func (m *Mixpanel) Read(collection, state string) (*ReadResult, error) {
+	if collection != MixpanelCollection {
+		return nil, fmt.Errorf("Mixpanel collection [%s] isn't supported. Available: [%s]", collection, MixpanelCollection)
+	}
+
+	day, err := m.nextDay(state)
+	if err != nil {
+		return nil, err
+	}
+	now := m.now().In(m.config.location)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if !day.Before(today) {
+		return &ReadResult{State: state}, nil
+	}
+
+	objects, err := m.export(day)
+	if err != nil {
+		return nil, fmt.Errorf("Mixpanel error exporting %s: %v", day.Format(reportDayLayout), err)
+	}
+
+	next := day.AddDate(0, 0, 1)
+	b, err := json.Marshal(&exportState{Next: next.Format(reportDayLayout)})
+	if err != nil {
+		return nil, fmt.Errorf("Mixpanel error marshalling state: %v", err)
+	}
+	return &ReadResult{Objects: objects, State: string(b), HasMore: next.Before(today)}, nil
+}
+
+func (m *Mixpanel) nextDay(state string) (time.Time, error) {
+	if state == "" {
+		return time.Parse(reportDayLayout, m.config.StartDate)
+	}
+
+	cursor := &exportState{}
+	if err := json.Unmarshal([]byte(state), cursor); err != nil {
+		return time.Time{}, fmt.Errorf("Mixpanel malformed state [%s]: %v", state, err)
+	}
+	day, err := time.Parse(reportDayLayout, cursor.Next)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Mixpanel malformed state [%s]: %v", state, err)
+	}
+	return day, nil
+}
+
+//export return converted events of the day. The response is JSON lines
+func (m *Mixpanel) export(day time.Time) ([]map[string]interface{}, error) {
+	query := url.Values{}
+	query.Set("from_date", day.Format(reportDayLayout))
+	query.Set("to_date", day.Format(reportDayLayout))
+	if m.config.ProjectId != "" {
+		query.Set("project_id", m.config.ProjectId)
+	}
+	if len(m.config.Events) > 0 {
+		b, err := json.Marshal(m.config.Events)
+		if err != nil {
+			return nil, err
+		}
+		query.Set("event", string(b))
+	}
+	requestURL := strings.TrimRight(m.apiURL, "/") + "/api/2.0/export?" + query.Encode()
+
+	body, err := m.client.do(func() (*http.Request, error) {
+		request, err := http.NewRequestWithContext(m.ctx, http.MethodGet, requestURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		if m.config.Username != "" {
+			request.SetBasicAuth(m.config.Username, m.config.APISecret)
+		} else {
+			request.SetBasicAuth(m.config.APISecret, "")
+		}
+		return request, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return readExportLines(m.sourceId, bytes.NewReader(body), func(event map[string]interface{}) (events.Fact, error) {
+		return events.ConvertMixpanel(event, m.config.location)
+	})
+}
+
+func (m *Mixpanel) Type() string {
+	return MixpanelType
+}
+
+func (m *Mixpanel) Close() error {
+	return nil
+}
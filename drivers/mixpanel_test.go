@@ -0,0 +1,67 @@
+package drivers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMixpanelRead(t *testing.T) {
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		require.True(t, ok)
+		require.Equal(t, "service_account", username)
+		require.Equal(t, "secret", password)
+		require.Equal(t, "/api/2.0/export", r.URL.Path)
+		queries = append(queries, r.URL.RawQuery)
+
+		if r.URL.Query().Get("from_date") == "2020-09-01" {
+			w.Write([]byte(`{"event":"Signed Up","properties":{"time":1598954400,"distinct_id":"u1","$insert_id":"i1"}}
+{"properties":{"time":1598954401}}
+{"event":"Order Completed","properties":{"time":1598954402,"distinct_id":"u1","$insert_id":"i2","amount":10.5}}
+`))
+		}
+	}))
+	defer server.Close()
+
+	config := &MixpanelConfig{APISecret: "secret", Username: "service_account", ProjectId: "1", StartDate: "2020-09-01", ProjectTimezone: "America/Los_Angeles", Events: []string{"Signed Up", "Order Completed"}}
+	require.NoError(t, config.Validate())
+	//2020-09-03 in Los Angeles: only 2020-09-01 and 2020-09-02 are finished
+	mixpanel := &Mixpanel{ctx: context.Background(), config: config, client: newAPIClient("Mixpanel"), apiURL: server.URL,
+		now: func() time.Time { return time.Date(2020, 9, 4, 1, 0, 0, 0, time.UTC) }}
+
+	result, err := mixpanel.Read(MixpanelCollection, "")
+	require.NoError(t, err)
+	require.Len(t, result.Objects, 2)
+	require.Equal(t, "mixpanel", result.Objects[0]["src"])
+	require.Equal(t, "Signed Up", result.Objects[0]["event_type"])
+	require.Equal(t, "2020-09-01T17:00:00.000000Z", result.Objects[0]["eventn_ctx"].(map[string]interface{})["utc_time"])
+	require.Equal(t, map[string]interface{}{"amount": json.Number("10.5")}, result.Objects[1]["event_data"])
+	require.Equal(t, `{"next":"2020-09-02"}`, result.State)
+	require.True(t, result.HasMore)
+
+	result, err = mixpanel.Read(MixpanelCollection, result.State)
+	require.NoError(t, err)
+	require.Empty(t, result.Objects)
+	require.Equal(t, `{"next":"2020-09-03"}`, result.State)
+	require.False(t, result.HasMore)
+
+	//the current day isn't exported
+	result, err = mixpanel.Read(MixpanelCollection, result.State)
+	require.NoError(t, err)
+	require.Empty(t, result.Objects)
+	require.Equal(t, `{"next":"2020-09-03"}`, result.State)
+
+	require.Equal(t, []string{
+		"event=%5B%22Signed+Up%22%2C%22Order+Completed%22%5D&from_date=2020-09-01&project_id=1&to_date=2020-09-01",
+		"event=%5B%22Signed+Up%22%2C%22Order+Completed%22%5D&from_date=2020-09-02&project_id=1&to_date=2020-09-02",
+	}, queries)
+
+	require.EqualError(t, (&MixpanelConfig{APISecret: "secret", Username: "service_account", StartDate: "2020-09-01"}).Validate(),
+		"Mixpanel project_id is required with service account username")
+}
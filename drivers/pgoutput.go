@@ -0,0 +1,243 @@
+package drivers
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/timestamp"
+	"strconv"
+	"time"
+)
+
+//postgresEpoch is the start of Postgres timestamps (microseconds in pgoutput messages)
+var postgresEpoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+//Postgres type oids which values are converted from the text representation
+const (
+	boolOid    = 16
+	int8Oid    = 20
+	int2Oid    = 21
+	int4Oid    = 23
+	float4Oid  = 700
+	float8Oid  = 701
+	numericOid = 1700
+)
+
+var errShortMessage = errors.New("message is too short")
+
+type pgRelation struct {
+	schema  string
+	table   string
+	columns []pgColumn
+}
+
+type pgColumn struct {
+	name    string
+	key     bool
+	typeOid uint32
+}
+
+//pgOutputDecoder decodes pgoutput protocol version 1 messages. Relation messages are sent by Postgres before the first
+//change of the relation in the connection so they are kept between decode calls
+type pgOutputDecoder struct {
+	relations map[uint32]*pgRelation
+}
+
+func newPgOutputDecoder() *pgOutputDecoder {
+	return &pgOutputDecoder{relations: map[uint32]*pgRelation{}}
+}
+
+func (pod *pgOutputDecoder) decode(messages []slotMessage) ([]*cdcChange, error) {
+	var changes []*cdcChange
+	var commitTime string
+	for _, message := range messages {
+		if len(message.data) == 0 {
+			continue
+		}
+		r := &pgReader{data: message.data[1:]}
+		switch message.data[0] {
+		case 'B':
+			//final lsn, commit timestamp, xid
+			r.uint64()
+			commitTime = timestamp.ToISOFormat(postgresEpoch.Add(time.Duration(int64(r.uint64())) * time.Microsecond))
+		case 'R':
+			relationId := r.uint32()
+			relation := &pgRelation{schema: r.string(), table: r.string()}
+			//replica identity setting
+			r.byte()
+			columnsCount := int(r.uint16())
+			for i := 0; i < columnsCount && r.err == nil; i++ {
+				flags := r.byte()
+				column := pgColumn{key: flags&1 == 1, name: r.string(), typeOid: r.uint32()}
+				//type modifier
+				r.uint32()
+				relation.columns = append(relation.columns, column)
+			}
+			if r.err == nil {
+				pod.relations[relationId] = relation
+			}
+		case 'I', 'U', 'D':
+			change, err := pod.decodeChange(message, r)
+			if err != nil {
+				return nil, err
+			}
+			change.timestamp = commitTime
+			changes = append(changes, change)
+		}
+
+		if r.err != nil {
+			return nil, fmt.Errorf("Error parsing pgoutput [%c] message at %s: %v", message.data[0], message.lsn, r.err)
+		}
+	}
+	return changes, nil
+}
+
+//decodeChange decode insert (N tuple), update ([K|O tuple] N tuple) and delete (K|O tuple) messages
+func (pod *pgOutputDecoder) decodeChange(message slotMessage, r *pgReader) (*cdcChange, error) {
+	relationId := r.uint32()
+	relation, ok := pod.relations[relationId]
+	if !ok {
+		return nil, fmt.Errorf("Error parsing pgoutput message at %s: unknown relation %d", message.lsn, relationId)
+	}
+
+	change := &cdcChange{schema: relation.schema, table: relation.table, lsn: message.lsn}
+	for _, column := range relation.columns {
+		if column.key {
+			change.primaryKeys = append(change.primaryKeys, column.name)
+		}
+	}
+
+	switch message.data[0] {
+	case 'I':
+		change.operation = "insert"
+		r.byte()
+		change.columns = r.tuple(relation)
+	case 'U':
+		change.operation = "update"
+		//old key or old tuple is sent if replica identity columns are changed
+		if kind := r.byte(); kind == 'K' || kind == 'O' {
+			r.tuple(relation)
+			r.byte()
+		}
+		change.columns = r.tuple(relation)
+	case 'D':
+		change.operation = "delete"
+		r.byte()
+		columns := r.tuple(relation)
+		//not key columns are nulls in K tuple
+		change.columns = map[string]interface{}{}
+		for name, value := range columns {
+			if value != nil {
+				change.columns[name] = value
+			}
+		}
+	}
+	return change, nil
+}
+
+//pgReader reads big endian pgoutput values. The first error stops reading
+type pgReader struct {
+	data []byte
+	err  error
+}
+
+func (r *pgReader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if len(r.data) < n {
+		r.err = errShortMessage
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *pgReader) byte() byte {
+	b := r.next(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (r *pgReader) uint16() uint16 {
+	b := r.next(2)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint16(b)
+}
+
+func (r *pgReader) uint32() uint32 {
+	b := r.next(4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+func (r *pgReader) uint64() uint64 {
+	b := r.next(8)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(b)
+}
+
+//string reads null-terminated string
+func (r *pgReader) string() string {
+	if r.err != nil {
+		return ""
+	}
+	for i, b := range r.data {
+		if b == 0 {
+			s := string(r.data[:i])
+			r.data = r.data[i+1:]
+			return s
+		}
+	}
+	r.err = errShortMessage
+	return ""
+}
+
+//tuple reads TupleData: null ('n'), unchanged TOAST ('u', skipped) and text ('t') values
+func (r *pgReader) tuple(relation *pgRelation) map[string]interface{} {
+	values := map[string]interface{}{}
+	columnsCount := int(r.uint16())
+	for i := 0; i < columnsCount && r.err == nil; i++ {
+		var column pgColumn
+		if i < len(relation.columns) {
+			column = relation.columns[i]
+		} else {
+			column.name = "column_" + strconv.Itoa(i+1)
+		}
+
+		switch r.byte() {
+		case 'n':
+			values[column.name] = nil
+		case 't':
+			value := r.next(int(r.uint32()))
+			if r.err == nil {
+				values[column.name] = pgValue(column.typeOid, string(value))
+			}
+		}
+	}
+	return values
+}
+
+//pgValue convert bool and numeric types text values. Numbers are json.Number like in parsed events
+func pgValue(typeOid uint32, value string) interface{} {
+	switch typeOid {
+	case boolOid:
+		return value == "t"
+	case int2Oid, int4Oid, int8Oid, float4Oid, float8Oid, numericOid:
+		//NaN and Infinity are kept as strings
+		if _, err := strconv.ParseFloat(value, 64); err == nil && value != "NaN" && value != "Infinity" && value != "-Infinity" {
+			return json.Number(value)
+		}
+	}
+	return value
+}
@@ -0,0 +1,394 @@
+package drivers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/adapters"
+	"github.com/jitsucom/eventnative/timestamp"
+	"strings"
+	"time"
+)
+
+const (
+	Wal2JsonPlugin = "wal2json"
+	PgOutputPlugin = "pgoutput"
+
+	//CdcCollection is the only collection of Postgres CDC source: all tables changes are read from one replication slot
+	CdcCollection = "changes"
+
+	CdcOperationKey  = "_cdc_operation"
+	CdcTableKey      = "_cdc_table"
+	CdcLsnKey        = "_cdc_lsn"
+	CdcTimestampKey  = "_cdc_timestamp"
+	CdcPrimaryKeyKey = "_cdc_primary_key"
+
+	defaultCdcBatchSize = 10000
+)
+
+func init() {
+	RegisterConnector(PostgresCdcType, NewPostgresCdcConnector)
+}
+
+//PostgresCdcConfig is a Postgres change data capture source configuration: database connection, logical replication slot
+//with wal2json or pgoutput (publication is required) output plugin
+type PostgresCdcConfig struct {
+	adapters.DataSourceConfig `mapstructure:",squash" yaml:",inline"`
+
+	Slot   string `mapstructure:"slot" json:"slot,omitempty" yaml:"slot,omitempty"`
+	Plugin string `mapstructure:"plugin" json:"plugin,omitempty" yaml:"plugin,omitempty"`
+	//if true - the slot is created if it doesn't exist
+	CreateSlot  bool   `mapstructure:"create_slot" json:"create_slot,omitempty" yaml:"create_slot,omitempty"`
+	Publication string `mapstructure:"publication" json:"publication,omitempty" yaml:"publication,omitempty"`
+	//Optional. schema.table list: changes of other tables are skipped
+	Tables []string `mapstructure:"tables" json:"tables,omitempty" yaml:"tables,omitempty"`
+	//max changes per read (default 10000). Transactions aren't split, so pages might be bigger
+	BatchSize int `mapstructure:"batch_size" json:"batch_size,omitempty" yaml:"batch_size,omitempty"`
+}
+
+func (pcc *PostgresCdcConfig) Validate() error {
+	if pcc == nil {
+		return errors.New("Postgres CDC config is required")
+	}
+	if err := pcc.DataSourceConfig.Validate(); err != nil {
+		return err
+	}
+	if pcc.Port == 0 {
+		pcc.Port = 5432
+	}
+	if pcc.Slot == "" {
+		return errors.New("Postgres CDC slot is required")
+	}
+	switch pcc.Plugin {
+	case "":
+		pcc.Plugin = Wal2JsonPlugin
+	case Wal2JsonPlugin:
+	case PgOutputPlugin:
+		if pcc.Publication == "" {
+			return errors.New("Postgres CDC publication is required with pgoutput plugin")
+		}
+	default:
+		return fmt.Errorf("Postgres CDC plugin [%s] isn't supported. Available: [%s, %s]", pcc.Plugin, Wal2JsonPlugin, PgOutputPlugin)
+	}
+	if pcc.BatchSize <= 0 {
+		pcc.BatchSize = defaultCdcBatchSize
+	}
+	return nil
+}
+
+//slotMessage is a logical decoding output row
+type slotMessage struct {
+	lsn  string
+	data []byte
+}
+
+//slotReader reads changes from the logical replication slot
+type slotReader interface {
+	//peek return up to limit changes (whole transactions) after the slot confirmed position without consuming them
+	peek(limit int) ([]slotMessage, error)
+	//advance consume the slot changes up to the lsn
+	advance(lsn string) error
+	Close() error
+}
+
+//cdcChange is a decoded row change
+type cdcChange struct {
+	operation string
+	schema    string
+	table     string
+	lsn       string
+	timestamp string
+	//new values for inserts and updates, key (replica identity) values for deletes
+	columns     map[string]interface{}
+	primaryKeys []string
+}
+
+//changesDecoder decodes slot messages into changes. Transactions boundaries and other messages are skipped
+type changesDecoder interface {
+	decode(messages []slotMessage) ([]*cdcChange, error)
+}
+
+//PostgresCdc is a connector which reads inserts, updates and deletes from Postgres logical replication slot with SQL
+//interface (pg_logical_slot_peek_changes). The state is the lsn of the last stored change: the slot is advanced up
+//to it before the next read, so changes are delivered at least once
+type PostgresCdc struct {
+	config  *PostgresCdcConfig
+	reader  slotReader
+	decoder changesDecoder
+	tables  map[string]bool
+}
+
+//NewPostgresCdcConnector is a ConnectorFactory of Postgres CDC connector
+func NewPostgresCdcConnector(ctx context.Context, sourceId string, config map[string]interface{}) (Connector, error) {
+	cdcConfig := &PostgresCdcConfig{}
+	if err := unmarshalConfig(config, cdcConfig); err != nil {
+		return nil, err
+	}
+	if err := cdcConfig.Validate(); err != nil {
+		return nil, err
+	}
+
+	reader, err := newSqlSlotReader(ctx, cdcConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	var decoder changesDecoder
+	if cdcConfig.Plugin == PgOutputPlugin {
+		decoder = newPgOutputDecoder()
+	} else {
+		decoder = &wal2JsonDecoder{}
+	}
+
+	return newPostgresCdc(cdcConfig, reader, decoder), nil
+}
+
+func newPostgresCdc(config *PostgresCdcConfig, reader slotReader, decoder changesDecoder) *PostgresCdc {
+	var tables map[string]bool
+	if len(config.Tables) > 0 {
+		tables = map[string]bool{}
+		for _, table := range config.Tables {
+			if !strings.Contains(table, ".") {
+				table = "public." + table
+			}
+			tables[table] = true
+		}
+	}
+	return &PostgresCdc{config: config, reader: reader, decoder: decoder, tables: tables}
+}
+
+func (pc *PostgresCdc) Discover() ([]*Collection, error) {
+	return []*Collection{{Name: CdcCollection, Incremental: true, CursorField: CdcLsnKey}}, nil
+}
+
+func (pc *PostgresCdc) Read(collection, state string) (*ReadResult, error) {
+	if collection != CdcCollection {
+		return nil, fmt.Errorf("Postgres CDC collection [%s] isn't supported. Available: [%s]", collection, CdcCollection)
+	}
+
+	//the previous page has been stored
+	if state != "" {
+		if err := pc.reader.advance(state); err != nil {
+			return nil, fmt.Errorf("Postgres CDC error advancing slot [%s] to %s: %v", pc.config.Slot, state, err)
+		}
+	}
+
+	messages, err := pc.reader.peek(pc.config.BatchSize)
+	if err != nil {
+		return nil, fmt.Errorf("Postgres CDC error reading slot [%s]: %v", pc.config.Slot, err)
+	}
+
+	changes, err := pc.decoder.decode(messages)
+	if err != nil {
+		return nil, fmt.Errorf("Postgres CDC error decoding slot [%s] changes: %v", pc.config.Slot, err)
+	}
+
+	result := &ReadResult{State: state, HasMore: len(messages) >= pc.config.BatchSize}
+	if len(messages) > 0 {
+		result.State = messages[len(messages)-1].lsn
+	}
+	for _, change := range changes {
+		table := change.schema + "." + change.table
+		if pc.tables != nil && !pc.tables[table] {
+			continue
+		}
+
+		object := make(map[string]interface{}, len(change.columns)+5)
+		for name, value := range change.columns {
+			object[name] = value
+		}
+		object[CdcOperationKey] = change.operation
+		object[CdcTableKey] = table
+		object[CdcLsnKey] = change.lsn
+		if change.timestamp != "" {
+			object[CdcTimestampKey] = change.timestamp
+		}
+		if len(change.primaryKeys) > 0 {
+			object[CdcPrimaryKeyKey] = strings.Join(change.primaryKeys, ",")
+		}
+		result.Objects = append(result.Objects, object)
+	}
+	return result, nil
+}
+
+func (pc *PostgresCdc) Type() string {
+	return PostgresCdcType
+}
+
+func (pc *PostgresCdc) Close() error {
+	return pc.reader.Close()
+}
+
+//sqlSlotReader reads the slot with logical decoding SQL functions
+//One connection is used: pgoutput relation messages are sent once per backend connection
+type sqlSlotReader struct {
+	ctx        context.Context
+	dataSource *sql.DB
+	slot       string
+	binary     bool
+	options    []interface{}
+}
+
+func newSqlSlotReader(ctx context.Context, config *PostgresCdcConfig) (*sqlSlotReader, error) {
+	dataSource, err := sql.Open("postgres", adapters.PostgresConnectionString(&config.DataSourceConfig))
+	if err != nil {
+		return nil, fmt.Errorf("Postgres CDC error connecting: %v", err)
+	}
+	dataSource.SetMaxOpenConns(1)
+	if err := dataSource.PingContext(ctx); err != nil {
+		dataSource.Close()
+		return nil, fmt.Errorf("Postgres CDC error connecting: %v", err)
+	}
+
+	ssr := &sqlSlotReader{ctx: ctx, dataSource: dataSource, slot: config.Slot}
+	if config.Plugin == PgOutputPlugin {
+		ssr.binary = true
+		ssr.options = []interface{}{"proto_version", "1", "publication_names", config.Publication}
+	} else {
+		ssr.options = []interface{}{"format-version", "2", "include-timestamp", "1", "include-pk", "1"}
+	}
+
+	if err := ssr.ensureSlot(config.Plugin, config.CreateSlot); err != nil {
+		dataSource.Close()
+		return nil, err
+	}
+	return ssr, nil
+}
+
+//ensureSlot check that the slot exists with the plugin or create it
+func (ssr *sqlSlotReader) ensureSlot(plugin string, create bool) error {
+	var slotPlugin string
+	err := ssr.dataSource.QueryRowContext(ssr.ctx, `SELECT plugin FROM pg_replication_slots WHERE slot_name = $1 AND database = current_database()`, ssr.slot).Scan(&slotPlugin)
+	if err == sql.ErrNoRows {
+		if !create {
+			return fmt.Errorf("Postgres CDC replication slot [%s] doesn't exist. Create it or set create_slot: true", ssr.slot)
+		}
+		if _, err := ssr.dataSource.ExecContext(ssr.ctx, `SELECT pg_create_logical_replication_slot($1, $2)`, ssr.slot, plugin); err != nil {
+			return fmt.Errorf("Postgres CDC error creating replication slot [%s]: %v", ssr.slot, err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("Postgres CDC error getting replication slot [%s]: %v", ssr.slot, err)
+	}
+	if slotPlugin != plugin {
+		return fmt.Errorf("Postgres CDC replication slot [%s] plugin is [%s], but [%s] is configured", ssr.slot, slotPlugin, plugin)
+	}
+	return nil
+}
+
+func (ssr *sqlSlotReader) peek(limit int) ([]slotMessage, error) {
+	function, dataType := "pg_logical_slot_peek_changes", "text"
+	if ssr.binary {
+		function, dataType = "pg_logical_slot_peek_binary_changes", "bytea"
+	}
+	placeholders := make([]string, len(ssr.options))
+	for i := range ssr.options {
+		placeholders[i] = fmt.Sprintf("$%d", i+3)
+	}
+	query := fmt.Sprintf(`SELECT lsn::text, data::%s FROM %s($1, NULL, $2, %s)`, dataType, function, strings.Join(placeholders, ", "))
+
+	rows, err := ssr.dataSource.QueryContext(ssr.ctx, query, append([]interface{}{ssr.slot, limit}, ssr.options...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []slotMessage
+	for rows.Next() {
+		message := slotMessage{}
+		if err := rows.Scan(&message.lsn, &message.data); err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+	return messages, rows.Err()
+}
+
+//advance move the slot position without decoding changes by the output plugin (Postgres 11+)
+func (ssr *sqlSlotReader) advance(lsn string) error {
+	_, err := ssr.dataSource.ExecContext(ssr.ctx, `SELECT pg_replication_slot_advance($1, $2::pg_lsn)`, ssr.slot, lsn)
+	return err
+}
+
+func (ssr *sqlSlotReader) Close() error {
+	return ssr.dataSource.Close()
+}
+
+//wal2JsonDecoder decodes wal2json format-version 2 messages
+type wal2JsonDecoder struct{}
+
+type wal2JsonColumn struct {
+	Name  string      `json:"name"`
+	Value interface{} `json:"value"`
+}
+
+type wal2JsonMessage struct {
+	Action    string           `json:"action"`
+	Schema    string           `json:"schema"`
+	Table     string           `json:"table"`
+	Timestamp string           `json:"timestamp"`
+	Columns   []wal2JsonColumn `json:"columns"`
+	Identity  []wal2JsonColumn `json:"identity"`
+	PK        []wal2JsonColumn `json:"pk"`
+}
+
+var wal2JsonOperations = map[string]string{"I": "insert", "U": "update", "D": "delete"}
+
+func (wjd *wal2JsonDecoder) decode(messages []slotMessage) ([]*cdcChange, error) {
+	var changes []*cdcChange
+	var commitTime string
+	for _, message := range messages {
+		decoder := json.NewDecoder(bytes.NewReader(message.data))
+		decoder.UseNumber()
+		parsed := &wal2JsonMessage{}
+		if err := decoder.Decode(parsed); err != nil {
+			return nil, fmt.Errorf("Error parsing wal2json message at %s: %v", message.lsn, err)
+		}
+
+		//begin rows have the commit timestamp
+		if parsed.Action == "B" {
+			commitTime = wal2JsonTimestamp(parsed.Timestamp)
+			continue
+		}
+		operation, ok := wal2JsonOperations[parsed.Action]
+		if !ok {
+			continue
+		}
+
+		change := &cdcChange{operation: operation, schema: parsed.Schema, table: parsed.Table, lsn: message.lsn, timestamp: commitTime, columns: map[string]interface{}{}}
+		if parsed.Timestamp != "" {
+			change.timestamp = wal2JsonTimestamp(parsed.Timestamp)
+		}
+		columns := parsed.Columns
+		if operation == "delete" {
+			columns = parsed.Identity
+		}
+		for _, column := range columns {
+			change.columns[column.Name] = column.Value
+		}
+
+		keys := parsed.PK
+		if len(keys) == 0 {
+			keys = parsed.Identity
+		}
+		for _, key := range keys {
+			change.primaryKeys = append(change.primaryKeys, key.Name)
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+//wal2JsonTimestamp return Postgres timestamptz text (2020-09-01 10:00:00.123456+00) in ISO format or as is if it isn't parsed
+func wal2JsonTimestamp(value string) string {
+	for _, layout := range []string{"2006-01-02 15:04:05.999999-07", "2006-01-02 15:04:05.999999-07:00"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return timestamp.ToISOFormat(t.UTC())
+		}
+	}
+	return value
+}
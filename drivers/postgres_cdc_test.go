@@ -0,0 +1,153 @@
+package drivers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type slotReaderMock struct {
+	messages []slotMessage
+	advanced []string
+}
+
+func (srm *slotReaderMock) peek(limit int) ([]slotMessage, error) {
+	if len(srm.messages) < limit {
+		limit = len(srm.messages)
+	}
+	return srm.messages[:limit], nil
+}
+
+func (srm *slotReaderMock) advance(lsn string) error {
+	srm.advanced = append(srm.advanced, lsn)
+	for len(srm.messages) > 0 && srm.messages[0].lsn <= lsn {
+		srm.messages = srm.messages[1:]
+	}
+	return nil
+}
+
+func (srm *slotReaderMock) Close() error { return nil }
+
+func TestPostgresCdcWal2Json(t *testing.T) {
+	reader := &slotReaderMock{messages: []slotMessage{
+		{lsn: "0/A1", data: []byte(`{"action":"B","timestamp":"2020-09-01 10:00:00.123456+03"}`)},
+		{lsn: "0/A2", data: []byte(`{"action":"I","schema":"public","table":"users","columns":[{"name":"id","type":"integer","value":1},{"name":"email","type":"text","value":"a@b.c"}],"pk":[{"name":"id","type":"integer"}]}`)},
+		{lsn: "0/A3", data: []byte(`{"action":"I","schema":"public","table":"logs","columns":[{"name":"id","type":"integer","value":1}]}`)},
+		{lsn: "0/A4", data: []byte(`{"action":"C"}`)},
+		{lsn: "0/B1", data: []byte(`{"action":"B","timestamp":"2020-09-01 11:00:00+00"}`)},
+		{lsn: "0/B2", data: []byte(`{"action":"U","schema":"public","table":"users","columns":[{"name":"id","type":"integer","value":1},{"name":"email","type":"text","value":"x@y.z"}],"identity":[{"name":"id","type":"integer","value":1}]}`)},
+		{lsn: "0/B3", data: []byte(`{"action":"D","schema":"public","table":"users","identity":[{"name":"id","type":"integer","value":1}]}`)},
+		{lsn: "0/B4", data: []byte(`{"action":"C"}`)},
+	}}
+	cdc := newPostgresCdc(&PostgresCdcConfig{Slot: "slot", BatchSize: 4, Tables: []string{"users"}}, reader, &wal2JsonDecoder{})
+
+	result, err := cdc.Read(CdcCollection, "")
+	require.NoError(t, err)
+	require.Equal(t, []map[string]interface{}{{
+		"id": json.Number("1"), "email": "a@b.c", CdcOperationKey: "insert", CdcTableKey: "public.users", CdcLsnKey: "0/A2",
+		CdcTimestampKey: "2020-09-01T07:00:00.123456Z", CdcPrimaryKeyKey: "id",
+	}}, result.Objects)
+	require.Equal(t, "0/A4", result.State)
+	require.True(t, result.HasMore)
+	require.Empty(t, reader.advanced)
+
+	result, err = cdc.Read(CdcCollection, result.State)
+	require.NoError(t, err)
+	require.Equal(t, []string{"0/A4"}, reader.advanced)
+	require.Len(t, result.Objects, 2)
+	require.Equal(t, "update", result.Objects[0][CdcOperationKey])
+	require.Equal(t, "x@y.z", result.Objects[0]["email"])
+	require.Equal(t, map[string]interface{}{
+		"id": json.Number("1"), CdcOperationKey: "delete", CdcTableKey: "public.users", CdcLsnKey: "0/B3",
+		CdcTimestampKey: "2020-09-01T11:00:00.000000Z", CdcPrimaryKeyKey: "id",
+	}, result.Objects[1])
+	require.Equal(t, "0/B4", result.State)
+
+	//nothing new: the state is kept
+	result, err = cdc.Read(CdcCollection, result.State)
+	require.NoError(t, err)
+	require.Empty(t, result.Objects)
+	require.False(t, result.HasMore)
+	require.Equal(t, "0/B4", result.State)
+
+	_, err = cdc.Read("users", "")
+	require.Error(t, err)
+}
+
+//pgMessage builds pgoutput message from bytes, strings (null-terminated) and integers (big endian)
+func pgMessage(parts ...interface{}) []byte {
+	buf := &bytes.Buffer{}
+	for _, part := range parts {
+		switch value := part.(type) {
+		case string:
+			buf.WriteString(value)
+			buf.WriteByte(0)
+		case byte:
+			buf.WriteByte(value)
+		default:
+			binary.Write(buf, binary.BigEndian, value)
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestPgOutputDecode(t *testing.T) {
+	relation := pgMessage(byte('R'), uint32(16385), "public", "users", byte('d'), uint16(3),
+		byte(1), "id", uint32(int4Oid), int32(-1),
+		byte(0), "active", uint32(boolOid), int32(-1),
+		byte(0), "name", uint32(25), int32(-1))
+	messages := []slotMessage{
+		//commit timestamp is 2020-01-01T00:00:00Z
+		{lsn: "0/1", data: pgMessage(byte('B'), uint64(1), int64(631152000000000), uint32(500))},
+		{lsn: "0/2", data: relation},
+		{lsn: "0/3", data: pgMessage(byte('I'), uint32(16385), byte('N'), uint16(3),
+			byte('t'), uint32(2), []byte("42"), byte('t'), uint32(1), []byte("t"), byte('n'))},
+		{lsn: "0/4", data: pgMessage(byte('U'), uint32(16385), byte('N'), uint16(3),
+			byte('t'), uint32(2), []byte("42"), byte('u'), byte('t'), uint32(3), []byte("bob"))},
+		{lsn: "0/5", data: pgMessage(byte('D'), uint32(16385), byte('K'), uint16(3),
+			byte('t'), uint32(2), []byte("42"), byte('n'), byte('n'))},
+		{lsn: "0/6", data: pgMessage(byte('C'), byte(0), uint64(1), uint64(2), int64(0))},
+	}
+
+	decoder := newPgOutputDecoder()
+	changes, err := decoder.decode(messages)
+	require.NoError(t, err)
+	require.Len(t, changes, 3)
+
+	require.Equal(t, &cdcChange{operation: "insert", schema: "public", table: "users", lsn: "0/3", timestamp: "2020-01-01T00:00:00.000000Z",
+		columns: map[string]interface{}{"id": json.Number("42"), "active": true, "name": nil}, primaryKeys: []string{"id"}}, changes[0])
+	//unchanged TOAST values are skipped
+	require.Equal(t, map[string]interface{}{"id": json.Number("42"), "name": "bob"}, changes[1].columns)
+	require.Equal(t, "delete", changes[2].operation)
+	require.Equal(t, map[string]interface{}{"id": json.Number("42")}, changes[2].columns)
+
+	//relations are kept between reads
+	changes, err = decoder.decode(messages[2:3])
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+
+	_, err = newPgOutputDecoder().decode(messages[2:3])
+	require.Error(t, err)
+
+	_, err = decoder.decode([]slotMessage{{lsn: "0/7", data: relation[:10]}})
+	require.Error(t, err)
+}
+
+func TestPostgresCdcConfigValidate(t *testing.T) {
+	config := &PostgresCdcConfig{Slot: "slot"}
+	config.Host, config.Db, config.Username = "localhost", "db", "user"
+	require.NoError(t, config.Validate())
+	require.Equal(t, Wal2JsonPlugin, config.Plugin)
+	require.Equal(t, 5432, config.Port)
+	require.Equal(t, defaultCdcBatchSize, config.BatchSize)
+
+	config.Plugin = PgOutputPlugin
+	require.Error(t, config.Validate())
+	config.Publication = "pub"
+	require.NoError(t, config.Validate())
+
+	config.Plugin = "test_decoding"
+	require.Error(t, config.Validate())
+}
@@ -0,0 +1,348 @@
+package drivers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSalesforceLoginURL   = "https://login.salesforce.com"
+	defaultSalesforceAPIVersion = "50.0"
+	defaultSalesforcePageSize   = 2000
+
+	salesforceCursorField = "SystemModstamp"
+	salesforceIdField     = "Id"
+	//SOQL datetime literal format
+	soqlDatetimeLayout = "2006-01-02T15:04:05.000Z"
+)
+
+var sObjectNameRegex = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+
+func init() {
+	RegisterConnector(SalesforceType, NewSalesforceConnector)
+}
+
+//SalesforceConfig is a Salesforce source configuration. Collections are sObjects names (Account, Contact, Opportunity, etc.)
+//OAuth refresh token flow is used if refresh_token is set, otherwise username-password flow
+type SalesforceConfig struct {
+	LoginURL      string `mapstructure:"login_url" json:"login_url,omitempty" yaml:"login_url,omitempty"`
+	ClientId      string `mapstructure:"client_id" json:"client_id,omitempty" yaml:"client_id,omitempty"`
+	ClientSecret  string `mapstructure:"client_secret" json:"client_secret,omitempty" yaml:"client_secret,omitempty"`
+	RefreshToken  string `mapstructure:"refresh_token" json:"refresh_token,omitempty" yaml:"refresh_token,omitempty"`
+	Username      string `mapstructure:"username" json:"username,omitempty" yaml:"username,omitempty"`
+	Password      string `mapstructure:"password" json:"password,omitempty" yaml:"password,omitempty"`
+	SecurityToken string `mapstructure:"security_token" json:"security_token,omitempty" yaml:"security_token,omitempty"`
+	APIVersion    string `mapstructure:"api_version" json:"api_version,omitempty" yaml:"api_version,omitempty"`
+	//records per read (default and max 2000)
+	PageSize int `mapstructure:"page_size" json:"page_size,omitempty" yaml:"page_size,omitempty"`
+	//Optional. ISO datetime: records modified before it aren't synchronized on the first sync
+	StartDate string `mapstructure:"start_date" json:"start_date,omitempty" yaml:"start_date,omitempty"`
+}
+
+func (sc *SalesforceConfig) Validate() error {
+	if sc == nil {
+		return errors.New("Salesforce config is required")
+	}
+	if sc.ClientId == "" || sc.ClientSecret == "" {
+		return errors.New("Salesforce client_id and client_secret are required")
+	}
+	if sc.RefreshToken == "" && (sc.Username == "" || sc.Password == "") {
+		return errors.New("Salesforce refresh_token or username and password are required")
+	}
+	if sc.StartDate != "" {
+		startDate, err := time.Parse(time.RFC3339Nano, sc.StartDate)
+		if err != nil {
+			return fmt.Errorf("Salesforce start_date must be ISO datetime: %v", err)
+		}
+		sc.StartDate = startDate.UTC().Format(soqlDatetimeLayout)
+	}
+	if sc.LoginURL == "" {
+		sc.LoginURL = defaultSalesforceLoginURL
+	}
+	sc.LoginURL = strings.TrimRight(sc.LoginURL, "/")
+	if sc.APIVersion == "" {
+		sc.APIVersion = defaultSalesforceAPIVersion
+	}
+	sc.APIVersion = strings.TrimPrefix(sc.APIVersion, "v")
+	if sc.PageSize <= 0 || sc.PageSize > defaultSalesforcePageSize {
+		sc.PageSize = defaultSalesforcePageSize
+	}
+	return nil
+}
+
+//salesforceState is keyset pagination cursor: the last synced record SystemModstamp (SOQL literal) and Id
+type salesforceState struct {
+	SystemModstamp string `json:"system_modstamp"`
+	Id             string `json:"id"`
+}
+
+type salesforceQueryResponse struct {
+	Records        []map[string]interface{} `json:"records"`
+	Done           bool                     `json:"done"`
+	NextRecordsURL string                   `json:"nextRecordsUrl"`
+}
+
+//Salesforce is a connector which reads sObjects records incrementally with SOQL queries ordered by SystemModstamp and Id
+//Deleted records aren't synchronized
+type Salesforce struct {
+	ctx    context.Context
+	config *SalesforceConfig
+	client *apiClient
+
+	authMutex   sync.Mutex
+	accessToken string
+	instanceURL string
+
+	fieldsMutex sync.Mutex
+	fields      map[string][]string
+}
+
+//NewSalesforceConnector is a ConnectorFactory of Salesforce connector
+func NewSalesforceConnector(ctx context.Context, sourceId string, config map[string]interface{}) (Connector, error) {
+	sfConfig := &SalesforceConfig{}
+	if err := unmarshalConfig(config, sfConfig); err != nil {
+		return nil, err
+	}
+	if err := sfConfig.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &Salesforce{ctx: ctx, config: sfConfig, client: newAPIClient("Salesforce"), fields: map[string][]string{}}, nil
+}
+
+func (s *Salesforce) Discover() ([]*Collection, error) {
+	response := &struct {
+		SObjects []struct {
+			Name          string `json:"name"`
+			Queryable     bool   `json:"queryable"`
+			Replicateable bool   `json:"replicateable"`
+		} `json:"sobjects"`
+	}{}
+	if err := s.get("/sobjects", response); err != nil {
+		return nil, fmt.Errorf("Salesforce error getting sObjects: %v", err)
+	}
+
+	var collections []*Collection
+	for _, sObject := range response.SObjects {
+		if sObject.Queryable && sObject.Replicateable {
+			collections = append(collections, &Collection{Name: sObject.Name, Incremental: true, CursorField: salesforceCursorField, PrimaryKeyFields: []string{salesforceIdField}})
+		}
+	}
+	return collections, nil
+}
+
+//Read return the next page of records modified after the state cursor
+func (s *Salesforce) Read(collection, state string) (*ReadResult, error) {
+	if !sObjectNameRegex.MatchString(collection) {
+		return nil, fmt.Errorf("Salesforce collection [%s] isn't a valid sObject name", collection)
+	}
+
+	cursor := &salesforceState{}
+	if state != "" {
+		if err := json.Unmarshal([]byte(state), cursor); err != nil {
+			return nil, fmt.Errorf("Salesforce malformed state [%s]: %v", state, err)
+		}
+	}
+
+	fields, err := s.collectionFields(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	query := s.query(collection, fields, cursor)
+	records, err := s.queryRecords(query)
+	if err != nil {
+		return nil, fmt.Errorf("Salesforce error querying [%s]: %v", collection, err)
+	}
+
+	result := &ReadResult{State: state, HasMore: len(records) >= s.config.PageSize}
+	for _, record := range records {
+		delete(record, "attributes")
+		result.Objects = append(result.Objects, record)
+	}
+
+	if len(records) > 0 {
+		last := records[len(records)-1]
+		modstamp, _ := last[salesforceCursorField].(string)
+		t, err := time.Parse("2006-01-02T15:04:05.000-0700", modstamp)
+		if err != nil {
+			return nil, fmt.Errorf("Salesforce [%s] record has malformed %s value [%s]: %v", collection, salesforceCursorField, modstamp, err)
+		}
+		id, _ := last[salesforceIdField].(string)
+
+		b, err := json.Marshal(&salesforceState{SystemModstamp: t.UTC().Format(soqlDatetimeLayout), Id: id})
+		if err != nil {
+			return nil, fmt.Errorf("Salesforce error marshalling state: %v", err)
+		}
+		result.State = string(b)
+	}
+	return result, nil
+}
+
+//query return SOQL of the page after the cursor. Records with the same SystemModstamp are ordered by Id
+func (s *Salesforce) query(collection string, fields []string, cursor *salesforceState) string {
+	where := ""
+	if cursor.SystemModstamp != "" {
+		where = fmt.Sprintf(" WHERE (%s > %s OR (%s = %s AND %s > '%s'))", salesforceCursorField, cursor.SystemModstamp,
+			salesforceCursorField, cursor.SystemModstamp, salesforceIdField, strings.ReplaceAll(cursor.Id, "'", `\'`))
+	} else if s.config.StartDate != "" {
+		where = fmt.Sprintf(" WHERE %s >= %s", salesforceCursorField, s.config.StartDate)
+	}
+
+	return fmt.Sprintf("SELECT %s FROM %s%s ORDER BY %s ASC, %s ASC LIMIT %d", strings.Join(fields, ","), collection, where,
+		salesforceCursorField, salesforceIdField, s.config.PageSize)
+}
+
+//queryRecords run the query and return all records: big records are returned in several batches (nextRecordsUrl)
+func (s *Salesforce) queryRecords(query string) ([]map[string]interface{}, error) {
+	response := &salesforceQueryResponse{}
+	if err := s.get("/query?q="+url.QueryEscape(query), response); err != nil {
+		return nil, err
+	}
+	records := response.Records
+	for !response.Done && response.NextRecordsURL != "" {
+		nextURL := response.NextRecordsURL
+		response = &salesforceQueryResponse{}
+		if err := s.get(nextURL, response); err != nil {
+			return nil, err
+		}
+		records = append(records, response.Records...)
+	}
+	return records, nil
+}
+
+//collectionFields return cached sObject fields names. Binary (base64) fields are skipped
+func (s *Salesforce) collectionFields(collection string) ([]string, error) {
+	s.fieldsMutex.Lock()
+	defer s.fieldsMutex.Unlock()
+
+	if fields, ok := s.fields[collection]; ok {
+		return fields, nil
+	}
+
+	response := &struct {
+		Fields []struct {
+			Name string `json:"name"`
+			Type string `json:"type"`
+		} `json:"fields"`
+	}{}
+	if err := s.get("/sobjects/"+collection+"/describe", response); err != nil {
+		return nil, fmt.Errorf("Salesforce error describing [%s]: %v", collection, err)
+	}
+
+	var fields []string
+	hasCursor := false
+	for _, field := range response.Fields {
+		if field.Type == "base64" {
+			continue
+		}
+		if field.Name == salesforceCursorField {
+			hasCursor = true
+		}
+		fields = append(fields, field.Name)
+	}
+	if !hasCursor {
+		return nil, fmt.Errorf("Salesforce [%s] doesn't have %s field: incremental sync isn't supported", collection, salesforceCursorField)
+	}
+
+	s.fields[collection] = fields
+	return fields, nil
+}
+
+//get request REST API path (relative to /services/data/v<version> or absolute path of nextRecordsUrl)
+//The access token is refreshed once if it is expired
+func (s *Salesforce) get(path string, result interface{}) error {
+	var body []byte
+	for attempt := 0; attempt < 2; attempt++ {
+		accessToken, instanceURL, err := s.authenticate(attempt > 0)
+		if err != nil {
+			return err
+		}
+
+		requestURL := instanceURL + path
+		if !strings.HasPrefix(path, "/services/") {
+			requestURL = instanceURL + "/services/data/v" + s.config.APIVersion + path
+		}
+		body, err = s.client.do(func() (*http.Request, error) {
+			request, err := http.NewRequestWithContext(s.ctx, http.MethodGet, requestURL, nil)
+			if err != nil {
+				return nil, err
+			}
+			request.Header.Set("Authorization", "Bearer "+accessToken)
+			return request, nil
+		})
+		if apiErr, ok := err.(*apiError); ok && apiErr.StatusCode == http.StatusUnauthorized && attempt == 0 {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		break
+	}
+
+	return json.Unmarshal(body, result)
+}
+
+//authenticate return cached or new (if force or not authenticated yet) access token and instance URL
+func (s *Salesforce) authenticate(force bool) (string, string, error) {
+	s.authMutex.Lock()
+	defer s.authMutex.Unlock()
+
+	if s.accessToken != "" && !force {
+		return s.accessToken, s.instanceURL, nil
+	}
+
+	form := url.Values{}
+	form.Set("client_id", s.config.ClientId)
+	form.Set("client_secret", s.config.ClientSecret)
+	if s.config.RefreshToken != "" {
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", s.config.RefreshToken)
+	} else {
+		form.Set("grant_type", "password")
+		form.Set("username", s.config.Username)
+		form.Set("password", s.config.Password+s.config.SecurityToken)
+	}
+
+	body, err := s.client.do(func() (*http.Request, error) {
+		request, err := http.NewRequestWithContext(s.ctx, http.MethodPost, s.config.LoginURL+"/services/oauth2/token", strings.NewReader(form.Encode()))
+		if err != nil {
+			return nil, err
+		}
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return request, nil
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("Salesforce authentication error: %v", err)
+	}
+
+	token := &struct {
+		AccessToken string `json:"access_token"`
+		InstanceURL string `json:"instance_url"`
+	}{}
+	if err := json.Unmarshal(body, token); err != nil {
+		return "", "", fmt.Errorf("Salesforce error parsing authentication response: %v", err)
+	}
+	if token.AccessToken == "" || token.InstanceURL == "" {
+		return "", "", errors.New("Salesforce authentication response doesn't contain access_token and instance_url")
+	}
+
+	s.accessToken, s.instanceURL = token.AccessToken, strings.TrimRight(token.InstanceURL, "/")
+	return s.accessToken, s.instanceURL, nil
+}
+
+func (s *Salesforce) Type() string {
+	return SalesforceType
+}
+
+func (s *Salesforce) Close() error {
+	return nil
+}
@@ -0,0 +1,78 @@
+package drivers
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSalesforceRead(t *testing.T) {
+	var server *httptest.Server
+	var queries []string
+	tokens := 0
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/services/oauth2/token":
+			require.NoError(t, r.ParseForm())
+			require.Equal(t, "refresh_token", r.Form.Get("grant_type"))
+			tokens++
+			fmt.Fprintf(w, `{"access_token":"token%d","instance_url":"%s"}`, tokens, server.URL)
+		case "/services/data/v50.0/sobjects/Account/describe":
+			w.Write([]byte(`{"fields":[{"name":"Id","type":"id"},{"name":"Name","type":"string"},{"name":"Logo","type":"base64"},{"name":"SystemModstamp","type":"datetime"}]}`))
+		case "/services/data/v50.0/query":
+			//the first token is expired
+			if r.Header.Get("Authorization") == "Bearer token1" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			queries = append(queries, r.URL.Query().Get("q"))
+			w.Write([]byte(`{"done":false,"nextRecordsUrl":"/services/data/v50.0/query/01g-2","records":[{"attributes":{"type":"Account"},"Id":"001A","Name":"a","SystemModstamp":"2020-09-01T10:00:00.000+0000"}]}`))
+		case "/services/data/v50.0/query/01g-2":
+			w.Write([]byte(`{"done":true,"records":[{"attributes":{"type":"Account"},"Id":"001B","Name":"b","SystemModstamp":"2020-09-01T12:00:00.000+0200"}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	config := &SalesforceConfig{LoginURL: server.URL, ClientId: "id", ClientSecret: "secret", RefreshToken: "refresh", PageSize: 2, StartDate: "2020-01-01T00:00:00Z"}
+	require.NoError(t, config.Validate())
+	salesforce := &Salesforce{ctx: context.Background(), config: config, client: newAPIClient("Salesforce"), fields: map[string][]string{}}
+
+	result, err := salesforce.Read("Account", "")
+	require.NoError(t, err)
+	require.Equal(t, []map[string]interface{}{
+		{"Id": "001A", "Name": "a", "SystemModstamp": "2020-09-01T10:00:00.000+0000"},
+		{"Id": "001B", "Name": "b", "SystemModstamp": "2020-09-01T12:00:00.000+0200"},
+	}, result.Objects)
+	require.True(t, result.HasMore)
+	require.Equal(t, `{"system_modstamp":"2020-09-01T10:00:00.000Z","id":"001B"}`, result.State)
+	require.Equal(t, 2, tokens)
+
+	_, err = salesforce.Read("Account", result.State)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"SELECT Id,Name,SystemModstamp FROM Account WHERE SystemModstamp >= 2020-01-01T00:00:00.000Z ORDER BY SystemModstamp ASC, Id ASC LIMIT 2",
+		"SELECT Id,Name,SystemModstamp FROM Account WHERE (SystemModstamp > 2020-09-01T10:00:00.000Z OR (SystemModstamp = 2020-09-01T10:00:00.000Z AND Id > '001B')) ORDER BY SystemModstamp ASC, Id ASC LIMIT 2",
+	}, queries)
+
+	_, err = salesforce.Read("Account WHERE", "")
+	require.Error(t, err)
+
+	_, err = salesforce.Read("Unknown", "")
+	require.Error(t, err)
+}
+
+func TestSalesforceConfigValidate(t *testing.T) {
+	require.Error(t, (&SalesforceConfig{ClientId: "id", ClientSecret: "secret"}).Validate())
+	require.Error(t, (&SalesforceConfig{ClientId: "id", ClientSecret: "secret", RefreshToken: "token", StartDate: "2020-01-01"}).Validate())
+
+	config := &SalesforceConfig{ClientId: "id", ClientSecret: "secret", Username: "user", Password: "password", APIVersion: "v48.0"}
+	require.NoError(t, config.Validate())
+	require.Equal(t, defaultSalesforceLoginURL, config.LoginURL)
+	require.Equal(t, "48.0", config.APIVersion)
+	require.Equal(t, defaultSalesforcePageSize, config.PageSize)
+}
@@ -0,0 +1,207 @@
+package drivers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	stripeAPIURL          = "https://api.stripe.com/v1/"
+	defaultStripePageSize = 100
+	stripeCreatedField    = "created"
+)
+
+//stripeCollections are supported Stripe list API objects
+var stripeCollections = []string{"charges", "invoices", "subscriptions", "customers"}
+
+func init() {
+	RegisterConnector(StripeType, NewStripeConnector)
+}
+
+//StripeConfig is a Stripe source configuration. Collections are Stripe objects: charges, invoices, subscriptions, customers
+type StripeConfig struct {
+	SecretKey string `mapstructure:"secret_key" json:"secret_key,omitempty" yaml:"secret_key,omitempty"`
+	//Optional. Stripe-Version header (account default version is used if empty)
+	APIVersion string `mapstructure:"api_version" json:"api_version,omitempty" yaml:"api_version,omitempty"`
+	//objects per request (default and max 100)
+	PageSize int `mapstructure:"page_size" json:"page_size,omitempty" yaml:"page_size,omitempty"`
+	//Optional. Unix seconds: objects created before it aren't synchronized on the first sync
+	StartDate int64 `mapstructure:"start_date" json:"start_date,omitempty" yaml:"start_date,omitempty"`
+}
+
+func (sc *StripeConfig) Validate() error {
+	if sc == nil {
+		return errors.New("Stripe config is required")
+	}
+	if sc.SecretKey == "" {
+		return errors.New("Stripe secret_key is required")
+	}
+	if sc.PageSize <= 0 || sc.PageSize > defaultStripePageSize {
+		sc.PageSize = defaultStripePageSize
+	}
+	return nil
+}
+
+//stripeState is a Stripe collection cursor state. Stripe lists objects from the newest to the oldest, so a sync reads
+//pages of objects created >= CreatedGte page by page (StartingAfter is the last read object id) and the next sync
+//cursor is the max created value of the sync (MaxCreated)
+type stripeState struct {
+	CreatedGte    int64  `json:"created_gte"`
+	StartingAfter string `json:"starting_after,omitempty"`
+	MaxCreated    int64  `json:"max_created,omitempty"`
+}
+
+type stripeListResponse struct {
+	Data    []map[string]interface{} `json:"data"`
+	HasMore bool                     `json:"has_more"`
+}
+
+//Stripe is a connector which reads Stripe objects incrementally via created[gte] cursors
+//Objects created in the same second as the cursor are read again: they are deduplicated by the event id hash
+type Stripe struct {
+	ctx    context.Context
+	config *StripeConfig
+	client *http.Client
+	apiURL string
+}
+
+//NewStripeConnector is a ConnectorFactory of Stripe connector
+func NewStripeConnector(ctx context.Context, sourceId string, config map[string]interface{}) (Connector, error) {
+	stripeConfig := &StripeConfig{}
+	if err := unmarshalConfig(config, stripeConfig); err != nil {
+		return nil, err
+	}
+	if err := stripeConfig.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &Stripe{ctx: ctx, config: stripeConfig, client: &http.Client{Timeout: time.Minute}, apiURL: stripeAPIURL}, nil
+}
+
+func (s *Stripe) Discover() ([]*Collection, error) {
+	collections := make([]*Collection, 0, len(stripeCollections))
+	for _, name := range stripeCollections {
+		collections = append(collections, &Collection{Name: name, Incremental: true, CursorField: stripeCreatedField, PrimaryKeyFields: []string{"id"}})
+	}
+	return collections, nil
+}
+
+func (s *Stripe) Read(collection, state string) (*ReadResult, error) {
+	if !isStripeCollection(collection) {
+		return nil, fmt.Errorf("Stripe collection [%s] isn't supported. Available: [%s]", collection, strings.Join(stripeCollections, ", "))
+	}
+
+	cursor := &stripeState{CreatedGte: s.config.StartDate}
+	if state != "" {
+		if err := json.Unmarshal([]byte(state), cursor); err != nil {
+			return nil, fmt.Errorf("Stripe malformed state [%s]: %v", state, err)
+		}
+	}
+
+	response, err := s.list(collection, cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, object := range response.Data {
+		if created := stripeCreated(object); created > cursor.MaxCreated {
+			cursor.MaxCreated = created
+		}
+	}
+
+	result := &ReadResult{Objects: response.Data, HasMore: response.HasMore && len(response.Data) > 0}
+	if result.HasMore {
+		id, _ := response.Data[len(response.Data)-1]["id"].(string)
+		cursor.StartingAfter = id
+	} else {
+		//the sync is finished: the next one reads objects created since the newest object of this sync
+		next := &stripeState{CreatedGte: cursor.CreatedGte}
+		if cursor.MaxCreated > next.CreatedGte {
+			next.CreatedGte = cursor.MaxCreated
+		}
+		cursor = next
+	}
+
+	b, err := json.Marshal(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("Stripe error marshalling state: %v", err)
+	}
+	result.State = string(b)
+	return result, nil
+}
+
+//list request one page of Stripe list API: GET /v1/<collection>
+func (s *Stripe) list(collection string, cursor *stripeState) (*stripeListResponse, error) {
+	query := url.Values{}
+	query.Set("limit", strconv.Itoa(s.config.PageSize))
+	if cursor.CreatedGte > 0 {
+		query.Set("created[gte]", strconv.FormatInt(cursor.CreatedGte, 10))
+	}
+	if cursor.StartingAfter != "" {
+		query.Set("starting_after", cursor.StartingAfter)
+	}
+	//canceled subscriptions aren't listed by default
+	if collection == "subscriptions" {
+		query.Set("status", "all")
+	}
+
+	request, err := http.NewRequestWithContext(s.ctx, http.MethodGet, s.apiURL+collection+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Authorization", "Bearer "+s.config.SecretKey)
+	if s.config.APIVersion != "" {
+		request.Header.Set("Stripe-Version", s.config.APIVersion)
+	}
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("Error requesting Stripe [%s]: %v", collection, err)
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading Stripe [%s] response: %v", collection, err)
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Stripe [%s] responded with %d: %s", collection, response.StatusCode, string(body))
+	}
+
+	listResponse := &stripeListResponse{}
+	if err := json.Unmarshal(body, listResponse); err != nil {
+		return nil, fmt.Errorf("Error parsing Stripe [%s] response: %v", collection, err)
+	}
+	return listResponse, nil
+}
+
+func (s *Stripe) Type() string {
+	return StripeType
+}
+
+func (s *Stripe) Close() error {
+	return nil
+}
+
+func isStripeCollection(collection string) bool {
+	for _, name := range stripeCollections {
+		if name == collection {
+			return true
+		}
+	}
+	return false
+}
+
+//stripeCreated return object created unix seconds (JSON numbers are float64)
+func stripeCreated(object map[string]interface{}) int64 {
+	created, _ := object[stripeCreatedField].(float64)
+	return int64(created)
+}
@@ -0,0 +1,90 @@
+package drivers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestStripeRead(t *testing.T) {
+	//charges from the newest to the oldest as Stripe lists them
+	charges := []map[string]interface{}{
+		{"id": "ch_4", "created": 400},
+		{"id": "ch_3", "created": 300},
+		{"id": "ch_2", "created": 200},
+		{"id": "ch_1", "created": 100},
+	}
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer sk_test", r.Header.Get("Authorization"))
+		require.Equal(t, "/v1/charges", r.URL.Path)
+		queries = append(queries, r.URL.RawQuery)
+
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		createdGte, _ := strconv.Atoi(r.URL.Query().Get("created[gte]"))
+		startingAfter := r.URL.Query().Get("starting_after")
+
+		var data []map[string]interface{}
+		skip := startingAfter != ""
+		for _, charge := range charges {
+			if skip {
+				skip = charge["id"] != startingAfter
+				continue
+			}
+			if charge["created"].(int) >= createdGte {
+				data = append(data, charge)
+			}
+		}
+		hasMore := len(data) > limit
+		if hasMore {
+			data = data[:limit]
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": data, "has_more": hasMore})
+	}))
+	defer server.Close()
+
+	stripe := &Stripe{ctx: context.Background(), config: &StripeConfig{SecretKey: "sk_test", PageSize: 2, StartDate: 150}, client: server.Client(), apiURL: server.URL + "/v1/"}
+
+	result, err := stripe.Read("charges", "")
+	require.NoError(t, err)
+	require.Len(t, result.Objects, 2)
+	require.Equal(t, "ch_4", result.Objects[0]["id"])
+	require.True(t, result.HasMore)
+	require.Equal(t, `{"created_gte":150,"starting_after":"ch_3","max_created":400}`, result.State)
+
+	result, err = stripe.Read("charges", result.State)
+	require.NoError(t, err)
+	require.Len(t, result.Objects, 1)
+	require.Equal(t, "ch_2", result.Objects[0]["id"])
+	require.False(t, result.HasMore)
+	require.Equal(t, `{"created_gte":400}`, result.State)
+
+	//the next sync reads objects created since the newest one
+	charges = append([]map[string]interface{}{{"id": "ch_5", "created": 500}}, charges...)
+	result, err = stripe.Read("charges", result.State)
+	require.NoError(t, err)
+	require.Len(t, result.Objects, 2)
+	require.False(t, result.HasMore)
+	require.Equal(t, `{"created_gte":500}`, result.State)
+
+	require.Equal(t, []string{
+		"created%5Bgte%5D=150&limit=2",
+		"created%5Bgte%5D=150&limit=2&starting_after=ch_3",
+		"created%5Bgte%5D=400&limit=2",
+	}, queries)
+
+	_, err = stripe.Read("payouts", "")
+	require.Error(t, err)
+}
+
+func TestStripeConfigValidate(t *testing.T) {
+	require.Error(t, (&StripeConfig{}).Validate())
+
+	config := &StripeConfig{SecretKey: "sk_test", PageSize: 1000}
+	require.NoError(t, config.Validate())
+	require.Equal(t, defaultStripePageSize, config.PageSize)
+}
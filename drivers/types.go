@@ -1,6 +1,15 @@
 package drivers
 
 const (
-	GooglePlayType = "google_play"
-	FirebaseType   = "firebase"
+	GooglePlayType   = "google_play"
+	FirebaseType     = "firebase"
+	GoogleSheetsType = "google_sheets"
+	StripeType       = "stripe"
+	PostgresCdcType  = "postgres_cdc"
+	SalesforceType   = "salesforce"
+	HubSpotType      = "hubspot"
+	FacebookAdsType  = "facebook_ads"
+	GoogleAdsType    = "google_ads"
+	AmplitudeType    = "amplitude"
+	MixpanelType     = "mixpanel"
 )
@@ -0,0 +1,192 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+)
+
+//prefix of encrypted records and lines: plain records (written before encryption was enabled) are read as is
+const prefix = "enc1:"
+
+var (
+	prefixBytes = []byte(prefix)
+
+	ErrNotConfigured = errors.New("Encrypted data can't be decrypted: server.encryption.key isn't configured")
+
+	//Instance is a global cipher of locally buffered events files (queue, events logs, fallback, archive)
+	//nil if encryption isn't configured
+	Instance *Cipher
+)
+
+//Config is an encryption at rest configuration (server.encryption)
+//keys are base64 encoded 16, 24 or 32 bytes (AES-128, AES-192 or AES-256), file:///path to a file or env://NAME of
+//an environment variable with such value or aws-kms://region/ciphertext data key encrypted by AWS KMS (see resolveKey)
+type Config struct {
+	Key string `mapstructure:"key"`
+	//keys of files which were written before the key rotation. They are used only for decryption
+	PreviousKeys []string `mapstructure:"previous_keys"`
+}
+
+//Cipher encrypts data with AES-GCM using the current key and decrypts it with the current or previous keys
+type Cipher struct {
+	current cipher.AEAD
+	all     []cipher.AEAD
+}
+
+//Init create global Instance if the key is configured
+func Init(config Config) error {
+	if config.Key == "" {
+		if len(config.PreviousKeys) > 0 {
+			return errors.New("server.encryption.key is required if previous_keys are configured")
+		}
+		return nil
+	}
+
+	c, err := NewCipher(config.Key, config.PreviousKeys...)
+	if err != nil {
+		return err
+	}
+	Instance = c
+	return nil
+}
+
+//NewCipher return Cipher with the current key and previous keys (see Config)
+func NewCipher(key string, previousKeys ...string) (*Cipher, error) {
+	c := &Cipher{}
+	for i, k := range append([]string{key}, previousKeys...) {
+		aead, err := newAEAD(k)
+		if err != nil {
+			if i == 0 {
+				return nil, fmt.Errorf("Error creating cipher from server.encryption.key: %v", err)
+			}
+			return nil, fmt.Errorf("Error creating cipher from server.encryption.previous_keys[%d]: %v", i-1, err)
+		}
+		c.all = append(c.all, aead)
+	}
+	c.current = c.all[0]
+	return c, nil
+}
+
+func newAEAD(key string) (cipher.AEAD, error) {
+	keyBytes, err := resolveKey(key)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(keyBytes)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+//Encrypt return prefix + nonce + sealed data
+func (c *Cipher) Encrypt(data []byte) ([]byte, error) {
+	nonce := make([]byte, c.current.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("Error generating nonce: %v", err)
+	}
+
+	result := make([]byte, 0, len(prefix)+len(nonce)+len(data)+c.current.Overhead())
+	result = append(result, prefixBytes...)
+	result = append(result, nonce...)
+	return c.current.Seal(result, nonce, data, nil), nil
+}
+
+//Decrypt return opened data from Encrypt result. Data without prefix is returned as is
+func (c *Cipher) Decrypt(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, prefixBytes) {
+		return data, nil
+	}
+	return c.open(data[len(prefix):])
+}
+
+//open return data opened with the first matched key: the current or previous ones
+func (c *Cipher) open(sealed []byte) ([]byte, error) {
+	for _, aead := range c.all {
+		if len(sealed) < aead.NonceSize() {
+			return nil, errors.New("Encrypted data is truncated")
+		}
+		if opened, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil); err == nil {
+			return opened, nil
+		}
+	}
+	return nil, errors.New("Encrypted data can't be decrypted with configured keys")
+}
+
+//Encrypt return data encrypted by Instance or data as is if encryption isn't configured
+func Encrypt(data []byte) ([]byte, error) {
+	if Instance == nil {
+		return data, nil
+	}
+	return Instance.Encrypt(data)
+}
+
+//Decrypt return data decrypted by Instance. Plain data is returned as is
+func Decrypt(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, prefixBytes) {
+		return data, nil
+	}
+	if Instance == nil {
+		return nil, ErrNotConfigured
+	}
+	return Instance.Decrypt(data)
+}
+
+//EncryptLine return line encrypted by Instance as prefix + base64 (without new line chars) or line as is if encryption isn't configured
+func EncryptLine(line []byte) ([]byte, error) {
+	if Instance == nil {
+		return line, nil
+	}
+
+	encrypted, err := Instance.Encrypt(line)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]byte, len(prefix)+base64.StdEncoding.EncodedLen(len(encrypted)-len(prefix)))
+	copy(result, prefixBytes)
+	base64.StdEncoding.Encode(result[len(prefix):], encrypted[len(prefix):])
+	return result, nil
+}
+
+//DecryptLine return line decrypted by Instance. Plain line is returned as is
+func DecryptLine(line []byte) ([]byte, error) {
+	if !bytes.HasPrefix(line, prefixBytes) {
+		return line, nil
+	}
+	if Instance == nil {
+		return nil, ErrNotConfigured
+	}
+
+	sealed := make([]byte, base64.StdEncoding.DecodedLen(len(line)-len(prefix)))
+	n, err := base64.StdEncoding.Decode(sealed, line[len(prefix):])
+	if err != nil {
+		return nil, fmt.Errorf("Encrypted line is malformed: %v", err)
+	}
+	return Instance.open(sealed[:n])
+}
+
+//DecryptLines return payload with all lines decrypted (see DecryptLine)
+func DecryptLines(payload []byte) ([]byte, error) {
+	if !bytes.Contains(payload, prefixBytes) {
+		return payload, nil
+	}
+
+	lines := bytes.Split(payload, []byte("\n"))
+	for i, line := range lines {
+		if !bytes.HasPrefix(line, prefixBytes) {
+			continue
+		}
+		decrypted, err := DecryptLine(bytes.TrimSpace(line))
+		if err != nil {
+			return nil, fmt.Errorf("Error decrypting line %d: %v", i+1, err)
+		}
+		lines[i] = decrypted
+	}
+	return bytes.Join(lines, []byte("\n")), nil
+}
@@ -0,0 +1,90 @@
+package encryption
+
+import (
+	"encoding/base64"
+	"github.com/stretchr/testify/require"
+	"os"
+	"testing"
+)
+
+var (
+	testKey    = base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+	testOldKey = base64.StdEncoding.EncodeToString([]byte("fedcba9876543210fedcba9876543210"))
+)
+
+func TestEncryptLines(t *testing.T) {
+	require.NoError(t, Init(Config{Key: testOldKey}))
+	oldLine, err := EncryptLine([]byte(`{"event_type":"old"}`))
+	require.NoError(t, err)
+
+	require.NoError(t, Init(Config{Key: testKey, PreviousKeys: []string{testOldKey}}))
+	defer func() { Instance = nil }()
+	newLine, err := EncryptLine([]byte(`{"event_type":"new"}`))
+	require.NoError(t, err)
+	require.NotContains(t, string(newLine), "event_type")
+
+	payload := append(append(append(append([]byte(`{"event_type":"plain"}`+"\n"), oldLine...), '\n'), newLine...), '\n')
+	decrypted, err := DecryptLines(payload)
+	require.NoError(t, err)
+	require.Equal(t, `{"event_type":"plain"}`+"\n"+`{"event_type":"old"}`+"\n"+`{"event_type":"new"}`+"\n", string(decrypted))
+
+	require.NoError(t, Init(Config{Key: testOldKey}))
+	_, err = DecryptLine(newLine)
+	require.EqualError(t, err, "Encrypted data can't be decrypted with configured keys")
+
+	Instance = nil
+	_, err = DecryptLines(payload)
+	require.EqualError(t, err, "Error decrypting line 2: "+ErrNotConfigured.Error())
+}
+
+func TestEncryptRecords(t *testing.T) {
+	c, err := NewCipher(testKey)
+	require.NoError(t, err)
+
+	record := []byte(`{"FactBytes":"e30="}`)
+	encrypted, err := c.Encrypt(record)
+	require.NoError(t, err)
+	decrypted, err := c.Decrypt(encrypted)
+	require.NoError(t, err)
+	require.Equal(t, record, decrypted)
+
+	plain, err := c.Decrypt(record)
+	require.NoError(t, err)
+	require.Equal(t, record, plain)
+
+	_, err = NewCipher("not base64!")
+	require.Error(t, err)
+	_, err = NewCipher(base64.StdEncoding.EncodeToString([]byte("short")))
+	require.EqualError(t, err, "Error creating cipher from server.encryption.key: crypto/aes: invalid key size 5")
+}
+
+func TestResolveKey(t *testing.T) {
+	os.Setenv("EN_TEST_ENCRYPTION_KEY", testKey)
+	defer os.Unsetenv("EN_TEST_ENCRYPTION_KEY")
+	key, err := resolveKey("env://EN_TEST_ENCRYPTION_KEY")
+	require.NoError(t, err)
+	require.Equal(t, "0123456789abcdef0123456789abcdef", string(key))
+
+	_, err = resolveKey("env://EN_TEST_MISSING_KEY")
+	require.EqualError(t, err, "Key environment variable EN_TEST_MISSING_KEY is empty")
+
+	defaultKMSDecrypt := kmsDecrypt
+	defer func() { kmsDecrypt = defaultKMSDecrypt }()
+	kmsDecrypt = func(region string, ciphertext []byte) ([]byte, error) {
+		require.Equal(t, "eu-west-1", region)
+		require.Equal(t, "ciphertext", string(ciphertext))
+		return []byte("fedcba9876543210fedcba9876543210"), nil
+	}
+	c, err := NewCipher("aws-kms://eu-west-1/"+base64.StdEncoding.EncodeToString([]byte("ciphertext")), testKey)
+	require.NoError(t, err)
+	old, err := NewCipher(testOldKey)
+	require.NoError(t, err)
+	encrypted, err := old.Encrypt([]byte("data"))
+	require.NoError(t, err)
+	decrypted, err := c.Decrypt(encrypted)
+	require.NoError(t, err)
+	require.Equal(t, "data", string(decrypted))
+
+	_, err = resolveKey("aws-kms://ciphertext")
+	require.EqualError(t, err, "AWS KMS key must be aws-kms://region/base64 ciphertext")
+}
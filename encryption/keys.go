@@ -0,0 +1,75 @@
+package encryption
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+const (
+	filePrefix   = "file://"
+	envPrefix    = "env://"
+	awsKMSPrefix = "aws-kms://"
+)
+
+//kmsDecrypt return plaintext of the AWS KMS ciphertext blob. AWS credentials are taken from the default chain
+//(environment variables, shared credentials file or instance role). Overridden in tests
+var kmsDecrypt = func(region string, ciphertext []byte) ([]byte, error) {
+	kmsSession, err := session.NewSession(aws.NewConfig().WithRegion(region))
+	if err != nil {
+		return nil, err
+	}
+	output, err := kms.New(kmsSession).Decrypt(&kms.DecryptInput{CiphertextBlob: ciphertext})
+	if err != nil {
+		return nil, err
+	}
+	return output.Plaintext, nil
+}
+
+//resolveKey return raw key bytes of the configured key value:
+//file:///path - base64 key is read from the file
+//env://NAME - base64 key is read from the environment variable
+//aws-kms://region/ciphertext - base64 data key ciphertext (e.g. from aws kms generate-data-key) is decrypted by AWS KMS on startup
+//other values are base64 keys
+func resolveKey(key string) ([]byte, error) {
+	switch {
+	case strings.HasPrefix(key, filePrefix):
+		b, err := ioutil.ReadFile(strings.TrimPrefix(key, filePrefix))
+		if err != nil {
+			return nil, fmt.Errorf("Error reading key file: %v", err)
+		}
+		key = string(b)
+	case strings.HasPrefix(key, envPrefix):
+		name := strings.TrimPrefix(key, envPrefix)
+		key = os.Getenv(name)
+		if key == "" {
+			return nil, fmt.Errorf("Key environment variable %s is empty", name)
+		}
+	case strings.HasPrefix(key, awsKMSPrefix):
+		parts := strings.SplitN(strings.TrimPrefix(key, awsKMSPrefix), "/", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, errors.New("AWS KMS key must be aws-kms://region/base64 ciphertext")
+		}
+		ciphertext, err := base64.StdEncoding.DecodeString(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, fmt.Errorf("AWS KMS ciphertext must be base64 encoded: %v", err)
+		}
+		plaintext, err := kmsDecrypt(parts[0], ciphertext)
+		if err != nil {
+			return nil, fmt.Errorf("Error decrypting data key with AWS KMS: %v", err)
+		}
+		return plaintext, nil
+	}
+
+	keyBytes, err := base64.StdEncoding.DecodeString(strings.TrimSpace(key))
+	if err != nil {
+		return nil, fmt.Errorf("Key must be base64 encoded: %v", err)
+	}
+	return keyBytes, nil
+}
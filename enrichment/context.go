@@ -0,0 +1,117 @@
+package enrichment
+
+import (
+	"github.com/jitsucom/eventnative/appconfig"
+	"github.com/jitsucom/eventnative/jsonutils"
+)
+
+const (
+	tokenKey  = "api_key"
+	sourceKey = "src"
+)
+
+var (
+	collectionPath = jsonutils.NewJsonPath("/eventn_ctx/collection_id")
+	//request metadata paths of js, api and 3rd party events
+	ipPaths        = []*jsonutils.JsonPath{jsonutils.NewJsonPath("/source_ip"), jsonutils.NewJsonPath("/device_ctx/ip"), jsonutils.NewJsonPath("/eventn_ctx/ip")}
+	userAgentPaths = []*jsonutils.JsonPath{jsonutils.NewJsonPath("/eventn_ctx/user_agent"), jsonutils.NewJsonPath("/device_ctx/user_agent")}
+)
+
+//EventContext is an origin of the event: rules can behave differently per token, collection or source
+//without reading it from the event payload. nil context is an empty one
+type EventContext struct {
+	Token   string
+	TokenId string
+	//sources collection
+	Collection string
+	//js, api, source (sources sync), segment, ga, etc.
+	Source string
+	//request metadata. Empty if the event hasn't been received via HTTP API
+	Ip        string
+	UserAgent string
+}
+
+//NewEventContext return context from the event system fields (api_key, src, eventn_ctx.collection_id, source_ip etc.)
+func NewEventContext(fact map[string]interface{}) *EventContext {
+	ctx := &EventContext{
+		Token:      stringValue(fact, jsonutils.NewJsonPath("/"+tokenKey)),
+		Collection: stringValue(fact, collectionPath),
+		Source:     stringValue(fact, jsonutils.NewJsonPath("/"+sourceKey)),
+		Ip:         firstValue(fact, ipPaths),
+		UserAgent:  firstValue(fact, userAgentPaths),
+	}
+	if ctx.Token != "" && appconfig.Instance != nil && appconfig.Instance.AuthorizationService != nil {
+		ctx.TokenId = appconfig.Instance.AuthorizationService.GetTokenId(ctx.Token)
+	}
+
+	return ctx
+}
+
+func firstValue(fact map[string]interface{}, paths []*jsonutils.JsonPath) string {
+	for _, path := range paths {
+		if value := stringValue(fact, path); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func stringValue(fact map[string]interface{}, path *jsonutils.JsonPath) string {
+	value, ok := path.Get(fact)
+	if !ok {
+		return ""
+	}
+	str, _ := value.(string)
+	return str
+}
+
+//contextRule executes the rule only for events of the configured tokens, collections and sources
+type contextRule struct {
+	Rule
+
+	//token ids or token values
+	tokens      map[string]bool
+	collections map[string]bool
+	sources     map[string]bool
+}
+
+func newContextRule(rule Rule, config *RuleConfig) Rule {
+	if len(config.Tokens) == 0 && len(config.Collections) == 0 && len(config.Sources) == 0 {
+		return rule
+	}
+
+	return &contextRule{Rule: rule, tokens: toSet(config.Tokens), collections: toSet(config.Collections), sources: toSet(config.Sources)}
+}
+
+func (cr *contextRule) Execute(ctx *EventContext, fact map[string]interface{}) error {
+	if !cr.matches(ctx) {
+		return nil
+	}
+
+	return cr.Rule.Execute(ctx, fact)
+}
+
+//matches return true if every configured condition matches the context
+func (cr *contextRule) matches(ctx *EventContext) bool {
+	if ctx == nil {
+		ctx = &EventContext{}
+	}
+	if len(cr.tokens) > 0 && !cr.tokens[ctx.Token] && !cr.tokens[ctx.TokenId] {
+		return false
+	}
+	if len(cr.collections) > 0 && !cr.collections[ctx.Collection] {
+		return false
+	}
+	if len(cr.sources) > 0 && !cr.sources[ctx.Source] {
+		return false
+	}
+	return true
+}
+
+func toSet(values []string) map[string]bool {
+	set := map[string]bool{}
+	for _, value := range values {
+		set[value] = true
+	}
+	return set
+}
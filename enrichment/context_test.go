@@ -0,0 +1,56 @@
+package enrichment
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type countingRule struct {
+	executed int
+}
+
+func (cr *countingRule) Name() string {
+	return "counting"
+}
+
+func (cr *countingRule) Execute(ctx *EventContext, fact map[string]interface{}) error {
+	cr.executed++
+	return nil
+}
+
+func TestNewEventContext(t *testing.T) {
+	ctx := NewEventContext(map[string]interface{}{
+		"api_key":    "token1",
+		"src":        "source",
+		"source_ip":  "10.10.10.10",
+		"eventn_ctx": map[string]interface{}{"collection_id": "users", "user_agent": "Mozilla/5.0"},
+	})
+	require.Equal(t, &EventContext{Token: "token1", Collection: "users", Source: "source", Ip: "10.10.10.10", UserAgent: "Mozilla/5.0"}, ctx)
+
+	require.Equal(t, &EventContext{Ip: "11.11.11.11"}, NewEventContext(map[string]interface{}{"device_ctx": map[string]interface{}{"ip": "11.11.11.11"}}))
+}
+
+func TestContextRule(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   *RuleConfig
+		ctx      *EventContext
+		expected int
+	}{
+		{"without conditions", &RuleConfig{}, nil, 1},
+		{"token value", &RuleConfig{Tokens: []string{"token1"}}, &EventContext{Token: "token1", TokenId: "id1"}, 1},
+		{"token id", &RuleConfig{Tokens: []string{"id1"}}, &EventContext{Token: "token1", TokenId: "id1"}, 1},
+		{"other token", &RuleConfig{Tokens: []string{"token2"}}, &EventContext{Token: "token1", TokenId: "id1"}, 0},
+		{"nil context", &RuleConfig{Tokens: []string{"token1"}}, nil, 0},
+		{"collection and source", &RuleConfig{Collections: []string{"users"}, Sources: []string{"source"}}, &EventContext{Collection: "users", Source: "source"}, 1},
+		{"collection but other source", &RuleConfig{Collections: []string{"users"}, Sources: []string{"api"}}, &EventContext{Collection: "users", Source: "source"}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counting := &countingRule{}
+			rule := newContextRule(counting, tt.config)
+			require.NoError(t, rule.Execute(tt.ctx, map[string]interface{}{}))
+			require.Equal(t, tt.expected, counting.executed)
+		})
+	}
+}
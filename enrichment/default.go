@@ -6,4 +6,7 @@ var (
 
 	DefaultApiIpRuleConfig = &RuleConfig{Name: IpLookup, From: "/device_ctx/ip", To: "/eventn_ctx/location", Raw: true}
 	DefaultApiUaRuleConig  = &RuleConfig{Name: UserAgentParse, From: "/device_ctx/user_agent", To: "/eventn_ctx/parsed_ua", Raw: true}
+
+	DefaultThirdPartyIpRuleConfig = &RuleConfig{Name: IpLookup, From: "/eventn_ctx/ip", To: "/eventn_ctx/location", Raw: true}
+	DefaultThirdPartyUaRuleConfig = &RuleConfig{Name: UserAgentParse, From: "/eventn_ctx/user_agent", To: "/eventn_ctx/parsed_ua", Raw: true}
 )
@@ -26,7 +26,7 @@ func NewIpLookupRule(source, destination *jsonutils.JsonPath, convertResult bool
 	}, nil
 }
 
-func (ir *IpLookupRule) Execute(fact map[string]interface{}) error {
+func (ir *IpLookupRule) Execute(ctx *EventContext, fact map[string]interface{}) error {
 	ipIface, ok := ir.source.Get(fact)
 	if !ok {
 		return nil
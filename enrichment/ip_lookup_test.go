@@ -109,7 +109,7 @@ func TestIpLookup(t *testing.T) {
 			ipRule, err := NewIpLookupRule(jsonutils.NewJsonPath(tt.source), jsonutils.NewJsonPath(tt.destination), tt.convertResult)
 			require.NoError(t, err)
 
-			err = ipRule.Execute(tt.input)
+			err = ipRule.Execute(nil, tt.input)
 			if tt.expectedErr != "" {
 				require.Error(t, err)
 				require.Equal(t, tt.expectedErr, err.Error())
@@ -9,7 +9,8 @@ import (
 
 type Rule interface {
 	Name() string
-	Execute(fact map[string]interface{}) error
+	//Execute enrich the fact. ctx is the event origin (it might be nil)
+	Execute(ctx *EventContext, fact map[string]interface{}) error
 }
 
 func NewRule(ruleConfig *RuleConfig) (Rule, error) {
@@ -27,14 +28,20 @@ func NewRule(ruleConfig *RuleConfig) (Rule, error) {
 		return nil, errors.New("'to' must be a valid path like: /node1/node2")
 	}
 
+	var rule Rule
 	switch ruleConfig.Name {
 	case IpLookup:
-		return NewIpLookupRule(source, destination, !ruleConfig.Raw)
+		rule, err = NewIpLookupRule(source, destination, !ruleConfig.Raw)
 	case UserAgentParse:
-		return NewUserAgentParseRule(source, destination, !ruleConfig.Raw)
+		rule, err = NewUserAgentParseRule(source, destination, !ruleConfig.Raw)
 	default:
 		return nil, fmt.Errorf("Unsupported enrichment rule type: %s", ruleConfig.Name)
 	}
+	if err != nil {
+		return nil, err
+	}
+
+	return newContextRule(rule, ruleConfig), nil
 }
 
 //RuleConfig configuration for rules
@@ -45,6 +52,10 @@ type RuleConfig struct {
 	Name string `mapstructure:"name" json:"name,omitempty" yaml:"name,omitempty"`
 	From string `mapstructure:"from" json:"from,omitempty" yaml:"from,omitempty"`
 	To   string `mapstructure:"to" json:"to,omitempty" yaml:"to,omitempty"`
+	//optional conditions: the rule is executed only for events of these tokens (ids or values), collections and sources (src)
+	Tokens      []string `mapstructure:"tokens" json:"tokens,omitempty" yaml:"tokens,omitempty"`
+	Collections []string `mapstructure:"collections" json:"collections,omitempty" yaml:"collections,omitempty"`
+	Sources     []string `mapstructure:"sources" json:"sources,omitempty" yaml:"sources,omitempty"`
 	//System field
 	Raw bool
 }
@@ -70,5 +81,18 @@ func (r *RuleConfig) Validate() error {
 }
 
 func (r *RuleConfig) String() string {
-	return fmt.Sprintf("[%s] %s -> %s", r.Name, r.From, r.To)
+	var conditions []string
+	if len(r.Tokens) > 0 {
+		conditions = append(conditions, fmt.Sprintf("tokens: %v", r.Tokens))
+	}
+	if len(r.Collections) > 0 {
+		conditions = append(conditions, fmt.Sprintf("collections: %v", r.Collections))
+	}
+	if len(r.Sources) > 0 {
+		conditions = append(conditions, fmt.Sprintf("sources: %v", r.Sources))
+	}
+	if len(conditions) == 0 {
+		return fmt.Sprintf("[%s] %s -> %s", r.Name, r.From, r.To)
+	}
+	return fmt.Sprintf("[%s] %s -> %s (%s)", r.Name, r.From, r.To, strings.Join(conditions, ", "))
 }
@@ -21,7 +21,7 @@ func NewUserAgentParseRule(source, destination *jsonutils.JsonPath, convertResul
 	return &UserAgentParseRule{source: source, destination: destination, convertResult: convertResult, uaResolver: appconfig.Instance.UaResolver}, nil
 }
 
-func (uap *UserAgentParseRule) Execute(fact map[string]interface{}) error {
+func (uap *UserAgentParseRule) Execute(ctx *EventContext, fact map[string]interface{}) error {
 	uaIface, ok := uap.source.Get(fact)
 	if !ok {
 		return nil
@@ -82,7 +82,7 @@ func TestUserAgentParse(t *testing.T) {
 			uaRule, err := NewUserAgentParseRule(jsonutils.NewJsonPath(tt.source), jsonutils.NewJsonPath(tt.destination), tt.convertResult)
 			require.NoError(t, err)
 
-			err = uaRule.Execute(tt.input)
+			err = uaRule.Execute(nil, tt.input)
 			if tt.expectedErr != "" {
 				require.Error(t, err)
 				require.Equal(t, tt.expectedErr, err.Error())
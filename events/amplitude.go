@@ -0,0 +1,114 @@
+package events
+
+import (
+	"fmt"
+	"github.com/jitsucom/eventnative/geo"
+	"github.com/jitsucom/eventnative/timestamp"
+	"github.com/jitsucom/eventnative/useragent"
+	"time"
+)
+
+const (
+	amplitudeSrc        = "amplitude"
+	amplitudeContextKey = "amplitude_context"
+
+	amplitudeTimeLayout = "2006-01-02 15:04:05.999999"
+)
+
+//Amplitude export keys which are mapped into fact fields. The rest are kept in eventn_ctx.amplitude_context
+var amplitudeMappedKeys = map[string]bool{
+	"uuid": true, "$insert_id": true, "event_type": true, "event_time": true, "user_id": true, "device_id": true, "amplitude_id": true,
+	"user_properties": true, "event_properties": true, "ip_address": true, "language": true, "country": true, "region": true,
+	"city": true, "location_lat": true, "location_lng": true, "os_name": true, "os_version": true, "device_family": true,
+	"device_brand": true, "device_model": true,
+}
+
+//ConvertAmplitude return fact in JS SDK format from Amplitude Export API event:
+//uuid ($insert_id) -> eventn_ctx.event_id, user_id/device_id/amplitude_id/user_properties -> eventn_ctx.user,
+//event_time -> eventn_ctx.utc_time, ip_address/language -> eventn_ctx fields, country/region/city/location_lat/location_lng
+//-> eventn_ctx.location, os_name/os_version/device_* -> eventn_ctx.parsed_ua, event_properties -> event_data.
+//The rest of fields (session_id, platform, library, groups, etc.) are kept in eventn_ctx.amplitude_context
+func ConvertAmplitude(event map[string]interface{}) (Fact, error) {
+	if event == nil {
+		return nil, nilFactErr
+	}
+
+	eventType, _ := event["event_type"].(string)
+	if eventType == "" {
+		return nil, fmt.Errorf("Amplitude event must have event_type: %v", event["uuid"])
+	}
+
+	eventnCtx := map[string]interface{}{}
+	if uuid, ok := event["uuid"]; ok && uuid != nil {
+		eventnCtx[eventIdKey] = fmt.Sprint(uuid)
+	} else if insertId, ok := event["$insert_id"]; ok && insertId != nil {
+		eventnCtx[eventIdKey] = fmt.Sprint(insertId)
+	}
+
+	user := copyObject(getObject(event, "user_properties"))
+	setIfNotEmpty(user, "id", event, "user_id")
+	setIfNotEmpty(user, "anonymous_id", event, "device_id")
+	setIfNotEmpty(user, "amplitude_id", event, "amplitude_id")
+	if len(user) > 0 {
+		eventnCtx["user"] = user
+	}
+
+	if eventTimeStr, ok := event["event_time"].(string); ok {
+		eventTime, err := time.Parse(amplitudeTimeLayout, eventTimeStr)
+		if err != nil {
+			return nil, fmt.Errorf("Malformed Amplitude event_time [%s]: %v", eventTimeStr, err)
+		}
+		eventnCtx["utc_time"] = timestamp.ToISOFormat(eventTime)
+	}
+
+	setIfNotEmpty(eventnCtx, "ip", event, "ip_address")
+	setIfNotEmpty(eventnCtx, "user_language", event, "language")
+
+	location := map[string]interface{}{}
+	setIfNotEmpty(location, "country", event, "country")
+	setIfNotEmpty(location, "region", event, "region")
+	setIfNotEmpty(location, "city", event, "city")
+	setIfNotEmpty(location, "latitude", event, "location_lat")
+	setIfNotEmpty(location, "longitude", event, "location_lng")
+	if len(location) > 0 {
+		eventnCtx[geo.GeoDataKey] = location
+	}
+
+	parsedUa := map[string]interface{}{}
+	setIfNotEmpty(parsedUa, "os_family", event, "os_name")
+	setIfNotEmpty(parsedUa, "os_version", event, "os_version")
+	setIfNotEmpty(parsedUa, "device_family", event, "device_family")
+	setIfNotEmpty(parsedUa, "device_brand", event, "device_brand")
+	setIfNotEmpty(parsedUa, "device_model", event, "device_model")
+	if len(parsedUa) > 0 {
+		eventnCtx[useragent.ParsedUaKey] = parsedUa
+	}
+
+	rest := map[string]interface{}{}
+	for k, v := range event {
+		if !amplitudeMappedKeys[k] && v != nil {
+			rest[k] = v
+		}
+	}
+	if len(rest) > 0 {
+		eventnCtx[amplitudeContextKey] = rest
+	}
+
+	fact := Fact{
+		"src":        amplitudeSrc,
+		"event_type": eventType,
+		eventnKey:    eventnCtx,
+	}
+	if eventData := getObject(event, "event_properties"); len(eventData) > 0 {
+		fact["event_data"] = copyObject(eventData)
+	}
+
+	return fact, nil
+}
+
+//setIfNotEmpty is setIfExists which skips empty strings as well (exports contain empty strings instead of nulls)
+func setIfNotEmpty(to map[string]interface{}, toKey string, from map[string]interface{}, fromKey string) {
+	if v, ok := from[fromKey]; ok && v != nil && v != "" {
+		to[toKey] = v
+	}
+}
@@ -0,0 +1,88 @@
+package events
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestConvertAmplitude(t *testing.T) {
+	tests := []struct {
+		name        string
+		event       map[string]interface{}
+		expected    Fact
+		expectedErr string
+	}{
+		{
+			"Nil event",
+			nil,
+			nil,
+			"Input fact can't be nil",
+		},
+		{
+			"Event without type",
+			map[string]interface{}{"uuid": "u1"},
+			nil,
+			"Amplitude event must have event_type: u1",
+		},
+		{
+			"Malformed time",
+			map[string]interface{}{"event_type": "click", "event_time": "2020/09/01"},
+			nil,
+			"Malformed Amplitude event_time [2020/09/01]: parsing time \"2020/09/01\" as \"2006-01-02 15:04:05.999999\": cannot parse \"/09/01\" as \"-\"",
+		},
+		{
+			"Full event",
+			map[string]interface{}{
+				"uuid":             "5f1b1a3c-1b1a-4a3c-8c1b-1a3c1b1a4a3c",
+				"event_type":       "Order Completed",
+				"event_time":       "2020-09-01 10:00:00.123000",
+				"user_id":          "u1",
+				"device_id":        "d1",
+				"amplitude_id":     float64(123),
+				"user_properties":  map[string]interface{}{"plan": "pro"},
+				"event_properties": map[string]interface{}{"revenue": 10.5},
+				"ip_address":       "10.10.10.10",
+				"language":         "English",
+				"country":          "United States",
+				"region":           "California",
+				"city":             "",
+				"location_lat":     nil,
+				"os_name":          "ios",
+				"os_version":       "14.0",
+				"device_model":     "iPhone 11",
+				"session_id":       float64(1598954400000),
+				"platform":         "iOS",
+			},
+			Fact{
+				"src":        "amplitude",
+				"event_type": "Order Completed",
+				"event_data": map[string]interface{}{"revenue": 10.5},
+				"eventn_ctx": map[string]interface{}{
+					"event_id":      "5f1b1a3c-1b1a-4a3c-8c1b-1a3c1b1a4a3c",
+					"user":          map[string]interface{}{"id": "u1", "anonymous_id": "d1", "amplitude_id": float64(123), "plan": "pro"},
+					"utc_time":      "2020-09-01T10:00:00.123000Z",
+					"ip":            "10.10.10.10",
+					"user_language": "English",
+					"location":      map[string]interface{}{"country": "United States", "region": "California"},
+					"parsed_ua":     map[string]interface{}{"os_family": "ios", "os_version": "14.0", "device_model": "iPhone 11"},
+					"amplitude_context": map[string]interface{}{
+						"session_id": float64(1598954400000),
+						"platform":   "iOS",
+					},
+				},
+			},
+			"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := ConvertAmplitude(tt.event)
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
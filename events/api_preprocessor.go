@@ -6,7 +6,6 @@ import (
 	"github.com/jitsucom/eventnative/enrichment"
 	"github.com/jitsucom/eventnative/geo"
 	"github.com/jitsucom/eventnative/jsonutils"
-	"github.com/jitsucom/eventnative/logging"
 	"github.com/jitsucom/eventnative/useragent"
 )
 
@@ -51,17 +50,17 @@ func (ap *ApiPreprocessor) Preprocess(fact Fact) (Fact, error) {
 
 	_, ok := ap.geoDataPath.Get(fact)
 	if !ok {
-		err := ap.ipLookupRule.Execute(fact)
+		err := ap.ipLookupRule.Execute(nil, fact)
 		if err != nil {
-			logging.SystemErrorf("Error executing default api ip lookup enrichment rule: %v", err)
+			eventsLogger.SystemErrorf("Error executing default api ip lookup enrichment rule: %v", err)
 		}
 	}
 
 	_, ok = ap.parsedUaPath.Get(fact)
 	if !ok {
-		err := ap.uaParseRule.Execute(fact)
+		err := ap.uaParseRule.Execute(nil, fact)
 		if err != nil {
-			logging.SystemErrorf("Error executing default api ua parse enrichment rule: %v", err)
+			eventsLogger.SystemErrorf("Error executing default api ua parse enrichment rule: %v", err)
 		}
 	}
 
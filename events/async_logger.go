@@ -4,11 +4,24 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/encryption"
 	"github.com/jitsucom/eventnative/safego"
 	"io"
+	"sync/atomic"
+	"time"
 )
 
+//flushTimeout is max waiting time for writing of consumed events on Close if the flush deadline isn't set
+const flushTimeout = 30 * time.Second
+
+//flushDeadline is unix nanoseconds of the shutdown drain deadline (0 - isn't set)
+var flushDeadline int64
+
+//flushRequest is put into the channel on Close: all events consumed before it have been written when it is received
+type flushRequest struct {
+	done chan struct{}
+}
+
 //AsyncLogger write json logs to file system in different goroutine
 type AsyncLogger struct {
 	writer             io.WriteCloser
@@ -26,8 +39,40 @@ func (al *AsyncLogger) ConsumeAny(object interface{}) {
 	al.logCh <- object
 }
 
-//Close underlying log file writer
+//SetFlushDeadline make AsyncLogger.Close wait for writing of consumed events until the deadline instead of flushTimeout
+//It is set on shutdown: loggers are closed within the remaining drain timeout. Zero time resets the deadline
+func SetFlushDeadline(deadline time.Time) {
+	var nanos int64
+	if !deadline.IsZero() {
+		nanos = deadline.UnixNano()
+	}
+	atomic.StoreInt64(&flushDeadline, nanos)
+}
+
+//flushWaiting return time left until the flush deadline or flushTimeout if the deadline isn't set
+func flushWaiting() time.Duration {
+	if nanos := atomic.LoadInt64(&flushDeadline); nanos > 0 {
+		return time.Until(time.Unix(0, nanos))
+	}
+	return flushTimeout
+}
+
+//Close write all consumed events (events from the channel buffer aren't lost on shutdown) and close underlying log file writer
 func (al *AsyncLogger) Close() (resultErr error) {
+	waiting := flushWaiting()
+	timeout := time.After(waiting)
+	flush := &flushRequest{done: make(chan struct{})}
+	select {
+	case al.logCh <- flush:
+		select {
+		case <-flush.done:
+		case <-timeout:
+			eventsLogger.Errorf("Consumed events haven't been written in %s: %d events are lost", waiting, len(al.logCh))
+		}
+	case <-timeout:
+		eventsLogger.Errorf("Events channel is full for %s: %d events are lost", waiting, len(al.logCh))
+	}
+
 	if err := al.writer.Close(); err != nil {
 		return fmt.Errorf("Error closing writer: %v", err)
 	}
@@ -41,22 +86,32 @@ func NewAsyncLogger(writer io.WriteCloser, showInGlobalLogger bool) *AsyncLogger
 	safego.RunWithRestart(func() {
 		for {
 			fact := <-logger.logCh
+			if flush, ok := fact.(*flushRequest); ok {
+				close(flush.done)
+				continue
+			}
 			bts, err := json.Marshal(fact)
 			if err != nil {
-				logging.Errorf("Error marshaling event to json: %v", err)
+				eventsLogger.Errorf("Error marshaling event to json: %v", err)
 				continue
 			}
 
 			if logger.showInGlobalLogger {
 				prettyJsonBytes, _ := json.MarshalIndent(&fact, " ", " ")
-				logging.Info(string(prettyJsonBytes))
+				eventsLogger.Infof("%s", prettyJsonBytes)
+			}
+
+			bts, err = encryption.EncryptLine(bts)
+			if err != nil {
+				eventsLogger.Errorf("Error encrypting event: %v", err)
+				continue
 			}
 
 			buf := bytes.NewBuffer(bts)
 			buf.Write([]byte("\n"))
 
 			if _, err := logger.writer.Write(buf.Bytes()); err != nil {
-				logging.Errorf("Error writing event to log file: %v", err)
+				eventsLogger.Errorf("Error writing event to log file: %v", err)
 				continue
 			}
 		}
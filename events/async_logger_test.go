@@ -0,0 +1,75 @@
+package events
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+//slowWriter writes slower than events are consumed and fails writes after Close
+type slowWriter struct {
+	sync.Mutex
+	buf    bytes.Buffer
+	closed bool
+}
+
+func (sw *slowWriter) Write(p []byte) (int, error) {
+	time.Sleep(time.Millisecond)
+	sw.Lock()
+	defer sw.Unlock()
+	if sw.closed {
+		return 0, ErrQueueClosed
+	}
+	return sw.buf.Write(p)
+}
+
+func (sw *slowWriter) Close() error {
+	sw.Lock()
+	defer sw.Unlock()
+	sw.closed = true
+	return nil
+}
+
+//blockingWriter doesn't write until release is closed
+type blockingWriter struct {
+	release chan struct{}
+}
+
+func (bw *blockingWriter) Write(p []byte) (int, error) {
+	<-bw.release
+	return len(p), nil
+}
+
+func (bw *blockingWriter) Close() error {
+	return nil
+}
+
+func TestAsyncLoggerCloseDrainsConsumed(t *testing.T) {
+	writer := &slowWriter{}
+	logger := NewAsyncLogger(writer, false)
+	for i := 0; i < 100; i++ {
+		logger.Consume(Fact{"i": i}, "token")
+	}
+
+	require.NoError(t, logger.Close())
+	require.Equal(t, 100, strings.Count(writer.buf.String(), "\n"), "All consumed events must be written before the writer is closed")
+}
+
+func TestAsyncLoggerCloseFlushDeadline(t *testing.T) {
+	SetFlushDeadline(time.Now().Add(50 * time.Millisecond))
+	defer SetFlushDeadline(time.Time{})
+
+	writer := &blockingWriter{release: make(chan struct{})}
+	defer close(writer.release)
+	logger := NewAsyncLogger(writer, false)
+	for i := 0; i < 10; i++ {
+		logger.Consume(Fact{"i": i}, "token")
+	}
+
+	started := time.Now()
+	require.NoError(t, logger.Close())
+	require.True(t, time.Since(started) < 500*time.Millisecond, "Close must return at the flush deadline")
+}
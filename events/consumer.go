@@ -8,6 +8,9 @@ import (
 	"io"
 )
 
+//eventsLogger writes events component log lines (see logging.Configure levels)
+var eventsLogger = logging.Component(logging.EventsComponent)
+
 type Fact map[string]interface{}
 
 type FailedFact struct {
@@ -24,7 +27,7 @@ type Consumer interface {
 func (f Fact) Serialize() string {
 	b, err := json.Marshal(f)
 	if err != nil {
-		logging.Errorf("Error serializing event [%v]: %v", f, err)
+		eventsLogger.Errorf("Error serializing event [%v]: %v", f, err)
 		return fmt.Sprintf("%v", f)
 	}
 
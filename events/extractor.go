@@ -1,5 +1,11 @@
 package events
 
+//TestKey is a flag of test traffic events. It is set by clients or for events of test mode tokens (see IsTest)
+const TestKey = "_test"
+
+//TableKey is a fixed table name of service events (e.g. sessions summaries). It overrides destinations table name templates
+const TableKey = "_table"
+
 func ExtractEventId(fact Fact) string {
 	if fact == nil {
 		return ""
@@ -32,3 +38,16 @@ func ExtractEventId(fact Fact) string {
 
 	return ""
 }
+
+//IsTest return true if the event is test traffic: it has _test flag which is true (or "true"). Such events are stored
+//into <table>_test tables
+func IsTest(fact Fact) bool {
+	switch flag := fact[TestKey].(type) {
+	case bool:
+		return flag
+	case string:
+		return flag == "true"
+	default:
+		return false
+	}
+}
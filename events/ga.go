@@ -0,0 +1,327 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/timestamp"
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+const (
+	gaSrc  = "ga"
+	ga4Src = "ga4"
+
+	gaContextKey = "ga_context"
+)
+
+var (
+	//Measurement Protocol v1 parameters -> eventn_ctx fields
+	gaContextParams = map[string]string{
+		"dl": "url",
+		"dr": "referer",
+		"dt": "page_title",
+		"dp": "doc_path",
+		"dh": "doc_host",
+		"ul": "user_language",
+		"sr": "screen_resolution",
+		"vp": "vp_size",
+		"de": "doc_encoding",
+	}
+	//Measurement Protocol v1 parameters -> eventn_ctx.utm fields
+	gaUtmParams = map[string]string{
+		"cn": "campaign",
+		"cs": "source",
+		"cm": "medium",
+		"ck": "term",
+		"cc": "content",
+	}
+	//Measurement Protocol v1 parameters -> eventn_ctx.click_id fields
+	gaClickIdParams = map[string]string{
+		"gclid": "gclid",
+		"dclid": "dclid",
+	}
+	//Measurement Protocol v1 parameters -> event_data fields
+	gaDataParams = map[string]string{
+		"ec":  "category",
+		"ea":  "action",
+		"el":  "label",
+		"ev":  "value",
+		"cd":  "screen_name",
+		"ti":  "transaction_id",
+		"ta":  "affiliation",
+		"tr":  "revenue",
+		"ts":  "shipping",
+		"tt":  "tax",
+		"cu":  "currency",
+		"in":  "item_name",
+		"ip":  "item_price",
+		"iq":  "item_quantity",
+		"ic":  "item_code",
+		"iv":  "item_category",
+		"pa":  "product_action",
+		"sn":  "social_network",
+		"sa":  "social_action",
+		"st":  "social_target",
+		"exd": "exception_description",
+		"exf": "exception_fatal",
+		"utc": "timing_category",
+		"utv": "timing_variable",
+		"utt": "timing_time",
+		"utl": "timing_label",
+	}
+	//Measurement Protocol v1 numeric parameters
+	gaNumericParams = map[string]bool{"ev": true, "tr": true, "ts": true, "tt": true, "ip": true, "iq": true, "utt": true}
+	//Measurement Protocol v1 parameters which are mapped separately or ignored (cache buster)
+	gaSkippedParams = map[string]bool{"v": true, "t": true, "cid": true, "uid": true, "uip": true, "ua": true, "qt": true, "z": true}
+
+	gaCustomDimension = regexp.MustCompile(`^cd(\d+)$`)
+	gaCustomMetric    = regexp.MustCompile(`^cm(\d+)$`)
+
+	//GA4 event params -> eventn_ctx fields
+	ga4ContextParams = map[string]string{
+		"page_location":     "url",
+		"page_referrer":     "referer",
+		"page_title":        "page_title",
+		"language":          "user_language",
+		"screen_resolution": "screen_resolution",
+	}
+)
+
+//ConvertGA return fact in JS SDK format from Google Analytics Measurement Protocol v1 hit:
+//cid/uid -> eventn_ctx.user, dl/dr/dt/dp/dh/ul/sr/vp/de -> eventn_ctx page and client fields, cn/cs/cm/ck/cc -> eventn_ctx.utm,
+//ec/ea/el/ev and ecommerce, social, exception, timing parameters -> event_data, cdN/cmN -> event_data.dimensionN/metricN.
+//The rest of parameters (e.g. tid) are kept in eventn_ctx.ga_context. event_type is ea for event hits, pageview or screen otherwise hit type.
+//ip and userAgent are used if the hit doesn't have uip and ua overrides
+func ConvertGA(hit url.Values, ip, userAgent string, now time.Time) (Fact, error) {
+	hitType := hit.Get("t")
+	if hitType == "" {
+		return nil, errors.New("Hit type (t) is required")
+	}
+	clientId, userId := hit.Get("cid"), hit.Get("uid")
+	if clientId == "" && userId == "" {
+		return nil, errors.New("Client id (cid) or user id (uid) is required")
+	}
+
+	eventnCtx := map[string]interface{}{}
+	user := map[string]interface{}{}
+	if clientId != "" {
+		user["anonymous_id"] = clientId
+	}
+	if userId != "" {
+		user["id"] = userId
+	}
+	eventnCtx["user"] = user
+
+	if uip := hit.Get("uip"); uip != "" {
+		ip = uip
+	}
+	if ip != "" {
+		eventnCtx["ip"] = ip
+	}
+	if ua := hit.Get("ua"); ua != "" {
+		userAgent = ua
+	}
+	if userAgent != "" {
+		eventnCtx["user_agent"] = userAgent
+	}
+
+	//queue time: hit was collected qt milliseconds ago
+	eventTime := now
+	if qt, err := strconv.ParseInt(hit.Get("qt"), 10, 64); err == nil && qt > 0 {
+		eventTime = now.Add(-time.Duration(qt) * time.Millisecond)
+	}
+	eventnCtx["utc_time"] = timestamp.ToISOFormat(eventTime.UTC())
+
+	utm := map[string]interface{}{}
+	clickId := map[string]interface{}{}
+	gaContext := map[string]interface{}{}
+	eventData := map[string]interface{}{}
+	for param, values := range hit {
+		if len(values) == 0 || gaSkippedParams[param] {
+			continue
+		}
+		value := values[0]
+
+		if key, ok := gaContextParams[param]; ok {
+			eventnCtx[key] = value
+		} else if key, ok := gaUtmParams[param]; ok {
+			utm[key] = value
+		} else if key, ok := gaClickIdParams[param]; ok {
+			clickId[key] = value
+		} else if key, ok := gaDataParams[param]; ok {
+			eventData[key] = gaValue(param, value)
+		} else if match := gaCustomDimension.FindStringSubmatch(param); match != nil {
+			eventData["dimension"+match[1]] = value
+		} else if match := gaCustomMetric.FindStringSubmatch(param); match != nil {
+			eventData["metric"+match[1]] = gaNumber(value)
+		} else {
+			gaContext[param] = value
+		}
+	}
+	if len(utm) > 0 {
+		eventnCtx["utm"] = utm
+	}
+	if len(clickId) > 0 {
+		eventnCtx["click_id"] = clickId
+	}
+	if len(gaContext) > 0 {
+		eventnCtx[gaContextKey] = gaContext
+	}
+
+	fact := Fact{
+		"src":        gaSrc,
+		"event_type": gaEventType(hitType, hit.Get("ea")),
+		eventnKey:    eventnCtx,
+	}
+	if len(eventData) > 0 {
+		fact["event_data"] = eventData
+	}
+
+	return fact, nil
+}
+
+//ConvertGA4 return facts in JS SDK format from GA4 Measurement Protocol request (one per event):
+//client_id (app_instance_id)/user_id/user_properties -> eventn_ctx.user, timestamp_micros -> eventn_ctx.utc_time,
+//name -> event_type (page_view -> pageview), page_location/page_referrer/page_title/language/screen_resolution params -> eventn_ctx fields,
+//the rest of params -> event_data. measurementId (measurement_id or firebase_app_id) is kept in eventn_ctx.ga_context
+func ConvertGA4(payload map[string]interface{}, measurementId, ip, userAgent string, now time.Time) ([]Fact, error) {
+	if payload == nil {
+		return nil, nilFactErr
+	}
+
+	clientId, ok := payload["client_id"]
+	if !ok || clientId == nil {
+		clientId, ok = payload["app_instance_id"]
+	}
+	if !ok || clientId == nil {
+		return nil, errors.New("client_id or app_instance_id is required")
+	}
+
+	rawEvents, ok := payload["events"].([]interface{})
+	if !ok || len(rawEvents) == 0 {
+		return nil, errors.New("events must be a non-empty array")
+	}
+
+	user := map[string]interface{}{}
+	for name, property := range getObject(payload, "user_properties") {
+		if propertyObject, ok := property.(map[string]interface{}); ok {
+			user[name] = propertyObject["value"]
+		}
+	}
+	user["anonymous_id"] = clientId
+	if userId, ok := payload["user_id"]; ok && userId != nil {
+		user["id"] = userId
+	}
+
+	requestTime := ga4Time(payload["timestamp_micros"], now)
+
+	var facts []Fact
+	for i, rawEvent := range rawEvents {
+		event, ok := rawEvent.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("Event #%d must be an object", i)
+		}
+		name, _ := event["name"].(string)
+		if name == "" {
+			return nil, fmt.Errorf("Event #%d must have name", i)
+		}
+
+		eventnCtx := map[string]interface{}{
+			"user":     copyObject(user),
+			"utc_time": timestamp.ToISOFormat(ga4Time(event["timestamp_micros"], requestTime)),
+		}
+		if measurementId != "" {
+			eventnCtx[gaContextKey] = map[string]interface{}{"measurement_id": measurementId}
+		}
+		if ip != "" {
+			eventnCtx["ip"] = ip
+		}
+		if userAgent != "" {
+			eventnCtx["user_agent"] = userAgent
+		}
+
+		eventData := map[string]interface{}{}
+		for param, value := range getObject(event, "params") {
+			if key, ok := ga4ContextParams[param]; ok {
+				eventnCtx[key] = value
+			} else {
+				eventData[param] = value
+			}
+		}
+
+		eventType := name
+		if name == "page_view" {
+			eventType = "pageview"
+		}
+		fact := Fact{
+			"src":        ga4Src,
+			"event_type": eventType,
+			eventnKey:    eventnCtx,
+		}
+		if len(eventData) > 0 {
+			fact["event_data"] = eventData
+		}
+		facts = append(facts, fact)
+	}
+
+	return facts, nil
+}
+
+func gaEventType(hitType, eventAction string) string {
+	switch hitType {
+	case "event":
+		if eventAction != "" {
+			return eventAction
+		}
+		return hitType
+	case "pageview":
+		return "pageview"
+	case "screenview":
+		return "screen"
+	default:
+		return hitType
+	}
+}
+
+func gaValue(param, value string) interface{} {
+	if gaNumericParams[param] {
+		return gaNumber(value)
+	}
+	return value
+}
+
+//gaNumber return int64 or float64 value or the original string if it isn't a number
+func gaNumber(value string) interface{} {
+	if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(value, 64); err == nil {
+		return f
+	}
+	return value
+}
+
+//ga4Time return UTC time from timestamp_micros (number, json.Number or string) or defaultTime
+func ga4Time(micros interface{}, defaultTime time.Time) time.Time {
+	var value int64
+	switch v := micros.(type) {
+	case float64:
+		value = int64(v)
+	case json.Number, string:
+		parsed, err := strconv.ParseInt(fmt.Sprint(v), 10, 64)
+		if err != nil {
+			return defaultTime.UTC()
+		}
+		value = parsed
+	default:
+		return defaultTime.UTC()
+	}
+	if value <= 0 {
+		return defaultTime.UTC()
+	}
+	return time.Unix(0, value*int64(time.Microsecond)).UTC()
+}
@@ -0,0 +1,196 @@
+package events
+
+import (
+	"github.com/stretchr/testify/require"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestConvertGA(t *testing.T) {
+	now := time.Date(2020, 11, 10, 12, 0, 10, 0, time.UTC)
+	tests := []struct {
+		name        string
+		hit         string
+		expected    Fact
+		expectedErr string
+	}{
+		{
+			"Without hit type",
+			"v=1&cid=c1",
+			nil,
+			"Hit type (t) is required",
+		},
+		{
+			"Without client id",
+			"v=1&t=pageview",
+			nil,
+			"Client id (cid) or user id (uid) is required",
+		},
+		{
+			"Pageview with utm and custom dimension",
+			"v=1&tid=UA-1-1&cid=c1&t=pageview&dl=https%3A%2F%2Fsite.com%2Fa&dt=A&ul=en-us&cn=black_friday&cs=google&cd1=pro&cm2=5&gclid=g1",
+			Fact{
+				"src":        "ga",
+				"event_type": "pageview",
+				"event_data": map[string]interface{}{"dimension1": "pro", "metric2": int64(5)},
+				"eventn_ctx": map[string]interface{}{
+					"user":          map[string]interface{}{"anonymous_id": "c1"},
+					"ip":            "1.1.1.1",
+					"user_agent":    "Mozilla/5.0",
+					"utc_time":      "2020-11-10T12:00:10.000000Z",
+					"url":           "https://site.com/a",
+					"page_title":    "A",
+					"user_language": "en-us",
+					"utm":           map[string]interface{}{"campaign": "black_friday", "source": "google"},
+					"click_id":      map[string]interface{}{"gclid": "g1"},
+					"ga_context":    map[string]interface{}{"tid": "UA-1-1"},
+				},
+			},
+			"",
+		},
+		{
+			"Event with overrides and queue time",
+			"v=1&cid=c1&uid=u1&t=event&ec=video&ea=play&el=intro&ev=10&uip=10.10.10.10&ua=Bot&qt=5000&z=123",
+			Fact{
+				"src":        "ga",
+				"event_type": "play",
+				"event_data": map[string]interface{}{"category": "video", "action": "play", "label": "intro", "value": int64(10)},
+				"eventn_ctx": map[string]interface{}{
+					"user":       map[string]interface{}{"anonymous_id": "c1", "id": "u1"},
+					"ip":         "10.10.10.10",
+					"user_agent": "Bot",
+					"utc_time":   "2020-11-10T12:00:05.000000Z",
+				},
+			},
+			"",
+		},
+		{
+			"Screenview",
+			"v=1&uid=u1&t=screenview&cd=Home",
+			Fact{
+				"src":        "ga",
+				"event_type": "screen",
+				"event_data": map[string]interface{}{"screen_name": "Home"},
+				"eventn_ctx": map[string]interface{}{
+					"user":       map[string]interface{}{"id": "u1"},
+					"ip":         "1.1.1.1",
+					"user_agent": "Mozilla/5.0",
+					"utc_time":   "2020-11-10T12:00:10.000000Z",
+				},
+			},
+			"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hit, err := url.ParseQuery(tt.hit)
+			require.NoError(t, err)
+
+			actual, err := ConvertGA(hit, "1.1.1.1", "Mozilla/5.0", now)
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestConvertGA4(t *testing.T) {
+	now := time.Date(2020, 11, 10, 12, 0, 10, 0, time.UTC)
+	tests := []struct {
+		name        string
+		payload     map[string]interface{}
+		expected    []Fact
+		expectedErr string
+	}{
+		{
+			"Nil payload",
+			nil,
+			nil,
+			"Input fact can't be nil",
+		},
+		{
+			"Without client id",
+			map[string]interface{}{"events": []interface{}{map[string]interface{}{"name": "login"}}},
+			nil,
+			"client_id or app_instance_id is required",
+		},
+		{
+			"Without events",
+			map[string]interface{}{"client_id": "c1"},
+			nil,
+			"events must be a non-empty array",
+		},
+		{
+			"Event without name",
+			map[string]interface{}{"client_id": "c1", "events": []interface{}{map[string]interface{}{}}},
+			nil,
+			"Event #0 must have name",
+		},
+		{
+			"Events with user properties and timestamps",
+			map[string]interface{}{
+				"client_id":        "c1",
+				"user_id":          "u1",
+				"timestamp_micros": "1605009600000000",
+				"user_properties":  map[string]interface{}{"plan": map[string]interface{}{"value": "pro"}},
+				"events": []interface{}{
+					map[string]interface{}{
+						"name":   "page_view",
+						"params": map[string]interface{}{"page_location": "https://site.com", "page_title": "Home", "engagement_time_msec": 100.0},
+					},
+					map[string]interface{}{
+						"name":             "purchase",
+						"timestamp_micros": 1605009605000000.0,
+						"params":           map[string]interface{}{"value": 10.5, "currency": "USD"},
+					},
+				},
+			},
+			[]Fact{
+				{
+					"src":        "ga4",
+					"event_type": "pageview",
+					"event_data": map[string]interface{}{"engagement_time_msec": 100.0},
+					"eventn_ctx": map[string]interface{}{
+						"user":       map[string]interface{}{"anonymous_id": "c1", "id": "u1", "plan": "pro"},
+						"utc_time":   "2020-11-10T12:00:00.000000Z",
+						"ga_context": map[string]interface{}{"measurement_id": "G-1"},
+						"ip":         "1.1.1.1",
+						"user_agent": "Mozilla/5.0",
+						"url":        "https://site.com",
+						"page_title": "Home",
+					},
+				},
+				{
+					"src":        "ga4",
+					"event_type": "purchase",
+					"event_data": map[string]interface{}{"value": 10.5, "currency": "USD"},
+					"eventn_ctx": map[string]interface{}{
+						"user":       map[string]interface{}{"anonymous_id": "c1", "id": "u1", "plan": "pro"},
+						"utc_time":   "2020-11-10T12:00:05.000000Z",
+						"ga_context": map[string]interface{}{"measurement_id": "G-1"},
+						"ip":         "1.1.1.1",
+						"user_agent": "Mozilla/5.0",
+					},
+				},
+			},
+			"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := ConvertGA4(tt.payload, "G-1", "1.1.1.1", "Mozilla/5.0", now)
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"errors"
 	"fmt"
 	"github.com/jitsucom/eventnative/enrichment"
-	"github.com/jitsucom/eventnative/logging"
 )
 
 var nilFactErr = errors.New("Input fact can't be nil")
@@ -43,14 +42,14 @@ func (jp *JsPreprocessor) Preprocess(fact Fact) (Fact, error) {
 		return nil, nilFactErr
 	}
 
-	err := jp.ipLookupRule.Execute(fact)
+	err := jp.ipLookupRule.Execute(nil, fact)
 	if err != nil {
-		logging.SystemErrorf("Error executing default js ip lookup enrichment rule: %v", err)
+		eventsLogger.SystemErrorf("Error executing default js ip lookup enrichment rule: %v", err)
 	}
 
-	err = jp.uaParseRule.Execute(fact)
+	err = jp.uaParseRule.Execute(nil, fact)
 	if err != nil {
-		logging.SystemErrorf("Error executing default js ua parse enrichment rule: %v", err)
+		eventsLogger.SystemErrorf("Error executing default js ua parse enrichment rule: %v", err)
 	}
 
 	return fact, nil
@@ -0,0 +1,155 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/geo"
+	"github.com/jitsucom/eventnative/timestamp"
+	"github.com/jitsucom/eventnative/useragent"
+	"math"
+	"strings"
+	"time"
+)
+
+const (
+	mixpanelSrc        = "mixpanel"
+	mixpanelContextKey = "mixpanel_context"
+	mixpanelPageView   = "$mp_web_page_view"
+)
+
+//Mixpanel properties -> eventn_ctx fields
+var mixpanelContextProperties = map[string]string{
+	"$current_url": "url",
+	"$referrer":    "referer",
+}
+
+//Mixpanel properties -> eventn_ctx.location and eventn_ctx.parsed_ua fields
+var (
+	mixpanelLocationProperties = map[string]string{"mp_country_code": "country", "$region": "region", "$city": "city"}
+	mixpanelUaProperties       = map[string]string{"$browser": "ua_family", "$browser_version": "ua_version", "$os": "os_family", "$device": "device_family"}
+)
+
+//Mixpanel properties which are mapped separately
+var mixpanelMappedProperties = map[string]bool{
+	"time": true, "distinct_id": true, "$insert_id": true, "$user_id": true, "$device_id": true, "$screen_width": true, "$screen_height": true,
+}
+
+//ConvertMixpanel return fact in JS SDK format from Mixpanel raw export event {"event": ..., "properties": {...}}:
+//$insert_id -> eventn_ctx.event_id, $user_id/$device_id (or distinct_id)/distinct_id -> eventn_ctx.user id/anonymous_id/distinct_id,
+//time (seconds or milliseconds in location) -> eventn_ctx.utc_time, $current_url/$referrer/$screen_* -> eventn_ctx fields,
+//utm_* -> eventn_ctx.utm, mp_country_code/$region/$city -> eventn_ctx.location, $browser/$os/$device -> eventn_ctx.parsed_ua.
+//The rest of $ and mp_ properties are kept in eventn_ctx.mixpanel_context, custom properties -> event_data.
+//$mp_web_page_view event is converted into pageview
+func ConvertMixpanel(event map[string]interface{}, location *time.Location) (Fact, error) {
+	if event == nil {
+		return nil, nilFactErr
+	}
+
+	eventType, _ := event["event"].(string)
+	if eventType == "" {
+		return nil, errors.New("Mixpanel event must have event name")
+	}
+	if eventType == mixpanelPageView {
+		eventType = "pageview"
+	}
+	properties := getObject(event, "properties")
+
+	eventnCtx := map[string]interface{}{}
+	setIfNotEmpty(eventnCtx, eventIdKey, properties, "$insert_id")
+
+	user := map[string]interface{}{}
+	setIfNotEmpty(user, "id", properties, "$user_id")
+	setIfNotEmpty(user, "anonymous_id", properties, "distinct_id")
+	setIfNotEmpty(user, "anonymous_id", properties, "$device_id")
+	setIfNotEmpty(user, "distinct_id", properties, "distinct_id")
+	if len(user) > 0 {
+		eventnCtx["user"] = user
+	}
+
+	if eventTime, ok := mixpanelTime(properties["time"], location); ok {
+		eventnCtx["utc_time"] = timestamp.ToISOFormat(eventTime)
+	}
+	if width, ok := properties["$screen_width"]; ok {
+		eventnCtx["screen_resolution"] = fmt.Sprintf("%vx%v", width, properties["$screen_height"])
+	}
+
+	locationData := map[string]interface{}{}
+	parsedUa := map[string]interface{}{}
+	utm := map[string]interface{}{}
+	rest := map[string]interface{}{}
+	eventData := map[string]interface{}{}
+	for k, v := range properties {
+		if mixpanelMappedProperties[k] || v == nil {
+			continue
+		}
+		if ctxKey, ok := mixpanelContextProperties[k]; ok {
+			eventnCtx[ctxKey] = v
+		} else if locationKey, ok := mixpanelLocationProperties[k]; ok {
+			locationData[locationKey] = v
+		} else if uaKey, ok := mixpanelUaProperties[k]; ok {
+			parsedUa[uaKey] = v
+		} else if strings.HasPrefix(k, "utm_") {
+			utm[strings.TrimPrefix(k, "utm_")] = v
+		} else if strings.HasPrefix(k, "$") || strings.HasPrefix(k, "mp_") {
+			rest[k] = v
+		} else {
+			eventData[k] = v
+		}
+	}
+	if len(locationData) > 0 {
+		eventnCtx[geo.GeoDataKey] = locationData
+	}
+	if len(parsedUa) > 0 {
+		eventnCtx[useragent.ParsedUaKey] = parsedUa
+	}
+	if len(utm) > 0 {
+		eventnCtx["utm"] = utm
+	}
+	if len(rest) > 0 {
+		eventnCtx[mixpanelContextKey] = rest
+	}
+
+	fact := Fact{
+		"src":        mixpanelSrc,
+		"event_type": eventType,
+		eventnKey:    eventnCtx,
+	}
+	if len(eventData) > 0 {
+		fact["event_data"] = eventData
+	}
+
+	return fact, nil
+}
+
+//mixpanelTime return UTC time of Mixpanel time property: unix seconds or milliseconds. Legacy projects export
+//seconds shifted into the project timezone: location is used for shifting them back
+func mixpanelTime(value interface{}, location *time.Location) (time.Time, bool) {
+	var unix float64
+	switch v := value.(type) {
+	case float64:
+		unix = v
+	case json.Number:
+		parsed, err := v.Float64()
+		if err != nil {
+			return time.Time{}, false
+		}
+		unix = parsed
+	default:
+		return time.Time{}, false
+	}
+
+	var t time.Time
+	if unix > 1e12 {
+		//milliseconds since 2001-09-09
+		t = time.Unix(0, int64(math.Round(unix))*int64(time.Millisecond)).UTC()
+	} else {
+		seconds := math.Floor(unix)
+		t = time.Unix(int64(seconds), int64(math.Round((unix-seconds)*1e6))*int64(time.Microsecond)).UTC()
+	}
+	if location != nil && location != time.UTC {
+		//project local wall clock -> UTC
+		t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), location).UTC()
+	}
+	return t, true
+}
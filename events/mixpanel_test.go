@@ -0,0 +1,122 @@
+package events
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestConvertMixpanel(t *testing.T) {
+	losAngeles, err := time.LoadLocation("America/Los_Angeles")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		event       map[string]interface{}
+		location    *time.Location
+		expected    Fact
+		expectedErr string
+	}{
+		{
+			"Nil event",
+			nil,
+			time.UTC,
+			nil,
+			"Input fact can't be nil",
+		},
+		{
+			"Event without name",
+			map[string]interface{}{"properties": map[string]interface{}{}},
+			time.UTC,
+			nil,
+			"Mixpanel event must have event name",
+		},
+		{
+			"Page view",
+			map[string]interface{}{
+				"event": "$mp_web_page_view",
+				"properties": map[string]interface{}{
+					"time":            json.Number("1598954400"),
+					"distinct_id":     "d1",
+					"$insert_id":      "i1",
+					"$current_url":    "https://site.com/a",
+					"$referrer":       "https://google.com",
+					"$screen_width":   json.Number("1920"),
+					"$screen_height":  json.Number("1080"),
+					"$browser":        "Chrome",
+					"$os":             "Mac OS X",
+					"mp_country_code": "US",
+					"$city":           "San Francisco",
+					"utm_source":      "google",
+					"mp_lib":          "web",
+					"plan":            "pro",
+				},
+			},
+			time.UTC,
+			Fact{
+				"src":        "mixpanel",
+				"event_type": "pageview",
+				"event_data": map[string]interface{}{"plan": "pro"},
+				"eventn_ctx": map[string]interface{}{
+					"event_id":          "i1",
+					"user":              map[string]interface{}{"anonymous_id": "d1", "distinct_id": "d1"},
+					"utc_time":          "2020-09-01T10:00:00.000000Z",
+					"url":               "https://site.com/a",
+					"referer":           "https://google.com",
+					"screen_resolution": "1920x1080",
+					"location":          map[string]interface{}{"country": "US", "city": "San Francisco"},
+					"parsed_ua":         map[string]interface{}{"ua_family": "Chrome", "os_family": "Mac OS X"},
+					"utm":               map[string]interface{}{"source": "google"},
+					"mixpanel_context":  map[string]interface{}{"mp_lib": "web"},
+				},
+			},
+			"",
+		},
+		{
+			"Identified user with legacy project timezone time",
+			map[string]interface{}{
+				"event": "Signed Up",
+				"properties": map[string]interface{}{
+					//2020-09-01 10:00:00 in Los Angeles
+					"time":        float64(1598954400),
+					"distinct_id": "u1",
+					"$user_id":    "u1",
+					"$device_id":  "d1",
+				},
+			},
+			losAngeles,
+			Fact{
+				"src":        "mixpanel",
+				"event_type": "Signed Up",
+				"eventn_ctx": map[string]interface{}{
+					"user":     map[string]interface{}{"id": "u1", "anonymous_id": "d1", "distinct_id": "u1"},
+					"utc_time": "2020-09-01T17:00:00.000000Z",
+				},
+			},
+			"",
+		},
+		{
+			"Milliseconds time",
+			map[string]interface{}{"event": "e", "properties": map[string]interface{}{"time": float64(1598954400123)}},
+			time.UTC,
+			Fact{
+				"src":        "mixpanel",
+				"event_type": "e",
+				"eventn_ctx": map[string]interface{}{"utc_time": "2020-09-01T10:00:00.123000Z"},
+			},
+			"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := ConvertMixpanel(tt.event, tt.location)
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
@@ -4,13 +4,19 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/encryption"
 	"github.com/jitsucom/eventnative/parsers"
 	"github.com/joncrlsn/dque"
+	"os"
+	"path"
+	"sync"
 	"time"
 )
 
-const eventsPerPersistedFile = 2000
+const (
+	eventsPerPersistedFile = 2000
+	spoolPostfix           = "-spool"
+)
 
 var ErrQueueClosed = errors.New("queue is closed")
 
@@ -18,25 +24,42 @@ type QueuedFact struct {
 	FactBytes    []byte
 	DequeuedTime time.Time
 	TokenId      string
+	//failed write attempts count (for retry policy)
+	Attempts int `json:",omitempty"`
 }
 
 // QueuedFactBuilder creates and returns a new *events.QueuedFact (must be pointer).
-// This is used when we load a segment of the queue from disk.
+// This is used when we load a segment of the legacy queue from disk.
 func QueuedFactBuilder() interface{} {
 	return &QueuedFact{}
 }
 
+//PersistentQueue is a disk-backed events queue of one stream destination
+//consumer must call Commit after the event from PeekBlock has been processed (stored, re-enqueued or sent to fallback)
 type PersistentQueue struct {
-	queue *dque.DQue
+	queue *segmentQueue
+
+	queueName   string
+	fallbackDir string
+	spoolMutex  sync.Mutex
+	spool       *PersistentQueue
 }
 
-func NewPersistentQueue(queueName, fallbackDir string) (*PersistentQueue, error) {
-	queue, err := dque.NewOrOpen(queueName, fallbackDir, eventsPerPersistedFile, QueuedFactBuilder)
+//NewPersistentQueue open or create queue in fallbackDir/queueName.queue directory
+//maxSizeBytes limits size of not committed events on disk (0 - unlimited). If it is exceeded - IsFull returns true
+//events from legacy (dque) queue directory are moved into the new queue
+func NewPersistentQueue(queueName, fallbackDir string, maxSizeBytes int64) (*PersistentQueue, error) {
+	queue, err := openSegmentQueue(path.Join(fallbackDir, queueName+".queue"), defaultMaxSegmentBytes, maxSizeBytes)
 	if err != nil {
 		return nil, fmt.Errorf("Error opening/creating event queue [%s]: %v", queueName, err)
 	}
 
-	return &PersistentQueue{queue: queue}, nil
+	pq := &PersistentQueue{queue: queue, queueName: queueName, fallbackDir: fallbackDir}
+	if err := pq.migrateLegacy(queueName, fallbackDir); err != nil {
+		eventsLogger.Errorf("Error moving events from legacy queue [%s]: %v", queueName, err)
+	}
+
+	return pq, nil
 }
 
 func (pq *PersistentQueue) Consume(f Fact, tokenId string) {
@@ -44,44 +67,184 @@ func (pq *PersistentQueue) Consume(f Fact, tokenId string) {
 }
 
 func (pq *PersistentQueue) ConsumeTimed(f Fact, t time.Time, tokenId string) {
+	if err := pq.put(f, t, tokenId, 0, true); err != nil {
+		logSkippedEvent(f, err)
+	}
+}
+
+//ConsumeWithError put event into the queue and return error if it can't be done (e.g. the queue is full)
+func (pq *PersistentQueue) ConsumeWithError(f Fact, tokenId string) error {
+	return pq.put(f, time.Now(), tokenId, 0, true)
+}
+
+//Requeue put event from PeekBlock to the end of the queue for retry with failed attempts count. It won't be processed until t
+//max queue size isn't checked because the event will be committed right after requeue
+func (pq *PersistentQueue) Requeue(f Fact, t time.Time, tokenId string, attempts int) error {
+	return pq.put(f, t, tokenId, attempts, false)
+}
+
+func (pq *PersistentQueue) put(f Fact, t time.Time, tokenId string, attempts int, checkSize bool) error {
 	factBytes, err := json.Marshal(f)
 	if err != nil {
-		logSkippedEvent(f, fmt.Errorf("Error marshalling events fact: %v", err))
-		return
+		return fmt.Errorf("Error marshalling events fact: %v", err)
 	}
 
-	if err := pq.queue.Enqueue(&QueuedFact{FactBytes: factBytes, DequeuedTime: t, TokenId: tokenId}); err != nil {
-		logSkippedEvent(f, fmt.Errorf("Error putting event fact bytes to the persistent queue: %v", err))
-		return
+	if err := pq.enqueue(&QueuedFact{FactBytes: factBytes, DequeuedTime: t, TokenId: tokenId, Attempts: attempts}, checkSize); err != nil {
+		return fmt.Errorf("Error putting event fact bytes to the persistent queue: %v", err)
 	}
+
+	return nil
 }
 
-func (pq *PersistentQueue) DequeueBlock() (Fact, time.Time, string, error) {
-	iface, err := pq.queue.DequeueBlock()
+//PeekBlock return the first not committed event with its dequeue time, token id and failed write attempts count.
+//Blocks until an event is available or the queue is closed
+//malformed events are committed (skipped) and returned with error
+func (pq *PersistentQueue) PeekBlock() (Fact, time.Time, string, int, error) {
+	payload, err := pq.queue.PeekBlock()
 	if err != nil {
-		if err == dque.ErrQueueClosed {
-			err = ErrQueueClosed
-		}
-		return nil, time.Time{}, "", err
+		return nil, time.Time{}, "", 0, err
+	}
+	payload, err = encryption.Decrypt(payload)
+	if err != nil {
+		pq.queue.Commit()
+		return nil, time.Time{}, "", 0, fmt.Errorf("Error decrypting queued event: %v", err)
 	}
 
-	wrappedFact, ok := iface.(*QueuedFact)
-	if !ok || len(wrappedFact.FactBytes) == 0 {
-		return nil, time.Time{}, "", errors.New("Dequeued object is not a QueuedFact instance or fact bytes is empty")
+	wrappedFact := &QueuedFact{}
+	if err := json.Unmarshal(payload, wrappedFact); err != nil || len(wrappedFact.FactBytes) == 0 {
+		pq.queue.Commit()
+		return nil, time.Time{}, "", 0, fmt.Errorf("Dequeued object is not a QueuedFact instance or fact bytes is empty: %v", err)
 	}
 
 	fact, err := parsers.ParseJson(wrappedFact.FactBytes)
 	if err != nil {
-		return nil, time.Time{}, "", fmt.Errorf("Error unmarshalling events.Fact from bytes: %v", err)
+		pq.queue.Commit()
+		return nil, time.Time{}, "", 0, fmt.Errorf("Error unmarshalling events.Fact from bytes: %v", err)
+	}
+
+	return fact, wrappedFact.DequeuedTime, wrappedFact.TokenId, wrappedFact.Attempts, nil
+}
+
+//Commit mark the event from PeekBlock as processed
+func (pq *PersistentQueue) Commit() error {
+	return pq.queue.Commit()
+}
+
+//IsFull return true if max queue size is configured and exceeded
+//it is used for backpressure: new events are rejected with retryable error
+func (pq *PersistentQueue) IsFull() bool {
+	return pq.queue.IsFull()
+}
+
+//SizeBytes return size of not committed events on disk
+func (pq *PersistentQueue) SizeBytes() int64 {
+	return pq.queue.SizeBytes()
+}
+
+//Recovered return corrupted parts count and summary of the queue recovery (corrupted entries and quarantined segments)
+func (pq *PersistentQueue) Recovered() (int, int, string) {
+	stats := pq.queue.Recovered()
+	return stats.corruptedParts, stats.quarantinedSegments, stats.String()
+}
+
+//Spool return the circuit breaker spool of the queue: events are moved there while the destination circuit is open
+//and are processed in order after it has been recovered. It is opened in fallbackDir/queueName-spool.queue on the first call
+func (pq *PersistentQueue) Spool() (*PersistentQueue, error) {
+	pq.spoolMutex.Lock()
+	defer pq.spoolMutex.Unlock()
+
+	if pq.spool == nil {
+		spool, err := NewPersistentQueue(pq.queueName+spoolPostfix, pq.fallbackDir, 0)
+		if err != nil {
+			return nil, err
+		}
+		pq.spool = spool
 	}
+	return pq.spool, nil
+}
+
+//HasSpool return true if the spool has been opened or its directory exists (e.g. spooled events from the previous run)
+func (pq *PersistentQueue) HasSpool() bool {
+	pq.spoolMutex.Lock()
+	defer pq.spoolMutex.Unlock()
+
+	if pq.spool != nil {
+		return true
+	}
+	_, err := os.Stat(path.Join(pq.fallbackDir, pq.queueName+spoolPostfix+".queue"))
+	return err == nil
+}
 
-	return fact, wrappedFact.DequeuedTime, wrappedFact.TokenId, nil
+//IsEmpty return true if there are no not committed events
+func (pq *PersistentQueue) IsEmpty() bool {
+	return pq.queue.SizeBytes() == 0
 }
 
 func (pq *PersistentQueue) Close() error {
+	pq.spoolMutex.Lock()
+	spool := pq.spool
+	pq.spoolMutex.Unlock()
+
+	if spool != nil {
+		if err := spool.Close(); err != nil {
+			eventsLogger.Errorf("[%s] Error closing spool: %v", pq.queueName, err)
+		}
+	}
 	return pq.queue.Close()
 }
 
+func (pq *PersistentQueue) enqueue(queuedFact *QueuedFact, checkSize bool) error {
+	b, err := json.Marshal(queuedFact)
+	if err != nil {
+		return err
+	}
+	b, err = encryption.Encrypt(b)
+	if err != nil {
+		return err
+	}
+	return pq.queue.Enqueue(b, checkSize)
+}
+
+//migrateLegacy move all events from dque queue directory (fallbackDir/queueName) if exists and remove it
+func (pq *PersistentQueue) migrateLegacy(queueName, fallbackDir string) error {
+	legacyDir := path.Join(fallbackDir, queueName)
+	if _, err := os.Stat(legacyDir); err != nil {
+		return nil
+	}
+
+	legacy, err := dque.Open(queueName, fallbackDir, eventsPerPersistedFile, QueuedFactBuilder)
+	if err != nil {
+		return err
+	}
+
+	moved := 0
+	for {
+		iface, err := legacy.Dequeue()
+		if err == dque.ErrEmpty {
+			break
+		}
+		if err != nil {
+			legacy.Close()
+			return err
+		}
+
+		if queuedFact, ok := iface.(*QueuedFact); ok && len(queuedFact.FactBytes) > 0 {
+			if err := pq.enqueue(queuedFact, false); err != nil {
+				legacy.Close()
+				return err
+			}
+			moved++
+		}
+	}
+
+	if err := legacy.Close(); err != nil {
+		return err
+	}
+	eventsLogger.Infof("[%s] %d events have been moved from legacy queue", queueName, moved)
+
+	return os.RemoveAll(legacyDir)
+}
+
 func logSkippedEvent(fact Fact, err error) {
-	logging.Warnf("Unable to enqueue object %v reason: %v. This object will be skipped", fact, err)
+	eventsLogger.WithEventId(ExtractEventId(fact)).Warnf("Unable to enqueue object %v reason: %v. This object will be skipped", fact, err)
 }
@@ -0,0 +1,66 @@
+package events
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"github.com/jitsucom/eventnative/encryption"
+	"io/ioutil"
+	"path"
+)
+
+//ReadQueue iterate over not committed events of the persistent queue dir (<fallbackDir>/<queueName>.queue) without
+//changing or locking it. It is used for offline inspection (en-cli inspect)
+//decoding errors are passed to fn. Corrupted parts of segments are skipped like PersistentQueue does
+func ReadQueue(dir string, fn func(queuedFact *QueuedFact, err error) error) error {
+	segments, err := listSegments(dir)
+	if err != nil {
+		return err
+	}
+
+	var committedSegment, committedOffset int64
+	if b, err := ioutil.ReadFile(path.Join(dir, offsetFileName)); err == nil && len(b) == offsetFileSize {
+		committedSegment = int64(binary.BigEndian.Uint64(b[0:8]))
+		committedOffset = int64(binary.BigEndian.Uint64(b[8:16]))
+	}
+
+	for _, id := range segments {
+		if id < committedSegment {
+			continue
+		}
+
+		var offset int64
+		if id == committedSegment {
+			offset = committedOffset
+		}
+		if err := readSegment(segmentPath(dir, id), offset, fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func readSegment(filePath string, offset int64, fn func(queuedFact *QueuedFact, err error) error) error {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("Error reading queue segment [%s]: %v", filePath, err)
+	}
+	if offset > int64(len(data)) {
+		return nil
+	}
+
+	return forEachEntry(data[offset:], func(entry []byte) error {
+		queuedFact := &QueuedFact{}
+		payload, err := encryption.Decrypt(entry[entryHeaderSize:])
+		if err != nil {
+			return fn(nil, fmt.Errorf("Error decrypting queued fact: %v", err))
+		}
+		if err := json.Unmarshal(payload, queuedFact); err != nil {
+			return fn(nil, fmt.Errorf("Error unmarshalling queued fact: %v", err))
+		}
+		return fn(queuedFact, nil)
+	}, func(start, end int) error {
+		return fn(nil, fmt.Errorf("Corrupted part of queue segment [%s]: %d bytes after offset [%d] are skipped", filePath, end-start, offset+int64(start)))
+	})
+}
@@ -0,0 +1,54 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestReadQueue(t *testing.T) {
+	dir, err := ioutil.TempDir("", "segment_queue")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	//small segments for rotation
+	sq, err := openSegmentQueue(dir, 100, 0)
+	require.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		b, err := json.Marshal(&QueuedFact{FactBytes: []byte(fmt.Sprintf(`{"id":%d}`, i)), TokenId: "token1"})
+		require.NoError(t, err)
+		require.NoError(t, sq.Enqueue(b, true))
+	}
+	require.NoError(t, sq.Enqueue([]byte("malformed"), true))
+
+	//committed entries aren't read
+	_, err = sq.PeekBlock()
+	require.NoError(t, err)
+	require.NoError(t, sq.Commit())
+	require.NoError(t, sq.Close())
+
+	var facts []string
+	var errs []error
+	require.NoError(t, ReadQueue(dir, func(queuedFact *QueuedFact, err error) error {
+		if err != nil {
+			errs = append(errs, err)
+			return nil
+		}
+		require.Equal(t, "token1", queuedFact.TokenId)
+		facts = append(facts, string(queuedFact.FactBytes))
+		return nil
+	}))
+	require.Equal(t, []string{`{"id":1}`, `{"id":2}`, `{"id":3}`, `{"id":4}`}, facts)
+	require.Equal(t, 1, len(errs))
+
+	//queue isn't changed
+	sq, err = openSegmentQueue(dir, 100, 0)
+	require.NoError(t, err)
+	defer sq.Close()
+	payload, err := sq.PeekBlock()
+	require.NoError(t, err)
+	require.Contains(t, string(payload), "eyJpZCI6MX0=")
+}
@@ -0,0 +1,258 @@
+package events
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/timestamp"
+	"time"
+)
+
+const (
+	SegmentTrack    = "track"
+	SegmentIdentify = "identify"
+	SegmentPage     = "page"
+	SegmentScreen   = "screen"
+	SegmentGroup    = "group"
+	SegmentAlias    = "alias"
+
+	segmentSrc = "segment"
+)
+
+//analytics.js short paths (/v1/t, /v1/p, etc.)
+var segmentShortTypes = map[string]string{
+	"t": SegmentTrack,
+	"i": SegmentIdentify,
+	"p": SegmentPage,
+	"s": SegmentScreen,
+	"g": SegmentGroup,
+	"a": SegmentAlias,
+}
+
+//Segment context.campaign keys -> eventn_ctx.utm keys
+var segmentCampaignKeys = map[string]string{
+	"name":    "campaign",
+	"source":  "source",
+	"medium":  "medium",
+	"term":    "term",
+	"content": "content",
+}
+
+//Segment context keys which are mapped into eventn_ctx fields. The rest are kept in eventn_ctx.segment_context
+var segmentMappedContextKeys = map[string]bool{
+	"ip":        true,
+	"userAgent": true,
+	"page":      true,
+	"campaign":  true,
+	"locale":    true,
+	"screen":    true,
+	"traits":    true,
+}
+
+//SegmentType return Segment call type by path type (full or analytics.js short one) or "" if unknown
+func SegmentType(pathType string) string {
+	switch pathType {
+	case SegmentTrack, SegmentIdentify, SegmentPage, SegmentScreen, SegmentGroup, SegmentAlias:
+		return pathType
+	default:
+		return segmentShortTypes[pathType]
+	}
+}
+
+//ConvertSegment return fact in JS SDK format from Segment HTTP Tracking API message:
+//messageId -> eventn_ctx.event_id, userId/anonymousId/traits -> eventn_ctx.user, context.page -> eventn_ctx page fields,
+//context.campaign -> eventn_ctx.utm, timestamp (corrected with sentAt clock skew) -> eventn_ctx.utc_time,
+//properties (or traits for group) -> event_data. The rest of context is kept in eventn_ctx.segment_context
+//callType is used if the message doesn't have type. ip is used if the message doesn't have context.ip
+func ConvertSegment(message map[string]interface{}, callType, ip string, now time.Time) (Fact, error) {
+	if message == nil {
+		return nil, nilFactErr
+	}
+
+	if messageType, ok := message["type"].(string); ok && messageType != "" {
+		callType = messageType
+	}
+	callType = SegmentType(callType)
+	if callType == "" {
+		return nil, fmt.Errorf("Unknown Segment message type: %v", message["type"])
+	}
+
+	segmentContext := getObject(message, "context")
+	eventnCtx := map[string]interface{}{}
+
+	if messageId, ok := message["messageId"]; ok && messageId != nil {
+		eventnCtx[eventIdKey] = fmt.Sprint(messageId)
+	}
+
+	//user
+	user := map[string]interface{}{}
+	for k, v := range getObject(segmentContext, "traits") {
+		user[k] = v
+	}
+	if callType == SegmentIdentify {
+		for k, v := range getObject(message, "traits") {
+			user[k] = v
+		}
+	}
+	if userId, ok := message["userId"]; ok && userId != nil {
+		user["id"] = userId
+	}
+	if anonymousId, ok := message["anonymousId"]; ok && anonymousId != nil {
+		user["anonymous_id"] = anonymousId
+	}
+	if len(user) > 0 {
+		eventnCtx["user"] = user
+	}
+
+	//client
+	if contextIp, ok := segmentContext["ip"].(string); ok && contextIp != "" {
+		ip = contextIp
+	}
+	if ip != "" {
+		eventnCtx["ip"] = ip
+	}
+	if userAgent, ok := segmentContext["userAgent"].(string); ok {
+		eventnCtx["user_agent"] = userAgent
+	}
+	if locale, ok := segmentContext["locale"].(string); ok {
+		eventnCtx["user_language"] = locale
+	}
+	screen := getObject(segmentContext, "screen")
+	if width, ok := screen["width"]; ok {
+		eventnCtx["screen_resolution"] = fmt.Sprintf("%vx%v", width, screen["height"])
+	}
+
+	//page: page call properties have priority over context.page
+	page := getObject(segmentContext, "page")
+	if callType == SegmentPage {
+		merged := map[string]interface{}{}
+		for k, v := range page {
+			merged[k] = v
+		}
+		for _, k := range []string{"url", "referrer", "title", "path", "search"} {
+			if v, ok := getObject(message, "properties")[k]; ok {
+				merged[k] = v
+			}
+		}
+		page = merged
+	}
+	setIfExists(eventnCtx, "url", page, "url")
+	setIfExists(eventnCtx, "referer", page, "referrer")
+	setIfExists(eventnCtx, "page_title", page, "title")
+	setIfExists(eventnCtx, "doc_path", page, "path")
+	setIfExists(eventnCtx, "doc_search", page, "search")
+
+	utm := map[string]interface{}{}
+	campaign := getObject(segmentContext, "campaign")
+	for segmentKey, utmKey := range segmentCampaignKeys {
+		setIfExists(utm, utmKey, campaign, segmentKey)
+	}
+	if len(utm) > 0 {
+		eventnCtx["utm"] = utm
+	}
+
+	if eventTime, ok := segmentTimestamp(message, now); ok {
+		eventnCtx["utc_time"] = timestamp.ToISOFormat(eventTime)
+	}
+
+	rest := map[string]interface{}{}
+	for k, v := range segmentContext {
+		if !segmentMappedContextKeys[k] {
+			rest[k] = v
+		}
+	}
+	if len(rest) > 0 {
+		eventnCtx["segment_context"] = rest
+	}
+
+	fact := Fact{
+		"src":     segmentSrc,
+		eventnKey: eventnCtx,
+	}
+
+	eventData := map[string]interface{}{}
+	switch callType {
+	case SegmentTrack:
+		eventName, _ := message["event"].(string)
+		if eventName == "" {
+			return nil, errors.New("Segment track message must have event")
+		}
+		fact["event_type"] = eventName
+		eventData = copyObject(getObject(message, "properties"))
+	case SegmentPage:
+		fact["event_type"] = "pageview"
+		eventData = copyObject(getObject(message, "properties"))
+		setIfExists(eventData, "name", message, "name")
+		setIfExists(eventData, "category", message, "category")
+	case SegmentScreen:
+		fact["event_type"] = SegmentScreen
+		eventData = copyObject(getObject(message, "properties"))
+		setIfExists(eventData, "name", message, "name")
+	case SegmentIdentify:
+		fact["event_type"] = SegmentIdentify
+	case SegmentGroup:
+		fact["event_type"] = SegmentGroup
+		eventData = copyObject(getObject(message, "traits"))
+		setIfExists(eventData, "group_id", message, "groupId")
+	case SegmentAlias:
+		fact["event_type"] = SegmentAlias
+		setIfExists(eventData, "previous_id", message, "previousId")
+	}
+	if len(eventData) > 0 {
+		fact["event_data"] = eventData
+	}
+
+	return fact, nil
+}
+
+//segmentTimestamp return message timestamp corrected with client clock skew (now - sentAt) like Segment does
+//Fallback to originalTimestamp. Return false if timestamp isn't set or malformed
+func segmentTimestamp(message map[string]interface{}, now time.Time) (time.Time, bool) {
+	eventTime, ok := parseSegmentTime(message["timestamp"])
+	if !ok {
+		eventTime, ok = parseSegmentTime(message["originalTimestamp"])
+		if !ok {
+			return time.Time{}, false
+		}
+	}
+
+	if sentAt, ok := parseSegmentTime(message["sentAt"]); ok {
+		eventTime = eventTime.Add(now.Sub(sentAt))
+	}
+
+	return eventTime.UTC(), true
+}
+
+func parseSegmentTime(value interface{}) (time.Time, bool) {
+	str, ok := value.(string)
+	if !ok || str == "" {
+		return time.Time{}, false
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, str)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+func getObject(object map[string]interface{}, key string) map[string]interface{} {
+	if object == nil {
+		return nil
+	}
+	result, _ := object[key].(map[string]interface{})
+	return result
+}
+
+func copyObject(object map[string]interface{}) map[string]interface{} {
+	result := map[string]interface{}{}
+	for k, v := range object {
+		result[k] = v
+	}
+	return result
+}
+
+func setIfExists(to map[string]interface{}, toKey string, from map[string]interface{}, fromKey string) {
+	if v, ok := from[fromKey]; ok && v != nil {
+		to[toKey] = v
+	}
+}
@@ -0,0 +1,475 @@
+package events
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	segmentFileExt = ".segment"
+	offsetFileName = "offset"
+
+	//4 bytes payload length + 4 bytes payload crc32
+	entryHeaderSize = 8
+	//segment ids and committed offset
+	offsetFileSize = 16
+
+	defaultMaxSegmentBytes = 16 * 1024 * 1024
+	//entries with greater length are considered as corrupted
+	maxEntryBytes = 64 * 1024 * 1024
+)
+
+var (
+	ErrQueueFull = errors.New("queue is full")
+
+	errCorruptedEntry = errors.New("corrupted entry")
+)
+
+//segmentQueue is a disk-backed FIFO queue with a single consumer and commit-after-write semantics:
+//entries are appended to segment files: <dir>/<segment id>.segment (entry = length + crc32 + payload)
+//committed read position (segment id + offset) is kept in <dir>/offset file
+//PeekBlock returns the same entry until Commit is called, so entries aren't lost if the process crashes while processing
+//fully committed segment files are removed
+//corrupted entries (e.g. after an unclean shutdown) are skipped: damaged segments are copied into <dir>/quarantine
+//and rewritten with valid entries only (see recoverSegment)
+//all writes go to the OS page cache without fsync: entries survive process crashes but not OS crashes
+type segmentQueue struct {
+	mutex sync.Mutex
+	cond  *sync.Cond
+
+	dir             string
+	maxSegmentBytes int64
+	//0 means unlimited
+	maxBytes int64
+
+	//sorted segment ids. The first one is being read, the last one is being written
+	segments     []int64
+	writer       *os.File
+	writerOffset int64
+	//size of not committed entries on disk
+	sizeBytes int64
+
+	offsetFile *os.File
+	reader     *bufio.Reader
+	readerFile *os.File
+	//committed offset in the first segment
+	readOffset int64
+
+	//entry which was returned from PeekBlock and wasn't committed
+	peeked     []byte
+	peekedSize int64
+
+	recovery recoveryStats
+
+	closed bool
+}
+
+func openSegmentQueue(dir string, maxSegmentBytes, maxBytes int64) (*segmentQueue, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("Error creating queue dir [%s]: %v", dir, err)
+	}
+
+	sq := &segmentQueue{dir: dir, maxSegmentBytes: maxSegmentBytes, maxBytes: maxBytes}
+	sq.cond = sync.NewCond(&sq.mutex)
+
+	if err := sq.load(); err != nil {
+		sq.closeFiles()
+		return nil, err
+	}
+
+	return sq, nil
+}
+
+//load read segment ids and committed offset from disk, remove committed segments, recover damaged segments and open files
+func (sq *segmentQueue) load() error {
+	segments, err := listSegments(sq.dir)
+	if err != nil {
+		return err
+	}
+	sq.segments = segments
+
+	sq.offsetFile, err = os.OpenFile(path.Join(sq.dir, offsetFileName), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("Error opening queue offset file: %v", err)
+	}
+
+	committedSegment, committedOffset := sq.readCommitted()
+	//remove segments which have been fully committed
+	for len(sq.segments) > 0 && sq.segments[0] < committedSegment {
+		if err := os.Remove(sq.segmentPath(sq.segments[0])); err != nil {
+			return fmt.Errorf("Error removing committed queue segment: %v", err)
+		}
+		sq.segments = sq.segments[1:]
+	}
+	if len(sq.segments) > 0 && sq.segments[0] == committedSegment {
+		sq.readOffset = committedOffset
+	}
+
+	//skip corrupted entries (including torn write at the end of the last segment), segments which can't be read are quarantined
+	for i := 0; i < len(sq.segments); {
+		var offset int64
+		if i == 0 {
+			offset = sq.readOffset
+		}
+		if _, err := sq.recoverSegment(sq.segments[i], offset); err != nil {
+			eventsLogger.Errorf("[%s] Queue segment [%d] can't be recovered: %v", sq.name(), sq.segments[i], err)
+			if err := sq.quarantineSegment(sq.segments[i]); err != nil {
+				return err
+			}
+			if i == 0 {
+				sq.readOffset = 0
+			}
+			sq.segments = append(sq.segments[:i], sq.segments[i+1:]...)
+			continue
+		}
+		i++
+	}
+	if sq.recovery.quarantinedSegments > 0 {
+		eventsLogger.SystemErrorf("[%s] Queue has been recovered after unclean shutdown: %s", sq.name(), sq.recovery.String())
+	}
+
+	if len(sq.segments) == 0 {
+		sq.segments = append(sq.segments, committedSegment+1)
+	}
+
+	lastSegment := sq.segments[len(sq.segments)-1]
+	sq.writer, err = os.OpenFile(sq.segmentPath(lastSegment), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("Error opening queue segment for writing: %v", err)
+	}
+	size, err := sq.writer.Seek(0, io.SeekEnd)
+	if err != nil {
+		return fmt.Errorf("Error seeking queue segment: %v", err)
+	}
+	sq.writerOffset = size
+	sq.sizeBytes = sq.currentSize()
+
+	return sq.openReader()
+}
+
+//Enqueue append payload to the last segment
+//return ErrQueueFull if checkSize is true and max queue size is exceeded
+func (sq *segmentQueue) Enqueue(payload []byte, checkSize bool) error {
+	entry := make([]byte, entryHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(entry[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(entry[4:8], crc32.ChecksumIEEE(payload))
+	copy(entry[entryHeaderSize:], payload)
+
+	sq.mutex.Lock()
+	defer sq.mutex.Unlock()
+
+	if sq.closed {
+		return ErrQueueClosed
+	}
+
+	if checkSize && sq.maxBytes > 0 && sq.sizeBytes+int64(len(entry)) > sq.maxBytes {
+		return ErrQueueFull
+	}
+
+	if sq.writerOffset >= sq.maxSegmentBytes {
+		if err := sq.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := sq.writer.Write(entry)
+	sq.writerOffset += int64(n)
+	sq.sizeBytes += int64(n)
+	if err != nil {
+		return fmt.Errorf("Error writing to queue segment: %v", err)
+	}
+
+	sq.cond.Broadcast()
+	return nil
+}
+
+//PeekBlock return the first not committed entry. Blocks until an entry is available or the queue is closed
+//return the same entry until Commit is called
+func (sq *segmentQueue) PeekBlock() ([]byte, error) {
+	sq.mutex.Lock()
+	defer sq.mutex.Unlock()
+
+	for {
+		if sq.closed {
+			return nil, ErrQueueClosed
+		}
+
+		if sq.peeked != nil {
+			return sq.peeked, nil
+		}
+
+		payload, err := sq.readEntry()
+		if err == nil {
+			sq.peeked = payload
+			sq.peekedSize = int64(entryHeaderSize + len(payload))
+			return payload, nil
+		}
+
+		if err == errCorruptedEntry {
+			//don't append new entries after the corrupted one
+			if len(sq.segments) == 1 {
+				if err := sq.rotate(); err != nil {
+					return nil, err
+				}
+			}
+
+			rewritten, recoverErr := sq.recoverSegment(sq.segments[0], sq.readOffset)
+			if recoverErr == nil && rewritten {
+				recoverErr = sq.reopenReader()
+				if recoverErr == nil {
+					continue
+				}
+			}
+			eventsLogger.SystemErrorf("[%s] Corrupted entry in queue segment [%d] after offset [%d] can't be recovered: %v. The rest of the segment will be skipped", sq.name(), sq.segments[0], sq.readOffset, recoverErr)
+		} else if err != io.EOF {
+			return nil, err
+		}
+
+		//the first segment has been read
+		if len(sq.segments) > 1 {
+			if err := sq.nextSegment(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		sq.cond.Wait()
+	}
+}
+
+//Commit mark the entry from PeekBlock as processed
+func (sq *segmentQueue) Commit() error {
+	sq.mutex.Lock()
+	defer sq.mutex.Unlock()
+
+	if sq.closed {
+		return ErrQueueClosed
+	}
+
+	if sq.peeked == nil {
+		return nil
+	}
+
+	sq.readOffset += sq.peekedSize
+	sq.sizeBytes -= sq.peekedSize
+	sq.peeked = nil
+	sq.peekedSize = 0
+
+	return sq.writeCommitted(sq.segments[0], sq.readOffset)
+}
+
+//SizeBytes return size of not committed entries
+func (sq *segmentQueue) SizeBytes() int64 {
+	sq.mutex.Lock()
+	defer sq.mutex.Unlock()
+	return sq.sizeBytes
+}
+
+//Recovered return a copy of the segments recovery counts
+func (sq *segmentQueue) Recovered() recoveryStats {
+	sq.mutex.Lock()
+	defer sq.mutex.Unlock()
+	return sq.recovery
+}
+
+//IsFull return true if max queue size is configured and exceeded
+func (sq *segmentQueue) IsFull() bool {
+	sq.mutex.Lock()
+	defer sq.mutex.Unlock()
+	return sq.maxBytes > 0 && sq.sizeBytes >= sq.maxBytes
+}
+
+func (sq *segmentQueue) Close() error {
+	sq.mutex.Lock()
+	defer sq.mutex.Unlock()
+
+	if sq.closed {
+		return nil
+	}
+
+	sq.closed = true
+	sq.cond.Broadcast()
+	return sq.closeFiles()
+}
+
+//readEntry read the next entry from the current segment
+func (sq *segmentQueue) readEntry() ([]byte, error) {
+	//entries might be partially read only in the corrupted case: all writes are under the mutex
+	return decodeEntry(sq.reader)
+}
+
+//decodeEntry read the next entry from the reader
+//return io.EOF if there is no whole entry yet and errCorruptedEntry if crc doesn't match
+func decodeEntry(reader *bufio.Reader) ([]byte, error) {
+	header, err := reader.Peek(entryHeaderSize)
+	if err != nil {
+		if err == io.EOF && len(header) > 0 {
+			return nil, errCorruptedEntry
+		}
+		return nil, err
+	}
+
+	length := int(binary.BigEndian.Uint32(header[0:4]))
+	checksum := binary.BigEndian.Uint32(header[4:8])
+
+	if length == 0 || length > maxEntryBytes {
+		return nil, errCorruptedEntry
+	}
+
+	if _, err := reader.Discard(entryHeaderSize); err != nil {
+		return nil, err
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return nil, errCorruptedEntry
+	}
+
+	if crc32.ChecksumIEEE(payload) != checksum {
+		return nil, errCorruptedEntry
+	}
+
+	return payload, nil
+}
+
+//nextSegment remove the first (fully read) segment and start reading the next one
+func (sq *segmentQueue) nextSegment() error {
+	finished := sq.segments[0]
+	sq.segments = sq.segments[1:]
+	sq.readOffset = 0
+
+	if err := sq.writeCommitted(sq.segments[0], 0); err != nil {
+		return err
+	}
+
+	sq.readerFile.Close()
+	if err := os.Remove(sq.segmentPath(finished)); err != nil {
+		eventsLogger.Errorf("Error removing finished queue segment [%s]: %v", sq.segmentPath(finished), err)
+	}
+	//recalculate because corrupted part of the segment might be skipped
+	sq.sizeBytes = sq.currentSize()
+
+	return sq.openReader()
+}
+
+//reopenReader open the first segment again (e.g. after it has been rewritten) from the committed offset
+func (sq *segmentQueue) reopenReader() error {
+	sq.readerFile.Close()
+	sq.sizeBytes = sq.currentSize()
+	return sq.openReader()
+}
+
+//rotate start writing to a new segment
+func (sq *segmentQueue) rotate() error {
+	next := sq.segments[len(sq.segments)-1] + 1
+	writer, err := os.OpenFile(sq.segmentPath(next), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("Error creating queue segment: %v", err)
+	}
+
+	sq.writer.Close()
+	sq.writer = writer
+	sq.writerOffset = 0
+	sq.segments = append(sq.segments, next)
+	return nil
+}
+
+func (sq *segmentQueue) openReader() error {
+	file, err := os.Open(sq.segmentPath(sq.segments[0]))
+	if err != nil {
+		return fmt.Errorf("Error opening queue segment for reading: %v", err)
+	}
+	if _, err := file.Seek(sq.readOffset, io.SeekStart); err != nil {
+		file.Close()
+		return fmt.Errorf("Error seeking queue segment: %v", err)
+	}
+
+	sq.readerFile = file
+	sq.reader = bufio.NewReader(file)
+	return nil
+}
+
+//currentSize return size of all segments without committed part of the first one
+func (sq *segmentQueue) currentSize() int64 {
+	var size int64
+	for _, id := range sq.segments {
+		if info, err := os.Stat(sq.segmentPath(id)); err == nil {
+			size += info.Size()
+		}
+	}
+	return size - sq.readOffset
+}
+
+func (sq *segmentQueue) readCommitted() (int64, int64) {
+	buf := make([]byte, offsetFileSize)
+	if n, err := sq.offsetFile.ReadAt(buf, 0); err != nil || n != offsetFileSize {
+		return 0, 0
+	}
+
+	return int64(binary.BigEndian.Uint64(buf[0:8])), int64(binary.BigEndian.Uint64(buf[8:16]))
+}
+
+func (sq *segmentQueue) writeCommitted(segment, offset int64) error {
+	buf := make([]byte, offsetFileSize)
+	binary.BigEndian.PutUint64(buf[0:8], uint64(segment))
+	binary.BigEndian.PutUint64(buf[8:16], uint64(offset))
+	if _, err := sq.offsetFile.WriteAt(buf, 0); err != nil {
+		return fmt.Errorf("Error writing queue offset: %v", err)
+	}
+	return nil
+}
+
+//listSegments return sorted segment ids from the queue dir
+func listSegments(dir string) ([]int64, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading queue dir [%s]: %v", dir, err)
+	}
+
+	var segments []int64
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), segmentFileExt) {
+			continue
+		}
+		id, err := strconv.ParseInt(strings.TrimSuffix(file.Name(), segmentFileExt), 10, 64)
+		if err != nil {
+			eventsLogger.Warnf("Skipping unknown file [%s] in queue dir [%s]", file.Name(), dir)
+			continue
+		}
+		segments = append(segments, id)
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i] < segments[j] })
+
+	return segments, nil
+}
+
+func (sq *segmentQueue) segmentPath(id int64) string {
+	return segmentPath(sq.dir, id)
+}
+
+func segmentPath(dir string, id int64) string {
+	return path.Join(dir, fmt.Sprintf("%013d%s", id, segmentFileExt))
+}
+
+func (sq *segmentQueue) closeFiles() error {
+	var lastErr error
+	for _, file := range []*os.File{sq.writer, sq.readerFile, sq.offsetFile} {
+		if file == nil {
+			continue
+		}
+		if err := file.Close(); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
@@ -0,0 +1,194 @@
+package events
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestSegmentQueueCommitAfterWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "segment_queue")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	//small segments for rotation
+	sq, err := openSegmentQueue(dir, 30, 0)
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, sq.Enqueue([]byte(fmt.Sprintf("event%d", i)), true))
+	}
+	require.Equal(t, int64(5*(entryHeaderSize+6)), sq.SizeBytes())
+
+	payload, err := sq.PeekBlock()
+	require.NoError(t, err)
+	require.Equal(t, "event0", string(payload))
+
+	//the same entry until commit
+	payload, err = sq.PeekBlock()
+	require.NoError(t, err)
+	require.Equal(t, "event0", string(payload))
+	require.NoError(t, sq.Commit())
+
+	payload, err = sq.PeekBlock()
+	require.NoError(t, err)
+	require.Equal(t, "event1", string(payload))
+
+	//crash (or close) without commit: event1 will be read again
+	require.NoError(t, sq.Close())
+
+	sq, err = openSegmentQueue(dir, 30, 0)
+	require.NoError(t, err)
+	defer sq.Close()
+
+	for i := 1; i < 5; i++ {
+		payload, err := sq.PeekBlock()
+		require.NoError(t, err)
+		require.Equal(t, fmt.Sprintf("event%d", i), string(payload))
+		require.NoError(t, sq.Commit())
+	}
+	require.Equal(t, int64(0), sq.SizeBytes())
+
+	//committed segments are removed
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	segments := 0
+	for _, f := range files {
+		if path.Ext(f.Name()) == segmentFileExt {
+			segments++
+		}
+	}
+	require.Equal(t, 1, segments)
+}
+
+func TestSegmentQueueMaxSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "segment_queue")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	sq, err := openSegmentQueue(dir, defaultMaxSegmentBytes, 2*(entryHeaderSize+6))
+	require.NoError(t, err)
+	defer sq.Close()
+
+	require.NoError(t, sq.Enqueue([]byte("event0"), true))
+	require.False(t, sq.IsFull())
+	require.NoError(t, sq.Enqueue([]byte("event1"), true))
+	require.True(t, sq.IsFull())
+	require.Equal(t, ErrQueueFull, sq.Enqueue([]byte("event2"), true))
+
+	//requeue ignores max size
+	require.NoError(t, sq.Enqueue([]byte("event3"), false))
+
+	_, err = sq.PeekBlock()
+	require.NoError(t, err)
+	require.NoError(t, sq.Commit())
+	_, err = sq.PeekBlock()
+	require.NoError(t, err)
+	require.NoError(t, sq.Commit())
+	require.False(t, sq.IsFull())
+}
+
+func TestSegmentQueueTornWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "segment_queue")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	sq, err := openSegmentQueue(dir, defaultMaxSegmentBytes, 0)
+	require.NoError(t, err)
+	require.NoError(t, sq.Enqueue([]byte("event0"), true))
+	segmentPath := sq.segmentPath(sq.segments[0])
+	require.NoError(t, sq.Close())
+
+	//partially written entry
+	f, err := os.OpenFile(segmentPath, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = f.Write([]byte{0, 0, 0, 10, 1, 2})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	sq, err = openSegmentQueue(dir, defaultMaxSegmentBytes, 0)
+	require.NoError(t, err)
+	defer sq.Close()
+	require.NoError(t, sq.Enqueue([]byte("event1"), true))
+
+	for i := 0; i < 2; i++ {
+		payload, err := sq.PeekBlock()
+		require.NoError(t, err)
+		require.Equal(t, fmt.Sprintf("event%d", i), string(payload))
+		require.NoError(t, sq.Commit())
+	}
+}
+
+func TestSegmentQueueCorruptedEntries(t *testing.T) {
+	dir, err := ioutil.TempDir("", "segment_queue")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	sq, err := openSegmentQueue(dir, defaultMaxSegmentBytes, 0)
+	require.NoError(t, err)
+	for i := 0; i < 4; i++ {
+		require.NoError(t, sq.Enqueue([]byte(fmt.Sprintf("event%d", i)), true))
+	}
+	segmentPath := sq.segmentPath(sq.segments[0])
+	require.NoError(t, sq.Close())
+
+	//damaged payload of event1 and length of event2
+	data, err := ioutil.ReadFile(segmentPath)
+	require.NoError(t, err)
+	entrySize := entryHeaderSize + 6
+	data[entrySize+entryHeaderSize] = 'x'
+	data[2*entrySize] = 0xff
+	require.NoError(t, ioutil.WriteFile(segmentPath, data, 0644))
+
+	sq, err = openSegmentQueue(dir, defaultMaxSegmentBytes, 0)
+	require.NoError(t, err)
+	defer sq.Close()
+	require.Equal(t, recoveryStats{corruptedParts: 1, corruptedBytes: int64(2 * entrySize), quarantinedSegments: 1}, sq.recovery)
+	require.Equal(t, int64(2*entrySize), sq.SizeBytes())
+
+	for _, expected := range []string{"event0", "event3"} {
+		payload, err := sq.PeekBlock()
+		require.NoError(t, err)
+		require.Equal(t, expected, string(payload))
+		require.NoError(t, sq.Commit())
+	}
+
+	//the original segment is kept in quarantine
+	quarantined, err := ioutil.ReadDir(path.Join(dir, quarantineDirName))
+	require.NoError(t, err)
+	require.Len(t, quarantined, 1)
+	quarantinedData, err := ioutil.ReadFile(path.Join(dir, quarantineDirName, quarantined[0].Name()))
+	require.NoError(t, err)
+	require.Equal(t, data, quarantinedData)
+}
+
+func TestSegmentQueueBlocking(t *testing.T) {
+	dir, err := ioutil.TempDir("", "segment_queue")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	sq, err := openSegmentQueue(dir, defaultMaxSegmentBytes, 0)
+	require.NoError(t, err)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		sq.Enqueue([]byte("event0"), true)
+	}()
+
+	payload, err := sq.PeekBlock()
+	require.NoError(t, err)
+	require.Equal(t, "event0", string(payload))
+	require.NoError(t, sq.Commit())
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		sq.Close()
+	}()
+
+	_, err = sq.PeekBlock()
+	require.Equal(t, ErrQueueClosed, err)
+}
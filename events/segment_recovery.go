@@ -0,0 +1,157 @@
+package events
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/jitsucom/eventnative/metrics"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+//damaged segments are kept in <queue dir>/quarantine for manual inspection and aren't removed automatically
+const quarantineDirName = "quarantine"
+
+//recoveryStats are counts of the queue segments recovery
+//corrupted part is a sequence of bytes between valid entries (one or several damaged entries)
+type recoveryStats struct {
+	corruptedParts      int
+	corruptedBytes      int64
+	quarantinedSegments int
+}
+
+func (rs *recoveryStats) String() string {
+	return fmt.Sprintf("%d corrupted parts (%d bytes) were skipped, %d damaged segments were quarantined", rs.corruptedParts, rs.corruptedBytes, rs.quarantinedSegments)
+}
+
+//forEachEntry call entryFn with every valid entry (header + payload) and corruptedFn with every corrupted part
+//reading is resumed from the next valid entry after a corrupted part
+func forEachEntry(data []byte, entryFn func(entry []byte) error, corruptedFn func(start, end int) error) error {
+	for pos := 0; pos < len(data); {
+		if size, ok := entryAt(data, pos); ok {
+			if err := entryFn(data[pos : pos+size]); err != nil {
+				return err
+			}
+			pos += size
+			continue
+		}
+
+		next := pos + 1
+		for next < len(data) {
+			if _, ok := entryAt(data, next); ok {
+				break
+			}
+			next++
+		}
+		if err := corruptedFn(pos, next); err != nil {
+			return err
+		}
+		pos = next
+	}
+
+	return nil
+}
+
+//entryAt return size of the entry at pos and true if the entry is valid: it has non-empty payload of the declared length
+//with matched crc32
+func entryAt(data []byte, pos int) (int, bool) {
+	if len(data)-pos < entryHeaderSize {
+		return 0, false
+	}
+	length := int(binary.BigEndian.Uint32(data[pos : pos+4]))
+	if length == 0 || length > maxEntryBytes || len(data)-pos-entryHeaderSize < length {
+		return 0, false
+	}
+	payload := data[pos+entryHeaderSize : pos+entryHeaderSize+length]
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(data[pos+4:pos+8]) {
+		return 0, false
+	}
+	return entryHeaderSize + length, true
+}
+
+//recoverSegment check not committed entries of the segment (after offset). If there are corrupted parts, the segment is
+//copied into quarantine dir and rewritten with valid entries only (committed part isn't changed so the offset stays valid)
+//return true if the segment has been rewritten or err if the segment can't be read or rewritten
+func (sq *segmentQueue) recoverSegment(id, offset int64) (bool, error) {
+	segmentPath := sq.segmentPath(id)
+	data, err := ioutil.ReadFile(segmentPath)
+	if err != nil {
+		return false, fmt.Errorf("Error reading queue segment: %v", err)
+	}
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+
+	valid := make([]byte, offset, len(data))
+	copy(valid, data[:offset])
+	parts := 0
+	var corruptedBytes int64
+	forEachEntry(data[offset:], func(entry []byte) error {
+		valid = append(valid, entry...)
+		return nil
+	}, func(start, end int) error {
+		parts++
+		corruptedBytes += int64(end - start)
+		return nil
+	})
+	if parts == 0 {
+		return false, nil
+	}
+
+	quarantinePath, err := sq.quarantinePath(id)
+	if err != nil {
+		return false, err
+	}
+	if err := ioutil.WriteFile(quarantinePath, data, 0644); err != nil {
+		return false, fmt.Errorf("Error copying damaged queue segment into quarantine: %v", err)
+	}
+
+	tmpPath := segmentPath + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, valid, 0644); err != nil {
+		return false, fmt.Errorf("Error writing recovered queue segment: %v", err)
+	}
+	if err := os.Rename(tmpPath, segmentPath); err != nil {
+		return false, fmt.Errorf("Error replacing queue segment with recovered one: %v", err)
+	}
+
+	sq.recovery.corruptedParts += parts
+	sq.recovery.corruptedBytes += corruptedBytes
+	sq.recovery.quarantinedSegments++
+	metrics.QueueCorruptedParts(sq.name(), parts)
+	metrics.QueueQuarantinedSegment(sq.name())
+	eventsLogger.Warnf("[%s] Queue segment [%d] has %d corrupted parts (%d bytes): they were skipped, damaged segment was copied into [%s]",
+		sq.name(), id, parts, corruptedBytes, quarantinePath)
+	return true, nil
+}
+
+//quarantineSegment move the segment which can't be recovered into quarantine dir
+func (sq *segmentQueue) quarantineSegment(id int64) error {
+	quarantinePath, err := sq.quarantinePath(id)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(sq.segmentPath(id), quarantinePath); err != nil {
+		return fmt.Errorf("Error moving damaged queue segment into quarantine: %v", err)
+	}
+
+	sq.recovery.quarantinedSegments++
+	metrics.QueueQuarantinedSegment(sq.name())
+	eventsLogger.Warnf("[%s] Queue segment [%d] was moved into [%s]", sq.name(), id, quarantinePath)
+	return nil
+}
+
+func (sq *segmentQueue) quarantinePath(id int64) (string, error) {
+	dir := path.Join(sq.dir, quarantineDirName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("Error creating queue quarantine dir [%s]: %v", dir, err)
+	}
+	return path.Join(dir, fmt.Sprintf("%013d-%d%s", id, time.Now().UTC().Unix(), segmentFileExt)), nil
+}
+
+//name return queue name from the queue dir (<fallbackDir>/<queueName>.queue)
+func (sq *segmentQueue) name() string {
+	return strings.TrimSuffix(path.Base(sq.dir), ".queue")
+}
@@ -0,0 +1,158 @@
+package events
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestConvertSegment(t *testing.T) {
+	now := time.Date(2020, 11, 10, 12, 0, 10, 0, time.UTC)
+	tests := []struct {
+		name        string
+		message     map[string]interface{}
+		callType    string
+		expected    Fact
+		expectedErr string
+	}{
+		{
+			"Nil message",
+			nil,
+			SegmentTrack,
+			nil,
+			"Input fact can't be nil",
+		},
+		{
+			"Unknown type",
+			map[string]interface{}{"type": "unknown"},
+			"",
+			nil,
+			"Unknown Segment message type: unknown",
+		},
+		{
+			"Track without event",
+			map[string]interface{}{"userId": "u1"},
+			SegmentTrack,
+			nil,
+			"Segment track message must have event",
+		},
+		{
+			"Track with context and clock skew",
+			map[string]interface{}{
+				"type":        "track",
+				"messageId":   "m1",
+				"userId":      "u1",
+				"anonymousId": "a1",
+				"event":       "Order Completed",
+				"properties":  map[string]interface{}{"revenue": 10.5},
+				"timestamp":   "2020-11-10T12:00:00.000Z",
+				"sentAt":      "2020-11-10T12:00:05.000Z",
+				"context": map[string]interface{}{
+					"ip":        "10.10.10.10",
+					"userAgent": "Mozilla/5.0",
+					"locale":    "en-US",
+					"screen":    map[string]interface{}{"width": 1920, "height": 1080},
+					"page":      map[string]interface{}{"url": "https://site.com/a?b=c", "referrer": "https://google.com", "title": "A", "path": "/a", "search": "?b=c"},
+					"campaign":  map[string]interface{}{"name": "black_friday", "source": "google"},
+					"traits":    map[string]interface{}{"email": "a@b.com"},
+					"library":   map[string]interface{}{"name": "analytics.js", "version": "4.1.0"},
+				},
+			},
+			"",
+			Fact{
+				"src":        "segment",
+				"event_type": "Order Completed",
+				"event_data": map[string]interface{}{"revenue": 10.5},
+				"eventn_ctx": map[string]interface{}{
+					"event_id":          "m1",
+					"user":              map[string]interface{}{"id": "u1", "anonymous_id": "a1", "email": "a@b.com"},
+					"ip":                "10.10.10.10",
+					"user_agent":        "Mozilla/5.0",
+					"user_language":     "en-US",
+					"screen_resolution": "1920x1080",
+					"url":               "https://site.com/a?b=c",
+					"referer":           "https://google.com",
+					"page_title":        "A",
+					"doc_path":          "/a",
+					"doc_search":        "?b=c",
+					"utm":               map[string]interface{}{"campaign": "black_friday", "source": "google"},
+					"utc_time":          "2020-11-10T12:00:05.000000Z",
+					"segment_context":   map[string]interface{}{"library": map[string]interface{}{"name": "analytics.js", "version": "4.1.0"}},
+				},
+			},
+			"",
+		},
+		{
+			"Page with request ip",
+			map[string]interface{}{
+				"anonymousId": "a1",
+				"name":        "Home",
+				"properties":  map[string]interface{}{"url": "https://site.com", "title": "Home page"},
+				"context":     map[string]interface{}{"page": map[string]interface{}{"url": "https://other.com", "path": "/"}},
+			},
+			"p",
+			Fact{
+				"src":        "segment",
+				"event_type": "pageview",
+				"event_data": map[string]interface{}{"url": "https://site.com", "title": "Home page", "name": "Home"},
+				"eventn_ctx": map[string]interface{}{
+					"user":       map[string]interface{}{"anonymous_id": "a1"},
+					"ip":         "1.1.1.1",
+					"url":        "https://site.com",
+					"page_title": "Home page",
+					"doc_path":   "/",
+				},
+			},
+			"",
+		},
+		{
+			"Identify traits",
+			map[string]interface{}{
+				"userId": "u1",
+				"traits": map[string]interface{}{"email": "a@b.com", "plan": "pro"},
+			},
+			SegmentIdentify,
+			Fact{
+				"src":        "segment",
+				"event_type": "identify",
+				"eventn_ctx": map[string]interface{}{
+					"user": map[string]interface{}{"id": "u1", "email": "a@b.com", "plan": "pro"},
+					"ip":   "1.1.1.1",
+				},
+			},
+			"",
+		},
+		{
+			"Group and alias",
+			map[string]interface{}{
+				"type":    "group",
+				"userId":  "u1",
+				"groupId": "g1",
+				"traits":  map[string]interface{}{"name": "Company"},
+			},
+			SegmentAlias,
+			Fact{
+				"src":        "segment",
+				"event_type": "group",
+				"event_data": map[string]interface{}{"group_id": "g1", "name": "Company"},
+				"eventn_ctx": map[string]interface{}{
+					"user": map[string]interface{}{"id": "u1"},
+					"ip":   "1.1.1.1",
+				},
+			},
+			"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := ConvertSegment(tt.message, tt.callType, "1.1.1.1", now)
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package events
+
+import (
+	"fmt"
+	"github.com/jitsucom/eventnative/enrichment"
+)
+
+//ThirdPartyPreprocessor preprocess events which are converted from 3rd party tracking APIs messages (Segment, Google Analytics)
+type ThirdPartyPreprocessor struct {
+	ipLookupRule enrichment.Rule
+	uaParseRule  enrichment.Rule
+}
+
+func NewThirdPartyPreprocessor() (Preprocessor, error) {
+	ipLookupRule, err := enrichment.NewRule(enrichment.DefaultThirdPartyIpRuleConfig)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating default 3rd party ip lookup enrichment rule: %v", err)
+	}
+
+	uaParseRule, err := enrichment.NewRule(enrichment.DefaultThirdPartyUaRuleConfig)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating default 3rd party ua parse enrichment rule: %v", err)
+	}
+
+	return &ThirdPartyPreprocessor{
+		ipLookupRule: ipLookupRule,
+		uaParseRule:  uaParseRule,
+	}, nil
+}
+
+//Preprocess executes default enrichment rules on eventn_ctx.ip and eventn_ctx.user_agent
+//return same object
+func (tpp *ThirdPartyPreprocessor) Preprocess(fact Fact) (Fact, error) {
+	if fact == nil {
+		return nil, nilFactErr
+	}
+
+	if err := tpp.ipLookupRule.Execute(nil, fact); err != nil {
+		eventsLogger.SystemErrorf("Error executing default 3rd party ip lookup enrichment rule: %v", err)
+	}
+
+	if err := tpp.uaParseRule.Execute(nil, fact); err != nil {
+		eventsLogger.SystemErrorf("Error executing default 3rd party ua parse enrichment rule: %v", err)
+	}
+
+	return fact, nil
+}
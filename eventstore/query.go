@@ -0,0 +1,771 @@
+package eventstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jitsucom/eventnative/filters"
+	"github.com/jitsucom/eventnative/timestamp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	DefaultLimit = 100
+	MaxLimit     = 10000
+
+	fieldExpression     = ""
+	dateTruncExpression = "date_trunc"
+
+	countFunction = "count"
+	sumFunction   = "sum"
+	avgFunction   = "avg"
+	minFunction   = "min"
+	maxFunction   = "max"
+)
+
+var (
+	aggregateFunctions = map[string]bool{countFunction: true, sumFunction: true, avgFunction: true, minFunction: true, maxFunction: true}
+	truncUnits         = map[string]time.Duration{"minute": time.Minute, "hour": time.Hour, "day": 24 * time.Hour}
+	//clauseKeywords finish WHERE condition
+	clauseKeywords = map[string]bool{"group": true, "order": true, "limit": true}
+)
+
+//Query is a parsed read-only SQL query over one table of the store:
+//
+//  SELECT column [AS alias], ... FROM table
+//    [WHERE filter expression (the same syntax as destinations filter: event_type == "click" && user.id != null)]
+//    [GROUP BY column or alias, ...] [ORDER BY column or alias [ASC|DESC], ...] [LIMIT n]
+//
+//  column := * | field | date_trunc('minute' | 'hour' | 'day', field) | count(*) | count([DISTINCT] field) | sum|avg|min|max(field)
+type Query struct {
+	columns []*column
+	table   string
+	where   *filters.Filter
+	groupBy []*column
+	orderBy []*ordering
+	limit   int
+}
+
+type column struct {
+	//field name or * for all fields
+	field string
+	//empty for field, date_trunc or aggregate function name
+	function string
+	distinct bool
+	unit     time.Duration
+	alias    string
+}
+
+func (c *column) aggregate() bool {
+	return aggregateFunctions[c.function]
+}
+
+//key identify the column expression in GROUP BY
+func (c *column) key() string {
+	return fmt.Sprintf("%s(%t,%s,%d)", c.function, c.distinct, c.field, c.unit)
+}
+
+//value return the field value or truncated time of the row. Not aggregate columns only
+func (c *column) value(row map[string]interface{}) interface{} {
+	value := row[c.field]
+	if c.function != dateTruncExpression {
+		return value
+	}
+	t, ok := toTime(value)
+	if !ok {
+		return nil
+	}
+	return t.UTC().Truncate(c.unit)
+}
+
+type ordering struct {
+	column string
+	desc   bool
+}
+
+type queryToken struct {
+	value string
+	//true if value is a quoted string
+	quoted bool
+	pos    int
+}
+
+//ParseQuery return Query or error if the query is malformed or isn't supported
+func ParseQuery(query string) (*Query, error) {
+	tokens, err := tokenizeQuery(query)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing query: %v", err)
+	}
+
+	p := &queryParser{query: query, tokens: tokens}
+	q, err := p.parse()
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing query: %v", err)
+	}
+	return q, nil
+}
+
+type queryParser struct {
+	query  string
+	tokens []queryToken
+	pos    int
+}
+
+func (p *queryParser) peek() queryToken {
+	if p.pos >= len(p.tokens) {
+		return queryToken{pos: len(p.query)}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *queryParser) next() queryToken {
+	t := p.peek()
+	if p.pos < len(p.tokens) {
+		p.pos++
+	}
+	return t
+}
+
+func (p *queryParser) end() bool {
+	return p.pos >= len(p.tokens)
+}
+
+//keyword return true if the next token is the not quoted keyword (case insensitive)
+func (p *queryParser) keyword(keyword string) bool {
+	t := p.peek()
+	return !t.quoted && strings.EqualFold(t.value, keyword)
+}
+
+func (p *queryParser) clauseKeyword() bool {
+	t := p.peek()
+	return !t.quoted && clauseKeywords[strings.ToLower(t.value)]
+}
+
+func (p *queryParser) expect(value string) error {
+	t := p.next()
+	if t.quoted || !strings.EqualFold(t.value, value) {
+		return fmt.Errorf("expected [%s] but got %s", value, describe(t))
+	}
+	return nil
+}
+
+func (p *queryParser) parse() (*Query, error) {
+	if err := p.expect("select"); err != nil {
+		return nil, err
+	}
+
+	q := &Query{limit: DefaultLimit}
+	for {
+		c, err := p.parseColumn(true)
+		if err != nil {
+			return nil, err
+		}
+		q.columns = append(q.columns, c)
+		if p.peek().value != "," {
+			break
+		}
+		p.next()
+	}
+
+	if err := p.expect("from"); err != nil {
+		return nil, err
+	}
+	table := p.next()
+	if table.value == "" || (!table.quoted && !isIdentifier(table.value)) {
+		return nil, fmt.Errorf("expected table name but got %s", describe(table))
+	}
+	q.table = table.value
+
+	if p.keyword("where") {
+		p.next()
+		start := p.peek().pos
+		for !p.end() && !p.clauseKeyword() {
+			p.next()
+		}
+		expression := strings.TrimSpace(p.query[start:p.peek().pos])
+		if expression == "" {
+			return nil, fmt.Errorf("WHERE condition is empty")
+		}
+		where, err := filters.Parse(expression)
+		if err != nil {
+			return nil, err
+		}
+		q.where = where
+	}
+
+	if p.keyword("group") {
+		p.next()
+		if err := p.expect("by"); err != nil {
+			return nil, err
+		}
+		for {
+			c, err := p.parseColumn(false)
+			if err != nil {
+				return nil, err
+			}
+			q.groupBy = append(q.groupBy, q.resolve(c))
+			if p.peek().value != "," {
+				break
+			}
+			p.next()
+		}
+	}
+
+	if p.keyword("order") {
+		p.next()
+		if err := p.expect("by"); err != nil {
+			return nil, err
+		}
+		for {
+			t := p.next()
+			if t.value == "" {
+				return nil, fmt.Errorf("expected ORDER BY column but got %s", describe(t))
+			}
+			o := &ordering{column: t.value}
+			if p.keyword("desc") {
+				p.next()
+				o.desc = true
+			} else if p.keyword("asc") {
+				p.next()
+			}
+			q.orderBy = append(q.orderBy, o)
+			if p.peek().value != "," {
+				break
+			}
+			p.next()
+		}
+	}
+
+	if p.keyword("limit") {
+		p.next()
+		t := p.next()
+		limit, err := strconv.Atoi(t.value)
+		if err != nil || limit <= 0 {
+			return nil, fmt.Errorf("LIMIT must be positive int but got %s", describe(t))
+		}
+		if limit > MaxLimit {
+			return nil, fmt.Errorf("LIMIT must be <= %d", MaxLimit)
+		}
+		q.limit = limit
+	}
+
+	if !p.end() {
+		return nil, fmt.Errorf("unexpected %s", describe(p.peek()))
+	}
+
+	return q, q.validate()
+}
+
+//parseColumn parse select (with alias) or group by column
+func (p *queryParser) parseColumn(withAlias bool) (*column, error) {
+	t := p.next()
+	if t.value == "*" && withAlias {
+		return &column{field: "*"}, nil
+	}
+	if t.value == "" || t.quoted || !isIdentifier(t.value) {
+		return nil, fmt.Errorf("expected column but got %s", describe(t))
+	}
+
+	c := &column{field: t.value}
+	if p.peek().value == "(" {
+		p.next()
+		function := strings.ToLower(t.value)
+		switch {
+		case function == dateTruncExpression:
+			unitToken := p.next()
+			unit, ok := truncUnits[strings.ToLower(unitToken.value)]
+			if !unitToken.quoted || !ok {
+				return nil, fmt.Errorf("date_trunc unit must be 'minute', 'hour' or 'day' but got %s", describe(unitToken))
+			}
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+			field := p.next()
+			if !isIdentifier(field.value) || field.quoted {
+				return nil, fmt.Errorf("expected date_trunc field but got %s", describe(field))
+			}
+			c = &column{field: field.value, function: dateTruncExpression, unit: unit, alias: strings.ToLower(unitToken.value)}
+		case aggregateFunctions[function]:
+			c = &column{function: function}
+			if p.keyword("distinct") && function == countFunction {
+				p.next()
+				c.distinct = true
+			}
+			field := p.next()
+			if field.value == "*" && function == countFunction && !c.distinct {
+				c.alias = countFunction
+			} else if isIdentifier(field.value) && !field.quoted {
+				c.field = field.value
+				c.alias = function + "_" + field.value
+				if c.distinct {
+					c.alias = function + "_distinct_" + field.value
+				}
+			} else {
+				return nil, fmt.Errorf("expected %s argument but got %s", function, describe(field))
+			}
+		default:
+			return nil, fmt.Errorf("unknown function [%s]. Available: [count, sum, avg, min, max, date_trunc]", t.value)
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+	}
+
+	if withAlias && p.keyword("as") {
+		p.next()
+		alias := p.next()
+		if alias.value == "" || (!alias.quoted && !isIdentifier(alias.value)) {
+			return nil, fmt.Errorf("expected alias but got %s", describe(alias))
+		}
+		c.alias = alias.value
+	}
+
+	return c, nil
+}
+
+//resolve return select column with the alias (or the same expression) of the group by column
+func (q *Query) resolve(c *column) *column {
+	for _, selected := range q.columns {
+		if c.function == fieldExpression && selected.name() == c.field {
+			return selected
+		}
+		if selected.key() == c.key() {
+			return selected
+		}
+	}
+	return c
+}
+
+func (q *Query) aggregated() bool {
+	if len(q.groupBy) > 0 {
+		return true
+	}
+	for _, c := range q.columns {
+		if c.aggregate() {
+			return true
+		}
+	}
+	return false
+}
+
+func (q *Query) validate() error {
+	for _, c := range q.groupBy {
+		if c.aggregate() {
+			return fmt.Errorf("aggregate functions aren't allowed in GROUP BY")
+		}
+	}
+
+	grouped := map[string]bool{}
+	for _, c := range q.groupBy {
+		grouped[c.key()] = true
+	}
+	for _, c := range q.columns {
+		if c.field == "*" && c.function == fieldExpression {
+			if q.aggregated() {
+				return fmt.Errorf("* isn't allowed in aggregation queries")
+			}
+			continue
+		}
+		if q.aggregated() && !c.aggregate() && !grouped[c.key()] {
+			return fmt.Errorf("column [%s] must be in GROUP BY or be used in an aggregate function", c.name())
+		}
+	}
+	return nil
+}
+
+func (c *column) name() string {
+	if c.alias != "" {
+		return c.alias
+	}
+	return c.field
+}
+
+//Result is a query result: columns names and rows values in the columns order
+type Result struct {
+	Columns     []string        `json:"columns"`
+	Rows        [][]interface{} `json:"rows"`
+	ScannedRows int             `json:"scanned_rows"`
+	ElapsedMs   int64           `json:"elapsed_ms"`
+}
+
+//execute run the query over the table rows
+func (q *Query) execute(rows []map[string]interface{}) (*Result, error) {
+	var matched []map[string]interface{}
+	for _, row := range rows {
+		if q.where != nil {
+			ok, err := q.where.Match(row)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+		}
+		matched = append(matched, row)
+	}
+
+	var result *Result
+	if q.aggregated() {
+		result = q.aggregate(matched)
+	} else {
+		result = q.project(matched)
+	}
+	result.ScannedRows = len(rows)
+
+	if err := q.sort(result); err != nil {
+		return nil, err
+	}
+	if len(result.Rows) > q.limit {
+		result.Rows = result.Rows[:q.limit]
+	}
+	return result, nil
+}
+
+//project return selected columns of rows. * is expanded into sorted fields of all rows
+func (q *Query) project(rows []map[string]interface{}) *Result {
+	var columns []*column
+	for _, c := range q.columns {
+		if c.field != "*" {
+			columns = append(columns, c)
+			continue
+		}
+		fields := map[string]bool{}
+		for _, row := range rows {
+			for field := range row {
+				fields[field] = true
+			}
+		}
+		var sorted []string
+		for field := range fields {
+			sorted = append(sorted, field)
+		}
+		sort.Strings(sorted)
+		for _, field := range sorted {
+			columns = append(columns, &column{field: field})
+		}
+	}
+
+	result := &Result{Rows: [][]interface{}{}}
+	for _, c := range columns {
+		result.Columns = append(result.Columns, c.name())
+	}
+	for _, row := range rows {
+		values := make([]interface{}, len(columns))
+		for i, c := range columns {
+			values[i] = c.value(row)
+		}
+		result.Rows = append(result.Rows, values)
+	}
+	return result
+}
+
+type accumulator struct {
+	count    int64
+	sum      float64
+	numbers  int64
+	extreme  interface{}
+	distinct map[string]bool
+}
+
+func (a *accumulator) add(c *column, value interface{}) {
+	if c.field == "" {
+		a.count++
+		return
+	}
+	if value == nil {
+		return
+	}
+	switch c.function {
+	case countFunction:
+		if c.distinct {
+			a.distinct[fmt.Sprint(value)] = true
+		} else {
+			a.count++
+		}
+	case sumFunction, avgFunction:
+		if number, ok := toNumber(value); ok {
+			a.sum += number
+			a.numbers++
+		}
+	case minFunction:
+		if a.extreme == nil || compareValues(value, a.extreme) < 0 {
+			a.extreme = value
+		}
+	case maxFunction:
+		if a.extreme == nil || compareValues(value, a.extreme) > 0 {
+			a.extreme = value
+		}
+	}
+}
+
+func (a *accumulator) result(c *column) interface{} {
+	switch c.function {
+	case countFunction:
+		if c.distinct {
+			return int64(len(a.distinct))
+		}
+		return a.count
+	case sumFunction:
+		return a.sum
+	case avgFunction:
+		if a.numbers == 0 {
+			return nil
+		}
+		return a.sum / float64(a.numbers)
+	default:
+		return a.extreme
+	}
+}
+
+type group struct {
+	values       []interface{}
+	accumulators []*accumulator
+}
+
+//aggregate return a row per group (one row without GROUP BY) with group by and aggregate columns
+func (q *Query) aggregate(rows []map[string]interface{}) *Result {
+	newGroup := func(values []interface{}) *group {
+		g := &group{values: values}
+		for range q.columns {
+			g.accumulators = append(g.accumulators, &accumulator{distinct: map[string]bool{}})
+		}
+		return g
+	}
+
+	groups := map[string]*group{}
+	var order []string
+	if len(q.groupBy) == 0 {
+		groups[""] = newGroup(nil)
+		order = append(order, "")
+	}
+	for _, row := range rows {
+		values := make([]interface{}, len(q.groupBy))
+		for i, c := range q.groupBy {
+			values[i] = c.value(row)
+		}
+		key := ""
+		if len(values) > 0 {
+			b, _ := json.Marshal(values)
+			key = string(b)
+		}
+		g, ok := groups[key]
+		if !ok {
+			g = newGroup(values)
+			groups[key] = g
+			order = append(order, key)
+		}
+		for i, c := range q.columns {
+			if c.aggregate() {
+				g.accumulators[i].add(c, row[c.field])
+			}
+		}
+	}
+
+	result := &Result{Rows: [][]interface{}{}}
+	for _, c := range q.columns {
+		result.Columns = append(result.Columns, c.name())
+	}
+	for _, key := range order {
+		g := groups[key]
+		values := make([]interface{}, len(q.columns))
+		for i, c := range q.columns {
+			if c.aggregate() {
+				values[i] = g.accumulators[i].result(c)
+				continue
+			}
+			for j, groupBy := range q.groupBy {
+				if groupBy.key() == c.key() {
+					values[i] = g.values[j]
+				}
+			}
+		}
+		result.Rows = append(result.Rows, values)
+	}
+	return result
+}
+
+//sort order result rows by columns names. Rows are kept in the table order if ORDER BY isn't specified
+func (q *Query) sort(result *Result) error {
+	var indexes []int
+	for _, o := range q.orderBy {
+		index := -1
+		for i, name := range result.Columns {
+			if name == o.column {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			return fmt.Errorf("Error executing query: ORDER BY column [%s] isn't selected", o.column)
+		}
+		indexes = append(indexes, index)
+	}
+
+	sort.SliceStable(result.Rows, func(i, j int) bool {
+		for k, o := range q.orderBy {
+			cmp := compareValues(result.Rows[i][indexes[k]], result.Rows[j][indexes[k]])
+			if cmp == 0 {
+				continue
+			}
+			if o.desc {
+				return cmp > 0
+			}
+			return cmp < 0
+		}
+		return false
+	})
+	return nil
+}
+
+//compareValues return -1, 0, 1. nil is the lowest value, numbers are compared as float64,
+//time is compared as string in timestamp.Layout, other values as their string representations
+func compareValues(left, right interface{}) int {
+	if left == nil || right == nil {
+		switch {
+		case left == nil && right == nil:
+			return 0
+		case left == nil:
+			return -1
+		default:
+			return 1
+		}
+	}
+
+	leftNumber, leftOk := toNumber(left)
+	rightNumber, rightOk := toNumber(right)
+	if leftOk && rightOk {
+		switch {
+		case leftNumber < rightNumber:
+			return -1
+		case leftNumber > rightNumber:
+			return 1
+		default:
+			return 0
+		}
+	}
+
+	return strings.Compare(toString(left), toString(right))
+}
+
+func toNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+func toString(value interface{}) string {
+	if t, ok := value.(time.Time); ok {
+		return t.UTC().Format(timestamp.Layout)
+	}
+	return fmt.Sprint(value)
+}
+
+//toTime return time.Time value or parsed timestamp.Layout (RFC3339) string
+func toTime(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, true
+	case string:
+		if t, err := time.Parse(timestamp.Layout, v); err == nil {
+			return t, true
+		}
+		if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+//tokenizeQuery split query into identifiers, numbers, single quoted strings and symbols.
+//Symbols of the WHERE filter expression (e.g. == or &&) are kept as separate tokens: the expression is parsed by filters
+func tokenizeQuery(query string) ([]queryToken, error) {
+	var tokens []queryToken
+	runes := []rune(query)
+	bytePos := func(runePos int) int {
+		return len(string(runes[:runePos]))
+	}
+	pos := 0
+	for pos < len(runes) {
+		r := runes[pos]
+		if unicode.IsSpace(r) {
+			pos++
+			continue
+		}
+
+		if r == '\'' || r == '"' {
+			start := pos
+			var sb strings.Builder
+			closed := false
+			for pos++; pos < len(runes); pos++ {
+				if runes[pos] == '\\' && pos+1 < len(runes) {
+					pos++
+					sb.WriteRune(runes[pos])
+					continue
+				}
+				if runes[pos] == r {
+					closed = true
+					pos++
+					break
+				}
+				sb.WriteRune(runes[pos])
+			}
+			if !closed {
+				return nil, fmt.Errorf("unclosed string at position %d", start)
+			}
+			tokens = append(tokens, queryToken{value: sb.String(), quoted: true, pos: bytePos(start)})
+			continue
+		}
+
+		if isIdentifierRune(r) || r == '-' {
+			start := pos
+			for pos++; pos < len(runes) && isIdentifierRune(runes[pos]); pos++ {
+			}
+			tokens = append(tokens, queryToken{value: string(runes[start:pos]), pos: bytePos(start)})
+			continue
+		}
+
+		tokens = append(tokens, queryToken{value: string(r), pos: bytePos(pos)})
+		pos++
+	}
+	return tokens, nil
+}
+
+func isIdentifierRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '.' || r == '/'
+}
+
+func isIdentifier(value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, r := range value {
+		if !isIdentifierRune(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func describe(t queryToken) string {
+	if t.value == "" && !t.quoted {
+		return "end of query"
+	}
+	return fmt.Sprintf("[%s] at position %d", t.value, t.pos)
+}
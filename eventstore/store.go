@@ -0,0 +1,238 @@
+package eventstore
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultRetentionHours  = 24
+	DefaultMaxRowsPerTable = 100000
+)
+
+//Instance is a singleton store. Nil (default) means the store is disabled
+var Instance *Store
+
+//Config is a server.event_store configuration
+type Config struct {
+	Enabled         bool `mapstructure:"enabled"`
+	RetentionHours  int  `mapstructure:"retention_hours"`
+	MaxRowsPerTable int  `mapstructure:"max_rows_per_table"`
+	//destinations ids which events are kept. Empty means all destinations
+	Destinations []string `mapstructure:"destinations"`
+}
+
+//Init set Instance if the store is enabled
+func Init(config *Config) {
+	if config == nil || !config.Enabled {
+		return
+	}
+	Instance = NewStore(config.RetentionHours, config.MaxRowsPerTable, config.Destinations)
+}
+
+//TableStats is a count of rows which are kept in the table and their stored time range
+type TableStats struct {
+	Rows   int       `json:"rows"`
+	Oldest time.Time `json:"oldest"`
+	Newest time.Time `json:"newest"`
+}
+
+//segment is rows which have been stored during one hour
+type segment struct {
+	hour int64
+	rows []map[string]interface{}
+	//stored time of every row
+	times []time.Time
+}
+
+type table struct {
+	//ordered by hour
+	segments []*segment
+	rows     int
+}
+
+//Store keeps processed events of the last retention hours per destination table in memory (per server)
+//for short-term analytics via read-only SQL queries (see Query). The oldest rows are dropped if a table exceeds maxRowsPerTable
+type Store struct {
+	sync.RWMutex
+
+	retention       time.Duration
+	maxRowsPerTable int
+	//nil means all destinations
+	destinations map[string]bool
+	now          func() time.Time
+	tables       map[string]map[string]*table
+}
+
+func NewStore(retentionHours, maxRowsPerTable int, destinations []string) *Store {
+	if retentionHours <= 0 {
+		retentionHours = DefaultRetentionHours
+	}
+	if maxRowsPerTable <= 0 {
+		maxRowsPerTable = DefaultMaxRowsPerTable
+	}
+	var destinationsFilter map[string]bool
+	if len(destinations) > 0 {
+		destinationsFilter = map[string]bool{}
+		for _, destinationId := range destinations {
+			destinationsFilter[destinationId] = true
+		}
+	}
+	return &Store{retention: time.Duration(retentionHours) * time.Hour, maxRowsPerTable: maxRowsPerTable,
+		destinations: destinationsFilter, now: time.Now, tables: map[string]map[string]*table{}}
+}
+
+//Put copy the processed (flattened) object which has been stored in the destination table. Nil Store doesn't keep anything
+func (s *Store) Put(destinationId, tableName string, object map[string]interface{}) {
+	if s == nil || (s.destinations != nil && !s.destinations[destinationId]) {
+		return
+	}
+
+	row := make(map[string]interface{}, len(object))
+	for key, value := range object {
+		row[key] = value
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	now := s.now()
+	destinationTables, ok := s.tables[destinationId]
+	if !ok {
+		destinationTables = map[string]*table{}
+		s.tables[destinationId] = destinationTables
+	}
+	t, ok := destinationTables[tableName]
+	if !ok {
+		t = &table{}
+		destinationTables[tableName] = t
+	}
+
+	hour := now.Unix() / 3600
+	if len(t.segments) == 0 || t.segments[len(t.segments)-1].hour != hour {
+		t.segments = append(t.segments, &segment{hour: hour})
+	}
+	last := t.segments[len(t.segments)-1]
+	last.rows = append(last.rows, row)
+	last.times = append(last.times, now)
+	t.rows++
+
+	s.expire(t, now)
+	for t.rows > s.maxRowsPerTable {
+		oldest := t.segments[0]
+		oldest.rows[0] = nil
+		oldest.rows = oldest.rows[1:]
+		oldest.times = oldest.times[1:]
+		t.rows--
+		if len(oldest.rows) == 0 {
+			t.segments = t.segments[1:]
+		}
+	}
+}
+
+//Query execute read-only query over the destination table rows of the retention window
+func (s *Store) Query(destinationId, query string) (*Result, error) {
+	if s == nil {
+		return nil, fmt.Errorf("Event store isn't enabled")
+	}
+
+	q, err := ParseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	rows := s.rows(destinationId, q.table)
+	if rows == nil {
+		return nil, fmt.Errorf("Table [%s] of destination [%s] doesn't have events in the store", q.table, destinationId)
+	}
+
+	result, err := q.execute(rows)
+	if err != nil {
+		return nil, err
+	}
+	result.ElapsedMs = time.Since(start).Milliseconds()
+	return result, nil
+}
+
+//Tables return rows stats of the destination tables
+func (s *Store) Tables(destinationId string) map[string]*TableStats {
+	result := map[string]*TableStats{}
+	if s == nil {
+		return result
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	now := s.now()
+	for name, t := range s.tables[destinationId] {
+		s.expire(t, now)
+		if t.rows == 0 {
+			continue
+		}
+		first, last := t.segments[0], t.segments[len(t.segments)-1]
+		result[name] = &TableStats{Rows: t.rows, Oldest: first.times[0].UTC(), Newest: last.times[len(last.times)-1].UTC()}
+	}
+	return result
+}
+
+//DestinationIds return ids of destinations which have events in the store
+func (s *Store) DestinationIds() []string {
+	if s == nil {
+		return []string{}
+	}
+
+	s.RLock()
+	defer s.RUnlock()
+
+	ids := make([]string, 0, len(s.tables))
+	for id := range s.tables {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+//rows return not expired rows of the table or nil if the table doesn't exist.
+//Rows maps aren't changed after Put: they are read without the lock
+func (s *Store) rows(destinationId, tableName string) []map[string]interface{} {
+	s.Lock()
+	defer s.Unlock()
+
+	t, ok := s.tables[destinationId][tableName]
+	if !ok {
+		return nil
+	}
+	s.expire(t, s.now())
+
+	rows := make([]map[string]interface{}, 0, t.rows)
+	for _, seg := range t.segments {
+		rows = append(rows, seg.rows...)
+	}
+	return rows
+}
+
+//expire drop rows which are older than retention. Must be called under the write lock
+func (s *Store) expire(t *table, now time.Time) {
+	threshold := now.Add(-s.retention)
+	for len(t.segments) > 0 {
+		oldest := t.segments[0]
+		//the whole segment is expired
+		if oldest.times[len(oldest.times)-1].Before(threshold) {
+			t.rows -= len(oldest.rows)
+			t.segments = t.segments[1:]
+			continue
+		}
+		i := sort.Search(len(oldest.times), func(i int) bool { return !oldest.times[i].Before(threshold) })
+		for j := 0; j < i; j++ {
+			oldest.rows[j] = nil
+		}
+		oldest.rows = oldest.rows[i:]
+		oldest.times = oldest.times[i:]
+		t.rows -= i
+		return
+	}
+}
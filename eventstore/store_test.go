@@ -0,0 +1,107 @@
+package eventstore
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestStoreQuery(t *testing.T) {
+	store := NewStore(24, 100, nil)
+	now := time.Date(2020, 11, 10, 12, 0, 30, 0, time.UTC)
+	store.now = func() time.Time { return now }
+
+	for _, row := range []map[string]interface{}{
+		{"event_type": "pageview", "user_id": "u1", "revenue": 0, "_timestamp": now.Add(-time.Minute)},
+		{"event_type": "pageview", "user_id": "u2", "_timestamp": now.Add(-time.Minute)},
+		{"event_type": "purchase", "user_id": "u1", "revenue": 10.5, "_timestamp": now},
+		{"event_type": "purchase", "user_id": "u1", "revenue": 4.5, "_timestamp": now},
+	} {
+		store.Put("pg", "events", row)
+	}
+
+	tests := []struct {
+		name            string
+		query           string
+		expectedColumns []string
+		expectedRows    [][]interface{}
+	}{
+		{
+			"group by with order",
+			"SELECT event_type, count(*) AS events, count(DISTINCT user_id), sum(revenue) FROM events GROUP BY event_type ORDER BY events DESC, event_type",
+			[]string{"event_type", "events", "count_distinct_user_id", "sum_revenue"},
+			[][]interface{}{{"pageview", int64(2), int64(2), float64(0)}, {"purchase", int64(2), int64(1), float64(15)}},
+		},
+		{
+			"date_trunc and where",
+			`select date_trunc('minute', _timestamp), max(revenue) as top from events where event_type == "purchase" || revenue != null group by minute order by minute`,
+			[]string{"minute", "top"},
+			[][]interface{}{{time.Date(2020, 11, 10, 11, 59, 0, 0, time.UTC), 0}, {time.Date(2020, 11, 10, 12, 0, 0, 0, time.UTC), 10.5}},
+		},
+		{
+			"aggregates without group by",
+			"SELECT count(*), avg(revenue) FROM events WHERE user_id == 'u1'",
+			[]string{"count", "avg_revenue"},
+			[][]interface{}{{int64(3), float64(5)}},
+		},
+		{
+			"projection with limit",
+			"SELECT user_id AS user, revenue FROM events WHERE event_type == 'purchase' ORDER BY revenue LIMIT 1",
+			[]string{"user", "revenue"},
+			[][]interface{}{{"u1", 4.5}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := store.Query("pg", tt.query)
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedColumns, result.Columns)
+			require.Equal(t, tt.expectedRows, result.Rows)
+			require.Equal(t, 4, result.ScannedRows)
+		})
+	}
+}
+
+func TestQueryErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		query       string
+		expectedErr string
+	}{
+		{"not select", "DELETE FROM events", "Error parsing query: expected [select] but got [DELETE] at position 0"},
+		{"not grouped column", "SELECT event_type, count(*) FROM events", "Error parsing query: column [event_type] must be in GROUP BY or be used in an aggregate function"},
+		{"unknown function", "SELECT lower(event_type) FROM events", "Error parsing query: unknown function [lower]. Available: [count, sum, avg, min, max, date_trunc]"},
+		{"malformed where", "SELECT * FROM events WHERE event_type = 'click'", "Error parsing query: Error parsing filter expression [event_type = 'click']: Unexpected symbol [=] at position 11"},
+		{"big limit", "SELECT * FROM events LIMIT 100000", "Error parsing query: LIMIT must be <= 10000"},
+		{"trailing tokens", "SELECT * FROM events LIMIT 10 OFFSET 5", "Error parsing query: unexpected [OFFSET] at position 30"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseQuery(tt.query)
+			require.EqualError(t, err, tt.expectedErr)
+		})
+	}
+}
+
+func TestStoreExpiration(t *testing.T) {
+	store := NewStore(1, 3, []string{"pg"})
+	now := time.Date(2020, 11, 10, 12, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+
+	store.Put("bq", "events", map[string]interface{}{"id": 0})
+	for i := 1; i <= 4; i++ {
+		store.Put("pg", "events", map[string]interface{}{"id": i})
+		now = now.Add(20 * time.Minute)
+	}
+	require.Equal(t, []string{"pg"}, store.DestinationIds())
+
+	//the first row is dropped by rows limit, the second one is expired
+	now = now.Add(time.Minute)
+	result, err := store.Query("pg", "SELECT id FROM events")
+	require.NoError(t, err)
+	require.Equal(t, [][]interface{}{{3}, {4}}, result.Rows)
+	require.Equal(t, map[string]*TableStats{"events": {Rows: 2, Oldest: now.Add(-41 * time.Minute), Newest: now.Add(-21 * time.Minute)}}, store.Tables("pg"))
+
+	_, err = store.Query("pg", "SELECT id FROM clicks")
+	require.EqualError(t, err, "Table [clicks] of destination [pg] doesn't have events in the store")
+}
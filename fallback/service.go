@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/jitsucom/eventnative/appconfig"
 	"github.com/jitsucom/eventnative/destinations"
+	"github.com/jitsucom/eventnative/encryption"
 	"github.com/jitsucom/eventnative/logfiles"
 	"github.com/jitsucom/eventnative/logging"
 	"github.com/jitsucom/eventnative/metrics"
@@ -62,7 +63,11 @@ func (s *Service) Replay(fileName string) error {
 	defer s.locks.Delete(fileName)
 
 	filePath := path.Join(s.fallbackDir, fileName)
-	b, err := ioutil.ReadFile(filePath)
+	b, err := logging.ReadLogFile(filePath)
+	if err != nil {
+		return fmt.Errorf("Error reading fallback file [%s]: %v", fileName, err)
+	}
+	b, err = encryption.DecryptLines(b)
 	if err != nil {
 		return fmt.Errorf("Error reading fallback file [%s]: %v", fileName, err)
 	}
@@ -111,7 +116,7 @@ func (s *Service) Replay(fileName string) error {
 }
 
 func (s *Service) GetFileStatuses(destinationsFilter map[string]bool) []*FileStatus {
-	files, err := filepath.Glob(s.fileMask)
+	files, err := logging.GlobLogFiles(s.fileMask)
 	if err != nil {
 		logging.Errorf("Error finding fallback files by mask [%s]: %v", s.fileMask, err)
 		return []*FileStatus{}
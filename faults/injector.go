@@ -0,0 +1,189 @@
+package faults
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const defaultErrorMessage = "Injected fault"
+
+var (
+	ErrDisabled = errors.New("Fault injection is disabled. Please set server.fault_injection.enabled: true")
+
+	//Instance is a singleton fault injector. All faults are no-op until it is enabled with Init(true)
+	Instance = NewInjector(false)
+)
+
+//Fault is a test-mode failure configuration of one destination:
+//LatencyMs - delay before every store/insert operation
+//ErrorRate - probability [0..1] of failing the whole store/insert operation with ErrorMessage
+//PartialFailureRate - probability [0..1] of every event in a batch file to be failed and sent to fallback
+type Fault struct {
+	LatencyMs          int64   `json:"latency_ms"`
+	ErrorRate          float64 `json:"error_rate"`
+	PartialFailureRate float64 `json:"partial_failure_rate"`
+	ErrorMessage       string  `json:"error_message,omitempty"`
+}
+
+//Validate return err if rates aren't in [0..1] or latency is negative
+func (f *Fault) Validate() error {
+	if f.LatencyMs < 0 {
+		return errors.New("latency_ms must be >= 0")
+	}
+	if f.ErrorRate < 0 || f.ErrorRate > 1 {
+		return errors.New("error_rate must be in [0..1]")
+	}
+	if f.PartialFailureRate < 0 || f.PartialFailureRate > 1 {
+		return errors.New("partial_failure_rate must be in [0..1]")
+	}
+	return nil
+}
+
+//Injector keeps faults per destination name and applies them on store/insert operations
+//It is used by operators for validating retry, circuit breaker and fallback behavior
+type Injector struct {
+	sync.RWMutex
+
+	enabled bool
+	faults  map[string]*Fault
+	//return random float in [0..1)
+	random func() float64
+	sleep  func(time.Duration)
+}
+
+func NewInjector(enabled bool) *Injector {
+	return &Injector{enabled: enabled, faults: map[string]*Fault{}, random: rand.Float64, sleep: time.Sleep}
+}
+
+//Init enable or disable singleton Instance. When disabled - all configured faults are removed
+func Init(enabled bool) {
+	Instance.Lock()
+	defer Instance.Unlock()
+
+	Instance.enabled = enabled
+	if !enabled {
+		Instance.faults = map[string]*Fault{}
+	}
+}
+
+func (i *Injector) Enabled() bool {
+	i.RLock()
+	defer i.RUnlock()
+	return i.enabled
+}
+
+//Set put fault for destination name (replaces existing one)
+func (i *Injector) Set(destinationName string, fault *Fault) error {
+	if err := fault.Validate(); err != nil {
+		return err
+	}
+
+	i.Lock()
+	defer i.Unlock()
+
+	if !i.enabled {
+		return ErrDisabled
+	}
+
+	if fault.ErrorMessage == "" {
+		fault.ErrorMessage = defaultErrorMessage
+	}
+	i.faults[destinationName] = fault
+	return nil
+}
+
+//Remove delete fault of destination name. Remove all faults if destinationName is empty
+func (i *Injector) Remove(destinationName string) {
+	i.Lock()
+	defer i.Unlock()
+
+	if destinationName == "" {
+		i.faults = map[string]*Fault{}
+	} else {
+		delete(i.faults, destinationName)
+	}
+}
+
+//Faults return copy of configured faults per destination name
+func (i *Injector) Faults() map[string]Fault {
+	i.RLock()
+	defer i.RUnlock()
+
+	result := make(map[string]Fault, len(i.faults))
+	for name, fault := range i.faults {
+		result[name] = *fault
+	}
+	return result
+}
+
+//Inject sleep configured latency and return error with configured error rate
+//return nil if there is no fault for destination name
+func (i *Injector) Inject(destinationName string) error {
+	fault, ok := i.get(destinationName)
+	if !ok {
+		return nil
+	}
+
+	if fault.LatencyMs > 0 {
+		i.sleep(time.Duration(fault.LatencyMs) * time.Millisecond)
+	}
+
+	if fault.ErrorRate > 0 && i.random() < fault.ErrorRate {
+		return fmt.Errorf("%s [destination: %s]", fault.ErrorMessage, destinationName)
+	}
+
+	return nil
+}
+
+//Split return payload without lines which are failed with configured partial failure rate and failed lines
+//return payload as is if there is no fault for destination name
+func (i *Injector) Split(destinationName string, payload []byte) ([]byte, [][]byte) {
+	fault, ok := i.get(destinationName)
+	if !ok || fault.PartialFailureRate == 0 {
+		return payload, nil
+	}
+
+	var kept [][]byte
+	var failed [][]byte
+	for _, line := range bytes.Split(payload, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		if i.random() < fault.PartialFailureRate {
+			failed = append(failed, line)
+		} else {
+			kept = append(kept, line)
+		}
+	}
+
+	return bytes.Join(kept, []byte("\n")), failed
+}
+
+//ErrorMessage return configured error message of destination's fault
+func (i *Injector) ErrorMessage(destinationName string) string {
+	fault, ok := i.get(destinationName)
+	if !ok {
+		return defaultErrorMessage
+	}
+	return fault.ErrorMessage
+}
+
+func (i *Injector) get(destinationName string) (Fault, bool) {
+	i.RLock()
+	defer i.RUnlock()
+
+	if !i.enabled {
+		return Fault{}, false
+	}
+
+	fault, ok := i.faults[destinationName]
+	if !ok {
+		return Fault{}, false
+	}
+	return *fault, true
+}
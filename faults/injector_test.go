@@ -0,0 +1,131 @@
+package faults
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestInject(t *testing.T) {
+	tests := []struct {
+		name          string
+		enabled       bool
+		fault         *Fault
+		random        float64
+		expectedSleep time.Duration
+		expectedErr   string
+	}{
+		{
+			"disabled injector",
+			false,
+			nil,
+			0,
+			0,
+			"",
+		},
+		{
+			"no fault",
+			true,
+			nil,
+			0,
+			0,
+			"",
+		},
+		{
+			"latency only",
+			true,
+			&Fault{LatencyMs: 150},
+			0,
+			150 * time.Millisecond,
+			"",
+		},
+		{
+			"error rate hit",
+			true,
+			&Fault{ErrorRate: 0.5, ErrorMessage: "connection refused"},
+			0.3,
+			0,
+			"connection refused [destination: dest1]",
+		},
+		{
+			"error rate miss",
+			true,
+			&Fault{ErrorRate: 0.5},
+			0.7,
+			0,
+			"",
+		},
+		{
+			"default error message",
+			true,
+			&Fault{LatencyMs: 10, ErrorRate: 1},
+			0.99,
+			10 * time.Millisecond,
+			"Injected fault [destination: dest1]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var slept time.Duration
+			injector := NewInjector(true)
+			injector.random = func() float64 { return tt.random }
+			injector.sleep = func(d time.Duration) { slept += d }
+			if tt.fault != nil {
+				require.NoError(t, injector.Set("dest1", tt.fault))
+			}
+			injector.enabled = tt.enabled
+
+			err := injector.Inject("dest1")
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.expectedSleep, slept)
+			require.NoError(t, injector.Inject("dest2"))
+		})
+	}
+}
+
+func TestSplit(t *testing.T) {
+	injector := NewInjector(true)
+	randoms := []float64{0.1, 0.9, 0.4, 0.6}
+	injector.random = func() float64 {
+		r := randoms[0]
+		randoms = randoms[1:]
+		return r
+	}
+	payload := []byte("{\"a\":1}\n{\"a\":2}\n\n{\"a\":3}\n{\"a\":4}\n")
+
+	kept, failed := injector.Split("dest1", payload)
+	require.Equal(t, payload, kept, "Payload without fault must be unchanged")
+	require.Empty(t, failed)
+
+	require.NoError(t, injector.Set("dest1", &Fault{PartialFailureRate: 0.5}))
+	kept, failed = injector.Split("dest1", payload)
+	require.Equal(t, "{\"a\":2}\n{\"a\":4}", string(kept))
+	require.Equal(t, [][]byte{[]byte("{\"a\":1}"), []byte("{\"a\":3}")}, failed)
+}
+
+func TestSetAndRemove(t *testing.T) {
+	injector := NewInjector(false)
+	require.Equal(t, ErrDisabled, injector.Set("dest1", &Fault{ErrorRate: 0.1}))
+
+	injector = NewInjector(true)
+	require.EqualError(t, injector.Set("dest1", &Fault{ErrorRate: 1.5}), "error_rate must be in [0..1]")
+	require.EqualError(t, injector.Set("dest1", &Fault{PartialFailureRate: -1}), "partial_failure_rate must be in [0..1]")
+	require.EqualError(t, injector.Set("dest1", &Fault{LatencyMs: -1}), "latency_ms must be >= 0")
+
+	require.NoError(t, injector.Set("dest1", &Fault{ErrorRate: 0.1}))
+	require.NoError(t, injector.Set("dest2", &Fault{LatencyMs: 100}))
+	require.Equal(t, map[string]Fault{
+		"dest1": {ErrorRate: 0.1, ErrorMessage: defaultErrorMessage},
+		"dest2": {LatencyMs: 100, ErrorMessage: defaultErrorMessage},
+	}, injector.Faults())
+
+	injector.Remove("dest1")
+	require.Equal(t, 1, len(injector.Faults()))
+
+	injector.Remove("")
+	require.Empty(t, injector.Faults())
+}
@@ -0,0 +1,419 @@
+package filters
+
+import (
+	"fmt"
+	"github.com/jitsucom/eventnative/jsonutils"
+	"strconv"
+	"strings"
+)
+
+//Filter is a parsed boolean expression which is evaluated against every event
+//e.g. event_type in ["pageview", "click"] && user.anonymous_id != null
+//
+//Grammar (operators precedence from the lowest):
+//  expression := or
+//  or         := and ('||' and)*
+//  and        := unary ('&&' unary)*
+//  unary      := '!' unary | comparison
+//  comparison := operand (('==' | '!=' | '<' | '<=' | '>' | '>=') operand | 'not'? 'in' operand)?
+//  operand    := field | string | number | true | false | null | list | '(' expression ')'
+//  list       := '[' (operand (',' operand)*)? ']'
+type Filter struct {
+	expression string
+	root       node
+}
+
+//Parse return Filter from expression or error if expression is malformed
+func Parse(expression string) (*Filter, error) {
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing filter expression [%s]: %v", expression, err)
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.parseExpression()
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing filter expression [%s]: %v", expression, err)
+	}
+
+	if next := p.peek(); next.typ != tokenEOF {
+		return nil, fmt.Errorf("Error parsing filter expression [%s]: unexpected %s", expression, next)
+	}
+
+	return &Filter{expression: expression, root: root}, nil
+}
+
+//Match return true if object satisfies the filter expression
+func (f *Filter) Match(object map[string]interface{}) (bool, error) {
+	result, err := f.root.eval(object)
+	if err != nil {
+		return false, fmt.Errorf("Error evaluating filter [%s]: %v", f.expression, err)
+	}
+
+	b, ok := result.(bool)
+	if !ok {
+		return false, fmt.Errorf("Error evaluating filter [%s]: result isn't boolean: %v", f.expression, result)
+	}
+
+	return b, nil
+}
+
+func (f *Filter) String() string {
+	return f.expression
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.typ != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) expect(typ tokenType) error {
+	if t := p.next(); t.typ != typ {
+		return fmt.Errorf("expected %s but got %s", tokenNames[typ], t)
+	}
+	return nil
+}
+
+func (p *parser) parseExpression() (node, error) {
+	return p.parseOr()
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().typ == tokenOr {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &orNode{left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().typ == tokenAnd {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &andNode{left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.peek().typ == tokenBang {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand: operand}, nil
+	}
+
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	switch operator := p.peek(); operator.typ {
+	case tokenEq, tokenNeq, tokenLt, tokenLte, tokenGt, tokenGte:
+		p.next()
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return &compareNode{left: left, right: right, operator: operator.typ}, nil
+	case tokenIn:
+		p.next()
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return &inNode{left: left, right: right}, nil
+	case tokenNot:
+		p.next()
+		if err := p.expect(tokenIn); err != nil {
+			return nil, err
+		}
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand: &inNode{left: left, right: right}}, nil
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseOperand() (node, error) {
+	t := p.next()
+	switch t.typ {
+	case tokenField:
+		return newFieldNode(t.value), nil
+	case tokenString:
+		return &literalNode{value: t.value}, nil
+	case tokenNumber:
+		number, err := strconv.ParseFloat(t.value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed %s: %v", t, err)
+		}
+		return &literalNode{value: number}, nil
+	case tokenTrue:
+		return &literalNode{value: true}, nil
+	case tokenFalse:
+		return &literalNode{value: false}, nil
+	case tokenNull:
+		return &literalNode{value: nil}, nil
+	case tokenLParen:
+		expression, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokenRParen); err != nil {
+			return nil, err
+		}
+		return expression, nil
+	case tokenLBracket:
+		return p.parseList()
+	default:
+		return nil, fmt.Errorf("unexpected %s", t)
+	}
+}
+
+//parseList parse list after '['
+func (p *parser) parseList() (node, error) {
+	list := &listNode{}
+	if p.peek().typ == tokenRBracket {
+		p.next()
+		return list, nil
+	}
+
+	for {
+		element, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		list.elements = append(list.elements, element)
+
+		t := p.next()
+		switch t.typ {
+		case tokenComma:
+			continue
+		case tokenRBracket:
+			return list, nil
+		default:
+			return nil, fmt.Errorf("expected , or ] but got %s", t)
+		}
+	}
+}
+
+type node interface {
+	eval(object map[string]interface{}) (interface{}, error)
+}
+
+type literalNode struct {
+	value interface{}
+}
+
+func (ln *literalNode) eval(object map[string]interface{}) (interface{}, error) {
+	return ln.value, nil
+}
+
+type listNode struct {
+	elements []node
+}
+
+func (ln *listNode) eval(object map[string]interface{}) (interface{}, error) {
+	values := make([]interface{}, 0, len(ln.elements))
+	for _, element := range ln.elements {
+		value, err := element.eval(object)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+//fieldNode return field value or nil if field doesn't exist
+type fieldNode struct {
+	path *jsonutils.JsonPath
+}
+
+//newFieldNode accept dot separated path (user.id) or json path (/user/id)
+func newFieldNode(field string) *fieldNode {
+	if !strings.HasPrefix(field, "/") {
+		field = strings.ReplaceAll(field, ".", "/")
+	}
+	return &fieldNode{path: jsonutils.NewJsonPath(field)}
+}
+
+func (fn *fieldNode) eval(object map[string]interface{}) (interface{}, error) {
+	value, _ := fn.path.Get(object)
+	return value, nil
+}
+
+type andNode struct {
+	left  node
+	right node
+}
+
+func (an *andNode) eval(object map[string]interface{}) (interface{}, error) {
+	left, err := evalBool(an.left, object)
+	if err != nil {
+		return nil, err
+	}
+	if !left {
+		return false, nil
+	}
+
+	return evalBool(an.right, object)
+}
+
+type orNode struct {
+	left  node
+	right node
+}
+
+func (on *orNode) eval(object map[string]interface{}) (interface{}, error) {
+	left, err := evalBool(on.left, object)
+	if err != nil {
+		return nil, err
+	}
+	if left {
+		return true, nil
+	}
+
+	return evalBool(on.right, object)
+}
+
+type notNode struct {
+	operand node
+}
+
+func (nn *notNode) eval(object map[string]interface{}) (interface{}, error) {
+	value, err := evalBool(nn.operand, object)
+	if err != nil {
+		return nil, err
+	}
+	return !value, nil
+}
+
+type compareNode struct {
+	left     node
+	right    node
+	operator tokenType
+}
+
+func (cn *compareNode) eval(object map[string]interface{}) (interface{}, error) {
+	left, err := cn.left.eval(object)
+	if err != nil {
+		return nil, err
+	}
+	right, err := cn.right.eval(object)
+	if err != nil {
+		return nil, err
+	}
+
+	switch cn.operator {
+	case tokenEq:
+		return equal(left, right), nil
+	case tokenNeq:
+		return !equal(left, right), nil
+	}
+
+	//values of different types aren't ordered: comparison is false
+	result, ok := compare(left, right)
+	if !ok {
+		return false, nil
+	}
+
+	switch cn.operator {
+	case tokenLt:
+		return result < 0, nil
+	case tokenLte:
+		return result <= 0, nil
+	case tokenGt:
+		return result > 0, nil
+	default:
+		return result >= 0, nil
+	}
+}
+
+//inNode return true if left value equals one of right list elements
+type inNode struct {
+	left  node
+	right node
+}
+
+func (in *inNode) eval(object map[string]interface{}) (interface{}, error) {
+	left, err := in.left.eval(object)
+	if err != nil {
+		return nil, err
+	}
+	right, err := in.right.eval(object)
+	if err != nil {
+		return nil, err
+	}
+
+	//missing field isn't in any list
+	if right == nil {
+		return false, nil
+	}
+
+	list, ok := right.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("right operand of 'in' must be a list: %v", right)
+	}
+
+	for _, element := range list {
+		if equal(left, element) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func evalBool(n node, object map[string]interface{}) (bool, error) {
+	value, err := n.eval(object)
+	if err != nil {
+		return false, err
+	}
+
+	b, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("value [%v] isn't boolean", value)
+	}
+	return b, nil
+}
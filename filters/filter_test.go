@@ -0,0 +1,161 @@
+package filters
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		expression  string
+		expectedErr string
+	}{
+		{
+			"Empty expression",
+			"",
+			"Error parsing filter expression []: unexpected end of expression at position 0",
+		},
+		{
+			"Unclosed string",
+			`event_type == "pageview`,
+			"Error parsing filter expression [event_type == \"pageview]: Unclosed string at position 14",
+		},
+		{
+			"Unknown symbol",
+			`event_type # "pageview"`,
+			"Error parsing filter expression [event_type # \"pageview\"]: Unexpected symbol [#] at position 11",
+		},
+		{
+			"Missing operand",
+			`event_type == `,
+			"Error parsing filter expression [event_type == ]: unexpected end of expression at position 14",
+		},
+		{
+			"Unclosed list",
+			`event_type in ["a", "b"`,
+			"Error parsing filter expression [event_type in [\"a\", \"b\"]: expected , or ] but got end of expression at position 23",
+		},
+		{
+			"Unclosed parenthesis",
+			`(event_type == "a"`,
+			"Error parsing filter expression [(event_type == \"a\"]: expected ) but got end of expression at position 18",
+		},
+		{
+			"Not without in",
+			`event_type not "a"`,
+			"Error parsing filter expression [event_type not \"a\"]: expected in but got string [a] at position 15",
+		},
+		{
+			"Extra tokens",
+			`event_type == "a" "b"`,
+			"Error parsing filter expression [event_type == \"a\" \"b\"]: unexpected string [b] at position 18",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.expression)
+			require.EqualError(t, err, tt.expectedErr)
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	object := map[string]interface{}{
+		"event_type": "pageview",
+		"count":      json.Number("10"),
+		"amount":     12.5,
+		"is_bot":     false,
+		"tags":       []interface{}{"vip", "beta"},
+		"_timestamp": "2020-08-02T18:23:58.057807Z",
+		"user": map[string]interface{}{
+			"anonymous_id": "abc",
+			"id":           nil,
+		},
+	}
+
+	tests := []struct {
+		name       string
+		expression string
+		expected   bool
+	}{
+		{"Equal string", `event_type == "pageview"`, true},
+		{"Single quotes", `event_type == 'pageview'`, true},
+		{"Not equal string", `event_type != "pageview"`, false},
+		{"Nested field", `user.anonymous_id == "abc"`, true},
+		{"Json path field", `/user/anonymous_id == "abc"`, true},
+		{"Not null", `user.anonymous_id != null`, true},
+		{"Null value", `user.id == null`, true},
+		{"Missing field is null", `user.email == null`, true},
+		{"And true", `event_type == "pageview" && user.anonymous_id != null`, true},
+		{"And false", `event_type == "pageview" && user.anonymous_id == null`, false},
+		{"Or", `event_type == "click" || user.anonymous_id == "abc"`, true},
+		{"Not", `!(event_type == "click")`, true},
+		{"Precedence", `event_type == "click" && count == 1 || amount > 10`, true},
+		{"Parentheses", `event_type == "click" && (count == 1 || amount > 10)`, false},
+		{"In list", `event_type in ["pageview", "click"]`, true},
+		{"Not in list", `event_type not in ["pageview", "click"]`, false},
+		{"In empty list", `event_type in []`, false},
+		{"In number list", `count in [1, 10]`, true},
+		{"In array field", `"vip" in tags`, true},
+		{"In missing field", `"vip" in user.tags`, false},
+		{"Json number equals number", `count == 10`, true},
+		{"Json number equals float", `count == 10.0`, true},
+		{"Number isn't equal to string", `count == "10"`, false},
+		{"Greater", `count > 9`, true},
+		{"Greater or equal", `count >= 10`, true},
+		{"Less float", `amount < 12.6`, true},
+		{"Less or equal negative", `amount <= -1`, false},
+		{"Exponent", `amount < 1.3e1`, true},
+		{"String comparison", `_timestamp >= "2020-08-01"`, true},
+		{"Different types aren't ordered", `event_type > 1`, false},
+		{"Boolean", `is_bot == false`, true},
+		{"Boolean field", `!is_bot`, true},
+		{"Boolean isn't equal to string", `is_bot == "false"`, false},
+		{"Missing field comparison", `user.age > 18`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := Parse(tt.expression)
+			require.NoError(t, err)
+
+			actual, err := filter.Match(object)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestMatchErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		expression  string
+		expectedErr string
+	}{
+		{
+			"Not boolean result",
+			`event_type`,
+			"Error evaluating filter [event_type]: result isn't boolean: pageview",
+		},
+		{
+			"Not boolean operand",
+			`event_type && true`,
+			"Error evaluating filter [event_type && true]: value [pageview] isn't boolean",
+		},
+		{
+			"In not list",
+			`"a" in event_type`,
+			"Error evaluating filter [\"a\" in event_type]: right operand of 'in' must be a list: pageview",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := Parse(tt.expression)
+			require.NoError(t, err)
+
+			_, err = filter.Match(map[string]interface{}{"event_type": "pageview"})
+			require.EqualError(t, err, tt.expectedErr)
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package filters
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenType int
+
+const (
+	tokenEOF tokenType = iota
+	tokenField
+	tokenString
+	tokenNumber
+	tokenNull
+	tokenTrue
+	tokenFalse
+	tokenIn
+	tokenNot
+	tokenEq
+	tokenNeq
+	tokenLt
+	tokenLte
+	tokenGt
+	tokenGte
+	tokenAnd
+	tokenOr
+	tokenBang
+	tokenLParen
+	tokenRParen
+	tokenLBracket
+	tokenRBracket
+	tokenComma
+)
+
+var tokenNames = map[tokenType]string{
+	tokenEOF:      "end of expression",
+	tokenField:    "field",
+	tokenString:   "string",
+	tokenNumber:   "number",
+	tokenNull:     "null",
+	tokenTrue:     "true",
+	tokenFalse:    "false",
+	tokenIn:       "in",
+	tokenNot:      "not",
+	tokenEq:       "==",
+	tokenNeq:      "!=",
+	tokenLt:       "<",
+	tokenLte:      "<=",
+	tokenGt:       ">",
+	tokenGte:      ">=",
+	tokenAnd:      "&&",
+	tokenOr:       "||",
+	tokenBang:     "!",
+	tokenLParen:   "(",
+	tokenRParen:   ")",
+	tokenLBracket: "[",
+	tokenRBracket: "]",
+	tokenComma:    ",",
+}
+
+type token struct {
+	typ   tokenType
+	value string
+	pos   int
+}
+
+func (t token) String() string {
+	if t.value != "" {
+		return fmt.Sprintf("%s [%s] at position %d", tokenNames[t.typ], t.value, t.pos)
+	}
+	return fmt.Sprintf("%s at position %d", tokenNames[t.typ], t.pos)
+}
+
+//operators ordered by length: longest first
+var operators = []struct {
+	value string
+	typ   tokenType
+}{
+	{"==", tokenEq},
+	{"!=", tokenNeq},
+	{"<=", tokenLte},
+	{">=", tokenGte},
+	{"&&", tokenAnd},
+	{"||", tokenOr},
+	{"<", tokenLt},
+	{">", tokenGt},
+	{"!", tokenBang},
+	{"(", tokenLParen},
+	{")", tokenRParen},
+	{"[", tokenLBracket},
+	{"]", tokenRBracket},
+	{",", tokenComma},
+}
+
+var keywords = map[string]tokenType{
+	"null":  tokenNull,
+	"true":  tokenTrue,
+	"false": tokenFalse,
+	"in":    tokenIn,
+	"not":   tokenNot,
+}
+
+//tokenize split expression into tokens
+//field is a dot separated path (user.anonymous_id) or json path (/user/anonymous_id)
+//string is a single or double quoted value
+//number starts with digit or minus sign (-1.5e3)
+func tokenize(expression string) ([]token, error) {
+	var tokens []token
+	runes := []rune(expression)
+	pos := 0
+	for pos < len(runes) {
+		r := runes[pos]
+		if unicode.IsSpace(r) {
+			pos++
+			continue
+		}
+
+		if r == '"' || r == '\'' {
+			value, end, err := readString(runes, pos)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{typ: tokenString, value: value, pos: pos})
+			pos = end
+			continue
+		}
+
+		if unicode.IsDigit(r) || (r == '-' && pos+1 < len(runes) && unicode.IsDigit(runes[pos+1])) {
+			start := pos
+			pos++
+			for pos < len(runes) && isNumberRune(runes[pos], runes[pos-1]) {
+				pos++
+			}
+			tokens = append(tokens, token{typ: tokenNumber, value: string(runes[start:pos]), pos: start})
+			continue
+		}
+
+		if isFieldRune(r) {
+			start := pos
+			for pos < len(runes) && isFieldRune(runes[pos]) {
+				pos++
+			}
+			word := string(runes[start:pos])
+			if typ, ok := keywords[strings.ToLower(word)]; ok {
+				tokens = append(tokens, token{typ: typ, pos: start})
+			} else {
+				tokens = append(tokens, token{typ: tokenField, value: word, pos: start})
+			}
+			continue
+		}
+
+		matched := false
+		for _, operator := range operators {
+			if strings.HasPrefix(string(runes[pos:]), operator.value) {
+				tokens = append(tokens, token{typ: operator.typ, pos: pos})
+				pos += len([]rune(operator.value))
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("Unexpected symbol [%c] at position %d", r, pos)
+		}
+	}
+
+	return append(tokens, token{typ: tokenEOF, pos: pos}), nil
+}
+
+//readString return unquoted string value and position after closing quote
+func readString(runes []rune, start int) (string, int, error) {
+	quote := runes[start]
+	var sb strings.Builder
+	for pos := start + 1; pos < len(runes); pos++ {
+		r := runes[pos]
+		if r == '\\' && pos+1 < len(runes) {
+			pos++
+			sb.WriteRune(runes[pos])
+			continue
+		}
+		if r == quote {
+			return sb.String(), pos + 1, nil
+		}
+		sb.WriteRune(r)
+	}
+
+	return "", 0, fmt.Errorf("Unclosed string at position %d", start)
+}
+
+//isNumberRune return true if r can be a part of number literal: digits, dot and exponent with sign
+func isNumberRune(r, prev rune) bool {
+	return unicode.IsDigit(r) || r == '.' || r == 'e' || r == 'E' || ((r == '-' || r == '+') && (prev == 'e' || prev == 'E'))
+}
+
+func isFieldRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '.' || r == '/'
+}
@@ -0,0 +1,86 @@
+package filters
+
+import (
+	"encoding/json"
+	"github.com/jitsucom/eventnative/timestamp"
+	"reflect"
+	"strings"
+	"time"
+)
+
+//equal compare values with type awareness:
+//nil equals only nil, numbers are compared as float64 (json.Number as well), strings and booleans by value
+//values of different types aren't equal
+func equal(left, right interface{}) bool {
+	if left == nil || right == nil {
+		return left == nil && right == nil
+	}
+
+	if result, ok := compare(left, right); ok {
+		return result == 0
+	}
+
+	leftBool, leftOk := left.(bool)
+	rightBool, rightOk := right.(bool)
+	if leftOk || rightOk {
+		return leftOk && rightOk && leftBool == rightBool
+	}
+
+	return reflect.DeepEqual(left, right)
+}
+
+//compare return -1, 0, 1 and true if values are comparable: both numbers or both strings
+//time.Time is compared as string in timestamp.Layout
+func compare(left, right interface{}) (int, bool) {
+	leftNumber, leftOk := toNumber(left)
+	rightNumber, rightOk := toNumber(right)
+	if leftOk && rightOk {
+		switch {
+		case leftNumber < rightNumber:
+			return -1, true
+		case leftNumber > rightNumber:
+			return 1, true
+		default:
+			return 0, true
+		}
+	}
+
+	leftString, leftOk := toString(left)
+	rightString, rightOk := toString(right)
+	if leftOk && rightOk {
+		return strings.Compare(leftString, rightString), true
+	}
+
+	return 0, false
+}
+
+func toNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+func toString(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case time.Time:
+		return v.UTC().Format(timestamp.Layout), true
+	default:
+		return "", false
+	}
+}
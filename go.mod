@@ -12,18 +12,22 @@ require (
 	github.com/coreos/etcd v3.3.13+incompatible
 	github.com/docker/go-connections v0.4.0
 	github.com/gin-gonic/gin v1.6.3
-	github.com/golang/protobuf v1.4.2 // indirect
+	github.com/golang/protobuf v1.4.2
 	github.com/gomodule/redigo v1.8.2
 	github.com/google/go-cmp v0.5.1 // indirect
 	github.com/google/go-github/v32 v32.1.0
 	github.com/google/martian v2.1.0+incompatible
 	github.com/google/uuid v1.1.2
 	github.com/gookit/color v1.3.1
+	github.com/gorilla/websocket v1.4.2
 	github.com/hashicorp/go-multierror v1.1.0
 	github.com/joncrlsn/dque v0.0.0-20200702023911-3e80e3146ce5
+	github.com/json-iterator/go v1.1.9
+	github.com/klauspost/compress v1.10.1
 	github.com/lib/pq v1.8.0
 	github.com/mailru/easyjson v0.7.6
 	github.com/mailru/go-clickhouse v1.3.0
+	github.com/minio/simdjson-go v0.1.5
 	github.com/oschwald/geoip2-golang v1.4.0
 	github.com/panjf2000/ants/v2 v2.4.3
 	github.com/prometheus/client_golang v0.9.3
@@ -43,6 +47,7 @@ require (
 	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect
 	google.golang.org/api v0.17.0
 	google.golang.org/appengine v1.6.6 // indirect
+	google.golang.org/grpc v1.29.1
 	gopkg.in/natefinch/lumberjack.v2 v2.0.0
 	honnef.co/go/tools v0.0.1-2020.1.4 // indirect
 )
@@ -0,0 +1,78 @@
+package grpcapi
+
+import (
+	"github.com/golang/protobuf/proto"
+)
+
+//Event and EventAck are eventnative.proto messages. They are marshalled by protobuf struct tags
+
+type Event struct {
+	Payload   []byte `protobuf:"bytes,1,opt,name=payload,proto3" json:"payload,omitempty"`
+	MessageId int64  `protobuf:"varint,2,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
+}
+
+func (m *Event) Reset()         { *m = Event{} }
+func (m *Event) String() string { return proto.CompactTextString(m) }
+func (*Event) ProtoMessage()    {}
+
+type EventAck struct {
+	MessageId int64  `protobuf:"varint,1,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
+	EventId   string `protobuf:"bytes,2,opt,name=event_id,json=eventId,proto3" json:"event_id,omitempty"`
+	Status    string `protobuf:"bytes,3,opt,name=status,proto3" json:"status,omitempty"`
+	Error     string `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
+	Warning   string `protobuf:"bytes,5,opt,name=warning,proto3" json:"warning,omitempty"`
+}
+
+func (m *EventAck) Reset()         { *m = EventAck{} }
+func (m *EventAck) String() string { return proto.CompactTextString(m) }
+func (*EventAck) ProtoMessage()    {}
+
+//ProcessRequest, ProcessBatchRequest, ProcessResult, ProcessBatchResponse and Column are eventnative.proto Processor messages
+
+type ProcessRequest struct {
+	DestinationId string `protobuf:"bytes,1,opt,name=destination_id,json=destinationId,proto3" json:"destination_id,omitempty"`
+	Payload       []byte `protobuf:"bytes,2,opt,name=payload,proto3" json:"payload,omitempty"`
+}
+
+func (m *ProcessRequest) Reset()         { *m = ProcessRequest{} }
+func (m *ProcessRequest) String() string { return proto.CompactTextString(m) }
+func (*ProcessRequest) ProtoMessage()    {}
+
+type ProcessBatchRequest struct {
+	DestinationId string   `protobuf:"bytes,1,opt,name=destination_id,json=destinationId,proto3" json:"destination_id,omitempty"`
+	Payloads      [][]byte `protobuf:"bytes,2,rep,name=payloads,proto3" json:"payloads,omitempty"`
+}
+
+func (m *ProcessBatchRequest) Reset()         { *m = ProcessBatchRequest{} }
+func (m *ProcessBatchRequest) String() string { return proto.CompactTextString(m) }
+func (*ProcessBatchRequest) ProtoMessage()    {}
+
+type Column struct {
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Type string `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+}
+
+func (m *Column) Reset()         { *m = Column{} }
+func (m *Column) String() string { return proto.CompactTextString(m) }
+func (*Column) ProtoMessage()    {}
+
+type ProcessResult struct {
+	EventId string    `protobuf:"bytes,1,opt,name=event_id,json=eventId,proto3" json:"event_id,omitempty"`
+	Table   string    `protobuf:"bytes,2,opt,name=table,proto3" json:"table,omitempty"`
+	Object  []byte    `protobuf:"bytes,3,opt,name=object,proto3" json:"object,omitempty"`
+	Columns []*Column `protobuf:"bytes,4,rep,name=columns,proto3" json:"columns,omitempty"`
+	Skipped bool      `protobuf:"varint,5,opt,name=skipped,proto3" json:"skipped,omitempty"`
+	Error   string    `protobuf:"bytes,6,opt,name=error,proto3" json:"error,omitempty"`
+}
+
+func (m *ProcessResult) Reset()         { *m = ProcessResult{} }
+func (m *ProcessResult) String() string { return proto.CompactTextString(m) }
+func (*ProcessResult) ProtoMessage()    {}
+
+type ProcessBatchResponse struct {
+	Results []*ProcessResult `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+}
+
+func (m *ProcessBatchResponse) Reset()         { *m = ProcessBatchResponse{} }
+func (m *ProcessBatchResponse) String() string { return proto.CompactTextString(m) }
+func (*ProcessBatchResponse) ProtoMessage()    {}
@@ -0,0 +1,151 @@
+package grpcapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/parsers"
+	"github.com/jitsucom/eventnative/safego"
+	"github.com/jitsucom/eventnative/schema"
+	"github.com/jitsucom/eventnative/timestamp"
+	"github.com/jitsucom/eventnative/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net"
+	"sort"
+)
+
+const DefaultProcessorPort = 3051
+
+//Processor is a gRPC server of eventnative.Processor service (processor service mode): events are processed
+//by destinations processors (mapping, enrichment rules, table name template, filter, sampling, typing) and returned to clients.
+//Nothing is stored, so the service is stateless and might be scaled horizontally
+type Processor struct {
+	//destination id -> compiled processor
+	processors           map[string]*schema.Processor
+	isAllowedOriginsFunc func(string) ([]string, bool)
+
+	listener net.Listener
+	server   *grpc.Server
+}
+
+//NewProcessor return Processor which listens config port. Tokens are checked with isAllowedOriginsFunc on every RPC
+func NewProcessor(config Config, processors map[string]*schema.Processor, isAllowedOriginsFunc func(string) ([]string, bool)) (*Processor, error) {
+	listener, server, err := listen(config)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Processor{
+		processors:           processors,
+		isAllowedOriginsFunc: isAllowedOriginsFunc,
+		listener:             listener,
+		server:               server,
+	}
+	RegisterProcessorServer(p.server, p)
+
+	return p, nil
+}
+
+//Start serve gRPC requests in a separate goroutine
+func (p *Processor) Start() {
+	logging.Infof("Starting gRPC processor server on %s with %d destination(s)", p.listener.Addr(), len(p.processors))
+	safego.RunWithRestart(func() {
+		if err := p.server.Serve(p.listener); err != nil && err != grpc.ErrServerStopped {
+			logging.Errorf("gRPC processor server error: %v", err)
+		}
+	})
+}
+
+//ProcessFact process one event. Malformed events and processing errors are returned as InvalidArgument status
+func (p *Processor) ProcessFact(ctx context.Context, request *ProcessRequest) (*ProcessResult, error) {
+	processor, err := p.processor(ctx, request.DestinationId)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := process(processor, request.Payload)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return result, nil
+}
+
+//ProcessBatch process all events. Processing errors are written into results and don't break the batch
+func (p *Processor) ProcessBatch(ctx context.Context, request *ProcessBatchRequest) (*ProcessBatchResponse, error) {
+	processor, err := p.processor(ctx, request.DestinationId)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &ProcessBatchResponse{Results: make([]*ProcessResult, 0, len(request.Payloads))}
+	for _, payload := range request.Payloads {
+		result, err := process(processor, payload)
+		if err != nil {
+			result.Error = err.Error()
+		}
+		response.Results = append(response.Results, result)
+	}
+	return response, nil
+}
+
+//Close stop the server gracefully: in-flight RPCs are finished
+func (p *Processor) Close() error {
+	p.server.GracefulStop()
+	return nil
+}
+
+//processor return the destination processor if the request is authorized
+func (p *Processor) processor(ctx context.Context, destinationId string) (*schema.Processor, error) {
+	if _, err := authorize(ctx, p.isAllowedOriginsFunc); err != nil {
+		return nil, err
+	}
+
+	processor, ok := p.processors[destinationId]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "Destination [%s] isn't configured", destinationId)
+	}
+	return processor, nil
+}
+
+//process return the result (with event id if the payload has been parsed) and error if the event hasn't been processed.
+//Events are enriched with event id and _timestamp (if they aren't set) the same way as ingested ones
+func process(processor *schema.Processor, payload []byte) (*ProcessResult, error) {
+	result := &ProcessResult{}
+	object, err := parsers.ParseJson(payload)
+	if err != nil {
+		return result, fmt.Errorf("Failed to parse event payload: %v", err)
+	}
+
+	events.EnrichWithEventId(object, uuid.New())
+	result.EventId = events.ExtractEventId(object)
+	if _, ok := object[timestamp.Key]; !ok {
+		object[timestamp.Key] = timestamp.NowUTC()
+	}
+
+	table, processed, err := processor.ProcessFact(object)
+	if err != nil {
+		return result, fmt.Errorf("Error processing event: %v", err)
+	}
+	if !table.Exists() {
+		result.Skipped = true
+		return result, nil
+	}
+
+	b, err := json.Marshal(processed)
+	if err != nil {
+		return result, fmt.Errorf("Error marshalling processed event: %v", err)
+	}
+	result.Table = table.Name
+	result.Object = b
+	for name, column := range table.Columns {
+		result.Columns = append(result.Columns, &Column{Name: name, Type: column.GetType().String()})
+	}
+	sort.Slice(result.Columns, func(i, j int) bool {
+		return result.Columns[i].Name < result.Columns[j].Name
+	})
+	return result, nil
+}
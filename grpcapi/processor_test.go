@@ -0,0 +1,74 @@
+package grpcapi
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/golang/protobuf/proto"
+	"github.com/jitsucom/eventnative/schema"
+	"github.com/jitsucom/eventnative/storages"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+func TestProcessor(t *testing.T) {
+	processor, err := storages.NewProcessor("pg", storages.DestinationConfig{
+		Type:   "postgres",
+		Filter: `event_type != "ping"`,
+		DataLayout: &storages.DataLayout{
+			TableNameTemplate: "{{.event_type}}",
+			Mapping:           []string{"/user/email -> "},
+		},
+	})
+	require.NoError(t, err)
+
+	p := &Processor{
+		processors: map[string]*schema.Processor{"pg": processor},
+		isAllowedOriginsFunc: func(token string) ([]string, bool) {
+			return nil, token == "s2s"
+		},
+	}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-auth-token", "s2s"))
+
+	result, err := p.ProcessFact(ctx, &ProcessRequest{DestinationId: "pg",
+		Payload: []byte(`{"event_type": "signup", "eventn_ctx": {"event_id": "1"}, "user": {"id": 10, "email": "a@b.c"}, "_timestamp": "2020-08-02T21:23:58.000000Z"}`)})
+	require.NoError(t, err)
+	require.Equal(t, "1", result.EventId)
+	require.Equal(t, "signup", result.Table)
+	require.False(t, result.Skipped)
+	object := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(result.Object, &object))
+	require.Equal(t, "signup", object["event_type"])
+	require.Equal(t, float64(10), object["user_id"])
+	require.NotContains(t, object, "user_email", "Mapping must be applied")
+	require.Contains(t, result.Columns, &Column{Name: "user_id", Type: "INT64"})
+
+	//results keep batch order, errors don't break the batch
+	batch, err := p.ProcessBatch(ctx, &ProcessBatchRequest{DestinationId: "pg", Payloads: [][]byte{
+		[]byte(`{"event_type": "ping"}`),
+		[]byte(`{"event_type": `),
+		[]byte(`{"event_type": "click"}`),
+	}})
+	require.NoError(t, err)
+	require.Len(t, batch.Results, 3)
+	require.True(t, batch.Results[0].Skipped)
+	require.NotEmpty(t, batch.Results[0].EventId, "Event id must be generated")
+	require.Contains(t, batch.Results[1].Error, "Failed to parse event payload")
+	require.Equal(t, "click", batch.Results[2].Table)
+
+	//protobuf round trip
+	b, err := proto.Marshal(batch)
+	require.NoError(t, err)
+	decoded := &ProcessBatchResponse{}
+	require.NoError(t, proto.Unmarshal(b, decoded))
+	require.Equal(t, batch.Results[2].Object, decoded.Results[2].Object)
+	require.Equal(t, batch.Results[2].Columns, decoded.Results[2].Columns)
+
+	_, err = p.ProcessFact(ctx, &ProcessRequest{DestinationId: "unknown", Payload: []byte(`{}`)})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = p.ProcessFact(context.Background(), &ProcessRequest{DestinationId: "pg", Payload: []byte(`{}`)})
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+}
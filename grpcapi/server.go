@@ -0,0 +1,227 @@
+package grpcapi
+
+import (
+	"context"
+	"fmt"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/handlers"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/parsers"
+	"github.com/jitsucom/eventnative/safego"
+	"github.com/jitsucom/eventnative/trackingplan"
+	"github.com/jitsucom/eventnative/validation"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+)
+
+const (
+	defaultMaxMessageSizeKb = 1024
+
+	tokenMetadataKey         = "x-auth-token"
+	authorizationMetadataKey = "authorization"
+	bearerPrefix             = "Bearer "
+
+	ackStatusOk    = "ok"
+	ackStatusError = "error"
+)
+
+//Config is a gRPC ingestion server configuration
+type Config struct {
+	//listen port. 0 - gRPC server is disabled
+	Port             int `mapstructure:"port"`
+	MaxMessageSizeKb int `mapstructure:"max_message_size_kb"`
+}
+
+//Server is a gRPC server of eventnative.Ingestion service. Events are processed by the same
+//event handler (enrichment, validation, destinations) as HTTP /api/v1/s2s/event requests
+type Server struct {
+	eventHandler         *handlers.EventHandler
+	isAllowedOriginsFunc func(string) ([]string, bool)
+
+	listener net.Listener
+	server   *grpc.Server
+}
+
+//NewServer return Server which listens config port. Tokens are checked with isAllowedOriginsFunc on every RPC
+func NewServer(config Config, eventHandler *handlers.EventHandler, isAllowedOriginsFunc func(string) ([]string, bool)) (*Server, error) {
+	listener, server, err := listen(config)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		eventHandler:         eventHandler,
+		isAllowedOriginsFunc: isAllowedOriginsFunc,
+		listener:             listener,
+		server:               server,
+	}
+	RegisterIngestionServer(s.server, s)
+
+	return s, nil
+}
+
+//listen return listener of config port and gRPC server with configured max message size
+func listen(config Config) (net.Listener, *grpc.Server, error) {
+	maxMessageSizeKb := config.MaxMessageSizeKb
+	if maxMessageSizeKb <= 0 {
+		maxMessageSizeKb = defaultMaxMessageSizeKb
+	}
+
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", config.Port))
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error listening gRPC port %d: %v", config.Port, err)
+	}
+	return listener, grpc.NewServer(grpc.MaxRecvMsgSize(maxMessageSizeKb * 1024)), nil
+}
+
+//Start serve gRPC requests in a separate goroutine
+func (s *Server) Start() {
+	logging.Infof("Starting gRPC ingestion server on %s", s.listener.Addr())
+	safego.RunWithRestart(func() {
+		if err := s.server.Serve(s.listener); err != nil && err != grpc.ErrServerStopped {
+			logging.Errorf("gRPC ingestion server error: %v", err)
+		}
+	})
+}
+
+//SendEvent process one event. Processing errors are returned as gRPC statuses:
+//InvalidArgument - malformed or invalid event, ResourceExhausted - destinations queues are full
+func (s *Server) SendEvent(ctx context.Context, event *Event) (*EventAck, error) {
+	token, err := s.authorize(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ack, err := s.process(token, event, event.MessageId, requestFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return ack, nil
+}
+
+//SendEventStream process events until the client closes the stream. Every event is acknowledged:
+//processing errors are written into EventAck and don't break the stream
+func (s *Server) SendEventStream(stream IngestionSendEventStreamServer) error {
+	token, err := s.authorize(stream.Context())
+	if err != nil {
+		return err
+	}
+
+	request := requestFromContext(stream.Context())
+	var sequence int64
+	for {
+		event, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		sequence++
+		messageId := event.MessageId
+		if messageId == 0 {
+			messageId = sequence
+		}
+
+		ack, err := s.process(token, event, messageId, request)
+		if err != nil {
+			ack.Status = ackStatusError
+			ack.Error = status.Convert(err).Message()
+		}
+		if err := stream.Send(ack); err != nil {
+			return err
+		}
+	}
+}
+
+//Close stop the server gracefully: in-flight RPCs are finished
+func (s *Server) Close() error {
+	s.server.GracefulStop()
+	return nil
+}
+
+func (s *Server) authorize(ctx context.Context) (string, error) {
+	return authorize(ctx, s.isAllowedOriginsFunc)
+}
+
+//authorize return the metadata token or Unauthenticated status if it isn't a server token
+func authorize(ctx context.Context, isAllowedOriginsFunc func(string) ([]string, bool)) (string, error) {
+	token := extractToken(ctx)
+	if _, ok := isAllowedOriginsFunc(token); !ok {
+		return "", status.Error(codes.Unauthenticated, "The token isn't a server token. Please use s2s integration token")
+	}
+	return token, nil
+}
+
+//process return ack (with event id if the payload has been parsed) and gRPC status error if the event isn't processed
+func (s *Server) process(token string, event *Event, messageId int64, request *http.Request) (*EventAck, error) {
+	ack := &EventAck{MessageId: messageId, Status: ackStatusOk}
+	payload, err := parsers.ParseJson(event.Payload)
+	if err != nil {
+		return ack, status.Errorf(codes.InvalidArgument, "Failed to parse event payload: %v", err)
+	}
+
+	deprecation, err := s.eventHandler.ProcessEvent(token, payload, request)
+	ack.EventId = events.ExtractEventId(payload)
+	if deprecation != nil {
+		ack.Warning = deprecation.String()
+	}
+	if err != nil {
+		if _, ok := err.(*validation.Error); ok {
+			return ack, status.Errorf(codes.InvalidArgument, "Event doesn't match JSON Schema: %v", err)
+		}
+		if _, ok := err.(*trackingplan.Error); ok {
+			return ack, status.Errorf(codes.InvalidArgument, "Event doesn't conform to tracking plan: %v", err)
+		}
+		if err == events.ErrQueueFull {
+			return ack, status.Error(codes.ResourceExhausted, "Events queue is full. Please retry later")
+		}
+		logging.Error("Error processing gRPC event:", err)
+		return ack, status.Errorf(codes.InvalidArgument, "Error processing event: %v", err)
+	}
+
+	return ack, nil
+}
+
+//extractToken return token from x-auth-token or authorization (Bearer) metadata
+func extractToken(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if values := md.Get(tokenMetadataKey); len(values) > 0 && values[0] != "" {
+		return values[0]
+	}
+	if values := md.Get(authorizationMetadataKey); len(values) > 0 && strings.HasPrefix(values[0], bearerPrefix) {
+		return strings.TrimPrefix(values[0], bearerPrefix)
+	}
+	return ""
+}
+
+//requestFromContext return http request with metadata as headers and peer address as remote address
+//it is used by the event handler for ip (X-Real-IP, X-Forwarded-For) and client version extraction
+func requestFromContext(ctx context.Context) *http.Request {
+	request := &http.Request{Header: http.Header{}}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for key, values := range md {
+			if key == tokenMetadataKey || key == authorizationMetadataKey {
+				continue
+			}
+			for _, value := range values {
+				request.Header.Add(key, value)
+			}
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		request.RemoteAddr = p.Addr.String()
+	}
+	return request
+}
@@ -0,0 +1,39 @@
+package grpcapi
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+	"testing"
+)
+
+func TestExtractToken(t *testing.T) {
+	tests := []struct {
+		name     string
+		md       metadata.MD
+		expected string
+	}{
+		{"Without metadata", nil, ""},
+		{"X-Auth-Token", metadata.Pairs("x-auth-token", "token1", "authorization", "Bearer token2"), "token1"},
+		{"Bearer", metadata.Pairs("authorization", "Bearer token2"), "token2"},
+		{"Not bearer", metadata.Pairs("authorization", "Basic dXNlcg=="), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.md != nil {
+				ctx = metadata.NewIncomingContext(ctx, tt.md)
+			}
+			require.Equal(t, tt.expected, extractToken(ctx))
+		})
+	}
+}
+
+func TestRequestFromContext(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-auth-token", "token1", "x-real-ip", "10.10.10.10", "x-client-version", "2.1.0"))
+	request := requestFromContext(ctx)
+
+	require.Equal(t, "10.10.10.10", request.Header.Get("X-Real-IP"))
+	require.Equal(t, "2.1.0", request.Header.Get("X-Client-Version"))
+	require.Equal(t, "", request.Header.Get("X-Auth-Token"), "token mustn't be passed as a header")
+}
@@ -0,0 +1,233 @@
+package grpcapi
+
+import (
+	"context"
+	"google.golang.org/grpc"
+)
+
+const (
+	sendEventMethod       = "/eventnative.Ingestion/SendEvent"
+	sendEventStreamMethod = "/eventnative.Ingestion/SendEventStream"
+
+	processFactMethod  = "/eventnative.Processor/ProcessFact"
+	processBatchMethod = "/eventnative.Processor/ProcessBatch"
+)
+
+//IngestionServer is a server API of eventnative.Ingestion service
+type IngestionServer interface {
+	SendEvent(context.Context, *Event) (*EventAck, error)
+	SendEventStream(IngestionSendEventStreamServer) error
+}
+
+//IngestionSendEventStreamServer is a server side of SendEventStream bidirectional stream
+type IngestionSendEventStreamServer interface {
+	Send(*EventAck) error
+	Recv() (*Event, error)
+	grpc.ServerStream
+}
+
+//RegisterIngestionServer register eventnative.Ingestion service implementation in gRPC server
+func RegisterIngestionServer(s *grpc.Server, srv IngestionServer) {
+	s.RegisterService(&ingestionServiceDesc, srv)
+}
+
+var ingestionServiceDesc = grpc.ServiceDesc{
+	ServiceName: "eventnative.Ingestion",
+	HandlerType: (*IngestionServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SendEvent",
+			Handler:    sendEventHandler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "SendEventStream",
+			Handler:       sendEventStreamHandler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "grpcapi/eventnative.proto",
+}
+
+func sendEventHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := &Event{}
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IngestionServer).SendEvent(ctx, in)
+	}
+
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: sendEventMethod}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IngestionServer).SendEvent(ctx, req.(*Event))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func sendEventStreamHandler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(IngestionServer).SendEventStream(&sendEventStreamServer{stream})
+}
+
+type sendEventStreamServer struct {
+	grpc.ServerStream
+}
+
+func (s *sendEventStreamServer) Send(ack *EventAck) error {
+	return s.ServerStream.SendMsg(ack)
+}
+
+func (s *sendEventStreamServer) Recv() (*Event, error) {
+	event := &Event{}
+	if err := s.ServerStream.RecvMsg(event); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+//IngestionClient is a client API of eventnative.Ingestion service.
+//Token must be set in outgoing context metadata (x-auth-token)
+type IngestionClient interface {
+	SendEvent(ctx context.Context, in *Event, opts ...grpc.CallOption) (*EventAck, error)
+	SendEventStream(ctx context.Context, opts ...grpc.CallOption) (IngestionSendEventStreamClient, error)
+}
+
+//IngestionSendEventStreamClient is a client side of SendEventStream bidirectional stream
+type IngestionSendEventStreamClient interface {
+	Send(*Event) error
+	Recv() (*EventAck, error)
+	grpc.ClientStream
+}
+
+type ingestionClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewIngestionClient(cc grpc.ClientConnInterface) IngestionClient {
+	return &ingestionClient{cc: cc}
+}
+
+func (c *ingestionClient) SendEvent(ctx context.Context, in *Event, opts ...grpc.CallOption) (*EventAck, error) {
+	out := &EventAck{}
+	if err := c.cc.Invoke(ctx, sendEventMethod, in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ingestionClient) SendEventStream(ctx context.Context, opts ...grpc.CallOption) (IngestionSendEventStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &ingestionServiceDesc.Streams[0], sendEventStreamMethod, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &sendEventStreamClient{stream}, nil
+}
+
+type sendEventStreamClient struct {
+	grpc.ClientStream
+}
+
+func (c *sendEventStreamClient) Send(event *Event) error {
+	return c.ClientStream.SendMsg(event)
+}
+
+func (c *sendEventStreamClient) Recv() (*EventAck, error) {
+	ack := &EventAck{}
+	if err := c.ClientStream.RecvMsg(ack); err != nil {
+		return nil, err
+	}
+	return ack, nil
+}
+
+//ProcessorServer is a server API of eventnative.Processor service
+type ProcessorServer interface {
+	ProcessFact(context.Context, *ProcessRequest) (*ProcessResult, error)
+	ProcessBatch(context.Context, *ProcessBatchRequest) (*ProcessBatchResponse, error)
+}
+
+//RegisterProcessorServer register eventnative.Processor service implementation in gRPC server
+func RegisterProcessorServer(s *grpc.Server, srv ProcessorServer) {
+	s.RegisterService(&processorServiceDesc, srv)
+}
+
+var processorServiceDesc = grpc.ServiceDesc{
+	ServiceName: "eventnative.Processor",
+	HandlerType: (*ProcessorServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ProcessFact",
+			Handler:    processFactHandler,
+		},
+		{
+			MethodName: "ProcessBatch",
+			Handler:    processBatchHandler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "grpcapi/eventnative.proto",
+}
+
+func processFactHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := &ProcessRequest{}
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProcessorServer).ProcessFact(ctx, in)
+	}
+
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: processFactMethod}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProcessorServer).ProcessFact(ctx, req.(*ProcessRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func processBatchHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := &ProcessBatchRequest{}
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProcessorServer).ProcessBatch(ctx, in)
+	}
+
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: processBatchMethod}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProcessorServer).ProcessBatch(ctx, req.(*ProcessBatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+//ProcessorClient is a client API of eventnative.Processor service.
+//Token must be set in outgoing context metadata (x-auth-token)
+type ProcessorClient interface {
+	ProcessFact(ctx context.Context, in *ProcessRequest, opts ...grpc.CallOption) (*ProcessResult, error)
+	ProcessBatch(ctx context.Context, in *ProcessBatchRequest, opts ...grpc.CallOption) (*ProcessBatchResponse, error)
+}
+
+type processorClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewProcessorClient(cc grpc.ClientConnInterface) ProcessorClient {
+	return &processorClient{cc: cc}
+}
+
+func (c *processorClient) ProcessFact(ctx context.Context, in *ProcessRequest, opts ...grpc.CallOption) (*ProcessResult, error) {
+	out := &ProcessResult{}
+	if err := c.cc.Invoke(ctx, processFactMethod, in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *processorClient) ProcessBatch(ctx context.Context, in *ProcessBatchRequest, opts ...grpc.CallOption) (*ProcessBatchResponse, error) {
+	out := &ProcessBatchResponse{}
+	if err := c.cc.Invoke(ctx, processBatchMethod, in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/destinations"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/middleware"
+	"github.com/jitsucom/eventnative/storages"
+	"github.com/jitsucom/eventnative/uuid"
+	"github.com/jitsucom/eventnative/workspaces"
+	"net/http"
+)
+
+//AnnotationRequest is a correction of the stored event fields in the destination table
+type AnnotationRequest struct {
+	DestinationId string                 `json:"destination_id"`
+	Table         string                 `json:"table"`
+	EventId       string                 `json:"event_id"`
+	Fields        map[string]interface{} `json:"fields"`
+	Author        string                 `json:"author,omitempty"`
+	Comment       string                 `json:"comment,omitempty"`
+}
+
+type AnnotationResponse struct {
+	Id          string `json:"id"`
+	UpdatedRows int64  `json:"updated_rows"`
+}
+
+//AnnotationsHandler apply post-hoc corrections of stored events to SQL destinations (see storages.Annotator)
+type AnnotationsHandler struct {
+	destinationService *destinations.Service
+}
+
+func NewAnnotationsHandler(destinationService *destinations.Service) *AnnotationsHandler {
+	return &AnnotationsHandler{destinationService: destinationService}
+}
+
+//PostHandler apply the annotation and return its id with updated rows count
+func (ah *AnnotationsHandler) PostHandler(c *gin.Context) {
+	req := &AnnotationRequest{}
+	//numbers are kept as json.Number: they are converted into the column types
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(req); err != nil {
+		logging.Errorf("Error parsing annotation body: %v", err)
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Failed to parse body", Error: err.Error()})
+		return
+	}
+
+	if workspaceId := middleware.GetWorkspaceId(c); !workspaces.Owns(workspaceId, req.DestinationId) {
+		c.JSON(http.StatusForbidden, middleware.ErrorResponse{Message: fmt.Sprintf("Destination [%s] doesn't belong to workspace [%s]", req.DestinationId, workspaceId)})
+		return
+	}
+
+	storageProxy, ok := ah.destinationService.GetStorageById(req.DestinationId)
+	if !ok {
+		c.JSON(http.StatusNotFound, middleware.ErrorResponse{Message: fmt.Sprintf("Destination [%s] wasn't found", req.DestinationId)})
+		return
+	}
+	storage, ok := storageProxy.Get()
+	if !ok {
+		c.JSON(http.StatusServiceUnavailable, middleware.ErrorResponse{Message: fmt.Sprintf("Destination [%s] hasn't been initialized yet", req.DestinationId)})
+		return
+	}
+	annotator, ok := storage.(storages.Annotator)
+	if !ok {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: fmt.Sprintf("Destination [%s] of type [%s] doesn't support annotations", req.DestinationId, storage.Type())})
+		return
+	}
+
+	annotation := &storages.Annotation{
+		Id:      uuid.New(),
+		Table:   req.Table,
+		EventId: req.EventId,
+		Fields:  req.Fields,
+		Author:  req.Author,
+		Comment: req.Comment,
+	}
+	updated, err := annotator.Annotate(annotation)
+	if err != nil {
+		logging.Errorf("Error applying annotation of [%s] event into [%s] table of [%s]: %v", req.EventId, req.Table, req.DestinationId, err)
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Failed to apply annotation", Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, AnnotationResponse{Id: annotation.Id, UpdatedRows: updated})
+}
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"compress/gzip"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/acks"
+	"github.com/jitsucom/eventnative/appconfig"
+	"github.com/jitsucom/eventnative/clientversion"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/middleware"
+	"github.com/jitsucom/eventnative/parsers"
+	"github.com/jitsucom/eventnative/trackingplan"
+	"github.com/jitsucom/eventnative/validation"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+const (
+	DefaultBatchMaxBodyMb = 50
+	DefaultBatchMaxLines  = 10000
+
+	batchMaxLineSize = 1024 * 1024
+)
+
+//BatchLineStatus is a processing result of one batch line. Line is a line (or array element) index starting from 0
+//Failures and Violations are JSON Schema failures and tracking plan violations of rejected events
+//Retry is true if the event hasn't been processed because of the full queue and should be re-sent later
+type BatchLineStatus struct {
+	Line       int                       `json:"line"`
+	EventId    string                    `json:"event_id,omitempty"`
+	Status     string                    `json:"status"`
+	Error      string                    `json:"error,omitempty"`
+	Failures   []*validation.Failure     `json:"failures,omitempty"`
+	Violations []*trackingplan.Violation `json:"violations,omitempty"`
+	Retry      bool                      `json:"retry,omitempty"`
+}
+
+//BatchResponse is a batch endpoint response with per-line statuses
+//BatchId is set if the delivery acknowledgment is requested (see acks.Result)
+type BatchResponse struct {
+	Accepted int               `json:"accepted"`
+	Rejected int               `json:"rejected"`
+	Lines    []BatchLineStatus `json:"lines"`
+	BatchId  string            `json:"batch_id,omitempty"`
+}
+
+//BatchHandler accept NDJSON (one event per line) or JSON array of events in one request.
+//Body might be gzipped (Content-Encoding: gzip). All lines are validated and processed, the response has a status of every line
+//Delivery acknowledgment of accepted lines is requested with ack=true (the result is polled via AckHandler) or ack_url
+//(the result is POSTed when all lines have been stored into all destinations)
+type BatchHandler struct {
+	eventHandler *EventHandler
+	maxBodyBytes int64
+	maxLines     int
+}
+
+func NewBatchHandler(eventHandler *EventHandler, maxBodyMb, maxLines int) *BatchHandler {
+	if maxBodyMb <= 0 {
+		maxBodyMb = DefaultBatchMaxBodyMb
+	}
+	if maxLines <= 0 {
+		maxLines = DefaultBatchMaxLines
+	}
+	return &BatchHandler{eventHandler: eventHandler, maxBodyBytes: int64(maxBodyMb) * 1024 * 1024, maxLines: maxLines}
+}
+
+func (bh *BatchHandler) Handler(c *gin.Context) {
+	iface, ok := c.Get(middleware.TokenName)
+	if !ok {
+		logging.SystemError("Token wasn't found in context")
+		return
+	}
+	token := iface.(string)
+
+	var batch *acks.Batch
+	if ackUrl := c.Query("ack_url"); ackUrl != "" {
+		callback, err := acks.Instance.WebhookCallback(ackUrl)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Wrong ack_url", Error: err.Error()})
+			return
+		}
+		batch = acks.Instance.NewBatch(batchOwner(token), callback)
+	} else if c.Query("ack") == "true" {
+		batch = acks.Instance.NewBatch(batchOwner(token), nil)
+	}
+
+	body, err := bh.body(c.Request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Failed to read body", Error: err.Error()})
+		return
+	}
+	defer body.Close()
+
+	lines, err := parsers.ParseJsonLines(body, batchMaxLineSize, bh.maxLines)
+	if err != nil {
+		//nothing has been added
+		batch.Seal()
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Failed to parse batch", Error: err.Error()})
+		return
+	}
+
+	response := BatchResponse{Lines: make([]BatchLineStatus, 0, len(lines))}
+	var lastDeprecation *clientversion.Deprecation
+	for i, line := range lines {
+		status := BatchLineStatus{Line: i, Status: ackStatusOk}
+		if line.Err != nil {
+			status.Status = ackStatusError
+			status.Error = "Failed to parse event: " + line.Err.Error()
+		} else {
+			deprecation, err := bh.eventHandler.ProcessBatchEvent(token, line.Object, c.Request, batch)
+			if deprecation != nil {
+				lastDeprecation = deprecation
+			}
+			status.EventId = events.ExtractEventId(line.Object)
+			if err != nil {
+				status.Status = ackStatusError
+				status.Error = err.Error()
+				if validationErr, ok := err.(*validation.Error); ok {
+					status.Failures = validationErr.Failures
+				}
+				if trackingPlanErr, ok := err.(*trackingplan.Error); ok {
+					status.Violations = trackingPlanErr.Violations
+				}
+				status.Retry = err == events.ErrQueueFull
+			}
+		}
+
+		if status.Status == ackStatusOk {
+			response.Accepted++
+		} else {
+			response.Rejected++
+		}
+		response.Lines = append(response.Lines, status)
+	}
+
+	batch.Seal()
+	response.BatchId = batch.Id()
+
+	if lastDeprecation != nil {
+		writeDeprecationHeaders(c, lastDeprecation)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+//AckHandler return the delivery acknowledgment result of the batch which has been sent with the same token
+func (bh *BatchHandler) AckHandler(c *gin.Context) {
+	iface, ok := c.Get(middleware.TokenName)
+	if !ok {
+		logging.SystemError("Token wasn't found in context")
+		return
+	}
+
+	result, ok := acks.Instance.Result(c.Param("batch_id"))
+	if !ok || result.Owner != batchOwner(iface.(string)) {
+		c.JSON(http.StatusNotFound, middleware.ErrorResponse{Message: "Batch wasn't found"})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+//batchOwner return token id of the (signed) token
+func batchOwner(token string) string {
+	return appconfig.Instance.AuthorizationService.GetTokenId(appconfig.Instance.AuthorizationService.ResolveToken(token))
+}
+
+//body return request body reader (decompressed if Content-Encoding is gzip) limited by max body size
+func (bh *BatchHandler) body(r *http.Request) (io.ReadCloser, error) {
+	encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+	switch encoding {
+	case "", "identity":
+		return ioutil.NopCloser(&limitedReader{reader: r.Body, left: bh.maxBodyBytes}), nil
+	case "gzip":
+		gzipReader, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return nil, fmt.Errorf("Malformed gzip body: %v", err)
+		}
+		//decompressed size is limited (gzip bomb protection)
+		return &gzipBody{limitedReader: limitedReader{reader: gzipReader, left: bh.maxBodyBytes}, gzipReader: gzipReader}, nil
+	default:
+		return nil, fmt.Errorf("Unsupported Content-Encoding: %s. Supported: gzip", encoding)
+	}
+}
+
+//limitedReader return error (not EOF as io.LimitReader) if the body exceeds the limit
+type limitedReader struct {
+	reader io.Reader
+	left   int64
+}
+
+func (lr *limitedReader) Read(p []byte) (int, error) {
+	if lr.left <= 0 {
+		return 0, fmt.Errorf("Body exceeds max size")
+	}
+	if int64(len(p)) > lr.left {
+		p = p[:lr.left]
+	}
+	n, err := lr.reader.Read(p)
+	lr.left -= int64(n)
+	return n, err
+}
+
+type gzipBody struct {
+	limitedReader
+	gzipReader *gzip.Reader
+}
+
+func (gb *gzipBody) Close() error {
+	return gb.gzipReader.Close()
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+const (
+	//capabilities response format version: it is incremented on incompatible changes
+	capabilitiesVersion = 1
+	devVersion          = "dev"
+
+	ClientToken = "client"
+	ServerToken = "server"
+
+	JsonFormat            = "json"
+	NdjsonFormat          = "ndjson"
+	JsonArrayFormat       = "json_array"
+	WebSocketFormat       = "websocket"
+	SegmentFormat         = "segment"
+	GoogleAnalyticsFormat = "google_analytics"
+	GA4Format             = "ga4"
+
+	GzipCompression = "gzip"
+
+	//features which are always available
+	BatchAcksFeature    = "batch_acks"
+	BackpressureFeature = "backpressure"
+	SignedTokensFeature = "signed_tokens"
+
+	//configurable features
+	IdentityResolutionFeature   = "identity_resolution"
+	BotFilterFeature            = "bot_filter"
+	TrackingPlanFeature         = "tracking_plan"
+	ValidationFeature           = "validation"
+	AnonymousAggregationFeature = "anonymous_aggregation"
+	SessionizationFeature       = "sessionization"
+	ProcessingShardsFeature     = "processing_shards"
+	ClusteringFeature           = "clustering"
+	CircuitBreakersFeature      = "circuit_breakers"
+	GrpcFeature                 = "grpc"
+)
+
+//CapabilitiesEndpoint is an ingestion endpoint. Token is a kind of accepted API token: client (browser origins are checked)
+//or server (s2s). Segment and Google Analytics endpoints accept both kinds of tokens
+type CapabilitiesEndpoint struct {
+	Path         string   `json:"path"`
+	Methods      []string `json:"methods"`
+	Token        string   `json:"token,omitempty"`
+	Formats      []string `json:"formats"`
+	Compressions []string `json:"compressions,omitempty"`
+}
+
+//CapabilitiesLimits are ingestion limits. Batch limits are applied to decompressed bodies
+type CapabilitiesLimits struct {
+	MaxBatchBodyBytes int64 `json:"max_batch_body_bytes"`
+	MaxBatchLines     int   `json:"max_batch_lines"`
+	MaxBatchLineBytes int   `json:"max_batch_line_bytes"`
+}
+
+//CapabilitiesResponse is a server capabilities description. SDKs and edge workers negotiate batching, compression,
+//acknowledgments and retries with it. Features are all known features with enabled flags
+type CapabilitiesResponse struct {
+	CapabilitiesVersion int                     `json:"capabilities_version"`
+	Version             string                  `json:"version"`
+	Commit              string                  `json:"commit,omitempty"`
+	BuiltAt             string                  `json:"built_at,omitempty"`
+	Endpoints           []*CapabilitiesEndpoint `json:"endpoints"`
+	GrpcPort            int                     `json:"grpc_port,omitempty"`
+	Limits              CapabilitiesLimits      `json:"limits"`
+	Features            map[string]bool         `json:"features"`
+}
+
+//ingestionEndpoints are ingestion routes of main.go SetupRouter
+var ingestionEndpoints = []*CapabilitiesEndpoint{
+	{Path: "/api/v1/event", Methods: []string{http.MethodPost}, Token: ClientToken, Formats: []string{JsonFormat}},
+	{Path: "/api/v1/s2s/event", Methods: []string{http.MethodPost}, Token: ServerToken, Formats: []string{JsonFormat}},
+	{Path: "/api/v1/event/batch", Methods: []string{http.MethodPost}, Token: ClientToken, Formats: []string{NdjsonFormat, JsonArrayFormat}, Compressions: []string{GzipCompression}},
+	{Path: "/api/v1/s2s/event/batch", Methods: []string{http.MethodPost}, Token: ServerToken, Formats: []string{NdjsonFormat, JsonArrayFormat}, Compressions: []string{GzipCompression}},
+	{Path: "/api/v1/event/ws", Methods: []string{http.MethodGet}, Token: ClientToken, Formats: []string{WebSocketFormat}},
+	{Path: "/api/v1/s2s/event/ws", Methods: []string{http.MethodGet}, Token: ServerToken, Formats: []string{WebSocketFormat}},
+	{Path: "/v1/:type", Methods: []string{http.MethodPost}, Formats: []string{SegmentFormat}},
+	{Path: "/collect", Methods: []string{http.MethodGet, http.MethodPost}, Formats: []string{GoogleAnalyticsFormat}},
+	{Path: "/batch", Methods: []string{http.MethodPost}, Formats: []string{GoogleAnalyticsFormat}},
+	{Path: "/mp/collect", Methods: []string{http.MethodPost}, Formats: []string{GA4Format}},
+}
+
+//CapabilitiesHandler serves server capabilities. The response is built once: it depends only on the server configuration
+type CapabilitiesHandler struct {
+	response *CapabilitiesResponse
+}
+
+//NewCapabilitiesHandler return CapabilitiesHandler with batch handler limits and enabled flags of configurable features
+//grpcPort is 0 if gRPC API isn't enabled
+func NewCapabilitiesHandler(version, commit, builtAt string, batchHandler *BatchHandler, grpcPort int, features map[string]bool) *CapabilitiesHandler {
+	if version == "" {
+		version = devVersion
+	}
+
+	allFeatures := map[string]bool{
+		BatchAcksFeature:    true,
+		BackpressureFeature: true,
+		SignedTokensFeature: true,
+	}
+	for name, enabled := range features {
+		allFeatures[name] = enabled
+	}
+
+	return &CapabilitiesHandler{response: &CapabilitiesResponse{
+		CapabilitiesVersion: capabilitiesVersion,
+		Version:             version,
+		Commit:              commit,
+		BuiltAt:             builtAt,
+		Endpoints:           ingestionEndpoints,
+		GrpcPort:            grpcPort,
+		Limits: CapabilitiesLimits{
+			MaxBatchBodyBytes: batchHandler.maxBodyBytes,
+			MaxBatchLines:     batchHandler.maxLines,
+			MaxBatchLineBytes: batchMaxLineSize,
+		},
+		Features: allFeatures,
+	}}
+}
+
+func (ch *CapabilitiesHandler) Handler(c *gin.Context) {
+	c.JSON(http.StatusOK, ch.response)
+}
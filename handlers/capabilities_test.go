@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/middleware"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCapabilitiesHandler(t *testing.T) {
+	tests := []struct {
+		name             string
+		version          string
+		grpcPort         int
+		features         map[string]bool
+		expectedVersion  string
+		expectedFeatures map[string]bool
+	}{
+		{
+			"Dev version without configured features",
+			"",
+			0,
+			nil,
+			devVersion,
+			map[string]bool{BatchAcksFeature: true, BackpressureFeature: true, SignedTokensFeature: true},
+		},
+		{
+			"Release version with configured features",
+			"v1.30.0",
+			3001,
+			map[string]bool{BotFilterFeature: true, ValidationFeature: false, GrpcFeature: true},
+			"v1.30.0",
+			map[string]bool{BatchAcksFeature: true, BackpressureFeature: true, SignedTokensFeature: true,
+				BotFilterFeature: true, ValidationFeature: false, GrpcFeature: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			batchHandler := NewBatchHandler(nil, 2, 500)
+			handler := NewCapabilitiesHandler(tt.version, "abc123", "2021-01-01T00:00:00Z", batchHandler, tt.grpcPort, tt.features)
+
+			rec := serveCapabilities(handler)
+			require.Equal(t, http.StatusOK, rec.Code)
+			require.Equal(t, "*", rec.Header().Get("Access-Control-Allow-Origin"))
+
+			response := &CapabilitiesResponse{}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), response))
+			require.Equal(t, capabilitiesVersion, response.CapabilitiesVersion)
+			require.Equal(t, tt.expectedVersion, response.Version)
+			require.Equal(t, "abc123", response.Commit)
+			require.Equal(t, "2021-01-01T00:00:00Z", response.BuiltAt)
+			require.Equal(t, tt.grpcPort, response.GrpcPort)
+			require.Equal(t, ingestionEndpoints, response.Endpoints)
+			require.Equal(t, CapabilitiesLimits{MaxBatchBodyBytes: 2 * 1024 * 1024, MaxBatchLines: 500, MaxBatchLineBytes: batchMaxLineSize}, response.Limits)
+			require.Equal(t, tt.expectedFeatures, response.Features)
+		})
+	}
+}
+
+func TestCapabilitiesHandlerDefaultLimits(t *testing.T) {
+	rec := serveCapabilities(NewCapabilitiesHandler("", "", "", NewBatchHandler(nil, 0, 0), 0, nil))
+
+	response := &CapabilitiesResponse{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), response))
+	require.Equal(t, int64(DefaultBatchMaxBodyMb)*1024*1024, response.Limits.MaxBatchBodyBytes)
+	require.Equal(t, DefaultBatchMaxLines, response.Limits.MaxBatchLines)
+}
+
+func TestCapabilitiesCors(t *testing.T) {
+	router := capabilitiesRouter(NewCapabilitiesHandler("", "", "", NewBatchHandler(nil, 0, 0), 0, nil))
+
+	//preflight request from any origin
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodOptions, "/api/v1/capabilities", nil)
+	req.Header.Set("Origin", "https://site.com")
+	router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "*", rec.Header().Get("Access-Control-Allow-Origin"))
+}
+
+//capabilitiesRouter return the capabilities route wrapped with CORS middleware (as in main.go)
+func capabilitiesRouter(handler *CapabilitiesHandler) http.Handler {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/v1/capabilities", handler.Handler)
+	return middleware.Cors(router, func(string) ([]string, bool) { return nil, false })
+}
+
+func serveCapabilities(handler *CapabilitiesHandler) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/capabilities", nil)
+	req.Header.Set("Origin", "https://site.com")
+	capabilitiesRouter(handler).ServeHTTP(rec, req)
+	return rec
+}
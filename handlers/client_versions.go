@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/clientversion"
+	"net/http"
+	"strings"
+)
+
+type ClientVersionsResponse struct {
+	Tokens map[string]*TokenClientVersions `json:"tokens"`
+}
+
+type TokenClientVersions struct {
+	MinVersion string                 `json:"min_version,omitempty"`
+	Versions   []clientversion.Bucket `json:"versions"`
+}
+
+//ClientVersionsHandler return client versions statistics per token id of the current instance
+type ClientVersionsHandler struct {
+	tracker *clientversion.Tracker
+}
+
+func NewClientVersionsHandler(tracker *clientversion.Tracker) *ClientVersionsHandler {
+	return &ClientVersionsHandler{tracker: tracker}
+}
+
+func (cvh *ClientVersionsHandler) GetHandler(c *gin.Context) {
+	tokenIds := c.Query("token_ids")
+	tokensFilter := map[string]bool{}
+	if tokenIds != "" {
+		for _, tokenId := range strings.Split(tokenIds, ",") {
+			tokensFilter[tokenId] = true
+		}
+	}
+
+	response := ClientVersionsResponse{Tokens: map[string]*TokenClientVersions{}}
+	for tokenId, buckets := range cvh.tracker.GetBuckets(tokensFilter) {
+		response.Tokens[tokenId] = &TokenClientVersions{MinVersion: cvh.tracker.MinVersion(tokenId), Versions: buckets}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -3,12 +3,15 @@ package handlers
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/jitsucom/eventnative/cluster"
+	"github.com/jitsucom/eventnative/clustering"
 	"github.com/jitsucom/eventnative/middleware"
 	"net/http"
 )
 
 type ClusterInfo struct {
 	Instances []InstanceInfo `json:"instances"`
+	//destinations ids which clustering partitions are owned by this node (in clustering mode)
+	OwnedPartitions []string `json:"owned_partitions,omitempty"`
 }
 
 type InstanceInfo struct {
@@ -37,5 +40,10 @@ func (ch *ClusterHandler) Handler(c *gin.Context) {
 		instances = append(instances, InstanceInfo{Name: name})
 	}
 
-	c.JSON(http.StatusOK, ClusterInfo{Instances: instances})
+	info := ClusterInfo{Instances: instances}
+	if clustering.Instance != nil {
+		info.OwnedPartitions = clustering.Instance.Owned()
+	}
+
+	c.JSON(http.StatusOK, info)
 }
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/destinations"
+	"github.com/jitsucom/eventnative/middleware"
+	"github.com/jitsucom/eventnative/storages"
+	"github.com/jitsucom/eventnative/workspaces"
+	"net/http"
+	"strings"
+)
+
+type ColumnTypesResponse struct {
+	Destinations map[string][]*storages.TableTypesReport `json:"destinations"`
+}
+
+//ColumnTypesHandler return per destination table discovered column types vs actual warehouse column types
+//helps operators to find mismatches (e.g. warehouse has INT column but events send strings) and fix mapping typecasts
+type ColumnTypesHandler struct {
+	destinationService *destinations.Service
+}
+
+func NewColumnTypesHandler(destinationService *destinations.Service) *ColumnTypesHandler {
+	return &ColumnTypesHandler{destinationService: destinationService}
+}
+
+//GetHandler accept optional destination_ids (comma separated) and mismatches_only=true query parameters
+func (cth *ColumnTypesHandler) GetHandler(c *gin.Context) {
+	destinationIds := c.Query("destination_ids")
+	destinationsFilter := map[string]bool{}
+	if destinationIds != "" {
+		for _, destinationId := range strings.Split(destinationIds, ",") {
+			destinationsFilter[strings.TrimSpace(destinationId)] = true
+		}
+	}
+	mismatchesOnly := c.Query("mismatches_only") == "true"
+	workspaceId := middleware.GetWorkspaceId(c)
+
+	response := ColumnTypesResponse{Destinations: map[string][]*storages.TableTypesReport{}}
+	for name, storageProxy := range cth.destinationService.GetAllStorages() {
+		if len(destinationsFilter) > 0 && !destinationsFilter[name] {
+			continue
+		}
+		if !workspaces.Owns(workspaceId, name) {
+			continue
+		}
+
+		storage, ok := storageProxy.Get()
+		if !ok {
+			continue
+		}
+
+		reporter, ok := storage.(storages.ColumnTypesReporter)
+		if !ok {
+			continue
+		}
+
+		reports := reporter.ColumnTypesReport()
+		if mismatchesOnly {
+			reports = filterMismatches(reports)
+		}
+		response.Destinations[name] = reports
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func filterMismatches(reports []*storages.TableTypesReport) []*storages.TableTypesReport {
+	filtered := []*storages.TableTypesReport{}
+	for _, report := range reports {
+		if report.Mismatches == 0 {
+			continue
+		}
+
+		tableReport := &storages.TableTypesReport{Table: report.Table, Mismatches: report.Mismatches}
+		for _, column := range report.Columns {
+			if column.Mismatch {
+				tableReport.Columns = append(tableReport.Columns, column)
+			}
+		}
+		filtered = append(filtered, tableReport)
+	}
+	return filtered
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/declarative"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/middleware"
+	"net/http"
+)
+
+//ConfigHandler is a declarative configuration API (e.g. for Terraform providers)
+type ConfigHandler struct {
+	declarativeService *declarative.Service
+}
+
+func NewConfigHandler(declarativeService *declarative.Service) *ConfigHandler {
+	return &ConfigHandler{declarativeService: declarativeService}
+}
+
+//GetHandler return the effective tokens and destinations configuration as canonical JSON
+func (ch *ConfigHandler) GetHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, ch.declarativeService.Export())
+}
+
+//PlanHandler return diff between the current configuration and the desired state from the body
+func (ch *ConfigHandler) PlanHandler(c *gin.Context) {
+	desired := &declarative.Config{}
+	if err := c.BindJSON(desired); err != nil {
+		logging.Errorf("Error parsing declarative config body: %v", err)
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Failed to parse body", Error: err.Error()})
+		return
+	}
+
+	plan, err := ch.declarativeService.Plan(desired)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Invalid config", Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
+
+//ApplyHandler apply the desired state from the body and return applied plan
+func (ch *ConfigHandler) ApplyHandler(c *gin.Context) {
+	desired := &declarative.Config{}
+	if err := c.BindJSON(desired); err != nil {
+		logging.Errorf("Error parsing declarative config body: %v", err)
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Failed to parse body", Error: err.Error()})
+		return
+	}
+
+	plan, err := ch.declarativeService.Apply(desired)
+	if err != nil {
+		logging.Errorf("Error applying declarative config: %v", err)
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Failed to apply config", Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
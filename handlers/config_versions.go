@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/configversions"
+	"github.com/jitsucom/eventnative/middleware"
+	"github.com/jitsucom/eventnative/workspaces"
+	"net/http"
+	"strings"
+)
+
+type ConfigVersionsResponse struct {
+	Destinations map[string][]*configversions.Version `json:"destinations"`
+}
+
+//ConfigVersionsHandler return destinations processing configuration versions (which are used by as_of replays)
+type ConfigVersionsHandler struct {
+	versions *configversions.Versions
+}
+
+func NewConfigVersionsHandler(versions *configversions.Versions) *ConfigVersionsHandler {
+	return &ConfigVersionsHandler{versions: versions}
+}
+
+//GetHandler return versions (the newest first). Accept optional destination_ids (comma separated) query parameter
+func (cvh *ConfigVersionsHandler) GetHandler(c *gin.Context) {
+	destinationIds := cvh.versions.Destinations()
+	if destinationIdsStr := c.Query("destination_ids"); destinationIdsStr != "" {
+		destinationIds = []string{}
+		for _, destinationId := range strings.Split(destinationIdsStr, ",") {
+			destinationIds = append(destinationIds, strings.TrimSpace(destinationId))
+		}
+	}
+
+	workspaceId := middleware.GetWorkspaceId(c)
+	response := ConfigVersionsResponse{Destinations: map[string][]*configversions.Version{}}
+	for _, destinationId := range destinationIds {
+		if workspaces.Owns(workspaceId, destinationId) {
+			response.Destinations[destinationId] = cvh.versions.History(destinationId)
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
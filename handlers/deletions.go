@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/destinations"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/middleware"
+	"github.com/jitsucom/eventnative/storages"
+	"github.com/jitsucom/eventnative/workspaces"
+	"net/http"
+	"time"
+)
+
+const defaultDeletionBatchSize = 10000
+
+//DeletionRequest is a bulk delete of events which match the filter from the destinations tables.
+//DryRun only counts matched rows. BatchSize overrides the configured rows count per DELETE
+type DeletionRequest struct {
+	DestinationIds []string `json:"destination_ids"`
+	storages.DeletionFilter
+	DryRun    bool `json:"dry_run,omitempty"`
+	BatchSize int  `json:"batch_size,omitempty"`
+}
+
+//DestinationDeletion is a deleted (or matched in dry-run) rows count or an error of the destination
+type DestinationDeletion struct {
+	Rows  int64  `json:"rows"`
+	Error string `json:"error,omitempty"`
+}
+
+type DeletionResponse struct {
+	DryRun       bool                            `json:"dry_run"`
+	Destinations map[string]*DestinationDeletion `json:"destinations"`
+}
+
+//DeletionsHandler delete stored events by filter from SQL destinations (see storages.BulkDeleter)
+type DeletionsHandler struct {
+	destinationService *destinations.Service
+	batchSize          int
+	pause              time.Duration
+}
+
+func NewDeletionsHandler(destinationService *destinations.Service, batchSize int, pause time.Duration) *DeletionsHandler {
+	if batchSize <= 0 {
+		batchSize = defaultDeletionBatchSize
+	}
+	return &DeletionsHandler{destinationService: destinationService, batchSize: batchSize, pause: pause}
+}
+
+//PostHandler delete (or count in dry-run) matched rows of every requested destination one by one.
+//Destinations errors don't stop deletion from others and are returned per destination
+func (dh *DeletionsHandler) PostHandler(c *gin.Context) {
+	req := &DeletionRequest{}
+	//numbers are kept as json.Number: they are converted into the column types
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(req); err != nil {
+		logging.Errorf("Error parsing bulk delete body: %v", err)
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Failed to parse body", Error: err.Error()})
+		return
+	}
+	if len(req.DestinationIds) == 0 {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "destination_ids are required"})
+		return
+	}
+	batchSize := dh.batchSize
+	if req.BatchSize > 0 {
+		batchSize = req.BatchSize
+	}
+
+	workspaceId := middleware.GetWorkspaceId(c)
+	deleters := map[string]storages.BulkDeleter{}
+	for _, destinationId := range req.DestinationIds {
+		if !workspaces.Owns(workspaceId, destinationId) {
+			c.JSON(http.StatusForbidden, middleware.ErrorResponse{Message: fmt.Sprintf("Destination [%s] doesn't belong to workspace [%s]", destinationId, workspaceId)})
+			return
+		}
+
+		storageProxy, ok := dh.destinationService.GetStorageById(destinationId)
+		if !ok {
+			c.JSON(http.StatusNotFound, middleware.ErrorResponse{Message: fmt.Sprintf("Destination [%s] wasn't found", destinationId)})
+			return
+		}
+		storage, ok := storageProxy.Get()
+		if !ok {
+			c.JSON(http.StatusServiceUnavailable, middleware.ErrorResponse{Message: fmt.Sprintf("Destination [%s] hasn't been initialized yet", destinationId)})
+			return
+		}
+		deleter, ok := storage.(storages.BulkDeleter)
+		if !ok {
+			c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: fmt.Sprintf("Destination [%s] of type [%s] doesn't support bulk delete", destinationId, storage.Type())})
+			return
+		}
+		deleters[destinationId] = deleter
+	}
+
+	response := DeletionResponse{DryRun: req.DryRun, Destinations: map[string]*DestinationDeletion{}}
+	for _, destinationId := range req.DestinationIds {
+		deleter := deleters[destinationId]
+
+		var rows int64
+		var err error
+		if req.DryRun {
+			rows, err = deleter.CountDeletion(&req.DeletionFilter)
+		} else {
+			rows, err = deleter.Delete(&req.DeletionFilter, batchSize, dh.pause)
+		}
+
+		result := &DestinationDeletion{Rows: rows}
+		if err != nil {
+			logging.Errorf("Error bulk deleting rows of [%s] table of [%s] (dry-run: %t): %v", req.Table, destinationId, req.DryRun, err)
+			result.Error = err.Error()
+		}
+		response.Destinations[destinationId] = result
+	}
+
+	c.JSON(http.StatusOK, response)
+}
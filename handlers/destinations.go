@@ -16,7 +16,7 @@ import (
 func DestinationsHandler(c *gin.Context) {
 	destinationConfig := &storages.DestinationConfig{}
 	if err := c.BindJSON(destinationConfig); err != nil {
-		logging.Error("Error parsing destinations body: %v", err)
+		logging.Errorf("Error parsing destinations body: %v", err)
 		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Failed to parse body", Error: err.Error()})
 		return
 	}
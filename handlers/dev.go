@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/destinations"
+	"github.com/jitsucom/eventnative/middleware"
+	"github.com/jitsucom/eventnative/storages"
+	"net/http"
+)
+
+type DevDestination struct {
+	Tables []*storages.MemoryTable `json:"tables"`
+	Traces []*storages.Trace       `json:"traces"`
+}
+
+type DevResponse struct {
+	Destinations map[string]*DevDestination `json:"destinations"`
+}
+
+//DevHandler serve dev mode web page and received tables, rows and processing traces of in-memory destinations
+type DevHandler struct {
+	destinationService *destinations.Service
+}
+
+func NewDevHandler(destinationService *destinations.Service) *DevHandler {
+	return &DevHandler{destinationService: destinationService}
+}
+
+//PageHandler serve single page which polls TablesHandler
+func (dh *DevHandler) PageHandler(c *gin.Context) {
+	c.Header("Content-type", htmlContentType)
+	c.String(http.StatusOK, devPage)
+}
+
+//TablesHandler return tables with the last rows and traces per in-memory destination
+func (dh *DevHandler) TablesHandler(c *gin.Context) {
+	response := DevResponse{Destinations: map[string]*DevDestination{}}
+	for name, memory := range dh.memoryStorages() {
+		response.Destinations[name] = &DevDestination{Tables: memory.Tables(), Traces: memory.Traces()}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+//ClearHandler remove all tables and traces from in-memory destinations
+func (dh *DevHandler) ClearHandler(c *gin.Context) {
+	for _, memory := range dh.memoryStorages() {
+		memory.Clear()
+	}
+
+	c.JSON(http.StatusOK, middleware.OkResponse())
+}
+
+func (dh *DevHandler) memoryStorages() map[string]*storages.Memory {
+	result := map[string]*storages.Memory{}
+	for name, storageProxy := range dh.destinationService.GetAllStorages() {
+		storage, ok := storageProxy.Get()
+		if !ok {
+			continue
+		}
+
+		if memory, ok := storage.(*storages.Memory); ok {
+			result[name] = memory
+		}
+	}
+	return result
+}
+
+const devPage = `<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>EventNative dev mode</title>
+  <style>
+    body { font-family: -apple-system, BlinkMacSystemFont, sans-serif; margin: 20px; color: #222; }
+    h2 { margin-top: 32px; }
+    table { border-collapse: collapse; margin-bottom: 16px; font-size: 13px; }
+    th, td { border: 1px solid #ddd; padding: 4px 8px; text-align: left; vertical-align: top; white-space: nowrap; }
+    th small { color: #888; font-weight: normal; }
+    .scroll { overflow-x: auto; }
+    .error { color: #c00; }
+    pre { margin: 0; font-size: 12px; }
+    button { margin-left: 8px; }
+  </style>
+</head>
+<body>
+  <h1>EventNative dev mode <button id="clear">Clear</button> <label><input type="checkbox" id="pause"> pause</label></h1>
+  <p>Events are stored in memory only. Send events with the configured token (default: <code>dev</code>) to this server.</p>
+  <div id="content">Loading...</div>
+<script>
+function esc(value) {
+  if (value === undefined || value === null) return '';
+  if (typeof value === 'object') value = JSON.stringify(value);
+  return String(value).replace(/[&<>"']/g, function(c) {
+    return {'&': '&amp;', '<': '&lt;', '>': '&gt;', '"': '&quot;', "'": '&#39;'}[c];
+  });
+}
+
+function renderTable(table) {
+  var columns = Object.keys(table.columns).sort();
+  var html = '<h3>' + esc(table.name) + ' <small>(' + table.total + ' rows, the last ' + table.rows.length + ' are shown)</small></h3>';
+  html += '<div class="scroll"><table><tr>';
+  columns.forEach(function(column) {
+    html += '<th>' + esc(column) + '<br><small>' + esc(table.columns[column]) + '</small></th>';
+  });
+  html += '</tr>';
+  table.rows.slice().reverse().forEach(function(row) {
+    html += '<tr>';
+    columns.forEach(function(column) { html += '<td>' + esc(row[column]) + '</td>'; });
+    html += '</tr>';
+  });
+  return html + '</table></div>';
+}
+
+function renderTraces(traces) {
+  if (!traces.length) return '';
+  var html = '<h3>Processing traces</h3><table><tr><th>time</th><th>event id</th><th>result</th><th>payload</th></tr>';
+  traces.forEach(function(trace) {
+    var result = trace.error ? '<span class="error">' + esc(trace.error) + '</span>' : 'stored in ' + esc(trace.table);
+    var payload = trace.error ? trace.event : trace.row;
+    html += '<tr><td>' + esc(trace.time) + '</td><td>' + esc(trace.event_id) + '</td><td>' + result + '</td><td><pre>' +
+      esc(JSON.stringify(payload, null, 2)) + '</pre></td></tr>';
+  });
+  return html + '</table>';
+}
+
+function refresh() {
+  if (document.getElementById('pause').checked) return;
+  fetch('/api/v1/dev/tables').then(function(response) { return response.json(); }).then(function(data) {
+    var names = Object.keys(data.destinations).sort();
+    if (!names.length) {
+      document.getElementById('content').innerHTML = 'There are no in-memory destinations';
+      return;
+    }
+    var html = '';
+    names.forEach(function(name) {
+      var destination = data.destinations[name];
+      html += '<h2>Destination: ' + esc(name) + '</h2>';
+      if (!destination.tables.length) html += '<p>No events have been received yet</p>';
+      destination.tables.forEach(function(table) { html += renderTable(table); });
+      html += renderTraces(destination.traces);
+    });
+    document.getElementById('content').innerHTML = html;
+  }).catch(function(err) {
+    document.getElementById('content').innerHTML = '<span class="error">' + esc(err) + '</span>';
+  });
+}
+
+document.getElementById('clear').onclick = function() {
+  fetch('/api/v1/dev/tables', {method: 'DELETE'}).then(refresh);
+};
+refresh();
+setInterval(refresh, 2000);
+</script>
+</body>
+</html>
+`
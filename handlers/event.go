@@ -2,22 +2,38 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/acks"
+	"github.com/jitsucom/eventnative/aggregation"
 	"github.com/jitsucom/eventnative/appconfig"
+	"github.com/jitsucom/eventnative/botfilter"
 	"github.com/jitsucom/eventnative/caching"
+	"github.com/jitsucom/eventnative/clientip"
+	"github.com/jitsucom/eventnative/clientversion"
 	"github.com/jitsucom/eventnative/destinations"
 	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/identity"
 	"github.com/jitsucom/eventnative/logging"
 	"github.com/jitsucom/eventnative/middleware"
+	"github.com/jitsucom/eventnative/reports"
+	"github.com/jitsucom/eventnative/sessions"
+	"github.com/jitsucom/eventnative/sharding"
 	"github.com/jitsucom/eventnative/telemetry"
 	"github.com/jitsucom/eventnative/timestamp"
+	"github.com/jitsucom/eventnative/trackingplan"
 	"github.com/jitsucom/eventnative/uuid"
+	"github.com/jitsucom/eventnative/validation"
+	"github.com/jitsucom/eventnative/workspaces"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 )
 
+//eventsLogger writes events component log lines (see logging.Configure levels)
+var eventsLogger = logging.Component(logging.EventsComponent)
+
 const (
 	apiTokenKey  = "api_key"
 	ipKey        = "source_ip"
@@ -34,6 +50,20 @@ type OldCachedEventsResponse struct {
 	Events []events.Fact `json:"events"`
 }
 
+//TrackingPlanErrorResponse is a response on events which don't conform to the tracking plan with block action
+type TrackingPlanErrorResponse struct {
+	Message    string                    `json:"message"`
+	Error      string                    `json:"error"`
+	Violations []*trackingplan.Violation `json:"violations"`
+}
+
+//ValidationErrorResponse is a response on events which don't match JSON Schemas
+type ValidationErrorResponse struct {
+	Message  string                `json:"message"`
+	Error    string                `json:"error"`
+	Failures []*validation.Failure `json:"failures"`
+}
+
 type CachedEventsResponse struct {
 	TotalEvents    int           `json:"total_events"`
 	ResponseEvents int           `json:"response_events"`
@@ -44,25 +74,59 @@ type CachedEventsResponse struct {
 type EventHandler struct {
 	destinationService  *destinations.Service
 	preprocessor        events.Preprocessor
+	shards              *sharding.Shards
 	eventsCache         *caching.EventsCache
 	inMemoryEventsCache *events.Cache
+	clientVersions      *clientversion.Tracker
+	validator           *validation.Service
+	identityResolver    *identity.Resolver
+	botFilter           *botfilter.Filter
+	trackingPlan        *trackingplan.Plan
+	aggregator          *aggregation.Aggregator
+	sessionizer         *sessions.Sessionizer
+}
+
+//EventHandlerOptions are optional events processing stages of EventHandler. Zero value disables all of them
+type EventHandlerOptions struct {
+	//if Shards isn't nil - events are preprocessed and consumed by token shard with shard own preprocessor
+	Shards *sharding.Shards
+	//if Validator isn't nil - events are validated with JSON Schemas before any enrichment
+	Validator *validation.Service
+	//if IdentityResolver isn't nil - events are enriched with canonical user id and identity merge records are consumed after events
+	IdentityResolver *identity.Resolver
+	//if BotFilter isn't nil - bot traffic is tagged or dropped before caching and processing
+	BotFilter *botfilter.Filter
+	//if TrackingPlan isn't nil - non-conforming events are blocked, quarantined or tagged before caching and processing
+	TrackingPlan *trackingplan.Plan
+	//if Aggregator isn't nil - events of aggregated types aren't cached or stored row-level: only their aggregates are consumed
+	Aggregator *aggregation.Aggregator
+	//if Sessionizer isn't nil - events are enriched with session id and sequence number and closed sessions summaries are consumed
+	Sessionizer *sessions.Sessionizer
 }
 
 //Accept all events according to token
 func NewEventHandler(destinationService *destinations.Service, preprocessor events.Preprocessor, eventsCache *caching.EventsCache,
-	inMemoryEventsCache *events.Cache) (eventHandler *EventHandler) {
+	inMemoryEventsCache *events.Cache, clientVersions *clientversion.Tracker, options EventHandlerOptions) (eventHandler *EventHandler) {
 	return &EventHandler{
 		destinationService:  destinationService,
 		preprocessor:        preprocessor,
+		shards:              options.Shards,
 		eventsCache:         eventsCache,
 		inMemoryEventsCache: inMemoryEventsCache,
+		clientVersions:      clientVersions,
+		validator:           options.Validator,
+		identityResolver:    options.IdentityResolver,
+		botFilter:           options.BotFilter,
+		trackingPlan:        options.TrackingPlan,
+		aggregator:          options.Aggregator,
+		sessionizer:         options.Sessionizer,
 	}
 }
 
 func (eh *EventHandler) PostHandler(c *gin.Context) {
 	payload := events.Fact{}
 	if err := c.BindJSON(&payload); err != nil {
-		logging.Error("Error parsing event body: %v", err)
+		eventsLogger.Errorf("Error parsing event body: %v", err)
 		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Failed to parse body", Error: err.Error()})
 		return
 	}
@@ -74,6 +138,89 @@ func (eh *EventHandler) PostHandler(c *gin.Context) {
 	}
 	token := iface.(string)
 
+	deprecation, err := eh.ProcessEvent(token, payload, c.Request)
+	if deprecation != nil {
+		writeDeprecationHeaders(c, deprecation)
+	}
+	if err != nil {
+		if validationErr, ok := err.(*validation.Error); ok {
+			c.JSON(http.StatusBadRequest, ValidationErrorResponse{Message: "Event doesn't match JSON Schema", Error: err.Error(), Failures: validationErr.Failures})
+			return
+		}
+		if trackingPlanErr, ok := err.(*trackingplan.Error); ok {
+			c.JSON(http.StatusBadRequest, TrackingPlanErrorResponse{Message: "Event doesn't conform to tracking plan", Error: err.Error(), Violations: trackingPlanErr.Violations})
+			return
+		}
+		if err == events.ErrQueueFull {
+			c.Header("Retry-After", "1")
+			c.JSON(http.StatusServiceUnavailable, middleware.ErrorResponse{Message: "Events queue is full. Please retry later", Error: err.Error()})
+			return
+		}
+		eventsLogger.WithEventId(events.ExtractEventId(payload)).Errorf("Error processing event: %v", err)
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Error processing event", Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, middleware.OkResponse())
+}
+
+//ProcessEvent enrich payload, put it into events cache, preprocess and send to all token consumers
+//return clientversion.Deprecation if client version is deprecated
+//return err if payload can't be preprocessed or events.ErrQueueFull if stream destination queue or processing shard queue is full
+//return *validation.Error if payload doesn't match JSON Schema with reject action
+//return *trackingplan.Error if payload doesn't conform to the tracking plan with block action
+func (eh *EventHandler) ProcessEvent(token string, payload events.Fact, r *http.Request) (*clientversion.Deprecation, error) {
+	return eh.ProcessBatchEvent(token, payload, r, nil)
+}
+
+//ProcessBatchEvent is ProcessEvent of the source batch event: the event is added into the batch (if it isn't nil) before consuming
+//so the batch is acknowledged only after the event has been delivered into all token destinations
+func (eh *EventHandler) ProcessBatchEvent(token string, payload events.Fact, r *http.Request, batch *acks.Batch) (*clientversion.Deprecation, error) {
+	//signed tokens have already been verified by auth middleware
+	token = appconfig.Instance.AuthorizationService.ResolveToken(token)
+
+	if eh.shards == nil {
+		return eh.processEvent(eh.preprocessor, token, payload, r, batch)
+	}
+
+	var deprecation *clientversion.Deprecation
+	var err error
+	if shardErr := eh.shards.Do(token, func(preprocessor events.Preprocessor) {
+		deprecation, err = eh.processEvent(preprocessor, token, payload, r, batch)
+	}); shardErr != nil {
+		return nil, shardErr
+	}
+
+	return deprecation, err
+}
+
+func (eh *EventHandler) processEvent(preprocessor events.Preprocessor, token string, payload events.Fact, r *http.Request, batch *acks.Batch) (*clientversion.Deprecation, error) {
+	tokenId := appconfig.Instance.AuthorizationService.GetTokenId(token)
+
+	//validate original payload before any enrichment
+	validationErr := eh.validator.Validate(token, tokenId, payload)
+	if validationErr != nil && validationErr.Action == validation.RejectAction {
+		return nil, validationErr
+	}
+
+	ip := extractIp(r)
+	deprecation := eh.clientVersions.Track(tokenId, eh.clientVersions.Extract(r, payload))
+
+	//bot traffic is dropped before caching or tagged
+	if eh.botFilter != nil && eh.botFilter.Check(payload, ip, r.UserAgent()) {
+		return deprecation, nil
+	}
+
+	trackingPlanErr := eh.trackingPlan.Check(tokenId, payload)
+	if trackingPlanErr != nil && trackingPlanErr.Action == trackingplan.BlockAction {
+		return deprecation, trackingPlanErr
+	}
+
+	//anonymous aggregation: events aren't cached, written into fallback or stored row-level
+	if eh.aggregator != nil && eh.aggregator.Match(payload) {
+		return deprecation, eh.aggregate(preprocessor, token, tokenId, ip, payload, validationErr != nil || trackingPlanErr != nil)
+	}
+
 	//Deprecated
 	eh.inMemoryEventsCache.PutAsync(token, payload)
 
@@ -82,41 +229,122 @@ func (eh *EventHandler) PostHandler(c *gin.Context) {
 	//get eventId if it is in request
 	eventId := events.ExtractEventId(payload)
 
-	tokenId := appconfig.Instance.AuthorizationService.GetTokenId(token)
-
 	//caching
+	eh.eventsCache.PutToken(tokenId, eventId, payload.Clone())
 	for destinationId := range eh.destinationService.GetDestinationIds(tokenId) {
 		//clone payload map for preventing concurrent changes while serialization
 		eh.eventsCache.Put(destinationId, eventId, payload.Clone())
 	}
 
-	ip := extractIp(c.Request)
 	if ip != "" {
 		payload[ipKey] = ip
 	}
 
-	processed, err := eh.preprocessor.Preprocess(payload)
+	processed, err := preprocessor.Preprocess(payload)
 	if err != nil {
-		logging.Error("Error processing event:", err)
-		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Error processing event", Error: err.Error()})
-		return
+		return deprecation, err
 	}
 
 	processed[apiTokenKey] = token
 	processed[timestamp.Key] = timestamp.NowUTC()
+	if appconfig.Instance.AuthorizationService.IsTestToken(tokenId) {
+		processed[events.TestKey] = true
+	}
+
+	if validationErr != nil {
+		reports.Instance.Ingested(tokenId, 1)
+		eh.fallback(tokenId, eventId, processed, validationErr)
+		return deprecation, nil
+	}
+	//quarantine
+	if trackingPlanErr != nil {
+		reports.Instance.Ingested(tokenId, 1)
+		eh.fallback(tokenId, eventId, processed, trackingPlanErr)
+		return deprecation, nil
+	}
+
+	var identityMerge events.Fact
+	if eh.identityResolver != nil {
+		identityMerge = eh.identityResolver.Resolve(processed)
+		if identityMerge != nil {
+			identityMerge[apiTokenKey] = token
+			identityMerge[timestamp.Key] = processed[timestamp.Key]
+		}
+	}
+	//after identity resolution: sessions can be keyed by canonical id
+	if eh.sessionizer != nil {
+		eh.sessionizer.Assign(token, tokenId, processed)
+	}
 
 	consumers := eh.destinationService.GetConsumers(tokenId)
+	//backpressure: reject the event before consuming if at least one stream destination queue is full
+	for _, consumer := range consumers {
+		if queue, ok := consumer.(*events.PersistentQueue); ok && queue.IsFull() {
+			return deprecation, events.ErrQueueFull
+		}
+	}
+
 	if len(consumers) == 0 {
 		logging.Warnf("Unknown token[%s] request was received", token)
 	} else {
 		telemetry.Event()
+		reports.Instance.Ingested(tokenId, 1)
+
+		if batch != nil {
+			var destinationIds []string
+			for destinationId := range eh.destinationService.GetDestinationIds(tokenId) {
+				destinationIds = append(destinationIds, destinationId)
+			}
+			batch.Add(eventId, destinationIds)
+		}
 
 		for _, consumer := range consumers {
 			consumer.Consume(processed, tokenId)
+			if identityMerge != nil {
+				consumer.Consume(identityMerge, tokenId)
+			}
 		}
 	}
 
-	c.JSON(http.StatusOK, middleware.OkResponse())
+	return deprecation, nil
+}
+
+//aggregate preprocess the event and add it into aggregates. Events which would be written into fallback are skipped:
+//they mustn't be stored row-level and they would skew aggregates
+func (eh *EventHandler) aggregate(preprocessor events.Preprocessor, token, tokenId, ip string, payload events.Fact, failed bool) error {
+	if failed {
+		eh.aggregator.Skip(payload)
+		return nil
+	}
+
+	if ip != "" {
+		payload[ipKey] = ip
+	}
+	processed, err := preprocessor.Preprocess(payload)
+	if err != nil {
+		return err
+	}
+
+	telemetry.Event()
+	reports.Instance.Ingested(tokenId, 1)
+	eh.aggregator.Aggregate(token, tokenId, processed)
+	return nil
+}
+
+//fallback write the event into all token destinations fallback (it can be replayed after fixing via fallback API)
+//reason is *validation.Error or *trackingplan.Error
+func (eh *EventHandler) fallback(tokenId, eventId string, processed events.Fact, reason error) {
+	b, err := json.Marshal(processed)
+	if err != nil {
+		eventsLogger.WithEventId(eventId).SystemErrorf("Error marshalling event which doesn't match JSON Schema or tracking plan: %v", err)
+		return
+	}
+
+	for _, storageProxy := range eh.destinationService.GetStorages(tokenId) {
+		if storage, ok := storageProxy.Get(); ok {
+			storage.Fallback(&events.FailedFact{Event: b, Error: reason.Error(), EventId: eventId})
+		}
+	}
 }
 
 func (eh *EventHandler) OldGetHandler(c *gin.Context) {
@@ -146,11 +374,13 @@ func (eh *EventHandler) OldGetHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+//GetHandler return cached raw and processed events of destinations (destination_ids) and raw events of API tokens (token_ids)
 func (eh *EventHandler) GetHandler(c *gin.Context) {
 	destinationIds := c.Query("destination_ids")
-	if destinationIds == "" {
-		logging.Errorf("Empty destination ids in events cache handler")
-		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "destination_ids is required parameter."})
+	tokenIds := c.Query("token_ids")
+	if destinationIds == "" && tokenIds == "" {
+		logging.Errorf("Empty destination ids and token ids in events cache handler")
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "destination_ids or token_ids is required parameter."})
 		return
 	}
 
@@ -183,9 +413,26 @@ func (eh *EventHandler) GetHandler(c *gin.Context) {
 		}
 	}
 
+	workspaceId := middleware.GetWorkspaceId(c)
+	var keys []string
+	for _, destinationId := range splitIds(destinationIds) {
+		if !workspaces.Owns(workspaceId, destinationId) {
+			c.JSON(http.StatusForbidden, middleware.ErrorResponse{Message: fmt.Sprintf("Destination [%s] doesn't belong to workspace [%s]", destinationId, workspaceId)})
+			return
+		}
+		keys = append(keys, destinationId)
+	}
+	for _, tokenId := range splitIds(tokenIds) {
+		if !workspaces.Owns(workspaceId, tokenId) {
+			c.JSON(http.StatusForbidden, middleware.ErrorResponse{Message: fmt.Sprintf("Token [%s] doesn't belong to workspace [%s]", tokenId, workspaceId)})
+			return
+		}
+		keys = append(keys, caching.TokenKey(tokenId))
+	}
+
 	response := CachedEventsResponse{Events: []CachedEvent{}}
-	for _, destinationId := range strings.Split(destinationIds, ",") {
-		eventsArray := eh.eventsCache.GetN(destinationId, start, end, limit)
+	for _, key := range keys {
+		eventsArray := eh.eventsCache.GetN(key, start, end, limit)
 		for _, event := range eventsArray {
 			response.Events = append(response.Events, CachedEvent{
 				Original: []byte(event.Original),
@@ -194,23 +441,88 @@ func (eh *EventHandler) GetHandler(c *gin.Context) {
 			})
 		}
 		response.ResponseEvents += len(eventsArray)
-		response.TotalEvents += eh.eventsCache.GetTotal(destinationId)
+		response.TotalEvents += eh.eventsCache.GetTotal(key)
 	}
 
 	c.JSON(http.StatusOK, response)
 }
 
-func extractIp(r *http.Request) string {
-	ip := r.Header.Get("X-Real-IP")
-	if ip == "" {
-		ip = r.Header.Get("X-Forwarded-For")
+//writeDeprecationHeaders write Deprecation, Sunset (if configured) and Warning headers
+func writeDeprecationHeaders(c *gin.Context, deprecation *clientversion.Deprecation) {
+	c.Header("Deprecation", "true")
+	if deprecation.Sunset != "" {
+		c.Header("Sunset", deprecation.Sunset)
+	}
+	c.Header("Warning", fmt.Sprintf(`299 - "%s"`, deprecation.String()))
+}
+
+//authorizeThirdPartyToken check that token is a client or server secret and write error response if it isn't
+//Origin of browser requests with client token is checked here because 3rd party APIs tokens might be in the body
+//or in not standard parameters and CORS middleware can't check them
+func authorizeThirdPartyToken(c *gin.Context, token string) bool {
+	origins, isClient := appconfig.Instance.AuthorizationService.GetClientOrigins(token)
+	_, isServer := appconfig.Instance.AuthorizationService.GetServerOrigins(token)
+	if !isClient && !isServer {
+		c.JSON(http.StatusUnauthorized, middleware.ErrorResponse{Message: "Wrong token"})
+		return false
 	}
-	if ip == "" {
-		remoteAddr := r.RemoteAddr
-		if remoteAddr != "" {
-			addrPort := strings.Split(remoteAddr, ":")
-			ip = addrPort[0]
+	if reqOrigin := c.GetHeader("Origin"); !isServer && reqOrigin != "" && !middleware.IsOriginAllowed(origins, reqOrigin) {
+		c.JSON(http.StatusForbidden, middleware.ErrorResponse{Message: fmt.Sprintf("Origin [%s] isn't allowed for the token", reqOrigin)})
+		return false
+	}
+	return true
+}
+
+//processThirdPartyFacts process converted 3rd party APIs facts and write error response if at least one of them wasn't processed
+func processThirdPartyFacts(c *gin.Context, eventHandler *EventHandler, token string, facts []events.Fact, name string) bool {
+	var lastDeprecation *clientversion.Deprecation
+	var lastErr error
+	failed := 0
+	for _, fact := range facts {
+		deprecation, err := eventHandler.ProcessEvent(token, fact, c.Request)
+		if deprecation != nil {
+			lastDeprecation = deprecation
+		}
+		if err != nil {
+			failed++
+			lastErr = err
+		}
+	}
+	if lastDeprecation != nil {
+		writeDeprecationHeaders(c, lastDeprecation)
+	}
+
+	if lastErr == nil {
+		return true
+	}
+
+	message := fmt.Sprintf("%d of %d %s weren't processed", failed, len(facts), name)
+	if validationErr, ok := lastErr.(*validation.Error); ok {
+		c.JSON(http.StatusBadRequest, ValidationErrorResponse{Message: message, Error: lastErr.Error(), Failures: validationErr.Failures})
+		return false
+	}
+	if lastErr == events.ErrQueueFull {
+		c.Header("Retry-After", "1")
+		c.JSON(http.StatusServiceUnavailable, middleware.ErrorResponse{Message: message, Error: lastErr.Error()})
+		return false
+	}
+	logging.Errorf("Error processing %s: %v", name, lastErr)
+	c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: message, Error: lastErr.Error()})
+	return false
+}
+
+//extractIp return client ip according to the configured client ip policy (trusted proxies, X-Forwarded-For depth)
+func extractIp(r *http.Request) string {
+	return clientip.Extract(r)
+}
+
+//splitIds return not empty comma separated ids
+func splitIds(ids string) []string {
+	var result []string
+	for _, id := range strings.Split(ids, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			result = append(result, id)
 		}
 	}
-	return ip
+	return result
 }
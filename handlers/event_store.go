@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/eventstore"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/middleware"
+	"github.com/jitsucom/eventnative/workspaces"
+	"net/http"
+)
+
+type EventStoreQueryRequest struct {
+	DestinationId string `json:"destination_id"`
+	Query         string `json:"query"`
+}
+
+type EventStoreTablesResponse struct {
+	Destinations map[string]map[string]*eventstore.TableStats `json:"destinations"`
+}
+
+//EventStoreHandler is a read-only SQL API over the last hours of processed events which are kept in memory (per server)
+type EventStoreHandler struct {
+	store *eventstore.Store
+}
+
+func NewEventStoreHandler(store *eventstore.Store) *EventStoreHandler {
+	return &EventStoreHandler{store: store}
+}
+
+//QueryHandler execute {"destination_id", "query": "SELECT event_type, count(*) FROM events GROUP BY event_type"} query
+func (esh *EventStoreHandler) QueryHandler(c *gin.Context) {
+	if esh.store == nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Event store isn't enabled. Please configure server.event_store"})
+		return
+	}
+
+	req := &EventStoreQueryRequest{}
+	if err := c.BindJSON(req); err != nil {
+		logging.Errorf("Error parsing event store query body: %v", err)
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Failed to parse body", Error: err.Error()})
+		return
+	}
+	if req.DestinationId == "" || req.Query == "" {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "destination_id and query are required parameters"})
+		return
+	}
+
+	workspaceId := middleware.GetWorkspaceId(c)
+	if !workspaces.Owns(workspaceId, req.DestinationId) {
+		c.JSON(http.StatusForbidden, middleware.ErrorResponse{Message: fmt.Sprintf("Destination [%s] doesn't belong to workspace [%s]", req.DestinationId, workspaceId)})
+		return
+	}
+
+	result, err := esh.store.Query(req.DestinationId, req.Query)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Failed to execute query", Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+//TablesHandler return kept rows stats of workspace (or all) destinations tables
+func (esh *EventStoreHandler) TablesHandler(c *gin.Context) {
+	workspaceId := middleware.GetWorkspaceId(c)
+
+	response := EventStoreTablesResponse{Destinations: map[string]map[string]*eventstore.TableStats{}}
+	for _, destinationId := range esh.store.DestinationIds() {
+		if !workspaces.Owns(workspaceId, destinationId) {
+			continue
+		}
+		if tables := esh.store.Tables(destinationId); len(tables) > 0 {
+			response.Destinations[destinationId] = tables
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/faults"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/middleware"
+	"net/http"
+)
+
+type FaultsResponse struct {
+	Enabled bool                    `json:"enabled"`
+	Faults  map[string]faults.Fault `json:"faults"`
+}
+
+//FaultsHandler configures test-mode fault injection per destination id
+type FaultsHandler struct {
+	injector *faults.Injector
+}
+
+func NewFaultsHandler(injector *faults.Injector) *FaultsHandler {
+	return &FaultsHandler{injector: injector}
+}
+
+//GetHandler return all configured faults
+func (fh *FaultsHandler) GetHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, FaultsResponse{Enabled: fh.injector.Enabled(), Faults: fh.injector.Faults()})
+}
+
+//SetHandler put fault from request body for destination id
+func (fh *FaultsHandler) SetHandler(c *gin.Context) {
+	destinationId := c.Param("destination_id")
+
+	fault := &faults.Fault{}
+	if err := c.BindJSON(fault); err != nil {
+		logging.Errorf("Error parsing fault body: %v", err)
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Failed to parse body", Error: err.Error()})
+		return
+	}
+
+	if err := fh.injector.Set(destinationId, fault); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Failed to set fault", Error: err.Error()})
+		return
+	}
+
+	logging.Warnf("[%s] Fault injection has been configured: latency %d ms, error rate %.2f, partial failure rate %.2f",
+		destinationId, fault.LatencyMs, fault.ErrorRate, fault.PartialFailureRate)
+	c.JSON(http.StatusOK, middleware.OkResponse())
+}
+
+//DeleteHandler remove fault of destination id or all faults if destination id isn't provided
+func (fh *FaultsHandler) DeleteHandler(c *gin.Context) {
+	destinationId := c.Param("destination_id")
+	fh.injector.Remove(destinationId)
+
+	if destinationId == "" {
+		logging.Info("All injected faults have been removed")
+	} else {
+		logging.Infof("[%s] Injected fault has been removed", destinationId)
+	}
+	c.JSON(http.StatusOK, middleware.OkResponse())
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/middleware"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	ga4ApiSecret = "api_secret"
+	//Measurement Protocol v1 batch limit
+	gaMaxBatchHits = 20
+)
+
+//1x1 transparent GIF which is returned by Google Analytics collect endpoint
+var gaPixel = []byte{
+	0x47, 0x49, 0x46, 0x38, 0x39, 0x61, 0x01, 0x00, 0x01, 0x00, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0xff, 0xff, 0xff, 0x21, 0xf9, 0x04, 0x01, 0x00, 0x00, 0x00, 0x00, 0x2c, 0x00, 0x00, 0x00, 0x00,
+	0x01, 0x00, 0x01, 0x00, 0x00, 0x02, 0x01, 0x44, 0x00, 0x3b,
+}
+
+//GAHandler accept Google Analytics Measurement Protocol hits: v1 (/collect, /batch) and GA4 (/mp/collect)
+//and process them as JS SDK events. Token (client or server secret) is taken from token query parameter,
+//X-Auth-Token header or GA4 api_secret query parameter
+type GAHandler struct {
+	eventHandler *EventHandler
+}
+
+func NewGAHandler(eventHandler *EventHandler) *GAHandler {
+	return &GAHandler{eventHandler: eventHandler}
+}
+
+//CollectHandler accept one Measurement Protocol v1 hit as GET query or POST url-encoded body
+func (gh *GAHandler) CollectHandler(c *gin.Context) {
+	hits, ok := gh.readHits(c, false)
+	if !ok {
+		return
+	}
+	gh.process(c, hits)
+}
+
+//BatchHandler accept up to 20 Measurement Protocol v1 hits (one url-encoded hit per line)
+func (gh *GAHandler) BatchHandler(c *gin.Context) {
+	hits, ok := gh.readHits(c, true)
+	if !ok {
+		return
+	}
+	gh.process(c, hits)
+}
+
+//GA4Handler accept GA4 Measurement Protocol JSON request with events
+func (gh *GAHandler) GA4Handler(c *gin.Context) {
+	token := extractGAToken(c.Request)
+	if token == "" {
+		token = c.Query(ga4ApiSecret)
+	}
+	if !authorizeThirdPartyToken(c, token) {
+		return
+	}
+
+	payload := map[string]interface{}{}
+	if err := c.BindJSON(&payload); err != nil {
+		logging.Errorf("Error parsing GA4 Measurement Protocol body: %v", err)
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Failed to parse body", Error: err.Error()})
+		return
+	}
+
+	measurementId := c.Query("measurement_id")
+	if measurementId == "" {
+		measurementId = c.Query("firebase_app_id")
+	}
+
+	facts, err := events.ConvertGA4(payload, measurementId, extractIp(c.Request), c.Request.UserAgent(), time.Now().UTC())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Malformed GA4 Measurement Protocol payload", Error: err.Error()})
+		return
+	}
+
+	if !processThirdPartyFacts(c, gh.eventHandler, token, facts, "GA4 events") {
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+//readHits return authorized hits from query (GET) or body (POST). Token parameter is removed from hits
+func (gh *GAHandler) readHits(c *gin.Context, isBatch bool) ([]url.Values, bool) {
+	token := extractGAToken(c.Request)
+	if !authorizeThirdPartyToken(c, token) {
+		return nil, false
+	}
+
+	var rawHits []string
+	if c.Request.Method == http.MethodGet {
+		rawHits = []string{c.Request.URL.RawQuery}
+	} else {
+		body, err := ioutil.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Failed to read body", Error: err.Error()})
+			return nil, false
+		}
+		if isBatch {
+			for _, line := range bytes.Split(body, []byte("\n")) {
+				if len(bytes.TrimSpace(line)) > 0 {
+					rawHits = append(rawHits, string(line))
+				}
+			}
+			if len(rawHits) > gaMaxBatchHits {
+				c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: fmt.Sprintf("Batch can't contain more than %d hits", gaMaxBatchHits)})
+				return nil, false
+			}
+		} else {
+			rawHits = []string{string(body)}
+		}
+	}
+
+	var hits []url.Values
+	for i, rawHit := range rawHits {
+		hit, err := url.ParseQuery(strings.TrimSpace(rawHit))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: fmt.Sprintf("Malformed hit #%d", i), Error: err.Error()})
+			return nil, false
+		}
+		hit.Del(middleware.TokenName)
+		hits = append(hits, hit)
+	}
+
+	return hits, true
+}
+
+//process convert all hits before processing (malformed batch isn't processed at all) and write GIF response
+func (gh *GAHandler) process(c *gin.Context, hits []url.Values) {
+	token := extractGAToken(c.Request)
+	ip := extractIp(c.Request)
+	now := time.Now().UTC()
+
+	facts := make([]events.Fact, 0, len(hits))
+	for i, hit := range hits {
+		fact, err := events.ConvertGA(hit, ip, c.Request.UserAgent(), now)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: fmt.Sprintf("Malformed Measurement Protocol hit #%d", i), Error: err.Error()})
+			return
+		}
+		facts = append(facts, fact)
+	}
+
+	if !processThirdPartyFacts(c, gh.eventHandler, token, facts, "Measurement Protocol hits") {
+		return
+	}
+
+	c.Header("Cache-Control", "no-cache, no-store, must-revalidate")
+	c.Data(http.StatusOK, "image/gif", gaPixel)
+}
+
+//extractGAToken return token from token query parameter or X-Auth-Token header
+func extractGAToken(r *http.Request) string {
+	if token := r.URL.Query().Get(middleware.TokenName); token != "" {
+		return token
+	}
+	return r.Header.Get("x-auth-token")
+}
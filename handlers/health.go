@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/appstatus"
+	"github.com/jitsucom/eventnative/breaker"
+	"github.com/jitsucom/eventnative/destinations"
+	"github.com/jitsucom/eventnative/health"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/storages"
+	"github.com/jitsucom/eventnative/trackingplan"
+	"github.com/jitsucom/eventnative/validation"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	OkStatus       = "ok"
+	DegradedStatus = "degraded"
+	//IdleStatus means that the server is shutting down
+	IdleStatus = "idle"
+
+	DestinationOk           = "ok"
+	DestinationInitializing = "initializing"
+	DestinationUnreachable  = "unreachable"
+	DestinationFailing      = "failing"
+	DestinationQueueFull    = "queue_full"
+	DestinationCircuitOpen  = "circuit_open"
+)
+
+var errCheckTimeout = errors.New("Connectivity check timeout")
+
+//QueueHealth is the events queue of the stream mode destination
+type QueueHealth struct {
+	SizeBytes int64 `json:"size_bytes"`
+	Full      bool  `json:"full"`
+	//circuit breaker spool size
+	SpoolBytes int64 `json:"spool_bytes,omitempty"`
+}
+
+//DestinationHealth is the destination status with the last flushes. Error is returned only with server admin token
+type DestinationHealth struct {
+	Status      string       `json:"status"`
+	Queue       *QueueHealth `json:"queue,omitempty"`
+	Circuit     string       `json:"circuit,omitempty"`
+	LastSuccess string       `json:"last_success,omitempty"`
+	LastError   string       `json:"last_error,omitempty"`
+	Error       string       `json:"error,omitempty"`
+}
+
+//SchemasHealth is loaded validation schemas and tracking plan events
+type SchemasHealth struct {
+	ValidationSchemas  []string `json:"validation_schemas"`
+	TrackingPlanEvents int      `json:"tracking_plan_events"`
+}
+
+type HealthResponse struct {
+	Status       string                        `json:"status"`
+	Destinations map[string]*DestinationHealth `json:"destinations"`
+	Schemas      *SchemasHealth                `json:"schemas"`
+}
+
+//HealthHandler report destinations statuses (initialization, connectivity, queues and the last flushes) and loaded schemas
+//for liveness (/health) and readiness (/ready) probes
+type HealthHandler struct {
+	destinationService *destinations.Service
+	validator          *validation.Service
+	trackingPlan       *trackingplan.Plan
+	tracker            *health.Tracker
+	adminToken         string
+	checkTimeout       time.Duration
+	//if true - /ready fails if any destination is degraded, otherwise only if events can't be accepted
+	strictReadiness bool
+}
+
+func NewHealthHandler(destinationService *destinations.Service, validator *validation.Service, trackingPlan *trackingplan.Plan,
+	tracker *health.Tracker, adminToken string, checkTimeout time.Duration, strictReadiness bool) *HealthHandler {
+	return &HealthHandler{destinationService: destinationService, validator: validator, trackingPlan: trackingPlan, tracker: tracker,
+		adminToken: adminToken, checkTimeout: checkTimeout, strictReadiness: strictReadiness}
+}
+
+//LivenessHandler always return 200 while the server is serving requests. Body status is degraded if any destination isn't ok
+func (hh *HealthHandler) LivenessHandler(c *gin.Context) {
+	response, _ := hh.check(hh.withErrors(c))
+	c.JSON(http.StatusOK, response)
+}
+
+//ReadyHandler return 503 if the server is shutting down, a stream destination queue is full (events are rejected)
+//or any destination is degraded in strict readiness mode
+func (hh *HealthHandler) ReadyHandler(c *gin.Context) {
+	response, accepting := hh.check(hh.withErrors(c))
+	if appstatus.Instance.Idle || !accepting || (hh.strictReadiness && response.Status != OkStatus) {
+		c.JSON(http.StatusServiceUnavailable, response)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+//withErrors return true if the request has server admin token: errors might contain hosts and credentials details
+func (hh *HealthHandler) withErrors(c *gin.Context) bool {
+	return hh.adminToken != "" && c.GetHeader("X-Admin-Token") == hh.adminToken
+}
+
+//check return health of all destinations and false if any destination queue is full. Connectivity checks are run concurrently:
+//every goroutine writes only its destination health
+func (hh *HealthHandler) check(withErrors bool) (*HealthResponse, bool) {
+	response := &HealthResponse{Status: OkStatus, Destinations: map[string]*DestinationHealth{}, Schemas: &SchemasHealth{
+		ValidationSchemas:  hh.validator.Schemas(),
+		TrackingPlanEvents: hh.trackingPlan.EventsCount(),
+	}}
+	if appstatus.Instance.Idle {
+		response.Status = IdleStatus
+	}
+
+	accepting := true
+	var wg sync.WaitGroup
+	for destinationId, storageProxy := range hh.destinationService.GetAllStorages() {
+		destinationHealth := &DestinationHealth{Status: DestinationOk}
+		flushes := hh.tracker.Flushes(destinationId)
+		destinationHealth.LastSuccess = formatTime(flushes.LastSuccess)
+		destinationHealth.LastError = formatTime(flushes.LastError)
+		if flushes.Failing() {
+			destinationHealth.Status = DestinationFailing
+		}
+		if breaker.Instance.Enabled() {
+			destinationHealth.Circuit = breaker.Instance.Get(destinationId).Status().State
+			if destinationHealth.Circuit != breaker.StateClosed {
+				destinationHealth.Status = DestinationCircuitOpen
+			}
+		}
+		if queue, ok := hh.destinationService.GetEventQueue(destinationId); ok {
+			destinationHealth.Queue = &QueueHealth{SizeBytes: queue.SizeBytes(), Full: queue.IsFull()}
+			if queue.HasSpool() {
+				if spool, err := queue.Spool(); err == nil {
+					destinationHealth.Queue.SpoolBytes = spool.SizeBytes()
+				}
+			}
+			if destinationHealth.Queue.Full {
+				destinationHealth.Status = DestinationQueueFull
+				accepting = false
+			}
+		}
+		response.Destinations[destinationId] = destinationHealth
+
+		storage, ok := storageProxy.Get()
+		if !ok {
+			destinationHealth.Status = DestinationInitializing
+			continue
+		}
+		pinger, ok := storage.(storages.Pinger)
+		if !ok {
+			continue
+		}
+
+		wg.Add(1)
+		go func(destinationId string, destinationHealth *DestinationHealth) {
+			defer wg.Done()
+			if err := hh.ping(pinger); err != nil {
+				logging.Warnf("[%s] Destination connectivity check failed: %v", destinationId, err)
+				destinationHealth.Status = DestinationUnreachable
+				if withErrors {
+					destinationHealth.Error = err.Error()
+				}
+			}
+		}(destinationId, destinationHealth)
+	}
+	wg.Wait()
+
+	for _, destinationHealth := range response.Destinations {
+		if destinationHealth.Status != DestinationOk && response.Status == OkStatus {
+			response.Status = DegradedStatus
+		}
+	}
+
+	return response, accepting
+}
+
+//ping return errCheckTimeout if the check isn't finished in checkTimeout. The check goroutine isn't stopped then
+func (hh *HealthHandler) ping(pinger storages.Pinger) error {
+	result := make(chan error, 1)
+	go func() {
+		result <- pinger.Ping()
+	}()
+
+	select {
+	case err := <-result:
+		return err
+	case <-time.After(hh.checkTimeout):
+		return errCheckTimeout
+	}
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
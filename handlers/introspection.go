@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/destinations"
+	"github.com/jitsucom/eventnative/enrichment"
+	"github.com/jitsucom/eventnative/introspection"
+	"github.com/jitsucom/eventnative/middleware"
+	"github.com/jitsucom/eventnative/storages"
+	"github.com/jitsucom/eventnative/workspaces"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//ProcessorConfig is a destination events processing configuration without credentials
+type ProcessorConfig struct {
+	Events       string                   `json:"events,omitempty"`
+	Filter       string                   `json:"filter,omitempty"`
+	DataLayout   *storages.DataLayout     `json:"data_layout,omitempty"`
+	Enrichment   []*enrichment.RuleConfig `json:"enrichment,omitempty"`
+	BreakOnError bool                     `json:"break_on_error"`
+}
+
+type IntrospectedDestination struct {
+	Id          string           `json:"id"`
+	Type        string           `json:"type"`
+	Mode        string           `json:"mode"`
+	Initialized bool             `json:"initialized"`
+	Processor   *ProcessorConfig `json:"processor"`
+}
+
+type IntrospectedDestinationsResponse struct {
+	Destinations []*IntrospectedDestination `json:"destinations"`
+}
+
+type IntrospectedEventsResponse struct {
+	Events []introspection.Event `json:"events"`
+}
+
+type ThroughputResponse struct {
+	Destinations map[string]map[string]*introspection.Throughput `json:"destinations"`
+}
+
+//IntrospectionHandler is a read-only live pipeline introspection API: destinations with processing configs,
+//recent and failed events (per server in-memory buffers) and per table throughput
+type IntrospectionHandler struct {
+	destinationService *destinations.Service
+	recorder           *introspection.Recorder
+}
+
+func NewIntrospectionHandler(destinationService *destinations.Service, recorder *introspection.Recorder) *IntrospectionHandler {
+	return &IntrospectionHandler{destinationService: destinationService, recorder: recorder}
+}
+
+//DestinationsHandler return workspace (or all) destinations sorted by id
+func (ih *IntrospectionHandler) DestinationsHandler(c *gin.Context) {
+	workspaceId := middleware.GetWorkspaceId(c)
+
+	response := IntrospectedDestinationsResponse{Destinations: []*IntrospectedDestination{}}
+	for destinationId, destination := range ih.destinationService.GetConfig() {
+		if !workspaces.Owns(workspaceId, destinationId) {
+			continue
+		}
+
+		mode := destination.Mode
+		if mode == "" {
+			mode = storages.BatchMode
+		}
+		initialized := false
+		if storageProxy, ok := ih.destinationService.GetStorageById(destinationId); ok {
+			_, initialized = storageProxy.Get()
+		}
+		response.Destinations = append(response.Destinations, &IntrospectedDestination{
+			Id:          destinationId,
+			Type:        destination.Type,
+			Mode:        mode,
+			Initialized: initialized,
+			Processor: &ProcessorConfig{
+				Events:       destination.Events,
+				Filter:       destination.Filter,
+				DataLayout:   destination.DataLayout,
+				Enrichment:   destination.Enrichment,
+				BreakOnError: destination.BreakOnError,
+			},
+		})
+	}
+	sort.Slice(response.Destinations, func(i, j int) bool {
+		return response.Destinations[i].Id < response.Destinations[j].Id
+	})
+
+	c.JSON(http.StatusOK, response)
+}
+
+//RecentEventsHandler return the recent events of the destination from the newest. Accept optional limit query parameter
+func (ih *IntrospectionHandler) RecentEventsHandler(c *gin.Context) {
+	destinationId, limit, ok := ih.eventsParams(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, IntrospectedEventsResponse{Events: ih.recorder.Recent(destinationId, limit)})
+}
+
+//FailedEventsHandler return the last failed events of the destination with errors from the newest.
+//Accept optional limit query parameter
+func (ih *IntrospectionHandler) FailedEventsHandler(c *gin.Context) {
+	destinationId, limit, ok := ih.eventsParams(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, IntrospectedEventsResponse{Events: ih.recorder.LastFailed(destinationId, limit)})
+}
+
+//ThroughputHandler return per table stored rows of destinations. Accept optional destination_ids (comma separated) query parameter
+func (ih *IntrospectionHandler) ThroughputHandler(c *gin.Context) {
+	destinationIds := c.Query("destination_ids")
+	destinationsFilter := map[string]bool{}
+	if destinationIds != "" {
+		for _, destinationId := range strings.Split(destinationIds, ",") {
+			destinationsFilter[strings.TrimSpace(destinationId)] = true
+		}
+	}
+	workspaceId := middleware.GetWorkspaceId(c)
+
+	response := ThroughputResponse{Destinations: map[string]map[string]*introspection.Throughput{}}
+	for _, destinationId := range ih.recorder.DestinationIds() {
+		if len(destinationsFilter) > 0 && !destinationsFilter[destinationId] {
+			continue
+		}
+		if !workspaces.Owns(workspaceId, destinationId) {
+			continue
+		}
+
+		if throughput := ih.recorder.Throughput(destinationId); len(throughput) > 0 {
+			response.Destinations[destinationId] = throughput
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+//eventsParams return destination id path parameter and limit query parameter or false if the response has been written
+func (ih *IntrospectionHandler) eventsParams(c *gin.Context) (string, int, bool) {
+	destinationId := c.Param("destination_id")
+	workspaceId := middleware.GetWorkspaceId(c)
+	if !workspaces.Owns(workspaceId, destinationId) {
+		c.JSON(http.StatusForbidden, middleware.ErrorResponse{Message: fmt.Sprintf("Destination [%s] doesn't belong to workspace [%s]", destinationId, workspaceId)})
+		return "", 0, false
+	}
+
+	limit := defaultLimit
+	if limitStr := c.Query("limit"); limitStr != "" {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "limit must be positive int"})
+			return "", 0, false
+		}
+	}
+
+	return destinationId, limit, true
+}
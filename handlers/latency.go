@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/latency"
+	"github.com/jitsucom/eventnative/middleware"
+	"github.com/jitsucom/eventnative/workspaces"
+	"net/http"
+	"strings"
+)
+
+type LatencyResponse struct {
+	Destinations map[string]*latency.Stats `json:"destinations"`
+}
+
+//LatencyHandler return per destination percentiles of client -> server -> destination ack latencies
+type LatencyHandler struct {
+	tracker *latency.Tracker
+}
+
+func NewLatencyHandler(tracker *latency.Tracker) *LatencyHandler {
+	return &LatencyHandler{tracker: tracker}
+}
+
+//GetHandler accept optional destination_ids (comma separated) query parameter
+func (lh *LatencyHandler) GetHandler(c *gin.Context) {
+	destinationIds := c.Query("destination_ids")
+	destinationsFilter := map[string]bool{}
+	if destinationIds != "" {
+		for _, destinationId := range strings.Split(destinationIds, ",") {
+			destinationsFilter[strings.TrimSpace(destinationId)] = true
+		}
+	}
+	workspaceId := middleware.GetWorkspaceId(c)
+
+	response := LatencyResponse{Destinations: map[string]*latency.Stats{}}
+	for _, destinationId := range lh.tracker.DestinationIds() {
+		if len(destinationsFilter) > 0 && !destinationsFilter[destinationId] {
+			continue
+		}
+		if !workspaces.Owns(workspaceId, destinationId) {
+			continue
+		}
+
+		if stats := lh.tracker.Stats(destinationId); stats != nil {
+			response.Destinations[destinationId] = stats
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
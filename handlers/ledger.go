@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/ledger"
+	"github.com/jitsucom/eventnative/middleware"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const defaultLedgerLimit = 100
+
+type LedgerResponse struct {
+	Destinations map[string][]*ledger.Entry `json:"destinations"`
+}
+
+//LedgerHandler return batch files load history per destination from the ledger
+type LedgerHandler struct {
+	ledger *ledger.Ledger
+}
+
+func NewLedgerHandler(l *ledger.Ledger) *LedgerHandler {
+	return &LedgerHandler{ledger: l}
+}
+
+//GetHandler accept optional destination_ids (comma separated), status and limit (default 100 per destination) query parameters
+func (lh *LedgerHandler) GetHandler(c *gin.Context) {
+	limit := defaultLedgerLimit
+	if limitStr := c.Query("limit"); limitStr != "" {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit < 0 {
+			c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "limit must be non-negative integer"})
+			return
+		}
+	}
+	status := c.Query("status")
+
+	destinationIds := lh.ledger.Destinations()
+	if destinationIdsStr := c.Query("destination_ids"); destinationIdsStr != "" {
+		destinationIds = []string{}
+		for _, destinationId := range strings.Split(destinationIdsStr, ",") {
+			destinationIds = append(destinationIds, strings.TrimSpace(destinationId))
+		}
+	}
+
+	response := LedgerResponse{Destinations: map[string][]*ledger.Entry{}}
+	for _, destinationId := range destinationIds {
+		entries := []*ledger.Entry{}
+		for _, entry := range lh.ledger.History(destinationId, 0) {
+			if limit > 0 && len(entries) >= limit {
+				break
+			}
+			if status == "" || entry.Status == status {
+				entries = append(entries, entry)
+			}
+		}
+		response.Destinations[destinationId] = entries
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/middleware"
+	"github.com/jitsucom/eventnative/migration"
+	"github.com/jitsucom/eventnative/workspaces"
+	"net/http"
+	"strings"
+)
+
+//BackfillRequest is a backfill of the declared column rename: old values of from column are copied into the new column
+type BackfillRequest struct {
+	DestinationId string `json:"destination_id"`
+	Table         string `json:"table"`
+	From          string `json:"from"`
+}
+
+type MigrationsResponse struct {
+	Migrations []*migration.Migration `json:"migrations"`
+}
+
+type MigrationsHandler struct {
+	migrationService *migration.Service
+}
+
+func NewMigrationsHandler(migrationService *migration.Service) *MigrationsHandler {
+	return &MigrationsHandler{migrationService: migrationService}
+}
+
+//GetHandler return declared column renames with backfill progress. Accept optional destination_ids (comma separated) query parameter
+func (mh *MigrationsHandler) GetHandler(c *gin.Context) {
+	destinationIds := c.Query("destination_ids")
+	destinationsFilter := map[string]bool{}
+	if destinationIds != "" {
+		for _, destinationId := range strings.Split(destinationIds, ",") {
+			destinationsFilter[strings.TrimSpace(destinationId)] = true
+		}
+	}
+
+	workspaceId := middleware.GetWorkspaceId(c)
+	migrations := []*migration.Migration{}
+	for _, m := range mh.migrationService.Migrations(destinationsFilter) {
+		if workspaces.Owns(workspaceId, m.DestinationId) {
+			migrations = append(migrations, m)
+		}
+	}
+
+	c.JSON(http.StatusOK, MigrationsResponse{Migrations: migrations})
+}
+
+//BackfillHandler start (or resume) backfill of the renamed column and return its progress
+func (mh *MigrationsHandler) BackfillHandler(c *gin.Context) {
+	req := &BackfillRequest{}
+	if err := c.BindJSON(req); err != nil {
+		logging.Errorf("Error parsing backfill body: %v", err)
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Failed to parse body", Error: err.Error()})
+		return
+	}
+
+	if workspaceId := middleware.GetWorkspaceId(c); !workspaces.Owns(workspaceId, req.DestinationId) {
+		c.JSON(http.StatusForbidden, middleware.ErrorResponse{Message: fmt.Sprintf("Destination [%s] doesn't belong to workspace [%s]", req.DestinationId, workspaceId)})
+		return
+	}
+
+	progress, err := mh.migrationService.Backfill(req.DestinationId, req.Table, req.From)
+	if err != nil {
+		logging.Errorf("Error starting backfill of [%s] column in [%s] table of [%s]: %v", req.From, req.Table, req.DestinationId, err)
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Failed to start backfill", Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, progress)
+}
+
+//ProgressHandler return backfill progress. Require destination_id, table and from query parameters
+func (mh *MigrationsHandler) ProgressHandler(c *gin.Context) {
+	destinationId := c.Query("destination_id")
+	if !workspaces.Owns(middleware.GetWorkspaceId(c), destinationId) {
+		c.JSON(http.StatusNotFound, middleware.ErrorResponse{Message: "Backfill wasn't found"})
+		return
+	}
+
+	progress, err := mh.migrationService.Progress(destinationId, c.Query("table"), c.Query("from"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, middleware.ErrorResponse{Message: "Failed to get backfill progress", Error: err.Error()})
+		return
+	}
+	if progress == nil {
+		c.JSON(http.StatusNotFound, middleware.ErrorResponse{Message: "Backfill wasn't found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, progress)
+}
+
+//ApplyPlanRequest is a reviewed table migration plan which should be applied
+type ApplyPlanRequest struct {
+	DestinationId string `json:"destination_id"`
+	Table         string `json:"table"`
+}
+
+type MigrationPlansResponse struct {
+	Plans []*migration.DestinationPlan `json:"plans"`
+}
+
+//PlansHandler return table migration plans (steps and DDL statements) which are waiting for review.
+//Accept optional destination_ids (comma separated) query parameter
+func (mh *MigrationsHandler) PlansHandler(c *gin.Context) {
+	destinationIds := c.Query("destination_ids")
+	destinationsFilter := map[string]bool{}
+	if destinationIds != "" {
+		for _, destinationId := range strings.Split(destinationIds, ",") {
+			destinationsFilter[strings.TrimSpace(destinationId)] = true
+		}
+	}
+
+	workspaceId := middleware.GetWorkspaceId(c)
+	plans := []*migration.DestinationPlan{}
+	for _, plan := range mh.migrationService.Plans(destinationsFilter) {
+		if workspaces.Owns(workspaceId, plan.DestinationId) {
+			plans = append(plans, plan)
+		}
+	}
+
+	c.JSON(http.StatusOK, MigrationPlansResponse{Plans: plans})
+}
+
+//ApplyPlanHandler apply reviewed table migration plan
+func (mh *MigrationsHandler) ApplyPlanHandler(c *gin.Context) {
+	req := &ApplyPlanRequest{}
+	if err := c.BindJSON(req); err != nil {
+		logging.Errorf("Error parsing apply migration plan body: %v", err)
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Failed to parse body", Error: err.Error()})
+		return
+	}
+
+	if workspaceId := middleware.GetWorkspaceId(c); !workspaces.Owns(workspaceId, req.DestinationId) {
+		c.JSON(http.StatusForbidden, middleware.ErrorResponse{Message: fmt.Sprintf("Destination [%s] doesn't belong to workspace [%s]", req.DestinationId, workspaceId)})
+		return
+	}
+
+	if err := mh.migrationService.ApplyPlan(req.DestinationId, req.Table); err != nil {
+		logging.Errorf("Error applying migration plan of [%s] table of [%s]: %v", req.Table, req.DestinationId, err)
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Failed to apply migration plan", Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, middleware.OkResponse())
+}
@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/recovery"
+	"net/http"
+)
+
+//RecoveryHandler return startup recovery report: batches which have been found mid-upload and their resolutions
+type RecoveryHandler struct {
+	recovery *recovery.Recovery
+}
+
+func NewRecoveryHandler(r *recovery.Recovery) *RecoveryHandler {
+	return &RecoveryHandler{recovery: r}
+}
+
+func (rh *RecoveryHandler) GetHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, rh.recovery.Report())
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/middleware"
+	"github.com/jitsucom/eventnative/replay"
+	"github.com/jitsucom/eventnative/workspaces"
+	"net/http"
+	"strings"
+	"time"
+)
+
+//ArchiveReplayRequest is a replay of archived raw events with _timestamp in [from, to) (RFC3339) into the destination
+//config: current (default) - events are processed with the current destination configuration,
+//as_of - with the destination processing configuration which was active at events _timestamp
+type ArchiveReplayRequest struct {
+	DestinationId string    `json:"destination_id"`
+	From          time.Time `json:"from"`
+	To            time.Time `json:"to"`
+	Config        string    `json:"config"`
+}
+
+type ReplayTasksResponse struct {
+	Tasks []*replay.Task `json:"tasks"`
+}
+
+type ReplayHandler struct {
+	replayService *replay.Service
+}
+
+func NewReplayHandler(replayService *replay.Service) *ReplayHandler {
+	return &ReplayHandler{replayService: replayService}
+}
+
+//PostHandler start replay task and return it
+func (rh *ReplayHandler) PostHandler(c *gin.Context) {
+	req := &ArchiveReplayRequest{}
+	if err := c.BindJSON(req); err != nil {
+		logging.Errorf("Error parsing replay body: %v", err)
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Failed to parse body", Error: err.Error()})
+		return
+	}
+
+	if workspaceId := middleware.GetWorkspaceId(c); !workspaces.Owns(workspaceId, req.DestinationId) {
+		c.JSON(http.StatusForbidden, middleware.ErrorResponse{Message: fmt.Sprintf("Destination [%s] doesn't belong to workspace [%s]", req.DestinationId, workspaceId)})
+		return
+	}
+
+	task, err := rh.replayService.Replay(req.DestinationId, req.From, req.To, req.Config)
+	if err != nil {
+		logging.Errorf("Error starting replay of archived events into [%s]: %v", req.DestinationId, err)
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Failed to start replay", Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, task)
+}
+
+//GetHandler return replay tasks. Accept optional destination_ids (comma separated) query parameter
+func (rh *ReplayHandler) GetHandler(c *gin.Context) {
+	destinationIds := c.Query("destination_ids")
+	destinationsFilter := map[string]bool{}
+	if destinationIds != "" {
+		for _, destinationId := range strings.Split(destinationIds, ",") {
+			destinationsFilter[strings.TrimSpace(destinationId)] = true
+		}
+	}
+
+	workspaceId := middleware.GetWorkspaceId(c)
+	tasks := []*replay.Task{}
+	for _, task := range rh.replayService.Tasks(destinationsFilter) {
+		if workspaces.Owns(workspaceId, task.DestinationId) {
+			tasks = append(tasks, task)
+		}
+	}
+
+	c.JSON(http.StatusOK, ReplayTasksResponse{Tasks: tasks})
+}
+
+//TaskHandler return replay task by id
+func (rh *ReplayHandler) TaskHandler(c *gin.Context) {
+	task, ok := rh.replayService.Get(c.Param("id"))
+	if !ok || !workspaces.Owns(middleware.GetWorkspaceId(c), task.DestinationId) {
+		c.JSON(http.StatusNotFound, middleware.ErrorResponse{Message: "Replay task wasn't found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, task)
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/middleware"
+	"github.com/jitsucom/eventnative/reports"
+	"net/http"
+	"time"
+)
+
+const defaultReportPeriod = 24 * time.Hour
+
+type ReportsResponse struct {
+	Reports []*reports.Report `json:"reports"`
+}
+
+type ReportsHandler struct {
+	reportsService *reports.Service
+}
+
+func NewReportsHandler(reportsService *reports.Service) *ReportsHandler {
+	return &ReportsHandler{reportsService: reportsService}
+}
+
+//GetHandler return workspaces usage and delivery SLA reports over [from, to) (RFC3339, default the last 24 hours)
+//Server admin token might filter by workspace_id query parameter, workspace admin token gets only the authorized workspace
+func (rh *ReportsHandler) GetHandler(c *gin.Context) {
+	to := time.Now().UTC()
+	if toStr := c.Query("to"); toStr != "" {
+		t, err := time.Parse(time.RFC3339, toStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Error parsing to query parameter. Accepted datetime format: " + time.RFC3339, Error: err.Error()})
+			return
+		}
+		to = t
+	}
+
+	from := to.Add(-defaultReportPeriod)
+	if fromStr := c.Query("from"); fromStr != "" {
+		t, err := time.Parse(time.RFC3339, fromStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Error parsing from query parameter. Accepted datetime format: " + time.RFC3339, Error: err.Error()})
+			return
+		}
+		from = t
+	}
+
+	if !from.Before(to) {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: fmt.Sprintf("from [%s] must be before to [%s]", from.Format(time.RFC3339), to.Format(time.RFC3339))})
+		return
+	}
+
+	workspaceId := middleware.GetWorkspaceId(c)
+	if workspaceId == "" {
+		workspaceId = c.Query("workspace_id")
+	}
+
+	c.JSON(http.StatusOK, ReportsResponse{Reports: rh.reportsService.Reports(workspaceId, from, to)})
+}
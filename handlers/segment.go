@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/middleware"
+	"net/http"
+	"time"
+)
+
+const (
+	segmentBatch  = "batch"
+	segmentImport = "import"
+
+	segmentWriteKey = "writeKey"
+)
+
+//SegmentResponse is a Segment HTTP Tracking API response
+type SegmentResponse struct {
+	Success bool `json:"success"`
+}
+
+//SegmentHandler accept Segment HTTP Tracking API messages: track, identify, page, screen, group, alias, batch (import)
+//and analytics.js short paths (t, i, p, s, g, a) and process them as JS SDK events.
+//Segment write key must be EventNative client or server secret. It is taken from Basic auth username (Segment libraries),
+//writeKey body field (analytics.js) or token query parameter
+type SegmentHandler struct {
+	eventHandler *EventHandler
+}
+
+func NewSegmentHandler(eventHandler *EventHandler) *SegmentHandler {
+	return &SegmentHandler{eventHandler: eventHandler}
+}
+
+func (sh *SegmentHandler) Handler(c *gin.Context) {
+	callType := c.Param("type")
+	isBatch := callType == segmentBatch || callType == segmentImport
+	if !isBatch && events.SegmentType(callType) == "" {
+		c.JSON(http.StatusNotFound, middleware.ErrorResponse{Message: fmt.Sprintf("Unknown Segment API call: %s", callType)})
+		return
+	}
+
+	body := map[string]interface{}{}
+	if err := c.BindJSON(&body); err != nil {
+		logging.Errorf("Error parsing Segment body: %v", err)
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Failed to parse body", Error: err.Error()})
+		return
+	}
+
+	token := extractSegmentWriteKey(c.Request, body)
+	if !authorizeThirdPartyToken(c, token) {
+		return
+	}
+
+	messages, err := segmentMessages(body, isBatch)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Malformed Segment payload", Error: err.Error()})
+		return
+	}
+
+	//convert all messages before processing: malformed batch isn't processed at all
+	ip := extractIp(c.Request)
+	now := time.Now().UTC()
+	facts := make([]events.Fact, 0, len(messages))
+	for i, message := range messages {
+		fact, err := events.ConvertSegment(message, callType, ip, now)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: fmt.Sprintf("Malformed Segment message #%d", i), Error: err.Error()})
+			return
+		}
+		facts = append(facts, fact)
+	}
+
+	if !processThirdPartyFacts(c, sh.eventHandler, token, facts, "Segment messages") {
+		return
+	}
+
+	c.JSON(http.StatusOK, SegmentResponse{Success: true})
+}
+
+//extractSegmentWriteKey return write key from Basic auth username, body writeKey or token query parameter
+func extractSegmentWriteKey(r *http.Request, body map[string]interface{}) string {
+	if username, _, ok := r.BasicAuth(); ok && username != "" {
+		return username
+	}
+	if writeKey, ok := body[segmentWriteKey].(string); ok && writeKey != "" {
+		return writeKey
+	}
+	return r.URL.Query().Get(middleware.TokenName)
+}
+
+//segmentMessages return single message or batch messages with batch context merged into every message context
+func segmentMessages(body map[string]interface{}, isBatch bool) ([]map[string]interface{}, error) {
+	if !isBatch {
+		delete(body, segmentWriteKey)
+		return []map[string]interface{}{body}, nil
+	}
+
+	batch, ok := body["batch"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("batch field must be an array")
+	}
+
+	batchContext, _ := body["context"].(map[string]interface{})
+	messages := make([]map[string]interface{}, 0, len(batch))
+	for i, item := range batch {
+		message, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("batch message #%d must be an object", i)
+		}
+
+		if len(batchContext) > 0 {
+			context := map[string]interface{}{}
+			for k, v := range batchContext {
+				context[k] = v
+			}
+			if messageContext, ok := message["context"].(map[string]interface{}); ok {
+				for k, v := range messageContext {
+					context[k] = v
+				}
+			}
+			message["context"] = context
+		}
+		messages = append(messages, message)
+	}
+
+	return messages, nil
+}
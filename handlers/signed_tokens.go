@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/appconfig"
+	"github.com/jitsucom/eventnative/authorization"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/middleware"
+	"github.com/jitsucom/eventnative/timestamp"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var ingestionPathsByScope = map[string]string{
+	authorization.JsScope:  "/api/v1/event",
+	authorization.ApiScope: "/api/v1/s2s/event",
+}
+
+type SignTokenRequest struct {
+	Token  string `json:"token"`
+	Scope  string `json:"scope"`
+	TTLSec int64  `json:"ttl_sec"`
+}
+
+type SignTokenResponse struct {
+	Token     string `json:"token"`
+	ExpiresAt string `json:"expires_at"`
+	//ingestion url with signed token. Relative if server.public_url isn't configured
+	Url string `json:"url"`
+}
+
+//SignedTokensHandler mints short-lived signed ingestion tokens for edge workers or mobile clients
+type SignedTokensHandler struct {
+	serverPublicUrl string
+}
+
+func NewSignedTokensHandler(serverPublicUrl string) *SignedTokensHandler {
+	return &SignedTokensHandler{serverPublicUrl: strings.TrimSuffix(serverPublicUrl, "/")}
+}
+
+func (sth *SignedTokensHandler) SignHandler(c *gin.Context) {
+	req := &SignTokenRequest{}
+	if err := c.BindJSON(req); err != nil {
+		logging.Errorf("Error parsing sign token body: %v", err)
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Failed to parse body", Error: err.Error()})
+		return
+	}
+
+	if req.Token == "" {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "token is required field"})
+		return
+	}
+
+	if req.Scope == "" {
+		req.Scope = authorization.JsScope
+	}
+
+	path, ok := ingestionPathsByScope[req.Scope]
+	if !ok {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "scope must be one of: " + authorization.JsScope + ", " + authorization.ApiScope})
+		return
+	}
+
+	signed, expiresAt, err := appconfig.Instance.AuthorizationService.SignToken(req.Token, req.Scope, time.Duration(req.TTLSec)*time.Second)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Failed to sign token", Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, SignTokenResponse{
+		Token:     signed,
+		ExpiresAt: expiresAt.Format(timestamp.Layout),
+		Url:       sth.serverPublicUrl + path + "?" + middleware.TokenName + "=" + url.QueryEscape(signed),
+	})
+}
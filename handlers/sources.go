@@ -2,12 +2,16 @@ package handlers
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/drivers"
 	"github.com/jitsucom/eventnative/logging"
 	"github.com/jitsucom/eventnative/middleware"
 	"github.com/jitsucom/eventnative/sources"
 	"net/http"
+	"strconv"
 )
 
+const defaultSourceRunsLimit = 20
+
 type SourceSyncStatusResponse struct {
 	Statuses []SourceSyncStatus `json:"statuses"`
 }
@@ -18,6 +22,14 @@ type SourceSyncStatus struct {
 	Logs       string `json:"logs"`
 }
 
+type SourceRunsResponse struct {
+	Runs map[string][]*sources.SyncRun `json:"runs"`
+}
+
+type SourceDiscoverResponse struct {
+	Collections []*drivers.Collection `json:"collections"`
+}
+
 type SourcesHandler struct {
 	sourcesService *sources.Service
 }
@@ -26,6 +38,7 @@ func NewSourcesHandler(sourcesService *sources.Service) *SourcesHandler {
 	return &SourcesHandler{sourcesService: sourcesService}
 }
 
+//SyncHandler run manual sync of all source collections or only of collection query parameter
 func (sh *SourcesHandler) SyncHandler(c *gin.Context) {
 	sourceId := c.Param("id")
 	if sourceId == "" {
@@ -33,7 +46,7 @@ func (sh *SourcesHandler) SyncHandler(c *gin.Context) {
 		return
 	}
 
-	err := sh.sourcesService.Sync(sourceId)
+	err := sh.sourcesService.Sync(sourceId, c.Query("collection"), sources.ManualTrigger)
 	if err != nil {
 		logging.Error(err)
 		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Sync failed", Error: err.Error()})
@@ -83,3 +96,50 @@ func (sh *SourcesHandler) StatusHandler(c *gin.Context) {
 
 	c.JSON(http.StatusOK, SourceSyncStatusResponse{Statuses: statuses})
 }
+
+//RunsHandler return sync runs history per collection from the newest to the oldest. Accept optional limit
+//(default 20 per collection) query parameter
+func (sh *SourcesHandler) RunsHandler(c *gin.Context) {
+	sourceId := c.Param("id")
+	if sourceId == "" {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "id is required path parameter"})
+		return
+	}
+
+	limit := defaultSourceRunsLimit
+	if limitStr := c.Query("limit"); limitStr != "" {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "limit must be positive int"})
+			return
+		}
+	}
+
+	runs, err := sh.sourcesService.GetRuns(sourceId, limit)
+	if err != nil {
+		logging.Error(err)
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Getting runs failed", Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, SourceRunsResponse{Runs: runs})
+}
+
+//DiscoverHandler return collections of the connector source
+func (sh *SourcesHandler) DiscoverHandler(c *gin.Context) {
+	sourceId := c.Param("id")
+	if sourceId == "" {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "id is required path parameter"})
+		return
+	}
+
+	collections, err := sh.sourcesService.Discover(sourceId)
+	if err != nil {
+		logging.Error(err)
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Discovery failed", Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, SourceDiscoverResponse{Collections: collections})
+}
@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/trackingplan"
+	"net/http"
+)
+
+//TrackingPlanHandler return tracking plan conformance report of the current instance
+type TrackingPlanHandler struct {
+	plan *trackingplan.Plan
+}
+
+func NewTrackingPlanHandler(plan *trackingplan.Plan) *TrackingPlanHandler {
+	return &TrackingPlanHandler{plan: plan}
+}
+
+func (tph *TrackingPlanHandler) ReportHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, tph.plan.Report())
+}
@@ -0,0 +1,317 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/middleware"
+	"github.com/jitsucom/eventnative/parsers"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	//time allowed to write a message to the client
+	wsWriteWait = 10 * time.Second
+	//time allowed to read the next message or pong from the client
+	wsPongWait = 60 * time.Second
+	//send pings to the client with this period. Must be less than wsPongWait
+	wsPingPeriod = (wsPongWait * 9) / 10
+	//maximum message size allowed from the client
+	wsMaxMessageSize = 1024 * 1024
+
+	ackStatusOk    = "ok"
+	ackStatusError = "error"
+
+	//ack query parameter values: ack every message or send WebSocketPeriodicAck every ack_interval_ms
+	ackModeMessage  = "message"
+	ackModePeriodic = "periodic"
+
+	defaultAckInterval = time.Second
+	minAckInterval     = 100 * time.Millisecond
+	//max rejected messages details in one periodic ack
+	maxPeriodicAckErrors = 100
+)
+
+//WebSocketAck is sent to the client on every incoming message
+//MessageId is a sequence number of the message in the connection (starts from 1)
+type WebSocketAck struct {
+	MessageId int64  `json:"message_id"`
+	EventId   string `json:"event_id,omitempty"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	//client version deprecation warning
+	Warning string `json:"warning,omitempty"`
+}
+
+//WebSocketPeriodicAck is sent to the client every ack interval in periodic ack mode (only if there are new messages)
+//Accepted and Rejected are counts of messages since the previous ack. All messages up to LastMessageId have been processed.
+//Errors contain up to 100 rejected messages details
+type WebSocketPeriodicAck struct {
+	LastMessageId int64          `json:"last_message_id"`
+	Accepted      int64          `json:"accepted"`
+	Rejected      int64          `json:"rejected"`
+	Errors        []WebSocketAck `json:"errors,omitempty"`
+	//client version deprecation warning
+	Warning string `json:"warning,omitempty"`
+}
+
+//WebSocketHandler accept events via long-lived websocket connection
+//every message must be a JSON event object and is acknowledged with WebSocketAck (default)
+//or counted in WebSocketPeriodicAck (?ack=periodic&ack_interval_ms=1000) for high-frequency clients
+type WebSocketHandler struct {
+	eventHandler         *EventHandler
+	isAllowedOriginsFunc func(string) ([]string, bool)
+}
+
+func NewWebSocketHandler(eventHandler *EventHandler, isAllowedOriginsFunc func(string) ([]string, bool)) *WebSocketHandler {
+	return &WebSocketHandler{eventHandler: eventHandler, isAllowedOriginsFunc: isAllowedOriginsFunc}
+}
+
+//Handler upgrade authorized request to websocket connection and serve it until the client disconnects
+func (wsh *WebSocketHandler) Handler(c *gin.Context) {
+	iface, ok := c.Get(middleware.TokenName)
+	if !ok {
+		logging.SystemError("Token wasn't found in context")
+		return
+	}
+	token := iface.(string)
+
+	origins, _ := wsh.isAllowedOriginsFunc(token)
+	upgrader := websocket.Upgrader{
+		CheckOrigin: func(r *http.Request) bool {
+			reqOrigin := r.Header.Get("Origin")
+			//not browser clients don't send Origin header
+			if reqOrigin == "" {
+				return true
+			}
+			return middleware.IsOriginAllowed(origins, reqOrigin)
+		},
+	}
+
+	//upgrader writes http error response itself
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		logging.Errorf("Error upgrading connection to websocket: %v", err)
+		return
+	}
+
+	session := &webSocketSession{
+		conn:         conn,
+		token:        token,
+		request:      c.Request,
+		eventHandler: wsh.eventHandler,
+	}
+	if c.Query("ack") == ackModePeriodic {
+		session.periodic = &periodicAcks{interval: parseAckInterval(c.Query("ack_interval_ms"))}
+	}
+	session.serve()
+}
+
+//parseAckInterval return ack interval from milliseconds string or default value (not less than 100 ms)
+func parseAckInterval(value string) time.Duration {
+	ms, err := strconv.Atoi(value)
+	if err != nil || ms <= 0 {
+		return defaultAckInterval
+	}
+	interval := time.Duration(ms) * time.Millisecond
+	if interval < minAckInterval {
+		return minAckInterval
+	}
+	return interval
+}
+
+//webSocketSession is a single client connection
+//per-message acks are written from the reading goroutine, periodic acks - from the acking goroutine (only one writer in every mode),
+//pings are written via concurrent safe WriteControl
+type webSocketSession struct {
+	conn  *websocket.Conn
+	token string
+	//upgrade request is used for ip and client version headers
+	request      *http.Request
+	eventHandler *EventHandler
+	//nil in per-message ack mode
+	periodic *periodicAcks
+}
+
+//periodicAcks accumulate processing results between periodic acks
+type periodicAcks struct {
+	sync.Mutex
+
+	interval time.Duration
+	pending  WebSocketPeriodicAck
+	dirty    bool
+}
+
+//add put the message processing result into the pending ack
+func (pa *periodicAcks) add(ack WebSocketAck) {
+	pa.Lock()
+	defer pa.Unlock()
+
+	pa.dirty = true
+	pa.pending.LastMessageId = ack.MessageId
+	if ack.Warning != "" {
+		pa.pending.Warning = ack.Warning
+	}
+	if ack.Status == ackStatusOk {
+		pa.pending.Accepted++
+		return
+	}
+	pa.pending.Rejected++
+	if len(pa.pending.Errors) < maxPeriodicAckErrors {
+		pa.pending.Errors = append(pa.pending.Errors, ack)
+	}
+}
+
+//cut return pending ack and reset counters. Return false if there are no new messages
+func (pa *periodicAcks) cut() (WebSocketPeriodicAck, bool) {
+	pa.Lock()
+	defer pa.Unlock()
+
+	if !pa.dirty {
+		return WebSocketPeriodicAck{}, false
+	}
+	ack := pa.pending
+	pa.pending = WebSocketPeriodicAck{LastMessageId: ack.LastMessageId}
+	pa.dirty = false
+	return ack, true
+}
+
+func (wss *webSocketSession) serve() {
+	defer wss.conn.Close()
+
+	wss.conn.SetReadLimit(wsMaxMessageSize)
+	wss.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	wss.conn.SetPongHandler(func(string) error {
+		return wss.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	done := make(chan struct{})
+	defer close(done)
+	go wss.ping(done)
+
+	var readErr error
+	if wss.periodic != nil {
+		//client close frame is answered only after the final periodic ack
+		wss.conn.SetCloseHandler(func(int, string) error { return nil })
+		stop, flushed := make(chan struct{}), make(chan struct{})
+		go wss.ackPeriodically(stop, flushed)
+		defer func() {
+			close(stop)
+			<-flushed
+			if closeErr, ok := readErr.(*websocket.CloseError); ok {
+				wss.replyClose(closeErr.Code)
+			}
+		}()
+	}
+
+	var messageId int64
+	for {
+		_, message, err := wss.conn.ReadMessage()
+		if err != nil {
+			readErr = err
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				logging.Errorf("Error reading websocket message: %v", err)
+			}
+			return
+		}
+		//any message extends the deadline
+		wss.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+
+		messageId++
+		ack := wss.process(messageId, message)
+		if wss.periodic != nil {
+			wss.periodic.add(ack)
+			continue
+		}
+
+		wss.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+		if err := wss.conn.WriteJSON(ack); err != nil {
+			logging.Errorf("Error writing websocket ack: %v", err)
+			return
+		}
+	}
+}
+
+func (wss *webSocketSession) process(messageId int64, message []byte) WebSocketAck {
+	payload, err := parsers.ParseJson(message)
+	if err != nil {
+		return WebSocketAck{MessageId: messageId, Status: ackStatusError, Error: "Failed to parse message: " + err.Error()}
+	}
+
+	ack := WebSocketAck{MessageId: messageId, Status: ackStatusOk}
+	deprecation, err := wss.eventHandler.ProcessEvent(wss.token, payload, wss.request)
+	ack.EventId = events.ExtractEventId(payload)
+	if deprecation != nil {
+		ack.Warning = deprecation.String()
+	}
+	if err != nil {
+		logging.Error("Error processing websocket event:", err)
+		ack.Status = ackStatusError
+		ack.Error = "Error processing event: " + err.Error()
+	}
+
+	return ack
+}
+
+//ackPeriodically write pending periodic ack every interval and the last one when stop is closed (after the reading goroutine has stopped)
+//Write error closes the connection (the reading goroutine stops)
+func (wss *webSocketSession) ackPeriodically(stop, flushed chan struct{}) {
+	defer close(flushed)
+	ticker := time.NewTicker(wss.periodic.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			if err := wss.writePeriodicAck(); err != nil {
+				logging.Debugf("Error writing final websocket periodic ack: %v", err)
+			}
+			return
+		case <-ticker.C:
+			if err := wss.writePeriodicAck(); err != nil {
+				logging.Errorf("Error writing websocket periodic ack: %v", err)
+				wss.conn.Close()
+				return
+			}
+		}
+	}
+}
+
+//writePeriodicAck write pending periodic ack if there are new messages
+func (wss *webSocketSession) writePeriodicAck() error {
+	ack, ok := wss.periodic.cut()
+	if !ok {
+		return nil
+	}
+	wss.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	return wss.conn.WriteJSON(ack)
+}
+
+//replyClose answer client close frame (the same way as the websocket default close handler)
+func (wss *webSocketSession) replyClose(code int) {
+	message := []byte{}
+	if code != websocket.CloseNoStatusReceived {
+		message = websocket.FormatCloseMessage(code, "")
+	}
+	wss.conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(wsWriteWait))
+}
+
+func (wss *webSocketSession) ping(done chan struct{}) {
+	ticker := time.NewTicker(wsPingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := wss.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+				return
+			}
+		}
+	}
+}
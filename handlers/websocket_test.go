@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/jitsucom/eventnative/middleware"
+	"github.com/stretchr/testify/require"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseAckInterval(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected time.Duration
+	}{
+		{"Empty", "", defaultAckInterval},
+		{"Not a number", "abc", defaultAckInterval},
+		{"Zero", "0", defaultAckInterval},
+		{"Negative", "-100", defaultAckInterval},
+		{"Less than min", "10", minAckInterval},
+		{"Min", "100", minAckInterval},
+		{"Custom", "2500", 2500 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, parseAckInterval(tt.input))
+		})
+	}
+}
+
+func TestPeriodicAcks(t *testing.T) {
+	pa := &periodicAcks{interval: time.Second}
+
+	_, ok := pa.cut()
+	require.False(t, ok, "Empty acks mustn't be cut")
+
+	pa.add(WebSocketAck{MessageId: 1, Status: ackStatusOk})
+	pa.add(WebSocketAck{MessageId: 2, Status: ackStatusError, Error: "err2"})
+	pa.add(WebSocketAck{MessageId: 3, Status: ackStatusOk, Warning: "deprecated"})
+
+	ack, ok := pa.cut()
+	require.True(t, ok)
+	require.Equal(t, WebSocketPeriodicAck{
+		LastMessageId: 3,
+		Accepted:      2,
+		Rejected:      1,
+		Errors:        []WebSocketAck{{MessageId: 2, Status: ackStatusError, Error: "err2"}},
+		Warning:       "deprecated",
+	}, ack)
+
+	_, ok = pa.cut()
+	require.False(t, ok, "Acks without new messages mustn't be cut")
+
+	//counters are reset, last message id is kept
+	for i := int64(4); i < 4+maxPeriodicAckErrors+10; i++ {
+		pa.add(WebSocketAck{MessageId: i, Status: ackStatusError})
+	}
+	ack, ok = pa.cut()
+	require.True(t, ok)
+	require.Equal(t, int64(3+maxPeriodicAckErrors+10), ack.LastMessageId)
+	require.Equal(t, int64(0), ack.Accepted)
+	require.Equal(t, int64(maxPeriodicAckErrors+10), ack.Rejected)
+	require.Len(t, ack.Errors, maxPeriodicAckErrors)
+	require.Equal(t, "", ack.Warning)
+}
+
+func TestWebSocketMessageAcks(t *testing.T) {
+	conn := dialTestWebSocket(t, "")
+	defer conn.Close()
+
+	for i := int64(1); i <= 3; i++ {
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("not a json")))
+
+		ack := WebSocketAck{}
+		require.NoError(t, conn.ReadJSON(&ack))
+		require.Equal(t, i, ack.MessageId)
+		require.Equal(t, ackStatusError, ack.Status)
+		require.True(t, strings.HasPrefix(ack.Error, "Failed to parse message"), ack.Error)
+	}
+}
+
+func TestWebSocketPeriodicAcks(t *testing.T) {
+	conn := dialTestWebSocket(t, "?ack=periodic&ack_interval_ms=100")
+	defer conn.Close()
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("not a json")))
+	}
+
+	ack := WebSocketPeriodicAck{}
+	require.NoError(t, conn.ReadJSON(&ack))
+	require.Equal(t, int64(3), ack.LastMessageId)
+	require.Equal(t, int64(0), ack.Accepted)
+	require.Equal(t, int64(3), ack.Rejected)
+	require.Len(t, ack.Errors, 3)
+}
+
+func TestWebSocketPeriodicAcksFlushOnClose(t *testing.T) {
+	//interval is longer than the test: only the final ack is written
+	conn := dialTestWebSocket(t, "?ack=periodic&ack_interval_ms=60000")
+	defer conn.Close()
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("not a json")))
+	}
+	require.NoError(t, conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")))
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	ack := WebSocketPeriodicAck{}
+	require.NoError(t, conn.ReadJSON(&ack))
+	require.Equal(t, int64(2), ack.LastMessageId)
+	require.Equal(t, int64(2), ack.Rejected)
+
+	//close frame is answered after the final ack
+	_, _, err := conn.ReadMessage()
+	require.True(t, websocket.IsCloseError(err, websocket.CloseNormalClosure), "Expected close error: %v", err)
+}
+
+//dialTestWebSocket serve WebSocketHandler with authorized token and return client connection
+func dialTestWebSocket(t *testing.T, query string) *websocket.Conn {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	wsHandler := NewWebSocketHandler(nil, func(string) ([]string, bool) { return nil, true })
+	router.GET("/ws", func(c *gin.Context) {
+		c.Set(middleware.TokenName, "token")
+	}, wsHandler.Handler)
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws"+query, nil)
+	require.NoError(t, err)
+	return conn
+}
@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/middleware"
+	"github.com/jitsucom/eventnative/workspaces"
+	"net/http"
+)
+
+type WorkspacesResponse struct {
+	Workspaces []*workspaces.Info `json:"workspaces"`
+}
+
+type WorkspacesHandler struct {
+	workspacesService *workspaces.Service
+}
+
+func NewWorkspacesHandler(workspacesService *workspaces.Service) *WorkspacesHandler {
+	return &WorkspacesHandler{workspacesService: workspacesService}
+}
+
+//GetHandler return all workspaces for server admin token or only the authorized one for workspace admin token
+func (wh *WorkspacesHandler) GetHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, WorkspacesResponse{Workspaces: wh.workspacesService.Infos(middleware.GetWorkspaceId(c))})
+}
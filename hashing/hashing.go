@@ -0,0 +1,89 @@
+package hashing
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+)
+
+const (
+	FNV     = "fnv"
+	Murmur2 = "murmur2"
+	Murmur3 = "murmur3"
+	XXHash  = "xxhash"
+
+	Default = FNV
+)
+
+//Func is a hash function of a partitioning key
+type Func func(key []byte) uint64
+
+//Partitioner return partition index of a key. Keys with the same value are always in the same partition
+type Partitioner struct {
+	name string
+	hash Func
+}
+
+var functions = map[string]Func{
+	//FNV-1a 32 bit
+	FNV: func(key []byte) uint64 {
+		h := fnv.New32a()
+		h.Write(key)
+		return uint64(h.Sum32())
+	},
+	//Kafka default partitioner hash (Java client murmur2 with 0x9747b28c seed)
+	Murmur2: func(key []byte) uint64 {
+		return uint64(murmur2(key))
+	},
+	//MurmurHash3 x86 32 bit with 0 seed
+	Murmur3: func(key []byte) uint64 {
+		return uint64(murmur3(key, 0))
+	},
+	//XXH64 with 0 seed
+	XXHash: func(key []byte) uint64 {
+		return xxhash64(key)
+	},
+}
+
+//NewPartitioner return partitioner with the hash function by name. Empty name is FNV
+func NewPartitioner(name string) (*Partitioner, error) {
+	if name == "" {
+		name = Default
+	}
+	hash, ok := functions[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("Unknown hash function: %s. Available: [%s]", name, strings.Join(Names(), ", "))
+	}
+
+	return &Partitioner{name: strings.ToLower(name), hash: hash}, nil
+}
+
+//Names return sorted hash functions names
+func Names() []string {
+	var names []string
+	for name := range functions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+//Name return hash function name
+func (p *Partitioner) Name() string {
+	return p.name
+}
+
+//Partition return partition index of the key in [0, count)
+//murmur2 partitions are the same as Kafka default partitioner ones: positive hash (sign bit is cleared) % count
+func (p *Partitioner) Partition(key []byte, count int) int {
+	if count <= 1 {
+		return 0
+	}
+
+	hash := p.hash(key)
+	if p.name == Murmur2 {
+		hash &= 0x7fffffff
+	}
+	return int(hash % uint64(count))
+}
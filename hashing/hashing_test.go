@@ -0,0 +1,64 @@
+package hashing
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+//javaInt return uint64 of Java signed int hash (Kafka tests values)
+func javaInt(v int32) uint64 {
+	return uint64(uint32(v))
+}
+
+func TestFunctions(t *testing.T) {
+	tests := []struct {
+		name     string
+		function string
+		key      string
+		expected uint64
+	}{
+		{"fnv empty", FNV, "", 0x811c9dc5},
+		{"fnv", FNV, "a", 0xe40c292c},
+		{"kafka murmur2 short", Murmur2, "21", javaInt(-973932308)},
+		{"kafka murmur2", Murmur2, "foobar", javaInt(-790332482)},
+		{"kafka murmur2 long", Murmur2, "a-little-bit-long-string", javaInt(-985981536)},
+		{"kafka murmur2 tail", Murmur2, "abc", 479470107},
+		{"murmur3 empty", Murmur3, "", 0},
+		{"murmur3", Murmur3, "hello", 0x248bfa47},
+		{"xxhash empty", XXHash, "", 0xef46db3751d8e999},
+		{"xxhash", XXHash, "abc", 0x44bc2cf5ad770999},
+		{"xxhash long", XXHash, "Nobody inspects the spammish repetition", 0xfbcea83c8a378bf1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, functions[tt.function]([]byte(tt.key)))
+		})
+	}
+}
+
+func TestPartition(t *testing.T) {
+	_, err := NewPartitioner("md5")
+	require.Error(t, err)
+
+	p, err := NewPartitioner("")
+	require.NoError(t, err)
+	require.Equal(t, FNV, p.Name())
+	require.Equal(t, 0, p.Partition([]byte("key"), 1))
+
+	//Kafka: Utils.toPositive(Utils.murmur2(key)) % numPartitions
+	p, err = NewPartitioner("MURMUR2")
+	require.NoError(t, err)
+	require.Equal(t, int((javaInt(-790332482)&0x7fffffff)%12), p.Partition([]byte("foobar"), 12))
+
+	for _, name := range Names() {
+		p, err := NewPartitioner(name)
+		require.NoError(t, err)
+		distribution := map[int]int{}
+		for _, key := range []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l"} {
+			partition := p.Partition([]byte(key), 3)
+			require.True(t, partition >= 0 && partition < 3)
+			distribution[partition]++
+		}
+		require.Len(t, distribution, 3, name)
+	}
+}
@@ -0,0 +1,74 @@
+package health
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//Instance is a singleton tracker of destinations flushes
+var Instance = NewTracker()
+
+//Flushes is the last successful and the last failed flush (store of a batch or a streamed event) of the destination
+type Flushes struct {
+	LastSuccess time.Time `json:"last_success,omitempty"`
+	LastError   time.Time `json:"last_error,omitempty"`
+}
+
+//Failing return true if the last flush has failed
+func (f Flushes) Failing() bool {
+	return !f.LastError.IsZero() && f.LastError.After(f.LastSuccess)
+}
+
+//flushes keeps unix nanos: flushes are reported from the events hot path
+type flushes struct {
+	lastSuccess int64
+	lastError   int64
+}
+
+//Tracker keeps the last flushes times per destination (per server)
+type Tracker struct {
+	now func() time.Time
+	//destination id -> *flushes
+	destinations sync.Map
+}
+
+func NewTracker() *Tracker {
+	return &Tracker{now: time.Now}
+}
+
+//Succeeded put the current time as the last successful flush of the destination
+func (t *Tracker) Succeeded(destinationId string) {
+	atomic.StoreInt64(&t.get(destinationId).lastSuccess, t.now().UnixNano())
+}
+
+//Failed put the current time as the last failed flush of the destination
+func (t *Tracker) Failed(destinationId string) {
+	atomic.StoreInt64(&t.get(destinationId).lastError, t.now().UnixNano())
+}
+
+//Flushes return the last flushes of the destination. Zero times mean that there weren't such flushes since the start
+func (t *Tracker) Flushes(destinationId string) Flushes {
+	value, ok := t.destinations.Load(destinationId)
+	if !ok {
+		return Flushes{}
+	}
+
+	f := value.(*flushes)
+	return Flushes{LastSuccess: fromUnixNano(atomic.LoadInt64(&f.lastSuccess)), LastError: fromUnixNano(atomic.LoadInt64(&f.lastError))}
+}
+
+func (t *Tracker) get(destinationId string) *flushes {
+	if value, ok := t.destinations.Load(destinationId); ok {
+		return value.(*flushes)
+	}
+	value, _ := t.destinations.LoadOrStore(destinationId, &flushes{})
+	return value.(*flushes)
+}
+
+func fromUnixNano(nanos int64) time.Time {
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos).UTC()
+}
@@ -0,0 +1,30 @@
+package health
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestTracker(t *testing.T) {
+	now := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+	tracker := NewTracker()
+	tracker.now = func() time.Time { return now }
+
+	require.Equal(t, Flushes{}, tracker.Flushes("pg"))
+
+	tracker.Succeeded("pg")
+	flushes := tracker.Flushes("pg")
+	require.Equal(t, now, flushes.LastSuccess)
+	require.False(t, flushes.Failing())
+
+	now = now.Add(time.Minute)
+	tracker.Failed("pg")
+	flushes = tracker.Flushes("pg")
+	require.Equal(t, now, flushes.LastError)
+	require.True(t, flushes.Failing())
+
+	now = now.Add(time.Minute)
+	tracker.Succeeded("pg")
+	require.False(t, tracker.Flushes("pg").Failing())
+}
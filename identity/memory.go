@@ -0,0 +1,37 @@
+package identity
+
+import (
+	"sync"
+)
+
+//Memory is an alias table of the current instance. It is lost on restart
+type Memory struct {
+	sync.RWMutex
+	aliases map[string]string
+}
+
+func NewMemory() *Memory {
+	return &Memory{aliases: map[string]string{}}
+}
+
+func (m *Memory) GetAlias(anonymousId string) (string, error) {
+	m.RLock()
+	defer m.RUnlock()
+	return m.aliases[anonymousId], nil
+}
+
+func (m *Memory) SaveAlias(anonymousId, userId string) (string, error) {
+	m.Lock()
+	defer m.Unlock()
+	previous := m.aliases[anonymousId]
+	m.aliases[anonymousId] = userId
+	return previous, nil
+}
+
+func (m *Memory) Type() string {
+	return MemoryType
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
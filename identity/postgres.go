@@ -0,0 +1,83 @@
+package identity
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/jitsucom/eventnative/adapters"
+	"github.com/jitsucom/eventnative/logging"
+)
+
+const (
+	defaultAliasTable = "identity_aliases"
+
+	createAliasTableTemplate = `CREATE TABLE IF NOT EXISTS %s (anonymous_id text PRIMARY KEY, user_id text NOT NULL, updated_at timestamp NOT NULL DEFAULT now())`
+	getAliasTemplate         = `SELECT user_id FROM %s WHERE anonymous_id = $1`
+	//previous user id is selected in the same statement (row lock) as upsert
+	saveAliasTemplate = `WITH previous AS (SELECT user_id FROM %[1]s WHERE anonymous_id = $1 FOR UPDATE)
+INSERT INTO %[1]s (anonymous_id, user_id, updated_at) VALUES ($1, $2, now())
+ON CONFLICT (anonymous_id) DO UPDATE SET user_id = EXCLUDED.user_id, updated_at = EXCLUDED.updated_at
+RETURNING (SELECT user_id FROM previous)`
+)
+
+//Postgres is an alias table in Postgres. The table is created on start if it doesn't exist
+type Postgres struct {
+	dataSource *sql.DB
+	getQuery   string
+	saveQuery  string
+}
+
+func NewPostgres(config *adapters.DataSourceConfig, table string) (*Postgres, error) {
+	logging.Infof("Initializing identity postgres [%s:%d] table [%s]...", config.Host, config.Port, table)
+	connectionString := fmt.Sprintf("host=%s port=%d dbname=%s user=%s password=%s ",
+		config.Host, config.Port, config.Db, config.Username, config.Password)
+	for k, v := range config.Parameters {
+		connectionString += k + "=" + v + " "
+	}
+	dataSource, err := sql.Open("postgres", connectionString)
+	if err != nil {
+		return nil, err
+	}
+	if err := dataSource.Ping(); err != nil {
+		dataSource.Close()
+		return nil, fmt.Errorf("Error testing connection to identity Postgres: %v", err)
+	}
+
+	if config.Schema != "" {
+		table = config.Schema + "." + table
+	}
+	if _, err := dataSource.Exec(fmt.Sprintf(createAliasTableTemplate, table)); err != nil {
+		dataSource.Close()
+		return nil, fmt.Errorf("Error creating identity alias table [%s]: %v", table, err)
+	}
+
+	return &Postgres{
+		dataSource: dataSource,
+		getQuery:   fmt.Sprintf(getAliasTemplate, table),
+		saveQuery:  fmt.Sprintf(saveAliasTemplate, table),
+	}, nil
+}
+
+func (p *Postgres) GetAlias(anonymousId string) (string, error) {
+	var userId string
+	err := p.dataSource.QueryRow(p.getQuery, anonymousId).Scan(&userId)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return userId, err
+}
+
+func (p *Postgres) SaveAlias(anonymousId, userId string) (string, error) {
+	var previous sql.NullString
+	if err := p.dataSource.QueryRow(p.saveQuery, anonymousId, userId).Scan(&previous); err != nil {
+		return "", err
+	}
+	return previous.String, nil
+}
+
+func (p *Postgres) Type() string {
+	return PostgresType
+}
+
+func (p *Postgres) Close() error {
+	return p.dataSource.Close()
+}
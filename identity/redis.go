@@ -0,0 +1,84 @@
+package identity
+
+import (
+	"fmt"
+	"github.com/gomodule/redigo/redis"
+	"github.com/jitsucom/eventnative/logging"
+	"strconv"
+	"time"
+)
+
+//Redis is an alias table in Redis
+//redis key [variables] - description
+//identity:anonymous_id#anonymousId [anonymousId] - string with user id
+type Redis struct {
+	pool *redis.Pool
+}
+
+func NewRedis(host string, port int, password string) (*Redis, error) {
+	logging.Infof("Initializing identity redis [%s:%d]...", host, port)
+	r := &Redis{pool: &redis.Pool{
+		MaxIdle:     100,
+		MaxActive:   600,
+		IdleTimeout: 240 * time.Second,
+
+		Wait: false,
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial(
+				"tcp",
+				host+":"+strconv.Itoa(port),
+				redis.DialConnectTimeout(10*time.Second),
+				redis.DialReadTimeout(10*time.Second),
+				redis.DialPassword(password),
+			)
+		},
+		TestOnBorrow: func(c redis.Conn, t time.Time) error {
+			_, err := c.Do("PING")
+			return err
+		},
+	}}
+
+	//test connection
+	connection := r.pool.Get()
+	defer connection.Close()
+	if _, err := redis.String(connection.Do("PING")); err != nil {
+		r.pool.Close()
+		return nil, fmt.Errorf("Error testing connection to identity Redis: %v", err)
+	}
+
+	return r, nil
+}
+
+func (r *Redis) GetAlias(anonymousId string) (string, error) {
+	connection := r.pool.Get()
+	defer connection.Close()
+
+	userId, err := redis.String(connection.Do("GET", aliasKey(anonymousId)))
+	if err == redis.ErrNil {
+		return "", nil
+	}
+	return userId, err
+}
+
+func (r *Redis) SaveAlias(anonymousId, userId string) (string, error) {
+	connection := r.pool.Get()
+	defer connection.Close()
+
+	previous, err := redis.String(connection.Do("GETSET", aliasKey(anonymousId), userId))
+	if err == redis.ErrNil {
+		return "", nil
+	}
+	return previous, err
+}
+
+func (r *Redis) Type() string {
+	return RedisType
+}
+
+func (r *Redis) Close() error {
+	return r.pool.Close()
+}
+
+func aliasKey(anonymousId string) string {
+	return "identity:anonymous_id#" + anonymousId
+}
@@ -0,0 +1,217 @@
+package identity
+
+import (
+	"fmt"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/jsonutils"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/uuid"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultAnonymousIdField = "/eventn_ctx/user/anonymous_id"
+	defaultUserIdField      = "/eventn_ctx/user/id"
+	defaultCanonicalIdField = "/eventn_ctx/user/canonical_id"
+	defaultMergeEventType   = "identity_merge"
+	defaultCacheSize        = 100000
+	defaultCacheTtl         = time.Minute
+)
+
+//Config is an identity stitching configuration
+type Config struct {
+	Enabled          bool   `mapstructure:"enabled"`
+	AnonymousIdField string `mapstructure:"anonymous_id_field"`
+	UserIdField      string `mapstructure:"user_id_field"`
+	CanonicalIdField string `mapstructure:"canonical_id_field"`
+	//emit merge records (event_type = merge_event_type) into the token destinations
+	EmitMerges     bool   `mapstructure:"emit_merges"`
+	MergeEventType string `mapstructure:"merge_event_type"`
+	//max cached aliases of the current instance
+	CacheSize int `mapstructure:"cache_size"`
+	//aliases changed by other instances are seen after cache ttl
+	CacheTtlSec int `mapstructure:"cache_ttl_sec"`
+
+	Storage StorageConfig `mapstructure:"storage"`
+}
+
+type cachedAlias struct {
+	userId  string
+	expires time.Time
+}
+
+//Resolver stitches anonymous ids and user ids: when an event has both ids - the anonymous id is aliased to the user id
+//in the alias table. Every event is enriched with canonical id: user id, the anonymous id alias or the anonymous id itself
+type Resolver struct {
+	storage          Storage
+	anonymousIdField *jsonutils.JsonPath
+	userIdField      *jsonutils.JsonPath
+	canonicalIdField *jsonutils.JsonPath
+	emitMerges       bool
+	mergeEventType   string
+
+	cacheMutex sync.Mutex
+	cache      map[string]cachedAlias
+	cacheSize  int
+	cacheTtl   time.Duration
+}
+
+//NewResolver return Resolver with configured storage or nil if identity stitching is disabled
+func NewResolver(config Config) (*Resolver, error) {
+	if !config.Enabled {
+		return nil, nil
+	}
+
+	storage, err := NewStorage(config.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating identity storage: %v", err)
+	}
+
+	return newResolver(config, storage), nil
+}
+
+func newResolver(config Config, storage Storage) *Resolver {
+	if config.AnonymousIdField == "" {
+		config.AnonymousIdField = defaultAnonymousIdField
+	}
+	if config.UserIdField == "" {
+		config.UserIdField = defaultUserIdField
+	}
+	if config.CanonicalIdField == "" {
+		config.CanonicalIdField = defaultCanonicalIdField
+	}
+	if config.MergeEventType == "" {
+		config.MergeEventType = defaultMergeEventType
+	}
+	if config.CacheSize <= 0 {
+		config.CacheSize = defaultCacheSize
+	}
+	cacheTtl := defaultCacheTtl
+	if config.CacheTtlSec > 0 {
+		cacheTtl = time.Duration(config.CacheTtlSec) * time.Second
+	}
+
+	logging.Infof("[identity] Initialized with [%s] storage: %s + %s -> %s", storage.Type(), config.AnonymousIdField, config.UserIdField, config.CanonicalIdField)
+	return &Resolver{
+		storage:          storage,
+		anonymousIdField: jsonutils.NewJsonPath(config.AnonymousIdField),
+		userIdField:      jsonutils.NewJsonPath(config.UserIdField),
+		canonicalIdField: jsonutils.NewJsonPath(config.CanonicalIdField),
+		emitMerges:       config.EmitMerges,
+		mergeEventType:   config.MergeEventType,
+		cache:            map[string]cachedAlias{},
+		cacheSize:        config.CacheSize,
+		cacheTtl:         cacheTtl,
+	}
+}
+
+//Resolve enrich the event with canonical id and save the anonymous id alias if the event has both ids.
+//return merge record if the anonymous id has been aliased to the user id for the first time (or re-aliased from another user id)
+//and merges emitting is enabled. Storage errors don't stop processing: the event is enriched with ids as is
+func (r *Resolver) Resolve(event events.Fact) events.Fact {
+	anonymousId := r.extract(event, r.anonymousIdField)
+	userId := r.extract(event, r.userIdField)
+
+	switch {
+	case userId != "" && anonymousId != "":
+		r.setCanonicalId(event, userId)
+		return r.alias(event, anonymousId, userId)
+	case userId != "":
+		r.setCanonicalId(event, userId)
+	case anonymousId != "":
+		canonicalId := anonymousId
+		if aliasedUserId, err := r.getAlias(anonymousId); err != nil {
+			logging.Errorf("[identity] Error getting alias of anonymous id [%s]: %v", anonymousId, err)
+		} else if aliasedUserId != "" {
+			canonicalId = aliasedUserId
+		}
+		r.setCanonicalId(event, canonicalId)
+	}
+
+	return nil
+}
+
+func (r *Resolver) alias(event events.Fact, anonymousId, userId string) events.Fact {
+	if cached, ok := r.getCached(anonymousId); ok && cached == userId {
+		return nil
+	}
+
+	previous, err := r.storage.SaveAlias(anonymousId, userId)
+	if err != nil {
+		logging.Errorf("[identity] Error saving alias [%s] -> [%s]: %v", anonymousId, userId, err)
+		return nil
+	}
+	r.putCached(anonymousId, userId)
+
+	if previous == userId || !r.emitMerges {
+		return nil
+	}
+
+	merge := events.Fact{
+		"event_type":      r.mergeEventType,
+		"anonymous_id":    anonymousId,
+		"user_id":         userId,
+		"source_event_id": events.ExtractEventId(event),
+	}
+	if previous != "" {
+		merge["previous_user_id"] = previous
+	}
+	events.EnrichWithEventId(merge, uuid.New())
+	return merge
+}
+
+func (r *Resolver) getAlias(anonymousId string) (string, error) {
+	if cached, ok := r.getCached(anonymousId); ok {
+		return cached, nil
+	}
+
+	userId, err := r.storage.GetAlias(anonymousId)
+	if err != nil {
+		return "", err
+	}
+	//not aliased ids are cached as well
+	r.putCached(anonymousId, userId)
+	return userId, nil
+}
+
+func (r *Resolver) getCached(anonymousId string) (string, bool) {
+	r.cacheMutex.Lock()
+	defer r.cacheMutex.Unlock()
+
+	cached, ok := r.cache[anonymousId]
+	if !ok || time.Now().After(cached.expires) {
+		return "", false
+	}
+	return cached.userId, true
+}
+
+func (r *Resolver) putCached(anonymousId, userId string) {
+	r.cacheMutex.Lock()
+	defer r.cacheMutex.Unlock()
+
+	//protect from unlimited growth
+	if len(r.cache) >= r.cacheSize {
+		r.cache = map[string]cachedAlias{}
+	}
+	r.cache[anonymousId] = cachedAlias{userId: userId, expires: time.Now().Add(r.cacheTtl)}
+}
+
+func (r *Resolver) setCanonicalId(event events.Fact, canonicalId string) {
+	if !r.canonicalIdField.Set(event, canonicalId) {
+		logging.Warnf("[identity] Canonical id can't be set into %s: node isn't an object", r.canonicalIdField.String())
+	}
+}
+
+func (r *Resolver) extract(event events.Fact, path *jsonutils.JsonPath) string {
+	value, ok := path.Get(event)
+	if !ok || value == nil {
+		return ""
+	}
+	return strings.TrimSpace(fmt.Sprint(value))
+}
+
+func (r *Resolver) Close() error {
+	return r.storage.Close()
+}
@@ -0,0 +1,66 @@
+package identity
+
+import (
+	"github.com/jitsucom/eventnative/events"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	resolver := newResolver(Config{EmitMerges: true}, NewMemory())
+
+	//anonymous event before identification
+	anonymous := events.Fact{"eventn_ctx": map[string]interface{}{"user": map[string]interface{}{"anonymous_id": "anon1"}}}
+	require.Nil(t, resolver.Resolve(anonymous))
+	require.Equal(t, "anon1", anonymous["eventn_ctx"].(map[string]interface{})["user"].(map[string]interface{})["canonical_id"])
+
+	//identification
+	identified := events.Fact{"eventn_ctx": map[string]interface{}{"event_id": "event1", "user": map[string]interface{}{"anonymous_id": "anon1", "id": "user1"}}}
+	merge := resolver.Resolve(identified)
+	require.NotNil(t, merge)
+	require.Equal(t, "identity_merge", merge["event_type"])
+	require.Equal(t, "anon1", merge["anonymous_id"])
+	require.Equal(t, "user1", merge["user_id"])
+	require.Equal(t, "event1", merge["source_event_id"])
+	require.NotContains(t, merge, "previous_user_id")
+	require.NotEmpty(t, events.ExtractEventId(merge))
+	require.Equal(t, "user1", identified["eventn_ctx"].(map[string]interface{})["user"].(map[string]interface{})["canonical_id"])
+
+	//the same alias doesn't produce merges
+	require.Nil(t, resolver.Resolve(events.Fact{"eventn_ctx": map[string]interface{}{"user": map[string]interface{}{"anonymous_id": "anon1", "id": "user1"}}}))
+
+	//anonymous event after identification (not cached)
+	resolver.cache = map[string]cachedAlias{}
+	anonymous = events.Fact{"eventn_ctx": map[string]interface{}{"user": map[string]interface{}{"anonymous_id": "anon1"}}}
+	require.Nil(t, resolver.Resolve(anonymous))
+	require.Equal(t, "user1", anonymous["eventn_ctx"].(map[string]interface{})["user"].(map[string]interface{})["canonical_id"])
+
+	//re-aliasing
+	merge = resolver.Resolve(events.Fact{"eventn_ctx": map[string]interface{}{"user": map[string]interface{}{"anonymous_id": "anon1", "id": "user2"}}})
+	require.NotNil(t, merge)
+	require.Equal(t, "user1", merge["previous_user_id"])
+	require.Equal(t, "user2", merge["user_id"])
+
+	//user id only
+	identified = events.Fact{"eventn_ctx": map[string]interface{}{"user": map[string]interface{}{"id": 123}}}
+	require.Nil(t, resolver.Resolve(identified))
+	require.Equal(t, "123", identified["eventn_ctx"].(map[string]interface{})["user"].(map[string]interface{})["canonical_id"])
+
+	//without ids
+	empty := events.Fact{"event_type": "view"}
+	require.Nil(t, resolver.Resolve(empty))
+	require.Equal(t, events.Fact{"event_type": "view"}, empty)
+}
+
+func TestResolveWithoutMerges(t *testing.T) {
+	storage := NewMemory()
+	resolver := newResolver(Config{CanonicalIdField: "/canonical_id"}, storage)
+
+	event := events.Fact{"eventn_ctx": map[string]interface{}{"user": map[string]interface{}{"anonymous_id": "anon1", "id": "user1"}}}
+	require.Nil(t, resolver.Resolve(event))
+	require.Equal(t, "user1", event["canonical_id"])
+
+	userId, err := storage.GetAlias("anon1")
+	require.NoError(t, err)
+	require.Equal(t, "user1", userId)
+}
@@ -0,0 +1,68 @@
+package identity
+
+import (
+	"fmt"
+	"github.com/jitsucom/eventnative/adapters"
+	"io"
+)
+
+const (
+	MemoryType   = "memory"
+	RedisType    = "redis"
+	PostgresType = "postgres"
+)
+
+//Storage is an alias table: anonymous id -> user id
+type Storage interface {
+	io.Closer
+
+	//GetAlias return user id of the anonymous id or empty string if the anonymous id isn't aliased
+	GetAlias(anonymousId string) (string, error)
+	//SaveAlias atomically set user id of the anonymous id and return the previous one (empty string if it didn't exist)
+	SaveAlias(anonymousId, userId string) (string, error)
+
+	Type() string
+}
+
+//StorageConfig is an alias table storage configuration
+type StorageConfig struct {
+	Type  string `mapstructure:"type"`
+	Redis struct {
+		Host     string `mapstructure:"host"`
+		Port     int    `mapstructure:"port"`
+		Password string `mapstructure:"password"`
+	} `mapstructure:"redis"`
+	Postgres *adapters.DataSourceConfig `mapstructure:"postgres"`
+	//Postgres alias table name
+	Table string `mapstructure:"table"`
+}
+
+func NewStorage(config StorageConfig) (Storage, error) {
+	switch config.Type {
+	case "", MemoryType:
+		return NewMemory(), nil
+	case RedisType:
+		if config.Redis.Host == "" {
+			return nil, fmt.Errorf("identity.storage.redis.host is required")
+		}
+		port := config.Redis.Port
+		if port == 0 {
+			port = 6379
+		}
+		return NewRedis(config.Redis.Host, port, config.Redis.Password)
+	case PostgresType:
+		if err := config.Postgres.Validate(); err != nil {
+			return nil, err
+		}
+		if config.Postgres.Port == 0 {
+			config.Postgres.Port = 5432
+		}
+		table := config.Table
+		if table == "" {
+			table = defaultAliasTable
+		}
+		return NewPostgres(config.Postgres, table)
+	default:
+		return nil, fmt.Errorf("Unknown identity storage type: %s. Available: [%s, %s, %s]", config.Type, MemoryType, RedisType, PostgresType)
+	}
+}
@@ -0,0 +1,68 @@
+package integration_tests
+
+import (
+	"context"
+	"fmt"
+	"github.com/jitsucom/eventnative/adapters"
+	"github.com/jitsucom/eventnative/caching"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/meta"
+	"github.com/jitsucom/eventnative/schema"
+	"github.com/jitsucom/eventnative/storages"
+	"github.com/jitsucom/eventnative/synchronization"
+	"github.com/jitsucom/eventnative/test"
+	"github.com/jitsucom/eventnative/typing"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestDeletionSkipsNullKeys(t *testing.T) {
+	ctx := context.Background()
+	container, err := test.NewPostgresContainer(ctx)
+	if err != nil {
+		t.Fatalf("failed to initialize container: %v", err)
+	}
+	defer container.Close()
+	pgParams := make(map[string]string)
+	pgParams["sslmode"] = "disable"
+
+	dsConfig := &adapters.DataSourceConfig{Host: container.Host, Port: container.Port, Db: container.Database, Schema: container.Schema, Username: container.Username, Password: container.Password, Parameters: pgParams}
+	processor, err := schema.NewProcessor("events", []string{}, "", map[string]bool{}, nil, nil)
+	require.NoError(t, err)
+	monitor, err := synchronization.NewService(ctx, "test", "", "", 0)
+	require.NoError(t, err)
+
+	fallBackLoggerFactoryMethod := func() *events.AsyncLogger {
+		return nil
+	}
+
+	eventsCache := caching.NewEventsCache(&meta.Dummy{}, 100)
+	pg, err := storages.NewPostgres(ctx, dsConfig, processor, nil, "test", true, false, monitor, storages.AutoMigrations, fallBackLoggerFactoryMethod, &logging.QueryLogger{}, eventsCache, storages.LoadOptions{})
+	if err != nil {
+		require.Fail(t, "failed to initialize", err)
+	}
+	require.NotNil(t, pg)
+
+	columns := make(map[string]schema.Column)
+	columns["eventn_ctx_event_id"] = schema.NewColumn(typing.STRING)
+	columns["event_type"] = schema.NewColumn(typing.STRING)
+	table := &schema.Table{Name: "events", Version: 1, Columns: columns, PKFields: map[string]bool{}}
+
+	//rows without event id are stored before keyed ones: the first batches mustn't consist of NULL keys only
+	for i := 0; i < 5; i++ {
+		require.NoError(t, pg.Insert(table, events.Fact{"event_type": "test"}))
+	}
+	for i := 0; i < 5; i++ {
+		require.NoError(t, pg.Insert(table, events.Fact{"eventn_ctx_event_id": fmt.Sprintf("id%d", i), "event_type": "test"}))
+	}
+
+	filter := &storages.DeletionFilter{Table: "events", Fields: []*storages.FieldPredicate{{Field: "event_type", Operator: adapters.EqOperator, Value: "test"}}}
+	deleted, err := pg.Delete(filter, 2, 0)
+	require.NoError(t, err)
+	require.Equal(t, int64(5), deleted)
+
+	rows, err := container.CountRows("events")
+	require.NoError(t, err)
+	require.Equal(t, 5, rows, "Rows without event id must be kept")
+}
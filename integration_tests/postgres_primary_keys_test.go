@@ -27,7 +27,7 @@ func TestPrimaryKeyRemoval(t *testing.T) {
 	pgParams["sslmode"] = "disable"
 
 	dsConfig := &adapters.DataSourceConfig{Host: container.Host, Port: container.Port, Db: container.Database, Schema: container.Schema, Username: container.Username, Password: container.Password, Parameters: pgParams}
-	processor, err := schema.NewProcessor("users", []string{}, "", map[string]bool{}, nil)
+	processor, err := schema.NewProcessor("users", []string{}, "", map[string]bool{}, nil, nil)
 	require.NoError(t, err)
 	monitor, err := synchronization.NewService(ctx, "test", "", "", 0)
 	require.NoError(t, err)
@@ -37,7 +37,7 @@ func TestPrimaryKeyRemoval(t *testing.T) {
 	}
 
 	eventsCache := caching.NewEventsCache(&meta.Dummy{}, 100)
-	pg, err := storages.NewPostgres(ctx, dsConfig, processor, nil, "test", true, false, monitor, fallBackLoggerFactoryMethod, &logging.QueryLogger{}, eventsCache)
+	pg, err := storages.NewPostgres(ctx, dsConfig, processor, nil, "test", true, false, monitor, storages.AutoMigrations, fallBackLoggerFactoryMethod, &logging.QueryLogger{}, eventsCache, storages.LoadOptions{})
 	if err != nil {
 		require.Fail(t, "failed to initialize", err)
 	}
@@ -0,0 +1,256 @@
+package introspection
+
+import (
+	"github.com/jitsucom/eventnative/events"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultRecentEvents = 100
+	DefaultFailedEvents = 100
+
+	PendingStatus   = "pending"
+	SucceededStatus = "succeeded"
+	FailedStatus    = "failed"
+
+	//throughputMinutes is a count of minute buckets of per table throughput
+	throughputMinutes = 60
+)
+
+//Instance is a singleton recorder
+var Instance = NewRecorder(DefaultRecentEvents, DefaultFailedEvents)
+
+//Event is a recent event of the destination with the processing status
+type Event struct {
+	EventId   string      `json:"event_id"`
+	Received  time.Time   `json:"received"`
+	Status    string      `json:"status"`
+	Table     string      `json:"table,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	Processed *time.Time  `json:"processed,omitempty"`
+	Original  events.Fact `json:"original,omitempty"`
+}
+
+//Throughput is a count of rows which have been stored in the destination table
+type Throughput struct {
+	Rows       int64     `json:"rows"`
+	LastMinute int64     `json:"last_minute"`
+	LastHour   int64     `json:"last_hour"`
+	LastStored time.Time `json:"last_stored"`
+}
+
+//ring is a buffer of the last events. Events are found by id for status updates
+type ring struct {
+	events []*Event
+	next   int
+	byId   map[string]*Event
+}
+
+func newRing(capacity int) *ring {
+	return &ring{events: make([]*Event, capacity), byId: map[string]*Event{}}
+}
+
+func (r *ring) put(event *Event) {
+	if evicted := r.events[r.next]; evicted != nil && r.byId[evicted.EventId] == evicted {
+		delete(r.byId, evicted.EventId)
+	}
+	r.events[r.next] = event
+	if event.EventId != "" {
+		r.byId[event.EventId] = event
+	}
+	r.next = (r.next + 1) % len(r.events)
+}
+
+//last return copies of at most n events from the newest to the oldest
+func (r *ring) last(n int) []Event {
+	result := []Event{}
+	for i := 1; i <= len(r.events) && len(result) < n; i++ {
+		event := r.events[(r.next-i+len(r.events))%len(r.events)]
+		if event == nil {
+			break
+		}
+		result = append(result, *event)
+	}
+	return result
+}
+
+//tableThroughput keeps rows counts per minute of the last hour. Bucket is reset when its minute is outdated
+type tableThroughput struct {
+	rows       int64
+	lastStored time.Time
+	minutes    [throughputMinutes]int64
+	buckets    [throughputMinutes]int64
+}
+
+func (tt *tableThroughput) add(now time.Time) {
+	minute := now.Unix() / 60
+	i := minute % throughputMinutes
+	if tt.minutes[i] != minute {
+		tt.minutes[i] = minute
+		tt.buckets[i] = 0
+	}
+	tt.buckets[i]++
+	tt.rows++
+	tt.lastStored = now
+}
+
+func (tt *tableThroughput) stats(now time.Time) *Throughput {
+	minute := now.Unix() / 60
+	stats := &Throughput{Rows: tt.rows, LastStored: tt.lastStored.UTC()}
+	for i := 0; i < throughputMinutes; i++ {
+		age := minute - tt.minutes[i]
+		if age < 0 || age >= throughputMinutes {
+			continue
+		}
+		stats.LastHour += tt.buckets[i]
+		if age == 0 {
+			stats.LastMinute += tt.buckets[i]
+		}
+	}
+	return stats
+}
+
+type destinationRecords struct {
+	recent *ring
+	failed *ring
+	tables map[string]*tableThroughput
+}
+
+//Recorder keeps the last received and failed events and per table throughput of every destination in memory (per server)
+//for the live pipeline introspection API
+type Recorder struct {
+	sync.RWMutex
+
+	recentCapacity int
+	failedCapacity int
+	now            func() time.Time
+	destinations   map[string]*destinationRecords
+}
+
+//Init replace Instance with recorder with the capacities
+func Init(recentEvents, failedEvents int) {
+	Instance = NewRecorder(recentEvents, failedEvents)
+}
+
+func NewRecorder(recentEvents, failedEvents int) *Recorder {
+	if recentEvents <= 0 {
+		recentEvents = DefaultRecentEvents
+	}
+	if failedEvents <= 0 {
+		failedEvents = DefaultFailedEvents
+	}
+	return &Recorder{recentCapacity: recentEvents, failedCapacity: failedEvents, now: time.Now, destinations: map[string]*destinationRecords{}}
+}
+
+//Received put the event which has been accepted for the destination. The event mustn't be changed after the call
+func (r *Recorder) Received(destinationId, eventId string, original events.Fact) {
+	r.Lock()
+	r.records(destinationId).recent.put(&Event{EventId: eventId, Received: r.now().UTC(), Status: PendingStatus, Original: original})
+	r.Unlock()
+}
+
+//Succeeded mark the recent event as stored in the table and count the table throughput
+func (r *Recorder) Succeeded(destinationId, eventId, table string) {
+	r.Lock()
+	defer r.Unlock()
+
+	now := r.now()
+	records := r.records(destinationId)
+	if event, ok := records.recent.byId[eventId]; ok {
+		event.Status = SucceededStatus
+		event.Table = table
+		processed := now.UTC()
+		event.Processed = &processed
+	}
+
+	throughput, ok := records.tables[table]
+	if !ok {
+		throughput = &tableThroughput{}
+		records.tables[table] = throughput
+	}
+	throughput.add(now)
+}
+
+//Failed mark the recent event as failed and put it into the last failed events
+func (r *Recorder) Failed(destinationId, eventId, errMsg string) {
+	r.Lock()
+	defer r.Unlock()
+
+	now := r.now().UTC()
+	records := r.records(destinationId)
+	failed := &Event{EventId: eventId, Status: FailedStatus, Error: errMsg, Processed: &now}
+	if event, ok := records.recent.byId[eventId]; ok {
+		event.Status = FailedStatus
+		event.Error = errMsg
+		event.Processed = &now
+		failed.Received = event.Received
+		failed.Original = event.Original
+	}
+	records.failed.put(failed)
+}
+
+//Recent return at most n recent events of the destination from the newest
+func (r *Recorder) Recent(destinationId string, n int) []Event {
+	r.RLock()
+	defer r.RUnlock()
+
+	records, ok := r.destinations[destinationId]
+	if !ok {
+		return []Event{}
+	}
+	return records.recent.last(n)
+}
+
+//LastFailed return at most n failed events of the destination from the newest
+func (r *Recorder) LastFailed(destinationId string, n int) []Event {
+	r.RLock()
+	defer r.RUnlock()
+
+	records, ok := r.destinations[destinationId]
+	if !ok {
+		return []Event{}
+	}
+	return records.failed.last(n)
+}
+
+//Throughput return per table throughput of the destination
+func (r *Recorder) Throughput(destinationId string) map[string]*Throughput {
+	r.RLock()
+	defer r.RUnlock()
+
+	result := map[string]*Throughput{}
+	records, ok := r.destinations[destinationId]
+	if !ok {
+		return result
+	}
+	now := r.now()
+	for table, throughput := range records.tables {
+		result[table] = throughput.stats(now)
+	}
+	return result
+}
+
+//DestinationIds return ids of destinations which have recorded events
+func (r *Recorder) DestinationIds() []string {
+	r.RLock()
+	defer r.RUnlock()
+
+	ids := make([]string, 0, len(r.destinations))
+	for id := range r.destinations {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+//records return destination records. Must be called under the write lock
+func (r *Recorder) records(destinationId string) *destinationRecords {
+	records, ok := r.destinations[destinationId]
+	if !ok {
+		records = &destinationRecords{recent: newRing(r.recentCapacity), failed: newRing(r.failedCapacity), tables: map[string]*tableThroughput{}}
+		r.destinations[destinationId] = records
+	}
+	return records
+}
@@ -0,0 +1,63 @@
+package introspection
+
+import (
+	"github.com/jitsucom/eventnative/events"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestRecorderEvents(t *testing.T) {
+	recorder := NewRecorder(2, 2)
+	now := time.Date(2020, 11, 10, 12, 0, 0, 0, time.UTC)
+	recorder.now = func() time.Time { return now }
+
+	require.Equal(t, []Event{}, recorder.Recent("pg", 10))
+
+	recorder.Received("pg", "1", events.Fact{"event_id": "1"})
+	recorder.Received("pg", "2", events.Fact{"event_id": "2"})
+	recorder.Succeeded("pg", "2", "events")
+	//evicts the first one
+	recorder.Received("pg", "3", events.Fact{"event_id": "3"})
+	recorder.Failed("pg", "3", "malformed")
+	//isn't recent anymore
+	recorder.Failed("pg", "1", "timeout")
+
+	recent := recorder.Recent("pg", 10)
+	require.Len(t, recent, 2)
+	require.Equal(t, "3", recent[0].EventId)
+	require.Equal(t, FailedStatus, recent[0].Status)
+	require.Equal(t, "malformed", recent[0].Error)
+	require.Equal(t, "2", recent[1].EventId)
+	require.Equal(t, SucceededStatus, recent[1].Status)
+	require.Equal(t, "events", recent[1].Table)
+	require.Len(t, recorder.Recent("pg", 1), 1)
+
+	failed := recorder.LastFailed("pg", 10)
+	require.Len(t, failed, 2)
+	require.Equal(t, "1", failed[0].EventId)
+	require.Nil(t, failed[0].Original)
+	require.Equal(t, "3", failed[1].EventId)
+	require.Equal(t, events.Fact{"event_id": "3"}, failed[1].Original)
+}
+
+func TestRecorderThroughput(t *testing.T) {
+	recorder := NewRecorder(10, 10)
+	now := time.Date(2020, 11, 10, 12, 0, 0, 0, time.UTC)
+	recorder.now = func() time.Time { return now }
+
+	recorder.Succeeded("pg", "1", "events")
+	now = now.Add(30 * time.Minute)
+	recorder.Succeeded("pg", "2", "events")
+	recorder.Succeeded("pg", "3", "events")
+	recorder.Succeeded("pg", "4", "clicks")
+
+	throughput := recorder.Throughput("pg")
+	require.Equal(t, &Throughput{Rows: 3, LastMinute: 2, LastHour: 3, LastStored: now}, throughput["events"])
+	require.Equal(t, &Throughput{Rows: 1, LastMinute: 1, LastHour: 1, LastStored: now}, throughput["clicks"])
+
+	//the first minute is out of the last hour window
+	now = now.Add(31 * time.Minute)
+	require.Equal(t, &Throughput{Rows: 3, LastMinute: 0, LastHour: 2, LastStored: now.Add(-31 * time.Minute)}, recorder.Throughput("pg")["events"])
+	require.Equal(t, []string{"pg"}, recorder.DestinationIds())
+}
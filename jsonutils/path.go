@@ -23,6 +23,11 @@ func (jp *JsonPath) IsEmpty() bool {
 	return len(jp.parts) == 0
 }
 
+//Parts return path segments
+func (jp *JsonPath) Parts() []string {
+	return jp.parts
+}
+
 //Get return value of json path
 func (jp *JsonPath) Get(obj map[string]interface{}) (interface{}, bool) {
 	return jp.getAndRemove(obj, false)
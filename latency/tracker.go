@@ -0,0 +1,267 @@
+package latency
+
+import (
+	"bytes"
+	"github.com/jitsucom/eventnative/metrics"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	//ClientToServer is a latency from client send time (eventn_ctx.utc_time) to server receive time (_timestamp)
+	ClientToServer = "client_to_server"
+	//ServerToDestination is a latency from server receive time to destination ack time
+	ServerToDestination = "server_to_destination"
+	//EndToEnd is a latency from client send time to destination ack time
+	EndToEnd = "end_to_end"
+
+	DefaultWindowSize = 1000
+
+	eventnKey       = "eventn_ctx"
+	clientTimeKey   = "utc_time"
+	receivedTimeKey = "_timestamp"
+)
+
+var (
+	stages = []string{ClientToServer, ServerToDestination, EndToEnd}
+
+	clientTimePrefix   = []byte(`"` + clientTimeKey + `":"`)
+	receivedTimePrefix = []byte(`"` + receivedTimeKey + `":"`)
+)
+
+//Instance is a singleton tracker
+var Instance = NewTracker(DefaultWindowSize)
+
+//Times is a client send and server receive times of the event. Zero value means unknown
+type Times struct {
+	Client   time.Time
+	Received time.Time
+}
+
+//Percentiles is a latency percentiles in milliseconds over the last window samples
+type Percentiles struct {
+	Samples int   `json:"samples"`
+	P50Ms   int64 `json:"p50_ms"`
+	P95Ms   int64 `json:"p95_ms"`
+	P99Ms   int64 `json:"p99_ms"`
+	MaxMs   int64 `json:"max_ms"`
+}
+
+//Stats is a destination latency statistics
+type Stats struct {
+	Events  int64                   `json:"events"`
+	Batches int64                   `json:"batches"`
+	LastAck time.Time               `json:"last_ack"`
+	Stages  map[string]*Percentiles `json:"stages"`
+}
+
+//window is a ring buffer of the last latency samples in milliseconds
+type window struct {
+	samples []int64
+	next    int
+	full    bool
+}
+
+type destinationLatency struct {
+	events  int64
+	batches int64
+	lastAck time.Time
+	stages  map[string]*window
+}
+
+//Tracker keeps the last windowSize latency samples of every stage per destination (per server)
+//and exposes them as Prometheus histograms
+type Tracker struct {
+	sync.RWMutex
+
+	windowSize   int
+	now          func() time.Time
+	destinations map[string]*destinationLatency
+}
+
+//Init replace Instance with tracker with the window size
+func Init(windowSize int) {
+	Instance = NewTracker(windowSize)
+}
+
+func NewTracker(windowSize int) *Tracker {
+	if windowSize <= 0 {
+		windowSize = DefaultWindowSize
+	}
+	return &Tracker{windowSize: windowSize, now: time.Now, destinations: map[string]*destinationLatency{}}
+}
+
+//Event observe latencies of the event which has been stored in the destination right now (stream mode)
+func (t *Tracker) Event(destinationId string, event map[string]interface{}) {
+	t.Observe(destinationId, []Times{EventTimes(event)}, t.now())
+}
+
+//Batch observe latencies of all json lines events which have been stored in the destination right now as one batch
+func (t *Tracker) Batch(destinationId string, payload []byte) {
+	t.Observe(destinationId, BatchTimes(payload), t.now())
+}
+
+//Observe put latencies of the events which have been acknowledged by the destination at ack time as one batch
+func (t *Tracker) Observe(destinationId string, times []Times, ack time.Time) {
+	if len(times) == 0 {
+		return
+	}
+
+	t.Lock()
+	dl, ok := t.destinations[destinationId]
+	if !ok {
+		dl = &destinationLatency{stages: map[string]*window{}}
+		for _, stage := range stages {
+			dl.stages[stage] = &window{samples: make([]int64, t.windowSize)}
+		}
+		t.destinations[destinationId] = dl
+	}
+	dl.batches++
+	dl.events += int64(len(times))
+	dl.lastAck = ack
+
+	for _, eventTimes := range times {
+		if !eventTimes.Client.IsZero() && !eventTimes.Received.IsZero() {
+			t.put(destinationId, dl, ClientToServer, eventTimes.Received.Sub(eventTimes.Client))
+		}
+		if !eventTimes.Received.IsZero() {
+			t.put(destinationId, dl, ServerToDestination, ack.Sub(eventTimes.Received))
+		}
+		if !eventTimes.Client.IsZero() {
+			t.put(destinationId, dl, EndToEnd, ack.Sub(eventTimes.Client))
+		}
+	}
+	t.Unlock()
+}
+
+//Stats return latency statistics of the destination or nil if it doesn't have acknowledged events
+func (t *Tracker) Stats(destinationId string) *Stats {
+	t.RLock()
+	defer t.RUnlock()
+
+	dl, ok := t.destinations[destinationId]
+	if !ok {
+		return nil
+	}
+
+	stats := &Stats{Events: dl.events, Batches: dl.batches, LastAck: dl.lastAck.UTC(), Stages: map[string]*Percentiles{}}
+	for stage, w := range dl.stages {
+		stats.Stages[stage] = w.percentiles()
+	}
+	return stats
+}
+
+//DestinationIds return ids of destinations which have acknowledged events
+func (t *Tracker) DestinationIds() []string {
+	t.RLock()
+	defer t.RUnlock()
+
+	ids := make([]string, 0, len(t.destinations))
+	for id := range t.destinations {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (t *Tracker) put(destinationId string, dl *destinationLatency, stage string, latency time.Duration) {
+	//clients clocks might be ahead of the server
+	if latency < 0 {
+		latency = 0
+	}
+	dl.stages[stage].put(latency.Milliseconds())
+	metrics.DestinationLatency(destinationId, stage, latency.Seconds())
+}
+
+func (w *window) put(sample int64) {
+	w.samples[w.next] = sample
+	w.next++
+	if w.next == len(w.samples) {
+		w.next = 0
+		w.full = true
+	}
+}
+
+//percentiles return nearest-rank percentiles of the window samples
+func (w *window) percentiles() *Percentiles {
+	size := w.next
+	if w.full {
+		size = len(w.samples)
+	}
+	if size == 0 {
+		return &Percentiles{}
+	}
+
+	sorted := make([]int64, size)
+	copy(sorted, w.samples[:size])
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	rank := func(p float64) int64 {
+		i := int(float64(size)*p+0.999999) - 1
+		if i < 0 {
+			i = 0
+		}
+		return sorted[i]
+	}
+	return &Percentiles{Samples: size, P50Ms: rank(0.5), P95Ms: rank(0.95), P99Ms: rank(0.99), MaxMs: sorted[size-1]}
+}
+
+//EventTimes return client send time (eventn_ctx.utc_time or flattened eventn_ctx_utc_time) and
+//server receive time (_timestamp) of the event
+func EventTimes(event map[string]interface{}) Times {
+	times := Times{Received: parseTime(event[receivedTimeKey])}
+	if eventn, ok := event[eventnKey].(map[string]interface{}); ok {
+		times.Client = parseTime(eventn[clientTimeKey])
+	} else {
+		times.Client = parseTime(event[eventnKey+"_"+clientTimeKey])
+	}
+	return times
+}
+
+//BatchTimes return times of all json lines events. Values are found without unmarshalling
+func BatchTimes(payload []byte) []Times {
+	var result []Times
+	for len(payload) > 0 {
+		line := payload
+		if i := bytes.IndexByte(payload, '\n'); i >= 0 {
+			line, payload = payload[:i], payload[i+1:]
+		} else {
+			payload = nil
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		result = append(result, Times{Client: findTime(line, clientTimePrefix), Received: findTime(line, receivedTimePrefix)})
+	}
+	return result
+}
+
+func findTime(line, prefix []byte) time.Time {
+	start := bytes.Index(line, prefix)
+	if start < 0 {
+		return time.Time{}
+	}
+	value := line[start+len(prefix):]
+	end := bytes.IndexByte(value, '"')
+	if end < 0 {
+		return time.Time{}
+	}
+	return parseTime(string(value[:end]))
+}
+
+func parseTime(value interface{}) time.Time {
+	switch v := value.(type) {
+	case time.Time:
+		return v
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return time.Time{}
+		}
+		return t
+	default:
+		return time.Time{}
+	}
+}
@@ -0,0 +1,54 @@
+package latency
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestBatchTimes(t *testing.T) {
+	payload := []byte(`{"eventn_ctx":{"event_id":"1","utc_time":"2020-11-10T12:00:00.000000Z"},"_timestamp":"2020-11-10T12:00:01.000000Z"}
+{"eventn_ctx":{"event_id":"2"},"_timestamp":"2020-11-10T12:00:02.000000Z"}
+
+{"eventn_ctx":{"event_id":"3","utc_time":"malformed"}}`)
+
+	require.Equal(t, []Times{
+		{Client: time.Date(2020, 11, 10, 12, 0, 0, 0, time.UTC), Received: time.Date(2020, 11, 10, 12, 0, 1, 0, time.UTC)},
+		{Received: time.Date(2020, 11, 10, 12, 0, 2, 0, time.UTC)},
+		{},
+	}, BatchTimes(payload))
+}
+
+func TestEventTimes(t *testing.T) {
+	received := time.Date(2020, 11, 10, 12, 0, 1, 0, time.UTC)
+	require.Equal(t, Times{Client: time.Date(2020, 11, 10, 12, 0, 0, 0, time.UTC), Received: received},
+		EventTimes(map[string]interface{}{"eventn_ctx": map[string]interface{}{"utc_time": "2020-11-10T12:00:00.000000Z"}, "_timestamp": received}))
+	require.Equal(t, Times{Client: time.Date(2020, 11, 10, 12, 0, 0, 0, time.UTC)},
+		EventTimes(map[string]interface{}{"eventn_ctx_utc_time": "2020-11-10T12:00:00.000000Z"}))
+}
+
+func TestTrackerStats(t *testing.T) {
+	tracker := NewTracker(10)
+	client := time.Date(2020, 11, 10, 12, 0, 0, 0, time.UTC)
+
+	require.Nil(t, tracker.Stats("pg"))
+
+	//15 samples: only the last 10 are kept
+	var times []Times
+	for i := 1; i <= 15; i++ {
+		times = append(times, Times{Client: client, Received: client.Add(time.Duration(i) * time.Second)})
+	}
+	ack := client.Add(20 * time.Second)
+	tracker.Observe("pg", times, ack)
+	tracker.Observe("pg", []Times{{Received: client.Add(30 * time.Second)}}, ack)
+
+	stats := tracker.Stats("pg")
+	require.Equal(t, int64(16), stats.Events)
+	require.Equal(t, int64(2), stats.Batches)
+	require.Equal(t, ack, stats.LastAck)
+	require.Equal(t, &Percentiles{Samples: 10, P50Ms: 10000, P95Ms: 15000, P99Ms: 15000, MaxMs: 15000}, stats.Stages[ClientToServer])
+	//the last sample is negative (clock skew) and is counted as 0. It replaces the oldest one
+	require.Equal(t, &Percentiles{Samples: 10, P50Ms: 8000, P95Ms: 13000, P99Ms: 13000, MaxMs: 13000}, stats.Stages[ServerToDestination])
+	require.Equal(t, &Percentiles{Samples: 10, P50Ms: 20000, P95Ms: 20000, P99Ms: 20000, MaxMs: 20000}, stats.Stages[EndToEnd])
+	require.Equal(t, []string{"pg"}, tracker.DestinationIds())
+}
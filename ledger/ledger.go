@@ -0,0 +1,462 @@
+package ledger
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/uuid"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	//file has been written to the stage (S3, GCS) and hasn't been copied yet
+	StatusStaged = "staged"
+	//copy has been confirmed by the warehouse
+	StatusLoaded = "loaded"
+	//last copy attempt has failed. Stage file is kept and will be retried
+	StatusFailed = "failed"
+	//copy has failed max attempts before the restart. Stage file is kept for manual handling and isn't retried
+	StatusDeadLettered = "dead_lettered"
+
+	fileExtension      = ".ledger"
+	DefaultHistorySize = 1000
+)
+
+//Instance is a singleton ledger. It is in-memory until Init is called
+var Instance = NewInMemory(DefaultHistorySize)
+
+//Entry is a batch file load record of one destination table
+type Entry struct {
+	LoadId   string    `json:"load_id"`
+	FileKey  string    `json:"file_key"`
+	Checksum string    `json:"checksum"`
+	Table    string    `json:"table"`
+	Rows     int       `json:"rows"`
+	Status   string    `json:"status"`
+	Error    string    `json:"error,omitempty"`
+	Attempts int       `json:"attempts"`
+	StagedAt time.Time `json:"staged_at"`
+	LoadedAt time.Time `json:"loaded_at"`
+}
+
+type destinationLedger struct {
+	//ordered by staging time
+	entries []*Entry
+	//the last entry of the file key
+	byFileKey map[string]*Entry
+	//number of lines in the ledger file (for compaction)
+	lines int
+}
+
+//Ledger records which batch files have been staged and loaded per destination (file key + checksum + table + load id)
+//It makes batch loading idempotent:
+//1. re-run of the same log file (e.g. after a crash before the upload status has been saved) doesn't stage files twice
+//2. staged files which have been copied but not deleted from the stage (e.g. after a crash right after copy) aren't copied twice
+//Entries are written to <dir>/<destination id>.ledger files (one json entry per line, the last one wins) with fsync
+type Ledger struct {
+	sync.RWMutex
+
+	dir string
+	//max loaded entries per destination. Staged and failed entries are never evicted
+	historySize  int
+	destinations map[string]*destinationLedger
+}
+
+//Init open the persistent ledger and replace Instance
+func Init(dir string, historySize int) error {
+	l, err := Open(dir, historySize)
+	if err != nil {
+		return err
+	}
+
+	Instance = l
+	return nil
+}
+
+//NewInMemory return ledger without persistence
+func NewInMemory(historySize int) *Ledger {
+	if historySize <= 0 {
+		historySize = DefaultHistorySize
+	}
+	return &Ledger{historySize: historySize, destinations: map[string]*destinationLedger{}}
+}
+
+//Open create dir if doesn't exist and load all destinations ledger files
+func Open(dir string, historySize int) (*Ledger, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("Error creating ledger dir [%s]: %v", dir, err)
+	}
+
+	l := NewInMemory(historySize)
+	l.dir = dir
+
+	files, err := filepath.Glob(path.Join(dir, "*"+fileExtension))
+	if err != nil {
+		return nil, fmt.Errorf("Error reading ledger dir [%s]: %v", dir, err)
+	}
+
+	for _, filePath := range files {
+		destinationId := strings.TrimSuffix(filepath.Base(filePath), fileExtension)
+		if err := l.load(destinationId, filePath); err != nil {
+			return nil, err
+		}
+	}
+
+	return l, nil
+}
+
+//Checksum return sha256 hex of the payload
+func Checksum(payload []byte) string {
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:])
+}
+
+//Find return a copy of the last entry of the file key if it has the same checksum
+//entries without checksum (file has been staged before the ledger existed) match any checksum
+func (l *Ledger) Find(destinationId, fileKey, checksum string) (*Entry, bool) {
+	l.RLock()
+	defer l.RUnlock()
+
+	dl, ok := l.destinations[destinationId]
+	if !ok {
+		return nil, false
+	}
+
+	entry, ok := dl.byFileKey[fileKey]
+	if !ok || (entry.Checksum != "" && entry.Checksum != checksum) {
+		return nil, false
+	}
+
+	entryCopy := *entry
+	return &entryCopy, true
+}
+
+//Stage record a new staged file and return its load id
+func (l *Ledger) Stage(destinationId, fileKey, checksum, table string, rows int) string {
+	entry := &Entry{
+		LoadId:   uuid.New(),
+		FileKey:  fileKey,
+		Checksum: checksum,
+		Table:    table,
+		Rows:     rows,
+		Status:   StatusStaged,
+		StagedAt: time.Now().UTC(),
+	}
+
+	l.Lock()
+	defer l.Unlock()
+
+	dl := l.getOrCreate(destinationId)
+	dl.entries = append(dl.entries, entry)
+	dl.byFileKey[fileKey] = entry
+	l.persist(destinationId, dl, entry)
+
+	return entry.LoadId
+}
+
+//IsLoaded return true if the file copy has already been confirmed
+func (l *Ledger) IsLoaded(destinationId, fileKey string) bool {
+	l.RLock()
+	defer l.RUnlock()
+
+	dl, ok := l.destinations[destinationId]
+	if !ok {
+		return false
+	}
+
+	entry, ok := dl.byFileKey[fileKey]
+	return ok && entry.Status == StatusLoaded
+}
+
+//IsDeadLettered return true if the file copy mustn't be retried
+func (l *Ledger) IsDeadLettered(destinationId, fileKey string) bool {
+	l.RLock()
+	defer l.RUnlock()
+
+	dl, ok := l.destinations[destinationId]
+	if !ok {
+		return false
+	}
+
+	entry, ok := dl.byFileKey[fileKey]
+	return ok && entry.Status == StatusDeadLettered
+}
+
+//Unfinished return copies of destination entries which have been staged but haven't been loaded (staged and failed)
+func (l *Ledger) Unfinished(destinationId string) []*Entry {
+	l.RLock()
+	defer l.RUnlock()
+
+	unfinished := []*Entry{}
+	dl, ok := l.destinations[destinationId]
+	if !ok {
+		return unfinished
+	}
+
+	for _, entry := range dl.entries {
+		if dl.byFileKey[entry.FileKey] == entry && (entry.Status == StatusStaged || entry.Status == StatusFailed) {
+			entryCopy := *entry
+			unfinished = append(unfinished, &entryCopy)
+		}
+	}
+	return unfinished
+}
+
+//HasFilePrefix return true if destination has entries with file keys which start with prefix (e.g. files of the log file)
+func (l *Ledger) HasFilePrefix(destinationId, prefix string) bool {
+	l.RLock()
+	defer l.RUnlock()
+
+	dl, ok := l.destinations[destinationId]
+	if !ok {
+		return false
+	}
+
+	for fileKey := range dl.byFileKey {
+		if strings.HasPrefix(fileKey, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+//DeadLetter record that the staged file copy won't be retried
+func (l *Ledger) DeadLetter(destinationId, fileKey string) {
+	l.Lock()
+	defer l.Unlock()
+
+	dl, ok := l.destinations[destinationId]
+	if !ok {
+		return
+	}
+	entry, ok := dl.byFileKey[fileKey]
+	if !ok {
+		return
+	}
+
+	entry.Status = StatusDeadLettered
+	l.persist(destinationId, dl, entry)
+}
+
+//Loaded record copy attempt result of the staged file
+//files which have been staged before the ledger existed get entries without checksum
+func (l *Ledger) Loaded(destinationId, fileKey, table string, rows int, loadErr error) {
+	l.Lock()
+	defer l.Unlock()
+
+	dl := l.getOrCreate(destinationId)
+	entry, ok := dl.byFileKey[fileKey]
+	if !ok {
+		entry = &Entry{LoadId: uuid.New(), FileKey: fileKey, Table: table, Rows: rows, StagedAt: time.Now().UTC()}
+		dl.entries = append(dl.entries, entry)
+		dl.byFileKey[fileKey] = entry
+	}
+
+	entry.Attempts++
+	if loadErr != nil {
+		entry.Status = StatusFailed
+		entry.Error = loadErr.Error()
+	} else {
+		entry.Status = StatusLoaded
+		entry.Error = ""
+		entry.LoadedAt = time.Now().UTC()
+	}
+
+	l.evict(dl)
+	l.persist(destinationId, dl, entry)
+}
+
+//History return copies of destination entries (the newest first)
+func (l *Ledger) History(destinationId string, limit int) []*Entry {
+	l.RLock()
+	defer l.RUnlock()
+
+	history := []*Entry{}
+	dl, ok := l.destinations[destinationId]
+	if !ok {
+		return history
+	}
+
+	for i := len(dl.entries) - 1; i >= 0; i-- {
+		if limit > 0 && len(history) >= limit {
+			break
+		}
+		entryCopy := *dl.entries[i]
+		history = append(history, &entryCopy)
+	}
+
+	return history
+}
+
+//Destinations return sorted destination ids which have entries
+func (l *Ledger) Destinations() []string {
+	l.RLock()
+	defer l.RUnlock()
+
+	ids := make([]string, 0, len(l.destinations))
+	for id := range l.destinations {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (l *Ledger) getOrCreate(destinationId string) *destinationLedger {
+	dl, ok := l.destinations[destinationId]
+	if !ok {
+		dl = &destinationLedger{byFileKey: map[string]*Entry{}}
+		l.destinations[destinationId] = dl
+	}
+	return dl
+}
+
+//evict remove the oldest loaded entries over history size
+func (l *Ledger) evict(dl *destinationLedger) {
+	loaded := 0
+	for _, entry := range dl.entries {
+		if entry.Status == StatusLoaded {
+			loaded++
+		}
+	}
+
+	if loaded <= l.historySize {
+		return
+	}
+
+	toEvict := loaded - l.historySize
+	kept := make([]*Entry, 0, len(dl.entries)-toEvict)
+	for _, entry := range dl.entries {
+		if toEvict > 0 && entry.Status == StatusLoaded {
+			toEvict--
+			if dl.byFileKey[entry.FileKey] == entry {
+				delete(dl.byFileKey, entry.FileKey)
+			}
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	dl.entries = kept
+}
+
+//persist append entry line to the destination ledger file and fsync it
+//the file is rewritten with actual entries when it has too many obsolete lines
+func (l *Ledger) persist(destinationId string, dl *destinationLedger, entry *Entry) {
+	if l.dir == "" {
+		return
+	}
+
+	filePath := path.Join(l.dir, destinationId+fileExtension)
+	if dl.lines > 2*len(dl.entries)+l.historySize {
+		if err := l.rewrite(filePath, dl); err != nil {
+			logging.SystemErrorf("[%s] Error rewriting ledger file: %v", destinationId, err)
+		}
+		return
+	}
+
+	b, err := json.Marshal(entry)
+	if err != nil {
+		logging.SystemErrorf("[%s] Error marshalling ledger entry: %v", destinationId, err)
+		return
+	}
+
+	file, err := os.OpenFile(filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		logging.SystemErrorf("[%s] Error opening ledger file: %v", destinationId, err)
+		return
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(b, '\n')); err != nil {
+		logging.SystemErrorf("[%s] Error writing ledger file: %v", destinationId, err)
+		return
+	}
+	if err := file.Sync(); err != nil {
+		logging.SystemErrorf("[%s] Error syncing ledger file: %v", destinationId, err)
+	}
+	dl.lines++
+}
+
+//rewrite write all entries into tmp file and rename it
+func (l *Ledger) rewrite(filePath string, dl *destinationLedger) error {
+	tmpPath := filePath + ".tmp"
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(file)
+	for _, entry := range dl.entries {
+		b, err := json.Marshal(entry)
+		if err != nil {
+			file.Close()
+			return err
+		}
+		writer.Write(append(b, '\n'))
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		return err
+	}
+	dl.lines = len(dl.entries)
+	return nil
+}
+
+//load replay ledger file lines: the last line of a load id wins
+func (l *Ledger) load(destinationId, filePath string) error {
+	b, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("Error reading ledger file [%s]: %v", filePath, err)
+	}
+
+	dl := l.getOrCreate(destinationId)
+	byLoadId := map[string]*Entry{}
+	for _, line := range strings.Split(string(b), "\n") {
+		if line == "" {
+			continue
+		}
+		dl.lines++
+
+		entry := &Entry{}
+		if err := json.Unmarshal([]byte(line), entry); err != nil {
+			//torn write of the last line
+			logging.Warnf("[%s] Skipping malformed ledger line: %v", destinationId, err)
+			continue
+		}
+
+		if existing, ok := byLoadId[entry.LoadId]; ok {
+			*existing = *entry
+			continue
+		}
+		byLoadId[entry.LoadId] = entry
+		dl.entries = append(dl.entries, entry)
+	}
+
+	sort.SliceStable(dl.entries, func(i, j int) bool { return dl.entries[i].StagedAt.Before(dl.entries[j].StagedAt) })
+	for _, entry := range dl.entries {
+		dl.byFileKey[entry.FileKey] = entry
+	}
+	l.evict(dl)
+
+	return nil
+}
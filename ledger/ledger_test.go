@@ -0,0 +1,65 @@
+package ledger
+
+import (
+	"errors"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestLedger(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ledger")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	l, err := Open(dir, 2)
+	require.NoError(t, err)
+
+	checksum := Checksum([]byte("payload"))
+	_, ok := l.Find("dst1", "file1", checksum)
+	require.False(t, ok)
+
+	loadId := l.Stage("dst1", "file1", checksum, "events", 10)
+	entry, ok := l.Find("dst1", "file1", checksum)
+	require.True(t, ok)
+	require.Equal(t, loadId, entry.LoadId)
+	require.Equal(t, StatusStaged, entry.Status)
+
+	//other content with the same key isn't found
+	_, ok = l.Find("dst1", "file1", Checksum([]byte("other")))
+	require.False(t, ok)
+
+	l.Loaded("dst1", "file1", "events", 10, errors.New("copy error"))
+	require.False(t, l.IsLoaded("dst1", "file1"))
+	l.Loaded("dst1", "file1", "events", 10, nil)
+	require.True(t, l.IsLoaded("dst1", "file1"))
+
+	//file has been staged before the ledger existed
+	l.Loaded("dst1", "file2", "users", 5, nil)
+	_, ok = l.Find("dst1", "file2", checksum)
+	require.True(t, ok)
+
+	//reopen: the last state wins
+	l, err = Open(dir, 2)
+	require.NoError(t, err)
+	require.Equal(t, []string{"dst1"}, l.Destinations())
+	history := l.History("dst1", 0)
+	require.Equal(t, 2, len(history))
+	require.Equal(t, "file2", history[0].FileKey)
+	require.Equal(t, "file1", history[1].FileKey)
+	require.Equal(t, loadId, history[1].LoadId)
+	require.Equal(t, StatusLoaded, history[1].Status)
+	require.Equal(t, 2, history[1].Attempts)
+	require.Equal(t, "", history[1].Error)
+
+	//loaded entries over history size are evicted, staged ones are kept
+	l.Stage("dst1", "file3", checksum, "events", 1)
+	l.Stage("dst1", "file4", checksum, "events", 1)
+	l.Loaded("dst1", "file4", "events", 1, nil)
+	require.False(t, l.IsLoaded("dst1", "file1"))
+	history = l.History("dst1", 0)
+	require.Equal(t, 3, len(history))
+	require.Equal(t, []string{"file4", "file3", "file2"}, []string{history[0].FileKey, history[1].FileKey, history[2].FileKey})
+	require.Equal(t, 1, len(l.History("dst1", 1)))
+}
@@ -0,0 +1,125 @@
+package logfiles
+
+import (
+	"fmt"
+	"github.com/jitsucom/eventnative/logging"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+//Archive keeps uploaded raw events log files (original unprocessed events) for replaying
+//files older than retention are removed (0 retention means forever)
+type Archive struct {
+	dir       string
+	retention time.Duration
+}
+
+func NewArchive(dir string, retentionDays int) (*Archive, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("Error creating archive dir [%s]: %v", dir, err)
+	}
+
+	return &Archive{dir: dir, retention: time.Duration(retentionDays) * 24 * time.Hour}, nil
+}
+
+//Dir return archive directory path
+func (a *Archive) Dir() string {
+	return a.dir
+}
+
+//Put move file into the archive dir with keeping modification time
+func (a *Archive) Put(filePath string) error {
+	archivedPath := path.Join(a.dir, filepath.Base(filePath))
+	if err := os.Rename(filePath, archivedPath); err == nil {
+		return nil
+	}
+
+	//rename doesn't work between different devices
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return err
+	}
+	if err := copyFile(filePath, archivedPath); err != nil {
+		os.Remove(archivedPath)
+		return fmt.Errorf("Error copying file [%s] to archive: %v", filePath, err)
+	}
+	if err := os.Chtimes(archivedPath, info.ModTime(), info.ModTime()); err != nil {
+		logging.Warnf("Error setting modification time of archived file [%s]: %v", archivedPath, err)
+	}
+
+	return os.Remove(filePath)
+}
+
+//Files return archived file paths with modification time after from (all files if from is zero)
+func (a *Archive) Files(fileMask string, from time.Time) ([]string, error) {
+	files, err := logging.GlobLogFiles(path.Join(a.dir, fileMask))
+	if err != nil {
+		return nil, err
+	}
+
+	var result []string
+	for _, filePath := range files {
+		if !from.IsZero() {
+			info, err := os.Stat(filePath)
+			if err != nil {
+				continue
+			}
+			//file is written before its modification time
+			if info.ModTime().Before(from) {
+				continue
+			}
+		}
+		result = append(result, filePath)
+	}
+	return result, nil
+}
+
+//CleanUp remove files older than retention
+func (a *Archive) CleanUp() {
+	if a.retention <= 0 {
+		return
+	}
+
+	files, err := filepath.Glob(path.Join(a.dir, "*"))
+	if err != nil {
+		logging.Errorf("Error finding archived files in [%s]: %v", a.dir, err)
+		return
+	}
+
+	threshold := time.Now().Add(-a.retention)
+	for _, filePath := range files {
+		info, err := os.Stat(filePath)
+		if err != nil || info.IsDir() || !info.ModTime().Before(threshold) {
+			continue
+		}
+		if err := os.Remove(filePath); err != nil {
+			logging.Errorf("Error removing archived file [%s]: %v", filePath, err)
+		}
+	}
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
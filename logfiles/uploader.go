@@ -2,15 +2,26 @@ package logfiles
 
 import (
 	"github.com/jitsucom/eventnative/appstatus"
+	"github.com/jitsucom/eventnative/breaker"
 	"github.com/jitsucom/eventnative/counters"
 	"github.com/jitsucom/eventnative/destinations"
+	"github.com/jitsucom/eventnative/encryption"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/faults"
+	"github.com/jitsucom/eventnative/health"
+	"github.com/jitsucom/eventnative/latency"
+	"github.com/jitsucom/eventnative/ledger"
 	"github.com/jitsucom/eventnative/logging"
 	"github.com/jitsucom/eventnative/metrics"
+	"github.com/jitsucom/eventnative/recovery"
+	"github.com/jitsucom/eventnative/reports"
+	"github.com/jitsucom/eventnative/retry"
 	"github.com/jitsucom/eventnative/safego"
-	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -24,9 +35,16 @@ type PeriodicUploader struct {
 
 	statusManager      *StatusManager
 	destinationService *destinations.Service
+	//uploaded files are moved into the archive instead of deleting if it is configured
+	archive *Archive
+
+	workers     int32
+	backlogLock sync.RWMutex
+	backlog     int
+	oldestFile  time.Time
 }
 
-func NewUploader(logEventPath, fileMask string, uploadEveryS int, destinationService *destinations.Service) (*PeriodicUploader, error) {
+func NewUploader(logEventPath, fileMask string, uploadEveryS int, destinationService *destinations.Service, archive *Archive) (*PeriodicUploader, error) {
 	statusManager, err := NewStatusManager(logEventPath)
 	if err != nil {
 		return nil, err
@@ -37,11 +55,13 @@ func NewUploader(logEventPath, fileMask string, uploadEveryS int, destinationSer
 		uploadEvery:        time.Duration(uploadEveryS) * time.Second,
 		statusManager:      statusManager,
 		destinationService: destinationService,
+		archive:            archive,
+		workers:            1,
 	}, nil
 }
 
 //Start reading event logger log directory and finding already rotated and closed files by mask
-//pass them to storages according to tokens
+//pass them to storages according to tokens (files are uploaded concurrently by Workers() goroutines)
 //keep uploading log statuses file for every event log file
 func (u *PeriodicUploader) Start() {
 	safego.RunWithRestart(func() {
@@ -55,72 +75,248 @@ func (u *PeriodicUploader) Start() {
 				continue
 			}
 
-			files, err := filepath.Glob(u.fileMask)
+			files, err := logging.GlobLogFiles(u.fileMask)
 			if err != nil {
 				logging.Error("Error finding files by mask", u.fileMask, err)
 				return
 			}
+			u.setBacklog(files)
 
+			filesCh := make(chan string)
+			wg := sync.WaitGroup{}
+			//destinations with open circuits are skipped until the next iteration
+			skipped := &sync.Map{}
+			for i := 0; i < u.Workers(); i++ {
+				wg.Add(1)
+				safego.Run(func() {
+					defer wg.Done()
+					for filePath := range filesCh {
+						u.upload(filePath, skipped)
+					}
+				})
+			}
 			for _, filePath := range files {
-				fileName := filepath.Base(filePath)
+				filesCh <- filePath
+			}
+			close(filesCh)
+			wg.Wait()
 
-				b, err := ioutil.ReadFile(filePath)
-				if err != nil {
-					logging.Error("Error reading file", filePath, err)
-					continue
-				}
-				if len(b) == 0 {
-					os.Remove(filePath)
-					continue
-				}
-				//get token from filename
-				regexResult := logging.TokenIdExtractRegexp.FindStringSubmatch(fileName)
-				if len(regexResult) != 2 {
-					logging.Errorf("Error processing file %s. Malformed name", filePath)
-					continue
-				}
+			u.setBacklog(nil)
+			if u.archive != nil {
+				u.archive.CleanUp()
+			}
 
-				tokenId := regexResult[1]
-				storageProxies := u.destinationService.GetStorages(tokenId)
-				if len(storageProxies) == 0 {
-					logging.Warnf("Destination storages weren't found for file [%s] and token [%s]", filePath, tokenId)
-					continue
-				}
+			time.Sleep(u.uploadEvery)
+		}
+	})
+}
 
-				//flag for deleting file if all storages don't have errors while storing this file
-				deleteFile := true
-				for _, storageProxy := range storageProxies {
-					storage, ok := storageProxy.Get()
-					if !ok {
-						deleteFile = false
-						continue
-					}
-					if !u.statusManager.IsUploaded(fileName, storage.Name()) {
-						rowsCount, err := storage.Store(fileName, b)
-						if err != nil {
-							deleteFile = false
-							logging.Errorf("[%s] Error storing file %s in destination: %v", storage.Name(), filePath, err)
-							metrics.ErrorTokenEvents(tokenId, storage.Name(), rowsCount)
-							counters.ErrorEvents(storage.Name(), rowsCount)
-						} else {
-							metrics.SuccessTokenEvents(tokenId, storage.Name(), rowsCount)
-							counters.SuccessEvents(storage.Name(), rowsCount)
-						}
-						u.statusManager.UpdateStatus(fileName, storage.Name(), err)
-					}
-				}
+//Recover add log files which have been found mid-upload into the recovery report. It must be called before Start
+//File is mid-upload for the storage if it has failed or not saved upload status (e.g. crash) or has already been
+//partly staged. Staged files are skipped on the next upload (see ledger) so partly staged uploads are resumed,
+//other ones are processed again from the beginning
+func (u *PeriodicUploader) Recover() {
+	files, err := logging.GlobLogFiles(u.fileMask)
+	if err != nil {
+		logging.Error("Error finding files by mask", u.fileMask, err)
+		return
+	}
 
-				if deleteFile {
-					err := os.Remove(filePath)
-					if err != nil {
-						logging.Error("Error deleting file", filePath, err)
-					} else {
-						u.statusManager.CleanUp(fileName)
-					}
+	for _, filePath := range files {
+		fileName := filepath.Base(filePath)
+		regexResult := logging.TokenIdExtractRegexp.FindStringSubmatch(fileName)
+		if len(regexResult) != 2 {
+			continue
+		}
+
+		for _, storageProxy := range u.destinationService.GetStorages(regexResult[1]) {
+			storage, ok := storageProxy.Get()
+			if !ok {
+				continue
+			}
+
+			status, hasStatus := u.statusManager.Get(fileName, storage.Name())
+			if hasStatus && status.Uploaded {
+				continue
+			}
+
+			batch := &recovery.Batch{DestinationId: storage.Name(), Type: recovery.LogFileBatch, Name: fileName}
+			if ledger.Instance.HasFilePrefix(storage.Name(), fileName) {
+				batch.Resolution = recovery.Resumed
+				batch.Details = "already staged files will be skipped"
+			} else if hasStatus {
+				batch.Resolution = recovery.Restaged
+				batch.Details = "previous attempt error: " + status.Err
+			} else {
+				//hasn't been uploaded yet
+				continue
+			}
+			recovery.Instance.Add(batch)
+		}
+	}
+}
+
+//upload pass file to all token storages and remove it if all of them have stored it
+//files are kept for destinations with open circuit breakers: they are skipped (to keep files order) until the next
+//iteration where the oldest file is the probe write
+func (u *PeriodicUploader) upload(filePath string, skipped *sync.Map) {
+	fileName := filepath.Base(filePath)
+
+	b, err := logging.ReadLogFile(filePath)
+	if err != nil {
+		logging.Error("Error reading file", filePath, err)
+		return
+	}
+	if len(b) == 0 {
+		os.Remove(filePath)
+		return
+	}
+	b, err = encryption.DecryptLines(b)
+	if err != nil {
+		logging.Errorf("Error decrypting file %s: %v", filePath, err)
+		return
+	}
+	//get token from filename
+	regexResult := logging.TokenIdExtractRegexp.FindStringSubmatch(fileName)
+	if len(regexResult) != 2 {
+		logging.Errorf("Error processing file %s. Malformed name", filePath)
+		return
+	}
+
+	tokenId := regexResult[1]
+	storageProxies := u.destinationService.GetStorages(tokenId)
+	if len(storageProxies) == 0 {
+		logging.Warnf("Destination storages weren't found for file [%s] and token [%s]", filePath, tokenId)
+		return
+	}
+
+	//flag for deleting file if all storages don't have errors while storing this file
+	deleteFile := true
+	for _, storageProxy := range storageProxies {
+		storage, ok := storageProxy.Get()
+		if !ok {
+			deleteFile = false
+			continue
+		}
+		if !u.statusManager.IsUploaded(fileName, storage.Name()) {
+			if _, ok := skipped.Load(storage.Name()); ok || !breaker.Instance.Get(storage.Name()).Allow() {
+				skipped.Store(storage.Name(), true)
+				deleteFile = false
+				continue
+			}
+
+			rowsCount, err := u.store(storage, fileName, b)
+			if err != nil {
+				if retry.Get(storage.Name()).Retryable(err) {
+					breaker.Instance.Get(storage.Name()).Failed()
+				} else {
+					//non-retryable error is a response of the destination: it is available
+					breaker.Instance.Get(storage.Name()).Succeeded()
 				}
+				deleteFile = false
+				logging.Errorf("[%s] Error storing file %s in destination: %v", storage.Name(), filePath, err)
+				metrics.ErrorTokenEvents(tokenId, storage.Name(), rowsCount)
+				counters.ErrorEvents(storage.Name(), rowsCount)
+				health.Instance.Failed(storage.Name())
+			} else {
+				breaker.Instance.Get(storage.Name()).Succeeded()
+				metrics.SuccessTokenEvents(tokenId, storage.Name(), rowsCount)
+				counters.SuccessEvents(storage.Name(), rowsCount)
+				health.Instance.Succeeded(storage.Name())
+				reports.Instance.BatchLatency(storage.Name(), b)
+				latency.Instance.Batch(storage.Name(), b)
 			}
+			u.statusManager.UpdateStatus(fileName, storage.Name(), err)
+		}
+	}
 
-			time.Sleep(u.uploadEvery)
+	if deleteFile {
+		if err := u.remove(filePath); err != nil {
+			logging.Error("Error deleting file", filePath, err)
+		} else {
+			u.statusManager.CleanUp(fileName)
 		}
+	}
+}
+
+//Workers return current upload goroutines count
+func (u *PeriodicUploader) Workers() int {
+	return int(atomic.LoadInt32(&u.workers))
+}
+
+//SetWorkers change upload goroutines count. It is applied on the next upload iteration
+func (u *PeriodicUploader) SetWorkers(workers int) {
+	if workers < 1 {
+		workers = 1
+	}
+	atomic.StoreInt32(&u.workers, int32(workers))
+}
+
+//Backlog return count of log files which are being uploaded
+func (u *PeriodicUploader) Backlog() int {
+	u.backlogLock.RLock()
+	defer u.backlogLock.RUnlock()
+	return u.backlog
+}
+
+//Latency return age of the oldest log file which is being uploaded
+func (u *PeriodicUploader) Latency() time.Duration {
+	u.backlogLock.RLock()
+	defer u.backlogLock.RUnlock()
+	if u.oldestFile.IsZero() {
+		return 0
+	}
+	return time.Since(u.oldestFile)
+}
+
+func (u *PeriodicUploader) setBacklog(files []string) {
+	var oldest time.Time
+	for _, filePath := range files {
+		if info, err := os.Stat(filePath); err == nil && (oldest.IsZero() || info.ModTime().Before(oldest)) {
+			oldest = info.ModTime()
+		}
+	}
+
+	u.backlogLock.Lock()
+	u.backlog = len(files)
+	u.oldestFile = oldest
+	u.backlogLock.Unlock()
+}
+
+//remove delete uploaded file or move it into the archive
+func (u *PeriodicUploader) remove(filePath string) error {
+	if u.archive != nil {
+		return u.archive.Put(filePath)
+	}
+
+	return os.Remove(filePath)
+}
+
+//store pass payload to storage: retryable errors are retried according to the destination retry policy
+//applies test-mode fault injection: latency, errors and partial failures (failed lines are sent to fallback
+//only if other lines have been stored ok)
+func (u *PeriodicUploader) store(storage events.Storage, fileName string, payload []byte) (int, error) {
+	if err := faults.Instance.Inject(storage.Name()); err != nil {
+		return 0, err
+	}
+
+	payload, failedLines := faults.Instance.Split(storage.Name(), payload)
+	var rowsCount int
+	err := retry.Get(storage.Name()).Do("store", func() (err error) {
+		rowsCount, err = storage.Store(fileName, payload)
+		return err
 	})
+	if err != nil || len(failedLines) == 0 {
+		return rowsCount, err
+	}
+
+	errMsg := faults.Instance.ErrorMessage(storage.Name())
+	var failedFacts []*events.FailedFact
+	for _, line := range failedLines {
+		failedFacts = append(failedFacts, &events.FailedFact{Event: line, Error: errMsg})
+	}
+	storage.Fallback(failedFacts...)
+	counters.ErrorEvents(storage.Name(), len(failedFacts))
+
+	return rowsCount, nil
 }
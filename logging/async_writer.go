@@ -0,0 +1,115 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DefaultAsyncBufferSize = 10000
+
+	asyncMaxBatchBytes = 64 * 1024
+	asyncFlushTimeout  = 5 * time.Second
+)
+
+//AsyncWriter is a non-blocking writer: lines are put into the buffered channel and written into the underlying writer
+//in batches by a separate goroutine. If the buffer is full - lines are dropped (and counted) so the caller
+//(e.g. events processing) is never blocked by slow stdout or disk
+type AsyncWriter struct {
+	writer  io.Writer
+	lines   chan []byte
+	flushCh chan chan struct{}
+
+	dropped uint64
+}
+
+//NewAsyncWriter return AsyncWriter with bufferSize lines buffer and run writing goroutine
+func NewAsyncWriter(writer io.Writer, bufferSize int) *AsyncWriter {
+	if bufferSize <= 0 {
+		bufferSize = DefaultAsyncBufferSize
+	}
+	aw := &AsyncWriter{
+		writer:  writer,
+		lines:   make(chan []byte, bufferSize),
+		flushCh: make(chan chan struct{}),
+	}
+	go aw.start()
+	return aw
+}
+
+//Write put copy of the line into the buffer or drop it if the buffer is full. It never returns error
+func (aw *AsyncWriter) Write(p []byte) (int, error) {
+	line := make([]byte, len(p))
+	copy(line, p)
+
+	select {
+	case aw.lines <- line:
+	default:
+		atomic.AddUint64(&aw.dropped, 1)
+	}
+	return len(p), nil
+}
+
+//Flush wait until all buffered lines are written (at most 5 seconds)
+func (aw *AsyncWriter) Flush() {
+	done := make(chan struct{})
+	select {
+	case aw.flushCh <- done:
+	case <-time.After(asyncFlushTimeout):
+		return
+	}
+
+	select {
+	case <-done:
+	case <-time.After(asyncFlushTimeout):
+	}
+}
+
+//Dropped return count of lines which were dropped because of the full buffer
+func (aw *AsyncWriter) Dropped() uint64 {
+	return atomic.LoadUint64(&aw.dropped)
+}
+
+func (aw *AsyncWriter) start() {
+	batch := make([]byte, 0, asyncMaxBatchBytes)
+	var reportedDropped uint64
+	for {
+		var done chan struct{}
+		select {
+		case line := <-aw.lines:
+			batch = append(batch, line...)
+			//take all buffered lines up to the batch limit without blocking
+			for len(batch) < asyncMaxBatchBytes && len(aw.lines) > 0 {
+				batch = append(batch, <-aw.lines...)
+			}
+		case done = <-aw.flushCh:
+			for len(aw.lines) > 0 {
+				batch = append(batch, <-aw.lines...)
+			}
+		}
+
+		if dropped := aw.Dropped(); dropped != reportedDropped {
+			batch = append(batch, fmt.Sprintf("%s %s Async logger buffer is full: %d log lines have been dropped\n", time.Now().UTC().Format(dateTimeLayout), warnPrefix, dropped-reportedDropped)...)
+			reportedDropped = dropped
+		}
+		batch = aw.write(batch)
+
+		if done != nil {
+			close(done)
+		}
+	}
+}
+
+//write batch into the underlying writer and return empty batch for reuse
+func (aw *AsyncWriter) write(batch []byte) []byte {
+	if len(batch) > 0 {
+		//underlying writer error can't be logged (it would be written into the same writer)
+		aw.writer.Write(batch)
+	}
+	if cap(batch) > 4*asyncMaxBatchBytes {
+		return make([]byte, 0, asyncMaxBatchBytes)
+	}
+	return batch[:0]
+}
@@ -0,0 +1,63 @@
+package logging
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type blockingWriter struct {
+	sync.Mutex
+	buf     bytes.Buffer
+	blocked chan struct{}
+}
+
+func (bw *blockingWriter) Write(p []byte) (int, error) {
+	<-bw.blocked
+	bw.Lock()
+	defer bw.Unlock()
+	return bw.buf.Write(p)
+}
+
+func (bw *blockingWriter) String() string {
+	bw.Lock()
+	defer bw.Unlock()
+	return bw.buf.String()
+}
+
+func TestAsyncWriter(t *testing.T) {
+	writer := &blockingWriter{blocked: make(chan struct{})}
+	close(writer.blocked)
+	aw := NewAsyncWriter(writer, 100)
+
+	for _, line := range []string{"line1\n", "line2\n", "line3\n"} {
+		n, err := aw.Write([]byte(line))
+		require.NoError(t, err)
+		require.Equal(t, len(line), n)
+	}
+	aw.Flush()
+
+	require.Equal(t, "line1\nline2\nline3\n", writer.String())
+	require.Equal(t, uint64(0), aw.Dropped())
+}
+
+func TestAsyncWriterDropsWhenFull(t *testing.T) {
+	writer := &blockingWriter{blocked: make(chan struct{})}
+	aw := NewAsyncWriter(writer, 2)
+
+	//the first line is taken by the writing goroutine which is blocked by the writer, the next 2 lines fill the buffer
+	for i := 0; i < 10; i++ {
+		aw.Write([]byte("line\n"))
+	}
+	require.True(t, aw.Dropped() > 0, "writes mustn't block if the buffer is full")
+
+	close(writer.blocked)
+	aw.Flush()
+	require.Contains(t, writer.String(), "log lines have been dropped")
+
+	aw.Write([]byte("last\n"))
+	aw.Flush()
+	require.True(t, strings.HasSuffix(writer.String(), "last\n"))
+}
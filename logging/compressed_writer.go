@@ -0,0 +1,137 @@
+package logging
+
+import (
+	"fmt"
+	"github.com/jitsucom/eventnative/compression"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+//backupTimeFormat is the same as lumberjack rotated files time format
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+//compressedFileWriter write data into the gzip or zstd compressed file and rotate it by uncompressed size
+//(the same as lumberjack rotation of plain files) or on Rotate() call.
+//Rotated files have lumberjack names with the compression extension: server-logger-2006-01-02T15-04-05.000.log.gz
+type compressedFileWriter struct {
+	sync.Mutex
+	filePath    string
+	compression string
+	maxSize     int64
+
+	file       *os.File
+	compressor io.WriteCloser
+	size       int64
+}
+
+func newCompressedFileWriter(filePath, compressionName string, maxSize int64) *compressedFileWriter {
+	return &compressedFileWriter{filePath: filePath + compression.Extension(compressionName), compression: compressionName, maxSize: maxSize}
+}
+
+//Write compress p into the current file. The file is opened on the first write after rotation
+func (cfw *compressedFileWriter) Write(p []byte) (int, error) {
+	cfw.Lock()
+	defer cfw.Unlock()
+
+	if cfw.size > 0 && cfw.size+int64(len(p)) > cfw.maxSize {
+		if err := cfw.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	if cfw.file == nil {
+		if err := cfw.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := cfw.compressor.Write(p)
+	cfw.size += int64(n)
+	return n, err
+}
+
+//Rotate close the current file and rename it with the rotation time. Nothing is done if there were no writes
+func (cfw *compressedFileWriter) Rotate() error {
+	cfw.Lock()
+	defer cfw.Unlock()
+
+	return cfw.rotate()
+}
+
+//Close flush compressed data and close the current file without rotation
+func (cfw *compressedFileWriter) Close() error {
+	cfw.Lock()
+	defer cfw.Unlock()
+
+	return cfw.close()
+}
+
+//open create the current file. Compressed stream can't be continued, so the file which is left after the previous run
+//(e.g. crash) is rotated as is
+func (cfw *compressedFileWriter) open() error {
+	if info, err := os.Stat(cfw.filePath); err == nil && info.Size() > 0 {
+		if err := os.Rename(cfw.filePath, cfw.backupName(info.ModTime())); err != nil {
+			return fmt.Errorf("Error rotating previous log file %s: %v", cfw.filePath, err)
+		}
+	}
+
+	file, err := os.OpenFile(cfw.filePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("Error opening log file %s: %v", cfw.filePath, err)
+	}
+	compressor, err := compression.NewWriter(cfw.compression, file)
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	cfw.file = file
+	cfw.compressor = compressor
+	cfw.size = 0
+	return nil
+}
+
+func (cfw *compressedFileWriter) rotate() error {
+	if cfw.file == nil {
+		return nil
+	}
+	if err := cfw.close(); err != nil {
+		return err
+	}
+	if err := os.Rename(cfw.filePath, cfw.backupName(time.Now())); err != nil {
+		return fmt.Errorf("Error rotating log file %s: %v", cfw.filePath, err)
+	}
+	return nil
+}
+
+func (cfw *compressedFileWriter) close() error {
+	if cfw.file == nil {
+		return nil
+	}
+
+	compressorErr := cfw.compressor.Close()
+	fileErr := cfw.file.Close()
+	cfw.file = nil
+	cfw.compressor = nil
+	if compressorErr != nil {
+		return fmt.Errorf("Error flushing compressed log file %s: %v", cfw.filePath, compressorErr)
+	}
+	if fileErr != nil {
+		return fmt.Errorf("Error closing log file %s: %v", cfw.filePath, fileErr)
+	}
+	return nil
+}
+
+//backupName return not existing rotated file path: time is inserted before .log extension
+func (cfw *compressedFileWriter) backupName(t time.Time) string {
+	extension := ".log" + compression.Extension(cfw.compression)
+	for {
+		name := strings.TrimSuffix(cfw.filePath, extension) + "-" + t.UTC().Format(backupTimeFormat) + extension
+		if _, err := os.Stat(name); os.IsNotExist(err) {
+			return name
+		}
+		t = t.Add(time.Millisecond)
+	}
+}
@@ -0,0 +1,41 @@
+package logging
+
+import (
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompressedFileWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "compressed_writer")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	line := strings.Repeat("a", 99) + "\n"
+	writer := newCompressedFileWriter(filepath.Join(dir, "server-event-token.log"), "zstd", 250)
+	for i := 0; i < 5; i++ {
+		_, err := writer.Write([]byte(line))
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Rotate())
+	//without writes
+	require.NoError(t, writer.Rotate())
+
+	files, err := GlobLogFiles(filepath.Join(dir, "server-event-*-20*.log"))
+	require.NoError(t, err)
+	//rotated by size after 2 lines
+	require.Len(t, files, 3)
+
+	var content string
+	for _, file := range files {
+		require.True(t, strings.HasSuffix(file, ".log.zst"), file)
+		require.Equal(t, "token", TokenIdExtractRegexp.FindStringSubmatch(filepath.Base(file))[1])
+		b, err := ReadLogFile(file)
+		require.NoError(t, err)
+		content += string(b)
+	}
+	require.Equal(t, strings.Repeat(line, 5), content)
+}
@@ -5,10 +5,12 @@ import (
 	"time"
 )
 
+const dateTimeLayout = "2006-01-02 15:04:05"
+
 type DateTimeWriterProxy struct {
 	writer io.Writer
 }
 
 func (wp DateTimeWriterProxy) Write(bytes []byte) (int, error) {
-	return wp.writer.Write([]byte(time.Now().UTC().Format("2006-01-02 15:04:05") + " " + string(bytes)))
+	return wp.writer.Write([]byte(time.Now().UTC().Format(dateTimeLayout) + " " + string(bytes)))
 }
@@ -3,11 +3,14 @@ package logging
 import (
 	"fmt"
 	"github.com/google/martian/log"
+	"github.com/jitsucom/eventnative/compression"
 	"github.com/jitsucom/eventnative/safego"
 	"gopkg.in/natefinch/lumberjack.v2"
 	"io"
+	"io/ioutil"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"time"
 )
 
@@ -16,19 +19,38 @@ const logFileMaxSizeMB = 100
 //regex for reading already rotated and closed log files
 var TokenIdExtractRegexp = regexp.MustCompile("-event-(.*)-\\d\\d\\d\\d-\\d\\d-\\d\\dT")
 
+//rotatingWriter is a lumberjack.Logger or a compressedFileWriter
+type rotatingWriter interface {
+	io.WriteCloser
+	Rotate() error
+}
+
 type WriterProxy struct {
-	lWriter       *lumberjack.Logger
+	lWriter       rotatingWriter
 	rotateOnClose bool
 }
 
+//NewRollingWriter return writer into the log file which is rotated by size and every config.RotationMin.
+//Files are written with config.Compression if it is set (see compressedFileWriter)
 func NewRollingWriter(config Config) io.WriteCloser {
 	fileNamePath := filepath.Join(config.FileDir, fmt.Sprintf("%s-%s.log", config.ServerName, config.LoggerName))
-	lWriter := &lumberjack.Logger{
-		Filename: fileNamePath,
-		MaxSize:  logFileMaxSizeMB,
+	maxSizeMB := logFileMaxSizeMB
+	if config.MaxSizeMB > 0 {
+		maxSizeMB = config.MaxSizeMB
 	}
-	if config.MaxBackups > 0 {
-		lWriter.MaxBackups = config.MaxBackups
+
+	var lWriter rotatingWriter
+	if config.Compression != "" {
+		lWriter = newCompressedFileWriter(fileNamePath, config.Compression, int64(maxSizeMB)*1024*1024)
+	} else {
+		lumberjackWriter := &lumberjack.Logger{
+			Filename: fileNamePath,
+			MaxSize:  maxSizeMB,
+		}
+		if config.MaxBackups > 0 {
+			lumberjackWriter.MaxBackups = config.MaxBackups
+		}
+		lWriter = lumberjackWriter
 	}
 
 	if config.RotationMin == 0 {
@@ -48,6 +70,36 @@ func NewRollingWriter(config Config) io.WriteCloser {
 	return &WriterProxy{lWriter: lWriter, rotateOnClose: config.RotateOnClose}
 }
 
+//GlobLogFiles return paths of log files by mask (with .log extension) and of the compressed ones (with .log.gz or .log.zst)
+func GlobLogFiles(fileMask string) ([]string, error) {
+	var files []string
+	for _, extension := range []string{"", compression.Extension(compression.Gzip), compression.Extension(compression.Zstd)} {
+		matched, err := filepath.Glob(fileMask + extension)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matched...)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+//ReadLogFile return the log file content. Compressed files are decompressed: events of truncated files (e.g. after a crash)
+//are read until the truncation point
+func ReadLogFile(filePath string) ([]byte, error) {
+	b, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err = compression.Decompress(b)
+	if err == compression.ErrTruncated {
+		Warnf("Compressed file %s is truncated: only %d bytes are read", filePath, len(b))
+		return b, nil
+	}
+	return b, err
+}
+
 func (wp *WriterProxy) Write(p []byte) (int, error) {
 	return wp.lWriter.Write(p)
 }
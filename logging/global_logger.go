@@ -4,9 +4,10 @@ import (
 	"errors"
 	"fmt"
 	"github.com/gookit/color"
+	"github.com/jitsucom/eventnative/compression"
 	"github.com/jitsucom/eventnative/notifications"
 	"io"
-	"log"
+	"os"
 	"strings"
 )
 
@@ -24,6 +25,10 @@ type Config struct {
 	RotationMin   int64
 	MaxBackups    int
 	RotateOnClose bool
+	//MaxSizeMB is a max size of the uncompressed file data. Default is 100
+	MaxSizeMB int
+	//Compression is gzip or zstd. Plain files are written if it is empty. MaxBackups isn't applied to compressed files
+	Compression string
 }
 
 func (c Config) Validate() error {
@@ -33,21 +38,36 @@ func (c Config) Validate() error {
 	if c.ServerName == "" {
 		return errors.New("Server name can't be empty")
 	}
+	if err := compression.Validate(c.Compression); err != nil {
+		return err
+	}
 
 	return nil
 }
 
+//asyncWriter is set if global logger is asynchronous
+var asyncWriter *AsyncWriter
+
 //Initialize main logger
 func InitGlobalLogger(writer io.Writer) error {
-	dateTimeWriter := DateTimeWriterProxy{
-		writer: writer,
-	}
-	log.SetOutput(dateTimeWriter)
-	log.SetFlags(0)
+	setOutput(writer)
 
 	return nil
 }
 
+//InitAsyncGlobalLogger initialize main logger which never blocks callers: lines are written by AsyncWriter in batches
+func InitAsyncGlobalLogger(writer io.Writer, bufferSize int) error {
+	asyncWriter = NewAsyncWriter(writer, bufferSize)
+	return InitGlobalLogger(asyncWriter)
+}
+
+//Flush wait until all lines of asynchronous global logger are written. It must be called before os.Exit
+func Flush() {
+	if asyncWriter != nil {
+		asyncWriter.Flush()
+	}
+}
+
 func SystemErrorf(format string, v ...interface{}) {
 	SystemError(fmt.Sprintf(format, v...))
 }
@@ -64,7 +84,7 @@ func Errorf(format string, v ...interface{}) {
 }
 
 func Error(v ...interface{}) {
-	log.Println(errMsg(v...))
+	root.write(ErrorLevel, sprint(v...))
 }
 
 func Infof(format string, v ...interface{}) {
@@ -72,7 +92,7 @@ func Infof(format string, v ...interface{}) {
 }
 
 func Info(v ...interface{}) {
-	log.Println(append([]interface{}{infoPrefix}, v...)...)
+	root.write(InfoLevel, sprint(v...))
 }
 
 func Debugf(format string, v ...interface{}) {
@@ -80,7 +100,7 @@ func Debugf(format string, v ...interface{}) {
 }
 
 func Debug(v ...interface{}) {
-	log.Println(append([]interface{}{debugPrefix}, v...)...)
+	root.write(DebugLevel, sprint(v...))
 }
 
 func Warnf(format string, v ...interface{}) {
@@ -88,21 +108,26 @@ func Warnf(format string, v ...interface{}) {
 }
 
 func Warn(v ...interface{}) {
-	log.Println(append([]interface{}{warnPrefix}, v...)...)
+	root.write(WarnLevel, sprint(v...))
 }
 
 func Fatal(v ...interface{}) {
-	log.Fatal(errMsg(v...))
+	root.write(ErrorLevel, sprint(v...))
+	Flush()
+	os.Exit(1)
 }
 
 func Fatalf(format string, v ...interface{}) {
-	log.Fatalf(format, errMsg(v...))
+	root.write(ErrorLevel, fmt.Sprintf(format, v...))
+	Flush()
+	os.Exit(1)
 }
 
-func errMsg(values ...interface{}) string {
-	valuesStr := []string{errPrefix}
-	for _, v := range values {
-		valuesStr = append(valuesStr, fmt.Sprint(v))
-	}
-	return color.Red.Sprint(strings.Join(valuesStr, " "))
+//sprint join values with spaces (the same as log.Println)
+func sprint(values ...interface{}) string {
+	return strings.TrimSuffix(fmt.Sprintln(values...), "\n")
+}
+
+func errMsg(msg string) string {
+	return color.Red.Sprint(errPrefix + " " + msg)
 }
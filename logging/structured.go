@@ -0,0 +1,233 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jitsucom/eventnative/notifications"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	TextFormat = "text"
+	JsonFormat = "json"
+
+	SchemaComponent       = "schema"
+	EventsComponent       = "events"
+	DestinationsComponent = "destinations"
+
+	EventIdField     = "event_id"
+	DestinationField = "destination"
+
+	jsonTimeLayout = "2006-01-02T15:04:05.000Z"
+)
+
+type Level int
+
+const (
+	DebugLevel Level = iota
+	InfoLevel
+	WarnLevel
+	ErrorLevel
+)
+
+var levelNames = map[Level]string{DebugLevel: "debug", InfoLevel: "info", WarnLevel: "warn", ErrorLevel: "error"}
+
+func (l Level) String() string {
+	return levelNames[l]
+}
+
+//ParseLevel return Level by name: debug, info, warn or error
+func ParseLevel(name string) (Level, error) {
+	for level, levelName := range levelNames {
+		if strings.ToLower(name) == levelName {
+			return level, nil
+		}
+	}
+	return DebugLevel, fmt.Errorf("Unknown log level: %s. Available: [debug, info, warn, error]", name)
+}
+
+//Fields are key-value pairs which are written with log line (e.g. event_id and destination of processing logs)
+type Fields map[string]interface{}
+
+var (
+	//globalWriter is an output of the global logger. Text lines are written with date time prefix
+	globalWriter io.Writer
+	format       = TextFormat
+	defaultLevel = DebugLevel
+	//componentLevels overrides defaultLevel for the components (e.g. schema: warn)
+	componentLevels = map[string]Level{}
+
+	root = &Logger{}
+)
+
+//Configure set output format (text or json), the default level and per component levels of the global logger.
+//It must be called on startup before logging from other goroutines
+func Configure(outputFormat, level string, levels map[string]string) error {
+	if outputFormat == "" {
+		outputFormat = TextFormat
+	}
+	if outputFormat != TextFormat && outputFormat != JsonFormat {
+		return fmt.Errorf("Unknown log format: %s. Available: [%s, %s]", outputFormat, TextFormat, JsonFormat)
+	}
+
+	parsedDefault := DebugLevel
+	if level != "" {
+		var err error
+		if parsedDefault, err = ParseLevel(level); err != nil {
+			return err
+		}
+	}
+	parsedLevels := map[string]Level{}
+	for component, componentLevel := range levels {
+		parsedLevel, err := ParseLevel(componentLevel)
+		if err != nil {
+			return fmt.Errorf("Component [%s]: %v", component, err)
+		}
+		parsedLevels[component] = parsedLevel
+	}
+
+	format = outputFormat
+	defaultLevel = parsedDefault
+	componentLevels = parsedLevels
+	if globalWriter != nil {
+		setOutput(globalWriter)
+	}
+	return nil
+}
+
+//setOutput set the global logger output: JSON lines have time field and are written as is
+func setOutput(writer io.Writer) {
+	globalWriter = writer
+	if format == JsonFormat {
+		log.SetOutput(writer)
+	} else {
+		log.SetOutput(DateTimeWriterProxy{writer: writer})
+	}
+	log.SetFlags(0)
+}
+
+//Logger writes lines of the component with fields. Lines with level lower than the component level are skipped
+type Logger struct {
+	component string
+	fields    Fields
+}
+
+//Component return logger of the component (schema, events, destinations or any other name)
+func Component(name string) *Logger {
+	return &Logger{component: name}
+}
+
+//With return copy of the logger with the fields added
+func (l *Logger) With(fields Fields) *Logger {
+	merged := make(Fields, len(l.fields)+len(fields))
+	for key, value := range l.fields {
+		merged[key] = value
+	}
+	for key, value := range fields {
+		merged[key] = value
+	}
+	return &Logger{component: l.component, fields: merged}
+}
+
+//WithEventId return copy of the logger with event_id field
+func (l *Logger) WithEventId(eventId string) *Logger {
+	return l.With(Fields{EventIdField: eventId})
+}
+
+//WithDestination return copy of the logger with destination field
+func (l *Logger) WithDestination(destinationName string) *Logger {
+	return l.With(Fields{DestinationField: destinationName})
+}
+
+//Enabled return true if lines of the level are written
+func (l *Logger) Enabled(level Level) bool {
+	if componentLevel, ok := componentLevels[l.component]; ok {
+		return level >= componentLevel
+	}
+	return level >= defaultLevel
+}
+
+func (l *Logger) Debugf(format string, v ...interface{}) {
+	l.write(DebugLevel, fmt.Sprintf(format, v...))
+}
+
+func (l *Logger) Infof(format string, v ...interface{}) {
+	l.write(InfoLevel, fmt.Sprintf(format, v...))
+}
+
+func (l *Logger) Warnf(format string, v ...interface{}) {
+	l.write(WarnLevel, fmt.Sprintf(format, v...))
+}
+
+func (l *Logger) Errorf(format string, v ...interface{}) {
+	l.write(ErrorLevel, fmt.Sprintf(format, v...))
+}
+
+//SystemErrorf write error line and send system error notification
+func (l *Logger) SystemErrorf(format string, v ...interface{}) {
+	msg := "System error: " + fmt.Sprintf(format, v...)
+	l.write(ErrorLevel, msg)
+	notifications.SystemError(msg)
+}
+
+func (l *Logger) write(level Level, msg string) {
+	if !l.Enabled(level) {
+		return
+	}
+
+	if format == JsonFormat {
+		line := make(map[string]interface{}, len(l.fields)+4)
+		for key, value := range l.fields {
+			line[key] = value
+		}
+		line["time"] = time.Now().UTC().Format(jsonTimeLayout)
+		line["level"] = level.String()
+		line["msg"] = msg
+		if l.component != "" {
+			line["component"] = l.component
+		}
+		data, err := json.Marshal(line)
+		if err != nil {
+			data, _ = json.Marshal(map[string]interface{}{"time": line["time"], "level": line["level"], "msg": msg, "marshal_error": err.Error()})
+		}
+		log.Println(string(data))
+		return
+	}
+
+	text := msg
+	if l.component != "" || len(l.fields) > 0 {
+		text += l.textFields()
+	}
+	switch level {
+	case ErrorLevel:
+		log.Println(errMsg(text))
+	case WarnLevel:
+		log.Println(warnPrefix, text)
+	case InfoLevel:
+		log.Println(infoPrefix, text)
+	default:
+		log.Println(debugPrefix, text)
+	}
+}
+
+//textFields return sorted key=value pairs of the component and fields
+func (l *Logger) textFields() string {
+	keys := make([]string, 0, len(l.fields))
+	for key := range l.fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var builder strings.Builder
+	if l.component != "" {
+		builder.WriteString(" component=" + l.component)
+	}
+	for _, key := range keys {
+		builder.WriteString(fmt.Sprintf(" %s=%v", key, l.fields[key]))
+	}
+	return builder.String()
+}
@@ -0,0 +1,51 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestStructuredLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	InitGlobalLogger(buf)
+	defer func() {
+		require.NoError(t, Configure(TextFormat, "", nil))
+		InitGlobalLogger(os.Stdout)
+	}()
+
+	require.NoError(t, Configure(JsonFormat, "info", map[string]string{SchemaComponent: "warn"}))
+
+	logger := Component(DestinationsComponent).WithDestination("pg").WithEventId("id1")
+	logger.Debugf("skipped")
+	logger.Errorf("Error inserting object: %v", "connection refused")
+	Component(SchemaComponent).Infof("skipped")
+	Infof("[%s] initialized", "pg")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+
+	line := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &line))
+	require.NotEmpty(t, line["time"])
+	delete(line, "time")
+	require.Equal(t, map[string]interface{}{"level": "error", "msg": "Error inserting object: connection refused",
+		"component": "destinations", "destination": "pg", "event_id": "id1"}, line)
+
+	line = map[string]interface{}{}
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &line))
+	require.Equal(t, "info", line["level"])
+	require.Equal(t, "[pg] initialized", line["msg"])
+	require.NotContains(t, line, "component")
+
+	buf.Reset()
+	require.NoError(t, Configure(TextFormat, "", nil))
+	Component(EventsComponent).WithEventId("id2").Warnf("Unable to enqueue object")
+	require.True(t, strings.HasSuffix(buf.String(), "[WARN]: Unable to enqueue object component=events event_id=id2\n"), buf.String())
+
+	require.EqualError(t, Configure("xml", "", nil), "Unknown log format: xml. Available: [text, json]")
+	require.EqualError(t, Configure(JsonFormat, "", map[string]string{SchemaComponent: "verbose"}), "Component [schema]: Unknown log level: verbose. Available: [debug, info, warn, error]")
+}
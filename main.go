@@ -4,33 +4,69 @@ import (
 	"bytes"
 	"context"
 	"flag"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
+	"github.com/jitsucom/eventnative/acks"
+	"github.com/jitsucom/eventnative/aggregation"
 	"github.com/jitsucom/eventnative/appconfig"
 	"github.com/jitsucom/eventnative/appstatus"
+	"github.com/jitsucom/eventnative/autoscaling"
+	"github.com/jitsucom/eventnative/botfilter"
+	"github.com/jitsucom/eventnative/breaker"
 	"github.com/jitsucom/eventnative/caching"
+	"github.com/jitsucom/eventnative/clientip"
+	"github.com/jitsucom/eventnative/clientversion"
 	"github.com/jitsucom/eventnative/cluster"
+	"github.com/jitsucom/eventnative/clustering"
+	"github.com/jitsucom/eventnative/compression"
+	"github.com/jitsucom/eventnative/configversions"
 	"github.com/jitsucom/eventnative/counters"
+	"github.com/jitsucom/eventnative/declarative"
 	"github.com/jitsucom/eventnative/destinations"
+	"github.com/jitsucom/eventnative/drift"
+	"github.com/jitsucom/eventnative/encryption"
 	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/eventstore"
 	"github.com/jitsucom/eventnative/fallback"
+	"github.com/jitsucom/eventnative/faults"
+	"github.com/jitsucom/eventnative/grpcapi"
 	"github.com/jitsucom/eventnative/handlers"
+	"github.com/jitsucom/eventnative/health"
+	"github.com/jitsucom/eventnative/identity"
+	"github.com/jitsucom/eventnative/introspection"
+	"github.com/jitsucom/eventnative/latency"
+	"github.com/jitsucom/eventnative/ledger"
 	"github.com/jitsucom/eventnative/logfiles"
 	"github.com/jitsucom/eventnative/logging"
 	"github.com/jitsucom/eventnative/meta"
 	"github.com/jitsucom/eventnative/metrics"
 	"github.com/jitsucom/eventnative/middleware"
+	"github.com/jitsucom/eventnative/migration"
 	"github.com/jitsucom/eventnative/notifications"
+	"github.com/jitsucom/eventnative/parsers"
+	"github.com/jitsucom/eventnative/recovery"
+	"github.com/jitsucom/eventnative/replay"
+	"github.com/jitsucom/eventnative/reports"
+	"github.com/jitsucom/eventnative/retention"
 	"github.com/jitsucom/eventnative/safego"
+	"github.com/jitsucom/eventnative/secrets"
+	"github.com/jitsucom/eventnative/sessions"
+	"github.com/jitsucom/eventnative/sharding"
 	"github.com/jitsucom/eventnative/sources"
 	"github.com/jitsucom/eventnative/storages"
 	"github.com/jitsucom/eventnative/synchronization"
 	"github.com/jitsucom/eventnative/telemetry"
+	"github.com/jitsucom/eventnative/timestamp"
+	"github.com/jitsucom/eventnative/trackingplan"
+	"github.com/jitsucom/eventnative/validation"
+	"github.com/jitsucom/eventnative/workspaces"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"math/rand"
 	"net/http"
 	"os"
 	"os/signal"
+	"path"
 	"runtime/debug"
 	"strings"
 	"syscall"
@@ -45,6 +81,13 @@ const (
 	uploaderFileMask   = "-event-*-20*.log"
 	uploaderLoadEveryS = 60
 
+	ledgerDir         = "ledger"
+	configVersionsDir = "config_versions"
+	sourcesStateDir   = "sources_state"
+
+	devToken           = "dev"
+	devDestinationName = "dev"
+
 	destinationsKey = "destinations"
 	sourcesKey      = "sources"
 )
@@ -52,6 +95,9 @@ const (
 var (
 	configFilePath   = flag.String("cfg", "", "config file path")
 	containerizedRun = flag.Bool("cr", false, "containerised run marker")
+	devMode          = flag.Bool("dev", false, "dev mode: in-memory destination with /dev web page of received tables, rows and processing traces. Don't use it in production")
+	validate         = flag.Bool("validate", false, "validate mode: compile the configuration, test-connect to meta storage and destinations, print all problems and exit")
+	processorMode    = flag.Bool("processor", false, "processor service mode: only gRPC processing API (mapping, enrichment, typing) of configured destinations is served, events aren't stored")
 
 	//ldflags
 	commit  string
@@ -77,6 +123,73 @@ func readInViperConfig() error {
 	return nil
 }
 
+//setupDevMode configure defaults for running locally without any config and warehouse:
+//in-memory stream destination (if destinations aren't configured), 'dev' token (if tokens aren't configured) and tmp logs dirs
+func setupDevMode() {
+	logging.Warn("Dev mode is enabled! Received tables and rows are available on /dev page. Don't use it in production")
+
+	if !viper.IsSet(destinationsKey) && viper.GetString("destinations_json") == "" {
+		viper.Set(destinationsKey, map[string]interface{}{
+			devDestinationName: map[string]interface{}{"type": storages.MemoryType, "mode": storages.StreamMode},
+		})
+		logging.Infof("Dev mode: in-memory destination [%s] is configured", devDestinationName)
+	}
+
+	if !viper.IsSet("server.auth") && !viper.IsSet("server.s2s_auth") {
+		viper.Set("server.auth", []string{devToken})
+		logging.Infof("Dev mode: use token [%s] for sending events", devToken)
+	}
+
+	devDir := path.Join(os.TempDir(), "eventnative-dev")
+	if !viper.IsSet("log.path") {
+		viper.Set("log.path", path.Join(devDir, "events"))
+	}
+	if !viper.IsSet("log.fallback") {
+		viper.Set("log.fallback", path.Join(devDir, "fallback"))
+	}
+	if !viper.IsSet("server.telemetry.disabled.usage") {
+		viper.Set("server.telemetry.disabled.usage", true)
+	}
+}
+
+//destinationsConfig return destinations viper config or source string (url, file, JSON). DESTINATIONS_JSON env overrides them
+func destinationsConfig() (*viper.Viper, string) {
+	destinationsViper := viper.Sub(destinationsKey)
+	destinationsStr := viper.GetString(destinationsKey)
+
+	//override with config from os env
+	destinationsJsonConfig := viper.GetString("destinations_json")
+	if destinationsJsonConfig != "" && destinationsJsonConfig != "{}" {
+		envJsonViper := viper.New()
+		envJsonViper.SetConfigType("json")
+		if err := envJsonViper.ReadConfig(bytes.NewBufferString(destinationsJsonConfig)); err != nil {
+			logging.Error("Error reading/parsing json config from DESTINATIONS_JSON", err)
+		} else {
+			destinationsViper = envJsonViper.Sub(destinationsKey)
+			destinationsStr = envJsonViper.GetString(destinationsKey)
+		}
+	}
+	return destinationsViper, destinationsStr
+}
+
+//metaStorageConfig return meta.storage viper config. META_STORAGE_JSON env overrides it
+func metaStorageConfig() *viper.Viper {
+	metaStorageViper := viper.Sub("meta.storage")
+
+	//override with config from os env
+	metaStorageJsonConfig := viper.GetString("meta_storage_json")
+	if metaStorageJsonConfig != "" && metaStorageJsonConfig != "{}" {
+		envJsonViper := viper.New()
+		envJsonViper.SetConfigType("json")
+		if err := envJsonViper.ReadConfig(bytes.NewBufferString(metaStorageJsonConfig)); err != nil {
+			logging.Error("Error reading/parsing json config from META_STORAGE_JSON", err)
+		} else {
+			metaStorageViper = envJsonViper.Sub("meta_storage")
+		}
+	}
+	return metaStorageViper
+}
+
 //go:generate easyjson -all useragent/resolver.go telemetry/models.go
 func main() {
 	//Setup seed for globalRand
@@ -92,6 +205,14 @@ func main() {
 		logging.Fatal("Error while reading application config: ", err)
 	}
 
+	if *devMode {
+		setupDevMode()
+	}
+
+	if *validate {
+		os.Exit(validateConfig(os.Stdout))
+	}
+
 	if err := appconfig.Init(); err != nil {
 		logging.Fatal(err)
 	}
@@ -105,6 +226,53 @@ func main() {
 
 	telemetry.Init(commit, tag, builtAt, viper.GetBool("server.telemetry.disabled.usage"))
 	metrics.Init(viper.GetBool("server.metrics.prometheus.enabled"))
+	latency.Init(viper.GetInt("server.latency.window_size"))
+	introspection.Init(viper.GetInt("server.introspection.recent_events"), viper.GetInt("server.introspection.failed_events"))
+
+	//source batches delivery acknowledgments
+	acksConfig := &acks.Config{}
+	if err := viper.UnmarshalKey("server.acks", acksConfig); err != nil {
+		logging.Fatal("Error parsing server.acks config:", err)
+	}
+	acks.Init(acksConfig)
+
+	//secrets references (env://, vault://, awssm://) in destinations configs: must be initialized before destinations
+	secretsConfig := &secrets.Config{}
+	if err := viper.UnmarshalKey("server.secrets", secretsConfig); err != nil {
+		logging.Fatal("Error parsing server.secrets config:", err)
+	}
+	secrets.Init(secretsConfig)
+
+	//short-term analytics store of processed events: must be initialized before destinations
+	eventStoreConfig := &eventstore.Config{}
+	if err := viper.UnmarshalKey("server.event_store", eventStoreConfig); err != nil {
+		logging.Fatal("Error parsing server.event_store config:", err)
+	}
+	eventstore.Init(eventStoreConfig)
+
+	//timestamps normalization zone: must be initialized before events processing
+	timestampsConfig := &timestamp.Config{}
+	if err := viper.UnmarshalKey("server.timestamps", timestampsConfig); err != nil {
+		logging.Fatal("Error parsing server.timestamps config:", err)
+	}
+	if err := timestamp.Init(timestampsConfig); err != nil {
+		logging.Fatal(err)
+	}
+
+	//encryption at rest of events log, fallback, archive files and stream queues: must be initialized before destinations
+	encryptionConfig := encryption.Config{}
+	if err := viper.UnmarshalKey("server.encryption", &encryptionConfig); err != nil {
+		logging.Fatal("Error parsing server.encryption config:", err)
+	}
+	if err := encryption.Init(encryptionConfig); err != nil {
+		logging.Fatal(err)
+	}
+
+	//JSON decoder of events log files, fallback files and stream payloads
+	if err := parsers.SetDecoder(viper.GetString("server.json_decoder")); err != nil {
+		logging.Fatal(err)
+	}
+	logging.Infof("JSON decoder: %s", parsers.Decoder().Name())
 
 	slackNotificationsWebHook := viper.GetString("notifications.slack.url")
 	if slackNotificationsWebHook != "" {
@@ -121,14 +289,33 @@ func main() {
 		telemetry.ServerStop()
 		appstatus.Instance.Idle = true
 		cancel()
-		appconfig.Instance.Close()
+		//stop accepting events, drain in-flight ones through processing shards into log files and queues, close destinations
+		drainTimeout := time.Duration(viper.GetInt("server.shutdown.drain_timeout_sec")) * time.Second
+		//event loggers write consumed events within the time left after HTTP requests and shards draining
+		events.SetFlushDeadline(time.Now().Add(drainTimeout))
+		if appconfig.Instance.Drain(drainTimeout) {
+			if err := recovery.Instance.Close(); err != nil {
+				logging.Error(err)
+			}
+		} else {
+			//running marker is kept: not finished uploads are recovered on the next start
+			logging.Errorf("Draining hasn't finished in %s: in-flight events and batches will be recovered on the next start", drainTimeout)
+		}
+		counters.Flush()
 		telemetry.Flush()
 		notifications.Close()
 		time.Sleep(3 * time.Second)
 		telemetry.Close()
+		logging.Flush()
 		os.Exit(0)
 	}()
 
+	if *processorMode {
+		runProcessorService()
+		//until shutdown signal
+		select {}
+	}
+
 	//synchronization service
 	syncService, err := synchronization.NewService(
 		ctx,
@@ -143,44 +330,18 @@ func main() {
 	// ** Destinations **
 
 	//destinations config
-	destinationsViper := viper.Sub(destinationsKey)
-	destinationsStr := viper.GetString(destinationsKey)
-
-	//override with config from os env
-	destinationsJsonConfig := viper.GetString("destinations_json")
-	if destinationsJsonConfig != "" && destinationsJsonConfig != "{}" {
-		envJsonViper := viper.New()
-		envJsonViper.SetConfigType("json")
-		if err := envJsonViper.ReadConfig(bytes.NewBufferString(destinationsJsonConfig)); err != nil {
-			logging.Error("Error reading/parsing json config from DESTINATIONS_JSON", err)
-		} else {
-			destinationsViper = envJsonViper.Sub(destinationsKey)
-			destinationsStr = envJsonViper.GetString(destinationsKey)
-		}
-	}
+	destinationsViper, destinationsStr := destinationsConfig()
 
 	//Get logger configuration
 	logEventPath := viper.GetString("log.path")
 	logFallbackPath := viper.GetString("log.fallback")
 	logRotationMin := viper.GetInt64("log.rotation_min")
-
-	//meta storage config
-	metaStorageViper := viper.Sub("meta.storage")
-
-	//override with config from os env
-	metaStorageJsonConfig := viper.GetString("meta_storage_json")
-	if metaStorageJsonConfig != "" && metaStorageJsonConfig != "{}" {
-		envJsonViper := viper.New()
-		envJsonViper.SetConfigType("json")
-		if err := envJsonViper.ReadConfig(bytes.NewBufferString(metaStorageJsonConfig)); err != nil {
-			logging.Error("Error reading/parsing json config from META_STORAGE_JSON", err)
-		} else {
-			metaStorageViper = envJsonViper.Sub("meta_storage")
-		}
+	if err := compression.Validate(viper.GetString("log.compression")); err != nil {
+		logging.Fatal("Error in log.compression:", err)
 	}
 
 	//meta storage
-	metaStorage, err := meta.NewStorage(metaStorageViper)
+	metaStorage, err := meta.NewStorage(metaStorageConfig())
 	if err != nil {
 		logging.Fatalf("Error initializing meta storage: %v", err)
 	}
@@ -190,21 +351,125 @@ func main() {
 	//events counters
 	counters.InitEvents(metaStorage)
 
+	//test-mode fault injection
+	if viper.GetBool("server.fault_injection.enabled") {
+		logging.Warn("Fault injection is enabled! Faults can be configured via /api/v1/faults. Don't use it in production")
+		faults.Init(true)
+	}
+
+	//batch files load ledger
+	if err := ledger.Init(path.Join(logEventPath, ledgerDir), viper.GetInt("server.ledger.history_size")); err != nil {
+		logging.Fatal(err)
+	}
+
+	//destinations processing configuration versions for as_of replays
+	if err := configversions.Init(path.Join(logEventPath, configVersionsDir)); err != nil {
+		logging.Fatal(err)
+	}
+
+	//startup recovery report: must be initialized before destinations (stream queues are recovered on open)
+	if err := recovery.Init(logEventPath); err != nil {
+		logging.Fatal(err)
+	}
+	recovery.RecoverStaged(ledger.Instance, viper.GetInt("server.recovery.max_copy_attempts"))
+
+	//schema drift detector: must be initialized before destinations
+	driftConfig := drift.Config{}
+	if err := viper.UnmarshalKey("server.schema_drift", &driftConfig); err != nil {
+		logging.Fatal("Error parsing server.schema_drift config:", err)
+	}
+	if err := drift.Init(driftConfig); err != nil {
+		logging.Fatal(err)
+	}
+	if drift.Instance != nil {
+		appconfig.Instance.ScheduleClosing(drift.Instance)
+	}
+
 	//events cache
 	eventsCacheSize := viper.GetInt("server.cache.events.size")
-	eventsCache := caching.NewEventsCache(metaStorage, eventsCacheSize)
+	var eventsCacheStorage caching.EventsStorage
+	switch viper.GetString("server.cache.events.storage") {
+	case "":
+		eventsCacheStorage = metaStorage
+		if metaStorage.Type() == meta.DummyType {
+			eventsCacheStorage = caching.NewMemoryEventsStorage()
+		}
+	case caching.RedisStorage:
+		if metaStorage.Type() != meta.RedisType {
+			logging.Fatal("server.cache.events.storage: redis requires meta.storage.redis configuration")
+		}
+		eventsCacheStorage = metaStorage
+	case caching.MemoryStorage:
+		eventsCacheStorage = caching.NewMemoryEventsStorage()
+	default:
+		logging.Fatalf("Unknown server.cache.events.storage: %s. Available: [%s, %s]", viper.GetString("server.cache.events.storage"), caching.RedisStorage, caching.MemoryStorage)
+	}
+	eventsCache := caching.NewEventsCache(eventsCacheStorage, eventsCacheSize)
+	eventsCache.SetRedactor(caching.NewRedactor(viper.GetStringSlice("server.cache.events.redact")))
 	appconfig.Instance.ScheduleClosing(eventsCache)
 
 	//Deprecated
 	inMemoryEventsCache := events.NewCache(eventsCacheSize)
 	appconfig.Instance.ScheduleClosing(inMemoryEventsCache)
 
+	//workspaces (logical projects): tokens and destinations are added to configured ones
+	workspacesService, err := workspaces.NewService(viper.Sub("workspaces"), viper.GetString("server.admin_token"))
+	if err != nil {
+		logging.Fatal(err)
+	}
+	workspaces.Instance = workspacesService
+	if len(workspacesService.Tokens()) > 0 {
+		appconfig.Instance.AuthorizationService.SetWorkspaceTokens(workspacesService.Tokens())
+	}
+
+	//startup schema check: problems of all destinations are reported at once instead of failing lazily
+	if viper.GetBool("server.strict_config") {
+		if problems := validateDestinations(ctx, syncService, workspacesService.Destinations(), false); len(problems) > 0 {
+			for _, problem := range problems {
+				logging.Error(problem)
+			}
+			logging.Fatalf("Destinations configuration has %d problem(s). Run with -validate flag for the full check", len(problems))
+		}
+	}
+
+	//destinations circuit breakers: must be initialized before destinations (stream workers spool events)
+	breakerConfig := &breaker.Config{}
+	if err := viper.UnmarshalKey("server.circuit_breaker", breakerConfig); err != nil {
+		logging.Fatal("Error parsing server.circuit_breaker config:", err)
+	}
+	breaker.Init(breakerConfig)
+
+	//clustering mode (shared queue with per-destination partitions ownership): must be initialized before destinations
+	clusteringConfig := &clustering.Config{}
+	if err := viper.UnmarshalKey("server.clustering", clusteringConfig); err != nil {
+		logging.Fatal("Error parsing server.clustering config:", err)
+	}
+	if err := clustering.Init(appconfig.Instance.ServerName, clusteringConfig); err != nil {
+		logging.Fatal(err)
+	}
+
 	//Create event destinations
-	destinationsService, err := destinations.NewService(ctx, destinationsViper, destinationsStr, logEventPath, logFallbackPath, logRotationMin, syncService, appconfig.Instance.QueryLogsWriter, eventsCache, storages.Create)
+	destinationsService, err := destinations.NewService(ctx, destinationsViper, destinationsStr, workspacesService.Destinations(), logEventPath, logFallbackPath, logRotationMin, syncService, appconfig.Instance.QueryLogsWriter, eventsCache, storages.Create)
 	if err != nil {
 		logging.Fatal(err)
 	}
 	appconfig.Instance.ScheduleClosing(destinationsService)
+	if clustering.Instance != nil {
+		//closed before destinations: in-flight batches are stored
+		appconfig.Instance.ScheduleClosing(clustering.Instance)
+	}
+
+	//per workspace usage and delivery SLA reports
+	reportsConfig := &reports.Config{}
+	if err := viper.UnmarshalKey("server.reports", reportsConfig); err != nil {
+		logging.Fatal("Error parsing server.reports config:", err)
+	}
+	reports.Init(reportsConfig.RetentionHours, appconfig.Instance.AuthorizationService.GetTokenWorkspace, workspacesService.GetDestinationWorkspace)
+	reportsService, err := reports.NewService(reportsConfig, appconfig.Instance.ServerName)
+	if err != nil {
+		logging.Fatal(err)
+	}
+	appconfig.Instance.ScheduleClosing(reportsService)
 
 	// ** Sources **
 
@@ -226,18 +491,53 @@ func main() {
 	//sources sync tasks pool size
 	poolSize := viper.GetInt("server.sync_tasks.pool.size")
 
+	//source connectors cursor state
+	stateConfig := sources.StateConfig{}
+	if err := viper.UnmarshalKey("server.sync_tasks.state", &stateConfig); err != nil {
+		logging.Fatal("Error parsing server.sync_tasks.state config:", err)
+	}
+	stateStorage, err := sources.NewStateStorage(stateConfig, metaStorage, path.Join(logEventPath, sourcesStateDir))
+	if err != nil {
+		logging.Fatal(err)
+	}
+
 	//Create sources
-	sourceService, err := sources.NewService(ctx, sourcesViper, destinationsService, metaStorage, syncService, poolSize)
+	sourceService, err := sources.NewService(ctx, sourcesViper, destinationsService, metaStorage, stateStorage, syncService, poolSize)
 	if err != nil {
 		logging.Fatal(err)
 	}
 	appconfig.Instance.ScheduleClosing(sourceService)
 
+	//uploaded raw events log files archive for replaying
+	var archive *logfiles.Archive
+	if archivePath := viper.GetString("log.archive.path"); archivePath != "" {
+		archive, err = logfiles.NewArchive(archivePath, viper.GetInt("log.archive.retention_days"))
+		if err != nil {
+			logging.Fatal(err)
+		}
+	}
+	replayService := replay.NewService(archive, appconfig.Instance.ServerName, destinationsService)
+	migrationService := migration.NewService(destinationsService, metaStorage, viper.GetInt("server.migrations.backfill_batch_size"))
+	//destinations data_layout.retention rules
+	retentionService := retention.NewService(destinationsService, syncService, viper.GetInt("server.retention.run_every_min"))
+	appconfig.Instance.ScheduleClosing(retentionService)
+
 	//Uploader must read event logger directory
-	uploader, err := logfiles.NewUploader(logEventPath, appconfig.Instance.ServerName+uploaderFileMask, uploaderLoadEveryS, destinationsService)
+	uploader, err := logfiles.NewUploader(logEventPath, appconfig.Instance.ServerName+uploaderFileMask, uploaderLoadEveryS, destinationsService, archive)
 	if err != nil {
 		logging.Fatal("Error while creating file uploader", err)
 	}
+	//upload workers are scaled by log files backlog and the oldest file age
+	uploaderScaling := autoscaling.Config{}
+	if err := viper.UnmarshalKey("server.autoscaling.uploader", &uploaderScaling); err != nil {
+		logging.Fatal("Error parsing server.autoscaling.uploader config:", err)
+	}
+	if uploaderScaling.Enabled {
+		uploaderController := autoscaling.NewController("uploader", uploaderScaling, uploader)
+		uploaderController.Start()
+		appconfig.Instance.ScheduleClosing(uploaderController)
+	}
+	uploader.Recover()
 	uploader.Start()
 
 	adminToken := viper.GetString("server.admin_token")
@@ -254,8 +554,9 @@ func main() {
 		appconfig.Instance.ScheduleClosing(vn)
 	}
 
-	router := SetupRouter(destinationsService, adminToken, syncService, eventsCache, inMemoryEventsCache, sourceService, fallbackService)
+	router := SetupRouter(destinationsService, adminToken, syncService, eventsCache, inMemoryEventsCache, sourceService, fallbackService, replayService, migrationService, workspacesService, reportsService)
 
+	recovery.Instance.Finish()
 	telemetry.ServerStart()
 	notifications.ServerStart()
 	logging.Info("Started server: " + appconfig.Instance.Authority)
@@ -266,16 +567,48 @@ func main() {
 		ReadHeaderTimeout: time.Second * 60,
 		IdleTimeout:       time.Second * 65,
 	}
-	logging.Fatal(server.ListenAndServe())
+	//is closed first on shutdown: new requests aren't accepted, in-flight ones are finished within a half of the drain timeout
+	//(the rest is left for processing shards, event loggers and destinations)
+	appconfig.Instance.ScheduleClosing(&httpServerCloser{server: server, timeout: time.Duration(viper.GetInt("server.shutdown.drain_timeout_sec")) * time.Second / 2})
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		logging.Fatal(err)
+	}
+	//until shutdown goroutine exits the process
+	select {}
+}
+
+//httpServerCloser stop the HTTP server gracefully: listeners are closed and in-flight requests are waited for timeout
+type httpServerCloser struct {
+	server  *http.Server
+	timeout time.Duration
+}
+
+func (hsc *httpServerCloser) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), hsc.timeout)
+	defer cancel()
+
+	if err := hsc.server.Shutdown(ctx); err != nil {
+		return fmt.Errorf("Error shutting down HTTP server: %v", err)
+	}
+	return nil
 }
 
 func SetupRouter(destinations *destinations.Service, adminToken string, clusterManager cluster.Manager,
-	eventsCache *caching.EventsCache, inMemoryEventsCache *events.Cache, sources *sources.Service, fallbackService *fallback.Service) *gin.Engine {
+	eventsCache *caching.EventsCache, inMemoryEventsCache *events.Cache, sources *sources.Service, fallbackService *fallback.Service,
+	replayService *replay.Service, migrationService *migration.Service, workspacesService *workspaces.Service, reportsService *reports.Service) *gin.Engine {
 	gin.SetMode(gin.ReleaseMode)
 
 	router := gin.New() //gin.Default()
 	router.Use(gin.Recovery())
 
+	var endpointLimits []middleware.EndpointLimit
+	if err := viper.UnmarshalKey("server.load_shedding.endpoints", &endpointLimits); err != nil {
+		logging.Fatalf("Error parsing server.load_shedding.endpoints: %v", err)
+	}
+	if loadShedder := middleware.NewLoadShedder(viper.GetInt("server.load_shedding.max_in_flight"), endpointLimits); loadShedder != nil {
+		router.Use(loadShedder.Handler)
+	}
+
 	router.GET("/", handlers.NewRedirectHandler("/p/welcome.html").Handler)
 	router.GET("/ping", func(c *gin.Context) {
 		c.String(http.StatusOK, "pong")
@@ -298,35 +631,252 @@ func SetupRouter(destinations *destinations.Service, adminToken string, clusterM
 	if err != nil {
 		logging.Fatal(err)
 	}
-	jsEventHandler := handlers.NewEventHandler(destinations, jsEventsPreprocessor, eventsCache, inMemoryEventsCache)
-	apiEventHandler := handlers.NewEventHandler(destinations, apiEventsPreprocessor, eventsCache, inMemoryEventsCache)
+	clientVersions := clientversion.NewTracker()
+	clientIpConfig := clientip.Config{}
+	if err := viper.UnmarshalKey("server.client_ip", &clientIpConfig); err != nil {
+		logging.Fatal("Error parsing server.client_ip config:", err)
+	}
+	if err := clientip.Init(clientIpConfig); err != nil {
+		logging.Fatal("Error creating client ip resolver:", err)
+	}
+	validator, err := validation.NewService(viper.Sub("server.validation.schemas"))
+	if err != nil {
+		logging.Fatal(err)
+	}
+	thirdPartyEventsPreprocessor, err := events.NewThirdPartyPreprocessor()
+	if err != nil {
+		logging.Fatal(err)
+	}
+
+	identityConfig := identity.Config{}
+	if err := viper.UnmarshalKey("server.identity", &identityConfig); err != nil {
+		logging.Fatal("Error parsing server.identity config:", err)
+	}
+	identityResolver, err := identity.NewResolver(identityConfig)
+	if err != nil {
+		logging.Fatal(err)
+	}
+	if identityResolver != nil {
+		appconfig.Instance.ScheduleClosing(identityResolver)
+	}
+
+	//bot filter is applied only to js events: server-to-server api and thirdparty clients don't have browser user agents
+	botFilterConfig := botfilter.Config{}
+	if err := viper.UnmarshalKey("server.bot_filter", &botFilterConfig); err != nil {
+		logging.Fatal("Error parsing server.bot_filter config:", err)
+	}
+	botFilter, err := botfilter.NewFilter(botFilterConfig)
+	if err != nil {
+		logging.Fatal(err)
+	}
+
+	trackingPlanConfig := trackingplan.Config{}
+	if err := viper.UnmarshalKey("server.tracking_plan", &trackingPlanConfig); err != nil {
+		logging.Fatal("Error parsing server.tracking_plan config:", err)
+	}
+	trackingPlan, err := trackingplan.NewPlan(trackingPlanConfig)
+	if err != nil {
+		logging.Fatal(err)
+	}
+
+	//aggregator is scheduled for closing before processing shards: shards are drained before the last aggregates are flushed
+	aggregationConfig := aggregation.Config{}
+	if err := viper.UnmarshalKey("server.anonymous_aggregation", &aggregationConfig); err != nil {
+		logging.Fatal("Error parsing server.anonymous_aggregation config:", err)
+	}
+	aggregator, err := aggregation.NewAggregator(appconfig.Instance.ServerName, aggregationConfig, destinations.GetConsumers)
+	if err != nil {
+		logging.Fatal(err)
+	}
+	if aggregator != nil {
+		appconfig.Instance.ScheduleClosing(aggregator)
+	}
+
+	//sessionizer is scheduled for closing before processing shards as well: memory sessions are closed after the last events
+	sessionizationConfig := sessions.Config{}
+	if err := viper.UnmarshalKey("server.sessionization", &sessionizationConfig); err != nil {
+		logging.Fatal("Error parsing server.sessionization config:", err)
+	}
+	sessionizer, err := sessions.NewSessionizer(sessionizationConfig, destinations.GetConsumers)
+	if err != nil {
+		logging.Fatal(err)
+	}
+	if sessionizer != nil {
+		appconfig.Instance.ScheduleClosing(sessionizer)
+	}
+
+	shardingConfig := sharding.Config{}
+	if err := viper.UnmarshalKey("server.sharding", &shardingConfig); err != nil {
+		logging.Fatal("Error parsing server.sharding config:", err)
+	}
+
+	handlerOptions := handlers.EventHandlerOptions{Validator: validator, IdentityResolver: identityResolver, TrackingPlan: trackingPlan,
+		Aggregator: aggregator, Sessionizer: sessionizer}
+	jsOptions, apiOptions, thirdPartyOptions := handlerOptions, handlerOptions, handlerOptions
+	jsOptions.Shards = newShards("js", shardingConfig, events.NewJsPreprocessor)
+	jsOptions.BotFilter = botFilter
+	apiOptions.Shards = newShards("api", shardingConfig, events.NewApiPreprocessor)
+	thirdPartyOptions.Shards = newShards("thirdparty", shardingConfig, events.NewThirdPartyPreprocessor)
+	jsEventHandler := handlers.NewEventHandler(destinations, jsEventsPreprocessor, eventsCache, inMemoryEventsCache, clientVersions, jsOptions)
+	apiEventHandler := handlers.NewEventHandler(destinations, apiEventsPreprocessor, eventsCache, inMemoryEventsCache, clientVersions, apiOptions)
+	thirdPartyEventHandler := handlers.NewEventHandler(destinations, thirdPartyEventsPreprocessor, eventsCache, inMemoryEventsCache, clientVersions, thirdPartyOptions)
+
+	grpcConfig := grpcapi.Config{}
+	if err := viper.UnmarshalKey("server.grpc", &grpcConfig); err != nil {
+		logging.Fatal("Error parsing server.grpc config:", err)
+	}
+	if grpcConfig.Port > 0 {
+		grpcServer, err := grpcapi.NewServer(grpcConfig, apiEventHandler, appconfig.Instance.AuthorizationService.GetServerOrigins)
+		if err != nil {
+			logging.Fatal(err)
+		}
+		grpcServer.Start()
+		appconfig.Instance.ScheduleClosing(grpcServer)
+	}
 
 	sourcesHandler := handlers.NewSourcesHandler(sources)
 	fallbackHandler := handlers.NewFallbackHandler(fallbackService)
 
-	adminTokenMiddleware := middleware.AdminToken{Token: adminToken}
+	healthHandler := handlers.NewHealthHandler(destinations, validator, trackingPlan, health.Instance, adminToken,
+		time.Duration(viper.GetInt("server.health.check_timeout_sec"))*time.Second, viper.GetBool("server.health.strict_readiness"))
+	router.GET("/health", healthHandler.LivenessHandler)
+	router.GET("/ready", healthHandler.ReadyHandler)
+
+	adminTokenMiddleware := middleware.AdminToken{Token: adminToken, WorkspaceByToken: workspacesService.GetIdByAdminToken}
 	apiV1 := router.Group("/api/v1")
 	{
 		apiV1.POST("/event", middleware.TokenFuncAuth(jsEventHandler.PostHandler, appconfig.Instance.AuthorizationService.GetClientOrigins, ""))
 		apiV1.POST("/s2s/event", middleware.TokenFuncAuth(apiEventHandler.PostHandler, appconfig.Instance.AuthorizationService.GetServerOrigins, "The token isn't a server token. Please use s2s integration token"))
+		jsBatchHandler := handlers.NewBatchHandler(jsEventHandler, viper.GetInt("server.batch.max_body_mb"), viper.GetInt("server.batch.max_lines"))
+		apiBatchHandler := handlers.NewBatchHandler(apiEventHandler, viper.GetInt("server.batch.max_body_mb"), viper.GetInt("server.batch.max_lines"))
+
+		//capabilities are public: SDKs and edge workers request them before sending events
+		capabilitiesHandler := handlers.NewCapabilitiesHandler(tag, commit, builtAt, jsBatchHandler, grpcConfig.Port, map[string]bool{
+			handlers.IdentityResolutionFeature:   identityResolver != nil,
+			handlers.BotFilterFeature:            botFilter != nil,
+			handlers.TrackingPlanFeature:         trackingPlan != nil,
+			handlers.ValidationFeature:           !validator.IsEmpty(),
+			handlers.AnonymousAggregationFeature: aggregator != nil,
+			handlers.SessionizationFeature:       sessionizer != nil,
+			handlers.ProcessingShardsFeature:     shardingConfig.Enabled,
+			handlers.ClusteringFeature:           clustering.Instance != nil,
+			handlers.CircuitBreakersFeature:      breaker.Instance.Enabled(),
+			handlers.GrpcFeature:                 grpcConfig.Port > 0,
+		})
+		apiV1.GET("/capabilities", capabilitiesHandler.Handler)
+		apiV1.POST("/event/batch", middleware.TokenFuncAuth(jsBatchHandler.Handler, appconfig.Instance.AuthorizationService.GetClientOrigins, ""))
+		apiV1.POST("/s2s/event/batch", middleware.TokenFuncAuth(apiBatchHandler.Handler, appconfig.Instance.AuthorizationService.GetServerOrigins, "The token isn't a server token. Please use s2s integration token"))
+		apiV1.GET("/event/batch/:batch_id", middleware.TokenFuncAuth(jsBatchHandler.AckHandler, appconfig.Instance.AuthorizationService.GetClientOrigins, ""))
+		apiV1.GET("/s2s/event/batch/:batch_id", middleware.TokenFuncAuth(apiBatchHandler.AckHandler, appconfig.Instance.AuthorizationService.GetServerOrigins, "The token isn't a server token. Please use s2s integration token"))
+		apiV1.GET("/event/ws", middleware.TokenFuncAuth(handlers.NewWebSocketHandler(jsEventHandler, appconfig.Instance.AuthorizationService.GetClientOrigins).Handler, appconfig.Instance.AuthorizationService.GetClientOrigins, ""))
+		apiV1.GET("/s2s/event/ws", middleware.TokenFuncAuth(handlers.NewWebSocketHandler(apiEventHandler, appconfig.Instance.AuthorizationService.GetServerOrigins).Handler, appconfig.Instance.AuthorizationService.GetServerOrigins, "The token isn't a server token. Please use s2s integration token"))
+
+		apiV1.POST("/tokens/sign", adminTokenMiddleware.AdminAuth(handlers.NewSignedTokensHandler(publicUrl).SignHandler, middleware.AdminTokenErr))
 
 		apiV1.POST("/destinations/test", adminTokenMiddleware.AdminAuth(handlers.DestinationsHandler, middleware.AdminTokenErr))
+		apiV1.GET("/destinations/column_types", adminTokenMiddleware.WorkspaceAuth(handlers.NewColumnTypesHandler(destinations).GetHandler, middleware.AdminTokenErr))
+		apiV1.GET("/destinations/latency", adminTokenMiddleware.WorkspaceAuth(handlers.NewLatencyHandler(latency.Instance).GetHandler, middleware.AdminTokenErr))
+
+		introspectionHandler := handlers.NewIntrospectionHandler(destinations, introspection.Instance)
+		apiV1.GET("/introspection/destinations", adminTokenMiddleware.WorkspaceAuth(introspectionHandler.DestinationsHandler, middleware.AdminTokenErr))
+		apiV1.GET("/introspection/destinations/:destination_id/events", adminTokenMiddleware.WorkspaceAuth(introspectionHandler.RecentEventsHandler, middleware.AdminTokenErr))
+		apiV1.GET("/introspection/destinations/:destination_id/failed", adminTokenMiddleware.WorkspaceAuth(introspectionHandler.FailedEventsHandler, middleware.AdminTokenErr))
+		apiV1.GET("/introspection/throughput", adminTokenMiddleware.WorkspaceAuth(introspectionHandler.ThroughputHandler, middleware.AdminTokenErr))
+
+		eventStoreHandler := handlers.NewEventStoreHandler(eventstore.Instance)
+		apiV1.POST("/event_store/query", adminTokenMiddleware.WorkspaceAuth(eventStoreHandler.QueryHandler, middleware.AdminTokenErr))
+		apiV1.GET("/event_store/tables", adminTokenMiddleware.WorkspaceAuth(eventStoreHandler.TablesHandler, middleware.AdminTokenErr))
+
 		apiV1.POST("/sources/:id/sync", adminTokenMiddleware.AdminAuth(sourcesHandler.SyncHandler, middleware.AdminTokenErr))
 		apiV1.GET("/sources/:id/status", adminTokenMiddleware.AdminAuth(sourcesHandler.StatusHandler, middleware.AdminTokenErr))
+		apiV1.GET("/sources/:id/runs", adminTokenMiddleware.AdminAuth(sourcesHandler.RunsHandler, middleware.AdminTokenErr))
+		apiV1.GET("/sources/:id/discover", adminTokenMiddleware.AdminAuth(sourcesHandler.DiscoverHandler, middleware.AdminTokenErr))
 
 		apiV1.GET("/cluster", adminTokenMiddleware.AdminAuth(handlers.NewClusterHandler(clusterManager).Handler, middleware.AdminTokenErr))
 		apiV1.GET("/cache/events", adminTokenMiddleware.AdminAuth(jsEventHandler.OldGetHandler, middleware.AdminTokenErr))
-		apiV1.GET("/events/cache", adminTokenMiddleware.AdminAuth(jsEventHandler.GetHandler, middleware.AdminTokenErr))
+		apiV1.GET("/events/cache", adminTokenMiddleware.WorkspaceAuth(jsEventHandler.GetHandler, middleware.AdminTokenErr))
+		apiV1.GET("/client_versions", adminTokenMiddleware.AdminAuth(handlers.NewClientVersionsHandler(clientVersions).GetHandler, middleware.AdminTokenErr))
+		apiV1.GET("/tracking_plan/report", adminTokenMiddleware.AdminAuth(handlers.NewTrackingPlanHandler(trackingPlan).ReportHandler, middleware.AdminTokenErr))
+
+		faultsHandler := handlers.NewFaultsHandler(faults.Instance)
+		apiV1.GET("/faults", adminTokenMiddleware.AdminAuth(faultsHandler.GetHandler, middleware.AdminTokenErr))
+		apiV1.POST("/faults/:destination_id", adminTokenMiddleware.AdminAuth(faultsHandler.SetHandler, middleware.AdminTokenErr))
+		apiV1.DELETE("/faults", adminTokenMiddleware.AdminAuth(faultsHandler.DeleteHandler, middleware.AdminTokenErr))
+		apiV1.DELETE("/faults/:destination_id", adminTokenMiddleware.AdminAuth(faultsHandler.DeleteHandler, middleware.AdminTokenErr))
+
+		apiV1.GET("/ledger", adminTokenMiddleware.AdminAuth(handlers.NewLedgerHandler(ledger.Instance).GetHandler, middleware.AdminTokenErr))
+		apiV1.GET("/status/recovery", adminTokenMiddleware.AdminAuth(handlers.NewRecoveryHandler(recovery.Instance).GetHandler, middleware.AdminTokenErr))
 
 		apiV1.GET("/fallback", adminTokenMiddleware.AdminAuth(fallbackHandler.GetHandler, middleware.AdminTokenErr))
 		apiV1.POST("/fallback/replay", adminTokenMiddleware.AdminAuth(fallbackHandler.ReplayHandler, middleware.AdminTokenErr))
+
+		replayHandler := handlers.NewReplayHandler(replayService)
+		apiV1.GET("/config_versions", adminTokenMiddleware.WorkspaceAuth(handlers.NewConfigVersionsHandler(configversions.Instance).GetHandler, middleware.AdminTokenErr))
+		apiV1.POST("/replay", adminTokenMiddleware.WorkspaceAuth(replayHandler.PostHandler, middleware.AdminTokenErr))
+		apiV1.GET("/replay", adminTokenMiddleware.WorkspaceAuth(replayHandler.GetHandler, middleware.AdminTokenErr))
+		apiV1.GET("/replay/:id", adminTokenMiddleware.WorkspaceAuth(replayHandler.TaskHandler, middleware.AdminTokenErr))
+
+		migrationsHandler := handlers.NewMigrationsHandler(migrationService)
+		apiV1.GET("/migrations", adminTokenMiddleware.WorkspaceAuth(migrationsHandler.GetHandler, middleware.AdminTokenErr))
+		apiV1.POST("/migrations/backfill", adminTokenMiddleware.WorkspaceAuth(migrationsHandler.BackfillHandler, middleware.AdminTokenErr))
+		apiV1.GET("/migrations/backfill", adminTokenMiddleware.WorkspaceAuth(migrationsHandler.ProgressHandler, middleware.AdminTokenErr))
+		apiV1.GET("/migrations/plans", adminTokenMiddleware.WorkspaceAuth(migrationsHandler.PlansHandler, middleware.AdminTokenErr))
+		apiV1.POST("/migrations/plans/apply", adminTokenMiddleware.WorkspaceAuth(migrationsHandler.ApplyPlanHandler, middleware.AdminTokenErr))
+
+		apiV1.POST("/annotations", adminTokenMiddleware.WorkspaceAuth(handlers.NewAnnotationsHandler(destinations).PostHandler, middleware.AdminTokenErr))
+		deletionsHandler := handlers.NewDeletionsHandler(destinations, viper.GetInt("server.bulk_delete.batch_size"), time.Duration(viper.GetInt("server.bulk_delete.pause_ms"))*time.Millisecond)
+		apiV1.POST("/events/delete", adminTokenMiddleware.WorkspaceAuth(deletionsHandler.PostHandler, middleware.AdminTokenErr))
+
+		//workspace admin tokens have access only to the workspace objects
+		apiV1.GET("/workspaces", adminTokenMiddleware.WorkspaceAuth(handlers.NewWorkspacesHandler(workspacesService).GetHandler, middleware.AdminTokenErr))
+
+		apiV1.GET("/reports", adminTokenMiddleware.WorkspaceAuth(handlers.NewReportsHandler(reportsService).GetHandler, middleware.AdminTokenErr))
+
+		configHandler := handlers.NewConfigHandler(declarative.NewService(appconfig.Instance.AuthorizationService, destinations))
+		apiV1.GET("/config", adminTokenMiddleware.AdminAuth(configHandler.GetHandler, middleware.AdminTokenErr))
+		apiV1.POST("/config/plan", adminTokenMiddleware.AdminAuth(configHandler.PlanHandler, middleware.AdminTokenErr))
+		apiV1.POST("/config/apply", adminTokenMiddleware.AdminAuth(configHandler.ApplyHandler, middleware.AdminTokenErr))
+
+		//dev mode pages are served without admin token: the server is run locally
+		if *devMode {
+			devHandler := handlers.NewDevHandler(destinations)
+			router.GET("/dev", devHandler.PageHandler)
+			apiV1.GET("/dev/tables", devHandler.TablesHandler)
+			apiV1.DELETE("/dev/tables", devHandler.ClearHandler)
+		}
 	}
 
 	router.POST("/api.:ignored", middleware.TokenFuncAuth(jsEventHandler.PostHandler, appconfig.Instance.AuthorizationService.GetClientOrigins, ""))
 
+	//Segment HTTP Tracking API compatible endpoints: Segment libraries can be pointed at the server without changes
+	router.POST("/v1/:type", handlers.NewSegmentHandler(thirdPartyEventHandler).Handler)
+
+	//Google Analytics Measurement Protocol (v1 and GA4) compatible endpoints
+	gaHandler := handlers.NewGAHandler(thirdPartyEventHandler)
+	for _, collectPath := range []string{"/collect", "/r/collect", "/j/collect"} {
+		router.GET(collectPath, gaHandler.CollectHandler)
+		router.POST(collectPath, gaHandler.CollectHandler)
+	}
+	router.POST("/batch", gaHandler.BatchHandler)
+	router.POST("/mp/collect", gaHandler.GA4Handler)
+
 	if metrics.Enabled {
 		router.GET("/prometheus", middleware.TokenAuth(gin.WrapH(promhttp.Handler()), adminToken))
 	}
 
 	return router
 }
+
+//newShards return processing shards of the event handler or nil if sharding isn't enabled
+func newShards(name string, config sharding.Config, preprocessorFactory func() (events.Preprocessor, error)) *sharding.Shards {
+	if !config.Enabled {
+		return nil
+	}
+
+	shards, err := sharding.New(name, config, preprocessorFactory)
+	if err != nil {
+		logging.Fatalf("Error creating [%s] processing shards: %v", name, err)
+	}
+	appconfig.Instance.ScheduleClosing(shards)
+	return shards
+}
@@ -12,12 +12,16 @@ import (
 	"github.com/jitsucom/eventnative/logging"
 	"github.com/jitsucom/eventnative/meta"
 	"github.com/jitsucom/eventnative/middleware"
+	"github.com/jitsucom/eventnative/migration"
+	"github.com/jitsucom/eventnative/replay"
+	"github.com/jitsucom/eventnative/reports"
 	"github.com/jitsucom/eventnative/sources"
 	"github.com/jitsucom/eventnative/storages"
 	"github.com/jitsucom/eventnative/synchronization"
 	"github.com/jitsucom/eventnative/telemetry"
 	"github.com/jitsucom/eventnative/test"
 	"github.com/jitsucom/eventnative/uuid"
+	"github.com/jitsucom/eventnative/workspaces"
 	"strings"
 	"time"
 
@@ -176,7 +180,7 @@ func TestCors(t *testing.T) {
 			destinationService := destinations.NewTestService(destinations.TokenizedConsumers{"id1": {"id1": events.NewAsyncLogger(inmemWriter, false)}},
 				destinations.TokenizedStorages{}, destinations.TokenizedIds{})
 			router := SetupRouter(destinationService, "", synchronization.NewInMemoryService([]string{}),
-				caching.NewEventsCache(&meta.Dummy{}, 100), events.NewCache(5), sources.NewTestService(), fallback.NewTestService())
+				caching.NewEventsCache(&meta.Dummy{}, 100), events.NewCache(5), sources.NewTestService(), fallback.NewTestService(), replay.NewTestService(), migration.NewTestService(), workspaces.NewTestService(), reports.NewTestService())
 
 			freezeTime := time.Date(2020, 06, 16, 23, 0, 0, 0, time.UTC)
 			patch := monkey.Patch(time.Now, func() time.Time { return freezeTime })
@@ -301,7 +305,7 @@ func TestApiEvent(t *testing.T) {
 			destinationService := destinations.NewTestService(destinations.TokenizedConsumers{"id1": {"id1": events.NewAsyncLogger(inmemWriter, false)}},
 				destinations.TokenizedStorages{}, destinations.TokenizedIds{})
 			router := SetupRouter(destinationService, "", synchronization.NewInMemoryService([]string{}),
-				caching.NewEventsCache(&meta.Dummy{}, 100), events.NewCache(5), sources.NewTestService(), fallback.NewTestService())
+				caching.NewEventsCache(&meta.Dummy{}, 100), events.NewCache(5), sources.NewTestService(), fallback.NewTestService(), replay.NewTestService(), migration.NewTestService(), workspaces.NewTestService(), reports.NewTestService())
 
 			freezeTime := time.Date(2020, 06, 16, 23, 0, 0, 0, time.UTC)
 			patch := monkey.Patch(time.Now, func() time.Time { return freezeTime })
@@ -435,11 +439,11 @@ func testPostgresStoreEvents(t *testing.T, pgDestinationConfigTemplate string, e
 	defer appconfig.Instance.Close()
 	monitor := synchronization.NewInMemoryService([]string{})
 	eventsCache := caching.NewEventsCache(&meta.Dummy{}, 100)
-	dest, err := destinations.NewService(ctx, viper.Sub("dest"), destinationConfig, "/tmp", "/tmp/fallback", 5, monitor, nil, eventsCache, storages.Create)
+	dest, err := destinations.NewService(ctx, viper.Sub("dest"), destinationConfig, nil, "/tmp", "/tmp/fallback", 5, monitor, nil, eventsCache, storages.Create)
 	require.NoError(t, err)
 	defer dest.Close()
 
-	router := SetupRouter(dest, "", synchronization.NewInMemoryService([]string{}), eventsCache, events.NewCache(5), sources.NewTestService(), fallback.NewTestService())
+	router := SetupRouter(dest, "", synchronization.NewInMemoryService([]string{}), eventsCache, events.NewCache(5), sources.NewTestService(), fallback.NewTestService(), replay.NewTestService(), migration.NewTestService(), workspaces.NewTestService(), reports.NewTestService())
 
 	server := &http.Server{
 		Addr:              httpAuthority,
@@ -532,11 +536,11 @@ func testClickhouseStoreEvents(t *testing.T, configTemplate string, expectedEven
 	require.NoError(t, err)
 	defer appconfig.Instance.Close()
 	eventsCache := caching.NewEventsCache(&meta.Dummy{}, 100)
-	dest, err := destinations.NewService(ctx, viper.Sub("dest"), destinationConfig, "/tmp", "/tmp/fallback", 5,
+	dest, err := destinations.NewService(ctx, viper.Sub("dest"), destinationConfig, nil, "/tmp", "/tmp/fallback", 5,
 		synchronization.NewInMemoryService([]string{}), nil, eventsCache, storages.Create)
 	require.NoError(t, err)
 	defer dest.Close()
-	router := SetupRouter(dest, "", synchronization.NewInMemoryService([]string{}), eventsCache, events.NewCache(5), sources.NewTestService(), fallback.NewTestService())
+	router := SetupRouter(dest, "", synchronization.NewInMemoryService([]string{}), eventsCache, events.NewCache(5), sources.NewTestService(), fallback.NewTestService(), replay.NewTestService(), migration.NewTestService(), workspaces.NewTestService(), reports.NewTestService())
 
 	server := &http.Server{
 		Addr:              httpAuthority,
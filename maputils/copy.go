@@ -13,3 +13,15 @@ func CopyMap(m map[string]interface{}) map[string]interface{} {
 
 	return cp
 }
+
+//CopyMapInto copy m into the cp map (e.g. pooled one): nested maps are copied recursively
+func CopyMapInto(m, cp map[string]interface{}) {
+	for k, v := range m {
+		vm, ok := v.(map[string]interface{})
+		if ok {
+			cp[k] = CopyMap(vm)
+		} else {
+			cp[k] = v
+		}
+	}
+}
@@ -29,6 +29,22 @@ func (d *Dummy) SaveCollectionLog(sourceId, collection, log string) error {
 	return nil
 }
 
+func (d *Dummy) GetCollectionState(sourceId, collection string) (string, error) {
+	return "", nil
+}
+
+func (d *Dummy) SaveCollectionState(sourceId, collection, state string) error {
+	return nil
+}
+
+func (d *Dummy) AddSyncRun(sourceId, collection, run string, limit int) error {
+	return nil
+}
+
+func (d *Dummy) GetSyncRuns(sourceId, collection string, limit int) ([]string, error) {
+	return []string{}, nil
+}
+
 func (d *Dummy) SuccessEvents(destinationId string, now time.Time, value int) error {
 	return nil
 }
@@ -51,6 +67,14 @@ func (d *Dummy) RemoveLastEvent(destinationId string) error {
 	return nil
 }
 
+func (d *Dummy) GetMigrationProgress(destinationId, table, column string) (string, error) {
+	return "", nil
+}
+
+func (d *Dummy) SaveMigrationProgress(destinationId, table, column, progress string) error {
+	return nil
+}
+
 func (d *Dummy) GetTotalEvents(destinationId string) (int, error) {
 	return 0, nil
 }
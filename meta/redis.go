@@ -23,6 +23,7 @@ type Redis struct {
 //source#sourceId:collection#collectionId:chunks [sourceId, collectionId] - hashtable with signatures
 //source#sourceId:collection#collectionId:status [sourceId, collectionId] - hashtable with collection statuses
 //source#sourceId:collection#collectionId:log    [sourceId, collectionId] - hashtable with reloading logs
+//source#sourceId:collection#collectionId:runs   [sourceId, collectionId] - list with sync runs json (the newest first)
 //
 //events caching
 //hourly_events:destination#destinationId:day#yyyymmdd:success [hour] - hashtable with success events counter by hour
@@ -32,6 +33,9 @@ type Redis struct {
 //
 //last_events:destination#destinationId:id#eventn_ctx_event_id [original, success, error] - hashtable with original event json, processed with schema json, error json
 //last_events_index:destination#destinationId [timestamp_long eventn_ctx_event_id] - sorted set of eventIds and timestamps
+//
+//migrations
+//migrations:destination#destinationId:table#tableName [column] - hashtable with renamed columns backfill progress json
 func NewRedis(host string, port int, password string) (*Redis, error) {
 	logging.Infof("Initializing redis [%s:%d]...", host, port)
 	r := &Redis{pool: &redis.Pool{
@@ -166,6 +170,75 @@ func (r *Redis) SaveCollectionLog(sourceId, collection, log string) error {
 	return nil
 }
 
+func (r *Redis) GetCollectionState(sourceId, collection string) (string, error) {
+	key := "source#" + sourceId + ":collection#" + collection + ":state"
+	field := "current"
+	connection := r.pool.Get()
+	defer connection.Close()
+	state, err := redis.String(connection.Do("HGET", key, field))
+	noticeError(err)
+	if err != nil {
+		if err == redis.ErrNil {
+			return "", nil
+		}
+
+		return "", err
+	}
+
+	return state, nil
+}
+
+func (r *Redis) SaveCollectionState(sourceId, collection, state string) error {
+	key := "source#" + sourceId + ":collection#" + collection + ":state"
+	field := "current"
+	connection := r.pool.Get()
+	defer connection.Close()
+	_, err := connection.Do("HSET", key, field, state)
+	noticeError(err)
+	if err != nil && err != redis.ErrNil {
+		return err
+	}
+
+	return nil
+}
+
+//AddSyncRun push the run into the head of the list and trim the list to the last limit runs
+func (r *Redis) AddSyncRun(sourceId, collection, run string, limit int) error {
+	key := "source#" + sourceId + ":collection#" + collection + ":runs"
+	connection := r.pool.Get()
+	defer connection.Close()
+	_, err := connection.Do("LPUSH", key, run)
+	noticeError(err)
+	if err != nil && err != redis.ErrNil {
+		return err
+	}
+
+	_, err = connection.Do("LTRIM", key, 0, limit-1)
+	noticeError(err)
+	if err != nil && err != redis.ErrNil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *Redis) GetSyncRuns(sourceId, collection string, limit int) ([]string, error) {
+	key := "source#" + sourceId + ":collection#" + collection + ":runs"
+	connection := r.pool.Get()
+	defer connection.Close()
+	runs, err := redis.Strings(connection.Do("LRANGE", key, 0, limit-1))
+	noticeError(err)
+	if err != nil {
+		if err == redis.ErrNil {
+			return []string{}, nil
+		}
+
+		return nil, err
+	}
+
+	return runs, nil
+}
+
 func (r *Redis) SuccessEvents(destinationId string, now time.Time, value int) error {
 	return r.incrementEventsCount(destinationId, "success", now, value)
 }
@@ -310,6 +383,36 @@ func (r *Redis) GetTotalEvents(destinationId string) (int, error) {
 	return count, nil
 }
 
+func (r *Redis) GetMigrationProgress(destinationId, table, column string) (string, error) {
+	key := "migrations:destination#" + destinationId + ":table#" + table
+	connection := r.pool.Get()
+	defer connection.Close()
+	progress, err := redis.String(connection.Do("HGET", key, column))
+	noticeError(err)
+	if err != nil {
+		if err == redis.ErrNil {
+			return "", nil
+		}
+
+		return "", err
+	}
+
+	return progress, nil
+}
+
+func (r *Redis) SaveMigrationProgress(destinationId, table, column, progress string) error {
+	key := "migrations:destination#" + destinationId + ":table#" + table
+	connection := r.pool.Get()
+	defer connection.Close()
+	_, err := connection.Do("HSET", key, column, progress)
+	noticeError(err)
+	if err != nil && err != redis.ErrNil {
+		return err
+	}
+
+	return nil
+}
+
 func (r *Redis) Type() string {
 	return RedisType
 }
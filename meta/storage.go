@@ -26,6 +26,12 @@ type Storage interface {
 	SaveCollectionStatus(sourceId, collection, status string) error
 	GetCollectionLog(sourceId, collection string) (string, error)
 	SaveCollectionLog(sourceId, collection, log string) error
+	//source connectors cursor state
+	GetCollectionState(sourceId, collection string) (string, error)
+	SaveCollectionState(sourceId, collection, state string) error
+	//sync runs history (JSON) from the newest to the oldest. Only the last limit runs are kept
+	AddSyncRun(sourceId, collection, run string, limit int) error
+	GetSyncRuns(sourceId, collection string, limit int) ([]string, error)
 
 	//events counters
 	SuccessEvents(destinationId string, now time.Time, value int) error
@@ -40,6 +46,10 @@ type Storage interface {
 	GetEvents(destinationId string, start, end time.Time, n int) ([]Event, error)
 	GetTotalEvents(destinationId string) (int, error)
 
+	//column renames backfill progress (JSON)
+	GetMigrationProgress(destinationId, table, column string) (string, error)
+	SaveMigrationProgress(destinationId, table, column, progress string) error
+
 	Type() string
 }
 
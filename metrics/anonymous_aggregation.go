@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	AggregatedResult = "aggregated"
+	SkippedResult    = "skipped"
+	WrittenResult    = "written"
+	SuppressedResult = "suppressed"
+)
+
+var (
+	anonymousAggregationEvents     *prometheus.CounterVec
+	anonymousAggregationAggregates *prometheus.CounterVec
+)
+
+func initAnonymousAggregation() {
+	anonymousAggregationEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "eventnative",
+		Subsystem: "anonymous_aggregation",
+		Name:      "events",
+	}, []string{"rule", "result"})
+	anonymousAggregationAggregates = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "eventnative",
+		Subsystem: "anonymous_aggregation",
+		Name:      "aggregates",
+	}, []string{"rule", "result"})
+}
+
+//AnonymousAggregationEvent increment matched events counter of the rule. result is aggregated or skipped
+func AnonymousAggregationEvent(rule, result string) {
+	if Enabled {
+		anonymousAggregationEvents.WithLabelValues(rule, result).Inc()
+	}
+}
+
+//AnonymousAggregate increment flushed aggregates counter of the rule. result is written or suppressed (fewer than min_count events)
+func AnonymousAggregate(rule, result string) {
+	if Enabled {
+		anonymousAggregationAggregates.WithLabelValues(rule, result).Inc()
+	}
+}
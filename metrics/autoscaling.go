@@ -0,0 +1,22 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var autoscalingWorkers *prometheus.GaugeVec
+
+func initAutoscaling() {
+	autoscalingWorkers = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "eventnative",
+		Subsystem: "autoscaling",
+		Name:      "workers",
+	}, []string{"name"})
+}
+
+func AutoscalingWorkers(name string, value int) {
+	if Enabled {
+		autoscalingWorkers.WithLabelValues(name).Set(float64(value))
+	}
+}
@@ -0,0 +1,23 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var botFilterMatched *prometheus.CounterVec
+
+func initBotFilter() {
+	botFilterMatched = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "eventnative",
+		Subsystem: "bot_filter",
+		Name:      "matched",
+	}, []string{"rule", "action"})
+}
+
+//BotFilterMatched increment events counter of the bot filter rule with the applied action (tag or drop)
+func BotFilterMatched(rule, action string) {
+	if Enabled {
+		botFilterMatched.WithLabelValues(rule, action).Inc()
+	}
+}
@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	destinationCircuitState *prometheus.GaugeVec
+	destinationSpooled      *prometheus.CounterVec
+)
+
+func initBreakers() {
+	destinationCircuitState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "eventnative",
+		Subsystem: "destination",
+		Name:      "circuit_open",
+	}, []string{"project_id", "destination_id"})
+	destinationSpooled = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "eventnative",
+		Subsystem: "destination",
+		Name:      "spooled_events",
+	}, []string{"project_id", "destination_id"})
+}
+
+//DestinationCircuit set 1 if the destination circuit breaker is open (or half-open) and 0 if it is closed
+func DestinationCircuit(destinationName string, open bool) {
+	if Enabled {
+		projectId, destinationId := extractLabels(destinationName)
+		value := 0.0
+		if open {
+			value = 1
+		}
+		destinationCircuitState.WithLabelValues(projectId, destinationId).Set(value)
+	}
+}
+
+//DestinationSpooled increment counter of events which have been diverted into the destination spool
+func DestinationSpooled(destinationName string) {
+	if Enabled {
+		projectId, destinationId := extractLabels(destinationName)
+		destinationSpooled.WithLabelValues(projectId, destinationId).Inc()
+	}
+}
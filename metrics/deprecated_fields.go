@@ -0,0 +1,23 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var deprecatedFields *prometheus.CounterVec
+
+func initDeprecatedFields() {
+	deprecatedFields = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "eventnative",
+		Subsystem: "deprecated_fields",
+		Name:      "events",
+	}, []string{"field", "action"})
+}
+
+//DeprecatedField increment events counter which contain the deprecated field with the applied action (used or dropped after sunset)
+func DeprecatedField(field, action string) {
+	if Enabled {
+		deprecatedFields.WithLabelValues(field, action).Inc()
+	}
+}
@@ -0,0 +1,25 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var destinationLatency *prometheus.HistogramVec
+
+func initLatency() {
+	destinationLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "eventnative",
+		Subsystem: "destinations",
+		Name:      "latency_seconds",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600, 1800, 3600, 10800},
+	}, []string{"project_id", "destination_id", "stage"})
+}
+
+//DestinationLatency observe event latency. stage is client_to_server, server_to_destination or end_to_end
+func DestinationLatency(destinationName, stage string, seconds float64) {
+	if Enabled {
+		projectId, destinationId := extractLabels(destinationName)
+		destinationLatency.WithLabelValues(projectId, destinationId, stage).Observe(seconds)
+	}
+}
@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	shedRequests     *prometheus.CounterVec
+	inFlightRequests *prometheus.GaugeVec
+)
+
+func initLoadShedding() {
+	shedRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "eventnative",
+		Subsystem: "server",
+		Name:      "shed_requests",
+	}, []string{"endpoint", "limit"})
+	inFlightRequests = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "eventnative",
+		Subsystem: "server",
+		Name:      "in_flight_requests",
+	}, []string{"endpoint"})
+}
+
+//ShedRequest increment rejected requests counter. limit is global or endpoint
+func ShedRequest(endpoint, limit string) {
+	if Enabled {
+		shedRequests.WithLabelValues(endpoint, limit).Inc()
+	}
+}
+
+func InFlightRequests(endpoint string, value int) {
+	if Enabled {
+		inFlightRequests.WithLabelValues(endpoint).Set(float64(value))
+	}
+}
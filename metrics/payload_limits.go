@@ -0,0 +1,40 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	payloadLimitsViolations *prometheus.CounterVec
+	tableColumnsExceeded    *prometheus.CounterVec
+)
+
+func initPayloadLimits() {
+	payloadLimitsViolations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "eventnative",
+		Subsystem: "payload_limits",
+		Name:      "violations",
+	}, []string{"limit", "policy"})
+	tableColumnsExceeded = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "eventnative",
+		Subsystem: "payload_limits",
+		Name:      "table_columns_exceeded",
+	}, []string{"project_id", "destination_id", "policy"})
+}
+
+//PayloadLimitViolation increment events counter which exceeded destination data_layout limit (event_size, columns or string_length)
+//with the applied policy (reject, truncate or fallback)
+func PayloadLimitViolation(limit, policy string) {
+	if Enabled {
+		payloadLimitsViolations.WithLabelValues(limit, policy).Inc()
+	}
+}
+
+//TableColumnsExceeded increment events counter which would push a destination table past max_table_columns (alert metric)
+func TableColumnsExceeded(destinationName, policy string, value int) {
+	if Enabled {
+		projectId, destinationId := extractLabels(destinationName)
+		tableColumnsExceeded.WithLabelValues(projectId, destinationId, policy).Add(float64(value))
+	}
+}
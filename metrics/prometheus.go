@@ -15,6 +15,25 @@ func Init(enabled bool) {
 		initSourcesPool()
 		initSourceObjects()
 		initRedis()
+		initLoadShedding()
+		initValidation()
+		initLatency()
+		initAutoscaling()
+		initShards()
+		initBotFilter()
+		initPayloadLimits()
+		initDeprecatedFields()
+		initSchemaDrift()
+		initTrackingPlan()
+		initRetention()
+		initQueue()
+		initSQLHooks()
+		initViews()
+		initRetries()
+		initBreakers()
+		initAnonymousAggregation()
+		initSessions()
+		initTypecast()
 	} else {
 		logging.Warnf("Metrics isn't enabled")
 	}
@@ -0,0 +1,38 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	queueCorruptedParts      *prometheus.CounterVec
+	queueQuarantinedSegments *prometheus.CounterVec
+)
+
+func initQueue() {
+	queueCorruptedParts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "eventnative",
+		Subsystem: "queue",
+		Name:      "corrupted_parts",
+	}, []string{"queue"})
+	queueQuarantinedSegments = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "eventnative",
+		Subsystem: "queue",
+		Name:      "quarantined_segments",
+	}, []string{"queue"})
+}
+
+//QueueCorruptedParts increment counter of skipped corrupted parts (one or several damaged entries) of the stream queue segments
+func QueueCorruptedParts(queue string, value int) {
+	if Enabled && value > 0 {
+		queueCorruptedParts.WithLabelValues(queue).Add(float64(value))
+	}
+}
+
+//QueueQuarantinedSegment increment counter of damaged stream queue segments which have been copied or moved into quarantine
+func QueueQuarantinedSegment(queue string) {
+	if Enabled {
+		queueQuarantinedSegments.WithLabelValues(queue).Inc()
+	}
+}
@@ -0,0 +1,49 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	retentionRows   *prometheus.CounterVec
+	retentionErrors *prometheus.CounterVec
+)
+
+func initRetention() {
+	retentionRows = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "eventnative",
+		Subsystem: "retention",
+		Name:      "rows",
+	}, []string{"project_id", "destination_id", "action"})
+	retentionErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "eventnative",
+		Subsystem: "retention",
+		Name:      "errors",
+	}, []string{"project_id", "destination_id"})
+}
+
+//RetentionDeletedRows increment rows counter of the destination deleted by ttl_days retention rules
+func RetentionDeletedRows(destinationName string, value int64) {
+	retentionRowsAdd(destinationName, "deleted", value)
+}
+
+//RetentionArchivedRows increment rows counter of the destination moved into archive destinations
+func RetentionArchivedRows(destinationName string, value int64) {
+	retentionRowsAdd(destinationName, "archived", value)
+}
+
+func retentionRowsAdd(destinationName, action string, value int64) {
+	if Enabled && value > 0 {
+		projectId, destinationId := extractLabels(destinationName)
+		retentionRows.WithLabelValues(projectId, destinationId, action).Add(float64(value))
+	}
+}
+
+//RetentionError increment retention rules applying errors counter of the destination
+func RetentionError(destinationName string) {
+	if Enabled {
+		projectId, destinationId := extractLabels(destinationName)
+		retentionErrors.WithLabelValues(projectId, destinationId).Inc()
+	}
+}
@@ -0,0 +1,33 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var destinationRetries *prometheus.CounterVec
+
+func initRetries() {
+	destinationRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "eventnative",
+		Subsystem: "destination",
+		Name:      "retries",
+	}, []string{"project_id", "destination_id", "operation", "result"})
+}
+
+//DestinationRetry increment retries counter of the destination write operation
+func DestinationRetry(destinationName, operation string) {
+	destinationRetriesInc(destinationName, operation, "retried")
+}
+
+//DestinationRetriesExhausted increment counter of the destination write operation failures after all attempts
+func DestinationRetriesExhausted(destinationName, operation string) {
+	destinationRetriesInc(destinationName, operation, "exhausted")
+}
+
+func destinationRetriesInc(destinationName, operation, result string) {
+	if Enabled {
+		projectId, destinationId := extractLabels(destinationName)
+		destinationRetries.WithLabelValues(projectId, destinationId, operation, result).Inc()
+	}
+}
@@ -0,0 +1,24 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var schemaDrifts *prometheus.CounterVec
+
+func initSchemaDrift() {
+	schemaDrifts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "eventnative",
+		Subsystem: "schema_drift",
+		Name:      "changes",
+	}, []string{"project_id", "destination_id", "kind"})
+}
+
+//SchemaDrift increment detected schema drifts counter of the destination (new_field, type_changed or null_rate)
+func SchemaDrift(destinationName, kind string) {
+	if Enabled {
+		projectId, destinationId := extractLabels(destinationName)
+		schemaDrifts.WithLabelValues(projectId, destinationId, kind).Inc()
+	}
+}
@@ -0,0 +1,38 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	sessionsStarted prometheus.Counter
+	sessionsClosed  prometheus.Counter
+)
+
+func initSessions() {
+	sessionsStarted = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "eventnative",
+		Subsystem: "sessions",
+		Name:      "started",
+	})
+	sessionsClosed = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "eventnative",
+		Subsystem: "sessions",
+		Name:      "closed",
+	})
+}
+
+//SessionStarted increment started user sessions counter
+func SessionStarted() {
+	if Enabled {
+		sessionsStarted.Inc()
+	}
+}
+
+//SessionClosed increment closed user sessions (summaries) counter
+func SessionClosed() {
+	if Enabled {
+		sessionsClosed.Inc()
+	}
+}
@@ -0,0 +1,49 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	shardQueueSize *prometheus.GaugeVec
+	shardRejected  *prometheus.CounterVec
+	shardDuration  *prometheus.HistogramVec
+)
+
+func initShards() {
+	shardQueueSize = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "eventnative",
+		Subsystem: "shards",
+		Name:      "queue_size",
+	}, []string{"name", "shard"})
+	shardRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "eventnative",
+		Subsystem: "shards",
+		Name:      "rejected",
+	}, []string{"name", "shard"})
+	shardDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "eventnative",
+		Subsystem: "shards",
+		Name:      "processing_seconds",
+		Buckets:   []float64{0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1},
+	}, []string{"name", "shard"})
+}
+
+func ShardQueueSize(name, shard string, size int) {
+	if Enabled {
+		shardQueueSize.WithLabelValues(name, shard).Set(float64(size))
+	}
+}
+
+func ShardRejected(name, shard string) {
+	if Enabled {
+		shardRejected.WithLabelValues(name, shard).Inc()
+	}
+}
+
+func ShardProcessed(name, shard string, seconds float64) {
+	if Enabled {
+		shardDuration.WithLabelValues(name, shard).Observe(seconds)
+	}
+}
@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	sqlHooksDuration *prometheus.HistogramVec
+	sqlHooksErrors   *prometheus.CounterVec
+)
+
+func initSQLHooks() {
+	sqlHooksDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "eventnative",
+		Subsystem: "sql_hooks",
+		Name:      "duration_seconds",
+		Buckets:   []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 300, 900},
+	}, []string{"project_id", "destination_id", "table", "stage"})
+	sqlHooksErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "eventnative",
+		Subsystem: "sql_hooks",
+		Name:      "errors",
+	}, []string{"project_id", "destination_id", "table", "stage"})
+}
+
+//SQLHookDuration observe execution time of the destination table SQL hook statement. stage is pre or post
+func SQLHookDuration(destinationName, table, stage string, seconds float64) {
+	if Enabled {
+		projectId, destinationId := extractLabels(destinationName)
+		sqlHooksDuration.WithLabelValues(projectId, destinationId, table, stage).Observe(seconds)
+	}
+}
+
+//SQLHookError increment failed SQL hook statements counter of the destination table
+func SQLHookError(destinationName, table, stage string) {
+	if Enabled {
+		projectId, destinationId := extractLabels(destinationName)
+		sqlHooksErrors.WithLabelValues(projectId, destinationId, table, stage).Inc()
+	}
+}
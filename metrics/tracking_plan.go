@@ -0,0 +1,39 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	trackingPlanEvents     *prometheus.CounterVec
+	trackingPlanViolations *prometheus.CounterVec
+)
+
+func initTrackingPlan() {
+	trackingPlanEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "eventnative",
+		Subsystem: "tracking_plan",
+		Name:      "events",
+	}, []string{"event_type", "result"})
+	trackingPlanViolations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "eventnative",
+		Subsystem: "tracking_plan",
+		Name:      "violations",
+	}, []string{"event_type", "kind"})
+}
+
+//TrackingPlanEvent increment checked events counter. eventType is a declared event type or unknown,
+//result is conforming, block, quarantine or tag
+func TrackingPlanEvent(eventType, result string) {
+	if Enabled {
+		trackingPlanEvents.WithLabelValues(eventType, result).Inc()
+	}
+}
+
+//TrackingPlanViolation increment violations counter. kind is unknown_event, missing_property or unknown_property
+func TrackingPlanViolation(eventType, kind string) {
+	if Enabled {
+		trackingPlanViolations.WithLabelValues(eventType, kind).Inc()
+	}
+}
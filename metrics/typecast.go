@@ -0,0 +1,24 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var typecastQuarantinedFields *prometheus.CounterVec
+
+func initTypecast() {
+	typecastQuarantinedFields = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "eventnative",
+		Subsystem: "typecast",
+		Name:      "quarantined_fields",
+	}, []string{"project_id", "destination_id"})
+}
+
+//TypecastQuarantinedField increment counter of fields which couldn't be converted to column types and have been quarantined
+func TypecastQuarantinedField(destinationName string) {
+	if Enabled {
+		projectId, destinationId := extractLabels(destinationName)
+		typecastQuarantinedFields.WithLabelValues(projectId, destinationId).Inc()
+	}
+}
@@ -0,0 +1,38 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	validatedEvents      *prometheus.CounterVec
+	validationViolations *prometheus.CounterVec
+)
+
+func initValidation() {
+	validatedEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "eventnative",
+		Subsystem: "validation",
+		Name:      "events",
+	}, []string{"schema", "result"})
+	validationViolations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "eventnative",
+		Subsystem: "validation",
+		Name:      "violations",
+	}, []string{"schema", "rule"})
+}
+
+//ValidatedEvent increment validated events counter. result is passed, reject or fallback
+func ValidatedEvent(schema, result string) {
+	if Enabled {
+		validatedEvents.WithLabelValues(schema, result).Inc()
+	}
+}
+
+//ValidationViolation increment failed schema rule counter. rule is a JSON pointer in the schema
+func ValidationViolation(schema, rule string) {
+	if Enabled {
+		validationViolations.WithLabelValues(schema, rule).Inc()
+	}
+}
@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	viewsDuration *prometheus.HistogramVec
+	viewsErrors   *prometheus.CounterVec
+)
+
+func initViews() {
+	viewsDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "eventnative",
+		Subsystem: "views",
+		Name:      "duration_seconds",
+		Buckets:   []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 300, 900},
+	}, []string{"project_id", "destination_id", "view", "operation"})
+	viewsErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "eventnative",
+		Subsystem: "views",
+		Name:      "errors",
+	}, []string{"project_id", "destination_id", "view", "operation"})
+}
+
+//ViewDuration observe execution time of the destination managed view statement. operation is create or refresh
+func ViewDuration(destinationName, view, operation string, seconds float64) {
+	if Enabled {
+		projectId, destinationId := extractLabels(destinationName)
+		viewsDuration.WithLabelValues(projectId, destinationId, view, operation).Observe(seconds)
+	}
+}
+
+//ViewError increment failed managed view statements counter of the destination
+func ViewError(destinationName, view, operation string) {
+	if Enabled {
+		projectId, destinationId := extractLabels(destinationName)
+		viewsErrors.WithLabelValues(projectId, destinationId, view, operation).Inc()
+	}
+}
@@ -5,10 +5,17 @@ import (
 	"net/http"
 )
 
-const AdminTokenErr = "Admin token does not match"
+const (
+	AdminTokenErr = "Admin token does not match"
+
+	//WorkspaceIdKey is a gin context key of the authorized workspace id (empty if server admin token is used)
+	WorkspaceIdKey = "workspace_id"
+)
 
 type AdminToken struct {
 	Token string
+	//return workspace id by the workspace admin token
+	WorkspaceByToken func(token string) (string, bool)
 }
 
 func (a *AdminToken) AdminAuth(main gin.HandlerFunc, errMsg string) gin.HandlerFunc {
@@ -25,3 +32,25 @@ func (a *AdminToken) AdminAuth(main gin.HandlerFunc, errMsg string) gin.HandlerF
 		main(c)
 	}
 }
+
+//WorkspaceAuth accept server admin token (access to all workspaces) or workspace admin token (access only to the workspace objects)
+//put authorized workspace id into gin context. See GetWorkspaceId
+func (a *AdminToken) WorkspaceAuth(main gin.HandlerFunc, errMsg string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.GetHeader("X-Admin-Token")
+		if a.WorkspaceByToken != nil && token != "" {
+			if workspaceId, ok := a.WorkspaceByToken(token); ok {
+				c.Set(WorkspaceIdKey, workspaceId)
+				main(c)
+				return
+			}
+		}
+
+		a.AdminAuth(main, errMsg)(c)
+	}
+}
+
+//GetWorkspaceId return workspace id which was authorized by WorkspaceAuth or empty string if server admin token was used
+func GetWorkspaceId(c *gin.Context) string {
+	return c.GetString(WorkspaceIdKey)
+}
@@ -5,7 +5,7 @@ import (
 	"strings"
 )
 
-//Cors handle OPTIONS requests and check if request /event or dynamic event endpoint or static endpoint (/t /s /p)
+//Cors handle OPTIONS requests and check if request /event or dynamic event endpoint, Segment compatible endpoint (/v1), Google Analytics compatible endpoints (/collect /batch /mp/collect), capabilities or static endpoint (/t /s /p)
 //check origins - if matched write origin to acao header otherwise don't write it
 func Cors(h http.Handler, isAllowedOriginsFunc func(string) ([]string, bool)) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -28,6 +28,16 @@ func Cors(h http.Handler, isAllowedOriginsFunc func(string) ([]string, bool)) ht
 				}
 			}
 
+		} else if strings.HasPrefix(r.URL.Path, "/v1/") || strings.HasSuffix(r.URL.Path, "/collect") || r.URL.Path == "/batch" {
+			//Segment and Google Analytics compatible API: token might be in the body. Client tokens origins are checked by the handler
+			writeDefaultCorsHeaders(w)
+			if reqOrigin := r.Header.Get("Origin"); reqOrigin != "" {
+				w.Header().Add("Access-Control-Allow-Origin", reqOrigin)
+			}
+		} else if r.URL.Path == "/api/v1/capabilities" {
+			//capabilities don't depend on the token
+			writeDefaultCorsHeaders(w)
+			w.Header().Add("Access-Control-Allow-Origin", "*")
 		} else if strings.Contains(r.URL.Path, "/p/") || strings.Contains(r.URL.Path, "/s/") || strings.Contains(r.URL.Path, "/t/") {
 			writeDefaultCorsHeaders(w)
 			w.Header().Add("Access-Control-Allow-Origin", "*")
@@ -49,6 +59,21 @@ func writeDefaultCorsHeaders(w http.ResponseWriter) {
 	w.Header().Add("Access-Control-Allow-Credentials", "true")
 }
 
+//IsOriginAllowed return true if origins is empty or one of them matches reqOrigin
+func IsOriginAllowed(origins []string, reqOrigin string) bool {
+	if len(origins) == 0 {
+		return true
+	}
+
+	for _, allowedOrigin := range origins {
+		if checkOrigin(allowedOrigin, reqOrigin) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func checkOrigin(allowedOrigin, reqOrigin string) bool {
 	var prefix, suffix bool
 	//reformat req origin
@@ -0,0 +1,122 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/metrics"
+	"net/http"
+)
+
+const (
+	globalLimit   = "global"
+	endpointLimit = "endpoint"
+	allEndpoints  = "all"
+)
+
+//endpoints which are never shed (health checks and metrics)
+var notShedEndpoints = map[string]bool{
+	"/ping":       true,
+	"/health":     true,
+	"/ready":      true,
+	"/prometheus": true,
+}
+
+//EndpointLimit is max in-flight requests of the endpoint. Path is a router path e.g. /api/v1/event
+type EndpointLimit struct {
+	Path        string `mapstructure:"path" json:"path,omitempty" yaml:"path,omitempty"`
+	MaxInFlight int    `mapstructure:"max_in_flight" json:"max_in_flight,omitempty" yaml:"max_in_flight,omitempty"`
+}
+
+//LoadShedder rejects requests with 503 immediately when in-flight requests count exceeds global or endpoint limit
+//long-lived websocket connections hold a slot until they are closed
+type LoadShedder struct {
+	global    *semaphore
+	endpoints map[string]*semaphore
+}
+
+//NewLoadShedder return LoadShedder or nil if there are no limits (maxInFlight <= 0 means unlimited)
+func NewLoadShedder(maxInFlight int, endpointLimits []EndpointLimit) *LoadShedder {
+	endpoints := map[string]*semaphore{}
+	for _, limit := range endpointLimits {
+		if limit.MaxInFlight > 0 {
+			endpoints[limit.Path] = newSemaphore(limit.MaxInFlight)
+		}
+	}
+
+	if maxInFlight <= 0 && len(endpoints) == 0 {
+		return nil
+	}
+
+	ls := &LoadShedder{endpoints: endpoints}
+	if maxInFlight > 0 {
+		ls.global = newSemaphore(maxInFlight)
+	}
+
+	return ls
+}
+
+//Handler is a gin middleware func
+func (ls *LoadShedder) Handler(c *gin.Context) {
+	endpoint := c.FullPath()
+	if endpoint == "" || notShedEndpoints[endpoint] {
+		c.Next()
+		return
+	}
+
+	if ls.global != nil {
+		if !ls.global.tryAcquire() {
+			shed(c, endpoint, globalLimit)
+			return
+		}
+		metrics.InFlightRequests(allEndpoints, ls.global.inFlight())
+		defer func() {
+			ls.global.release()
+			metrics.InFlightRequests(allEndpoints, ls.global.inFlight())
+		}()
+	}
+
+	if sem, ok := ls.endpoints[endpoint]; ok {
+		if !sem.tryAcquire() {
+			shed(c, endpoint, endpointLimit)
+			return
+		}
+		metrics.InFlightRequests(endpoint, sem.inFlight())
+		defer func() {
+			sem.release()
+			metrics.InFlightRequests(endpoint, sem.inFlight())
+		}()
+	}
+
+	c.Next()
+}
+
+func shed(c *gin.Context, endpoint, limit string) {
+	metrics.ShedRequest(endpoint, limit)
+	c.Header("Retry-After", "1")
+	c.AbortWithStatusJSON(http.StatusServiceUnavailable, ErrorResponse{Message: "Server is overloaded. Please retry later"})
+}
+
+//semaphore is a non-blocking counting semaphore
+type semaphore struct {
+	slots chan struct{}
+}
+
+func newSemaphore(size int) *semaphore {
+	return &semaphore{slots: make(chan struct{}, size)}
+}
+
+func (s *semaphore) tryAcquire() bool {
+	select {
+	case s.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *semaphore) release() {
+	<-s.slots
+}
+
+func (s *semaphore) inFlight() int {
+	return len(s.slots)
+}
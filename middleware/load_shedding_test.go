@@ -0,0 +1,150 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewLoadShedder(t *testing.T) {
+	tests := []struct {
+		name              string
+		maxInFlight       int
+		endpointLimits    []EndpointLimit
+		expectedNil       bool
+		expectedGlobal    bool
+		expectedEndpoints []string
+	}{
+		{"No limits", 0, nil, true, false, nil},
+		{"Only non-positive limits", -1, []EndpointLimit{{Path: "/a", MaxInFlight: 0}}, true, false, nil},
+		{"Only global", 10, nil, false, true, []string{}},
+		{"Only endpoint", 0, []EndpointLimit{{Path: "/a", MaxInFlight: 1}, {Path: "/b", MaxInFlight: 0}}, false, false, []string{"/a"}},
+		{"Global and endpoint", 10, []EndpointLimit{{Path: "/a", MaxInFlight: 1}}, false, true, []string{"/a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ls := NewLoadShedder(tt.maxInFlight, tt.endpointLimits)
+			if tt.expectedNil {
+				require.Nil(t, ls)
+				return
+			}
+
+			require.NotNil(t, ls)
+			require.Equal(t, tt.expectedGlobal, ls.global != nil)
+			var endpoints []string
+			for endpoint := range ls.endpoints {
+				endpoints = append(endpoints, endpoint)
+			}
+			require.ElementsMatch(t, tt.expectedEndpoints, endpoints)
+		})
+	}
+}
+
+func TestLoadShedderGlobalLimit(t *testing.T) {
+	ls := NewLoadShedder(2, nil)
+	router, inFlight := newLoadSheddingRouter(ls)
+
+	first := inFlight.start(router, "/a")
+	second := inFlight.start(router, "/b")
+	require.Equal(t, 2, ls.global.inFlight())
+
+	rec := serve(router, "/a")
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	require.Equal(t, "1", rec.Header().Get("Retry-After"))
+	require.Equal(t, 2, ls.global.inFlight(), "Shed request mustn't hold a slot")
+
+	//health checks aren't shed
+	require.Equal(t, http.StatusOK, serve(router, "/ping").Code)
+	//unknown routes aren't counted
+	require.Equal(t, http.StatusNotFound, serve(router, "/unknown").Code)
+	require.Equal(t, 2, ls.global.inFlight())
+
+	require.Equal(t, http.StatusOK, first.finish().Code)
+	require.Equal(t, 1, ls.global.inFlight())
+
+	third := inFlight.start(router, "/a")
+	require.Equal(t, http.StatusOK, second.finish().Code)
+	require.Equal(t, http.StatusOK, third.finish().Code)
+	require.Equal(t, 0, ls.global.inFlight())
+}
+
+func TestLoadShedderEndpointLimit(t *testing.T) {
+	ls := NewLoadShedder(3, []EndpointLimit{{Path: "/a", MaxInFlight: 1}})
+	router, inFlight := newLoadSheddingRouter(ls)
+
+	first := inFlight.start(router, "/a")
+	require.Equal(t, 1, ls.endpoints["/a"].inFlight())
+	require.Equal(t, 1, ls.global.inFlight())
+
+	rec := serve(router, "/a")
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	require.Equal(t, "1", rec.Header().Get("Retry-After"))
+	require.Equal(t, 1, ls.endpoints["/a"].inFlight())
+	require.Equal(t, 1, ls.global.inFlight(), "Global slot of the request shed by endpoint limit must be released")
+
+	//other endpoints aren't limited by /a limit
+	second := inFlight.start(router, "/b")
+	require.Equal(t, 2, ls.global.inFlight())
+
+	require.Equal(t, http.StatusOK, first.finish().Code)
+	require.Equal(t, 0, ls.endpoints["/a"].inFlight())
+	require.Equal(t, http.StatusOK, inFlight.start(router, "/a").finish().Code)
+
+	require.Equal(t, http.StatusOK, second.finish().Code)
+	require.Equal(t, 0, ls.endpoints["/a"].inFlight())
+	require.Equal(t, 0, ls.global.inFlight())
+}
+
+//blockingRequests make /a and /b handlers wait until the request is finished by the test
+//every handled request sends its own release channel into entered
+type blockingRequests struct {
+	entered chan chan struct{}
+}
+
+type blockingRequest struct {
+	release chan struct{}
+	done    chan *httptest.ResponseRecorder
+}
+
+//newLoadSheddingRouter return router with load shedder and blocking /a, /b handlers and non-blocking /ping handler
+func newLoadSheddingRouter(ls *LoadShedder) (*gin.Engine, *blockingRequests) {
+	gin.SetMode(gin.TestMode)
+	br := &blockingRequests{entered: make(chan chan struct{})}
+	blocking := func(c *gin.Context) {
+		release := make(chan struct{})
+		br.entered <- release
+		<-release
+		c.Status(http.StatusOK)
+	}
+
+	router := gin.New()
+	router.Use(ls.Handler)
+	router.GET("/a", blocking)
+	router.GET("/b", blocking)
+	router.GET("/ping", func(c *gin.Context) { c.Status(http.StatusOK) })
+	return router, br
+}
+
+//start serve the request in a goroutine and return when it is being handled (holds load shedder slots)
+func (br *blockingRequests) start(router *gin.Engine, path string) *blockingRequest {
+	req := &blockingRequest{done: make(chan *httptest.ResponseRecorder, 1)}
+	go func() {
+		req.done <- serve(router, path)
+	}()
+	req.release = <-br.entered
+	return req
+}
+
+//finish let the request complete and return its response
+func (r *blockingRequest) finish() *httptest.ResponseRecorder {
+	close(r.release)
+	return <-r.done
+}
+
+func serve(router *gin.Engine, path string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec
+}
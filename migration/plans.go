@@ -0,0 +1,70 @@
+package migration
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/schema"
+	"github.com/jitsucom/eventnative/storages"
+	"sort"
+)
+
+//DestinationPlan is a table migration plan of the destination which is waiting for review (data_layout.schema_migrations: review)
+type DestinationPlan struct {
+	DestinationId string `json:"destination_id"`
+	*schema.MigrationPlan
+}
+
+//Plans return migration plans waiting for review sorted by destination id filtered by destination ids if the filter isn't empty
+func (s *Service) Plans(destinationsFilter map[string]bool) []*DestinationPlan {
+	var destinationIds []string
+	for destinationId := range s.destinationService.GetConfig() {
+		if len(destinationsFilter) == 0 || destinationsFilter[destinationId] {
+			destinationIds = append(destinationIds, destinationId)
+		}
+	}
+	sort.Strings(destinationIds)
+
+	plans := []*DestinationPlan{}
+	for _, destinationId := range destinationIds {
+		planner, err := s.planner(destinationId)
+		if err != nil {
+			continue
+		}
+		for _, plan := range planner.MigrationPlans() {
+			plans = append(plans, &DestinationPlan{DestinationId: destinationId, MigrationPlan: plan})
+		}
+	}
+
+	return plans
+}
+
+//ApplyPlan apply reviewed migration plan of the destination table
+func (s *Service) ApplyPlan(destinationId, table string) error {
+	if destinationId == "" || table == "" {
+		return errors.New("destination_id and table are required parameters")
+	}
+
+	planner, err := s.planner(destinationId)
+	if err != nil {
+		return err
+	}
+
+	return planner.ApplyMigrationPlan(table)
+}
+
+func (s *Service) planner(destinationId string) (storages.MigrationPlanner, error) {
+	storageProxy, ok := s.destinationService.GetStorageById(destinationId)
+	if !ok {
+		return nil, fmt.Errorf("Destination [%s] wasn't found", destinationId)
+	}
+	storage, ok := storageProxy.Get()
+	if !ok {
+		return nil, fmt.Errorf("Destination [%s] hasn't been initialized yet", destinationId)
+	}
+	planner, ok := storage.(storages.MigrationPlanner)
+	if !ok {
+		return nil, fmt.Errorf("Destination [%s] of type [%s] doesn't support migration plans", destinationId, storage.Type())
+	}
+
+	return planner, nil
+}
@@ -0,0 +1,305 @@
+package migration
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/destinations"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/meta"
+	"github.com/jitsucom/eventnative/safego"
+	"github.com/jitsucom/eventnative/schema"
+	"github.com/jitsucom/eventnative/storages"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	StatusRunning  = "running"
+	StatusComplete = "complete"
+	StatusFailed   = "failed"
+
+	defaultBatchSize = 10000
+)
+
+//Migration is a declared column rename of the destination (see DataLayout.ColumnRenames) with backfill progress
+//Backfill progress is present only for renames of the certain table
+type Migration struct {
+	DestinationId string    `json:"destination_id"`
+	Table         string    `json:"table,omitempty"`
+	From          string    `json:"from"`
+	To            string    `json:"to"`
+	Backfill      *Progress `json:"backfill,omitempty"`
+}
+
+//Progress is a backfill of the renamed column old values via batched destination-side UPDATE.
+//It is saved into meta storage after every batch. Interrupted backfill is resumed with the previous counters
+type Progress struct {
+	DestinationId string    `json:"destination_id"`
+	Table         string    `json:"table"`
+	From          string    `json:"from"`
+	To            string    `json:"to"`
+	Status        string    `json:"status"`
+	BatchSize     int       `json:"batch_size"`
+	Batches       int       `json:"batches"`
+	UpdatedRows   int64     `json:"updated_rows"`
+	Error         string    `json:"error,omitempty"`
+	StartedAt     time.Time `json:"started_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+//Service manages backfills of renamed columns: old column values are copied into the new column in batches
+//until there are no rows with old value and without new value. Backfill can be safely restarted
+type Service struct {
+	sync.RWMutex
+
+	destinationService *destinations.Service
+	metaStorage        meta.Storage
+	batchSize          int
+
+	//destinationId/table/from -> backfills started by the current instance
+	backfills map[string]*Progress
+}
+
+//only for tests
+func NewTestService() *Service {
+	return &Service{metaStorage: &meta.Dummy{}, batchSize: defaultBatchSize, backfills: map[string]*Progress{}}
+}
+
+func NewService(destinationService *destinations.Service, metaStorage meta.Storage, batchSize int) *Service {
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+
+	return &Service{
+		destinationService: destinationService,
+		metaStorage:        metaStorage,
+		batchSize:          batchSize,
+		backfills:          map[string]*Progress{},
+	}
+}
+
+//Migrations return declared column renames sorted by destination id filtered by destination ids if the filter isn't empty
+func (s *Service) Migrations(destinationsFilter map[string]bool) []*Migration {
+	var destinationIds []string
+	config := s.destinationService.GetConfig()
+	for destinationId := range config {
+		if len(destinationsFilter) == 0 || destinationsFilter[destinationId] {
+			destinationIds = append(destinationIds, destinationId)
+		}
+	}
+	sort.Strings(destinationIds)
+
+	migrations := []*Migration{}
+	for _, destinationId := range destinationIds {
+		for _, rename := range renames(config[destinationId]) {
+			migration := &Migration{DestinationId: destinationId, Table: rename.Table, From: rename.From, To: rename.To}
+			if rename.Table != "" {
+				progress, err := s.Progress(destinationId, rename.Table, rename.From)
+				if err != nil {
+					logging.Errorf("[%s] Error getting backfill progress of [%s]: %v", destinationId, rename, err)
+				}
+				migration.Backfill = progress
+			}
+			migrations = append(migrations, migration)
+		}
+	}
+
+	return migrations
+}
+
+//Progress return a copy of the column backfill progress or nil if backfill hasn't been started
+func (s *Service) Progress(destinationId, table, from string) (*Progress, error) {
+	s.RLock()
+	progress, ok := s.backfills[key(destinationId, table, from)]
+	if ok {
+		progressCopy := *progress
+		s.RUnlock()
+		return &progressCopy, nil
+	}
+	s.RUnlock()
+
+	return s.load(destinationId, table, from)
+}
+
+//Backfill start backfill of the declared column rename in the table
+//only one backfill of the column can be run at the same time by the instance
+func (s *Service) Backfill(destinationId, table, from string) (*Progress, error) {
+	if destinationId == "" || table == "" || from == "" {
+		return nil, errors.New("destination_id, table and from are required parameters")
+	}
+
+	config, ok := s.destinationService.GetConfig()[destinationId]
+	if !ok {
+		return nil, fmt.Errorf("Destination [%s] wasn't found", destinationId)
+	}
+	rename, ok := findRename(renames(config), table, from)
+	if !ok {
+		return nil, fmt.Errorf("Column [%s] rename of [%s] table isn't declared in destination [%s] data_layout.column_renames", from, table, destinationId)
+	}
+
+	storageProxy, ok := s.destinationService.GetStorageById(destinationId)
+	if !ok {
+		return nil, fmt.Errorf("Destination [%s] wasn't found", destinationId)
+	}
+	storage, ok := storageProxy.Get()
+	if !ok {
+		return nil, fmt.Errorf("Destination [%s] hasn't been initialized yet", destinationId)
+	}
+	backfiller, ok := storage.(storages.ColumnBackfiller)
+	if !ok {
+		return nil, fmt.Errorf("Destination [%s] of type [%s] doesn't support column backfill", destinationId, storage.Type())
+	}
+
+	//previous progress of interrupted or failed backfill is continued
+	stored, err := s.load(destinationId, table, from)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Lock()
+	progressKey := key(destinationId, table, from)
+	if running, ok := s.backfills[progressKey]; ok && running.Status == StatusRunning {
+		s.Unlock()
+		return nil, fmt.Errorf("Column [%s] of [%s] table is being backfilled", from, table)
+	}
+	now := time.Now().UTC()
+	progress := &Progress{
+		DestinationId: destinationId,
+		Table:         table,
+		From:          from,
+		To:            rename.To,
+		Status:        StatusRunning,
+		BatchSize:     s.batchSize,
+		StartedAt:     now,
+		UpdatedAt:     now,
+	}
+	if stored != nil && stored.Status != StatusComplete && stored.To == rename.To {
+		progress.Batches = stored.Batches
+		progress.UpdatedRows = stored.UpdatedRows
+		progress.StartedAt = stored.StartedAt
+	}
+	s.backfills[progressKey] = progress
+	progressCopy := *progress
+	s.Unlock()
+
+	s.save(&progressCopy)
+
+	logging.Infof("[%s] Backfill of renamed column %s has been started", destinationId, rename)
+	safego.Run(func() {
+		finished := false
+		defer func() {
+			if !finished {
+				s.finish(progress, errors.New("Backfill has been interrupted by panic"))
+			}
+		}()
+
+		err := s.run(backfiller, progress)
+		finished = true
+		s.finish(progress, err)
+	})
+
+	return &progressCopy, nil
+}
+
+func (s *Service) run(backfiller storages.ColumnBackfiller, progress *Progress) error {
+	for {
+		updated, err := backfiller.BackfillColumn(progress.Table, progress.From, progress.To, s.batchSize)
+		if err != nil {
+			return err
+		}
+
+		s.Lock()
+		progress.Batches++
+		progress.UpdatedRows += updated
+		progress.UpdatedAt = time.Now().UTC()
+		progressCopy := *progress
+		s.Unlock()
+
+		if updated < int64(s.batchSize) {
+			return nil
+		}
+
+		s.save(&progressCopy)
+	}
+}
+
+func (s *Service) finish(progress *Progress, err error) {
+	s.Lock()
+	progress.UpdatedAt = time.Now().UTC()
+	if err != nil {
+		progress.Status = StatusFailed
+		progress.Error = err.Error()
+	} else {
+		progress.Status = StatusComplete
+		progress.Error = ""
+	}
+	progressCopy := *progress
+	s.Unlock()
+
+	s.save(&progressCopy)
+
+	logging.Infof("[%s] Backfill of [%s] column from [%s] in [%s] table has been finished with status [%s]: batches: %d, updated rows: %d",
+		progress.DestinationId, progress.To, progress.From, progress.Table, progressCopy.Status, progressCopy.Batches, progressCopy.UpdatedRows)
+}
+
+func (s *Service) load(destinationId, table, from string) (*Progress, error) {
+	payload, err := s.metaStorage.GetMigrationProgress(destinationId, table, from)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting backfill progress from meta storage: %v", err)
+	}
+	if payload == "" {
+		return nil, nil
+	}
+
+	progress := &Progress{}
+	if err := json.Unmarshal([]byte(payload), progress); err != nil {
+		return nil, fmt.Errorf("Error parsing backfill progress: %v", err)
+	}
+	return progress, nil
+}
+
+func (s *Service) save(progress *Progress) {
+	payload, err := json.Marshal(progress)
+	if err != nil {
+		logging.SystemErrorf("Error marshalling backfill progress: %v", err)
+		return
+	}
+
+	if err := s.metaStorage.SaveMigrationProgress(progress.DestinationId, progress.Table, progress.From, string(payload)); err != nil {
+		logging.Errorf("[%s] Error saving backfill progress of [%s] column in [%s] table: %v", progress.DestinationId, progress.From, progress.Table, err)
+	}
+}
+
+func renames(config storages.DestinationConfig) []schema.ColumnRename {
+	if config.DataLayout == nil {
+		return nil
+	}
+	return config.DataLayout.ColumnRenames
+}
+
+//findRename return the rename of the table column or all tables rename of the column
+func findRename(renames []schema.ColumnRename, table, from string) (schema.ColumnRename, bool) {
+	var allTables *schema.ColumnRename
+	for i, rename := range renames {
+		if rename.From != from {
+			continue
+		}
+		if rename.Table == table {
+			return rename, true
+		}
+		if rename.Table == "" {
+			allTables = &renames[i]
+		}
+	}
+
+	if allTables != nil {
+		return *allTables, true
+	}
+	return schema.ColumnRename{}, false
+}
+
+func key(destinationId, table, from string) string {
+	return destinationId + "/" + table + "/" + from
+}
@@ -2,6 +2,7 @@ package notifications
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"github.com/jitsucom/eventnative/safego"
 	"io/ioutil"
@@ -37,6 +38,32 @@ const (
 			]
 		}
 	]
+}`
+	warningTemplate = `{
+	"attachments": [
+		{
+			"color": "#f0ad4e",
+			"blocks": [
+				{
+					"type": "section",
+					"text": {
+						"type": "mrkdwn",
+						"text": "*%s* [%s]:"
+					}
+				},
+				{
+					"type": "divider"
+				},
+				{
+					"type": "section",
+					"text": {
+						"type": "mrkdwn",
+						"text": "%s"
+					}
+				}
+			]
+		}
+	]
 }`
 	systemErrorTemplate = `{
 	"attachments": [
@@ -149,6 +176,14 @@ func SystemError(msg ...interface{}) {
 	}
 }
 
+//Warning send message with warning color (e.g. schema drift). Message is escaped and can be multiline
+func Warning(msg string) {
+	if instance != nil {
+		escaped, _ := json.Marshal(msg)
+		instance.messagesCh <- fmt.Sprintf(warningTemplate, instance.serviceName, instance.serverName, string(escaped[1:len(escaped)-1]))
+	}
+}
+
 func Close() {
 	if instance != nil {
 		instance.closed = true
@@ -0,0 +1,199 @@
+package parsers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/minio/simdjson-go"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	StdDecoder      = "std"
+	JsoniterDecoder = "jsoniter"
+	SimdjsonDecoder = "simdjson"
+)
+
+//JsonDecoder decode JSON object bytes into map. All decoders return numbers as json.Number: typing.ReformatValue
+//converts them into int64 (integer literals) or float64 (literals with fraction or exponent)
+type JsonDecoder interface {
+	Decode(b []byte) (map[string]interface{}, error)
+	Name() string
+}
+
+//decoder is used in ParseJson (events log files, fallback files and stream payloads parsing)
+var decoder JsonDecoder = &stdDecoder{}
+
+//SetDecoder set JSON decoder by name: std (default, encoding/json), jsoniter or simdjson (requires AVX2 and CLMUL CPU support)
+func SetDecoder(name string) error {
+	switch name {
+	case "", StdDecoder:
+		decoder = &stdDecoder{}
+	case JsoniterDecoder:
+		decoder = newJsoniterDecoder()
+	case SimdjsonDecoder:
+		if !simdjson.SupportedCPU() {
+			return errors.New("JSON decoder simdjson isn't supported by the CPU: AVX2 and CLMUL instructions are required")
+		}
+		decoder = &simdjsonDecoder{}
+	default:
+		return fmt.Errorf("Unknown JSON decoder: %s. Available: [%s, %s, %s]", name, StdDecoder, JsoniterDecoder, SimdjsonDecoder)
+	}
+	return nil
+}
+
+//Decoder return the current JSON decoder
+func Decoder() JsonDecoder {
+	return decoder
+}
+
+//stdDecoder is an encoding/json decoder with json.Number
+type stdDecoder struct{}
+
+func (sd *stdDecoder) Decode(b []byte) (map[string]interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+
+	obj := map[string]interface{}{}
+	err := decoder.Decode(&obj)
+	return obj, err
+}
+
+func (sd *stdDecoder) Name() string {
+	return StdDecoder
+}
+
+//jsoniterDecoder is encoding/json compatible jsoniter decoder. Numbers are kept as json.Number with the source text
+type jsoniterDecoder struct {
+	api jsoniter.API
+}
+
+func newJsoniterDecoder() *jsoniterDecoder {
+	return &jsoniterDecoder{api: jsoniter.Config{
+		EscapeHTML:             true,
+		SortMapKeys:            true,
+		ValidateJsonRawMessage: true,
+		UseNumber:              true,
+	}.Froze()}
+}
+
+func (jd *jsoniterDecoder) Decode(b []byte) (map[string]interface{}, error) {
+	obj := map[string]interface{}{}
+	err := jd.api.Unmarshal(b, &obj)
+	return obj, err
+}
+
+func (jd *jsoniterDecoder) Name() string {
+	return JsoniterDecoder
+}
+
+//simdjsonDecoder is a SIMD decoder. Parsed tapes are reused between calls.
+//Numbers are parsed by simdjson, so json.Number text is normalized: 1.50 -> 1.5, 1e3 -> 1000.0 and integers beyond
+//int64/uint64 range lose precision as float64 (std and jsoniter decoders keep them as typing.Decimal)
+type simdjsonDecoder struct {
+	tapes sync.Pool
+}
+
+func (sd *simdjsonDecoder) Decode(b []byte) (map[string]interface{}, error) {
+	reuse, _ := sd.tapes.Get().(*simdjson.ParsedJson)
+	parsed, err := simdjson.Parse(b, reuse)
+	if err != nil {
+		return nil, err
+	}
+	defer sd.tapes.Put(parsed)
+
+	iter := parsed.Iter()
+	if iter.Advance() != simdjson.TypeRoot {
+		return nil, errors.New("JSON value is empty")
+	}
+	t, root, err := iter.Root(nil)
+	if err != nil {
+		return nil, err
+	}
+	if t != simdjson.TypeObject {
+		return nil, fmt.Errorf("JSON value must be an object: %s", t)
+	}
+	object, err := root.Object(nil)
+	if err != nil {
+		return nil, err
+	}
+	return simdjsonObject(object)
+}
+
+func (sd *simdjsonDecoder) Name() string {
+	return SimdjsonDecoder
+}
+
+func simdjsonObject(object *simdjson.Object) (map[string]interface{}, error) {
+	result := map[string]interface{}{}
+	var element simdjson.Iter
+	for {
+		name, t, err := object.NextElement(&element)
+		if err != nil {
+			return nil, err
+		}
+		if t == simdjson.TypeNone {
+			return result, nil
+		}
+		value, err := simdjsonValue(&element, t)
+		if err != nil {
+			return nil, err
+		}
+		result[name] = value
+	}
+}
+
+func simdjsonValue(iter *simdjson.Iter, t simdjson.Type) (interface{}, error) {
+	switch t {
+	case simdjson.TypeInt:
+		v, err := iter.Int()
+		return json.Number(strconv.FormatInt(v, 10)), err
+	case simdjson.TypeUint:
+		v, err := iter.Uint()
+		return json.Number(strconv.FormatUint(v, 10)), err
+	case simdjson.TypeFloat:
+		v, err := iter.Float()
+		return floatNumber(v), err
+	case simdjson.TypeObject:
+		object, err := iter.Object(nil)
+		if err != nil {
+			return nil, err
+		}
+		return simdjsonObject(object)
+	case simdjson.TypeArray:
+		array, err := iter.Array(nil)
+		if err != nil {
+			return nil, err
+		}
+		elements := []interface{}{}
+		arrayIter := array.Iter()
+		for {
+			elementType := arrayIter.Advance()
+			if elementType == simdjson.TypeNone {
+				return elements, nil
+			}
+			element, err := simdjsonValue(&arrayIter, elementType)
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, element)
+		}
+	default:
+		//strings, booleans and nulls
+		return iter.Interface()
+	}
+}
+
+//floatNumber return json.Number of the float literal: it always has a fraction or an exponent (e.g. 2.0 isn't formatted as 2), so
+//typing.ReformatValue keeps it float64 as with the source text
+func floatNumber(v float64) json.Number {
+	text := strconv.FormatFloat(v, 'g', -1, 64)
+	if !strings.ContainsAny(text, ".eEIN") {
+		text += ".0"
+	}
+	return json.Number(text)
+}
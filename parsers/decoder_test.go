@@ -0,0 +1,101 @@
+package parsers
+
+import (
+	"encoding/json"
+	"github.com/jitsucom/eventnative/typing"
+	"github.com/minio/simdjson-go"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestDecoders(t *testing.T) {
+	input := []byte(`{"int": 42, "negative": -7, "big": 9007199254740993, "float": 1.5, "exp": 1.5e3, "integral_float": 2.0,` +
+		`"str": "va\"lue", "bool": true, "null": null, "nested": {"arr": [1, 2.5, "a", {"k": false}], "empty": {}}}` + "\n")
+	expected := map[string]interface{}{
+		"int":            int64(42),
+		"negative":       int64(-7),
+		"big":            int64(9007199254740993),
+		"float":          1.5,
+		"exp":            float64(1500),
+		"integral_float": float64(2),
+		"str":            `va"lue`,
+		"bool":           true,
+		"null":           nil,
+		"nested": map[string]interface{}{
+			"arr":   []interface{}{int64(1), 2.5, "a", map[string]interface{}{"k": false}},
+			"empty": map[string]interface{}{},
+		},
+	}
+
+	decoders := []JsonDecoder{&stdDecoder{}, newJsoniterDecoder()}
+	if simdjson.SupportedCPU() {
+		decoders = append(decoders, &simdjsonDecoder{})
+	}
+	for _, decoder := range decoders {
+		t.Run(decoder.Name(), func(t *testing.T) {
+			//twice: simdjson tapes are reused
+			for i := 0; i < 2; i++ {
+				actual, err := decoder.Decode(input)
+				require.NoError(t, err)
+				require.IsType(t, json.Number(""), actual["int"])
+				require.Equal(t, expected, reformat(actual))
+			}
+
+			_, err := decoder.Decode([]byte(`{"key": `))
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestSetDecoder(t *testing.T) {
+	defer SetDecoder(StdDecoder)
+
+	require.NoError(t, SetDecoder(JsoniterDecoder))
+	require.Equal(t, JsoniterDecoder, Decoder().Name())
+	object, err := ParseJson([]byte(`{"a": 1}`))
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"a": json.Number("1")}, object)
+
+	require.EqualError(t, SetDecoder("fast"), "Unknown JSON decoder: fast. Available: [std, jsoniter, simdjson]")
+}
+
+//reformat return object with typing.ReformatValue applied to all json.Number values
+func reformat(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		result := map[string]interface{}{}
+		for key, element := range v {
+			result[key] = reformat(element)
+		}
+		return result
+	case []interface{}:
+		result := []interface{}{}
+		for _, element := range v {
+			result = append(result, reformat(element))
+		}
+		return result
+	default:
+		return typing.ReformatValue(v)
+	}
+}
+
+func BenchmarkDecoders(b *testing.B) {
+	input := []byte(`{"_timestamp": "2020-08-02T10:00:00.000000Z", "eventn_ctx": {"event_id": "b6a1f4c2", "user": {"anonymous_id": "anon123",` +
+		`"id": 42}, "page_url": "https://jitsu.com/docs", "local_tz_offset": -180, "utm": {"source": "google", "medium": "cpc"}},` +
+		`"event_type": "pageview", "amount": 12.5, "items": [{"id": 1, "price": 3.5}, {"id": 2, "price": 9}], "is_new": true}`)
+
+	decoders := []JsonDecoder{&stdDecoder{}, newJsoniterDecoder()}
+	if simdjson.SupportedCPU() {
+		decoders = append(decoders, &simdjsonDecoder{})
+	}
+	for _, decoder := range decoders {
+		b.Run(decoder.Name(), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := decoder.Decode(input); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
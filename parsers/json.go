@@ -1,19 +1,13 @@
 package parsers
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
 )
 
-//Parse json bytes into map with json Numbers
+//Parse json bytes into map with json Numbers with the configured decoder (see SetDecoder)
 func ParseJson(b []byte) (map[string]interface{}, error) {
-	decoder := json.NewDecoder(bytes.NewReader(b))
-	decoder.UseNumber()
-
-	obj := map[string]interface{}{}
-	err := decoder.Decode(&obj)
-	return obj, err
+	return decoder.Decode(b)
 }
 
 //Return parsed into map[string]interface{} event from events.FailedFact
@@ -41,10 +35,5 @@ func ParseInterface(v interface{}) (map[string]interface{}, error) {
 	if err != nil {
 		return nil, fmt.Errorf("Error marshalling value: %v", err)
 	}
-	decoder := json.NewDecoder(bytes.NewReader(b))
-	decoder.UseNumber()
-
-	obj := map[string]interface{}{}
-	err = decoder.Decode(&obj)
-	return obj, err
+	return ParseJson(b)
 }
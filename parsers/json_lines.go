@@ -0,0 +1,106 @@
+package parsers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+//JsonLine is a parsed line (or array element) of a batch. Object is nil if Err isn't nil
+type JsonLine struct {
+	Object map[string]interface{}
+	Err    error
+}
+
+//ParseJsonLines parse NDJSON (one object per line, empty lines are skipped) or JSON array of objects into objects with json Numbers.
+//Malformed lines don't stop parsing: they are returned with Err. return error if reader fails, NDJSON line exceeds maxLineSize,
+//there are more than maxLines lines or a JSON array is malformed (array elements can't be re-synchronized)
+func ParseJsonLines(r io.Reader, maxLineSize, maxLines int) ([]JsonLine, error) {
+	reader := bufio.NewReader(r)
+	first, err := peekNonSpace(reader)
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if first == '[' {
+		return parseJsonArray(reader, maxLines)
+	}
+
+	scanner := bufio.NewScanner(reader)
+	//max token size is the larger of maxLineSize and initial buffer capacity
+	initialSize := 64 * 1024
+	if initialSize > maxLineSize {
+		initialSize = maxLineSize
+	}
+	scanner.Buffer(make([]byte, 0, initialSize), maxLineSize)
+	var lines []JsonLine
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if len(lines) == maxLines {
+			return nil, fmt.Errorf("Batch can't contain more than %d lines", maxLines)
+		}
+
+		object, err := ParseJson(line)
+		if err != nil {
+			lines = append(lines, JsonLine{Err: err})
+		} else {
+			lines = append(lines, JsonLine{Object: object})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		if err == bufio.ErrTooLong {
+			return nil, fmt.Errorf("Line #%d exceeds max size %d bytes", len(lines), maxLineSize)
+		}
+		return nil, err
+	}
+
+	return lines, nil
+}
+
+func parseJsonArray(reader io.Reader, maxLines int) ([]JsonLine, error) {
+	decoder := json.NewDecoder(reader)
+	decoder.UseNumber()
+
+	var elements []json.RawMessage
+	if err := decoder.Decode(&elements); err != nil {
+		return nil, fmt.Errorf("Malformed JSON array: %v", err)
+	}
+	if len(elements) > maxLines {
+		return nil, fmt.Errorf("Batch can't contain more than %d lines", maxLines)
+	}
+
+	lines := make([]JsonLine, 0, len(elements))
+	for _, element := range elements {
+		object, err := ParseJson(element)
+		if err != nil {
+			lines = append(lines, JsonLine{Err: err})
+		} else {
+			lines = append(lines, JsonLine{Object: object})
+		}
+	}
+	return lines, nil
+}
+
+//peekNonSpace skip leading whitespaces and return the first not space byte without consuming it
+func peekNonSpace(reader *bufio.Reader) (byte, error) {
+	for {
+		b, err := reader.Peek(1)
+		if err != nil {
+			return 0, err
+		}
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			reader.ReadByte()
+		default:
+			return b[0], nil
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package parsers
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestParseJsonLines(t *testing.T) {
+	tests := []struct {
+		name          string
+		input         string
+		expected      []map[string]interface{}
+		expectedLines []bool
+		expectedErr   string
+	}{
+		{
+			"Empty",
+			"  \n",
+			nil,
+			nil,
+			"",
+		},
+		{
+			"NDJSON with malformed and empty lines",
+			"{\"a\":1}\n\n{malformed\n {\"b\":\"c\"} \n",
+			[]map[string]interface{}{{"a": json.Number("1")}, nil, {"b": "c"}},
+			[]bool{true, false, true},
+			"",
+		},
+		{
+			"JSON array",
+			"\n [{\"a\":1}, 5, {\"b\":\"c\"}]",
+			[]map[string]interface{}{{"a": json.Number("1")}, nil, {"b": "c"}},
+			[]bool{true, false, true},
+			"",
+		},
+		{
+			"Malformed JSON array",
+			"[{\"a\":1},",
+			nil,
+			nil,
+			"Malformed JSON array: unexpected EOF",
+		},
+		{
+			"Too many lines",
+			"{}\n{}\n{}\n{}",
+			nil,
+			nil,
+			"Batch can't contain more than 3 lines",
+		},
+		{
+			"Too long line",
+			"{}\n{\"a\":\"" + strings.Repeat("a", 100) + "\"}",
+			nil,
+			nil,
+			"Line #1 exceeds max size 64 bytes",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := ParseJsonLines(strings.NewReader(tt.input), 64, 3)
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, len(tt.expectedLines), len(actual))
+			for i, line := range actual {
+				if tt.expectedLines[i] {
+					require.NoError(t, line.Err)
+					require.Equal(t, tt.expected[i], line.Object)
+				} else {
+					require.Error(t, line.Err)
+					require.Nil(t, line.Object)
+				}
+			}
+		})
+	}
+}
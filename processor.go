@@ -0,0 +1,47 @@
+package main
+
+import (
+	"github.com/jitsucom/eventnative/appconfig"
+	"github.com/jitsucom/eventnative/destinations"
+	"github.com/jitsucom/eventnative/grpcapi"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/schema"
+	"github.com/jitsucom/eventnative/storages"
+	"github.com/spf13/viper"
+)
+
+//runProcessorService serve only gRPC eventnative.Processor API (processor service mode): configured destinations are
+//compiled into processors without connecting to them, events are processed and returned to clients, nothing is stored.
+//Destinations configuration is loaded once on startup
+func runProcessorService() {
+	processorConfig := grpcapi.Config{Port: grpcapi.DefaultProcessorPort}
+	if err := viper.UnmarshalKey("server.processor", &processorConfig); err != nil {
+		logging.Fatal("Error parsing server.processor config:", err)
+	}
+
+	destinationsViper, destinationsStr := destinationsConfig()
+	configs, err := destinations.LoadConfig(destinationsViper, destinationsStr)
+	if err != nil {
+		logging.Fatal(err)
+	}
+
+	processors := map[string]*schema.Processor{}
+	for name, destination := range configs {
+		processor, err := storages.NewProcessor(name, destination)
+		if err != nil {
+			logging.Errorf("[%s] Error initializing destination processor: %v", name, err)
+			continue
+		}
+		processors[name] = processor
+	}
+	if len(processors) == 0 {
+		logging.Fatal("Processor service mode requires at least one valid destination")
+	}
+
+	processorServer, err := grpcapi.NewProcessor(processorConfig, processors, appconfig.Instance.AuthorizationService.GetServerOrigins)
+	if err != nil {
+		logging.Fatal(err)
+	}
+	processorServer.Start()
+	appconfig.Instance.ScheduleClosing(processorServer)
+}
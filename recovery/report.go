@@ -0,0 +1,135 @@
+package recovery
+
+import (
+	"fmt"
+	"github.com/jitsucom/eventnative/logging"
+	"io/ioutil"
+	"os"
+	"path"
+	"sync"
+	"time"
+)
+
+const (
+	//batch upload is continued from the point it has been interrupted (e.g. staged file will be copied, not committed queue events will be stored)
+	Resumed = "resumed"
+	//batch will be processed and staged again from the local log file (staging is idempotent, see ledger)
+	Restaged = "restaged"
+	//batch can't be resumed: it is kept for manual handling (quarantined queue segments, stage files which failed max attempts)
+	DeadLettered = "dead_lettered"
+
+	LogFileBatch     = "log_file"
+	StagedFileBatch  = "staged_file"
+	StreamQueueBatch = "stream_queue"
+
+	//exists while the server is running: removed on graceful shutdown
+	markerFileName = "eventnative.running"
+)
+
+//Instance is a singleton startup recovery report. Batches are collected until Finish is called
+var Instance = New()
+
+//Batch is an upload which has been found unfinished on startup and its resolution
+type Batch struct {
+	DestinationId string `json:"destination_id"`
+	Type          string `json:"type"`
+	Name          string `json:"name"`
+	Resolution    string `json:"resolution"`
+	Details       string `json:"details,omitempty"`
+}
+
+//Report is a startup recovery report: unclean_shutdown is true if the previous run hasn't been shut down gracefully
+type Report struct {
+	StartedAt       time.Time `json:"started_at"`
+	FinishedAt      time.Time `json:"finished_at,omitempty"`
+	UncleanShutdown bool      `json:"unclean_shutdown"`
+	Batches         []*Batch  `json:"batches"`
+}
+
+type Recovery struct {
+	sync.RWMutex
+
+	markerPath string
+	report     *Report
+	finished   bool
+}
+
+func New() *Recovery {
+	return &Recovery{report: &Report{StartedAt: time.Now().UTC(), Batches: []*Batch{}}}
+}
+
+//Init check the running marker file in dir (left by the previous run if it has crashed) and create it
+func Init(dir string) error {
+	markerPath := path.Join(dir, markerFileName)
+	_, err := os.Stat(markerPath)
+	Instance.Lock()
+	Instance.markerPath = markerPath
+	Instance.report.UncleanShutdown = err == nil
+	Instance.Unlock()
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("Error creating dir [%s] for running marker file: %v", dir, err)
+	}
+	if err := ioutil.WriteFile(markerPath, []byte(time.Now().UTC().Format(time.RFC3339)), 0644); err != nil {
+		return fmt.Errorf("Error writing running marker file: %v", err)
+	}
+
+	if Instance.report.UncleanShutdown {
+		logging.Warnf("Previous run hasn't been shut down gracefully. Unfinished uploads will be recovered, see /api/v1/status/recovery")
+	}
+	return nil
+}
+
+//Add put the batch into the report if startup hasn't been finished
+func (r *Recovery) Add(batch *Batch) {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.finished {
+		return
+	}
+	logging.Infof("[%s] Recovery: %s [%s] %s. %s", batch.DestinationId, batch.Type, batch.Name, batch.Resolution, batch.Details)
+	r.report.Batches = append(r.report.Batches, batch)
+}
+
+//Finish stop collecting batches: it is called when all startup recovery steps have been done
+func (r *Recovery) Finish() {
+	r.Lock()
+	defer r.Unlock()
+
+	r.finished = true
+	r.report.FinishedAt = time.Now().UTC()
+
+	counts := map[string]int{}
+	for _, batch := range r.report.Batches {
+		counts[batch.Resolution]++
+	}
+	if len(r.report.Batches) > 0 {
+		logging.Infof("Recovery report: %d batches were found unfinished: %d resumed, %d restaged, %d dead lettered",
+			len(r.report.Batches), counts[Resumed], counts[Restaged], counts[DeadLettered])
+	}
+}
+
+//Report return a copy of the report
+func (r *Recovery) Report() *Report {
+	r.RLock()
+	defer r.RUnlock()
+
+	reportCopy := *r.report
+	reportCopy.Batches = append([]*Batch{}, r.report.Batches...)
+	return &reportCopy
+}
+
+//Close remove the running marker file: the next run won't be considered as recovery after a crash
+func (r *Recovery) Close() error {
+	r.RLock()
+	defer r.RUnlock()
+
+	if r.markerPath == "" {
+		return nil
+	}
+	if err := os.Remove(r.markerPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("Error removing running marker file: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package recovery
+
+import (
+	"errors"
+	"github.com/jitsucom/eventnative/ledger"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestRecoverStaged(t *testing.T) {
+	dir, err := ioutil.TempDir("", "recovery")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	Instance = New()
+	require.NoError(t, Init(dir))
+	require.False(t, Instance.Report().UncleanShutdown)
+
+	l := ledger.NewInMemory(10)
+	checksum := ledger.Checksum([]byte("payload"))
+	l.Stage("dst1", "file1", checksum, "events", 1)
+	l.Stage("dst1", "file2", checksum, "events", 1)
+	l.Loaded("dst1", "file2", "events", 1, errors.New("copy error"))
+	l.Loaded("dst1", "file2", "events", 1, errors.New("copy error"))
+	l.Stage("dst1", "file3", checksum, "events", 1)
+	l.Loaded("dst1", "file3", "events", 1, nil)
+
+	RecoverStaged(l, 2)
+	Instance.Finish()
+	//batches aren't added after startup
+	Instance.Add(&Batch{DestinationId: "dst2", Type: LogFileBatch, Name: "file", Resolution: Restaged})
+
+	report := Instance.Report()
+	require.Equal(t, 2, len(report.Batches))
+	require.Equal(t, &Batch{DestinationId: "dst1", Type: StagedFileBatch, Name: "file1", Resolution: Resumed}, report.Batches[0])
+	require.Equal(t, DeadLettered, report.Batches[1].Resolution)
+	require.True(t, l.IsDeadLettered("dst1", "file2"))
+	require.Equal(t, 1, len(l.Unfinished("dst1")))
+
+	//marker is kept after crash
+	Instance = New()
+	require.NoError(t, Init(dir))
+	require.True(t, Instance.Report().UncleanShutdown)
+
+	require.NoError(t, Instance.Close())
+	Instance = New()
+	require.NoError(t, Init(dir))
+	require.False(t, Instance.Report().UncleanShutdown)
+}
@@ -0,0 +1,26 @@
+package recovery
+
+import (
+	"fmt"
+	"github.com/jitsucom/eventnative/ledger"
+)
+
+//RecoverStaged add staged files which haven't been copied into the report. They are resumed by destinations copy loops
+//Files which copy has failed maxAttempts times (0 - unlimited) are dead lettered: they are kept in the stage and aren't retried
+func RecoverStaged(l *ledger.Ledger, maxAttempts int) {
+	for _, destinationId := range l.Destinations() {
+		for _, entry := range l.Unfinished(destinationId) {
+			batch := &Batch{DestinationId: destinationId, Type: StagedFileBatch, Name: entry.FileKey, Resolution: Resumed}
+			if entry.Error != "" {
+				batch.Details = fmt.Sprintf("%d failed copy attempts, last error: %s", entry.Attempts, entry.Error)
+			}
+
+			if maxAttempts > 0 && entry.Attempts >= maxAttempts {
+				l.DeadLetter(destinationId, entry.FileKey)
+				batch.Resolution = DeadLettered
+			}
+
+			Instance.Add(batch)
+		}
+	}
+}
@@ -0,0 +1,523 @@
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/configversions"
+	"github.com/jitsucom/eventnative/destinations"
+	"github.com/jitsucom/eventnative/encryption"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/logfiles"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/metrics"
+	"github.com/jitsucom/eventnative/parsers"
+	"github.com/jitsucom/eventnative/safego"
+	"github.com/jitsucom/eventnative/timestamp"
+	"github.com/jitsucom/eventnative/uuid"
+	"io"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	StatusRunning  = "running"
+	StatusComplete = "complete"
+	StatusFailed   = "failed"
+
+	//events are processed with the current destination configuration
+	CurrentConfig = "current"
+	//events are processed with the destination processing configuration which was active at their _timestamp
+	AsOfConfig = "as_of"
+	//events which are older than the first recorded config version
+	unknownVersion = "current"
+
+	//$serverName-event-$token-$timestamp.log
+	eventsFileMaskPostfix = "-event-*-20*.log"
+	replayIdentifier      = "replay"
+	maxTasksHistory       = 100
+	queueFullRetryTimeout = time.Second
+	storageInitTimeout    = time.Minute
+)
+
+//Task is a replay of archived raw events with _timestamp in [From, To) into the destination
+type Task struct {
+	Id            string    `json:"id"`
+	DestinationId string    `json:"destination_id"`
+	From          time.Time `json:"from"`
+	To            time.Time `json:"to"`
+	Config        string    `json:"config"`
+	Status        string    `json:"status"`
+	Files         int       `json:"files"`
+	ReplayedFiles int       `json:"replayed_files"`
+	Events        int       `json:"events"`
+	Skipped       int       `json:"skipped"`
+	Errors        []string  `json:"errors,omitempty"`
+	StartedAt     time.Time `json:"started_at"`
+	FinishedAt    time.Time `json:"finished_at"`
+	//replayed events count per config version (as_of config only)
+	Versions map[string]int `json:"versions,omitempty"`
+}
+
+//Service re-ingests archived raw events (original unprocessed events log files) through the current destination
+//configuration (mapping, enrichment, table name template etc.): after fixing mappings or adding a new destination.
+//Batch destinations store selected events from each archived file as one file. Stream destinations get events into the queue.
+//In as_of config mode events are processed with the destination processing configuration version (see configversions)
+//which was active at their _timestamp: events of previous versions are stored by temporary batch mode storages.
+//Events are replayed as is: replaying into a destination which already has these events produces duplicates
+type Service struct {
+	sync.RWMutex
+
+	archive            *logfiles.Archive
+	fileMask           string
+	destinationService *destinations.Service
+
+	tasks   []*Task
+	running map[string]bool
+}
+
+//only for tests
+func NewTestService() *Service {
+	return &Service{running: map[string]bool{}}
+}
+
+func NewService(archive *logfiles.Archive, serverName string, destinationService *destinations.Service) *Service {
+	return &Service{
+		archive:            archive,
+		fileMask:           serverName + eventsFileMaskPostfix,
+		destinationService: destinationService,
+		running:            map[string]bool{},
+	}
+}
+
+//Replay start a replay task of archived events with _timestamp in [from, to) (zero values mean unbounded) with
+//current (default) or as_of config. Only one task per destination can be run at the same time
+func (s *Service) Replay(destinationId string, from, to time.Time, config string) (*Task, error) {
+	if s.archive == nil {
+		return nil, errors.New("Raw events archive isn't configured. Please configure log.archive.path")
+	}
+	if destinationId == "" {
+		return nil, errors.New("destination_id is required parameter")
+	}
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		return nil, errors.New("'from' must be before 'to'")
+	}
+	if config == "" {
+		config = CurrentConfig
+	}
+	if config != CurrentConfig && config != AsOfConfig {
+		return nil, fmt.Errorf("Unknown config: %s. Available: [%s, %s]", config, CurrentConfig, AsOfConfig)
+	}
+	if _, ok := s.destinationService.GetStorageById(destinationId); !ok {
+		return nil, fmt.Errorf("Destination [%s] wasn't found", destinationId)
+	}
+
+	s.Lock()
+	if s.running[destinationId] {
+		s.Unlock()
+		return nil, fmt.Errorf("Destination [%s] is being replayed", destinationId)
+	}
+	task := &Task{
+		Id:            uuid.New(),
+		DestinationId: destinationId,
+		From:          from,
+		To:            to,
+		Config:        config,
+		Status:        StatusRunning,
+		StartedAt:     time.Now().UTC(),
+	}
+	s.running[destinationId] = true
+	s.tasks = append(s.tasks, task)
+	s.evict()
+	taskCopy := *task
+	s.Unlock()
+
+	logging.Infof("[%s] Replay task [%s] of archived events from [%s] to [%s] with %s config has been started", destinationId, task.Id, from, to, config)
+	safego.Run(func() {
+		finished := false
+		defer func() {
+			if !finished {
+				s.finish(task, errors.New("Replay has been interrupted by panic"))
+			}
+		}()
+
+		err := s.run(task)
+		finished = true
+		s.finish(task, err)
+	})
+
+	return &taskCopy, nil
+}
+
+//Get return a copy of the task
+func (s *Service) Get(id string) (*Task, bool) {
+	s.RLock()
+	defer s.RUnlock()
+
+	for _, task := range s.tasks {
+		if task.Id == id {
+			return copyTask(task), true
+		}
+	}
+	return nil, false
+}
+
+//Tasks return copies of tasks (the newest first) filtered by destination ids if the filter isn't empty
+func (s *Service) Tasks(destinationsFilter map[string]bool) []*Task {
+	s.RLock()
+	defer s.RUnlock()
+
+	tasks := []*Task{}
+	for i := len(s.tasks) - 1; i >= 0; i-- {
+		task := s.tasks[i]
+		if len(destinationsFilter) > 0 && !destinationsFilter[task.DestinationId] {
+			continue
+		}
+		tasks = append(tasks, copyTask(task))
+	}
+	return tasks
+}
+
+func (s *Service) run(task *Task) error {
+	files, err := s.archive.Files(s.fileMask, task.From)
+	if err != nil {
+		return fmt.Errorf("Error finding archived files: %v", err)
+	}
+
+	s.update(task, func() { task.Files = len(files) })
+
+	//temporary storages of previous config versions
+	versionStorages := map[int]events.StorageProxy{}
+	defer func() {
+		for version, storageProxy := range versionStorages {
+			if err := storageProxy.Close(); err != nil {
+				logging.Errorf("[%s] Error closing config version %d storage: %v", task.DestinationId, version, err)
+			}
+		}
+	}()
+
+	for _, filePath := range files {
+		fileName := filepath.Base(filePath)
+		regexResult := logging.TokenIdExtractRegexp.FindStringSubmatch(fileName)
+		if len(regexResult) != 2 {
+			s.addError(task, fmt.Sprintf("Error processing file %s: Malformed name", fileName))
+			continue
+		}
+
+		//destination doesn't consume events of the token
+		tokenId := regexResult[1]
+		if !s.destinationService.GetDestinationIds(tokenId)[task.DestinationId] {
+			continue
+		}
+
+		payload, err := logging.ReadLogFile(filePath)
+		if err == nil {
+			payload, err = encryption.DecryptLines(payload)
+		}
+		if err != nil {
+			s.addError(task, fmt.Sprintf("Error reading file %s: %v", fileName, err))
+			continue
+		}
+
+		facts, selected, skipped := selectEvents(payload, task.From, task.To)
+		s.update(task, func() { task.Skipped += skipped })
+		if len(facts) == 0 {
+			continue
+		}
+
+		if task.Config == AsOfConfig {
+			err = s.replayAsOf(task, tokenId, fileName, facts, versionStorages)
+		} else {
+			err = s.replayFile(task, tokenId, fileName, facts, selected)
+		}
+		if err != nil {
+			logging.Errorf("[%s] Error replaying file %s: %v", task.DestinationId, fileName, err)
+			s.addError(task, fmt.Sprintf("Error replaying file %s: %v", fileName, err))
+			continue
+		}
+
+		s.update(task, func() {
+			task.ReplayedFiles++
+			task.Events += len(facts)
+		})
+	}
+
+	return nil
+}
+
+//replayFile put events into the stream destination queue or store selected lines as one file in the batch destination
+func (s *Service) replayFile(task *Task, tokenId, fileName string, facts []events.Fact, selected []byte) error {
+	if eventQueue, ok := s.destinationService.GetEventQueue(task.DestinationId); ok {
+		for _, fact := range facts {
+			//backpressure: wait until the destination handles queued events
+			for eventQueue.IsFull() {
+				time.Sleep(queueFullRetryTimeout)
+			}
+			if err := eventQueue.ConsumeWithError(fact, tokenId); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	storageProxy, ok := s.destinationService.GetStorageById(task.DestinationId)
+	if !ok {
+		return fmt.Errorf("Destination [%s] wasn't found", task.DestinationId)
+	}
+	storage, ok := storageProxy.Get()
+	if !ok {
+		return fmt.Errorf("Destination [%s] hasn't been initialized yet", task.DestinationId)
+	}
+
+	//unique file name: the same file might have been already loaded (see ledger)
+	rowsCount, err := storage.StoreWithParseFunc(replayIdentifier+"-"+task.Id+"-"+fileName, selected, parsers.ParseJson)
+	if err != nil {
+		metrics.ErrorTokenEvents(replayIdentifier, storage.Name(), rowsCount)
+		return err
+	}
+
+	metrics.SuccessTokenEvents(replayIdentifier, storage.Name(), rowsCount)
+	return nil
+}
+
+//replayAsOf replay events groups by config versions which were active at their _timestamp: events of the current
+//version and events which are older than the first recorded version are replayed with the current config
+func (s *Service) replayAsOf(task *Task, tokenId, fileName string, facts []events.Fact, versionStorages map[int]events.StorageProxy) error {
+	latest, _ := configversions.Instance.Latest(task.DestinationId)
+	for _, group := range groupByVersion(task.DestinationId, facts) {
+		var err error
+		key := unknownVersion
+		if group.version == nil || (latest != nil && group.version.Version == latest.Version) {
+			if group.version != nil {
+				key = versionKey(group.version)
+			}
+			err = s.replayFile(task, tokenId, fileName, group.facts, serialize(group.facts))
+		} else {
+			key = versionKey(group.version)
+			err = s.replayVersion(task, group, fileName, versionStorages)
+		}
+		if err != nil {
+			return err
+		}
+
+		s.update(task, func() {
+			if task.Versions == nil {
+				task.Versions = map[string]int{}
+			}
+			task.Versions[key] += len(group.facts)
+		})
+	}
+
+	return nil
+}
+
+//replayVersion store events with the config version storage (it is created on the first use)
+func (s *Service) replayVersion(task *Task, group *versionGroup, fileName string, versionStorages map[int]events.StorageProxy) error {
+	storageProxy, ok := versionStorages[group.version.Version]
+	if !ok {
+		var err error
+		storageProxy, err = s.destinationService.CreateVersionStorage(task.DestinationId, group.version)
+		if err != nil {
+			return fmt.Errorf("Error creating config version %d storage: %v", group.version.Version, err)
+		}
+		versionStorages[group.version.Version] = storageProxy
+	}
+
+	storage, err := waitStorage(storageProxy, storageInitTimeout)
+	if err != nil {
+		return fmt.Errorf("Config version %d storage: %v", group.version.Version, err)
+	}
+
+	rowsCount, err := storage.StoreWithParseFunc(fmt.Sprintf("%s-%s-%s-%s", replayIdentifier, task.Id, versionKey(group.version), fileName), serialize(group.facts), parsers.ParseJson)
+	if err != nil {
+		metrics.ErrorTokenEvents(replayIdentifier, storage.Name(), rowsCount)
+		return err
+	}
+
+	metrics.SuccessTokenEvents(replayIdentifier, storage.Name(), rowsCount)
+	return nil
+}
+
+func (s *Service) finish(task *Task, err error) {
+	s.Lock()
+	defer s.Unlock()
+
+	task.FinishedAt = time.Now().UTC()
+	if err != nil {
+		task.Status = StatusFailed
+		task.Errors = append(task.Errors, err.Error())
+	} else {
+		task.Status = StatusComplete
+	}
+	delete(s.running, task.DestinationId)
+
+	logging.Infof("[%s] Replay task [%s] has been finished with status [%s]: replayed files: %d of %d, events: %d, skipped: %d, errors: %d",
+		task.DestinationId, task.Id, task.Status, task.ReplayedFiles, task.Files, task.Events, task.Skipped, len(task.Errors))
+}
+
+func (s *Service) update(task *Task, f func()) {
+	s.Lock()
+	defer s.Unlock()
+
+	f()
+}
+
+func (s *Service) addError(task *Task, msg string) {
+	s.update(task, func() { task.Errors = append(task.Errors, msg) })
+}
+
+//evict remove the oldest finished tasks over history size
+//method must be called with lock
+func (s *Service) evict() {
+	for len(s.tasks) > maxTasksHistory {
+		evicted := false
+		for i, task := range s.tasks {
+			if task.Status != StatusRunning {
+				s.tasks = append(s.tasks[:i], s.tasks[i+1:]...)
+				evicted = true
+				break
+			}
+		}
+		if !evicted {
+			return
+		}
+	}
+}
+
+//selectEvents return events with _timestamp in [from, to), their lines and skipped (out of range or malformed) events count
+//zero from and to mean unbounded
+func selectEvents(payload []byte, from, to time.Time) ([]events.Fact, []byte, int) {
+	var facts []events.Fact
+	selected := &bytes.Buffer{}
+	skipped := 0
+
+	reader := bufio.NewReaderSize(bytes.NewReader(payload), 64*1024)
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			fact, err := parsers.ParseJson(line)
+			if err != nil || !inRange(fact, from, to) {
+				skipped++
+			} else {
+				facts = append(facts, fact)
+				selected.Write(line)
+				selected.WriteByte('\n')
+			}
+		}
+
+		if readErr != nil {
+			if readErr != io.EOF {
+				logging.Errorf("Error reading archived file line: %v", readErr)
+			}
+			break
+		}
+	}
+
+	return facts, selected.Bytes(), skipped
+}
+
+//versionGroup is events which have been received while the config version was active
+//version is nil if events are older than the first recorded version or don't have _timestamp
+type versionGroup struct {
+	version *configversions.Version
+	facts   []events.Fact
+}
+
+//groupByVersion return events groups by config versions which were active at events _timestamp (in order of first occurrence)
+func groupByVersion(destinationId string, facts []events.Fact) []*versionGroup {
+	var groups []*versionGroup
+	byVersion := map[int]*versionGroup{}
+	for _, fact := range facts {
+		var version *configversions.Version
+		if t, ok := eventTime(fact); ok {
+			version, _ = configversions.Instance.AsOf(destinationId, t)
+		}
+
+		number := 0
+		if version != nil {
+			number = version.Version
+		}
+		group, ok := byVersion[number]
+		if !ok {
+			group = &versionGroup{version: version}
+			byVersion[number] = group
+			groups = append(groups, group)
+		}
+		group.facts = append(group.facts, fact)
+	}
+
+	return groups
+}
+
+//waitStorage return the storage when it has been initialized
+func waitStorage(storageProxy events.StorageProxy, timeout time.Duration) (events.Storage, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		if storage, ok := storageProxy.Get(); ok {
+			return storage, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("Storage hasn't been initialized in %s", timeout)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func serialize(facts []events.Fact) []byte {
+	buf := &bytes.Buffer{}
+	for _, fact := range facts {
+		buf.WriteString(fact.Serialize())
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+func versionKey(version *configversions.Version) string {
+	return fmt.Sprintf("v%d", version.Version)
+}
+
+//inRange return true if event _timestamp is in [from, to)
+//events without _timestamp are in range only if the range is unbounded
+func inRange(fact events.Fact, from, to time.Time) bool {
+	if from.IsZero() && to.IsZero() {
+		return true
+	}
+
+	t, ok := eventTime(fact)
+	if !ok {
+		return false
+	}
+
+	if !from.IsZero() && t.Before(from) {
+		return false
+	}
+	if !to.IsZero() && !t.Before(to) {
+		return false
+	}
+	return true
+}
+
+//eventTime return parsed event _timestamp
+func eventTime(fact events.Fact) (time.Time, bool) {
+	ts, _ := fact[timestamp.Key].(string)
+	//RFC3339Nano accepts any fraction digits count (timestamp.Layout has exactly 6)
+	t, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+func copyTask(task *Task) *Task {
+	taskCopy := *task
+	taskCopy.Errors = append([]string{}, task.Errors...)
+	if task.Versions != nil {
+		taskCopy.Versions = make(map[string]int, len(task.Versions))
+		for version, count := range task.Versions {
+			taskCopy.Versions[version] = count
+		}
+	}
+	return &taskCopy
+}
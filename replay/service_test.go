@@ -0,0 +1,118 @@
+package replay
+
+import (
+	"encoding/json"
+	"github.com/jitsucom/eventnative/configversions"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/storages"
+	"github.com/jitsucom/eventnative/timestamp"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestSelectEvents(t *testing.T) {
+	payload := []byte(`{"id":1,"_timestamp":"2020-06-16T23:00:00.000000Z"}
+{"id":2,"_timestamp":"2020-06-17T00:00:00.000000Z"}
+
+{"id":3,"_timestamp":"2020-06-17T12:30:00.123Z"}
+{"id":4}
+malformed
+{"id":5,"_timestamp":"2020-06-18T00:00:00.000000Z"}`)
+
+	tests := []struct {
+		name             string
+		from             time.Time
+		to               time.Time
+		expectedIds      []json.Number
+		expectedSelected string
+		expectedSkipped  int
+	}{
+		{
+			"unbounded",
+			time.Time{},
+			time.Time{},
+			[]json.Number{"1", "2", "3", "4", "5"},
+			`{"id":1,"_timestamp":"2020-06-16T23:00:00.000000Z"}
+{"id":2,"_timestamp":"2020-06-17T00:00:00.000000Z"}
+{"id":3,"_timestamp":"2020-06-17T12:30:00.123Z"}
+{"id":4}
+{"id":5,"_timestamp":"2020-06-18T00:00:00.000000Z"}
+`,
+			1,
+		},
+		{
+			"one day",
+			time.Date(2020, 6, 17, 0, 0, 0, 0, time.UTC),
+			time.Date(2020, 6, 18, 0, 0, 0, 0, time.UTC),
+			[]json.Number{"2", "3"},
+			`{"id":2,"_timestamp":"2020-06-17T00:00:00.000000Z"}
+{"id":3,"_timestamp":"2020-06-17T12:30:00.123Z"}
+`,
+			4,
+		},
+		{
+			"from only",
+			time.Date(2020, 6, 17, 12, 0, 0, 0, time.UTC),
+			time.Time{},
+			[]json.Number{"3", "5"},
+			`{"id":3,"_timestamp":"2020-06-17T12:30:00.123Z"}
+{"id":5,"_timestamp":"2020-06-18T00:00:00.000000Z"}
+`,
+			4,
+		},
+		{
+			"empty range",
+			time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+			time.Time{},
+			nil,
+			"",
+			6,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			facts, selected, skipped := selectEvents(payload, tt.from, tt.to)
+
+			var ids []json.Number
+			for _, fact := range facts {
+				ids = append(ids, fact["id"].(json.Number))
+			}
+			require.Equal(t, tt.expectedIds, ids)
+			require.Equal(t, tt.expectedSelected, string(selected))
+			require.Equal(t, tt.expectedSkipped, skipped)
+		})
+	}
+}
+
+func TestGroupByVersion(t *testing.T) {
+	configversions.Instance = configversions.NewInMemory()
+	defer func() { configversions.Instance = configversions.NewInMemory() }()
+
+	first, _ := configversions.Instance.Record("dst1", storages.DestinationConfig{Filter: "first"})
+	time.Sleep(10 * time.Millisecond)
+	second, _ := configversions.Instance.Record("dst1", storages.DestinationConfig{Filter: "second"})
+
+	at := func(id int, t time.Time) events.Fact {
+		return events.Fact{"id": id, timestamp.Key: t.Format(timestamp.Layout)}
+	}
+	facts := []events.Fact{
+		at(1, second.ActiveFrom.Add(time.Millisecond)),
+		at(2, first.ActiveFrom.Add(-time.Hour)),
+		at(3, first.ActiveFrom.Add(time.Millisecond)),
+		{"id": 4},
+		at(5, second.ActiveFrom.Add(time.Hour)),
+	}
+
+	groups := groupByVersion("dst1", facts)
+	require.Len(t, groups, 3)
+
+	require.Equal(t, 2, groups[0].version.Version)
+	require.Equal(t, []events.Fact{facts[0], facts[4]}, groups[0].facts)
+	//before the first version and without _timestamp
+	require.Nil(t, groups[1].version)
+	require.Equal(t, []events.Fact{facts[1], facts[3]}, groups[1].facts)
+	require.Equal(t, 1, groups[2].version.Version)
+	require.Equal(t, "first", groups[2].version.Processing.Filter)
+	require.Equal(t, []events.Fact{facts[2]}, groups[2].facts)
+}
@@ -0,0 +1,298 @@
+package reports
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	//GlobalWorkspace is a workspace id of tokens and destinations which don't belong to any workspace
+	GlobalWorkspace = "-"
+
+	DefaultRetentionHours = 24 * 8
+
+	timestampPrefix = `"_timestamp":"`
+)
+
+//latency histogram upper bounds. The last bucket is unbounded
+var latencyBounds = []time.Duration{
+	100 * time.Millisecond, 250 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 2500 * time.Millisecond, 5 * time.Second, 10 * time.Second, 30 * time.Second,
+	time.Minute, 2 * time.Minute, 5 * time.Minute, 10 * time.Minute, 30 * time.Minute,
+	time.Hour, 3 * time.Hour, 6 * time.Hour, 12 * time.Hour, 24 * time.Hour,
+}
+
+//Instance is a singleton collector. Workspaces are resolved as GlobalWorkspace until Init is called
+var Instance = NewCollector(DefaultRetentionHours, nil, nil)
+
+//hourly usage statistics of one workspace
+type bucket struct {
+	ingested  int64
+	delivered int64
+	failed    int64
+	fallback  int64
+
+	latencies  []int64
+	maxLatency time.Duration
+}
+
+//Collector keeps hourly per workspace statistics in memory (per server): ingested, delivered, failed,
+//fallback events and end-to-end (ingestion -> destination) latency histogram
+type Collector struct {
+	sync.Mutex
+
+	retention            time.Duration
+	tokenWorkspace       func(tokenId string) string
+	destinationWorkspace func(destinationId string) string
+	now                  func() time.Time
+
+	//workspace id -> hour start unix -> bucket
+	buckets map[string]map[int64]*bucket
+}
+
+//Init replace Instance with collector with workspace resolvers (return empty string for global objects)
+func Init(retentionHours int, tokenWorkspace, destinationWorkspace func(string) string) {
+	Instance = NewCollector(retentionHours, tokenWorkspace, destinationWorkspace)
+}
+
+func NewCollector(retentionHours int, tokenWorkspace, destinationWorkspace func(string) string) *Collector {
+	if retentionHours <= 0 {
+		retentionHours = DefaultRetentionHours
+	}
+	return &Collector{
+		retention:            time.Duration(retentionHours) * time.Hour,
+		tokenWorkspace:       tokenWorkspace,
+		destinationWorkspace: destinationWorkspace,
+		now:                  time.Now,
+		buckets:              map[string]map[int64]*bucket{},
+	}
+}
+
+//Ingested increment accepted events counter of the token workspace
+func (c *Collector) Ingested(tokenId string, value int) {
+	c.update(resolve(c.tokenWorkspace, tokenId), func(b *bucket) { b.ingested += int64(value) })
+}
+
+//Delivered increment stored events counter of the destination workspace
+func (c *Collector) Delivered(destinationId string, value int) {
+	c.update(resolve(c.destinationWorkspace, destinationId), func(b *bucket) { b.delivered += int64(value) })
+}
+
+//Failed increment failed events counter of the destination workspace
+func (c *Collector) Failed(destinationId string, value int) {
+	c.update(resolve(c.destinationWorkspace, destinationId), func(b *bucket) { b.failed += int64(value) })
+}
+
+//Fallback increment events counter which were written into the destination fallback
+func (c *Collector) Fallback(destinationId string, value int) {
+	c.update(resolve(c.destinationWorkspace, destinationId), func(b *bucket) { b.fallback += int64(value) })
+}
+
+//Latency put end-to-end latency of the event (ingestion time - now) into the destination workspace histogram
+func (c *Collector) Latency(destinationId string, ingestedAt time.Time) {
+	if ingestedAt.IsZero() {
+		return
+	}
+	latency := c.now().Sub(ingestedAt)
+	c.update(resolve(c.destinationWorkspace, destinationId), func(b *bucket) { b.observe(latency) })
+}
+
+//EventLatency put end-to-end latency of the event with _timestamp field (time.Time or string)
+func (c *Collector) EventLatency(destinationId string, ts interface{}) {
+	c.Latency(destinationId, parseTimestamp(ts))
+}
+
+//BatchLatency put end-to-end latencies of all events in the json lines payload
+//_timestamp values are found without unmarshalling
+func (c *Collector) BatchLatency(destinationId string, payload []byte) {
+	now := c.now()
+	var latencies []time.Duration
+	for len(payload) > 0 {
+		line := payload
+		if i := bytes.IndexByte(payload, '\n'); i >= 0 {
+			line, payload = payload[:i], payload[i+1:]
+		} else {
+			payload = nil
+		}
+
+		start := bytes.Index(line, []byte(timestampPrefix))
+		if start < 0 {
+			continue
+		}
+		value := line[start+len(timestampPrefix):]
+		end := bytes.IndexByte(value, '"')
+		if end < 0 {
+			continue
+		}
+		if ingestedAt := parseTimestamp(string(value[:end])); !ingestedAt.IsZero() {
+			latencies = append(latencies, now.Sub(ingestedAt))
+		}
+	}
+
+	if len(latencies) == 0 {
+		return
+	}
+	c.update(resolve(c.destinationWorkspace, destinationId), func(b *bucket) {
+		for _, latency := range latencies {
+			b.observe(latency)
+		}
+	})
+}
+
+//Workspaces return ids of workspaces with statistics
+func (c *Collector) Workspaces() []string {
+	c.Lock()
+	defer c.Unlock()
+
+	ids := make([]string, 0, len(c.buckets))
+	for id := range c.buckets {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+//Report return aggregated statistics of the workspace for hours which start in [from, to)
+func (c *Collector) Report(workspaceId string, from, to time.Time) *Report {
+	report := &Report{WorkspaceId: workspaceId, From: from.UTC(), To: to.UTC()}
+	total := &bucket{latencies: make([]int64, len(latencyBounds)+1)}
+
+	c.Lock()
+	for hour, b := range c.buckets[workspaceId] {
+		hourStart := time.Unix(hour, 0)
+		if hourStart.Before(from.Truncate(time.Hour)) || !hourStart.Before(to) {
+			continue
+		}
+		total.ingested += b.ingested
+		total.delivered += b.delivered
+		total.failed += b.failed
+		total.fallback += b.fallback
+		for i, count := range b.latencies {
+			total.latencies[i] += count
+		}
+		if b.maxLatency > total.maxLatency {
+			total.maxLatency = b.maxLatency
+		}
+	}
+	c.Unlock()
+
+	report.Ingested = total.ingested
+	report.Delivered = total.delivered
+	report.Failed = total.failed
+	report.Fallback = total.fallback
+	if total.delivered+total.failed > 0 {
+		report.DeliveryRate = float64(total.delivered) / float64(total.delivered+total.failed)
+	}
+	report.LatencyP95Ms = total.percentile(0.95).Milliseconds()
+	return report
+}
+
+func (c *Collector) update(workspaceId string, f func(b *bucket)) {
+	now := c.now()
+	hour := now.Truncate(time.Hour).Unix()
+
+	c.Lock()
+	defer c.Unlock()
+
+	hours, ok := c.buckets[workspaceId]
+	if !ok {
+		hours = map[int64]*bucket{}
+		c.buckets[workspaceId] = hours
+	}
+	b, ok := hours[hour]
+	if !ok {
+		b = &bucket{latencies: make([]int64, len(latencyBounds)+1)}
+		hours[hour] = b
+
+		//new hour bucket: remove expired ones
+		expired := now.Add(-c.retention).Unix()
+		for h := range hours {
+			if h < expired {
+				delete(hours, h)
+			}
+		}
+	}
+
+	f(b)
+}
+
+func (b *bucket) observe(latency time.Duration) {
+	if latency < 0 {
+		latency = 0
+	}
+	i := sort.Search(len(latencyBounds), func(i int) bool { return latency <= latencyBounds[i] })
+	b.latencies[i]++
+	if latency > b.maxLatency {
+		b.maxLatency = latency
+	}
+}
+
+//percentile return upper bound of the histogram bucket which contains the percentile (max latency for the last bucket)
+func (b *bucket) percentile(p float64) time.Duration {
+	var count int64
+	for _, c := range b.latencies {
+		count += c
+	}
+	if count == 0 {
+		return 0
+	}
+
+	rank := int64(float64(count)*p + 0.999999)
+	var cumulative int64
+	for i, c := range b.latencies {
+		cumulative += c
+		if cumulative >= rank {
+			if i < len(latencyBounds) && latencyBounds[i] < b.maxLatency {
+				return latencyBounds[i]
+			}
+			return b.maxLatency
+		}
+	}
+	return b.maxLatency
+}
+
+func resolve(resolver func(string) string, id string) string {
+	if resolver != nil {
+		if workspaceId := resolver(id); workspaceId != "" {
+			return workspaceId
+		}
+	}
+	return GlobalWorkspace
+}
+
+func parseTimestamp(ts interface{}) time.Time {
+	switch v := ts.(type) {
+	case time.Time:
+		return v
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return time.Time{}
+		}
+		return t
+	default:
+		return time.Time{}
+	}
+}
+
+//fallbackCounter counts lines (events) which are written into the destination fallback log
+type fallbackCounter struct {
+	io.WriteCloser
+	destinationId string
+}
+
+//NewFallbackCounter return writer which increments Instance fallback counter on every write (one write is one event)
+func NewFallbackCounter(destinationId string, writer io.WriteCloser) io.WriteCloser {
+	return &fallbackCounter{WriteCloser: writer, destinationId: destinationId}
+}
+
+func (fc *fallbackCounter) Write(p []byte) (int, error) {
+	n, err := fc.WriteCloser.Write(p)
+	if err == nil {
+		Instance.Fallback(fc.destinationId, 1)
+	}
+	return n, err
+}
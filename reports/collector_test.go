@@ -0,0 +1,178 @@
+package reports
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestCollectorReport(t *testing.T) {
+	now := time.Date(2020, 11, 10, 12, 30, 0, 0, time.UTC)
+	c := NewCollector(2, func(tokenId string) string {
+		if tokenId == "ws1.token" {
+			return "ws1"
+		}
+		return ""
+	}, func(destinationId string) string {
+		if destinationId == "ws1.pg" {
+			return "ws1"
+		}
+		return ""
+	})
+	c.now = func() time.Time { return now }
+
+	c.Ingested("ws1.token", 10)
+	c.Ingested("token", 3)
+	c.Delivered("ws1.pg", 8)
+	c.Failed("ws1.pg", 2)
+	c.Fallback("ws1.pg", 2)
+	c.Delivered("pg", 3)
+	for i := 0; i < 19; i++ {
+		c.Latency("ws1.pg", now.Add(-200*time.Millisecond))
+	}
+	c.Latency("ws1.pg", now.Add(-time.Minute))
+
+	require.Equal(t, []string{GlobalWorkspace, "ws1"}, c.Workspaces())
+
+	report := c.Report("ws1", now.Add(-time.Hour), now)
+	require.Equal(t, int64(10), report.Ingested)
+	require.Equal(t, int64(8), report.Delivered)
+	require.Equal(t, int64(2), report.Failed)
+	require.Equal(t, int64(2), report.Fallback)
+	require.Equal(t, 0.8, report.DeliveryRate)
+	require.Equal(t, int64(250), report.LatencyP95Ms)
+
+	global := c.Report(GlobalWorkspace, now.Add(-time.Hour), now)
+	require.Equal(t, int64(3), global.Ingested)
+	require.Equal(t, int64(3), global.Delivered)
+	require.Equal(t, 1.0, global.DeliveryRate)
+
+	//out of the period
+	require.Equal(t, int64(0), c.Report("ws1", now.Add(time.Hour), now.Add(2*time.Hour)).Ingested)
+
+	//expired buckets are removed on the new hour bucket creation
+	now = now.Add(3 * time.Hour)
+	c.Ingested("ws1.token", 1)
+	report = c.Report("ws1", now.Add(-10*time.Hour), now.Add(time.Hour))
+	require.Equal(t, int64(1), report.Ingested)
+	require.Equal(t, int64(0), report.Delivered)
+}
+
+func TestBatchLatency(t *testing.T) {
+	now := time.Date(2020, 11, 10, 12, 30, 0, 0, time.UTC)
+	c := NewCollector(0, nil, nil)
+	c.now = func() time.Time { return now }
+
+	payload := []byte(`{"event_id":"1","_timestamp":"2020-11-10T12:29:59.000000Z"}
+{"event_id":"2"}
+{"event_id":"3","_timestamp":"malformed"}
+{"event_id":"4","_timestamp":"2020-11-10T12:25:00.000000Z"}`)
+	c.BatchLatency("pg", payload)
+
+	b := c.buckets[GlobalWorkspace][now.Truncate(time.Hour).Unix()]
+	require.NotNil(t, b)
+
+	var count int64
+	for _, v := range b.latencies {
+		count += v
+	}
+	require.Equal(t, int64(2), count)
+	require.Equal(t, 5*time.Minute, b.maxLatency)
+	require.Equal(t, 5*time.Minute, b.percentile(0.95))
+}
+
+func TestPercentile(t *testing.T) {
+	tests := []struct {
+		name      string
+		latencies []time.Duration
+		expected  time.Duration
+	}{
+		{
+			"empty",
+			nil,
+			0,
+		},
+		{
+			"max latency less than bucket bound",
+			[]time.Duration{10 * time.Millisecond, 20 * time.Millisecond},
+			20 * time.Millisecond,
+		},
+		{
+			"unbounded bucket",
+			[]time.Duration{48 * time.Hour},
+			48 * time.Hour,
+		},
+		{
+			"p95 bucket bound",
+			[]time.Duration{time.Second, time.Second, time.Second, time.Second, time.Second, time.Second, time.Second, time.Second, time.Second, time.Second,
+				time.Second, time.Second, time.Second, time.Second, time.Second, time.Second, time.Second, time.Second, 4 * time.Second, time.Hour},
+			5 * time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &bucket{latencies: make([]int64, len(latencyBounds)+1)}
+			for _, latency := range tt.latencies {
+				b.observe(latency)
+			}
+			require.Equal(t, tt.expected, b.percentile(0.95))
+		})
+	}
+}
+
+func TestCheckSla(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   *SlaConfig
+		report   *Report
+		expected *Sla
+	}{
+		{
+			"not configured",
+			nil,
+			&Report{},
+			nil,
+		},
+		{
+			"met",
+			&SlaConfig{LatencyP95Ms: 1000, DeliveryRate: 0.99},
+			&Report{LatencyP95Ms: 500, Delivered: 100, DeliveryRate: 1},
+			&Sla{SlaConfig: SlaConfig{LatencyP95Ms: 1000, DeliveryRate: 0.99}, Met: true},
+		},
+		{
+			"nothing was delivered",
+			&SlaConfig{DeliveryRate: 0.99},
+			&Report{},
+			&Sla{SlaConfig: SlaConfig{DeliveryRate: 0.99}, Met: true},
+		},
+		{
+			"violated",
+			&SlaConfig{LatencyP95Ms: 1000, DeliveryRate: 0.99},
+			&Report{LatencyP95Ms: 2500, Delivered: 8, Failed: 2, DeliveryRate: 0.8},
+			&Sla{SlaConfig: SlaConfig{LatencyP95Ms: 1000, DeliveryRate: 0.99}, Met: false,
+				Violations: []string{"p95 latency 2500ms > 1000ms", "delivery rate 0.8000 < 0.9900"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, checkSla(tt.config, tt.report))
+		})
+	}
+}
+
+func TestLastPeriod(t *testing.T) {
+	//Wednesday
+	now := time.Date(2020, 11, 11, 12, 30, 0, 0, time.UTC)
+
+	from, to := lastPeriod(HourlyPeriod, now)
+	require.Equal(t, time.Date(2020, 11, 11, 11, 0, 0, 0, time.UTC), from)
+	require.Equal(t, time.Date(2020, 11, 11, 12, 0, 0, 0, time.UTC), to)
+
+	from, to = lastPeriod(DailyPeriod, now)
+	require.Equal(t, time.Date(2020, 11, 10, 0, 0, 0, 0, time.UTC), from)
+	require.Equal(t, time.Date(2020, 11, 11, 0, 0, 0, 0, time.UTC), to)
+
+	from, to = lastPeriod(WeeklyPeriod, now)
+	require.Equal(t, time.Date(2020, 11, 2, 0, 0, 0, 0, time.UTC), from)
+	require.Equal(t, time.Date(2020, 11, 9, 0, 0, 0, 0, time.UTC), to)
+}
@@ -0,0 +1,263 @@
+package reports
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/safego"
+	"io/ioutil"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+const (
+	HourlyPeriod = "hourly"
+	DailyPeriod  = "daily"
+	WeeklyPeriod = "weekly"
+)
+
+//Report is a usage and delivery SLA summary of the workspace over the period
+type Report struct {
+	ServerName   string    `json:"server_name,omitempty"`
+	WorkspaceId  string    `json:"workspace_id"`
+	From         time.Time `json:"from"`
+	To           time.Time `json:"to"`
+	Ingested     int64     `json:"ingested"`
+	Delivered    int64     `json:"delivered"`
+	Failed       int64     `json:"failed"`
+	Fallback     int64     `json:"fallback"`
+	DeliveryRate float64   `json:"delivery_rate"`
+	LatencyP95Ms int64     `json:"latency_p95_ms"`
+	Sla          *Sla      `json:"sla,omitempty"`
+}
+
+//SlaConfig is a delivery SLA targets. Zero values aren't checked
+type SlaConfig struct {
+	LatencyP95Ms int64   `mapstructure:"latency_p95_ms" json:"latency_p95_ms,omitempty"`
+	DeliveryRate float64 `mapstructure:"delivery_rate" json:"delivery_rate,omitempty"`
+}
+
+//Sla is a result of SLA targets checking
+type Sla struct {
+	SlaConfig
+	Met        bool     `json:"met"`
+	Violations []string `json:"violations,omitempty"`
+}
+
+//EmailConfig is a SMTP configuration for sending scheduled reports
+type EmailConfig struct {
+	Host     string   `mapstructure:"host"`
+	Port     int      `mapstructure:"port"`
+	Username string   `mapstructure:"username"`
+	Password string   `mapstructure:"password"`
+	From     string   `mapstructure:"from"`
+	To       []string `mapstructure:"to"`
+}
+
+//Config is a reports configuration
+type Config struct {
+	RetentionHours int          `mapstructure:"retention_hours"`
+	Schedule       string       `mapstructure:"schedule"`
+	Webhook        string       `mapstructure:"webhook"`
+	Email          *EmailConfig `mapstructure:"email"`
+	Sla            *SlaConfig   `mapstructure:"sla"`
+}
+
+//Service builds reports from Instance collector and sends scheduled ones
+type Service struct {
+	serverName string
+	config     *Config
+	client     *http.Client
+	closed     bool
+}
+
+func NewTestService() *Service {
+	return &Service{config: &Config{}}
+}
+
+//NewService return Service and start scheduled reports sending if schedule is configured
+func NewService(config *Config, serverName string) (*Service, error) {
+	if config == nil {
+		config = &Config{}
+	}
+
+	switch config.Schedule {
+	case "", HourlyPeriod, DailyPeriod, WeeklyPeriod:
+	default:
+		return nil, fmt.Errorf("Unknown reports schedule: %s. Available: [%s, %s, %s]", config.Schedule, HourlyPeriod, DailyPeriod, WeeklyPeriod)
+	}
+	if config.Schedule != "" && config.Webhook == "" && config.Email == nil {
+		return nil, fmt.Errorf("Reports schedule requires webhook or email configuration")
+	}
+	if config.Email != nil && (config.Email.Host == "" || config.Email.From == "" || len(config.Email.To) == 0) {
+		return nil, fmt.Errorf("Reports email requires host, from and to")
+	}
+
+	service := &Service{serverName: serverName, config: config, client: &http.Client{Timeout: 30 * time.Second}}
+	if config.Schedule != "" {
+		service.startScheduler()
+	}
+	return service, nil
+}
+
+//Reports return reports of workspaces (all if workspaceId is empty) over the period
+func (s *Service) Reports(workspaceId string, from, to time.Time) []*Report {
+	var ids []string
+	if workspaceId != "" {
+		ids = []string{workspaceId}
+	} else {
+		ids = Instance.Workspaces()
+	}
+
+	reports := make([]*Report, 0, len(ids))
+	for _, id := range ids {
+		report := Instance.Report(id, from, to)
+		report.ServerName = s.serverName
+		report.Sla = checkSla(s.config.Sla, report)
+		reports = append(reports, report)
+	}
+	return reports
+}
+
+func (s *Service) Close() error {
+	s.closed = true
+	return nil
+}
+
+func (s *Service) startScheduler() {
+	safego.RunWithRestart(func() {
+		for {
+			if s.closed {
+				break
+			}
+
+			//sleep until the current period is finished
+			_, currentStart := lastPeriod(s.config.Schedule, time.Now().UTC())
+			time.Sleep(time.Until(currentStart.Add(periodDuration(s.config.Schedule))))
+			if s.closed {
+				break
+			}
+
+			from, to := lastPeriod(s.config.Schedule, time.Now().UTC())
+			s.send(s.Reports("", from, to))
+		}
+	})
+}
+
+func (s *Service) send(reports []*Report) {
+	if len(reports) == 0 {
+		return
+	}
+
+	if s.config.Webhook != "" {
+		if err := s.sendWebhook(reports); err != nil {
+			logging.Errorf("[reports] Error sending reports to webhook: %v", err)
+		}
+	}
+	if s.config.Email != nil {
+		if err := s.sendEmail(reports); err != nil {
+			logging.Errorf("[reports] Error sending reports email: %v", err)
+		}
+	}
+}
+
+func (s *Service) sendWebhook(reports []*Report) error {
+	b, err := json.Marshal(map[string]interface{}{"reports": reports})
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.client.Post(s.config.Webhook, "application/json", bytes.NewBuffer(b))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("HTTP code = %d, body: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+func (s *Service) sendEmail(reports []*Report) error {
+	config := s.config.Email
+	port := config.Port
+	if port == 0 {
+		port = 587
+	}
+
+	var auth smtp.Auth
+	if config.Username != "" {
+		auth = smtp.PlainAuth("", config.Username, config.Password, config.Host)
+	}
+
+	subject := fmt.Sprintf("EventNative [%s] usage report %s - %s", s.serverName, reports[0].From.Format(time.RFC3339), reports[0].To.Format(time.RFC3339))
+	message := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\n\r\n%s", config.From, strings.Join(config.To, ", "), subject, FormatText(reports))
+	return smtp.SendMail(fmt.Sprintf("%s:%d", config.Host, port), auth, config.From, config.To, []byte(message))
+}
+
+//FormatText return human-readable reports table
+func FormatText(reports []*Report) string {
+	var sb strings.Builder
+	for _, report := range reports {
+		sb.WriteString(fmt.Sprintf("Workspace: %s\n", report.WorkspaceId))
+		sb.WriteString(fmt.Sprintf("  ingested: %d, delivered: %d, failed: %d, fallback: %d\n", report.Ingested, report.Delivered, report.Failed, report.Fallback))
+		sb.WriteString(fmt.Sprintf("  delivery rate: %.4f, p95 latency: %dms\n", report.DeliveryRate, report.LatencyP95Ms))
+		if report.Sla != nil {
+			if report.Sla.Met {
+				sb.WriteString("  SLA: met\n")
+			} else {
+				sb.WriteString(fmt.Sprintf("  SLA: violated (%s)\n", strings.Join(report.Sla.Violations, "; ")))
+			}
+		}
+	}
+	return sb.String()
+}
+
+func checkSla(config *SlaConfig, report *Report) *Sla {
+	if config == nil || config.LatencyP95Ms == 0 && config.DeliveryRate == 0 {
+		return nil
+	}
+
+	sla := &Sla{SlaConfig: *config, Met: true}
+	if config.LatencyP95Ms > 0 && report.LatencyP95Ms > config.LatencyP95Ms {
+		sla.Violations = append(sla.Violations, fmt.Sprintf("p95 latency %dms > %dms", report.LatencyP95Ms, config.LatencyP95Ms))
+	}
+	//events weren't delivered or failed: nothing to check
+	if config.DeliveryRate > 0 && report.Delivered+report.Failed > 0 && report.DeliveryRate < config.DeliveryRate {
+		sla.Violations = append(sla.Violations, fmt.Sprintf("delivery rate %.4f < %.4f", report.DeliveryRate, config.DeliveryRate))
+	}
+	sla.Met = len(sla.Violations) == 0
+	return sla
+}
+
+//lastPeriod return the last finished period [from, to) before now
+func lastPeriod(schedule string, now time.Time) (time.Time, time.Time) {
+	var to time.Time
+	switch schedule {
+	case HourlyPeriod:
+		to = now.Truncate(time.Hour)
+	case WeeklyPeriod:
+		day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		//weeks start on Monday
+		to = day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	default:
+		to = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	return to.Add(-periodDuration(schedule)), to
+}
+
+func periodDuration(schedule string) time.Duration {
+	switch schedule {
+	case HourlyPeriod:
+		return time.Hour
+	case WeeklyPeriod:
+		return 7 * 24 * time.Hour
+	default:
+		return 24 * time.Hour
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"os"
 )
 
 const (
@@ -52,3 +53,16 @@ func LoadFromHttp(url, ifModifiedSinceValue string) ([]byte, string, error) {
 
 	return b, resp.Header.Get(lastModifiedHeader), nil
 }
+
+//SaveToFile write payload into tmp file and rename it for atomic replacing of the file which is watched by LoadFromFile
+func SaveToFile(filePath string, payload []byte) error {
+	tmpPath := filePath + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, payload, 0644); err != nil {
+		return fmt.Errorf("Error writing resource to file %s: %v", tmpPath, err)
+	}
+
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		return fmt.Errorf("Error renaming resource file %s: %v", tmpPath, err)
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package retention
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jitsucom/eventnative/adapters"
+	"github.com/jitsucom/eventnative/destinations"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/metrics"
+	"github.com/jitsucom/eventnative/safego"
+	"github.com/jitsucom/eventnative/storages"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	lockCollection = "retention"
+
+	defaultRunEveryMin = 60
+	day                = 24 * time.Hour
+)
+
+//Service periodically applies destinations data_layout.retention rules:
+//rows of the rule event types older than ttl_days are deleted,
+//rows older than archive_after_days are stored into the archive destination and deleted.
+//Rules of the destination are applied under the cluster lock: only one node applies them at the same time
+type Service struct {
+	destinationService *destinations.Service
+	monitorKeeper      storages.MonitorKeeper
+	runEvery           time.Duration
+
+	closed bool
+}
+
+//NewService return Service and start applying retention rules every runEveryMin minutes
+func NewService(destinationService *destinations.Service, monitorKeeper storages.MonitorKeeper, runEveryMin int) *Service {
+	if runEveryMin <= 0 {
+		runEveryMin = defaultRunEveryMin
+	}
+
+	service := &Service{destinationService: destinationService, monitorKeeper: monitorKeeper, runEvery: time.Duration(runEveryMin) * time.Minute}
+	service.start()
+	return service
+}
+
+func (s *Service) start() {
+	safego.RunWithRestart(func() {
+		for {
+			if s.closed {
+				break
+			}
+
+			if destinations.StatusInstance.Reloading {
+				time.Sleep(2 * time.Second)
+				continue
+			}
+
+			s.run(time.Now().UTC())
+			time.Sleep(s.runEvery)
+		}
+	})
+}
+
+//run apply retention rules of all destinations sorted by id
+func (s *Service) run(now time.Time) {
+	config := s.destinationService.GetConfig()
+	var destinationIds []string
+	for destinationId, destination := range config {
+		if destination.DataLayout != nil && len(destination.DataLayout.Retention) > 0 {
+			destinationIds = append(destinationIds, destinationId)
+		}
+	}
+	sort.Strings(destinationIds)
+
+	for _, destinationId := range destinationIds {
+		if s.closed {
+			return
+		}
+		if err := s.apply(destinationId, config[destinationId].DataLayout.Retention, now); err != nil {
+			logging.Errorf("[%s] Error applying retention rules: %v", destinationId, err)
+			metrics.RetentionError(destinationId)
+		}
+	}
+}
+
+func (s *Service) apply(destinationId string, rules []*storages.RetentionRule, now time.Time) error {
+	retainer, err := s.retainer(destinationId)
+	if err != nil {
+		return err
+	}
+
+	lock, err := s.monitorKeeper.Lock(destinationId, lockCollection)
+	if err != nil {
+		return fmt.Errorf("Error locking retention: %v", err)
+	}
+	defer s.monitorKeeper.Unlock(lock)
+
+	for _, rule := range rules {
+		condition := &adapters.ExpirationCondition{EventTypes: rule.EventTypes, ExcludedEventTypes: storages.ExcludedEventTypes(rules, rule)}
+		if rule.ArchiveDestination == "" {
+			condition.Before = now.Add(-time.Duration(rule.TTLDays) * day)
+			deleted, err := retainer.DeleteExpired(condition, nil)
+			metrics.RetentionDeletedRows(destinationId, deleted)
+			if err != nil {
+				return fmt.Errorf("Error applying rule %s: %v", rule, err)
+			}
+			continue
+		}
+
+		archive, err := s.archive(rule.ArchiveDestination)
+		if err != nil {
+			return fmt.Errorf("Error applying rule %s: %v", rule, err)
+		}
+		condition.Before = now.Add(-time.Duration(rule.ArchiveAfterDays) * day)
+		archived, err := retainer.DeleteExpired(condition, func(table string, rows []map[string]interface{}) error {
+			return store(archive, destinationId, table, rows, now)
+		})
+		metrics.RetentionArchivedRows(destinationId, archived)
+		if err != nil {
+			return fmt.Errorf("Error applying rule %s: %v", rule, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Service) retainer(destinationId string) (storages.Retainer, error) {
+	storageProxy, ok := s.destinationService.GetStorageById(destinationId)
+	if !ok {
+		return nil, fmt.Errorf("Destination [%s] wasn't found", destinationId)
+	}
+	storage, ok := storageProxy.Get()
+	if !ok {
+		return nil, fmt.Errorf("Destination [%s] hasn't been initialized yet", destinationId)
+	}
+	retainer, ok := storage.(storages.Retainer)
+	if !ok {
+		return nil, fmt.Errorf("Destination [%s] of type [%s] doesn't support retention", destinationId, storage.Type())
+	}
+	return retainer, nil
+}
+
+func (s *Service) archive(destinationId string) (events.Storage, error) {
+	storageProxy, ok := s.destinationService.GetStorageById(destinationId)
+	if !ok {
+		return nil, fmt.Errorf("Archive destination [%s] wasn't found", destinationId)
+	}
+	storage, ok := storageProxy.Get()
+	if !ok {
+		return nil, fmt.Errorf("Archive destination [%s] hasn't been initialized yet", destinationId)
+	}
+	return storage, nil
+}
+
+//store write rows into the archive destination as one JSON lines file (like uploaded events log file)
+func store(archive events.Storage, destinationId, table string, rows []map[string]interface{}, now time.Time) error {
+	lines := make([]string, 0, len(rows))
+	for _, row := range rows {
+		b, err := json.Marshal(row)
+		if err != nil {
+			return fmt.Errorf("Error marshalling row: %v", err)
+		}
+		lines = append(lines, string(b))
+	}
+
+	fileName := fmt.Sprintf("retention-%s-%s-%s.log", destinationId, table, now.Format("2006-01-02T15-04-05"))
+	stored, err := archive.Store(fileName, []byte(strings.Join(lines, "\n")))
+	if err != nil {
+		return err
+	}
+	if stored != len(rows) {
+		return fmt.Errorf("Archive destination [%s] stored %d of %d rows", archive.Name(), stored, len(rows))
+	}
+
+	logging.Infof("[%s] Retention: %d rows of [%s] table were archived into [%s]", destinationId, stored, table, archive.Name())
+	return nil
+}
+
+func (s *Service) Close() error {
+	s.closed = true
+	return nil
+}
@@ -0,0 +1,231 @@
+package retry
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/metrics"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultMaxAttempts      = 5
+	defaultInitialBackoffMs = 1000
+	defaultMaxBackoffMs     = 60000
+	defaultMultiplier       = 2
+	defaultJitter           = 0.2
+)
+
+var (
+	policies      = map[string]*Policy{}
+	policiesMutex sync.RWMutex
+
+	//network errors are retryable for all destinations
+	networkErrors = []string{"connection refused", "connection reset", "broken pipe", "i/o timeout", "eof", "no such host", "tls handshake timeout"}
+)
+
+//Config is a destination writes retry policy configuration. Backoff of attempt n is
+//min(initial_backoff_ms * multiplier^(n-1), max_backoff_ms) ± jitter share
+type Config struct {
+	//attempts count including the first one. 0 - default, -1 - unlimited (stream mode only)
+	MaxAttempts      int     `mapstructure:"max_attempts" json:"max_attempts,omitempty" yaml:"max_attempts,omitempty"`
+	InitialBackoffMs int     `mapstructure:"initial_backoff_ms" json:"initial_backoff_ms,omitempty" yaml:"initial_backoff_ms,omitempty"`
+	MaxBackoffMs     int     `mapstructure:"max_backoff_ms" json:"max_backoff_ms,omitempty" yaml:"max_backoff_ms,omitempty"`
+	Multiplier       float64 `mapstructure:"multiplier" json:"multiplier,omitempty" yaml:"multiplier,omitempty"`
+	//share of backoff in [0, 1]: backoff is randomized in [backoff * (1 - jitter), backoff * (1 + jitter)]
+	Jitter float64 `mapstructure:"jitter" json:"jitter,omitempty" yaml:"jitter,omitempty"`
+}
+
+//Classifier return true if the write error is transient and the write might be retried
+type Classifier func(err error) bool
+
+//Policy is a destination writes retry policy: max attempts, exponential backoff with jitter and retryable errors
+//classification of the destination adapter
+type Policy struct {
+	destinationName string
+	maxAttempts     int
+	initialBackoff  time.Duration
+	maxBackoff      time.Duration
+	multiplier      float64
+	jitter          float64
+	classifier      Classifier
+
+	sleep func(time.Duration)
+}
+
+//NewPolicy return Policy with default values of not configured parameters. Network errors are always retryable,
+//other errors are classified by classifier (if it isn't nil)
+func NewPolicy(destinationName string, config *Config, classifier Classifier) (*Policy, error) {
+	if config == nil {
+		config = &Config{}
+	}
+	if config.MaxAttempts < -1 {
+		return nil, fmt.Errorf("Retry max_attempts must be positive or -1 (unlimited): %d", config.MaxAttempts)
+	}
+	if config.InitialBackoffMs < 0 || config.MaxBackoffMs < 0 {
+		return nil, errors.New("Retry backoff can't be negative")
+	}
+	if config.Multiplier != 0 && config.Multiplier < 1 {
+		return nil, fmt.Errorf("Retry multiplier must be >= 1: %v", config.Multiplier)
+	}
+	if config.Jitter < 0 || config.Jitter > 1 {
+		return nil, fmt.Errorf("Retry jitter must be in [0, 1] range: %v", config.Jitter)
+	}
+
+	p := &Policy{
+		destinationName: destinationName,
+		maxAttempts:     config.MaxAttempts,
+		initialBackoff:  time.Duration(config.InitialBackoffMs) * time.Millisecond,
+		maxBackoff:      time.Duration(config.MaxBackoffMs) * time.Millisecond,
+		multiplier:      config.Multiplier,
+		jitter:          config.Jitter,
+		classifier:      classifier,
+		sleep:           time.Sleep,
+	}
+	if p.maxAttempts == 0 {
+		p.maxAttempts = defaultMaxAttempts
+	}
+	if p.initialBackoff == 0 {
+		p.initialBackoff = defaultInitialBackoffMs * time.Millisecond
+	}
+	if p.maxBackoff == 0 {
+		p.maxBackoff = defaultMaxBackoffMs * time.Millisecond
+	}
+	if p.maxBackoff < p.initialBackoff {
+		p.maxBackoff = p.initialBackoff
+	}
+	if p.multiplier == 0 {
+		p.multiplier = defaultMultiplier
+	}
+	if config.Jitter == 0 {
+		p.jitter = defaultJitter
+	}
+
+	return p, nil
+}
+
+//Register put the destination policy. It is used by destination writers (stream workers, batch uploaders)
+func Register(destinationName string, policy *Policy) {
+	policiesMutex.Lock()
+	policies[destinationName] = policy
+	policiesMutex.Unlock()
+}
+
+//Get return the destination policy or the default one (only network errors are retryable) if it isn't registered
+func Get(destinationName string) *Policy {
+	policiesMutex.RLock()
+	policy, ok := policies[destinationName]
+	policiesMutex.RUnlock()
+	if ok {
+		return policy
+	}
+
+	policy, _ = NewPolicy(destinationName, nil, nil)
+	return policy
+}
+
+//Retryable return true if the error is a network error or is classified as retryable by the destination adapter
+func (p *Policy) Retryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if IsNetworkError(err) {
+		return true
+	}
+	return p.classifier != nil && p.classifier(err)
+}
+
+//ShouldRetry return true if the error of the attempt (starts from 1) is retryable and attempts are left
+func (p *Policy) ShouldRetry(err error, attempt int) bool {
+	return p.Retryable(err) && (p.maxAttempts < 0 || attempt < p.maxAttempts)
+}
+
+//Backoff return randomized delay before the next attempt after the failed attempt (starts from 1)
+func (p *Policy) Backoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	backoff := float64(p.initialBackoff) * math.Pow(p.multiplier, float64(attempt-1))
+	if backoff > float64(p.maxBackoff) {
+		backoff = float64(p.maxBackoff)
+	}
+	backoff *= 1 - p.jitter + 2*p.jitter*rand.Float64()
+	return time.Duration(backoff)
+}
+
+//Do run f until it succeeds, fails with not retryable error or attempts are exhausted. Unlimited attempts
+//are bounded by default max attempts count here: f is retried inline
+func (p *Policy) Do(operation string, f func() error) error {
+	maxAttempts := p.maxAttempts
+	if maxAttempts < 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := f()
+		if err == nil || !p.Retryable(err) {
+			return err
+		}
+		if attempt >= maxAttempts {
+			p.Exhausted(operation, attempt, err)
+			return err
+		}
+
+		backoff := p.Backoff(attempt)
+		p.Retried(operation, attempt, backoff, err)
+		p.sleep(backoff)
+	}
+}
+
+//Retried log and count the retry of failed attempt
+func (p *Policy) Retried(operation string, attempt int, backoff time.Duration, err error) {
+	logging.Warnf("[%s] %s attempt %d has failed: %v. Retry in %s", p.destinationName, operation, attempt, err, backoff.Round(time.Millisecond))
+	metrics.DestinationRetry(p.destinationName, operation)
+}
+
+//Exhausted log and count the failed write after all attempts
+func (p *Policy) Exhausted(operation string, attempts int, err error) {
+	logging.Errorf("[%s] %s has failed after %d attempt(s): %v", p.destinationName, operation, attempts, err)
+	metrics.DestinationRetriesExhausted(p.destinationName, operation)
+}
+
+func (p *Policy) String() string {
+	attempts := fmt.Sprint(p.maxAttempts)
+	if p.maxAttempts < 0 {
+		attempts = "unlimited"
+	}
+	return fmt.Sprintf("max attempts: %s, backoff: %s..%s x%v, jitter: %v", attempts, p.initialBackoff, p.maxBackoff, p.multiplier, p.jitter)
+}
+
+//IsNetworkError return true if the error is a timeout, refused or reset connection, broken pipe or unexpected EOF.
+//Adapters errors are often wrapped as strings, so messages are checked as well
+func IsNetworkError(err error) bool {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return ContainsAny(err, networkErrors...)
+}
+
+//ContainsAny return true if the error message contains any of substrings (case insensitive)
+func ContainsAny(err error, substrings ...string) bool {
+	msg := strings.ToLower(err.Error())
+	for _, substring := range substrings {
+		if strings.Contains(msg, strings.ToLower(substring)) {
+			return true
+		}
+	}
+	return false
+}
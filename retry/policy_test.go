@@ -0,0 +1,77 @@
+package retry
+
+import (
+	"errors"
+	"github.com/stretchr/testify/require"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	policy, err := NewPolicy("test", &Config{InitialBackoffMs: 100, MaxBackoffMs: 1000, Multiplier: 2, Jitter: 0.1}, nil)
+	require.NoError(t, err)
+
+	tests := []struct {
+		attempt  int
+		expected time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, 1000 * time.Millisecond},
+		{50, 1000 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 100; i++ {
+			backoff := policy.Backoff(tt.attempt)
+			require.True(t, backoff >= tt.expected*9/10 && backoff <= tt.expected*11/10, "attempt %d: %s", tt.attempt, backoff)
+		}
+	}
+}
+
+func TestDo(t *testing.T) {
+	retryable := errors.New("pq: deadlock detected")
+	classifier := func(err error) bool { return ContainsAny(err, "deadlock detected") }
+
+	tests := []struct {
+		name             string
+		errs             []error
+		expectedErr      error
+		expectedAttempts int
+	}{
+		{"Succeeded after retries", []error{retryable, io.EOF, nil}, nil, 3},
+		{"Not retryable error", []error{errors.New("syntax error"), nil}, errors.New("syntax error"), 1},
+		{"Exhausted", []error{retryable, retryable, retryable, nil}, retryable, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := NewPolicy("test", &Config{MaxAttempts: 3}, classifier)
+			require.NoError(t, err)
+			var sleeps []time.Duration
+			policy.sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+
+			attempts := 0
+			err = policy.Do("store", func() error {
+				err := tt.errs[attempts]
+				attempts++
+				return err
+			})
+			require.Equal(t, tt.expectedErr, err)
+			require.Equal(t, tt.expectedAttempts, attempts)
+			require.Len(t, sleeps, tt.expectedAttempts-1)
+		})
+	}
+}
+
+func TestShouldRetry(t *testing.T) {
+	unlimited, err := NewPolicy("test", &Config{MaxAttempts: -1}, nil)
+	require.NoError(t, err)
+	require.True(t, unlimited.ShouldRetry(errors.New("dial tcp: connection refused"), 1000))
+	require.False(t, unlimited.ShouldRetry(errors.New("column doesn't exist"), 1))
+
+	_, err = NewPolicy("test", &Config{Jitter: 2}, nil)
+	require.Error(t, err)
+	_, err = NewPolicy("test", &Config{Multiplier: 0.5}, nil)
+	require.Error(t, err)
+}
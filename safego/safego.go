@@ -46,3 +46,16 @@ func (exec *Execution) WithRestartTimeout(timeout time.Duration) *Execution {
 	exec.restartTimeout = timeout
 	return exec
 }
+
+//Run run a new goroutine and add panic handler without restart
+//(for one time jobs which mustn't be re-run from the beginning)
+func Run(f func()) {
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				GlobalRecoverHandler(r)
+			}
+		}()
+		f()
+	}()
+}
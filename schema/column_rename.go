@@ -0,0 +1,103 @@
+package schema
+
+import (
+	"fmt"
+)
+
+//ColumnRename is a declared rename of the table column (flattened name e.g. eventn_ctx_user_id):
+//From values are written into To column going forward. Old values can be backfilled via migrations API
+type ColumnRename struct {
+	//empty - all destination tables
+	Table string `mapstructure:"table" json:"table,omitempty" yaml:"table,omitempty"`
+	From  string `mapstructure:"from" json:"from" yaml:"from"`
+	To    string `mapstructure:"to" json:"to" yaml:"to"`
+}
+
+func (cr ColumnRename) String() string {
+	table := cr.Table
+	if table == "" {
+		table = "*"
+	}
+	return fmt.Sprintf("%s: %s -> %s", table, cr.From, cr.To)
+}
+
+//columnRenames is a table name (empty - all tables) -> from column -> to column
+type columnRenames map[string]map[string]string
+
+//newColumnRenames return validated renames: from and to are required and different, one column can't be renamed twice
+//in the same table and renamed column can't be a source of another rename (chains aren't supported)
+func newColumnRenames(renames []ColumnRename) (columnRenames, error) {
+	result := columnRenames{}
+	for _, rename := range renames {
+		if rename.From == "" || rename.To == "" {
+			return nil, fmt.Errorf("Column rename [%s]: from and to are required", rename)
+		}
+		if rename.From == rename.To {
+			return nil, fmt.Errorf("Column rename [%s]: from and to must be different", rename)
+		}
+
+		tableRenames, ok := result[rename.Table]
+		if !ok {
+			tableRenames = map[string]string{}
+			result[rename.Table] = tableRenames
+		}
+		if _, ok := tableRenames[rename.From]; ok {
+			return nil, fmt.Errorf("Column rename [%s]: column has been already renamed", rename)
+		}
+		tableRenames[rename.From] = rename.To
+	}
+
+	for table, tableRenames := range result {
+		for from, to := range tableRenames {
+			for otherTable, otherRenames := range result {
+				//all tables renames are applied together with every table renames
+				if table != "" && otherTable != "" && otherTable != table {
+					continue
+				}
+				if _, ok := otherRenames[to]; ok {
+					return nil, fmt.Errorf("Column rename [%s]: renamed column can't be renamed again", ColumnRename{Table: table, From: from, To: to})
+				}
+			}
+		}
+	}
+
+	return result, nil
+}
+
+//SetColumnRenames set declared renames of tables columns
+func (p *Processor) SetColumnRenames(renames []ColumnRename) error {
+	parsed, err := newColumnRenames(renames)
+	if err != nil {
+		return err
+	}
+	p.renames = parsed
+	return nil
+}
+
+//lookup return new column name of the table column. Table renames override all tables renames
+func (cr columnRenames) lookup(table, column string) (string, bool) {
+	if to, ok := cr[table][column]; ok {
+		return to, true
+	}
+	to, ok := cr[""][column]
+	return to, ok
+}
+
+//apply move renamed columns values of the flat object into new columns. New column value has priority
+func (cr columnRenames) apply(table string, flatObject map[string]interface{}) {
+	if len(cr) == 0 {
+		return
+	}
+
+	for column, value := range flatObject {
+		to, ok := cr.lookup(table, column)
+		if !ok {
+			continue
+		}
+
+		delete(flatObject, column)
+		if _, exists := flatObject[to]; !exists {
+			flatObject[to] = value
+		}
+	}
+}
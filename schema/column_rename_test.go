@@ -0,0 +1,75 @@
+package schema
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestColumnRenamesApply(t *testing.T) {
+	renames, err := newColumnRenames([]ColumnRename{
+		{From: "user_email", To: "eventn_ctx_user_email"},
+		{Table: "events", From: "amount", To: "revenue"},
+		{Table: "events", From: "user_email", To: "email"},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		table    string
+		input    map[string]interface{}
+		expected map[string]interface{}
+	}{
+		{
+			"all tables rename",
+			"pages",
+			map[string]interface{}{"user_email": "a@b.com", "amount": 10},
+			map[string]interface{}{"eventn_ctx_user_email": "a@b.com", "amount": 10},
+		},
+		{
+			"table renames override all tables renames",
+			"events",
+			map[string]interface{}{"user_email": "a@b.com", "amount": 10},
+			map[string]interface{}{"email": "a@b.com", "revenue": 10},
+		},
+		{
+			"new column value has priority",
+			"events",
+			map[string]interface{}{"amount": 10, "revenue": 20},
+			map[string]interface{}{"revenue": 20},
+		},
+		{
+			"without renamed columns",
+			"events",
+			map[string]interface{}{"field": "value"},
+			map[string]interface{}{"field": "value"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			renames.apply(tt.table, tt.input)
+			require.Equal(t, tt.expected, tt.input)
+		})
+	}
+}
+
+func TestNewColumnRenamesErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		renames []ColumnRename
+	}{
+		{"empty to", []ColumnRename{{From: "a"}}},
+		{"the same column", []ColumnRename{{From: "a", To: "a"}}},
+		{"renamed twice", []ColumnRename{{Table: "events", From: "a", To: "b"}, {Table: "events", From: "a", To: "c"}}},
+		{"chain", []ColumnRename{{Table: "events", From: "a", To: "b"}, {Table: "events", From: "b", To: "c"}}},
+		{"chain with all tables rename", []ColumnRename{{From: "a", To: "b"}, {Table: "events", From: "b", To: "c"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newColumnRenames(tt.renames)
+			require.Error(t, err)
+		})
+	}
+
+	_, err := newColumnRenames([]ColumnRename{{Table: "events", From: "a", To: "b"}, {Table: "pages", From: "b", To: "c"}})
+	require.NoError(t, err)
+}
@@ -0,0 +1,159 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+)
+
+const (
+	//one row of columns names: Redshift COPY ... CSV IGNOREHEADER 1, Snowflake FILE_FORMAT = (TYPE = CSV SKIP_HEADER = 1)
+	CsvNamesHeader = "names"
+	//two rows: columns names and columns types (e.g. INT64, TIMESTAMP): IGNOREHEADER 2, SKIP_HEADER = 2
+	CsvTypedHeader = "typed"
+	//without header rows
+	CsvNoHeader = "none"
+
+	defaultCsvDelimiter = ","
+)
+
+//CsvOptions is a configuration of RFC 4180 CSV batch files: values with delimiters, quotes or line breaks are enclosed
+//in double quotes (Redshift COPY ... CSV, Snowflake FIELD_OPTIONALLY_ENCLOSED_BY = '"'), nulls are empty values,
+//objects and arrays are JSON strings
+type CsvOptions struct {
+	//one character. Default: ,
+	Delimiter string `mapstructure:"delimiter" json:"delimiter,omitempty" yaml:"delimiter,omitempty"`
+	//names (default), typed or none
+	Header string `mapstructure:"header" json:"header,omitempty" yaml:"header,omitempty"`
+}
+
+func (co *CsvOptions) Validate() error {
+	if co == nil {
+		return nil
+	}
+	if co.Delimiter != "" && utf8.RuneCountInString(co.Delimiter) != 1 {
+		return fmt.Errorf("CSV delimiter must be one character: %q", co.Delimiter)
+	}
+	switch co.Header {
+	case "", CsvNamesHeader, CsvTypedHeader, CsvNoHeader:
+		return nil
+	default:
+		return fmt.Errorf("Unknown CSV header: %s. Available: [%s, %s, %s]", co.Header, CsvNamesHeader, CsvTypedHeader, CsvNoHeader)
+	}
+}
+
+//GetDelimiter return configured or default delimiter
+func (co *CsvOptions) GetDelimiter() string {
+	if co == nil || co.Delimiter == "" {
+		return defaultCsvDelimiter
+	}
+	return co.Delimiter
+}
+
+//HeaderRows return count of header rows which loaders must skip
+func (co *CsvOptions) HeaderRows() int {
+	header := CsvNamesHeader
+	if co != nil && co.Header != "" {
+		header = co.Header
+	}
+	switch header {
+	case CsvTypedHeader:
+		return 2
+	case CsvNoHeader:
+		return 0
+	default:
+		return 1
+	}
+}
+
+//CsvColumn is a column of CSV file with its type
+type CsvColumn struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+//GetCsvPayload return CSV file bytes and its columns. Columns are in file ordering (if configured) or alphabetical order
+func (pf ProcessedFile) GetCsvPayload(options *CsvOptions) ([]byte, []CsvColumn, error) {
+	if len(pf.payload) == 0 {
+		return nil, nil, errors.New("CSV file payload can't be empty")
+	}
+
+	columns := pf.GetColumns()
+	header := make([]string, 0, len(columns))
+	types := make([]string, 0, len(columns))
+	for _, column := range columns {
+		header = append(header, column.Name)
+		types = append(types, column.Type)
+	}
+
+	buf := &bytes.Buffer{}
+	writer := csv.NewWriter(buf)
+	writer.Comma, _ = utf8.DecodeRuneInString(options.GetDelimiter())
+
+	headerRows := options.HeaderRows()
+	if headerRows > 0 {
+		if err := writer.Write(header); err != nil {
+			return nil, nil, err
+		}
+	}
+	if headerRows > 1 {
+		if err := writer.Write(types); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	record := make([]string, len(header))
+	for _, object := range pf.payload {
+		for i, name := range header {
+			value, err := csvValue(object[name])
+			if err != nil {
+				return nil, nil, fmt.Errorf("Error marshalling [%s] value: %v", name, err)
+			}
+			record[i] = value
+		}
+		if err := writer.Write(record); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return nil, nil, err
+	}
+	return buf.Bytes(), columns, nil
+}
+
+//GetColumns return the file columns with types in file ordering (if configured) or alphabetical order
+func (pf ProcessedFile) GetColumns() []CsvColumn {
+	header := orderedHeader(pf.DataSchema.Columns, pf.columnsOrder)
+	columns := make([]CsvColumn, 0, len(header))
+	for _, name := range header {
+		columns = append(columns, CsvColumn{Name: name, Type: pf.DataSchema.Columns[name].GetType().String()})
+	}
+	return columns
+}
+
+//csvValue return the string value as is, other values as JSON without enclosing quotes (e.g. timestamps)
+func csvValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	}
+
+	b, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	if len(b) >= 2 && b[0] == quotaByteValue && b[len(b)-1] == quotaByteValue {
+		var str string
+		if err := json.Unmarshal(b, &str); err == nil {
+			return str, nil
+		}
+	}
+	return string(b), nil
+}
@@ -0,0 +1,76 @@
+package schema
+
+import (
+	"github.com/jitsucom/eventnative/parsers"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestGetCsvPayload(t *testing.T) {
+	payload := []byte(`{"id": 1, "name": "a, \"quoted\"", "_timestamp": "2020-08-02T21:23:58.000000Z", "tags": ["x", "y"]}
+{"id": 2, "_timestamp": "2020-08-02T21:23:59.000000Z"}
+`)
+
+	tests := []struct {
+		name               string
+		options            *CsvOptions
+		expectedCsv        string
+		expectedHeaderRows int
+	}{
+		{
+			"Default names header",
+			nil,
+			`_timestamp,id,name,tags
+2020-08-02T21:23:58Z,1,"a, ""quoted""","[""x"",""y""]"
+2020-08-02T21:23:59Z,2,,
+`,
+			1,
+		},
+		{
+			"Typed header with delimiter",
+			&CsvOptions{Delimiter: "|", Header: CsvTypedHeader},
+			`_timestamp|id|name|tags
+TIMESTAMP|INT64|STRING|STRING
+2020-08-02T21:23:58Z|1|"a, ""quoted"""|"[""x"",""y""]"
+2020-08-02T21:23:59Z|2||
+`,
+			2,
+		},
+		{
+			"Without header",
+			&CsvOptions{Header: CsvNoHeader},
+			`2020-08-02T21:23:58Z,1,"a, ""quoted""","[""x"",""y""]"
+2020-08-02T21:23:59Z,2,,
+`,
+			0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewProcessor("events", []string{}, Default, map[string]bool{}, nil, nil)
+			require.NoError(t, err)
+
+			files, failed, err := p.ProcessFilePayload("file", payload, true, parsers.ParseJson)
+			require.NoError(t, err)
+			require.Empty(t, failed)
+			require.Len(t, files, 1)
+
+			b, columns, err := files["events"].GetCsvPayload(tt.options)
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedCsv, string(b))
+			require.Equal(t, tt.expectedHeaderRows, tt.options.HeaderRows())
+			require.Equal(t, []CsvColumn{{"_timestamp", "TIMESTAMP"}, {"id", "INT64"}, {"name", "STRING"}, {"tags", "STRING"}}, columns)
+
+			manifest := NewManifest("file.csv", "events", "csv", b, columns, 2)
+			require.Equal(t, len(b), manifest.Bytes)
+			require.Len(t, manifest.MD5, 32)
+			require.Len(t, manifest.SHA256, 64)
+		})
+	}
+}
+
+func TestCsvOptionsValidation(t *testing.T) {
+	require.NoError(t, (*CsvOptions)(nil).Validate())
+	require.Error(t, (&CsvOptions{Delimiter: "||"}).Validate())
+	require.Error(t, (&CsvOptions{Header: "random"}).Validate())
+}
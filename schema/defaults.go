@@ -0,0 +1,150 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/jsonutils"
+	"strings"
+)
+
+const (
+	DropNulls    = "drop"
+	KeepNulls    = "keep"
+	DefaultNulls = "default"
+)
+
+//Defaults are default values of fields which are missing in mapped events and a policy of explicit JSON nulls
+type Defaults struct {
+	//default value rules in format: /field/subfield -> JSON value (e.g. /revenue -> 0 or /campaign -> "unknown")
+	Values []string `mapstructure:"values" json:"values,omitempty" yaml:"values,omitempty"`
+	//drop (default) - null fields are removed from events
+	//keep - null fields are written as NULL values of typed columns (fields with unknown type are removed)
+	//default - null fields are replaced with default values (fields without default values are removed)
+	Nulls string `mapstructure:"nulls" json:"nulls,omitempty" yaml:"nulls,omitempty"`
+}
+
+type defaultValue struct {
+	path  *jsonutils.JsonPath
+	value interface{}
+}
+
+type defaults struct {
+	values []*defaultValue
+	//flat field name -> default value
+	fields map[string]interface{}
+	nulls  string
+}
+
+//newDefaults return parsed defaults or nil if there are no default values and nulls are dropped
+func newDefaults(config *Defaults, flattener *Flattener) (*defaults, error) {
+	if config == nil {
+		return nil, nil
+	}
+
+	nulls := config.Nulls
+	switch nulls {
+	case "":
+		nulls = DropNulls
+	case DropNulls, KeepNulls, DefaultNulls:
+	default:
+		return nil, fmt.Errorf("Unknown defaults nulls policy: %s. Available: [%s, %s, %s]", nulls, DropNulls, KeepNulls, DefaultNulls)
+	}
+	if len(config.Values) == 0 && nulls == DropNulls {
+		return nil, nil
+	}
+
+	d := &defaults{fields: map[string]interface{}{}, nulls: nulls}
+	for _, rule := range config.Values {
+		parts := strings.SplitN(rule, "->", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Malformed default value [%s]. Use format: /field1/subfield1 -> JSON value", rule)
+		}
+		path := jsonutils.NewJsonPath(strings.TrimSpace(parts[0]))
+		if path.IsEmpty() {
+			return nil, fmt.Errorf("Malformed default value [%s]. Field path before '->' can't be empty", rule)
+		}
+
+		value, err := parseDefaultValue(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, fmt.Errorf("Malformed default value [%s]: %v", rule, err)
+		}
+
+		//flat field name of the path (e.g. /utm/campaign -> utm_campaign)
+		nested := map[string]interface{}{}
+		path.Set(nested, value)
+		flatObject, err := flattener.FlattenObject(nested)
+		if err != nil {
+			return nil, fmt.Errorf("Malformed default value [%s]: %v", rule, err)
+		}
+		for field := range flatObject {
+			if _, ok := d.fields[field]; ok {
+				return nil, fmt.Errorf("Default value of [%s] field is declared twice", field)
+			}
+			d.fields[field] = value
+		}
+
+		d.values = append(d.values, &defaultValue{path: path, value: value})
+	}
+
+	return d, nil
+}
+
+//parseDefaultValue return JSON scalar value (numbers are json.Number like in parsed events). Not JSON value is a string
+func parseDefaultValue(raw string) (interface{}, error) {
+	if raw == "" {
+		return nil, errors.New("Value after '->' can't be empty")
+	}
+
+	decoder := json.NewDecoder(bytes.NewBufferString(raw))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil || decoder.More() {
+		return raw, nil
+	}
+
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		return nil, errors.New("Objects and arrays can't be default values")
+	case nil:
+		return nil, errors.New("Null can't be a default value")
+	}
+	return value, nil
+}
+
+//SetDefaults set default values of missing fields and nulls policy. Nil config means nulls are dropped
+func (p *Processor) SetDefaults(config *Defaults) error {
+	parsed, err := newDefaults(config, p.flattener)
+	if err != nil {
+		return err
+	}
+	p.defaults = parsed
+	p.flattener.omitNilValues = !parsed.keepNulls()
+	p.copyFree = p.isCopyFree()
+	return nil
+}
+
+//apply put default values into the mapped object if fields are missing (or null with default nulls policy)
+func (d *defaults) apply(object map[string]interface{}) {
+	for _, dv := range d.values {
+		value, ok := dv.path.Get(object)
+		if !ok || (value == nil && d.nulls == DefaultNulls) {
+			dv.path.Set(object, dv.value)
+		}
+	}
+}
+
+//keepNulls return true if null fields must be written as NULL values
+func (d *defaults) keepNulls() bool {
+	return d != nil && d.nulls == KeepNulls
+}
+
+//field return default value of the flat field
+func (d *defaults) field(name string) (interface{}, bool) {
+	if d == nil {
+		return nil, false
+	}
+	value, ok := d.fields[name]
+	return value, ok
+}
@@ -0,0 +1,126 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/jsonutils"
+	"github.com/jitsucom/eventnative/metrics"
+	"strings"
+	"time"
+)
+
+const (
+	sunsetLayout = "2006-01-02"
+
+	deprecatedFieldUsed    = "used"
+	deprecatedFieldDropped = "dropped"
+)
+
+//Deprecations are fields which are going to be retired: events which still use them are counted
+//(and tagged if TagField is configured). Since the sunset date the field is dropped from events
+type Deprecations struct {
+	Fields []DeprecatedField `mapstructure:"fields" json:"fields,omitempty" yaml:"fields,omitempty"`
+	//if set - comma separated names of used deprecated fields are written into the field (e.g. /eventn_ctx/deprecated_fields)
+	TagField string `mapstructure:"tag_field" json:"tag_field,omitempty" yaml:"tag_field,omitempty"`
+}
+
+//DeprecatedField is a json path of the source event field (e.g. /user/legacy_id) and an optional sunset date (UTC, YYYY-MM-DD)
+type DeprecatedField struct {
+	Field  string `mapstructure:"field" json:"field,omitempty" yaml:"field,omitempty"`
+	Sunset string `mapstructure:"sunset" json:"sunset,omitempty" yaml:"sunset,omitempty"`
+}
+
+func (df DeprecatedField) String() string {
+	if df.Sunset == "" {
+		return df.Field
+	}
+	return fmt.Sprintf("%s (sunset: %s)", df.Field, df.Sunset)
+}
+
+type deprecatedField struct {
+	name string
+	path *jsonutils.JsonPath
+	//zero if field doesn't have sunset date
+	sunset time.Time
+}
+
+type deprecations struct {
+	fields []*deprecatedField
+	//nil if events aren't tagged
+	tag *jsonutils.JsonPath
+
+	now func() time.Time
+}
+
+//newDeprecations return parsed deprecations or nil if there are no deprecated fields
+func newDeprecations(config *Deprecations) (*deprecations, error) {
+	if config == nil || len(config.Fields) == 0 {
+		return nil, nil
+	}
+
+	d := &deprecations{now: time.Now}
+	names := map[string]bool{}
+	for _, field := range config.Fields {
+		path := jsonutils.NewJsonPath(field.Field)
+		if path.IsEmpty() {
+			return nil, errors.New("Deprecated field path is required")
+		}
+		name := path.String()
+		if names[name] {
+			return nil, fmt.Errorf("Deprecated field [%s] is declared twice", name)
+		}
+		names[name] = true
+
+		parsed := &deprecatedField{name: name, path: path}
+		if field.Sunset != "" {
+			sunset, err := time.Parse(sunsetLayout, field.Sunset)
+			if err != nil {
+				return nil, fmt.Errorf("Deprecated field [%s]: malformed sunset date [%s]. Use format: YYYY-MM-DD", name, field.Sunset)
+			}
+			parsed.sunset = sunset
+		}
+		d.fields = append(d.fields, parsed)
+	}
+
+	if config.TagField != "" {
+		d.tag = jsonutils.NewJsonPath(config.TagField)
+	}
+
+	return d, nil
+}
+
+//SetDeprecations set deprecated fields of the destination. Nil config means there are no deprecated fields
+func (p *Processor) SetDeprecations(config *Deprecations) error {
+	parsed, err := newDeprecations(config)
+	if err != nil {
+		return err
+	}
+	p.deprecations = parsed
+	p.copyFree = p.isCopyFree()
+	return nil
+}
+
+//apply count deprecated fields usage, remove fields which are past the sunset date and tag the object
+//with used deprecated fields names
+func (d *deprecations) apply(object map[string]interface{}) {
+	now := d.now().UTC()
+	var used []string
+	for _, field := range d.fields {
+		if _, ok := field.path.Get(object); !ok {
+			continue
+		}
+
+		if !field.sunset.IsZero() && !now.Before(field.sunset) {
+			field.path.GetAndRemove(object)
+			metrics.DeprecatedField(field.name, deprecatedFieldDropped)
+			continue
+		}
+
+		used = append(used, field.name)
+		metrics.DeprecatedField(field.name, deprecatedFieldUsed)
+	}
+
+	if d.tag != nil && len(used) > 0 {
+		d.tag.Set(object, strings.Join(used, ","))
+	}
+}
@@ -0,0 +1,64 @@
+package schema
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestDeprecationsApply(t *testing.T) {
+	d, err := newDeprecations(&Deprecations{
+		Fields: []DeprecatedField{
+			{Field: "/user/legacy_id"},
+			{Field: "/old_field", Sunset: "2021-01-31"},
+			{Field: "/future_field", Sunset: "2021-03-01"},
+		},
+		TagField: "/eventn_ctx/deprecated_fields",
+	})
+	require.NoError(t, err)
+	d.now = func() time.Time { return time.Date(2021, 1, 31, 0, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name     string
+		input    map[string]interface{}
+		expected map[string]interface{}
+	}{
+		{
+			"without deprecated fields",
+			map[string]interface{}{"field": 1},
+			map[string]interface{}{"field": 1},
+		},
+		{
+			"used fields are tagged",
+			map[string]interface{}{"user": map[string]interface{}{"legacy_id": 1}, "future_field": 2},
+			map[string]interface{}{"user": map[string]interface{}{"legacy_id": 1}, "future_field": 2,
+				"eventn_ctx": map[string]interface{}{"deprecated_fields": "/user/legacy_id,/future_field"}},
+		},
+		{
+			"field is dropped since sunset date",
+			map[string]interface{}{"old_field": 1, "field": 2},
+			map[string]interface{}{"field": 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d.apply(tt.input)
+			require.Equal(t, tt.expected, tt.input)
+		})
+	}
+}
+
+func TestNewDeprecationsErrors(t *testing.T) {
+	d, err := newDeprecations(&Deprecations{TagField: "/tag"})
+	require.NoError(t, err)
+	require.Nil(t, d)
+
+	_, err = newDeprecations(&Deprecations{Fields: []DeprecatedField{{Field: "/a", Sunset: "31.01.2021"}}})
+	require.Error(t, err)
+
+	_, err = newDeprecations(&Deprecations{Fields: []DeprecatedField{{Field: "/a"}, {Field: "a"}}})
+	require.Error(t, err)
+
+	_, err = newDeprecations(&Deprecations{Fields: []DeprecatedField{{}}})
+	require.Error(t, err)
+}
@@ -29,28 +29,34 @@ func (f FieldMappingType) String() string {
 }
 
 type FieldMapper struct {
-	rules []*MappingRule
+	plan *mappingPlan
 }
 
 type StrictFieldMapper struct {
-	rules []*MappingRule
+	plan *mappingPlan
 }
 
 type DummyMapper struct{}
 
+//TypeCast is a mapping typecast. Decimal is set for DECIMAL type
+type TypeCast struct {
+	Type    typing.DataType
+	Decimal *typing.DecimalSpec
+}
+
 type MappingRule struct {
 	source      *jsonutils.JsonPath
 	destination *jsonutils.JsonPath
 }
 
 //NewFieldMapper return FieldMapper, fields to typecast and err
-func NewFieldMapper(mappingType FieldMappingType, mappings []string) (Mapper, map[string]typing.DataType, error) {
+func NewFieldMapper(mappingType FieldMappingType, mappings []string) (Mapper, map[string]TypeCast, error) {
 	if len(mappings) == 0 {
 		return &DummyMapper{}, nil, nil
 	}
 
 	var rules []*MappingRule
-	fieldsToCast := map[string]typing.DataType{}
+	fieldsToCast := map[string]TypeCast{}
 	for _, mapping := range mappings {
 		mappingWithoutSpaces := strings.ReplaceAll(mapping, " ", "")
 		parts := strings.Split(mappingWithoutSpaces, "->")
@@ -75,44 +81,61 @@ func NewFieldMapper(mappingType FieldMappingType, mappings []string) (Mapper, ma
 			continue
 		}
 
-		//parse type casting
-		destParts := strings.Split(destination, ")")
-		if len(destParts) != 2 || (len(destParts) == 1 && destParts[0] == "") {
+		//parse type casting: cast type can contain parentheses itself: (decimal(12,2))
+		castEnd := strings.LastIndex(destination, ")")
+		destParts := []string{destination[:castEnd], destination[castEnd+1:]}
+		if !strings.HasPrefix(destParts[0], "(") {
 			return nil, nil, fmt.Errorf("Malformed cast statement in data mapping [%s]. Use format: /field1/subfield1 -> (integer) /field2/subfield2", mapping)
 		}
 
 		// /key1/key2 -> key1_key2
 		formattedDestination := strings.ReplaceAll(jsonutils.FormatPrefixSuffix(destParts[1]), "/", "_")
 
-		castType := strings.ReplaceAll(destParts[0], "(", "")
-		dataType, err := typing.TypeFromString(castType)
+		castType := strings.TrimPrefix(destParts[0], "(")
+		typeCast, err := parseTypeCast(castType)
 		if err != nil {
-			return nil, nil, fmt.Errorf("Malformed cast type in data mapping [%s]: %v. Available types: integer, double, string, timestamp", mapping, err)
+			return nil, nil, fmt.Errorf("Malformed cast type in data mapping [%s]: %v. Available types: integer, double, decimal(precision,scale), string, timestamp", mapping, err)
 		}
 
-		fieldsToCast[formattedDestination] = dataType
+		fieldsToCast[formattedDestination] = typeCast
 		rules = append(rules, &MappingRule{
 			source:      jsonutils.NewJsonPath(source),
 			destination: jsonutils.NewJsonPath(destParts[1]),
 		})
 	}
 	if mappingType == Strict {
-		return &StrictFieldMapper{rules: rules}, fieldsToCast, nil
+		return &StrictFieldMapper{plan: compileMappingPlan(rules, false)}, fieldsToCast, nil
+	}
+	return &FieldMapper{plan: compileMappingPlan(rules, true)}, fieldsToCast, nil
+}
+
+func parseTypeCast(castType string) (TypeCast, error) {
+	spec, ok, err := typing.ParseDecimalType(castType)
+	if err != nil {
+		return TypeCast{}, err
+	}
+	if ok {
+		return TypeCast{Type: typing.DECIMAL, Decimal: &spec}, nil
 	}
-	return &FieldMapper{rules: rules}, fieldsToCast, nil
+
+	dataType, err := typing.TypeFromString(castType)
+	if err != nil {
+		return TypeCast{}, err
+	}
+	return TypeCast{Type: dataType}, nil
 }
 
 //Map changes input object and applies deletes and mappings
 func (fm FieldMapper) Map(object map[string]interface{}) (map[string]interface{}, error) {
-	applyMapping(object, object, fm.rules)
+	fm.plan.execute(object, object)
 
 	return object, nil
 }
 
 func (fm StrictFieldMapper) Map(object map[string]interface{}) (map[string]interface{}, error) {
-	mappedObject := make(map[string]interface{})
+	mappedObject := make(map[string]interface{}, fm.plan.topLevelSize+len(systemFields))
 
-	applyMapping(object, mappedObject, fm.rules)
+	fm.plan.execute(object, mappedObject)
 
 	for _, field := range systemFields {
 		if val, ok := object[field]; ok {
@@ -126,22 +149,3 @@ func (fm StrictFieldMapper) Map(object map[string]interface{}) (map[string]inter
 func (DummyMapper) Map(object map[string]interface{}) (map[string]interface{}, error) {
 	return object, nil
 }
-
-func applyMapping(sourceObj, destinationObj map[string]interface{}, rules []*MappingRule) {
-	for _, rule := range rules {
-		value, ok := rule.source.GetAndRemove(sourceObj)
-		if ok {
-			//handle delete rules
-			if rule.destination.IsEmpty() {
-				continue
-			}
-
-			ok := rule.destination.Set(destinationObj, value)
-			if !ok {
-				//key wasn't set into destination object
-				//set as is
-				rule.source.Set(destinationObj, value)
-			}
-		}
-	}
-}
@@ -0,0 +1,178 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/timestamp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	//rows are sorted by _timestamp
+	TimestampRowsOrder = "timestamp"
+	//rows are sorted by primary key fields values (in alphabetical order of fields names)
+	PrimaryKeysRowsOrder = "primary_keys"
+)
+
+//FileOrdering is a configuration of rows and columns order in batch files (S3 files, Redshift, BigQuery and Snowflake
+//stage files). By default rows are in the order of receiving and columns are in arbitrary (CSV) or alphabetical (JSON) order
+type FileOrdering struct {
+	//timestamp or primary_keys. Empty means the order of receiving
+	Rows string `mapstructure:"rows" json:"rows,omitempty" yaml:"rows,omitempty"`
+	//columns which are written first in this order. Other columns follow in alphabetical order
+	Columns []string `mapstructure:"columns" json:"columns,omitempty" yaml:"columns,omitempty"`
+}
+
+func (fo *FileOrdering) String() string {
+	rows := fo.Rows
+	if rows == "" {
+		rows = "receiving"
+	}
+	result := fmt.Sprintf("rows by %s", rows)
+	if len(fo.Columns) > 0 {
+		result += fmt.Sprintf(", columns: [%s]", strings.Join(fo.Columns, ", "))
+	}
+	return result
+}
+
+type fileOrderer struct {
+	//sorted flat fields which rows are compared by. Empty means rows aren't sorted
+	sortFields []string
+	columns    []string
+}
+
+//newFileOrderer return parsed file ordering or nil if it isn't configured
+func newFileOrderer(config *FileOrdering, pkFields map[string]bool) (*fileOrderer, error) {
+	if config == nil {
+		return nil, nil
+	}
+
+	orderer := &fileOrderer{}
+	switch config.Rows {
+	case "":
+	case TimestampRowsOrder:
+		orderer.sortFields = []string{timestamp.Key}
+	case PrimaryKeysRowsOrder:
+		orderer.sortFields = PkToFieldsArray(pkFields)
+		if len(orderer.sortFields) == 0 {
+			return nil, errors.New("File ordering rows by primary keys requires primary_key_fields")
+		}
+	default:
+		return nil, fmt.Errorf("Unknown file ordering rows: %s. Available: [%s, %s]", config.Rows, TimestampRowsOrder, PrimaryKeysRowsOrder)
+	}
+
+	seen := map[string]bool{}
+	for _, column := range config.Columns {
+		if column == "" {
+			return nil, errors.New("File ordering column name can't be empty")
+		}
+		if seen[column] {
+			return nil, fmt.Errorf("File ordering column [%s] is duplicated", column)
+		}
+		seen[column] = true
+		orderer.columns = append(orderer.columns, column)
+	}
+
+	return orderer, nil
+}
+
+//apply sort the file rows stably and set the columns order
+func (fo *fileOrderer) apply(pf *ProcessedFile) {
+	pf.stableColumns = true
+	pf.columnsOrder = fo.columns
+	if len(fo.sortFields) == 0 {
+		return
+	}
+
+	sort.SliceStable(pf.payload, func(i, j int) bool {
+		for _, field := range fo.sortFields {
+			if c := compareValues(pf.payload[i][field], pf.payload[j][field]); c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+}
+
+//orderedHeader return configured columns (which exist in the table) and then other columns in alphabetical order
+func orderedHeader(columns Columns, order []string) []string {
+	var header []string
+	ordered := map[string]bool{}
+	for _, column := range order {
+		if _, ok := columns[column]; ok {
+			header = append(header, column)
+			ordered[column] = true
+		}
+	}
+
+	var rest []string
+	for column := range columns {
+		if !ordered[column] {
+			rest = append(rest, column)
+		}
+	}
+	sort.Strings(rest)
+	return append(header, rest...)
+}
+
+//compareValues return -1, 0 or 1. Missing (nil) values go first, numbers are compared as numbers, times as times,
+//other values as strings
+func compareValues(a, b interface{}) int {
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return -1
+		default:
+			return 1
+		}
+	}
+
+	if aTime, ok := a.(time.Time); ok {
+		if bTime, ok := b.(time.Time); ok {
+			switch {
+			case aTime.Before(bTime):
+				return -1
+			case aTime.After(bTime):
+				return 1
+			default:
+				return 0
+			}
+		}
+	}
+
+	if aNumber, ok := toFloat(a); ok {
+		if bNumber, ok := toFloat(b); ok {
+			switch {
+			case aNumber < bNumber:
+				return -1
+			case aNumber > bNumber:
+				return 1
+			default:
+				return 0
+			}
+		}
+	}
+
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,76 @@
+package schema
+
+import (
+	"github.com/jitsucom/eventnative/parsers"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestFileOrdering(t *testing.T) {
+	payload := []byte(`{"id": 3, "name": "c", "_timestamp": "2020-08-02T21:23:58.000000Z"}
+{"id": 1, "name": "a", "_timestamp": "2020-08-02T21:23:59.000000Z"}
+{"id": 2, "name": "b", "_timestamp": "2020-08-02T21:23:57.000000Z"}
+{"id": 2, "_timestamp": "2020-08-02T21:23:56.000000Z"}
+`)
+
+	tests := []struct {
+		name         string
+		ordering     *FileOrdering
+		expectedJson string
+		expectedCsv  string
+	}{
+		{
+			"By timestamp with configured columns",
+			&FileOrdering{Rows: TimestampRowsOrder, Columns: []string{"name", "id", "unknown"}},
+			`{"id":2,"_timestamp":"2020-08-02T21:23:56Z"}
+{"name":"b","id":2,"_timestamp":"2020-08-02T21:23:57Z"}
+{"name":"c","id":3,"_timestamp":"2020-08-02T21:23:58Z"}
+{"name":"a","id":1,"_timestamp":"2020-08-02T21:23:59Z"}`,
+			`name||id||_timestamp
+||2||2020-08-02T21:23:56Z
+b||2||2020-08-02T21:23:57Z
+c||3||2020-08-02T21:23:58Z
+a||1||2020-08-02T21:23:59Z`,
+		},
+		{
+			"By primary keys keeps receiving order of equal keys",
+			&FileOrdering{Rows: PrimaryKeysRowsOrder},
+			`{"_timestamp":"2020-08-02T21:23:59Z","id":1,"name":"a"}
+{"_timestamp":"2020-08-02T21:23:57Z","id":2,"name":"b"}
+{"_timestamp":"2020-08-02T21:23:56Z","id":2}
+{"_timestamp":"2020-08-02T21:23:58Z","id":3,"name":"c"}`,
+			`_timestamp||id||name
+2020-08-02T21:23:59Z||1||a
+2020-08-02T21:23:57Z||2||b
+2020-08-02T21:23:56Z||2||
+2020-08-02T21:23:58Z||3||c`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewProcessor("events", []string{}, Default, map[string]bool{"id": true}, nil, nil)
+			require.NoError(t, err)
+			require.NoError(t, p.SetFileOrdering(tt.ordering))
+
+			files, failed, err := p.ProcessFilePayload("file", payload, true, parsers.ParseJson)
+			require.NoError(t, err)
+			require.Empty(t, failed)
+			require.Len(t, files, 1)
+
+			jsonBytes, _ := files["events"].GetPayloadBytes(JsonMarshallerInstance)
+			require.Equal(t, tt.expectedJson, string(jsonBytes))
+			csvBytes, _ := files["events"].GetPayloadBytes(CsvMarshallerInstance)
+			require.Equal(t, tt.expectedCsv, string(csvBytes))
+		})
+	}
+}
+
+func TestFileOrderingValidation(t *testing.T) {
+	_, err := newFileOrderer(&FileOrdering{Rows: PrimaryKeysRowsOrder}, map[string]bool{})
+	require.Error(t, err)
+	_, err = newFileOrderer(&FileOrdering{Rows: "random"}, nil)
+	require.True(t, strings.Contains(err.Error(), "Unknown file ordering rows"))
+	_, err = newFileOrderer(&FileOrdering{Columns: []string{"a", "a"}}, nil)
+	require.Error(t, err)
+}
@@ -4,12 +4,21 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
-	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 const maxStringLength = 8192
 
+//specialChars are replaced with '_' in keys
+var specialChars = [utf8.RuneSelf]bool{}
+
+func init() {
+	for _, c := range "()$[]{}@!#%&,.;:^-" {
+		specialChars[c] = true
+	}
+}
+
 type Flattener struct {
 	omitNilValues   bool
 	toLowerCaseKeys bool
@@ -48,7 +57,7 @@ func NewFlattener() *Flattener {
 //from {"$key1":1} to {"_key1":1}
 //from {"(key1)":1} to {"_key1_":1}
 func (f *Flattener) FlattenObject(json map[string]interface{}) (map[string]interface{}, error) {
-	flattenMap := make(map[string]interface{})
+	flattenMap := make(map[string]interface{}, len(json))
 
 	err := f.flatten("", json, flattenMap)
 	if err != nil {
@@ -59,15 +68,73 @@ func (f *Flattener) FlattenObject(json map[string]interface{}) (map[string]inter
 
 }
 
+//IsFlat return true if the object doesn't have nested objects and arrays
+func IsFlat(object map[string]interface{}) bool {
+	for _, value := range object {
+		switch value.(type) {
+		case nil, string, bool, json.Number, float64, int, int64:
+		default:
+			//maps, slices and other types are checked with reflection in flatten
+			return false
+		}
+	}
+	return true
+}
+
+//CopyFlat return copy of the flat object (see IsFlat) with normalized keys and cut strings (nil values are omitted):
+//the same result as FlattenObject in one pass without recursion and reflection
+func (f *Flattener) CopyFlat(object map[string]interface{}) map[string]interface{} {
+	flattenMap := make(map[string]interface{}, len(object))
+	for key, value := range object {
+		if value == nil && f.omitNilValues {
+			continue
+		}
+		//strings aren't boxed again if they aren't cut
+		if str, ok := value.(string); ok && len(str) > maxStringLength {
+			value = limitLength(str)
+		}
+		flattenMap[f.normalizeKey(key)] = value
+	}
+	return flattenMap
+}
+
+//normalizeKey return lower case key without special chars. Already normalized keys are returned without allocations
+func (f *Flattener) normalizeKey(key string) string {
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if c >= utf8.RuneSelf || (f.toLowerCaseKeys && c >= 'A' && c <= 'Z') || specialChars[c] {
+			if f.toLowerCaseKeys {
+				key = strings.ToLower(key)
+			}
+			return f.specialCharsReplacer.Replace(key)
+		}
+	}
+	return key
+}
+
 //recursive function for flatten key (if value is inner object -> recursion call)
 //makes all keys to lower case
 //remove $, (, ) from all keys
 //cut strings to maxStringLength size
 func (f *Flattener) flatten(key string, value interface{}, destination map[string]interface{}) error {
-	if f.toLowerCaseKeys {
-		key = strings.ToLower(key)
+	key = f.normalizeKey(key)
+
+	switch typed := value.(type) {
+	case string:
+		if len(typed) > maxStringLength {
+			value = limitLength(typed)
+		}
+		destination[key] = value
+		return nil
+	case bool, json.Number, float64, int64:
+		destination[key] = value
+		return nil
+	case nil:
+		if !f.omitNilValues {
+			destination[key] = value
+		}
+		return nil
 	}
-	key = f.specialCharsReplacer.Replace(key)
 
 	t := reflect.ValueOf(value)
 	switch t.Kind() {
@@ -88,18 +155,9 @@ func (f *Flattener) flatten(key string, value interface{}, destination map[strin
 				return fmt.Errorf("Error flatten object with key %s_%s: %v", key, k, err)
 			}
 		}
-	case reflect.Bool:
-		destination[key] = strconv.FormatBool(value.(bool))
 	default:
 		if !f.omitNilValues || value != nil {
-			switch value.(type) {
-			case string:
-				strValue, _ := value.(string)
-
-				destination[key] = limitLength(strValue)
-			default:
-				destination[key] = value
-			}
+			destination[key] = value
 		}
 	}
 
@@ -41,7 +41,7 @@ func TestFlattenObject(t *testing.T) {
 				"key10": true,
 			},
 			map[string]interface{}{"key1": "value1", "key2": 2, "key4": "[]", "key5": "[1,2,3,4]", "key7": "[1,0.8884213]", "key8_sub_key1": "event",
-				"key8_sub_key2": 123123.3123, "key8_sub_key3_sub_sub_key1": "[\"1,\",\"2.\"]", "key10": "true"},
+				"key8_sub_key2": 123123.3123, "key8_sub_key3_sub_sub_key1": "[\"1,\",\"2.\"]", "key10": true},
 		},
 		{
 			"replaced special chars",
@@ -56,7 +56,7 @@ func TestFlattenObject(t *testing.T) {
 				},
 				"key10": true,
 			},
-			map[string]interface{}{"ke_y1": "value1", "ke_y2": 2, "_ke_y2": 3, "ke_y2_": 4, "_key3_": 5, "key8_sub_key1": "event", "key10": "true"},
+			map[string]interface{}{"ke_y1": "value1", "ke_y2": 2, "_ke_y2": 3, "ke_y2_": 4, "_key3_": 5, "key8_sub_key1": "event", "key10": true},
 		},
 	}
 	flattener := NewFlattener()
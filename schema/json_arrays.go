@@ -0,0 +1,92 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+//expandJsonArrays return the payload as lines with one JSON value per line:
+//1. the whole payload JSON array (it might be multi-line and without trailing \n) is expanded into a line per element
+//2. otherwise every line (ended with \n) with a JSON array is expanded into lines per element, other lines are kept
+//Elements are compacted: pretty-printed elements are written as single lines. Payload without arrays is returned as is
+func expandJsonArrays(payload []byte) ([]byte, error) {
+	if bytes.IndexByte(payload, '[') < 0 {
+		return payload, nil
+	}
+
+	trimmed := bytes.TrimSpace(payload)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var elements []json.RawMessage
+		if err := json.Unmarshal(trimmed, &elements); err == nil {
+			buf := bytes.NewBuffer(make([]byte, 0, len(trimmed)+len(elements)))
+			if err := writeElements(buf, elements); err != nil {
+				return nil, err
+			}
+			return buf.Bytes(), nil
+		}
+	}
+
+	if !hasArrayLine(payload) {
+		return payload, nil
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, len(payload)))
+	lineNumber := 0
+	for len(payload) > 0 {
+		index := bytes.IndexByte(payload, '\n')
+		if index < 0 {
+			//the last line without \n is kept as is (it isn't processed)
+			buf.Write(payload)
+			break
+		}
+
+		line := payload[:index+1]
+		payload = payload[index+1:]
+		lineNumber++
+
+		trimmedLine := bytes.TrimSpace(line)
+		if len(trimmedLine) == 0 || trimmedLine[0] != '[' {
+			buf.Write(line)
+			continue
+		}
+
+		var elements []json.RawMessage
+		if err := json.Unmarshal(trimmedLine, &elements); err != nil {
+			return nil, fmt.Errorf("Malformed JSON array in line #%d: %v", lineNumber, err)
+		}
+		if err := writeElements(buf, elements); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+//writeElements write every compacted element with \n
+func writeElements(buf *bytes.Buffer, elements []json.RawMessage) error {
+	for _, element := range elements {
+		if err := json.Compact(buf, element); err != nil {
+			return fmt.Errorf("Malformed JSON array element: %v", err)
+		}
+		buf.WriteByte('\n')
+	}
+	return nil
+}
+
+//hasArrayLine return true if any line of the payload starts with [ (leading spaces are skipped)
+func hasArrayLine(payload []byte) bool {
+	lineStart := true
+	for _, b := range payload {
+		switch {
+		case b == '\n':
+			lineStart = true
+		case lineStart && (b == ' ' || b == '\t' || b == '\r'):
+		case lineStart && b == '[':
+			return true
+		default:
+			lineStart = false
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package schema
+
+import (
+	"github.com/jitsucom/eventnative/parsers"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestExpandJsonArrays(t *testing.T) {
+	tests := []struct {
+		name     string
+		payload  string
+		expected string
+	}{
+		{"NDJSON", "{\"a\":[1,2]}\n{\"a\":3}\n", "{\"a\":[1,2]}\n{\"a\":3}\n"},
+		{"Whole file array", "[\n  {\"a\": 1},\n  {\n    \"a\": [2]\n  }\n]", "{\"a\":1}\n{\"a\":[2]}\n"},
+		{"Array lines", "[{\"a\":1},{\"a\":2}]\n{\"a\":3}\n  [{\"a\":4}]\n[]\n{\"a\":5}", "{\"a\":1}\n{\"a\":2}\n{\"a\":3}\n{\"a\":4}\n{\"a\":5}"},
+		{"Empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := expandJsonArrays([]byte(tt.payload))
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, string(actual))
+		})
+	}
+
+	_, err := expandJsonArrays([]byte("[{\"a\":1},{\"a\":2}]\n[{\"a\":\n"))
+	require.EqualError(t, err, "Malformed JSON array in line #2: unexpected end of JSON input")
+}
+
+func TestProcessFilePayloadJsonArrays(t *testing.T) {
+	p, err := NewProcessor("{{.event_type}}", []string{}, Default, map[string]bool{}, nil, nil)
+	require.NoError(t, err)
+
+	payload := []byte(`[
+  {"_timestamp": "2020-08-02T10:00:00.000000Z", "event_type": "click", "id": 1},
+  {"_timestamp": "2020-08-02T10:00:00.000000Z", "event_type": "view", "id": 2},
+  {"event_type": "broken"}
+]`)
+	files, failed, err := p.ProcessFilePayload("file", payload, false, parsers.ParseJson)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	require.Len(t, files["click"].payload, 1)
+	require.Len(t, files["view"].payload, 1)
+	require.Len(t, failed, 1)
+	require.Equal(t, `{"event_type":"broken"}`, string(failed[0].Event))
+
+	//array lines are expanded in parallel parsing too
+	p.SetParallelParsing(2, 0)
+	files, failed, err = p.ProcessFilePayload("file", []byte(`[{"_timestamp": "2020-08-02T10:00:00.000000Z", "event_type": "click", "id": 1}, {"_timestamp": "2020-08-02T10:00:00.000000Z", "event_type": "click", "id": 2}]
+{"_timestamp": "2020-08-02T10:00:00.000000Z", "event_type": "click", "id": 3}
+`), false, parsers.ParseJson)
+	require.NoError(t, err)
+	require.Empty(t, failed)
+	require.Len(t, files["click"].payload, 3)
+}
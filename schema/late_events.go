@@ -0,0 +1,109 @@
+package schema
+
+import (
+	"fmt"
+	"github.com/jitsucom/eventnative/timestamp"
+	"sync"
+	"time"
+)
+
+const (
+	LateTableAction = "table"
+	LateTagAction   = "tag"
+
+	LateTableSuffix = "_late"
+	LateColumn      = "_late"
+)
+
+//LateEvents is a configuration of late events handling. Event is late if its _timestamp is older than the table
+//watermark (max _timestamp of the table events processed by the node) by more than lateness_min
+type LateEvents struct {
+	LatenessMin int `mapstructure:"lateness_min" json:"lateness_min,omitempty" yaml:"lateness_min,omitempty"`
+	//table (default) - late events are written into <table name>_late table, tag - into the same table with _late=true column
+	Action string `mapstructure:"action" json:"action,omitempty" yaml:"action,omitempty"`
+}
+
+func (le *LateEvents) String() string {
+	action := le.Action
+	if action == "" {
+		action = LateTableAction
+	}
+	return fmt.Sprintf("older than table watermark by %d minutes, action: %s", le.LatenessMin, action)
+}
+
+type lateEvents struct {
+	sync.Mutex
+
+	lateness time.Duration
+	action   string
+	//table name -> max _timestamp
+	watermarks map[string]time.Time
+}
+
+//newLateEvents return parsed late events configuration or nil if it isn't configured
+func newLateEvents(config *LateEvents) (*lateEvents, error) {
+	if config == nil {
+		return nil, nil
+	}
+	if config.LatenessMin <= 0 {
+		return nil, fmt.Errorf("Late events lateness_min must be positive: %d", config.LatenessMin)
+	}
+
+	action := config.Action
+	switch action {
+	case "":
+		action = LateTableAction
+	case LateTableAction, LateTagAction:
+	default:
+		return nil, fmt.Errorf("Unknown late events action: %s. Available: [%s, %s]", action, LateTableAction, LateTagAction)
+	}
+
+	return &lateEvents{lateness: time.Duration(config.LatenessMin) * time.Minute, action: action, watermarks: map[string]time.Time{}}, nil
+}
+
+//SetLateEvents set late events handling. Nil config means late events aren't routed or tagged
+func (p *Processor) SetLateEvents(config *LateEvents) error {
+	parsed, err := newLateEvents(config)
+	if err != nil {
+		return err
+	}
+	p.lateEvents = parsed
+	return nil
+}
+
+//apply advance the table watermark with the object _timestamp and return table name for the object:
+//<table name>_late if the object is late and action is table. Late object is tagged with _late=true if action is tag
+func (le *lateEvents) apply(tableName string, object map[string]interface{}) string {
+	ts, ok := object[timestamp.Key].(string)
+	if !ok {
+		return tableName
+	}
+	t, err := time.Parse(timestamp.Layout, ts)
+	if err != nil {
+		return tableName
+	}
+
+	if !le.late(tableName, t) {
+		return tableName
+	}
+
+	if le.action == LateTagAction {
+		object[LateColumn] = true
+		return tableName
+	}
+	return tableName + LateTableSuffix
+}
+
+//late return true if t is older than the table watermark by more than lateness. Otherwise advance the watermark
+func (le *lateEvents) late(tableName string, t time.Time) bool {
+	le.Lock()
+	defer le.Unlock()
+
+	watermark, ok := le.watermarks[tableName]
+	if !ok || t.After(watermark) {
+		le.watermarks[tableName] = t
+		return false
+	}
+
+	return watermark.Sub(t) > le.lateness
+}
@@ -0,0 +1,211 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/metrics"
+	"sort"
+	"unicode/utf8"
+)
+
+const (
+	RejectPolicy   = "reject"
+	TruncatePolicy = "truncate"
+	FallbackPolicy = "fallback"
+
+	eventSizeLimit    = "event_size"
+	columnsLimit      = "columns"
+	stringLengthLimit = "string_length"
+
+	//approximate size of not string values
+	valueSizeBytes = 8
+)
+
+//Limits are guards against pathological events (e.g. thousands of keys) applied to flattened events. 0 - unlimited
+//Policy (default: fallback):
+//reject - event is skipped,
+//truncate - strings are cut to max_string_length, columns over max_columns are removed (system and primary key columns are kept),
+//events which still exceed max_event_size_kb are written into fallback
+//fallback - event is written into fallback
+//MaxTableColumns is checked by destinations against the warehouse table schema: truncate policy removes new columns over the limit
+type Limits struct {
+	//approximate size of flattened event: columns names and string values lengths, 8 bytes per other value
+	MaxEventSizeKb  int `mapstructure:"max_event_size_kb" json:"max_event_size_kb,omitempty" yaml:"max_event_size_kb,omitempty"`
+	MaxColumns      int `mapstructure:"max_columns" json:"max_columns,omitempty" yaml:"max_columns,omitempty"`
+	MaxStringLength int `mapstructure:"max_string_length" json:"max_string_length,omitempty" yaml:"max_string_length,omitempty"`
+	//max columns of warehouse table. 0 - warehouse columns cap (postgres and redshift: 1600, bigquery: 10000)
+	MaxTableColumns int    `mapstructure:"max_table_columns" json:"max_table_columns,omitempty" yaml:"max_table_columns,omitempty"`
+	Policy          string `mapstructure:"policy" json:"policy,omitempty" yaml:"policy,omitempty"`
+}
+
+//GetPolicy return configured policy (fallback by default) or error if it is unknown
+func (l *Limits) GetPolicy() (string, error) {
+	switch l.Policy {
+	case "":
+		return FallbackPolicy, nil
+	case RejectPolicy, TruncatePolicy, FallbackPolicy:
+		return l.Policy, nil
+	default:
+		return "", fmt.Errorf("Unknown limits policy [%s]. Available: %s, %s, %s", l.Policy, RejectPolicy, TruncatePolicy, FallbackPolicy)
+	}
+}
+
+type limiter struct {
+	maxEventSize    int
+	maxColumns      int
+	maxStringLength int
+	policy          string
+	//columns which aren't removed by truncate policy
+	kept map[string]bool
+}
+
+//newLimiter return limiter or nil if limits aren't configured
+func newLimiter(limits *Limits, pkFields map[string]bool) (*limiter, error) {
+	if limits == nil {
+		return nil, nil
+	}
+	//policy is validated even if only max_table_columns is configured
+	policy, err := limits.GetPolicy()
+	if err != nil {
+		return nil, err
+	}
+	if limits.MaxEventSizeKb <= 0 && limits.MaxColumns <= 0 && limits.MaxStringLength <= 0 {
+		return nil, nil
+	}
+
+	kept := map[string]bool{}
+	for _, field := range systemFields {
+		kept[field] = true
+	}
+	for field := range pkFields {
+		kept[field] = true
+	}
+	if limits.MaxColumns > 0 && limits.MaxColumns < len(kept) {
+		return nil, fmt.Errorf("Limits max_columns must be at least %d: system and primary key columns are always kept", len(kept))
+	}
+
+	return &limiter{
+		maxEventSize:    limits.MaxEventSizeKb * 1024,
+		maxColumns:      limits.MaxColumns,
+		maxStringLength: limits.MaxStringLength,
+		policy:          policy,
+		kept:            kept,
+	}, nil
+}
+
+//SetLimits set guards against pathological events. Nil limits mean unlimited events
+func (p *Processor) SetLimits(limits *Limits) error {
+	parsed, err := newLimiter(limits, p.pkFields)
+	if err != nil {
+		return err
+	}
+	p.limiter = parsed
+	p.limits = limits
+	return nil
+}
+
+//apply check limits and apply the policy to the flat object
+//return false if the object must be skipped (reject policy) or error if the object must be written into fallback
+func (l *limiter) apply(flatObject map[string]interface{}) (bool, error) {
+	violation := l.violation(flatObject)
+	if violation == "" {
+		return true, nil
+	}
+
+	metrics.PayloadLimitViolation(violation, l.policy)
+	switch l.policy {
+	case RejectPolicy:
+		return false, nil
+	case TruncatePolicy:
+		l.truncate(flatObject)
+		if l.maxEventSize > 0 && eventSize(flatObject) > l.maxEventSize {
+			return true, fmt.Errorf("Event size exceeds %d KB limit after truncation", l.maxEventSize/1024)
+		}
+		return true, nil
+	default:
+		return true, errors.New(l.describe(violation, flatObject))
+	}
+}
+
+//violation return the first exceeded limit name or empty string
+func (l *limiter) violation(flatObject map[string]interface{}) string {
+	if l.maxColumns > 0 && len(flatObject) > l.maxColumns {
+		return columnsLimit
+	}
+	if l.maxStringLength > 0 {
+		for _, value := range flatObject {
+			if str, ok := value.(string); ok && len(str) > l.maxStringLength {
+				return stringLengthLimit
+			}
+		}
+	}
+	if l.maxEventSize > 0 && eventSize(flatObject) > l.maxEventSize {
+		return eventSizeLimit
+	}
+
+	return ""
+}
+
+//truncate remove columns over limit (in columns names order after kept ones) and cut strings to the max length (valid UTF-8)
+func (l *limiter) truncate(flatObject map[string]interface{}) {
+	if l.maxColumns > 0 && len(flatObject) > l.maxColumns {
+		var columns []string
+		for column := range flatObject {
+			if !l.kept[column] {
+				columns = append(columns, column)
+			}
+		}
+		sort.Strings(columns)
+
+		allowed := l.maxColumns - (len(flatObject) - len(columns))
+		for _, column := range columns[allowed:] {
+			delete(flatObject, column)
+		}
+	}
+
+	if l.maxStringLength > 0 {
+		for column, value := range flatObject {
+			if str, ok := value.(string); ok && len(str) > l.maxStringLength {
+				flatObject[column] = truncateString(str, l.maxStringLength)
+			}
+		}
+	}
+}
+
+func (l *limiter) describe(violation string, flatObject map[string]interface{}) string {
+	switch violation {
+	case columnsLimit:
+		return fmt.Sprintf("Event has %d columns: it exceeds %d columns limit", len(flatObject), l.maxColumns)
+	case stringLengthLimit:
+		return fmt.Sprintf("Event has string values longer than %d bytes limit", l.maxStringLength)
+	default:
+		return fmt.Sprintf("Event size exceeds %d KB limit", l.maxEventSize/1024)
+	}
+}
+
+//eventSize return approximate size of the flat object
+func eventSize(flatObject map[string]interface{}) int {
+	size := 0
+	for column, value := range flatObject {
+		size += len(column)
+		if str, ok := value.(string); ok {
+			size += len(str)
+		} else {
+			size += valueSizeBytes
+		}
+	}
+	return size
+}
+
+//truncateString return at most maxBytes prefix of the string without cutting multibyte characters
+func truncateString(str string, maxBytes int) string {
+	if len(str) <= maxBytes {
+		return str
+	}
+
+	cut := maxBytes
+	for cut > 0 && !utf8.RuneStart(str[cut]) {
+		cut--
+	}
+	return str[:cut]
+}
@@ -0,0 +1,109 @@
+package schema
+
+import (
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestLimiterApply(t *testing.T) {
+	tests := []struct {
+		name             string
+		limits           Limits
+		input            map[string]interface{}
+		expectedAccepted bool
+		expectedErr      string
+		expected         map[string]interface{}
+	}{
+		{
+			"under limits",
+			Limits{MaxColumns: 4, MaxStringLength: 5, MaxEventSizeKb: 1},
+			map[string]interface{}{"_timestamp": "2020", "a": "abc", "b": 1},
+			true,
+			"",
+			map[string]interface{}{"_timestamp": "2020", "a": "abc", "b": 1},
+		},
+		{
+			"columns fallback",
+			Limits{MaxColumns: 4},
+			map[string]interface{}{"_timestamp": "2020", "a": 1, "b": 2, "c": 3, "d": 4},
+			true,
+			"Event has 5 columns: it exceeds 4 columns limit",
+			nil,
+		},
+		{
+			"columns reject",
+			Limits{MaxColumns: 4, Policy: RejectPolicy},
+			map[string]interface{}{"_timestamp": "2020", "a": 1, "b": 2, "c": 3, "d": 4},
+			false,
+			"",
+			nil,
+		},
+		{
+			"columns truncate keeps system and primary key columns",
+			Limits{MaxColumns: 5, Policy: TruncatePolicy},
+			map[string]interface{}{"_timestamp": "2020", "eventn_ctx_event_id": "1", "z_id": 1, "a": 1, "b": 2, "c": 3},
+			true,
+			"",
+			map[string]interface{}{"_timestamp": "2020", "eventn_ctx_event_id": "1", "z_id": 1, "a": 1, "b": 2},
+		},
+		{
+			"string truncate without cutting multibyte characters",
+			Limits{MaxStringLength: 4, Policy: TruncatePolicy},
+			map[string]interface{}{"a": "abcdef", "b": "abцd", "c": 123456},
+			true,
+			"",
+			map[string]interface{}{"a": "abcd", "b": "abц", "c": 123456},
+		},
+		{
+			"event size fallback",
+			Limits{MaxEventSizeKb: 1},
+			map[string]interface{}{"a": strings.Repeat("a", 1024)},
+			true,
+			"Event size exceeds 1 KB limit",
+			nil,
+		},
+		{
+			"event size after truncation",
+			Limits{MaxEventSizeKb: 1, MaxStringLength: 512, Policy: TruncatePolicy},
+			map[string]interface{}{"a": strings.Repeat("a", 1024), "b": strings.Repeat("b", 1024)},
+			true,
+			"Event size exceeds 1 KB limit after truncation",
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := newLimiter(&tt.limits, map[string]bool{"z_id": true})
+			require.NoError(t, err)
+
+			accepted, err := l.apply(tt.input)
+			require.Equal(t, tt.expectedAccepted, accepted)
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+			if tt.expected != nil {
+				require.Equal(t, tt.expected, tt.input)
+			}
+		})
+	}
+}
+
+func TestNewLimiter(t *testing.T) {
+	l, err := newLimiter(nil, nil)
+	require.NoError(t, err)
+	require.Nil(t, l)
+
+	l, err = newLimiter(&Limits{Policy: RejectPolicy}, nil)
+	require.NoError(t, err)
+	require.Nil(t, l)
+
+	_, err = newLimiter(&Limits{MaxColumns: 10, Policy: "drop"}, nil)
+	require.Error(t, err)
+
+	_, err = newLimiter(&Limits{MaxColumns: 3}, map[string]bool{"id": true})
+	require.Error(t, err)
+}
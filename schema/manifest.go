@@ -0,0 +1,44 @@
+package schema
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+const ManifestSuffix = ".manifest.json"
+
+//Manifest is a sidecar of a batch file (<file name>.manifest.json) which is written after the file itself,
+//so external loaders might consume the file safely: check it's complete (checksums, bytes and rows count) and create
+//the table with columns types before COPY
+type Manifest struct {
+	File       string      `json:"file"`
+	Table      string      `json:"table"`
+	Format     string      `json:"format"`
+	Delimiter  string      `json:"delimiter,omitempty"`
+	HeaderRows int         `json:"header_rows"`
+	Columns    []CsvColumn `json:"columns"`
+	Rows       int         `json:"rows"`
+	Bytes      int         `json:"bytes"`
+	MD5        string      `json:"md5"`
+	SHA256     string      `json:"sha256"`
+	CreatedAt  time.Time   `json:"created_at"`
+}
+
+//NewManifest return Manifest with checksums of payload
+func NewManifest(fileName, table, format string, payload []byte, columns []CsvColumn, rows int) *Manifest {
+	md5Sum := md5.Sum(payload)
+	sha256Sum := sha256.Sum256(payload)
+	return &Manifest{
+		File:      fileName,
+		Table:     table,
+		Format:    format,
+		Columns:   columns,
+		Rows:      rows,
+		Bytes:     len(payload),
+		MD5:       hex.EncodeToString(md5Sum[:]),
+		SHA256:    hex.EncodeToString(sha256Sum[:]),
+		CreatedAt: time.Now().UTC(),
+	}
+}
@@ -0,0 +1,140 @@
+package schema
+
+import (
+	"strings"
+)
+
+//mappingStep is a compiled mapping rule. Destination is nil for delete rules
+type mappingStep struct {
+	source      []string
+	destination []string
+	//capacities of destination nodes which are created along the destination path (len(destination) - 1)
+	capacities []int
+}
+
+//mappingPlan is a list of mapping rules compiled once per mapper: paths are split into segments beforehand,
+//no-op rules are dropped and missing destination nodes are created with known children count.
+//Steps are executed in rules order so every rule sees the result of the previous ones
+type mappingPlan struct {
+	steps []mappingStep
+	//distinct top level destination keys count. It is used for strict mapping result preallocation
+	topLevelSize int
+}
+
+//compileMappingPlan return plan of the source -> destination rules (empty destination is a delete rule)
+//if inPlace is true - rules with the same source and destination are skipped (they don't change the object)
+func compileMappingPlan(rules []*MappingRule, inPlace bool) *mappingPlan {
+	//destination node path -> distinct children keys
+	children := map[string]map[string]bool{}
+	for _, rule := range rules {
+		parts := rule.destination.Parts()
+		for i := range parts {
+			node := strings.Join(parts[:i], "/")
+			if children[node] == nil {
+				children[node] = map[string]bool{}
+			}
+			children[node][parts[i]] = true
+		}
+	}
+
+	plan := &mappingPlan{topLevelSize: len(children[""])}
+	for _, rule := range rules {
+		step := mappingStep{source: rule.source.Parts()}
+		if !rule.destination.IsEmpty() {
+			step.destination = rule.destination.Parts()
+			if inPlace && equalParts(step.source, step.destination) {
+				continue
+			}
+			step.capacities = make([]int, len(step.destination)-1)
+			for i := range step.capacities {
+				step.capacities[i] = len(children[strings.Join(step.destination[:i+1], "/")])
+			}
+		}
+		plan.steps = append(plan.steps, step)
+	}
+
+	return plan
+}
+
+//execute move values from sourceObj into destinationObj (might be the same object)
+//if value can't be set into destination (a node on the path isn't an object) - it is set as is
+func (mp *mappingPlan) execute(sourceObj, destinationObj map[string]interface{}) {
+	for i := range mp.steps {
+		step := &mp.steps[i]
+		value, ok := getAndRemove(sourceObj, step.source)
+		if !ok || step.destination == nil {
+			continue
+		}
+
+		if !set(destinationObj, step.destination, step.capacities, value) {
+			set(destinationObj, step.source, nil, value)
+		}
+	}
+}
+
+func getAndRemove(obj map[string]interface{}, path []string) (interface{}, bool) {
+	last := len(path) - 1
+	if last < 0 {
+		return nil, false
+	}
+	for i := 0; i < last; i++ {
+		sub, ok := obj[path[i]].(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		obj = sub
+	}
+
+	value, ok := obj[path[last]]
+	if ok {
+		delete(obj, path[last])
+	}
+	return value, ok
+}
+
+//set put value into the path creating missing nodes with capacities (capacities might be nil)
+//return false if a node on the path isn't an object
+func set(obj map[string]interface{}, path []string, capacities []int, value interface{}) bool {
+	if obj == nil {
+		return false
+	}
+	last := len(path) - 1
+	if last < 0 {
+		return false
+	}
+	for i := 0; i < last; i++ {
+		key := path[i]
+		node, exists := obj[key]
+		if !exists {
+			capacity := 0
+			if i < len(capacities) {
+				capacity = capacities[i]
+			}
+			sub := make(map[string]interface{}, capacity)
+			obj[key] = sub
+			obj = sub
+			continue
+		}
+
+		sub, ok := node.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		obj = sub
+	}
+
+	obj[path[last]] = value
+	return true
+}
+
+func equalParts(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,136 @@
+package schema
+
+import (
+	"github.com/jitsucom/eventnative/jsonutils"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+var benchmarkMappings = []string{
+	"/key1/subkey1 -> /key10/subkey1",
+	"/key1/subkey2 -> /key10/subkey2",
+	"/key2 -> /key11",
+	"/key3 -> ",
+	"/eventn_ctx/user/id -> /user/id",
+	"/eventn_ctx/user/email -> /user/email",
+	"/eventn_ctx/page/url -> /page/url",
+	"/src -> /src",
+}
+
+func TestCompileMappingPlan(t *testing.T) {
+	rules := []*MappingRule{
+		{source: jsonutils.NewJsonPath("/a/b"), destination: jsonutils.NewJsonPath("/x/y/z")},
+		{source: jsonutils.NewJsonPath("/a/c"), destination: jsonutils.NewJsonPath("/x/y/w")},
+		{source: jsonutils.NewJsonPath("/d"), destination: jsonutils.NewJsonPath("/x/v")},
+		{source: jsonutils.NewJsonPath("/e"), destination: jsonutils.NewJsonPath("")},
+		{source: jsonutils.NewJsonPath("/f/g"), destination: jsonutils.NewJsonPath("/f/g")},
+	}
+
+	inPlace := compileMappingPlan(rules, true)
+	require.Equal(t, 4, len(inPlace.steps), "No-op rule must be skipped in place")
+	require.Equal(t, []int{2, 2}, inPlace.steps[0].capacities)
+	require.Equal(t, []int{2}, inPlace.steps[2].capacities)
+	require.Nil(t, inPlace.steps[3].destination, "Delete rule must have nil destination")
+	require.Equal(t, 2, inPlace.topLevelSize)
+
+	strict := compileMappingPlan(rules, false)
+	require.Equal(t, 5, len(strict.steps), "No-op rule must be kept if destination is another object")
+}
+
+//mappedSink keeps benchmark results on the heap as Mapper results are
+var mappedSink map[string]interface{}
+
+func benchmarkObject() map[string]interface{} {
+	return map[string]interface{}{
+		"key1": map[string]interface{}{"subkey1": 123, "subkey2": "value"},
+		"key2": "value",
+		"key3": 999,
+		"eventn_ctx": map[string]interface{}{
+			"user":        map[string]interface{}{"id": "id1", "email": "a@b.c", "anonymous_id": "anon1"},
+			"page":        map[string]interface{}{"url": "https://jitsu.com", "title": "title"},
+			"event_id":    "event1",
+			"user_agent":  "Mozilla/5.0",
+			"utc_time":    "2020-06-16T23:00:00.000000Z",
+			"local_tz_ms": 180,
+		},
+		"src":                 "api",
+		"_timestamp":          "2020-06-16T23:00:00.000000Z",
+		"eventn_ctx_event_id": "event1",
+	}
+}
+
+//applyJsonPathRules is a mapping without compilation (rule by rule with JsonPath). It is used as a benchmarks baseline
+func applyJsonPathRules(sourceObj, destinationObj map[string]interface{}, rules []*MappingRule) {
+	for _, rule := range rules {
+		value, ok := rule.source.GetAndRemove(sourceObj)
+		if ok {
+			if rule.destination.IsEmpty() {
+				continue
+			}
+
+			if !rule.destination.Set(destinationObj, value) {
+				rule.source.Set(destinationObj, value)
+			}
+		}
+	}
+}
+
+func benchmarkRules() []*MappingRule {
+	var rules []*MappingRule
+	for _, mapping := range benchmarkMappings {
+		parts := strings.Split(strings.ReplaceAll(mapping, " ", ""), "->")
+		rules = append(rules, &MappingRule{source: jsonutils.NewJsonPath(parts[0]), destination: jsonutils.NewJsonPath(parts[1])})
+	}
+	return rules
+}
+
+func BenchmarkFieldMapper(b *testing.B) {
+	b.Run("json_path_rules", func(b *testing.B) {
+		rules := benchmarkRules()
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			object := benchmarkObject()
+			applyJsonPathRules(object, object, rules)
+			mappedSink = object
+		}
+	})
+	b.Run("compiled_plan", func(b *testing.B) {
+		mapper, _, err := NewFieldMapper(Default, benchmarkMappings)
+		require.NoError(b, err)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			mappedSink, _ = mapper.Map(benchmarkObject())
+		}
+	})
+}
+
+func BenchmarkStrictFieldMapper(b *testing.B) {
+	b.Run("json_path_rules", func(b *testing.B) {
+		rules := benchmarkRules()
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			object := benchmarkObject()
+			mappedObject := map[string]interface{}{}
+			applyJsonPathRules(object, mappedObject, rules)
+			for _, field := range systemFields {
+				if val, ok := object[field]; ok {
+					mappedObject[field] = val
+				}
+			}
+			mappedSink = mappedObject
+		}
+	})
+	b.Run("compiled_plan", func(b *testing.B) {
+		mapper, _, err := NewFieldMapper(Strict, benchmarkMappings)
+		require.NoError(b, err)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			mappedSink, _ = mapper.Map(benchmarkObject())
+		}
+	})
+}
@@ -3,6 +3,7 @@ package schema
 import (
 	"bytes"
 	"encoding/json"
+	"sort"
 )
 
 const quotaByteValue = 34
@@ -18,9 +19,55 @@ type Marshaller interface {
 type JsonMarshaller struct {
 }
 
-//Marshal object as json
+//Marshal object as json. If fields are provided - keys are written in fields order and then other keys in alphabetical order
 func (jm JsonMarshaller) Marshal(fields []string, object map[string]interface{}) ([]byte, error) {
-	return json.Marshal(object)
+	if len(fields) == 0 || object == nil {
+		return json.Marshal(object)
+	}
+
+	buf := bytes.Buffer{}
+	buf.WriteByte('{')
+	written := map[string]bool{}
+	writeField := func(key string, value interface{}) error {
+		if len(written) > 0 {
+			buf.WriteByte(',')
+		}
+		written[key] = true
+		keyBytes, err := json.Marshal(key)
+		if err != nil {
+			return err
+		}
+		valueBytes, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		buf.Write(keyBytes)
+		buf.WriteByte(':')
+		buf.Write(valueBytes)
+		return nil
+	}
+
+	for _, field := range fields {
+		if value, ok := object[field]; ok && !written[field] {
+			if err := writeField(field, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	var rest []string
+	for key := range object {
+		if !written[key] {
+			rest = append(rest, key)
+		}
+	}
+	sort.Strings(rest)
+	for _, key := range rest {
+		if err := writeField(key, object[key]); err != nil {
+			return nil, err
+		}
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
 }
 
 func (jm JsonMarshaller) NeedHeader() bool {
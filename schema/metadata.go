@@ -0,0 +1,156 @@
+package schema
+
+import (
+	"fmt"
+	"github.com/jitsucom/eventnative/enrichment"
+	"strings"
+	"time"
+)
+
+const (
+	IngestedAtMetadata    = "ingested_at"
+	ServerMetadata        = "server"
+	TokenIdMetadata       = "token_id"
+	SourceTypeMetadata    = "source_type"
+	ConfigVersionMetadata = "config_version"
+)
+
+//defaultMetadataColumns are default column names of metadata
+var defaultMetadataColumns = map[string]string{
+	IngestedAtMetadata:    "_ingested_at",
+	ServerMetadata:        "_server",
+	TokenIdMetadata:       "_token_id",
+	SourceTypeMetadata:    "_source_type",
+	ConfigVersionMetadata: "_config_version",
+}
+
+//MetadataColumns is a configuration of metadata columns which are injected into every processed object:
+//processing time (UTC), server name, API token id, source type (src: js, api, source, segment, ga, etc.) and the destination
+//processing configuration version. Empty column name means the default one. Metadata overwrites event fields with the same names
+type MetadataColumns struct {
+	IngestedAt    string `mapstructure:"ingested_at" json:"ingested_at,omitempty" yaml:"ingested_at,omitempty"`
+	Server        string `mapstructure:"server" json:"server,omitempty" yaml:"server,omitempty"`
+	TokenId       string `mapstructure:"token_id" json:"token_id,omitempty" yaml:"token_id,omitempty"`
+	SourceType    string `mapstructure:"source_type" json:"source_type,omitempty" yaml:"source_type,omitempty"`
+	ConfigVersion string `mapstructure:"config_version" json:"config_version,omitempty" yaml:"config_version,omitempty"`
+	//metadata which isn't injected: ingested_at, server, token_id, source_type or config_version
+	Exclude []string `mapstructure:"exclude" json:"exclude,omitempty" yaml:"exclude,omitempty"`
+}
+
+func (mc *MetadataColumns) String() string {
+	columns, err := mc.columns()
+	if err != nil {
+		return err.Error()
+	}
+
+	var pairs []string
+	for _, name := range []string{IngestedAtMetadata, ServerMetadata, TokenIdMetadata, SourceTypeMetadata, ConfigVersionMetadata} {
+		if column, ok := columns[name]; ok {
+			pairs = append(pairs, name+": "+column)
+		}
+	}
+	return strings.Join(pairs, ", ")
+}
+
+//columns return column names of injected metadata
+func (mc *MetadataColumns) columns() (map[string]string, error) {
+	columns := map[string]string{}
+	for name, column := range map[string]string{
+		IngestedAtMetadata:    mc.IngestedAt,
+		ServerMetadata:        mc.Server,
+		TokenIdMetadata:       mc.TokenId,
+		SourceTypeMetadata:    mc.SourceType,
+		ConfigVersionMetadata: mc.ConfigVersion,
+	} {
+		if column == "" {
+			column = defaultMetadataColumns[name]
+		}
+		columns[name] = column
+	}
+
+	for _, name := range mc.Exclude {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("Unknown metadata column: %s. Available: [%s, %s, %s, %s, %s]", name, IngestedAtMetadata, ServerMetadata, TokenIdMetadata, SourceTypeMetadata, ConfigVersionMetadata)
+		}
+		delete(columns, name)
+	}
+
+	used := map[string]string{}
+	for name, column := range columns {
+		if other, ok := used[column]; ok {
+			return nil, fmt.Errorf("Metadata %s and %s have the same column name: %s", name, other, column)
+		}
+		used[column] = name
+	}
+	return columns, nil
+}
+
+//metadata injects metadata columns into flat objects. Empty column means that it isn't injected
+type metadata struct {
+	ingestedAt    string
+	server        string
+	tokenId       string
+	sourceType    string
+	configVersion string
+
+	serverName string
+	version    int
+	now        func() time.Time
+}
+
+//newMetadata return parsed metadata columns configuration or nil if it isn't configured
+func newMetadata(config *MetadataColumns, serverName string, version int) (*metadata, error) {
+	if config == nil {
+		return nil, nil
+	}
+
+	columns, err := config.columns()
+	if err != nil {
+		return nil, err
+	}
+
+	return &metadata{
+		ingestedAt:    columns[IngestedAtMetadata],
+		server:        columns[ServerMetadata],
+		tokenId:       columns[TokenIdMetadata],
+		sourceType:    columns[SourceTypeMetadata],
+		configVersion: columns[ConfigVersionMetadata],
+		serverName:    serverName,
+		version:       version,
+		now:           time.Now,
+	}, nil
+}
+
+//SetMetadataColumns configure metadata columns injection into every processed object (raw events aren't changed)
+//serverName and configVersion are written into server and config_version columns (version isn't written if it is 0)
+func (p *Processor) SetMetadataColumns(config *MetadataColumns, serverName string, configVersion int) error {
+	parsed, err := newMetadata(config, serverName, configVersion)
+	if err != nil {
+		return err
+	}
+	p.metadata = parsed
+	return nil
+}
+
+//apply put metadata of the input object into the flat object. Token and source are read from the input object
+//because mapping might remove them
+func (m *metadata) apply(input, flatObject map[string]interface{}) {
+	if m.ingestedAt != "" {
+		flatObject[m.ingestedAt] = m.now().UTC()
+	}
+	if m.server != "" {
+		flatObject[m.server] = m.serverName
+	}
+	if m.tokenId != "" || m.sourceType != "" {
+		ctx := enrichment.NewEventContext(input)
+		if m.tokenId != "" && ctx.TokenId != "" {
+			flatObject[m.tokenId] = ctx.TokenId
+		}
+		if m.sourceType != "" && ctx.Source != "" {
+			flatObject[m.sourceType] = ctx.Source
+		}
+	}
+	if m.configVersion != "" && m.version > 0 {
+		flatObject[m.configVersion] = int64(m.version)
+	}
+}
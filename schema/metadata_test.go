@@ -0,0 +1,57 @@
+package schema
+
+import (
+	"github.com/jitsucom/eventnative/typing"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestProcessFactMetadataColumns(t *testing.T) {
+	ingestedAt := time.Date(2020, 10, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name            string
+		config          *MetadataColumns
+		expectedObject  map[string]interface{}
+		expectedColumns Columns
+	}{
+		{
+			"Default names",
+			&MetadataColumns{},
+			map[string]interface{}{"name": "a", "_ingested_at": ingestedAt, "_server": "node1", "_source_type": "api", "_config_version": int64(3)},
+			Columns{"_ingested_at": NewColumn(typing.TIMESTAMP), "_server": NewColumn(typing.STRING), "_source_type": NewColumn(typing.STRING), "_config_version": NewColumn(typing.INT64)},
+		},
+		{
+			"Custom names and exclude",
+			&MetadataColumns{Server: "name", SourceType: "meta_src", Exclude: []string{IngestedAtMetadata, ConfigVersionMetadata}},
+			map[string]interface{}{"name": "node1", "meta_src": "api"},
+			Columns{"name": NewColumn(typing.STRING), "meta_src": NewColumn(typing.STRING)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewProcessor("events", []string{}, Default, map[string]bool{}, nil, nil)
+			require.NoError(t, err)
+			require.NoError(t, p.SetMetadataColumns(tt.config, "node1", 3))
+			p.metadata.now = func() time.Time { return ingestedAt }
+
+			table, object, err := p.ProcessFact(map[string]interface{}{"_timestamp": "2020-10-01T09:59:00.000000Z", "name": "a", "src": "api"})
+			require.NoError(t, err)
+			delete(object, "_timestamp")
+			delete(object, "src")
+			require.Equal(t, tt.expectedObject, map[string]interface{}(object))
+			for name, column := range tt.expectedColumns {
+				require.Equal(t, column, table.Columns[name], name)
+			}
+		})
+	}
+}
+
+func TestMetadataColumnsErrors(t *testing.T) {
+	p := &Processor{}
+	require.NoError(t, p.SetMetadataColumns(nil, "node1", 1))
+	require.Nil(t, p.metadata)
+
+	require.Error(t, p.SetMetadataColumns(&MetadataColumns{Exclude: []string{"unknown"}}, "node1", 1))
+	require.Error(t, p.SetMetadataColumns(&MetadataColumns{Server: "_token_id"}, "node1", 1))
+}
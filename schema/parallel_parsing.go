@@ -0,0 +1,106 @@
+package schema
+
+import (
+	"bytes"
+	"github.com/jitsucom/eventnative/events"
+	"sync"
+)
+
+//parseBatchSize is a count of lines which are parsed by one worker at once
+const parseBatchSize = 256
+
+//parsedBatch is a range of payload lines with parsed objects. done is closed when all lines have been parsed
+type parsedBatch struct {
+	lines   [][]byte
+	objects []map[string]interface{}
+	errs    []error
+	done    chan struct{}
+}
+
+//SetParallelParsing enable pipelined ProcessFilePayload mode for payloads with size >= minPayloadSize:
+//lines are parsed by workers concurrently, objects are processed and merged into tables by one consumer in the lines order
+//workers <= 1 - lines are parsed sequentially
+func (p *Processor) SetParallelParsing(workers, minPayloadSize int) {
+	p.parseWorkers = workers
+	p.parseMinPayloadSize = minPayloadSize
+}
+
+//processFilePayloadParallel parse payload lines with worker pool and process them in order. Semantics are the same as
+//sequential ProcessFilePayload: parsing error is returned as is, processing errors are returned if breakOnError is true
+//or are put into failed facts
+func (p *Processor) processFilePayloadParallel(fileName string, payload []byte, breakOnError bool, parseFunc func([]byte) (map[string]interface{}, error)) (map[string]*ProcessedFile, []*events.FailedFact, error) {
+	batches := splitIntoBatches(payload)
+
+	jobs := make(chan *parsedBatch)
+	stop := make(chan struct{})
+	wg := &sync.WaitGroup{}
+	for i := 0; i < p.parseWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range jobs {
+				for i, line := range batch.lines {
+					batch.objects[i], batch.errs[i] = parseFunc(line)
+				}
+				close(batch.done)
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for _, batch := range batches {
+			select {
+			case jobs <- batch:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	//stop feeding workers if consumer returns before the end of payload
+	defer func() {
+		close(stop)
+		wg.Wait()
+	}()
+
+	result := newPayloadResult(fileName)
+	for _, batch := range batches {
+		<-batch.done
+		for i, line := range batch.lines {
+			if batch.errs[i] != nil {
+				return nil, nil, batch.errs[i]
+			}
+
+			if err := p.processLine(result, line, batch.objects[i], breakOnError); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	return p.orderFiles(result.filePerTable), result.failedFacts, nil
+}
+
+//splitIntoBatches return payload lines (with \n) in batches of parseBatchSize. Last line without \n is skipped as in
+//sequential ProcessFilePayload
+func splitIntoBatches(payload []byte) []*parsedBatch {
+	var batches []*parsedBatch
+	var current *parsedBatch
+	for len(payload) > 0 {
+		index := bytes.IndexByte(payload, '\n')
+		if index < 0 {
+			break
+		}
+
+		if current == nil || len(current.lines) == parseBatchSize {
+			current = &parsedBatch{lines: make([][]byte, 0, parseBatchSize), done: make(chan struct{})}
+			batches = append(batches, current)
+		}
+		current.lines = append(current.lines, payload[:index+1])
+		payload = payload[index+1:]
+	}
+
+	for _, batch := range batches {
+		batch.objects = make([]map[string]interface{}, len(batch.lines))
+		batch.errs = make([]error, len(batch.lines))
+	}
+	return batches
+}
@@ -0,0 +1,148 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+)
+
+//Granularity is a time partitioning granularity of table names
+type Granularity string
+
+const (
+	HOUR  Granularity = "hour"
+	DAY   Granularity = "day"
+	WEEK  Granularity = "week"
+	MONTH Granularity = "month"
+)
+
+//partitionHelpers is a table name template function name -> granularity
+var partitionHelpers = map[string]Granularity{
+	"hourly":  HOUR,
+	"daily":   DAY,
+	"weekly":  WEEK,
+	"monthly": MONTH,
+}
+
+//templateFunctions are available in table name templates:
+//{{hourly ._timestamp}} -> 2020_08_02_18, {{daily ._timestamp}} -> 2020_08_02,
+//{{weekly ._timestamp}} -> 2020_w31 (ISO 8601 year and week), {{monthly ._timestamp}} -> 2020_08,
+//{{partition "day" ._timestamp}} -> 2020_08_02
+var templateFunctions = template.FuncMap{
+	"hourly":  HOUR.Format,
+	"daily":   DAY.Format,
+	"weekly":  WEEK.Format,
+	"monthly": MONTH.Format,
+	"partition": func(granularity string, t time.Time) (string, error) {
+		g, err := GranularityFromString(granularity)
+		if err != nil {
+			return "", err
+		}
+		return g.Format(t), nil
+	},
+}
+
+//GranularityFromString return Granularity or error if the value is unknown
+func GranularityFromString(value string) (Granularity, error) {
+	g := Granularity(strings.ToLower(strings.TrimSpace(value)))
+	switch g {
+	case HOUR, DAY, WEEK, MONTH:
+		return g, nil
+	default:
+		return "", fmt.Errorf("Unknown partition granularity [%s]. Available: %s, %s, %s, %s", value, HOUR, DAY, WEEK, MONTH)
+	}
+}
+
+//Format return zero-padded partition of t: 2020_08_02_18 (hour), 2020_08_02 (day), 2020_w31 (ISO week), 2020_08 (month)
+func (g Granularity) Format(t time.Time) string {
+	switch g {
+	case HOUR:
+		return t.Format("2006_01_02_15")
+	case DAY:
+		return t.Format("2006_01_02")
+	case WEEK:
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%04d_w%02d", year, week)
+	default:
+		return t.Format("2006_01")
+	}
+}
+
+//TemplateGranularities return distinct granularities of partition helpers which are used in the table name template
+func TemplateGranularities(expression string) ([]Granularity, error) {
+	tmpl, err := template.New("table name extract").Funcs(templateFunctions).Parse(expression)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing table name template %v", err)
+	}
+
+	found := map[Granularity]bool{}
+	var granularities []Granularity
+	var walkErr error
+	walkCommands(tmpl.Tree.Root, func(command *parse.CommandNode) {
+		if len(command.Args) == 0 {
+			return
+		}
+		identifier, ok := command.Args[0].(*parse.IdentifierNode)
+		if !ok {
+			return
+		}
+
+		g, ok := partitionHelpers[identifier.Ident]
+		if identifier.Ident == "partition" && len(command.Args) > 1 {
+			//only constant granularity can be checked
+			if str, isString := command.Args[1].(*parse.StringNode); isString {
+				g, walkErr = GranularityFromString(str.Text)
+				ok = walkErr == nil
+			}
+		}
+		if ok && !found[g] {
+			found[g] = true
+			granularities = append(granularities, g)
+		}
+	})
+	if walkErr != nil {
+		return nil, walkErr
+	}
+
+	return granularities, nil
+}
+
+func walkCommands(node parse.Node, f func(command *parse.CommandNode)) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			walkCommands(child, f)
+		}
+	case *parse.ActionNode:
+		walkCommands(n.Pipe, f)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, command := range n.Cmds {
+			walkCommands(command, f)
+		}
+	case *parse.CommandNode:
+		f(n)
+		for _, arg := range n.Args {
+			walkCommands(arg, f)
+		}
+	case *parse.IfNode:
+		walkBranch(&n.BranchNode, f)
+	case *parse.RangeNode:
+		walkBranch(&n.BranchNode, f)
+	case *parse.WithNode:
+		walkBranch(&n.BranchNode, f)
+	}
+}
+
+func walkBranch(branch *parse.BranchNode, f func(command *parse.CommandNode)) {
+	walkCommands(branch.Pipe, f)
+	walkCommands(branch.List, f)
+	walkCommands(branch.ElseList, f)
+}
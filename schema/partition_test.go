@@ -0,0 +1,59 @@
+package schema
+
+import (
+	"github.com/jitsucom/eventnative/timestamp"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestGranularityFormat(t *testing.T) {
+	tests := []struct {
+		name        string
+		granularity Granularity
+		input       string
+		expected    string
+	}{
+		{"hour", HOUR, "2020-08-02T08:23:58.057807Z", "2020_08_02_08"},
+		{"day", DAY, "2020-08-02T18:23:58.057807Z", "2020_08_02"},
+		{"week", WEEK, "2020-08-02T18:23:58.057807Z", "2020_w31"},
+		{"week zero-padding", WEEK, "2020-02-03T18:23:58.057807Z", "2020_w06"},
+		{"ISO week belongs to previous year", WEEK, "2021-01-02T18:23:58.057807Z", "2020_w53"},
+		{"ISO week belongs to next year", WEEK, "2019-12-30T18:23:58.057807Z", "2020_w01"},
+		{"month", MONTH, "2020-01-02T18:23:58.057807Z", "2020_01"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input, err := time.Parse(timestamp.Layout, tt.input)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, tt.granularity.Format(input))
+		})
+	}
+}
+
+func TestTemplateGranularities(t *testing.T) {
+	tests := []struct {
+		name        string
+		template    string
+		expected    []Granularity
+		expectedErr string
+	}{
+		{"constant", "events", nil, ""},
+		{"Format isn't a partition helper", `events_{{._timestamp.Format "2006_01"}}`, nil, ""},
+		{"helper", `{{.event_type}}_{{daily ._timestamp}}`, []Granularity{DAY}, ""},
+		{"partition function", `events_{{partition "Week" ._timestamp}}`, []Granularity{WEEK}, ""},
+		{"helpers in branches", `{{if .event_type}}{{hourly ._timestamp}}{{else}}{{monthly ._timestamp}}{{end}}_{{hourly ._timestamp}}`, []Granularity{HOUR, MONTH}, ""},
+		{"unknown granularity", `events_{{partition "year" ._timestamp}}`, nil, "Unknown partition granularity [year]. Available: hour, day, week, month"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := TemplateGranularities(tt.template)
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/hashing"
+	"sort"
+	"strings"
+)
+
+const (
+	DefaultPartitionColumn = "_partition"
+
+	//primary key values of composite keys are joined with it
+	pkPartitionKeyDelimiter = "|"
+)
+
+//PkPartitioning is a configuration of output partitioning by primary key fields hash. Partition index is written into
+//the partition column so it can match downstream consumers partitioning (e.g. murmur2 is Kafka default partitioner hash)
+type PkPartitioning struct {
+	Partitions int `mapstructure:"partitions" json:"partitions,omitempty" yaml:"partitions,omitempty"`
+	//fnv (default), murmur2, murmur3 or xxhash
+	Hash string `mapstructure:"hash" json:"hash,omitempty" yaml:"hash,omitempty"`
+	//default _partition
+	Column string `mapstructure:"column" json:"column,omitempty" yaml:"column,omitempty"`
+}
+
+func (pp *PkPartitioning) String() string {
+	hash := pp.Hash
+	if hash == "" {
+		hash = hashing.Default
+	}
+	column := pp.Column
+	if column == "" {
+		column = DefaultPartitionColumn
+	}
+	return fmt.Sprintf("%d partitions by %s hash into %s column", pp.Partitions, hash, column)
+}
+
+type pkPartitioner struct {
+	partitioner *hashing.Partitioner
+	partitions  int
+	column      string
+	//sorted primary key fields
+	fields []string
+}
+
+//newPkPartitioner return parsed pk partitioning or nil if it isn't configured
+func newPkPartitioner(config *PkPartitioning, pkFields map[string]bool) (*pkPartitioner, error) {
+	if config == nil {
+		return nil, nil
+	}
+	if config.Partitions <= 0 {
+		return nil, fmt.Errorf("Primary key partitioning partitions must be positive: %d", config.Partitions)
+	}
+
+	fields := PkToFieldsArray(pkFields)
+	if len(fields) == 0 {
+		return nil, errors.New("Primary key partitioning requires primary_key_fields")
+	}
+	sort.Strings(fields)
+
+	partitioner, err := hashing.NewPartitioner(config.Hash)
+	if err != nil {
+		return nil, fmt.Errorf("Primary key partitioning: %v", err)
+	}
+
+	column := config.Column
+	if column == "" {
+		column = DefaultPartitionColumn
+	}
+	if pkFields[column] {
+		return nil, fmt.Errorf("Primary key partitioning column [%s] can't be a primary key field", column)
+	}
+
+	return &pkPartitioner{partitioner: partitioner, partitions: config.Partitions, column: column, fields: fields}, nil
+}
+
+//SetPkPartitioning set output partitioning by primary key fields hash. Nil config means output isn't partitioned
+func (p *Processor) SetPkPartitioning(config *PkPartitioning) error {
+	parsed, err := newPkPartitioner(config, p.pkFields)
+	if err != nil {
+		return err
+	}
+	p.pkPartitioner = parsed
+	return nil
+}
+
+//apply put partition index of the primary key value into the partition column
+//key is the primary key field value (values of composite keys in field names order joined with '|')
+//return err if any of primary key fields is missing
+func (pp *pkPartitioner) apply(flatObject map[string]interface{}) error {
+	values := make([]string, 0, len(pp.fields))
+	for _, field := range pp.fields {
+		value, ok := flatObject[field]
+		if !ok || value == nil {
+			return fmt.Errorf("Error computing primary key partition: [%s] field is missing", field)
+		}
+		values = append(values, fmt.Sprint(value))
+	}
+
+	flatObject[pp.column] = pp.partitioner.Partition([]byte(strings.Join(values, pkPartitionKeyDelimiter)), pp.partitions)
+	return nil
+}
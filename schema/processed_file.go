@@ -2,7 +2,6 @@ package schema
 
 import (
 	"bytes"
-	"github.com/jitsucom/eventnative/logging"
 	"strings"
 )
 
@@ -12,6 +11,9 @@ type ProcessedFile struct {
 	DataSchema *Table
 
 	payload []map[string]interface{}
+	//true if file ordering is configured: columns are written in columnsOrder and then in alphabetical order
+	stableColumns bool
+	columnsOrder  []string
 }
 
 //GetPayload return payload as is
@@ -19,6 +21,11 @@ func (pf ProcessedFile) GetPayload() []map[string]interface{} {
 	return pf.payload
 }
 
+//SetPayload replace payload (e.g. with objects which have passed table columns limit)
+func (pf *ProcessedFile) SetPayload(payload []map[string]interface{}) {
+	pf.payload = payload
+}
+
 //GetPayloadLen return count of rows(objects)
 func (pf ProcessedFile) GetPayloadLen() int {
 	return len(pf.payload)
@@ -32,14 +39,17 @@ func (pf ProcessedFile) GetPayloadBytes(marshaller Marshaller) ([]byte, int) {
 	var fields []string
 	//for csv writers using || delimiter
 	if marshaller.NeedHeader() {
-		fields = pf.DataSchema.Columns.Header()
+		fields = pf.header()
 		buf = bytes.NewBuffer([]byte(strings.Join(fields, "||")))
+	} else if pf.stableColumns {
+		//json objects keys are written in fields order
+		fields = pf.header()
 	}
 
 	for _, object := range pf.payload {
 		objectBytes, err := marshaller.Marshal(fields, object)
 		if err != nil {
-			logging.Error("Error marshaling object in processed file:", err)
+			schemaLogger.Errorf("Error marshaling object in processed file: %v", err)
 		} else {
 			if buf == nil {
 				buf = bytes.NewBuffer(objectBytes)
@@ -52,3 +62,11 @@ func (pf ProcessedFile) GetPayloadBytes(marshaller Marshaller) ([]byte, int) {
 
 	return buf.Bytes(), len(pf.payload)
 }
+
+//header return table columns in configured order if file ordering is configured
+func (pf ProcessedFile) header() []string {
+	if pf.stableColumns {
+		return orderedHeader(pf.DataSchema.Columns, pf.columnsOrder)
+	}
+	return pf.DataSchema.Columns.Header()
+}
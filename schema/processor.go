@@ -4,45 +4,97 @@ import (
 	"bufio"
 	"bytes"
 	"fmt"
+	"github.com/jitsucom/eventnative/compression"
 	"github.com/jitsucom/eventnative/enrichment"
 	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/filters"
 	"github.com/jitsucom/eventnative/logging"
 	"github.com/jitsucom/eventnative/maputils"
 	"github.com/jitsucom/eventnative/timestamp"
 	"github.com/jitsucom/eventnative/typing"
 	"io"
 	"strings"
+	"sync"
 	"text/template"
+	"text/template/parse"
 	"time"
 )
 
 type Processor struct {
 	flattener            *Flattener
 	fieldMapper          Mapper
-	typeCasts            map[string]typing.DataType
+	typeCasts            map[string]TypeCast
 	tableNameExtractFunc TableNameExtractFunction
 	tableNameExpression  string
 	pkFields             map[string]bool
 	enrichmentRules      []enrichment.Rule
+	//nil if destination doesn't have filter
+	filter *filters.Filter
+	//nil if destination isn't sampled
+	sampler *Sampler
+	renames columnRenames
+	//nil if destination doesn't have limits
+	limiter *limiter
+	limits  *Limits
+	//nil if destination doesn't have deprecated fields
+	deprecations *deprecations
+	//nil if destination doesn't have default values and nulls are dropped
+	defaults *defaults
+	//nil if destination doesn't route or tag late events
+	lateEvents *lateEvents
+	//nil if destination output isn't partitioned by primary key hash
+	pkPartitioner *pkPartitioner
+	//nil if batch files rows and columns are in default order
+	fileOrderer *fileOrderer
+	//nil if destination doesn't inject metadata columns (see SetMetadataColumns)
+	metadata *metadata
+	//is called with every processed object (e.g. schema drift detection)
+	observer func(table *Table, object map[string]interface{})
+	//is called with every skipped (filtered, not sampled or rejected by limits) input object of a file or a stream
+	skipObserver func(object map[string]interface{})
+	//true if enrichment, deprecations, mapping and default values don't change objects: flat input objects
+	//(see IsFlat) are copied by Flattener.CopyFlat without intermediate copy and recursive flattening
+	copyFree bool
+	//raw events mode: objects are written as is (see SetRaw)
+	raw       bool
+	rawColumn string
+	//payloads with size >= parseMinPayloadSize are parsed by parseWorkers goroutines (see SetParallelParsing)
+	parseWorkers        int
+	parseMinPayloadSize int
+	//true if fields which can't be converted are nulled and recorded in QuarantineColumn (see SetTypecastPolicy)
+	quarantine bool
+	//nil means schema component logger without destination (see SetDestinationName)
+	logger          *logging.Logger
+	destinationName string
 }
 
+//schemaLogger writes schema component log lines (see logging.Configure levels)
+var schemaLogger = logging.Component(logging.SchemaComponent)
+
+//copiesPool keeps maps for intermediate object copies: they aren't needed after flattening
+var copiesPool = sync.Pool{New: func() interface{} { return map[string]interface{}{} }}
+
 func NewProcessor(tableNameFuncExpression string, mappings []string, mappingType FieldMappingType, primaryKeyFields map[string]bool,
-	enrichmentRules []enrichment.Rule) (*Processor, error) {
+	enrichmentRules []enrichment.Rule, filter *filters.Filter) (*Processor, error) {
 	mapper, typeCasts, err := NewFieldMapper(mappingType, mappings)
 	if err != nil {
 		return nil, err
 	}
 
 	if typeCasts == nil {
-		typeCasts = map[string]typing.DataType{}
+		typeCasts = map[string]TypeCast{}
 	}
 
 	tmpl, err := template.New("table name extract").
+		Funcs(templateFunctions).
 		Parse(tableNameFuncExpression)
 	if err != nil {
 		return nil, fmt.Errorf("Error parsing table name template %v", err)
 	}
 
+	//constant table name (template without actions) isn't executed for every object
+	constantTableName, isConstant := constantTemplate(tmpl)
+
 	tableNameExtractFunc := func(object map[string]interface{}) (string, error) {
 		//we need time type of _timestamp field for extracting table name with date template
 		ts, ok := object[timestamp.Key]
@@ -53,6 +105,9 @@ func NewProcessor(tableNameFuncExpression string, mappings []string, mappingType
 		if err != nil {
 			return "", fmt.Errorf("Error extracting table name: malformed %s field: %v", timestamp.Key, err)
 		}
+		if isConstant {
+			return constantTableName, nil
+		}
 
 		object[timestamp.Key] = t
 		var buf bytes.Buffer
@@ -63,14 +118,10 @@ func NewProcessor(tableNameFuncExpression string, mappings []string, mappingType
 		//revert type of _timestamp field
 		object[timestamp.Key] = ts
 
-		// format "<no value>" -> null
-		formatted := strings.ReplaceAll(buf.String(), "<no value>", "null")
-		// format "Abc dse" -> "abc_dse"
-		reformatted := strings.ReplaceAll(formatted, " ", "_")
-		return strings.ToLower(reformatted), nil
+		return formatTableName(buf.String()), nil
 	}
 
-	return &Processor{
+	p := &Processor{
 		flattener:            NewFlattener(),
 		fieldMapper:          mapper,
 		typeCasts:            typeCasts,
@@ -78,20 +129,145 @@ func NewProcessor(tableNameFuncExpression string, mappings []string, mappingType
 		tableNameExpression:  tableNameFuncExpression,
 		pkFields:             primaryKeyFields,
 		enrichmentRules:      enrichmentRules,
-	}, nil
+		filter:               filter,
+	}
+	p.copyFree = p.isCopyFree()
+	return p, nil
+}
+
+//isCopyFree return true if enrichment, deprecations, mapping and default values don't change objects
+func (p *Processor) isCopyFree() bool {
+	_, isDummyMapper := p.fieldMapper.(*DummyMapper)
+	return len(p.enrichmentRules) == 0 && p.deprecations == nil && isDummyMapper && (p.defaults == nil || len(p.defaults.values) == 0)
+}
+
+//constantTemplate return formatted table name and true if the template doesn't have actions (e.g. "events")
+func constantTemplate(tmpl *template.Template) (string, bool) {
+	if tmpl.Tree == nil || tmpl.Tree.Root == nil {
+		return "", false
+	}
+	var text strings.Builder
+	for _, node := range tmpl.Tree.Root.Nodes {
+		textNode, ok := node.(*parse.TextNode)
+		if !ok {
+			return "", false
+		}
+		text.Write(textNode.Text)
+	}
+	return formatTableName(text.String()), true
+}
+
+//extractTableName return table name from the template or fixed table name of service events (see events.TableKey)
+//the template is executed anyway: it checks _timestamp field. Fixed table name is read from the original object
+//because mapping might have removed it
+func (p *Processor) extractTableName(object, original map[string]interface{}) (string, error) {
+	tableName, err := p.tableNameExtractFunc(object)
+	if err != nil {
+		return "", fmt.Errorf("Error extracting table name. Template: %s: %v", p.tableNameExpression, err)
+	}
+	if fixed, ok := original[events.TableKey].(string); ok && fixed != "" {
+		tableName = formatTableName(fixed)
+	}
+	if tableName == "" {
+		return "", fmt.Errorf("Unknown table name. Template: %s", p.tableNameExpression)
+	}
+	return tableName, nil
+}
+
+//formatTableName format "<no value>" -> null, "Abc dse" -> "abc_dse"
+func formatTableName(name string) string {
+	formatted := strings.ReplaceAll(name, "<no value>", "null")
+	reformatted := strings.ReplaceAll(formatted, " ", "_")
+	return strings.ToLower(reformatted)
+}
+
+//SetDestinationName set destination field of the processing log lines
+func (p *Processor) SetDestinationName(destinationName string) {
+	p.logger = schemaLogger.WithDestination(destinationName)
+	p.destinationName = destinationName
+}
+
+func (p *Processor) log() *logging.Logger {
+	if p.logger == nil {
+		return schemaLogger
+	}
+	return p.logger
+}
+
+//SetObserver set func which is called with table schema and flat object after processing every object
+func (p *Processor) SetObserver(observer func(table *Table, object map[string]interface{})) {
+	p.observer = observer
+}
+
+//SetSkipObserver set func which is called with every input object which has been skipped (e.g. by filter or sampling)
+//while processing file payloads and facts
+func (p *Processor) SetSkipObserver(observer func(object map[string]interface{})) {
+	p.skipObserver = observer
+}
+
+//SetSampler set destination sampling: objects which aren't sampled are skipped as filtered ones
+func (p *Processor) SetSampler(sampler *Sampler) {
+	p.sampler = sampler
+}
+
+//SetFileOrdering set rows and columns order of processed files. Nil config means default order
+func (p *Processor) SetFileOrdering(config *FileOrdering) error {
+	orderer, err := newFileOrderer(config, p.pkFields)
+	if err != nil {
+		return err
+	}
+	p.fileOrderer = orderer
+	return nil
+}
+
+//orderFiles apply file ordering (if configured) to all processed files
+func (p *Processor) orderFiles(files map[string]*ProcessedFile) map[string]*ProcessedFile {
+	if p.fileOrderer != nil {
+		for _, pf := range files {
+			p.fileOrderer.apply(pf)
+		}
+	}
+	return files
+}
+
+//Limits return configured destination limits or nil
+func (p *Processor) Limits() *Limits {
+	return p.limits
 }
 
 //ProcessFact return table representation, processed flatten object
 func (p *Processor) ProcessFact(fact map[string]interface{}) (*Table, events.Fact, error) {
-	return p.processObject(fact)
+	table, object, err := p.processObject(fact)
+	if err == nil && !table.Exists() && p.skipObserver != nil {
+		p.skipObserver(fact)
+	}
+	return table, object, err
 }
 
 //ProcessFilePayload process file payload lines divided with \n. Line by line where 1 line = 1 json
+//Lines with JSON arrays and the whole payload JSON array are expanded: 1 element = 1 object
 //Return array of processed objects per table like {"table1": []objects, "table2": []objects},
 //All failed events are moved to separate collection for sending to fallback
+//Lines of large payloads are parsed concurrently if parallel parsing is configured (see SetParallelParsing)
+//gzip or zstd compressed payloads are decompressed
 func (p *Processor) ProcessFilePayload(fileName string, payload []byte, breakOnError bool, parseFunc func([]byte) (map[string]interface{}, error)) (map[string]*ProcessedFile, []*events.FailedFact, error) {
-	var failedFacts []*events.FailedFact
-	filePerTable := map[string]*ProcessedFile{}
+	payload, err := compression.Decompress(payload)
+	if err == compression.ErrTruncated {
+		p.log().Warnf("Compressed file [%s] is truncated: only %d bytes are processed", fileName, len(payload))
+	} else if err != nil {
+		return nil, nil, fmt.Errorf("Error decompressing [%s] file: %v", fileName, err)
+	}
+
+	payload, err = expandJsonArrays(payload)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error reading [%s] file: %v", fileName, err)
+	}
+
+	if p.parseWorkers > 1 && len(payload) >= p.parseMinPayloadSize {
+		return p.processFilePayloadParallel(fileName, payload, breakOnError, parseFunc)
+	}
+
+	result := newPayloadResult(fileName)
 	input := bytes.NewBuffer(payload)
 	reader := bufio.NewReaderSize(input, 64*1024)
 	line, readErr := reader.ReadBytes('\n')
@@ -102,31 +278,8 @@ func (p *Processor) ProcessFilePayload(fileName string, payload []byte, breakOnE
 			return nil, nil, err
 		}
 
-		table, processedObject, err := p.processObject(object)
-		if err != nil {
-			if breakOnError {
-				return nil, nil, err
-			} else {
-				logging.Warnf("Unable to process object %s: %v. This line will be stored in fallback.", string(line), err)
-
-				failedFacts = append(failedFacts, &events.FailedFact{
-					//remove last byte (\n)
-					Event:   line[:len(line)-1],
-					Error:   err.Error(),
-					EventId: events.ExtractEventId(object),
-				})
-			}
-		}
-
-		//don't process empty object
-		if table.Exists() {
-			f, ok := filePerTable[table.Name]
-			if !ok {
-				filePerTable[table.Name] = &ProcessedFile{FileName: fileName, DataSchema: table, payload: []map[string]interface{}{processedObject}}
-			} else {
-				f.DataSchema.Columns.Merge(table.Columns)
-				f.payload = append(f.payload, processedObject)
-			}
+		if err := p.processLine(result, line, object, breakOnError); err != nil {
+			return nil, nil, err
 		}
 
 		line, readErr = reader.ReadBytes('\n')
@@ -135,7 +288,56 @@ func (p *Processor) ProcessFilePayload(fileName string, payload []byte, breakOnE
 		}
 	}
 
-	return filePerTable, failedFacts, nil
+	return p.orderFiles(result.filePerTable), result.failedFacts, nil
+}
+
+//payloadResult is processed objects per table and failed events of the file payload
+type payloadResult struct {
+	fileName     string
+	filePerTable map[string]*ProcessedFile
+	failedFacts  []*events.FailedFact
+}
+
+func newPayloadResult(fileName string) *payloadResult {
+	return &payloadResult{fileName: fileName, filePerTable: map[string]*ProcessedFile{}}
+}
+
+//processLine process the parsed line object and merge it into the table file
+//return err if object can't be processed and breakOnError is true, otherwise the line is put into failed facts
+func (p *Processor) processLine(result *payloadResult, line []byte, object map[string]interface{}, breakOnError bool) error {
+	table, processedObject, err := p.processObject(object)
+	if err != nil {
+		if breakOnError {
+			return err
+		} else {
+			eventId := events.ExtractEventId(object)
+			p.log().WithEventId(eventId).Warnf("Unable to process object %s: %v. This line will be stored in fallback.", string(line), err)
+
+			result.failedFacts = append(result.failedFacts, &events.FailedFact{
+				//remove last byte (\n). Line is copied: it might be a slice of the whole payload
+				Event:   append([]byte{}, line[:len(line)-1]...),
+				Error:   err.Error(),
+				EventId: eventId,
+			})
+		}
+	}
+
+	if err == nil && !table.Exists() && p.skipObserver != nil {
+		p.skipObserver(object)
+	}
+
+	//don't process empty object
+	if table.Exists() {
+		f, ok := result.filePerTable[table.Name]
+		if !ok {
+			result.filePerTable[table.Name] = &ProcessedFile{FileName: result.fileName, DataSchema: table, payload: []map[string]interface{}{processedObject}}
+		} else {
+			f.DataSchema.Columns.Merge(table.Columns)
+			f.payload = append(f.payload, processedObject)
+		}
+	}
+
+	return nil
 }
 
 //ProcessObjects process source chunk payload objects
@@ -164,76 +366,226 @@ func (p *Processor) ProcessObjects(objects []map[string]interface{}) (map[string
 		}
 	}
 
-	return unitPerTable, nil
+	return p.orderFiles(unitPerTable), nil
 }
 
-//ApplyDBTyping call ApplyDBTypingToObject to every object in input *ProcessedFile payload
-//return err if can't convert any field to DB schema type
+//ApplyDBTyping convert all payload fields to DB schema types
+//columns with one source type in the file (see ProcessedFile.DataSchema) are converted column by column with typing.ConvertColumn,
+//other fields are converted per object
+//return err if can't convert any field to DB schema type (fields are nulled and recorded in QuarantineColumn with quarantine policy)
 func (p *Processor) ApplyDBTyping(dbSchema *Table, pf *ProcessedFile) error {
+	columnar := map[string]bool{}
+	if pf.DataSchema != nil {
+		for name, fileColumn := range pf.DataSchema.Columns {
+			sourceType, ok := fileColumn.singleType()
+			if !ok {
+				continue
+			}
+			dbColumn, ok := dbSchema.Columns[name]
+			if !ok {
+				continue
+			}
+
+			if err := applyColumnDBTyping(name, sourceType, dbColumn.GetType(), pf.payload); err != nil {
+				//values aren't changed: the column is converted per object with quarantine of failed values
+				if p.quarantine {
+					continue
+				}
+				return err
+			}
+			columnar[name] = true
+		}
+	}
+
 	for _, object := range pf.payload {
-		if err := p.ApplyDBTypingToObject(dbSchema, object); err != nil {
-			return err
+		var quarantined map[string]*QuarantinedField
+		for k, v := range object {
+			if columnar[k] || v == nil {
+				continue
+			}
+
+			column := dbSchema.Columns[k]
+			converted, err := typing.Convert(column.GetType(), v)
+			if err != nil {
+				if p.quarantine {
+					quarantined = p.quarantineField(quarantined, object, k, column.GetType(), err)
+					continue
+				}
+				return fmt.Errorf("Error applying DB type [%s] to input [%s] field with [%v] value: %v", column.GetType(), k, v, err)
+			}
+			object[k] = converted
+		}
+		putQuarantined(object, quarantined)
+	}
+
+	return nil
+}
+
+//applyColumnDBTyping convert column values of all payload objects from sourceType to dbType in one pass
+func applyColumnDBTyping(name string, sourceType, dbType typing.DataType, payload []map[string]interface{}) error {
+	if sourceType == dbType {
+		return nil
+	}
+
+	values := make([]interface{}, 0, len(payload))
+	rows := make([]map[string]interface{}, 0, len(payload))
+	for _, object := range payload {
+		if v, ok := object[name]; ok && v != nil {
+			values = append(values, v)
+			rows = append(rows, object)
 		}
 	}
 
+	//value which can't be converted isn't changed
+	if index, err := typing.ConvertColumn(sourceType, dbType, values); err != nil {
+		return fmt.Errorf("Error applying DB type [%s] to input [%s] field with [%v] value: %v", dbType, name, values[index], err)
+	}
+
+	for i, object := range rows {
+		object[name] = values[i]
+	}
+
 	return nil
 }
 
 //ApplyDBTypingToObject convert all object fields to DB schema types
 //change input object
-//return err if can't convert any field to DB schema type
+//return err if can't convert any field to DB schema type (fields are nulled and recorded in QuarantineColumn with quarantine policy)
 func (p *Processor) ApplyDBTypingToObject(dbSchema *Table, object map[string]interface{}) error {
+	var quarantined map[string]*QuarantinedField
 	for k, v := range object {
+		if v == nil {
+			continue
+		}
 		column := dbSchema.Columns[k]
 		converted, err := typing.Convert(column.GetType(), v)
 		if err != nil {
+			if p.quarantine {
+				quarantined = p.quarantineField(quarantined, object, k, column.GetType(), err)
+				continue
+			}
 			return fmt.Errorf("Error applying DB type [%s] to input [%s] field with [%v] value: %v", column.GetType(), k, v, err)
 		}
 		object[k] = converted
 	}
+	putQuarantined(object, quarantined)
 
 	return nil
 }
 
 //Return table representation of object and flatten, mapped object
-//1. copy map and don't change input object
-//2. execute enrichment rules
-//3. remove toDelete fields from object
-//4. map object
-//5. flatten object
-//6. apply typecast
+//1. check filter and sampling: return nil table if object doesn't match or isn't sampled
+//2. return raw object representation if processor is in raw events mode
+//3. copy map and don't change input object (flat objects without enrichment, deprecations, mapping and defaults aren't copied)
+//4. execute enrichment rules with the event context (token, collection, source, request metadata)
+//5. count deprecated fields usage, drop fields which are past the sunset date
+//6. remove toDelete fields from object
+//7. map object
+//8. put default values of missing fields
+//9. flatten object
+//10. apply column renames of the table and route test traffic into <table>_test table
+//11. check limits: return nil table if object is rejected or error if it must be written into fallback
+//12. advance the table watermark: route late object into <table>_late table or tag it
+//13. put primary key hash partition
+//14. inject metadata columns
+//15. apply typecast (null fields are kept only with known column type). Fields which can't be converted are quarantined
+//with quarantine typecast policy
+//16. pass the result to observer
 func (p *Processor) processObject(objectsss map[string]interface{}) (*Table, map[string]interface{}, error) {
-	objectCopy := maputils.CopyMap(objectsss)
-	for _, rule := range p.enrichmentRules {
-		err := rule.Execute(objectCopy)
+	if p.filter != nil {
+		matched, err := p.filter.Match(objectsss)
 		if err != nil {
-			return nil, nil, fmt.Errorf("Error executing enrichment rule: [%s]: %v", rule.Name(), err)
+			return nil, nil, err
 		}
+		//skipped object is processed as empty one
+		if !matched {
+			return nil, nil, nil
+		}
+	}
+	if p.sampler != nil && !p.sampler.Sampled(objectsss) {
+		return nil, nil, nil
 	}
 
-	mappedObject, err := p.fieldMapper.Map(objectCopy)
-	if err != nil {
-		return nil, nil, fmt.Errorf("Error mapping object: %v", err)
+	if p.raw {
+		table, rawObject, err := p.processRawObject(objectsss)
+		if err != nil {
+			return nil, nil, err
+		}
+		if p.observer != nil {
+			p.observer(table, rawObject)
+		}
+		return table, rawObject, nil
 	}
 
-	flatObject, err := p.flattener.FlattenObject(mappedObject)
+	//flat object copy is the processing result: typecast values are written into it
+	var flatObject map[string]interface{}
+	if p.copyFree && IsFlat(objectsss) {
+		flatObject = p.flattener.CopyFlat(objectsss)
+	} else {
+		objectCopy := copiesPool.Get().(map[string]interface{})
+		maputils.CopyMapInto(objectsss, objectCopy)
+		flattened, err := p.mapAndFlatten(objectCopy)
+		//nested maps of the copy are left to GC: they might be referenced by enrichment results
+		for k := range objectCopy {
+			delete(objectCopy, k)
+		}
+		copiesPool.Put(objectCopy)
+		if err != nil {
+			return nil, nil, err
+		}
+		flatObject = flattened
+	}
+
+	tableName, err := p.extractTableName(flatObject, objectsss)
 	if err != nil {
 		return nil, nil, err
 	}
+	delete(flatObject, events.TableKey)
 
-	tableName, err := p.tableNameExtractFunc(flatObject)
-	if err != nil {
-		return nil, nil, fmt.Errorf("Error extracting table name. Template: %s: %v", p.tableNameExpression, err)
+	p.renames.apply(tableName, flatObject)
+
+	//test traffic is segregated before limits and watermarks of the production table are applied
+	tableName = testTableName(tableName, objectsss)
+	delete(flatObject, events.TestKey)
+
+	if p.limiter != nil {
+		accepted, err := p.limiter.apply(flatObject)
+		if err != nil {
+			return nil, nil, err
+		}
+		//rejected object is processed as empty one
+		if !accepted {
+			return nil, nil, nil
+		}
 	}
-	if tableName == "" {
-		return nil, nil, fmt.Errorf("Unknown table name. Template: %s", p.tableNameExpression)
+
+	if p.lateEvents != nil {
+		tableName = p.lateEvents.apply(tableName, flatObject)
+	}
+
+	if p.pkPartitioner != nil {
+		if err := p.pkPartitioner.apply(flatObject); err != nil {
+			return nil, nil, err
+		}
 	}
 
-	table := &Table{Name: tableName, Columns: Columns{}, PKFields: p.pkFields}
+	if p.metadata != nil {
+		p.metadata.apply(objectsss, flatObject)
+	}
+
+	table := &Table{Name: tableName, Columns: make(Columns, len(flatObject)), PKFields: p.pkFields}
 
 	//apply typecast and define column types
 	//mapping typecast overrides default typecast
+	var localTimeFields map[string]interface{}
+	var quarantined map[string]*QuarantinedField
 	for k, v := range flatObject {
+		if v == nil {
+			p.putNullColumn(table, flatObject, k)
+			continue
+		}
+
+		rawValue := v
 		//reformat from json.Number into int64 or float64 and put back
 		v = typing.ReformatValue(v)
 		flatObject[k] = v
@@ -255,22 +607,140 @@ func (p *Processor) processObject(objectsss map[string]interface{}) (*Table, map
 		}
 
 		//mapping typecast
-		if toType, ok := p.typeCasts[k]; ok {
-			converted, err := typing.Convert(toType, v)
+		if typeCast, ok := p.typeCasts[k]; ok {
+			//decimals are converted from raw json.Number for keeping all digits
+			if typeCast.Type == typing.DECIMAL {
+				converted, err := typing.ConvertDecimal(rawValue, *typeCast.Decimal)
+				if err != nil && p.quarantine {
+					flatObject[k] = rawValue
+					quarantined = p.quarantineField(quarantined, flatObject, k, typing.DECIMAL, err)
+					table.Columns[k] = NewDecimalColumn(*typeCast.Decimal)
+					continue
+				}
+				if err != nil {
+					return nil, nil, fmt.Errorf("Error converting field [%s] to [%s]: %v", k, typeCast.Decimal.String(), err)
+				}
+
+				flatObject[k] = converted
+				table.Columns[k] = NewDecimalColumn(*typeCast.Decimal)
+				continue
+			}
+
+			converted, err := typing.Convert(typeCast.Type, v)
+			if err != nil && p.quarantine {
+				quarantined = p.quarantineField(quarantined, flatObject, k, typeCast.Type, err)
+				table.Columns[k] = NewColumn(typeCast.Type)
+				continue
+			}
 			if err != nil {
-				strType, getStrErr := typing.StringFromType(toType)
+				strType, getStrErr := typing.StringFromType(typeCast.Type)
 				if getStrErr != nil {
 					strType = getStrErr.Error()
 				}
 				return nil, nil, fmt.Errorf("Error converting field [%s] to [%s]: %v", k, strType, err)
 			}
 
-			resultColumnType = toType
+			resultColumnType = typeCast.Type
 			flatObject[k] = converted
+
+			if typeCast.Type == typing.TIMESTAMP && timestamp.LocalFields() {
+				if localTimeFields == nil {
+					localTimeFields = map[string]interface{}{}
+				}
+				putLocalTimeFields(localTimeFields, k, v)
+			}
 		}
 
 		table.Columns[k] = NewColumn(resultColumnType)
 	}
 
+	for k, v := range localTimeFields {
+		flatObject[k] = v
+		resultColumnType, _ := typing.TypeFromValue(v)
+		table.Columns[k] = NewColumn(resultColumnType)
+	}
+
+	//quarantine column is in all tables: fields might be quarantined by DB typing after the table has been ensured
+	if p.quarantine {
+		putQuarantined(flatObject, quarantined)
+		table.Columns[QuarantineColumn] = NewColumn(typing.STRING)
+	}
+
+	if p.observer != nil {
+		p.observer(table, flatObject)
+	}
+
 	return table, flatObject, nil
 }
+
+//mapAndFlatten execute enrichment rules, deprecations, mapping and default values on the object copy and flatten it
+func (p *Processor) mapAndFlatten(objectCopy map[string]interface{}) (map[string]interface{}, error) {
+	var eventContext *enrichment.EventContext
+	if len(p.enrichmentRules) > 0 {
+		eventContext = enrichment.NewEventContext(objectCopy)
+	}
+	for _, rule := range p.enrichmentRules {
+		err := rule.Execute(eventContext, objectCopy)
+		if err != nil {
+			return nil, fmt.Errorf("Error executing enrichment rule: [%s]: %v", rule.Name(), err)
+		}
+	}
+
+	if p.deprecations != nil {
+		p.deprecations.apply(objectCopy)
+	}
+
+	mappedObject, err := p.fieldMapper.Map(objectCopy)
+	if err != nil {
+		return nil, fmt.Errorf("Error mapping object: %v", err)
+	}
+
+	if p.defaults != nil {
+		p.defaults.apply(mappedObject)
+	}
+
+	return p.flattener.FlattenObject(mappedObject)
+}
+
+//putNullColumn define column type of the kept null field: mapping typecast, default typecast or type of the default value
+//field is removed if its type is unknown
+func (p *Processor) putNullColumn(table *Table, object map[string]interface{}, name string) {
+	if typeCast, ok := p.typeCasts[name]; ok {
+		if typeCast.Type == typing.DECIMAL {
+			table.Columns[name] = NewDecimalColumn(*typeCast.Decimal)
+		} else {
+			table.Columns[name] = NewColumn(typeCast.Type)
+		}
+		return
+	}
+
+	if defaultType, ok := typing.DefaultTypes[name]; ok {
+		table.Columns[name] = NewColumn(defaultType)
+		return
+	}
+
+	if value, ok := p.defaults.field(name); ok {
+		if valueType, err := typing.TypeFromValue(typing.ReformatValue(value)); err == nil {
+			table.Columns[name] = NewColumn(valueType)
+			return
+		}
+	}
+
+	delete(object, name)
+}
+
+//putLocalTimeFields put <field>_local (wall clock time as TIMESTAMP) and <field>_tz (zone offset) of the source timestamp string
+func putLocalTimeFields(fields map[string]interface{}, name string, value interface{}) {
+	str, ok := value.(string)
+	if !ok {
+		return
+	}
+	t, err := typing.ParseTimestamp(str)
+	if err != nil {
+		return
+	}
+
+	local, zone := timestamp.Local(t)
+	fields[name+timestamp.LocalSuffix] = local
+	fields[name+timestamp.ZoneSuffix] = zone
+}
@@ -1,9 +1,12 @@
 package schema
 
 import (
+	"encoding/json"
+	"fmt"
 	"github.com/jitsucom/eventnative/appconfig"
 	"github.com/jitsucom/eventnative/enrichment"
 	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/filters"
 	"github.com/jitsucom/eventnative/geo"
 	"github.com/jitsucom/eventnative/parsers"
 	"github.com/jitsucom/eventnative/test"
@@ -131,7 +134,7 @@ func TestProcessFilePayload(t *testing.T) {
 			[]events.FailedFact{},
 		},
 	}
-	p, err := NewProcessor(`{{.event_type}}_{{._timestamp.Format "2006_01"}}`, []string{}, Default, map[string]bool{}, nil)
+	p, err := NewProcessor(`{{.event_type}}_{{._timestamp.Format "2006_01"}}`, []string{}, Default, map[string]bool{}, nil, nil)
 	require.NoError(t, err)
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -256,7 +259,7 @@ func TestProcessFact(t *testing.T) {
 	require.NoError(t, err)
 
 	p, err := NewProcessor(`events_{{._timestamp.Format "2006_01"}}`,
-		[]string{"/field1->/field2"}, Default, map[string]bool{}, []enrichment.Rule{uaRule, ipRule})
+		[]string{"/field1->/field2"}, Default, map[string]bool{}, []enrichment.Rule{uaRule, ipRule}, nil)
 	require.NoError(t, err)
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -274,3 +277,453 @@ func TestProcessFact(t *testing.T) {
 		})
 	}
 }
+
+func TestProcessFactWithFilter(t *testing.T) {
+	filter, err := filters.Parse(`event_type == "pageview" && user.anonymous_id != null`)
+	require.NoError(t, err)
+
+	p, err := NewProcessor("events", []string{}, Default, map[string]bool{}, nil, filter)
+	require.NoError(t, err)
+
+	table, object, err := p.ProcessFact(map[string]interface{}{"_timestamp": "2020-08-02T18:23:58.057807Z", "event_type": "pageview", "user": map[string]interface{}{"anonymous_id": "abc"}})
+	require.NoError(t, err)
+	require.True(t, table.Exists())
+	require.Equal(t, "abc", object["user_anonymous_id"])
+
+	table, object, err = p.ProcessFact(map[string]interface{}{"_timestamp": "2020-08-02T18:23:58.057807Z", "event_type": "click", "user": map[string]interface{}{"anonymous_id": "abc"}})
+	require.NoError(t, err)
+	require.False(t, table.Exists())
+	require.Nil(t, object)
+}
+
+func TestProcessFactDecimalCast(t *testing.T) {
+	p, err := NewProcessor("events", []string{"/price -> (decimal(12,2)) /price"}, Default, map[string]bool{}, nil, nil)
+	require.NoError(t, err)
+
+	table, object, err := p.ProcessFact(map[string]interface{}{"_timestamp": "2020-08-02T18:23:58.057807Z", "price": json.Number("1234567890.105")})
+	require.NoError(t, err)
+	require.Equal(t, NewDecimalColumn(typing.DecimalSpec{Precision: 12, Scale: 2}), table.Columns["price"])
+	require.Equal(t, typing.Decimal("1234567890.11"), object["price"])
+
+	_, _, err = p.ProcessFact(map[string]interface{}{"_timestamp": "2020-08-02T18:23:58.057807Z", "price": json.Number("12345678901")})
+	require.EqualError(t, err, "Error converting field [price] to [decimal(12,2)]: Value [12345678901] is out of decimal(12,2) range")
+}
+
+func TestProcessFactLocalTimeFields(t *testing.T) {
+	require.NoError(t, timestamp.Init(&timestamp.Config{LocalFields: true}))
+	defer timestamp.Init(&timestamp.Config{})
+
+	p, err := NewProcessor("events", []string{"/order/created_at -> (timestamp) /created_at"}, Default, map[string]bool{}, nil, nil)
+	require.NoError(t, err)
+
+	table, object, err := p.ProcessFact(map[string]interface{}{"_timestamp": "2020-08-02T18:23:58.057807Z", "order": map[string]interface{}{"created_at": "2020-08-02T21:23:58+03:00"}})
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2020, 8, 2, 18, 23, 58, 0, time.UTC), object["created_at"])
+	require.Equal(t, time.Date(2020, 8, 2, 21, 23, 58, 0, time.UTC), object["created_at_local"])
+	require.Equal(t, "+03:00", object["created_at_tz"])
+	require.Equal(t, NewColumn(typing.TIMESTAMP), table.Columns["created_at_local"])
+	require.Equal(t, NewColumn(typing.STRING), table.Columns["created_at_tz"])
+	_, ok := object["_timestamp_local"]
+	require.False(t, ok, "default typecast fields don't get local fields")
+}
+
+func TestProcessFactDefaults(t *testing.T) {
+	p, err := NewProcessor("events", []string{}, Default, map[string]bool{}, nil, nil)
+	require.NoError(t, err)
+	require.EqualError(t, p.SetDefaults(&Defaults{Values: []string{"/revenue -> [1]"}}), "Malformed default value [/revenue -> [1]]: Objects and arrays can't be default values")
+	require.EqualError(t, p.SetDefaults(&Defaults{Nulls: "null"}), "Unknown defaults nulls policy: null. Available: [drop, keep, default]")
+
+	testTime, _ := time.Parse(timestamp.Layout, "2020-08-02T18:23:58.057807Z")
+	tests := []struct {
+		name           string
+		nulls          string
+		input          map[string]interface{}
+		expectedObject map[string]interface{}
+		expectedTable  Columns
+	}{
+		{
+			"missing fields",
+			"",
+			map[string]interface{}{"_timestamp": "2020-08-02T18:23:58.057807Z", "revenue": json.Number("10.5")},
+			map[string]interface{}{"_timestamp": testTime, "revenue": 10.5, "utm_campaign": "unknown", "utm_source": "direct source"},
+			Columns{"_timestamp": NewColumn(typing.TIMESTAMP), "revenue": NewColumn(typing.FLOAT64), "utm_campaign": NewColumn(typing.STRING), "utm_source": NewColumn(typing.STRING)},
+		},
+		{
+			"dropped nulls",
+			DropNulls,
+			map[string]interface{}{"_timestamp": "2020-08-02T18:23:58.057807Z", "revenue": nil, "utm": map[string]interface{}{"campaign": nil, "source": "google"}, "title": nil},
+			map[string]interface{}{"_timestamp": testTime, "utm_source": "google"},
+			Columns{"_timestamp": NewColumn(typing.TIMESTAMP), "utm_source": NewColumn(typing.STRING)},
+		},
+		{
+			"kept nulls",
+			KeepNulls,
+			map[string]interface{}{"_timestamp": "2020-08-02T18:23:58.057807Z", "revenue": nil, "utm": map[string]interface{}{"campaign": nil, "source": "google"}, "title": nil},
+			map[string]interface{}{"_timestamp": testTime, "revenue": nil, "utm_campaign": nil, "utm_source": "google"},
+			Columns{"_timestamp": NewColumn(typing.TIMESTAMP), "revenue": NewColumn(typing.INT64), "utm_campaign": NewColumn(typing.STRING), "utm_source": NewColumn(typing.STRING)},
+		},
+		{
+			"default nulls",
+			DefaultNulls,
+			map[string]interface{}{"_timestamp": "2020-08-02T18:23:58.057807Z", "revenue": nil, "utm": map[string]interface{}{"campaign": nil, "source": "google"}, "title": nil},
+			map[string]interface{}{"_timestamp": testTime, "revenue": int64(0), "utm_campaign": "unknown", "utm_source": "google"},
+			Columns{"_timestamp": NewColumn(typing.TIMESTAMP), "revenue": NewColumn(typing.INT64), "utm_campaign": NewColumn(typing.STRING), "utm_source": NewColumn(typing.STRING)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewProcessor("events", []string{}, Default, map[string]bool{}, nil, nil)
+			require.NoError(t, err)
+			require.NoError(t, p.SetDefaults(&Defaults{Values: []string{"/revenue -> 0", `/utm/campaign -> "unknown"`, "/utm/source -> direct source"}, Nulls: tt.nulls}))
+
+			table, object, err := p.ProcessFact(tt.input)
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedObject, map[string]interface{}(object))
+			require.Equal(t, tt.expectedTable, table.Columns)
+		})
+	}
+}
+
+func TestApplyDBTyping(t *testing.T) {
+	testTime, _ := time.Parse(timestamp.Layout, "2020-08-02T18:23:58.057807Z")
+
+	fileColumns := Columns{
+		"int_field":    NewColumn(typing.INT64),
+		"float_field":  NewColumn(typing.FLOAT64),
+		"time_field":   NewColumn(typing.STRING),
+		"string_field": NewColumn(typing.STRING),
+		"mixed_field":  NewColumn(typing.INT64),
+	}
+	fileColumns.Merge(Columns{"mixed_field": NewColumn(typing.STRING)})
+
+	pf := &ProcessedFile{
+		DataSchema: &Table{Name: "events", Columns: fileColumns},
+		payload: []map[string]interface{}{
+			{"int_field": int64(1), "float_field": 1.5, "time_field": "2020-08-02T18:23:58.057807Z", "string_field": "a", "mixed_field": int64(10)},
+			{"int_field": 2, "mixed_field": "b"},
+			{"float_field": 2.0},
+		},
+	}
+	dbSchema := &Table{Name: "events", Columns: Columns{
+		"int_field":    NewColumn(typing.STRING),
+		"float_field":  NewColumn(typing.STRING),
+		"time_field":   NewColumn(typing.TIMESTAMP),
+		"string_field": NewColumn(typing.STRING),
+		"mixed_field":  NewColumn(typing.STRING),
+	}}
+
+	err := (&Processor{}).ApplyDBTyping(dbSchema, pf)
+	require.NoError(t, err)
+
+	test.ObjectsEqual(t, []map[string]interface{}{
+		{"int_field": "1", "float_field": "1.5", "time_field": testTime, "string_field": "a", "mixed_field": "10"},
+		{"int_field": "2", "mixed_field": "b"},
+		{"float_field": "2"},
+	}, pf.payload, "Typed payloads aren't equal")
+
+	//string -> timestamp error
+	pf = &ProcessedFile{
+		DataSchema: &Table{Name: "events", Columns: Columns{"time_field": NewColumn(typing.STRING)}},
+		payload:    []map[string]interface{}{{"time_field": "2020-08-02T18:23:58.057807Z"}, {"time_field": "not_time"}},
+	}
+	err = (&Processor{}).ApplyDBTyping(&Table{Name: "events", Columns: Columns{"time_field": NewColumn(typing.TIMESTAMP)}}, pf)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Error applying DB type [TIMESTAMP] to input [time_field] field with [not_time] value")
+}
+
+func BenchmarkApplyDBTyping(b *testing.B) {
+	dbSchema := &Table{Name: "events", Columns: Columns{
+		"int_field":    NewColumn(typing.STRING),
+		"float_field":  NewColumn(typing.STRING),
+		"count_field":  NewColumn(typing.FLOAT64),
+		"string_field": NewColumn(typing.STRING),
+	}}
+	newFile := func() *ProcessedFile {
+		pf := &ProcessedFile{DataSchema: &Table{Name: "events", Columns: Columns{
+			"int_field":    NewColumn(typing.INT64),
+			"float_field":  NewColumn(typing.FLOAT64),
+			"count_field":  NewColumn(typing.INT64),
+			"string_field": NewColumn(typing.STRING),
+		}}}
+		for i := 0; i < 1000; i++ {
+			pf.payload = append(pf.payload, map[string]interface{}{
+				"int_field":    int64(i),
+				"float_field":  float64(i) / 3,
+				"count_field":  int64(i),
+				"string_field": "value",
+			})
+		}
+		return pf
+	}
+	processor := &Processor{}
+
+	b.Run("per_object", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			pf := newFile()
+			b.StartTimer()
+			for _, object := range pf.payload {
+				if err := processor.ApplyDBTypingToObject(dbSchema, object); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+	b.Run("columnar", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			pf := newFile()
+			b.StartTimer()
+			if err := processor.ApplyDBTyping(dbSchema, pf); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestProcessFactLateEvents(t *testing.T) {
+	p, err := NewProcessor("events", []string{}, Default, map[string]bool{}, nil, nil)
+	require.NoError(t, err)
+	require.EqualError(t, p.SetLateEvents(&LateEvents{LatenessMin: 60, Action: "drop"}), "Unknown late events action: drop. Available: [table, tag]")
+
+	tests := []struct {
+		name           string
+		action         string
+		expectedTables []string
+		expectedLate   []interface{}
+	}{
+		{"table", LateTableAction, []string{"events", "events", "events_late", "events"}, []interface{}{nil, nil, nil, nil}},
+		{"tag", LateTagAction, []string{"events", "events", "events", "events"}, []interface{}{nil, nil, true, nil}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewProcessor("events", []string{}, Default, map[string]bool{}, nil, nil)
+			require.NoError(t, err)
+			require.NoError(t, p.SetLateEvents(&LateEvents{LatenessMin: 60, Action: tt.action}))
+
+			//watermark is 12:00 after the second event: 10:30 is late, 11:30 isn't
+			for i, ts := range []string{"2020-08-02T10:00:00.000000Z", "2020-08-02T12:00:00.000000Z", "2020-08-02T10:30:00.000000Z", "2020-08-02T11:30:00.000000Z"} {
+				table, object, err := p.ProcessFact(map[string]interface{}{"_timestamp": ts})
+				require.NoError(t, err)
+				require.Equal(t, tt.expectedTables[i], table.Name)
+				require.Equal(t, tt.expectedLate[i], object[LateColumn])
+			}
+		})
+	}
+}
+
+func TestProcessFactPkPartitioning(t *testing.T) {
+	p, err := NewProcessor("events", []string{}, Default, map[string]bool{}, nil, nil)
+	require.NoError(t, err)
+	require.EqualError(t, p.SetPkPartitioning(&PkPartitioning{Partitions: 12}), "Primary key partitioning requires primary_key_fields")
+
+	p, err = NewProcessor("events", []string{}, Default, map[string]bool{"id": true}, nil, nil)
+	require.NoError(t, err)
+	require.NoError(t, p.SetPkPartitioning(&PkPartitioning{Partitions: 12, Hash: "murmur2"}))
+
+	//Kafka default partitioner: toPositive(murmur2("foobar")) % 12 = 6
+	table, object, err := p.ProcessFact(map[string]interface{}{"_timestamp": "2020-08-02T10:00:00.000000Z", "id": "foobar"})
+	require.NoError(t, err)
+	require.Equal(t, 6, object[DefaultPartitionColumn])
+	require.Equal(t, typing.INT64, table.Columns[DefaultPartitionColumn].GetType())
+
+	_, _, err = p.ProcessFact(map[string]interface{}{"_timestamp": "2020-08-02T10:00:00.000000Z"})
+	require.EqualError(t, err, "Error computing primary key partition: [id] field is missing")
+}
+
+func TestProcessFactFlatFastPath(t *testing.T) {
+	p, err := NewProcessor("Events", []string{}, Default, map[string]bool{}, nil, nil)
+	require.NoError(t, err)
+	require.True(t, p.copyFree)
+
+	input := map[string]interface{}{"_timestamp": "2020-08-02T10:00:00.000000Z", "event-type": "click", "count": json.Number("3")}
+	table, object, err := p.ProcessFact(input)
+	require.NoError(t, err)
+	require.Equal(t, "events", table.Name)
+	require.Equal(t, events.Fact{"_timestamp": time.Date(2020, 8, 2, 10, 0, 0, 0, time.UTC), "event_type": "click", "count": int64(3)}, object)
+	//input object isn't changed
+	require.Equal(t, map[string]interface{}{"_timestamp": "2020-08-02T10:00:00.000000Z", "event-type": "click", "count": json.Number("3")}, input)
+
+	//nested objects are flattened by the general path
+	_, object, err = p.ProcessFact(map[string]interface{}{"_timestamp": "2020-08-02T10:00:00.000000Z", "user": map[string]interface{}{"Id": "u1"}})
+	require.NoError(t, err)
+	require.Equal(t, events.Fact{"_timestamp": time.Date(2020, 8, 2, 10, 0, 0, 0, time.UTC), "user_id": "u1"}, object)
+
+	p, err = NewProcessor("events", []string{"/count -> /cnt"}, Default, map[string]bool{}, nil, nil)
+	require.NoError(t, err)
+	require.False(t, p.copyFree)
+}
+
+func BenchmarkProcessFlatFact(b *testing.B) {
+	fact := map[string]interface{}{"_timestamp": "2020-08-02T10:00:00.000000Z", "eventn_ctx_event_id": "b6a1f4c2", "event_type": "pageview",
+		"api_key": "c2stMTIz", "source_ip": "10.0.0.1", "user_anonymous_id": "anon123", "user_id": "u42", "page_url": "https://jitsu.com/docs",
+		"page_title": "Docs", "referer": "https://google.com", "user_agent": "Mozilla/5.0", "utc_time": "2020-08-02T10:00:00.000000Z",
+		"local_tz_offset": json.Number("-180"), "screen_resolution": "1440x900", "doc_encoding": "UTF-8", "vp_size": "1440x789",
+		"utm_source": "google", "utm_medium": "cpc", "utm_campaign": "brand", "amount": json.Number("12.5"), "items": json.Number("3"),
+		"is_new": true, "session_id": "s1"}
+
+	b.Run("fast_path", func(b *testing.B) {
+		p, err := NewProcessor("events", []string{}, Default, map[string]bool{}, nil, nil)
+		require.NoError(b, err)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, _, err := p.ProcessFact(fact); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	//copy and recursive flattening (objects with nested objects or processors with mapping, enrichment, etc.)
+	b.Run("general_path", func(b *testing.B) {
+		p, err := NewProcessor("events", []string{}, Default, map[string]bool{}, nil, nil)
+		require.NoError(b, err)
+		p.copyFree = false
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, _, err := p.ProcessFact(fact); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestProcessFactRaw(t *testing.T) {
+	input := map[string]interface{}{"_timestamp": "2020-08-02T10:00:00.000000Z", "eventn_ctx": map[string]interface{}{"event_id": "e1"},
+		"event_type": "click", "user": map[string]interface{}{"id": "u1"}}
+
+	p, err := NewProcessor("{{.event_type}}_raw", []string{"/user/id -> /user_id"}, Default, map[string]bool{}, nil, nil)
+	require.NoError(t, err)
+	p.SetRaw(RawColumn)
+
+	table, object, err := p.ProcessFact(input)
+	require.NoError(t, err)
+	require.Equal(t, "click_raw", table.Name)
+	require.Equal(t, Columns{"_timestamp": NewColumn(typing.TIMESTAMP), "_raw": NewColumn(typing.STRING), "eventn_ctx_event_id": NewColumn(typing.STRING)}, table.Columns)
+	require.Equal(t, events.Fact{"_timestamp": time.Date(2020, 8, 2, 10, 0, 0, 0, time.UTC), "eventn_ctx_event_id": "e1",
+		"_raw": `{"_timestamp":"2020-08-02T10:00:00.000000Z","event_type":"click","eventn_ctx":{"event_id":"e1"},"user":{"id":"u1"}}`}, object)
+
+	//documents are kept as is
+	p.SetRaw("")
+	_, object, err = p.ProcessFact(input)
+	require.NoError(t, err)
+	require.Equal(t, events.Fact(input), object)
+
+	_, _, err = p.ProcessFact(map[string]interface{}{"event_type": "click"})
+	require.EqualError(t, err, "Error extracting table name. Template: {{.event_type}}_raw: Error extracting table name: _timestamp field doesn't exist")
+}
+
+func TestProcessFactTest(t *testing.T) {
+	p, err := NewProcessor("{{.event_type}}", []string{}, Default, map[string]bool{}, nil, nil)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		input         map[string]interface{}
+		expectedTable string
+	}{
+		{"production", map[string]interface{}{"event_type": "click", "key": "value"}, "click"},
+		{"test flag", map[string]interface{}{"event_type": "click", "key": "value", "_test": true}, "click_test"},
+		{"test string flag", map[string]interface{}{"event_type": "click", "key": "value", "_test": "true"}, "click_test"},
+		{"false flag", map[string]interface{}{"event_type": "click", "key": "value", "_test": false}, "click"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.input["_timestamp"] = "2020-08-02T10:00:00.000000Z"
+			table, object, err := p.ProcessFact(tt.input)
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedTable, table.Name)
+			//the flag isn't stored
+			require.Equal(t, events.Fact{"_timestamp": time.Date(2020, 8, 2, 10, 0, 0, 0, time.UTC), "event_type": "click", "key": "value"}, object)
+		})
+	}
+}
+
+func TestProcessFactFixedTable(t *testing.T) {
+	tests := []struct {
+		name          string
+		mappings      []string
+		mappingType   FieldMappingType
+		input         map[string]interface{}
+		expectedTable string
+	}{
+		{"template", nil, Default, map[string]interface{}{"event_type": "click"}, "events"},
+		{"fixed table", nil, Default, map[string]interface{}{"event_type": "_sessions", "_table": "_sessions"}, "_sessions"},
+		{"fixed table with strict mapping", []string{"/event_type -> /event_type"}, Strict, map[string]interface{}{"event_type": "_sessions", "_table": "_sessions"}, "_sessions"},
+		{"fixed test table", nil, Default, map[string]interface{}{"event_type": "_sessions", "_table": "_sessions", "_test": true}, "_sessions_test"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewProcessor("events", tt.mappings, tt.mappingType, map[string]bool{}, nil, nil)
+			require.NoError(t, err)
+			tt.input["_timestamp"] = "2020-08-02T10:00:00.000000Z"
+
+			table, object, err := p.ProcessFact(tt.input)
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedTable, table.Name)
+			//fixed table name isn't stored
+			require.NotContains(t, object, "_table")
+			require.NotContains(t, table.Columns, "_table")
+		})
+	}
+
+	//raw destinations
+	p, err := NewProcessor("events", []string{}, Default, map[string]bool{}, nil, nil)
+	require.NoError(t, err)
+	p.SetRaw("")
+	table, object, err := p.ProcessFact(map[string]interface{}{"_timestamp": "2020-08-02T10:00:00.000000Z", "_table": "_sessions"})
+	require.NoError(t, err)
+	require.Equal(t, "_sessions", table.Name)
+	require.NotContains(t, object, "_table")
+}
+
+func TestProcessFilePayloadParallel(t *testing.T) {
+	var payload []byte
+	for i := 0; i < 1000; i++ {
+		payload = append(payload, []byte(fmt.Sprintf(`{"_timestamp": "2020-08-02T10:00:00.000000Z", "event_type": "type_%d", "id": %d}`+"\n", i%3, i))...)
+		if i%100 == 0 {
+			payload = append(payload, []byte(`{"event_type": "broken", "id": "no_timestamp"}`+"\n")...)
+		}
+	}
+	//line without \n isn't processed
+	payload = append(payload, []byte(`{"_timestamp": "2020-08-02T10:00:00.000000Z", "event_type": "type_0"}`)...)
+
+	p, err := NewProcessor("{{.event_type}}", []string{}, Default, map[string]bool{}, nil, nil)
+	require.NoError(t, err)
+	expected, expectedFailed, err := p.ProcessFilePayload("testfile", payload, false, parsers.ParseJson)
+	require.NoError(t, err)
+	require.Len(t, expectedFailed, 10)
+
+	p.SetParallelParsing(4, 0)
+	actual, failed, err := p.ProcessFilePayload("testfile", payload, false, parsers.ParseJson)
+	require.NoError(t, err)
+	require.Equal(t, expected, actual)
+	require.Equal(t, expectedFailed, failed)
+
+	_, _, err = p.ProcessFilePayload("testfile", payload, true, parsers.ParseJson)
+	require.EqualError(t, err, "Error extracting table name. Template: {{.event_type}}: Error extracting table name: _timestamp field doesn't exist")
+
+	_, _, err = p.ProcessFilePayload("testfile", append([]byte("not json\n"), payload...), false, parsers.ParseJson)
+	require.Error(t, err)
+}
+
+func BenchmarkProcessFilePayload(b *testing.B) {
+	var payload []byte
+	for i := 0; i < 10000; i++ {
+		payload = append(payload, []byte(fmt.Sprintf(`{"_timestamp": "2020-08-02T10:00:00.000000Z", "event_type": "pageview", "id": %d, "page": {"url": "https://jitsu.com/docs/%d", "title": "Docs"}, "user": {"anonymous_id": "anon%d"}}`+"\n", i, i, i))...)
+	}
+
+	for _, workers := range []int{0, 4} {
+		b.Run(fmt.Sprintf("workers_%d", workers), func(b *testing.B) {
+			p, err := NewProcessor("events", []string{}, Default, map[string]bool{}, nil, nil)
+			require.NoError(b, err)
+			p.SetParallelParsing(workers, 0)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, _, err := p.ProcessFilePayload("testfile", payload, false, parsers.ParseJson); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
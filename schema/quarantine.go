@@ -0,0 +1,82 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jitsucom/eventnative/metrics"
+	"github.com/jitsucom/eventnative/typing"
+)
+
+const (
+	//QuarantineColumn keeps original values and conversion errors of fields which can't be converted to column types
+	QuarantineColumn = "_quarantined_fields"
+
+	//FailTypecastPolicy: the whole event fails if any field can't be converted (default)
+	FailTypecastPolicy = "fail"
+	//QuarantineTypecastPolicy: fields which can't be converted are written as nulls, the rest of the row is written.
+	//Original values and errors are recorded in QuarantineColumn
+	QuarantineTypecastPolicy = "quarantine"
+)
+
+//QuarantinedField is an original value of the field which can't be converted to the column type
+type QuarantinedField struct {
+	Value interface{} `json:"value"`
+	Type  string      `json:"type"`
+	Error string      `json:"error"`
+}
+
+//SetTypecastPolicy set fail (default) or quarantine policy of fields which can't be converted to mapping or DB column types
+func (p *Processor) SetTypecastPolicy(policy string) error {
+	switch policy {
+	case "", FailTypecastPolicy:
+		p.quarantine = false
+	case QuarantineTypecastPolicy:
+		p.quarantine = true
+	default:
+		return fmt.Errorf("Unknown typecast_failures policy: %s. Available: [%s, %s]", policy, FailTypecastPolicy, QuarantineTypecastPolicy)
+	}
+	return nil
+}
+
+//quarantineField null the field and record its original value with the conversion error
+//return quarantined fields with the new one (quarantined is created if it is nil)
+func (p *Processor) quarantineField(quarantined map[string]*QuarantinedField, object map[string]interface{}, name string, toType typing.DataType, err error) map[string]*QuarantinedField {
+	if quarantined == nil {
+		quarantined = map[string]*QuarantinedField{}
+	}
+
+	strType, getStrErr := typing.StringFromType(toType)
+	if getStrErr != nil {
+		strType = toType.String()
+	}
+	quarantined[name] = &QuarantinedField{Value: object[name], Type: strType, Error: err.Error()}
+	object[name] = nil
+	metrics.TypecastQuarantinedField(p.destinationName)
+	return quarantined
+}
+
+//putQuarantined merge quarantined fields into QuarantineColumn JSON value of the object
+//(fields might have been quarantined by mapping typecast and DB typing)
+func putQuarantined(object map[string]interface{}, quarantined map[string]*QuarantinedField) {
+	if len(quarantined) == 0 {
+		return
+	}
+
+	if existing, ok := object[QuarantineColumn].(string); ok && existing != "" {
+		previous := map[string]*QuarantinedField{}
+		if err := json.Unmarshal([]byte(existing), &previous); err == nil {
+			for name, field := range previous {
+				if _, ok := quarantined[name]; !ok {
+					quarantined[name] = field
+				}
+			}
+		}
+	}
+
+	b, err := json.Marshal(quarantined)
+	if err != nil {
+		schemaLogger.SystemErrorf("Error marshalling quarantined fields: %v", err)
+		return
+	}
+	object[QuarantineColumn] = string(b)
+}
@@ -0,0 +1,77 @@
+package schema
+
+import (
+	"encoding/json"
+	"github.com/jitsucom/eventnative/typing"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestApplyDBTypingQuarantine(t *testing.T) {
+	testTime, _ := time.Parse(time.RFC3339Nano, "2020-08-02T18:23:58.057807Z")
+	p := &Processor{}
+	require.NoError(t, p.SetTypecastPolicy(QuarantineTypecastPolicy))
+
+	dbSchema := &Table{Name: "events", Columns: Columns{
+		"time_field":   NewColumn(typing.TIMESTAMP),
+		"int_field":    NewColumn(typing.INT64),
+		"string_field": NewColumn(typing.STRING),
+	}}
+
+	//stream object
+	object := map[string]interface{}{"time_field": "not_time", "int_field": int64(1), "string_field": "a"}
+	require.NoError(t, p.ApplyDBTypingToObject(dbSchema, object))
+	require.Nil(t, object["time_field"])
+	require.Equal(t, int64(1), object["int_field"])
+	require.Equal(t, "a", object["string_field"])
+
+	quarantined := map[string]*QuarantinedField{}
+	require.NoError(t, json.Unmarshal([]byte(object[QuarantineColumn].(string)), &quarantined))
+	require.Len(t, quarantined, 1)
+	require.Equal(t, "not_time", quarantined["time_field"].Value)
+	require.Equal(t, "timestamp", quarantined["time_field"].Type)
+	require.NotEmpty(t, quarantined["time_field"].Error)
+
+	//batch payload: the failed column is converted per object
+	pf := &ProcessedFile{
+		DataSchema: &Table{Name: "events", Columns: Columns{"time_field": NewColumn(typing.STRING)}},
+		payload:    []map[string]interface{}{{"time_field": "2020-08-02T18:23:58.057807Z"}, {"time_field": "not_time"}},
+	}
+	require.NoError(t, p.ApplyDBTyping(dbSchema, pf))
+	require.Equal(t, testTime, pf.payload[0]["time_field"])
+	require.NotContains(t, pf.payload[0], QuarantineColumn)
+	require.Nil(t, pf.payload[1]["time_field"])
+	require.Contains(t, pf.payload[1][QuarantineColumn], "not_time")
+
+	require.Error(t, p.SetTypecastPolicy("stringify"))
+}
+
+func TestProcessFactQuarantine(t *testing.T) {
+	p, err := NewProcessor("events", []string{"/price -> (decimal(12,2)) /price", "/count -> (integer) /count"}, Default, map[string]bool{}, nil, nil)
+	require.NoError(t, err)
+	require.NoError(t, p.SetTypecastPolicy(QuarantineTypecastPolicy))
+
+	table, object, err := p.ProcessFact(map[string]interface{}{"_timestamp": "2020-08-02T18:23:58.057807Z", "price": json.Number("12345678901"), "count": "abc", "name": "a"})
+	require.NoError(t, err)
+	require.Nil(t, object["price"])
+	require.Nil(t, object["count"])
+	require.Equal(t, "a", object["name"])
+	require.Equal(t, NewDecimalColumn(typing.DecimalSpec{Precision: 12, Scale: 2}), table.Columns["price"])
+	require.Equal(t, NewColumn(typing.INT64), table.Columns["count"])
+	require.Equal(t, NewColumn(typing.STRING), table.Columns[QuarantineColumn])
+
+	quarantined := map[string]*QuarantinedField{}
+	require.NoError(t, json.Unmarshal([]byte(object[QuarantineColumn].(string)), &quarantined))
+	require.Len(t, quarantined, 2)
+	require.Equal(t, "abc", quarantined["count"].Value)
+	require.Equal(t, float64(12345678901), quarantined["price"].Value)
+
+	//DB typing quarantine is merged with mapping typecast one
+	dbSchema := &Table{Name: "events", Columns: Columns{"_timestamp": NewColumn(typing.TIMESTAMP), "name": NewColumn(typing.TIMESTAMP), QuarantineColumn: NewColumn(typing.STRING)}}
+	require.NoError(t, p.ApplyDBTypingToObject(dbSchema, object))
+	quarantined = map[string]*QuarantinedField{}
+	require.NoError(t, json.Unmarshal([]byte(object[QuarantineColumn].(string)), &quarantined))
+	require.Len(t, quarantined, 3)
+	require.Equal(t, "a", quarantined["name"].Value)
+}
@@ -0,0 +1,65 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/maputils"
+	"github.com/jitsucom/eventnative/timestamp"
+	"github.com/jitsucom/eventnative/typing"
+	"time"
+)
+
+const (
+	ProcessedEvents = "processed"
+	RawEvents       = "raw"
+
+	//RawColumn keeps the raw event JSON in tables of raw events destinations
+	RawColumn     = "_raw"
+	rawEventIdKey = "eventn_ctx_event_id"
+)
+
+//SetRaw switch the processor into raw events mode: events aren't enriched, mapped and flattened.
+//Raw events are written as a JSON string into the column along with event id and _timestamp columns
+//or as JSON documents as is if the column is empty (e.g. data lake destinations)
+func (p *Processor) SetRaw(column string) {
+	p.raw = true
+	p.rawColumn = column
+}
+
+//Raw return true if the processor is in raw events mode
+func (p *Processor) Raw() bool {
+	return p.raw
+}
+
+//processRawObject return table and raw object representation: document copy or row with event id, _timestamp and raw JSON column
+func (p *Processor) processRawObject(object map[string]interface{}) (*Table, map[string]interface{}, error) {
+	//table name template changes _timestamp type during execution
+	rawObject := maputils.CopyMap(object)
+	tableName, err := p.extractTableName(rawObject, object)
+	if err != nil {
+		return nil, nil, err
+	}
+	delete(rawObject, events.TableKey)
+
+	table := &Table{Name: testTableName(tableName, object), Columns: Columns{}, PKFields: p.pkFields}
+	if p.rawColumn == "" {
+		return table, rawObject, nil
+	}
+
+	b, err := json.Marshal(object)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error marshalling raw event: %v", err)
+	}
+	//_timestamp existence and format have been checked during table name extraction
+	t, _ := time.Parse(timestamp.Layout, object[timestamp.Key].(string))
+	row := map[string]interface{}{timestamp.Key: t, p.rawColumn: string(b)}
+	table.Columns[timestamp.Key] = NewColumn(typing.TIMESTAMP)
+	table.Columns[p.rawColumn] = NewColumn(typing.STRING)
+	if eventId := events.ExtractEventId(object); eventId != "" {
+		row[rawEventIdKey] = eventId
+		table.Columns[rawEventIdKey] = NewColumn(typing.STRING)
+	}
+
+	return table, row, nil
+}
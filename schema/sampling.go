@@ -0,0 +1,150 @@
+package schema
+
+import (
+	"fmt"
+	"github.com/jitsucom/eventnative/hashing"
+	"github.com/jitsucom/eventnative/jsonutils"
+	"math"
+	"sort"
+	"strings"
+)
+
+const (
+	//sampling key hash is bucketed into [0, samplingBuckets): rate precision is 0.01%
+	samplingBuckets = 10000
+	//is prepended to sampling keys so sampled users don't correlate with primary key partitions of the same hash
+	samplingKeySalt = "sampling|"
+)
+
+var (
+	defaultSamplingKeys = []string{"/eventn_ctx/user/anonymous_id"}
+	//events without sampling keys values are sampled by event id
+	samplingEventIdPath = jsonutils.NewJsonPath("/eventn_ctx/event_id")
+	samplingEventType   = jsonutils.NewJsonPath("/event_type")
+)
+
+//Sampling is a configuration of deterministic destination sampling: an event is stored if the hash of the first present
+//key value is in the rate share of buckets. All events of the same key (e.g. user's journey) are either stored or skipped
+type Sampling struct {
+	//share of stored events in [0, 1]
+	Rate float64 `mapstructure:"rate" json:"rate" yaml:"rate"`
+	//json paths of the sampling key fields. The first present value is used. Default: [/eventn_ctx/user/anonymous_id]
+	Keys []string `mapstructure:"keys" json:"keys,omitempty" yaml:"keys,omitempty"`
+	//event_type -> rate overrides (e.g. purchase: 1 keeps all purchases)
+	EventTypes map[string]float64 `mapstructure:"event_types" json:"event_types,omitempty" yaml:"event_types,omitempty"`
+	//fnv (default), murmur2, murmur3 or xxhash
+	Hash string `mapstructure:"hash" json:"hash,omitempty" yaml:"hash,omitempty"`
+}
+
+func (s *Sampling) String() string {
+	keys := s.Keys
+	if len(keys) == 0 {
+		keys = defaultSamplingKeys
+	}
+	result := fmt.Sprintf("rate %g by [%s]", s.Rate, strings.Join(keys, ", "))
+
+	if len(s.EventTypes) > 0 {
+		var overrides []string
+		for eventType, rate := range s.EventTypes {
+			overrides = append(overrides, fmt.Sprintf("%s: %g", eventType, rate))
+		}
+		sort.Strings(overrides)
+		result += fmt.Sprintf(", event types rates: [%s]", strings.Join(overrides, ", "))
+	}
+	return result
+}
+
+//Sampler decides which events are stored according to Sampling configuration
+type Sampler struct {
+	partitioner *hashing.Partitioner
+	//configured keys and the event id
+	keys []*jsonutils.JsonPath
+	//rates in buckets
+	threshold  int
+	eventTypes map[string]int
+}
+
+//NewSampler return parsed sampling or nil if it isn't configured
+func NewSampler(config *Sampling) (*Sampler, error) {
+	if config == nil {
+		return nil, nil
+	}
+	threshold, err := samplingThreshold(config.Rate)
+	if err != nil {
+		return nil, err
+	}
+
+	eventTypes := map[string]int{}
+	for eventType, rate := range config.EventTypes {
+		eventTypeThreshold, err := samplingThreshold(rate)
+		if err != nil {
+			return nil, fmt.Errorf("Event type [%s]: %v", eventType, err)
+		}
+		eventTypes[eventType] = eventTypeThreshold
+	}
+
+	keysConfig := config.Keys
+	if len(keysConfig) == 0 {
+		keysConfig = defaultSamplingKeys
+	}
+	var keys []*jsonutils.JsonPath
+	for _, key := range keysConfig {
+		path := jsonutils.NewJsonPath(key)
+		if path.IsEmpty() {
+			return nil, fmt.Errorf("Sampling key can't be empty")
+		}
+		keys = append(keys, path)
+	}
+	keys = append(keys, samplingEventIdPath)
+
+	partitioner, err := hashing.NewPartitioner(config.Hash)
+	if err != nil {
+		return nil, fmt.Errorf("Sampling: %v", err)
+	}
+
+	return &Sampler{partitioner: partitioner, keys: keys, threshold: threshold, eventTypes: eventTypes}, nil
+}
+
+func samplingThreshold(rate float64) (int, error) {
+	if rate < 0 || rate > 1 {
+		return 0, fmt.Errorf("Sampling rate must be in [0, 1]: %g", rate)
+	}
+	return int(math.Round(rate * samplingBuckets)), nil
+}
+
+//Sampled return true if the (not flattened) object must be stored
+func (s *Sampler) Sampled(object map[string]interface{}) bool {
+	threshold := s.threshold
+	if eventType, ok := samplingEventType.Get(object); ok {
+		if eventTypeThreshold, ok := s.eventTypes[fmt.Sprint(eventType)]; ok {
+			threshold = eventTypeThreshold
+		}
+	}
+	if threshold >= samplingBuckets {
+		return true
+	}
+	if threshold <= 0 {
+		return false
+	}
+
+	key, ok := s.key(object)
+	if !ok {
+		//nothing to be keyed by: the event is stored
+		return true
+	}
+	return s.partitioner.Partition([]byte(samplingKeySalt+key), samplingBuckets) < threshold
+}
+
+//key return the first present sampling key value or the event id
+func (s *Sampler) key(object map[string]interface{}) (string, bool) {
+	for _, path := range s.keys {
+		value, ok := path.Get(object)
+		if !ok || value == nil {
+			continue
+		}
+		if key := fmt.Sprint(value); key != "" {
+			return key, true
+		}
+	}
+	return "", false
+}
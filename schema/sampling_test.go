@@ -0,0 +1,74 @@
+package schema
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func samplingEvent(eventType, anonymousId, eventId string) map[string]interface{} {
+	user := map[string]interface{}{}
+	if anonymousId != "" {
+		user["anonymous_id"] = anonymousId
+	}
+	return map[string]interface{}{"event_type": eventType, "eventn_ctx": map[string]interface{}{"event_id": eventId, "user": user}}
+}
+
+func TestSampler(t *testing.T) {
+	_, err := NewSampler(&Sampling{Rate: 1.5})
+	require.Error(t, err)
+	_, err = NewSampler(&Sampling{Rate: 0.5, EventTypes: map[string]float64{"purchase": -1}})
+	require.Error(t, err)
+	_, err = NewSampler(&Sampling{Rate: 0.5, Hash: "md5"})
+	require.Error(t, err)
+
+	sampler, err := NewSampler(nil)
+	require.NoError(t, err)
+	require.Nil(t, sampler)
+
+	sampler, err = NewSampler(&Sampling{Rate: 0.1, EventTypes: map[string]float64{"purchase": 1}})
+	require.NoError(t, err)
+
+	sampled := 0
+	for i := 0; i < 10000; i++ {
+		userId := fmt.Sprintf("user%d", i)
+		userSampled := sampler.Sampled(samplingEvent("pageview", userId, fmt.Sprintf("%d_1", i)))
+		//the whole user journey is either sampled or not
+		require.Equal(t, userSampled, sampler.Sampled(samplingEvent("click", userId, fmt.Sprintf("%d_2", i))))
+		require.True(t, sampler.Sampled(samplingEvent("purchase", userId, fmt.Sprintf("%d_3", i))))
+		if userSampled {
+			sampled++
+		}
+	}
+	require.InDelta(t, 1000, sampled, 150)
+
+	//events without anonymous id are sampled by event id
+	noKeySampled := 0
+	for i := 0; i < 1000; i++ {
+		if sampler.Sampled(samplingEvent("pageview", "", fmt.Sprintf("event%d", i))) {
+			noKeySampled++
+		}
+	}
+	require.InDelta(t, 100, noKeySampled, 50)
+
+	none, err := NewSampler(&Sampling{Rate: 0})
+	require.NoError(t, err)
+	require.False(t, none.Sampled(samplingEvent("pageview", "user1", "1")))
+}
+
+func TestProcessFactWithSampling(t *testing.T) {
+	p, err := NewProcessor("events", []string{}, Default, map[string]bool{}, nil, nil)
+	require.NoError(t, err)
+	sampler, err := NewSampler(&Sampling{Rate: 0, EventTypes: map[string]float64{"purchase": 1}})
+	require.NoError(t, err)
+	p.SetSampler(sampler)
+
+	table, object, err := p.ProcessFact(map[string]interface{}{"_timestamp": "2020-08-02T18:23:58.057807Z", "event_type": "pageview"})
+	require.NoError(t, err)
+	require.False(t, table.Exists())
+	require.Nil(t, object)
+
+	table, _, err = p.ProcessFact(map[string]interface{}{"_timestamp": "2020-08-02T18:23:58.057807Z", "event_type": "purchase"})
+	require.NoError(t, err)
+	require.True(t, table.Exists())
+}
@@ -2,7 +2,6 @@ package schema
 
 import (
 	"fmt"
-	"github.com/jitsucom/eventnative/logging"
 	"github.com/jitsucom/eventnative/typing"
 	"reflect"
 )
@@ -25,11 +24,22 @@ func (c Columns) Merge(other Columns) {
 			//wipe column.type if new type was added
 			for t := range otherColumn.typeOccurrence {
 				if _, ok := currentColumn.typeOccurrence[t]; !ok {
-					currentColumn.typeOccurrence[t] = true
+					//occurrences might be shared (see NewColumn): they are copied on write
+					typeOccurrence := make(map[typing.DataType]bool, len(currentColumn.typeOccurrence)+1)
+					for existing := range currentColumn.typeOccurrence {
+						typeOccurrence[existing] = true
+					}
+					typeOccurrence[t] = true
+					currentColumn.typeOccurrence = typeOccurrence
 					currentColumn.dataType = nil
 					c[otherName] = currentColumn
 				}
 			}
+			//keep decimal precision and scale
+			if currentColumn.decimal == nil && otherColumn.decimal != nil {
+				currentColumn.decimal = otherColumn.decimal
+				c[otherName] = currentColumn
+			}
 		} else {
 			c[otherName] = otherColumn
 		}
@@ -129,15 +139,69 @@ func PkToFieldsArray(pkFields map[string]bool) []string {
 type Column struct {
 	dataType       *typing.DataType
 	typeOccurrence map[typing.DataType]bool
+	decimal        *typing.DecimalSpec
 }
 
+//dataTypes keeps all types values: columns point to them instead of allocating a new value for every column
+//(values aren't changed via column dataType pointers)
+var dataTypes = [...]typing.DataType{typing.UNKNOWN, typing.INT64, typing.FLOAT64, typing.STRING, typing.TIMESTAMP,
+	typing.DECIMAL, typing.BOOL, typing.DATE, typing.UUID}
+
+//singleTypeOccurrences keeps occurrences of columns with one type: they are shared by columns and are copied on write in Merge
+var singleTypeOccurrences = func() [len(dataTypes)]map[typing.DataType]bool {
+	var occurrences [len(dataTypes)]map[typing.DataType]bool
+	for i, t := range dataTypes {
+		occurrences[i] = map[typing.DataType]bool{t: true}
+	}
+	return occurrences
+}()
+
+//NewColumn return column of the type. Columns of known types are created without allocations
 func NewColumn(t typing.DataType) Column {
+	if t >= 0 && int(t) < len(dataTypes) {
+		return Column{dataType: &dataTypes[t], typeOccurrence: singleTypeOccurrences[t]}
+	}
 	return Column{
 		dataType:       &t,
 		typeOccurrence: map[typing.DataType]bool{t: true},
 	}
 }
 
+//NewDecimalColumn return DECIMAL column with precision and scale
+func NewDecimalColumn(spec typing.DecimalSpec) Column {
+	column := NewColumn(typing.DECIMAL)
+	column.decimal = &spec
+	return column
+}
+
+//DecimalSpec return column precision and scale or typing.DefaultDecimalSpec if they aren't configured
+func (c Column) DecimalSpec() typing.DecimalSpec {
+	if c.decimal == nil {
+		return typing.DefaultDecimalSpec
+	}
+	return *c.decimal
+}
+
+//decimalTo return column precision and scale if t is DECIMAL
+func (c Column) decimalTo(t typing.DataType) *typing.DecimalSpec {
+	if t != typing.DECIMAL {
+		return nil
+	}
+	spec := c.DecimalSpec()
+	return &spec
+}
+
+//singleType return column type if all column values in the file have the same type
+func (c Column) singleType() (typing.DataType, bool) {
+	if len(c.typeOccurrence) != 1 {
+		return typing.UNKNOWN, false
+	}
+	for t := range c.typeOccurrence {
+		return t, true
+	}
+	return typing.UNKNOWN, false
+}
+
 //GetType get column type based on occurrence in one file
 //lazily get common ancestor type (typing.GetCommonAncestorType)
 func (c Column) GetType() typing.DataType {
@@ -151,7 +215,7 @@ func (c Column) GetType() typing.DataType {
 	}
 
 	if len(types) == 0 {
-		logging.SystemError("Column typeOccurrence can't be empty")
+		schemaLogger.SystemErrorf("Column typeOccurrence can't be empty")
 		return typing.UNKNOWN
 	}
 
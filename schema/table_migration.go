@@ -0,0 +1,139 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jitsucom/eventnative/typing"
+	"sort"
+	"time"
+)
+
+const (
+	AddColumnStep   = "add_column"
+	WidenColumnStep = "widen_column"
+)
+
+//MigrationStep is a DDL change of the destination table: a new column or a column type widening
+//(e.g. integer -> double when double values are discovered)
+type MigrationStep struct {
+	Kind   string
+	Column string
+	//zero if column doesn't exist
+	From typing.DataType
+	To   typing.DataType
+	//precision and scale of DECIMAL To type
+	Decimal *typing.DecimalSpec
+}
+
+func (ms *MigrationStep) MarshalJSON() ([]byte, error) {
+	view := map[string]string{"kind": ms.Kind, "column": ms.Column, "to": ms.To.String()}
+	if ms.Kind == WidenColumnStep {
+		view["from"] = ms.From.String()
+	}
+	if ms.Decimal != nil {
+		view["decimal"] = ms.Decimal.String()
+	}
+	return json.Marshal(view)
+}
+
+//ToColumn return column with the step To type
+func (ms *MigrationStep) ToColumn() Column {
+	if ms.Decimal != nil {
+		return NewDecimalColumn(*ms.Decimal)
+	}
+	return NewColumn(ms.To)
+}
+
+//MigrationPlan is a DDL diff between the live destination table and the discovered table schema
+//Statements (and Error if the destination can't apply the plan) are filled when the plan is waiting for review
+type MigrationPlan struct {
+	Table      string           `json:"table"`
+	Steps      []*MigrationStep `json:"steps"`
+	Statements []string         `json:"statements,omitempty"`
+	Error      string           `json:"error,omitempty"`
+	PlannedAt  time.Time        `json:"planned_at"`
+}
+
+//NewMigrationPlan return steps for making dbSchema compatible with dataSchema sorted by column name:
+//new columns and widenings of columns which discovered types can't be converted to
+func NewMigrationPlan(dbSchema, dataSchema *Table) (*MigrationPlan, error) {
+	plan := &MigrationPlan{Table: dbSchema.Name, PlannedAt: time.Now().UTC()}
+	if !dataSchema.Exists() {
+		return plan, nil
+	}
+
+	for name, column := range dataSchema.Columns {
+		discoveredType := column.GetType()
+		dbColumn, ok := dbSchema.Columns[name]
+		if !ok {
+			plan.Steps = append(plan.Steps, &MigrationStep{Kind: AddColumnStep, Column: name, To: discoveredType, Decimal: column.decimalTo(discoveredType)})
+			continue
+		}
+
+		dbType := dbColumn.GetType()
+		if typing.IsConvertible(discoveredType, dbType) {
+			continue
+		}
+
+		widenedType := typing.GetCommonAncestorType(dbType, discoveredType)
+		if widenedType == typing.UNKNOWN || widenedType == dbType || !typing.IsConvertible(dbType, widenedType) {
+			return nil, fmt.Errorf("Unsupported column [%s] type changing from: %s to: %s", name, discoveredType.String(), dbType.String())
+		}
+		plan.Steps = append(plan.Steps, &MigrationStep{Kind: WidenColumnStep, Column: name, From: dbType, To: widenedType, Decimal: column.decimalTo(widenedType)})
+	}
+
+	sort.Slice(plan.Steps, func(i, j int) bool {
+		return plan.Steps[i].Column < plan.Steps[j].Column
+	})
+	return plan, nil
+}
+
+func (mp *MigrationPlan) Exists() bool {
+	return mp != nil && len(mp.Steps) > 0
+}
+
+//Widenings return column type widening steps
+func (mp *MigrationPlan) Widenings() []*MigrationStep {
+	var widenings []*MigrationStep
+	for _, step := range mp.Steps {
+		if step.Kind == WidenColumnStep {
+			widenings = append(widenings, step)
+		}
+	}
+	return widenings
+}
+
+//AddedColumns return schema of new columns (for TableManager.PatchTableSchema)
+func (mp *MigrationPlan) AddedColumns() *Table {
+	patch := &Table{Name: mp.Table, Columns: Columns{}}
+	for _, step := range mp.Steps {
+		if step.Kind == AddColumnStep {
+			patch.Columns[step.Column] = step.ToColumn()
+		}
+	}
+	return patch
+}
+
+//Apply put new and widened columns into the table schema
+func (mp *MigrationPlan) Apply(table *Table) {
+	for _, step := range mp.Steps {
+		table.Columns[step.Column] = step.ToColumn()
+	}
+}
+
+//Pending return steps which haven't been applied to the live table yet
+func (mp *MigrationPlan) Pending(liveSchema *Table) *MigrationPlan {
+	pending := &MigrationPlan{Table: mp.Table, PlannedAt: mp.PlannedAt}
+	for _, step := range mp.Steps {
+		column, ok := liveSchema.Columns[step.Column]
+		if ok && (step.Kind == AddColumnStep || column.GetType() == step.To) {
+			continue
+		}
+		if !ok && step.Kind == WidenColumnStep {
+			pending.Steps = append(pending.Steps, &MigrationStep{Kind: AddColumnStep, Column: step.Column, To: step.To, Decimal: step.Decimal})
+			continue
+		}
+		pending.Steps = append(pending.Steps, step)
+	}
+	return pending
+}
@@ -0,0 +1,78 @@
+package schema
+
+import (
+	"github.com/jitsucom/eventnative/typing"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestNewMigrationPlan(t *testing.T) {
+	dbSchema := &Table{Name: "events", Columns: Columns{
+		"id":    NewColumn(typing.INT64),
+		"price": NewColumn(typing.INT64),
+		"name":  NewColumn(typing.STRING),
+		"time":  NewColumn(typing.TIMESTAMP),
+	}}
+
+	tests := []struct {
+		name          string
+		dataSchema    *Table
+		expectedSteps []*MigrationStep
+		expectedErr   string
+	}{
+		{
+			"without changes",
+			&Table{Name: "events", Columns: Columns{"id": NewColumn(typing.INT64), "name": NewColumn(typing.INT64)}},
+			nil,
+			"",
+		},
+		{
+			"new columns and widening",
+			&Table{Name: "events", Columns: Columns{"price": NewColumn(typing.FLOAT64), "user": NewColumn(typing.STRING), "city": NewColumn(typing.STRING), "time": NewColumn(typing.INT64)}},
+			[]*MigrationStep{
+				{Kind: AddColumnStep, Column: "city", To: typing.STRING},
+				{Kind: WidenColumnStep, Column: "price", From: typing.INT64, To: typing.FLOAT64},
+				{Kind: WidenColumnStep, Column: "time", From: typing.TIMESTAMP, To: typing.STRING},
+				{Kind: AddColumnStep, Column: "user", To: typing.STRING},
+			},
+			"",
+		},
+		{
+			"incompatible types",
+			&Table{Name: "events", Columns: Columns{"id": NewColumn(typing.UNKNOWN)}},
+			nil,
+			"Unsupported column [id] type changing from: UNKNOWN to: INT64",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := NewMigrationPlan(dbSchema, tt.dataSchema)
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedSteps, plan.Steps)
+		})
+	}
+}
+
+func TestMigrationPlanPending(t *testing.T) {
+	plan := &MigrationPlan{Table: "events", Steps: []*MigrationStep{
+		{Kind: AddColumnStep, Column: "city", To: typing.STRING},
+		{Kind: WidenColumnStep, Column: "price", From: typing.INT64, To: typing.FLOAT64},
+		{Kind: WidenColumnStep, Column: "amount", From: typing.INT64, To: typing.FLOAT64},
+		{Kind: AddColumnStep, Column: "user", To: typing.STRING},
+	}}
+	liveSchema := &Table{Name: "events", Columns: Columns{
+		"city":   NewColumn(typing.STRING),
+		"price":  NewColumn(typing.FLOAT64),
+		"amount": NewColumn(typing.INT64),
+	}}
+
+	pending := plan.Pending(liveSchema)
+	require.Equal(t, []*MigrationStep{
+		{Kind: WidenColumnStep, Column: "amount", From: typing.INT64, To: typing.FLOAT64},
+		{Kind: AddColumnStep, Column: "user", To: typing.STRING},
+	}, pending.Steps)
+}
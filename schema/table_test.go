@@ -129,6 +129,10 @@ func TestColumnMerge(t *testing.T) {
 			test.ObjectsEqual(t, tt.expected, tt.current, "Columns aren't equal")
 		})
 	}
+
+	//shared single type occurrences aren't changed by merges
+	test.ObjectsEqual(t, map[typing.DataType]bool{typing.STRING: true}, NewColumn(typing.STRING).typeOccurrence, "Occurrences aren't equal")
+	test.ObjectsEqual(t, map[typing.DataType]bool{typing.FLOAT64: true}, NewColumn(typing.FLOAT64).typeOccurrence, "Occurrences aren't equal")
 }
 
 func TestColumnGetType(t *testing.T) {
@@ -0,0 +1,16 @@
+package schema
+
+import (
+	"github.com/jitsucom/eventnative/events"
+)
+
+//TestTableSuffix is a suffix of tables with test traffic events: events with _test flag or events of test mode tokens
+const TestTableSuffix = "_test"
+
+//testTableName return <table name>_test if the object is a test traffic event (see events.IsTest) or table name as is
+func testTableName(tableName string, object map[string]interface{}) string {
+	if events.IsTest(object) {
+		return tableName + TestTableSuffix
+	}
+	return tableName
+}
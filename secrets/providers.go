@@ -0,0 +1,180 @@
+package secrets
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	EnvScheme   = "env"
+	VaultScheme = "vault"
+	AWSScheme   = "awssm"
+
+	//separates the secret path and the key of the JSON secret: vault://secret/data/pg#password
+	keyDelimiter = "#"
+)
+
+//EnvProvider resolves env://NAME references with environment variables values
+type EnvProvider struct{}
+
+func (ep *EnvProvider) Resolve(reference string) (string, error) {
+	value, ok := os.LookupEnv(reference)
+	if !ok {
+		return "", fmt.Errorf("Environment variable %s isn't set", reference)
+	}
+	return value, nil
+}
+
+//VaultConfig is a HashiCorp Vault connection configuration. Empty values are taken from VAULT_ADDR, VAULT_TOKEN
+//and VAULT_NAMESPACE environment variables
+type VaultConfig struct {
+	Address   string `mapstructure:"address"`
+	Token     string `mapstructure:"token"`
+	Namespace string `mapstructure:"namespace"`
+}
+
+//VaultProvider resolves vault://path#key references with Vault HTTP API (GET /v1/path). Both KV v1 (secret/pg#password)
+//and KV v2 (secret/data/pg#password) engines are supported
+type VaultProvider struct {
+	config *VaultConfig
+	client *http.Client
+}
+
+func NewVaultProvider(config *VaultConfig) *VaultProvider {
+	return &VaultProvider{config: config, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (vp *VaultProvider) Resolve(reference string) (string, error) {
+	path, key, err := splitKey(reference)
+	if err != nil {
+		return "", err
+	}
+	if key == "" {
+		return "", errors.New("Vault reference must be vault://path#key")
+	}
+
+	address := valueOrEnv(vp.config.Address, "VAULT_ADDR")
+	if address == "" {
+		return "", errors.New("Vault address isn't configured: set server.secrets.vault.address or VAULT_ADDR")
+	}
+	request, err := http.NewRequest(http.MethodGet, strings.TrimRight(address, "/")+"/v1/"+strings.TrimLeft(path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("X-Vault-Token", valueOrEnv(vp.config.Token, "VAULT_TOKEN"))
+	if namespace := valueOrEnv(vp.config.Namespace, "VAULT_NAMESPACE"); namespace != "" {
+		request.Header.Set("X-Vault-Namespace", namespace)
+	}
+
+	response, err := vp.client.Do(request)
+	if err != nil {
+		return "", fmt.Errorf("Error requesting Vault: %v", err)
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return "", fmt.Errorf("Error reading Vault response: %v", err)
+	}
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Vault responded with %d: %s", response.StatusCode, string(body))
+	}
+
+	secret := struct {
+		Data map[string]interface{} `json:"data"`
+	}{}
+	if err := json.Unmarshal(body, &secret); err != nil {
+		return "", fmt.Errorf("Error parsing Vault response: %v", err)
+	}
+	data := secret.Data
+	//KV v2 wraps the secret into data.data
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		data = nested
+	}
+	return extractKey(data, key)
+}
+
+//AWSSecretsManagerProvider resolves awssm://region/secret-id#key references with AWS Secrets Manager. Without key
+//the whole secret string is used, with key the secret string must be a JSON object. AWS credentials are taken from
+//the default chain (environment variables, shared credentials file or instance role)
+type AWSSecretsManagerProvider struct{}
+
+//getSecretString return the current secret string of AWS Secrets Manager secret. Overridden in tests
+var getSecretString = func(region, secretId string) (string, error) {
+	awsSession, err := session.NewSession(aws.NewConfig().WithRegion(region))
+	if err != nil {
+		return "", err
+	}
+	output, err := secretsmanager.New(awsSession).GetSecretValue(&secretsmanager.GetSecretValueInput{SecretId: aws.String(secretId)})
+	if err != nil {
+		return "", err
+	}
+	if output.SecretString == nil {
+		return "", errors.New("Binary secrets aren't supported")
+	}
+	return *output.SecretString, nil
+}
+
+func (asp *AWSSecretsManagerProvider) Resolve(reference string) (string, error) {
+	path, key, err := splitKey(reference)
+	if err != nil {
+		return "", err
+	}
+	parts := strings.SplitN(path, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", errors.New("AWS Secrets Manager reference must be awssm://region/secret-id[#key]")
+	}
+
+	secretString, err := getSecretString(parts[0], parts[1])
+	if err != nil {
+		return "", fmt.Errorf("Error getting secret from AWS Secrets Manager: %v", err)
+	}
+	if key == "" {
+		return secretString, nil
+	}
+
+	data := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(secretString), &data); err != nil {
+		return "", fmt.Errorf("Secret must be a JSON object for getting key [%s]: %v", key, err)
+	}
+	return extractKey(data, key)
+}
+
+//splitKey return the path and the key of the path#key reference
+func splitKey(reference string) (string, string, error) {
+	parts := strings.SplitN(reference, keyDelimiter, 2)
+	if parts[0] == "" {
+		return "", "", errors.New("Secret path can't be empty")
+	}
+	if len(parts) == 1 {
+		return parts[0], "", nil
+	}
+	return parts[0], parts[1], nil
+}
+
+func extractKey(data map[string]interface{}, key string) (string, error) {
+	value, ok := data[key]
+	if !ok || value == nil {
+		return "", fmt.Errorf("Secret doesn't contain key [%s]", key)
+	}
+	if str, ok := value.(string); ok {
+		return str, nil
+	}
+	return fmt.Sprint(value), nil
+}
+
+func valueOrEnv(value, envName string) string {
+	if value != "" {
+		return value
+	}
+	return os.Getenv(envName)
+}
@@ -0,0 +1,166 @@
+package secrets
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const schemeDelimiter = "://"
+
+//Provider is a secrets backend which resolves references of its scheme (e.g. vault://secret/data/pg#password)
+type Provider interface {
+	//Resolve return the secret value of the reference without scheme prefix (e.g. secret/data/pg#password)
+	Resolve(reference string) (string, error)
+}
+
+var (
+	providersMutex sync.RWMutex
+	providers      = map[string]Provider{
+		EnvScheme:   &EnvProvider{},
+		VaultScheme: NewVaultProvider(&VaultConfig{}),
+		AWSScheme:   &AWSSecretsManagerProvider{},
+	}
+)
+
+//Config is a server.secrets configuration
+type Config struct {
+	Vault *VaultConfig `mapstructure:"vault"`
+}
+
+//Init configure built-in providers
+func Init(config *Config) {
+	if config.Vault != nil {
+		Register(VaultScheme, NewVaultProvider(config.Vault))
+	}
+}
+
+//Register add (or replace) the provider of the scheme
+func Register(scheme string, provider Provider) {
+	providersMutex.Lock()
+	defer providersMutex.Unlock()
+
+	providers[scheme] = provider
+}
+
+//Schemes return sorted schemes of registered providers
+func Schemes() []string {
+	providersMutex.RLock()
+	defer providersMutex.RUnlock()
+
+	var schemes []string
+	for scheme := range providers {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+//provider return the provider and the reference if the value is a reference of registered scheme
+func provider(value string) (Provider, string, bool) {
+	i := strings.Index(value, schemeDelimiter)
+	if i <= 0 {
+		return nil, "", false
+	}
+
+	providersMutex.RLock()
+	defer providersMutex.RUnlock()
+
+	p, ok := providers[value[:i]]
+	return p, value[i+len(schemeDelimiter):], ok
+}
+
+//IsReference return true if the value is a reference of registered provider scheme
+func IsReference(value string) bool {
+	_, _, ok := provider(value)
+	return ok
+}
+
+//Resolve return the secret value if the value is a reference (scheme://reference) of registered provider
+//or the value as is
+func Resolve(value string) (string, error) {
+	p, reference, ok := provider(value)
+	if !ok {
+		return value, nil
+	}
+
+	secret, err := p.Resolve(reference)
+	if err != nil {
+		return "", fmt.Errorf("Error resolving secret [%s]: %v", value, err)
+	}
+	return secret, nil
+}
+
+//ResolveAll replace all references in string fields, slices and map values of the object (pointer to a struct or a map)
+//with secret values in place. Return all resolving errors
+func ResolveAll(object interface{}) error {
+	var errs []string
+	resolveValue(reflect.ValueOf(object), &errs)
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func resolveValue(value reflect.Value, errs *[]string) {
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !value.IsNil() {
+			elem := value.Elem()
+			//strings in interfaces aren't settable: they are replaced by resolved copies
+			if value.Kind() == reflect.Interface && elem.Kind() == reflect.String {
+				if resolved, ok := resolveString(elem.String(), errs); ok && value.CanSet() {
+					value.Set(reflect.ValueOf(resolved))
+				}
+				return
+			}
+			resolveValue(elem, errs)
+		}
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			if field := value.Field(i); field.CanSet() {
+				resolveValue(field, errs)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			resolveValue(value.Index(i), errs)
+		}
+	case reflect.Map:
+		for _, key := range value.MapKeys() {
+			mapValue := value.MapIndex(key)
+			switch {
+			case mapValue.Kind() == reflect.String:
+				if resolved, ok := resolveString(mapValue.String(), errs); ok {
+					value.SetMapIndex(key, reflect.ValueOf(resolved).Convert(mapValue.Type()))
+				}
+			case mapValue.Kind() == reflect.Interface && !mapValue.IsNil() && mapValue.Elem().Kind() == reflect.String:
+				if resolved, ok := resolveString(mapValue.Elem().String(), errs); ok {
+					value.SetMapIndex(key, reflect.ValueOf(resolved))
+				}
+			default:
+				//map values aren't addressable: nested pointers, maps and slices are changed in place
+				resolveValue(mapValue, errs)
+			}
+		}
+	case reflect.String:
+		if resolved, ok := resolveString(value.String(), errs); ok && value.CanSet() {
+			value.SetString(resolved)
+		}
+	}
+}
+
+//resolveString return resolved value and true if the value is a reference which has been resolved
+func resolveString(value string, errs *[]string) (string, bool) {
+	if !IsReference(value) {
+		return "", false
+	}
+	resolved, err := Resolve(value)
+	if err != nil {
+		*errs = append(*errs, err.Error())
+		return "", false
+	}
+	return resolved, true
+}
@@ -0,0 +1,113 @@
+package secrets
+
+import (
+	"errors"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+type testDatasource struct {
+	Host       string
+	Password   string
+	Parameters map[string]string
+}
+
+type testDestination struct {
+	Type       string
+	DataSource *testDatasource
+	Hosts      []string
+	Custom     map[string]interface{}
+}
+
+func TestResolveAll(t *testing.T) {
+	require.NoError(t, os.Setenv("SECRETS_TEST_PASSWORD", "pg-password"))
+	defer os.Unsetenv("SECRETS_TEST_PASSWORD")
+
+	original := getSecretString
+	defer func() { getSecretString = original }()
+	getSecretString = func(region, secretId string) (string, error) {
+		if region == "us-east-1" && secretId == "prod/clickhouse" {
+			return `{"host": "ch.internal", "port": 9000}`, nil
+		}
+		return "", errors.New("ResourceNotFoundException")
+	}
+
+	destination := &testDestination{
+		Type: "postgres",
+		DataSource: &testDatasource{
+			Host:       "pg.internal",
+			Password:   "env://SECRETS_TEST_PASSWORD",
+			Parameters: map[string]string{"sslrootcert": "env://SECRETS_TEST_PASSWORD"},
+		},
+		Hosts:  []string{"awssm://us-east-1/prod/clickhouse#host", "plain://value"},
+		Custom: map[string]interface{}{"port": "awssm://us-east-1/prod/clickhouse#port", "n": 1},
+	}
+	require.NoError(t, ResolveAll(destination))
+	require.Equal(t, &testDestination{
+		Type: "postgres",
+		DataSource: &testDatasource{
+			Host:       "pg.internal",
+			Password:   "pg-password",
+			Parameters: map[string]string{"sslrootcert": "pg-password"},
+		},
+		Hosts:  []string{"ch.internal", "plain://value"},
+		Custom: map[string]interface{}{"port": "9000", "n": 1},
+	}, destination)
+
+	err := ResolveAll(&testDestination{Hosts: []string{"env://SECRETS_TEST_MISSING", "awssm://us-east-1/unknown", "awssm://us-east-1"}})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Environment variable SECRETS_TEST_MISSING isn't set")
+	require.Contains(t, err.Error(), "ResourceNotFoundException")
+	require.Contains(t, err.Error(), "awssm://region/secret-id[#key]")
+}
+
+func TestVaultProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "test-token" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/pg":
+			w.Write([]byte(`{"data": {"data": {"password": "v2-password"}, "metadata": {"version": 3}}}`))
+		case "/v1/kv/pg":
+			w.Write([]byte(`{"data": {"password": "v1-password"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name        string
+		token       string
+		reference   string
+		expected    string
+		expectedErr string
+	}{
+		{"KV v2", "test-token", "secret/data/pg#password", "v2-password", ""},
+		{"KV v1", "test-token", "kv/pg#password", "v1-password", ""},
+		{"Missing key", "test-token", "kv/pg#user", "", "Secret doesn't contain key [user]"},
+		{"Without key", "test-token", "kv/pg", "", "Vault reference must be vault://path#key"},
+		{"Unknown path", "test-token", "kv/unknown#password", "", "Vault responded with 404"},
+		{"Wrong token", "wrong", "kv/pg#password", "", "Vault responded with 403"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := NewVaultProvider(&VaultConfig{Address: server.URL, Token: tt.token})
+			actual, err := provider.Resolve(tt.reference)
+			if tt.expectedErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
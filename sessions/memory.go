@@ -0,0 +1,88 @@
+package sessions
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+type memorySession struct {
+	session   Session
+	expiresAt time.Time
+}
+
+//Memory is an open sessions state of the current instance. It is lost on restart
+type Memory struct {
+	sync.Mutex
+	sessions map[string]*memorySession
+}
+
+func NewMemory() *Memory {
+	return &Memory{sessions: map[string]*memorySession{}}
+}
+
+func (m *Memory) Get(key string) (*Session, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	ms, ok := m.sessions[key]
+	if !ok {
+		return nil, nil
+	}
+	session := ms.session
+	return &session, nil
+}
+
+func (m *Memory) Save(key string, session *Session, expiresAt time.Time) error {
+	m.Lock()
+	defer m.Unlock()
+
+	m.sessions[key] = &memorySession{session: *session, expiresAt: expiresAt}
+	return nil
+}
+
+//Expired return keys of expired sessions from the earliest expiration
+func (m *Memory) Expired(now time.Time, limit int) ([]string, error) {
+	m.Lock()
+	var expired []*memorySession
+	keys := map[*memorySession]string{}
+	for key, ms := range m.sessions {
+		if !ms.expiresAt.After(now) {
+			expired = append(expired, ms)
+			keys[ms] = key
+		}
+	}
+	m.Unlock()
+
+	sort.Slice(expired, func(i, j int) bool {
+		return expired[i].expiresAt.Before(expired[j].expiresAt)
+	})
+	if len(expired) > limit {
+		expired = expired[:limit]
+	}
+	result := make([]string, 0, len(expired))
+	for _, ms := range expired {
+		result = append(result, keys[ms])
+	}
+	return result, nil
+}
+
+func (m *Memory) Remove(key string) (*Session, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	ms, ok := m.sessions[key]
+	if !ok {
+		return nil, nil
+	}
+	delete(m.sessions, key)
+	return &ms.session, nil
+}
+
+func (m *Memory) Type() string {
+	return MemoryType
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
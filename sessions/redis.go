@@ -0,0 +1,140 @@
+package sessions
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gomodule/redigo/redis"
+	"github.com/jitsucom/eventnative/logging"
+	"strconv"
+	"time"
+)
+
+const (
+	expirationKey = "sessions:expiration"
+	//session keys of crashed or stopped deployments are removed by redis after expiration time + orphanTtl
+	orphanTtl = 24 * time.Hour
+)
+
+//Redis is an open sessions state in Redis shared by all instances
+//redis key [variables] - description
+//sessions:key#key [key] - JSON string with the session
+//sessions:expiration - sorted set with session keys and expiration unix times as scores
+type Redis struct {
+	pool *redis.Pool
+}
+
+func NewRedis(host string, port int, password string) (*Redis, error) {
+	logging.Infof("Initializing sessionization redis [%s:%d]...", host, port)
+	r := &Redis{pool: &redis.Pool{
+		MaxIdle:     100,
+		MaxActive:   600,
+		IdleTimeout: 240 * time.Second,
+
+		Wait: false,
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial(
+				"tcp",
+				host+":"+strconv.Itoa(port),
+				redis.DialConnectTimeout(10*time.Second),
+				redis.DialReadTimeout(10*time.Second),
+				redis.DialPassword(password),
+			)
+		},
+		TestOnBorrow: func(c redis.Conn, t time.Time) error {
+			_, err := c.Do("PING")
+			return err
+		},
+	}}
+
+	//test connection
+	connection := r.pool.Get()
+	defer connection.Close()
+	if _, err := redis.String(connection.Do("PING")); err != nil {
+		r.pool.Close()
+		return nil, fmt.Errorf("Error testing connection to sessionization Redis: %v", err)
+	}
+
+	return r, nil
+}
+
+func (r *Redis) Get(key string) (*Session, error) {
+	connection := r.pool.Get()
+	defer connection.Close()
+
+	b, err := redis.Bytes(connection.Do("GET", sessionKey(key)))
+	if err == redis.ErrNil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalSession(b)
+}
+
+//Save put the session and its expiration in one transaction
+func (r *Redis) Save(key string, session *Session, expiresAt time.Time) error {
+	b, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("Error marshalling session: %v", err)
+	}
+	ttl := int64((time.Until(expiresAt) + orphanTtl).Seconds())
+
+	connection := r.pool.Get()
+	defer connection.Close()
+
+	connection.Send("MULTI")
+	connection.Send("SET", sessionKey(key), b, "EX", ttl)
+	connection.Send("ZADD", expirationKey, expiresAt.Unix(), key)
+	_, err = connection.Do("EXEC")
+	return err
+}
+
+func (r *Redis) Expired(now time.Time, limit int) ([]string, error) {
+	connection := r.pool.Get()
+	defer connection.Close()
+
+	return redis.Strings(connection.Do("ZRANGEBYSCORE", expirationKey, "-inf", now.Unix(), "LIMIT", 0, limit))
+}
+
+//Remove get and delete the session in one transaction so only one instance gets it
+func (r *Redis) Remove(key string) (*Session, error) {
+	connection := r.pool.Get()
+	defer connection.Close()
+
+	connection.Send("MULTI")
+	connection.Send("GET", sessionKey(key))
+	connection.Send("DEL", sessionKey(key))
+	connection.Send("ZREM", expirationKey, key)
+	values, err := redis.Values(connection.Do("EXEC"))
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 || values[0] == nil {
+		return nil, nil
+	}
+	b, err := redis.Bytes(values[0], nil)
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalSession(b)
+}
+
+func (r *Redis) Type() string {
+	return RedisType
+}
+
+func (r *Redis) Close() error {
+	return r.pool.Close()
+}
+
+func unmarshalSession(b []byte) (*Session, error) {
+	session := &Session{}
+	if err := json.Unmarshal(b, session); err != nil {
+		return nil, fmt.Errorf("Error unmarshalling session: %v", err)
+	}
+	return session, nil
+}
+
+func sessionKey(key string) string {
+	return "sessions:key#" + key
+}
@@ -0,0 +1,320 @@
+package sessions
+
+import (
+	"fmt"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/jsonutils"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/metrics"
+	"github.com/jitsucom/eventnative/resources"
+	"github.com/jitsucom/eventnative/safego"
+	"github.com/jitsucom/eventnative/timestamp"
+	"github.com/jitsucom/eventnative/uuid"
+	"hash/fnv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	SessionIdKey    = "session_id"
+	UserIdKey       = "user_id"
+	SessionStartKey = "session_start"
+	SessionEndKey   = "session_end"
+	DurationSecKey  = "duration_sec"
+	EventsKey       = "events"
+
+	eventTypeKey = "event_type"
+	apiTokenKey  = "api_key"
+
+	defaultTimeoutMin       = 30
+	defaultUserIdField      = "/eventn_ctx/user/anonymous_id"
+	defaultSessionIdField   = "/eventn_ctx/session_id"
+	defaultSequenceField    = "/eventn_ctx/session_sequence"
+	defaultSummaryEventType = "_sessions"
+	defaultSummaryTable     = "_sessions"
+	closeCheckPeriod        = 5 * time.Second
+	closeBatchSize          = 1000
+	lockStripes             = 256
+)
+
+//Config is a sessionization configuration
+type Config struct {
+	Enabled bool `mapstructure:"enabled"`
+	//user inactivity timeout after which the session is closed
+	TimeoutMin  int    `mapstructure:"timeout_min"`
+	UserIdField string `mapstructure:"user_id_field"`
+	//session id and session sequence number (1 - the first event of the session) are set into the fields
+	SessionIdField string `mapstructure:"session_id_field"`
+	SequenceField  string `mapstructure:"sequence_field"`
+	//closed sessions summary rows event_type
+	SummaryEventType string `mapstructure:"summary_event_type"`
+	//closed sessions summary rows are written into the table regardless of destinations table name templates
+	SummaryTable string `mapstructure:"summary_table"`
+
+	Storage StorageConfig `mapstructure:"storage"`
+}
+
+//ConsumersFunc return token consumers of session summaries
+type ConsumersFunc func(tokenId string) []events.Consumer
+
+//Sessionizer assigns session id and session sequence number to events per token and user: a new session is started
+//after the user inactivity timeout (by arrival time). Sessions which aren't continued in the timeout are closed and
+//their summary rows are consumed by the token destinations. With shared (redis) storage events of the same user
+//may be processed by different instances. Concurrent first events of a user on different instances may start
+//different sessions: summary event id is derived from session id so the same session summary isn't duplicated by replays
+type Sessionizer struct {
+	storage          Storage
+	timeout          time.Duration
+	userIdField      *jsonutils.JsonPath
+	sessionIdField   *jsonutils.JsonPath
+	sequenceField    *jsonutils.JsonPath
+	summaryEventType string
+	summaryTable     string
+	consumers        ConsumersFunc
+	now              func() time.Time
+
+	//locks of session keys serialize events of the same user on the current instance
+	locks [lockStripes]sync.Mutex
+
+	closed chan struct{}
+	done   chan struct{}
+}
+
+//NewSessionizer return configured and started Sessionizer or nil if sessionization is disabled
+func NewSessionizer(config Config, consumers ConsumersFunc) (*Sessionizer, error) {
+	if !config.Enabled {
+		return nil, nil
+	}
+
+	storage, err := NewStorage(config.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating sessionization storage: %v", err)
+	}
+
+	s := newSessionizer(config, storage, consumers)
+	safego.Run(s.start)
+	logging.Infof("[sessionization] Initialized with [%s] storage: %s sessions with %s timeout -> %s, %s", storage.Type(),
+		s.userIdField.String(), s.timeout, s.sessionIdField.String(), s.sequenceField.String())
+	return s, nil
+}
+
+func newSessionizer(config Config, storage Storage, consumers ConsumersFunc) *Sessionizer {
+	timeoutMin := config.TimeoutMin
+	if timeoutMin <= 0 {
+		timeoutMin = defaultTimeoutMin
+	}
+	if config.UserIdField == "" {
+		config.UserIdField = defaultUserIdField
+	}
+	if config.SessionIdField == "" {
+		config.SessionIdField = defaultSessionIdField
+	}
+	if config.SequenceField == "" {
+		config.SequenceField = defaultSequenceField
+	}
+	if config.SummaryEventType == "" {
+		config.SummaryEventType = defaultSummaryEventType
+	}
+	if config.SummaryTable == "" {
+		config.SummaryTable = defaultSummaryTable
+	}
+
+	return &Sessionizer{
+		storage:          storage,
+		timeout:          time.Duration(timeoutMin) * time.Minute,
+		userIdField:      jsonutils.NewJsonPath(config.UserIdField),
+		sessionIdField:   jsonutils.NewJsonPath(config.SessionIdField),
+		sequenceField:    jsonutils.NewJsonPath(config.SequenceField),
+		summaryEventType: config.SummaryEventType,
+		summaryTable:     config.SummaryTable,
+		consumers:        consumers,
+		now:              time.Now,
+		closed:           make(chan struct{}),
+		done:             make(chan struct{}),
+	}
+}
+
+//Assign enrich the processed event with session id and sequence number of the user session. The expired session
+//of the user is closed before a new one is started. Events without user id aren't sessionized.
+//Storage errors don't stop processing: the event is consumed without session fields
+func (s *Sessionizer) Assign(token, tokenId string, event events.Fact) {
+	userId := s.extractUserId(event)
+	if userId == "" {
+		return
+	}
+	key := tokenId + "|" + userId
+	now := s.now().UTC()
+
+	lock := s.lock(key)
+	lock.Lock()
+	session, err := s.storage.Get(key)
+	if err != nil {
+		lock.Unlock()
+		logging.Errorf("[sessionization] Error getting session of user [%s]: %v", userId, err)
+		return
+	}
+
+	var expired *Session
+	if session != nil && s.isExpired(session, now) {
+		expired, err = s.storage.Remove(key)
+		if err != nil {
+			lock.Unlock()
+			logging.Errorf("[sessionization] Error closing session [%s] of user [%s]: %v", session.Id, userId, err)
+			return
+		}
+		session = nil
+	}
+	if session == nil {
+		session = &Session{Id: uuid.New(), Token: token, TokenId: tokenId, UserId: userId, StartedAt: now}
+		metrics.SessionStarted()
+	}
+	session.Events++
+	session.LastEventAt = now
+	err = s.storage.Save(key, session, now.Add(s.timeout))
+	lock.Unlock()
+
+	//closed by the current instance: it has been removed from the storage above
+	if expired != nil {
+		s.consume(expired)
+	}
+	if err != nil {
+		logging.Errorf("[sessionization] Error saving session [%s] of user [%s]: %v", session.Id, userId, err)
+		return
+	}
+
+	if !s.sessionIdField.Set(event, session.Id) {
+		logging.Warnf("[sessionization] Session id can't be set into %s: node isn't an object", s.sessionIdField.String())
+	}
+	if !s.sequenceField.Set(event, session.Events) {
+		logging.Warnf("[sessionization] Session sequence can't be set into %s: node isn't an object", s.sequenceField.String())
+	}
+}
+
+//Close stop closing expired sessions. Memory sessions are lost on restart: all of them are closed and consumed
+func (s *Sessionizer) Close() error {
+	close(s.closed)
+	<-s.done
+
+	if s.storage.Type() == MemoryType {
+		//every open session expires before now + timeout
+		s.closeExpired(s.now().UTC().Add(s.timeout))
+	}
+	return s.storage.Close()
+}
+
+func (s *Sessionizer) start() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(closeCheckPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.closed:
+			return
+		case <-ticker.C:
+			s.closeExpired(s.now().UTC())
+		}
+	}
+}
+
+//closeExpired close and consume sessions which have expired before or at the time
+func (s *Sessionizer) closeExpired(now time.Time) {
+	for {
+		keys, err := s.storage.Expired(now, closeBatchSize)
+		if err != nil {
+			logging.Errorf("[sessionization] Error getting expired sessions: %v", err)
+			return
+		}
+
+		closed := 0
+		for _, key := range keys {
+			if session := s.closeSession(key, now); session != nil {
+				s.consume(session)
+				closed++
+			}
+		}
+
+		//the next batch would contain the same continued sessions
+		if len(keys) < closeBatchSize || closed == 0 {
+			return
+		}
+	}
+}
+
+//closeSession remove and return the session if it is still expired or nil if it has been continued
+//or closed by another instance
+func (s *Sessionizer) closeSession(key string, now time.Time) *Session {
+	lock := s.lock(key)
+	lock.Lock()
+	defer lock.Unlock()
+
+	session, err := s.storage.Get(key)
+	if err != nil {
+		logging.Errorf("[sessionization] Error getting expired session [%s]: %v", key, err)
+		return nil
+	}
+	if session != nil && !s.isExpired(session, now) {
+		return nil
+	}
+
+	//expiration of missing sessions is removed as well
+	session, err = s.storage.Remove(key)
+	if err != nil {
+		logging.Errorf("[sessionization] Error closing expired session [%s]: %v", key, err)
+		return nil
+	}
+	return session
+}
+
+//consume send the closed session summary into all token destinations
+func (s *Sessionizer) consume(session *Session) {
+	metrics.SessionClosed()
+
+	consumers := s.consumers(session.TokenId)
+	if len(consumers) == 0 {
+		logging.Warnf("[sessionization] Token [%s] doesn't have destinations: session [%s] summary is skipped", session.TokenId, session.Id)
+		return
+	}
+	fact := s.summary(session)
+	for _, consumer := range consumers {
+		consumer.Consume(fact, session.TokenId)
+	}
+}
+
+//summary return the closed session event. Event id is deterministic so the same session isn't duplicated by replays
+func (s *Sessionizer) summary(session *Session) events.Fact {
+	sessionStart := timestamp.ToISOFormat(session.StartedAt)
+	fact := events.Fact{
+		eventTypeKey:    s.summaryEventType,
+		events.TableKey: s.summaryTable,
+		apiTokenKey:     session.Token,
+		timestamp.Key:   sessionStart,
+		SessionIdKey:    session.Id,
+		UserIdKey:       session.UserId,
+		SessionStartKey: sessionStart,
+		SessionEndKey:   timestamp.ToISOFormat(session.LastEventAt),
+		DurationSecKey:  int64(session.LastEventAt.Sub(session.StartedAt).Seconds()),
+		EventsKey:       session.Events,
+	}
+	events.EnrichWithEventId(fact, resources.GetHash([]byte(session.Id)))
+	return fact
+}
+
+func (s *Sessionizer) isExpired(session *Session, now time.Time) bool {
+	return !session.LastEventAt.Add(s.timeout).After(now)
+}
+
+func (s *Sessionizer) lock(key string) *sync.Mutex {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return &s.locks[h.Sum32()%lockStripes]
+}
+
+func (s *Sessionizer) extractUserId(event events.Fact) string {
+	value, ok := s.userIdField.Get(event)
+	if !ok || value == nil {
+		return ""
+	}
+	return strings.TrimSpace(fmt.Sprint(value))
+}
@@ -0,0 +1,122 @@
+package sessions
+
+import (
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/schema"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+type collectingConsumer struct {
+	facts []events.Fact
+}
+
+func (cc *collectingConsumer) Consume(fact events.Fact, tokenId string) {
+	cc.facts = append(cc.facts, fact)
+}
+
+func (cc *collectingConsumer) Close() error {
+	return nil
+}
+
+func TestSessionize(t *testing.T) {
+	consumer := &collectingConsumer{}
+	s := newSessionizer(Config{Enabled: true, TimeoutMin: 30}, NewMemory(), func(tokenId string) []events.Consumer {
+		require.Equal(t, "token1_id", tokenId)
+		return []events.Consumer{consumer}
+	})
+	now := time.Date(2020, 10, 1, 10, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+
+	event := func(anonymousId string) events.Fact {
+		return events.Fact{"event_type": "pageview", "eventn_ctx": map[string]interface{}{"user": map[string]interface{}{"anonymous_id": anonymousId}}}
+	}
+	assign := func(anonymousId string) (string, interface{}) {
+		e := event(anonymousId)
+		s.Assign("token1", "token1_id", e)
+		eventnCtx := e["eventn_ctx"].(map[string]interface{})
+		sessionId, _ := eventnCtx["session_id"].(string)
+		return sessionId, eventnCtx["session_sequence"]
+	}
+
+	first, sequence := assign("a")
+	require.NotEmpty(t, first)
+	require.Equal(t, 1, sequence)
+	other, sequence := assign("b")
+	require.NotEqual(t, first, other)
+	require.Equal(t, 1, sequence)
+
+	now = now.Add(10 * time.Minute)
+	sessionId, sequence := assign("a")
+	require.Equal(t, first, sessionId)
+	require.Equal(t, 2, sequence)
+
+	//events without user id aren't sessionized
+	withoutUser := events.Fact{"event_type": "pageview"}
+	s.Assign("token1", "token1_id", withoutUser)
+	require.Equal(t, events.Fact{"event_type": "pageview"}, withoutUser)
+
+	//b is expired and closed by the check, a is continued
+	now = now.Add(25 * time.Minute)
+	s.closeExpired(now)
+	require.Len(t, consumer.facts, 1)
+	require.Equal(t, "_sessions", consumer.facts[0]["event_type"])
+	require.Equal(t, other, consumer.facts[0]["session_id"])
+	require.Equal(t, "b", consumer.facts[0]["user_id"])
+	require.Equal(t, 1, consumer.facts[0]["events"])
+	require.Equal(t, int64(0), consumer.facts[0]["duration_sec"])
+
+	//a is expired and closed by the next event: a new session is started
+	now = now.Add(time.Hour)
+	sessionId, sequence = assign("a")
+	require.NotEqual(t, first, sessionId)
+	require.Equal(t, 1, sequence)
+	require.Len(t, consumer.facts, 2)
+	require.Equal(t, events.Fact{
+		"event_type":    "_sessions",
+		"_table":        "_sessions",
+		"api_key":       "token1",
+		"_timestamp":    "2020-10-01T10:00:00.000000Z",
+		"session_id":    first,
+		"user_id":       "a",
+		"session_start": "2020-10-01T10:00:00.000000Z",
+		"session_end":   "2020-10-01T10:10:00.000000Z",
+		"duration_sec":  int64(600),
+		"events":        2,
+		"eventn_ctx":    consumer.facts[1]["eventn_ctx"],
+	}, consumer.facts[1])
+	require.NotEmpty(t, events.ExtractEventId(consumer.facts[1]))
+
+	//memory sessions are closed on shutdown
+	go s.start()
+	require.NoError(t, s.Close())
+	require.Len(t, consumer.facts, 3)
+	require.Equal(t, sessionId, consumer.facts[2]["session_id"])
+}
+
+func TestSummaryTable(t *testing.T) {
+	tests := []struct {
+		name          string
+		config        Config
+		expectedTable string
+	}{
+		{"default", Config{Enabled: true}, "_sessions"},
+		{"configured", Config{Enabled: true, SummaryTable: "user_sessions"}, "user_sessions"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newSessionizer(tt.config, NewMemory(), nil)
+			summary := s.summary(&Session{Id: "s1", Token: "token1", TokenId: "token1_id", UserId: "a",
+				StartedAt: time.Date(2020, 10, 1, 10, 0, 0, 0, time.UTC), LastEventAt: time.Date(2020, 10, 1, 10, 10, 0, 0, time.UTC), Events: 2})
+
+			//summaries aren't written into the events table of the default destination table name template
+			processor, err := schema.NewProcessor("events", []string{}, schema.Default, map[string]bool{}, nil, nil)
+			require.NoError(t, err)
+			table, object, err := processor.ProcessFact(summary)
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedTable, table.Name)
+			require.Equal(t, "_sessions", object["event_type"])
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package sessions
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	MemoryType = "memory"
+	RedisType  = "redis"
+)
+
+//Session is an open user session state
+type Session struct {
+	Id          string    `json:"id"`
+	Token       string    `json:"token"`
+	TokenId     string    `json:"token_id"`
+	UserId      string    `json:"user_id"`
+	StartedAt   time.Time `json:"started_at"`
+	LastEventAt time.Time `json:"last_event_at"`
+	//events count of the session. It is the sequence number of the last event
+	Events int `json:"events"`
+}
+
+//Storage is an open sessions state: session key (token id and user id) -> session
+type Storage interface {
+	io.Closer
+
+	//Get return the session or nil if there isn't an open session with the key
+	Get(key string) (*Session, error)
+	//Save put the session with its expiration time (last event time + inactivity timeout)
+	Save(key string, session *Session, expiresAt time.Time) error
+	//Expired return at most limit keys of sessions which expire before or at now
+	Expired(now time.Time, limit int) ([]string, error)
+	//Remove delete the session and return it or nil if it has already been removed (e.g. closed by another instance)
+	Remove(key string) (*Session, error)
+
+	Type() string
+}
+
+//StorageConfig is an open sessions storage configuration
+type StorageConfig struct {
+	Type  string `mapstructure:"type"`
+	Redis struct {
+		Host     string `mapstructure:"host"`
+		Port     int    `mapstructure:"port"`
+		Password string `mapstructure:"password"`
+	} `mapstructure:"redis"`
+}
+
+func NewStorage(config StorageConfig) (Storage, error) {
+	switch config.Type {
+	case "", MemoryType:
+		return NewMemory(), nil
+	case RedisType:
+		if config.Redis.Host == "" {
+			return nil, fmt.Errorf("sessionization.storage.redis.host is required")
+		}
+		port := config.Redis.Port
+		if port == 0 {
+			port = 6379
+		}
+		return NewRedis(config.Redis.Host, port, config.Redis.Password)
+	default:
+		return nil, fmt.Errorf("Unknown sessionization storage type: %s. Available: [%s, %s]", config.Type, MemoryType, RedisType)
+	}
+}
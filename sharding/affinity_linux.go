@@ -0,0 +1,17 @@
+package sharding
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+//pinThread bind the current OS thread to the cpu via sched_setaffinity
+func pinThread(cpu int) error {
+	var mask [1024 / 64]uint64
+	mask[cpu/64] |= 1 << (uint(cpu) % 64)
+	_, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_SETAFFINITY, 0, uintptr(len(mask)*8), uintptr(unsafe.Pointer(&mask[0])))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,10 @@
+// +build !linux
+
+package sharding
+
+import "errors"
+
+//pinThread isn't supported: shard worker is only locked to OS thread
+func pinThread(cpu int) error {
+	return errors.New("CPU pinning is supported only on Linux")
+}
@@ -0,0 +1,185 @@
+package sharding
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/hashing"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/metrics"
+	"github.com/jitsucom/eventnative/safego"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const defaultQueueSize = 1000
+
+var ErrClosed = errors.New("Processing shards are closed")
+
+var defaultPartitioner, _ = hashing.NewPartitioner(hashing.Default)
+
+//Config is a processing pipeline sharding configuration
+type Config struct {
+	Enabled bool `mapstructure:"enabled"`
+	//shards count. 0 - GOMAXPROCS
+	Shards int `mapstructure:"shards"`
+	//max pending events per shard. If it is exceeded - events are rejected with events.ErrQueueFull
+	QueueSize int `mapstructure:"queue_size"`
+	//lock every shard worker to OS thread and bind the thread to CPU (shard index % CPU count). Linux only
+	PinCpus bool `mapstructure:"pin_cpus"`
+	//token hash function: fnv (default), murmur2, murmur3 or xxhash
+	Hash string `mapstructure:"hash"`
+}
+
+//Job is a processing function which is executed by shard worker with shard own preprocessor
+type Job func(preprocessor events.Preprocessor)
+
+type task struct {
+	job  Job
+	done chan struct{}
+}
+
+type shard struct {
+	index        int
+	label        string
+	preprocessor events.Preprocessor
+	queue        chan *task
+}
+
+//Shards is a processing pipeline which is split into N independent shards by token hash.
+//Every shard has own preprocessor instance, queue and worker goroutine so events of different tokens
+//don't contend on the same locks and channels
+type Shards struct {
+	sync.RWMutex
+
+	name        string
+	partitioner *hashing.Partitioner
+	shards      []*shard
+	wg          sync.WaitGroup
+	closed      bool
+}
+
+//New return Shards with preprocessor (created by preprocessorFactory) per shard and run shard workers
+func New(name string, config Config, preprocessorFactory func() (events.Preprocessor, error)) (*Shards, error) {
+	count := config.Shards
+	if count <= 0 {
+		count = runtime.GOMAXPROCS(0)
+	}
+	queueSize := config.QueueSize
+	if queueSize <= 0 {
+		queueSize = defaultQueueSize
+	}
+
+	partitioner, err := hashing.NewPartitioner(config.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Shards{name: name, partitioner: partitioner}
+	for i := 0; i < count; i++ {
+		preprocessor, err := preprocessorFactory()
+		if err != nil {
+			return nil, fmt.Errorf("Error creating preprocessor of shard #%d: %v", i, err)
+		}
+		s.shards = append(s.shards, &shard{index: i, label: strconv.Itoa(i), preprocessor: preprocessor, queue: make(chan *task, queueSize)})
+	}
+
+	for _, sh := range s.shards {
+		s.wg.Add(1)
+		s.run(sh, config.PinCpus)
+	}
+
+	logging.Infof("[%s] Processing pipeline is split into %d shards (queue size: %d, pin cpus: %v, hash: %s)", name, count, queueSize, config.PinCpus, partitioner.Name())
+	return s, nil
+}
+
+//Count return shards count
+func (s *Shards) Count() int {
+	return len(s.shards)
+}
+
+//Index return shard index of the token. All events of the token are processed by the same shard
+func (s *Shards) Index(token string) int {
+	return s.partitioner.Partition([]byte(token), len(s.shards))
+}
+
+//Do put job into the token shard queue and wait until it is executed
+//return events.ErrQueueFull if the shard queue is full or ErrClosed
+func (s *Shards) Do(token string, job Job) error {
+	sh := s.shards[s.Index(token)]
+	t := &task{job: job, done: make(chan struct{})}
+
+	s.RLock()
+	if s.closed {
+		s.RUnlock()
+		return ErrClosed
+	}
+	select {
+	case sh.queue <- t:
+	default:
+		s.RUnlock()
+		metrics.ShardRejected(s.name, sh.label)
+		return events.ErrQueueFull
+	}
+	s.RUnlock()
+	metrics.ShardQueueSize(s.name, sh.label, len(sh.queue))
+
+	<-t.done
+	return nil
+}
+
+//Close stop receiving jobs and wait until all shards queues are drained
+func (s *Shards) Close() error {
+	s.Lock()
+	if s.closed {
+		s.Unlock()
+		return nil
+	}
+	s.closed = true
+	for _, sh := range s.shards {
+		close(sh.queue)
+	}
+	s.Unlock()
+
+	s.wg.Wait()
+	return nil
+}
+
+func (s *Shards) run(sh *shard, pinCpu bool) {
+	safego.RunWithRestart(func() {
+		if pinCpu {
+			runtime.LockOSThread()
+			cpu := sh.index % runtime.NumCPU()
+			if err := pinThread(cpu); err != nil {
+				logging.Warnf("[%s] Error pinning shard #%d to CPU %d: %v", s.name, sh.index, cpu, err)
+			}
+		}
+
+		for t := range sh.queue {
+			start := time.Now()
+			s.execute(sh, t)
+			metrics.ShardProcessed(s.name, sh.label, time.Since(start).Seconds())
+			metrics.ShardQueueSize(s.name, sh.label, len(sh.queue))
+		}
+		s.wg.Done()
+	})
+}
+
+//execute run the job and release the waiting caller even if the job panics
+func (s *Shards) execute(sh *shard, t *task) {
+	defer close(t.done)
+	defer func() {
+		if r := recover(); r != nil {
+			logging.SystemErrorf("[%s] Panic in shard #%d job: %v", s.name, sh.index, r)
+		}
+	}()
+
+	t.job(sh.preprocessor)
+}
+
+//Index return shard index of the token with the default hash function (FNV-1a)
+func Index(token string, count int) int {
+	return defaultPartitioner.Partition([]byte(token), count)
+}
@@ -0,0 +1,94 @@
+package sharding
+
+import (
+	"fmt"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testPreprocessor struct {
+	id int
+}
+
+func (tp *testPreprocessor) Preprocess(fact events.Fact) (events.Fact, error) {
+	fact["shard"] = tp.id
+	return fact, nil
+}
+
+func TestIndex(t *testing.T) {
+	require.Equal(t, 0, Index("token", 0))
+	require.Equal(t, 0, Index("token", 1))
+
+	for _, token := range []string{"a", "b", "c", "token1", "token2"} {
+		index := Index(token, 8)
+		require.True(t, index >= 0 && index < 8)
+		require.Equal(t, index, Index(token, 8), "index of the same token must be stable")
+	}
+
+	distribution := map[int]int{}
+	for i := 0; i < 1000; i++ {
+		distribution[Index(fmt.Sprintf("token%d", i), 4)]++
+	}
+	require.Len(t, distribution, 4)
+}
+
+func TestDo(t *testing.T) {
+	created := 0
+	shards, err := New("test", Config{Shards: 4}, func() (events.Preprocessor, error) {
+		p := &testPreprocessor{id: created}
+		created++
+		return p, nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 4, shards.Count())
+	require.Equal(t, 4, created)
+
+	wg := sync.WaitGroup{}
+	results := make([]events.Fact, 100)
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			token := []string{"t1", "t2", "t3"}[i%3]
+			err := shards.Do(token, func(preprocessor events.Preprocessor) {
+				results[i], _ = preprocessor.Preprocess(events.Fact{"token": token})
+			})
+			require.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, result := range results {
+		require.Equal(t, shards.Index(result["token"].(string)), result["shard"], "events must be processed by the token shard preprocessor")
+	}
+
+	require.NoError(t, shards.Close())
+	require.Equal(t, ErrClosed, shards.Do("t1", func(preprocessor events.Preprocessor) {}))
+}
+
+func TestDoQueueFull(t *testing.T) {
+	shards, err := New("test", Config{Shards: 1, QueueSize: 1}, func() (events.Preprocessor, error) {
+		return &testPreprocessor{}, nil
+	})
+	require.NoError(t, err)
+	defer shards.Close()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	go shards.Do("t", func(preprocessor events.Preprocessor) {
+		close(started)
+		<-release
+	})
+	<-started
+	//the worker is busy: the first job fills the queue
+	go shards.Do("t", func(preprocessor events.Preprocessor) {})
+	for len(shards.shards[0].queue) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	require.Equal(t, events.ErrQueueFull, shards.Do("t", func(preprocessor events.Preprocessor) {}))
+	close(release)
+}
@@ -0,0 +1,147 @@
+package sources
+
+import (
+	"fmt"
+	"github.com/jitsucom/eventnative/drivers"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/faults"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/meta"
+	"github.com/jitsucom/eventnative/metrics"
+	"github.com/jitsucom/eventnative/storages"
+	"github.com/jitsucom/eventnative/timestamp"
+	"time"
+)
+
+//maxPagesPerSync prevents endless syncs of connectors which always return HasMore
+const maxPagesPerSync = 100000
+
+//ConnectorSyncTask read the collection pages from the persisted cursor state and store them into destinations
+//The state is saved after every page is stored in all destinations: failed syncs are continued from the last stored page
+type ConnectorSyncTask struct {
+	sourceId   string
+	collection string
+
+	identifier string
+
+	connector    drivers.Connector
+	metaStorage  meta.Storage
+	stateStorage StateStorage
+
+	destinations []events.Storage
+
+	lock    storages.Lock
+	trigger string
+}
+
+//Sync return count of stored objects and an error if the sync has failed
+func (cst *ConnectorSyncTask) Sync() (int, error) {
+	start := time.Now()
+	strWriter := logging.NewStringWriter()
+	strLogger := logging.NewSyncLogger(strWriter)
+
+	cst.updateCollectionStatus(meta.StatusLoading, "Still Running..")
+
+	status := meta.StatusFailed
+	defer func() {
+		cst.updateCollectionStatus(status, strWriter.String())
+	}()
+
+	logging.Infof("[%s] Running connector sync task type: [%s]", cst.identifier, cst.connector.Type())
+	strLogger.Infof("[%s] Running connector sync task type: [%s]", cst.identifier, cst.connector.Type())
+
+	state, err := cst.stateStorage.GetState(cst.sourceId, cst.collection)
+	if err != nil {
+		strLogger.Errorf("[%s] Error getting cursor state: %v", cst.identifier, err)
+		logging.Errorf("[%s] Error getting cursor state: %v", cst.identifier, err)
+		return 0, fmt.Errorf("Error getting cursor state: %v", err)
+	}
+	if state == "" {
+		strLogger.Infof("[%s] Cursor state is empty: running full sync", cst.identifier)
+	} else {
+		strLogger.Infof("[%s] Running incremental sync from cursor state: %s", cst.identifier, state)
+	}
+
+	var pages, total int
+	for hasMore := true; hasMore; pages++ {
+		if pages == maxPagesPerSync {
+			strLogger.Warnf("[%s] Sync has been stopped after %d pages: it will be continued from the last state", cst.identifier, pages)
+			logging.Warnf("[%s] Sync has been stopped after %d pages: it will be continued from the last state", cst.identifier, pages)
+			break
+		}
+
+		result, err := cst.connector.Read(cst.collection, state)
+		if err != nil {
+			strLogger.Errorf("[%s] Error reading page #%d: %v", cst.identifier, pages+1, err)
+			logging.Errorf("[%s] Error reading page #%d: %v", cst.identifier, pages+1, err)
+			return total, fmt.Errorf("Error reading page #%d: %v", pages+1, err)
+		}
+
+		if err := cst.store(strLogger, result.Objects); err != nil {
+			return total, err
+		}
+
+		if err := cst.stateStorage.SaveState(cst.sourceId, cst.collection, result.State); err != nil {
+			strLogger.Errorf("[%s] Error saving cursor state: %v", cst.identifier, err)
+			logging.SystemErrorf("Unable to save source [%s] collection [%s] cursor state: %v", cst.sourceId, cst.collection, err)
+			return total, fmt.Errorf("Error saving cursor state: %v", err)
+		}
+
+		total += len(result.Objects)
+		state = result.State
+		hasMore = result.HasMore
+	}
+
+	end := time.Now().Sub(start)
+	strLogger.Infof("[%s] FINISHED SUCCESSFULLY: %d objects of %d pages in [%.2f] seconds (~ %.2f minutes)", cst.identifier, total, pages, end.Seconds(), end.Minutes())
+	logging.Infof("[%s] type: [%s] objects: [%d] FINISHED SUCCESSFULLY in [%.2f] seconds (~ %.2f minutes)", cst.identifier, cst.connector.Type(), total, end.Seconds(), end.Minutes())
+	status = meta.StatusOk
+	return total, nil
+}
+
+//store enrich objects and store them into all destinations. Return an error if any destination fails
+func (cst *ConnectorSyncTask) store(strLogger *logging.SyncLogger, objects []map[string]interface{}) error {
+	if len(objects) == 0 {
+		return nil
+	}
+
+	for _, object := range objects {
+		//converted events (e.g. Amplitude, Mixpanel exports) keep their own src
+		if _, ok := object["src"]; !ok {
+			object["src"] = "source"
+		}
+		object[timestamp.Key] = timestamp.NowUTC()
+		events.EnrichWithEventId(object, getHash(object))
+		events.EnrichWithCollection(object, cst.collection)
+	}
+
+	for _, storage := range cst.destinations {
+		//test-mode fault injection
+		var rowsCount int
+		err := faults.Instance.Inject(storage.Name())
+		if err == nil {
+			rowsCount, err = storage.SyncStore(objects)
+		}
+		if err != nil {
+			strLogger.Errorf("[%s] Error storing %d source objects in [%s] destination: %v", cst.identifier, rowsCount, storage.Name(), err)
+			logging.Errorf("[%s] Error storing %d source objects in [%s] destination: %v", cst.identifier, rowsCount, storage.Name(), err)
+			metrics.ErrorSourceEvents(cst.sourceId, storage.Name(), rowsCount)
+			metrics.ErrorObjects(cst.sourceId, rowsCount)
+			return fmt.Errorf("Error storing %d source objects in [%s] destination: %v", rowsCount, storage.Name(), err)
+		}
+
+		metrics.SuccessSourceEvents(cst.sourceId, storage.Name(), rowsCount)
+		metrics.SuccessObjects(cst.sourceId, rowsCount)
+	}
+
+	return nil
+}
+
+func (cst *ConnectorSyncTask) updateCollectionStatus(status, logs string) {
+	if err := cst.metaStorage.SaveCollectionStatus(cst.sourceId, cst.collection, status); err != nil {
+		logging.SystemErrorf("Unable to update source [%s] collection [%s] status in storage: %v", cst.sourceId, cst.collection, err)
+	}
+	if err := cst.metaStorage.SaveCollectionLog(cst.sourceId, cst.collection, logs); err != nil {
+		logging.SystemErrorf("Unable to update source [%s] collection [%s] log in storage: %v", cst.sourceId, cst.collection, err)
+	}
+}
@@ -0,0 +1,130 @@
+package sources
+
+import (
+	"errors"
+	"github.com/jitsucom/eventnative/drivers"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/meta"
+	"github.com/jitsucom/eventnative/typing"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"testing"
+)
+
+//connectorMock return objects with ids > state by pages of pageSize
+type connectorMock struct {
+	total    int
+	pageSize int
+	reads    []string
+}
+
+func (cm *connectorMock) Discover() ([]*drivers.Collection, error) {
+	return []*drivers.Collection{{Name: "items", Incremental: true, CursorField: "id"}}, nil
+}
+
+func (cm *connectorMock) Read(collection, state string) (*drivers.ReadResult, error) {
+	cm.reads = append(cm.reads, state)
+	last := 0
+	if state != "" {
+		last, _ = strconv.Atoi(state)
+	}
+
+	result := &drivers.ReadResult{State: state}
+	for id := last + 1; id <= cm.total && len(result.Objects) < cm.pageSize; id++ {
+		result.Objects = append(result.Objects, map[string]interface{}{"id": id})
+		result.State = strconv.Itoa(id)
+	}
+	last, _ = strconv.Atoi(result.State)
+	result.HasMore = last < cm.total
+	return result, nil
+}
+
+func (cm *connectorMock) Type() string { return "mock" }
+func (cm *connectorMock) Close() error { return nil }
+
+type syncStorageMock struct {
+	failAfter int
+	objects   []map[string]interface{}
+}
+
+func (ssm *syncStorageMock) SyncStore(objects []map[string]interface{}) (int, error) {
+	if ssm.failAfter >= 0 && len(ssm.objects) >= ssm.failAfter {
+		return len(objects), errors.New("destination is unavailable")
+	}
+	ssm.objects = append(ssm.objects, objects...)
+	return len(objects), nil
+}
+func (ssm *syncStorageMock) Store(fileName string, payload []byte) (int, error) { return 0, nil }
+func (ssm *syncStorageMock) StoreWithParseFunc(fileName string, payload []byte, parseFunc func([]byte) (map[string]interface{}, error)) (int, error) {
+	return 0, nil
+}
+func (ssm *syncStorageMock) Fallback(fact ...*events.FailedFact)            {}
+func (ssm *syncStorageMock) ColumnTypesMapping() map[typing.DataType]string { return nil }
+func (ssm *syncStorageMock) Name() string                                   { return "mock_destination" }
+func (ssm *syncStorageMock) Type() string                                   { return "mock" }
+func (ssm *syncStorageMock) Close() error                                   { return nil }
+
+func TestConnectorSyncTask(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sources_state")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	stateStorage, err := NewStateStorage(StateConfig{Type: FileStateType}, &meta.Dummy{}, dir)
+	require.NoError(t, err)
+
+	connector := &connectorMock{total: 5, pageSize: 2}
+	storage := &syncStorageMock{failAfter: 2}
+	task := &ConnectorSyncTask{
+		sourceId:     "src1",
+		collection:   "items",
+		identifier:   "src1_items",
+		connector:    connector,
+		metaStorage:  &meta.Dummy{},
+		stateStorage: stateStorage,
+		destinations: []events.Storage{storage},
+	}
+
+	//the second page fails: the state of the first page is kept
+	rows, err := task.Sync()
+	require.EqualError(t, err, "Error storing 2 source objects in [mock_destination] destination: destination is unavailable")
+	require.Equal(t, 2, rows)
+	require.Len(t, storage.objects, 2)
+	require.Equal(t, "items", storage.objects[0]["eventn_ctx"].(map[string]interface{})["collection_id"])
+	state, err := stateStorage.GetState("src1", "items")
+	require.NoError(t, err)
+	require.Equal(t, "2", state)
+
+	//the sync is continued from the last stored page
+	storage.failAfter = -1
+	rows, err = task.Sync()
+	require.NoError(t, err)
+	require.Equal(t, 3, rows)
+	require.Len(t, storage.objects, 5)
+	require.Equal(t, 3, storage.objects[2]["id"])
+	require.Equal(t, []string{"", "2", "2", "4"}, connector.reads)
+
+	//incremental sync: nothing new
+	task.Sync()
+	require.Len(t, storage.objects, 5)
+
+	//state is persisted in the file
+	reopened, err := NewStateStorage(StateConfig{Type: FileStateType, Dir: dir}, &meta.Dummy{}, "")
+	require.NoError(t, err)
+	state, err = reopened.GetState("src1", "items")
+	require.NoError(t, err)
+	require.Equal(t, "5", state)
+}
+
+func TestNewStateStorage(t *testing.T) {
+	stateStorage, err := NewStateStorage(StateConfig{}, &meta.Dummy{}, "")
+	require.NoError(t, err)
+	require.Equal(t, MetaStateType, stateStorage.Type())
+
+	_, err = NewStateStorage(StateConfig{Type: "s3"}, &meta.Dummy{}, "")
+	require.Error(t, err)
+
+	_, err = NewStateStorage(StateConfig{Type: PostgresStateType}, &meta.Dummy{}, "")
+	require.Error(t, err)
+}
@@ -0,0 +1,75 @@
+package sources
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/meta"
+	"time"
+)
+
+const (
+	ScheduledTrigger = "scheduled"
+	ManualTrigger    = "manual"
+
+	//runsHistorySize is a max count of kept sync runs per collection
+	runsHistorySize = 100
+)
+
+//SyncRun is a collection synchronization run history record
+type SyncRun struct {
+	Collection string    `json:"collection"`
+	Trigger    string    `json:"trigger"`
+	Status     string    `json:"status"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Rows       int       `json:"rows"`
+	Error      string    `json:"error,omitempty"`
+}
+
+//newSyncRun return finished run record: status is FAILED if err isn't nil
+func newSyncRun(collection, trigger string, startedAt time.Time, rows int, err error) *SyncRun {
+	run := &SyncRun{
+		Collection: collection,
+		Trigger:    trigger,
+		Status:     meta.StatusOk,
+		StartedAt:  startedAt.UTC(),
+		FinishedAt: time.Now().UTC(),
+		Rows:       rows,
+	}
+	if err != nil {
+		run.Status = meta.StatusFailed
+		run.Error = err.Error()
+	}
+	return run
+}
+
+//saveSyncRun persist the run in meta storage history
+func saveSyncRun(metaStorage meta.Storage, sourceId string, run *SyncRun) {
+	b, err := json.Marshal(run)
+	if err != nil {
+		logging.SystemErrorf("Unable to marshal source [%s] collection [%s] sync run: %v", sourceId, run.Collection, err)
+		return
+	}
+	if err := metaStorage.AddSyncRun(sourceId, run.Collection, string(b), runsHistorySize); err != nil {
+		logging.SystemErrorf("Unable to save source [%s] collection [%s] sync run: %v", sourceId, run.Collection, err)
+	}
+}
+
+//getSyncRuns return the last limit runs of the collection from the newest to the oldest
+func getSyncRuns(metaStorage meta.Storage, sourceId, collection string, limit int) ([]*SyncRun, error) {
+	values, err := metaStorage.GetSyncRuns(sourceId, collection, limit)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting collection [%s] sync runs: %v", collection, err)
+	}
+
+	runs := make([]*SyncRun, 0, len(values))
+	for _, value := range values {
+		run := &SyncRun{}
+		if err := json.Unmarshal([]byte(value), run); err != nil {
+			return nil, fmt.Errorf("Error unmarshalling collection [%s] sync run: %v", collection, err)
+		}
+		runs = append(runs, run)
+	}
+	return runs, nil
+}
@@ -0,0 +1,63 @@
+package sources
+
+import (
+	"errors"
+	"github.com/jitsucom/eventnative/meta"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+//runsMetaStorageMock keeps sync runs in memory
+type runsMetaStorageMock struct {
+	meta.Dummy
+	runs map[string][]string
+}
+
+func (rmsm *runsMetaStorageMock) AddSyncRun(sourceId, collection, run string, limit int) error {
+	key := sourceId + "_" + collection
+	runs := append([]string{run}, rmsm.runs[key]...)
+	if len(runs) > limit {
+		runs = runs[:limit]
+	}
+	rmsm.runs[key] = runs
+	return nil
+}
+
+func (rmsm *runsMetaStorageMock) GetSyncRuns(sourceId, collection string, limit int) ([]string, error) {
+	runs := rmsm.runs[sourceId+"_"+collection]
+	if len(runs) > limit {
+		runs = runs[:limit]
+	}
+	return runs, nil
+}
+
+func TestSyncRuns(t *testing.T) {
+	metaStorage := &runsMetaStorageMock{runs: map[string][]string{}}
+	startedAt := time.Date(2020, 9, 1, 10, 0, 0, 0, time.UTC)
+
+	for i := 0; i < runsHistorySize+5; i++ {
+		saveSyncRun(metaStorage, "src1", newSyncRun("items", ScheduledTrigger, startedAt, i, nil))
+	}
+	saveSyncRun(metaStorage, "src1", newSyncRun("items", ManualTrigger, startedAt, 3, errors.New("destination is unavailable")))
+
+	runs, err := getSyncRuns(metaStorage, "src1", "items", 2)
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+	require.Equal(t, ManualTrigger, runs[0].Trigger)
+	require.Equal(t, meta.StatusFailed, runs[0].Status)
+	require.Equal(t, "destination is unavailable", runs[0].Error)
+	require.Equal(t, 3, runs[0].Rows)
+	require.Equal(t, startedAt, runs[0].StartedAt)
+	require.False(t, runs[0].FinishedAt.Before(startedAt))
+	require.Equal(t, meta.StatusOk, runs[1].Status)
+	require.Equal(t, runsHistorySize+4, runs[1].Rows)
+
+	runs, err = getSyncRuns(metaStorage, "src1", "items", 1000)
+	require.NoError(t, err)
+	require.Len(t, runs, runsHistorySize)
+
+	runs, err = getSyncRuns(metaStorage, "src1", "users", 10)
+	require.NoError(t, err)
+	require.Empty(t, runs)
+}
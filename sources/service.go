@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/hashicorp/go-multierror"
+	"github.com/jitsucom/eventnative/cron"
 	"github.com/jitsucom/eventnative/destinations"
 	"github.com/jitsucom/eventnative/drivers"
 	"github.com/jitsucom/eventnative/events"
@@ -20,6 +21,9 @@ import (
 	"time"
 )
 
+//schedulerInterval is a period of scheduled syncs checks (cron schedules have minute precision)
+const schedulerInterval = 10 * time.Second
+
 const marshallingErrorMsg = `Error initializing source: wrong config format: each source must contains one key and config as a value(see https://docs.eventnative.dev/configuration) e.g. 
 sources:  
   custom_name:
@@ -37,8 +41,12 @@ type Service struct {
 
 	destinationsService *destinations.Service
 	metaStorage         meta.Storage
+	stateStorage        StateStorage
 	monitorKeeper       storages.MonitorKeeper
 
+	//identifiers of collections which are being synchronized by this instance
+	running sync.Map
+
 	closed bool
 }
 
@@ -48,7 +56,7 @@ func NewTestService() *Service {
 }
 
 func NewService(ctx context.Context, sources *viper.Viper, destinationsService *destinations.Service,
-	metaStorage meta.Storage, monitorKeeper storages.MonitorKeeper, poolSize int) (*Service, error) {
+	metaStorage meta.Storage, stateStorage StateStorage, monitorKeeper storages.MonitorKeeper, poolSize int) (*Service, error) {
 
 	service := &Service{
 		ctx:     ctx,
@@ -56,6 +64,7 @@ func NewService(ctx context.Context, sources *viper.Viper, destinationsService *
 
 		destinationsService: destinationsService,
 		metaStorage:         metaStorage,
+		stateStorage:        stateStorage,
 		monitorKeeper:       monitorKeeper,
 	}
 
@@ -87,26 +96,57 @@ func NewService(ctx context.Context, sources *viper.Viper, destinationsService *
 		logging.Errorf("Sources are empty")
 	}
 
+	service.startScheduler()
+
 	return service, nil
 }
 
 func (s *Service) init(sc map[string]drivers.SourceConfig) {
 	for name, sourceConfig := range sc {
+		if sourceConfig.Type == "" {
+			sourceConfig.Type = name
+		}
 
-		driverPerCollection, err := drivers.Create(s.ctx, name, &sourceConfig)
-		if err != nil {
-			logging.Errorf("[%s] Error initializing source of type %s: %v", name, sourceConfig.Type, err)
-			continue
+		var schedule *cron.Schedule
+		if sourceConfig.Schedule != "" {
+			var err error
+			schedule, err = cron.Parse(sourceConfig.Schedule)
+			if err != nil {
+				logging.Errorf("[%s] Error initializing source of type %s: %v", name, sourceConfig.Type, err)
+				continue
+			}
 		}
 
-		s.Lock()
-		s.sources[name] = &Unit{
-			DriverPerCollection: driverPerCollection,
-			DestinationIds:      sourceConfig.Destinations,
+		unit := &Unit{DestinationIds: sourceConfig.Destinations, Schedule: schedule}
+		if drivers.IsConnector(sourceConfig.Type) {
+			connector, err := drivers.CreateConnector(s.ctx, name, &sourceConfig)
+			if err != nil {
+				logging.Errorf("[%s] Error initializing source of type %s: %v", name, sourceConfig.Type, err)
+				continue
+			}
+			unit.Connector = connector
+			unit.Collections = sourceConfig.Collections
+		} else {
+			driverPerCollection, err := drivers.Create(s.ctx, name, &sourceConfig)
+			if err != nil {
+				logging.Errorf("[%s] Error initializing source of type %s: %v", name, sourceConfig.Type, err)
+				continue
+			}
+			unit.DriverPerCollection = driverPerCollection
+		}
+		if schedule != nil {
+			unit.nextRun = schedule.Next(time.Now())
 		}
+
+		s.Lock()
+		s.sources[name] = unit
 		s.Unlock()
 
-		logging.Infof("[%s] source has been initialized!", name)
+		if schedule != nil {
+			logging.Infof("[%s] source has been initialized! Scheduled syncs: %s, next: %s", name, schedule, unit.nextRun.Format(time.RFC3339))
+		} else {
+			logging.Infof("[%s] source has been initialized!", name)
+		}
 
 	}
 }
@@ -127,7 +167,9 @@ func (s *Service) startMonitoring() {
 	})
 }
 
-func (s *Service) Sync(sourceId string) (multiErr error) {
+//Sync run sync tasks of the source collections (or only of the collection if it isn't empty). trigger is saved in
+//the runs history. Collections which are being synchronized by this instance are skipped with an error
+func (s *Service) Sync(sourceId, collection, trigger string) (multiErr error) {
 	s.RLock()
 	sourceUnit, ok := s.sources[sourceId]
 	s.RUnlock()
@@ -136,6 +178,14 @@ func (s *Service) Sync(sourceId string) (multiErr error) {
 		return errors.New("Source doesn't exist")
 	}
 
+	collections := sourceUnit.collections()
+	if collection != "" {
+		if !sourceUnit.hasCollection(collection) {
+			return fmt.Errorf("Source doesn't have collection [%s]", collection)
+		}
+		collections = []string{collection}
+	}
+
 	var destinationStorages []events.Storage
 	for _, destinationId := range sourceUnit.DestinationIds {
 		storageProxy, ok := s.destinationsService.GetStorageById(destinationId)
@@ -156,25 +206,52 @@ func (s *Service) Sync(sourceId string) (multiErr error) {
 		return errors.New("Empty destinations")
 	}
 
-	for collection, driver := range sourceUnit.DriverPerCollection {
+	for _, collection := range collections {
 		identifier := sourceId + "_" + collection
 
+		//the cluster lock waits for running syncs: scheduled and manual syncs of the same collection aren't queued
+		if _, running := s.running.LoadOrStore(identifier, true); running {
+			multiErr = multierror.Append(multiErr, fmt.Errorf("[%s] source [%s] collection is already being synchronized", sourceId, collection))
+			continue
+		}
+
 		collectionLock, err := s.monitorKeeper.Lock(sourceId, collection)
 		if err != nil {
+			s.running.Delete(identifier)
 			multiErr = multierror.Append(multiErr, fmt.Errorf("Error locking [%s] source [%s] collection: %v", sourceId, collection, err))
 			continue
 		}
 
-		err = s.pool.Invoke(SyncTask{
-			sourceId:     sourceId,
-			collection:   collection,
-			identifier:   identifier,
-			driver:       driver,
-			metaStorage:  s.metaStorage,
-			destinations: destinationStorages,
-			lock:         collectionLock,
-		})
+		var task interface{}
+		if sourceUnit.Connector != nil {
+			task = ConnectorSyncTask{
+				sourceId:     sourceId,
+				collection:   collection,
+				identifier:   identifier,
+				connector:    sourceUnit.Connector,
+				metaStorage:  s.metaStorage,
+				stateStorage: s.stateStorage,
+				destinations: destinationStorages,
+				lock:         collectionLock,
+				trigger:      trigger,
+			}
+		} else {
+			task = SyncTask{
+				sourceId:     sourceId,
+				collection:   collection,
+				identifier:   identifier,
+				driver:       sourceUnit.DriverPerCollection[collection],
+				metaStorage:  s.metaStorage,
+				destinations: destinationStorages,
+				lock:         collectionLock,
+				trigger:      trigger,
+			}
+		}
+
+		err = s.pool.Invoke(task)
 		if err != nil {
+			s.monitorKeeper.Unlock(collectionLock)
+			s.running.Delete(identifier)
 			multiErr = multierror.Append(multiErr, fmt.Errorf("Error running sync task goroutine [%s] source [%s] collection: %v", sourceId, collection, err))
 			continue
 		}
@@ -194,7 +271,7 @@ func (s *Service) GetStatus(sourceId string) (map[string]string, error) {
 	}
 
 	statuses := map[string]string{}
-	for collection, _ := range sourceUnit.DriverPerCollection {
+	for _, collection := range sourceUnit.collections() {
 		status, err := s.metaStorage.GetCollectionStatus(sourceId, collection)
 		if err != nil {
 			return nil, fmt.Errorf("Error getting collection status: %v", err)
@@ -217,7 +294,7 @@ func (s *Service) GetLogs(sourceId string) (map[string]string, error) {
 	}
 
 	logsMap := map[string]string{}
-	for collection, _ := range sourceUnit.DriverPerCollection {
+	for _, collection := range sourceUnit.collections() {
 		log, err := s.metaStorage.GetCollectionLog(sourceId, collection)
 		if err != nil {
 			return nil, fmt.Errorf("Error getting collection logs: %v", err)
@@ -229,15 +306,105 @@ func (s *Service) GetLogs(sourceId string) (map[string]string, error) {
 	return logsMap, nil
 }
 
-func (s *Service) syncCollection(i interface{}) {
-	synctTask, ok := i.(SyncTask)
+//GetRuns return the last limit sync runs per collection from the newest to the oldest
+func (s *Service) GetRuns(sourceId string, limit int) (map[string][]*SyncRun, error) {
+	s.RLock()
+	sourceUnit, ok := s.sources[sourceId]
+	s.RUnlock()
+
 	if !ok {
+		return nil, errors.New("Source doesn't exist")
+	}
+
+	runsMap := map[string][]*SyncRun{}
+	for _, collection := range sourceUnit.collections() {
+		runs, err := getSyncRuns(s.metaStorage, sourceId, collection, limit)
+		if err != nil {
+			return nil, err
+		}
+
+		runsMap[collection] = runs
+	}
+
+	return runsMap, nil
+}
+
+//Discover return collections of the connector source
+func (s *Service) Discover(sourceId string) ([]*drivers.Collection, error) {
+	s.RLock()
+	sourceUnit, ok := s.sources[sourceId]
+	s.RUnlock()
+
+	if !ok {
+		return nil, errors.New("Source doesn't exist")
+	}
+	if sourceUnit.Connector == nil {
+		return nil, fmt.Errorf("Source type doesn't support discovery. Connectors types: %v", drivers.ConnectorTypes())
+	}
+
+	collections, err := sourceUnit.Connector.Discover()
+	if err != nil {
+		return nil, fmt.Errorf("Error discovering collections: %v", err)
+	}
+	return collections, nil
+}
+
+//startScheduler run goroutine which starts scheduled sources syncs (checks every schedulerInterval)
+func (s *Service) startScheduler() {
+	safego.RunWithRestart(func() {
+		for {
+			if s.closed {
+				break
+			}
+
+			now := time.Now()
+			var due []string
+			s.Lock()
+			for sourceId, unit := range s.sources {
+				if unit.Schedule == nil || unit.nextRun.IsZero() || now.Before(unit.nextRun) {
+					continue
+				}
+				unit.nextRun = unit.Schedule.Next(now)
+				due = append(due, sourceId)
+			}
+			s.Unlock()
+
+			for _, sourceId := range due {
+				id := sourceId
+				//locking of collections might wait for other cluster nodes syncs
+				safego.Run(func() {
+					logging.Infof("[%s] Running scheduled sync", id)
+					if err := s.Sync(id, "", ScheduledTrigger); err != nil {
+						logging.Errorf("[%s] Error running scheduled sync: %v", id, err)
+					}
+				})
+			}
+
+			time.Sleep(schedulerInterval)
+		}
+	})
+}
+
+//syncCollection run the sync task, save the run into the history and release collection locks
+func (s *Service) syncCollection(i interface{}) {
+	start := time.Now()
+	switch task := i.(type) {
+	case SyncTask:
+		defer s.release(task.identifier, task.lock)
+		rows, err := task.Sync()
+		saveSyncRun(s.metaStorage, task.sourceId, newSyncRun(task.collection, task.trigger, start, rows, err))
+	case ConnectorSyncTask:
+		defer s.release(task.identifier, task.lock)
+		rows, err := task.Sync()
+		saveSyncRun(s.metaStorage, task.sourceId, newSyncRun(task.collection, task.trigger, start, rows, err))
+	default:
 		logging.SystemErrorf("Sync task has unknown type: %T", i)
-		return
 	}
+}
 
-	defer s.monitorKeeper.Unlock(synctTask.lock)
-	synctTask.Sync()
+func (s *Service) release(identifier string, lock storages.Lock) {
+	s.monitorKeeper.Unlock(lock)
+	s.running.Delete(identifier)
 }
 
 func (s *Service) Close() error {
@@ -247,5 +414,21 @@ func (s *Service) Close() error {
 		s.pool.Release()
 	}
 
+	s.RLock()
+	for sourceId, unit := range s.sources {
+		if unit.Connector != nil {
+			if err := unit.Connector.Close(); err != nil {
+				logging.Errorf("[%s] Error closing connector: %v", sourceId, err)
+			}
+		}
+	}
+	s.RUnlock()
+
+	if s.stateStorage != nil {
+		if err := s.stateStorage.Close(); err != nil {
+			logging.Errorf("Error closing sources state storage: %v", err)
+		}
+	}
+
 	return nil
 }
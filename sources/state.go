@@ -0,0 +1,229 @@
+package sources
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/adapters"
+	"github.com/jitsucom/eventnative/meta"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"sync"
+)
+
+const (
+	MetaStateType     = "meta"
+	FileStateType     = "file"
+	PostgresStateType = "postgres"
+
+	defaultStateTable = "eventnative_sources_state"
+	stateFileSuffix   = ".state.json"
+)
+
+//StateConfig is a source connectors cursor state storage configuration: meta (default) - meta.storage (redis),
+//file - JSON file per source in Dir, postgres - Table in the database
+type StateConfig struct {
+	Type     string                     `mapstructure:"type" json:"type,omitempty" yaml:"type,omitempty"`
+	Dir      string                     `mapstructure:"dir" json:"dir,omitempty" yaml:"dir,omitempty"`
+	Postgres *adapters.DataSourceConfig `mapstructure:"postgres" json:"postgres,omitempty" yaml:"postgres,omitempty"`
+	Table    string                     `mapstructure:"table" json:"table,omitempty" yaml:"table,omitempty"`
+}
+
+//StateStorage keeps connectors cursor state per source collection. Empty state means that collection hasn't been synchronized
+type StateStorage interface {
+	io.Closer
+	GetState(sourceId, collection string) (string, error)
+	SaveState(sourceId, collection, state string) error
+	Type() string
+}
+
+//NewStateStorage return configured state storage. defaultDir is used by file state storage if Dir isn't set
+func NewStateStorage(config StateConfig, metaStorage meta.Storage, defaultDir string) (StateStorage, error) {
+	switch config.Type {
+	case "", MetaStateType:
+		return &metaStateStorage{metaStorage: metaStorage}, nil
+	case FileStateType:
+		dir := config.Dir
+		if dir == "" {
+			dir = defaultDir
+		}
+		return newFileStateStorage(dir)
+	case PostgresStateType:
+		table := config.Table
+		if table == "" {
+			table = defaultStateTable
+		}
+		return newPostgresStateStorage(config.Postgres, table)
+	default:
+		return nil, fmt.Errorf("Unknown sources state storage type: %s. Available: [%s, %s, %s]", config.Type, MetaStateType, FileStateType, PostgresStateType)
+	}
+}
+
+//metaStateStorage keeps state in meta storage
+type metaStateStorage struct {
+	metaStorage meta.Storage
+}
+
+func (mss *metaStateStorage) GetState(sourceId, collection string) (string, error) {
+	return mss.metaStorage.GetCollectionState(sourceId, collection)
+}
+
+func (mss *metaStateStorage) SaveState(sourceId, collection, state string) error {
+	return mss.metaStorage.SaveCollectionState(sourceId, collection, state)
+}
+
+func (mss *metaStateStorage) Type() string {
+	return MetaStateType
+}
+
+//Close doesn't close meta storage: it is closed separately
+func (mss *metaStateStorage) Close() error {
+	return nil
+}
+
+//fileStateStorage keeps states of source collections in <dir>/<source id>.state.json file: {"collection": "state"}
+//Files are replaced atomically (written into temporary file and renamed)
+type fileStateStorage struct {
+	sync.Mutex
+	dir string
+}
+
+func newFileStateStorage(dir string) (*fileStateStorage, error) {
+	if dir == "" {
+		return nil, errors.New("Sources state dir is required")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("Error creating sources state dir [%s]: %v", dir, err)
+	}
+	return &fileStateStorage{dir: dir}, nil
+}
+
+func (fss *fileStateStorage) GetState(sourceId, collection string) (string, error) {
+	fss.Lock()
+	defer fss.Unlock()
+
+	states, err := fss.read(sourceId)
+	if err != nil {
+		return "", err
+	}
+	return states[collection], nil
+}
+
+func (fss *fileStateStorage) SaveState(sourceId, collection, state string) error {
+	fss.Lock()
+	defer fss.Unlock()
+
+	states, err := fss.read(sourceId)
+	if err != nil {
+		return err
+	}
+	states[collection] = state
+
+	b, err := json.MarshalIndent(states, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Error marshalling source [%s] state: %v", sourceId, err)
+	}
+
+	filePath := fss.filePath(sourceId)
+	tmpPath := filePath + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, b, 0644); err != nil {
+		return fmt.Errorf("Error writing source [%s] state file: %v", sourceId, err)
+	}
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		return fmt.Errorf("Error renaming source [%s] state file: %v", sourceId, err)
+	}
+	return nil
+}
+
+func (fss *fileStateStorage) read(sourceId string) (map[string]string, error) {
+	states := map[string]string{}
+	b, err := ioutil.ReadFile(fss.filePath(sourceId))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return states, nil
+		}
+		return nil, fmt.Errorf("Error reading source [%s] state file: %v", sourceId, err)
+	}
+
+	if err := json.Unmarshal(b, &states); err != nil {
+		return nil, fmt.Errorf("Error unmarshalling source [%s] state file: %v", sourceId, err)
+	}
+	return states, nil
+}
+
+func (fss *fileStateStorage) filePath(sourceId string) string {
+	//source ids might contain path separators
+	return path.Join(fss.dir, strings.ReplaceAll(sourceId, "/", "_")+stateFileSuffix)
+}
+
+func (fss *fileStateStorage) Type() string {
+	return FileStateType
+}
+
+func (fss *fileStateStorage) Close() error {
+	return nil
+}
+
+//postgresStateStorage keeps states in the table (it is created if doesn't exist)
+type postgresStateStorage struct {
+	dataSource *sql.DB
+	table      string
+}
+
+func newPostgresStateStorage(config *adapters.DataSourceConfig, table string) (*postgresStateStorage, error) {
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("Error validating sources state postgres config: %v", err)
+	}
+	if config.Schema != "" {
+		table = config.Schema + "." + table
+	}
+
+	dataSource, err := sql.Open("postgres", adapters.PostgresConnectionString(config))
+	if err != nil {
+		return nil, fmt.Errorf("Error connecting to sources state postgres: %v", err)
+	}
+	if err := dataSource.Ping(); err != nil {
+		dataSource.Close()
+		return nil, fmt.Errorf("Error connecting to sources state postgres: %v", err)
+	}
+
+	createStatement := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (source_id text NOT NULL, collection text NOT NULL, state text NOT NULL, updated_at timestamp NOT NULL DEFAULT now(), PRIMARY KEY (source_id, collection))`, table)
+	if _, err := dataSource.Exec(createStatement); err != nil {
+		dataSource.Close()
+		return nil, fmt.Errorf("Error creating sources state table [%s]: %v", table, err)
+	}
+
+	return &postgresStateStorage{dataSource: dataSource, table: table}, nil
+}
+
+func (pss *postgresStateStorage) GetState(sourceId, collection string) (string, error) {
+	var state string
+	err := pss.dataSource.QueryRow(fmt.Sprintf(`SELECT state FROM %s WHERE source_id = $1 AND collection = $2`, pss.table), sourceId, collection).Scan(&state)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("Error reading source [%s] collection [%s] state: %v", sourceId, collection, err)
+	}
+	return state, nil
+}
+
+func (pss *postgresStateStorage) SaveState(sourceId, collection, state string) error {
+	statement := fmt.Sprintf(`INSERT INTO %s (source_id, collection, state, updated_at) VALUES ($1, $2, $3, now()) ON CONFLICT (source_id, collection) DO UPDATE SET state = EXCLUDED.state, updated_at = EXCLUDED.updated_at`, pss.table)
+	if _, err := pss.dataSource.Exec(statement, sourceId, collection, state); err != nil {
+		return fmt.Errorf("Error saving source [%s] collection [%s] state: %v", sourceId, collection, err)
+	}
+	return nil
+}
+
+func (pss *postgresStateStorage) Type() string {
+	return PostgresStateType
+}
+
+func (pss *postgresStateStorage) Close() error {
+	return pss.dataSource.Close()
+}
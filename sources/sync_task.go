@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/jitsucom/eventnative/drivers"
 	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/faults"
 	"github.com/jitsucom/eventnative/logging"
 	"github.com/jitsucom/eventnative/meta"
 	"github.com/jitsucom/eventnative/metrics"
@@ -25,10 +26,12 @@ type SyncTask struct {
 
 	destinations []events.Storage
 
-	lock storages.Lock
+	lock    storages.Lock
+	trigger string
 }
 
-func (st *SyncTask) Sync() {
+//Sync return count of stored objects and an error if the sync has failed
+func (st *SyncTask) Sync() (int, error) {
 	start := time.Now()
 	strWriter := logging.NewStringWriter()
 	strLogger := logging.NewSyncLogger(strWriter)
@@ -45,7 +48,7 @@ func (st *SyncTask) Sync() {
 	if err != nil {
 		strLogger.Errorf("[%s] Error getting all available intervals: %v", st.identifier, err)
 		logging.Errorf("[%s] Error getting all available intervals: %v", st.identifier, err)
-		return
+		return 0, fmt.Errorf("Error getting all available intervals: %v", err)
 	}
 
 	strLogger.Infof("[%s] Total intervals: [%d]", st.identifier, len(intervals))
@@ -56,7 +59,7 @@ func (st *SyncTask) Sync() {
 		if err != nil {
 			strLogger.Errorf("[%s] Error getting interval [%s] signature: %v", st.identifier, interval.String(), err)
 			logging.Errorf("[%s] Error getting interval [%s] signature: %v", st.identifier, interval.String(), err)
-			return
+			return 0, fmt.Errorf("Error getting interval [%s] signature: %v", interval.String(), err)
 		}
 
 		nowSignature := interval.CalculateSignatureFrom(now)
@@ -79,6 +82,7 @@ func (st *SyncTask) Sync() {
 	logging.Infof("[%s] Intervals to sync: [%d]", st.identifier, len(intervalsToSync))
 	strLogger.Infof("[%s] Intervals to sync: [%d]", st.identifier, len(intervalsToSync))
 
+	var rows int
 	for _, intervalToSync := range intervalsToSync {
 		strLogger.Infof("[%s] Running [%s] synchronization", st.identifier, intervalToSync.String())
 
@@ -86,7 +90,7 @@ func (st *SyncTask) Sync() {
 		if err != nil {
 			strLogger.Errorf("[%s] Error [%s] synchronization: %v", st.identifier, intervalToSync.String(), err)
 			logging.Errorf("[%s] Error [%s] synchronization: %v", st.identifier, intervalToSync.String(), err)
-			return
+			return rows, fmt.Errorf("Error [%s] synchronization: %v", intervalToSync.String(), err)
 		}
 
 		for _, object := range objects {
@@ -98,19 +102,25 @@ func (st *SyncTask) Sync() {
 		}
 
 		for _, storage := range st.destinations {
-			rowsCount, err := storage.SyncStore(objects)
+			//test-mode fault injection
+			var rowsCount int
+			err := faults.Instance.Inject(storage.Name())
+			if err == nil {
+				rowsCount, err = storage.SyncStore(objects)
+			}
 			if err != nil {
 				strLogger.Errorf("[%s] Error storing %d source objects in [%s] destination: %v", st.identifier, rowsCount, storage.Name(), err)
 				logging.Errorf("[%s] Error storing %d source objects in [%s] destination: %v", st.identifier, rowsCount, storage.Name(), err)
 				metrics.ErrorSourceEvents(st.sourceId, storage.Name(), rowsCount)
 				metrics.ErrorObjects(st.sourceId, rowsCount)
-				return
+				return rows, fmt.Errorf("Error storing %d source objects in [%s] destination: %v", rowsCount, storage.Name(), err)
 			}
 
 			metrics.SuccessSourceEvents(st.sourceId, storage.Name(), rowsCount)
 			metrics.SuccessObjects(st.sourceId, rowsCount)
 		}
 
+		rows += len(objects)
 		if err := st.metaStorage.SaveSignature(st.sourceId, st.collection, intervalToSync.String(), intervalToSync.CalculateSignatureFrom(now)); err != nil {
 			logging.SystemErrorf("Unable to save source [%s] collection [%s] signature: %v", st.sourceId, st.collection, err)
 		}
@@ -122,6 +132,7 @@ func (st *SyncTask) Sync() {
 	strLogger.Infof("[%s] FINISHED SUCCESSFULLY in [%.2f] seconds (~ %.2f minutes)", st.identifier, end.Seconds(), end.Minutes())
 	logging.Infof("[%s] type: [%s] intervals: [%d] FINISHED SUCCESSFULLY in [%.2f] seconds (~ %.2f minutes)", st.identifier, st.driver.Type(), len(intervalsToSync), end.Seconds(), end.Minutes())
 	status = meta.StatusOk
+	return rows, nil
 }
 
 func (st *SyncTask) updateCollectionStatus(status, logs string) {
@@ -1,8 +1,41 @@
 package sources
 
-import "github.com/jitsucom/eventnative/drivers"
+import (
+	"github.com/jitsucom/eventnative/cron"
+	"github.com/jitsucom/eventnative/drivers"
+	"time"
+)
 
 type Unit struct {
 	DriverPerCollection map[string]drivers.Driver
-	DestinationIds      []string
+	//nil if source isn't a connector (see drivers.RegisterConnector). Connector collections are Collections
+	Connector      drivers.Connector
+	Collections    []string
+	DestinationIds []string
+
+	//nil if source isn't synchronized by schedule
+	Schedule *cron.Schedule
+	nextRun  time.Time
+}
+
+//collections return names of the source collections
+func (u *Unit) collections() []string {
+	if u.Connector != nil {
+		return u.Collections
+	}
+
+	collections := make([]string, 0, len(u.DriverPerCollection))
+	for collection := range u.DriverPerCollection {
+		collections = append(collections, collection)
+	}
+	return collections
+}
+
+func (u *Unit) hasCollection(collection string) bool {
+	for _, c := range u.collections() {
+		if c == collection {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,457 @@
+package storages
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/hashicorp/go-multierror"
+	"github.com/jitsucom/eventnative/caching"
+	"github.com/jitsucom/eventnative/counters"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/parsers"
+	"github.com/jitsucom/eventnative/schema"
+	"github.com/jitsucom/eventnative/timestamp"
+	"github.com/jitsucom/eventnative/typing"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	AggregatesPostgresStorage = "postgres"
+	AggregatesRedisStorage    = "redis"
+
+	AggregateBucketColumn    = "bucket"
+	AggregateCountColumn     = "count"
+	AggregateUpdatedAtColumn = "updated_at"
+	AggregateSumPrefix       = "sum_"
+	AggregateUniquesPrefix   = "uniques_"
+
+	aggregateEventTypeColumn = "event_type"
+)
+
+//AggregatesConfig is an aggregates destination configuration: metrics are rolled up at ingest time and increments
+//are applied to the storage (postgres tables or redis keys) for real-time dashboards without raw events queries
+type AggregatesConfig struct {
+	//postgres (default, datasource config) or redis
+	Storage string                 `mapstructure:"storage" json:"storage,omitempty" yaml:"storage,omitempty"`
+	Redis   *AggregatesRedisConfig `mapstructure:"redis" json:"redis,omitempty" yaml:"redis,omitempty"`
+	//redis keys TTL. 0 - keys don't expire
+	TtlDays int                `mapstructure:"ttl_days" json:"ttl_days,omitempty" yaml:"ttl_days,omitempty"`
+	Metrics []*AggregateMetric `mapstructure:"metrics" json:"metrics,omitempty" yaml:"metrics,omitempty"`
+}
+
+type AggregatesRedisConfig struct {
+	Host     string `mapstructure:"host" json:"host,omitempty" yaml:"host,omitempty"`
+	Port     int    `mapstructure:"port" json:"port,omitempty" yaml:"port,omitempty"`
+	Password string `mapstructure:"password" json:"password,omitempty" yaml:"password,omitempty"`
+}
+
+//AggregateMetric is a rolling aggregate of processed (flattened) events: count, sums and distinct values count
+//per dimension values per time bucket
+type AggregateMetric struct {
+	//postgres table name or redis keys prefix
+	Name string `mapstructure:"name" json:"name,omitempty" yaml:"name,omitempty"`
+	//event_type values of aggregated events. Empty - all events
+	EventTypes []string `mapstructure:"event_types" json:"event_types,omitempty" yaml:"event_types,omitempty"`
+	//hour (default), day, week or month buckets of time column
+	Granularity string `mapstructure:"granularity" json:"granularity,omitempty" yaml:"granularity,omitempty"`
+	//_timestamp if empty
+	TimeColumn string   `mapstructure:"time_column" json:"time_column,omitempty" yaml:"time_column,omitempty"`
+	Dimensions []string `mapstructure:"dimensions" json:"dimensions,omitempty" yaml:"dimensions,omitempty"`
+	Sums       []string `mapstructure:"sums" json:"sums,omitempty" yaml:"sums,omitempty"`
+	Uniques    []string `mapstructure:"uniques" json:"uniques,omitempty" yaml:"uniques,omitempty"`
+}
+
+func (am *AggregateMetric) String() string {
+	granularity := am.Granularity
+	if granularity == "" {
+		granularity = string(schema.HOUR)
+	}
+	return fmt.Sprintf("[%s] per %s by %v (event types: %v, sums: %v, uniques: %v)", am.Name, granularity, am.Dimensions,
+		am.EventTypes, am.Sums, am.Uniques)
+}
+
+type aggregateMetric struct {
+	name        string
+	eventTypes  map[string]bool
+	granularity schema.Granularity
+	timeColumn  string
+	dimensions  []string
+	sums        []string
+	uniques     []string
+}
+
+//aggregateDelta is an increment of one metric row: dimension values in the bucket
+type aggregateDelta struct {
+	metric     *aggregateMetric
+	bucket     time.Time
+	dimensions []string
+	//JSON array of dimension values
+	key     string
+	count   int64
+	sums    []float64
+	uniques []map[string]bool
+}
+
+//sortedUniques return distinct values of the unique column sorted
+func (ad *aggregateDelta) sortedUniques(i int) []string {
+	values := make([]string, 0, len(ad.uniques[i]))
+	for value := range ad.uniques[i] {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+	return values
+}
+
+//aggregatesBackend applies increments of one batch atomically
+type aggregatesBackend interface {
+	Apply(deltas []*aggregateDelta) error
+	Ping() error
+	Close() error
+}
+
+//newAggregateMetrics return parsed metrics with default values or error if they are misconfigured
+func newAggregateMetrics(config *AggregatesConfig) ([]*aggregateMetric, error) {
+	if config == nil || len(config.Metrics) == 0 {
+		return nil, fmt.Errorf("aggregates destination requires at least one aggregates.metrics item")
+	}
+	reserved := map[string]bool{AggregateBucketColumn: true, AggregateCountColumn: true, AggregateUpdatedAtColumn: true}
+
+	var metrics []*aggregateMetric
+	names := map[string]bool{}
+	for _, metricConfig := range config.Metrics {
+		if metricConfig.Name == "" {
+			return nil, fmt.Errorf("aggregates metric name is required")
+		}
+		if names[metricConfig.Name] {
+			return nil, fmt.Errorf("aggregates metric [%s] is declared twice", metricConfig.Name)
+		}
+		names[metricConfig.Name] = true
+
+		granularity := schema.HOUR
+		if metricConfig.Granularity != "" {
+			g, err := schema.GranularityFromString(metricConfig.Granularity)
+			if err != nil {
+				return nil, fmt.Errorf("aggregates metric [%s]: %v", metricConfig.Name, err)
+			}
+			granularity = g
+		}
+		timeColumn := metricConfig.TimeColumn
+		if timeColumn == "" {
+			timeColumn = timestamp.Key
+		}
+		for _, dimension := range metricConfig.Dimensions {
+			if reserved[dimension] || strings.HasPrefix(dimension, AggregateSumPrefix) || strings.HasPrefix(dimension, AggregateUniquesPrefix) {
+				return nil, fmt.Errorf("aggregates metric [%s] dimension [%s] conflicts with aggregate columns", metricConfig.Name, dimension)
+			}
+		}
+
+		eventTypes := map[string]bool{}
+		for _, eventType := range metricConfig.EventTypes {
+			eventTypes[eventType] = true
+		}
+		metrics = append(metrics, &aggregateMetric{
+			name:        metricConfig.Name,
+			eventTypes:  eventTypes,
+			granularity: granularity,
+			timeColumn:  timeColumn,
+			dimensions:  metricConfig.Dimensions,
+			sums:        metricConfig.Sums,
+			uniques:     metricConfig.Uniques,
+		})
+	}
+
+	return metrics, nil
+}
+
+//validateAggregates return err if aggregates are configured in not aggregates destination or are misconfigured
+func validateAggregates(destination DestinationConfig) error {
+	if destination.Type != AggregatesType {
+		if destination.Aggregates != nil {
+			return fmt.Errorf("aggregates are supported only by %s destination", AggregatesType)
+		}
+		return nil
+	}
+
+	switch destination.Aggregates.storage() {
+	case AggregatesPostgresStorage:
+	case AggregatesRedisStorage:
+		if destination.Aggregates.Redis == nil || destination.Aggregates.Redis.Host == "" {
+			return fmt.Errorf("aggregates.redis.host is required with %s storage", AggregatesRedisStorage)
+		}
+	default:
+		return fmt.Errorf("Unknown aggregates storage: %s. Available: [%s, %s]", destination.Aggregates.Storage, AggregatesPostgresStorage, AggregatesRedisStorage)
+	}
+
+	_, err := newAggregateMetrics(destination.Aggregates)
+	return err
+}
+
+func (ac *AggregatesConfig) storage() string {
+	if ac == nil || ac.Storage == "" {
+		return AggregatesPostgresStorage
+	}
+	return ac.Storage
+}
+
+//rollupAggregates return increments of all metrics of the objects sorted by metric, bucket and dimension values
+//(stable rows locking order). Objects without time column are skipped
+func rollupAggregates(metrics []*aggregateMetric, objects []map[string]interface{}) []*aggregateDelta {
+	type deltaKey struct {
+		metric string
+		bucket int64
+		key    string
+	}
+	deltas := map[deltaKey]*aggregateDelta{}
+
+	for _, object := range objects {
+		eventType, _ := object[aggregateEventTypeColumn].(string)
+		for _, metric := range metrics {
+			if len(metric.eventTypes) > 0 && !metric.eventTypes[eventType] {
+				continue
+			}
+			eventTime, ok := timeValue(object[metric.timeColumn])
+			if !ok {
+				continue
+			}
+			bucket := bucketStart(metric.granularity, eventTime.UTC())
+
+			dimensions := make([]string, len(metric.dimensions))
+			for i, dimension := range metric.dimensions {
+				dimensions[i] = dimensionString(object[dimension])
+			}
+			b, _ := json.Marshal(dimensions)
+			dk := deltaKey{metric: metric.name, bucket: bucket.Unix(), key: string(b)}
+
+			delta, ok := deltas[dk]
+			if !ok {
+				delta = &aggregateDelta{
+					metric:     metric,
+					bucket:     bucket,
+					dimensions: dimensions,
+					key:        dk.key,
+					sums:       make([]float64, len(metric.sums)),
+					uniques:    make([]map[string]bool, len(metric.uniques)),
+				}
+				for i := range delta.uniques {
+					delta.uniques[i] = map[string]bool{}
+				}
+				deltas[dk] = delta
+			}
+
+			delta.count++
+			for i, sum := range metric.sums {
+				if number, ok := numericValue(object[sum]); ok {
+					delta.sums[i] += number
+				}
+			}
+			for i, unique := range metric.uniques {
+				if value := dimensionString(object[unique]); value != "" {
+					delta.uniques[i][value] = true
+				}
+			}
+		}
+	}
+
+	result := make([]*aggregateDelta, 0, len(deltas))
+	for _, delta := range deltas {
+		result = append(result, delta)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].metric.name != result[j].metric.name {
+			return result[i].metric.name < result[j].metric.name
+		}
+		if !result[i].bucket.Equal(result[j].bucket) {
+			return result[i].bucket.Before(result[j].bucket)
+		}
+		return result[i].key < result[j].key
+	})
+	return result
+}
+
+//bucketStart return the bucket start of t: hour, day, ISO week (monday) or month
+func bucketStart(granularity schema.Granularity, t time.Time) time.Time {
+	switch granularity {
+	case schema.HOUR:
+		return t.Truncate(time.Hour)
+	case schema.DAY:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	case schema.WEEK:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	default:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	}
+}
+
+//dimensionString return dimension value as a string: missing and null values are empty strings
+func dimensionString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case time.Time:
+		return timestamp.ToISOFormat(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func numericValue(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+//Aggregates is a destination which keeps only rolling aggregates of processed events in postgres or redis
+type Aggregates struct {
+	name            string
+	schemaProcessor *schema.Processor
+	metrics         []*aggregateMetric
+	backend         aggregatesBackend
+	streamingWorker *StreamingWorker
+	fallbackLogger  *events.AsyncLogger
+	eventsCache     *caching.EventsCache
+	breakOnError    bool
+}
+
+func NewAggregates(name string, eventQueue *events.PersistentQueue, processor *schema.Processor, metrics []*aggregateMetric,
+	backend aggregatesBackend, breakOnError, streamMode bool, fallbackLoggerFactoryMethod func() *events.AsyncLogger,
+	eventsCache *caching.EventsCache) *Aggregates {
+	a := &Aggregates{
+		name:            name,
+		schemaProcessor: processor,
+		metrics:         metrics,
+		backend:         backend,
+		fallbackLogger:  fallbackLoggerFactoryMethod(),
+		eventsCache:     eventsCache,
+		breakOnError:    breakOnError,
+	}
+
+	if streamMode {
+		a.streamingWorker = newStreamingWorker(eventQueue, processor, a, eventsCache)
+		a.streamingWorker.start()
+	}
+
+	return a
+}
+
+//Store call StoreWithParseFunc with parsers.ParseJson func
+func (a *Aggregates) Store(fileName string, payload []byte) (int, error) {
+	return a.StoreWithParseFunc(fileName, payload, parsers.ParseJson)
+}
+
+//StoreWithParseFunc process file payload and apply its aggregates increments in one transaction (pipeline)
+func (a *Aggregates) StoreWithParseFunc(fileName string, payload []byte, parseFunc func([]byte) (map[string]interface{}, error)) (int, error) {
+	flatData, failedEvents, err := a.schemaProcessor.ProcessFilePayload(fileName, payload, a.breakOnError, parseFunc)
+	if err != nil {
+		return linesCount(payload), err
+	}
+
+	rowsCount, err := a.store(flatData)
+
+	//send failed events to fallback only if increments have been applied ok
+	if err == nil {
+		a.Fallback(failedEvents...)
+		counters.ErrorEvents(a.Name(), len(failedEvents))
+		for _, failedFact := range failedEvents {
+			a.eventsCache.Error(a.Name(), failedFact.EventId, failedFact.Error)
+		}
+	}
+
+	return rowsCount, err
+}
+
+//SyncStore process objects and apply their aggregates increments
+func (a *Aggregates) SyncStore(objects []map[string]interface{}) (int, error) {
+	flatData, err := a.schemaProcessor.ProcessObjects(objects)
+	if err != nil {
+		return len(objects), err
+	}
+
+	return a.store(flatData)
+}
+
+//Insert apply aggregates increments of one event
+func (a *Aggregates) Insert(dataSchema *schema.Table, fact events.Fact) error {
+	return a.backend.Apply(rollupAggregates(a.metrics, []map[string]interface{}{fact}))
+}
+
+//Fallback log event with error to fallback logger
+func (a *Aggregates) Fallback(failedFacts ...*events.FailedFact) {
+	for _, failedFact := range failedFacts {
+		a.fallbackLogger.ConsumeAny(failedFact)
+	}
+}
+
+func (a *Aggregates) store(flatData map[string]*schema.ProcessedFile) (int, error) {
+	tables := make([]string, 0, len(flatData))
+	for table := range flatData {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	var objects []map[string]interface{}
+	for _, table := range tables {
+		objects = append(objects, flatData[table].GetPayload()...)
+	}
+
+	if err := a.backend.Apply(rollupAggregates(a.metrics, objects)); err != nil {
+		return len(objects), err
+	}
+
+	for _, table := range tables {
+		fdata := flatData[table]
+		for _, object := range fdata.GetPayload() {
+			a.eventsCache.Succeed(a.Name(), events.ExtractEventId(object), object, fdata.DataSchema, a.ColumnTypesMapping())
+		}
+	}
+	return len(objects), nil
+}
+
+//ColumnTypesMapping return generic types names: events aren't stored row-level
+func (a *Aggregates) ColumnTypesMapping() map[typing.DataType]string {
+	return schemaToMemory
+}
+
+//Ping check the aggregates storage connection
+func (a *Aggregates) Ping() error {
+	return a.backend.Ping()
+}
+
+func (a *Aggregates) Name() string {
+	return a.name
+}
+
+func (a *Aggregates) Type() string {
+	return AggregatesType
+}
+
+func (a *Aggregates) Close() (multiErr error) {
+	if a.streamingWorker != nil {
+		a.streamingWorker.Close()
+	}
+
+	if err := a.backend.Close(); err != nil {
+		multiErr = multierror.Append(multiErr, fmt.Errorf("[%s] Error closing aggregates storage: %v", a.Name(), err))
+	}
+
+	if err := a.fallbackLogger.Close(); err != nil {
+		multiErr = multierror.Append(multiErr, fmt.Errorf("[%s] Error closing fallback logger: %v", a.Name(), err))
+	}
+
+	return
+}
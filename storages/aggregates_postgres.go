@@ -0,0 +1,171 @@
+package storages
+
+import (
+	"context"
+	"fmt"
+	"github.com/jitsucom/eventnative/adapters"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/schema"
+	"github.com/jitsucom/eventnative/typing"
+	"time"
+)
+
+const (
+	aggregateUniquesSuffix    = "_uniques"
+	aggregateDimensionsColumn = "dimensions"
+	aggregateFieldColumn      = "field"
+	aggregateValueColumn      = "value"
+	aggregatesInsertChunkRows = 1000
+)
+
+var aggregateUniquesColumns = []string{AggregateBucketColumn, aggregateDimensionsColumn, aggregateFieldColumn, aggregateValueColumn}
+
+//aggregateTables are the metric table (bucket, dimensions columns, count, sum_<column>, uniques_<column>, updated_at)
+//and distinct values table <metric>_uniques (bucket, dimensions JSON array, field, value). Uniques are exact:
+//a row increment contains count of values which haven't been inserted into the uniques table yet
+type aggregateTables struct {
+	metric  *schema.Table
+	uniques *schema.Table
+}
+
+//aggregatesPostgres applies increments of a batch in one transaction. Rows are upserted in the stable order:
+//concurrent batches of different instances don't deadlock
+type aggregatesPostgres struct {
+	adapter  *adapters.Postgres
+	tables   map[string]*aggregateTables
+	counters map[string]bool
+}
+
+//newAggregatesPostgres create the db schema and metrics tables if they don't exist and add new sums and uniques columns
+func newAggregatesPostgres(ctx context.Context, name string, config *adapters.DataSourceConfig, metrics []*aggregateMetric,
+	monitorKeeper MonitorKeeper, queryLogger *logging.QueryLogger) (*aggregatesPostgres, error) {
+	adapter, err := adapters.NewPostgres(ctx, config, queryLogger)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := adapter.CreateDbSchema(config.Schema); err != nil {
+		adapter.Close()
+		return nil, err
+	}
+
+	tableHelper := NewTableHelper(adapter, monitorKeeper, NewColumnTypesRegistry(), PostgresType, nil, AutoMigrations)
+	ap := &aggregatesPostgres{adapter: adapter, tables: map[string]*aggregateTables{}, counters: map[string]bool{AggregateCountColumn: true}}
+	for _, metric := range metrics {
+		tables := ap.metricTables(metric)
+		if _, err := tableHelper.EnsureTable(name, tables.metric); err != nil {
+			adapter.Close()
+			return nil, fmt.Errorf("Error creating aggregates metric [%s] table: %v", metric.name, err)
+		}
+		if tables.uniques != nil {
+			if _, err := tableHelper.EnsureTable(name, tables.uniques); err != nil {
+				adapter.Close()
+				return nil, fmt.Errorf("Error creating aggregates metric [%s] uniques table: %v", metric.name, err)
+			}
+		}
+		ap.tables[metric.name] = tables
+	}
+
+	return ap, nil
+}
+
+//metricTables return tables schemas of the metric and register its counters columns
+func (ap *aggregatesPostgres) metricTables(metric *aggregateMetric) *aggregateTables {
+	columns := schema.Columns{
+		AggregateBucketColumn:    schema.NewColumn(typing.TIMESTAMP),
+		AggregateCountColumn:     schema.NewColumn(typing.INT64),
+		AggregateUpdatedAtColumn: schema.NewColumn(typing.TIMESTAMP),
+	}
+	pkFields := map[string]bool{AggregateBucketColumn: true}
+	for _, dimension := range metric.dimensions {
+		columns[dimension] = schema.NewColumn(typing.STRING)
+		pkFields[dimension] = true
+	}
+	for _, sum := range metric.sums {
+		columns[AggregateSumPrefix+sum] = schema.NewColumn(typing.FLOAT64)
+		ap.counters[AggregateSumPrefix+sum] = true
+	}
+	for _, unique := range metric.uniques {
+		columns[AggregateUniquesPrefix+unique] = schema.NewColumn(typing.INT64)
+		ap.counters[AggregateUniquesPrefix+unique] = true
+	}
+	tables := &aggregateTables{metric: &schema.Table{Name: metric.name, Columns: columns, PKFields: pkFields}}
+
+	if len(metric.uniques) > 0 {
+		uniquesColumns := schema.Columns{AggregateBucketColumn: schema.NewColumn(typing.TIMESTAMP)}
+		uniquesPkFields := map[string]bool{}
+		for _, column := range aggregateUniquesColumns {
+			if column != AggregateBucketColumn {
+				uniquesColumns[column] = schema.NewColumn(typing.STRING)
+			}
+			uniquesPkFields[column] = true
+		}
+		tables.uniques = &schema.Table{Name: metric.name + aggregateUniquesSuffix, Columns: uniquesColumns, PKFields: uniquesPkFields}
+	}
+	return tables
+}
+
+func (ap *aggregatesPostgres) Apply(deltas []*aggregateDelta) error {
+	if len(deltas) == 0 {
+		return nil
+	}
+
+	wrappedTx, err := ap.adapter.OpenTx()
+	if err != nil {
+		return err
+	}
+
+	updatedAt := time.Now().UTC()
+	for _, delta := range deltas {
+		if err := ap.applyInTransaction(wrappedTx, delta, updatedAt); err != nil {
+			wrappedTx.Rollback()
+			return err
+		}
+	}
+
+	return wrappedTx.DirectCommit()
+}
+
+func (ap *aggregatesPostgres) applyInTransaction(wrappedTx *adapters.Transaction, delta *aggregateDelta, updatedAt time.Time) error {
+	tables := ap.tables[delta.metric.name]
+	row := map[string]interface{}{
+		AggregateBucketColumn:    delta.bucket,
+		AggregateCountColumn:     delta.count,
+		AggregateUpdatedAtColumn: updatedAt,
+	}
+	for i, dimension := range delta.metric.dimensions {
+		row[dimension] = delta.dimensions[i]
+	}
+	for i, sum := range delta.metric.sums {
+		row[AggregateSumPrefix+sum] = delta.sums[i]
+	}
+
+	for i, unique := range delta.metric.uniques {
+		var inserted int64
+		var rows [][]interface{}
+		values := delta.sortedUniques(i)
+		for j, value := range values {
+			rows = append(rows, []interface{}{delta.bucket, delta.key, unique, value})
+			if len(rows) < aggregatesInsertChunkRows && j < len(values)-1 {
+				continue
+			}
+			count, err := ap.adapter.InsertIgnoreInTransaction(wrappedTx, tables.uniques, aggregateUniquesColumns, rows)
+			if err != nil {
+				return err
+			}
+			inserted += count
+			rows = nil
+		}
+		row[AggregateUniquesPrefix+unique] = inserted
+	}
+
+	return ap.adapter.IncrementInTransaction(wrappedTx, tables.metric, row, ap.counters)
+}
+
+func (ap *aggregatesPostgres) Ping() error {
+	return ap.adapter.Ping()
+}
+
+func (ap *aggregatesPostgres) Close() error {
+	return ap.adapter.Close()
+}
@@ -0,0 +1,123 @@
+package storages
+
+import (
+	"fmt"
+	"github.com/gomodule/redigo/redis"
+	"github.com/jitsucom/eventnative/logging"
+	"strconv"
+	"time"
+)
+
+//aggregatesRedis applies increments of a batch in one MULTI/EXEC transaction. Uniques are HyperLogLog estimations
+//redis key [variables] - description
+//aggregates:destination#name:metric#metric:buckets [name, metric] - sorted set of buckets unix times (scores as well)
+//aggregates:destination#name:metric#metric:bucket#bucket:dimensions [name, metric, bucket] - set of dimension values JSON arrays
+//aggregates:destination#name:metric#metric:bucket#bucket:dimensions#key [name, metric, bucket, key] - hash with count and sum_<column> fields
+//aggregates:destination#name:metric#metric:bucket#bucket:dimensions#key:uniques#column [name, metric, bucket, key, column] - HyperLogLog
+type aggregatesRedis struct {
+	name string
+	pool *redis.Pool
+	ttl  time.Duration
+}
+
+func newAggregatesRedis(name string, config *AggregatesRedisConfig, ttl time.Duration) (*aggregatesRedis, error) {
+	port := config.Port
+	if port == 0 {
+		port = 6379
+	}
+	logging.Infof("[%s] Initializing aggregates redis [%s:%d]...", name, config.Host, port)
+	ar := &aggregatesRedis{name: name, ttl: ttl, pool: &redis.Pool{
+		MaxIdle:     100,
+		MaxActive:   600,
+		IdleTimeout: 240 * time.Second,
+
+		Wait: false,
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial(
+				"tcp",
+				config.Host+":"+strconv.Itoa(port),
+				redis.DialConnectTimeout(10*time.Second),
+				redis.DialReadTimeout(10*time.Second),
+				redis.DialPassword(config.Password),
+			)
+		},
+		TestOnBorrow: func(c redis.Conn, t time.Time) error {
+			_, err := c.Do("PING")
+			return err
+		},
+	}}
+
+	if err := ar.Ping(); err != nil {
+		ar.pool.Close()
+		return nil, fmt.Errorf("Error testing connection to aggregates Redis: %v", err)
+	}
+
+	return ar, nil
+}
+
+func (ar *aggregatesRedis) Apply(deltas []*aggregateDelta) error {
+	if len(deltas) == 0 {
+		return nil
+	}
+
+	connection := ar.pool.Get()
+	defer connection.Close()
+
+	connection.Send("MULTI")
+	metricKeys := map[string]bool{}
+	for _, delta := range deltas {
+		metricKey := ar.metricKey(delta.metric.name)
+		bucket := delta.bucket.Unix()
+		metricKeys[metricKey] = true
+		bucketKey := fmt.Sprintf("%s:bucket#%d", metricKey, bucket)
+		dimensionsKey := bucketKey + ":dimensions#" + delta.key
+
+		connection.Send("ZADD", metricKey+":buckets", bucket, bucket)
+		connection.Send("SADD", bucketKey+":dimensions", delta.key)
+		connection.Send("HINCRBY", dimensionsKey, AggregateCountColumn, delta.count)
+		keys := []string{bucketKey + ":dimensions", dimensionsKey}
+		for i, sum := range delta.metric.sums {
+			connection.Send("HINCRBYFLOAT", dimensionsKey, AggregateSumPrefix+sum, delta.sums[i])
+		}
+		for i, unique := range delta.metric.uniques {
+			values := delta.sortedUniques(i)
+			if len(values) == 0 {
+				continue
+			}
+			uniquesKey := dimensionsKey + ":uniques#" + unique
+			connection.Send("PFADD", redis.Args{uniquesKey}.AddFlat(values)...)
+			keys = append(keys, uniquesKey)
+		}
+
+		if ar.ttl > 0 {
+			for _, key := range keys {
+				connection.Send("EXPIRE", key, int64(ar.ttl.Seconds()))
+			}
+		}
+	}
+	//buckets of expired keys are removed from the index
+	if ar.ttl > 0 {
+		for metricKey := range metricKeys {
+			connection.Send("ZREMRANGEBYSCORE", metricKey+":buckets", "-inf", time.Now().Add(-ar.ttl).Unix())
+		}
+	}
+
+	_, err := connection.Do("EXEC")
+	return err
+}
+
+func (ar *aggregatesRedis) metricKey(metric string) string {
+	return "aggregates:destination#" + ar.name + ":metric#" + metric
+}
+
+func (ar *aggregatesRedis) Ping() error {
+	connection := ar.pool.Get()
+	defer connection.Close()
+
+	_, err := redis.String(connection.Do("PING"))
+	return err
+}
+
+func (ar *aggregatesRedis) Close() error {
+	return ar.pool.Close()
+}
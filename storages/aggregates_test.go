@@ -0,0 +1,134 @@
+package storages
+
+import (
+	"encoding/json"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/schema"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+type recordingAggregatesBackend struct {
+	batches [][]*aggregateDelta
+}
+
+func (rab *recordingAggregatesBackend) Apply(deltas []*aggregateDelta) error {
+	rab.batches = append(rab.batches, deltas)
+	return nil
+}
+
+func (rab *recordingAggregatesBackend) Ping() error {
+	return nil
+}
+
+func (rab *recordingAggregatesBackend) Close() error {
+	return nil
+}
+
+func TestRollupAggregates(t *testing.T) {
+	metrics, err := newAggregateMetrics(&AggregatesConfig{Metrics: []*AggregateMetric{
+		{Name: "purchases", EventTypes: []string{"purchase"}, Granularity: "day", Dimensions: []string{"utm_source"},
+			Sums: []string{"revenue"}, Uniques: []string{"user_id"}},
+		{Name: "events_hourly"},
+	}})
+	require.NoError(t, err)
+
+	t1 := time.Date(2020, 10, 1, 10, 15, 0, 0, time.UTC)
+	t2 := time.Date(2020, 10, 1, 11, 30, 0, 0, time.UTC)
+	objects := []map[string]interface{}{
+		{"event_type": "purchase", "_timestamp": t1, "utm_source": "google", "revenue": 10.5, "user_id": "u1"},
+		{"event_type": "purchase", "_timestamp": t2, "utm_source": "google", "revenue": json.Number("2"), "user_id": "u1"},
+		{"event_type": "purchase", "_timestamp": "2020-10-01T12:00:00.000000Z", "revenue": int64(3), "user_id": "u2"},
+		{"event_type": "pageview", "_timestamp": t2, "utm_source": "google", "user_id": "u3"},
+		//without time column
+		{"event_type": "purchase", "utm_source": "google", "revenue": 100.0},
+	}
+
+	deltas := rollupAggregates(metrics, objects)
+	require.Len(t, deltas, 5)
+
+	require.Equal(t, "events_hourly", deltas[0].metric.name)
+	require.Equal(t, time.Date(2020, 10, 1, 10, 0, 0, 0, time.UTC), deltas[0].bucket)
+	require.Equal(t, int64(1), deltas[0].count)
+	require.Equal(t, "[]", deltas[0].key)
+	require.Equal(t, time.Date(2020, 10, 1, 11, 0, 0, 0, time.UTC), deltas[1].bucket)
+	require.Equal(t, int64(2), deltas[1].count)
+	require.Equal(t, time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC), deltas[2].bucket)
+
+	//missing dimension is an empty string
+	require.Equal(t, "purchases", deltas[3].metric.name)
+	require.Equal(t, []string{""}, deltas[3].dimensions)
+	require.Equal(t, []float64{3}, deltas[3].sums)
+	require.Equal(t, []string{"u2"}, deltas[3].sortedUniques(0))
+
+	require.Equal(t, time.Date(2020, 10, 1, 0, 0, 0, 0, time.UTC), deltas[4].bucket)
+	require.Equal(t, `["google"]`, deltas[4].key)
+	require.Equal(t, int64(2), deltas[4].count)
+	require.Equal(t, []float64{12.5}, deltas[4].sums)
+	require.Equal(t, []string{"u1"}, deltas[4].sortedUniques(0))
+}
+
+func TestBucketStart(t *testing.T) {
+	//thursday
+	ts := time.Date(2020, 10, 1, 10, 15, 30, 0, time.UTC)
+	require.Equal(t, time.Date(2020, 10, 1, 10, 0, 0, 0, time.UTC), bucketStart(schema.HOUR, ts))
+	require.Equal(t, time.Date(2020, 10, 1, 0, 0, 0, 0, time.UTC), bucketStart(schema.DAY, ts))
+	require.Equal(t, time.Date(2020, 9, 28, 0, 0, 0, 0, time.UTC), bucketStart(schema.WEEK, ts))
+	require.Equal(t, time.Date(2020, 10, 1, 0, 0, 0, 0, time.UTC), bucketStart(schema.MONTH, ts))
+	//sunday belongs to the week started on monday
+	require.Equal(t, time.Date(2020, 9, 28, 0, 0, 0, 0, time.UTC), bucketStart(schema.WEEK, time.Date(2020, 10, 4, 23, 0, 0, 0, time.UTC)))
+}
+
+func TestValidateAggregates(t *testing.T) {
+	metrics := []*AggregateMetric{{Name: "pageviews"}}
+	tests := []struct {
+		name        string
+		destination DestinationConfig
+		expectedErr string
+	}{
+		{"not configured", DestinationConfig{Type: PostgresType}, ""},
+		{"postgres", DestinationConfig{Type: AggregatesType, Aggregates: &AggregatesConfig{Metrics: metrics}}, ""},
+		{"redis", DestinationConfig{Type: AggregatesType, Aggregates: &AggregatesConfig{Storage: "redis", Redis: &AggregatesRedisConfig{Host: "localhost"}, Metrics: metrics}}, ""},
+		{"not aggregates destination", DestinationConfig{Type: PostgresType, Aggregates: &AggregatesConfig{Metrics: metrics}},
+			"aggregates are supported only by aggregates destination"},
+		{"without metrics", DestinationConfig{Type: AggregatesType}, "aggregates destination requires at least one aggregates.metrics item"},
+		{"redis without host", DestinationConfig{Type: AggregatesType, Aggregates: &AggregatesConfig{Storage: "redis", Metrics: metrics}},
+			"aggregates.redis.host is required with redis storage"},
+		{"unknown storage", DestinationConfig{Type: AggregatesType, Aggregates: &AggregatesConfig{Storage: "mysql", Metrics: metrics}},
+			"Unknown aggregates storage: mysql. Available: [postgres, redis]"},
+		{"duplicate metric", DestinationConfig{Type: AggregatesType, Aggregates: &AggregatesConfig{Metrics: []*AggregateMetric{{Name: "a"}, {Name: "a"}}}},
+			"aggregates metric [a] is declared twice"},
+		{"reserved dimension", DestinationConfig{Type: AggregatesType, Aggregates: &AggregatesConfig{Metrics: []*AggregateMetric{{Name: "a", Dimensions: []string{"count"}}}}},
+			"aggregates metric [a] dimension [count] conflicts with aggregate columns"},
+		{"wrong granularity", DestinationConfig{Type: AggregatesType, Aggregates: &AggregatesConfig{Metrics: []*AggregateMetric{{Name: "a", Granularity: "minute"}}}},
+			"aggregates metric [a]: Unknown partition granularity [minute]. Available: hour, day, week, month"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateAggregates(tt.destination)
+			if tt.expectedErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tt.expectedErr)
+			}
+		})
+	}
+}
+
+func TestAggregatesInsert(t *testing.T) {
+	metrics, err := newAggregateMetrics(&AggregatesConfig{Metrics: []*AggregateMetric{{Name: "pageviews", EventTypes: []string{"pageview"}}}})
+	require.NoError(t, err)
+	backend := &recordingAggregatesBackend{}
+	aggregates := NewAggregates("test", nil, nil, metrics, backend, false, false, func() *events.AsyncLogger {
+		return events.NewAsyncLogger(discardWriter{}, false)
+	}, nil)
+	defer aggregates.Close()
+
+	require.NoError(t, aggregates.Insert(&schema.Table{Name: "events"}, events.Fact{"event_type": "pageview", "_timestamp": "2020-10-01T10:15:00.000000Z"}))
+	require.NoError(t, aggregates.Insert(&schema.Table{Name: "events"}, events.Fact{"event_type": "signup", "_timestamp": "2020-10-01T10:15:00.000000Z"}))
+	require.Len(t, backend.batches, 2)
+	require.Len(t, backend.batches[0], 1)
+	require.Equal(t, int64(1), backend.batches[0][0].count)
+	require.Empty(t, backend.batches[1])
+}
@@ -0,0 +1,133 @@
+package storages
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/schema"
+	"github.com/jitsucom/eventnative/typing"
+	"time"
+)
+
+const (
+	//AnnotationsTable is an audit table of applied annotations. It is created in every annotated destination
+	AnnotationsTable = "_annotations"
+
+	annotationEventIdColumn = "eventn_ctx_event_id"
+)
+
+//Annotation is a post-hoc correction of the stored event fields (e.g. fixed revenue amount) keyed by event id
+type Annotation struct {
+	Id      string                 `json:"id"`
+	Table   string                 `json:"table"`
+	EventId string                 `json:"event_id"`
+	Fields  map[string]interface{} `json:"fields"`
+	Author  string                 `json:"author,omitempty"`
+	Comment string                 `json:"comment,omitempty"`
+}
+
+//Annotator is a destination which supports post-hoc corrections of stored events. Corrections are applied as updates
+//of the event rows and are recorded in AnnotationsTable
+type Annotator interface {
+	//Annotate update the annotation fields of the table rows with the event id. return updated rows count
+	Annotate(annotation *Annotation) (int64, error)
+}
+
+//annotatingAdapter is a sql adapter which supports updates of rows by key column
+type annotatingAdapter interface {
+	GetTableSchema(tableName string) (*schema.Table, error)
+	Annotate(table *schema.Table, keyColumn, key string, fields map[string]interface{}, audit *schema.Table,
+		auditRow func(previous []map[string]interface{}, updated int64) map[string]interface{}) (int64, error)
+}
+
+//annotationsTable return a schema of AnnotationsTable
+func annotationsTable() *schema.Table {
+	return &schema.Table{Name: AnnotationsTable, PKFields: map[string]bool{}, Columns: schema.Columns{
+		"id":           schema.NewColumn(typing.STRING),
+		"table_name":   schema.NewColumn(typing.STRING),
+		"event_id":     schema.NewColumn(typing.STRING),
+		"fields":       schema.NewColumn(typing.STRING),
+		"previous":     schema.NewColumn(typing.STRING),
+		"updated_rows": schema.NewColumn(typing.INT64),
+		"author":       schema.NewColumn(typing.STRING),
+		"comment":      schema.NewColumn(typing.STRING),
+		"_timestamp":   schema.NewColumn(typing.TIMESTAMP),
+	}}
+}
+
+//annotate validate the annotation fields against the table schema, convert values into the column types and apply the
+//annotation with the audit row in one transaction
+func annotate(destinationName string, adapter annotatingAdapter, tableHelper *TableHelper, annotation *Annotation) (int64, error) {
+	if annotation.Table == "" || annotation.EventId == "" {
+		return 0, errors.New("Annotation table and event_id are required")
+	}
+	if len(annotation.Fields) == 0 {
+		return 0, errors.New("Annotation fields are required")
+	}
+
+	table, err := adapter.GetTableSchema(annotation.Table)
+	if err != nil {
+		return 0, err
+	}
+	if !table.Exists() {
+		return 0, fmt.Errorf("Table [%s] doesn't exist", annotation.Table)
+	}
+	if _, ok := table.Columns[annotationEventIdColumn]; !ok {
+		return 0, fmt.Errorf("Table [%s] doesn't have %s column", annotation.Table, annotationEventIdColumn)
+	}
+
+	fields := map[string]interface{}{}
+	for name, value := range annotation.Fields {
+		if name == annotationEventIdColumn {
+			return 0, fmt.Errorf("Column %s can't be annotated", annotationEventIdColumn)
+		}
+		column, ok := table.Columns[name]
+		if !ok {
+			return 0, fmt.Errorf("Column [%s] doesn't exist in [%s] table", name, annotation.Table)
+		}
+		if value == nil {
+			fields[name] = nil
+			continue
+		}
+		converted, err := typing.Convert(column.GetType(), typing.ReformatValue(value))
+		if err != nil {
+			return 0, fmt.Errorf("Error converting [%s] column value [%v] to %s: %v", name, value, column.GetType(), err)
+		}
+		fields[name] = converted
+	}
+
+	fieldsJson, err := json.Marshal(annotation.Fields)
+	if err != nil {
+		return 0, fmt.Errorf("Error marshalling annotation fields: %v", err)
+	}
+
+	audit, err := tableHelper.EnsureTable(destinationName, annotationsTable())
+	if err != nil {
+		return 0, fmt.Errorf("Error ensuring %s table: %v", AnnotationsTable, err)
+	}
+
+	updated, err := adapter.Annotate(table, annotationEventIdColumn, annotation.EventId, fields, audit,
+		func(previous []map[string]interface{}, updated int64) map[string]interface{} {
+			previousJson, err := json.Marshal(previous)
+			if err != nil {
+				destinationsLogger.SystemErrorf("Error marshalling [%s] annotation previous values: %v", annotation.Id, err)
+			}
+			return map[string]interface{}{
+				"id":           annotation.Id,
+				"table_name":   annotation.Table,
+				"event_id":     annotation.EventId,
+				"fields":       string(fieldsJson),
+				"previous":     string(previousJson),
+				"updated_rows": updated,
+				"author":       annotation.Author,
+				"comment":      annotation.Comment,
+				"_timestamp":   time.Now().UTC(),
+			}
+		})
+	if err != nil {
+		return 0, err
+	}
+
+	destinationsLogger.WithDestination(destinationName).Infof("Annotation [%s]: %d rows of [%s] table with event id [%s] were updated", annotation.Id, updated, annotation.Table, annotation.EventId)
+	return updated, nil
+}
@@ -0,0 +1,68 @@
+package storages
+
+import (
+	"encoding/json"
+	"github.com/jitsucom/eventnative/schema"
+	"github.com/jitsucom/eventnative/typing"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type annotatingAdapterMock struct {
+	table    *schema.Table
+	fields   map[string]interface{}
+	auditRow map[string]interface{}
+}
+
+func (aam *annotatingAdapterMock) GetTableSchema(tableName string) (*schema.Table, error) {
+	if tableName != aam.table.Name {
+		return &schema.Table{Name: tableName, Columns: schema.Columns{}}, nil
+	}
+	return aam.table, nil
+}
+
+func (aam *annotatingAdapterMock) Annotate(table *schema.Table, keyColumn, key string, fields map[string]interface{}, audit *schema.Table,
+	auditRow func(previous []map[string]interface{}, updated int64) map[string]interface{}) (int64, error) {
+	aam.fields = fields
+	aam.auditRow = auditRow([]map[string]interface{}{{"revenue": 10.0}}, 1)
+	return 1, nil
+}
+
+func TestAnnotate(t *testing.T) {
+	adapter := &annotatingAdapterMock{table: &schema.Table{Name: "events", Columns: schema.Columns{
+		"eventn_ctx_event_id": schema.NewColumn(typing.STRING),
+		"revenue":             schema.NewColumn(typing.FLOAT64),
+		"status":              schema.NewColumn(typing.STRING),
+	}}}
+	tableHelper := &TableHelper{tables: map[string]*schema.Table{AnnotationsTable: annotationsTable()}, columnTypes: NewColumnTypesRegistry()}
+
+	updated, err := annotate("test", adapter, tableHelper, &Annotation{Id: "a1", Table: "events", EventId: "e1",
+		Fields: map[string]interface{}{"revenue": json.Number("12"), "status": nil}, Author: "ops"})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), updated)
+	require.Equal(t, map[string]interface{}{"revenue": float64(12), "status": nil}, adapter.fields)
+	require.Equal(t, `{"revenue":12,"status":null}`, adapter.auditRow["fields"])
+	require.Equal(t, `[{"revenue":10}]`, adapter.auditRow["previous"])
+	require.Equal(t, "e1", adapter.auditRow["event_id"])
+	require.Equal(t, "ops", adapter.auditRow["author"])
+
+	tests := []struct {
+		name        string
+		annotation  *Annotation
+		expectedErr string
+	}{
+		{"without event id", &Annotation{Table: "events", Fields: map[string]interface{}{"status": "ok"}}, "Annotation table and event_id are required"},
+		{"without fields", &Annotation{Table: "events", EventId: "e1"}, "Annotation fields are required"},
+		{"unknown table", &Annotation{Table: "users", EventId: "e1", Fields: map[string]interface{}{"status": "ok"}}, "Table [users] doesn't exist"},
+		{"unknown column", &Annotation{Table: "events", EventId: "e1", Fields: map[string]interface{}{"amount": "ok"}}, "Column [amount] doesn't exist in [events] table"},
+		{"key column", &Annotation{Table: "events", EventId: "e1", Fields: map[string]interface{}{"eventn_ctx_event_id": "e2"}}, "Column eventn_ctx_event_id can't be annotated"},
+		{"wrong type", &Annotation{Table: "events", EventId: "e1", Fields: map[string]interface{}{"revenue": true}},
+			"Error converting [revenue] column value [true] to FLOAT64: No rule for converting BOOL to FLOAT64"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := annotate("test", adapter, tableHelper, tt.annotation)
+			require.EqualError(t, err, tt.expectedErr)
+		})
+	}
+}
@@ -10,6 +10,8 @@ import (
 	"github.com/jitsucom/eventnative/caching"
 	"github.com/jitsucom/eventnative/counters"
 	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/health"
+	"github.com/jitsucom/eventnative/ledger"
 	"github.com/jitsucom/eventnative/logging"
 	"github.com/jitsucom/eventnative/metrics"
 	"github.com/jitsucom/eventnative/parsers"
@@ -27,6 +29,7 @@ type BigQuery struct {
 	gcsAdapter      *adapters.GoogleCloudStorage
 	bqAdapter       *adapters.BigQuery
 	tableHelper     *TableHelper
+	columnTypes     *ColumnTypesRegistry
 	schemaProcessor *schema.Processor
 	streamingWorker *StreamingWorker
 	fallbackLogger  *events.AsyncLogger
@@ -37,7 +40,7 @@ type BigQuery struct {
 }
 
 func NewBigQuery(ctx context.Context, name string, eventQueue *events.PersistentQueue, config *adapters.GoogleConfig,
-	processor *schema.Processor, breakOnError, streamMode bool, monitorKeeper MonitorKeeper, fallbackLoggerFactoryMethod func() *events.AsyncLogger,
+	processor *schema.Processor, breakOnError, streamMode bool, monitorKeeper MonitorKeeper, schemaMigrations string, fallbackLoggerFactoryMethod func() *events.AsyncLogger,
 	queryLogger *logging.QueryLogger, eventsCache *caching.EventsCache) (*BigQuery, error) {
 	var gcsAdapter *adapters.GoogleCloudStorage
 	if !streamMode {
@@ -63,13 +66,15 @@ func NewBigQuery(ctx context.Context, name string, eventQueue *events.Persistent
 		return nil, err
 	}
 
-	tableHelper := NewTableHelper(bigQueryAdapter, monitorKeeper, BigQueryType)
+	columnTypes := NewColumnTypesRegistry()
+	tableHelper := NewTableHelper(bigQueryAdapter, monitorKeeper, columnTypes, BigQueryType, processor.Limits(), schemaMigrations)
 
 	bq := &BigQuery{
 		name:            name,
 		gcsAdapter:      gcsAdapter,
 		bqAdapter:       bigQueryAdapter,
 		tableHelper:     tableHelper,
+		columnTypes:     columnTypes,
 		schemaProcessor: processor,
 		fallbackLogger:  fallbackLoggerFactoryMethod(),
 		eventsCache:     eventsCache,
@@ -101,7 +106,7 @@ func (bq *BigQuery) startBatch() {
 
 			filesKeys, err := bq.gcsAdapter.ListBucket(appconfig.Instance.ServerName)
 			if err != nil {
-				logging.Errorf("[%s] Error reading files from google cloud storage: %v", bq.Name(), err)
+				destinationsLogger.WithDestination(bq.Name()).Errorf("Error reading files from google cloud storage: %v", err)
 				continue
 			}
 
@@ -112,22 +117,40 @@ func (bq *BigQuery) startBatch() {
 			for _, fileKey := range filesKeys {
 				tableName, tokenId, rowsCount, err := extractDataFromFileName(fileKey)
 				if err != nil {
-					logging.Errorf("[%s] Google cloud storage file [%s] has wrong format: %v", bq.Name(), fileKey, err)
+					destinationsLogger.WithDestination(bq.Name()).Errorf("Google cloud storage file [%s] has wrong format: %v", fileKey, err)
+					continue
+				}
+
+				//copy has failed max attempts before the restart: the file is kept in the google cloud storage for manual handling
+				if ledger.Instance.IsDeadLettered(bq.Name(), fileKey) {
+					continue
+				}
+
+				//copy has been confirmed but the file wasn't deleted (e.g. crash or delete error)
+				if ledger.Instance.IsLoaded(bq.Name(), fileKey) {
+					destinationsLogger.WithDestination(bq.Name()).Warnf("file %s has already been copied to BigQuery. It will be deleted from google cloud storage", fileKey)
+					if err := bq.gcsAdapter.DeleteObject(fileKey); err != nil {
+						destinationsLogger.WithDestination(bq.Name()).Errorf("Error deleting already copied file %s from google cloud storage: %v", fileKey, err)
+					}
 					continue
 				}
 
 				if err := bq.bqAdapter.Copy(fileKey, tableName); err != nil {
-					logging.Errorf("[%s] Error copying file [%s] from google cloud storage to BigQuery: %v", bq.Name(), fileKey, err)
+					destinationsLogger.WithDestination(bq.Name()).Errorf("Error copying file [%s] from google cloud storage to BigQuery: %v", fileKey, err)
 					metrics.ErrorTokenEvents(tokenId, bq.Name(), rowsCount)
 					counters.ErrorEvents(bq.Name(), rowsCount)
+					health.Instance.Failed(bq.Name())
+					ledger.Instance.Loaded(bq.Name(), fileKey, tableName, rowsCount, err)
 					continue
 				}
+				ledger.Instance.Loaded(bq.Name(), fileKey, tableName, rowsCount, nil)
 
 				metrics.SuccessTokenEvents(tokenId, bq.Name(), rowsCount)
 				counters.SuccessEvents(bq.Name(), rowsCount)
+				health.Instance.Succeeded(bq.Name())
 
 				if err := bq.gcsAdapter.DeleteObject(fileKey); err != nil {
-					logging.SystemErrorf("[%s] file %s wasn't deleted from google cloud storage and will be inserted in db again: %v", bq.Name(), fileKey, err)
+					destinationsLogger.WithDestination(bq.Name()).SystemErrorf("file %s wasn't deleted from google cloud storage: %v", fileKey, err)
 					continue
 				}
 			}
@@ -137,6 +160,10 @@ func (bq *BigQuery) startBatch() {
 
 //Insert fact in BigQuery
 func (bq *BigQuery) Insert(dataSchema *schema.Table, fact events.Fact) (err error) {
+	if ok, err := bq.tableHelper.GuardObjectColumns(bq.Name(), dataSchema, fact); !ok {
+		return err
+	}
+
 	dbSchema, err := bq.tableHelper.EnsureTable(bq.Name(), dataSchema)
 	if err != nil {
 		return err
@@ -163,6 +190,7 @@ func (bq *BigQuery) StoreWithParseFunc(fileName string, payload []byte, parseFun
 	if err != nil {
 		return linesCount(payload), err
 	}
+	failedEvents = append(failedEvents, bq.tableHelper.GuardColumns(bq.Name(), flatData)...)
 
 	var rowsCount int
 	for _, fdata := range flatData {
@@ -195,8 +223,7 @@ func (bq *BigQuery) StoreWithParseFunc(fileName string, payload []byte, parseFun
 
 	for _, fdata := range flatData {
 		b, fileRows := fdata.GetPayloadBytes(schema.JsonMarshallerInstance)
-		err := bq.gcsAdapter.UploadBytes(buildDataIntoFileName(fdata, fileRows), b)
-		if err != nil {
+		if err := stageOnce(bq.Name(), buildDataIntoFileName(fdata, fileRows), fdata.DataSchema.Name, fileRows, b, bq.gcsAdapter.UploadBytes); err != nil {
 			return fileRows, err
 		}
 	}
@@ -226,6 +253,21 @@ func (bq *BigQuery) ColumnTypesMapping() map[typing.DataType]string {
 	return adapters.SchemaToBigQueryString
 }
 
+//ColumnTypesReport return discovered column types vs actual db column types per table
+func (bq *BigQuery) ColumnTypesReport() []*TableTypesReport {
+	return bq.columnTypes.Report(bq.ColumnTypesMapping())
+}
+
+//MigrationPlans return table migration plans which are waiting for review
+func (bq *BigQuery) MigrationPlans() []*schema.MigrationPlan {
+	return bq.tableHelper.MigrationPlans()
+}
+
+//ApplyMigrationPlan apply reviewed table migration plan
+func (bq *BigQuery) ApplyMigrationPlan(table string) error {
+	return bq.tableHelper.ApplyMigrationPlan(bq.Name(), table)
+}
+
 func (bq *BigQuery) Name() string {
 	return bq.name
 }
@@ -22,6 +22,7 @@ type ClickHouse struct {
 	name            string
 	adapters        []*adapters.ClickHouse
 	tableHelpers    []*TableHelper
+	columnTypes     *ColumnTypesRegistry
 	schemaProcessor *schema.Processor
 	streamingWorker *StreamingWorker
 	fallbackLogger  *events.AsyncLogger
@@ -30,7 +31,7 @@ type ClickHouse struct {
 }
 
 func NewClickHouse(ctx context.Context, name string, eventQueue *events.PersistentQueue, config *adapters.ClickHouseConfig,
-	processor *schema.Processor, breakOnError, streamMode bool, monitorKeeper MonitorKeeper,
+	processor *schema.Processor, breakOnError, streamMode bool, monitorKeeper MonitorKeeper, schemaMigrations string,
 	fallbackLoggerFactoryMethod func() *events.AsyncLogger, queryLogger *logging.QueryLogger, eventsCache *caching.EventsCache) (*ClickHouse, error) {
 	tableStatementFactory, err := adapters.NewTableStatementFactory(config)
 	if err != nil {
@@ -46,6 +47,8 @@ func NewClickHouse(ctx context.Context, name string, eventQueue *events.Persiste
 
 	var chAdapters []*adapters.ClickHouse
 	var tableHelpers []*TableHelper
+	//column types are shared between all dsns
+	columnTypes := NewColumnTypesRegistry()
 	for _, dsn := range config.Dsns {
 		adapter, err := adapters.NewClickHouse(ctx, dsn, config.Database, config.Cluster, config.Tls,
 			tableStatementFactory, nullableFields, queryLogger)
@@ -58,13 +61,14 @@ func NewClickHouse(ctx context.Context, name string, eventQueue *events.Persiste
 		}
 
 		chAdapters = append(chAdapters, adapter)
-		tableHelpers = append(tableHelpers, NewTableHelper(adapter, monitorKeeper, ClickHouseType))
+		tableHelpers = append(tableHelpers, NewTableHelper(adapter, monitorKeeper, columnTypes, ClickHouseType, processor.Limits(), schemaMigrations))
 	}
 
 	ch := &ClickHouse{
 		name:            name,
 		adapters:        chAdapters,
 		tableHelpers:    tableHelpers,
+		columnTypes:     columnTypes,
 		schemaProcessor: processor,
 		eventsCache:     eventsCache,
 		breakOnError:    breakOnError,
@@ -102,6 +106,10 @@ func (ch *ClickHouse) Type() string {
 func (ch *ClickHouse) Insert(dataSchema *schema.Table, fact events.Fact) (err error) {
 	adapter, tableHelper := ch.getAdapters()
 
+	if ok, err := tableHelper.GuardObjectColumns(ch.Name(), dataSchema, fact); !ok {
+		return err
+	}
+
 	dbSchema, err := tableHelper.EnsureTable(ch.Name(), dataSchema)
 	if err != nil {
 		return err
@@ -127,6 +135,8 @@ func (ch *ClickHouse) StoreWithParseFunc(fileName string, payload []byte, parseF
 	if err != nil {
 		return linesCount(payload), err
 	}
+	_, tableHelper := ch.getAdapters()
+	failedEvents = append(failedEvents, tableHelper.GuardColumns(ch.Name(), flatData)...)
 
 	rowsCount, err := ch.store(flatData)
 
@@ -158,6 +168,11 @@ func (ch *ClickHouse) ColumnTypesMapping() map[typing.DataType]string {
 	return adapters.SchemaToClickhouse
 }
 
+//ColumnTypesReport return discovered column types vs actual db column types per table
+func (ch *ClickHouse) ColumnTypesReport() []*TableTypesReport {
+	return ch.columnTypes.Report(ch.ColumnTypesMapping())
+}
+
 //Fallback log event with error to fallback logger
 func (ch *ClickHouse) Fallback(failedFacts ...*events.FailedFact) {
 	for _, failedFact := range failedFacts {
@@ -212,6 +227,16 @@ func (ch *ClickHouse) store(flatData map[string]*schema.ProcessedFile) (rowsCoun
 	return rowsCount, tx.DirectCommit()
 }
 
+//Ping check connections of all ClickHouse nodes
+func (ch *ClickHouse) Ping() error {
+	for _, adapter := range ch.adapters {
+		if err := adapter.Ping(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 //Close adapters.ClickHouse
 func (ch *ClickHouse) Close() (multiErr error) {
 	for i, adapter := range ch.adapters {
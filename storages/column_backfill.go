@@ -0,0 +1,9 @@
+package storages
+
+//ColumnBackfiller is a destination which supports backfill of renamed columns (see DataLayout.ColumnRenames)
+type ColumnBackfiller interface {
+	//BackfillColumn copy at most batchSize old values of the from column into empty values of the to column.
+	//The to column is created with the from column type if it doesn't exist.
+	//return updated rows count: less than batchSize means that backfill is finished
+	BackfillColumn(tableName, from, to string, batchSize int) (int64, error)
+}
@@ -0,0 +1,190 @@
+package storages
+
+import (
+	"github.com/jitsucom/eventnative/schema"
+	"github.com/jitsucom/eventnative/typing"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	//discovered type equals warehouse type
+	ColumnTypeOk = "ok"
+	//discovered type is cast to warehouse type
+	ColumnTypeConvertible = "convertible"
+	//discovered type can't be cast to warehouse type: such events are failed
+	ColumnTypeIncompatible = "incompatible"
+	//column doesn't exist in the warehouse yet
+	ColumnTypeUnknown = "unknown"
+)
+
+//ColumnTypesReporter is implemented by storages which keep tables schema (all except s3)
+type ColumnTypesReporter interface {
+	ColumnTypesReport() []*TableTypesReport
+}
+
+type TableTypesReport struct {
+	Table      string               `json:"table"`
+	Mismatches int                  `json:"mismatches"`
+	Columns    []*ColumnTypesReport `json:"columns"`
+}
+
+type ColumnTypesReport struct {
+	Column string `json:"column"`
+	//warehouse column type in eventnative types and in sql types
+	WarehouseType    string            `json:"warehouse_type,omitempty"`
+	WarehouseSqlType string            `json:"warehouse_sql_type,omitempty"`
+	Discovered       []*DiscoveredType `json:"discovered"`
+	Mismatch         bool              `json:"mismatch"`
+}
+
+//DiscoveredType is a column type from incoming events with statistics and a status relatively to the warehouse type
+type DiscoveredType struct {
+	Type        string    `json:"type"`
+	Occurrences int64     `json:"occurrences"`
+	LastSeen    time.Time `json:"last_seen"`
+	Status      string    `json:"status"`
+}
+
+type discoveredStat struct {
+	occurrences int64
+	lastSeen    time.Time
+}
+
+type tableTypes struct {
+	warehouse  map[string]typing.DataType
+	discovered map[string]map[typing.DataType]*discoveredStat
+}
+
+//ColumnTypesRegistry accumulates column types discovered from incoming events and
+//the last known warehouse column types per table since the instance start
+type ColumnTypesRegistry struct {
+	sync.RWMutex
+	tables map[string]*tableTypes
+}
+
+func NewColumnTypesRegistry() *ColumnTypesRegistry {
+	return &ColumnTypesRegistry{tables: map[string]*tableTypes{}}
+}
+
+//Discovered increment occurrences of every column type from the data schema
+func (ctr *ColumnTypesRegistry) Discovered(dataSchema *schema.Table) {
+	if !dataSchema.Exists() {
+		return
+	}
+
+	now := time.Now().UTC()
+	ctr.Lock()
+	defer ctr.Unlock()
+
+	table := ctr.getOrCreate(dataSchema.Name)
+	for name, column := range dataSchema.Columns {
+		stats, ok := table.discovered[name]
+		if !ok {
+			stats = map[typing.DataType]*discoveredStat{}
+			table.discovered[name] = stats
+		}
+
+		columnType := column.GetType()
+		stat, ok := stats[columnType]
+		if !ok {
+			stat = &discoveredStat{}
+			stats[columnType] = stat
+		}
+		stat.occurrences++
+		stat.lastSeen = now
+	}
+}
+
+//Warehouse save actual warehouse column types
+func (ctr *ColumnTypesRegistry) Warehouse(dbSchema *schema.Table) {
+	if dbSchema == nil {
+		return
+	}
+
+	warehouse := make(map[string]typing.DataType, len(dbSchema.Columns))
+	for name, column := range dbSchema.Columns {
+		warehouse[name] = column.GetType()
+	}
+
+	ctr.Lock()
+	defer ctr.Unlock()
+	ctr.getOrCreate(dbSchema.Name).warehouse = warehouse
+}
+
+//Report return tables (sorted by name) with columns (sorted by name) where discovered types are compared to warehouse types
+//sqlTypes is used for warehouse type representation
+func (ctr *ColumnTypesRegistry) Report(sqlTypes map[typing.DataType]string) []*TableTypesReport {
+	ctr.RLock()
+	defer ctr.RUnlock()
+
+	reports := make([]*TableTypesReport, 0, len(ctr.tables))
+	for tableName, table := range ctr.tables {
+		tableReport := &TableTypesReport{Table: tableName, Columns: []*ColumnTypesReport{}}
+		for columnName, stats := range table.discovered {
+			columnReport := &ColumnTypesReport{Column: columnName}
+			warehouseType, exists := table.warehouse[columnName]
+			if exists {
+				columnReport.WarehouseType = warehouseType.String()
+				columnReport.WarehouseSqlType = sqlTypes[warehouseType]
+			}
+
+			for discoveredType, stat := range stats {
+				status := ColumnTypeUnknown
+				if exists {
+					status = typeStatus(discoveredType, warehouseType)
+				}
+				if status != ColumnTypeOk && status != ColumnTypeUnknown {
+					columnReport.Mismatch = true
+				}
+
+				columnReport.Discovered = append(columnReport.Discovered, &DiscoveredType{
+					Type:        discoveredType.String(),
+					Occurrences: stat.occurrences,
+					LastSeen:    stat.lastSeen,
+					Status:      status,
+				})
+			}
+			sort.Slice(columnReport.Discovered, func(i, j int) bool {
+				return columnReport.Discovered[i].Type < columnReport.Discovered[j].Type
+			})
+
+			if columnReport.Mismatch {
+				tableReport.Mismatches++
+			}
+			tableReport.Columns = append(tableReport.Columns, columnReport)
+		}
+		sort.Slice(tableReport.Columns, func(i, j int) bool {
+			return tableReport.Columns[i].Column < tableReport.Columns[j].Column
+		})
+
+		reports = append(reports, tableReport)
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].Table < reports[j].Table
+	})
+
+	return reports
+}
+
+func (ctr *ColumnTypesRegistry) getOrCreate(tableName string) *tableTypes {
+	table, ok := ctr.tables[tableName]
+	if !ok {
+		table = &tableTypes{warehouse: map[string]typing.DataType{}, discovered: map[string]map[typing.DataType]*discoveredStat{}}
+		ctr.tables[tableName] = table
+	}
+	return table
+}
+
+func typeStatus(discovered, warehouse typing.DataType) string {
+	if discovered == warehouse {
+		return ColumnTypeOk
+	}
+
+	if typing.IsConvertible(discovered, warehouse) {
+		return ColumnTypeConvertible
+	}
+
+	return ColumnTypeIncompatible
+}
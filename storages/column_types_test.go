@@ -0,0 +1,66 @@
+package storages
+
+import (
+	"github.com/jitsucom/eventnative/adapters"
+	"github.com/jitsucom/eventnative/schema"
+	"github.com/jitsucom/eventnative/typing"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestColumnTypesReport(t *testing.T) {
+	registry := NewColumnTypesRegistry()
+	registry.Discovered(&schema.Table{Name: "events", Columns: schema.Columns{
+		"id":      schema.NewColumn(typing.INT64),
+		"amount":  schema.NewColumn(typing.INT64),
+		"user_id": schema.NewColumn(typing.INT64),
+	}})
+	registry.Discovered(&schema.Table{Name: "events", Columns: schema.Columns{
+		"id":      schema.NewColumn(typing.INT64),
+		"amount":  schema.NewColumn(typing.FLOAT64),
+		"user_id": schema.NewColumn(typing.STRING),
+		"new_col": schema.NewColumn(typing.STRING),
+	}})
+	registry.Warehouse(&schema.Table{Name: "events", Columns: schema.Columns{
+		"id":      schema.NewColumn(typing.INT64),
+		"amount":  schema.NewColumn(typing.FLOAT64),
+		"user_id": schema.NewColumn(typing.INT64),
+	}})
+	//empty schemas are skipped
+	registry.Discovered(&schema.Table{Name: "skipped"})
+
+	reports := registry.Report(adapters.SchemaToPostgres)
+	require.Equal(t, 1, len(reports))
+
+	report := reports[0]
+	require.Equal(t, "events", report.Table)
+	require.Equal(t, 2, report.Mismatches)
+	require.Equal(t, 4, len(report.Columns))
+
+	expected := []struct {
+		column          string
+		warehouseType   string
+		mismatch        bool
+		discoveredTypes []string
+		statuses        []string
+		occurrences     []int64
+	}{
+		{"amount", "FLOAT64", true, []string{"FLOAT64", "INT64"}, []string{ColumnTypeOk, ColumnTypeConvertible}, []int64{1, 1}},
+		{"id", "INT64", false, []string{"INT64"}, []string{ColumnTypeOk}, []int64{2}},
+		{"new_col", "", false, []string{"STRING"}, []string{ColumnTypeUnknown}, []int64{1}},
+		{"user_id", "INT64", true, []string{"INT64", "STRING"}, []string{ColumnTypeOk, ColumnTypeIncompatible}, []int64{1, 1}},
+	}
+	for i, e := range expected {
+		column := report.Columns[i]
+		require.Equal(t, e.column, column.Column)
+		require.Equal(t, e.warehouseType, column.WarehouseType, e.column)
+		require.Equal(t, e.mismatch, column.Mismatch, e.column)
+		require.Equal(t, len(e.discoveredTypes), len(column.Discovered), e.column)
+		for j, discovered := range column.Discovered {
+			require.Equal(t, e.discoveredTypes[j], discovered.Type, e.column)
+			require.Equal(t, e.statuses[j], discovered.Status, e.column)
+			require.Equal(t, e.occurrences[j], discovered.Occurrences, e.column)
+		}
+	}
+	require.Equal(t, adapters.SchemaToPostgres[typing.INT64], report.Columns[1].WarehouseSqlType)
+}
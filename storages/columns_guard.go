@@ -0,0 +1,146 @@
+package storages
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/metrics"
+	"github.com/jitsucom/eventnative/schema"
+	"sort"
+)
+
+//hard columns limits of warehouse tables
+var warehouseColumnsCaps = map[string]int{
+	PostgresType: 1600,
+	RedshiftType: 1600,
+	BigQueryType: 10000,
+}
+
+//GuardColumns apply max table columns limit to every processed file: objects which would push the table
+//past the limit are skipped (reject policy), lose new columns over the limit (truncate policy) or
+//are returned as failed facts for writing into fallback (fallback policy). Empty files are removed from flatData
+func (th *TableHelper) GuardColumns(destinationName string, flatData map[string]*schema.ProcessedFile) []*events.FailedFact {
+	var failedFacts []*events.FailedFact
+	for key, fdata := range flatData {
+		accepted, failed := th.guardColumns(destinationName, fdata.DataSchema, fdata.GetPayload())
+		failedFacts = append(failedFacts, failed...)
+		if len(accepted) == 0 {
+			delete(flatData, key)
+			continue
+		}
+		fdata.SetPayload(accepted)
+	}
+
+	return failedFacts
+}
+
+//GuardObjectColumns apply max table columns limit to the object (stream mode)
+//return false if the object must be skipped (reject policy) or error if the object must be written into fallback
+func (th *TableHelper) GuardObjectColumns(destinationName string, dataSchema *schema.Table, object map[string]interface{}) (bool, error) {
+	accepted, failed := th.guardColumns(destinationName, dataSchema, []map[string]interface{}{object})
+	if len(failed) > 0 {
+		return false, errors.New(failed[0].Error)
+	}
+
+	return len(accepted) > 0, nil
+}
+
+//guardColumns return objects which fit into the table columns limit and failed facts
+//dataSchema columns which have been added only by skipped objects are removed
+func (th *TableHelper) guardColumns(destinationName string, dataSchema *schema.Table, objects []map[string]interface{}) ([]map[string]interface{}, []*events.FailedFact) {
+	if th.maxColumns <= 0 || !dataSchema.Exists() {
+		return objects, nil
+	}
+
+	known, err := th.knownColumns(dataSchema.Name)
+	if err != nil {
+		destinationsLogger.WithDestination(destinationName).Errorf("Unable to check table [%s] columns limit: %v", dataSchema.Name, err)
+		return objects, nil
+	}
+
+	newColumns := 0
+	for column := range dataSchema.Columns {
+		if _, ok := known[column]; !ok {
+			newColumns++
+		}
+	}
+	if len(known)+newColumns <= th.maxColumns {
+		return objects, nil
+	}
+
+	added := map[string]bool{}
+	var accepted []map[string]interface{}
+	var failedFacts []*events.FailedFact
+	exceeded := 0
+	for _, object := range objects {
+		var objectNewColumns []string
+		for column := range object {
+			if _, ok := known[column]; !ok && !added[column] {
+				objectNewColumns = append(objectNewColumns, column)
+			}
+		}
+
+		free := th.maxColumns - len(known) - len(added)
+		if free < 0 {
+			free = 0
+		}
+		if len(objectNewColumns) <= free {
+			for _, column := range objectNewColumns {
+				added[column] = true
+			}
+			accepted = append(accepted, object)
+			continue
+		}
+
+		exceeded++
+		switch th.columnsPolicy {
+		case schema.RejectPolicy:
+		case schema.TruncatePolicy:
+			sort.Strings(objectNewColumns)
+			for _, column := range objectNewColumns[:free] {
+				added[column] = true
+			}
+			for _, column := range objectNewColumns[free:] {
+				delete(object, column)
+			}
+			accepted = append(accepted, object)
+		default:
+			//flattened object is written because the original event isn't available here
+			failedFacts = append(failedFacts, &events.FailedFact{
+				Event: []byte(events.Fact(object).Serialize()),
+				Error: fmt.Sprintf("Table [%s] has %d columns: event with %d new columns exceeds %d columns limit",
+					dataSchema.Name, len(known)+len(added), len(objectNewColumns), th.maxColumns),
+				EventId: events.ExtractEventId(object),
+			})
+		}
+	}
+
+	for column := range dataSchema.Columns {
+		if _, ok := known[column]; !ok && !added[column] {
+			delete(dataSchema.Columns, column)
+		}
+	}
+
+	if exceeded > 0 {
+		destinationsLogger.WithDestination(destinationName).Errorf("%d events would push table [%s] past %d columns limit: applied [%s] policy", exceeded, dataSchema.Name, th.maxColumns, th.columnsPolicy)
+		metrics.TableColumnsExceeded(destinationName, th.columnsPolicy, exceeded)
+	}
+
+	return accepted, failedFacts
+}
+
+//knownColumns return cached table columns or columns from the warehouse if the table hasn't been cached yet
+func (th *TableHelper) knownColumns(tableName string) (schema.Columns, error) {
+	if dbTableSchema, ok := th.tables[tableName]; ok {
+		return dbTableSchema.Columns, nil
+	}
+
+	dbTableSchema, err := th.manager.GetTableSchema(tableName)
+	if err != nil {
+		return nil, err
+	}
+	if dbTableSchema.Columns == nil {
+		return schema.Columns{}, nil
+	}
+	return dbTableSchema.Columns, nil
+}
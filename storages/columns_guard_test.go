@@ -0,0 +1,78 @@
+package storages
+
+import (
+	"github.com/jitsucom/eventnative/schema"
+	"github.com/jitsucom/eventnative/typing"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestGuardColumns(t *testing.T) {
+	tests := []struct {
+		name             string
+		policy           string
+		input            []map[string]interface{}
+		expected         []map[string]interface{}
+		expectedFailed   int
+		expectedDBSchema []string
+	}{
+		{
+			"under limit",
+			schema.FallbackPolicy,
+			[]map[string]interface{}{{"a": 1, "c": 1}, {"b": 1}},
+			[]map[string]interface{}{{"a": 1, "c": 1}, {"b": 1}},
+			0,
+			[]string{"a", "b", "c"},
+		},
+		{
+			"fallback",
+			schema.FallbackPolicy,
+			[]map[string]interface{}{{"a": 1, "c": 1}, {"a": 1, "d": 1, "e": 1}, {"a": 1, "d": 1}},
+			[]map[string]interface{}{{"a": 1, "c": 1}, {"a": 1, "d": 1}},
+			1,
+			[]string{"a", "c", "d"},
+		},
+		{
+			"reject",
+			schema.RejectPolicy,
+			[]map[string]interface{}{{"c": 1, "d": 1, "e": 1}, {"c": 1}},
+			[]map[string]interface{}{{"c": 1}},
+			0,
+			[]string{"c"},
+		},
+		{
+			"truncate",
+			schema.TruncatePolicy,
+			[]map[string]interface{}{{"a": 1, "e": 1, "d": 1, "c": 1}},
+			[]map[string]interface{}{{"a": 1, "c": 1, "d": 1}},
+			0,
+			[]string{"a", "c", "d"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th := &TableHelper{
+				tables:        map[string]*schema.Table{"events": {Name: "events", Columns: schema.Columns{"a": schema.NewColumn(typing.INT64)}}},
+				maxColumns:    3,
+				columnsPolicy: tt.policy,
+			}
+
+			dataSchema := &schema.Table{Name: "events", Columns: schema.Columns{}}
+			for _, object := range tt.input {
+				for column := range object {
+					dataSchema.Columns[column] = schema.NewColumn(typing.INT64)
+				}
+			}
+
+			accepted, failed := th.guardColumns("test", dataSchema, tt.input)
+			require.Equal(t, tt.expected, accepted)
+			require.Len(t, failed, tt.expectedFailed)
+
+			var columns []string
+			for column := range dataSchema.Columns {
+				columns = append(columns, column)
+			}
+			require.ElementsMatch(t, tt.expectedDBSchema, columns)
+		})
+	}
+}
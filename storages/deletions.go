@@ -0,0 +1,159 @@
+package storages
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/adapters"
+	"github.com/jitsucom/eventnative/schema"
+	"github.com/jitsucom/eventnative/typing"
+	"time"
+)
+
+const (
+	deletionTimestampColumn = "_timestamp"
+	deletionKeyColumn       = "eventn_ctx_event_id"
+)
+
+//DeletionFilter is a filter of bulk deleted table rows: _timestamp in [From, To) and all field predicates.
+//At least one of them is required
+type DeletionFilter struct {
+	Table  string            `json:"table"`
+	From   time.Time         `json:"from,omitempty"`
+	To     time.Time         `json:"to,omitempty"`
+	Fields []*FieldPredicate `json:"fields,omitempty"`
+}
+
+//FieldPredicate is a comparison of the column value. Operators: eq, neq (Value is a scalar), in, not_in (Value is an array),
+//is_null and not_null (without Value)
+type FieldPredicate struct {
+	Field    string      `json:"field"`
+	Operator string      `json:"operator"`
+	Value    interface{} `json:"value,omitempty"`
+}
+
+//BulkDeleter is a destination which supports deletion of stored events by filter (e.g. test traffic or incident data)
+type BulkDeleter interface {
+	//CountDeletion return count of rows which match the filter (dry-run)
+	CountDeletion(filter *DeletionFilter) (int64, error)
+	//Delete delete rows which match the filter in batches of batchSize rows with the pause between them. return deleted rows count
+	Delete(filter *DeletionFilter, batchSize int, pause time.Duration) (int64, error)
+}
+
+//deletingAdapter is a sql adapter which supports counting and batch deletion of rows by condition
+type deletingAdapter interface {
+	GetTableSchema(tableName string) (*schema.Table, error)
+	CountRows(table *schema.Table, condition *adapters.DeletionCondition) (int64, error)
+	DeleteBatch(table *schema.Table, keyColumn string, condition *adapters.DeletionCondition, batchSize int) (int64, error)
+}
+
+//countDeletion return count of the table rows which match the filter
+func countDeletion(adapter deletingAdapter, filter *DeletionFilter) (int64, error) {
+	table, condition, err := deletionCondition(adapter, filter)
+	if err != nil {
+		return 0, err
+	}
+
+	return adapter.CountRows(table, condition)
+}
+
+//deleteByFilter delete the table rows which match the filter in batches (rows are selected by event id) until there are no
+//matched rows. Rows without event id aren't deleted
+func deleteByFilter(destinationName string, adapter deletingAdapter, filter *DeletionFilter, batchSize int, pause time.Duration) (int64, error) {
+	if batchSize <= 0 {
+		return 0, errors.New("Deletion batch size must be positive")
+	}
+	table, condition, err := deletionCondition(adapter, filter)
+	if err != nil {
+		return 0, err
+	}
+	if _, ok := table.Columns[deletionKeyColumn]; !ok {
+		return 0, fmt.Errorf("Table [%s] doesn't have %s column", filter.Table, deletionKeyColumn)
+	}
+
+	var deleted int64
+	for {
+		batchDeleted, err := adapter.DeleteBatch(table, deletionKeyColumn, condition, batchSize)
+		if err != nil {
+			return deleted, err
+		}
+		deleted += batchDeleted
+		if batchDeleted == 0 {
+			break
+		}
+
+		destinationsLogger.WithDestination(destinationName).Debugf("Bulk delete: %d rows of [%s] table were deleted", deleted, filter.Table)
+		time.Sleep(pause)
+	}
+
+	destinationsLogger.WithDestination(destinationName).Infof("Bulk delete: %d rows of [%s] table were deleted", deleted, filter.Table)
+	return deleted, nil
+}
+
+//deletionCondition validate the filter against the table schema and return the table with the condition.
+//Predicate values are converted into the column types
+func deletionCondition(adapter deletingAdapter, filter *DeletionFilter) (*schema.Table, *adapters.DeletionCondition, error) {
+	if filter.Table == "" {
+		return nil, nil, errors.New("Deletion table is required")
+	}
+	if filter.From.IsZero() && filter.To.IsZero() && len(filter.Fields) == 0 {
+		return nil, nil, errors.New("Deletion filter must have from, to or fields: deletion of all table rows isn't allowed")
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return nil, nil, errors.New("Deletion filter from must be before to")
+	}
+
+	table, err := adapter.GetTableSchema(filter.Table)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !table.Exists() {
+		return nil, nil, fmt.Errorf("Table [%s] doesn't exist", filter.Table)
+	}
+
+	condition := &adapters.DeletionCondition{TimestampColumn: deletionTimestampColumn, From: filter.From, To: filter.To}
+	if !filter.From.IsZero() || !filter.To.IsZero() {
+		if _, ok := table.Columns[deletionTimestampColumn]; !ok {
+			return nil, nil, fmt.Errorf("Table [%s] doesn't have %s column", filter.Table, deletionTimestampColumn)
+		}
+	}
+
+	for _, field := range filter.Fields {
+		column, ok := table.Columns[field.Field]
+		if !ok {
+			return nil, nil, fmt.Errorf("Column [%s] doesn't exist in [%s] table", field.Field, filter.Table)
+		}
+
+		var values []interface{}
+		switch field.Operator {
+		case adapters.EqOperator, adapters.NeqOperator:
+			if field.Value == nil {
+				return nil, nil, fmt.Errorf("Field [%s] %s predicate requires value. Use is_null or not_null for NULL values", field.Field, field.Operator)
+			}
+			values = []interface{}{field.Value}
+		case adapters.InOperator, adapters.NotInOperator:
+			array, ok := field.Value.([]interface{})
+			if !ok || len(array) == 0 {
+				return nil, nil, fmt.Errorf("Field [%s] %s predicate requires not empty array value", field.Field, field.Operator)
+			}
+			values = array
+		case adapters.IsNullOperator, adapters.NotNullOperator:
+			if field.Value != nil {
+				return nil, nil, fmt.Errorf("Field [%s] %s predicate doesn't have value", field.Field, field.Operator)
+			}
+		default:
+			return nil, nil, fmt.Errorf("Unknown field [%s] predicate operator: %s. Available: [eq, neq, in, not_in, is_null, not_null]", field.Field, field.Operator)
+		}
+
+		predicate := &adapters.Predicate{Column: field.Field, Operator: field.Operator}
+		for _, value := range values {
+			converted, err := typing.Convert(column.GetType(), typing.ReformatValue(value))
+			if err != nil {
+				return nil, nil, fmt.Errorf("Error converting [%s] column value [%v] to %s: %v", field.Field, value, column.GetType(), err)
+			}
+			predicate.Values = append(predicate.Values, converted)
+		}
+		condition.Predicates = append(condition.Predicates, predicate)
+	}
+
+	return table, condition, nil
+}
@@ -0,0 +1,94 @@
+package storages
+
+import (
+	"encoding/json"
+	"github.com/jitsucom/eventnative/adapters"
+	"github.com/jitsucom/eventnative/schema"
+	"github.com/jitsucom/eventnative/typing"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+type deletingAdapterMock struct {
+	table     *schema.Table
+	rows      int64
+	batches   int
+	condition *adapters.DeletionCondition
+}
+
+func (dam *deletingAdapterMock) GetTableSchema(tableName string) (*schema.Table, error) {
+	if tableName != dam.table.Name {
+		return &schema.Table{Name: tableName, Columns: schema.Columns{}}, nil
+	}
+	return dam.table, nil
+}
+
+func (dam *deletingAdapterMock) CountRows(table *schema.Table, condition *adapters.DeletionCondition) (int64, error) {
+	dam.condition = condition
+	return dam.rows, nil
+}
+
+func (dam *deletingAdapterMock) DeleteBatch(table *schema.Table, keyColumn string, condition *adapters.DeletionCondition, batchSize int) (int64, error) {
+	dam.condition = condition
+	dam.batches++
+	deleted := dam.rows
+	if deleted > int64(batchSize) {
+		deleted = int64(batchSize)
+	}
+	dam.rows -= deleted
+	return deleted, nil
+}
+
+func TestDeleteByFilter(t *testing.T) {
+	adapter := &deletingAdapterMock{rows: 25, table: &schema.Table{Name: "events", Columns: schema.Columns{
+		"_timestamp":          schema.NewColumn(typing.TIMESTAMP),
+		"eventn_ctx_event_id": schema.NewColumn(typing.STRING),
+		"event_type":          schema.NewColumn(typing.STRING),
+		"amount":              schema.NewColumn(typing.INT64),
+	}}}
+	from := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	filter := &DeletionFilter{Table: "events", From: from, Fields: []*FieldPredicate{
+		{Field: "event_type", Operator: adapters.InOperator, Value: []interface{}{"test"}},
+		{Field: "amount", Operator: adapters.EqOperator, Value: json.Number("0")},
+	}}
+
+	count, err := countDeletion(adapter, filter)
+	require.NoError(t, err)
+	require.Equal(t, int64(25), count)
+	require.Equal(t, &adapters.DeletionCondition{TimestampColumn: "_timestamp", From: from, Predicates: []*adapters.Predicate{
+		{Column: "event_type", Operator: adapters.InOperator, Values: []interface{}{"test"}},
+		{Column: "amount", Operator: adapters.EqOperator, Values: []interface{}{int64(0)}},
+	}}, adapter.condition)
+
+	deleted, err := deleteByFilter("test", adapter, filter, 10, 0)
+	require.NoError(t, err)
+	require.Equal(t, int64(25), deleted)
+	//the last batch returns 0
+	require.Equal(t, 4, adapter.batches)
+
+	tests := []struct {
+		name        string
+		filter      *DeletionFilter
+		expectedErr string
+	}{
+		{"without table", &DeletionFilter{From: from}, "Deletion table is required"},
+		{"without conditions", &DeletionFilter{Table: "events"}, "Deletion filter must have from, to or fields: deletion of all table rows isn't allowed"},
+		{"wrong range", &DeletionFilter{Table: "events", From: from, To: from}, "Deletion filter from must be before to"},
+		{"unknown table", &DeletionFilter{Table: "users", From: from}, "Table [users] doesn't exist"},
+		{"unknown column", &DeletionFilter{Table: "events", Fields: []*FieldPredicate{{Field: "app", Operator: adapters.IsNullOperator}}},
+			"Column [app] doesn't exist in [events] table"},
+		{"unknown operator", &DeletionFilter{Table: "events", Fields: []*FieldPredicate{{Field: "amount", Operator: "gt", Value: 1}}},
+			"Unknown field [amount] predicate operator: gt. Available: [eq, neq, in, not_in, is_null, not_null]"},
+		{"in without array", &DeletionFilter{Table: "events", Fields: []*FieldPredicate{{Field: "event_type", Operator: adapters.InOperator, Value: "test"}}},
+			"Field [event_type] in predicate requires not empty array value"},
+		{"eq null", &DeletionFilter{Table: "events", Fields: []*FieldPredicate{{Field: "event_type", Operator: adapters.EqOperator}}},
+			"Field [event_type] eq predicate requires value. Use is_null or not_null for NULL values"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := countDeletion(adapter, tt.filter)
+			require.EqualError(t, err, tt.expectedErr)
+		})
+	}
+}
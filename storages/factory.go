@@ -4,14 +4,23 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"github.com/jitsucom/eventnative/acks"
 	"github.com/jitsucom/eventnative/adapters"
 	"github.com/jitsucom/eventnative/appconfig"
 	"github.com/jitsucom/eventnative/caching"
+	"github.com/jitsucom/eventnative/drift"
 	"github.com/jitsucom/eventnative/enrichment"
 	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/filters"
 	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/recovery"
+	"github.com/jitsucom/eventnative/reports"
+	"github.com/jitsucom/eventnative/retry"
 	"github.com/jitsucom/eventnative/schema"
+	"github.com/spf13/viper"
 	"io"
+	"io/ioutil"
+	"time"
 )
 
 const (
@@ -23,6 +32,9 @@ const (
 
 var unknownDestination = errors.New("Unknown destination type")
 
+//destinationsLogger writes destinations component log lines (see logging.Configure levels)
+var destinationsLogger = logging.Component(logging.DestinationsComponent)
+
 type DestinationConfig struct {
 	OnlyTokens   []string                 `mapstructure:"only_tokens" json:"only_tokens,omitempty" yaml:"only_tokens,omitempty"`
 	Type         string                   `mapstructure:"type" json:"type,omitempty" yaml:"type,omitempty"`
@@ -30,12 +42,32 @@ type DestinationConfig struct {
 	DataLayout   *DataLayout              `mapstructure:"data_layout" json:"data_layout,omitempty" yaml:"data_layout,omitempty"`
 	Enrichment   []*enrichment.RuleConfig `mapstructure:"enrichment" json:"enrichment,omitempty" yaml:"enrichment,omitempty"`
 	BreakOnError bool                     `mapstructure:"break_on_error" json:"break_on_error,omitempty" yaml:"break_on_error,omitempty"`
+	Filter       string                   `mapstructure:"filter" json:"filter,omitempty" yaml:"filter,omitempty"`
+	//deterministic sampling of stored events (e.g. 1% of users journeys for expensive destinations)
+	Sampling *schema.Sampling `mapstructure:"sampling" json:"sampling,omitempty" yaml:"sampling,omitempty"`
+	//processed (default) - enriched, mapped and flattened events, raw - events as they have been received (before mapping)
+	Events string `mapstructure:"events" json:"events,omitempty" yaml:"events,omitempty"`
+	//workspace id: only the workspace tokens are stored. Is set for workspaces destinations
+	Workspace string `mapstructure:"workspace" json:"workspace,omitempty" yaml:"workspace,omitempty"`
+	//custom SQL statements which are executed before and after batch loads into the destination tables (postgres, redshift)
+	SQLHooks []*SQLHook `mapstructure:"sql_hooks" json:"sql_hooks,omitempty" yaml:"sql_hooks,omitempty"`
+	//rollups which are managed as materialized views and refreshed after batch loads (postgres, redshift)
+	Views []*View `mapstructure:"views" json:"views,omitempty" yaml:"views,omitempty"`
+	//identify events are folded into the upserted user profiles table (postgres)
+	UserProfiles *UserProfiles `mapstructure:"user_profiles" json:"user_profiles,omitempty" yaml:"user_profiles,omitempty"`
+	//metrics which are rolled up at ingest time (aggregates destination)
+	Aggregates *AggregatesConfig `mapstructure:"aggregates" json:"aggregates,omitempty" yaml:"aggregates,omitempty"`
+	//writes retry policy: max attempts, exponential backoff with jitter. Retryable errors are classified by destination type
+	Retry *retry.Config `mapstructure:"retry" json:"retry,omitempty" yaml:"retry,omitempty"`
 
 	DataSource *adapters.DataSourceConfig `mapstructure:"datasource" json:"datasource,omitempty" yaml:"datasource,omitempty"`
 	S3         *adapters.S3Config         `mapstructure:"s3" json:"s3,omitempty" yaml:"s3,omitempty"`
 	Google     *adapters.GoogleConfig     `mapstructure:"google" json:"google,omitempty" yaml:"google,omitempty"`
 	ClickHouse *adapters.ClickHouseConfig `mapstructure:"clickhouse" json:"clickhouse,omitempty" yaml:"clickhouse,omitempty"`
 	Snowflake  *adapters.SnowflakeConfig  `mapstructure:"snowflake" json:"snowflake,omitempty" yaml:"snowflake,omitempty"`
+
+	//processing configuration version (see configversions). It isn't configured: is set before the storage creation
+	ConfigVersion int `mapstructure:"-" json:"-" yaml:"-"`
 }
 
 type DataLayout struct {
@@ -43,6 +75,32 @@ type DataLayout struct {
 	Mapping           []string                `mapstructure:"mapping" json:"mapping,omitempty" yaml:"mapping,omitempty"`
 	TableNameTemplate string                  `mapstructure:"table_name_template" json:"table_name_template,omitempty" yaml:"table_name_template,omitempty"`
 	PrimaryKeyFields  []string                `mapstructure:"primary_key_fields" json:"primary_key_fields,omitempty" yaml:"primary_key_fields,omitempty"`
+	//hour, day, week or month. If it is set - table name template partition helpers and destination partitioning must match it
+	PartitionGranularity string `mapstructure:"partition_granularity" json:"partition_granularity,omitempty" yaml:"partition_granularity,omitempty"`
+	//columns renames: values are written into new columns going forward, old values can be backfilled via migrations API
+	ColumnRenames []schema.ColumnRename `mapstructure:"column_renames" json:"column_renames,omitempty" yaml:"column_renames,omitempty"`
+	//max event size, columns count and string length of flattened events
+	Limits *schema.Limits `mapstructure:"limits" json:"limits,omitempty" yaml:"limits,omitempty"`
+	//deprecated source fields: usage is counted and tagged, fields are dropped since the sunset date
+	Deprecations *schema.Deprecations `mapstructure:"deprecations" json:"deprecations,omitempty" yaml:"deprecations,omitempty"`
+	//default values of missing fields and explicit JSON nulls policy
+	Defaults *schema.Defaults `mapstructure:"defaults" json:"defaults,omitempty" yaml:"defaults,omitempty"`
+	//events older than the table watermark by more than lateness are written into <table>_late table or tagged with _late column
+	LateEvents *schema.LateEvents `mapstructure:"late_events" json:"late_events,omitempty" yaml:"late_events,omitempty"`
+	//primary key hash partition index is written into the partition column
+	PkPartitioning *schema.PkPartitioning `mapstructure:"pk_partitioning" json:"pk_partitioning,omitempty" yaml:"pk_partitioning,omitempty"`
+	//rows order (by _timestamp or primary keys) and stable columns order of batch files
+	FileOrdering *schema.FileOrdering `mapstructure:"file_ordering" json:"file_ordering,omitempty" yaml:"file_ordering,omitempty"`
+	//auto (default) - new columns and type widenings are applied automatically
+	//review - migration plans are waiting for review and applying via /api/v1/migrations/plans API
+	SchemaMigrations string `mapstructure:"schema_migrations" json:"schema_migrations,omitempty" yaml:"schema_migrations,omitempty"`
+	//per event types TTL overrides and archival tiers of the destination tables rows (postgres, redshift)
+	Retention []*RetentionRule `mapstructure:"retention" json:"retention,omitempty" yaml:"retention,omitempty"`
+	//fail (default) - the whole event fails if any field can't be converted to the column type
+	//quarantine - such fields are written as nulls with original values and errors in _quarantined_fields JSON column
+	TypecastFailures string `mapstructure:"typecast_failures" json:"typecast_failures,omitempty" yaml:"typecast_failures,omitempty"`
+	//ingestion time, server name, API token id, source type and processing config version columns of every processed object
+	MetadataColumns *schema.MetadataColumns `mapstructure:"metadata_columns" json:"metadata_columns,omitempty" yaml:"metadata_columns,omitempty"`
 }
 
 type Config struct {
@@ -52,26 +110,114 @@ type Config struct {
 	processor                   *schema.Processor
 	streamMode                  bool
 	monitorKeeper               MonitorKeeper
+	schemaMigrations            string
 	eventQueue                  *events.PersistentQueue
 	queryLogger                 *logging.QueryLogger
 	fallBackLoggerFactoryMethod func() *events.AsyncLogger
 	eventsCache                 *caching.EventsCache
+	retryPolicy                 *retry.Policy
+}
+
+//LoadOptions are optional SQL features around batch loads of SQL destinations. Nil features are disabled
+type LoadOptions struct {
+	SQLHooks *SQLHooks
+	Views    *ManagedViews
+	//Profiles are supported only by Postgres
+	Profiles *Profiles
+}
+
+//factoryMethods are destinations constructors by type
+var factoryMethods = map[string]func(*Config) (events.Storage, error){
+	RedshiftType:   createRedshift,
+	BigQueryType:   createBigQuery,
+	PostgresType:   createPostgres,
+	ClickHouseType: createClickHouse,
+	S3Type:         createS3,
+	SnowflakeType:  createSnowflake,
+	MemoryType:     createMemory,
+	AggregatesType: createAggregates,
 }
 
 //Create event storage proxy and event consumer (logger or event-queue)
 //Enrich incoming configs with default values if needed
 func Create(ctx context.Context, name, logEventPath, logFallbackPath string, logRotationMin int64,
 	destination DestinationConfig, monitorKeeper MonitorKeeper, queryWriter io.Writer, eventsCache *caching.EventsCache) (events.StorageProxy, *events.PersistentQueue, error) {
+	enrichDefaults(name, &destination)
+
+	storageConfig, err := newStorageConfig(ctx, name, &destination, monitorKeeper, queryWriter, eventsCache)
+	if err != nil {
+		return nil, nil, err
+	}
+	factoryMethod, ok := factoryMethods[destination.Type]
+	if !ok {
+		return nil, nil, unknownDestination
+	}
+	retry.Register(name, storageConfig.retryPolicy)
+
+	storageConfig.fallBackLoggerFactoryMethod = func() *events.AsyncLogger {
+		return events.NewAsyncLogger(reports.NewFallbackCounter(name, logging.NewRollingWriter(logging.Config{
+			LoggerName:    "errors-" + name,
+			ServerName:    appconfig.Instance.ServerName,
+			FileDir:       logFallbackPath,
+			RotationMin:   logRotationMin,
+			RotateOnClose: true,
+			MaxSizeMB:     viper.GetInt("log.max_file_size_mb"),
+			Compression:   viper.GetString("log.compression"),
+		})), false)
+	}
+
+	if destination.Mode == StreamMode {
+		queueName := fmt.Sprintf("%s-%s", appconfig.Instance.ServerName, name)
+		eventQueue, err := events.NewPersistentQueue(queueName, logEventPath, viper.GetInt64("server.stream_queue.max_size_mb")*1024*1024)
+		if err != nil {
+			return nil, nil, err
+		}
+		recoverQueue(name, queueName, eventQueue)
+		storageConfig.eventQueue = eventQueue
+	}
+
+	return newProxy(factoryMethod, storageConfig), storageConfig.eventQueue, nil
+}
+
+//NewProcessor compile only the destination processing pipeline (mapping, enrichment rules, table name template, filter, sampling)
+//without connecting to the destination. It is used in processor service mode
+func NewProcessor(name string, destination DestinationConfig) (*schema.Processor, error) {
+	enrichDefaults(name, &destination)
+
+	storageConfig, err := newStorageConfig(context.Background(), name, &destination, nil, ioutil.Discard, nil)
+	if err != nil {
+		return nil, err
+	}
+	return storageConfig.processor, nil
+}
+
+//enrichDefaults set destination type (= name) and mode (batch) if they aren't set
+func enrichDefaults(name string, destination *DestinationConfig) {
 	if destination.Type == "" {
 		destination.Type = name
 	}
 	if destination.Mode == "" {
 		destination.Mode = BatchMode
 	}
+}
 
+//newStorageConfig validate and compile the destination configuration: mapping, enrichment rules, table name template,
+//filter, sampling, SQL hooks, views, etc. Events queue and fallback logger factory aren't set
+func newStorageConfig(ctx context.Context, name string, destination *DestinationConfig, monitorKeeper MonitorKeeper,
+	queryWriter io.Writer, eventsCache *caching.EventsCache) (*Config, error) {
 	var mapping []string
 	var tableName string
 	var pkFieldsList []string
+	var columnRenames []schema.ColumnRename
+	var limits *schema.Limits
+	var deprecations *schema.Deprecations
+	var defaults *schema.Defaults
+	var lateEvents *schema.LateEvents
+	var pkPartitioning *schema.PkPartitioning
+	var fileOrdering *schema.FileOrdering
+	var typecastFailures string
+	var metadataColumns *schema.MetadataColumns
+	schemaMigrations := AutoMigrations
 	mappingFieldType := schema.Default
 	if destination.DataLayout != nil {
 		mappingFieldType = destination.DataLayout.MappingType
@@ -81,26 +227,41 @@ func Create(ctx context.Context, name, logEventPath, logFallbackPath string, log
 			tableName = destination.DataLayout.TableNameTemplate
 		}
 		pkFieldsList = destination.DataLayout.PrimaryKeyFields
+		columnRenames = destination.DataLayout.ColumnRenames
+		limits = destination.DataLayout.Limits
+		deprecations = destination.DataLayout.Deprecations
+		defaults = destination.DataLayout.Defaults
+		lateEvents = destination.DataLayout.LateEvents
+		pkPartitioning = destination.DataLayout.PkPartitioning
+		fileOrdering = destination.DataLayout.FileOrdering
+		typecastFailures = destination.DataLayout.TypecastFailures
+		metadataColumns = destination.DataLayout.MetadataColumns
+		if destination.DataLayout.SchemaMigrations != "" {
+			schemaMigrations = destination.DataLayout.SchemaMigrations
+		}
 	}
 
-	logging.Infof("[%s] Initializing destination of type: %s in mode: %s", name, destination.Type, destination.Mode)
+	destinationsLogger.WithDestination(name).Infof("Initializing destination of type: %s in mode: %s", destination.Type, destination.Mode)
 
 	if tableName == "" {
 		tableName = defaultTableName
-		logging.Infof("[%s] uses default table name: %s", name, tableName)
+		destinationsLogger.WithDestination(name).Infof("uses default table name: %s", tableName)
 	}
 
 	if len(mapping) == 0 {
-		logging.Warnf("[%s] doesn't have mapping rules", name)
+		destinationsLogger.WithDestination(name).Warnf("doesn't have mapping rules")
 	} else {
-		logging.Infof("[%s] Configured field mapping rules with [%s] mode:", name, mappingFieldType)
+		destinationsLogger.WithDestination(name).Infof("Configured field mapping rules with [%s] mode:", mappingFieldType)
 		for _, m := range mapping {
-			logging.Infof("[%s] %s", name, m)
+			destinationsLogger.WithDestination(name).Infof("%s", m)
 		}
 	}
 
 	if destination.Mode != BatchMode && destination.Mode != StreamMode {
-		return nil, nil, fmt.Errorf("Unknown destination mode: %s. Available mode: [%s, %s]", destination.Mode, BatchMode, StreamMode)
+		return nil, fmt.Errorf("Unknown destination mode: %s. Available mode: [%s, %s]", destination.Mode, BatchMode, StreamMode)
+	}
+	if destination.Events != "" && destination.Events != schema.ProcessedEvents && destination.Events != schema.RawEvents {
+		return nil, fmt.Errorf("Unknown destination events: %s. Available: [%s, %s]", destination.Events, schema.ProcessedEvents, schema.RawEvents)
 	}
 	pkFields := map[string]bool{}
 	for _, field := range pkFieldsList {
@@ -108,82 +269,195 @@ func Create(ctx context.Context, name, logEventPath, logFallbackPath string, log
 	}
 
 	if len(destination.Enrichment) == 0 {
-		logging.Warnf("[%s] doesn't have enrichment rules", name)
+		destinationsLogger.WithDestination(name).Warnf("doesn't have enrichment rules")
 	} else {
-		logging.Infof("[%s] Configured enrichment rules:", name)
+		destinationsLogger.WithDestination(name).Infof("Configured enrichment rules:")
 	}
 
 	var enrichmentRules []enrichment.Rule
 	for _, ruleConfig := range destination.Enrichment {
-		logging.Infof("[%s] %s", name, ruleConfig.String())
+		destinationsLogger.WithDestination(name).Infof("%s", ruleConfig.String())
 
 		rule, err := enrichment.NewRule(ruleConfig)
 		if err != nil {
-			return nil, nil, fmt.Errorf("Error creating enrichment rule [%s]: %v", ruleConfig.String(), err)
+			return nil, fmt.Errorf("Error creating enrichment rule [%s]: %v", ruleConfig.String(), err)
 		}
 
 		enrichmentRules = append(enrichmentRules, rule)
 	}
 
-	processor, err := schema.NewProcessor(tableName, mapping, mappingFieldType, pkFields, enrichmentRules)
+	var filter *filters.Filter
+	if destination.Filter != "" {
+		var err error
+		filter, err = filters.Parse(destination.Filter)
+		if err != nil {
+			return nil, err
+		}
+		destinationsLogger.WithDestination(name).Infof("Configured filter: %s", filter)
+	}
+
+	sampler, err := schema.NewSampler(destination.Sampling)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
+	}
+	if sampler != nil {
+		destinationsLogger.WithDestination(name).Infof("Configured sampling: %s", destination.Sampling)
 	}
 
-	var eventQueue *events.PersistentQueue
-	if destination.Mode == StreamMode {
-		queueName := fmt.Sprintf("%s-%s", appconfig.Instance.ServerName, name)
-		eventQueue, err = events.NewPersistentQueue(queueName, logEventPath)
-		if err != nil {
-			return nil, nil, err
+	if err := validatePartitionGranularity(*destination, tableName); err != nil {
+		return nil, err
+	}
+
+	if err := validateSchemaMigrations(destination.Type, schemaMigrations); err != nil {
+		return nil, err
+	}
+
+	for _, rename := range columnRenames {
+		destinationsLogger.WithDestination(name).Infof("Configured column rename %s", rename)
+	}
+
+	if err := validateRetention(*destination, name); err != nil {
+		return nil, err
+	}
+	if destination.DataLayout != nil {
+		for _, rule := range destination.DataLayout.Retention {
+			destinationsLogger.WithDestination(name).Infof("Configured retention rule %s", rule)
 		}
 	}
 
-	queryLogger := logging.NewQueryLogger(name, queryWriter)
-
-	storageConfig := &Config{
-		ctx:           ctx,
-		name:          name,
-		destination:   &destination,
-		processor:     processor,
-		streamMode:    destination.Mode == StreamMode,
-		monitorKeeper: monitorKeeper,
-		eventQueue:    eventQueue,
-		queryLogger:   queryLogger,
-		fallBackLoggerFactoryMethod: func() *events.AsyncLogger {
-			return events.NewAsyncLogger(logging.NewRollingWriter(logging.Config{
-				LoggerName:    "errors-" + name,
-				ServerName:    appconfig.Instance.ServerName,
-				FileDir:       logFallbackPath,
-				RotationMin:   logRotationMin,
-				RotateOnClose: true,
-			}), false)
-		},
-		eventsCache: eventsCache,
-	}
-
-	var storageProxy events.StorageProxy
-	switch destination.Type {
-	case RedshiftType:
-		storageProxy = newProxy(createRedshift, storageConfig)
-	case BigQueryType:
-		storageProxy = newProxy(createBigQuery, storageConfig)
-	case PostgresType:
-		storageProxy = newProxy(createPostgres, storageConfig)
-	case ClickHouseType:
-		storageProxy = newProxy(createClickHouse, storageConfig)
-	case S3Type:
-		storageProxy = newProxy(createS3, storageConfig)
-	case SnowflakeType:
-		storageProxy = newProxy(createSnowflake, storageConfig)
-	default:
-		if eventQueue != nil {
-			eventQueue.Close()
+	if err := validateSQLHooks(*destination, name); err != nil {
+		return nil, err
+	}
+	for _, hook := range destination.SQLHooks {
+		destinationsLogger.WithDestination(name).Infof("Configured SQL hook %s", hook)
+	}
+
+	if err := validateViews(*destination, name); err != nil {
+		return nil, err
+	}
+	for _, view := range destination.Views {
+		destinationsLogger.WithDestination(name).Infof("Configured view %s", view)
+	}
+
+	if err := validateUserProfiles(*destination); err != nil {
+		return nil, err
+	}
+	if destination.UserProfiles != nil {
+		destinationsLogger.WithDestination(name).Infof("Configured user profiles %s", destination.UserProfiles)
+	}
+	if err := validateAggregates(*destination); err != nil {
+		return nil, err
+	}
+	if destination.Aggregates != nil {
+		for _, metric := range destination.Aggregates.Metrics {
+			destinationsLogger.WithDestination(name).Infof("Configured aggregates metric %s", metric)
 		}
-		return nil, nil, unknownDestination
 	}
 
-	return storageProxy, eventQueue, nil
+	if deprecations != nil {
+		for _, field := range deprecations.Fields {
+			destinationsLogger.WithDestination(name).Infof("Configured deprecated field %s", field)
+		}
+	}
+
+	if defaults != nil {
+		for _, value := range defaults.Values {
+			destinationsLogger.WithDestination(name).Infof("Configured default value %s", value)
+		}
+	}
+
+	if lateEvents != nil {
+		destinationsLogger.WithDestination(name).Infof("Configured late events: %s", lateEvents)
+	}
+
+	if pkPartitioning != nil {
+		destinationsLogger.WithDestination(name).Infof("Configured primary key partitioning: %s", pkPartitioning)
+	}
+
+	processor, err := schema.NewProcessor(tableName, mapping, mappingFieldType, pkFields, enrichmentRules, filter)
+	if err != nil {
+		return nil, err
+	}
+	if err := processor.SetColumnRenames(columnRenames); err != nil {
+		return nil, err
+	}
+	if err := processor.SetLimits(limits); err != nil {
+		return nil, err
+	}
+	if err := processor.SetDeprecations(deprecations); err != nil {
+		return nil, err
+	}
+	if err := processor.SetDefaults(defaults); err != nil {
+		return nil, err
+	}
+	if err := processor.SetLateEvents(lateEvents); err != nil {
+		return nil, err
+	}
+	if err := processor.SetPkPartitioning(pkPartitioning); err != nil {
+		return nil, err
+	}
+	processor.SetDestinationName(name)
+	processor.SetSampler(sampler)
+	if err := processor.SetFileOrdering(fileOrdering); err != nil {
+		return nil, err
+	}
+	if fileOrdering != nil {
+		destinationsLogger.WithDestination(name).Infof("Configured file ordering: %s", fileOrdering)
+	}
+	if err := processor.SetTypecastPolicy(typecastFailures); err != nil {
+		return nil, err
+	}
+	if typecastFailures == schema.QuarantineTypecastPolicy {
+		destinationsLogger.WithDestination(name).Infof("Configured typecast failures quarantine into %s column", schema.QuarantineColumn)
+	}
+	if metadataColumns != nil {
+		if err := processor.SetMetadataColumns(metadataColumns, appconfig.Instance.ServerName, destination.ConfigVersion); err != nil {
+			return nil, err
+		}
+		destinationsLogger.WithDestination(name).Infof("Configured metadata columns: %s", metadataColumns)
+	}
+	processor.SetParallelParsing(viper.GetInt("server.parallel_parsing.workers"), viper.GetInt("server.parallel_parsing.min_file_size_kb")*1024)
+	if destination.Events == schema.RawEvents {
+		//s3 files keep raw JSON documents, tables keep raw JSON in a column
+		rawColumn := schema.RawColumn
+		if destination.Type == S3Type {
+			rawColumn = ""
+		}
+		processor.SetRaw(rawColumn)
+		if len(mapping) > 0 || len(enrichmentRules) > 0 {
+			destinationsLogger.WithDestination(name).Warnf("receives raw events: mapping and enrichment rules aren't applied")
+		}
+		destinationsLogger.WithDestination(name).Infof("Configured raw events")
+	}
+	processor.SetSkipObserver(func(object map[string]interface{}) {
+		acks.Instance.Skipped(name, events.ExtractEventId(object))
+	})
+	if drift.Instance != nil {
+		processor.SetObserver(func(table *schema.Table, object map[string]interface{}) {
+			drift.Instance.Observe(name, table, object)
+		})
+	}
+
+	retryPolicy, err := newRetryPolicy(name, destination)
+	if err != nil {
+		return nil, err
+	}
+	if destination.Retry != nil {
+		destinationsLogger.WithDestination(name).Infof("Configured retry policy: %s", retryPolicy)
+	}
+
+	return &Config{
+		ctx:              ctx,
+		name:             name,
+		destination:      destination,
+		processor:        processor,
+		streamMode:       destination.Mode == StreamMode,
+		monitorKeeper:    monitorKeeper,
+		schemaMigrations: schemaMigrations,
+		queryLogger:      logging.NewQueryLogger(name, queryWriter),
+		eventsCache:      eventsCache,
+		retryPolicy:      retryPolicy,
+	}, nil
 }
 
 //Create aws Redshift destination
@@ -195,19 +469,29 @@ func createRedshift(config *Config) (events.Storage, error) {
 	//enrich with default parameters
 	if redshiftConfig.Port <= 0 {
 		redshiftConfig.Port = 5439
-		logging.Warnf("[%s] port wasn't provided. Will be used default one: %d", config.name, redshiftConfig.Port)
+		destinationsLogger.WithDestination(config.name).Warnf("port wasn't provided. Will be used default one: %d", redshiftConfig.Port)
 	}
 	if redshiftConfig.Schema == "" {
 		redshiftConfig.Schema = "public"
-		logging.Warnf("[%s] schema wasn't provided. Will be used default one: %s", config.name, redshiftConfig.Schema)
+		destinationsLogger.WithDestination(config.name).Warnf("schema wasn't provided. Will be used default one: %s", redshiftConfig.Schema)
 	}
 	//default connect timeout seconds
 	if _, ok := redshiftConfig.Parameters["connect_timeout"]; !ok {
 		redshiftConfig.Parameters["connect_timeout"] = "600"
 	}
 
+	sqlHooks, err := NewSQLHooks(config.name, redshiftConfig.Schema, config.destination.SQLHooks)
+	if err != nil {
+		return nil, err
+	}
+	views, err := NewManagedViews(config.name, redshiftConfig.Schema, config.destination.Views)
+	if err != nil {
+		return nil, err
+	}
+
 	return NewAwsRedshift(config.ctx, config.name, config.eventQueue, config.destination.S3, redshiftConfig, config.processor,
-		config.destination.BreakOnError, config.streamMode, config.monitorKeeper, config.fallBackLoggerFactoryMethod, config.queryLogger, config.eventsCache)
+		config.destination.BreakOnError, config.streamMode, config.monitorKeeper, config.schemaMigrations, config.fallBackLoggerFactoryMethod, config.queryLogger, config.eventsCache,
+		LoadOptions{SQLHooks: sqlHooks, Views: views})
 }
 
 //Create google BigQuery destination
@@ -224,11 +508,11 @@ func createBigQuery(config *Config) (events.Storage, error) {
 	//enrich with default parameters
 	if gConfig.Dataset == "" {
 		gConfig.Dataset = "default"
-		logging.Warnf("[%s] dataset wasn't provided. Will be used default one: %s", config.name, gConfig.Dataset)
+		destinationsLogger.WithDestination(config.name).Warnf("dataset wasn't provided. Will be used default one: %s", gConfig.Dataset)
 	}
 
 	return NewBigQuery(config.ctx, config.name, config.eventQueue, gConfig, config.processor, config.destination.BreakOnError,
-		config.streamMode, config.monitorKeeper, config.fallBackLoggerFactoryMethod, config.queryLogger, config.eventsCache)
+		config.streamMode, config.monitorKeeper, config.schemaMigrations, config.fallBackLoggerFactoryMethod, config.queryLogger, config.eventsCache)
 }
 
 //Create Postgres destination
@@ -240,19 +524,33 @@ func createPostgres(config *Config) (events.Storage, error) {
 	//enrich with default parameters
 	if pgConfig.Port <= 0 {
 		pgConfig.Port = 5432
-		logging.Warnf("[%s] port wasn't provided. Will be used default one: %d", config.name, pgConfig.Port)
+		destinationsLogger.WithDestination(config.name).Warnf("port wasn't provided. Will be used default one: %d", pgConfig.Port)
 	}
 	if pgConfig.Schema == "" {
 		pgConfig.Schema = "public"
-		logging.Warnf("[%s] schema wasn't provided. Will be used default one: %s", config.name, pgConfig.Schema)
+		destinationsLogger.WithDestination(config.name).Warnf("schema wasn't provided. Will be used default one: %s", pgConfig.Schema)
 	}
 	//default connect timeout seconds
 	if _, ok := pgConfig.Parameters["connect_timeout"]; !ok {
 		pgConfig.Parameters["connect_timeout"] = "600"
 	}
 
+	sqlHooks, err := NewSQLHooks(config.name, pgConfig.Schema, config.destination.SQLHooks)
+	if err != nil {
+		return nil, err
+	}
+	views, err := NewManagedViews(config.name, pgConfig.Schema, config.destination.Views)
+	if err != nil {
+		return nil, err
+	}
+	profiles, err := NewProfiles(config.destination.UserProfiles)
+	if err != nil {
+		return nil, err
+	}
+
 	return NewPostgres(config.ctx, pgConfig, config.processor, config.eventQueue, config.name, config.destination.BreakOnError,
-		config.streamMode, config.monitorKeeper, config.fallBackLoggerFactoryMethod, config.queryLogger, config.eventsCache)
+		config.streamMode, config.monitorKeeper, config.schemaMigrations, config.fallBackLoggerFactoryMethod, config.queryLogger, config.eventsCache,
+		LoadOptions{SQLHooks: sqlHooks, Views: views, Profiles: profiles})
 }
 
 //Create ClickHouse destination
@@ -263,7 +561,7 @@ func createClickHouse(config *Config) (events.Storage, error) {
 	}
 
 	return NewClickHouse(config.ctx, config.name, config.eventQueue, chConfig, config.processor, config.destination.BreakOnError,
-		config.streamMode, config.monitorKeeper, config.fallBackLoggerFactoryMethod, config.queryLogger, config.eventsCache)
+		config.streamMode, config.monitorKeeper, config.schemaMigrations, config.fallBackLoggerFactoryMethod, config.queryLogger, config.eventsCache)
 }
 
 //Create s3 destination
@@ -278,6 +576,15 @@ func createS3(config *Config) (events.Storage, error) {
 	if err := s3Config.Validate(); err != nil {
 		return nil, err
 	}
+	if s3Config.GetFormat() == adapters.S3CsvFormat {
+		if config.destination.Events == schema.RawEvents {
+			return nil, errors.New("S3 csv format isn't supported with raw events: raw events are stored as JSON documents")
+		}
+		destinationsLogger.WithDestination(config.name).Infof("Configured csv format: delimiter [%s], %d header row(s)", s3Config.Csv.GetDelimiter(), s3Config.Csv.HeaderRows())
+	}
+	if s3Config.Manifest {
+		destinationsLogger.WithDestination(config.name).Infof("Configured manifest sidecar files")
+	}
 
 	return NewS3(config.name, s3Config, config.processor, config.destination.BreakOnError, config.fallBackLoggerFactoryMethod,
 		config.eventsCache)
@@ -291,7 +598,7 @@ func createSnowflake(config *Config) (events.Storage, error) {
 	}
 	if snowflakeConfig.Schema == "" {
 		snowflakeConfig.Schema = "PUBLIC"
-		logging.Warnf("[%s] schema wasn't provided. Will be used default one: %s", config.name, snowflakeConfig.Schema)
+		destinationsLogger.WithDestination(config.name).Warnf("schema wasn't provided. Will be used default one: %s", snowflakeConfig.Schema)
 	}
 
 	//default client_session_keep_alive
@@ -311,6 +618,106 @@ func createSnowflake(config *Config) (events.Storage, error) {
 	}
 
 	return NewSnowflake(config.ctx, config.name, config.eventQueue, config.destination.S3, config.destination.Google,
-		snowflakeConfig, config.processor, config.destination.BreakOnError, config.streamMode, config.monitorKeeper,
+		snowflakeConfig, config.processor, config.destination.BreakOnError, config.streamMode, config.monitorKeeper, config.schemaMigrations,
 		config.fallBackLoggerFactoryMethod, config.queryLogger, config.eventsCache)
 }
+
+//Create in-memory destination (dev mode)
+func createMemory(config *Config) (events.Storage, error) {
+	return NewMemory(config.name, config.eventQueue, config.processor, config.destination.BreakOnError, config.streamMode,
+		config.eventsCache), nil
+}
+
+//Create aggregates destination with postgres (datasource config) or redis storage
+func createAggregates(config *Config) (events.Storage, error) {
+	aggregatesConfig := config.destination.Aggregates
+	metrics, err := newAggregateMetrics(aggregatesConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	var backend aggregatesBackend
+	if aggregatesConfig.storage() == AggregatesRedisStorage {
+		backend, err = newAggregatesRedis(config.name, aggregatesConfig.Redis, time.Duration(aggregatesConfig.TtlDays)*24*time.Hour)
+	} else {
+		pgConfig := config.destination.DataSource
+		if err := pgConfig.Validate(); err != nil {
+			return nil, err
+		}
+		if pgConfig.Port <= 0 {
+			pgConfig.Port = 5432
+		}
+		if pgConfig.Schema == "" {
+			pgConfig.Schema = "public"
+		}
+		backend, err = newAggregatesPostgres(config.ctx, config.name, pgConfig, metrics, config.monitorKeeper, config.queryLogger)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return NewAggregates(config.name, config.eventQueue, config.processor, metrics, backend, config.destination.BreakOnError,
+		config.streamMode, config.fallBackLoggerFactoryMethod, config.eventsCache), nil
+}
+
+//validatePartitionGranularity return err if data_layout.partition_granularity doesn't match
+//table name template partition helpers or destination partitioning (ClickHouse PARTITION BY)
+func validatePartitionGranularity(destination DestinationConfig, tableNameTemplate string) error {
+	if destination.DataLayout == nil || destination.DataLayout.PartitionGranularity == "" {
+		return nil
+	}
+
+	granularity, err := schema.GranularityFromString(destination.DataLayout.PartitionGranularity)
+	if err != nil {
+		return err
+	}
+
+	templateGranularities, err := schema.TemplateGranularities(tableNameTemplate)
+	if err != nil {
+		return err
+	}
+	for _, templateGranularity := range templateGranularities {
+		if templateGranularity != granularity {
+			return fmt.Errorf("table_name_template [%s] uses [%s] partitions but partition_granularity is [%s]", tableNameTemplate, templateGranularity, granularity)
+		}
+	}
+
+	if destination.Type == ClickHouseType && destination.ClickHouse != nil {
+		if engineGranularity, ok := destination.ClickHouse.Engine.PartitionGranularity(); ok && engineGranularity != granularity {
+			return fmt.Errorf("ClickHouse engine partitions by [%s] but partition_granularity is [%s]", engineGranularity, granularity)
+		}
+	}
+
+	return nil
+}
+
+//validateSchemaMigrations return err if data_layout.schema_migrations is unknown or review mode isn't supported by the destination
+func validateSchemaMigrations(destinationType, schemaMigrations string) error {
+	switch schemaMigrations {
+	case AutoMigrations:
+		return nil
+	case ReviewMigrations:
+		switch destinationType {
+		case PostgresType, RedshiftType, BigQueryType, SnowflakeType:
+			return nil
+		default:
+			return fmt.Errorf("schema_migrations [%s] isn't supported by %s destination", ReviewMigrations, destinationType)
+		}
+	default:
+		return fmt.Errorf("Unknown schema_migrations: %s. Available: [%s, %s]", schemaMigrations, AutoMigrations, ReviewMigrations)
+	}
+}
+
+//recoverQueue add not committed events of the stream queue into the recovery report: they will be stored by the stream
+//worker. Damaged parts of the queue can't be stored: they are kept in the queue quarantine dir
+func recoverQueue(destinationId, queueName string, queue *events.PersistentQueue) {
+	corruptedParts, quarantinedSegments, details := queue.Recovered()
+	if corruptedParts > 0 || quarantinedSegments > 0 {
+		recovery.Instance.Add(&recovery.Batch{DestinationId: destinationId, Type: recovery.StreamQueueBatch, Name: queueName,
+			Resolution: recovery.DeadLettered, Details: details})
+	}
+	if sizeBytes := queue.SizeBytes(); sizeBytes > 0 {
+		recovery.Instance.Add(&recovery.Batch{DestinationId: destinationId, Type: recovery.StreamQueueBatch, Name: queueName,
+			Resolution: recovery.Resumed, Details: fmt.Sprintf("%d bytes of not committed events", sizeBytes)})
+	}
+}
@@ -0,0 +1,256 @@
+package storages
+
+import (
+	"encoding/json"
+	"github.com/jitsucom/eventnative/caching"
+	"github.com/jitsucom/eventnative/counters"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/parsers"
+	"github.com/jitsucom/eventnative/schema"
+	"github.com/jitsucom/eventnative/typing"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	memoryRowsLimit   = 100
+	memoryTracesLimit = 200
+)
+
+var schemaToMemory = map[typing.DataType]string{
+	typing.STRING:    typing.STRING.String(),
+	typing.INT64:     typing.INT64.String(),
+	typing.FLOAT64:   typing.FLOAT64.String(),
+	typing.TIMESTAMP: typing.TIMESTAMP.String(),
+	typing.DECIMAL:   typing.DECIMAL.String(),
+	typing.BOOL:      typing.BOOL.String(),
+	typing.DATE:      typing.DATE.String(),
+	typing.UUID:      typing.UUID.String(),
+	typing.UNKNOWN:   typing.STRING.String(),
+}
+
+//MemoryTable is a received table: merged columns types, rows count and the last rows
+type MemoryTable struct {
+	Name      string            `json:"name"`
+	Columns   map[string]string `json:"columns"`
+	Total     int               `json:"total"`
+	Rows      []events.Fact     `json:"rows"`
+	UpdatedAt time.Time         `json:"updated_at"`
+
+	types map[string]typing.DataType
+}
+
+//Trace is a processing result of one event: stored row with table name or error with raw event
+type Trace struct {
+	Time    time.Time       `json:"time"`
+	EventId string          `json:"event_id,omitempty"`
+	Table   string          `json:"table,omitempty"`
+	Row     events.Fact     `json:"row,omitempty"`
+	Event   json.RawMessage `json:"event,omitempty"`
+	Error   string          `json:"error,omitempty"`
+}
+
+//Memory keeps received tables with the last rows and processing traces in memory
+//It is used in dev mode (--dev flag) for verifying tracking locally without any warehouse (see /dev page)
+type Memory struct {
+	sync.RWMutex
+
+	name            string
+	schemaProcessor *schema.Processor
+	streamingWorker *StreamingWorker
+	eventsCache     *caching.EventsCache
+	breakOnError    bool
+
+	tables map[string]*MemoryTable
+	traces []*Trace
+}
+
+func NewMemory(name string, eventQueue *events.PersistentQueue, processor *schema.Processor, breakOnError, streamMode bool,
+	eventsCache *caching.EventsCache) *Memory {
+	m := &Memory{
+		name:            name,
+		schemaProcessor: processor,
+		eventsCache:     eventsCache,
+		breakOnError:    breakOnError,
+		tables:          map[string]*MemoryTable{},
+	}
+
+	if streamMode {
+		m.streamingWorker = newStreamingWorker(eventQueue, processor, m, eventsCache)
+		m.streamingWorker.start()
+	}
+
+	return m
+}
+
+//Store call StoreWithParseFunc with parsers.ParseJson func
+func (m *Memory) Store(fileName string, payload []byte) (int, error) {
+	return m.StoreWithParseFunc(fileName, payload, parsers.ParseJson)
+}
+
+//StoreWithParseFunc process file payload and keep rows in memory
+func (m *Memory) StoreWithParseFunc(fileName string, payload []byte, parseFunc func([]byte) (map[string]interface{}, error)) (int, error) {
+	flatData, failedEvents, err := m.schemaProcessor.ProcessFilePayload(fileName, payload, m.breakOnError, parseFunc)
+	if err != nil {
+		return linesCount(payload), err
+	}
+
+	rowsCount := m.store(flatData)
+
+	m.Fallback(failedEvents...)
+	counters.ErrorEvents(m.Name(), len(failedEvents))
+	for _, failedFact := range failedEvents {
+		m.eventsCache.Error(m.Name(), failedFact.EventId, failedFact.Error)
+	}
+
+	return rowsCount, nil
+}
+
+//SyncStore process objects and keep rows in memory
+func (m *Memory) SyncStore(objects []map[string]interface{}) (int, error) {
+	flatData, err := m.schemaProcessor.ProcessObjects(objects)
+	if err != nil {
+		return len(objects), err
+	}
+
+	return m.store(flatData), nil
+}
+
+//Insert keep the row in memory
+func (m *Memory) Insert(dataSchema *schema.Table, fact events.Fact) error {
+	m.add(dataSchema, fact)
+	return nil
+}
+
+//Fallback keep failed events as error traces (there is no fallback file in dev mode)
+func (m *Memory) Fallback(failedFacts ...*events.FailedFact) {
+	for _, failedFact := range failedFacts {
+		destinationsLogger.WithDestination(m.Name()).Warnf("Event %s wasn't processed: %s", string(failedFact.Event), failedFact.Error)
+
+		trace := &Trace{Time: time.Now().UTC(), EventId: failedFact.EventId, Error: failedFact.Error}
+		if json.Valid(failedFact.Event) {
+			trace.Event = json.RawMessage(failedFact.Event)
+		}
+
+		m.Lock()
+		m.trace(trace)
+		m.Unlock()
+	}
+}
+
+//Tables return copies of received tables sorted by name
+func (m *Memory) Tables() []*MemoryTable {
+	m.RLock()
+	defer m.RUnlock()
+
+	tables := make([]*MemoryTable, 0, len(m.tables))
+	for _, table := range m.tables {
+		columns := make(map[string]string, len(table.Columns))
+		for name, columnType := range table.Columns {
+			columns[name] = columnType
+		}
+		tables = append(tables, &MemoryTable{
+			Name:      table.Name,
+			Columns:   columns,
+			Total:     table.Total,
+			Rows:      append([]events.Fact{}, table.Rows...),
+			UpdatedAt: table.UpdatedAt,
+		})
+	}
+
+	sort.Slice(tables, func(i, j int) bool { return tables[i].Name < tables[j].Name })
+	return tables
+}
+
+//Traces return processing traces (the newest first)
+func (m *Memory) Traces() []*Trace {
+	m.RLock()
+	defer m.RUnlock()
+
+	traces := make([]*Trace, 0, len(m.traces))
+	for i := len(m.traces) - 1; i >= 0; i-- {
+		traces = append(traces, m.traces[i])
+	}
+	return traces
+}
+
+//Clear remove all tables and traces
+func (m *Memory) Clear() {
+	m.Lock()
+	defer m.Unlock()
+
+	m.tables = map[string]*MemoryTable{}
+	m.traces = nil
+}
+
+func (m *Memory) store(flatData map[string]*schema.ProcessedFile) int {
+	rowsCount := 0
+	for _, fdata := range flatData {
+		for _, object := range fdata.GetPayload() {
+			m.add(fdata.DataSchema, object)
+			m.eventsCache.Succeed(m.Name(), events.ExtractEventId(object), object, fdata.DataSchema, m.ColumnTypesMapping())
+		}
+		rowsCount += fdata.GetPayloadLen()
+	}
+	return rowsCount
+}
+
+//add merge table columns types (like a warehouse column is widened to the common type) and keep the last rows
+func (m *Memory) add(dataSchema *schema.Table, fact events.Fact) {
+	destinationsLogger.WithDestination(m.Name()).Infof("Table [%s] row: %s", dataSchema.Name, fact.Serialize())
+
+	m.Lock()
+	defer m.Unlock()
+
+	table, ok := m.tables[dataSchema.Name]
+	if !ok {
+		table = &MemoryTable{Name: dataSchema.Name, Columns: map[string]string{}, types: map[string]typing.DataType{}}
+		m.tables[dataSchema.Name] = table
+	}
+
+	for name, column := range dataSchema.Columns {
+		columnType := column.GetType()
+		if existing, ok := table.types[name]; ok {
+			columnType = typing.GetCommonAncestorType(existing, columnType)
+		}
+		table.types[name] = columnType
+		table.Columns[name] = schemaToMemory[columnType]
+	}
+
+	table.Total++
+	table.Rows = append(table.Rows, fact)
+	if len(table.Rows) > memoryRowsLimit {
+		table.Rows = table.Rows[len(table.Rows)-memoryRowsLimit:]
+	}
+	table.UpdatedAt = time.Now().UTC()
+
+	m.trace(&Trace{Time: table.UpdatedAt, EventId: events.ExtractEventId(fact), Table: dataSchema.Name, Row: fact})
+}
+
+//trace must be called under lock
+func (m *Memory) trace(trace *Trace) {
+	m.traces = append(m.traces, trace)
+	if len(m.traces) > memoryTracesLimit {
+		m.traces = m.traces[len(m.traces)-memoryTracesLimit:]
+	}
+}
+
+func (m *Memory) ColumnTypesMapping() map[typing.DataType]string {
+	return schemaToMemory
+}
+
+func (m *Memory) Name() string {
+	return m.name
+}
+
+func (m *Memory) Type() string {
+	return MemoryType
+}
+
+func (m *Memory) Close() error {
+	if m.streamingWorker != nil {
+		m.streamingWorker.Close()
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package storages
+
+import (
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/schema"
+	"github.com/jitsucom/eventnative/typing"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestMemoryInsert(t *testing.T) {
+	memory := NewMemory("test", nil, nil, false, false, nil)
+
+	require.NoError(t, memory.Insert(&schema.Table{Name: "events", Columns: schema.Columns{
+		"field1": schema.NewColumn(typing.INT64),
+		"field2": schema.NewColumn(typing.STRING),
+	}}, events.Fact{"field1": 1, "field2": "a"}))
+	require.NoError(t, memory.Insert(&schema.Table{Name: "events", Columns: schema.Columns{
+		"field1": schema.NewColumn(typing.FLOAT64),
+		"field3": schema.NewColumn(typing.TIMESTAMP),
+	}}, events.Fact{"field1": 1.5, "field3": "2020-01-01T00:00:00Z"}))
+	require.NoError(t, memory.Insert(&schema.Table{Name: "clicks", Columns: schema.Columns{
+		"field1": schema.NewColumn(typing.STRING),
+	}}, events.Fact{"field1": "b"}))
+
+	tables := memory.Tables()
+	require.Equal(t, 2, len(tables))
+	require.Equal(t, "clicks", tables[0].Name)
+	require.Equal(t, "events", tables[1].Name)
+	require.Equal(t, map[string]string{"field1": "FLOAT64", "field2": "STRING", "field3": "TIMESTAMP"}, tables[1].Columns)
+	require.Equal(t, 2, tables[1].Total)
+	require.Equal(t, []events.Fact{{"field1": 1, "field2": "a"}, {"field1": 1.5, "field3": "2020-01-01T00:00:00Z"}}, tables[1].Rows)
+
+	traces := memory.Traces()
+	require.Equal(t, 3, len(traces))
+	require.Equal(t, "clicks", traces[0].Table)
+
+	memory.Fallback(&events.FailedFact{Event: []byte(`{"field1":"c"}`), Error: "error", EventId: "1"})
+	traces = memory.Traces()
+	require.Equal(t, 4, len(traces))
+	require.Equal(t, "error", traces[0].Error)
+	require.Equal(t, `{"field1":"c"}`, string(traces[0].Event))
+
+	memory.Clear()
+	require.Empty(t, memory.Tables())
+	require.Empty(t, memory.Traces())
+}
+
+func TestMemoryRowsLimit(t *testing.T) {
+	memory := NewMemory("test", nil, nil, false, false, nil)
+	dataSchema := &schema.Table{Name: "events", Columns: schema.Columns{"id": schema.NewColumn(typing.INT64)}}
+	for i := 0; i < memoryRowsLimit+10; i++ {
+		require.NoError(t, memory.Insert(dataSchema, events.Fact{"id": i}))
+	}
+
+	tables := memory.Tables()
+	require.Equal(t, memoryRowsLimit+10, tables[0].Total)
+	require.Equal(t, memoryRowsLimit, len(tables[0].Rows))
+	require.Equal(t, events.Fact{"id": 10}, tables[0].Rows[0])
+}
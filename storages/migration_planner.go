@@ -0,0 +1,153 @@
+package storages
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/adapters"
+	"github.com/jitsucom/eventnative/notifications"
+	"github.com/jitsucom/eventnative/schema"
+	"sort"
+)
+
+const (
+	//new columns and type widenings are applied automatically
+	AutoMigrations = "auto"
+	//migration plans are waiting for review and applying via admin API: events of the table are failed until that
+	ReviewMigrations = "review"
+)
+
+//MigrationPlanner is implemented by storages which keep tables schema in review migrations mode
+type MigrationPlanner interface {
+	MigrationPlans() []*schema.MigrationPlan
+	ApplyMigrationPlan(table string) error
+}
+
+//migrate apply migration plan (auto mode) or save it for review (review mode)
+//must be called under the table lock
+func (th *TableHelper) migrate(plan *schema.MigrationPlan) error {
+	if th.migrationsMode == ReviewMigrations {
+		th.plan(plan)
+		return fmt.Errorf("Table [%s] schema migration is waiting for review: %d steps", plan.Table, len(plan.Steps))
+	}
+
+	if migrator, ok := th.manager.(adapters.Migrator); ok {
+		return migrator.ApplyMigration(plan)
+	}
+
+	if widenings := plan.Widenings(); len(widenings) > 0 {
+		return fmt.Errorf("Unsupported column [%s] type changing from: %s to: %s", widenings[0].Column, widenings[0].From.String(), widenings[0].To.String())
+	}
+	return th.manager.PatchTableSchema(plan.AddedColumns())
+}
+
+//plan save migration plan with DDL statements for review. Steps of the previous table plan are kept
+func (th *TableHelper) plan(plan *schema.MigrationPlan) {
+	th.plansMutex.Lock()
+	defer th.plansMutex.Unlock()
+
+	if previous, ok := th.plans[plan.Table]; ok {
+		plan = merge(previous, plan)
+	}
+	if migrator, ok := th.manager.(adapters.Migrator); ok {
+		statements, err := migrator.MigrationStatements(plan)
+		if err != nil {
+			plan.Error = err.Error()
+		}
+		plan.Statements = statements
+	}
+
+	if _, ok := th.plans[plan.Table]; !ok {
+		destinationsLogger.Warnf("[%s] table [%s] schema migration is waiting for review", th.storageType, plan.Table)
+	}
+	th.plans[plan.Table] = plan
+}
+
+//MigrationPlans return migration plans which are waiting for review sorted by table name
+func (th *TableHelper) MigrationPlans() []*schema.MigrationPlan {
+	th.plansMutex.RLock()
+	defer th.plansMutex.RUnlock()
+
+	plans := make([]*schema.MigrationPlan, 0, len(th.plans))
+	for _, plan := range th.plans {
+		planCopy := *plan
+		plans = append(plans, &planCopy)
+	}
+	sort.Slice(plans, func(i, j int) bool {
+		return plans[i].Table < plans[j].Table
+	})
+	return plans
+}
+
+//ApplyMigrationPlan apply reviewed plan steps which haven't been applied to the live table yet and increment the table version:
+//cached table schemas are re-read on the next EnsureTable call. It doesn't use the cache (can be called from another goroutine)
+func (th *TableHelper) ApplyMigrationPlan(destinationName, table string) error {
+	th.plansMutex.RLock()
+	plan, ok := th.plans[table]
+	th.plansMutex.RUnlock()
+	if !ok {
+		return fmt.Errorf("Table [%s] doesn't have migration plan", table)
+	}
+
+	lock, err := th.monitorKeeper.Lock(destinationName, table)
+	if err != nil {
+		msg := fmt.Sprintf("System error: Unable to lock table %s in %s: %v", table, th.storageType, err)
+		notifications.SystemError(msg)
+		return errors.New(msg)
+	}
+	defer th.monitorKeeper.Unlock(lock)
+
+	liveSchema, err := th.manager.GetTableSchema(table)
+	if err != nil {
+		return fmt.Errorf("Error getting table %s schema from %s: %v", table, th.storageType, err)
+	}
+
+	pending := plan.Pending(liveSchema)
+	if pending.Exists() {
+		if migrator, ok := th.manager.(adapters.Migrator); ok {
+			err = migrator.ApplyMigration(pending)
+		} else if len(pending.Widenings()) > 0 {
+			err = fmt.Errorf("%s doesn't support column type changing", th.storageType)
+		} else {
+			err = th.manager.PatchTableSchema(pending.AddedColumns())
+		}
+		if err != nil {
+			return err
+		}
+
+		if _, err := th.monitorKeeper.IncrementVersion(destinationName, table); err != nil {
+			return fmt.Errorf("Error incrementing version in storage [%s]: %v", th.storageType, err)
+		}
+	}
+
+	th.plansMutex.Lock()
+	delete(th.plans, table)
+	th.plansMutex.Unlock()
+
+	destinationsLogger.WithDestination(destinationName).Infof("Table [%s] schema migration has been applied: %d steps", table, len(pending.Steps))
+	return nil
+}
+
+//merge return plan with steps of the both plans: the last step of the column wins
+func merge(previous, next *schema.MigrationPlan) *schema.MigrationPlan {
+	steps := map[string]*schema.MigrationStep{}
+	for _, step := range previous.Steps {
+		steps[step.Column] = step
+	}
+	for _, step := range next.Steps {
+		if previousStep, ok := steps[step.Column]; ok && previousStep.Kind == schema.AddColumnStep {
+			//column doesn't exist yet: it is added with the widened type
+			steps[step.Column] = &schema.MigrationStep{Kind: schema.AddColumnStep, Column: step.Column, To: step.To, Decimal: step.Decimal}
+			continue
+		}
+		steps[step.Column] = step
+	}
+
+	merged := &schema.MigrationPlan{Table: next.Table, PlannedAt: previous.PlannedAt}
+	for _, step := range steps {
+		merged.Steps = append(merged.Steps, step)
+	}
+	sort.Slice(merged.Steps, func(i, j int) bool {
+		return merged.Steps[i].Column < merged.Steps[j].Column
+	})
+	return merged
+}
@@ -2,7 +2,6 @@ package storages
 
 import (
 	"context"
-	"github.com/jitsucom/eventnative/logging"
 	"io"
 )
 
@@ -54,7 +53,7 @@ func (rl *RetryableLock) Unlock() {
 func (rl *RetryableLock) unlockWithRetry(retry int) {
 	if err := rl.unlock(); err != nil {
 		if retry == rl.retryCount {
-			logging.SystemErrorf("Unable to unlock [%s] after %d tries: %v", rl.identifier, retry, err)
+			destinationsLogger.SystemErrorf("Unable to unlock [%s] after %d tries: %v", rl.identifier, retry, err)
 		} else {
 			rl.unlockWithRetry(retry + 1)
 		}
@@ -70,7 +69,7 @@ func (rl *RetryableLock) unlock() error {
 
 	if rl.resourceCloser != nil {
 		if closeError := rl.resourceCloser.Close(); closeError != nil {
-			logging.Errorf("%s unlocked successfully but failed to close resource: %v", rl.identifier, closeError)
+			destinationsLogger.Errorf("%s unlocked successfully but failed to close resource: %v", rl.identifier, closeError)
 		}
 	}
 
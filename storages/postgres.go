@@ -12,6 +12,7 @@ import (
 	"github.com/jitsucom/eventnative/logging"
 	"github.com/jitsucom/eventnative/schema"
 	"github.com/jitsucom/eventnative/typing"
+	"time"
 )
 
 //Store files to Postgres in two modes:
@@ -21,16 +22,20 @@ type Postgres struct {
 	name            string
 	adapter         *adapters.Postgres
 	tableHelper     *TableHelper
+	columnTypes     *ColumnTypesRegistry
 	schemaProcessor *schema.Processor
 	streamingWorker *StreamingWorker
 	fallbackLogger  *events.AsyncLogger
 	eventsCache     *caching.EventsCache
+	sqlHooks        *SQLHooks
+	views           *ManagedViews
+	profiles        *Profiles
 	breakOnError    bool
 }
 
 func NewPostgres(ctx context.Context, config *adapters.DataSourceConfig, processor *schema.Processor, eventQueue *events.PersistentQueue,
-	storageName string, breakOnError, streamMode bool, monitorKeeper MonitorKeeper, fallbackLoggerFactoryMethod func() *events.AsyncLogger,
-	queryLogger *logging.QueryLogger, eventsCache *caching.EventsCache) (*Postgres, error) {
+	storageName string, breakOnError, streamMode bool, monitorKeeper MonitorKeeper, schemaMigrations string, fallbackLoggerFactoryMethod func() *events.AsyncLogger,
+	queryLogger *logging.QueryLogger, eventsCache *caching.EventsCache, loadOptions LoadOptions) (*Postgres, error) {
 
 	adapter, err := adapters.NewPostgres(ctx, config, queryLogger)
 	if err != nil {
@@ -44,15 +49,20 @@ func NewPostgres(ctx context.Context, config *adapters.DataSourceConfig, process
 		return nil, err
 	}
 
-	tableHelper := NewTableHelper(adapter, monitorKeeper, PostgresType)
+	columnTypes := NewColumnTypesRegistry()
+	tableHelper := NewTableHelper(adapter, monitorKeeper, columnTypes, PostgresType, processor.Limits(), schemaMigrations)
 
 	p := &Postgres{
 		name:            storageName,
 		adapter:         adapter,
 		tableHelper:     tableHelper,
+		columnTypes:     columnTypes,
 		schemaProcessor: processor,
 		fallbackLogger:  fallbackLoggerFactoryMethod(),
 		eventsCache:     eventsCache,
+		sqlHooks:        loadOptions.SQLHooks,
+		views:           loadOptions.Views,
+		profiles:        loadOptions.Profiles,
 		breakOnError:    breakOnError,
 	}
 
@@ -77,6 +87,7 @@ func (p *Postgres) StoreWithParseFunc(fileName string, payload []byte, parseFunc
 	if err != nil {
 		return linesCount(payload), err
 	}
+	failedEvents = append(failedEvents, p.tableHelper.GuardColumns(p.Name(), flatData)...)
 
 	rowsCount, err := p.store(flatData)
 
@@ -113,6 +124,10 @@ func (p *Postgres) SyncStore(objects []map[string]interface{}) (int, error) {
 
 //Insert fact in Postgres
 func (p *Postgres) Insert(dataSchema *schema.Table, fact events.Fact) (err error) {
+	if ok, err := p.tableHelper.GuardObjectColumns(p.Name(), dataSchema, fact); !ok {
+		return err
+	}
+
 	dbSchema, err := p.tableHelper.EnsureTable(p.Name(), dataSchema)
 	if err != nil {
 		return err
@@ -125,10 +140,70 @@ func (p *Postgres) Insert(dataSchema *schema.Table, fact events.Fact) (err error
 	return p.adapter.Insert(dataSchema, fact)
 }
 
+//BackfillColumn ensure the to column and copy the next batch of the from column values into it
+func (p *Postgres) BackfillColumn(tableName, from, to string, batchSize int) (int64, error) {
+	dbSchema, err := p.adapter.GetTableSchema(tableName)
+	if err != nil {
+		return 0, err
+	}
+	if !dbSchema.Exists() {
+		return 0, fmt.Errorf("Table [%s] doesn't exist", tableName)
+	}
+	fromColumn, ok := dbSchema.Columns[from]
+	if !ok {
+		return 0, fmt.Errorf("Column [%s] doesn't exist in [%s] table", from, tableName)
+	}
+
+	if _, ok := dbSchema.Columns[to]; !ok {
+		patchSchema := &schema.Table{Name: tableName, Columns: schema.Columns{to: schema.NewColumn(fromColumn.GetType())}}
+		if err := p.tableHelper.AddColumns(p.Name(), patchSchema); err != nil {
+			return 0, err
+		}
+		dbSchema.Columns[to] = schema.NewColumn(fromColumn.GetType())
+	}
+
+	return p.adapter.BackfillColumn(dbSchema, from, to, batchSize)
+}
+
+//Annotate apply the correction to the event rows and record it in the annotations table (see Annotator)
+func (p *Postgres) Annotate(annotation *Annotation) (int64, error) {
+	return annotate(p.Name(), p.adapter, p.tableHelper, annotation)
+}
+
+//CountDeletion return count of rows which match the bulk delete filter (see BulkDeleter)
+func (p *Postgres) CountDeletion(filter *DeletionFilter) (int64, error) {
+	return countDeletion(p.adapter, filter)
+}
+
+//Delete delete rows which match the bulk delete filter in batches (see BulkDeleter)
+func (p *Postgres) Delete(filter *DeletionFilter, batchSize int, pause time.Duration) (int64, error) {
+	return deleteByFilter(p.Name(), p.adapter, filter, batchSize, pause)
+}
+
+//DeleteExpired delete rows of all tables which match the retention condition (see Retainer)
+func (p *Postgres) DeleteExpired(condition *adapters.ExpirationCondition, archive func(table string, rows []map[string]interface{}) error) (int64, error) {
+	return deleteExpired(p.Name(), p.adapter, condition, archive)
+}
+
 func (p *Postgres) ColumnTypesMapping() map[typing.DataType]string {
 	return adapters.SchemaToPostgres
 }
 
+//ColumnTypesReport return discovered column types vs actual db column types per table
+func (p *Postgres) ColumnTypesReport() []*TableTypesReport {
+	return p.columnTypes.Report(p.ColumnTypesMapping())
+}
+
+//MigrationPlans return table migration plans which are waiting for review
+func (p *Postgres) MigrationPlans() []*schema.MigrationPlan {
+	return p.tableHelper.MigrationPlans()
+}
+
+//ApplyMigrationPlan apply reviewed table migration plan
+func (p *Postgres) ApplyMigrationPlan(table string) error {
+	return p.tableHelper.ApplyMigrationPlan(p.Name(), table)
+}
+
 func (p *Postgres) store(flatData map[string]*schema.ProcessedFile) (rowsCount int, err error) {
 	for _, fdata := range flatData {
 		rowsCount += fdata.GetPayloadLen()
@@ -159,22 +234,72 @@ func (p *Postgres) store(flatData map[string]*schema.ProcessedFile) (rowsCount i
 		}
 	}
 
+	//identify events are folded into the user profiles table file (it isn't a part of flatData: rows aren't events)
+	profilesData := p.profiles.Fold(flatData)
+	if profilesData != nil {
+		dbSchema, err := p.tableHelper.EnsureTable(p.Name(), profilesData.DataSchema)
+		if err != nil {
+			return rowsCount, err
+		}
+
+		if err := p.schemaProcessor.ApplyDBTyping(dbSchema, profilesData); err != nil {
+			return rowsCount, err
+		}
+	}
+
+	for _, fdata := range flatData {
+		p.sqlHooks.Pre(fdata.DataSchema.Name, false, p.adapter.Exec)
+	}
+
 	//insert all data in one transaction
 	tx, err := p.adapter.OpenTx()
 	if err != nil {
 		return rowsCount, fmt.Errorf("Error opening postgres transaction: %v", err)
 	}
+	txExec := func(statement string) error {
+		return p.adapter.ExecInTransaction(tx, statement)
+	}
 
 	for _, fdata := range flatData {
+		if err := p.sqlHooks.Pre(fdata.DataSchema.Name, true, txExec); err != nil {
+			tx.Rollback()
+			return rowsCount, err
+		}
 		for _, object := range fdata.GetPayload() {
 			if err := p.adapter.InsertInTransaction(tx, fdata.DataSchema, object); err != nil {
 				tx.Rollback()
 				return rowsCount, err
 			}
 		}
+		if err := p.sqlHooks.Post(fdata.DataSchema.Name, true, txExec); err != nil {
+			tx.Rollback()
+			return rowsCount, err
+		}
 	}
+	if profilesData != nil {
+		for _, profile := range profilesData.GetPayload() {
+			if err := p.adapter.UpsertProfileInTransaction(tx, profilesData.DataSchema, profile, FirstSeenColumn, LastSeenColumn); err != nil {
+				tx.Rollback()
+				return rowsCount, err
+			}
+		}
+	}
+
+	if err := tx.DirectCommit(); err != nil {
+		return rowsCount, err
+	}
+
+	for _, fdata := range flatData {
+		p.sqlHooks.Post(fdata.DataSchema.Name, false, p.adapter.Exec)
+		p.views.AfterLoad(fdata.DataSchema.Name, p.adapter.Exec)
+	}
+
+	return rowsCount, nil
+}
 
-	return rowsCount, tx.DirectCommit()
+//Ping check Postgres connection
+func (p *Postgres) Ping() error {
+	return p.adapter.Ping()
 }
 
 //Close adapters.Postgres
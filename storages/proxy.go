@@ -2,7 +2,6 @@ package storages
 
 import (
 	"github.com/jitsucom/eventnative/events"
-	"github.com/jitsucom/eventnative/logging"
 	"github.com/jitsucom/eventnative/safego"
 	"sync"
 	"time"
@@ -33,7 +32,7 @@ func (rsp *RetryableProxy) start() {
 
 			storage, err := rsp.factoryMethod(rsp.config)
 			if err != nil {
-				logging.Errorf("[%s] Error initializing destination of type %s: %v. Retry after 1 minute", rsp.config.name, rsp.config.destination.Type, err)
+				destinationsLogger.WithDestination(rsp.config.name).Errorf("Error initializing destination of type %s: %v. Retry after 1 minute", rsp.config.destination.Type, err)
 				time.Sleep(1 * time.Minute)
 				continue
 			}
@@ -43,7 +42,7 @@ func (rsp *RetryableProxy) start() {
 			rsp.ready = true
 			rsp.Unlock()
 
-			logging.Infof("[%s] destination has been initialized!", rsp.config.name)
+			destinationsLogger.WithDestination(rsp.config.name).Infof("destination has been initialized!")
 
 			break
 		}
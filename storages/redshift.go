@@ -10,6 +10,8 @@ import (
 	"github.com/jitsucom/eventnative/caching"
 	"github.com/jitsucom/eventnative/counters"
 	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/health"
+	"github.com/jitsucom/eventnative/ledger"
 	"github.com/jitsucom/eventnative/logging"
 	"github.com/jitsucom/eventnative/metrics"
 	"github.com/jitsucom/eventnative/parsers"
@@ -30,10 +32,13 @@ type AwsRedshift struct {
 	s3Adapter       *adapters.S3
 	redshiftAdapter *adapters.AwsRedshift
 	tableHelper     *TableHelper
+	columnTypes     *ColumnTypesRegistry
 	schemaProcessor *schema.Processor
 	streamingWorker *StreamingWorker
 	fallbackLogger  *events.AsyncLogger
 	eventsCache     *caching.EventsCache
+	sqlHooks        *SQLHooks
+	views           *ManagedViews
 	breakOnError    bool
 
 	closed bool
@@ -41,8 +46,8 @@ type AwsRedshift struct {
 
 //NewAwsRedshift return AwsRedshift and start goroutine for aws redshift batch storage or for stream consumer depend on destination mode
 func NewAwsRedshift(ctx context.Context, name string, eventQueue *events.PersistentQueue, s3Config *adapters.S3Config, redshiftConfig *adapters.DataSourceConfig,
-	processor *schema.Processor, breakOnError, streamMode bool, monitorKeeper MonitorKeeper, fallbackLoggerFactoryMethod func() *events.AsyncLogger,
-    queryLogger *logging.QueryLogger, eventsCache *caching.EventsCache) (*AwsRedshift, error) {
+	processor *schema.Processor, breakOnError, streamMode bool, monitorKeeper MonitorKeeper, schemaMigrations string, fallbackLoggerFactoryMethod func() *events.AsyncLogger,
+    queryLogger *logging.QueryLogger, eventsCache *caching.EventsCache, loadOptions LoadOptions) (*AwsRedshift, error) {
 	var s3Adapter *adapters.S3
 	if !streamMode {
 		var err error
@@ -64,16 +69,20 @@ func NewAwsRedshift(ctx context.Context, name string, eventQueue *events.Persist
 		return nil, err
 	}
 
-	tableHelper := NewTableHelper(redshiftAdapter, monitorKeeper, RedshiftType)
+	columnTypes := NewColumnTypesRegistry()
+	tableHelper := NewTableHelper(redshiftAdapter, monitorKeeper, columnTypes, RedshiftType, processor.Limits(), schemaMigrations)
 
 	ar := &AwsRedshift{
 		name:            name,
 		s3Adapter:       s3Adapter,
 		redshiftAdapter: redshiftAdapter,
 		tableHelper:     tableHelper,
+		columnTypes:     columnTypes,
 		schemaProcessor: processor,
 		fallbackLogger:  fallbackLoggerFactoryMethod(),
 		eventsCache:     eventsCache,
+		sqlHooks:        loadOptions.SQLHooks,
+		views:           loadOptions.Views,
 		breakOnError:    breakOnError,
 	}
 
@@ -102,7 +111,7 @@ func (ar *AwsRedshift) startBatch() {
 
 			filesKeys, err := ar.s3Adapter.ListBucket(appconfig.Instance.ServerName)
 			if err != nil {
-				logging.Errorf("[%s] Error reading files from s3: %v", ar.Name(), err)
+				destinationsLogger.WithDestination(ar.Name()).Errorf("Error reading files from s3: %v", err)
 				continue
 			}
 
@@ -113,34 +122,62 @@ func (ar *AwsRedshift) startBatch() {
 			for _, fileKey := range filesKeys {
 				tableName, tokenId, rowsCount, err := extractDataFromFileName(fileKey)
 				if err != nil {
-					logging.Errorf("[%s] S3 file [%s] has wrong format: %v", ar.Name(), fileKey, err)
+					destinationsLogger.WithDestination(ar.Name()).Errorf("S3 file [%s] has wrong format: %v", fileKey, err)
 					continue
 				}
 
+				//copy has failed max attempts before the restart: the file is kept in the s3 for manual handling
+				if ledger.Instance.IsDeadLettered(ar.Name(), fileKey) {
+					continue
+				}
+
+				//copy has been confirmed but the file wasn't deleted (e.g. crash or delete error)
+				if ledger.Instance.IsLoaded(ar.Name(), fileKey) {
+					destinationsLogger.WithDestination(ar.Name()).Warnf("file %s has already been copied to redshift. It will be deleted from s3", fileKey)
+					if err := ar.s3Adapter.DeleteObject(fileKey); err != nil {
+						destinationsLogger.WithDestination(ar.Name()).Errorf("Error deleting already copied file %s from s3: %v", fileKey, err)
+					}
+					continue
+				}
+
+				ar.sqlHooks.Pre(tableName, false, ar.redshiftAdapter.Exec)
+
 				wrappedTx, err := ar.redshiftAdapter.OpenTx()
 				if err != nil {
-					logging.Errorf("[%s] Error creating redshift transaction: %v", ar.Name(), err)
+					destinationsLogger.WithDestination(ar.Name()).Errorf("Error creating redshift transaction: %v", err)
 					metrics.ErrorTokenEvents(tokenId, ar.Name(), rowsCount)
 					continue
 				}
 
-				if err := ar.redshiftAdapter.Copy(wrappedTx, fileKey, tableName); err != nil {
-					logging.Errorf("[%s] Error copying file [%s] from s3 to redshift: %v", ar.Name(), fileKey, err)
+				if err := ar.copy(wrappedTx, fileKey, tableName); err != nil {
+					destinationsLogger.WithDestination(ar.Name()).Errorf("Error copying file [%s] from s3 to redshift: %v", fileKey, err)
 					metrics.ErrorTokenEvents(tokenId, ar.Name(), rowsCount)
 					counters.ErrorEvents(ar.Name(), rowsCount)
+					health.Instance.Failed(ar.Name())
 					wrappedTx.Rollback()
+					ledger.Instance.Loaded(ar.Name(), fileKey, tableName, rowsCount, err)
 					continue
 				}
 
-				wrappedTx.Commit()
+				if err := wrappedTx.DirectCommit(); err != nil {
+					destinationsLogger.WithDestination(ar.Name()).Errorf("Error committing copy of file [%s] from s3 to redshift: %v", fileKey, err)
+					metrics.ErrorTokenEvents(tokenId, ar.Name(), rowsCount)
+					counters.ErrorEvents(ar.Name(), rowsCount)
+					health.Instance.Failed(ar.Name())
+					ledger.Instance.Loaded(ar.Name(), fileKey, tableName, rowsCount, err)
+					continue
+				}
+				ledger.Instance.Loaded(ar.Name(), fileKey, tableName, rowsCount, nil)
+				ar.sqlHooks.Post(tableName, false, ar.redshiftAdapter.Exec)
+				ar.views.AfterLoad(tableName, ar.redshiftAdapter.Exec)
 
 				metrics.SuccessTokenEvents(tokenId, ar.Name(), rowsCount)
 				counters.SuccessEvents(ar.Name(), rowsCount)
+				health.Instance.Succeeded(ar.Name())
 
-				//TODO may be we need to have a journal for collecting already processed files names
-				// if ar.s3Adapter.DeleteObject fails => it will be processed next time => duplicate data
+				//if ar.s3Adapter.DeleteObject fails => the file won't be copied again because it is loaded in the ledger
 				if err := ar.s3Adapter.DeleteObject(fileKey); err != nil {
-					logging.SystemErrorf("[%s] file %s wasn't deleted from s3 and will be inserted in db again: %v", ar.Name(), fileKey, err)
+					destinationsLogger.WithDestination(ar.Name()).SystemErrorf("file %s wasn't deleted from s3: %v", fileKey, err)
 					continue
 				}
 			}
@@ -148,8 +185,26 @@ func (ar *AwsRedshift) startBatch() {
 	})
 }
 
+//copy execute transactional SQL hooks of the table around the file copy in provided wrapped transaction
+func (ar *AwsRedshift) copy(wrappedTx *adapters.Transaction, fileKey, tableName string) error {
+	txExec := func(statement string) error {
+		return ar.redshiftAdapter.ExecInTransaction(wrappedTx, statement)
+	}
+	if err := ar.sqlHooks.Pre(tableName, true, txExec); err != nil {
+		return err
+	}
+	if err := ar.redshiftAdapter.Copy(wrappedTx, fileKey, tableName); err != nil {
+		return err
+	}
+	return ar.sqlHooks.Post(tableName, true, txExec)
+}
+
 //Insert fact in Redshift
 func (ar *AwsRedshift) Insert(dataSchema *schema.Table, fact events.Fact) (err error) {
+	if ok, err := ar.tableHelper.GuardObjectColumns(ar.Name(), dataSchema, fact); !ok {
+		return err
+	}
+
 	dbSchema, err := ar.tableHelper.EnsureTable(ar.Name(), dataSchema)
 	if err != nil {
 		return err
@@ -176,6 +231,7 @@ func (ar *AwsRedshift) StoreWithParseFunc(fileName string, payload []byte, parse
 	if err != nil {
 		return linesCount(payload), err
 	}
+	failedEvents = append(failedEvents, ar.tableHelper.GuardColumns(ar.Name(), flatData)...)
 
 	var rowsCount int
 	for _, fdata := range flatData {
@@ -209,8 +265,7 @@ func (ar *AwsRedshift) StoreWithParseFunc(fileName string, payload []byte, parse
 	//TODO put them all in one folder and if all ok => move them all to next working folder
 	for _, fdata := range flatData {
 		b, fileRows := fdata.GetPayloadBytes(schema.JsonMarshallerInstance)
-		err := ar.s3Adapter.UploadBytes(buildDataIntoFileName(fdata, fileRows), b)
-		if err != nil {
+		if err := stageOnce(ar.Name(), buildDataIntoFileName(fdata, fileRows), fdata.DataSchema.Name, fileRows, b, ar.s3Adapter.UploadBytes); err != nil {
 			return fileRows, err
 		}
 	}
@@ -236,8 +291,43 @@ func (ar *AwsRedshift) SyncStore(objects []map[string]interface{}) (int, error)
 	return 0, errors.New("RedShift doesn't support sync store")
 }
 
+//Annotate apply the correction to the event rows and record it in the annotations table (see Annotator)
+func (ar *AwsRedshift) Annotate(annotation *Annotation) (int64, error) {
+	return annotate(ar.Name(), ar.redshiftAdapter, ar.tableHelper, annotation)
+}
+
+//CountDeletion return count of rows which match the bulk delete filter (see BulkDeleter)
+func (ar *AwsRedshift) CountDeletion(filter *DeletionFilter) (int64, error) {
+	return countDeletion(ar.redshiftAdapter, filter)
+}
+
+//Delete delete rows which match the bulk delete filter in batches (see BulkDeleter)
+func (ar *AwsRedshift) Delete(filter *DeletionFilter, batchSize int, pause time.Duration) (int64, error) {
+	return deleteByFilter(ar.Name(), ar.redshiftAdapter, filter, batchSize, pause)
+}
+
+//DeleteExpired delete rows of all tables which match the retention condition (see Retainer)
+func (ar *AwsRedshift) DeleteExpired(condition *adapters.ExpirationCondition, archive func(table string, rows []map[string]interface{}) error) (int64, error) {
+	return deleteExpired(ar.Name(), ar.redshiftAdapter, condition, archive)
+}
+
 func (ar *AwsRedshift) ColumnTypesMapping() map[typing.DataType]string {
-	return adapters.SchemaToPostgres
+	return adapters.SchemaToRedshift
+}
+
+//ColumnTypesReport return discovered column types vs actual db column types per table
+func (ar *AwsRedshift) ColumnTypesReport() []*TableTypesReport {
+	return ar.columnTypes.Report(ar.ColumnTypesMapping())
+}
+
+//MigrationPlans return table migration plans which are waiting for review
+func (ar *AwsRedshift) MigrationPlans() []*schema.MigrationPlan {
+	return ar.tableHelper.MigrationPlans()
+}
+
+//ApplyMigrationPlan apply reviewed table migration plan
+func (ar *AwsRedshift) ApplyMigrationPlan(table string) error {
+	return ar.tableHelper.ApplyMigrationPlan(ar.Name(), table)
 }
 
 func (ar *AwsRedshift) Name() string {
@@ -248,6 +338,11 @@ func (ar *AwsRedshift) Type() string {
 	return RedshiftType
 }
 
+//Ping check Redshift connection
+func (ar *AwsRedshift) Ping() error {
+	return ar.redshiftAdapter.Ping()
+}
+
 func (ar *AwsRedshift) Close() (multiErr error) {
 	ar.closed = true
 
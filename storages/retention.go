@@ -0,0 +1,158 @@
+package storages
+
+import (
+	"fmt"
+	"github.com/jitsucom/eventnative/adapters"
+	"github.com/jitsucom/eventnative/schema"
+	"time"
+)
+
+const (
+	retentionTimestampColumn = "_timestamp"
+	retentionEventTypeColumn = "event_type"
+)
+
+//RetentionRule is a TTL override or an archival tier of the destination tables rows with certain event types.
+//Rule without event types is applied to event types which aren't listed in other rules of the destination
+type RetentionRule struct {
+	EventTypes []string `mapstructure:"event_types" json:"event_types,omitempty" yaml:"event_types,omitempty"`
+	//rows older than ttl_days are deleted
+	TTLDays int `mapstructure:"ttl_days" json:"ttl_days,omitempty" yaml:"ttl_days,omitempty"`
+	//rows older than archive_after_days are moved into the archive destination (e.g. s3)
+	ArchiveAfterDays   int    `mapstructure:"archive_after_days" json:"archive_after_days,omitempty" yaml:"archive_after_days,omitempty"`
+	ArchiveDestination string `mapstructure:"archive_destination" json:"archive_destination,omitempty" yaml:"archive_destination,omitempty"`
+}
+
+func (rr *RetentionRule) String() string {
+	eventTypes := "[all other event types]"
+	if len(rr.EventTypes) > 0 {
+		eventTypes = fmt.Sprint(rr.EventTypes)
+	}
+	if rr.ArchiveDestination != "" {
+		return fmt.Sprintf("%s: archive into [%s] after %d days", eventTypes, rr.ArchiveDestination, rr.ArchiveAfterDays)
+	}
+	return fmt.Sprintf("%s: delete after %d days", eventTypes, rr.TTLDays)
+}
+
+//Retainer is a destination which supports deletion and archival of outdated rows (see DataLayout.Retention)
+type Retainer interface {
+	//DeleteExpired delete rows of all destination tables which match the condition (columns are set by the destination). If archive func isn't nil,
+	//deleted rows are passed to it per table and aren't deleted if it returns an error. return deleted rows count
+	DeleteExpired(condition *adapters.ExpirationCondition, archive func(table string, rows []map[string]interface{}) error) (int64, error)
+}
+
+//expiringAdapter is a sql adapter which supports deletion of outdated rows
+type expiringAdapter interface {
+	TablesList() ([]string, error)
+	GetTableSchema(tableName string) (*schema.Table, error)
+	DeleteExpired(table *schema.Table, condition *adapters.ExpirationCondition, archive func([]map[string]interface{}) error) (int64, error)
+}
+
+//deleteExpired apply the condition to every table with timestamp (and event type if condition has event types) columns
+func deleteExpired(destinationName string, adapter expiringAdapter, condition *adapters.ExpirationCondition,
+	archive func(table string, rows []map[string]interface{}) error) (int64, error) {
+	tables, err := adapter.TablesList()
+	if err != nil {
+		return 0, err
+	}
+
+	var deleted int64
+	for _, tableName := range tables {
+		table, err := adapter.GetTableSchema(tableName)
+		if err != nil {
+			return deleted, err
+		}
+
+		if _, ok := table.Columns[retentionTimestampColumn]; !ok {
+			continue
+		}
+		tableCondition := *condition
+		tableCondition.TimestampColumn = retentionTimestampColumn
+		tableCondition.EventTypeColumn = retentionEventTypeColumn
+		if _, ok := table.Columns[retentionEventTypeColumn]; !ok {
+			if len(condition.EventTypes) > 0 {
+				continue
+			}
+			//rows without event type are matched by the rule without event types
+			tableCondition.ExcludedEventTypes = nil
+		}
+
+		var archiveTable func([]map[string]interface{}) error
+		if archive != nil {
+			archiveTable = func(rows []map[string]interface{}) error {
+				return archive(tableName, rows)
+			}
+		}
+
+		tableDeleted, err := adapter.DeleteExpired(table, &tableCondition, archiveTable)
+		if err != nil {
+			return deleted, fmt.Errorf("Error applying retention to [%s] table: %v", tableName, err)
+		}
+		if tableDeleted > 0 {
+			destinationsLogger.WithDestination(destinationName).Infof("Retention: %d rows of [%s] table older than %s were deleted", tableDeleted, tableName, condition.Before.Format(time.RFC3339))
+		}
+		deleted += tableDeleted
+	}
+
+	return deleted, nil
+}
+
+//validateRetention return err if retention rules are inconsistent or retention isn't supported by the destination
+func validateRetention(destination DestinationConfig, name string) error {
+	if destination.DataLayout == nil || len(destination.DataLayout.Retention) == 0 {
+		return nil
+	}
+
+	if destination.Type != PostgresType && destination.Type != RedshiftType {
+		return fmt.Errorf("data_layout.retention isn't supported by %s destination", destination.Type)
+	}
+
+	eventTypes := map[string]bool{}
+	defaultRule := false
+	for _, rule := range destination.DataLayout.Retention {
+		if len(rule.EventTypes) == 0 {
+			if defaultRule {
+				return fmt.Errorf("Only one data_layout.retention rule without event_types is allowed")
+			}
+			defaultRule = true
+		}
+		for _, eventType := range rule.EventTypes {
+			if eventTypes[eventType] {
+				return fmt.Errorf("Event type [%s] is used in several data_layout.retention rules", eventType)
+			}
+			eventTypes[eventType] = true
+		}
+
+		if rule.ArchiveDestination == "" && rule.ArchiveAfterDays == 0 {
+			if rule.TTLDays <= 0 {
+				return fmt.Errorf("data_layout.retention rule %v: ttl_days or archive_after_days with archive_destination must be positive", rule.EventTypes)
+			}
+			continue
+		}
+
+		if rule.TTLDays != 0 {
+			return fmt.Errorf("data_layout.retention rule %v: ttl_days can't be used with archive_destination. Configure retention of the archive destination instead", rule.EventTypes)
+		}
+		if rule.ArchiveDestination == "" || rule.ArchiveAfterDays <= 0 {
+			return fmt.Errorf("data_layout.retention rule %v: archive_destination and positive archive_after_days are required", rule.EventTypes)
+		}
+		if rule.ArchiveDestination == name {
+			return fmt.Errorf("data_layout.retention rule %v: archive_destination must be another destination", rule.EventTypes)
+		}
+	}
+
+	return nil
+}
+
+//ExcludedEventTypes return event types from other rules: they are excluded from the rule without event types
+func ExcludedEventTypes(rules []*RetentionRule, rule *RetentionRule) []string {
+	if len(rule.EventTypes) > 0 {
+		return nil
+	}
+
+	var excluded []string
+	for _, other := range rules {
+		excluded = append(excluded, other.EventTypes...)
+	}
+	return excluded
+}
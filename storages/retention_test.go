@@ -0,0 +1,76 @@
+package storages
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestValidateRetention(t *testing.T) {
+	tests := []struct {
+		name        string
+		destType    string
+		rules       []*RetentionRule
+		expectedErr string
+	}{
+		{
+			"ttl and archival tiers",
+			PostgresType,
+			[]*RetentionRule{
+				{EventTypes: []string{"debug", "heartbeat"}, TTLDays: 7},
+				{EventTypes: []string{"pageview"}, ArchiveAfterDays: 90, ArchiveDestination: "s3_archive"},
+				{TTLDays: 365},
+			},
+			"",
+		},
+		{
+			"unsupported destination",
+			S3Type,
+			[]*RetentionRule{{EventTypes: []string{"debug"}, TTLDays: 7}},
+			"data_layout.retention isn't supported by s3 destination",
+		},
+		{
+			"event type in several rules",
+			RedshiftType,
+			[]*RetentionRule{{EventTypes: []string{"debug"}, TTLDays: 7}, {EventTypes: []string{"debug"}, TTLDays: 1}},
+			"Event type [debug] is used in several data_layout.retention rules",
+		},
+		{
+			"several rules without event types",
+			PostgresType,
+			[]*RetentionRule{{TTLDays: 7}, {TTLDays: 1}},
+			"Only one data_layout.retention rule without event_types is allowed",
+		},
+		{
+			"archive into itself",
+			PostgresType,
+			[]*RetentionRule{{EventTypes: []string{"debug"}, ArchiveAfterDays: 7, ArchiveDestination: "pg"}},
+			"data_layout.retention rule [debug]: archive_destination must be another destination",
+		},
+		{
+			"archive without days",
+			PostgresType,
+			[]*RetentionRule{{EventTypes: []string{"debug"}, ArchiveDestination: "s3_archive"}},
+			"data_layout.retention rule [debug]: archive_destination and positive archive_after_days are required",
+		},
+		{
+			"empty rule",
+			PostgresType,
+			[]*RetentionRule{{EventTypes: []string{"debug"}}},
+			"data_layout.retention rule [debug]: ttl_days or archive_after_days with archive_destination must be positive",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateRetention(DestinationConfig{Type: tt.destType, DataLayout: &DataLayout{Retention: tt.rules}}, "pg")
+			if tt.expectedErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tt.expectedErr)
+			}
+		})
+	}
+
+	rules := []*RetentionRule{{EventTypes: []string{"debug", "heartbeat"}, TTLDays: 7}, {EventTypes: []string{"pageview"}, TTLDays: 30}, {TTLDays: 365}}
+	require.Nil(t, ExcludedEventTypes(rules, rules[0]))
+	require.Equal(t, []string{"debug", "heartbeat", "pageview"}, ExcludedEventTypes(rules, rules[2]))
+}
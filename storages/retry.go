@@ -0,0 +1,60 @@
+package storages
+
+import (
+	"github.com/jitsucom/eventnative/retry"
+)
+
+//retryClassifiers are retryable (transient) write errors classifications by destination type. Network errors are
+//retryable for all destinations (see retry.IsNetworkError)
+var retryClassifiers = map[string]retry.Classifier{
+	PostgresType:   isRetryablePostgresError,
+	RedshiftType:   isRetryablePostgresError,
+	ClickHouseType: isRetryableClickHouseError,
+	BigQueryType:   isRetryableGoogleError,
+	SnowflakeType:  isRetryableSnowflakeError,
+	S3Type:         isRetryableAwsError,
+	AggregatesType: isRetryablePostgresError,
+}
+
+//newRetryPolicy return the destination write retry policy with the destination type errors classification.
+//Stream mode events are retried until they are stored by default (they are kept in the queue meanwhile)
+func newRetryPolicy(name string, destination *DestinationConfig) (*retry.Policy, error) {
+	config := &retry.Config{}
+	if destination.Retry != nil {
+		*config = *destination.Retry
+	}
+	if destination.Mode == StreamMode && config.MaxAttempts == 0 {
+		config.MaxAttempts = -1
+	}
+	return retry.NewPolicy(name, config, retryClassifiers[destination.Type])
+}
+
+//postgres and redshift: connection exceptions, serialization failures, deadlocks, too many connections and shutdowns
+func isRetryablePostgresError(err error) bool {
+	return retry.ContainsAny(err, "could not serialize access", "deadlock detected", "too many clients",
+		"too many connections", "the database system is starting up", "the database system is shutting down",
+		"terminating connection", "could not connect to server", "server closed the connection unexpectedly")
+}
+
+//clickhouse: too many simultaneous queries (202), timeouts (159, 209), network errors (210) and too many parts (252)
+func isRetryableClickHouseError(err error) bool {
+	return retry.ContainsAny(err, "code: 159", "code: 202", "code: 209", "code: 210", "code: 252", "too many parts")
+}
+
+//bigquery and google cloud storage: rate limits, backend and internal errors
+func isRetryableGoogleError(err error) bool {
+	return retry.ContainsAny(err, "error 429", "error 500", "error 502", "error 503", "ratelimitexceeded",
+		"backenderror", "internalerror")
+}
+
+//snowflake: service unavailable, rate limits and gateway errors
+func isRetryableSnowflakeError(err error) bool {
+	return retry.ContainsAny(err, "service unavailable", "http 429", "http 502", "http 503", "http 504") ||
+		isRetryableAwsError(err)
+}
+
+//s3: throttling, request timeouts and internal errors
+func isRetryableAwsError(err error) bool {
+	return retry.ContainsAny(err, "slowdown", "throttling", "requesttimeout", "requesterror", "serviceunavailable",
+		"internalerror")
+}
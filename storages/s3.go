@@ -1,13 +1,13 @@
 package storages
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/jitsucom/eventnative/adapters"
 	"github.com/jitsucom/eventnative/caching"
 	"github.com/jitsucom/eventnative/counters"
 	"github.com/jitsucom/eventnative/events"
-	"github.com/jitsucom/eventnative/logging"
 	"github.com/jitsucom/eventnative/parsers"
 	"github.com/jitsucom/eventnative/schema"
 	"github.com/jitsucom/eventnative/typing"
@@ -17,6 +17,7 @@ import (
 type S3 struct {
 	name            string
 	s3Adapter       *adapters.S3
+	s3Config        *adapters.S3Config
 	schemaProcessor *schema.Processor
 	fallbackLogger  *events.AsyncLogger
 	eventsCache     *caching.EventsCache
@@ -33,6 +34,7 @@ func NewS3(name string, s3Config *adapters.S3Config, processor *schema.Processor
 	s3 := &S3{
 		name:            name,
 		s3Adapter:       s3Adapter,
+		s3Config:        s3Config,
 		schemaProcessor: processor,
 		fallbackLogger:  fallbackLoggerFactoryMethod(),
 		eventsCache:     eventsCache,
@@ -43,7 +45,7 @@ func NewS3(name string, s3Config *adapters.S3Config, processor *schema.Processor
 }
 
 func (s3 *S3) Consume(fact events.Fact, tokenId string) {
-	logging.Errorf("[%s] S3 storage doesn't support streaming mode", s3.Name())
+	destinationsLogger.WithDestination(s3.Name()).Errorf("S3 storage doesn't support streaming mode")
 }
 
 //Store call StoreWithParseFunc with parsers.ParseJson func
@@ -79,9 +81,7 @@ func (s3 *S3) StoreWithParseFunc(fileName string, payload []byte, parseFunc func
 	}()
 
 	for _, fdata := range flatData {
-		b, rows := fdata.GetPayloadBytes(schema.JsonMarshallerInstance)
-		err := s3.s3Adapter.UploadBytes(buildDataIntoFileName(fdata, rows), b)
-		if err != nil {
+		if err = s3.upload(fdata); err != nil {
 			return rowsCount, err
 		}
 	}
@@ -96,6 +96,46 @@ func (s3 *S3) StoreWithParseFunc(fileName string, payload []byte, parseFunc func
 	return rowsCount, nil
 }
 
+//upload write the file in configured format and then the manifest sidecar (if enabled)
+func (s3 *S3) upload(fdata *schema.ProcessedFile) error {
+	s3Config := s3.s3Config
+	format := s3Config.GetFormat()
+
+	var b []byte
+	var rows int
+	fileName := buildDataIntoFileName(fdata, fdata.GetPayloadLen())
+	var manifest *schema.Manifest
+	if format == adapters.S3CsvFormat {
+		var columns []schema.CsvColumn
+		var err error
+		b, columns, err = fdata.GetCsvPayload(s3Config.Csv)
+		if err != nil {
+			return fmt.Errorf("Error marshalling CSV file: %v", err)
+		}
+		rows = fdata.GetPayloadLen()
+		fileName += ".csv"
+		manifest = schema.NewManifest(fileName, fdata.DataSchema.Name, format, b, columns, rows)
+		manifest.Delimiter = s3Config.Csv.GetDelimiter()
+		manifest.HeaderRows = s3Config.Csv.HeaderRows()
+	} else {
+		b, rows = fdata.GetPayloadBytes(schema.JsonMarshallerInstance)
+		manifest = schema.NewManifest(fileName, fdata.DataSchema.Name, format, b, fdata.GetColumns(), rows)
+	}
+
+	if err := s3.s3Adapter.UploadBytes(fileName, b); err != nil {
+		return err
+	}
+	if !s3Config.Manifest {
+		return nil
+	}
+
+	manifestBytes, err := json.Marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("Error marshalling manifest of [%s] file: %v", fileName, err)
+	}
+	return s3.s3Adapter.UploadBytes(fileName+schema.ManifestSuffix, manifestBytes)
+}
+
 //Fallback log event with error to fallback logger
 func (s3 *S3) Fallback(failedFacts ...*events.FailedFact) {
 	for _, failedFact := range failedFacts {
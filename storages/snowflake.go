@@ -10,6 +10,8 @@ import (
 	"github.com/jitsucom/eventnative/caching"
 	"github.com/jitsucom/eventnative/counters"
 	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/health"
+	"github.com/jitsucom/eventnative/ledger"
 	"github.com/jitsucom/eventnative/logging"
 	"github.com/jitsucom/eventnative/metrics"
 	"github.com/jitsucom/eventnative/parsers"
@@ -29,6 +31,7 @@ type Snowflake struct {
 	stageAdapter     adapters.Stage
 	snowflakeAdapter *adapters.Snowflake
 	tableHelper      *TableHelper
+	columnTypes      *ColumnTypesRegistry
 	schemaProcessor  *schema.Processor
 	streamingWorker  *StreamingWorker
 	fallbackLogger   *events.AsyncLogger
@@ -40,7 +43,7 @@ type Snowflake struct {
 
 //NewSnowflake return Snowflake and start goroutine for Snowflake batch storage or for stream consumer depend on destination mode
 func NewSnowflake(ctx context.Context, name string, eventQueue *events.PersistentQueue, s3Config *adapters.S3Config, gcpConfig *adapters.GoogleConfig,
-	snowflakeConfig *adapters.SnowflakeConfig, processor *schema.Processor, breakOnError, streamMode bool, monitorKeeper MonitorKeeper,
+	snowflakeConfig *adapters.SnowflakeConfig, processor *schema.Processor, breakOnError, streamMode bool, monitorKeeper MonitorKeeper, schemaMigrations string,
 	fallbackLoggerFactoryMethod func() *events.AsyncLogger, queryLogger *logging.QueryLogger, eventsCache *caching.EventsCache) (*Snowflake, error) {
 	var stageAdapter adapters.Stage
 	if !streamMode {
@@ -66,13 +69,15 @@ func NewSnowflake(ctx context.Context, name string, eventQueue *events.Persisten
 		return nil, err
 	}
 
-	tableHelper := NewTableHelper(snowflakeAdapter, monitorKeeper, SnowflakeType)
+	columnTypes := NewColumnTypesRegistry()
+	tableHelper := NewTableHelper(snowflakeAdapter, monitorKeeper, columnTypes, SnowflakeType, processor.Limits(), schemaMigrations)
 
 	snowflake := &Snowflake{
 		name:             name,
 		stageAdapter:     stageAdapter,
 		snowflakeAdapter: snowflakeAdapter,
 		tableHelper:      tableHelper,
+		columnTypes:      columnTypes,
 		schemaProcessor:  processor,
 		fallbackLogger:   fallbackLoggerFactoryMethod(),
 		eventsCache:      eventsCache,
@@ -139,7 +144,7 @@ func (s *Snowflake) startBatch() {
 
 			filesKeys, err := s.stageAdapter.ListBucket(appconfig.Instance.ServerName)
 			if err != nil {
-				logging.Errorf("[%s] Error reading files from stage: %v", s.Name(), err)
+				destinationsLogger.WithDestination(s.Name()).Errorf("Error reading files from stage: %v", err)
 				continue
 			}
 
@@ -150,51 +155,76 @@ func (s *Snowflake) startBatch() {
 			for _, fileKey := range filesKeys {
 				tableName, tokenId, rowsCount, err := extractDataFromFileName(fileKey)
 				if err != nil {
-					logging.Errorf("[%s] stage file [%s] has wrong format: %v", s.Name(), fileKey, err)
+					destinationsLogger.WithDestination(s.Name()).Errorf("stage file [%s] has wrong format: %v", fileKey, err)
+					continue
+				}
+
+				//copy has failed max attempts before the restart: the file is kept in the stage for manual handling
+				if ledger.Instance.IsDeadLettered(s.Name(), fileKey) {
+					continue
+				}
+
+				//copy has been confirmed but the file wasn't deleted (e.g. crash or delete error)
+				if ledger.Instance.IsLoaded(s.Name(), fileKey) {
+					destinationsLogger.WithDestination(s.Name()).Warnf("file %s has already been copied to snowflake. It will be deleted from stage", fileKey)
+					if err := s.stageAdapter.DeleteObject(fileKey); err != nil {
+						destinationsLogger.WithDestination(s.Name()).Errorf("Error deleting already copied file %s from stage: %v", fileKey, err)
+					}
 					continue
 				}
 
 				payload, err := s.stageAdapter.GetObject(fileKey)
 				if err != nil {
-					logging.Errorf("[%s] Error getting file %s from stage in Snowflake storage: %v", s.Name(), fileKey, err)
+					destinationsLogger.WithDestination(s.Name()).Errorf("Error getting file %s from stage in Snowflake storage: %v", fileKey, err)
 					metrics.ErrorTokenEvents(tokenId, s.Name(), rowsCount)
 					continue
 				}
 
 				lines := strings.Split(string(payload), "\n")
 				if len(lines) == 0 {
-					logging.Errorf("[%s] Error reading stage file %s payload in Snowflake storage: empty file", s.Name(), fileKey)
+					destinationsLogger.WithDestination(s.Name()).Errorf("Error reading stage file %s payload in Snowflake storage: empty file", fileKey)
 					metrics.ErrorTokenEvents(tokenId, s.Name(), rowsCount)
 					continue
 				}
 				header := lines[0]
 				if header == "" {
-					logging.Errorf("[%s] Error reading stage file %s header in Snowflake storage: %v", s.Name(), fileKey, err)
+					destinationsLogger.WithDestination(s.Name()).Errorf("Error reading stage file %s header in Snowflake storage: %v", fileKey, err)
 					metrics.ErrorTokenEvents(tokenId, s.Name(), rowsCount)
 					continue
 				}
 
 				wrappedTx, err := s.snowflakeAdapter.OpenTx()
 				if err != nil {
-					logging.Errorf("[%s] Error creating snowflake transaction: %v", s.Name(), err)
+					destinationsLogger.WithDestination(s.Name()).Errorf("Error creating snowflake transaction: %v", err)
 					metrics.ErrorTokenEvents(tokenId, s.Name(), rowsCount)
 					continue
 				}
 
 				if err := s.snowflakeAdapter.Copy(wrappedTx, fileKey, header, tableName); err != nil {
-					logging.Errorf("[%s] Error copying file [%s] from stage to snowflake: %v", s.Name(), fileKey, err)
+					destinationsLogger.WithDestination(s.Name()).Errorf("Error copying file [%s] from stage to snowflake: %v", fileKey, err)
 					wrappedTx.Rollback()
 					metrics.ErrorTokenEvents(tokenId, s.Name(), rowsCount)
 					counters.ErrorEvents(s.Name(), rowsCount)
+					health.Instance.Failed(s.Name())
+					ledger.Instance.Loaded(s.Name(), fileKey, tableName, rowsCount, err)
 					continue
 				}
 
-				wrappedTx.Commit()
+				if err := wrappedTx.DirectCommit(); err != nil {
+					destinationsLogger.WithDestination(s.Name()).Errorf("Error committing copy of file [%s] from stage to snowflake: %v", fileKey, err)
+					metrics.ErrorTokenEvents(tokenId, s.Name(), rowsCount)
+					counters.ErrorEvents(s.Name(), rowsCount)
+					health.Instance.Failed(s.Name())
+					ledger.Instance.Loaded(s.Name(), fileKey, tableName, rowsCount, err)
+					continue
+				}
+				ledger.Instance.Loaded(s.Name(), fileKey, tableName, rowsCount, nil)
 				metrics.SuccessTokenEvents(tokenId, s.Name(), rowsCount)
 				counters.SuccessEvents(s.Name(), rowsCount)
+				health.Instance.Succeeded(s.Name())
 
 				if err := s.stageAdapter.DeleteObject(fileKey); err != nil {
-					logging.SystemErrorf("[%s] file %s wasn't deleted from stage and will be inserted in db again: %v", s.Name(), fileKey, err)
+					destinationsLogger.WithDestination(s.Name()).SystemErrorf("file %s wasn't deleted from stage: %v", fileKey, err)
 					continue
 				}
 
@@ -205,6 +235,10 @@ func (s *Snowflake) startBatch() {
 
 //Insert fact in Snowflake
 func (s *Snowflake) Insert(dataSchema *schema.Table, fact events.Fact) (err error) {
+	if ok, err := s.tableHelper.GuardObjectColumns(s.Name(), dataSchema, fact); !ok {
+		return err
+	}
+
 	dbSchema, err := s.tableHelper.EnsureTable(s.Name(), dataSchema)
 	if err != nil {
 		return err
@@ -231,6 +265,7 @@ func (s *Snowflake) StoreWithParseFunc(fileName string, payload []byte, parseFun
 	if err != nil {
 		return 0, err
 	}
+	failedEvents = append(failedEvents, s.tableHelper.GuardColumns(s.Name(), flatData)...)
 
 	var rowsCount int
 	for _, fdata := range flatData {
@@ -263,8 +298,7 @@ func (s *Snowflake) StoreWithParseFunc(fileName string, payload []byte, parseFun
 
 	for _, fdata := range flatData {
 		b, fileRows := fdata.GetPayloadBytes(schema.CsvMarshallerInstance)
-		err := s.stageAdapter.UploadBytes(buildDataIntoFileName(fdata, fileRows), b)
-		if err != nil {
+		if err := stageOnce(s.Name(), buildDataIntoFileName(fdata, fileRows), fdata.DataSchema.Name, fileRows, b, s.stageAdapter.UploadBytes); err != nil {
 			return fileRows, err
 		}
 	}
@@ -294,6 +328,21 @@ func (s *Snowflake) ColumnTypesMapping() map[typing.DataType]string {
 	return adapters.SchemaToSnowflake
 }
 
+//ColumnTypesReport return discovered column types vs actual db column types per table
+func (s *Snowflake) ColumnTypesReport() []*TableTypesReport {
+	return s.columnTypes.Report(s.ColumnTypesMapping())
+}
+
+//MigrationPlans return table migration plans which are waiting for review
+func (s *Snowflake) MigrationPlans() []*schema.MigrationPlan {
+	return s.tableHelper.MigrationPlans()
+}
+
+//ApplyMigrationPlan apply reviewed table migration plan
+func (s *Snowflake) ApplyMigrationPlan(table string) error {
+	return s.tableHelper.ApplyMigrationPlan(s.Name(), table)
+}
+
 func (s *Snowflake) Name() string {
 	return s.name
 }
@@ -302,6 +351,11 @@ func (s *Snowflake) Type() string {
 	return SnowflakeType
 }
 
+//Ping check Snowflake connection
+func (s *Snowflake) Ping() error {
+	return s.snowflakeAdapter.Ping()
+}
+
 func (s *Snowflake) Close() (multiErr error) {
 	s.closed = true
 
@@ -0,0 +1,200 @@
+package storages
+
+import (
+	"fmt"
+	"github.com/jitsucom/eventnative/metrics"
+	"path"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	AbortOnFailure  = "abort"
+	IgnoreOnFailure = "ignore"
+
+	preHookStage  = "pre"
+	postHookStage = "post"
+)
+
+//SQLHook is a set of custom SQL statements which are executed before (pre) and after (post) every batch load into the matched tables
+//(e.g. ANALYZE, REFRESH MATERIALIZED VIEW or partitions swap). Statements are go templates with {{.Schema}} and {{.Table}} values
+type SQLHook struct {
+	//table names or patterns (e.g. events_*). Empty means all tables
+	Tables []string `mapstructure:"tables" json:"tables,omitempty" yaml:"tables,omitempty"`
+	Pre    []string `mapstructure:"pre" json:"pre,omitempty" yaml:"pre,omitempty"`
+	Post   []string `mapstructure:"post" json:"post,omitempty" yaml:"post,omitempty"`
+	//abort (default) - statements are executed in the load transaction: an error rolls back the load and the batch is retried
+	//ignore - statements are executed outside of the load transaction (e.g. VACUUM): errors are logged and counted in metrics
+	OnFailure string `mapstructure:"on_failure" json:"on_failure,omitempty" yaml:"on_failure,omitempty"`
+}
+
+func (sh *SQLHook) String() string {
+	tables := "[all tables]"
+	if len(sh.Tables) > 0 {
+		tables = fmt.Sprint(sh.Tables)
+	}
+	onFailure := sh.OnFailure
+	if onFailure == "" {
+		onFailure = AbortOnFailure
+	}
+	return fmt.Sprintf("%s: %d pre and %d post statements, on failure: %s", tables, len(sh.Pre), len(sh.Post), onFailure)
+}
+
+//sqlHookData is a statement template values
+type sqlHookData struct {
+	Schema string
+	Table  string
+}
+
+type sqlHook struct {
+	tables        []string
+	pre           []*template.Template
+	post          []*template.Template
+	transactional bool
+}
+
+func (sh *sqlHook) matches(table string) bool {
+	if len(sh.tables) == 0 {
+		return true
+	}
+	for _, pattern := range sh.tables {
+		if matched, _ := path.Match(pattern, table); matched {
+			return true
+		}
+	}
+	return false
+}
+
+//SQLHooks execute configured statements of the destination around batch loads. Nil SQLHooks doesn't execute anything
+type SQLHooks struct {
+	destinationName string
+	dbSchema        string
+	hooks           []*sqlHook
+}
+
+//NewSQLHooks return SQLHooks with parsed statements templates or err if configuration is invalid
+func NewSQLHooks(destinationName, dbSchema string, configs []*SQLHook) (*SQLHooks, error) {
+	if len(configs) == 0 {
+		return nil, nil
+	}
+
+	sqlHooks := &SQLHooks{destinationName: destinationName, dbSchema: dbSchema}
+	for i, config := range configs {
+		if len(config.Pre) == 0 && len(config.Post) == 0 {
+			return nil, fmt.Errorf("sql_hooks[%d]: pre or post statements are required", i)
+		}
+		for _, pattern := range config.Tables {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("sql_hooks[%d]: malformed table pattern [%s]: %v", i, pattern, err)
+			}
+		}
+
+		hook := &sqlHook{tables: config.Tables}
+		switch config.OnFailure {
+		case "", AbortOnFailure:
+			hook.transactional = true
+		case IgnoreOnFailure:
+		default:
+			return nil, fmt.Errorf("sql_hooks[%d]: unknown on_failure: %s. Available: [%s, %s]", i, config.OnFailure, AbortOnFailure, IgnoreOnFailure)
+		}
+
+		var err error
+		if hook.pre, err = parseSQLHookStatements(i, preHookStage, config.Pre); err != nil {
+			return nil, err
+		}
+		if hook.post, err = parseSQLHookStatements(i, postHookStage, config.Post); err != nil {
+			return nil, err
+		}
+		sqlHooks.hooks = append(sqlHooks.hooks, hook)
+	}
+
+	return sqlHooks, nil
+}
+
+func parseSQLHookStatements(hookIndex int, stage string, statements []string) ([]*template.Template, error) {
+	var templates []*template.Template
+	for j, statement := range statements {
+		if strings.TrimSpace(statement) == "" {
+			return nil, fmt.Errorf("sql_hooks[%d].%s[%d]: statement is empty", hookIndex, stage, j)
+		}
+		tmpl, err := template.New(fmt.Sprintf("sql_hooks[%d].%s[%d]", hookIndex, stage, j)).Option("missingkey=error").Parse(statement)
+		if err != nil {
+			return nil, fmt.Errorf("sql_hooks[%d].%s[%d]: malformed statement template: %v", hookIndex, stage, j, err)
+		}
+		templates = append(templates, tmpl)
+	}
+	return templates, nil
+}
+
+//Pre execute pre statements of the table hooks with on_failure: abort (if transactional, exec runs in the load transaction)
+//or on_failure: ignore policy. The first error of transactional statements is returned, other errors are only logged
+func (sh *SQLHooks) Pre(table string, transactional bool, exec func(statement string) error) error {
+	return sh.run(preHookStage, table, transactional, exec)
+}
+
+//Post execute post statements of the table hooks (see Pre)
+func (sh *SQLHooks) Post(table string, transactional bool, exec func(statement string) error) error {
+	return sh.run(postHookStage, table, transactional, exec)
+}
+
+func (sh *SQLHooks) run(stage, table string, transactional bool, exec func(statement string) error) error {
+	if sh == nil {
+		return nil
+	}
+
+	data := sqlHookData{Schema: sh.dbSchema, Table: table}
+	for _, hook := range sh.hooks {
+		if hook.transactional != transactional || !hook.matches(table) {
+			continue
+		}
+
+		templates := hook.pre
+		if stage == postHookStage {
+			templates = hook.post
+		}
+		for _, tmpl := range templates {
+			if err := sh.execute(stage, table, tmpl, data, exec); err != nil {
+				metrics.SQLHookError(sh.destinationName, table, stage)
+				if transactional {
+					return err
+				}
+				destinationsLogger.WithDestination(sh.destinationName).Warnf("%v", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (sh *SQLHooks) execute(stage, table string, tmpl *template.Template, data sqlHookData, exec func(statement string) error) error {
+	var statement strings.Builder
+	if err := tmpl.Execute(&statement, data); err != nil {
+		return fmt.Errorf("Error rendering %s SQL hook of [%s] table: %v", tmpl.Name(), table, err)
+	}
+
+	start := time.Now()
+	err := exec(statement.String())
+	metrics.SQLHookDuration(sh.destinationName, table, stage, time.Since(start).Seconds())
+	if err != nil {
+		return fmt.Errorf("Error executing %s SQL hook of [%s] table: %v", tmpl.Name(), table, err)
+	}
+	return nil
+}
+
+//validateSQLHooks return err if SQL hooks are misconfigured or aren't supported by the destination
+func validateSQLHooks(destination DestinationConfig, name string) error {
+	if len(destination.SQLHooks) == 0 {
+		return nil
+	}
+
+	if destination.Type != PostgresType && destination.Type != RedshiftType {
+		return fmt.Errorf("sql_hooks aren't supported by %s destination", destination.Type)
+	}
+	if destination.Mode == StreamMode {
+		return fmt.Errorf("sql_hooks are executed around batch loads and aren't supported in %s mode", StreamMode)
+	}
+
+	_, err := NewSQLHooks(name, "", destination.SQLHooks)
+	return err
+}
@@ -0,0 +1,145 @@
+package storages
+
+import (
+	"errors"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestSQLHooks(t *testing.T) {
+	hooks, err := NewSQLHooks("pg", "analytics", []*SQLHook{
+		{Tables: []string{"pageview_*"}, Pre: []string{"LOCK TABLE {{.Schema}}.{{.Table}}"}, Post: []string{"REFRESH MATERIALIZED VIEW {{.Schema}}.daily"}},
+		{Post: []string{"ANALYZE {{.Schema}}.{{.Table}}"}, OnFailure: IgnoreOnFailure},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		stage         string
+		table         string
+		transactional bool
+		failing       bool
+		expected      []string
+		expectedErr   string
+	}{
+		{
+			"matched transactional pre",
+			preHookStage,
+			"pageview_2021_01",
+			true,
+			false,
+			[]string{"LOCK TABLE analytics.pageview_2021_01"},
+			"",
+		},
+		{
+			"not matched table",
+			preHookStage,
+			"click_2021_01",
+			true,
+			false,
+			nil,
+			"",
+		},
+		{
+			"non transactional post of all tables",
+			postHookStage,
+			"click_2021_01",
+			false,
+			false,
+			[]string{"ANALYZE analytics.click_2021_01"},
+			"",
+		},
+		{
+			"failed transactional post aborts",
+			postHookStage,
+			"pageview_2021_01",
+			true,
+			true,
+			[]string{"REFRESH MATERIALIZED VIEW analytics.daily"},
+			"Error executing sql_hooks[0].post[0] SQL hook of [pageview_2021_01] table: relation doesn't exist",
+		},
+		{
+			"failed non transactional post is ignored",
+			postHookStage,
+			"pageview_2021_01",
+			false,
+			true,
+			[]string{"ANALYZE analytics.pageview_2021_01"},
+			"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var executed []string
+			exec := func(statement string) error {
+				executed = append(executed, statement)
+				if tt.failing {
+					return errors.New("relation doesn't exist")
+				}
+				return nil
+			}
+
+			var err error
+			if tt.stage == preHookStage {
+				err = hooks.Pre(tt.table, tt.transactional, exec)
+			} else {
+				err = hooks.Post(tt.table, tt.transactional, exec)
+			}
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.expected, executed)
+		})
+	}
+}
+
+func TestValidateSQLHooks(t *testing.T) {
+	tests := []struct {
+		name        string
+		destination DestinationConfig
+		expectedErr string
+	}{
+		{
+			"valid",
+			DestinationConfig{Type: RedshiftType, Mode: BatchMode, SQLHooks: []*SQLHook{{Post: []string{"ANALYZE {{.Table}}"}}}},
+			"",
+		},
+		{
+			"unsupported destination",
+			DestinationConfig{Type: BigQueryType, Mode: BatchMode, SQLHooks: []*SQLHook{{Post: []string{"SELECT 1"}}}},
+			"sql_hooks aren't supported by bigquery destination",
+		},
+		{
+			"stream mode",
+			DestinationConfig{Type: PostgresType, Mode: StreamMode, SQLHooks: []*SQLHook{{Post: []string{"SELECT 1"}}}},
+			"sql_hooks are executed around batch loads and aren't supported in stream mode",
+		},
+		{
+			"without statements",
+			DestinationConfig{Type: PostgresType, Mode: BatchMode, SQLHooks: []*SQLHook{{Tables: []string{"events"}}}},
+			"sql_hooks[0]: pre or post statements are required",
+		},
+		{
+			"unknown policy",
+			DestinationConfig{Type: PostgresType, Mode: BatchMode, SQLHooks: []*SQLHook{{Post: []string{"SELECT 1"}, OnFailure: "retry"}}},
+			"sql_hooks[0]: unknown on_failure: retry. Available: [abort, ignore]",
+		},
+		{
+			"malformed template",
+			DestinationConfig{Type: PostgresType, Mode: BatchMode, SQLHooks: []*SQLHook{{Pre: []string{"ANALYZE {{.Table"}}}},
+			"sql_hooks[0].pre[0]: malformed statement template: template: sql_hooks[0].pre[0]:1: unclosed action",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSQLHooks(tt.destination, "pg")
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
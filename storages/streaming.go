@@ -1,14 +1,20 @@
 package storages
 
 import (
+	"github.com/jitsucom/eventnative/breaker"
 	"github.com/jitsucom/eventnative/caching"
 	"github.com/jitsucom/eventnative/counters"
 	"github.com/jitsucom/eventnative/events"
-	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/faults"
+	"github.com/jitsucom/eventnative/health"
+	"github.com/jitsucom/eventnative/latency"
 	"github.com/jitsucom/eventnative/metrics"
+	"github.com/jitsucom/eventnative/reports"
+	"github.com/jitsucom/eventnative/retry"
 	"github.com/jitsucom/eventnative/safego"
 	"github.com/jitsucom/eventnative/schema"
-	"strings"
+	"github.com/jitsucom/eventnative/timestamp"
+	"sync/atomic"
 	"time"
 )
 
@@ -18,106 +24,278 @@ type StreamingStorage interface {
 }
 
 //StreamingWorker reads events from queue and using events.StreamingStorage writes them
+//If the destination circuit breaker is open events are moved from the queue into the spool (see breaker package)
+//and are written in order from it after the destination has recovered (probe write has succeeded)
 type StreamingWorker struct {
 	eventQueue       *events.PersistentQueue
+	spool            *events.PersistentQueue
 	schemaProcessor  *schema.Processor
 	streamingStorage StreamingStorage
 	eventsCache      *caching.EventsCache
+	breaker          *breaker.Breaker
 
-	closed bool
+	//closed is read by the worker goroutines and set by Close: accessed atomically
+	closed uint32
 }
 
 func newStreamingWorker(eventQueue *events.PersistentQueue, schemaProcessor *schema.Processor, streamingStorage StreamingStorage,
 	eventsCache *caching.EventsCache) *StreamingWorker {
-	return &StreamingWorker{
+	sw := &StreamingWorker{
 		eventQueue:       eventQueue,
 		schemaProcessor:  schemaProcessor,
 		streamingStorage: streamingStorage,
 		eventsCache:      eventsCache,
+		breaker:          breaker.Instance.Get(streamingStorage.Name()),
 	}
+
+	//spooled events from the previous run are written even if breakers have been disabled
+	if breaker.Instance.Enabled() || eventQueue.HasSpool() {
+		spool, err := eventQueue.Spool()
+		if err != nil {
+			destinationsLogger.WithDestination(streamingStorage.Name()).Errorf("Error opening circuit breaker spool: %v. Events won't be spooled", err)
+		} else {
+			sw.spool = spool
+		}
+	}
+
+	return sw
 }
 
 //Run goroutine to:
 //1. read from queue
 //2. Insert in events.StreamingStorage
+//3. commit event in queue (commit-after-write)
 func (sw *StreamingWorker) start() {
 	safego.RunWithRestart(func() {
 		for {
-			if sw.closed {
+			if sw.isClosed() {
 				break
 			}
 
-			fact, dequeuedTime, tokenId, err := sw.eventQueue.DequeueBlock()
+			fact, dequeuedTime, tokenId, attempts, err := sw.eventQueue.PeekBlock()
 			if err != nil {
-				if err == events.ErrQueueClosed && sw.closed {
+				if err == events.ErrQueueClosed && sw.isClosed() {
 					continue
 				}
-				logging.SystemErrorf("[%s] Error reading event fact from queue: %v", sw.streamingStorage.Name(), err)
+				destinationsLogger.WithDestination(sw.streamingStorage.Name()).SystemErrorf("Error reading event fact from queue: %v", err)
 				continue
 			}
 
-			//dequeued event was from retry call and retry timeout hasn't come
-			if time.Now().Before(dequeuedTime) {
-				sw.eventQueue.ConsumeTimed(fact, dequeuedTime, tokenId)
+			//events order is kept: new events are spooled until the spool is written
+			var ok bool
+			if sw.spool != nil && (!sw.breaker.Closed() || !sw.spool.IsEmpty()) {
+				ok = sw.divert(fact, dequeuedTime, tokenId, attempts)
+			} else {
+				ok = sw.process(fact, dequeuedTime, tokenId, attempts)
+			}
+			if !ok {
+				//event will be peeked one more time
+				time.Sleep(time.Second)
 				continue
 			}
 
-			serialized := fact.Serialize()
+			if err := sw.eventQueue.Commit(); err != nil && !sw.isClosed() {
+				destinationsLogger.WithDestination(sw.streamingStorage.Name()).SystemErrorf("Error committing event in queue: %v", err)
+			}
+		}
+	})
 
-			dataSchema, flattenObject, err := sw.schemaProcessor.ProcessFact(fact)
-			if err != nil {
-				logging.Errorf("[%s] Unable to process object %s: %v", sw.streamingStorage.Name(), serialized, err)
-				metrics.ErrorTokenEvent(tokenId, sw.streamingStorage.Name())
-				counters.ErrorEvents(sw.streamingStorage.Name(), 1)
-				//cache
-				sw.eventsCache.Error(sw.streamingStorage.Name(), events.ExtractEventId(fact), err.Error())
-				sw.streamingStorage.Fallback(&events.FailedFact{
-					Event:   []byte(serialized),
-					Error:   err.Error(),
-					EventId: events.ExtractEventId(fact),
-				})
+	if sw.spool != nil {
+		safego.RunWithRestart(sw.drainSpool)
+	}
+}
 
-				continue
-			}
+//drainSpool write spooled events in order when the circuit breaker allows writes (closed circuit or probe)
+//event is kept at the spool head while its write fails with retryable error
+func (sw *StreamingWorker) drainSpool() {
+	for {
+		if sw.isClosed() {
+			break
+		}
 
-			//don't process empty object
-			if !dataSchema.Exists() {
+		fact, _, tokenId, _, err := sw.spool.PeekBlock()
+		if err != nil {
+			if err == events.ErrQueueClosed && sw.isClosed() {
 				continue
 			}
+			destinationsLogger.WithDestination(sw.streamingStorage.Name()).SystemErrorf("Error reading event fact from spool: %v", err)
+			continue
+		}
 
-			if err := sw.streamingStorage.Insert(dataSchema, flattenObject); err != nil {
-				logging.Errorf("[%s] Error inserting object %s to table [%s]: %v", sw.streamingStorage.Name(), flattenObject.Serialize(), dataSchema.Name, err)
-				if strings.Contains(err.Error(), "connection refused") ||
-					strings.Contains(err.Error(), "EOF") ||
-					strings.Contains(err.Error(), "write: broken pipe") {
-					sw.eventQueue.ConsumeTimed(fact, time.Now().Add(20*time.Second), tokenId)
-				} else {
-					sw.streamingStorage.Fallback(&events.FailedFact{
-						Event:   []byte(serialized),
-						Error:   err.Error(),
-						EventId: events.ExtractEventId(flattenObject),
-					})
-				}
+		for !sw.isClosed() && !sw.breaker.Allow() {
+			time.Sleep(time.Second)
+		}
+		if sw.isClosed() {
+			break
+		}
 
-				counters.ErrorEvents(sw.streamingStorage.Name(), 1)
-				//cache
-				sw.eventsCache.Error(sw.streamingStorage.Name(), events.ExtractEventId(fact), err.Error())
+		if !sw.processSpooled(fact, tokenId) {
+			//event will be peeked one more time
+			continue
+		}
 
-				metrics.ErrorTokenEvent(tokenId, sw.streamingStorage.Name())
-				continue
-			}
+		if err := sw.spool.Commit(); err != nil && !sw.isClosed() {
+			destinationsLogger.WithDestination(sw.streamingStorage.Name()).SystemErrorf("Error committing event in spool: %v", err)
+		}
+	}
+}
 
-			counters.SuccessEvents(sw.streamingStorage.Name(), 1)
+//process event and return true if it can be committed: stored, sent to fallback or requeued for retry
+//retryable insert errors are retried according to the destination retry policy, exhausted ones are sent to fallback
+func (sw *StreamingWorker) process(fact events.Fact, dequeuedTime time.Time, tokenId string, attempts int) bool {
+	//dequeued event was from retry call and retry timeout hasn't come
+	if time.Now().Before(dequeuedTime) {
+		return sw.requeue(fact, dequeuedTime, tokenId, attempts)
+	}
 
-			//cache
-			sw.eventsCache.Succeed(sw.streamingStorage.Name(), events.ExtractEventId(fact), flattenObject, dataSchema, sw.streamingStorage.ColumnTypesMapping())
+	serialized := fact.Serialize()
+	flattenObject, err := sw.insert(fact, serialized, tokenId)
+	if err == nil {
+		return true
+	}
 
-			metrics.SuccessTokenEvent(tokenId, sw.streamingStorage.Name())
+	policy := retry.Get(sw.streamingStorage.Name())
+	attempt := attempts + 1
+	if policy.Retryable(err) {
+		sw.breaker.Failed()
+		//the circuit has been opened: the event is the first spooled one
+		if sw.spool != nil && !sw.breaker.Closed() {
+			health.Instance.Failed(sw.streamingStorage.Name())
+			return sw.divert(fact, dequeuedTime, tokenId, attempt)
 		}
+	}
+	if policy.ShouldRetry(err, attempt) {
+		backoff := policy.Backoff(attempt)
+		if !sw.requeue(fact, time.Now().Add(backoff), tokenId, attempt) {
+			return false
+		}
+		policy.Retried("insert", attempt, backoff, err)
+	} else {
+		if policy.Retryable(err) {
+			policy.Exhausted("insert", attempt, err)
+		}
+		sw.streamingStorage.Fallback(&events.FailedFact{
+			Event:   []byte(serialized),
+			Error:   err.Error(),
+			EventId: events.ExtractEventId(flattenObject),
+		})
+	}
+
+	sw.failed(fact, tokenId, err)
+	return true
+}
+
+//processSpooled write spooled event and return true if it can be committed: stored or sent to fallback.
+//retryable insert errors aren't retried here: they are counted by the circuit breaker
+func (sw *StreamingWorker) processSpooled(fact events.Fact, tokenId string) bool {
+	serialized := fact.Serialize()
+	flattenObject, err := sw.insert(fact, serialized, tokenId)
+	if err == nil {
+		return true
+	}
+
+	if retry.Get(sw.streamingStorage.Name()).Retryable(err) {
+		sw.breaker.Failed()
+		health.Instance.Failed(sw.streamingStorage.Name())
+		return false
+	}
+
+	//non-retryable error is a response of the destination: it is available
+	sw.breaker.Succeeded()
+	sw.streamingStorage.Fallback(&events.FailedFact{
+		Event:   []byte(serialized),
+		Error:   err.Error(),
+		EventId: events.ExtractEventId(flattenObject),
 	})
+	sw.failed(fact, tokenId, err)
+	return true
+}
+
+//insert process and write the event. Processing errors are sent to fallback (nil is returned), insert errors are returned
+func (sw *StreamingWorker) insert(fact events.Fact, serialized, tokenId string) (events.Fact, error) {
+	dataSchema, flattenObject, err := sw.schemaProcessor.ProcessFact(fact)
+	if err != nil {
+		destinationsLogger.WithDestination(sw.streamingStorage.Name()).WithEventId(events.ExtractEventId(fact)).Errorf("Unable to process object %s: %v", serialized, err)
+		metrics.ErrorTokenEvent(tokenId, sw.streamingStorage.Name())
+		counters.ErrorEvents(sw.streamingStorage.Name(), 1)
+		//cache
+		sw.eventsCache.Error(sw.streamingStorage.Name(), events.ExtractEventId(fact), err.Error())
+		sw.streamingStorage.Fallback(&events.FailedFact{
+			Event:   []byte(serialized),
+			Error:   err.Error(),
+			EventId: events.ExtractEventId(fact),
+		})
+
+		//nothing has been written: the probe (if it is) is attempted with the next event
+		sw.breaker.Skipped()
+		return nil, nil
+	}
+
+	//don't process empty object
+	if !dataSchema.Exists() {
+		sw.breaker.Skipped()
+		return nil, nil
+	}
+
+	//test-mode fault injection
+	err = faults.Instance.Inject(sw.streamingStorage.Name())
+	if err == nil {
+		err = sw.streamingStorage.Insert(dataSchema, flattenObject)
+	}
+	if err != nil {
+		destinationsLogger.WithDestination(sw.streamingStorage.Name()).WithEventId(events.ExtractEventId(flattenObject)).Errorf("Error inserting object %s to table [%s]: %v", flattenObject.Serialize(), dataSchema.Name, err)
+		return flattenObject, err
+	}
+
+	sw.breaker.Succeeded()
+	counters.SuccessEvents(sw.streamingStorage.Name(), 1)
+	health.Instance.Succeeded(sw.streamingStorage.Name())
+	reports.Instance.EventLatency(sw.streamingStorage.Name(), fact[timestamp.Key])
+	latency.Instance.Event(sw.streamingStorage.Name(), fact)
+
+	//cache
+	sw.eventsCache.Succeed(sw.streamingStorage.Name(), events.ExtractEventId(fact), flattenObject, dataSchema, sw.streamingStorage.ColumnTypesMapping())
+
+	metrics.SuccessTokenEvent(tokenId, sw.streamingStorage.Name())
+	return flattenObject, nil
+}
+
+//failed count the failed write of the event which won't be retried
+func (sw *StreamingWorker) failed(fact events.Fact, tokenId string, err error) {
+	counters.ErrorEvents(sw.streamingStorage.Name(), 1)
+	health.Instance.Failed(sw.streamingStorage.Name())
+	//cache
+	sw.eventsCache.Error(sw.streamingStorage.Name(), events.ExtractEventId(fact), err.Error())
+
+	metrics.ErrorTokenEvent(tokenId, sw.streamingStorage.Name())
+}
+
+//divert put event to the end of the spool
+//return false if it can't be done: the event mustn't be committed
+func (sw *StreamingWorker) divert(fact events.Fact, dequeuedTime time.Time, tokenId string, attempts int) bool {
+	if err := sw.spool.Requeue(fact, dequeuedTime, tokenId, attempts); err != nil {
+		destinationsLogger.WithDestination(sw.streamingStorage.Name()).SystemErrorf("Error spooling event: %v", err)
+		return false
+	}
+	metrics.DestinationSpooled(sw.streamingStorage.Name())
+	return true
+}
+
+//requeue put event to the end of the queue for retry
+//return false if it can't be done (e.g. the queue is full): the event mustn't be committed
+func (sw *StreamingWorker) requeue(fact events.Fact, retryTime time.Time, tokenId string, attempts int) bool {
+	if err := sw.eventQueue.Requeue(fact, retryTime, tokenId, attempts); err != nil {
+		destinationsLogger.WithDestination(sw.streamingStorage.Name()).Errorf("Error requeuing event: %v", err)
+		return false
+	}
+	return true
+}
+
+func (sw *StreamingWorker) isClosed() bool {
+	return atomic.LoadUint32(&sw.closed) == 1
 }
 
 func (sw *StreamingWorker) Close() error {
-	sw.closed = true
+	atomic.StoreUint32(&sw.closed, 1)
 	return nil
 }
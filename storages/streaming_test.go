@@ -0,0 +1,104 @@
+package storages
+
+import (
+	"github.com/jitsucom/eventnative/breaker"
+	"github.com/jitsucom/eventnative/caching"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/faults"
+	"github.com/jitsucom/eventnative/timestamp"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestStreamingSpool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	breaker.Init(&breaker.Config{Enabled: true, FailureThreshold: 1, ProbeIntervalSec: 1})
+	faults.Init(true)
+	defer func() {
+		breaker.Instance = breaker.NewRegistry(nil)
+		faults.Instance = faults.NewInjector(false)
+	}()
+	require.NoError(t, faults.Instance.Set("mem", &faults.Fault{ErrorRate: 1, ErrorMessage: "connection refused"}))
+
+	processor, err := NewProcessor("mem", DestinationConfig{Type: MemoryType, Mode: StreamMode})
+	require.NoError(t, err)
+	eventQueue, err := events.NewPersistentQueue("mem", dir, 0)
+	require.NoError(t, err)
+	defer eventQueue.Close()
+	memory := NewMemory("mem", eventQueue, processor, false, true, caching.NewEventsCache(caching.NewMemoryEventsStorage(), 10))
+	defer memory.Close()
+
+	for i := 0; i < 5; i++ {
+		eventQueue.Consume(events.Fact{"id": i, timestamp.Key: "2021-01-01T00:00:00.000000Z"}, "token")
+	}
+
+	//the first failed insert opens the circuit: all events are spooled
+	spool, err := eventQueue.Spool()
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return eventQueue.IsEmpty() && !spool.IsEmpty() }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, breaker.StateOpen, breaker.Instance.Get("mem").Status().State)
+	require.Empty(t, memory.Tables())
+
+	//the destination has recovered: spooled events are written in order after the probe
+	faults.Instance.Remove("mem")
+	require.Eventually(t, func() bool {
+		tables := memory.Tables()
+		return len(tables) == 1 && tables[0].Total == 5
+	}, 10*time.Second, 10*time.Millisecond)
+	var ids []interface{}
+	for _, row := range memory.Tables()[0].Rows {
+		ids = append(ids, row["id"])
+	}
+	require.Equal(t, []interface{}{int64(0), int64(1), int64(2), int64(3), int64(4)}, ids)
+	require.True(t, breaker.Instance.Get("mem").Closed())
+	require.True(t, spool.IsEmpty())
+}
+
+func TestStreamingSpoolNonRetryableProbe(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	breaker.Init(&breaker.Config{Enabled: true, FailureThreshold: 1, ProbeIntervalSec: 1})
+	faults.Init(true)
+	defer func() {
+		breaker.Instance = breaker.NewRegistry(nil)
+		faults.Instance = faults.NewInjector(false)
+	}()
+	require.NoError(t, faults.Instance.Set("mem", &faults.Fault{ErrorRate: 1, ErrorMessage: "connection refused"}))
+
+	processor, err := NewProcessor("mem", DestinationConfig{Type: MemoryType, Mode: StreamMode})
+	require.NoError(t, err)
+	eventQueue, err := events.NewPersistentQueue("mem", dir, 0)
+	require.NoError(t, err)
+	defer eventQueue.Close()
+	memory := NewMemory("mem", eventQueue, processor, false, true, caching.NewEventsCache(caching.NewMemoryEventsStorage(), 10))
+	defer memory.Close()
+
+	for i := 0; i < 3; i++ {
+		eventQueue.Consume(events.Fact{"id": i, timestamp.Key: "2021-01-01T00:00:00.000000Z"}, "token")
+	}
+	spool, err := eventQueue.Spool()
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return eventQueue.IsEmpty() && !spool.IsEmpty() }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, breaker.StateOpen, breaker.Instance.Get("mem").Status().State)
+
+	//the probe event is rejected with non-retryable error: it is sent to fallback and the circuit is closed
+	require.NoError(t, faults.Instance.Set("mem", &faults.Fault{ErrorRate: 1, ErrorMessage: "invalid input syntax"}))
+	require.Eventually(t, func() bool { return breaker.Instance.Get("mem").Closed() }, 10*time.Second, 10*time.Millisecond)
+
+	//the destination writes the rest events
+	faults.Instance.Remove("mem")
+	eventQueue.Consume(events.Fact{"id": 3, timestamp.Key: "2021-01-01T00:00:00.000000Z"}, "token")
+	require.Eventually(t, func() bool {
+		tables := memory.Tables()
+		return spool.IsEmpty() && eventQueue.IsEmpty() && len(tables) == 1 && tables[0].Total > 0
+	}, 10*time.Second, 10*time.Millisecond)
+	require.True(t, breaker.Instance.Get("mem").Closed())
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/jitsucom/eventnative/adapters"
 	"github.com/jitsucom/eventnative/notifications"
 	"github.com/jitsucom/eventnative/schema"
+	"sync"
 )
 
 const unlockRetryCount = 5
@@ -16,23 +17,74 @@ type TableHelper struct {
 	manager       adapters.TableManager
 	monitorKeeper MonitorKeeper
 	tables        map[string]*schema.Table
+	columnTypes   *ColumnTypesRegistry
 	storageType   string
+
+	//0 - table columns aren't guarded
+	maxColumns    int
+	columnsPolicy string
+
+	//auto or review
+	migrationsMode string
+	//table name -> migration plan which is waiting for review
+	plans      map[string]*schema.MigrationPlan
+	plansMutex sync.RWMutex
 }
 
-func NewTableHelper(manager adapters.TableManager, monitorKeeper MonitorKeeper, storageType string) *TableHelper {
+//NewTableHelper return TableHelper with max table columns from limits or the warehouse columns cap
+//migrationsMode is auto (default) or review
+func NewTableHelper(manager adapters.TableManager, monitorKeeper MonitorKeeper, columnTypes *ColumnTypesRegistry, storageType string, limits *schema.Limits, migrationsMode string) *TableHelper {
+	maxColumns := warehouseColumnsCaps[storageType]
+	columnsPolicy := schema.FallbackPolicy
+	if limits != nil {
+		if limits.MaxTableColumns > 0 {
+			maxColumns = limits.MaxTableColumns
+		}
+		//policy has been already validated by schema.Processor
+		if policy, err := limits.GetPolicy(); err == nil {
+			columnsPolicy = policy
+		}
+	}
+	if migrationsMode == "" {
+		migrationsMode = AutoMigrations
+	}
+
 	return &TableHelper{
 		manager:       manager,
 		monitorKeeper: monitorKeeper,
 		tables:        map[string]*schema.Table{},
+		columnTypes:   columnTypes,
 		storageType:   storageType,
+		maxColumns:    maxColumns,
+		columnsPolicy: columnsPolicy,
+
+		migrationsMode: migrationsMode,
+		plans:          map[string]*schema.MigrationPlan{},
 	}
 }
 
 //EnsureTable return DB table schema and err if occurred
 //if table doesn't exist - create a new one and increment version
-//if exists - calculate diff, patch existing one with diff and increment version
+//if exists - calculate migration plan, apply it (or keep it for review) and increment version
 //return actual db table schema (with actual db types)
+//discovered and actual db column types are saved in ColumnTypesRegistry
 func (th *TableHelper) EnsureTable(destinationName string, dataSchema *schema.Table) (*schema.Table, error) {
+	th.columnTypes.Discovered(dataSchema)
+
+	dbTableSchema, err := th.ensureTable(destinationName, dataSchema)
+	if err != nil {
+		//e.g. incompatible types: keep the last known db schema in the registry
+		if cached, ok := th.tables[dataSchema.Name]; ok {
+			th.columnTypes.Warehouse(cached)
+		}
+		return nil, err
+	}
+
+	th.columnTypes.Warehouse(dbTableSchema)
+	return dbTableSchema, nil
+}
+
+func (th *TableHelper) ensureTable(destinationName string, dataSchema *schema.Table) (*schema.Table, error) {
 	var err error
 	dbTableSchema, ok := th.tables[dataSchema.Name]
 
@@ -47,14 +99,14 @@ func (th *TableHelper) EnsureTable(destinationName string, dataSchema *schema.Ta
 		th.tables[dbTableSchema.Name] = dbTableSchema
 	}
 
-	schemaDiff, err := dbTableSchema.Diff(dataSchema)
+	plan, err := schema.NewMigrationPlan(dbTableSchema, dataSchema)
 	if err != nil {
 		return nil, err
 	}
 	pkPatch := schema.NewPkFieldsPatch(dbTableSchema, dataSchema)
 
 	//if diff doesn't exist - do nothing
-	if !schemaDiff.Exists() && !pkPatch.Exists() {
+	if !plan.Exists() && !pkPatch.Exists() {
 		return dbTableSchema, nil
 	}
 
@@ -80,8 +132,9 @@ func (th *TableHelper) EnsureTable(destinationName string, dataSchema *schema.Ta
 		}
 
 		dbTableSchema.Version = ver
+		th.tables[dbTableSchema.Name] = dbTableSchema
 
-		schemaDiff, err = dbTableSchema.Diff(dataSchema)
+		plan, err = schema.NewMigrationPlan(dbTableSchema, dataSchema)
 		if err != nil {
 			return nil, err
 		}
@@ -89,13 +142,13 @@ func (th *TableHelper) EnsureTable(destinationName string, dataSchema *schema.Ta
 	}
 
 	//check if newSchemaDiff doesn't exist - do nothing
-	if !schemaDiff.Exists() && !pkPatch.Exists() {
+	if !plan.Exists() && !pkPatch.Exists() {
 		return dbTableSchema, nil
 	}
 
-	//patch and increment table version
-	if schemaDiff.Exists() {
-		if err := th.manager.PatchTableSchema(schemaDiff); err != nil {
+	//migrate and increment table version
+	if plan.Exists() {
+		if err := th.migrate(plan); err != nil {
 			return nil, err
 		}
 
@@ -105,9 +158,7 @@ func (th *TableHelper) EnsureTable(destinationName string, dataSchema *schema.Ta
 		}
 
 		//Save
-		for k, v := range schemaDiff.Columns {
-			dbTableSchema.Columns[k] = v
-		}
+		plan.Apply(dbTableSchema)
 		dbTableSchema.Version = newVersion
 	}
 
@@ -126,6 +177,28 @@ func (th *TableHelper) EnsureTable(destinationName string, dataSchema *schema.Ta
 	return dbTableSchema, nil
 }
 
+//AddColumns add columns of patchSchema into the existing table under the table lock and increment table version:
+//cached table schemas are re-read on the next EnsureTable call. It doesn't use the cache (can be called from another goroutine)
+func (th *TableHelper) AddColumns(destinationName string, patchSchema *schema.Table) error {
+	lock, err := th.monitorKeeper.Lock(destinationName, patchSchema.Name)
+	if err != nil {
+		msg := fmt.Sprintf("System error: Unable to lock table %s in %s: %v", patchSchema.Name, th.storageType, err)
+		notifications.SystemError(msg)
+		return errors.New(msg)
+	}
+	defer th.monitorKeeper.Unlock(lock)
+
+	if err := th.manager.PatchTableSchema(patchSchema); err != nil {
+		return err
+	}
+
+	if _, err := th.monitorKeeper.IncrementVersion(destinationName, patchSchema.Name); err != nil {
+		return fmt.Errorf("Error incrementing version in storage [%s]: %v", th.storageType, err)
+	}
+
+	return nil
+}
+
 //lock table -> get existing schema -> create a new one if doesn't exist -> return schema with version
 func (th *TableHelper) getOrCreate(destinationName string, dataSchema *schema.Table) (*schema.Table, error) {
 	lock, err := th.monitorKeeper.Lock(destinationName, dataSchema.Name)
@@ -7,4 +7,11 @@ const (
 	ClickHouseType = "clickhouse"
 	S3Type         = "s3"
 	SnowflakeType  = "snowflake"
+	MemoryType     = "memory"
+	AggregatesType = "aggregates"
 )
+
+//Pinger is a destination which supports connectivity check (see /ready endpoint)
+type Pinger interface {
+	Ping() error
+}
@@ -0,0 +1,252 @@
+package storages
+
+import (
+	"fmt"
+	"github.com/jitsucom/eventnative/schema"
+	"github.com/jitsucom/eventnative/timestamp"
+	"github.com/jitsucom/eventnative/typing"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	FirstSeenColumn = "first_seen"
+	LastSeenColumn  = "last_seen"
+
+	defaultUserProfilesTable        = "users"
+	defaultUserProfilesEventType    = "identify"
+	defaultUserProfilesTraitsPrefix = "eventn_ctx_user_"
+	defaultUserProfilesPkField      = "id"
+	userProfilesEventTypeColumn     = "event_type"
+)
+
+//UserProfiles is a materialized user profiles table configuration: identify events of every load are folded into one
+//upserted row per user (primary key fields) with the latest traits and first_seen/last_seen times of the user events
+type UserProfiles struct {
+	//users if empty
+	Table string `mapstructure:"table" json:"table,omitempty" yaml:"table,omitempty"`
+	//event_type values of folded events. Default: [identify]
+	EventTypes []string `mapstructure:"event_types" json:"event_types,omitempty" yaml:"event_types,omitempty"`
+	//flat columns prefix of user traits. Traits are written into the table without the prefix. Default: eventn_ctx_user_
+	TraitsPrefix string `mapstructure:"traits_prefix" json:"traits_prefix,omitempty" yaml:"traits_prefix,omitempty"`
+	//profile columns (traits without prefix) which identify the user. Default: [id]
+	PrimaryKeyFields []string `mapstructure:"primary_key_fields" json:"primary_key_fields,omitempty" yaml:"primary_key_fields,omitempty"`
+	//_timestamp if empty
+	TimeColumn string `mapstructure:"time_column" json:"time_column,omitempty" yaml:"time_column,omitempty"`
+}
+
+func (up *UserProfiles) String() string {
+	profiles := up.withDefaults()
+	return fmt.Sprintf("[%s] table of %v events traits (%s*) by %v, time column: %s", profiles.Table, profiles.EventTypes,
+		profiles.TraitsPrefix, profiles.PrimaryKeyFields, profiles.TimeColumn)
+}
+
+//withDefaults return copy of the configuration with default values of empty fields
+func (up *UserProfiles) withDefaults() UserProfiles {
+	profiles := *up
+	if profiles.Table == "" {
+		profiles.Table = defaultUserProfilesTable
+	}
+	if len(profiles.EventTypes) == 0 {
+		profiles.EventTypes = []string{defaultUserProfilesEventType}
+	}
+	if profiles.TraitsPrefix == "" {
+		profiles.TraitsPrefix = defaultUserProfilesTraitsPrefix
+	}
+	if len(profiles.PrimaryKeyFields) == 0 {
+		profiles.PrimaryKeyFields = []string{defaultUserProfilesPkField}
+	}
+	if profiles.TimeColumn == "" {
+		profiles.TimeColumn = timestamp.Key
+	}
+	return profiles
+}
+
+//Profiles folds identify events of loaded files into the user profiles table file. Nil Profiles doesn't fold anything
+type Profiles struct {
+	table        string
+	eventTypes   map[string]bool
+	traitsPrefix string
+	pkFields     []string
+	timeColumn   string
+}
+
+//NewProfiles return Profiles or nil if user profiles aren't configured
+func NewProfiles(config *UserProfiles) (*Profiles, error) {
+	if config == nil {
+		return nil, nil
+	}
+
+	profiles := config.withDefaults()
+	for _, field := range profiles.PrimaryKeyFields {
+		if field == FirstSeenColumn || field == LastSeenColumn {
+			return nil, fmt.Errorf("user_profiles primary_key_fields can't contain %s column", field)
+		}
+	}
+	eventTypes := map[string]bool{}
+	for _, eventType := range profiles.EventTypes {
+		eventTypes[eventType] = true
+	}
+
+	return &Profiles{
+		table:        profiles.Table,
+		eventTypes:   eventTypes,
+		traitsPrefix: profiles.TraitsPrefix,
+		pkFields:     profiles.PrimaryKeyFields,
+		timeColumn:   profiles.TimeColumn,
+	}, nil
+}
+
+//foldedProfile is a user profile row with times of the latest trait values
+type foldedProfile struct {
+	row        map[string]interface{}
+	traitTimes map[string]time.Time
+	firstSeen  time.Time
+	lastSeen   time.Time
+}
+
+//Fold return the profiles table file with one row per user of identify events in all files (the latest traits win)
+//or nil if there aren't such events. Events without time or primary key fields are skipped
+func (p *Profiles) Fold(flatData map[string]*schema.ProcessedFile) *schema.ProcessedFile {
+	if p == nil {
+		return nil
+	}
+
+	tables := make([]string, 0, len(flatData))
+	for table := range flatData {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	pkFields := map[string]bool{}
+	for _, field := range p.pkFields {
+		pkFields[field] = true
+	}
+	dataSchema := &schema.Table{Name: p.table, Columns: schema.Columns{
+		FirstSeenColumn: schema.NewColumn(typing.TIMESTAMP),
+		LastSeenColumn:  schema.NewColumn(typing.TIMESTAMP),
+	}, PKFields: pkFields}
+
+	folded := map[string]*foldedProfile{}
+	for _, table := range tables {
+		fdata := flatData[table]
+		for _, object := range fdata.GetPayload() {
+			eventType, _ := object[userProfilesEventTypeColumn].(string)
+			if !p.eventTypes[eventType] {
+				continue
+			}
+			eventTime, ok := timeValue(object[p.timeColumn])
+			if !ok {
+				continue
+			}
+
+			traits := map[string]interface{}{}
+			//trait -> flat column name
+			columns := map[string]string{}
+			for name, value := range object {
+				if value == nil || !strings.HasPrefix(name, p.traitsPrefix) {
+					continue
+				}
+				trait := strings.TrimPrefix(name, p.traitsPrefix)
+				if trait == "" || trait == FirstSeenColumn || trait == LastSeenColumn {
+					continue
+				}
+				traits[trait] = value
+				columns[trait] = name
+			}
+
+			key, ok := p.key(traits)
+			if !ok {
+				continue
+			}
+			for trait, name := range columns {
+				if _, ok := dataSchema.Columns[trait]; !ok && fdata.DataSchema != nil {
+					dataSchema.Columns[trait] = fdata.DataSchema.Columns[name]
+				}
+			}
+			profile, ok := folded[key]
+			if !ok {
+				profile = &foldedProfile{row: map[string]interface{}{}, traitTimes: map[string]time.Time{}, firstSeen: eventTime, lastSeen: eventTime}
+				folded[key] = profile
+			}
+			for trait, value := range traits {
+				if traitTime, ok := profile.traitTimes[trait]; !ok || !eventTime.Before(traitTime) {
+					profile.row[trait] = value
+					profile.traitTimes[trait] = eventTime
+				}
+			}
+			if eventTime.Before(profile.firstSeen) {
+				profile.firstSeen = eventTime
+			}
+			if eventTime.After(profile.lastSeen) {
+				profile.lastSeen = eventTime
+			}
+		}
+	}
+
+	if len(folded) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(folded))
+	for key := range folded {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	payload := make([]map[string]interface{}, 0, len(keys))
+	for _, key := range keys {
+		profile := folded[key]
+		profile.row[FirstSeenColumn] = profile.firstSeen
+		profile.row[LastSeenColumn] = profile.lastSeen
+		payload = append(payload, profile.row)
+	}
+
+	pf := &schema.ProcessedFile{DataSchema: dataSchema}
+	pf.SetPayload(payload)
+	return pf
+}
+
+//key return joined primary key values or false if any of them is missing
+func (p *Profiles) key(traits map[string]interface{}) (string, bool) {
+	values := make([]string, 0, len(p.pkFields))
+	for _, field := range p.pkFields {
+		value, ok := traits[field]
+		if !ok {
+			return "", false
+		}
+		values = append(values, fmt.Sprint(value))
+	}
+	return strings.Join(values, "\x00"), true
+}
+
+//timeValue return time of typed (time.Time) or not typed (timestamp.Layout string) time column value
+func timeValue(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, true
+	case string:
+		t, err := time.Parse(timestamp.Layout, v)
+		return t, err == nil
+	default:
+		return time.Time{}, false
+	}
+}
+
+//validateUserProfiles return err if user profiles are misconfigured or aren't supported by the destination
+func validateUserProfiles(destination DestinationConfig) error {
+	if destination.UserProfiles == nil {
+		return nil
+	}
+
+	if destination.Type != PostgresType {
+		return fmt.Errorf("user_profiles aren't supported by %s destination", destination.Type)
+	}
+	if destination.Mode == StreamMode {
+		return fmt.Errorf("user_profiles are folded from batch loads and aren't supported in %s mode", StreamMode)
+	}
+
+	_, err := NewProfiles(destination.UserProfiles)
+	return err
+}
@@ -0,0 +1,59 @@
+package storages
+
+import (
+	"github.com/jitsucom/eventnative/schema"
+	"github.com/jitsucom/eventnative/typing"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestProfilesFold(t *testing.T) {
+	profiles, err := NewProfiles(&UserProfiles{})
+	require.NoError(t, err)
+
+	t1 := time.Date(2020, 9, 1, 10, 0, 0, 0, time.UTC)
+	t2 := t1.Add(time.Hour)
+	t3 := t2.Add(time.Hour)
+	events := &schema.ProcessedFile{DataSchema: &schema.Table{Name: "events", Columns: schema.Columns{
+		"eventn_ctx_user_id":    schema.NewColumn(typing.STRING),
+		"eventn_ctx_user_email": schema.NewColumn(typing.STRING),
+		"eventn_ctx_user_plan":  schema.NewColumn(typing.STRING),
+	}}}
+	events.SetPayload([]map[string]interface{}{
+		//the latest traits win regardless of the order in the batch
+		{"event_type": "identify", "_timestamp": t2, "eventn_ctx_user_id": "u1", "eventn_ctx_user_email": "new@a.com"},
+		{"event_type": "identify", "_timestamp": t1, "eventn_ctx_user_id": "u1", "eventn_ctx_user_email": "old@a.com", "eventn_ctx_user_plan": "free"},
+		{"event_type": "pageview", "_timestamp": t3, "eventn_ctx_user_id": "u1", "eventn_ctx_user_plan": "pro"},
+		//without primary key field
+		{"event_type": "identify", "_timestamp": t3, "eventn_ctx_user_anonymous_id": "a1"},
+	})
+	segment := &schema.ProcessedFile{DataSchema: &schema.Table{Name: "segment"}}
+	segment.SetPayload([]map[string]interface{}{
+		{"event_type": "identify", "_timestamp": "2020-09-01T12:00:00.000000Z", "eventn_ctx_user_id": "u2", "eventn_ctx_user_first_seen": "x"},
+	})
+
+	folded := profiles.Fold(map[string]*schema.ProcessedFile{"events": events, "segment": segment})
+	require.NotNil(t, folded)
+	require.Equal(t, "users", folded.DataSchema.Name)
+	require.Equal(t, map[string]bool{"id": true}, folded.DataSchema.PKFields)
+	require.Equal(t, typing.STRING, folded.DataSchema.Columns["email"].GetType())
+	require.Equal(t, typing.TIMESTAMP, folded.DataSchema.Columns[FirstSeenColumn].GetType())
+	require.Equal(t, []map[string]interface{}{
+		{"id": "u1", "email": "new@a.com", "plan": "free", FirstSeenColumn: t1, LastSeenColumn: t2},
+		{"id": "u2", FirstSeenColumn: t3, LastSeenColumn: t3},
+	}, folded.GetPayload())
+
+	require.Nil(t, profiles.Fold(map[string]*schema.ProcessedFile{"segment": {DataSchema: &schema.Table{Name: "segment"}}}))
+	require.Nil(t, (*Profiles)(nil).Fold(map[string]*schema.ProcessedFile{"events": events}))
+}
+
+func TestValidateUserProfiles(t *testing.T) {
+	require.NoError(t, validateUserProfiles(DestinationConfig{Type: PostgresType, Mode: BatchMode, UserProfiles: &UserProfiles{}}))
+	require.EqualError(t, validateUserProfiles(DestinationConfig{Type: BigQueryType, Mode: BatchMode, UserProfiles: &UserProfiles{}}),
+		"user_profiles aren't supported by bigquery destination")
+	require.EqualError(t, validateUserProfiles(DestinationConfig{Type: PostgresType, Mode: StreamMode, UserProfiles: &UserProfiles{}}),
+		"user_profiles are folded from batch loads and aren't supported in stream mode")
+	require.EqualError(t, validateUserProfiles(DestinationConfig{Type: PostgresType, Mode: BatchMode, UserProfiles: &UserProfiles{PrimaryKeyFields: []string{"last_seen"}}}),
+		"user_profiles primary_key_fields can't contain last_seen column")
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"github.com/jitsucom/eventnative/ledger"
 	"github.com/jitsucom/eventnative/logging"
 	"github.com/jitsucom/eventnative/schema"
 	"strconv"
@@ -42,6 +43,23 @@ func extractDataFromFileName(fileKey string) (string, string, int, error) {
 	return names[1], regexTokenResult[1], rowsCount, nil
 }
 
+//stageOnce upload file to the stage (S3, GCS) and record it in the ledger
+//if the same file (key and checksum) has already been staged or loaded - it is skipped (e.g. re-run of the log file after crash)
+func stageOnce(destinationId, fileKey, table string, rows int, payload []byte, upload func(string, []byte) error) error {
+	checksum := ledger.Checksum(payload)
+	if entry, ok := ledger.Instance.Find(destinationId, fileKey, checksum); ok {
+		destinationsLogger.WithDestination(destinationId).Infof("file %s has already been %s (load id: %s). Skipping", fileKey, entry.Status, entry.LoadId)
+		return nil
+	}
+
+	if err := upload(fileKey, payload); err != nil {
+		return err
+	}
+
+	ledger.Instance.Stage(destinationId, fileKey, checksum, table, rows)
+	return nil
+}
+
 //return rows count from byte array
 func linesCount(s []byte) int {
 	nl := []byte{'\n'}
@@ -0,0 +1,67 @@
+package storages
+
+import (
+	"context"
+	"fmt"
+	"github.com/jitsucom/eventnative/events"
+	"io/ioutil"
+	"os"
+)
+
+type discardWriter struct{}
+
+func (discardWriter) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func (discardWriter) Close() error {
+	return nil
+}
+
+//Validate compile the destination configuration the same way as Create does: mapping, enrichment rules,
+//table name template, filter, sampling, hooks, views, etc. If connect is true - the destination is created (test connection)
+//with temporary events queue and discarded fallback and closed right away. Return all found problems
+func Validate(ctx context.Context, name string, destination DestinationConfig, monitorKeeper MonitorKeeper, connect bool) []error {
+	enrichDefaults(name, &destination)
+
+	var problems []error
+	factoryMethod, ok := factoryMethods[destination.Type]
+	if !ok {
+		problems = append(problems, fmt.Errorf("%v: %s", unknownDestination, destination.Type))
+	}
+
+	storageConfig, err := newStorageConfig(ctx, name, &destination, monitorKeeper, ioutil.Discard, nil)
+	if err != nil {
+		problems = append(problems, err)
+	}
+	if len(problems) > 0 || !connect {
+		return problems
+	}
+
+	storageConfig.fallBackLoggerFactoryMethod = func() *events.AsyncLogger {
+		return events.NewAsyncLogger(discardWriter{}, false)
+	}
+	if storageConfig.streamMode {
+		queueDir, err := ioutil.TempDir("", "eventnative-validate")
+		if err != nil {
+			return []error{fmt.Errorf("Error creating temporary events queue dir: %v", err)}
+		}
+		defer os.RemoveAll(queueDir)
+
+		eventQueue, err := events.NewPersistentQueue(name, queueDir, 0)
+		if err != nil {
+			return []error{err}
+		}
+		defer eventQueue.Close()
+		storageConfig.eventQueue = eventQueue
+	}
+
+	storage, err := factoryMethod(storageConfig)
+	if err != nil {
+		return []error{fmt.Errorf("Error connecting to destination: %v", err)}
+	}
+	if err := storage.Close(); err != nil {
+		destinationsLogger.WithDestination(name).Warnf("Error closing validated destination: %v", err)
+	}
+	return nil
+}
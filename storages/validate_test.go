@@ -0,0 +1,30 @@
+package storages
+
+import (
+	"context"
+	"github.com/jitsucom/eventnative/schema"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		destination DestinationConfig
+		problems    int
+	}{
+		{"valid stream", DestinationConfig{Type: MemoryType, Mode: StreamMode, Filter: `event_type == "pageview"`}, 0},
+		{"valid batch", DestinationConfig{Type: MemoryType, DataLayout: &DataLayout{TableNameTemplate: "{{.event_type}}"}}, 0},
+		{"unknown type", DestinationConfig{Type: "unknown"}, 1},
+		{"unknown type and mode", DestinationConfig{Type: "unknown", Mode: "sometimes"}, 2},
+		{"wrong filter", DestinationConfig{Type: MemoryType, Filter: `event_type ==`}, 1},
+		{"wrong template", DestinationConfig{Type: MemoryType, DataLayout: &DataLayout{TableNameTemplate: "{{.event_type"}}, 1},
+		{"wrong sampling", DestinationConfig{Type: MemoryType, Sampling: &schema.Sampling{Rate: 2}}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := Validate(context.Background(), "test", tt.destination, nil, true)
+			require.Len(t, problems, tt.problems, "%v", problems)
+		})
+	}
+}
@@ -0,0 +1,309 @@
+package storages
+
+import (
+	"fmt"
+	"github.com/jitsucom/eventnative/metrics"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	HourGranularity  = "hour"
+	DayGranularity   = "day"
+	WeekGranularity  = "week"
+	MonthGranularity = "month"
+
+	CountAggregate         = "count"
+	CountDistinctAggregate = "count_distinct"
+	SumAggregate           = "sum"
+	AvgAggregate           = "avg"
+	MinAggregate           = "min"
+	MaxAggregate           = "max"
+
+	defaultViewTimeColumn = "_timestamp"
+
+	createViewOperation  = "create"
+	refreshViewOperation = "refresh"
+
+	createMaterializedViewTemplate  = `CREATE MATERIALIZED VIEW "%s"."%s" AS SELECT %s FROM "%s"."%s"%s GROUP BY %s`
+	refreshMaterializedViewTemplate = `REFRESH MATERIALIZED VIEW "%s"."%s"`
+)
+
+var (
+	granularities = []string{HourGranularity, DayGranularity, WeekGranularity, MonthGranularity}
+	aggregates    = []string{CountAggregate, CountDistinctAggregate, SumAggregate, AvgAggregate, MinAggregate, MaxAggregate}
+)
+
+//ViewAggregate is an aggregated column of the view: function(column) AS as
+type ViewAggregate struct {
+	Function string `mapstructure:"function" json:"function,omitempty" yaml:"function,omitempty"`
+	//required for all functions except count (count(*) if empty)
+	Column string `mapstructure:"column" json:"column,omitempty" yaml:"column,omitempty"`
+	//result column name. function_column (or count) if empty
+	As string `mapstructure:"as" json:"as,omitempty" yaml:"as,omitempty"`
+}
+
+func (va *ViewAggregate) alias() string {
+	if va.As != "" {
+		return va.As
+	}
+	if va.Column == "" {
+		return va.Function
+	}
+	return va.Function + "_" + va.Column
+}
+
+func (va *ViewAggregate) expression() string {
+	column := "*"
+	if va.Column != "" {
+		column = quoteIdentifier(va.Column)
+	}
+	if va.Function == CountDistinctAggregate {
+		return fmt.Sprintf("count(DISTINCT %s) AS %s", column, quoteIdentifier(va.alias()))
+	}
+	return fmt.Sprintf("%s(%s) AS %s", va.Function, column, quoteIdentifier(va.alias()))
+}
+
+//View is a rollup of the destination table (e.g. daily events per type or revenue per day) which is managed as
+//a materialized view: it is created after the first load into the table and refreshed after the next loads
+type View struct {
+	Name  string `mapstructure:"name" json:"name,omitempty" yaml:"name,omitempty"`
+	Table string `mapstructure:"table" json:"table,omitempty" yaml:"table,omitempty"`
+	//hour, day (default), week or month. The truncated time column is the first view column named as granularity
+	Granularity string `mapstructure:"granularity" json:"granularity,omitempty" yaml:"granularity,omitempty"`
+	//_timestamp if empty
+	TimeColumn string   `mapstructure:"time_column" json:"time_column,omitempty" yaml:"time_column,omitempty"`
+	GroupBy    []string `mapstructure:"group_by" json:"group_by,omitempty" yaml:"group_by,omitempty"`
+	//count(*) AS count if empty
+	Aggregates []*ViewAggregate `mapstructure:"aggregates" json:"aggregates,omitempty" yaml:"aggregates,omitempty"`
+	//optional SQL condition (e.g. event_type = 'purchase')
+	Where string `mapstructure:"where" json:"where,omitempty" yaml:"where,omitempty"`
+	//the view is refreshed not more often than once in the interval. 0 - after every load
+	RefreshIntervalMin int `mapstructure:"refresh_interval_min" json:"refresh_interval_min,omitempty" yaml:"refresh_interval_min,omitempty"`
+}
+
+func (v *View) String() string {
+	refresh := "after every load"
+	if v.RefreshIntervalMin > 0 {
+		refresh = fmt.Sprintf("every %d min after loads", v.RefreshIntervalMin)
+	}
+	return fmt.Sprintf("%s: %s rollup of [%s] table by %v, refresh: %s", v.Name, v.granularity(), v.Table, v.GroupBy, refresh)
+}
+
+func (v *View) granularity() string {
+	if v.Granularity == "" {
+		return DayGranularity
+	}
+	return v.Granularity
+}
+
+func (v *View) timeColumn() string {
+	if v.TimeColumn == "" {
+		return defaultViewTimeColumn
+	}
+	return v.TimeColumn
+}
+
+func (v *View) aggregates() []*ViewAggregate {
+	if len(v.Aggregates) == 0 {
+		return []*ViewAggregate{{Function: CountAggregate}}
+	}
+	return v.Aggregates
+}
+
+//validate return err if the view can't be built
+func (v *View) validate() error {
+	if v.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if v.Table == "" {
+		return fmt.Errorf("table is required")
+	}
+	if !contains(granularities, v.granularity()) {
+		return fmt.Errorf("unknown granularity: %s. Available: %v", v.Granularity, granularities)
+	}
+	if v.RefreshIntervalMin < 0 {
+		return fmt.Errorf("refresh_interval_min must be >= 0")
+	}
+
+	identifiers := []string{v.Name, v.Table, v.timeColumn()}
+	identifiers = append(identifiers, v.GroupBy...)
+	for _, aggregate := range v.aggregates() {
+		if !contains(aggregates, aggregate.Function) {
+			return fmt.Errorf("unknown aggregate function: %s. Available: %v", aggregate.Function, aggregates)
+		}
+		if aggregate.Column == "" && aggregate.Function != CountAggregate {
+			return fmt.Errorf("column is required for %s aggregate", aggregate.Function)
+		}
+		identifiers = append(identifiers, aggregate.Column, aggregate.alias())
+	}
+	for _, identifier := range identifiers {
+		if strings.Contains(identifier, `"`) {
+			return fmt.Errorf("malformed name [%s]: double quotes aren't allowed", identifier)
+		}
+	}
+
+	return nil
+}
+
+//createStatement return CREATE MATERIALIZED VIEW statement: SELECT date_trunc(granularity, time_column), group by columns, aggregates
+func (v *View) createStatement(dbSchema string) string {
+	columns := []string{fmt.Sprintf("date_trunc('%s', %s) AS %s", v.granularity(), quoteIdentifier(v.timeColumn()), quoteIdentifier(v.granularity()))}
+	groupBy := []string{"1"}
+	for i, column := range v.GroupBy {
+		columns = append(columns, quoteIdentifier(column))
+		groupBy = append(groupBy, fmt.Sprint(i+2))
+	}
+	for _, aggregate := range v.aggregates() {
+		columns = append(columns, aggregate.expression())
+	}
+
+	where := ""
+	if v.Where != "" {
+		where = " WHERE " + v.Where
+	}
+
+	return fmt.Sprintf(createMaterializedViewTemplate, dbSchema, v.Name, strings.Join(columns, ", "), dbSchema, v.Table, where, strings.Join(groupBy, ", "))
+}
+
+func (v *View) refreshStatement(dbSchema string) string {
+	return fmt.Sprintf(refreshMaterializedViewTemplate, dbSchema, v.Name)
+}
+
+type managedView struct {
+	sync.Mutex
+	name            string
+	table           string
+	create          string
+	refresh         string
+	refreshInterval time.Duration
+
+	created     bool
+	lastRefresh time.Time
+}
+
+//ManagedViews create and refresh configured materialized views of the destination after loads. Nil ManagedViews doesn't execute anything
+type ManagedViews struct {
+	destinationName string
+	views           []*managedView
+}
+
+//NewManagedViews return ManagedViews with built statements or err if configuration is invalid
+func NewManagedViews(destinationName, dbSchema string, configs []*View) (*ManagedViews, error) {
+	if len(configs) == 0 {
+		return nil, nil
+	}
+
+	managedViews := &ManagedViews{destinationName: destinationName}
+	names := map[string]bool{}
+	for i, config := range configs {
+		if err := config.validate(); err != nil {
+			return nil, fmt.Errorf("views[%d]: %v", i, err)
+		}
+		if names[config.Name] {
+			return nil, fmt.Errorf("views[%d]: view [%s] is already configured", i, config.Name)
+		}
+		names[config.Name] = true
+
+		managedViews.views = append(managedViews.views, &managedView{
+			name:            config.Name,
+			table:           config.Table,
+			create:          config.createStatement(dbSchema),
+			refresh:         config.refreshStatement(dbSchema),
+			refreshInterval: time.Duration(config.RefreshIntervalMin) * time.Minute,
+		})
+	}
+
+	return managedViews, nil
+}
+
+//AfterLoad create (if it hasn't been created yet) or refresh views of the loaded table. Errors are logged and counted in metrics:
+//the data has already been loaded
+func (mv *ManagedViews) AfterLoad(table string, exec func(statement string) error) {
+	if mv == nil {
+		return
+	}
+
+	for _, view := range mv.views {
+		if view.table != table {
+			continue
+		}
+		if err := mv.apply(view, exec); err != nil {
+			destinationsLogger.WithDestination(mv.destinationName).Errorf("%v", err)
+		}
+	}
+}
+
+func (mv *ManagedViews) apply(view *managedView, exec func(statement string) error) error {
+	view.Lock()
+	defer view.Unlock()
+
+	if !view.created {
+		err := mv.execute(view, createViewOperation, view.create, exec)
+		if err == nil {
+			//created view is populated
+			view.created = true
+			view.lastRefresh = time.Now()
+			destinationsLogger.WithDestination(mv.destinationName).Infof("View [%s] has been created", view.name)
+			return nil
+		}
+		//view has been created before the restart. Changed definition isn't applied: the view should be dropped manually
+		if !strings.Contains(err.Error(), "already exists") {
+			return err
+		}
+		view.created = true
+	}
+
+	if time.Since(view.lastRefresh) < view.refreshInterval {
+		return nil
+	}
+	if err := mv.execute(view, refreshViewOperation, view.refresh, exec); err != nil {
+		return err
+	}
+	view.lastRefresh = time.Now()
+	return nil
+}
+
+func (mv *ManagedViews) execute(view *managedView, operation, statement string, exec func(statement string) error) error {
+	start := time.Now()
+	err := exec(statement)
+	metrics.ViewDuration(mv.destinationName, view.name, operation, time.Since(start).Seconds())
+	if err != nil {
+		if operation == refreshViewOperation || !strings.Contains(err.Error(), "already exists") {
+			metrics.ViewError(mv.destinationName, view.name, operation)
+		}
+		return fmt.Errorf("Error executing %s of [%s] view: %v", operation, view.name, err)
+	}
+	return nil
+}
+
+//validateViews return err if views are misconfigured or aren't supported by the destination
+func validateViews(destination DestinationConfig, name string) error {
+	if len(destination.Views) == 0 {
+		return nil
+	}
+
+	if destination.Type != PostgresType && destination.Type != RedshiftType {
+		return fmt.Errorf("views aren't supported by %s destination", destination.Type)
+	}
+	if destination.Mode == StreamMode {
+		return fmt.Errorf("views are refreshed after batch loads and aren't supported in %s mode", StreamMode)
+	}
+
+	_, err := NewManagedViews(name, "", destination.Views)
+	return err
+}
+
+func quoteIdentifier(identifier string) string {
+	return `"` + identifier + `"`
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package storages
+
+import (
+	"errors"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestViewCreateStatement(t *testing.T) {
+	tests := []struct {
+		name     string
+		view     *View
+		expected string
+	}{
+		{
+			"daily events per type",
+			&View{Name: "daily_events", Table: "events", GroupBy: []string{"event_type"}},
+			`CREATE MATERIALIZED VIEW "analytics"."daily_events" AS SELECT date_trunc('day', "_timestamp") AS "day", "event_type", count(*) AS "count" FROM "analytics"."events" GROUP BY 1, 2`,
+		},
+		{
+			"revenue per month",
+			&View{Name: "revenue", Table: "events", Granularity: MonthGranularity, TimeColumn: "utc_time", Where: "event_type = 'purchase'",
+				Aggregates: []*ViewAggregate{{Function: SumAggregate, Column: "revenue"}, {Function: CountDistinctAggregate, Column: "user_id", As: "buyers"}}},
+			`CREATE MATERIALIZED VIEW "analytics"."revenue" AS SELECT date_trunc('month', "utc_time") AS "month", sum("revenue") AS "sum_revenue", count(DISTINCT "user_id") AS "buyers" FROM "analytics"."events" WHERE event_type = 'purchase' GROUP BY 1`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, tt.view.validate())
+			require.Equal(t, tt.expected, tt.view.createStatement("analytics"))
+		})
+	}
+}
+
+func TestNewManagedViewsErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		views       []*View
+		expectedErr string
+	}{
+		{"no table", []*View{{Name: "v"}}, "views[0]: table is required"},
+		{"unknown granularity", []*View{{Name: "v", Table: "events", Granularity: "year"}}, "views[0]: unknown granularity: year. Available: [hour day week month]"},
+		{"sum without column", []*View{{Name: "v", Table: "events", Aggregates: []*ViewAggregate{{Function: SumAggregate}}}}, "views[0]: column is required for sum aggregate"},
+		{"duplicate", []*View{{Name: "v", Table: "events"}, {Name: "v", Table: "clicks"}}, "views[1]: view [v] is already configured"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewManagedViews("pg", "analytics", tt.views)
+			require.EqualError(t, err, tt.expectedErr)
+		})
+	}
+}
+
+func TestManagedViewsAfterLoad(t *testing.T) {
+	views, err := NewManagedViews("pg", "analytics", []*View{
+		{Name: "daily", Table: "events"},
+		{Name: "hourly", Table: "events", Granularity: HourGranularity, RefreshIntervalMin: 60},
+	})
+	require.NoError(t, err)
+
+	var executed []string
+	exec := func(statement string) error {
+		executed = append(executed, statement)
+		if statement == views.views[1].create {
+			return errors.New(`relation "hourly" already exists`)
+		}
+		return nil
+	}
+
+	views.AfterLoad("clicks", exec)
+	require.Empty(t, executed)
+
+	//daily is created, hourly already exists and is refreshed
+	views.AfterLoad("events", exec)
+	require.Equal(t, []string{views.views[0].create, views.views[1].create, `REFRESH MATERIALIZED VIEW "analytics"."hourly"`}, executed)
+
+	//hourly refresh interval hasn't passed
+	executed = nil
+	views.AfterLoad("events", exec)
+	require.Equal(t, []string{`REFRESH MATERIALIZED VIEW "analytics"."daily"`}, executed)
+}
@@ -1,19 +1,23 @@
 package telemetry
 
 import (
-	"sync/atomic"
+	"github.com/jitsucom/eventnative/counters"
 )
 
 type Collector struct {
-	events uint64
+	events *counters.ShardedCounter
+}
+
+func newCollector() *Collector {
+	return &Collector{events: counters.NewShardedCounter()}
 }
 
 //Event increment events counter
 func (c *Collector) Event() {
-	atomic.AddUint64(&c.events, 1)
+	c.events.Add(1)
 }
 
 //Cut return current value and set it to 0
 func (c *Collector) Cut() uint64 {
-	return atomic.SwapUint64(&c.events, 0)
+	return c.events.Cut()
 }
@@ -36,7 +36,7 @@ func Init(commit, tag, builtAt string, usageOptOut bool) {
 		url:         "https://t.jitsu.com/api/v1/s2s/event?token=ttttd50c-d8f2-414c-bf3d-9902a5031fd2",
 		usageOptOut: usageOptOut,
 
-		collector: &Collector{},
+		collector: newCollector(),
 
 		usageCh: make(chan *Request, 100),
 
@@ -0,0 +1,161 @@
+package bench
+
+import (
+	"fmt"
+	"github.com/jitsucom/eventnative/parsers"
+	"github.com/jitsucom/eventnative/schema"
+	"runtime"
+	"time"
+)
+
+const (
+	defaultEvents      = 100000
+	defaultBatchSize   = 1000
+	defaultFields      = 20
+	defaultDepth       = 2
+	defaultCardinality = 100
+	defaultEventTypes  = 5
+	defaultTable       = "events"
+)
+
+//Config is a synthetic workload: events are generated before measurements and processed in file payloads of BatchSize events
+type Config struct {
+	Events    int
+	BatchSize int
+	//leaf fields per event (without system fields)
+	Fields int
+	//max nesting level of leaf fields. 0 - flat events
+	Depth int
+	//distinct values of string and integer fields
+	Cardinality int
+	//distinct event_type values (use {{.event_type}} table name template for multiple tables)
+	EventTypes        int
+	TableNameTemplate string
+	Mappings          []string
+	Seed              int64
+}
+
+func (c *Config) setDefaults() {
+	if c.Events <= 0 {
+		c.Events = defaultEvents
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = defaultBatchSize
+	}
+	if c.Fields <= 0 {
+		c.Fields = defaultFields
+	}
+	if c.Depth < 0 {
+		c.Depth = 0
+	}
+	if c.Cardinality <= 0 {
+		c.Cardinality = defaultCardinality
+	}
+	if c.EventTypes <= 0 {
+		c.EventTypes = defaultEventTypes
+	}
+	if c.TableNameTemplate == "" {
+		c.TableNameTemplate = defaultTable
+	}
+}
+
+//Stats is a result of the workload processing
+type Stats struct {
+	Events         int           `json:"events"`
+	Failed         int           `json:"failed"`
+	Tables         int           `json:"tables"`
+	Columns        int           `json:"columns"`
+	Elapsed        time.Duration `json:"elapsed"`
+	Throughput     float64       `json:"throughput"`
+	BytesPerEvent  float64       `json:"bytes_per_event"`
+	AllocsPerEvent float64       `json:"allocs_per_event"`
+	GCCount        uint32        `json:"gc_count"`
+}
+
+func (s *Stats) String() string {
+	return fmt.Sprintf("events: %d (failed: %d), tables: %d, columns: %d, elapsed: %s, throughput: %.0f events/sec, %.0f B/event, %.1f allocs/event, gc: %d",
+		s.Events, s.Failed, s.Tables, s.Columns, s.Elapsed, s.Throughput, s.BytesPerEvent, s.AllocsPerEvent, s.GCCount)
+}
+
+//Workload is generated events payloads and the processor
+type Workload struct {
+	config    Config
+	processor *schema.Processor
+	payloads  [][]byte
+}
+
+//NewWorkload create the processor and generate all events (generation isn't measured)
+func NewWorkload(config Config) (*Workload, error) {
+	config.setDefaults()
+
+	processor, err := schema.NewProcessor(config.TableNameTemplate, config.Mappings, schema.Default, map[string]bool{}, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	generator := NewGenerator(config)
+	var payloads [][]byte
+	for generated := 0; generated < config.Events; generated += config.BatchSize {
+		count := config.BatchSize
+		if config.Events-generated < count {
+			count = config.Events - generated
+		}
+		payload, err := generator.Payload(count)
+		if err != nil {
+			return nil, fmt.Errorf("Error generating events: %v", err)
+		}
+		payloads = append(payloads, payload)
+	}
+
+	return &Workload{config: config, processor: processor, payloads: payloads}, nil
+}
+
+//Run generate the workload and run it
+func Run(config Config) (*Stats, error) {
+	workload, err := NewWorkload(config)
+	if err != nil {
+		return nil, err
+	}
+	return workload.Run()
+}
+
+//Run push the workload through the processor (parsing, mapping, flattening, typing) and the destination mock
+//(tables schema merging and DB typing like warehouse destinations do)
+func (w *Workload) Run() (*Stats, error) {
+	destination := newDestination(w.processor)
+	failed := 0
+
+	runtime.GC()
+	before := &runtime.MemStats{}
+	runtime.ReadMemStats(before)
+	start := time.Now()
+
+	for i, payload := range w.payloads {
+		processedFiles, failedFacts, err := w.processor.ProcessFilePayload(fmt.Sprintf("bench-%d.log", i), payload, false, parsers.ParseJson)
+		if err != nil {
+			return nil, fmt.Errorf("Error processing payload: %v", err)
+		}
+		failed += len(failedFacts)
+		for _, processedFile := range processedFiles {
+			failed += destination.store(processedFile)
+		}
+	}
+
+	elapsed := time.Since(start)
+	after := &runtime.MemStats{}
+	runtime.ReadMemStats(after)
+
+	events := w.config.Events
+	tables, columns := destination.size()
+	return &Stats{
+		Events:         events,
+		Failed:         failed,
+		Tables:         tables,
+		Columns:        columns,
+		Elapsed:        elapsed,
+		Throughput:     float64(events) / elapsed.Seconds(),
+		BytesPerEvent:  float64(after.TotalAlloc-before.TotalAlloc) / float64(events),
+		AllocsPerEvent: float64(after.Mallocs-before.Mallocs) / float64(events),
+		GCCount:        after.NumGC - before.NumGC,
+	}, nil
+}
@@ -0,0 +1,55 @@
+package bench
+
+import (
+	"github.com/stretchr/testify/require"
+	"reflect"
+	"testing"
+)
+
+func TestGenerator(t *testing.T) {
+	config := Config{Fields: 10, Depth: 2, Cardinality: 3, Seed: 1}
+	event := NewGenerator(config).Next()
+	require.True(t, reflect.DeepEqual(event, NewGenerator(config).Next()), "events of the same seed must be the same")
+
+	require.Contains(t, event, "f0")
+	require.Contains(t, event["n1"], "f1")
+	require.Contains(t, event["n1"].(map[string]interface{})["n2"], "f2")
+}
+
+func TestRun(t *testing.T) {
+	stats, err := Run(Config{Events: 250, BatchSize: 100, Fields: 10, Depth: 2, EventTypes: 2, TableNameTemplate: "{{.event_type}}"})
+	require.NoError(t, err)
+	require.Equal(t, 250, stats.Events)
+	require.Equal(t, 0, stats.Failed)
+	require.Equal(t, 2, stats.Tables)
+	require.True(t, stats.Throughput > 0)
+}
+
+//BenchmarkProcessing measures processor and DB typing per event: go test -bench . -benchmem ./test/bench/
+func BenchmarkProcessing(b *testing.B) {
+	for _, bm := range []struct {
+		name   string
+		config Config
+	}{
+		{"flat", Config{Fields: 20, Depth: 0}},
+		{"nested", Config{Fields: 20, Depth: 3}},
+		{"wide", Config{Fields: 200, Depth: 2}},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			bm.config.Events = b.N
+			workload, err := NewWorkload(bm.config)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			stats, err := workload.Run()
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ReportMetric(stats.Throughput, "events/s")
+			b.ReportMetric(stats.AllocsPerEvent, "allocs/event")
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package bench
+
+import (
+	"github.com/jitsucom/eventnative/schema"
+)
+
+//destination is a warehouse mock: tables are created with the first file columns types and patched with new columns,
+//rows are converted to the table columns types and discarded
+type destination struct {
+	processor *schema.Processor
+	tables    map[string]*schema.Table
+}
+
+func newDestination(processor *schema.Processor) *destination {
+	return &destination{processor: processor, tables: map[string]*schema.Table{}}
+}
+
+//store return count of rows which can't be converted to the table schema
+func (d *destination) store(processedFile *schema.ProcessedFile) int {
+	dataSchema := processedFile.DataSchema
+	table, ok := d.tables[dataSchema.Name]
+	if !ok {
+		table = &schema.Table{Name: dataSchema.Name, Columns: schema.Columns{}}
+		d.tables[dataSchema.Name] = table
+	}
+	for name, column := range dataSchema.Columns {
+		if _, ok := table.Columns[name]; !ok {
+			table.Columns[name] = schema.NewColumn(column.GetType())
+		}
+	}
+
+	if err := d.processor.ApplyDBTyping(table, processedFile); err != nil {
+		return processedFile.GetPayloadLen()
+	}
+	return 0
+}
+
+//size return tables count and total columns count
+func (d *destination) size() (int, int) {
+	columns := 0
+	for _, table := range d.tables {
+		columns += len(table.Columns)
+	}
+	return len(d.tables), columns
+}
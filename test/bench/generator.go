@@ -0,0 +1,104 @@
+package bench
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jitsucom/eventnative/timestamp"
+	"math/rand"
+	"strconv"
+	"time"
+)
+
+//Generator generates synthetic events: system fields (event id, _timestamp, event_type, user) and Fields leaf fields
+//of mixed types (string, integer, float, boolean, array) which are spread across nested objects up to Depth levels.
+//Events with 0 depth are flat: without nested objects and arrays.
+//String values are drawn from Cardinality distinct values. Events of the same seed are the same
+type Generator struct {
+	config Config
+	random *rand.Rand
+	start  time.Time
+	count  int
+}
+
+func NewGenerator(config Config) *Generator {
+	config.setDefaults()
+	return &Generator{
+		config: config,
+		random: rand.New(rand.NewSource(config.Seed)),
+		start:  time.Date(2020, 8, 2, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+//Next return the next synthetic event with json.Number numbers (like parsed events)
+func (g *Generator) Next() map[string]interface{} {
+	g.count++
+	event := map[string]interface{}{
+		"eventn_ctx_event_id": fmt.Sprintf("bench-%d-%d", g.config.Seed, g.count),
+		timestamp.Key:         g.start.Add(time.Duration(g.count) * time.Second).Format(timestamp.Layout),
+		"event_type":          fmt.Sprintf("event_type_%d", g.random.Intn(g.config.EventTypes)),
+	}
+	if g.config.Depth == 0 {
+		event["user_anonymous_id"] = g.value()
+		event["user_id"] = json.Number(strconv.Itoa(g.random.Intn(g.config.Cardinality)))
+	} else {
+		event["user"] = map[string]interface{}{
+			"anonymous_id": g.value(),
+			"id":           json.Number(strconv.Itoa(g.random.Intn(g.config.Cardinality))),
+		}
+	}
+
+	for i := 0; i < g.config.Fields; i++ {
+		//field i is nested on i % (depth + 1) level: /n1/n2/f2
+		object := event
+		for level := 1; level <= i%(g.config.Depth+1); level++ {
+			name := "n" + strconv.Itoa(level)
+			nested, ok := object[name].(map[string]interface{})
+			if !ok {
+				nested = map[string]interface{}{}
+				object[name] = nested
+			}
+			object = nested
+		}
+		object["f"+strconv.Itoa(i)] = g.typedValue(i)
+	}
+
+	return event
+}
+
+//Payload return count events serialized as a file payload (one JSON per line)
+func (g *Generator) Payload(count int) ([]byte, error) {
+	var payload []byte
+	for i := 0; i < count; i++ {
+		b, err := json.Marshal(g.Next())
+		if err != nil {
+			return nil, err
+		}
+		payload = append(payload, b...)
+		payload = append(payload, '\n')
+	}
+	return payload, nil
+}
+
+//typedValue return value of the field type: the same field has the same type in all events
+func (g *Generator) typedValue(field int) interface{} {
+	switch field % 5 {
+	case 0:
+		return g.value()
+	case 1:
+		return json.Number(strconv.Itoa(g.random.Intn(g.config.Cardinality)))
+	case 2:
+		return json.Number(strconv.FormatFloat(g.random.Float64()*1000, 'f', 2, 64))
+	case 3:
+		return g.random.Intn(2) == 1
+	default:
+		//flat events don't have arrays
+		if g.config.Depth == 0 {
+			return g.value()
+		}
+		return []interface{}{g.value(), g.value()}
+	}
+}
+
+func (g *Generator) value() string {
+	return "value_" + strconv.Itoa(g.random.Intn(g.config.Cardinality))
+}
@@ -0,0 +1,62 @@
+package soak
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+//default prometheus registry go and process collectors metrics
+const (
+	rssMetric         = "process_resident_memory_bytes"
+	gcCountMetric     = "go_gc_duration_seconds_count"
+	gcPauseMetric     = "go_gc_duration_seconds_sum"
+	allocatedMetric   = "go_memstats_alloc_bytes_total"
+	heapObjectsMetric = "go_memstats_heap_objects"
+)
+
+func scrape(client *http.Client, metricsUrl string) (map[string]float64, error) {
+	resp, err := client.Get(metricsUrl)
+	if err != nil {
+		return nil, fmt.Errorf("Error scraping metrics: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Error scraping metrics: HTTP code: %d", resp.StatusCode)
+	}
+
+	return parseMetrics(resp.Body)
+}
+
+//parseMetrics parse prometheus text exposition format into metric name (with labels) - value map
+func parseMetrics(r io.Reader) (map[string]float64, error) {
+	values := map[string]float64{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.Fields(line)
+		//labels might contain spaces: value is after the last '}'
+		if idx := strings.LastIndex(line, "}"); idx > 0 {
+			parts = append([]string{line[:idx+1]}, strings.Fields(line[idx+1:])...)
+		}
+		if len(parts) < 2 {
+			return nil, fmt.Errorf("Malformed metric line: %s", line)
+		}
+
+		value, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("Malformed metric [%s] value: %v", parts[0], err)
+		}
+		values[parts[0]] = value
+	}
+
+	return values, scanner.Err()
+}
@@ -0,0 +1,115 @@
+package soak
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+//Thresholds are max allowed candidate regressions in percents relatively to the baseline. 0 means not gated
+type Thresholds struct {
+	ThroughputDropPercent    float64 `json:"throughput_drop_percent"`
+	P99IncreasePercent       float64 `json:"p99_increase_percent"`
+	RSSIncreasePercent       float64 `json:"rss_increase_percent"`
+	GCPauseIncreasePercent   float64 `json:"gc_pause_increase_percent"`
+	AllocatedIncreasePercent float64 `json:"allocated_increase_percent"`
+}
+
+//Deltas are candidate changes in percents relatively to the baseline
+type Deltas struct {
+	ThroughputPercent float64 `json:"throughput_percent"`
+	P99Percent        float64 `json:"p99_percent"`
+	RSSPercent        float64 `json:"rss_percent"`
+	GCCountPercent    float64 `json:"gc_count_percent"`
+	GCPausePercent    float64 `json:"gc_pause_percent"`
+	AllocatedPercent  float64 `json:"allocated_percent"`
+}
+
+//Report is a baseline vs candidate comparison
+type Report struct {
+	Baseline    *Stats   `json:"baseline"`
+	Candidate   *Stats   `json:"candidate"`
+	Deltas      Deltas   `json:"deltas"`
+	Regressions []string `json:"regressions"`
+}
+
+//Compare return a report with deltas and regressions which exceed thresholds
+//requests errors are always regressions
+func Compare(baseline, candidate *Stats, thresholds Thresholds) *Report {
+	report := &Report{
+		Baseline:  baseline,
+		Candidate: candidate,
+		Deltas: Deltas{
+			ThroughputPercent: percentDelta(baseline.Throughput, candidate.Throughput),
+			P99Percent:        percentDelta(float64(baseline.P99), float64(candidate.P99)),
+			RSSPercent:        percentDelta(baseline.MaxRSSBytes, candidate.MaxRSSBytes),
+			GCCountPercent:    percentDelta(baseline.GCCount, candidate.GCCount),
+			GCPausePercent:    percentDelta(baseline.GCPauseSeconds, candidate.GCPauseSeconds),
+			AllocatedPercent:  percentDelta(baseline.AllocatedBytes, candidate.AllocatedBytes),
+		},
+		Regressions: []string{},
+	}
+
+	if candidate.Errors > 0 {
+		report.Regressions = append(report.Regressions, fmt.Sprintf("candidate requests errors: %d of %d", candidate.Errors, candidate.Requests))
+	}
+	report.check("throughput drop", -report.Deltas.ThroughputPercent, thresholds.ThroughputDropPercent)
+	report.check("p99 latency increase", report.Deltas.P99Percent, thresholds.P99IncreasePercent)
+	report.check("max RSS increase", report.Deltas.RSSPercent, thresholds.RSSIncreasePercent)
+	report.check("GC pause increase", report.Deltas.GCPausePercent, thresholds.GCPauseIncreasePercent)
+	report.check("allocated bytes increase", report.Deltas.AllocatedPercent, thresholds.AllocatedIncreasePercent)
+
+	return report
+}
+
+func (r *Report) check(name string, regressionPercent, threshold float64) {
+	if threshold > 0 && regressionPercent > threshold {
+		r.Regressions = append(r.Regressions, fmt.Sprintf("%s: %.2f%% (threshold: %.2f%%)", name, regressionPercent, threshold))
+	}
+}
+
+//String return human readable table
+func (r *Report) String() string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "%-16s %16s %16s %10s\n", "", r.Baseline.Target, r.Candidate.Target, "delta")
+	fmt.Fprintf(b, "%-16s %16.1f %16.1f %9.2f%%\n", "throughput (e/s)", r.Baseline.Throughput, r.Candidate.Throughput, r.Deltas.ThroughputPercent)
+	fmt.Fprintf(b, "%-16s %16s %16s %10s\n", "p50 latency", r.Baseline.P50, r.Candidate.P50, "")
+	fmt.Fprintf(b, "%-16s %16s %16s %9.2f%%\n", "p99 latency", r.Baseline.P99, r.Candidate.P99, r.Deltas.P99Percent)
+	fmt.Fprintf(b, "%-16s %16.0f %16.0f %9.2f%%\n", "max RSS (bytes)", r.Baseline.MaxRSSBytes, r.Candidate.MaxRSSBytes, r.Deltas.RSSPercent)
+	fmt.Fprintf(b, "%-16s %16.0f %16.0f %9.2f%%\n", "GC count", r.Baseline.GCCount, r.Candidate.GCCount, r.Deltas.GCCountPercent)
+	fmt.Fprintf(b, "%-16s %16.4f %16.4f %9.2f%%\n", "GC pause (s)", r.Baseline.GCPauseSeconds, r.Candidate.GCPauseSeconds, r.Deltas.GCPausePercent)
+	fmt.Fprintf(b, "%-16s %16.0f %16.0f %9.2f%%\n", "allocated bytes", r.Baseline.AllocatedBytes, r.Candidate.AllocatedBytes, r.Deltas.AllocatedPercent)
+	fmt.Fprintf(b, "%-16s %16d %16d %10s\n", "errors", r.Baseline.Errors, r.Candidate.Errors, "")
+	for _, regression := range r.Regressions {
+		fmt.Fprintf(b, "REGRESSION: %s\n", regression)
+	}
+	return b.String()
+}
+
+//percentile return nearest-rank percentile from sorted latencies
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
+
+//percentDelta return (candidate - baseline) / baseline in percents
+func percentDelta(baseline, candidate float64) float64 {
+	if baseline == 0 {
+		if candidate == 0 {
+			return 0
+		}
+		return 100
+	}
+	return (candidate - baseline) / baseline * 100
+}
@@ -0,0 +1,269 @@
+package soak
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/test"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+const (
+	adminToken     = "soak_admin_token"
+	startupTimeout = 60 * time.Second
+	rssSamplePace  = 500 * time.Millisecond
+
+	defaultEndpoint    = "/api/v1/s2s/event"
+	defaultConcurrency = 16
+	defaultEvents      = 100000
+)
+
+//DefaultEvent is used when Workload.Event isn't set
+var DefaultEvent = []byte(`{"event_type":"soak","eventn_ctx":{"event_id":"soak","user":{"anonymous_id":"soak_user"},"page_title":"Soak","url":"https://eventnative.dev/soak"},"amount":42.5,"items":[{"id":1},{"id":2}]}`)
+
+//Target is an eventnative binary with a configuration
+type Target struct {
+	Name       string
+	Binary     string
+	ConfigPath string
+	//additional OS env variables e.g. SERVER_LOG_PATH=/tmp/soak
+	Env []string
+}
+
+//Workload is a fixed amount of the same events which is sent with a fixed concurrency
+type Workload struct {
+	Token       string
+	Endpoint    string
+	Event       []byte
+	Events      int
+	Concurrency int
+	//events sent before measurements (aren't counted)
+	Warmup int
+}
+
+func (w *Workload) setDefaults() {
+	if w.Endpoint == "" {
+		w.Endpoint = defaultEndpoint
+	}
+	if len(w.Event) == 0 {
+		w.Event = DefaultEvent
+	}
+	if w.Events <= 0 {
+		w.Events = defaultEvents
+	}
+	if w.Concurrency <= 0 {
+		w.Concurrency = defaultConcurrency
+	}
+}
+
+//Stats is a result of a workload run against one target
+type Stats struct {
+	Target          string        `json:"target"`
+	Requests        int           `json:"requests"`
+	Errors          int           `json:"errors"`
+	Elapsed         time.Duration `json:"elapsed"`
+	Throughput      float64       `json:"throughput"`
+	P50             time.Duration `json:"p50"`
+	P99             time.Duration `json:"p99"`
+	MaxRSSBytes     float64       `json:"max_rss_bytes"`
+	GCCount         float64       `json:"gc_count"`
+	GCPauseSeconds  float64       `json:"gc_pause_seconds"`
+	AllocatedBytes  float64       `json:"allocated_bytes"`
+	HeapObjectsDiff float64       `json:"heap_objects_diff"`
+}
+
+//Run start target binary, send the workload, collect stats and stop the binary
+func Run(target Target, workload Workload) (*Stats, error) {
+	workload.setDefaults()
+
+	authority, err := test.GetLocalAuthority()
+	if err != nil {
+		return nil, fmt.Errorf("Error getting local address: %v", err)
+	}
+	_, port, err := net.SplitHostPort(authority)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing local address %s: %v", authority, err)
+	}
+
+	cmd := exec.Command(target.Binary, "-cfg", target.ConfigPath)
+	cmd.Env = append(os.Environ(), target.Env...)
+	cmd.Env = append(cmd.Env,
+		"SERVER_PORT="+port,
+		"SERVER_ADMIN_TOKEN="+adminToken,
+		"SERVER_METRICS_PROMETHEUS_ENABLED=true")
+	cmd.Stdout = ioutil.Discard
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("Error starting [%s] binary %s: %v", target.Name, target.Binary, err)
+	}
+	defer stop(cmd)
+
+	baseUrl := "http://" + authority
+	if err := waitReady(baseUrl); err != nil {
+		return nil, fmt.Errorf("[%s] %v", target.Name, err)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second, Transport: &http.Transport{MaxIdleConnsPerHost: workload.Concurrency}}
+	eventUrl := baseUrl + workload.Endpoint + "?token=" + workload.Token
+	metricsUrl := baseUrl + "/prometheus?token=" + adminToken
+
+	if workload.Warmup > 0 {
+		send(client, eventUrl, workload.Event, workload.Warmup, workload.Concurrency)
+	}
+
+	before, err := scrape(client, metricsUrl)
+	if err != nil {
+		return nil, fmt.Errorf("[%s] %v", target.Name, err)
+	}
+
+	rssSampler := newRSSSampler(client, metricsUrl)
+	started := time.Now()
+	latencies, errorsCount := send(client, eventUrl, workload.Event, workload.Events, workload.Concurrency)
+	elapsed := time.Since(started)
+	maxRSS := rssSampler.stop()
+
+	after, err := scrape(client, metricsUrl)
+	if err != nil {
+		return nil, fmt.Errorf("[%s] %v", target.Name, err)
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	return &Stats{
+		Target:          target.Name,
+		Requests:        workload.Events,
+		Errors:          errorsCount,
+		Elapsed:         elapsed,
+		Throughput:      float64(workload.Events) / elapsed.Seconds(),
+		P50:             percentile(latencies, 50),
+		P99:             percentile(latencies, 99),
+		MaxRSSBytes:     max(maxRSS, after[rssMetric]),
+		GCCount:         after[gcCountMetric] - before[gcCountMetric],
+		GCPauseSeconds:  after[gcPauseMetric] - before[gcPauseMetric],
+		AllocatedBytes:  after[allocatedMetric] - before[allocatedMetric],
+		HeapObjectsDiff: after[heapObjectsMetric] - before[heapObjectsMetric],
+	}, nil
+}
+
+//send post payload count times with concurrency workers and return request latencies and errors count
+func send(client *http.Client, url string, payload []byte, count, concurrency int) ([]time.Duration, int) {
+	latencies := make([]time.Duration, count)
+	var next, errorsCount int64 = -1, 0
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				idx := atomic.AddInt64(&next, 1)
+				if idx >= int64(count) {
+					return
+				}
+
+				started := time.Now()
+				if err := post(client, url, payload); err != nil {
+					atomic.AddInt64(&errorsCount, 1)
+				}
+				latencies[idx] = time.Since(started)
+			}
+		}()
+	}
+	wg.Wait()
+
+	return latencies, int(errorsCount)
+}
+
+func post(client *http.Client, url string, payload []byte) error {
+	resp, err := client.Post(url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func waitReady(baseUrl string) error {
+	deadline := time.Now().Add(startupTimeout)
+	for time.Now().Before(deadline) {
+		resp, err := http.Get(baseUrl + "/ping")
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return nil
+			}
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	return errors.New("Binary hasn't started in " + startupTimeout.String())
+}
+
+func stop(cmd *exec.Cmd) {
+	cmd.Process.Signal(syscall.SIGTERM)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		cmd.Process.Kill()
+		<-done
+	}
+}
+
+type rssSampler struct {
+	closed chan struct{}
+	done   chan float64
+}
+
+//newRSSSampler scrape process RSS periodically and keep the maximum value
+func newRSSSampler(client *http.Client, metricsUrl string) *rssSampler {
+	rs := &rssSampler{closed: make(chan struct{}), done: make(chan float64, 1)}
+	go func() {
+		maxRSS := 0.0
+		ticker := time.NewTicker(rssSamplePace)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-rs.closed:
+				rs.done <- maxRSS
+				return
+			case <-ticker.C:
+				values, err := scrape(client, metricsUrl)
+				if err == nil {
+					maxRSS = max(maxRSS, values[rssMetric])
+				}
+			}
+		}
+	}()
+	return rs
+}
+
+func (rs *rssSampler) stop() float64 {
+	close(rs.closed)
+	return <-rs.done
+}
+
+func max(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
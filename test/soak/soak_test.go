@@ -0,0 +1,163 @@
+package soak
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+//TestSoak run the same workload through baseline and candidate binaries and fail on regressions
+//Skipped unless SOAK_BASELINE_BINARY and SOAK_CANDIDATE_BINARY are set. See Makefile soak target
+func TestSoak(t *testing.T) {
+	baselineBinary := os.Getenv("SOAK_BASELINE_BINARY")
+	candidateBinary := os.Getenv("SOAK_CANDIDATE_BINARY")
+	if baselineBinary == "" || candidateBinary == "" {
+		t.Skip("SOAK_BASELINE_BINARY and SOAK_CANDIDATE_BINARY aren't set")
+	}
+
+	workload := Workload{
+		Token:       os.Getenv("SOAK_TOKEN"),
+		Endpoint:    os.Getenv("SOAK_ENDPOINT"),
+		Events:      envInt(t, "SOAK_EVENTS"),
+		Concurrency: envInt(t, "SOAK_CONCURRENCY"),
+		Warmup:      envInt(t, "SOAK_WARMUP"),
+	}
+	if eventPath := os.Getenv("SOAK_EVENT_PATH"); eventPath != "" {
+		event, err := ioutil.ReadFile(eventPath)
+		require.NoError(t, err)
+		workload.Event = event
+	}
+
+	thresholds := Thresholds{
+		ThroughputDropPercent:    envFloat(t, "SOAK_MAX_THROUGHPUT_DROP", 10),
+		P99IncreasePercent:       envFloat(t, "SOAK_MAX_P99_INCREASE", 20),
+		RSSIncreasePercent:       envFloat(t, "SOAK_MAX_RSS_INCREASE", 20),
+		GCPauseIncreasePercent:   envFloat(t, "SOAK_MAX_GC_PAUSE_INCREASE", 0),
+		AllocatedIncreasePercent: envFloat(t, "SOAK_MAX_ALLOCATED_INCREASE", 0),
+	}
+
+	baselineConfig := os.Getenv("SOAK_BASELINE_CONFIG")
+	candidateConfig := os.Getenv("SOAK_CANDIDATE_CONFIG")
+	if candidateConfig == "" {
+		candidateConfig = baselineConfig
+	}
+
+	baseline, err := Run(Target{Name: "baseline", Binary: baselineBinary, ConfigPath: baselineConfig}, workload)
+	require.NoError(t, err)
+	candidate, err := Run(Target{Name: "candidate", Binary: candidateBinary, ConfigPath: candidateConfig}, workload)
+	require.NoError(t, err)
+
+	report := Compare(baseline, candidate, thresholds)
+	t.Log("\n" + report.String())
+
+	if reportPath := os.Getenv("SOAK_REPORT_PATH"); reportPath != "" {
+		b, err := json.MarshalIndent(report, "", "  ")
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(reportPath, b, 0644))
+	}
+
+	require.Empty(t, report.Regressions)
+}
+
+func TestCompare(t *testing.T) {
+	baseline := &Stats{Target: "baseline", Requests: 100, Throughput: 1000, P99: 10 * time.Millisecond, MaxRSSBytes: 100, GCPauseSeconds: 0.5, AllocatedBytes: 1000}
+	tests := []struct {
+		name                string
+		candidate           *Stats
+		thresholds          Thresholds
+		expectedRegressions []string
+	}{
+		{
+			"no regressions",
+			&Stats{Target: "candidate", Requests: 100, Throughput: 950, P99: 11 * time.Millisecond, MaxRSSBytes: 110, GCPauseSeconds: 1, AllocatedBytes: 1000},
+			Thresholds{ThroughputDropPercent: 10, P99IncreasePercent: 20, RSSIncreasePercent: 20},
+			[]string{},
+		},
+		{
+			"all regressions",
+			&Stats{Target: "candidate", Requests: 100, Errors: 2, Throughput: 800, P99: 15 * time.Millisecond, MaxRSSBytes: 150, GCPauseSeconds: 1, AllocatedBytes: 2000},
+			Thresholds{ThroughputDropPercent: 10, P99IncreasePercent: 20, RSSIncreasePercent: 20, GCPauseIncreasePercent: 50, AllocatedIncreasePercent: 50},
+			[]string{
+				"candidate requests errors: 2 of 100",
+				"throughput drop: 20.00% (threshold: 10.00%)",
+				"p99 latency increase: 50.00% (threshold: 20.00%)",
+				"max RSS increase: 50.00% (threshold: 20.00%)",
+				"GC pause increase: 100.00% (threshold: 50.00%)",
+				"allocated bytes increase: 100.00% (threshold: 50.00%)",
+			},
+		},
+		{
+			"improvements",
+			&Stats{Target: "candidate", Requests: 100, Throughput: 2000, P99: 5 * time.Millisecond, MaxRSSBytes: 50},
+			Thresholds{ThroughputDropPercent: 1, P99IncreasePercent: 1, RSSIncreasePercent: 1},
+			[]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := Compare(baseline, tt.candidate, tt.thresholds)
+			require.Equal(t, tt.expectedRegressions, report.Regressions)
+		})
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	latencies := make([]time.Duration, 100)
+	for i := range latencies {
+		latencies[i] = time.Duration(i+1) * time.Millisecond
+	}
+
+	require.Equal(t, time.Duration(0), percentile(nil, 99))
+	require.Equal(t, 50*time.Millisecond, percentile(latencies, 50))
+	require.Equal(t, 99*time.Millisecond, percentile(latencies, 99))
+	require.Equal(t, 100*time.Millisecond, percentile(latencies, 100))
+	require.Equal(t, time.Millisecond, percentile(latencies[:1], 99))
+}
+
+func TestParseMetrics(t *testing.T) {
+	payload := `# HELP go_gc_duration_seconds A summary of the GC invocation durations.
+# TYPE go_gc_duration_seconds summary
+go_gc_duration_seconds{quantile="0.5"} 4.5e-05
+go_gc_duration_seconds_sum 0.0125
+go_gc_duration_seconds_count 42
+process_resident_memory_bytes 3.3554432e+07
+`
+	values, err := parseMetrics(strings.NewReader(payload))
+	require.NoError(t, err)
+	require.Equal(t, map[string]float64{
+		`go_gc_duration_seconds{quantile="0.5"}`: 4.5e-05,
+		gcPauseMetric:                            0.0125,
+		gcCountMetric:                            42,
+		rssMetric:                                33554432,
+	}, values)
+
+	_, err = parseMetrics(strings.NewReader("go_gc_duration_seconds_count abc"))
+	require.Error(t, err)
+}
+
+func envInt(t *testing.T, name string) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return 0
+	}
+
+	result, err := strconv.Atoi(value)
+	require.NoError(t, err, name)
+	return result
+}
+
+func envFloat(t *testing.T, name string, defaultValue float64) float64 {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue
+	}
+
+	result, err := strconv.ParseFloat(value, 64)
+	require.NoError(t, err, name)
+	return result
+}
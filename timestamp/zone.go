@@ -0,0 +1,61 @@
+package timestamp
+
+import (
+	"fmt"
+	"time"
+)
+
+//OffsetLayout is Layout with zone offset. UTC values are formatted the same as with Layout
+const OffsetLayout = "2006-01-02T15:04:05.000000Z07:00"
+
+//local wall clock time and zone offset fields suffixes
+const (
+	LocalSuffix = "_local"
+	ZoneSuffix  = "_tz"
+)
+
+var (
+	location    = time.UTC
+	localFields = false
+)
+
+//Config is a timestamps normalization configuration (server.timestamps)
+type Config struct {
+	//IANA zone name (e.g. Europe/Berlin). TIMESTAMP values are converted into the zone. Default is UTC
+	Zone string `mapstructure:"zone"`
+	//if true - timestamp fields with mapping typecast get <field>_local (wall clock time of the source offset) and <field>_tz (source offset) fields
+	LocalFields bool `mapstructure:"local_fields"`
+}
+
+//Init set up global timestamps normalization zone and local fields emission
+func Init(config *Config) error {
+	if config == nil {
+		return nil
+	}
+
+	if config.Zone != "" {
+		loc, err := time.LoadLocation(config.Zone)
+		if err != nil {
+			return fmt.Errorf("Error loading timestamps zone [%s]: %v", config.Zone, err)
+		}
+		location = loc
+	}
+	localFields = config.LocalFields
+	return nil
+}
+
+//Location return zone which TIMESTAMP values are normalized into
+func Location() *time.Location {
+	return location
+}
+
+//LocalFields return true if <field>_local and <field>_tz fields should be emitted
+func LocalFields() bool {
+	return localFields
+}
+
+//Local return wall clock time of t as UTC time and t zone offset (+03:00)
+func Local(t time.Time) (time.Time, string) {
+	wallClock := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	return wallClock, t.Format("-07:00")
+}
@@ -0,0 +1,295 @@
+package trackingplan
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jitsucom/eventnative/jsonutils"
+	"github.com/jitsucom/eventnative/validation"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	//SegmentFormat: Segment Protocols tracking plan JSON (Config API rules.events or Public API rules with TRACK type)
+	SegmentFormat = "segment"
+	//AvoFormat: Avo tracking plan JSON export (events with properties list)
+	AvoFormat = "avo"
+
+	//Segment track properties are stored in event_data (see events.ConvertSegment)
+	DefaultPropertiesPath = "/event_data"
+)
+
+var (
+	nonAlphanumericRegex = regexp.MustCompile(`[^a-z0-9]+`)
+
+	avoTypes = map[string]string{
+		"string": "string",
+		"int":    "integer",
+		"float":  "number",
+		"bool":   "boolean",
+		"object": "object",
+	}
+)
+
+//Imported is a tracking plan converted from an external format:
+//native tracking plan events and validation schemas (server.validation.schemas) with properties types
+type Imported struct {
+	TrackingPlan *Config                             `json:"tracking_plan"`
+	Schemas      map[string]*validation.SchemaConfig `json:"schemas,omitempty"`
+	//events which validation schemas can't be converted (e.g. they use $ref)
+	Warnings []string `json:"-"`
+}
+
+//importedEvent is an event in an intermediate format: properties JSON Schema (object with properties and required)
+type importedEvent struct {
+	name       string
+	properties map[string]interface{}
+}
+
+type segmentPlan struct {
+	//Config API format
+	Rules json.RawMessage `json:"rules"`
+	//Public API format: {"data": {"rules": [...]}}
+	Data *segmentPlan `json:"data"`
+}
+
+type segmentConfigRules struct {
+	Events []*segmentConfigEvent `json:"events"`
+}
+
+type segmentConfigEvent struct {
+	Name  string                 `json:"name"`
+	Rules map[string]interface{} `json:"rules"`
+}
+
+type segmentPublicRule struct {
+	Type       string                 `json:"type"`
+	Key        string                 `json:"key"`
+	JsonSchema map[string]interface{} `json:"jsonSchema"`
+}
+
+type avoPlan struct {
+	Events []*avoEvent `json:"events"`
+}
+
+type avoEvent struct {
+	Name       string         `json:"name"`
+	Properties []*avoProperty `json:"properties"`
+}
+
+type avoProperty struct {
+	Name          string        `json:"name"`
+	Type          string        `json:"type"`
+	Required      bool          `json:"required"`
+	Optional      *bool         `json:"optional"`
+	List          bool          `json:"list"`
+	AllowedValues []interface{} `json:"allowedValues"`
+}
+
+//Import return tracking plan config and validation schemas from Segment Protocols or Avo export
+//properties of the imported events are put under propertiesPath (DefaultPropertiesPath if empty, "/" - event root)
+func Import(format string, payload []byte, propertiesPath string) (*Imported, error) {
+	var events []*importedEvent
+	var err error
+	switch format {
+	case SegmentFormat:
+		events, err = parseSegment(payload)
+	case AvoFormat:
+		events, err = parseAvo(payload)
+	default:
+		return nil, fmt.Errorf("Unknown tracking plan format: %s. Available: [%s, %s]", format, SegmentFormat, AvoFormat)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, fmt.Errorf("Tracking plan doesn't contain events")
+	}
+
+	if propertiesPath == "" {
+		propertiesPath = DefaultPropertiesPath
+	}
+	prefix := jsonutils.NewJsonPath(propertiesPath).Parts()
+
+	imported := &Imported{TrackingPlan: &Config{Enabled: true}, Schemas: map[string]*validation.SchemaConfig{}}
+	for _, e := range events {
+		eventConfig := &EventConfig{Name: e.name}
+		required := toSet(e.properties["required"])
+		properties, _ := e.properties["properties"].(map[string]interface{})
+		for _, name := range sortedKeys(properties) {
+			path := "/" + strings.Join(append(append([]string{}, prefix...), name), "/")
+			if required[name] {
+				eventConfig.Required = append(eventConfig.Required, path)
+			} else {
+				eventConfig.Optional = append(eventConfig.Optional, path)
+			}
+		}
+		imported.TrackingPlan.Events = append(imported.TrackingPlan.Events, eventConfig)
+
+		if len(properties) == 0 {
+			continue
+		}
+		b, err := json.Marshal(wrap(prefix, e.properties))
+		if err != nil {
+			return nil, fmt.Errorf("Error marshalling event [%s] schema: %v", e.name, err)
+		}
+		if _, err := validation.ParseSchema(b); err != nil {
+			imported.Warnings = append(imported.Warnings, fmt.Sprintf("Event [%s] properties validation schema is skipped: %v", e.name, err))
+			continue
+		}
+		imported.Schemas[schemaName(e.name, imported.Schemas)] = &validation.SchemaConfig{EventTypes: []string{e.name}, Schema: string(b)}
+	}
+
+	return imported, nil
+}
+
+func parseSegment(payload []byte) ([]*importedEvent, error) {
+	plan := &segmentPlan{}
+	if err := json.Unmarshal(payload, plan); err != nil {
+		return nil, fmt.Errorf("Error unmarshalling Segment tracking plan: %v", err)
+	}
+	if plan.Data != nil {
+		plan = plan.Data
+	}
+	if len(plan.Rules) == 0 {
+		return nil, fmt.Errorf("Segment tracking plan doesn't contain rules")
+	}
+
+	var events []*importedEvent
+	//Public API: list of rules
+	if strings.HasPrefix(strings.TrimSpace(string(plan.Rules)), "[") {
+		var rules []*segmentPublicRule
+		if err := json.Unmarshal(plan.Rules, &rules); err != nil {
+			return nil, fmt.Errorf("Error unmarshalling Segment tracking plan rules: %v", err)
+		}
+		for _, rule := range rules {
+			if strings.ToUpper(rule.Type) == "TRACK" {
+				events = append(events, &importedEvent{name: rule.Key, properties: segmentProperties(rule.JsonSchema)})
+			}
+		}
+		return uniqueEvents(events)
+	}
+
+	rules := &segmentConfigRules{}
+	if err := json.Unmarshal(plan.Rules, rules); err != nil {
+		return nil, fmt.Errorf("Error unmarshalling Segment tracking plan rules: %v", err)
+	}
+	for _, event := range rules.Events {
+		events = append(events, &importedEvent{name: event.Name, properties: segmentProperties(event.Rules)})
+	}
+	return uniqueEvents(events)
+}
+
+//segmentProperties return properties JSON Schema from the Segment event JSON Schema (properties.properties)
+func segmentProperties(rules map[string]interface{}) map[string]interface{} {
+	properties, _ := rules["properties"].(map[string]interface{})
+	eventProperties, _ := properties["properties"].(map[string]interface{})
+	if eventProperties == nil {
+		return map[string]interface{}{}
+	}
+	return eventProperties
+}
+
+func parseAvo(payload []byte) ([]*importedEvent, error) {
+	plan := &avoPlan{}
+	if err := json.Unmarshal(payload, plan); err != nil {
+		return nil, fmt.Errorf("Error unmarshalling Avo tracking plan: %v", err)
+	}
+
+	var events []*importedEvent
+	for _, event := range plan.Events {
+		properties := map[string]interface{}{}
+		var required []interface{}
+		for _, property := range event.Properties {
+			if property.Name == "" {
+				return nil, fmt.Errorf("Avo event [%s] has property without name", event.Name)
+			}
+			if property.Required || (property.Optional != nil && !*property.Optional) {
+				required = append(required, property.Name)
+			}
+
+			propertySchema := map[string]interface{}{}
+			if t, ok := avoTypes[strings.ToLower(property.Type)]; ok {
+				propertySchema["type"] = t
+			}
+			if len(property.AllowedValues) > 0 {
+				propertySchema["enum"] = property.AllowedValues
+			}
+			if property.List {
+				propertySchema = map[string]interface{}{"type": "array", "items": propertySchema}
+			}
+			properties[property.Name] = propertySchema
+		}
+
+		eventSchema := map[string]interface{}{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			eventSchema["required"] = required
+		}
+		events = append(events, &importedEvent{name: event.Name, properties: eventSchema})
+	}
+	return uniqueEvents(events)
+}
+
+func uniqueEvents(events []*importedEvent) ([]*importedEvent, error) {
+	names := map[string]bool{}
+	for _, e := range events {
+		if e.name == "" {
+			return nil, fmt.Errorf("Tracking plan event name is required")
+		}
+		if names[e.name] {
+			return nil, fmt.Errorf("Tracking plan event [%s] is declared twice", e.name)
+		}
+		names[e.name] = true
+	}
+	return events, nil
+}
+
+//wrap return JSON Schema of the event where properties schema is nested into prefix objects
+func wrap(prefix []string, properties map[string]interface{}) map[string]interface{} {
+	wrapped := properties
+	_, hasRequired := properties["required"]
+	for i := len(prefix) - 1; i >= 0; i-- {
+		parent := map[string]interface{}{"type": "object", "properties": map[string]interface{}{prefix[i]: wrapped}}
+		if hasRequired {
+			parent["required"] = []string{prefix[i]}
+		}
+		wrapped = parent
+	}
+	return wrapped
+}
+
+//schemaName return lowercased unique name of the validation schema (config keys are lowercased)
+func schemaName(eventName string, existing map[string]*validation.SchemaConfig) string {
+	name := strings.Trim(nonAlphanumericRegex.ReplaceAllString(strings.ToLower(eventName), "_"), "_")
+	if name == "" {
+		name = "event"
+	}
+	name += "_properties"
+
+	unique := name
+	for i := 2; existing[unique] != nil; i++ {
+		unique = fmt.Sprintf("%s_%d", name, i)
+	}
+	return unique
+}
+
+func toSet(value interface{}) map[string]bool {
+	set := map[string]bool{}
+	values, _ := value.([]interface{})
+	for _, v := range values {
+		if str, ok := v.(string); ok {
+			set[str] = true
+		}
+	}
+	return set
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
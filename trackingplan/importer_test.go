@@ -0,0 +1,57 @@
+package trackingplan
+
+import (
+	"github.com/jitsucom/eventnative/validation"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestImportSegment(t *testing.T) {
+	payload := []byte(`{"display_name": "App", "rules": {"events": [
+		{"name": "Order Completed", "rules": {"type": "object", "properties": {"properties": {"type": "object",
+			"properties": {"revenue": {"type": ["number"]}, "coupon": {"type": ["string", "null"]}}, "required": ["revenue"]}}}},
+		{"name": "Signed Out", "rules": {"type": "object"}}
+	]}}`)
+
+	imported, err := Import(SegmentFormat, payload, "")
+	require.NoError(t, err)
+	require.Equal(t, []*EventConfig{
+		{Name: "Order Completed", Required: []string{"/event_data/revenue"}, Optional: []string{"/event_data/coupon"}},
+		{Name: "Signed Out"},
+	}, imported.TrackingPlan.Events)
+	require.Equal(t, map[string]*validation.SchemaConfig{
+		"order_completed_properties": {
+			EventTypes: []string{"Order Completed"},
+			Schema:     `{"properties":{"event_data":{"properties":{"coupon":{"type":["string","null"]},"revenue":{"type":["number"]}},"required":["revenue"],"type":"object"}},"required":["event_data"],"type":"object"}`,
+		},
+	}, imported.Schemas)
+}
+
+func TestImportSegmentPublicAPI(t *testing.T) {
+	payload := []byte(`{"data": {"rules": [
+		{"type": "IDENTIFY", "key": "", "jsonSchema": {}},
+		{"type": "TRACK", "key": "Signup", "jsonSchema": {"properties": {"properties": {"properties": {"plan": {"$ref": "#/definitions/plan"}}}}}}
+	]}}`)
+
+	imported, err := Import(SegmentFormat, payload, "/")
+	require.NoError(t, err)
+	require.Equal(t, []*EventConfig{{Name: "Signup", Optional: []string{"/plan"}}}, imported.TrackingPlan.Events)
+	require.Empty(t, imported.Schemas)
+	require.Len(t, imported.Warnings, 1)
+}
+
+func TestImportAvo(t *testing.T) {
+	payload := []byte(`{"events": [{"name": "Checkout", "properties": [
+		{"name": "Items", "type": "string", "list": true, "required": true},
+		{"name": "Method", "type": "string", "allowedValues": ["card", "paypal"]}
+	]}]}`)
+
+	imported, err := Import(AvoFormat, payload, "/props")
+	require.NoError(t, err)
+	require.Equal(t, []*EventConfig{{Name: "Checkout", Required: []string{"/props/Items"}, Optional: []string{"/props/Method"}}}, imported.TrackingPlan.Events)
+	require.Equal(t, `{"properties":{"props":{"properties":{"Items":{"items":{"type":"string"},"type":"array"},"Method":{"enum":["card","paypal"],"type":"string"}},"required":["Items"],"type":"object"}},"required":["props"],"type":"object"}`,
+		imported.Schemas["checkout_properties"].Schema)
+
+	_, err = Import("mixpanel", payload, "")
+	require.EqualError(t, err, "Unknown tracking plan format: mixpanel. Available: [segment, avo]")
+}
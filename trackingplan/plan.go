@@ -0,0 +1,294 @@
+package trackingplan
+
+import (
+	"fmt"
+	"github.com/jitsucom/eventnative/jsonutils"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/metrics"
+	"sort"
+	"strings"
+)
+
+const (
+	//BlockAction: events are rejected with HTTP 400 response and violations details
+	BlockAction = "block"
+	//QuarantineAction: events are accepted and written into the token destinations fallback (with violations as error)
+	QuarantineAction = "quarantine"
+	//TagAction: events are stored with violations in the tag field
+	TagAction = "tag"
+
+	UnknownEvent    = "unknown_event"
+	MissingProperty = "missing_property"
+	UnknownProperty = "unknown_property"
+
+	defaultTagField       = "/eventn_ctx/tracking_plan_violations"
+	defaultEventTypeField = "/event_type"
+	unknownEventType      = "unknown"
+)
+
+var defaultCommonProperties = []string{"/eventn_ctx", "/src"}
+
+//EventConfig is a declared event with its properties (JSON paths)
+type EventConfig struct {
+	Name     string   `mapstructure:"name" json:"name" yaml:"name"`
+	Required []string `mapstructure:"required" json:"required,omitempty" yaml:"required,omitempty"`
+	Optional []string `mapstructure:"optional" json:"optional,omitempty" yaml:"optional,omitempty"`
+}
+
+//Config is a tracking plan configuration. Events are a list (not a map) because config keys are lowercased
+type Config struct {
+	Enabled bool `mapstructure:"enabled" json:"enabled" yaml:"enabled"`
+	//token ids. Empty means all tokens
+	Tokens []string `mapstructure:"tokens" json:"tokens,omitempty" yaml:"tokens,omitempty"`
+	//block, quarantine or tag (default)
+	Action   string `mapstructure:"action" json:"action,omitempty" yaml:"action,omitempty"`
+	TagField string `mapstructure:"tag_field" json:"tag_field,omitempty" yaml:"tag_field,omitempty"`
+	//strict: event properties which aren't declared (required, optional or common) are violations
+	Strict bool `mapstructure:"strict" json:"strict,omitempty" yaml:"strict,omitempty"`
+	//properties which are allowed in all events. Default: /eventn_ctx, /src
+	CommonProperties []string       `mapstructure:"common_properties" json:"common_properties,omitempty" yaml:"common_properties,omitempty"`
+	Events           []*EventConfig `mapstructure:"events" json:"events,omitempty" yaml:"events,omitempty"`
+}
+
+//Violation is a tracking plan violation. Property is empty for unknown events
+type Violation struct {
+	Kind     string `json:"kind"`
+	Property string `json:"property,omitempty"`
+}
+
+func (v *Violation) String() string {
+	if v.Property == "" {
+		return v.Kind
+	}
+	return v.Kind + " " + v.Property
+}
+
+//Error is returned if the event doesn't conform to the tracking plan with block or quarantine action
+type Error struct {
+	Action     string
+	EventType  string
+	Violations []*Violation
+}
+
+func (e *Error) Error() string {
+	violations := make([]string, 0, len(e.Violations))
+	for _, violation := range e.Violations {
+		violations = append(violations, violation.String())
+	}
+	return fmt.Sprintf("Event [%s] doesn't conform to tracking plan: %s", e.EventType, strings.Join(violations, "; "))
+}
+
+type event struct {
+	required []*jsonutils.JsonPath
+	//required, optional and common properties parts
+	allowed [][]string
+}
+
+//Plan checks events against declared event names and their properties
+//non-conforming events are blocked, quarantined or tagged. Checks results are collected into the conformance report
+type Plan struct {
+	action         string
+	tokens         map[string]bool
+	strict         bool
+	tagField       *jsonutils.JsonPath
+	eventTypeField *jsonutils.JsonPath
+	events         map[string]*event
+
+	report *report
+}
+
+//NewPlan return configured Plan or nil if it is disabled
+func NewPlan(config Config) (*Plan, error) {
+	if !config.Enabled {
+		return nil, nil
+	}
+
+	action := config.Action
+	if action == "" {
+		action = TagAction
+	}
+	if action != BlockAction && action != QuarantineAction && action != TagAction {
+		return nil, fmt.Errorf("Unknown tracking plan action: %s. Available: [%s, %s, %s]", action, BlockAction, QuarantineAction, TagAction)
+	}
+	tagField := config.TagField
+	if tagField == "" {
+		tagField = defaultTagField
+	}
+	commonProperties := config.CommonProperties
+	if len(commonProperties) == 0 {
+		commonProperties = defaultCommonProperties
+	}
+
+	p := &Plan{
+		action:         action,
+		tokens:         map[string]bool{},
+		strict:         config.Strict,
+		tagField:       jsonutils.NewJsonPath(tagField),
+		eventTypeField: jsonutils.NewJsonPath(defaultEventTypeField),
+		events:         map[string]*event{},
+		report:         newReport(),
+	}
+	for _, token := range config.Tokens {
+		p.tokens[strings.TrimSpace(token)] = true
+	}
+
+	for _, eventConfig := range config.Events {
+		if eventConfig.Name == "" {
+			return nil, fmt.Errorf("Tracking plan event name is required")
+		}
+		if _, ok := p.events[eventConfig.Name]; ok {
+			return nil, fmt.Errorf("Tracking plan event [%s] is declared twice", eventConfig.Name)
+		}
+
+		e := &event{allowed: [][]string{p.eventTypeField.Parts(), p.tagField.Parts()}}
+		for _, property := range eventConfig.Required {
+			path := jsonutils.NewJsonPath(property)
+			if path.IsEmpty() {
+				return nil, fmt.Errorf("Tracking plan event [%s] has empty required property", eventConfig.Name)
+			}
+			e.required = append(e.required, path)
+			e.allowed = append(e.allowed, path.Parts())
+		}
+		for _, property := range append(eventConfig.Optional, commonProperties...) {
+			path := jsonutils.NewJsonPath(property)
+			if path.IsEmpty() {
+				return nil, fmt.Errorf("Tracking plan event [%s] has empty optional property", eventConfig.Name)
+			}
+			e.allowed = append(e.allowed, path.Parts())
+		}
+		p.events[eventConfig.Name] = e
+		p.report.declare(eventConfig.Name)
+	}
+
+	logging.Infof("[tracking_plan] Initialized with %d events and action: %s", len(p.events), p.action)
+	return p, nil
+}
+
+//EventsCount return count of the declared events or 0 if the plan isn't configured
+func (p *Plan) EventsCount() int {
+	if p == nil {
+		return 0
+	}
+	return len(p.events)
+}
+
+//Check return nil if the event conforms to the tracking plan, the plan isn't configured or isn't applied to the token
+//with tag action non-conforming events are tagged and nil is returned, otherwise return *Error with the plan action
+func (p *Plan) Check(tokenId string, object map[string]interface{}) *Error {
+	if p == nil || (len(p.tokens) > 0 && !p.tokens[tokenId]) {
+		return nil
+	}
+
+	eventType := ""
+	if value, ok := p.eventTypeField.Get(object); ok && value != nil {
+		eventType = fmt.Sprint(value)
+	}
+
+	violations := p.violations(eventType, object)
+	metricsEventType := eventType
+	if _, ok := p.events[eventType]; !ok {
+		metricsEventType = unknownEventType
+	}
+
+	p.report.add(eventType, violations)
+	if len(violations) == 0 {
+		metrics.TrackingPlanEvent(metricsEventType, "conforming")
+		return nil
+	}
+
+	metrics.TrackingPlanEvent(metricsEventType, p.action)
+	for _, violation := range violations {
+		metrics.TrackingPlanViolation(metricsEventType, violation.Kind)
+	}
+
+	if p.action == TagAction {
+		tags := make([]string, 0, len(violations))
+		for _, violation := range violations {
+			tags = append(tags, violation.String())
+		}
+		if !p.tagField.Set(object, strings.Join(tags, ",")) {
+			logging.Warnf("[tracking_plan] Tag can't be set into %s: node isn't an object", p.tagField.String())
+		}
+		return nil
+	}
+
+	return &Error{Action: p.action, EventType: eventType, Violations: violations}
+}
+
+//Report return conformance report of the current instance sorted by event type
+func (p *Plan) Report() *Report {
+	if p == nil {
+		return &Report{EventTypes: []*EventReport{}}
+	}
+
+	report := p.report.get()
+	report.Action = p.action
+	return report
+}
+
+//violations return violations sorted by kind and property
+func (p *Plan) violations(eventType string, object map[string]interface{}) []*Violation {
+	e, ok := p.events[eventType]
+	if !ok {
+		return []*Violation{{Kind: UnknownEvent}}
+	}
+
+	var violations []*Violation
+	for _, required := range e.required {
+		if value, ok := required.Get(object); !ok || value == nil {
+			violations = append(violations, &Violation{Kind: MissingProperty, Property: required.String()})
+		}
+	}
+
+	if p.strict {
+		for _, property := range leaves(nil, object) {
+			if !e.isAllowed(property) {
+				violations = append(violations, &Violation{Kind: UnknownProperty, Property: "/" + strings.Join(property, "/")})
+			}
+		}
+	}
+
+	sort.Slice(violations, func(i, j int) bool {
+		if violations[i].Kind != violations[j].Kind {
+			return violations[i].Kind < violations[j].Kind
+		}
+		return violations[i].Property < violations[j].Property
+	})
+	return violations
+}
+
+//isAllowed return true if the property or its parent is declared or the property is the parent of a declared one
+func (e *event) isAllowed(property []string) bool {
+	for _, allowed := range e.allowed {
+		if isPrefix(allowed, property) || isPrefix(property, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+func isPrefix(prefix, path []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for i, part := range prefix {
+		if path[i] != part {
+			return false
+		}
+	}
+	return true
+}
+
+//leaves return paths of all non-object values of the object
+func leaves(parent []string, object map[string]interface{}) [][]string {
+	var paths [][]string
+	for key, value := range object {
+		path := append(append([]string{}, parent...), key)
+		if sub, ok := value.(map[string]interface{}); ok && len(sub) > 0 {
+			paths = append(paths, leaves(path, sub)...)
+		} else {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
@@ -0,0 +1,73 @@
+package trackingplan
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	config := Config{
+		Enabled: true,
+		Action:  BlockAction,
+		Strict:  true,
+		Events: []*EventConfig{
+			{Name: "signup", Required: []string{"/user/email"}, Optional: []string{"/user/name"}},
+		},
+	}
+	plan, err := NewPlan(config)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		input      map[string]interface{}
+		violations []*Violation
+	}{
+		{
+			"conforming event",
+			map[string]interface{}{"event_type": "signup", "user": map[string]interface{}{"email": "a@b.c", "name": "a"}, "eventn_ctx": map[string]interface{}{"url": "u"}},
+			nil,
+		},
+		{
+			"unknown event",
+			map[string]interface{}{"event_type": "login"},
+			[]*Violation{{Kind: UnknownEvent}},
+		},
+		{
+			"missing and unknown properties",
+			map[string]interface{}{"event_type": "signup", "user": map[string]interface{}{"email": nil, "phone": "1"}, "plan": "pro"},
+			[]*Violation{{Kind: MissingProperty, Property: "/user/email"}, {Kind: UnknownProperty, Property: "/plan"}, {Kind: UnknownProperty, Property: "/user/phone"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkErr := plan.Check("token", tt.input)
+			if tt.violations == nil {
+				require.Nil(t, checkErr)
+				return
+			}
+			require.NotNil(t, checkErr)
+			require.Equal(t, BlockAction, checkErr.Action)
+			require.Equal(t, tt.violations, checkErr.Violations)
+		})
+	}
+
+	report := plan.Report()
+	require.Equal(t, int64(3), report.Events)
+	require.Equal(t, int64(1), report.Conforming)
+	require.Len(t, report.EventTypes, 2)
+	require.Equal(t, "login", report.EventTypes[0].EventType)
+	require.False(t, report.EventTypes[0].Declared)
+	require.Equal(t, map[string]int64{"missing_property /user/email": 1, "unknown_property /plan": 1, "unknown_property /user/phone": 1}, report.EventTypes[1].Violations)
+}
+
+func TestCheckTag(t *testing.T) {
+	plan, err := NewPlan(Config{Enabled: true, Tokens: []string{"token"}, Events: []*EventConfig{{Name: "signup", Required: []string{"/email"}}}})
+	require.NoError(t, err)
+
+	event := map[string]interface{}{"event_type": "signup"}
+	require.Nil(t, plan.Check("other_token", event))
+	require.Equal(t, map[string]interface{}{"event_type": "signup"}, event)
+
+	require.Nil(t, plan.Check("token", event))
+	require.Equal(t, map[string]interface{}{"event_type": "signup", "eventn_ctx": map[string]interface{}{"tracking_plan_violations": "missing_property /email"}}, event)
+}
@@ -0,0 +1,114 @@
+package trackingplan
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+//max distinct undeclared event types in the report. Others are counted as unknown
+const maxUndeclaredEvents = 100
+
+//EventReport is conformance statistics of one event type
+//Violations: violation (kind and property) -> events count
+type EventReport struct {
+	EventType       string           `json:"event_type"`
+	Declared        bool             `json:"declared"`
+	Events          int64            `json:"events"`
+	Conforming      int64            `json:"conforming"`
+	ConformanceRate float64          `json:"conformance_rate"`
+	Violations      map[string]int64 `json:"violations,omitempty"`
+	LastSeen        *time.Time       `json:"last_seen,omitempty"`
+	LastViolation   *time.Time       `json:"last_violation,omitempty"`
+}
+
+//Report is a tracking plan conformance report of the current instance
+type Report struct {
+	Action          string         `json:"action"`
+	Events          int64          `json:"events"`
+	Conforming      int64          `json:"conforming"`
+	ConformanceRate float64        `json:"conformance_rate"`
+	EventTypes      []*EventReport `json:"event_types"`
+}
+
+type report struct {
+	sync.RWMutex
+
+	events     map[string]*EventReport
+	undeclared int
+	now        func() time.Time
+}
+
+func newReport() *report {
+	return &report{events: map[string]*EventReport{}, now: time.Now}
+}
+
+func (r *report) declare(eventType string) {
+	r.events[eventType] = &EventReport{EventType: eventType, Declared: true}
+}
+
+func (r *report) add(eventType string, violations []*Violation) {
+	r.Lock()
+	defer r.Unlock()
+
+	eventReport, ok := r.events[eventType]
+	if !ok {
+		if r.undeclared >= maxUndeclaredEvents {
+			eventType = unknownEventType
+		}
+		eventReport, ok = r.events[eventType]
+		if !ok {
+			eventReport = &EventReport{EventType: eventType}
+			r.events[eventType] = eventReport
+			r.undeclared++
+		}
+	}
+
+	now := r.now().UTC()
+	eventReport.Events++
+	eventReport.LastSeen = &now
+	if len(violations) == 0 {
+		eventReport.Conforming++
+		return
+	}
+
+	eventReport.LastViolation = &now
+	if eventReport.Violations == nil {
+		eventReport.Violations = map[string]int64{}
+	}
+	for _, violation := range violations {
+		eventReport.Violations[violation.String()]++
+	}
+}
+
+func (r *report) get() *Report {
+	r.RLock()
+	defer r.RUnlock()
+
+	result := &Report{EventTypes: make([]*EventReport, 0, len(r.events))}
+	for _, eventReport := range r.events {
+		reportCopy := *eventReport
+		reportCopy.Violations = make(map[string]int64, len(eventReport.Violations))
+		for violation, count := range eventReport.Violations {
+			reportCopy.Violations[violation] = count
+		}
+		reportCopy.ConformanceRate = rate(reportCopy.Conforming, reportCopy.Events)
+
+		result.Events += reportCopy.Events
+		result.Conforming += reportCopy.Conforming
+		result.EventTypes = append(result.EventTypes, &reportCopy)
+	}
+	result.ConformanceRate = rate(result.Conforming, result.Events)
+
+	sort.Slice(result.EventTypes, func(i, j int) bool {
+		return result.EventTypes[i].EventType < result.EventTypes[j].EventType
+	})
+	return result
+}
+
+func rate(conforming, events int64) float64 {
+	if events == 0 {
+		return 0
+	}
+	return float64(conforming) / float64(events)
+}
@@ -0,0 +1,123 @@
+package typing
+
+import (
+	"fmt"
+	"github.com/jitsucom/eventnative/timestamp"
+	"strconv"
+	"time"
+)
+
+//ConvertColumn convert all values which have fromType (one source type column) into toType in place.
+//Values are converted in one pass over typed slice (without per value type detection and rule lookup) if they all have
+//the same Go type (int64, float64, string or time.Time). Otherwise every value is converted with Convert.
+//return index of the value which can't be converted and error
+func ConvertColumn(fromType, toType DataType, values []interface{}) (int, error) {
+	if fromType == toType || len(values) == 0 {
+		return -1, nil
+	}
+
+	r := rule{from: fromType, to: toType}
+	if _, ok := convertRules[r]; !ok {
+		return 0, fmt.Errorf("No rule for converting %s to %s", fromType.String(), toType.String())
+	}
+
+	switch r {
+	case rule{from: INT64, to: STRING}:
+		if ints, ok := int64Values(values); ok {
+			for i, v := range ints {
+				values[i] = strconv.FormatInt(v, 10)
+			}
+			return -1, nil
+		}
+	case rule{from: INT64, to: FLOAT64}:
+		if ints, ok := int64Values(values); ok {
+			for i, v := range ints {
+				values[i] = float64(v)
+			}
+			return -1, nil
+		}
+	case rule{from: FLOAT64, to: STRING}:
+		if floats, ok := float64Values(values); ok {
+			for i, v := range floats {
+				values[i] = strconv.FormatFloat(v, 'f', -1, 64)
+			}
+			return -1, nil
+		}
+	case rule{from: TIMESTAMP, to: STRING}:
+		if times, ok := timeValues(values); ok {
+			for i, v := range times {
+				values[i] = v.Format(timestamp.OffsetLayout)
+			}
+			return -1, nil
+		}
+	case rule{from: STRING, to: TIMESTAMP}:
+		if strs, ok := stringValues(values); ok {
+			for i, v := range strs {
+				converted, err := stringToTimestamp(v)
+				if err != nil {
+					return i, err
+				}
+				values[i] = converted
+			}
+			return -1, nil
+		}
+	}
+
+	//mixed Go types
+	for i, v := range values {
+		converted, err := Convert(toType, v)
+		if err != nil {
+			return i, err
+		}
+		values[i] = converted
+	}
+	return -1, nil
+}
+
+func int64Values(values []interface{}) ([]int64, bool) {
+	result := make([]int64, len(values))
+	for i, v := range values {
+		typed, ok := v.(int64)
+		if !ok {
+			return nil, false
+		}
+		result[i] = typed
+	}
+	return result, true
+}
+
+func float64Values(values []interface{}) ([]float64, bool) {
+	result := make([]float64, len(values))
+	for i, v := range values {
+		typed, ok := v.(float64)
+		if !ok {
+			return nil, false
+		}
+		result[i] = typed
+	}
+	return result, true
+}
+
+func stringValues(values []interface{}) ([]string, bool) {
+	result := make([]string, len(values))
+	for i, v := range values {
+		typed, ok := v.(string)
+		if !ok {
+			return nil, false
+		}
+		result[i] = typed
+	}
+	return result, true
+}
+
+func timeValues(values []interface{}) ([]time.Time, bool) {
+	result := make([]time.Time, len(values))
+	for i, v := range values {
+		typed, ok := v.(time.Time)
+		if !ok {
+			return nil, false
+		}
+		result[i] = typed
+	}
+	return result, true
+}
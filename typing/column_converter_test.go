@@ -0,0 +1,117 @@
+package typing
+
+import (
+	"github.com/jitsucom/eventnative/test"
+	"github.com/jitsucom/eventnative/timestamp"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestConvertColumn(t *testing.T) {
+	testTime, _ := time.Parse(timestamp.Layout, "2020-08-02T18:23:58.057807Z")
+
+	tests := []struct {
+		name          string
+		from          DataType
+		to            DataType
+		input         []interface{}
+		expected      []interface{}
+		expectedIndex int
+		expectedErr   string
+	}{
+		{
+			"Same types",
+			STRING,
+			STRING,
+			[]interface{}{"a", "b"},
+			[]interface{}{"a", "b"},
+			-1,
+			"",
+		},
+		{
+			"int64 -> string",
+			INT64,
+			STRING,
+			[]interface{}{int64(1), int64(-20)},
+			[]interface{}{"1", "-20"},
+			-1,
+			"",
+		},
+		{
+			"mixed ints -> string",
+			INT64,
+			STRING,
+			[]interface{}{int64(1), 2, int8(3)},
+			[]interface{}{"1", "2", "3"},
+			-1,
+			"",
+		},
+		{
+			"int64 -> float64",
+			INT64,
+			FLOAT64,
+			[]interface{}{int64(1), int64(2)},
+			[]interface{}{float64(1), float64(2)},
+			-1,
+			"",
+		},
+		{
+			"float64 -> string",
+			FLOAT64,
+			STRING,
+			[]interface{}{1.5, float64(2)},
+			[]interface{}{"1.5", "2"},
+			-1,
+			"",
+		},
+		{
+			"timestamp -> string",
+			TIMESTAMP,
+			STRING,
+			[]interface{}{testTime},
+			[]interface{}{"2020-08-02T18:23:58.057807Z"},
+			-1,
+			"",
+		},
+		{
+			"string -> timestamp",
+			STRING,
+			TIMESTAMP,
+			[]interface{}{"2020-08-02T18:23:58.057807Z"},
+			[]interface{}{testTime},
+			-1,
+			"",
+		},
+		{
+			"string -> timestamp error",
+			STRING,
+			TIMESTAMP,
+			[]interface{}{"2020-08-02T18:23:58.057807Z", "abc"},
+			[]interface{}{testTime, "abc"},
+			1,
+			"Error stringToTimestamp() for value: abc: parsing time \"abc\" as \"2006-01-02T15:04:05.000Z\": cannot parse \"abc\" as \"2006\"",
+		},
+		{
+			"No rule",
+			STRING,
+			INT64,
+			[]interface{}{"1"},
+			[]interface{}{"1"},
+			0,
+			"No rule for converting STRING to INT64",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			index, err := ConvertColumn(tt.from, tt.to, tt.input)
+			require.Equal(t, tt.expectedIndex, index)
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+			test.ObjectsEqual(t, tt.expected, tt.input, "Converted values aren't equal")
+		})
+	}
+}
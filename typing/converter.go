@@ -15,6 +15,7 @@ import (
 //    |
 //  INT64(1)
 //
+//DECIMAL, BOOL, DATE and UUID common types are in outOfTreeCommonTypes
 var (
 	typecastTree = &typeNode{
 		t: STRING,
@@ -37,11 +38,35 @@ var (
 
 		rule{from: STRING, to: TIMESTAMP}: stringToTimestamp,
 
+		rule{from: INT64, to: DECIMAL}:   numberToDecimal,
+		rule{from: FLOAT64, to: DECIMAL}: numberToDecimal,
+		rule{from: STRING, to: DECIMAL}:  numberToDecimal,
+		rule{from: DECIMAL, to: FLOAT64}: decimalToFloat,
+		rule{from: DECIMAL, to: STRING}:  numberToString,
+
+		rule{from: BOOL, to: STRING}: boolToString,
+		rule{from: STRING, to: BOOL}: stringToBool,
+		rule{from: INT64, to: BOOL}:  intToBool,
+
+		rule{from: DATE, to: STRING}:    stringValue,
+		rule{from: DATE, to: TIMESTAMP}: dateToTimestamp,
+		rule{from: STRING, to: DATE}:    stringToDate,
+		rule{from: TIMESTAMP, to: DATE}: timestampToDate,
+
+		rule{from: UUID, to: STRING}: stringValue,
+
 		// Future
 		/*rule{from: STRING, to: INT64}:     stringToInt,
 		rule{from: STRING, to: FLOAT64}:   stringToFloat,
 		rule{from: FLOAT64, to: INT64}: floatToInt,*/
 	}
+	//common types of types which aren't a part of Typecast tree. All other pairs with them have STRING common type
+	//DECIMAL keeps integers without loss, floats are inexact anyway. DATE is a TIMESTAMP without time
+	outOfTreeCommonTypes = map[rule]DataType{
+		rule{from: DECIMAL, to: INT64}:   DECIMAL,
+		rule{from: DECIMAL, to: FLOAT64}: FLOAT64,
+		rule{from: DATE, to: TIMESTAMP}:  TIMESTAMP,
+	}
 
 	charsInNumberStringReplacer = strings.NewReplacer(",", "", " ", "")
 )
@@ -90,6 +115,24 @@ func Convert(toType DataType, v interface{}) (interface{}, error) {
 }
 
 func GetCommonAncestorType(t1, t2 DataType) DataType {
+	if t1 == t2 {
+		return t1
+	}
+
+	//see iota order of DataType
+	if t1 > TIMESTAMP || t2 > TIMESTAMP {
+		if t1 == UNKNOWN || t2 == UNKNOWN {
+			return UNKNOWN
+		}
+		if commonType, ok := outOfTreeCommonTypes[rule{from: t1, to: t2}]; ok {
+			return commonType
+		}
+		if commonType, ok := outOfTreeCommonTypes[rule{from: t2, to: t1}]; ok {
+			return commonType
+		}
+		return STRING
+	}
+
 	return lowestCommonAncestor(typecastTree, t1, t2)
 }
 
@@ -141,6 +184,8 @@ func numberToString(v interface{}) (interface{}, error) {
 	case string:
 		str, _ := v.(string)
 		return str, nil
+	case Decimal:
+		return v.(Decimal).String(), nil
 	default:
 		return nil, fmt.Errorf("Error numberToString(): Unknown value type: %t", v)
 	}
@@ -151,7 +196,7 @@ func timestampToString(v interface{}) (interface{}, error) {
 	switch v.(type) {
 	case time.Time:
 		timeValue, _ := v.(time.Time)
-		return timeValue.Format(timestamp.Layout), nil
+		return timeValue.Format(timestamp.OffsetLayout), nil
 	case string:
 		str, _ := v.(string)
 		return str, nil
@@ -160,6 +205,73 @@ func timestampToString(v interface{}) (interface{}, error) {
 	}
 }
 
+func boolToString(v interface{}) (interface{}, error) {
+	boolValue, ok := v.(bool)
+	if !ok {
+		return nil, fmt.Errorf("Value: %v with type: %t isn't bool", v, v)
+	}
+	return strconv.FormatBool(boolValue), nil
+}
+
+func stringToBool(v interface{}) (interface{}, error) {
+	boolValue, err := strconv.ParseBool(v.(string))
+	if err != nil {
+		return nil, fmt.Errorf("Error stringToBool() for value: %v: %v", v, err)
+	}
+	return boolValue, nil
+}
+
+func intToBool(v interface{}) (interface{}, error) {
+	switch value := v.(type) {
+	case int:
+		return value != 0, nil
+	case int8:
+		return value != 0, nil
+	case int16:
+		return value != 0, nil
+	case int32:
+		return value != 0, nil
+	case int64:
+		return value != 0, nil
+	default:
+		return nil, fmt.Errorf("Value: %v with type: %t isn't int", v, v)
+	}
+}
+
+//stringValue return DATE or UUID string value as is
+func stringValue(v interface{}) (interface{}, error) {
+	str, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("Value: %v with type: %t isn't string", v, v)
+	}
+	return str, nil
+}
+
+func dateToTimestamp(v interface{}) (interface{}, error) {
+	t, err := time.Parse(DateLayout, v.(string))
+	if err != nil {
+		return nil, fmt.Errorf("Error dateToTimestamp() for value: %v: %v", v, err)
+	}
+	return t, nil
+}
+
+//stringToDate return date string from timestamp string (2020-08-02T18:23:58.057807Z -> 2020-08-02)
+func stringToDate(v interface{}) (interface{}, error) {
+	t, err := stringToTimestamp(v)
+	if err != nil {
+		return nil, err
+	}
+	return timestampToDate(t)
+}
+
+func timestampToDate(v interface{}) (interface{}, error) {
+	t, ok := v.(time.Time)
+	if !ok {
+		return nil, fmt.Errorf("Value: %v with type: %t isn't time.Time", v, v)
+	}
+	return t.Format(DateLayout), nil
+}
+
 func numberToFloat(v interface{}) (interface{}, error) {
 	switch v.(type) {
 	case int:
@@ -200,16 +312,28 @@ func StringToFloat(v interface{}) (interface{}, error) {
 	return floatValue, nil
 }
 
+//stringToTimestamp return time in timestamp.Location() from string with any zone offset
 func stringToTimestamp(v interface{}) (interface{}, error) {
-	t, err := time.Parse(timestamp.Layout, v.(string))
+	t, err := ParseTimestamp(v.(string))
 	if err != nil {
-		t, err = time.Parse(timestamp.DeprecatedLayout, v.(string))
-		if err != nil {
-			return nil, fmt.Errorf("Error stringToTimestamp() for value: %v: %v", v, err)
-		}
+		return nil, fmt.Errorf("Error stringToTimestamp() for value: %v: %v", v, err)
 	}
 
-	return t, nil
+	return t.In(timestamp.Location()), nil
+}
+
+//ParseTimestamp return time with the source zone offset from string in timestamp.Layout or RFC3339 with any fraction digits
+//(2020-08-02T18:23:58.057807Z, 2020-08-02T21:23:58+03:00)
+func ParseTimestamp(value string) (time.Time, error) {
+	t, err := time.Parse(timestamp.Layout, value)
+	if err == nil {
+		return t, nil
+	}
+	t, err = time.Parse(time.RFC3339Nano, value)
+	if err == nil {
+		return t, nil
+	}
+	return time.Parse(timestamp.DeprecatedLayout, value)
 }
 
 //StringWithCommasToFloat return float64 value from string (1,200.50)
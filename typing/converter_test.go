@@ -2,6 +2,7 @@ package typing
 
 import (
 	"github.com/jitsucom/eventnative/test"
+	"github.com/jitsucom/eventnative/timestamp"
 	"github.com/stretchr/testify/require"
 	"testing"
 	"time"
@@ -64,6 +65,69 @@ func TestConvert(t *testing.T) {
 			float64(123),
 			"",
 		},
+		{
+			"bool -> string",
+			true,
+			STRING,
+			"true",
+			"",
+		},
+		{
+			"string -> bool",
+			"false",
+			BOOL,
+			false,
+			"",
+		},
+		{
+			"int -> bool",
+			int64(1),
+			BOOL,
+			true,
+			"",
+		},
+		{
+			"timestamp string -> date",
+			"2020-07-20T10:15:23.000000Z",
+			DATE,
+			"2020-07-20",
+			"",
+		},
+		{
+			"date -> timestamp",
+			"2020-07-20",
+			TIMESTAMP,
+			time.Date(2020, 07, 20, 0, 0, 0, 0, time.UTC),
+			"",
+		},
+		{
+			"uuid -> string",
+			"57ac6e96-172a-4929-9fcf-6e6b1f16afdc",
+			STRING,
+			"57ac6e96-172a-4929-9fcf-6e6b1f16afdc",
+			"",
+		},
+		{
+			"string -> uuid error",
+			"abc",
+			UUID,
+			nil,
+			"No rule for converting STRING to UUID",
+		},
+		{
+			"string with offset -> timestamp in UTC",
+			"2020-07-20T13:15:23.5+03:00",
+			TIMESTAMP,
+			time.Date(2020, 07, 20, 10, 15, 23, 500000000, time.UTC),
+			"",
+		},
+		{
+			"timestamp -> string",
+			time.Date(2020, 07, 20, 10, 15, 23, 0, time.UTC),
+			STRING,
+			"2020-07-20T10:15:23.000000Z",
+			"",
+		},
 		/* Future
 		{
 				"string -> int ok",
@@ -262,6 +326,42 @@ func TestGetCommonAncestorType(t *testing.T) {
 			TIMESTAMP,
 			STRING,
 		},
+		{
+			"int64+decimal=decimal",
+			INT64,
+			DECIMAL,
+			DECIMAL,
+		},
+		{
+			"decimal+float64=float64",
+			DECIMAL,
+			FLOAT64,
+			FLOAT64,
+		},
+		{
+			"decimal+timestamp=string",
+			DECIMAL,
+			TIMESTAMP,
+			STRING,
+		},
+		{
+			"date+timestamp=timestamp",
+			DATE,
+			TIMESTAMP,
+			TIMESTAMP,
+		},
+		{
+			"bool+int64=string",
+			BOOL,
+			INT64,
+			STRING,
+		},
+		{
+			"uuid+uuid=uuid",
+			UUID,
+			UUID,
+			UUID,
+		},
 	}
 
 	for _, tt := range tests {
@@ -270,3 +370,20 @@ func TestGetCommonAncestorType(t *testing.T) {
 		})
 	}
 }
+
+func TestConvertTimestampZone(t *testing.T) {
+	require.NoError(t, timestamp.Init(&timestamp.Config{Zone: "America/New_York"}))
+	defer timestamp.Init(&timestamp.Config{Zone: "UTC"})
+
+	converted, err := Convert(TIMESTAMP, "2020-07-20T10:15:23.000000Z")
+	require.NoError(t, err)
+	require.Equal(t, "2020-07-20T06:15:23.000000-04:00", converted.(time.Time).Format(timestamp.OffsetLayout))
+
+	str, err := Convert(STRING, converted)
+	require.NoError(t, err)
+	require.Equal(t, "2020-07-20T06:15:23.000000-04:00", str)
+
+	date, err := Convert(DATE, time.Date(2020, 07, 20, 2, 0, 0, 0, time.UTC).In(timestamp.Location()))
+	require.NoError(t, err)
+	require.Equal(t, "2020-07-19", date)
+}
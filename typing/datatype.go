@@ -17,20 +17,38 @@ const (
 	FLOAT64
 	STRING
 	TIMESTAMP
+	//types below aren't a part of Typecast tree (see typing.outOfTreeCommonTypes)
+	DECIMAL
+	BOOL
+	DATE
+	UUID
 )
 
+//DateLayout is a layout of DATE values (date without time)
+const DateLayout = "2006-01-02"
+
 var (
 	inputStringToType = map[string]DataType{
 		"string":    STRING,
 		"integer":   INT64,
 		"double":    FLOAT64,
 		"timestamp": TIMESTAMP,
+		"decimal":   DECIMAL,
+		"numeric":   DECIMAL,
+		"boolean":   BOOL,
+		"bool":      BOOL,
+		"date":      DATE,
+		"uuid":      UUID,
 	}
 	typeToInputString = map[DataType]string{
 		STRING:    "string",
 		INT64:     "integer",
 		FLOAT64:   "double",
 		TIMESTAMP: "timestamp",
+		DECIMAL:   "decimal",
+		BOOL:      "boolean",
+		DATE:      "date",
+		UUID:      "uuid",
 	}
 )
 
@@ -46,6 +64,14 @@ func (dt DataType) String() string {
 		return "FLOAT64"
 	case TIMESTAMP:
 		return "TIMESTAMP"
+	case DECIMAL:
+		return "DECIMAL"
+	case BOOL:
+		return "BOOL"
+	case DATE:
+		return "DATE"
+	case UUID:
+		return "UUID"
 	case UNKNOWN:
 		return "UNKNOWN"
 	}
@@ -73,6 +99,7 @@ func StringFromType(dataType DataType) (string, error) {
 //note: json.Unmarshal returns json.Number type that can be int or float
 //      we have to check does json number have dot in string representation
 // if have -> return float64 otherwise int64
+//numbers which can't be kept in float64 or int64 without loss are returned as Decimal
 func ReformatValue(v interface{}) interface{} {
 	jsonNumber, ok := v.(json.Number)
 	if !ok {
@@ -80,6 +107,12 @@ func ReformatValue(v interface{}) interface{} {
 	}
 
 	if strings.Contains(jsonNumber.String(), ".") {
+		if significantDigits(jsonNumber.String()) > maxFloat64Digits {
+			if decimal, err := NewDecimal(jsonNumber.String()); err == nil {
+				return interface{}(decimal)
+			}
+		}
+
 		floatValue, err := jsonNumber.Float64()
 		if err != nil {
 			logging.Errorf("Error parsing %s into float64: %v", jsonNumber.String(), err)
@@ -90,6 +123,9 @@ func ReformatValue(v interface{}) interface{} {
 
 	intValue, err := jsonNumber.Int64()
 	if err != nil {
+		if decimal, decimalErr := NewDecimal(jsonNumber.String()); decimalErr == nil {
+			return interface{}(decimal)
+		}
 		logging.Errorf("Error parsing %s into int64: %v", jsonNumber.String(), err)
 		return v
 	}
@@ -97,17 +133,57 @@ func ReformatValue(v interface{}) interface{} {
 }
 
 //TypeFromValue return DataType from v type
+//strings in UUID (8-4-4-4-12 hex digits) or date (2006-01-02) formats are UUID and DATE
 func TypeFromValue(v interface{}) (DataType, error) {
-	switch v.(type) {
+	switch value := v.(type) {
 	case string:
+		if IsUUID(value) {
+			return UUID, nil
+		}
+		if IsDate(value) {
+			return DATE, nil
+		}
 		return STRING, nil
+	case bool:
+		return BOOL, nil
 	case float32, float64:
 		return FLOAT64, nil
 	case int, int8, int16, int32, int64:
 		return INT64, nil
 	case time.Time:
 		return TIMESTAMP, nil
+	case Decimal:
+		return DECIMAL, nil
 	default:
 		return UNKNOWN, fmt.Errorf("Unknown DataType for value: %v type: %t", v, v)
 	}
 }
+
+//IsUUID return true if the string is a UUID in canonical format: 8-4-4-4-12 hex digits
+func IsUUID(value string) bool {
+	if len(value) != 36 {
+		return false
+	}
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if i == 8 || i == 13 || i == 18 || i == 23 {
+			if c != '-' {
+				return false
+			}
+			continue
+		}
+		if !(c >= '0' && c <= '9') && !(c >= 'a' && c <= 'f') && !(c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+//IsDate return true if the string is a valid date in DateLayout
+func IsDate(value string) bool {
+	if len(value) != len(DateLayout) || value[4] != '-' || value[7] != '-' {
+		return false
+	}
+	_, err := time.Parse(DateLayout, value)
+	return err == nil
+}
@@ -58,6 +58,30 @@ func TestTypeFromString(t *testing.T) {
 			TIMESTAMP,
 			"",
 		},
+		{
+			"Numeric ok",
+			"numeric",
+			DECIMAL,
+			"",
+		},
+		{
+			"Boolean ok",
+			"boolean",
+			BOOL,
+			"",
+		},
+		{
+			"Date ok",
+			"date",
+			DATE,
+			"",
+		},
+		{
+			"UUID ok",
+			"uuid",
+			UUID,
+			"",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -135,10 +159,10 @@ func TestTypeFromValue(t *testing.T) {
 			"Unknown DataType for value: <nil> type: %!t(<nil>)",
 		},
 		{
-			"Unknown boolean",
+			"Boolean ok",
 			true,
-			UNKNOWN,
-			"Unknown DataType for value: true type: true",
+			BOOL,
+			"",
 		},
 		{
 			"String ok",
@@ -146,6 +170,24 @@ func TestTypeFromValue(t *testing.T) {
 			STRING,
 			"",
 		},
+		{
+			"UUID string ok",
+			"57ac6e96-172a-4929-9fcf-6e6b1f16afdc",
+			UUID,
+			"",
+		},
+		{
+			"Date string ok",
+			"2020-08-02",
+			DATE,
+			"",
+		},
+		{
+			"Malformed date string -> string",
+			"2020-13-45",
+			STRING,
+			"",
+		},
 		{
 			"Float32 with zero -> float64",
 			float32(123.0),
@@ -250,6 +292,16 @@ func TestReformat(t *testing.T) {
 			json.Number("5"),
 			"int64",
 		},
+		{
+			"json float with more than 15 digits",
+			json.Number("12345678901234.567"),
+			"typing.Decimal",
+		},
+		{
+			"json int out of int64 range",
+			json.Number("92233720368547758070"),
+			"typing.Decimal",
+		},
 		{
 			"error wrong number",
 			json.Number("aa"),
@@ -0,0 +1,189 @@
+package typing
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultDecimalPrecision = 38
+	DefaultDecimalScale     = 18
+
+	//float64 keeps 15 significant decimal digits without loss
+	maxFloat64Digits = 15
+)
+
+var decimalTypeRegex = regexp.MustCompile(`^(decimal|numeric)(\((\d+)(,(\d+))?\))?$`)
+
+//DecimalSpec is a precision (total digits count) and a scale (digits after the point count) of DECIMAL column
+type DecimalSpec struct {
+	Precision int
+	Scale     int
+}
+
+//DefaultDecimalSpec is used for DECIMAL columns without explicit precision and scale
+var DefaultDecimalSpec = DecimalSpec{Precision: DefaultDecimalPrecision, Scale: DefaultDecimalScale}
+
+func (ds DecimalSpec) String() string {
+	return fmt.Sprintf("decimal(%d,%d)", ds.Precision, ds.Scale)
+}
+
+//ParseDecimalType return DecimalSpec and true from decimal, decimal(p), decimal(p,s) (or numeric) cast type
+//return false if t isn't a decimal type and error if precision or scale are malformed
+func ParseDecimalType(t string) (DecimalSpec, bool, error) {
+	parts := decimalTypeRegex.FindStringSubmatch(strings.ToLower(strings.ReplaceAll(t, " ", "")))
+	if parts == nil {
+		return DecimalSpec{}, false, nil
+	}
+
+	spec := DefaultDecimalSpec
+	if parts[3] != "" {
+		spec.Precision, _ = strconv.Atoi(parts[3])
+		spec.Scale = 0
+	}
+	if parts[5] != "" {
+		spec.Scale, _ = strconv.Atoi(parts[5])
+	}
+	if spec.Precision < 1 || spec.Precision > DefaultDecimalPrecision || spec.Scale > spec.Precision {
+		return DecimalSpec{}, true, fmt.Errorf("Malformed decimal type [%s]: precision must be in [1, %d] range and scale mustn't be greater than precision", t, DefaultDecimalPrecision)
+	}
+	return spec, true, nil
+}
+
+//Decimal is an exact decimal number (e.g. monetary value). It is kept as a decimal string and is serialized
+//into JSON and CSV as a number without float64 conversion
+type Decimal string
+
+//NewDecimal return Decimal from a number string (12.30, -5, 1.5e3) or error if it isn't a number
+func NewDecimal(value string) (Decimal, error) {
+	value = strings.TrimSpace(value)
+	rat, ok := new(big.Rat).SetString(value)
+	if !ok {
+		return "", fmt.Errorf("Value [%s] isn't a decimal number", value)
+	}
+
+	//keep fraction digits of the original value (12.30 isn't normalized into 12.3)
+	mantissa, exponent := value, 0
+	if index := strings.IndexAny(value, "eE"); index >= 0 {
+		mantissa = value[:index]
+		exponent, _ = strconv.Atoi(value[index+1:])
+	}
+	scale := 0
+	if index := strings.Index(mantissa, "."); index >= 0 {
+		scale = len(mantissa) - index - 1
+	}
+	scale -= exponent
+	if scale < 0 {
+		scale = 0
+	}
+
+	return Decimal(rat.FloatString(scale)), nil
+}
+
+//Round return Decimal rounded to the spec scale (halves are rounded away from zero)
+//or error if integer part digits count is greater than precision - scale
+func (d Decimal) Round(spec DecimalSpec) (Decimal, error) {
+	rat, ok := new(big.Rat).SetString(string(d))
+	if !ok {
+		return "", fmt.Errorf("Value [%s] isn't a decimal number", d)
+	}
+
+	rounded := rat.FloatString(spec.Scale)
+	integerPart := strings.TrimLeft(strings.SplitN(strings.TrimPrefix(rounded, "-"), ".", 2)[0], "0")
+	if len(integerPart) > spec.Precision-spec.Scale {
+		return "", fmt.Errorf("Value [%s] is out of %s range", d, spec.String())
+	}
+	return Decimal(rounded), nil
+}
+
+//Float64 return the nearest float64 value
+func (d Decimal) Float64() (float64, error) {
+	return strconv.ParseFloat(string(d), 64)
+}
+
+func (d Decimal) String() string {
+	return string(d)
+}
+
+//MarshalJSON return decimal as a JSON number
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	if d == "" {
+		return []byte("null"), nil
+	}
+	return []byte(d), nil
+}
+
+//Value return decimal string for SQL drivers
+func (d Decimal) Value() (driver.Value, error) {
+	return string(d), nil
+}
+
+//ConvertDecimal return Decimal rounded to the spec from json.Number, string, int, float or Decimal value
+func ConvertDecimal(v interface{}, spec DecimalSpec) (Decimal, error) {
+	var decimal Decimal
+	var err error
+	switch value := v.(type) {
+	case Decimal:
+		decimal = value
+	case json.Number:
+		decimal, err = NewDecimal(value.String())
+	case string:
+		decimal, err = NewDecimal(value)
+	default:
+		var converted interface{}
+		converted, err = numberToString(v)
+		if err == nil {
+			decimal, err = NewDecimal(converted.(string))
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return decimal.Round(spec)
+}
+
+//significantDigits return digits count of the number mantissa without leading zeros
+func significantDigits(number string) int {
+	if index := strings.IndexAny(number, "eE"); index >= 0 {
+		number = number[:index]
+	}
+	//digits without sign, point and leading zeros (without allocations: it is called for every float value)
+	digits := 0
+	for i := 0; i < len(number); i++ {
+		c := number[i]
+		if c < '0' || c > '9' || (c == '0' && digits == 0) {
+			continue
+		}
+		digits++
+	}
+	return digits
+}
+
+func numberToDecimal(v interface{}) (interface{}, error) {
+	switch value := v.(type) {
+	case Decimal:
+		return value, nil
+	case string:
+		return NewDecimal(value)
+	default:
+		converted, err := numberToString(v)
+		if err != nil {
+			return nil, err
+		}
+		return NewDecimal(converted.(string))
+	}
+}
+
+func decimalToFloat(v interface{}) (interface{}, error) {
+	decimal, ok := v.(Decimal)
+	if !ok {
+		return numberToFloat(v)
+	}
+	return decimal.Float64()
+}
@@ -0,0 +1,71 @@
+package typing
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestParseDecimalType(t *testing.T) {
+	tests := []struct {
+		name          string
+		input         string
+		expected      DecimalSpec
+		expectedOk    bool
+		expectedError string
+	}{
+		{"decimal without precision", "decimal", DefaultDecimalSpec, true, ""},
+		{"numeric with precision and scale", "NUMERIC(12, 2)", DecimalSpec{Precision: 12, Scale: 2}, true, ""},
+		{"decimal with precision", "decimal(10)", DecimalSpec{Precision: 10}, true, ""},
+		{"not decimal", "double", DecimalSpec{}, false, ""},
+		{"scale greater than precision", "decimal(2,4)", DecimalSpec{}, true, "Malformed decimal type [decimal(2,4)]: precision must be in [1, 38] range and scale mustn't be greater than precision"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, ok, err := ParseDecimalType(tt.input)
+			require.Equal(t, tt.expectedOk, ok)
+			if tt.expectedError != "" {
+				require.EqualError(t, err, tt.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, spec)
+		})
+	}
+}
+
+func TestConvertDecimal(t *testing.T) {
+	spec := DecimalSpec{Precision: 6, Scale: 2}
+	tests := []struct {
+		name          string
+		input         interface{}
+		expected      Decimal
+		expectedError string
+	}{
+		{"json number", json.Number("1234.5"), "1234.50", ""},
+		{"json number rounding", json.Number("0.125"), "0.13", ""},
+		{"negative string", "-12.3", "-12.30", ""},
+		{"exponent", json.Number("1.5e2"), "150.00", ""},
+		{"int", int64(42), "42.00", ""},
+		{"float", 19.99, "19.99", ""},
+		{"out of range", json.Number("12345.6"), "", "Value [12345.6] is out of decimal(6,2) range"},
+		{"not a number", "abc", "", "Value [abc] isn't a decimal number"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := ConvertDecimal(tt.input, spec)
+			if tt.expectedError != "" {
+				require.EqualError(t, err, tt.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestDecimalMarshalJSON(t *testing.T) {
+	b, err := json.Marshal(map[string]interface{}{"price": Decimal("12345678901234567890.10")})
+	require.NoError(t, err)
+	require.Equal(t, `{"price":12345678901234567890.10}`, string(b))
+}
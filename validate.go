@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/jitsucom/eventnative/appconfig"
+	"github.com/jitsucom/eventnative/caching"
+	"github.com/jitsucom/eventnative/compression"
+	"github.com/jitsucom/eventnative/destinations"
+	"github.com/jitsucom/eventnative/encryption"
+	"github.com/jitsucom/eventnative/eventstore"
+	"github.com/jitsucom/eventnative/meta"
+	"github.com/jitsucom/eventnative/parsers"
+	"github.com/jitsucom/eventnative/secrets"
+	"github.com/jitsucom/eventnative/storages"
+	"github.com/jitsucom/eventnative/synchronization"
+	"github.com/jitsucom/eventnative/timestamp"
+	"github.com/jitsucom/eventnative/workspaces"
+	"github.com/spf13/viper"
+	"io"
+	"sort"
+)
+
+//configProblem is a configuration problem of the section (e.g. server.timestamps or destinations.<id>)
+type configProblem struct {
+	section string
+	err     error
+}
+
+func (cp configProblem) String() string {
+	return fmt.Sprintf("[%s] %v", cp.section, cp.err)
+}
+
+//validateConfig load the full configuration, compile destinations (mappings, enrichment rules, table templates, filters, etc.)
+//and test-connect to meta storage and destinations. Print every found problem and return process exit code:
+//0 - the configuration is valid, 1 - there are problems
+func validateConfig(output io.Writer) int {
+	var problems []configProblem
+	add := func(section string, err error) {
+		if err != nil {
+			problems = append(problems, configProblem{section: section, err: err})
+		}
+	}
+
+	add("server", appconfig.Init())
+	if appconfig.Instance != nil {
+		defer appconfig.Instance.Close()
+	}
+
+	add("server.event_store", viper.UnmarshalKey("server.event_store", &eventstore.Config{}))
+
+	timestampsConfig := &timestamp.Config{}
+	if err := viper.UnmarshalKey("server.timestamps", timestampsConfig); err != nil {
+		add("server.timestamps", err)
+	} else {
+		add("server.timestamps", timestamp.Init(timestampsConfig))
+	}
+
+	encryptionConfig := encryption.Config{}
+	if err := viper.UnmarshalKey("server.encryption", &encryptionConfig); err != nil {
+		add("server.encryption", err)
+	} else {
+		add("server.encryption", encryption.Init(encryptionConfig))
+	}
+
+	secretsConfig := &secrets.Config{}
+	if err := viper.UnmarshalKey("server.secrets", secretsConfig); err != nil {
+		add("server.secrets", err)
+	} else {
+		secrets.Init(secretsConfig)
+	}
+
+	add("server.json_decoder", parsers.SetDecoder(viper.GetString("server.json_decoder")))
+	add("log.compression", compression.Validate(viper.GetString("log.compression")))
+
+	metaStorage, err := meta.NewStorage(metaStorageConfig())
+	if err != nil {
+		add("meta.storage", err)
+	} else {
+		cacheStorage := viper.GetString("server.cache.events.storage")
+		switch cacheStorage {
+		case "", caching.MemoryStorage:
+		case caching.RedisStorage:
+			if metaStorage.Type() != meta.RedisType {
+				add("server.cache.events.storage", fmt.Errorf("redis requires meta.storage.redis configuration"))
+			}
+		default:
+			add("server.cache.events.storage", fmt.Errorf("Unknown storage: %s. Available: [%s, %s]", cacheStorage, caching.RedisStorage, caching.MemoryStorage))
+		}
+		metaStorage.Close()
+	}
+
+	var workspaceDestinations map[string]storages.DestinationConfig
+	workspacesService, err := workspaces.NewService(viper.Sub("workspaces"), viper.GetString("server.admin_token"))
+	if err != nil {
+		add("workspaces", err)
+	} else {
+		workspaceDestinations = workspacesService.Destinations()
+	}
+
+	serverName := viper.GetString("server.name")
+	monitorKeeper := synchronization.NewInMemoryService([]string{serverName})
+	destinationsProblems := validateDestinations(context.Background(), monitorKeeper, workspaceDestinations, true)
+	problems = append(problems, destinationsProblems...)
+
+	if len(problems) == 0 {
+		fmt.Fprintln(output, "Configuration is valid")
+		return 0
+	}
+	for _, problem := range problems {
+		fmt.Fprintln(output, problem)
+	}
+	fmt.Fprintf(output, "Configuration has %d problem(s)\n", len(problems))
+	return 1
+}
+
+//validateDestinations compile configured and workspaces destinations and test-connect to them if connect is true.
+//Return problems of all destinations
+func validateDestinations(ctx context.Context, monitorKeeper storages.MonitorKeeper, workspaceDestinations map[string]storages.DestinationConfig, connect bool) []configProblem {
+	destinationsViper, destinationsStr := destinationsConfig()
+	configs, err := destinations.LoadConfig(destinationsViper, destinationsStr)
+	if err != nil {
+		return []configProblem{{section: destinationsKey, err: err}}
+	}
+	for name, config := range workspaceDestinations {
+		configs[name] = config
+	}
+
+	var names []string
+	for name := range configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var problems []configProblem
+	for _, name := range names {
+		section := destinationsKey + "." + name
+		destination, err := destinations.ResolveSecrets(configs[name])
+		if err != nil {
+			problems = append(problems, configProblem{section: section, err: err})
+			continue
+		}
+		for _, err := range storages.Validate(ctx, name, destination, monitorKeeper, connect) {
+			problems = append(problems, configProblem{section: section, err: err})
+		}
+	}
+	return problems
+}
@@ -0,0 +1,549 @@
+package validation
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	knownTypes = map[string]bool{"object": true, "array": true, "string": true, "number": true, "integer": true, "boolean": true, "null": true}
+
+	emailRegex = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+	uuidRegex  = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+)
+
+//Schema is a compiled JSON Schema (draft 7 subset): type, enum, const, required, properties, additionalProperties,
+//items, minItems, maxItems, minLength, maxLength, pattern, format (date-time, date, email, uuid, ipv4),
+//minimum, maximum, exclusiveMinimum, exclusiveMaximum, allOf, anyOf. Other keywords are ignored, $ref isn't supported
+type Schema struct {
+	//schema location (JSON pointer) is used as rule name in violations and metrics
+	location string
+	//false boolean schema: nothing is valid
+	never bool
+
+	types    []string
+	enum     []interface{}
+	constant interface{}
+	hasConst bool
+
+	required             []string
+	properties           map[string]*Schema
+	additionalProperties *Schema
+	noAdditional         bool
+
+	items    *Schema
+	minItems *int
+	maxItems *int
+
+	minLength *int
+	maxLength *int
+	pattern   *regexp.Regexp
+	format    string
+
+	minimum          *float64
+	maximum          *float64
+	exclusiveMinimum *float64
+	exclusiveMaximum *float64
+
+	allOf []*Schema
+	anyOf []*Schema
+}
+
+//Violation is a failed schema rule
+type Violation struct {
+	//JSON pointer of the event field
+	Field string `json:"field"`
+	//JSON pointer of the schema rule
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func (v *Violation) String() string {
+	field := v.Field
+	if field == "" {
+		field = "/"
+	}
+	return fmt.Sprintf("%s: %s", field, v.Message)
+}
+
+//ParseSchema return compiled Schema from JSON bytes or error if schema is malformed
+func ParseSchema(b []byte) (*Schema, error) {
+	var raw interface{}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("Error unmarshalling JSON Schema: %v", err)
+	}
+
+	return compile(raw, "")
+}
+
+func compile(raw interface{}, location string) (*Schema, error) {
+	schema := &Schema{location: location}
+
+	//boolean schemas: true - everything is valid, false - nothing is valid
+	if b, ok := raw.(bool); ok {
+		schema.never = !b
+		return schema, nil
+	}
+
+	obj, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: schema must be an object or boolean", locationOrRoot(location))
+	}
+
+	if _, ok := obj["$ref"]; ok {
+		return nil, fmt.Errorf("%s: $ref isn't supported", locationOrRoot(location))
+	}
+
+	var err error
+	if t, ok := obj["type"]; ok {
+		if schema.types, err = parseTypes(t); err != nil {
+			return nil, fmt.Errorf("%s/type: %v", location, err)
+		}
+	}
+	if e, ok := obj["enum"]; ok {
+		values, ok := e.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s/enum: must be an array", location)
+		}
+		schema.enum = values
+	}
+	if c, ok := obj["const"]; ok {
+		schema.constant = c
+		schema.hasConst = true
+	}
+
+	if r, ok := obj["required"]; ok {
+		if schema.required, err = parseStrings(r); err != nil {
+			return nil, fmt.Errorf("%s/required: %v", location, err)
+		}
+	}
+	if p, ok := obj["properties"]; ok {
+		properties, ok := p.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s/properties: must be an object", location)
+		}
+		schema.properties = map[string]*Schema{}
+		for name, propertyRaw := range properties {
+			if schema.properties[name], err = compile(propertyRaw, location+"/properties/"+escape(name)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if a, ok := obj["additionalProperties"]; ok {
+		if b, ok := a.(bool); ok && !b {
+			schema.noAdditional = true
+		} else if schema.additionalProperties, err = compile(a, location+"/additionalProperties"); err != nil {
+			return nil, err
+		}
+	}
+
+	if i, ok := obj["items"]; ok {
+		if schema.items, err = compile(i, location+"/items"); err != nil {
+			return nil, err
+		}
+	}
+	if schema.minItems, err = parseInt(obj, "minItems", location); err != nil {
+		return nil, err
+	}
+	if schema.maxItems, err = parseInt(obj, "maxItems", location); err != nil {
+		return nil, err
+	}
+
+	if schema.minLength, err = parseInt(obj, "minLength", location); err != nil {
+		return nil, err
+	}
+	if schema.maxLength, err = parseInt(obj, "maxLength", location); err != nil {
+		return nil, err
+	}
+	if p, ok := obj["pattern"]; ok {
+		expression, ok := p.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s/pattern: must be a string", location)
+		}
+		if schema.pattern, err = regexp.Compile(expression); err != nil {
+			return nil, fmt.Errorf("%s/pattern: %v", location, err)
+		}
+	}
+	if f, ok := obj["format"]; ok {
+		if schema.format, ok = f.(string); !ok {
+			return nil, fmt.Errorf("%s/format: must be a string", location)
+		}
+	}
+
+	if schema.minimum, err = parseNumber(obj, "minimum", location); err != nil {
+		return nil, err
+	}
+	if schema.maximum, err = parseNumber(obj, "maximum", location); err != nil {
+		return nil, err
+	}
+	if schema.exclusiveMinimum, err = parseNumber(obj, "exclusiveMinimum", location); err != nil {
+		return nil, err
+	}
+	if schema.exclusiveMaximum, err = parseNumber(obj, "exclusiveMaximum", location); err != nil {
+		return nil, err
+	}
+
+	if schema.allOf, err = parseSchemas(obj, "allOf", location); err != nil {
+		return nil, err
+	}
+	if schema.anyOf, err = parseSchemas(obj, "anyOf", location); err != nil {
+		return nil, err
+	}
+
+	return schema, nil
+}
+
+//Validate return all violations of the value. Empty result means the value is valid
+func (s *Schema) Validate(value interface{}) []*Violation {
+	return s.validate(normalize(value), "")
+}
+
+func (s *Schema) validate(value interface{}, field string) []*Violation {
+	if s.never {
+		return []*Violation{s.violation(field, "", "value isn't allowed")}
+	}
+
+	if len(s.types) > 0 && !matchTypes(s.types, value) {
+		return []*Violation{s.violation(field, "/type", fmt.Sprintf("expected %s but got %s", strings.Join(s.types, " or "), typeOf(value)))}
+	}
+
+	var violations []*Violation
+	if s.enum != nil && !contains(s.enum, value) {
+		violations = append(violations, s.violation(field, "/enum", fmt.Sprintf("value must be one of %v", s.enum)))
+	}
+	if s.hasConst && !reflect.DeepEqual(s.constant, value) {
+		violations = append(violations, s.violation(field, "/const", fmt.Sprintf("value must be %v", s.constant)))
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		violations = append(violations, s.validateObject(v, field)...)
+	case []interface{}:
+		violations = append(violations, s.validateArray(v, field)...)
+	case string:
+		violations = append(violations, s.validateString(v, field)...)
+	case float64:
+		violations = append(violations, s.validateNumber(v, field)...)
+	}
+
+	for _, subSchema := range s.allOf {
+		violations = append(violations, subSchema.validate(value, field)...)
+	}
+	if len(s.anyOf) > 0 {
+		matched := false
+		for _, subSchema := range s.anyOf {
+			if len(subSchema.validate(value, field)) == 0 {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			violations = append(violations, s.violation(field, "/anyOf", "value doesn't match any of schemas"))
+		}
+	}
+
+	return violations
+}
+
+func (s *Schema) validateObject(obj map[string]interface{}, field string) []*Violation {
+	var violations []*Violation
+	for _, name := range s.required {
+		if _, ok := obj[name]; !ok {
+			violations = append(violations, s.violation(field, "/required", fmt.Sprintf("required field %s is missing", name)))
+		}
+	}
+
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fieldValue := obj[name]
+		fieldPath := field + "/" + escape(name)
+		if propertySchema, ok := s.properties[name]; ok {
+			violations = append(violations, propertySchema.validate(fieldValue, fieldPath)...)
+		} else if s.noAdditional {
+			violations = append(violations, s.violation(fieldPath, "/additionalProperties", "additional field isn't allowed"))
+		} else if s.additionalProperties != nil {
+			violations = append(violations, s.additionalProperties.validate(fieldValue, fieldPath)...)
+		}
+	}
+
+	return violations
+}
+
+func (s *Schema) validateArray(array []interface{}, field string) []*Violation {
+	var violations []*Violation
+	if s.minItems != nil && len(array) < *s.minItems {
+		violations = append(violations, s.violation(field, "/minItems", fmt.Sprintf("array must have at least %d items", *s.minItems)))
+	}
+	if s.maxItems != nil && len(array) > *s.maxItems {
+		violations = append(violations, s.violation(field, "/maxItems", fmt.Sprintf("array must have at most %d items", *s.maxItems)))
+	}
+	if s.items != nil {
+		for i, item := range array {
+			violations = append(violations, s.items.validate(item, fmt.Sprintf("%s/%d", field, i))...)
+		}
+	}
+
+	return violations
+}
+
+func (s *Schema) validateString(str string, field string) []*Violation {
+	var violations []*Violation
+	length := utf8.RuneCountInString(str)
+	if s.minLength != nil && length < *s.minLength {
+		violations = append(violations, s.violation(field, "/minLength", fmt.Sprintf("string must have at least %d characters", *s.minLength)))
+	}
+	if s.maxLength != nil && length > *s.maxLength {
+		violations = append(violations, s.violation(field, "/maxLength", fmt.Sprintf("string must have at most %d characters", *s.maxLength)))
+	}
+	if s.pattern != nil && !s.pattern.MatchString(str) {
+		violations = append(violations, s.violation(field, "/pattern", fmt.Sprintf("string doesn't match pattern %s", s.pattern.String())))
+	}
+	if s.format != "" && !matchFormat(s.format, str) {
+		violations = append(violations, s.violation(field, "/format", fmt.Sprintf("string isn't valid %s", s.format)))
+	}
+
+	return violations
+}
+
+func (s *Schema) validateNumber(number float64, field string) []*Violation {
+	var violations []*Violation
+	if s.minimum != nil && number < *s.minimum {
+		violations = append(violations, s.violation(field, "/minimum", fmt.Sprintf("value must be >= %v", *s.minimum)))
+	}
+	if s.maximum != nil && number > *s.maximum {
+		violations = append(violations, s.violation(field, "/maximum", fmt.Sprintf("value must be <= %v", *s.maximum)))
+	}
+	if s.exclusiveMinimum != nil && number <= *s.exclusiveMinimum {
+		violations = append(violations, s.violation(field, "/exclusiveMinimum", fmt.Sprintf("value must be > %v", *s.exclusiveMinimum)))
+	}
+	if s.exclusiveMaximum != nil && number >= *s.exclusiveMaximum {
+		violations = append(violations, s.violation(field, "/exclusiveMaximum", fmt.Sprintf("value must be < %v", *s.exclusiveMaximum)))
+	}
+
+	return violations
+}
+
+func (s *Schema) violation(field, keyword, message string) *Violation {
+	return &Violation{Field: field, Rule: locationOrRoot(s.location + keyword), Message: message}
+}
+
+func matchTypes(types []string, value interface{}) bool {
+	actual := typeOf(value)
+	for _, t := range types {
+		if t == actual || t == "number" && actual == "integer" {
+			return true
+		}
+	}
+	return false
+}
+
+//typeOf return JSON Schema type of the normalized value
+func typeOf(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if v == math.Trunc(v) && !math.IsInf(v, 0) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func matchFormat(format, str string) bool {
+	switch format {
+	case "date-time":
+		_, err := time.Parse(time.RFC3339Nano, str)
+		return err == nil
+	case "date":
+		_, err := time.Parse("2006-01-02", str)
+		return err == nil
+	case "email":
+		return emailRegex.MatchString(str)
+	case "uuid":
+		return uuidRegex.MatchString(str)
+	case "ipv4":
+		ip := net.ParseIP(str)
+		return ip != nil && ip.To4() != nil && !strings.Contains(str, ":")
+	default:
+		//unknown formats are annotations only
+		return true
+	}
+}
+
+//normalize convert all numbers into float64 and typed slices/maps into generic ones
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return v.String()
+		}
+		return f
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case float32:
+		return float64(v)
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			result[key] = normalize(item)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = normalize(item)
+		}
+		return result
+	case []map[string]interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = normalize(item)
+		}
+		return result
+	case []string:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = item
+		}
+		return result
+	default:
+		return value
+	}
+}
+
+func contains(values []interface{}, value interface{}) bool {
+	for _, v := range values {
+		if reflect.DeepEqual(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseTypes(raw interface{}) ([]string, error) {
+	var types []string
+	switch t := raw.(type) {
+	case string:
+		types = []string{t}
+	case []interface{}:
+		var err error
+		if types, err = parseStrings(t); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("must be a string or an array of strings")
+	}
+
+	for _, t := range types {
+		if !knownTypes[t] {
+			return nil, fmt.Errorf("unknown type %s", t)
+		}
+	}
+	return types, nil
+}
+
+func parseStrings(raw interface{}) ([]string, error) {
+	array, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("must be an array of strings")
+	}
+
+	result := make([]string, 0, len(array))
+	for _, item := range array {
+		str, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("must be an array of strings")
+		}
+		result = append(result, str)
+	}
+	return result, nil
+}
+
+func parseInt(obj map[string]interface{}, keyword, location string) (*int, error) {
+	number, err := parseNumber(obj, keyword, location)
+	if err != nil || number == nil {
+		return nil, err
+	}
+
+	if *number < 0 || *number != math.Trunc(*number) {
+		return nil, fmt.Errorf("%s/%s: must be a non-negative integer", location, keyword)
+	}
+	result := int(*number)
+	return &result, nil
+}
+
+func parseNumber(obj map[string]interface{}, keyword, location string) (*float64, error) {
+	raw, ok := obj[keyword]
+	if !ok {
+		return nil, nil
+	}
+
+	number, ok := raw.(float64)
+	if !ok {
+		return nil, fmt.Errorf("%s/%s: must be a number", location, keyword)
+	}
+	return &number, nil
+}
+
+func parseSchemas(obj map[string]interface{}, keyword, location string) ([]*Schema, error) {
+	raw, ok := obj[keyword]
+	if !ok {
+		return nil, nil
+	}
+
+	array, ok := raw.([]interface{})
+	if !ok || len(array) == 0 {
+		return nil, fmt.Errorf("%s/%s: must be a non-empty array", location, keyword)
+	}
+
+	schemas := make([]*Schema, 0, len(array))
+	for i, item := range array {
+		subSchema, err := compile(item, fmt.Sprintf("%s/%s/%d", location, keyword, i))
+		if err != nil {
+			return nil, err
+		}
+		schemas = append(schemas, subSchema)
+	}
+	return schemas, nil
+}
+
+//escape return JSON pointer escaped token
+func escape(token string) string {
+	return strings.Replace(strings.Replace(token, "~", "~0", -1), "/", "~1", -1)
+}
+
+func locationOrRoot(location string) string {
+	if location == "" {
+		return "/"
+	}
+	return location
+}
@@ -0,0 +1,104 @@
+package validation
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+const testSchema = `{
+  "type": "object",
+  "required": ["event_type", "user"],
+  "properties": {
+    "event_type": {"type": "string", "enum": ["signup", "login"]},
+    "user": {
+      "type": "object",
+      "required": ["id"],
+      "additionalProperties": false,
+      "properties": {
+        "id": {"type": "string", "minLength": 3, "pattern": "^u_"},
+        "email": {"type": "string", "format": "email"},
+        "age": {"type": "integer", "minimum": 0, "exclusiveMaximum": 150}
+      }
+    },
+    "tags": {"type": "array", "maxItems": 2, "items": {"type": "string"}},
+    "amount": {"anyOf": [{"type": "number", "minimum": 1}, {"type": "null"}]},
+    "source": {"const": "web"}
+  }
+}`
+
+func TestSchemaValidate(t *testing.T) {
+	schema, err := ParseSchema([]byte(testSchema))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		event    map[string]interface{}
+		expected []*Violation
+	}{
+		{
+			"valid",
+			map[string]interface{}{"event_type": "signup", "user": map[string]interface{}{"id": "u_123", "email": "a@b.com", "age": 30},
+				"tags": []interface{}{"a", "b"}, "amount": nil, "source": "web", "extra": 1},
+			nil,
+		},
+		{
+			"json numbers",
+			map[string]interface{}{"event_type": "login", "user": map[string]interface{}{"id": "u_123", "age": json.Number("30")}, "amount": json.Number("1.5")},
+			nil,
+		},
+		{
+			"missing required",
+			map[string]interface{}{"event_type": "signup"},
+			[]*Violation{{Field: "", Rule: "/required", Message: "required field user is missing"}},
+		},
+		{
+			"violations",
+			map[string]interface{}{"event_type": "purchase", "user": map[string]interface{}{"id": "x1", "email": "abc", "age": 30.5, "name": "john"},
+				"tags": []interface{}{"a", 2, "c"}, "amount": 0, "source": "app"},
+			[]*Violation{
+				{Field: "/amount", Rule: "/properties/amount/anyOf", Message: "value doesn't match any of schemas"},
+				{Field: "/event_type", Rule: "/properties/event_type/enum", Message: "value must be one of [signup login]"},
+				{Field: "/source", Rule: "/properties/source/const", Message: "value must be web"},
+				{Field: "/tags", Rule: "/properties/tags/maxItems", Message: "array must have at most 2 items"},
+				{Field: "/tags/1", Rule: "/properties/tags/items/type", Message: "expected string but got integer"},
+				{Field: "/user/age", Rule: "/properties/user/properties/age/type", Message: "expected integer but got number"},
+				{Field: "/user/email", Rule: "/properties/user/properties/email/format", Message: "string isn't valid email"},
+				{Field: "/user/id", Rule: "/properties/user/properties/id/minLength", Message: "string must have at least 3 characters"},
+				{Field: "/user/id", Rule: "/properties/user/properties/id/pattern", Message: "string doesn't match pattern ^u_"},
+				{Field: "/user/name", Rule: "/properties/user/additionalProperties", Message: "additional field isn't allowed"},
+			},
+		},
+		{
+			"wrong type",
+			map[string]interface{}{"event_type": "signup", "user": "u_123"},
+			[]*Violation{{Field: "/user", Rule: "/properties/user/type", Message: "expected object but got string"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, schema.Validate(tt.event))
+		})
+	}
+}
+
+func TestParseSchemaErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		schema      string
+		expectedErr string
+	}{
+		{"malformed", `{"type":`, "Error unmarshalling JSON Schema: unexpected end of JSON input"},
+		{"not object", `[]`, "/: schema must be an object or boolean"},
+		{"ref", `{"properties": {"a": {"$ref": "#/definitions/a"}}}`, "/properties/a: $ref isn't supported"},
+		{"unknown type", `{"type": "date"}`, "/type: unknown type date"},
+		{"negative length", `{"minLength": -1}`, "/minLength: must be a non-negative integer"},
+		{"malformed pattern", `{"properties": {"a": {"pattern": "("}}}`, "/properties/a/pattern: error parsing regexp: missing closing ): `(`"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseSchema([]byte(tt.schema))
+			require.EqualError(t, err, tt.expectedErr)
+		})
+	}
+}
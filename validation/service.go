@@ -0,0 +1,198 @@
+package validation
+
+import (
+	"fmt"
+	"github.com/jitsucom/eventnative/metrics"
+	"github.com/spf13/viper"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+const (
+	//RejectAction: events are rejected with HTTP 400 response and violations details
+	RejectAction = "reject"
+	//FallbackAction: events are accepted and written into the token destinations fallback (with violations as error)
+	FallbackAction = "fallback"
+
+	eventTypeKey = "event_type"
+)
+
+//SchemaConfig is a JSON Schema attached to tokens and (or) event types
+type SchemaConfig struct {
+	//token ids or client/server secrets. Empty means all tokens
+	Tokens []string `mapstructure:"tokens" json:"tokens,omitempty" yaml:"tokens,omitempty"`
+	//Empty means all event types
+	EventTypes []string `mapstructure:"event_types" json:"event_types,omitempty" yaml:"event_types,omitempty"`
+	//reject (default) or fallback
+	OnFailure string `mapstructure:"on_failure" json:"on_failure,omitempty" yaml:"on_failure,omitempty"`
+	//JSON string or file path. YAML objects aren't supported because config keys are lowercased
+	Schema string `mapstructure:"schema" json:"schema" yaml:"schema"`
+}
+
+//Failure is a failed schema with violations
+type Failure struct {
+	Schema     string       `json:"schema"`
+	Action     string       `json:"action"`
+	Violations []*Violation `json:"violations"`
+}
+
+//Error is returned if the event doesn't match at least one schema
+type Error struct {
+	Action   string
+	Failures []*Failure
+}
+
+func (e *Error) Error() string {
+	var messages []string
+	for _, failure := range e.Failures {
+		violations := make([]string, 0, len(failure.Violations))
+		for _, violation := range failure.Violations {
+			violations = append(violations, violation.String())
+		}
+		messages = append(messages, fmt.Sprintf("schema [%s]: %s", failure.Schema, strings.Join(violations, "; ")))
+	}
+	return "Event doesn't match " + strings.Join(messages, ", ")
+}
+
+type rule struct {
+	name       string
+	tokens     map[string]bool
+	eventTypes map[string]bool
+	action     string
+	schema     *Schema
+}
+
+//Service validates events against JSON Schemas which match the event token and event_type
+type Service struct {
+	rules []*rule
+}
+
+func NewTestService() *Service {
+	return &Service{}
+}
+
+//NewService return Service with schemas from the viper config (might be nil)
+func NewService(schemasViper *viper.Viper) (*Service, error) {
+	configs := map[string]*SchemaConfig{}
+	if schemasViper != nil {
+		if err := schemasViper.Unmarshal(&configs); err != nil {
+			return nil, fmt.Errorf("Error unmarshalling validation schemas. Schema must be JSON string or file path: %v", err)
+		}
+	}
+
+	return NewServiceFromConfigs(configs)
+}
+
+//NewServiceFromConfigs return Service with compiled schemas or error if configs are invalid
+func NewServiceFromConfigs(configs map[string]*SchemaConfig) (*Service, error) {
+	var names []string
+	for name := range configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	service := &Service{}
+	for _, name := range names {
+		config := configs[name]
+		r := &rule{name: name, tokens: toSet(config.Tokens), eventTypes: toSet(config.EventTypes), action: config.OnFailure}
+		if r.action == "" {
+			r.action = RejectAction
+		}
+		if r.action != RejectAction && r.action != FallbackAction {
+			return nil, fmt.Errorf("Validation schema [%s] has unknown on_failure: %s. Available: [%s, %s]", name, r.action, RejectAction, FallbackAction)
+		}
+
+		payload, err := loadSchema(config.Schema)
+		if err != nil {
+			return nil, fmt.Errorf("Error loading validation schema [%s]: %v", name, err)
+		}
+		if r.schema, err = ParseSchema(payload); err != nil {
+			return nil, fmt.Errorf("Error parsing validation schema [%s]: %v", name, err)
+		}
+
+		service.rules = append(service.rules, r)
+	}
+
+	return service, nil
+}
+
+//IsEmpty return true if schemas aren't configured
+func (s *Service) IsEmpty() bool {
+	return len(s.rules) == 0
+}
+
+//Schemas return sorted names of the loaded schemas
+func (s *Service) Schemas() []string {
+	names := make([]string, 0, len(s.rules))
+	for _, r := range s.rules {
+		names = append(names, r.name)
+	}
+	return names
+}
+
+//Validate return nil if the event matches all schemas of the token (token secret or id) and event_type
+//otherwise return *Error with the strictest action of failed schemas (reject > fallback)
+func (s *Service) Validate(token, tokenId string, event map[string]interface{}) *Error {
+	if s == nil || len(s.rules) == 0 {
+		return nil
+	}
+
+	eventType, _ := event[eventTypeKey].(string)
+	var result *Error
+	for _, r := range s.rules {
+		if len(r.tokens) > 0 && !r.tokens[token] && !r.tokens[tokenId] {
+			continue
+		}
+		if len(r.eventTypes) > 0 && !r.eventTypes[eventType] {
+			continue
+		}
+
+		violations := r.schema.Validate(event)
+		if len(violations) == 0 {
+			metrics.ValidatedEvent(r.name, "passed")
+			continue
+		}
+
+		metrics.ValidatedEvent(r.name, r.action)
+		for _, violation := range violations {
+			metrics.ValidationViolation(r.name, violation.Rule)
+		}
+
+		if result == nil {
+			result = &Error{Action: FallbackAction}
+		}
+		if r.action == RejectAction {
+			result.Action = RejectAction
+		}
+		result.Failures = append(result.Failures, &Failure{Schema: r.name, Action: r.action, Violations: violations})
+	}
+
+	return result
+}
+
+//loadSchema return schema bytes from JSON string or file (file:// prefix is optional)
+func loadSchema(schema string) ([]byte, error) {
+	schema = strings.TrimSpace(schema)
+	if schema == "" {
+		return nil, fmt.Errorf("schema is required")
+	}
+
+	if strings.HasPrefix(schema, "{") {
+		return []byte(schema), nil
+	}
+
+	payload, err := ioutil.ReadFile(strings.TrimPrefix(schema, "file://"))
+	if err != nil {
+		return nil, fmt.Errorf("Error reading schema file: %v", err)
+	}
+	return payload, nil
+}
+
+func toSet(values []string) map[string]bool {
+	result := map[string]bool{}
+	for _, value := range values {
+		result[strings.TrimSpace(value)] = true
+	}
+	return result
+}
@@ -0,0 +1,38 @@
+package validation
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestServiceValidate(t *testing.T) {
+	service, err := NewServiceFromConfigs(map[string]*SchemaConfig{
+		"all":    {Schema: `{"required": ["event_type"]}`, OnFailure: FallbackAction},
+		"signup": {Schema: `{"required": ["user_id"]}`, EventTypes: []string{"signup"}},
+		"token1": {Schema: `{"properties": {"amount": {"type": "number"}}}`, Tokens: []string{"token1_secret"}, OnFailure: FallbackAction},
+	})
+	require.NoError(t, err)
+
+	require.Nil(t, service.Validate("token2_secret", "token2", map[string]interface{}{"event_type": "pageview", "amount": "1"}))
+
+	result := service.Validate("token1_secret", "token1", map[string]interface{}{"event_type": "pageview", "amount": "1"})
+	require.NotNil(t, result)
+	require.Equal(t, FallbackAction, result.Action)
+	require.Equal(t, []*Failure{{Schema: "token1", Action: FallbackAction,
+		Violations: []*Violation{{Field: "/amount", Rule: "/properties/amount/type", Message: "expected number but got string"}}}}, result.Failures)
+
+	result = service.Validate("token2_secret", "token2", map[string]interface{}{"amount": 1})
+	require.NotNil(t, result)
+	require.Equal(t, FallbackAction, result.Action)
+	require.Equal(t, "Event doesn't match schema [all]: /: required field event_type is missing", result.Error())
+
+	result = service.Validate("token1_secret", "token1", map[string]interface{}{"event_type": "signup", "amount": true})
+	require.NotNil(t, result)
+	require.Equal(t, RejectAction, result.Action)
+	require.Len(t, result.Failures, 2)
+	require.Equal(t, "signup", result.Failures[0].Schema)
+	require.Equal(t, "token1", result.Failures[1].Schema)
+
+	_, err = NewServiceFromConfigs(map[string]*SchemaConfig{"s": {Schema: `{}`, OnFailure: "drop"}})
+	require.EqualError(t, err, "Validation schema [s] has unknown on_failure: drop. Available: [reject, fallback]")
+}
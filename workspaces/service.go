@@ -0,0 +1,229 @@
+package workspaces
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/authorization"
+	"github.com/jitsucom/eventnative/storages"
+	"github.com/spf13/viper"
+	"sort"
+	"strings"
+)
+
+//Delimiter separates workspace id and token id (destination name) in instance-wide ids. It is also used as
+//project_id separator in metrics labels
+const Delimiter = "."
+
+//tenantDelimiter separates destination name and token id in per token destination instance name (see destinations.resolveTenants)
+const tenantDelimiter = "."
+
+//Instance is a singleton of workspaces service which is used in ownership checks. It doesn't have workspaces until it is set on startup
+var Instance = NewTestService()
+
+//Workspace is a logical project which groups tokens and destinations (with their mappings)
+//tokens ids and destinations names are local: they are prefixed with $workspaceId. on resolving
+type Workspace struct {
+	AdminToken   string                                `mapstructure:"admin_token"`
+	Tokens       []authorization.Token                 `mapstructure:"tokens"`
+	Destinations map[string]storages.DestinationConfig `mapstructure:"destinations"`
+}
+
+//Info is an admin API representation of the workspace
+type Info struct {
+	Id           string   `json:"id"`
+	TokenIds     []string `json:"token_ids"`
+	Destinations []string `json:"destinations"`
+}
+
+//Service keeps workspaces with resolved tokens and destinations
+type Service struct {
+	ids                []string
+	workspaces         map[string]*Workspace
+	idsByAdminToken    map[string]string
+	tokens             []authorization.Token
+	destinationConfigs map[string]storages.DestinationConfig
+	//workspace id by instance-wide token id, destination name and per token destination instance name
+	owners map[string]string
+}
+
+func NewTestService() *Service {
+	return &Service{workspaces: map[string]*Workspace{}, idsByAdminToken: map[string]string{}, destinationConfigs: map[string]storages.DestinationConfig{}, owners: map[string]string{}}
+}
+
+//NewService return Service with workspaces from the viper config (might be nil)
+func NewService(workspacesViper *viper.Viper, serverAdminToken string) (*Service, error) {
+	configs := map[string]*Workspace{}
+	if workspacesViper != nil {
+		if err := workspacesViper.Unmarshal(&configs); err != nil {
+			return nil, fmt.Errorf("Error unmarshalling workspaces: %v", err)
+		}
+	}
+
+	return NewServiceFromConfigs(configs, serverAdminToken)
+}
+
+//NewServiceFromConfigs return Service with resolved workspaces or error if configs are invalid
+func NewServiceFromConfigs(configs map[string]*Workspace, serverAdminToken string) (*Service, error) {
+	service := &Service{
+		workspaces:         map[string]*Workspace{},
+		idsByAdminToken:    map[string]string{},
+		destinationConfigs: map[string]storages.DestinationConfig{},
+		owners:             map[string]string{},
+	}
+
+	for id := range configs {
+		service.ids = append(service.ids, id)
+	}
+	sort.Strings(service.ids)
+
+	for _, id := range service.ids {
+		workspace, err := resolve(id, configs[id])
+		if err != nil {
+			return nil, err
+		}
+
+		if workspace.AdminToken != "" {
+			if workspace.AdminToken == serverAdminToken {
+				return nil, fmt.Errorf("Workspace [%s] admin_token must differ from server.admin_token", id)
+			}
+			if anotherId, ok := service.idsByAdminToken[workspace.AdminToken]; ok {
+				return nil, fmt.Errorf("Workspaces [%s] and [%s] have the same admin_token", anotherId, id)
+			}
+			service.idsByAdminToken[workspace.AdminToken] = id
+		}
+
+		service.workspaces[id] = workspace
+		service.tokens = append(service.tokens, workspace.Tokens...)
+		for _, token := range workspace.Tokens {
+			service.owners[token.Id] = id
+		}
+		for name, destination := range workspace.Destinations {
+			service.destinationConfigs[name] = destination
+			service.owners[name] = id
+			//workspace destinations have per token instances only of the workspace tokens
+			for _, token := range workspace.Tokens {
+				service.owners[name+tenantDelimiter+token.Id] = id
+			}
+		}
+	}
+
+	return service, nil
+}
+
+//Tokens return all workspaces tokens with instance-wide ids
+func (s *Service) Tokens() []authorization.Token {
+	return s.tokens
+}
+
+//Destinations return all workspaces destinations with instance-wide names
+func (s *Service) Destinations() map[string]storages.DestinationConfig {
+	return s.destinationConfigs
+}
+
+//GetIdByAdminToken return workspace id by the workspace admin token
+func (s *Service) GetIdByAdminToken(adminToken string) (string, bool) {
+	id, ok := s.idsByAdminToken[adminToken]
+	return id, ok
+}
+
+//Infos return all workspaces if workspaceId is empty or only the workspace
+func (s *Service) Infos(workspaceId string) []*Info {
+	infos := []*Info{}
+	for _, id := range s.ids {
+		if workspaceId != "" && workspaceId != id {
+			continue
+		}
+
+		workspace := s.workspaces[id]
+		info := &Info{Id: id, TokenIds: []string{}, Destinations: []string{}}
+		for _, token := range workspace.Tokens {
+			info.TokenIds = append(info.TokenIds, token.Id)
+		}
+		for name := range workspace.Destinations {
+			info.Destinations = append(info.Destinations, name)
+		}
+		sort.Strings(info.Destinations)
+		infos = append(infos, info)
+	}
+
+	return infos
+}
+
+//GetDestinationWorkspace return workspace id of the destination (or per token destination instance)
+//return "" if the destination doesn't belong to any workspace
+func (s *Service) GetDestinationWorkspace(destinationId string) string {
+	return s.owners[destinationId]
+}
+
+//Owns return true if workspaceId is empty (server admin) or the destination (or per token destination instance) or the token
+//(token id) is one of the workspace resolved ones. Global destinations and tokens don't belong to any workspace
+//even if their names start with the workspace id
+func (s *Service) Owns(workspaceId, id string) bool {
+	return workspaceId == "" || s.owners[id] == workspaceId
+}
+
+//Owns return Instance.Owns
+func Owns(workspaceId, id string) bool {
+	return Instance.Owns(workspaceId, id)
+}
+
+//resolve return a copy of the workspace with instance-wide token ids and destination names:
+//$workspaceId.$tokenId and $workspaceId.$destinationName. Tokens destinations and destinations only_tokens are
+//resolved as well
+func resolve(id string, config *Workspace) (*Workspace, error) {
+	if strings.TrimSpace(id) == "" {
+		return nil, errors.New("Workspace id can't be empty")
+	}
+	if strings.Contains(id, Delimiter) {
+		return nil, fmt.Errorf("Workspace id [%s] can't contain '%s'", id, Delimiter)
+	}
+
+	workspace := &Workspace{Destinations: map[string]storages.DestinationConfig{}}
+	if config == nil {
+		return workspace, nil
+	}
+	workspace.AdminToken = strings.TrimSpace(config.AdminToken)
+
+	prefix := id + Delimiter
+	localIds := map[string]bool{}
+	for _, token := range config.Tokens {
+		if strings.TrimSpace(token.ClientSecret) == "" && strings.TrimSpace(token.ServerSecret) == "" {
+			return nil, fmt.Errorf("Workspace [%s] token [%s] must have client_secret or server_secret", id, token.Id)
+		}
+
+		localId := authorization.GetTokenId(token)
+		if localIds[localId] {
+			return nil, fmt.Errorf("Workspace [%s] token id [%s] is duplicated", id, localId)
+		}
+		localIds[localId] = true
+
+		token.Id = prefix + localId
+		token.Workspace = id
+		if len(token.Destinations) > 0 {
+			destinations := make([]string, 0, len(token.Destinations))
+			for _, name := range token.Destinations {
+				destinations = append(destinations, prefix+name)
+			}
+			token.Destinations = destinations
+		}
+		workspace.Tokens = append(workspace.Tokens, token)
+	}
+
+	for name, destination := range config.Destinations {
+		destination.Workspace = id
+		if len(destination.OnlyTokens) > 0 {
+			onlyTokens := make([]string, 0, len(destination.OnlyTokens))
+			for _, identity := range destination.OnlyTokens {
+				//local token id or client/server secret
+				if localIds[identity] {
+					identity = prefix + identity
+				}
+				onlyTokens = append(onlyTokens, identity)
+			}
+			destination.OnlyTokens = onlyTokens
+		}
+		workspace.Destinations[prefix+name] = destination
+	}
+
+	return workspace, nil
+}
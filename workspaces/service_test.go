@@ -0,0 +1,133 @@
+package workspaces
+
+import (
+	"github.com/jitsucom/eventnative/authorization"
+	"github.com/jitsucom/eventnative/storages"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestNewServiceFromConfigs(t *testing.T) {
+	tests := []struct {
+		name                 string
+		configs              map[string]*Workspace
+		expectedTokens       []authorization.Token
+		expectedDestinations map[string]storages.DestinationConfig
+		expectedErr          string
+	}{
+		{
+			"empty",
+			map[string]*Workspace{},
+			nil,
+			map[string]storages.DestinationConfig{},
+			"",
+		},
+		{
+			"resolved ids and names",
+			map[string]*Workspace{
+				"marketing": {
+					AdminToken: "marketing_admin",
+					Tokens: []authorization.Token{
+						{Id: "web", ClientSecret: "marketing_web"},
+						{Id: "backend", ServerSecret: "marketing_s2s", Destinations: []string{"pg"}},
+					},
+					Destinations: map[string]storages.DestinationConfig{
+						"pg": {Type: "postgres"},
+						"s3": {Type: "s3", OnlyTokens: []string{"web", "marketing_s2s"}},
+					},
+				},
+				"product": {
+					Tokens:       []authorization.Token{{Id: "web", ClientSecret: "product_web"}},
+					Destinations: map[string]storages.DestinationConfig{"pg": {Type: "postgres"}},
+				},
+			},
+			[]authorization.Token{
+				{Id: "marketing.web", ClientSecret: "marketing_web", Workspace: "marketing"},
+				{Id: "marketing.backend", ServerSecret: "marketing_s2s", Destinations: []string{"marketing.pg"}, Workspace: "marketing"},
+				{Id: "product.web", ClientSecret: "product_web", Workspace: "product"},
+			},
+			map[string]storages.DestinationConfig{
+				"marketing.pg": {Type: "postgres", Workspace: "marketing"},
+				"marketing.s3": {Type: "s3", OnlyTokens: []string{"marketing.web", "marketing_s2s"}, Workspace: "marketing"},
+				"product.pg":   {Type: "postgres", Workspace: "product"},
+			},
+			"",
+		},
+		{
+			"invalid id",
+			map[string]*Workspace{"a.b": {}},
+			nil,
+			nil,
+			"Workspace id [a.b] can't contain '.'",
+		},
+		{
+			"duplicated token id",
+			map[string]*Workspace{"a": {Tokens: []authorization.Token{{Id: "t", ClientSecret: "1"}, {Id: "t", ClientSecret: "2"}}}},
+			nil,
+			nil,
+			"Workspace [a] token id [t] is duplicated",
+		},
+		{
+			"token without secrets",
+			map[string]*Workspace{"a": {Tokens: []authorization.Token{{Id: "t"}}}},
+			nil,
+			nil,
+			"Workspace [a] token [t] must have client_secret or server_secret",
+		},
+		{
+			"server admin token",
+			map[string]*Workspace{"a": {AdminToken: "admin"}},
+			nil,
+			nil,
+			"Workspace [a] admin_token must differ from server.admin_token",
+		},
+		{
+			"shared admin token",
+			map[string]*Workspace{"a": {AdminToken: "ws_admin"}, "b": {AdminToken: "ws_admin"}},
+			nil,
+			nil,
+			"Workspaces [a] and [b] have the same admin_token",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, err := NewServiceFromConfigs(tt.configs, "admin")
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedTokens, service.Tokens())
+			require.Equal(t, tt.expectedDestinations, service.Destinations())
+		})
+	}
+}
+
+func TestOwns(t *testing.T) {
+	service, err := NewServiceFromConfigs(map[string]*Workspace{
+		"marketing": {
+			Tokens:       []authorization.Token{{Id: "web", ClientSecret: "marketing_web"}},
+			Destinations: map[string]storages.DestinationConfig{"pg": {Type: "postgres"}},
+		},
+		"product": {},
+	}, "admin")
+	require.NoError(t, err)
+
+	require.True(t, service.Owns("", "pg"))
+	require.True(t, service.Owns("marketing", "marketing.pg"))
+	require.True(t, service.Owns("marketing", "marketing.pg.marketing.web"))
+	require.True(t, service.Owns("marketing", "marketing.web"))
+	require.False(t, service.Owns("marketing", "pg"))
+	require.False(t, service.Owns("marketing", "marketing_old.pg"))
+	require.False(t, service.Owns("product", "marketing.pg"))
+	require.False(t, service.Owns("product", "marketing.web"))
+
+	//per token instances of global destination "marketing" and global tokens with the workspace prefix don't belong to the workspace
+	require.False(t, service.Owns("marketing", "marketing.globaltoken"))
+	require.False(t, service.Owns("marketing", "marketing.pg.globaltoken"))
+	require.False(t, service.Owns("marketing", "marketing.unknown"))
+
+	require.Equal(t, "marketing", service.GetDestinationWorkspace("marketing.pg.marketing.web"))
+	require.Equal(t, "", service.GetDestinationWorkspace("marketing.globaltoken"))
+}